@@ -0,0 +1,82 @@
+// Command flexnode-controller runs the FlexNode controller in the cluster. It watches FlexNode objects and
+// drives the registered agents towards their spec through the agents' webhook listeners.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/controller"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+)
+
+// Version information, set at build time
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
+func main() {
+	var (
+		kubeconfig      string
+		secretNamespace string
+		syncPeriod      time.Duration
+		logLevel        string
+	)
+	cmd := &cobra.Command{
+		Use:          "flexnode-controller",
+		Short:        "Manage AKS flex nodes through FlexNode objects",
+		Version:      fmt.Sprintf("%s (commit %s, built %s)", Version, GitCommit, BuildTime),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			level, err := logger.ParseLogLevel(logLevel)
+			if err != nil {
+				return err
+			}
+			if secretNamespace == "" {
+				return fmt.Errorf("--secret-namespace is required")
+			}
+			if syncPeriod < time.Second {
+				return fmt.Errorf("invalid --sync-period: %s. Expected at least 1s", syncPeriod)
+			}
+			log := logrus.New()
+			log.SetLevel(level)
+
+			// An empty kubeconfig selects the in-cluster configuration
+			restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+			if err != nil {
+				return fmt.Errorf("failed to load kubeconfig: %w", err)
+			}
+			client, err := dynamic.NewForConfig(restConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create Kubernetes client: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			log.Infof("Starting FlexNode controller %s with sync period %s", Version, syncPeriod)
+			if err := controller.New(client, secretNamespace, syncPeriod, log).Run(ctx); err != nil && ctx.Err() == nil {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig file; the in-cluster configuration is used when empty")
+	cmd.Flags().StringVar(&secretNamespace, "secret-namespace", "flexnode-system", "Namespace of the Secrets FlexNodes may reference; secretRefs to other namespaces are rejected")
+	cmd.Flags().DurationVar(&syncPeriod, "sync-period", 30*time.Second, "How often every FlexNode is checked in addition to watch events")
+	cmd.Flags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warning or error")
+
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		os.Exit(1)
+	}
+}
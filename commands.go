@@ -1,21 +1,56 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/history"
+	"go.goms.io/aks/AKSFlexNode/pkg/backup"
 	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/canary"
+	"go.goms.io/aks/AKSFlexNode/pkg/cis"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/preflight"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/configgen"
+	"go.goms.io/aks/AKSFlexNode/pkg/decommission"
+	"go.goms.io/aks/AKSFlexNode/pkg/diagnostics"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+	"go.goms.io/aks/AKSFlexNode/pkg/fleet"
+	"go.goms.io/aks/AKSFlexNode/pkg/flexnode"
+	"go.goms.io/aks/AKSFlexNode/pkg/gitops"
+	"go.goms.io/aks/AKSFlexNode/pkg/heartbeat"
 	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+	"go.goms.io/aks/AKSFlexNode/pkg/metrics"
+	"go.goms.io/aks/AKSFlexNode/pkg/nodecert"
+	"go.goms.io/aks/AKSFlexNode/pkg/nodespec"
+	"go.goms.io/aks/AKSFlexNode/pkg/patching"
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
+	"go.goms.io/aks/AKSFlexNode/pkg/rotation"
+	"go.goms.io/aks/AKSFlexNode/pkg/sbom"
 	"go.goms.io/aks/AKSFlexNode/pkg/spec"
 	"go.goms.io/aks/AKSFlexNode/pkg/status"
+	"go.goms.io/aks/AKSFlexNode/pkg/telemetry"
+	"go.goms.io/aks/AKSFlexNode/pkg/tui"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/webhook"
 )
 
 // Version information variables (set at build time)
@@ -25,98 +60,1204 @@ var (
 	BuildTime = "unknown"
 )
 
+const profileFlagUsage = "Directory to write per-step CPU and heap profiles and a bootstrap time summary to"
+
+// agentService is the systemd unit running the agent daemon
+const agentService = "aks-flex-node-agent"
+
 // NewAgentCommand creates a new agent command
 func NewAgentCommand() *cobra.Command {
+	var profileDir string
 	cmd := &cobra.Command{
 		Use:   "agent",
 		Short: "Start AKS node agent with Arc connection",
 		Long:  "Initialize and run the AKS node agent daemon with automatic status tracking and self-recovery",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgent(cmd.Context())
+			return runAgent(cmd.Context(), profileDir)
+		},
+	}
+
+	cmd.Flags().StringVar(&profileDir, "profile", "", profileFlagUsage)
+	return cmd
+}
+
+// NewInitCommand creates a new init command
+func NewInitCommand() *cobra.Command {
+	var opts configgen.Options
+	var output string
+	var force, nonInteractive bool
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Generate a configuration file",
+		Long: "Generate and validate a configuration file, choosing the subscription, AKS cluster and Arc resource group " +
+			"from those the credential can see; values not given as flags are asked for interactively",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInit(cmd, opts, output, force, nonInteractive)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&output, "output", "/etc/aks-flex-node/config.json", "Path of the configuration file, or - for standard output")
+	flags.BoolVar(&force, "force", false, "Overwrite an existing configuration file")
+	flags.BoolVar(&nonInteractive, "non-interactive", false, "Never prompt; fail when a required value has no flag and no single choice")
+	flags.StringVar(&opts.Auth, "auth", "", "Authentication method: arc, service-principal, managed-identity or bootstrap-token")
+	flags.StringVar(&opts.SubscriptionID, "subscription-id", "", "Subscription of the node's Azure resources")
+	flags.StringVar(&opts.TenantID, "tenant-id", "", "Tenant ID (defaults to the subscription's tenant)")
+	flags.StringVar(&opts.ClusterResourceID, "cluster-resource-id", "", "Resource ID of the target AKS cluster")
+	flags.StringVar(&opts.ClientID, "client-id", "", "Service principal client ID")
+	flags.StringVar(&opts.ClientSecret, "client-secret", "", "Service principal client secret")
+	flags.StringVar(&opts.ManagedIdentityClientID, "managed-identity-client-id", "", "Client ID of the managed identity, for VMs with several identities")
+	flags.StringVar(&opts.ArcResourceGroup, "arc-resource-group", "", "Resource group of the Arc machine")
+	flags.StringVar(&opts.ArcLocation, "arc-location", "", "Azure region of the Arc machine (defaults to the cluster region)")
+	flags.StringVar(&opts.ArcMachineName, "arc-machine-name", "", "Name of the Arc machine (defaults to the hostname)")
+	flags.StringVar(&opts.BootstrapToken, "bootstrap-token", "", "Bootstrap token in the form <token-id>.<token-secret>")
+	flags.StringVar(&opts.ServerURL, "server-url", "", "API server URL, for bootstrap token authentication")
+	flags.StringVar(&opts.CACertData, "ca-cert-data", "", "Base64-encoded cluster CA certificate, for bootstrap token authentication")
+	return cmd
+}
+
+// NewInstallCommand creates a new install command
+func NewInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Bootstrap the node interactively in a terminal UI",
+		Long:  "Run bootstrap once in a terminal UI that shows each component's progress and logs and can retry a failed component",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInstall(cmd.Context())
+		},
+	}
+
+	return cmd
+}
+
+// NewPlanCommand creates a new plan command
+func NewPlanCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "plan -f FILE",
+		Short: "Write a plan of the changes apply would make, for review",
+		Long: "Compute the settings that change from the state last applied and what each bootstrap step will do, " +
+			"print them and write them to a plan file. `apply --plan` carries out exactly that plan, and refuses " +
+			"to when the configuration or the node changed since",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPlan(cmd, output)
+		},
+	}
+
+	// -f names the same file as the global --config flag
+	cmd.Flags().StringVarP(&configPath, "filename", "f", "", "Path of the NodeSpec YAML or configuration JSON file")
+	_ = cmd.MarkFlagFilename("filename", "yaml", "yml", "json")
+	cmd.Flags().StringVarP(&output, "output", "o", "aks-flex-node.plan.json", "Path of the plan file to write")
+	return cmd
+}
+
+// NewApplyCommand creates a new apply command
+func NewApplyCommand() *cobra.Command {
+	var dryRun bool
+	var planPath string
+	cmd := &cobra.Command{
+		Use:   "apply -f FILE",
+		Short: "Converge the node to a NodeSpec",
+		Long: "Show how the desired state in a NodeSpec or configuration file differs from the state last applied, " +
+			"then install and reconfigure the node's components until they match it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if planPath != "" {
+				return runApplyPlan(cmd, planPath)
+			}
+			return runApply(cmd, dryRun)
 		},
 	}
 
-	return cmd
+	// -f names the same file as the global --config flag
+	cmd.Flags().StringVarP(&configPath, "filename", "f", "", "Path of the NodeSpec YAML or configuration JSON file")
+	_ = cmd.MarkFlagFilename("filename", "yaml", "yml", "json")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show the changes, without changing the node")
+	cmd.Flags().StringVar(&planPath, "plan", "", "Carry out a plan file written by the plan command, if the configuration and node are unchanged")
+	cmd.MarkFlagsMutuallyExclusive("dry-run", "plan")
+	return cmd
+}
+
+// NewUnbootstrapCommand creates a new unbootstrap command
+func NewUnbootstrapCommand() *cobra.Command {
+	var force, dryRun bool
+	cmd := &cobra.Command{
+		Use:   "unbootstrap",
+		Short: "Remove AKS node configuration and Arc connection",
+		Long:  "Clean up and remove all AKS node components and Arc registration from this machine. Refuses to run while pods or volumes are still on the node unless --force is given.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dryRun {
+				return runUnbootstrapDryRun(cmd.Context(), cmd.OutOrStdout())
+			}
+			return runUnbootstrap(cmd.Context(), force)
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Unbootstrap even if pods other than DaemonSet pods still run or volumes are still attached")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show what would be removed and the workloads that would be disrupted, without changing anything")
+	cmd.MarkFlagsMutuallyExclusive("dry-run", "force")
+	return cmd
+}
+
+// NewSwitchClusterCommand creates a new switch-cluster command
+func NewSwitchClusterCommand() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "switch-cluster <name>",
+		Short: "Move the node to another cluster listed in azure.clusters",
+		Long:  "Drain the node and remove it from its current cluster, then fetch credentials for the named cluster of azure.clusters, rewire the kubelet to it and update the Arc machine's role assignments and tags",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSwitchCluster(cmd.Context(), args[0], force)
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Switch even if the node cannot be drained, or rerun a switch to the current cluster that failed")
+	return cmd
+}
+
+// NewRotateCredentialsCommand creates a new rotate-credentials command
+func NewRotateCredentialsCommand() *cobra.Command {
+	var source rotation.Source
+	cmd := &cobra.Command{
+		Use:   "rotate-credentials",
+		Short: "Switch the node to a new service principal secret or certificate",
+		Long:  "Read a new service principal secret or certificate from Key Vault, a file or a secret reference, check that it obtains a token, store it in the configuration, and refresh the kubelet token script and the agent, after which the previous credential can be removed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRotateCredentials(cmd.Context(), source)
+		},
+	}
+
+	cmd.Flags().StringVar(&source.KeyVaultSecretID, "keyvault-secret", "", "Key Vault secret holding the new credential (default azure.servicePrincipal.rotation)")
+	cmd.Flags().StringVar(&source.File, "file", "", "File holding the new client secret or PEM certificate and key (default azure.servicePrincipal.rotation)")
+	cmd.Flags().StringVar(&source.Secret, "secret", "", "Secret reference to the new credential, e.g. env:NAME or pkcs11:<URI> (default azure.servicePrincipal.rotation)")
+	cmd.MarkFlagsMutuallyExclusive("keyvault-secret", "file", "secret")
+	return cmd
+}
+
+// NewResumeCommand creates a new resume command
+func NewResumeCommand() *cobra.Command {
+	var profileDir string
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Resume a bootstrap interrupted by a reboot",
+		Long:  "Continue bootstrap after a step required a reboot; run at boot by the aks-flex-node-resume systemd unit",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runResume(cmd.Context(), profileDir)
+		},
+	}
+
+	cmd.Flags().StringVar(&profileDir, "profile", "", profileFlagUsage)
+	return cmd
+}
+
+// NewSupportBundleCommand creates a new support-bundle command
+func NewSupportBundleCommand() *cobra.Command {
+	var output string
+	var duration, interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "support-bundle",
+		Short: "Collect logs, status and host metrics for support",
+		Long:  "Write a tarball with the agent log, node status and a short capture of CPU, memory, disk IO and network statistics",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSupportBundle(cmd.Context(), output, duration, interval)
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "Path of the bundle (default aks-flex-node-support-<timestamp>.tar.gz in the current directory)")
+	cmd.Flags().DurationVar(&duration, "metrics-duration", 30*time.Second, "How long to sample host metrics; 0 skips the capture")
+	cmd.Flags().DurationVar(&interval, "metrics-interval", time.Second, "Time between host metric samples")
+	return cmd
+}
+
+// NewBackupCommand creates a new backup command
+func NewBackupCommand() *cobra.Command {
+	var output, passphraseFile string
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write an encrypted snapshot of the node's identity and configuration",
+		Long:  "Write an encrypted snapshot of the agent configuration and state, the kubelet certificates and kubeconfigs, the rendered runtime and kubelet configuration, and the Arc agent state, to rebuild the node with restore after a hardware failure",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBackup(cmd.Context(), output, passphraseFile)
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "Path of the snapshot (default aks-flex-node-backup-<hostname>-<timestamp>.bin in the current directory)")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "File holding the passphrase the snapshot is encrypted with (required)")
+	_ = cmd.MarkFlagRequired("passphrase-file")
+	return cmd
+}
+
+// NewRestoreCommand creates a new restore command
+func NewRestoreCommand() *cobra.Command {
+	var input, passphraseFile string
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore a snapshot written by backup onto a replacement machine",
+		Long:  "Write back the files of a snapshot written by backup, so a replacement machine with the same hostname rejoins the cluster and Azure as the same node once the agent runs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRestore(cmd.Context(), input, passphraseFile, force)
+		},
+	}
+
+	cmd.Flags().StringVar(&input, "input", "", "Path of the snapshot (required)")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "File holding the passphrase the snapshot was encrypted with (required)")
+	cmd.Flags().BoolVar(&force, "force", false, "Restore even if the hostname differs from the snapshot's or the kubelet is running")
+	_ = cmd.MarkFlagRequired("input")
+	_ = cmd.MarkFlagRequired("passphrase-file")
+	return cmd
+}
+
+// NewSBOMCommand creates a new sbom command
+func NewSBOMCommand() *cobra.Command {
+	var format, output string
+	cmd := &cobra.Command{
+		Use:   "sbom",
+		Short: "Print the software bill of materials of the installed components",
+		Long:  "Print an SBOM listing the agent, every component bootstrap installed with the artifact it was downloaded from, and the files bootstrap installed with their SHA-256 digests",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSBOM(cmd, format, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", sbom.FormatSPDX, "Format of the SBOM: spdx (SPDX 2.3 JSON) or cyclonedx (CycloneDX 1.5 JSON)")
+	cmd.Flags().StringVar(&output, "output", "-", "Path to write the SBOM to, or - for stdout")
+	return cmd
+}
+
+// NewAssessCommand creates a new assess command
+func NewAssessCommand() *cobra.Command {
+	var asJSON bool
+	var minScore int
+	cmd := &cobra.Command{
+		Use:   "assess",
+		Short: "Assess the node against the CIS Kubernetes benchmark",
+		Long:  "Run the automated worker node checks of the CIS Kubernetes Benchmark against the installed kubelet configuration and file permissions, and print a scored report with the remediation of each failed check",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAssess(cmd, asJSON, minScore)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	cmd.Flags().IntVar(&minScore, "min-score", 0, "Exit with an error when the score, in percent, is below this")
+	return cmd
+}
+
+// NewFleetCommand creates the fleet command, whose subcommands work on many nodes from an operator's machine
+func NewFleetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fleet",
+		Short: "Work with many enrolled nodes at once",
+	}
+	cmd.AddCommand(newFleetStatusCommand())
+	return cmd
+}
+
+// newFleetStatusCommand creates the fleet status command
+func newFleetStatusCommand() *cobra.Command {
+	var inventoryPath, format string
+	var filter fleet.Filter
+	var parallel int
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "status --inventory FILE",
+		Short: "Show a health matrix of the enrolled nodes",
+		Long: "Query the status of every node in the inventory concurrently, through the agent's webhook listener or over SSH, " +
+			"and show whether kubelet, the container runtime and the Arc agent are healthy and which nodes run other versions than the rest of the fleet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inventory, err := fleet.LoadInventory(inventoryPath)
+			if err != nil {
+				return err
+			}
+			results := fleet.NewQuerier(inventory, parallel, timeout).Query(cmd.Context())
+			return fleet.Write(cmd.OutOrStdout(), filter.Apply(results), format)
+		},
+	}
+
+	cmd.Flags().StringVar(&inventoryPath, "inventory", "", "YAML or JSON file listing the nodes and how to reach them")
+	_ = cmd.MarkFlagRequired("inventory")
+	cmd.Flags().StringVarP(&format, "output", "o", fleet.FormatTable, "Output format: table, json or csv")
+	cmd.Flags().BoolVar(&filter.UnhealthyOnly, "unhealthy", false, "Only show nodes that are unhealthy or did not answer")
+	cmd.Flags().BoolVar(&filter.DriftOnly, "version-drift", false, "Only show nodes whose kubelet or agent version differs from most of the fleet")
+	cmd.Flags().IntVar(&parallel, "parallel", fleet.DefaultParallelism, "Number of nodes queried at the same time")
+	cmd.Flags().DurationVar(&timeout, "timeout", fleet.DefaultTimeout, "Time to wait for each node")
+	return cmd
+}
+
+// NewHistoryCommand creates the history command
+func NewHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show operations the agent carried out",
+	}
+	cmd.AddCommand(newAzureHistoryCommand())
+	return cmd
+}
+
+// newAzureHistoryCommand creates the history azure command
+func newAzureHistoryCommand() *cobra.Command {
+	var format string
+	var since time.Duration
+	var filter history.Filter
+	cmd := &cobra.Command{
+		Use:   "azure",
+		Short: "Show the changes the agent made to Azure resources",
+		Long: "Show the Azure operations the agent sent to create, update or delete resources, with the correlation ID " +
+			"of each, to match them with the Azure Activity Log",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := history.Read(history.Path)
+			if err != nil {
+				return err
+			}
+			if since > 0 {
+				filter.Since = time.Now().Add(-since)
+			}
+			return history.Write(cmd.OutOrStdout(), filter.Apply(entries), format)
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "output", "o", history.FormatTable, "Output format: table or json")
+	cmd.Flags().DurationVar(&since, "since", 0, "Only show operations of this long ago or later, e.g. 24h")
+	cmd.Flags().BoolVar(&filter.FailedOnly, "failed", false, "Only show operations that failed")
+	cmd.Flags().StringVar(&filter.Resource, "resource", "", "Only show operations on resources whose ID contains this")
+	return cmd
+}
+
+// NewVersionCommand creates a new version command
+func NewVersionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show version information",
+		Long:  "Display version, build commit, and build time information",
+		Run: func(cmd *cobra.Command, args []string) {
+			runVersion()
+		},
+	}
+
+	return cmd
+}
+
+// runAgent executes the bootstrap process and then runs as daemon
+func runAgent(ctx context.Context, profileDir string) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := loadAgentConfig()
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	result, err := runBootstrap(ctx, bootstrapper.New(cfg, logger), profileDir, logger)
+	telemetry.NewReporter(cfg, logger, Version).Report(ctx, "bootstrap", result, err)
+	if err != nil {
+		return err
+	}
+
+	// Handle and log the bootstrap result
+	if err := handleExecutionResult(result, "bootstrap", logger); err != nil {
+		return err
+	}
+	recordSBOM(ctx, cfg, result, logger)
+
+	// The resume unit continues bootstrap and the agent service starts again after the reboot
+	if result.RebootRequired {
+		return nil
+	}
+
+	// After successful bootstrap, transition to daemon mode
+	logger.Info(messages.Get(messages.BootstrapToDaemon))
+	return runDaemonLoop(ctx, cfg)
+}
+
+// runBootstrap runs bootstrap; when profileDir is set it writes CPU and heap profiles for each step
+// there and logs a summary of how much of each step went to downloads, Azure calls and local commands
+func runBootstrap(ctx context.Context, b *bootstrapper.Bootstrapper, profileDir string, logger *logrus.Logger) (*bootstrapper.ExecutionResult, error) {
+	if profileDir == "" {
+		return b.Bootstrap(ctx)
+	}
+
+	profiler, err := profiling.NewProfiler(profileDir, logger)
+	if err != nil {
+		return nil, err
+	}
+	b.SetObserver(stepProfiler{profiler})
+	result, err := b.Bootstrap(ctx)
+
+	var summary strings.Builder
+	if summaryErr := profiling.WriteSummary(&summary, profiler.Phases()); summaryErr == nil {
+		logger.Infof("Bootstrap time by step:\n%s", summary.String())
+	}
+	if path, summaryErr := profiler.WriteSummary(); summaryErr != nil {
+		logger.Warnf("Failed to write profile summary: %v", summaryErr)
+	} else {
+		logger.Infof("Bootstrap profiles written to %s (summary: %s)", profileDir, path)
+	}
+	return result, err
+}
+
+// stepProfiler profiles each bootstrap step as it runs
+type stepProfiler struct {
+	*profiling.Profiler
+}
+
+func (p stepProfiler) StepStarted(stepName string) {
+	p.Start(stepName)
+}
+
+func (p stepProfiler) StepFinished(result bootstrapper.StepResult) {
+	p.Finish(result.Duration)
+}
+
+// runInit generates, validates and writes the configuration file
+func runInit(cmd *cobra.Command, opts configgen.Options, output string, force, nonInteractive bool) error {
+	prompt := configgen.NoPrompter()
+	if !nonInteractive && term.IsTerminal(int(os.Stdin.Fd())) {
+		// Questions go to stderr so the configuration can be written to stdout
+		prompt = configgen.NewTerminalPrompter(os.Stdin, cmd.ErrOrStderr())
+	}
+
+	data, err := configgen.NewGenerator(opts, prompt).Generate(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if err := configgen.Validate(data); err != nil {
+		return err
+	}
+
+	if output == "-" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := configgen.Write(output, data, configgen.HasSecret(data), force); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Configuration written to %s\n", output)
+	return nil
+}
+
+// runInstall executes the bootstrap process in the terminal UI without entering daemon mode
+func runInstall(ctx context.Context) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	result, err := tui.RunInstall(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	return handleExecutionResult(result, "bootstrap", logger)
+}
+
+// runApply shows the changes from the last applied desired state and converges the node to the new one
+func runApply(cmd *cobra.Command, dryRun bool) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	_, err = applySpec(cmd.Context(), cfg, cmd.OutOrStdout(), dryRun)
+	return err
+}
+
+// runPlan writes the plan of applying the configuration to output
+func runPlan(cmd *cobra.Command, output string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	plan, err := makePlan(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), plan.Summary())
+	if err := nodespec.SavePlan(output, plan); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Plan %s written to %s; carry it out with: aks-flex-node apply -f %s --plan %s\n",
+		plan.Digest(), output, configPath, output)
+	return nil
+}
+
+// runApplyPlan converges the node to the configuration after checking that it still matches the plan at planPath
+func runApplyPlan(cmd *cobra.Command, planPath string) error {
+	reviewed, err := nodespec.LoadPlan(planPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	current, err := makePlan(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+	if err := reviewed.Verify(current); err != nil {
+		return fmt.Errorf("refusing to apply plan %s: %w", reviewed.Digest(), err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Applying plan %s\n", reviewed.Digest())
+
+	_, err = applySpec(cmd.Context(), cfg, cmd.OutOrStdout(), false)
+	return err
+}
+
+// makePlan computes what applySpec would do with cfg: the changed settings and each bootstrap step's action
+func makePlan(ctx context.Context, cfg *config.Config) (*nodespec.Plan, error) {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	desired, err := nodespec.Flatten(cfg)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := nodespec.LoadApplied(nodespec.AppliedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bootstrapper.New(cfg, logger)
+	if len(nodespec.Diff(applied, desired)) > 0 {
+		// As in applySpec, components that are already installed render their configuration again
+		b.Reconfigure()
+	}
+	planned, err := b.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]nodespec.PlanStep, len(planned))
+	for i, step := range planned {
+		steps[i] = nodespec.PlanStep{Name: step.Name, Action: step.Action}
+	}
+	return nodespec.NewPlan(Version, configPath, applied, desired, steps, time.Now()), nil
+}
+
+// applySpec writes the changes from the last applied desired state to out and, unless dryRun is set,
+// converges the node to cfg. It reports whether the node now matches cfg.
+func applySpec(ctx context.Context, cfg *config.Config, out io.Writer, dryRun bool) (bool, error) {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	desired, err := nodespec.Flatten(cfg)
+	if err != nil {
+		return false, err
+	}
+	applied, err := nodespec.LoadApplied(nodespec.AppliedPath)
+	if err != nil {
+		return false, err
+	}
+	changes := nodespec.Diff(applied, desired)
+	fmt.Fprintln(out, nodespec.Summary(changes))
+	if dryRun {
+		return false, nil
+	}
+
+	b := bootstrapper.New(cfg, logger)
+	if len(changes) > 0 {
+		// Components that are already installed render their configuration again from the new spec
+		b.Reconfigure()
+	}
+	result, err := b.Bootstrap(ctx)
+	if err == nil && result.Success && len(changes) > 0 && cfg.Agent.Canary.Enabled {
+		// The changed components are only reported as applied once a pod runs on the node with them
+		if err = canary.New(cfg, logger).Verify(ctx); err != nil {
+			err = fmt.Errorf("node failed verification with a smoke pod after the changes: %w", err)
+		}
+	}
+	telemetry.NewReporter(cfg, logger, Version).Report(ctx, "apply", result, err)
+	if err != nil {
+		return false, err
+	}
+	if err := handleExecutionResult(result, "apply", logger); err != nil {
+		return false, err
+	}
+	if result.Success {
+		recordAppliedSpec(desired, logger)
+	}
+	recordSBOM(ctx, cfg, result, logger)
+	return result.Success, nil
+}
+
+// recordAppliedSpec records the desired state bootstrap converged the node to, for the next apply to compare with
+func recordAppliedSpec(desired map[string]string, logger *logrus.Logger) {
+	if err := nodespec.SaveApplied(nodespec.AppliedPath, desired); err != nil {
+		logger.Warnf("Failed to record the applied desired state: %v", err)
+	}
+}
+
+// loadAgentConfig loads the configuration with the NodeSpec last applied from the agent's source on top, or
+// else the one of the last upgrade requested through the webhook listener
+func loadAgentConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	specPath := webhook.SpecPath
+	if cfg.IsSpecSourceConfigured() {
+		specPath = gitops.SpecPath
+	}
+	if _, err := os.Stat(specPath); err != nil {
+		return cfg, nil
+	}
+	return config.LoadLayeredConfig(configPath, specPath)
+}
+
+// syncSpecSource applies the NodeSpec published by the agent's source when its revision changed since the last
+// successful apply, and returns the configuration the node now runs with. A revision that fails to apply is
+// retried on the next poll.
+func syncSpecSource(ctx context.Context, cfg *config.Config, source gitops.Source) (*config.Config, error) {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	revision, spec, err := source.Fetch(ctx)
+	if err != nil {
+		return cfg, err
+	}
+	applied, err := gitops.LoadAppliedRevision(gitops.RevisionPath)
+	if err != nil {
+		return cfg, err
+	}
+	if revision == applied {
+		logger.Debugf("NodeSpec from %s is unchanged at revision %s", source, revision)
+		return cfg, nil
+	}
+
+	logger.Infof("Applying NodeSpec revision %s from %s", revision, source)
+	desired, converged, err := applyLayeredSpec(ctx, cfg, spec, gitops.PendingSpecPath, gitops.SpecPath)
+	if err != nil {
+		return desired, fmt.Errorf("failed to apply NodeSpec revision %s: %w", revision, err)
+	}
+	if !converged {
+		// A reboot interrupted the apply; the revision is applied again once the agent restarts
+		return desired, nil
+	}
+	if err := gitops.SaveAppliedRevision(gitops.RevisionPath, revision); err != nil {
+		return desired, err
+	}
+	logger.Infof("Node converged to NodeSpec revision %s", revision)
+	return desired, nil
+}
+
+// applyLayeredSpec applies spec on top of the local configuration and returns the configuration the node now
+// runs with. The spec is kept at pendingPath while it is applied and moved to specPath, where the agent loads it
+// from when it restarts, once the node converged.
+func applyLayeredSpec(ctx context.Context, cfg *config.Config, spec []byte, pendingPath, specPath string) (*config.Config, bool, error) {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	if err := gitops.SaveSpec(pendingPath, spec); err != nil {
+		return cfg, false, err
+	}
+	desired, err := config.LoadLayeredConfig(configPath, pendingPath)
+	if err != nil {
+		return cfg, false, messages.Errorf(messages.ConfigLoadFailed, pendingPath, err)
+	}
+
+	var summary strings.Builder
+	converged, err := applySpec(ctx, desired, &summary, false)
+	logger.Infof("Changes applied:\n%s", strings.TrimSpace(summary.String()))
+	if err != nil || !converged {
+		return cfg, false, err
+	}
+	if err := os.Rename(pendingPath, specPath); err != nil {
+		return desired, true, fmt.Errorf("failed to record applied NodeSpec: %w", err)
+	}
+	return desired, true, nil
+}
+
+// runWebhookAction runs a provisioning action accepted by the webhook listener and returns the configuration the
+// node runs with afterwards
+func runWebhookAction(ctx context.Context, cfg *config.Config, action webhook.Action) (*config.Config, error) {
+	logger := logger.GetLoggerFromContext(ctx)
+	logger.Infof("Running %s action %s requested through the webhook listener", action.Action, action.ID)
+
+	operation := "webhook " + action.Action
+	var result *bootstrapper.ExecutionResult
+	var err error
+	switch action.Action {
+	case webhook.Bootstrap:
+		if bootstrapper.RebootPending() {
+			return cfg, fmt.Errorf("a reboot requested by bootstrap is pending")
+		}
+		result, err = bootstrapper.New(cfg, logger).Bootstrap(ctx)
+	case webhook.Install:
+		if bootstrapper.RebootPending() {
+			return cfg, fmt.Errorf("a reboot requested by bootstrap is pending")
+		}
+		result, err = bootstrapper.New(cfg, logger).Reinstall(ctx, []string{action.Component})
+	case webhook.Upgrade:
+		if cfg.IsSpecSourceConfigured() {
+			return cfg, fmt.Errorf("the Kubernetes version is managed by agent.source; change it there")
+		}
+		desired, converged, err := applyLayeredSpec(ctx, cfg, webhook.UpgradeSpec(action.KubernetesVersion), webhook.PendingSpecPath, webhook.SpecPath)
+		if err == nil && !converged {
+			err = fmt.Errorf("upgrade to %s did not complete", action.KubernetesVersion)
+		}
+		return desired, err
+	case webhook.Drain:
+		return cfg, webhook.NewDrainer(cfg.Agent.Webhook.DrainKubeconfig).Drain(ctx)
+	case webhook.Uncordon:
+		return cfg, webhook.NewDrainer(cfg.Agent.Webhook.DrainKubeconfig).Uncordon(ctx)
+	default:
+		return cfg, fmt.Errorf("unknown action %q", action.Action)
+	}
+	telemetry.NewReporter(cfg, logger, Version).Report(ctx, operation, result, err)
+	if err != nil {
+		return cfg, err
+	}
+	recordSBOM(ctx, cfg, result, logger)
+	return cfg, handleExecutionResult(result, operation, logger)
+}
+
+// runUnbootstrap executes the unbootstrap process
+func runUnbootstrap(ctx context.Context, force bool) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	opts := []flexnode.Option{flexnode.WithLogger(logger), flexnode.WithVersion(Version)}
+	if force {
+		opts = append(opts, flexnode.WithForce())
+	}
+	result, err := flexnode.New(cfg, opts...).Uninstall(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Handle and log the result (unbootstrap is more lenient with failures)
+	return handleExecutionResult(result, "unbootstrap", logger)
+}
+
+// runUnbootstrapDryRun writes the files, services, Azure resources and cluster objects unbootstrap would
+// remove, and the workloads and volumes it would disrupt, to out
+func runUnbootstrapDryRun(ctx context.Context, out io.Writer) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+
+	plan := flexnode.New(cfg, flexnode.WithLogger(logger), flexnode.WithVersion(Version)).PlanUninstall(ctx)
+	for _, line := range plan.Lines() {
+		fmt.Fprintln(out, line)
+	}
+
+	report, err := decommission.NewChecker().Check()
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "Workloads disrupted: unknown, %v\n", err)
+	case !report.Busy():
+		fmt.Fprintf(out, "Workloads disrupted (0):\n  none on node %s\n", report.Node)
+	default:
+		fmt.Fprintf(out, "Workloads disrupted (%d workloads, %d volumes):\n", len(report.Workloads), len(report.Volumes))
+		for _, line := range report.Lines() {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+	fmt.Fprintln(out, "Dry run: nothing was removed")
+	return nil
+}
+
+// runSwitchCluster moves the node from its current cluster to another one of azure.clusters
+func runSwitchCluster(ctx context.Context, name string, force bool) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+	names := cfg.GetClusterNames()
+	if len(names) == 0 {
+		return fmt.Errorf("azure.clusters is not configured; list the clusters the node may join there")
+	}
+	name = strings.ToLower(name)
+	if !slices.Contains(names, name) {
+		return fmt.Errorf("unknown cluster %q. Expected one of %s", name, strings.Join(names, ", "))
+	}
+	previous := cfg.GetCurrentCluster()
+	if name == previous && !force {
+		logger.Infof("The node is already a member of cluster %s", name)
+		return nil
+	}
+	if bootstrapper.RebootPending() {
+		return fmt.Errorf("a reboot requested by bootstrap is pending")
+	}
+
+	// Leave the current cluster: move the workloads away and remove the node object
+	logger.Infof("Leaving cluster %s", previous)
+	if err := leaveCluster(ctx, webhook.NewDrainer(kubelet.KubeletKubeconfigPath), force, logger); err != nil {
+		return err
+	}
+
+	// Join the new cluster with the configuration resolved for it, so role assignment scopes follow the cluster
+	if err := config.RecordCurrentCluster(name); err != nil {
+		return fmt.Errorf("failed to record current cluster: %w", err)
+	}
+	target, err := config.LoadConfig(configPath)
+	if err != nil {
+		if restoreErr := config.RecordCurrentCluster(previous); restoreErr != nil {
+			logger.Warnf("Failed to restore the record of cluster %s: %v", previous, restoreErr)
+		}
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+	logger.Infof("Joining cluster %s (%s)", name, target.GetTargetClusterName())
+	result, err := bootstrapper.New(target, logger).Reinstall(ctx, []string{"ArcInstall", "KubeletInstaller"})
+	telemetry.NewReporter(target, logger, Version).Report(ctx, "switch-cluster", result, err)
+	if err == nil {
+		err = handleExecutionResult(result, "switch-cluster", logger)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to join cluster %s; run 'aks-flex-node switch-cluster %s --force' to retry: %w", name, name, err)
+	}
+	logger.Infof("The node is now a member of cluster %s. Restart the agent so it follows the new cluster, e.g. with 'systemctl restart aks-flex-node-agent'", name)
+	return nil
+}
+
+// runRotateCredentials switches the node to the credential published at source, or the configured source,
+// and restarts the agent so it uses it too
+func runRotateCredentials(ctx context.Context, source rotation.Source) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+	rotated, err := rotateCredentials(ctx, cfg, source)
+	if err != nil || !rotated {
+		return err
+	}
+	if utils.IsServiceActive(agentService) {
+		if err := utils.RestartService(agentService); err != nil {
+			return fmt.Errorf("the credential was rotated but restarting %s failed: %w", agentService, err)
+		}
+	}
+	logger.Info("The node uses the new credential; the previous one can be removed from the application")
+	return nil
+}
+
+// rotateCredentials reads the credential from source, or the configured source when source is empty, and
+// switches the node to it unless it is already in use. The credential must obtain a token before anything
+// changes. It reports whether the node switched.
+func rotateCredentials(ctx context.Context, cfg *config.Config, source rotation.Source) (bool, error) {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	if !cfg.IsSPConfigured() {
+		return false, fmt.Errorf("credential rotation requires service principal authentication (azure.servicePrincipal)")
+	}
+	if source.IsEmpty() {
+		source = rotation.SourceFromConfig(cfg)
+	}
+	if source.IsEmpty() {
+		return false, fmt.Errorf("no credential source; pass --keyvault-secret, --file or --secret, or set azure.servicePrincipal.rotation")
+	}
+
+	next, err := rotation.Read(ctx, cfg, source)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the credential from %s: %w", source, err)
+	}
+	current, err := rotation.Current(cfg)
+	if err != nil {
+		return false, err
+	}
+	if next.Equal(current) {
+		logger.Debugf("The credential published at %s is already in use", source)
+		return false, nil
+	}
+
+	logger.Infof("Found a new service principal credential at %s, checking that it obtains a token", source)
+	if err := rotation.Validate(ctx, cfg, next); err != nil {
+		return false, azerrors.Wrap(err)
+	}
+	if err := rotation.Apply(cfg, next); err != nil {
+		return false, err
+	}
+	logger.Infof("Stored the new credential in %s", cfg.GetConfigPath())
+
+	// The kubelet runs the token script whenever its token expires, so it needs no restart
+	updated, err := loadAgentConfig()
+	if err != nil {
+		return true, messages.Errorf(messages.ConfigLoadFailed, configPath, err)
+	}
+	if err := kubelet.NewInstaller(updated, logger).RefreshTokenScript(); err != nil {
+		return true, fmt.Errorf("failed to refresh the kubelet token script: %w", err)
+	}
+	return true, nil
+}
+
+// nodeCertificateCheckInterval is how often the agent checks whether the node certificate is due for renewal
+const nodeCertificateCheckInterval = time.Hour
+
+// renewNodeCertificate issues a new kubelet client certificate once the current one is due for renewal and
+// restarts the kubelet, which only reads its client certificate at start
+func renewNodeCertificate(ctx context.Context, cfg *config.Config) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	issuer, err := nodecert.NewIssuer(cfg)
+	if err != nil {
+		return err
+	}
+	cert, renewed, err := nodecert.Ensure(ctx, cfg, issuer, time.Now())
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		logger.Debugf("The node certificate is valid until %s", cert.NotAfter.Format(time.RFC3339))
+		return nil
+	}
+
+	logger.Infof("Renewed the node certificate from %s, valid until %s; restarting kubelet", issuer, cert.NotAfter.Format(time.RFC3339))
+	if err := utils.RunSystemCommand("systemctl", "restart", "kubelet"); err != nil {
+		return fmt.Errorf("failed to restart kubelet with the renewed certificate: %w", err)
+	}
+	return nil
 }
 
-// NewUnbootstrapCommand creates a new unbootstrap command
-func NewUnbootstrapCommand() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "unbootstrap",
-		Short: "Remove AKS node configuration and Arc connection",
-		Long:  "Clean up and remove all AKS node components and Arc registration from this machine",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return runUnbootstrap(cmd.Context())
-		},
+// leaveCluster drains the node and deletes its node object. Without --force a failed drain stops the switch
+// before anything changed; deleting the node object is best effort, as the old cluster may be unreachable.
+func leaveCluster(ctx context.Context, drainer *webhook.Drainer, force bool, logger *logrus.Logger) error {
+	if !utils.FileExists(kubelet.KubeletKubeconfigPath) {
+		logger.Info("The node was not bootstrapped; nothing to drain")
+		return nil
 	}
-
-	return cmd
+	if err := drainer.Drain(ctx); err != nil {
+		if !force {
+			return fmt.Errorf("failed to drain the node; pass --force to switch anyway: %w", err)
+		}
+		logger.Warnf("Switching without draining because of --force: %v", err)
+	}
+	if err := drainer.Delete(ctx); err != nil {
+		logger.Warnf("Failed to remove the node from its current cluster; delete it there with kubectl: %v", err)
+	}
+	return nil
 }
 
-// NewVersionCommand creates a new version command
-func NewVersionCommand() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "version",
-		Short: "Show version information",
-		Long:  "Display version, build commit, and build time information",
-		Run: func(cmd *cobra.Command, args []string) {
-			runVersion()
-		},
+// runResume continues a bootstrap that stopped for a reboot; it does nothing when no reboot interrupted bootstrap
+func runResume(ctx context.Context, profileDir string) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cfg, err := loadAgentConfig()
+	if err != nil {
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
 	}
 
-	return cmd
+	if !bootstrapper.ResumePending() {
+		logger.Info(messages.Get(messages.NothingToResume))
+		return nil
+	}
+
+	result, err := runBootstrap(ctx, bootstrapper.New(cfg, logger), profileDir, logger)
+	telemetry.NewReporter(cfg, logger, Version).Report(ctx, "resume", result, err)
+	if err != nil {
+		return err
+	}
+	recordSBOM(ctx, cfg, result, logger)
+	return handleExecutionResult(result, "bootstrap", logger)
 }
 
-// runAgent executes the bootstrap process and then runs as daemon
-func runAgent(ctx context.Context) error {
+// runSupportBundle collects the support bundle
+func runSupportBundle(ctx context.Context, output string, duration, interval time.Duration) error {
 	logger := logger.GetLoggerFromContext(ctx)
 
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+		return messages.Errorf(messages.ConfigLoadFailed, configPath, err)
 	}
 
-	bootstrapExecutor := bootstrapper.New(cfg, logger)
-	result, err := bootstrapExecutor.Bootstrap(ctx)
-	if err != nil {
+	if output == "" {
+		output = fmt.Sprintf("aks-flex-node-support-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	opts := diagnostics.BundleOptions{
+		Files: []string{
+			filepath.Join(cfg.Agent.LogDir, "aks-flex-node.log"),
+			status.GetStatusFilePath(),
+			history.Path,
+		},
+		MetricsDuration: duration,
+		MetricsInterval: interval,
+	}
+	if err := diagnostics.WriteBundle(ctx, output, opts, logger); err != nil {
 		return err
 	}
+	logger.Infof("Support bundle written to %s", output)
+	return nil
+}
 
-	// Handle and log the bootstrap result
-	if err := handleExecutionResult(result, "bootstrap", logger); err != nil {
+// runBackup writes the encrypted snapshot of the node
+func runBackup(ctx context.Context, output, passphraseFile string) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	passphrase, err := readPassphrase(passphraseFile)
+	if err != nil {
 		return err
 	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine hostname: %w", err)
+	}
+	if output == "" {
+		output = fmt.Sprintf("aks-flex-node-backup-%s-%s.bin", hostname, time.Now().Format("20060102-150405"))
+	}
 
-	// After successful bootstrap, transition to daemon mode
-	logger.Info("Bootstrap completed successfully, transitioning to daemon mode...")
-	return runDaemonLoop(ctx, cfg)
+	// The configuration may live outside /etc/aks-flex-node
+	paths := backup.DefaultPaths
+	if abs, err := filepath.Abs(configPath); err == nil {
+		paths = append(slices.Clone(paths), abs)
+	}
+
+	out, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot %s: %w", output, err)
+	}
+	manifest, err := backup.Create(out, "/", paths, backup.Manifest{Hostname: hostname, AgentVersion: Version, CreatedAt: time.Now().UTC()}, passphrase)
+	if err = errors.Join(err, out.Close()); err != nil {
+		_ = os.Remove(output)
+		return fmt.Errorf("failed to write snapshot %s: %w", output, err)
+	}
+	logger.Infof("Snapshot of %d files written to %s. Store it and the passphrase off this machine.", len(manifest.Files), output)
+	return nil
 }
 
-// runUnbootstrap executes the unbootstrap process
-func runUnbootstrap(ctx context.Context) error {
+// runRestore writes back a snapshot; the agent then brings up the node with the restored identity
+func runRestore(ctx context.Context, input, passphraseFile string, force bool) error {
 	logger := logger.GetLoggerFromContext(ctx)
 
-	cfg, err := config.LoadConfig(configPath)
+	passphrase, err := readPassphrase(passphraseFile)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	archive, err := backup.Open(in, passphrase)
+	_ = in.Close()
 	if err != nil {
-		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+		return err
 	}
+	manifest := archive.Manifest
+	logger.Infof("Snapshot of %s taken at %s by agent %s holds %d files", manifest.Hostname, manifest.CreatedAt.Format(time.RFC3339), manifest.AgentVersion, len(manifest.Files))
 
-	bootstrapExecutor := bootstrapper.New(cfg, logger)
-	result, err := bootstrapExecutor.Unbootstrap(ctx)
+	// The kubelet registers the node under its hostname, so a different hostname would join as a new node
+	hostname, err := os.Hostname()
 	if err != nil {
+		return fmt.Errorf("failed to determine hostname: %w", err)
+	}
+	if !strings.EqualFold(hostname, manifest.Hostname) && !force {
+		return fmt.Errorf("snapshot belongs to %s, not %s; set the hostname with 'hostnamectl set-hostname %s' or pass --force", manifest.Hostname, hostname, manifest.Hostname)
+	}
+	if utils.IsServiceActive("kubelet") && !force {
+		return fmt.Errorf("kubelet is running; stop it before restoring or pass --force")
+	}
+
+	if err := archive.Extract("/"); err != nil {
 		return err
 	}
+	logger.Infof("Restored %d files. Start the agent to bring up the node, e.g. with 'systemctl start aks-flex-node-agent'", len(manifest.Files))
+	return nil
+}
 
-	// Handle and log the result (unbootstrap is more lenient with failures)
-	return handleExecutionResult(result, "unbootstrap", logger)
+// readPassphrase reads a snapshot passphrase from a file, ignoring a trailing newline
+func readPassphrase(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+// runAssess prints the CIS benchmark report of the node
+func runAssess(cmd *cobra.Command, asJSON bool, minScore int) error {
+	report, err := cis.Assess()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(cmd.OutOrStdout())
+	}
+	if err != nil {
+		return err
+	}
+	if report.Score < minScore {
+		return fmt.Errorf("CIS benchmark score %d%% is below the minimum of %d%%", report.Score, minScore)
+	}
+	return nil
+}
+
+// runSBOM generates the SBOM from what the node has installed now
+func runSBOM(cmd *cobra.Command, format, output string) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine hostname: %w", err)
+	}
+	inventory, err := sbom.Collect(Version, hostname)
+	if err != nil {
+		return err
+	}
+	data, err := inventory.Encode(format)
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "SBOM written to %s\n", output)
+	return nil
+}
+
+// recordSBOM writes the SBOM after a successful bootstrap and, when configured, tags the Arc machine with it.
+// The node works without it, so failures are only logged.
+func recordSBOM(ctx context.Context, cfg *config.Config, result *bootstrapper.ExecutionResult, logger *logrus.Logger) {
+	if cfg.Agent.SBOM.Disabled || result == nil || !result.Success || result.RebootRequired {
+		return
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warnf("Failed to write the SBOM: failed to determine hostname: %v", err)
+		return
+	}
+	inventory, err := sbom.Collect(Version, hostname)
+	if err != nil {
+		logger.Warnf("Failed to write the SBOM: %v", err)
+		return
+	}
+	digest, err := inventory.Write()
+	if err != nil {
+		logger.Warnf("Failed to write the SBOM: %v", err)
+		return
+	}
+	logger.Infof("SBOM of %d components and %d files written to %s and %s", len(inventory.Components), len(inventory.Files), sbom.SPDXPath, sbom.CycloneDXPath)
+
+	if !cfg.Agent.SBOM.AttachToArc || !cfg.IsARCEnabled() {
+		return
+	}
+	tags := map[string]string{
+		sbom.DigestTag:     digest,
+		sbom.ComponentsTag: inventory.Summary(sbom.MaxTagValueLength),
+	}
+	if err := arc.TagMachine(ctx, cfg, logger, tags); err != nil {
+		logger.Warnf("Failed to attach the SBOM to the Arc machine: %v", err)
+		return
+	}
+	logger.Info("Tagged the Arc machine with the SBOM digest and component versions")
 }
 
 // runVersion displays version information
 func runVersion() {
-	fmt.Printf("AKS Flex Node Agent\n")
-	fmt.Printf("Version: %s\n", Version)
-	fmt.Printf("Git Commit: %s\n", GitCommit)
-	fmt.Printf("Build Time: %s\n", BuildTime)
+	fmt.Println(messages.Get(messages.VersionTitle))
+	fmt.Println(messages.Get(messages.VersionLine, Version))
+	fmt.Println(messages.Get(messages.GitCommitLine, GitCommit))
+	fmt.Println(messages.Get(messages.BuildTimeLine, BuildTime))
 }
 
 // runDaemonLoop runs the periodic status collection and bootstrap monitoring daemon
@@ -149,6 +1290,114 @@ func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
 	defer bootstrapTicker.Stop()
 	defer specTicker.Stop()
 
+	// Forward NPD problem metrics when a sink is configured; a nil channel never fires
+	var exporter *metrics.Exporter
+	var metricsTick <-chan time.Time
+	if cfg.IsNPDMetricsExportEnabled() {
+		var err error
+		if exporter, err = metrics.NewExporter(cfg, logger); err != nil {
+			logger.Warnf("NPD metrics export disabled: %v", err)
+		} else {
+			logger.Infof("Forwarding NPD problem metrics to %s every %s", exporter.Sink(), exporter.Interval())
+			metricsTicker := time.NewTicker(exporter.Interval())
+			defer metricsTicker.Stop()
+			metricsTick = metricsTicker.C
+		}
+	}
+
+	// Check installed binaries and configuration files against the digests recorded by bootstrap
+	var driftTick <-chan time.Time
+	if !cfg.Agent.Drift.Disabled {
+		driftTicker := time.NewTicker(cfg.GetDriftCheckInterval())
+		defer driftTicker.Stop()
+		driftTick = driftTicker.C
+	}
+
+	// Look for OS updates waiting for a reboot, and reboot for them in the maintenance window when configured
+	var patchingTick <-chan time.Time
+	if !cfg.Agent.Patching.Disabled {
+		patchingTicker := time.NewTicker(cfg.GetPatchingCheckInterval())
+		defer patchingTicker.Stop()
+		patchingTick = patchingTicker.C
+	}
+
+	// Catch role assignments removed in Azure before the kubelet or Arc agent fails on them
+	var roleAssignmentTick <-chan time.Time
+	if cfg.IsARCEnabled() && !cfg.Agent.RoleAssignments.Disabled {
+		roleAssignmentTicker := time.NewTicker(cfg.GetRoleAssignmentCheckInterval())
+		defer roleAssignmentTicker.Stop()
+		roleAssignmentTick = roleAssignmentTicker.C
+	}
+
+	// Switch to a new service principal credential as soon as it is published
+	var rotationTick <-chan time.Time
+	if cfg.IsCredentialRotationConfigured() && !cfg.Azure.ServicePrincipal.Rotation.Disabled {
+		logger.Infof("Checking %s for a new service principal credential every %s", rotation.SourceFromConfig(cfg), cfg.GetCredentialRotationInterval())
+		rotationTicker := time.NewTicker(cfg.GetCredentialRotationInterval())
+		defer rotationTicker.Stop()
+		rotationTick = rotationTicker.C
+	}
+
+	// Renew the kubelet's issued client certificate before it expires
+	var nodeCertTick <-chan time.Time
+	if cfg.IsNodeCertificateConfigured() {
+		nodeCertTicker := time.NewTicker(nodeCertificateCheckInterval)
+		defer nodeCertTicker.Stop()
+		nodeCertTick = nodeCertTicker.C
+	}
+
+	// Pull the NodeSpec from Git or an OCI registry and apply each new revision
+	var sourceTick <-chan time.Time
+	source := gitops.NewSource(cfg, logger)
+	if source != nil {
+		logger.Infof("Pulling NodeSpec from %s every %s", source, cfg.GetSpecSourceInterval())
+		sourceTicker := time.NewTicker(cfg.GetSpecSourceInterval())
+		defer sourceTicker.Stop()
+		sourceTick = sourceTicker.C
+	}
+
+	// Accept provisioning actions from a central controller; they run in this loop, one at a time
+	var hooks *webhook.Server
+	var webhookActions <-chan webhook.Action
+	if cfg.IsWebhookEnabled() {
+		var err error
+		if hooks, err = webhook.NewServer(ctx, cfg, bootstrapper.New(cfg, logger).BootstrapStepNames(), logger); err != nil {
+			logger.Warnf("Webhook listener disabled: %v", err)
+		} else {
+			// Fleet status queries read the status collected below
+			hooks.ServeStatus(statusFilePath)
+			go func() {
+				if err := hooks.Serve(ctx); err != nil {
+					logger.Warnf("Stopped listening for provisioning actions: %v", err)
+				}
+			}()
+			webhookActions = hooks.Actions()
+		}
+	}
+
+	// Share the artifact cache with nodes on the same LAN
+	if address := cfg.Downloads.Peers.ServeAddress; address != "" {
+		cache := download.NewCache(cfg.GetDownloadCacheDirectory(), nil)
+		go func() {
+			if err := download.ServePeers(ctx, address, cache, logger); err != nil {
+				logger.Warnf("Stopped serving the artifact cache to LAN peers: %v", err)
+			}
+		}()
+	}
+
+	// Publish the agent's liveness on the node. It runs apart from this loop so a long bootstrap does not look
+	// like a dead agent, and the daemon waits for it to mark the agent stopped before it exits.
+	if !cfg.Agent.Heartbeat.Disabled {
+		beat := heartbeat.New(cfg, Version, logger)
+		logger.Infof("Publishing the %s node condition every %s", heartbeat.ConditionType, beat.Interval())
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			beat.Run(ctx)
+		}()
+		defer func() { <-stopped }()
+	}
+
 	// Collect status immediately on start
 	if err := collectAndWriteStatus(ctx, cfg, statusFilePath); err != nil {
 		logger.Errorf("Failed to collect initial status: %v", err)
@@ -158,6 +1407,24 @@ func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
 	if err := collectAndWriteManagedClusterSpec(ctx, cfg); err != nil {
 		logger.Warnf("Failed to collect initial managed cluster spec: %v", err)
 	}
+	if err := collectGuestConfiguration(ctx, cfg); err != nil {
+		logger.Warnf("Failed to collect guest configuration assignments: %v", err)
+	}
+
+	// Check right away, which also brings the node back after a patch reboot the agent drained it for
+	if patchingTick != nil {
+		if err := checkOSPatches(ctx, cfg); err != nil {
+			logger.Warnf("OS patch check failed: %v", err)
+		}
+	}
+
+	// Apply the NodeSpec published by the source right away rather than after the first poll interval
+	if source != nil {
+		var err error
+		if cfg, err = syncSpecSource(ctx, cfg, source); err != nil {
+			logger.Errorf("Failed to sync NodeSpec from %s: %v", source, err)
+		}
+	}
 
 	// Run the periodic collection and monitoring loop
 	for {
@@ -181,6 +1448,50 @@ func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
 			} else {
 				logger.Infof("Bootstrap health check completed at %s", time.Now().Format("2006-01-02 15:04:05"))
 			}
+		case <-driftTick:
+			if err := checkDrift(ctx, cfg); err != nil {
+				logger.Warnf("Drift check failed: %v", err)
+			}
+		case <-patchingTick:
+			if err := checkOSPatches(ctx, cfg); err != nil {
+				logger.Warnf("OS patch check failed: %v", err)
+			}
+		case <-roleAssignmentTick:
+			if err := checkRoleAssignments(ctx, cfg); err != nil {
+				logger.Warnf("Role assignment check failed: %v", err)
+			}
+		case <-rotationTick:
+			rotated, err := rotateCredentials(ctx, cfg, rotation.Source{})
+			if err != nil {
+				logger.Errorf("Credential rotation failed: %v", err)
+			}
+			if rotated {
+				// Everything the daemon started holds the previous credential; the restart picks up the new one
+				logger.Infof("Rotated the service principal credential, restarting %s", agentService)
+				if err := utils.RunSystemCommand("systemctl", "restart", "--no-block", agentService); err != nil {
+					logger.Errorf("Failed to restart %s after credential rotation: %v", agentService, err)
+				}
+			}
+		case <-nodeCertTick:
+			if err := renewNodeCertificate(ctx, cfg); err != nil {
+				logger.Errorf("Node certificate renewal failed: %v", err)
+			}
+		case <-sourceTick:
+			var err error
+			if cfg, err = syncSpecSource(ctx, cfg, source); err != nil {
+				logger.Errorf("Failed to sync NodeSpec from %s: %v", source, err)
+			}
+		case action := <-webhookActions:
+			hooks.Started(action)
+			var err error
+			if cfg, err = runWebhookAction(ctx, cfg, action); err != nil {
+				logger.Errorf("Webhook action %s (%s) failed: %v", action.ID, action.Action, err)
+			}
+			hooks.Finished(action, err)
+		case <-metricsTick:
+			if err := exporter.Export(ctx); err != nil {
+				logger.Warnf("Failed to export NPD problem metrics: %v", err)
+			}
 		case <-specTicker.C:
 			logger.Infof("Starting periodic managed cluster spec collection at %s...", time.Now().Format("2006-01-02 15:04:05"))
 			if err := collectAndWriteManagedClusterSpec(ctx, cfg); err != nil {
@@ -188,6 +1499,9 @@ func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
 			} else {
 				logger.Infof("Managed cluster spec collection completed at %s", time.Now().Format("2006-01-02 15:04:05"))
 			}
+			if err := collectGuestConfiguration(ctx, cfg); err != nil {
+				logger.Warnf("Failed to collect guest configuration assignments: %v", err)
+			}
 		}
 	}
 }
@@ -199,12 +1513,39 @@ func collectAndWriteManagedClusterSpec(ctx context.Context, cfg *config.Config)
 	return err
 }
 
+// collectGuestConfiguration refreshes the guest configuration compliance reported in the node status
+func collectGuestConfiguration(ctx context.Context, cfg *config.Config) error {
+	if !cfg.IsARCEnabled() {
+		return nil
+	}
+	logger := logger.GetLoggerFromContext(ctx)
+	state, err := preflight.RefreshGuestConfiguration(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	for _, conflict := range state.Conflicts {
+		logger.Warnf("Azure Policy %s", conflict)
+	}
+	return nil
+}
+
 // checkAndBootstrap checks if the node needs re-bootstrapping and performs it if necessary
 func checkAndBootstrap(ctx context.Context, cfg *config.Config) error {
 	logger := logger.GetLoggerFromContext(ctx)
 	// Create status collector to check bootstrap requirements
 	collector := status.NewCollector(cfg, logger, Version)
 
+	// Bootstrap continues from the resume unit once the requested reboot happened
+	if bootstrapper.RebootPending() {
+		logger.Debug("Reboot pending, skipping auto-bootstrap check")
+		return nil
+	}
+	// A node going down for OS updates looks unhealthy; bootstrapping it would fight the reboot
+	if reason := patching.RebootInProgress(ctx, kubelet.KubeletKubeconfigPath); reason != "" {
+		logger.Infof("Skipping auto-bootstrap check: %s", reason)
+		return nil
+	}
+
 	// Check if bootstrap is needed
 	needsBootstrap := collector.NeedsBootstrap(ctx)
 	if !needsBootstrap {
@@ -216,6 +1557,7 @@ func checkAndBootstrap(ctx context.Context, cfg *config.Config) error {
 	// Perform bootstrap
 	bootstrapExecutor := bootstrapper.New(cfg, logger)
 	result, err := bootstrapExecutor.Bootstrap(ctx)
+	telemetry.NewReporter(cfg, logger, Version).Report(ctx, "auto-bootstrap", result, err)
 	if err != nil {
 		// Bootstrap failed - remove status file so next check will detect the problem
 		removeStatusFile(ctx)
@@ -228,11 +1570,156 @@ func checkAndBootstrap(ctx context.Context, cfg *config.Config) error {
 		removeStatusFile(ctx)
 		return fmt.Errorf("auto-bootstrap execution failed: %s", err)
 	}
+	recordSBOM(ctx, cfg, result, logger)
 
 	logger.Info("Auto-bootstrap completed successfully")
 	return nil
 }
 
+// checkDrift reports installed files changed outside of bootstrap and reinstalls them when remediation is enabled
+func checkDrift(ctx context.Context, cfg *config.Config) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	manifest, err := drift.Load(drift.ManifestPath)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		logger.Debug("No installed files recorded yet, skipping drift check")
+		return nil
+	}
+
+	findings := manifest.Check()
+	// NPD raises the drift event from the report
+	if err := drift.WriteReport(drift.ReportPath, findings); err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	for _, finding := range findings {
+		logger.Warnf("Drift detected: %s", finding)
+	}
+
+	if !cfg.Agent.Drift.Remediate {
+		return nil
+	}
+	if bootstrapper.RebootPending() {
+		logger.Debug("Reboot pending, skipping drift remediation")
+		return nil
+	}
+	if reason := patching.RebootInProgress(ctx, kubelet.KubeletKubeconfigPath); reason != "" {
+		logger.Infof("Skipping drift remediation: %s", reason)
+		return nil
+	}
+
+	logger.Infof("Remediating drift by re-running steps %s", strings.Join(drift.Steps(findings), ", "))
+	result, err := bootstrapper.New(cfg, logger).Remediate(ctx, findings)
+	if err != nil {
+		return fmt.Errorf("drift remediation failed: %w", err)
+	}
+	if err := handleExecutionResult(result, "drift remediation", logger); err != nil {
+		return err
+	}
+	return drift.WriteReport(drift.ReportPath, nil)
+}
+
+// checkRoleAssignments reports the required role assignments removed from Azure, and creates them again when
+// recreation is enabled
+func checkRoleAssignments(ctx context.Context, cfg *config.Config) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	missing, err := arc.VerifyRoleAssignments(ctx, cfg, logger, cfg.Agent.RoleAssignments.Recreate)
+	if azerrors.IsNotFound(err) {
+		logger.Debug("Arc machine not registered yet, skipping role assignment check")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, assignment := range missing {
+		if assignment.Recreated {
+			logger.Warnf("Recreated role assignment removed from Azure: %s", assignment)
+		} else {
+			logger.Errorf("Required role assignment missing: %s", assignment)
+		}
+	}
+	// NPD raises the RoleAssignmentMissing problem from the report
+	return arc.WriteRoleAssignmentReport(arc.RoleAssignmentReportPath, missing)
+}
+
+// checkOSPatches records OS updates waiting for a reboot and, with the maintenance-window reboot policy,
+// drains the node and reboots it once the window opens
+func checkOSPatches(ctx context.Context, cfg *config.Config) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	// A cancelled reboot would otherwise leave the node cordoned until the agent restarts
+	if err := finishPatchReboot(ctx, cfg); err != nil {
+		logger.Warnf("Failed to uncordon the node after the patch reboot: %v", err)
+	}
+
+	state := patching.Detect(ctx)
+	if err := patching.WriteState(state); err != nil {
+		return err
+	}
+	if !state.RebootRequired {
+		return nil
+	}
+	logger.Warnf("OS updates are waiting for a reboot: %s", strings.Join(state.Reasons, ", "))
+
+	if cfg.Agent.Patching.RebootPolicy != "maintenance-window" {
+		return nil
+	}
+	if bootstrapper.RebootPending() {
+		logger.Debug("Bootstrap reboot pending, leaving the patch reboot to it")
+		return nil
+	}
+	if reason := patching.RebootInProgress(ctx, kubelet.KubeletKubeconfigPath); reason != "" {
+		logger.Debugf("Not rebooting for OS updates: %s", reason)
+		return nil
+	}
+	if !bootstrapper.InMaintenanceWindow(time.Now(), cfg.Agent.Reboot.MaintenanceWindow) {
+		logger.Debugf("Waiting for the maintenance window %s to reboot for OS updates", cfg.Agent.Reboot.MaintenanceWindow)
+		return nil
+	}
+
+	drainer := webhook.NewDrainer(cfg.Agent.Patching.DrainKubeconfig)
+	logger.Info("Draining the node to reboot for OS updates")
+	if err := drainer.Drain(ctx); err != nil {
+		if uncordonErr := drainer.Uncordon(ctx); uncordonErr != nil {
+			logger.Warnf("Failed to uncordon the node after the drain failed: %v", uncordonErr)
+		}
+		return fmt.Errorf("failed to drain the node for the patch reboot: %w", err)
+	}
+	// Recorded before the reboot so the daemon uncordons the node when it starts again
+	if err := patching.RecordDrain(); err != nil {
+		return err
+	}
+	if err := utils.RunSystemCommand("shutdown", "-r", "+1", "aks-flex-node: rebooting to apply OS updates"); err != nil {
+		return fmt.Errorf("failed to schedule the patch reboot: %w", err)
+	}
+	logger.Info("Rebooting in 1 minute to apply OS updates")
+	return nil
+}
+
+// finishPatchReboot uncordons the node the agent drained for a patch reboot, once the machine rebooted or
+// when the scheduled reboot was cancelled
+func finishPatchReboot(ctx context.Context, cfg *config.Config) error {
+	drained, rebooted := patching.Drained()
+	if !drained || (!rebooted && patching.ShutdownScheduled()) {
+		return nil
+	}
+	logger := logger.GetLoggerFromContext(ctx)
+	if kubeconfig := cfg.Agent.Patching.DrainKubeconfig; kubeconfig != "" {
+		if err := webhook.NewDrainer(kubeconfig).Uncordon(ctx); err != nil {
+			return err
+		}
+		logger.Info("Uncordoned the node after the patch reboot")
+	} else {
+		logger.Warn("The node was drained for a patch reboot but agent.patching.drainKubeconfig is no longer set; uncordon it manually")
+	}
+	return patching.ClearDrain()
+}
+
 func removeStatusFile(ctx context.Context) {
 	logger := logger.GetLoggerFromContext(ctx)
 	statusFilePath := status.GetStatusFilePath()
@@ -279,22 +1766,31 @@ func collectAndWriteStatus(ctx context.Context, cfg *config.Config, statusFilePa
 // handleExecutionResult processes and logs execution results
 func handleExecutionResult(result *bootstrapper.ExecutionResult, operation string, logger *logrus.Logger) error {
 	if result == nil {
-		return fmt.Errorf("%s result is nil", operation)
+		return messages.Errorf(messages.OperationResultNil, operation)
+	}
+
+	if result.RebootRequired {
+		logger.Warn(messages.Get(messages.RebootRequired, operation, result.RebootStep))
+		if !result.RebootScheduledAt.IsZero() {
+			logger.Warn(messages.Get(messages.RebootScheduled, result.RebootScheduledAt.Format(time.RFC1123)))
+		} else {
+			logger.Warn(messages.Get(messages.RebootManual))
+		}
+		return nil
 	}
 
 	if result.Success {
-		logger.Infof("%s completed successfully (duration: %v, steps: %d)",
-			operation, result.Duration, result.StepCount)
+		logger.Info(messages.Get(messages.OperationSucceeded, operation, result.Duration, result.StepCount))
 		return nil
 	}
 
 	if operation == "unbootstrap" {
 		// For unbootstrap, log warnings but don't fail completely
-		logger.Warnf("%s completed with some failures: %s (duration: %v)",
-			operation, result.Error, result.Duration)
+		logger.Warn(messages.Get(messages.OperationPartialFail, operation, result.Error, result.Duration))
+		logger.Warn(messages.Get(messages.HintPrefix, messages.Get(messages.HintUnbootstrap)))
 		return nil
 	}
 
-	// For bootstrap, return error on failure
-	return fmt.Errorf("%s failed: %s", operation, result.Error)
+	// For bootstrap, return error on failure. The executor already printed the hint for the failed step.
+	return messages.Errorf(messages.OperationFailed, operation, result.Error)
 }
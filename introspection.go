@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// cliInfo is the machine-readable description of the CLI printed by `commands --json`
+type cliInfo struct {
+	Version string `json:"version"`
+	commandInfo
+}
+
+// commandInfo describes one command and its subcommands
+type commandInfo struct {
+	Name           string        `json:"name"`
+	Path           string        `json:"path"`
+	Short          string        `json:"short,omitempty"`
+	Long           string        `json:"long,omitempty"`
+	Usage          string        `json:"usage"`
+	Aliases        []string      `json:"aliases,omitempty"`
+	RequiresConfig bool          `json:"requiresConfig"`
+	Flags          []flagInfo    `json:"flags,omitempty"`
+	Subcommands    []commandInfo `json:"subcommands,omitempty"`
+}
+
+// flagInfo describes one flag; persistent flags are inherited by subcommands
+type flagInfo struct {
+	Name       string `json:"name"`
+	Shorthand  string `json:"shorthand,omitempty"`
+	Type       string `json:"type"`
+	Default    string `json:"default,omitempty"`
+	Usage      string `json:"usage"`
+	Persistent bool   `json:"persistent,omitempty"`
+}
+
+// NewCommandsCommand creates a new commands command
+func NewCommandsCommand() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "commands",
+		Short: "List available commands and flags",
+		Long:  "List all commands with their flags; --json prints a machine-readable description for wrapper tooling",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := cliInfo{Version: Version, commandInfo: describeCommand(cmd.Root())}
+			if asJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(info)
+			}
+			return writeCommandTable(cmd.OutOrStdout(), info.commandInfo)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the command tree as JSON")
+	return cmd
+}
+
+// requiresConfig reports whether a command needs --config; init, which writes it, commands that only describe the CLI,
+// and fleet commands, which run on an operator's machine, do not
+func requiresConfig(cmd *cobra.Command) bool {
+	for c := cmd; c != nil && c.HasParent(); c = c.Parent() {
+		switch c.Name() {
+		case "init", "restore", "fleet", "history", "version", "commands", "completion", "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return false
+		}
+	}
+	return cmd.HasParent()
+}
+
+// describeCommand builds the description of cmd and its visible subcommands
+func describeCommand(cmd *cobra.Command) commandInfo {
+	info := commandInfo{
+		Name:           cmd.Name(),
+		Path:           cmd.CommandPath(),
+		Short:          cmd.Short,
+		Long:           cmd.Long,
+		Usage:          cmd.UseLine(),
+		Aliases:        cmd.Aliases,
+		RequiresConfig: cmd.Runnable() && requiresConfig(cmd),
+	}
+
+	// cobra adds --help lazily to the command being run only, so leave it out everywhere
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Name != "help" {
+			info.Flags = append(info.Flags, describeFlag(f, false))
+		}
+	})
+	cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		info.Flags = append(info.Flags, describeFlag(f, true))
+	})
+	sort.Slice(info.Flags, func(i, j int) bool { return info.Flags[i].Name < info.Flags[j].Name })
+
+	for _, sub := range cmd.Commands() {
+		if sub.Hidden || sub.Name() == "help" {
+			continue
+		}
+		info.Subcommands = append(info.Subcommands, describeCommand(sub))
+	}
+	return info
+}
+
+func describeFlag(f *pflag.Flag, persistent bool) flagInfo {
+	return flagInfo{
+		Name:       f.Name,
+		Shorthand:  f.Shorthand,
+		Type:       f.Value.Type(),
+		Default:    f.DefValue,
+		Usage:      f.Usage,
+		Persistent: persistent,
+	}
+}
+
+// writeCommandTable prints one line per command with its own flags
+func writeCommandTable(w io.Writer, root commandInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMMAND\tDESCRIPTION\tFLAGS")
+	var walk func(info commandInfo)
+	walk = func(info commandInfo) {
+		if info.Path != root.Path {
+			flags := make([]string, len(info.Flags))
+			for i, f := range info.Flags {
+				flags[i] = "--" + f.Name
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", info.Path, info.Short, strings.Join(flags, " "))
+		}
+		for _, sub := range info.Subcommands {
+			walk(sub)
+		}
+	}
+	walk(root)
+	return tw.Flush()
+}
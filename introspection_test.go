@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newTestRoot() *cobra.Command {
+	root := &cobra.Command{Use: "aks-flex-node"}
+	root.PersistentFlags().String("config", "", "config file")
+	agent := &cobra.Command{Use: "agent", Short: "Run the agent", RunE: func(*cobra.Command, []string) error { return nil }}
+	bundle := &cobra.Command{Use: "support-bundle", RunE: func(*cobra.Command, []string) error { return nil }}
+	bundle.Flags().String("output", "out.tar.gz", "bundle path")
+	hidden := &cobra.Command{Use: "secret", Hidden: true, Run: func(*cobra.Command, []string) {}}
+	root.AddCommand(agent, bundle, hidden, NewVersionCommand(), NewCommandsCommand(), NewFleetCommand(), NewHistoryCommand())
+	return root
+}
+
+func TestRequiresConfig(t *testing.T) {
+	root := newTestRoot()
+	root.InitDefaultCompletionCmd()
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: []string{"agent"}, want: true},
+		{args: []string{"support-bundle"}, want: true},
+		{args: []string{"version"}, want: false},
+		{args: []string{"commands"}, want: false},
+		{args: []string{"fleet", "status"}, want: false},
+		{args: []string{"history", "azure"}, want: false},
+		{args: []string{"completion", "bash"}, want: false},
+		{args: []string{}, want: false},
+	}
+	for _, tt := range tests {
+		cmd, _, err := root.Find(tt.args)
+		if err != nil {
+			t.Fatalf("Find(%v) error = %v", tt.args, err)
+		}
+		if got := requiresConfig(cmd); got != tt.want {
+			t.Errorf("requiresConfig(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCommandsJSON(t *testing.T) {
+	root := newTestRoot()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"commands", "--json"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var info cliInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if info.Name != "aks-flex-node" || len(info.Flags) != 1 || !info.Flags[0].Persistent {
+		t.Errorf("root = %+v", info)
+	}
+
+	byName := map[string]commandInfo{}
+	for _, sub := range info.Subcommands {
+		byName[sub.Name] = sub
+	}
+	if _, ok := byName["secret"]; ok {
+		t.Error("hidden commands should be left out")
+	}
+	if _, ok := byName["help"]; ok {
+		t.Error("the help command should be left out")
+	}
+	if !byName["agent"].RequiresConfig || byName["version"].RequiresConfig {
+		t.Errorf("requiresConfig: agent = %v, version = %v", byName["agent"].RequiresConfig, byName["version"].RequiresConfig)
+	}
+	flags := byName["support-bundle"].Flags
+	if len(flags) != 1 || flags[0].Name != "output" || flags[0].Type != "string" || flags[0].Default != "out.tar.gz" {
+		t.Errorf("support-bundle flags = %+v", flags)
+	}
+	for _, f := range byName["commands"].Flags {
+		if f.Name == "help" {
+			t.Error("--help should be left out")
+		}
+	}
+}
+
+func TestCommandsTable(t *testing.T) {
+	root := newTestRoot()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"commands"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "aks-flex-node support-bundle") || !strings.Contains(out.String(), "--output") {
+		t.Errorf("table output:\n%s", out.String())
+	}
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
 	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
 )
 
 var (
@@ -18,6 +21,13 @@ var (
 )
 
 func main() {
+	// Pick up the locale from the environment first so early errors are localized too.
+	// An unsupported environment locale silently falls back to English.
+	_ = messages.SetLocale(messages.DetectLocale())
+
+	// Binaries installed to /opt/bin on immutable hosts are run by name like the distribution's
+	osimage.ExtendPath()
+
 	rootCmd := &cobra.Command{
 		Use:   "aks-flex-node",
 		Short: "AKS Flex Node Agent",
@@ -25,13 +35,29 @@ func main() {
 	}
 
 	// Add global flags for configuration
-	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to configuration JSON file (required)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to configuration JSON or NodeSpec YAML file (required)")
 	// Don't mark as required globally - we'll check in PersistentPreRunE for commands that need it
+	_ = rootCmd.MarkPersistentFlagFilename("config", "json", "yaml", "yml")
 
 	// Add commands
+	rootCmd.AddCommand(NewInitCommand())
 	rootCmd.AddCommand(NewAgentCommand())
+	rootCmd.AddCommand(NewInstallCommand())
+	rootCmd.AddCommand(NewPlanCommand())
+	rootCmd.AddCommand(NewApplyCommand())
 	rootCmd.AddCommand(NewUnbootstrapCommand())
+	rootCmd.AddCommand(NewSwitchClusterCommand())
+	rootCmd.AddCommand(NewRotateCredentialsCommand())
+	rootCmd.AddCommand(NewResumeCommand())
+	rootCmd.AddCommand(NewSupportBundleCommand())
+	rootCmd.AddCommand(NewBackupCommand())
+	rootCmd.AddCommand(NewRestoreCommand())
+	rootCmd.AddCommand(NewSBOMCommand())
+	rootCmd.AddCommand(NewAssessCommand())
+	rootCmd.AddCommand(NewFleetCommand())
+	rootCmd.AddCommand(NewHistoryCommand())
 	rootCmd.AddCommand(NewVersionCommand())
+	rootCmd.AddCommand(NewCommandsCommand())
 
 	// Set up context with signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -43,26 +69,33 @@ func main() {
 	go func() {
 		<-sigCh
 		// Use a basic logger for shutdown signal since context may not be available
-		fmt.Println("Received shutdown signal, cancelling operations...")
+		fmt.Println(messages.Get(messages.ShutdownSignal))
 		cancel()
 	}()
 
 	// Set up persistent pre-run to initialize config and logger
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
-		// Skip config loading for version command
-		if cmd.Name() == "version" {
+		// Skip config loading for init and for commands that only describe the CLI, including shell completion
+		if !requiresConfig(cmd) {
 			return nil
 		}
 
 		// For other commands, config is required
 		if configPath == "" {
-			return fmt.Errorf("config path is required for %s command", cmd.Name())
+			return &errdefs.ConfigError{Err: messages.Errorf(messages.ConfigPathRequired, cmd.Name())}
 		}
 
 		// Load config if specified
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
-			return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+			return &errdefs.ConfigError{Path: configPath, Err: messages.Errorf(messages.ConfigLoadFailed, configPath, err)}
+		}
+
+		// A locale in the config file takes precedence over the environment
+		if cfg.Agent.Locale != "" {
+			if err := messages.SetLocale(cfg.Agent.Locale); err != nil {
+				fmt.Fprintln(os.Stderr, messages.Get(messages.UnsupportedLocale, err, messages.DefaultLocale))
+			}
 		}
 
 		// Setup logger and update context
@@ -73,7 +106,29 @@ func main() {
 
 	// Execute command with context
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "Command execution failed: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, messages.Get(messages.CommandFailed, err))
+		os.Exit(exitCode(err))
+	}
+}
+
+// Exit codes returned for each failure category so scripts can tell them apart
+const (
+	exitFailure       = 1
+	exitConfigError   = 2
+	exitPreflight     = 3
+	exitAzureAPIError = 4
+)
+
+// exitCode maps err to the process exit code of its failure category
+func exitCode(err error) int {
+	if _, ok := errdefs.As[*errdefs.ConfigError](err); ok {
+		return exitConfigError
+	}
+	if _, ok := errdefs.As[*errdefs.PreflightError](err); ok {
+		return exitPreflight
+	}
+	if _, ok := errdefs.As[*errdefs.AzureAPIError](err); ok {
+		return exitAzureAPIError
 	}
+	return exitFailure
 }
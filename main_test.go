@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "generic", err: errors.New("boom"), want: exitFailure},
+		{name: "config", err: &errdefs.ConfigError{Err: errors.New("bad")}, want: exitConfigError},
+		{name: "preflight", err: &errdefs.PreflightError{Err: errors.New("1 of 4 preflight checks failed")}, want: exitPreflight},
+		{
+			name: "azure error inside a component",
+			err: fmt.Errorf("bootstrap failed at step ArcInstall: %w",
+				&errdefs.ComponentError{Name: "ArcInstall", Err: &errdefs.AzureAPIError{Code: "AuthorizationFailed", Err: errors.New("403")}}),
+			want: exitAzureAPIError,
+		},
+		{
+			name: "preflight inside a component",
+			err:  &errdefs.ComponentError{Name: "Preflight", Err: &errdefs.PreflightError{Err: errors.New("failed")}},
+			want: exitPreflight,
+		},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -7,9 +7,16 @@ import (
 	"os/exec"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/blob"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/history"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/throttle"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/writelimit"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
 )
 
 // AuthProvider is a simple factory for Azure credentials
@@ -37,12 +44,12 @@ func (a *AuthProvider) UserCredential(cfg *config.Config) (azcore.TokenCredentia
 	if cfg.IsMIConfigured() {
 		return a.msiCredential(cfg)
 	}
-	return a.cliCredential()
+	return a.cliCredential(cfg)
 }
 
 // msiCredential creates managed identity credential for VM MSI with optional ClientID
 func (a *AuthProvider) msiCredential(cfg *config.Config) (azcore.TokenCredential, error) {
-	options := &azidentity.ManagedIdentityCredentialOptions{}
+	options := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: ClientOptions(cfg)}
 
 	// If ClientID is specified, use it to select a specific managed identity
 	if cfg.Azure.ManagedIdentity != nil && cfg.Azure.ManagedIdentity.ClientID != "" {
@@ -56,14 +63,74 @@ func (a *AuthProvider) msiCredential(cfg *config.Config) (azcore.TokenCredential
 	return cred, nil
 }
 
+// BrokerCredential returns the machine's managed identity credential used to request bootstrap tokens from the
+// configured broker, selecting the user-assigned identity given by the broker's client ID
+func (a *AuthProvider) BrokerCredential(cfg *config.Config) (azcore.TokenCredential, error) {
+	options := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: ClientOptions(cfg)}
+	if broker := cfg.Azure.BootstrapToken.Broker; broker.ClientID != "" {
+		options.ID = azidentity.ClientID(broker.ClientID)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed identity credential for the bootstrap token broker: %w", err)
+	}
+	return cred, nil
+}
+
+// NodeCertificateCredential returns the machine's managed identity credential used to issue the node certificate
+// from Key Vault, selecting the user-assigned identity given by the Key Vault issuer's client ID
+func (a *AuthProvider) NodeCertificateCredential(cfg *config.Config) (azcore.TokenCredential, error) {
+	options := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: ClientOptions(cfg)}
+	if kv := cfg.Azure.NodeCertificate.KeyVault; kv != nil && kv.ClientID != "" {
+		options.ID = azidentity.ClientID(kv.ClientID)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed identity credential for the node certificate issuer: %w", err)
+	}
+	return cred, nil
+}
+
 // serviceCredential creates service principal credential from config
 func (a *AuthProvider) serviceCredential(cfg *config.Config) (azcore.TokenCredential, error) {
-	cred, err := azidentity.NewClientSecretCredential(
-		cfg.Azure.ServicePrincipal.TenantID,
-		cfg.Azure.ServicePrincipal.ClientID,
-		cfg.Azure.ServicePrincipal.ClientSecret,
-		nil,
-	)
+	sp := cfg.Azure.ServicePrincipal
+	if sp.ClientCertificateFile == "" {
+		return ServicePrincipalCredential(cfg, sp.ClientSecret, nil)
+	}
+	certificate, err := os.ReadFile(sp.ClientCertificateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service principal certificate: %w", err)
+	}
+	return ServicePrincipalCredential(cfg, "", certificate)
+}
+
+// ServicePrincipalCredential creates a credential for the configured service principal from a client secret
+// or, when certificatePEM is set, from a PEM certificate and private key. It lets a new credential be tried
+// before it replaces the configured one.
+func ServicePrincipalCredential(cfg *config.Config, secret string, certificatePEM []byte) (azcore.TokenCredential, error) {
+	sp := cfg.Azure.ServicePrincipal
+	// Allow the credential to request tokens for the cluster and auxiliary tenants.
+	// This requires a multi-tenant app registration provisioned in each of those tenants.
+	if certificatePEM != nil {
+		certs, key, err := azidentity.ParseCertificates(certificatePEM, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service principal certificate: %w", err)
+		}
+		cred, err := azidentity.NewClientCertificateCredential(sp.TenantID, sp.ClientID, certs, key, &azidentity.ClientCertificateCredentialOptions{
+			ClientOptions:              ClientOptions(cfg),
+			AdditionallyAllowedTenants: cfg.GetAuxiliaryTenantIDs(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create service principal credential: %w", err)
+		}
+		return cred, nil
+	}
+
+	options := &azidentity.ClientSecretCredentialOptions{
+		ClientOptions:              ClientOptions(cfg),
+		AdditionallyAllowedTenants: cfg.GetAuxiliaryTenantIDs(),
+	}
+	cred, err := azidentity.NewClientSecretCredential(sp.TenantID, sp.ClientID, secret, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service principal credential: %w", err)
 	}
@@ -71,14 +138,96 @@ func (a *AuthProvider) serviceCredential(cfg *config.Config) (azcore.TokenCreden
 }
 
 // cliCredential creates Azure CLI credential
-func (a *AuthProvider) cliCredential() (azcore.TokenCredential, error) {
-	cred, err := azidentity.NewAzureCLICredential(nil)
+func (a *AuthProvider) cliCredential(cfg *config.Config) (azcore.TokenCredential, error) {
+	options := &azidentity.AzureCLICredentialOptions{
+		AdditionallyAllowedTenants: cfg.GetAuxiliaryTenantIDs(),
+	}
+	cred, err := azidentity.NewAzureCLICredential(options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CLI credential: %w", err)
 	}
 	return cred, nil
 }
 
+// ClusterCredential returns the user credential scoped to the target cluster's tenant,
+// for clients that call ARM in the cluster subscription
+func (a *AuthProvider) ClusterCredential(cfg *config.Config) (azcore.TokenCredential, error) {
+	cred, err := a.UserCredential(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.IsCrossTenant() {
+		return cred, nil
+	}
+	return WithTenant(cred, cfg.GetTargetClusterTenantID()), nil
+}
+
+// ClientOptions returns the pipeline options shared by all Azure clients. Each HTTP attempt is
+// bounded by the configured per-try timeout, so a stuck connection is abandoned and retried, and
+// paced by the shared throttle, so all clients back off when Azure throttles any of them. Every
+// change to an ARM resource is recorded in the Azure operation history. Certificates passed to
+// TrustCertificates are trusted in addition to the system roots.
+func ClientOptions(cfg *config.Config) policy.ClientOptions {
+	return policy.ClientOptions{
+		Retry:            policy.RetryOptions{TryTimeout: cfg.GetAzureTryTimeout()},
+		PerCallPolicies:  []policy.Policy{profiling.AzurePolicy(), history.Shared.Policy()},
+		PerRetryPolicies: []policy.Policy{profiling.AzureAttemptPolicy(), throttle.Shared.Policy()},
+		Transport:        transport(),
+	}
+}
+
+// ARMClientOptions returns ARM client options with the per-try timeout that also attach
+// auxiliary tenant tokens to every request when auxiliary tenants are needed, and hold a write
+// slot of the fleet for every write when azure.armWriteLimit is configured
+func ARMClientOptions(cfg *config.Config) *arm.ClientOptions {
+	options := &arm.ClientOptions{
+		ClientOptions:    ClientOptions(cfg),
+		AuxiliaryTenants: cfg.GetAuxiliaryTenantIDs(),
+	}
+	if limit := cfg.Azure.ARMWriteLimit; limit != nil {
+		leaser := writelimit.Lazy(func() (writelimit.Leaser, error) {
+			cred, err := NewAuthProvider().UserCredential(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get credential for the ARM write limit container: %w", err)
+			}
+			blobOptions := ClientOptions(cfg)
+			client, err := blob.NewClient(limit.ContainerURL, cred, &blobOptions)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		})
+		limiter := writelimit.NewLimiter(leaser, cfg.GetARMWriteConcurrency(), retry.RealClock)
+		options.PerRetryPolicies = append(options.PerRetryPolicies, limiter.Policy())
+	}
+	return options
+}
+
+// OperationContext derives a context for one Azure operation, bounded by the configured operation timeout.
+// The SDK retries within this deadline; a parent deadline that is sooner still applies.
+func OperationContext(ctx context.Context, cfg *config.Config) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, cfg.GetAzureOperationTimeout())
+}
+
+// tenantCredential requests tokens from a fixed tenant unless the caller asks for a specific one
+type tenantCredential struct {
+	cred     azcore.TokenCredential
+	tenantID string
+}
+
+// WithTenant wraps a credential so tokens are issued by the given tenant
+func WithTenant(cred azcore.TokenCredential, tenantID string) azcore.TokenCredential {
+	return &tenantCredential{cred: cred, tenantID: tenantID}
+}
+
+// GetToken implements azcore.TokenCredential
+func (t *tenantCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if options.TenantID == "" {
+		options.TenantID = t.tenantID
+	}
+	return t.cred.GetToken(ctx, options)
+}
+
 // GetAccessToken retrieves access token for given credential with default ARM scope
 func (a *AuthProvider) GetAccessToken(ctx context.Context, cred azcore.TokenCredential) (string, error) {
 	return a.GetAccessTokenForResource(ctx, cred, "https://management.azure.com/.default")
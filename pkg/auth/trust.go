@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+var (
+	trustMu sync.RWMutex
+	// trustedClient sends the requests of Azure clients when extra roots are trusted; nil uses the SDK's default
+	trustedClient *http.Client
+)
+
+// TrustCertificates makes the Azure clients created afterwards trust certs in addition to the system roots,
+// so they reach Azure through a TLS-inspecting proxy before its CA is installed into the host's trust store
+func TrustCertificates(certs []*x509.Certificate) {
+	trustMu.Lock()
+	defer trustMu.Unlock()
+	if len(certs) == 0 {
+		trustedClient = nil
+		return
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	trustedClient = &http.Client{Transport: transport}
+}
+
+// transport returns the client for the trusted certificates, or nil when none are trusted
+func transport() policy.Transporter {
+	trustMu.RLock()
+	defer trustMu.RUnlock()
+	if trustedClient == nil {
+		return nil
+	}
+	return trustedClient
+}
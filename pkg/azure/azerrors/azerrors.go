@@ -0,0 +1,319 @@
+// Package azerrors classifies errors returned by Azure Resource Manager and Microsoft Entra ID, so callers
+// decide on retries and remediation hints from error codes instead of matching message text.
+package azerrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+)
+
+// Category groups errors that are handled the same way
+type Category string
+
+const (
+	CategoryUnknown           Category = "Unknown"
+	CategoryNotFound          Category = "NotFound"
+	CategoryAlreadyExists     Category = "AlreadyExists"
+	CategoryPrincipalNotFound Category = "PrincipalNotFound"
+	CategoryForbidden         Category = "Forbidden"
+	CategoryUnauthorized      Category = "Unauthorized"
+	CategoryWrongTenant       Category = "WrongTenant"
+	CategoryTokenExpired      Category = "TokenExpired"
+	CategoryClockSkew         Category = "ClockSkew"
+	CategoryThrottled         Category = "Throttled"
+	CategoryTransient         Category = "Transient"
+)
+
+// maxClockSkew is how far the local clock may differ from the server's before tokens are rejected.
+// Entra ID and ARM tolerate about five minutes.
+const maxClockSkew = 5 * time.Minute
+
+// Classification describes an Azure error
+type Classification struct {
+	Category   Category
+	Code       string        // ARM or Entra ID error code, e.g. AuthorizationFailed or AADSTS700024
+	StatusCode int           // HTTP status code, 0 when unknown
+	Retryable  bool          // Whether retrying the same request can succeed
+	Hint       string        // How to fix the problem, empty when there is nothing specific to suggest
+	ClockSkew  time.Duration // Local clock minus server clock, when the response carried a Date header
+	RetryHints
+}
+
+// RetryHints are the pacing hints of an Azure response
+type RetryHints struct {
+	RetryAfter time.Duration // Wait the server asked for before the next request, 0 when it did not ask
+	Remaining  int           // Fewest requests left in the reported rate limit windows, -1 when none were reported
+}
+
+// rateLimitHeaderPrefix starts the headers ARM reports the requests left in each rate limit window with,
+// e.g. x-ms-ratelimit-remaining-subscription-reads
+const rateLimitHeaderPrefix = "X-Ms-Ratelimit-Remaining-"
+
+// codeCategories maps error codes to categories. Codes are compared case-insensitively.
+var codeCategories = map[string]Category{
+	"NotFound":                           CategoryNotFound,
+	"ResourceNotFound":                   CategoryNotFound,
+	"ResourceGroupNotFound":              CategoryNotFound,
+	"RoleAssignmentNotFound":             CategoryNotFound,
+	"RoleAssignmentExists":               CategoryAlreadyExists,
+	"PrincipalNotFound":                  CategoryPrincipalNotFound,
+	"AuthorizationFailed":                CategoryForbidden,
+	"LinkedAuthorizationFailed":          CategoryForbidden,
+	"Forbidden":                          CategoryForbidden,
+	"InvalidAuthenticationToken":         CategoryUnauthorized,
+	"AuthenticationFailed":               CategoryUnauthorized,
+	"InvalidAuthenticationTokenTenant":   CategoryWrongTenant,
+	"ExpiredAuthenticationToken":         CategoryTokenExpired,
+	"TooManyRequests":                    CategoryThrottled,
+	"SubscriptionRequestsThrottled":      CategoryThrottled,
+	"TenantRequestsThrottled":            CategoryThrottled,
+	"InternalServerError":                CategoryTransient,
+	"ServiceUnavailable":                 CategoryTransient,
+	"GatewayTimeout":                     CategoryTransient,
+	"RetryableError":                     CategoryTransient,
+	"AADSTS700024":                       CategoryClockSkew, // client assertion is not within its valid time range
+	"AADSTS500133":                       CategoryClockSkew, // assertion is not within its valid time range
+	"AADSTS90072":                        CategoryWrongTenant,
+	"AADSTS700016":                       CategoryWrongTenant, // application not found in the directory
+	"AADSTS7000215":                      CategoryUnauthorized,
+	"AADSTS7000222":                      CategoryUnauthorized, // client secret expired
+	"AADSTS50173":                        CategoryTokenExpired,
+	"MissingSubscriptionRegistration":    CategoryForbidden,
+	"DisallowedByAzurePolicy":            CategoryForbidden,
+	"RequestDisallowedByPolicy":          CategoryForbidden,
+	"ScopeLocked":                        CategoryForbidden,
+	"InvalidAuthenticationTokenAudience": CategoryUnauthorized,
+}
+
+// hints holds the remediation shown for each category
+var hints = map[Category]string{
+	CategoryForbidden:         "grant the identity a role that allows the operation on the scope, e.g. Owner or User Access Administrator for role assignments",
+	CategoryUnauthorized:      "check the configured credentials; for a service principal verify the client ID and that the secret has not expired",
+	CategoryWrongTenant:       "the token was issued by a different tenant than the resource's; check azure.tenantId and azure.targetCluster.tenantId",
+	CategoryTokenExpired:      "a freshly issued token was rejected as expired; check the system clock with 'timedatectl status'",
+	CategoryClockSkew:         "the system clock is off; enable time synchronization with 'timedatectl set-ntp true' and check 'timedatectl status'",
+	CategoryPrincipalNotFound: "the identity has not replicated yet; this usually resolves within a few minutes",
+	CategoryThrottled:         "Azure is throttling requests; retry later",
+	CategoryTransient:         "Azure returned a temporary error; retry later",
+}
+
+var (
+	errorCodePattern = regexp.MustCompile(`ERROR CODE: (\S+)`)
+	aadstsPattern    = regexp.MustCompile(`AADSTS\d+`)
+	statusPattern    = regexp.MustCompile(`\b([45]\d\d) (Bad Request|Unauthorized|Forbidden|Not Found|Conflict|Too Many Requests|Internal Server Error|Bad Gateway|Service Unavailable|Gateway Timeout)\b`)
+)
+
+// Classify inspects err and returns its classification. Errors that are not from Azure are CategoryUnknown.
+func Classify(err error) Classification {
+	return classifyAt(err, time.Now())
+}
+
+func classifyAt(err error, now time.Time) Classification {
+	if err == nil {
+		return Classification{Category: CategoryUnknown, RetryHints: Hints(nil, now)}
+	}
+
+	c := Classification{}
+	var resp *http.Response
+	var respErr *azcore.ResponseError
+	var authErr *azidentity.AuthenticationFailedError
+	switch {
+	case errors.As(err, &respErr):
+		c.Code = respErr.ErrorCode
+		c.StatusCode = respErr.StatusCode
+		resp = respErr.RawResponse
+	case errors.As(err, &authErr):
+		resp = authErr.RawResponse
+		if resp != nil {
+			c.StatusCode = resp.StatusCode
+		}
+	}
+
+	// Fall back to the message for errors that were flattened to text or come from Entra ID
+	message := err.Error()
+	if c.Code == "" {
+		if m := aadstsPattern.FindString(message); m != "" {
+			c.Code = m
+		} else if m := errorCodePattern.FindStringSubmatch(message); m != nil {
+			c.Code = m[1]
+		} else {
+			c.Code = knownCodeIn(message)
+		}
+	}
+	if c.StatusCode == 0 {
+		if m := statusPattern.FindStringSubmatch(message); m != nil {
+			c.StatusCode, _ = strconv.Atoi(m[1])
+		}
+	}
+
+	c.Category = categorize(c.Code, c.StatusCode)
+	c.RetryHints = Hints(resp, now)
+	if skew, ok := clockSkew(resp, now); ok {
+		c.ClockSkew = skew
+		// Authentication failures with a clock this far off are caused by the clock, whatever the code says
+		if skew > maxClockSkew || skew < -maxClockSkew {
+			switch c.Category {
+			case CategoryUnauthorized, CategoryTokenExpired, CategoryClockSkew:
+				c.Category = CategoryClockSkew
+			}
+		}
+	}
+
+	c.Retryable = retryable(c.Category)
+	c.Hint = hints[c.Category]
+	if c.Category == CategoryClockSkew && c.ClockSkew != 0 {
+		c.Hint = fmt.Sprintf("the system clock differs from Azure by %s; %s", c.ClockSkew.Round(time.Second), c.Hint)
+	}
+	return c
+}
+
+// knownCodeIn finds the longest known error code mentioned in message
+func knownCodeIn(message string) string {
+	var found string
+	for code := range codeCategories {
+		if len(code) > len(found) && strings.Contains(message, code) {
+			found = code
+		}
+	}
+	return found
+}
+
+// categorize maps an error code, or failing that the HTTP status, to a category
+func categorize(code string, status int) Category {
+	for known, category := range codeCategories {
+		if strings.EqualFold(known, code) {
+			return category
+		}
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return CategoryNotFound
+	case status == http.StatusConflict && strings.Contains(strings.ToLower(code), "exist"):
+		return CategoryAlreadyExists
+	case status == http.StatusUnauthorized:
+		return CategoryUnauthorized
+	case status == http.StatusForbidden:
+		return CategoryForbidden
+	case status == http.StatusTooManyRequests:
+		return CategoryThrottled
+	case status >= 500:
+		return CategoryTransient
+	}
+	return CategoryUnknown
+}
+
+// retryable reports whether a fresh attempt can succeed without changing anything
+func retryable(category Category) bool {
+	switch category {
+	case CategoryPrincipalNotFound, CategoryThrottled, CategoryTransient, CategoryTokenExpired:
+		return true
+	}
+	return false
+}
+
+// clockSkew computes local time minus the server time from the response's Date header
+func clockSkew(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(date), true
+}
+
+// Hints reads the retry and rate limit headers of an Azure response. resp may be nil.
+func Hints(resp *http.Response, now time.Time) RetryHints {
+	hints := RetryHints{Remaining: -1}
+	if resp == nil {
+		return hints
+	}
+	hints.RetryAfter = retryAfter(resp.Header, now)
+	for name, values := range resp.Header {
+		if !strings.HasPrefix(http.CanonicalHeaderKey(name), rateLimitHeaderPrefix) {
+			continue
+		}
+		for _, value := range values {
+			// Resource-specific limits are written as policy;remaining, e.g. Microsoft.Compute/HighCostGet3Min;107
+			if i := strings.LastIndexByte(value, ';'); i >= 0 {
+				value = value[i+1:]
+			}
+			remaining, err := strconv.Atoi(strings.TrimSpace(value))
+			if err == nil && remaining >= 0 && (hints.Remaining < 0 || remaining < hints.Remaining) {
+				hints.Remaining = remaining
+			}
+		}
+	}
+	return hints
+}
+
+// retryAfter reads the wait from retry-after-ms, x-ms-retry-after-ms or Retry-After, which holds either
+// seconds or an HTTP date
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	for _, name := range []string{"Retry-After-Ms", "X-Ms-Retry-After-Ms"} {
+		if ms, err := strconv.Atoi(strings.TrimSpace(header.Get(name))); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// Is reports whether err falls into the category
+func Is(err error, category Category) bool {
+	return err != nil && Classify(err).Category == category
+}
+
+// IsNotFound reports whether err means the resource does not exist
+func IsNotFound(err error) bool {
+	return Is(err, CategoryNotFound)
+}
+
+// IsRetryable reports whether retrying the request that failed with err can succeed
+func IsRetryable(err error) bool {
+	return err != nil && Classify(err).Retryable
+}
+
+// Wrap returns err as an *errdefs.AzureAPIError carrying its classification and remediation hint.
+// Errors that are not recognized as Azure errors are returned unchanged.
+func Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := errdefs.As[*errdefs.AzureAPIError](err); ok {
+		return err
+	}
+	c := Classify(err)
+	if c.Category == CategoryUnknown {
+		return err
+	}
+	return &errdefs.AzureAPIError{
+		Code:       c.Code,
+		Status:     c.StatusCode,
+		Category:   string(c.Category),
+		Retryable:  c.Retryable,
+		Hint:       c.Hint,
+		RetryAfter: c.RetryAfter,
+		Err:        err,
+	}
+}
@@ -0,0 +1,180 @@
+package azerrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+)
+
+func responseError(status int, code string, date time.Time) error {
+	header := http.Header{}
+	if !date.IsZero() {
+		header.Set("Date", date.UTC().Format(http.TimeFormat))
+	}
+	return &azcore.ResponseError{
+		ErrorCode:   code,
+		StatusCode:  status,
+		RawResponse: &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		err           error
+		wantCategory  Category
+		wantRetryable bool
+		wantHint      string
+	}{
+		{name: "nil", err: nil, wantCategory: CategoryUnknown},
+		{name: "plain error", err: errors.New("connection refused"), wantCategory: CategoryUnknown},
+		{name: "not found by status", err: responseError(404, "", now), wantCategory: CategoryNotFound},
+		{name: "role assignment exists", err: responseError(409, "RoleAssignmentExists", now), wantCategory: CategoryAlreadyExists},
+		{name: "authorization failed", err: responseError(403, "AuthorizationFailed", now), wantCategory: CategoryForbidden, wantHint: "grant the identity"},
+		{name: "wrong tenant", err: responseError(401, "InvalidAuthenticationTokenTenant", now), wantCategory: CategoryWrongTenant, wantHint: "azure.tenantId"},
+		{
+			name:          "expired token with synchronized clock",
+			err:           responseError(401, "ExpiredAuthenticationToken", now.Add(-30*time.Second)),
+			wantCategory:  CategoryTokenExpired,
+			wantRetryable: true,
+		},
+		{
+			name:         "expired token with clock ahead",
+			err:          responseError(401, "ExpiredAuthenticationToken", now.Add(-2*time.Hour)),
+			wantCategory: CategoryClockSkew,
+			wantHint:     "differs from Azure by 2h0m0s",
+		},
+		{
+			name:         "invalid token with clock behind",
+			err:          responseError(401, "InvalidAuthenticationToken", now.Add(20*time.Minute)),
+			wantCategory: CategoryClockSkew,
+			wantHint:     "timedatectl set-ntp true",
+		},
+		{name: "forbidden is not blamed on the clock", err: responseError(403, "AuthorizationFailed", now.Add(time.Hour)), wantCategory: CategoryForbidden},
+		{name: "throttled", err: responseError(429, "", now), wantCategory: CategoryThrottled, wantRetryable: true},
+		{name: "server error", err: responseError(503, "", now), wantCategory: CategoryTransient, wantRetryable: true},
+		{
+			name:         "entra assertion time range",
+			err:          errors.New("ClientCertificateCredential authentication failed: AADSTS700024: Client assertion is not within its valid time range"),
+			wantCategory: CategoryClockSkew,
+		},
+		{
+			name:          "flattened principal not found",
+			err:           errors.New("RESPONSE 400: 400 Bad Request\nERROR CODE: PrincipalNotFound\nPrincipal does not exist"),
+			wantCategory:  CategoryPrincipalNotFound,
+			wantRetryable: true,
+		},
+		{name: "flattened forbidden", err: errors.New("403 Forbidden: insufficient permissions"), wantCategory: CategoryForbidden},
+		{name: "code in message", err: errors.New("RoleAssignmentNotFound"), wantCategory: CategoryNotFound},
+		{name: "wrapped", err: fmt.Errorf("failed to delete: %w", responseError(404, "ResourceNotFound", now)), wantCategory: CategoryNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyAt(tt.err, now)
+			if got.Category != tt.wantCategory {
+				t.Errorf("Category = %s, want %s (code %q, status %d)", got.Category, tt.wantCategory, got.Code, got.StatusCode)
+			}
+			if got.Retryable != tt.wantRetryable {
+				t.Errorf("Retryable = %v, want %v", got.Retryable, tt.wantRetryable)
+			}
+			if !strings.Contains(got.Hint, tt.wantHint) {
+				t.Errorf("Hint = %q, want containing %q", got.Hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	base := responseError(403, "AuthorizationFailed", time.Time{})
+	wrapped := Wrap(fmt.Errorf("failed to create role assignment: %w", base))
+	if !errors.Is(wrapped, base) {
+		t.Error("Wrap() does not wrap the original error")
+	}
+	apiErr, ok := errdefs.As[*errdefs.AzureAPIError](wrapped)
+	if !ok {
+		t.Fatalf("Wrap() = %T, want *errdefs.AzureAPIError", wrapped)
+	}
+	if apiErr.Code != "AuthorizationFailed" || apiErr.Status != 403 || apiErr.Category != string(CategoryForbidden) {
+		t.Errorf("Wrap() = %+v, want code, status and category set", apiErr)
+	}
+	if !strings.Contains(wrapped.Error(), "(hint: grant the identity") {
+		t.Errorf("Wrap() = %q, want a hint", wrapped.Error())
+	}
+	if again := Wrap(wrapped); again != wrapped {
+		t.Error("Wrap() wrapped an AzureAPIError twice")
+	}
+
+	plain := errors.New("boom")
+	if got := Wrap(plain); got != plain {
+		t.Errorf("Wrap() = %v, want the error unchanged", got)
+	}
+	if Wrap(nil) != nil {
+		t.Error("Wrap(nil) != nil")
+	}
+}
+
+func TestHints(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   RetryHints
+	}{
+		{name: "no hints", want: RetryHints{Remaining: -1}},
+		{name: "seconds", header: map[string]string{"Retry-After": "17"}, want: RetryHints{RetryAfter: 17 * time.Second, Remaining: -1}},
+		{
+			name:   "date",
+			header: map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)},
+			want:   RetryHints{RetryAfter: time.Minute, Remaining: -1},
+		},
+		{
+			name:   "milliseconds take precedence",
+			header: map[string]string{"Retry-After": "2", "x-ms-retry-after-ms": "1500"},
+			want:   RetryHints{RetryAfter: 1500 * time.Millisecond, Remaining: -1},
+		},
+		{name: "invalid", header: map[string]string{"Retry-After": "soon"}, want: RetryHints{Remaining: -1}},
+		{
+			name: "fewest remaining requests",
+			header: map[string]string{
+				"x-ms-ratelimit-remaining-subscription-writes": "1199",
+				"x-ms-ratelimit-remaining-tenant-reads":        "42",
+				"x-ms-ratelimit-remaining-resource":            "Microsoft.Compute/HighCostGet3Min;107",
+			},
+			want: RetryHints{Remaining: 42},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			for name, value := range tt.header {
+				resp.Header.Set(name, value)
+			}
+			if got := Hints(resp, now); got != tt.want {
+				t.Errorf("Hints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	throttled := &azcore.ResponseError{
+		ErrorCode:   "SubscriptionRequestsThrottled",
+		StatusCode:  http.StatusTooManyRequests,
+		RawResponse: &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"30"}}},
+	}
+	if c := Classify(throttled); c.Category != CategoryThrottled || c.RetryAfter != 30*time.Second {
+		t.Errorf("Classify() = %+v, want throttled with a 30s Retry-After", c)
+	}
+	if apiErr, ok := errdefs.As[*errdefs.AzureAPIError](Wrap(throttled)); !ok || apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("Wrap() = %+v, want RetryAfter 30s", apiErr)
+	}
+}
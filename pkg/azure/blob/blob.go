@@ -0,0 +1,262 @@
+// Package blob reads and writes block blobs of one Azure Storage container through the Blob service REST API
+package blob
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+const (
+	storageScope = "https://storage.azure.com/.default"
+	apiVersion   = "2023-11-03"
+)
+
+// ErrNotFound is returned by Get for a blob that does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// ErrLeased is returned by AcquireLease while another client holds the lease of the blob
+var ErrLeased = errors.New("blob is leased")
+
+// Client reads and writes the blobs of a container
+type Client struct {
+	pipeline     runtime.Pipeline
+	containerURL string
+}
+
+// NewClient creates a client for the container at containerURL, e.g.
+// https://myaccount.blob.core.windows.net/aks-flex-node. The identity needs the Storage Blob Data
+// Contributor role on the container. options may be nil.
+func NewClient(containerURL string, cred azcore.TokenCredential, options *policy.ClientOptions) (*Client, error) {
+	if err := ValidateContainerURL(containerURL); err != nil {
+		return nil, err
+	}
+	client, err := azcore.NewClient("blob.Client", "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{storageScope}, nil)},
+	}, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Blob client: %w", err)
+	}
+	return &Client{pipeline: client.Pipeline(), containerURL: strings.TrimSuffix(containerURL, "/")}, nil
+}
+
+// ValidateContainerURL checks that containerURL is an https URL naming a container
+func ValidateContainerURL(containerURL string) error {
+	parsed, err := url.Parse(containerURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.RawQuery != "" ||
+		strings.Count(strings.Trim(parsed.Path, "/"), "/") != 0 || strings.Trim(parsed.Path, "/") == "" {
+		return fmt.Errorf("invalid Blob container URL %q: expected https://<account>.blob.core.windows.net/<container>", containerURL)
+	}
+	return nil
+}
+
+// Put uploads data as the block blob name, replacing it if it exists
+func (c *Client) Put(ctx context.Context, name string, data []byte) error {
+	req, err := c.newRequest(ctx, http.MethodPut, c.blobURL(name))
+	if err != nil {
+		return err
+	}
+	req.Raw().Header.Set("x-ms-blob-type", "BlockBlob")
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader(data)), "application/octet-stream"); err != nil {
+		return fmt.Errorf("failed to build Blob request: %w", err)
+	}
+	resp, err := c.do(req, http.StatusCreated)
+	if err != nil {
+		return fmt.Errorf("failed to upload blob %s: %w", name, err)
+	}
+	return resp.Body.Close()
+}
+
+// Get downloads the blob name. It returns ErrNotFound when the blob does not exist.
+func (c *Client) Get(ctx context.Context, name string) ([]byte, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, c.blobURL(name))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob %s: %w", name, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Delete removes the blob name. A blob that does not exist is not an error.
+func (c *Client) Delete(ctx context.Context, name string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, c.blobURL(name))
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, http.StatusAccepted, http.StatusNotFound)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", name, err)
+	}
+	return resp.Body.Close()
+}
+
+// AcquireLease takes a lease of the blob name for duration, between 15 and 60 seconds, creating an empty blob
+// when it does not exist. It returns the lease ID, or ErrLeased while another client holds the lease.
+func (c *Client) AcquireLease(ctx context.Context, name string, duration time.Duration) (string, error) {
+	for created := false; ; created = true {
+		req, err := c.newLeaseRequest(ctx, name, "acquire")
+		if err != nil {
+			return "", err
+		}
+		req.Raw().Header.Set("x-ms-lease-duration", strconv.Itoa(int(duration.Seconds())))
+		resp, err := c.do(req, http.StatusCreated, http.StatusConflict, http.StatusNotFound)
+		if err != nil {
+			return "", fmt.Errorf("failed to acquire lease of blob %s: %w", name, err)
+		}
+		_ = resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusCreated:
+			return resp.Header.Get("x-ms-lease-id"), nil
+		case resp.StatusCode == http.StatusConflict:
+			return "", fmt.Errorf("%w: %s", ErrLeased, name)
+		case created:
+			return "", fmt.Errorf("failed to acquire lease of blob %s: the blob disappeared after it was created", name)
+		}
+		if err := c.create(ctx, name); err != nil {
+			return "", err
+		}
+	}
+}
+
+// ReleaseLease gives up the lease leaseID of the blob name. A lease that expired is not an error.
+func (c *Client) ReleaseLease(ctx context.Context, name, leaseID string) error {
+	req, err := c.newLeaseRequest(ctx, name, "release")
+	if err != nil {
+		return err
+	}
+	req.Raw().Header.Set("x-ms-lease-id", leaseID)
+	resp, err := c.do(req, http.StatusOK, http.StatusConflict)
+	if err != nil {
+		return fmt.Errorf("failed to release lease of blob %s: %w", name, err)
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) newLeaseRequest(ctx context.Context, name, action string) (*policy.Request, error) {
+	req, err := c.newRequest(ctx, http.MethodPut, c.blobURL(name)+"?comp=lease")
+	if err != nil {
+		return nil, err
+	}
+	req.Raw().Header.Set("x-ms-lease-action", action)
+	return req, nil
+}
+
+// create uploads an empty block blob name unless it exists; a blob created or leased by another client
+// in the meantime is left as it is
+func (c *Client) create(ctx context.Context, name string) error {
+	req, err := c.newRequest(ctx, http.MethodPut, c.blobURL(name))
+	if err != nil {
+		return err
+	}
+	req.Raw().Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Raw().Header.Set("If-None-Match", "*")
+	resp, err := c.do(req, http.StatusCreated, http.StatusConflict, http.StatusPreconditionFailed)
+	if err != nil {
+		return fmt.Errorf("failed to create blob %s: %w", name, err)
+	}
+	return resp.Body.Close()
+}
+
+// listResult is the subset of the List Blobs response we use
+type listResult struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List returns the names of the blobs starting with prefix
+func (c *Client) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := c.newRequest(ctx, http.MethodGet, c.containerURL+"?"+query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.do(req, http.StatusOK)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode blob list: %w", err)
+		}
+		for _, blob := range result.Blobs.Blob {
+			names = append(names, blob.Name)
+		}
+		if result.NextMarker == "" {
+			return names, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (c *Client) blobURL(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return c.containerURL + "/" + strings.Join(segments, "/")
+}
+
+func (c *Client) newRequest(ctx context.Context, method, endpoint string) (*policy.Request, error) {
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Blob request: %w", err)
+	}
+	req.Raw().Header.Set("x-ms-version", apiVersion)
+	return req, nil
+}
+
+// do sends req and returns the response when its status is one of want; otherwise the response
+// is closed and returned as an error
+func (c *Client) do(req *policy.Request, want ...int) (*http.Response, error) {
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range want {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return nil, runtime.NewResponseError(resp)
+}
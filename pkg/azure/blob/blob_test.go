@@ -0,0 +1,196 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// fakeContainer serves the Blob operations the client uses for the container "state"
+type fakeContainer struct {
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	leases map[string]string // Lease ID by blob name
+}
+
+func (f *fakeContainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("x-ms-version") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/state/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("comp") == "list":
+		var names []string
+		for name := range f.blobs {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		// Page after the first blob to exercise the marker
+		marker, next := r.URL.Query().Get("marker"), ""
+		if marker == "" && len(names) > 1 {
+			names, next = names[:1], names[1]
+		} else if marker != "" {
+			names = names[sort.SearchStrings(names, marker):]
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+		for _, name := range names {
+			fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", name)
+		}
+		fmt.Fprintf(w, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", next)
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "lease":
+		if _, ok := f.blobs[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Header.Get("x-ms-lease-action") {
+		case "acquire":
+			if _, ok := f.leases[name]; ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			f.leases[name] = fmt.Sprintf("lease-%d", len(f.leases)+1)
+			w.Header().Set("x-ms-lease-id", f.leases[name])
+			w.WriteHeader(http.StatusCreated)
+		case "release":
+			if f.leases[name] != r.Header.Get("x-ms-lease-id") {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			delete(f.leases, name)
+		}
+	case r.Method == http.MethodPut && r.Header.Get("If-None-Match") == "*" && f.blobs[name] != nil:
+		w.WriteHeader(http.StatusConflict)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-blob-type") == "BlockBlob":
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		data, ok := f.blobs[name]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	container := &fakeContainer{blobs: map[string][]byte{}, leases: map[string]string{}}
+	server := httptest.NewTLSServer(container)
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/state", fakeCredential{}, &policy.ClientOptions{
+		Transport: server.Client(),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"node-1/reboot-pending.json", "node-1/webhook/spec.json", "node-2/reboot-pending.json"} {
+		if err := client.Put(ctx, name, []byte(name)); err != nil {
+			t.Fatalf("Put(%s) unexpected error: %v", name, err)
+		}
+	}
+	names, err := client.List(ctx, "node-1/")
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if want := []string{"node-1/reboot-pending.json", "node-1/webhook/spec.json"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+	if data, err := client.Get(ctx, "node-1/webhook/spec.json"); err != nil || string(data) != "node-1/webhook/spec.json" {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+	if err := client.Delete(ctx, "node-1/webhook/spec.json"); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if err := client.Delete(ctx, "node-1/webhook/spec.json"); err != nil {
+		t.Errorf("Delete() of a missing blob unexpected error: %v", err)
+	}
+	if _, err := client.Get(ctx, "node-1/webhook/spec.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a deleted blob error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClientLease(t *testing.T) {
+	ctx := context.Background()
+	container := &fakeContainer{blobs: map[string][]byte{}, leases: map[string]string{}}
+	server := httptest.NewTLSServer(container)
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/state", fakeCredential{}, &policy.ClientOptions{
+		Transport: server.Client(),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+
+	// The blob does not exist yet and is created for the lease
+	leaseID, err := client.AcquireLease(ctx, "slots/0", time.Minute)
+	if err != nil || leaseID == "" {
+		t.Fatalf("AcquireLease() = %q, %v", leaseID, err)
+	}
+	if _, err := client.AcquireLease(ctx, "slots/0", time.Minute); !errors.Is(err, ErrLeased) {
+		t.Errorf("AcquireLease() of a leased blob error = %v, want ErrLeased", err)
+	}
+	if err := client.ReleaseLease(ctx, "slots/0", leaseID); err != nil {
+		t.Fatalf("ReleaseLease() unexpected error: %v", err)
+	}
+	if err := client.ReleaseLease(ctx, "slots/0", leaseID); err != nil {
+		t.Errorf("ReleaseLease() of a released lease unexpected error: %v", err)
+	}
+	if _, err := client.AcquireLease(ctx, "slots/0", time.Minute); err != nil {
+		t.Errorf("AcquireLease() after release unexpected error: %v", err)
+	}
+}
+
+func TestValidateContainerURL(t *testing.T) {
+	for url, valid := range map[string]bool{
+		"https://contoso.blob.core.windows.net/state":        true,
+		"https://contoso.blob.core.windows.net/state/":       true,
+		"http://contoso.blob.core.windows.net/state":         false,
+		"https://contoso.blob.core.windows.net":              false,
+		"https://contoso.blob.core.windows.net/state/nested": false,
+		"https://contoso.blob.core.windows.net/state?sv=1":   false,
+	} {
+		if err := ValidateContainerURL(url); (err == nil) != valid {
+			t.Errorf("ValidateContainerURL(%q) = %v, want valid %v", url, err, valid)
+		}
+	}
+}
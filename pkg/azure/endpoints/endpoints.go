@@ -0,0 +1,70 @@
+// Package endpoints lists the Azure service endpoints the agent talks to and how each one
+// is expected to resolve when the node reaches Azure over private endpoints.
+package endpoints
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Endpoint is an Azure service host the agent connects to over HTTPS
+type Endpoint struct {
+	Name string // Human readable service name, e.g. "Azure Resource Manager"
+	Host string // Fully qualified host name
+
+	// PrivateLinkCapable is true when the service can be reached through a private endpoint.
+	// Microsoft Entra ID (AAD) has no private endpoint and always resolves publicly.
+	PrivateLinkCapable bool
+}
+
+// Address returns the host:port used to dial the endpoint
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, "443")
+}
+
+const (
+	// ResourceManagerHost is the Azure Resource Manager endpoint for the public cloud
+	ResourceManagerHost = "management.azure.com"
+
+	// ActiveDirectoryHost is the Microsoft Entra ID (AAD) login endpoint for the public cloud
+	ActiveDirectoryHost = "login.microsoftonline.com"
+
+	// ArcGlobalHost serves the Arc agent installer and global Arc identity service
+	ArcGlobalHost = "gbl.his.arc.azure.com"
+
+	// GuestConfigurationHost is the Arc extension and guest configuration agent service
+	GuestConfigurationHost = "agentserviceapi.guestconfiguration.azure.com"
+)
+
+// ForRegion returns the endpoints used during bootstrap for an Arc machine in the given region
+func ForRegion(region string) []Endpoint {
+	region = strings.ToLower(strings.ReplaceAll(region, " ", ""))
+	list := []Endpoint{
+		{Name: "Azure Resource Manager", Host: ResourceManagerHost, PrivateLinkCapable: true},
+		{Name: "Microsoft Entra ID", Host: ActiveDirectoryHost, PrivateLinkCapable: false},
+		{Name: "Azure Arc identity service (global)", Host: ArcGlobalHost, PrivateLinkCapable: true},
+		{Name: "Azure Arc guest configuration", Host: GuestConfigurationHost, PrivateLinkCapable: true},
+	}
+	if region != "" {
+		list = append(list,
+			Endpoint{Name: "Azure Arc identity service (regional)", Host: fmt.Sprintf("%s.his.arc.azure.com", region), PrivateLinkCapable: true},
+			Endpoint{Name: "Azure Arc guest configuration (regional)", Host: fmt.Sprintf("%s-gas.guestconfiguration.azure.com", region), PrivateLinkCapable: true},
+		)
+	}
+	return list
+}
+
+// PublicAddresses returns the addresses that are not private (RFC 1918, RFC 4193) or loopback.
+// A private-link endpoint resolving to any of them usually means the privatelink DNS zone is
+// not linked to the node's network or the on-premises DNS forwarder is missing.
+func PublicAddresses(addrs []net.IP) []net.IP {
+	var public []net.IP
+	for _, ip := range addrs {
+		if ip.IsPrivate() || ip.IsLoopback() {
+			continue
+		}
+		public = append(public, ip)
+	}
+	return public
+}
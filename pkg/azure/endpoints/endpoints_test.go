@@ -0,0 +1,45 @@
+package endpoints
+
+import (
+	"net"
+	"testing"
+)
+
+func TestForRegion(t *testing.T) {
+	list := ForRegion("East US")
+	hosts := map[string]Endpoint{}
+	for _, e := range list {
+		hosts[e.Host] = e
+	}
+
+	for _, host := range []string{ResourceManagerHost, ActiveDirectoryHost, ArcGlobalHost, "eastus.his.arc.azure.com", "eastus-gas.guestconfiguration.azure.com"} {
+		if _, ok := hosts[host]; !ok {
+			t.Errorf("ForRegion() missing endpoint %s", host)
+		}
+	}
+	if hosts[ActiveDirectoryHost].PrivateLinkCapable {
+		t.Error("Microsoft Entra ID should not be private link capable")
+	}
+	if got := hosts[ResourceManagerHost].Address(); got != "management.azure.com:443" {
+		t.Errorf("Address() = %q", got)
+	}
+
+	if got := len(ForRegion("")); got != 4 {
+		t.Errorf("ForRegion(\"\") returned %d endpoints, want 4 global endpoints", got)
+	}
+}
+
+func TestPublicAddresses(t *testing.T) {
+	addrs := []net.IP{
+		net.ParseIP("10.1.2.3"),
+		net.ParseIP("172.16.0.4"),
+		net.ParseIP("192.168.1.1"),
+		net.ParseIP("fd00::1"),
+		net.ParseIP("20.50.1.1"),
+		net.ParseIP("127.0.0.1"),
+	}
+	public := PublicAddresses(addrs)
+	if len(public) != 1 || public[0].String() != "20.50.1.1" {
+		t.Errorf("PublicAddresses() = %v, want [20.50.1.1]", public)
+	}
+}
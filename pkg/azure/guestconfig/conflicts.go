@@ -0,0 +1,70 @@
+package guestconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// baselineConflicts maps built-in guest configurations to what they enforce against a Kubernetes node
+var baselineConflicts = map[string]string{
+	// The Azure security baseline for Linux requires IP forwarding off, which pod networking needs on
+	"azurelinuxbaseline": "it turns IP forwarding (net.ipv4.ip_forward) off, which pod networking requires",
+}
+
+// managedNames are the services, kernel settings and modules the node agent enables or configures.
+// An enforcing configuration whose parameters name one of them is likely to disable or revert it.
+var managedNames = map[string]string{
+	"kubelet":                             "the kubelet service",
+	"containerd":                          "the containerd service",
+	"crio":                                "the CRI-O service",
+	"himdsd":                              "the Arc agent service",
+	"aks-flex-node-agent":                 "the node agent service",
+	"net.ipv4.ip_forward":                 "IP forwarding",
+	"net.ipv6.conf.all.forwarding":        "IPv6 forwarding",
+	"net.bridge.bridge-nf-call-iptables":  "bridge netfilter",
+	"net.bridge.bridge-nf-call-ip6tables": "bridge netfilter",
+	"br_netfilter":                        "the br_netfilter module",
+	"overlay":                             "the overlay module",
+}
+
+// Conflicts describes the enforcing assignments that would undo settings the node agent applies.
+// Audit-only assignments only report compliance and never conflict.
+func Conflicts(assignments []Assignment) []string {
+	var conflicts []string
+	for _, a := range assignments {
+		if !a.Enforcing() {
+			continue
+		}
+		if reason, ok := baselineConflicts[strings.ToLower(a.Configuration)]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("guest configuration %s (%s): %s", a.Name, a.AssignmentType, reason))
+		}
+		if names := parameterConflicts(a.Parameters); len(names) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("guest configuration %s (%s): its parameters name %s, which the node agent manages",
+				a.Name, a.AssignmentType, strings.Join(names, ", ")))
+		}
+	}
+	return conflicts
+}
+
+// parameterConflicts returns what the parameter values refer to among the managed names, sorted.
+// Values are split into words so "containerd" does not match "containerd-shim" paths by accident.
+func parameterConflicts(parameters map[string]string) []string {
+	found := map[string]bool{}
+	for _, value := range parameters {
+		words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-')
+		})
+		for _, word := range words {
+			if what, ok := managedNames[strings.TrimSuffix(word, ".service")]; ok {
+				found[what] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(found))
+	for what := range found {
+		names = append(names, what)
+	}
+	sort.Strings(names)
+	return names
+}
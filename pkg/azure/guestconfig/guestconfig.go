@@ -0,0 +1,194 @@
+// Package guestconfig reads the Azure Policy guest configuration assignments of an Arc machine and finds
+// the ones that would undo settings the node agent applies.
+package guestconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// apiVersion of the Microsoft.GuestConfiguration resource provider
+const apiVersion = "2022-01-25"
+
+// StatePath records the assignments found by the last check, read by the status collector
+const StatePath = "/var/lib/aks-flex-node/guest-configuration.json"
+
+// Assignment is a guest configuration assigned to the machine and its last reported compliance
+type Assignment struct {
+	Name             string            `json:"name"`
+	Configuration    string            `json:"configuration"`
+	Version          string            `json:"version,omitempty"`
+	AssignmentType   string            `json:"assignmentType,omitempty"`
+	ComplianceStatus string            `json:"complianceStatus,omitempty"`
+	LastChecked      time.Time         `json:"lastChecked,omitempty"`
+	Parameters       map[string]string `json:"parameters,omitempty"`
+}
+
+// Enforcing reports whether the guest configuration agent changes the machine instead of only auditing it.
+// An empty assignment type means Audit.
+func (a Assignment) Enforcing() bool {
+	switch strings.ToLower(a.AssignmentType) {
+	case "applyandmonitor", "applyandautocorrect", "deployandautocorrect":
+		return true
+	}
+	return false
+}
+
+// State is the content of StatePath
+type State struct {
+	UpdatedAt   time.Time    `json:"updatedAt"`
+	Assignments []Assignment `json:"assignments"`
+	Conflicts   []string     `json:"conflicts,omitempty"`
+}
+
+// Client lists guest configuration assignments through Azure Resource Manager
+type Client struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+// NewClient creates a guest configuration client. options may be nil.
+func NewClient(cred azcore.TokenCredential, options *arm.ClientOptions) (*Client, error) {
+	client, err := arm.NewClient("guestconfig.Client", "v1.0.0", cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guest configuration client: %w", err)
+	}
+	return &Client{pipeline: client.Pipeline(), endpoint: client.Endpoint()}, nil
+}
+
+// assignmentList is the subset of the guestConfigurationAssignments list response we use
+type assignmentList struct {
+	Value []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			GuestConfiguration struct {
+				Name                   string `json:"name"`
+				Version                string `json:"version"`
+				AssignmentType         string `json:"assignmentType"`
+				ConfigurationParameter []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"configurationParameter"`
+			} `json:"guestConfiguration"`
+			ComplianceStatus            string    `json:"complianceStatus"`
+			LastComplianceStatusChecked time.Time `json:"lastComplianceStatusChecked"`
+		} `json:"properties"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// ListMachineAssignments returns the guest configuration assignments of an Arc machine.
+// A machine that does not exist yet has none.
+func (c *Client) ListMachineAssignments(ctx context.Context, subscriptionID, resourceGroup, machineName string) ([]Assignment, error) {
+	next := fmt.Sprintf("%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.HybridCompute/machines/%s"+
+		"/providers/Microsoft.GuestConfiguration/guestConfigurationAssignments?api-version=%s",
+		strings.TrimSuffix(c.endpoint, "/"), url.PathEscape(subscriptionID), url.PathEscape(resourceGroup),
+		url.PathEscape(machineName), apiVersion)
+
+	var assignments []Assignment
+	for next != "" {
+		page, err := c.listPage(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil {
+			return nil, nil
+		}
+		assignments = append(assignments, page.assignments()...)
+		next = page.NextLink
+	}
+	return assignments, nil
+}
+
+// listPage fetches one page of assignments; nil means the machine was not found
+func (c *Client) listPage(ctx context.Context, endpoint string) (*assignmentList, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build guest configuration request: %w", err)
+	}
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("guest configuration request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, runtime.NewResponseError(resp)
+	}
+	var page assignmentList
+	if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+		return nil, fmt.Errorf("failed to decode guest configuration assignments: %w", err)
+	}
+	return &page, nil
+}
+
+func (l *assignmentList) assignments() []Assignment {
+	assignments := make([]Assignment, 0, len(l.Value))
+	for _, v := range l.Value {
+		gc := v.Properties.GuestConfiguration
+		a := Assignment{
+			Name:             v.Name,
+			Configuration:    gc.Name,
+			Version:          gc.Version,
+			AssignmentType:   gc.AssignmentType,
+			ComplianceStatus: v.Properties.ComplianceStatus,
+			LastChecked:      v.Properties.LastComplianceStatusChecked,
+		}
+		for _, p := range gc.ConfigurationParameter {
+			if a.Parameters == nil {
+				a.Parameters = map[string]string{}
+			}
+			a.Parameters[p.Name] = p.Value
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments
+}
+
+// ReadState returns the recorded assignments, or nil when they were never checked
+func ReadState() (*State, error) {
+	data, err := os.ReadFile(StatePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", StatePath, err)
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", StatePath, err)
+	}
+	return state, nil
+}
+
+// WriteState records the assignments and their conflicts for the status collector
+func WriteState(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(StatePath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(StatePath), err)
+	}
+	return utils.WriteFileAtomicSystem(StatePath, data, 0o644)
+}
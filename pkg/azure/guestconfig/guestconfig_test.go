@@ -0,0 +1,75 @@
+package guestconfig
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testAssignments = `{"value": [
+  {"name": "AzureLinuxBaseline@contoso", "properties": {
+    "guestConfiguration": {"name": "AzureLinuxBaseline", "version": "1.*", "assignmentType": "ApplyAndAutoCorrect"},
+    "complianceStatus": "NonCompliant", "lastComplianceStatusChecked": "2026-10-17T08:00:00Z"}},
+  {"name": "DisabledServices", "properties": {
+    "guestConfiguration": {"name": "LinuxServices", "assignmentType": "ApplyAndMonitor",
+      "configurationParameter": [{"name": "ServiceName", "value": "telnet;containerd.service"}]},
+    "complianceStatus": "Compliant"}},
+  {"name": "AuditOnly", "properties": {
+    "guestConfiguration": {"name": "AzureLinuxBaseline", "assignmentType": "Audit"},
+    "complianceStatus": "Pending"}}
+], "nextLink": ""}`
+
+func TestAssignments(t *testing.T) {
+	var list assignmentList
+	if err := json.Unmarshal([]byte(testAssignments), &list); err != nil {
+		t.Fatal(err)
+	}
+	assignments := list.assignments()
+	if len(assignments) != 3 {
+		t.Fatalf("assignments() returned %d assignments, want 3", len(assignments))
+	}
+	first := assignments[0]
+	if first.Configuration != "AzureLinuxBaseline" || first.ComplianceStatus != "NonCompliant" || first.LastChecked.IsZero() {
+		t.Errorf("assignments()[0] = %+v, want the baseline with its compliance", first)
+	}
+	if got := assignments[1].Parameters["ServiceName"]; got != "telnet;containerd.service" {
+		t.Errorf("assignments()[1] parameter = %q, want the configured value", got)
+	}
+	if !first.Enforcing() || assignments[2].Enforcing() || (Assignment{}).Enforcing() {
+		t.Error("Enforcing() should be true for ApplyAndAutoCorrect only among these assignments")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	var list assignmentList
+	if err := json.Unmarshal([]byte(testAssignments), &list); err != nil {
+		t.Fatal(err)
+	}
+	conflicts := Conflicts(list.assignments())
+	if len(conflicts) != 2 {
+		t.Fatalf("Conflicts() = %q, want the enforced baseline and the disabled containerd service", conflicts)
+	}
+	if !strings.Contains(conflicts[0], "net.ipv4.ip_forward") {
+		t.Errorf("Conflicts()[0] = %q, want the IP forwarding conflict", conflicts[0])
+	}
+	if !strings.Contains(conflicts[1], "the containerd service") || strings.Contains(conflicts[1], "telnet") {
+		t.Errorf("Conflicts()[1] = %q, want only the containerd service", conflicts[1])
+	}
+}
+
+func TestParameterConflicts(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "kubelet,himdsd", want: "the Arc agent service, the kubelet service"},
+		{value: "net.ipv4.ip_forward=0", want: "IP forwarding"},
+		{value: "/usr/bin/containerd-shim", want: ""},
+		{value: "kubelet-extra", want: ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(parameterConflicts(map[string]string{"p": tt.value}), ", "); got != tt.want {
+			t.Errorf("parameterConflicts(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package history keeps a local record of the requests the agent sent to change Azure resources: the
+// operation, the resource, the correlation ID and the result. During incident review the record is matched
+// with the Azure Activity Log, which lists the same operations under the same correlation IDs.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const (
+	// Path is where the operations are recorded, one JSON object per line
+	Path = "/var/lib/aks-flex-node/azure-history.jsonl"
+
+	// maxSize is the size above which the record is moved to Path.1, replacing the older one, so the two
+	// files keep the last few thousand operations
+	maxSize = 4 << 20
+)
+
+// Results of an operation
+const (
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// Output formats of Write
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// Entry is one Azure operation sent by the agent
+type Entry struct {
+	Time          time.Time     `json:"time"`
+	Operation     string        `json:"operation"` // As named in the Activity Log, e.g. Microsoft.Authorization/roleAssignments/write
+	Method        string        `json:"method"`
+	Resource      string        `json:"resource"` // ID of the resource the request was sent to
+	CorrelationID string        `json:"correlationId,omitempty"`
+	Status        int           `json:"status,omitempty"` // HTTP status of the response; 0 when none was received
+	Result        string        `json:"result"`           // Succeeded or Failed
+	Error         string        `json:"error,omitempty"`
+	Duration      time.Duration `json:"duration"` // Including the SDK's retries
+}
+
+// Shared records the operations of all Azure clients of the process
+var Shared = NewRecorder(Path)
+
+// Recorder appends entries to a file
+type Recorder struct {
+	path string
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+// NewRecorder creates a recorder appending to path
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path, now: time.Now}
+}
+
+// Record appends an entry. An entry that cannot be written, e.g. because a command runs without access to
+// the state directory, is lost, which is not worth failing the Azure operation for.
+func (r *Recorder) Record(entry Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info, err := os.Stat(r.path); err == nil && info.Size() > maxSize {
+		_ = os.Rename(r.path, r.path+".1")
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o750); err != nil {
+		return
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	_, _ = file.Write(append(data, '\n'))
+}
+
+// Policy returns a per-call pipeline policy recording the ARM requests that change resources, once with
+// the outcome of the SDK's last retry. Reads, and requests to data planes such as Blob Storage, are not
+// recorded.
+func (r *Recorder) Policy() policy.Policy {
+	return recorderPolicy{recorder: r}
+}
+
+type recorderPolicy struct {
+	recorder *Recorder
+}
+
+func (rp recorderPolicy) Do(req *policy.Request) (*http.Response, error) {
+	raw := req.Raw()
+	if !isWrite(raw.Method) || !isARMPath(raw.URL.Path) {
+		return req.Next()
+	}
+	start := rp.recorder.now()
+	resp, err := req.Next()
+
+	entry := Entry{
+		Time:          start.UTC(),
+		Operation:     OperationName(raw.Method, raw.URL.Path),
+		Method:        raw.Method,
+		Resource:      resourceID(raw.Method, raw.URL.Path),
+		CorrelationID: raw.Header.Get("x-ms-correlation-request-id"),
+		Result:        Succeeded,
+		Duration:      rp.recorder.now().Sub(start),
+	}
+	if resp != nil {
+		entry.Status = resp.StatusCode
+		if id := resp.Header.Get("x-ms-correlation-request-id"); id != "" {
+			entry.CorrelationID = id
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			entry.Result = Failed
+			entry.Error = resp.Header.Get("x-ms-error-code")
+		}
+	}
+	if err != nil {
+		entry.Result, entry.Error = Failed, err.Error()
+	}
+	rp.recorder.Record(entry)
+	return resp, err
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isARMPath reports whether path is an ARM resource path, which all start at a subscription or a provider
+func isARMPath(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "/subscriptions/") || strings.HasPrefix(lower, "/providers/")
+}
+
+// resourceID returns the resource a request path targets, leaving out the action of a POST request
+func resourceID(method, path string) string {
+	if method == http.MethodPost {
+		if segments := strings.Split(strings.Trim(path, "/"), "/"); len(segments)%2 == 1 {
+			return "/" + strings.Join(segments[:len(segments)-1], "/")
+		}
+	}
+	return path
+}
+
+// OperationName returns the name the Activity Log lists a request under: the resource type of the path
+// followed by write, delete or the action of a POST request, e.g. Microsoft.HybridCompute/machines/extensions/write
+func OperationName(method, path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	action := ""
+	if method == http.MethodPost && len(segments)%2 == 1 {
+		action, segments = segments[len(segments)-1], segments[:len(segments)-1]
+	}
+
+	// The resource type starts at the namespace after the last "providers", e.g. of a role assignment on a
+	// cluster. Subscriptions and resource groups are Microsoft.Resources types without a providers segment.
+	resourceType, start := "Microsoft.Resources", 0
+	for idx := len(segments) - 2; idx >= 0; idx-- {
+		if strings.EqualFold(segments[idx], "providers") {
+			resourceType, start = segments[idx+1], idx+2
+			break
+		}
+	}
+	for idx := start; idx < len(segments); idx += 2 {
+		resourceType += "/" + segments[idx]
+	}
+
+	switch {
+	case action != "":
+		return resourceType + "/" + action + "/action"
+	case method == http.MethodDelete:
+		return resourceType + "/delete"
+	default:
+		return resourceType + "/write"
+	}
+}
+
+// Read returns the recorded entries at path, oldest first, including those already moved to path.1.
+// Nothing recorded yet is not an error.
+func Read(path string) ([]Entry, error) {
+	var entries []Entry
+	for _, name := range []string{path + ".1", path} {
+		file, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Azure operation history: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry Entry
+			// A line cut short by a crash is skipped
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	return entries, nil
+}
+
+// Filter selects entries of the history
+type Filter struct {
+	Since      time.Time // Only entries at or after this time; zero means all
+	FailedOnly bool
+	Resource   string // Only entries whose resource contains this, ignoring case
+}
+
+// Apply returns the entries matching the filter
+func (f Filter) Apply(entries []Entry) []Entry {
+	var matched []Entry
+	for _, entry := range entries {
+		if entry.Time.Before(f.Since) || (f.FailedOnly && entry.Result != Failed) {
+			continue
+		}
+		if f.Resource != "" && !strings.Contains(strings.ToLower(entry.Resource), strings.ToLower(f.Resource)) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+	return matched
+}
+
+// Write renders entries as a table or as JSON
+func Write(w io.Writer, entries []Entry, format string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if entries == nil {
+			entries = []Entry{}
+		}
+		return encoder.Encode(entries)
+	case FormatTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tOPERATION\tRESOURCE\tRESULT\tCORRELATION ID")
+		for _, entry := range entries {
+			result := entry.Result
+			if entry.Status != 0 {
+				result = fmt.Sprintf("%s (%d)", result, entry.Status)
+			}
+			if entry.Error != "" {
+				result += ": " + entry.Error
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.RFC3339), entry.Operation, entry.Resource, result, entry.CorrelationID)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q; use %s or %s", format, FormatTable, FormatJSON)
+	}
+}
@@ -0,0 +1,175 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+func TestOperationName(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodPut, path: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/edge-01/extensions/AzureMonitorLinuxAgent", want: "Microsoft.HybridCompute/machines/extensions/write"},
+		{method: http.MethodDelete, path: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/edge-01", want: "Microsoft.HybridCompute/machines/delete"},
+		{method: http.MethodPut, path: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/aks/providers/Microsoft.Authorization/roleAssignments/1234", want: "Microsoft.Authorization/roleAssignments/write"},
+		{method: http.MethodPost, path: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/aks/listClusterUserCredential", want: "Microsoft.ContainerService/managedClusters/listClusterUserCredential/action"},
+		{method: http.MethodPost, path: "/subscriptions/sub/providers/Microsoft.HybridCompute/register", want: "Microsoft.HybridCompute/register/action"},
+		{method: http.MethodPut, path: "/subscriptions/sub/resourcegroups/rg", want: "Microsoft.Resources/subscriptions/resourcegroups/write"},
+	}
+	for _, tt := range tests {
+		if got := OperationName(tt.method, tt.path); got != tt.want {
+			t.Errorf("OperationName(%s, %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azure-history.jsonl")
+	recorder := NewRecorder(path)
+
+	transport := transportFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/unreachable") {
+			return nil, errors.New("connection reset by peer")
+		}
+		header := http.Header{"X-Ms-Correlation-Request-Id": []string{"corr-" + req.Method}}
+		status := http.StatusOK
+		if req.Method == http.MethodDelete {
+			status = http.StatusForbidden
+			header.Set("x-ms-error-code", "AuthorizationFailed")
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: http.NoBody, Request: req}, nil
+	})
+	pipeline := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:       transport,
+		Retry:           policy.RetryOptions{MaxRetries: -1},
+		PerCallPolicies: []policy.Policy{recorder.Policy()},
+	})
+
+	const machine = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/edge-01"
+	for _, req := range []struct{ method, url string }{
+		{http.MethodPut, "https://management.azure.com" + machine + "?api-version=1"},
+		{http.MethodGet, "https://management.azure.com" + machine},
+		{http.MethodPut, "https://edgestate.blob.core.windows.net/state/node.tar"},
+		{http.MethodDelete, "https://management.azure.com" + machine},
+		{http.MethodPost, "https://management.azure.com" + machine + "/unreachable"},
+	} {
+		request, err := runtime.NewRequest(context.Background(), req.method, req.url)
+		if err != nil {
+			t.Fatalf("NewRequest() unexpected error: %v", err)
+		}
+		_, _ = pipeline.Do(request)
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() unexpected error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("recorded %d operations, want the 3 ARM writes: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Operation != "Microsoft.HybridCompute/machines/write" || e.Resource != machine || e.CorrelationID != "corr-PUT" || e.Result != Succeeded || e.Status != http.StatusOK {
+		t.Errorf("PUT recorded as %+v", e)
+	}
+	if e := entries[1]; e.Operation != "Microsoft.HybridCompute/machines/delete" || e.Result != Failed || e.Error != "AuthorizationFailed" || e.Status != http.StatusForbidden {
+		t.Errorf("failed DELETE recorded as %+v", e)
+	}
+	if e := entries[2]; e.Resource != machine || e.Result != Failed || !strings.Contains(e.Error, "connection reset") || e.Status != 0 {
+		t.Errorf("POST without a response recorded as %+v", e)
+	}
+}
+
+func TestReadRotated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azure-history.jsonl")
+	write := func(name string, operations ...string) {
+		var data []byte
+		for _, operation := range operations {
+			line, _ := json.Marshal(Entry{Operation: operation})
+			data = append(append(data, line...), '\n')
+		}
+		if err := os.WriteFile(name, data, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if entries, err := Read(path); err != nil || entries != nil {
+		t.Fatalf("Read() = %v, %v without a history, want nothing", entries, err)
+	}
+	write(path+".1", "old")
+	write(path, "new")
+	// A line cut short by a crash
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = file.WriteString(`{"operation":"cut`)
+	file.Close()
+
+	entries, err := Read(path)
+	if err != nil || len(entries) != 2 || entries[0].Operation != "old" || entries[1].Operation != "new" {
+		t.Errorf("Read() = %+v, %v, want the rotated entry before the current one", entries, err)
+	}
+
+	recorder := NewRecorder(path)
+	line := []byte(`{"operation":"filler"}` + "\n")
+	if err := os.WriteFile(path, bytes.Repeat(line, maxSize/len(line)+1), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Record(Entry{Operation: "after rotation"})
+	entries, _ = Read(path)
+	if last := entries[len(entries)-1]; last.Operation != "after rotation" {
+		t.Errorf("last entry = %+v, want the one recorded after rotation", last)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() > 1024 {
+		t.Errorf("history not rotated once it exceeded %d bytes", maxSize)
+	}
+}
+
+func TestFilterAndWrite(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: now.Add(-48 * time.Hour), Operation: "Microsoft.Authorization/roleAssignments/write", Resource: "/subscriptions/sub/providers/Microsoft.Authorization/roleAssignments/a1", Result: Succeeded, Status: 201},
+		{Time: now.Add(-time.Hour), Operation: "Microsoft.HybridCompute/machines/delete", Resource: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/edge-01", Result: Failed, Status: 403, Error: "AuthorizationFailed", CorrelationID: "c2"},
+		{Time: now, Operation: "Microsoft.HybridCompute/machines/extensions/write", Resource: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/edge-01/extensions/ama", Result: Succeeded, Status: 200},
+	}
+
+	if got := (Filter{Since: now.Add(-24 * time.Hour)}).Apply(entries); len(got) != 2 {
+		t.Errorf("Filter{Since} kept %d entries, want 2", len(got))
+	}
+	if got := (Filter{FailedOnly: true}).Apply(entries); len(got) != 1 || got[0].CorrelationID != "c2" {
+		t.Errorf("Filter{FailedOnly} = %+v, want the failed delete", got)
+	}
+	if got := (Filter{Resource: "EXTENSIONS/AMA"}).Apply(entries); len(got) != 1 {
+		t.Errorf("Filter{Resource} kept %d entries, want the extension write", len(got))
+	}
+
+	var table bytes.Buffer
+	if err := Write(&table, entries, FormatTable); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(table.String(), "failed (403): AuthorizationFailed") || !strings.Contains(table.String(), "CORRELATION ID") {
+		t.Errorf("table output:\n%s", table.String())
+	}
+	var out bytes.Buffer
+	if err := Write(&out, nil, FormatJSON); err != nil || strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("Write(nil, json) = %q, %v, want an empty list", out.String(), err)
+	}
+	if err := Write(&out, entries, "yaml"); err == nil {
+		t.Error("Write() expected an error for an unknown format")
+	}
+}
+
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
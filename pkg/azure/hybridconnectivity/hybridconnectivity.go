@@ -0,0 +1,118 @@
+// Package hybridconnectivity manages the Microsoft.HybridConnectivity endpoint of an Arc machine, through which
+// `az ssh arc` reaches the machine's SSH server over the Arc agent instead of an inbound network connection.
+package hybridconnectivity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// apiVersion of the Microsoft.HybridConnectivity resource provider
+const apiVersion = "2023-03-15"
+
+// Client manages the default endpoint of an Arc machine and its SSH service configuration
+type Client struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+// NewClient creates a hybrid connectivity client. options may be nil.
+func NewClient(cred azcore.TokenCredential, options *arm.ClientOptions) (*Client, error) {
+	client, err := arm.NewClient("hybridconnectivity.Client", "v1.0.0", cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hybrid connectivity client: %w", err)
+	}
+	return &Client{pipeline: client.Pipeline(), endpoint: client.Endpoint()}, nil
+}
+
+// serviceConfiguration is the subset of the serviceConfigurations resource we use
+type serviceConfiguration struct {
+	Properties struct {
+		ServiceName string `json:"serviceName"`
+		Port        int    `json:"port"`
+	} `json:"properties"`
+}
+
+// EnsureSSHEndpoint creates the default endpoint of the machine and points its SSH service configuration at
+// port. It returns true when the SSH service configuration did not exist before, so the caller knows it
+// owns it and may delete it again.
+func (c *Client) EnsureSSHEndpoint(ctx context.Context, subscriptionID, resourceGroup, machineName string, port int) (bool, error) {
+	current := &serviceConfiguration{}
+	found, err := c.do(ctx, http.MethodGet, c.sshURL(subscriptionID, resourceGroup, machineName), nil, current)
+	if err != nil {
+		return false, err
+	}
+	if found && current.Properties.Port == port {
+		return false, nil
+	}
+
+	endpoint := map[string]any{"properties": map[string]any{"type": "default"}}
+	if _, err := c.do(ctx, http.MethodPut, c.endpointURL(subscriptionID, resourceGroup, machineName), endpoint, nil); err != nil {
+		return false, fmt.Errorf("failed to create the Arc connectivity endpoint: %w", err)
+	}
+	desired := map[string]any{"properties": map[string]any{"serviceName": "SSH", "port": port}}
+	if _, err := c.do(ctx, http.MethodPut, c.sshURL(subscriptionID, resourceGroup, machineName), desired, nil); err != nil {
+		return false, fmt.Errorf("failed to configure SSH on the Arc connectivity endpoint: %w", err)
+	}
+	return !found, nil
+}
+
+// DeleteSSHEndpoint removes the SSH service configuration of the machine; a missing one is not an error.
+// The default endpoint is left in place as other services, such as RDP, may use it.
+func (c *Client) DeleteSSHEndpoint(ctx context.Context, subscriptionID, resourceGroup, machineName string) error {
+	if _, err := c.do(ctx, http.MethodDelete, c.sshURL(subscriptionID, resourceGroup, machineName), nil, nil); err != nil {
+		return fmt.Errorf("failed to remove SSH from the Arc connectivity endpoint: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) endpointURL(subscriptionID, resourceGroup, machineName string) string {
+	return fmt.Sprintf("%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.HybridCompute/machines/%s"+
+		"/providers/Microsoft.HybridConnectivity/endpoints/default",
+		strings.TrimSuffix(c.endpoint, "/"), url.PathEscape(subscriptionID), url.PathEscape(resourceGroup), url.PathEscape(machineName))
+}
+
+func (c *Client) sshURL(subscriptionID, resourceGroup, machineName string) string {
+	return c.endpointURL(subscriptionID, resourceGroup, machineName) + "/serviceConfigurations/SSH"
+}
+
+// do sends a request with an optional JSON body and decodes the response into out when it is not nil.
+// It returns false when the resource to read or delete was not found.
+func (c *Client) do(ctx context.Context, method, endpoint string, body, out any) (bool, error) {
+	req, err := runtime.NewRequest(ctx, method, endpoint+"?api-version="+apiVersion)
+	if err != nil {
+		return false, fmt.Errorf("failed to build hybrid connectivity request: %w", err)
+	}
+	if body != nil {
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return false, err
+		}
+	}
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("hybrid connectivity request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method != http.MethodPut:
+		return false, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return false, runtime.NewResponseError(resp)
+	}
+	if out != nil {
+		if err := runtime.UnmarshalAsJSON(resp, out); err != nil {
+			return false, fmt.Errorf("failed to decode hybrid connectivity response: %w", err)
+		}
+	}
+	return true, nil
+}
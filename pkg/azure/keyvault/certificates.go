@@ -0,0 +1,197 @@
+// Package keyvault reads certificates from Azure Key Vault for components that install
+// enterprise trust material on the node, and secrets such as rotated service principal credentials.
+package keyvault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const (
+	// Key Vault data plane token scope and API version
+	vaultScope      = "https://vault.azure.net/.default"
+	vaultAPIVersion = "7.4"
+)
+
+// CertificateClient reads the public part of certificates stored in a Key Vault
+type CertificateClient struct {
+	pipeline runtime.Pipeline
+	vaultURL string
+}
+
+// NewCertificateClient creates a Key Vault certificate client for the given vault URL,
+// e.g. https://myvault.vault.azure.net. options may be nil.
+func NewCertificateClient(vaultURL string, cred azcore.TokenCredential, options *policy.ClientOptions) (*CertificateClient, error) {
+	pipeline, err := newPipeline("keyvault.CertificateClient", vaultURL, cred, options)
+	if err != nil {
+		return nil, err
+	}
+	return &CertificateClient{pipeline: pipeline, vaultURL: strings.TrimSuffix(vaultURL, "/")}, nil
+}
+
+// newPipeline checks the vault URL and creates a pipeline authenticating to the Key Vault data plane
+func newPipeline(name, vaultURL string, cred azcore.TokenCredential, options *policy.ClientOptions) (runtime.Pipeline, error) {
+	parsed, err := url.Parse(vaultURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return runtime.Pipeline{}, fmt.Errorf("invalid Key Vault URL %q: expected https://<vault-name>.vault.azure.net", vaultURL)
+	}
+
+	client, err := azcore.NewClient(name, "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{vaultScope}, nil)},
+	}, options)
+	if err != nil {
+		return runtime.Pipeline{}, fmt.Errorf("failed to create Key Vault client: %w", err)
+	}
+	return client.Pipeline(), nil
+}
+
+// certificateBundle is the subset of the Key Vault certificate response we use
+type certificateBundle struct {
+	// CER holds the base64 encoded DER certificate
+	CER string `json:"cer"`
+}
+
+// GetCertificatePEM returns the latest version of the named certificate, PEM encoded.
+// Only the public certificate is read, so the caller needs the certificates/get permission only.
+func (c *CertificateClient) GetCertificatePEM(ctx context.Context, name string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/certificates/%s?api-version=%s", c.vaultURL, url.PathEscape(name), vaultAPIVersion)
+	req, err := runtime.NewRequest(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Key Vault request: %w", err)
+	}
+
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("key Vault request for certificate %s failed: %w", name, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	var bundle certificateBundle
+	if err := runtime.UnmarshalAsJSON(resp, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode certificate %s: %w", name, err)
+	}
+	return cerToPEM(bundle.CER)
+}
+
+// cerToPEM converts a base64 encoded DER certificate to PEM
+func cerToPEM(cer string) ([]byte, error) {
+	if cer == "" {
+		return nil, fmt.Errorf("certificate has no public part")
+	}
+	der, err := base64.StdEncoding.DecodeString(cer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate is not valid base64: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// CertificateRequest describes a certificate for Key Vault to issue through one of its registered issuers.
+// Its key is exportable and stored as PEM, so the certificate with its key can be read as a secret of the same name.
+type CertificateRequest struct {
+	Subject           string   // X.500 distinguished name, e.g. "CN=system:node:edge-01, O=system:nodes"
+	IssuerName        string   // Certificate issuer registered in the vault
+	ValidityMonths    int      // Validity of the certificate
+	ExtendedKeyUsages []string // Extended key usage OIDs
+}
+
+// certificateOperation is the subset of the Key Vault certificate operation response we use
+type certificateOperation struct {
+	Status        string `json:"status"`
+	StatusDetails string `json:"status_details"`
+	Error         *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// operationPollInterval is how often a pending certificate operation is polled; replaced in tests
+var operationPollInterval = 5 * time.Second
+
+// CreateCertificate issues a new version of the named certificate and waits until the issuer completed it.
+// The caller needs the certificates/create and certificates/get permissions.
+func (c *CertificateClient) CreateCertificate(ctx context.Context, name string, request CertificateRequest) error {
+	body := map[string]any{
+		"policy": map[string]any{
+			"key_props":    map[string]any{"exportable": true, "kty": "RSA", "key_size": 2048, "reuse_key": false},
+			"secret_props": map[string]any{"contentType": "application/x-pem-file"},
+			"x509_props": map[string]any{
+				"subject":         request.Subject,
+				"ekus":            request.ExtendedKeyUsages,
+				"key_usage":       []string{"digitalSignature", "keyEncipherment"},
+				"validity_months": request.ValidityMonths,
+			},
+			"issuer": map[string]any{"name": request.IssuerName},
+		},
+	}
+	endpoint := fmt.Sprintf("%s/certificates/%s/create?api-version=%s", c.vaultURL, url.PathEscape(name), vaultAPIVersion)
+	op, err := c.doOperation(ctx, http.MethodPost, endpoint, body, http.StatusAccepted)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate %s: %w", name, err)
+	}
+
+	pending := fmt.Sprintf("%s/certificates/%s/pending?api-version=%s", c.vaultURL, url.PathEscape(name), vaultAPIVersion)
+	for op.Status == "inProgress" {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("certificate %s was not issued in time: %w", name, ctx.Err())
+		case <-time.After(operationPollInterval):
+		}
+		if op, err = c.doOperation(ctx, http.MethodGet, pending, nil, http.StatusOK); err != nil {
+			return fmt.Errorf("failed to read the pending operation of certificate %s: %w", name, err)
+		}
+	}
+	if op.Status != "completed" {
+		detail := op.StatusDetails
+		if op.Error != nil {
+			detail = op.Error.Code + ": " + op.Error.Message
+		}
+		return fmt.Errorf("certificate %s was not issued, operation %s: %s", name, op.Status, detail)
+	}
+	return nil
+}
+
+// doOperation sends a certificate operation request and decodes the operation it returns
+func (c *CertificateClient) doOperation(ctx context.Context, method, endpoint string, body any, wantStatus int) (*certificateOperation, error) {
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Key Vault request: %w", err)
+	}
+	if body != nil {
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return nil, fmt.Errorf("failed to build Key Vault request: %w", err)
+		}
+	}
+
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != wantStatus && resp.StatusCode != http.StatusOK {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	var op certificateOperation
+	if err := runtime.UnmarshalAsJSON(resp, &op); err != nil {
+		return nil, fmt.Errorf("failed to decode the certificate operation: %w", err)
+	}
+	return &op, nil
+}
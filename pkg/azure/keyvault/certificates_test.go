@@ -0,0 +1,142 @@
+package keyvault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+func TestCerToPEM(t *testing.T) {
+	der := []byte{0x30, 0x03, 0x02, 0x01, 0x01}
+	out, err := cerToPEM(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		t.Fatalf("cerToPEM() unexpected error: %v", err)
+	}
+	block, _ := pem.Decode(out)
+	if block == nil || block.Type != "CERTIFICATE" || string(block.Bytes) != string(der) {
+		t.Errorf("cerToPEM() = %q, want PEM certificate wrapping the DER bytes", out)
+	}
+
+	if _, err := cerToPEM(""); err == nil {
+		t.Error("cerToPEM() expected error for empty certificate")
+	}
+	if _, err := cerToPEM("not base64!"); err == nil {
+		t.Error("cerToPEM() expected error for invalid base64")
+	}
+}
+
+func TestNewCertificateClientRejectsInvalidURL(t *testing.T) {
+	for _, vaultURL := range []string{"", "http://myvault.vault.azure.net", "myvault"} {
+		if _, err := NewCertificateClient(vaultURL, nil, nil); err == nil {
+			t.Errorf("NewCertificateClient(%q) expected error", vaultURL)
+		}
+	}
+}
+
+func TestParseSecretID(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    SecretID
+		wantErr bool
+	}{
+		{id: "https://myvault.vault.azure.net/secrets/flex-sp", want: SecretID{VaultURL: "https://myvault.vault.azure.net", Name: "flex-sp"}},
+		{id: "https://myvault.vault.azure.net/secrets/flex-sp/0123abcd/", want: SecretID{VaultURL: "https://myvault.vault.azure.net", Name: "flex-sp", Version: "0123abcd"}},
+		{id: "http://myvault.vault.azure.net/secrets/flex-sp", wantErr: true},
+		{id: "https://myvault.vault.azure.net/certificates/flex-sp", wantErr: true},
+		{id: "https://myvault.vault.azure.net/secrets/", wantErr: true},
+		{id: "https://myvault.vault.azure.net/secrets/flex-sp?api-version=7.4", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSecretID(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSecretID(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSecretID(%q) = %+v, want %+v", tt.id, got, tt.want)
+		}
+		if !tt.wantErr && got.String() != strings.TrimSuffix(tt.id, "/") {
+			t.Errorf("SecretID.String() = %q, want %q", got.String(), tt.id)
+		}
+	}
+}
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestCreateCertificate(t *testing.T) {
+	operationPollInterval = time.Millisecond
+	t.Cleanup(func() { operationPollInterval = 5 * time.Second })
+
+	tests := []struct {
+		name    string
+		pending []string
+		wantErr string
+	}{
+		{name: "completed", pending: []string{`{"status": "inProgress"}`, `{"status": "completed"}`}},
+		{name: "rejected", pending: []string{`{"status": "failed", "error": {"code": "Rejected", "message": "issuer denied the request"}}`}, wantErr: "issuer denied the request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var policyBody map[string]any
+			polls := 0
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/certificates/edge-01/create":
+					if err := json.NewDecoder(r.Body).Decode(&policyBody); err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprint(w, `{"status": "inProgress"}`)
+				case r.Method == http.MethodGet && r.URL.Path == "/certificates/edge-01/pending":
+					fmt.Fprint(w, tt.pending[min(polls, len(tt.pending)-1)])
+					polls++
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			client, err := NewCertificateClient(server.URL, fakeCredential{}, &policy.ClientOptions{
+				Transport: server.Client(),
+				Retry:     policy.RetryOptions{MaxRetries: -1},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.CreateCertificate(context.Background(), "edge-01", CertificateRequest{
+				Subject:           "CN=system:node:edge-01, O=system:nodes",
+				IssuerName:        "corp-ca",
+				ValidityMonths:    12,
+				ExtendedKeyUsages: []string{"1.3.6.1.5.5.7.3.2"},
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("CreateCertificate() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateCertificate() unexpected error: %v", err)
+			}
+			x509Props := policyBody["policy"].(map[string]any)["x509_props"].(map[string]any)
+			if x509Props["subject"] != "CN=system:node:edge-01, O=system:nodes" {
+				t.Errorf("requested subject = %v, want the node subject", x509Props["subject"])
+			}
+		})
+	}
+}
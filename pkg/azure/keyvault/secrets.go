@@ -0,0 +1,110 @@
+package keyvault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// SecretID identifies a Key Vault secret, optionally pinned to one version
+type SecretID struct {
+	VaultURL string
+	Name     string
+	Version  string // Empty for the latest version
+}
+
+// ParseSecretID parses a secret identifier such as https://myvault.vault.azure.net/secrets/name
+// or https://myvault.vault.azure.net/secrets/name/version
+func ParseSecretID(id string) (SecretID, error) {
+	parsed, err := url.Parse(id)
+	if err == nil && parsed.Scheme == "https" && parsed.Host != "" && parsed.RawQuery == "" {
+		parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if (len(parts) == 2 || len(parts) == 3) && parts[0] == "secrets" && parts[1] != "" {
+			secretID := SecretID{VaultURL: "https://" + parsed.Host, Name: parts[1]}
+			if len(parts) == 3 {
+				secretID.Version = parts[2]
+			}
+			return secretID, nil
+		}
+	}
+	return SecretID{}, fmt.Errorf("invalid Key Vault secret ID %q: expected https://<vault-name>.vault.azure.net/secrets/<name>[/<version>]", id)
+}
+
+// String returns the secret identifier
+func (id SecretID) String() string {
+	s := id.VaultURL + "/secrets/" + id.Name
+	if id.Version != "" {
+		s += "/" + id.Version
+	}
+	return s
+}
+
+// Secret is a secret value with the metadata needed to interpret it
+type Secret struct {
+	Value string
+	// ContentType is set by the uploader; certificates managed by Key Vault use
+	// application/x-pem-file or application/x-pkcs12, the latter base64 encoded
+	ContentType string
+	// ID is the identifier of the version that was read
+	ID string
+}
+
+// SecretClient reads secrets stored in a Key Vault
+type SecretClient struct {
+	pipeline runtime.Pipeline
+	vaultURL string
+}
+
+// NewSecretClient creates a Key Vault secret client for the given vault URL,
+// e.g. https://myvault.vault.azure.net. options may be nil.
+func NewSecretClient(vaultURL string, cred azcore.TokenCredential, options *policy.ClientOptions) (*SecretClient, error) {
+	pipeline, err := newPipeline("keyvault.SecretClient", vaultURL, cred, options)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretClient{pipeline: pipeline, vaultURL: strings.TrimSuffix(vaultURL, "/")}, nil
+}
+
+// secretBundle is the subset of the Key Vault secret response we use
+type secretBundle struct {
+	Value       string `json:"value"`
+	ContentType string `json:"contentType"`
+	ID          string `json:"id"`
+}
+
+// GetSecret returns a version of the named secret; an empty version reads the latest one.
+// The caller needs the secrets/get permission.
+func (c *SecretClient) GetSecret(ctx context.Context, name, version string) (*Secret, error) {
+	endpoint := fmt.Sprintf("%s/secrets/%s/%s?api-version=%s", c.vaultURL, url.PathEscape(name), url.PathEscape(version), vaultAPIVersion)
+	req, err := runtime.NewRequest(ctx, http.MethodGet, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Key Vault request: %w", err)
+	}
+
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("key Vault request for secret %s failed: %w", name, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	var bundle secretBundle
+	if err := runtime.UnmarshalAsJSON(resp, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode secret %s: %w", name, err)
+	}
+	if bundle.Value == "" {
+		return nil, fmt.Errorf("secret %s has no value", name)
+	}
+	return &Secret{Value: bundle.Value, ContentType: bundle.ContentType, ID: bundle.ID}, nil
+}
@@ -0,0 +1,269 @@
+// Package prerequisites creates the Azure resources a new edge site needs before its first node connects: the
+// resource group of the Arc machines, a user-assigned managed identity and links from private DNS zones to the
+// site's virtual network. The resources are declared by an ARM template built into the agent and deployed at
+// subscription scope; resources that already exist are left out of the deployment, so they are never changed.
+package prerequisites
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/scope"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+//go:embed template.json
+var templateJSON []byte
+
+// API versions used to check whether the resources exist; they match the template
+const (
+	identityAPIVersion = "2023-01-31"
+	dnsLinkAPIVersion  = "2020-06-01"
+)
+
+// Client is the ARM access the deployment needs. It matches what the Azure SDK provides so tests can
+// substitute a fake.
+type Client interface {
+	ResourceGroupExists(ctx context.Context, name string) (bool, error)
+	ResourceExists(ctx context.Context, id, apiVersion string) (bool, error)
+	DeployAtSubscription(ctx context.Context, name, location string, template, parameters map[string]any) error
+}
+
+// Spec is the set of resources the site needs
+type Spec struct {
+	SubscriptionID    string
+	ResourceGroup     string
+	Location          string
+	Tags              map[string]string
+	IdentityName      string
+	VirtualNetworkID  string
+	PrivateDNSZoneIDs []string
+}
+
+// NewSpec returns the resources declared in azure.prerequisites
+func NewSpec(cfg *config.Config) Spec {
+	spec := Spec{
+		SubscriptionID: cfg.GetSubscriptionID(),
+		ResourceGroup:  cfg.GetPrerequisitesResourceGroup(),
+		Location:       cfg.GetPrerequisitesLocation(),
+	}
+	if prerequisites := cfg.Azure.Prerequisites; prerequisites != nil {
+		spec.Tags = prerequisites.Tags
+		spec.IdentityName = prerequisites.ManagedIdentityName
+		spec.VirtualNetworkID = prerequisites.VirtualNetworkID
+		spec.PrivateDNSZoneIDs = prerequisites.PrivateDNSZoneIDs
+	}
+	return spec
+}
+
+// Plan is what a deployment creates: the resources of a Spec that do not exist yet
+type Plan struct {
+	ResourceGroup     bool
+	Identity          bool
+	PrivateDNSZoneIDs []string
+}
+
+// Empty reports whether every resource already exists
+func (p Plan) Empty() bool {
+	return !p.ResourceGroup && !p.Identity && len(p.PrivateDNSZoneIDs) == 0
+}
+
+// String describes the resources the plan creates, for logs
+func (p Plan) String() string {
+	var parts []string
+	if p.ResourceGroup {
+		parts = append(parts, "resource group")
+	}
+	if p.Identity {
+		parts = append(parts, "managed identity")
+	}
+	for _, zoneID := range p.PrivateDNSZoneIDs {
+		parts = append(parts, "DNS link for "+path.Base(zoneID))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LinkName returns the name of the virtual network links the deployment creates, derived from the virtual
+// network so links of different sites to a shared zone do not replace each other
+func (s Spec) LinkName() string {
+	name := "aks-flex-node-" + path.Base(s.VirtualNetworkID)
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return name
+}
+
+// Check returns the resources of spec that do not exist yet
+func Check(ctx context.Context, client Client, spec Spec) (Plan, error) {
+	var plan Plan
+	exists, err := client.ResourceGroupExists(ctx, spec.ResourceGroup)
+	if err != nil {
+		return plan, fmt.Errorf("failed to check resource group %s: %w", spec.ResourceGroup, err)
+	}
+	plan.ResourceGroup = !exists
+
+	if spec.IdentityName != "" {
+		if plan.ResourceGroup {
+			plan.Identity = true
+		} else {
+			id, err := scope.Resource(spec.SubscriptionID, spec.ResourceGroup, "Microsoft.ManagedIdentity", "userAssignedIdentities", spec.IdentityName)
+			if err != nil {
+				return plan, err
+			}
+			exists, err := client.ResourceExists(ctx, id, identityAPIVersion)
+			if err != nil {
+				return plan, fmt.Errorf("failed to check managed identity %s: %w", spec.IdentityName, err)
+			}
+			plan.Identity = !exists
+		}
+	}
+
+	for _, zoneID := range spec.PrivateDNSZoneIDs {
+		linkID := zoneID + "/virtualNetworkLinks/" + spec.LinkName()
+		exists, err := client.ResourceExists(ctx, linkID, dnsLinkAPIVersion)
+		if err != nil {
+			return plan, fmt.Errorf("failed to check the link of private DNS zone %s: %w", path.Base(zoneID), err)
+		}
+		if !exists {
+			plan.PrivateDNSZoneIDs = append(plan.PrivateDNSZoneIDs, zoneID)
+		}
+	}
+	return plan, nil
+}
+
+// Deploy deploys the template with the resources of plan
+func Deploy(ctx context.Context, client Client, spec Spec, plan Plan) error {
+	template, err := Template()
+	if err != nil {
+		return err
+	}
+	return client.DeployAtSubscription(ctx, DeploymentName(spec), spec.Location, template, Parameters(spec, plan))
+}
+
+// Template returns the built-in ARM template
+func Template() (map[string]any, error) {
+	var template map[string]any
+	if err := json.Unmarshal(templateJSON, &template); err != nil {
+		return nil, fmt.Errorf("invalid built-in prerequisites template: %w", err)
+	}
+	return template, nil
+}
+
+// Parameters returns the template parameters creating the resources of plan
+func Parameters(spec Spec, plan Plan) map[string]any {
+	tags := map[string]any{}
+	for key, value := range spec.Tags {
+		tags[key] = value
+	}
+	identityName := ""
+	if plan.Identity {
+		identityName = spec.IdentityName
+	}
+	zoneIDs := make([]any, 0, len(plan.PrivateDNSZoneIDs))
+	for _, zoneID := range plan.PrivateDNSZoneIDs {
+		zoneIDs = append(zoneIDs, zoneID)
+	}
+
+	values := map[string]any{
+		"createResourceGroup": plan.ResourceGroup,
+		"resourceGroupName":   spec.ResourceGroup,
+		"location":            spec.Location,
+		"tags":                tags,
+		"identityName":        identityName,
+		"virtualNetworkId":    spec.VirtualNetworkID,
+		"privateDnsZoneIds":   zoneIDs,
+		"linkName":            spec.LinkName(),
+	}
+	parameters := make(map[string]any, len(values))
+	for name, value := range values {
+		parameters[name] = map[string]any{"value": value}
+	}
+	return parameters
+}
+
+// DeploymentName returns the name of the subscription deployment. Subscription deployments share one
+// namespace, so the name includes the resource group of the site.
+func DeploymentName(spec Spec) string {
+	name := "aks-flex-node-prerequisites-" + strings.ToLower(spec.ResourceGroup)
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// azureClient implements Client with the Azure SDK
+type azureClient struct {
+	groups      *armresources.ResourceGroupsClient
+	resources   *armresources.Client
+	deployments *armresources.DeploymentsClient
+}
+
+// NewClient creates a Client for the subscription backed by the Azure SDK. options may be nil.
+func NewClient(subscriptionID string, cred azcore.TokenCredential, options *arm.ClientOptions) (Client, error) {
+	factory, err := armresources.NewClientFactory(subscriptionID, cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resources client: %w", err)
+	}
+	return &azureClient{
+		groups:      factory.NewResourceGroupsClient(),
+		resources:   factory.NewClient(),
+		deployments: factory.NewDeploymentsClient(),
+	}, nil
+}
+
+// NewClientForConfig creates a Client with the configured credential. Without a service principal the Azure
+// CLI is used, which is logged in first when needed.
+func NewClientForConfig(ctx context.Context, cfg *config.Config, logger *logrus.Logger) (Client, error) {
+	authProvider := auth.NewAuthProvider()
+	if !cfg.IsSPConfigured() {
+		logger.Info("🔐 Checking Azure CLI authentication status...")
+		if err := authProvider.EnsureAuthenticated(ctx, cfg.GetTenantID()); err != nil {
+			return nil, fmt.Errorf("failed to ensure Azure CLI authentication: %w", err)
+		}
+	}
+	cred, err := authProvider.UserCredential(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user credential: %w", err)
+	}
+	return NewClient(cfg.GetSubscriptionID(), cred, auth.ARMClientOptions(cfg))
+}
+
+func (c *azureClient) ResourceGroupExists(ctx context.Context, name string) (bool, error) {
+	response, err := c.groups.CheckExistence(ctx, name, nil)
+	return response.Success, err
+}
+
+func (c *azureClient) ResourceExists(ctx context.Context, id, apiVersion string) (bool, error) {
+	response, err := c.resources.CheckExistenceByID(ctx, id, apiVersion, nil)
+	return response.Success, err
+}
+
+func (c *azureClient) DeployAtSubscription(ctx context.Context, name, location string, template, parameters map[string]any) error {
+	mode := armresources.DeploymentModeIncremental
+	poller, err := c.deployments.BeginCreateOrUpdateAtSubscriptionScope(ctx, name, armresources.Deployment{
+		Location: to.Ptr(location),
+		Properties: &armresources.DeploymentProperties{
+			Mode:       &mode,
+			Template:   template,
+			Parameters: parameters,
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start deployment %s: %w", name, err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("deployment %s failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package prerequisites
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	vnetID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/net-rg/providers/Microsoft.Network/virtualNetworks/site-vnet"
+	zoneA  = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/dns-rg/providers/Microsoft.Network/privateDnsZones/privatelink.his.arc.azure.com"
+	zoneB  = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/dns-rg/providers/Microsoft.Network/privateDnsZones/privatelink.guestconfiguration.azure.com"
+)
+
+// fakeClient reports the resources in existing and records deployments
+type fakeClient struct {
+	groupExists bool
+	existing    []string
+	deployed    map[string]any
+}
+
+func (f *fakeClient) ResourceGroupExists(ctx context.Context, name string) (bool, error) {
+	return f.groupExists, nil
+}
+
+func (f *fakeClient) ResourceExists(ctx context.Context, id, apiVersion string) (bool, error) {
+	return slices.Contains(f.existing, id), nil
+}
+
+func (f *fakeClient) DeployAtSubscription(ctx context.Context, name, location string, template, parameters map[string]any) error {
+	f.deployed = parameters
+	return nil
+}
+
+func testSpec() Spec {
+	return Spec{
+		SubscriptionID:    "12345678-1234-1234-1234-123456789012",
+		ResourceGroup:     "site-rg",
+		Location:          "westeurope",
+		IdentityName:      "site-identity",
+		VirtualNetworkID:  vnetID,
+		PrivateDNSZoneIDs: []string{zoneA, zoneB},
+	}
+}
+
+func TestTemplateDeclaresParameters(t *testing.T) {
+	template, err := Template()
+	if err != nil {
+		t.Fatalf("Template() error = %v", err)
+	}
+	declared := template["parameters"].(map[string]any)
+	for name := range Parameters(testSpec(), Plan{}) {
+		if _, ok := declared[name]; !ok {
+			t.Errorf("parameter %s is not declared by the template", name)
+		}
+	}
+	for name := range declared {
+		if _, ok := Parameters(testSpec(), Plan{})[name]; !ok {
+			t.Errorf("template parameter %s is never passed", name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	spec := testSpec()
+
+	plan, err := Check(context.Background(), &fakeClient{}, spec)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !plan.ResourceGroup || !plan.Identity || len(plan.PrivateDNSZoneIDs) != 2 {
+		t.Errorf("Check() on a new site = %+v, want everything", plan)
+	}
+
+	client := &fakeClient{
+		groupExists: true,
+		existing: []string{
+			"/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/site-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/site-identity",
+			zoneA + "/virtualNetworkLinks/aks-flex-node-site-vnet",
+		},
+	}
+	plan, err = Check(context.Background(), client, spec)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if plan.ResourceGroup || plan.Identity || !slices.Equal(plan.PrivateDNSZoneIDs, []string{zoneB}) {
+		t.Errorf("Check() = %+v, want only the link of the second zone", plan)
+	}
+	if got := plan.String(); got != "DNS link for privatelink.guestconfiguration.azure.com" {
+		t.Errorf("Plan.String() = %q", got)
+	}
+
+	if err := Deploy(context.Background(), client, spec, plan); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+	value := func(name string) any { return client.deployed[name].(map[string]any)["value"] }
+	if value("createResourceGroup") != false || value("identityName") != "" {
+		t.Errorf("existing resources are deployed again: %v", client.deployed)
+	}
+	if zones := value("privateDnsZoneIds").([]any); len(zones) != 1 || zones[0] != zoneB {
+		t.Errorf("privateDnsZoneIds = %v, want only %s", zones, zoneB)
+	}
+}
+
+func TestDeploymentName(t *testing.T) {
+	spec := testSpec()
+	spec.ResourceGroup = strings.Repeat("Site", 30)
+	if name := DeploymentName(spec); len(name) > 64 || !strings.HasPrefix(name, "aks-flex-node-prerequisites-site") {
+		t.Errorf("DeploymentName() = %q", name)
+	}
+}
@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ErrNoLedger is returned by Ledger.List when nothing was ever recorded, e.g. because the assignments were
+// created by a version of the agent that did not keep a ledger
+var ErrNoLedger = errors.New("no role assignment ledger")
+
+// Ledger records the role assignments a RoleAssigner created, so they can be removed later without touching
+// assignments that already existed on the same scopes for the same principals
+type Ledger interface {
+	Record(ctx context.Context, assignment Assignment) error
+	Forget(ctx context.Context, id string) error
+	List(ctx context.Context) ([]Assignment, error)
+}
+
+// FileLedger keeps the ledger as a JSON file
+type FileLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileLedger creates a ledger stored at path
+func NewFileLedger(path string) *FileLedger {
+	return &FileLedger{path: path}
+}
+
+// Record adds an assignment to the ledger, replacing an entry with the same ID
+func (l *FileLedger) Record(ctx context.Context, assignment Assignment) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := l.read()
+	if err != nil && !errors.Is(err, ErrNoLedger) {
+		return err
+	}
+	entries = removeID(entries, assignment.ID)
+	return l.write(append(entries, assignment))
+}
+
+// Forget removes an assignment from the ledger; unknown IDs are ignored
+func (l *FileLedger) Forget(ctx context.Context, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := l.read()
+	if errors.Is(err, ErrNoLedger) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return l.write(removeID(entries, id))
+}
+
+// List returns the recorded assignments, or ErrNoLedger when the ledger file does not exist
+func (l *FileLedger) List(ctx context.Context) ([]Assignment, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read()
+}
+
+func (l *FileLedger) read() ([]Assignment, error) {
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoLedger
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role assignment ledger %s: %w", l.path, err)
+	}
+	var entries []Assignment
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid role assignment ledger %s: %w", l.path, err)
+	}
+	return entries, nil
+}
+
+// write keeps an empty ledger rather than removing the file, so a node whose assignments were all removed
+// is not mistaken for one that never kept a ledger
+func (l *FileLedger) write(entries []Assignment) error {
+	if entries == nil {
+		entries = []Assignment{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal role assignment ledger: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(l.path)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(l.path), err)
+	}
+	if err := utils.WriteFileAtomicSystem(l.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write role assignment ledger %s: %w", l.path, err)
+	}
+	return nil
+}
+
+func removeID(entries []Assignment, id string) []Assignment {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.ID, id) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,107 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestFileLedger(t *testing.T) {
+	ctx := context.Background()
+	ledger := NewFileLedger(filepath.Join(t.TempDir(), "state", "role-assignments.json"))
+
+	if _, err := ledger.List(ctx); !errors.Is(err, ErrNoLedger) {
+		t.Fatalf("List() error = %v, want ErrNoLedger before anything is recorded", err)
+	}
+	for _, id := range []string{"/scope/a1", "/scope/a2", "/scope/a1"} {
+		if err := ledger.Record(ctx, Assignment{ID: id}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	if err := ledger.Forget(ctx, "/scope/a2"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if err := ledger.Forget(ctx, "/scope/a1"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+
+	entries, err := ledger.List(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("List() = %v, %v, want an empty ledger that still exists", entries, err)
+	}
+}
+
+func TestRemoveRecordedKeepsPreexistingAssignments(t *testing.T) {
+	ctx := context.Background()
+	client := &mockRoleAssignmentsClient{}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+	assigner.Ledger = NewFileLedger(filepath.Join(t.TempDir(), "role-assignments.json"))
+
+	spec := AssignmentSpec{PrincipalID: "principal-1", RoleDefinitionID: "role-1", Scope: "/test/scope", RoleName: "Reader"}
+	if err := assigner.EnsureAssignment(ctx, spec); err != nil {
+		t.Fatalf("EnsureAssignment() error = %v", err)
+	}
+	// An assignment that already existed is reported by ARM as a conflict and must not be recorded
+	client.createErr = errors.New("ERROR CODE: RoleAssignmentExists")
+	if err := assigner.EnsureAssignment(ctx, AssignmentSpec{PrincipalID: "principal-1", RoleDefinitionID: "role-2", Scope: "/test/scope"}); err != nil {
+		t.Fatalf("EnsureAssignment() error = %v", err)
+	}
+
+	recorded, err := assigner.Ledger.List(ctx)
+	if err != nil || len(recorded) != 1 {
+		t.Fatalf("ledger = %v, %v, want the one created assignment", recorded, err)
+	}
+
+	removed, err := assigner.RemoveRecorded(ctx, "principal-2")
+	if err != nil || len(removed) != 0 || len(client.deleted) != 0 {
+		t.Fatalf("RemoveRecorded() for another principal = %v, %v, deleted %v", removed, err, client.deleted)
+	}
+	removed, err = assigner.RemoveRecorded(ctx, "principal-1")
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("RemoveRecorded() = %v, %v", removed, err)
+	}
+	if !slices.Equal(client.deleted, []string{recorded[0].Name}) {
+		t.Errorf("deleted %v, want only %s", client.deleted, recorded[0].Name)
+	}
+	if recorded[0].ID != "/test/scope/providers/Microsoft.Authorization/roleAssignments/"+recorded[0].Name {
+		t.Errorf("recorded ID = %s", recorded[0].ID)
+	}
+	if left, _ := assigner.Ledger.List(ctx); len(left) != 0 {
+		t.Errorf("ledger after removal = %v, want empty", left)
+	}
+}
+
+func TestRemoveRecordedWithoutLedger(t *testing.T) {
+	assigner := NewRoleAssigner(&mockRoleAssignmentsClient{}, testSubscriptionID, newTestLogger())
+	if _, err := assigner.RemoveRecorded(context.Background(), "principal-1"); !errors.Is(err, ErrNoLedger) {
+		t.Errorf("RemoveRecorded() error = %v, want ErrNoLedger", err)
+	}
+}
+
+func TestRemoveManagedAssignmentSkipsOthers(t *testing.T) {
+	managed := newTestAssignment("managed", "principal-1", "role-1")
+	managed.Properties.Description = to.StringPtr(ManagedByDescription)
+	managed.Properties.Scope = to.StringPtr("/test/scope")
+	foreign := newTestAssignment("foreign", "principal-1", "role-1")
+	foreign.Properties.Scope = to.StringPtr("/test/scope")
+	inherited := newTestAssignment("inherited", "principal-1", "role-1")
+	inherited.Properties.Description = to.StringPtr(ManagedByDescription)
+	inherited.Properties.Scope = to.StringPtr("/test")
+
+	client := &mockRoleAssignmentsClient{assignments: []*armauthorization.RoleAssignment{managed, foreign, inherited}}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+	err := assigner.RemoveManagedAssignment(context.Background(), AssignmentSpec{
+		PrincipalID: "principal-1", RoleDefinitionID: "role-1", Scope: "/test/scope",
+	})
+	if err != nil {
+		t.Fatalf("RemoveManagedAssignment() error = %v", err)
+	}
+	if !slices.Equal(client.deleted, []string{"managed"}) {
+		t.Errorf("deleted %v, want only the managed assignment", client.deleted)
+	}
+}
@@ -0,0 +1,111 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/throttle"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+const (
+	// Microsoft Graph endpoint and token scope used to verify principals before role assignment
+	graphEndpoint = "https://graph.microsoft.com"
+	graphScope    = "https://graph.microsoft.com/.default"
+)
+
+// ErrPrincipalNotInDirectory is returned when the principal never shows up in the directory,
+// which usually means the principal ID is wrong rather than still replicating
+var ErrPrincipalNotInDirectory = errors.New("principal not found in directory")
+
+// Principal lookup polling schedule
+const (
+	principalPollInitialDelay = 5 * time.Second
+	principalPollMaxDelay     = 30 * time.Second
+	principalPollTimeout      = 3 * time.Minute
+)
+
+// PrincipalChecker looks up whether a principal exists in the directory
+type PrincipalChecker interface {
+	PrincipalExists(ctx context.Context, principalID string) (bool, error)
+}
+
+// GraphPrincipalChecker queries Microsoft Graph for a service principal by object ID
+type GraphPrincipalChecker struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+// NewGraphPrincipalChecker creates a Graph-backed principal checker using the given credential. options may be nil.
+func NewGraphPrincipalChecker(cred azcore.TokenCredential, options *policy.ClientOptions) (*GraphPrincipalChecker, error) {
+	client, err := azcore.NewClient("rbac.GraphPrincipalChecker", "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{graphScope}, nil)},
+	}, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Microsoft Graph client: %w", err)
+	}
+	return &GraphPrincipalChecker{pipeline: client.Pipeline(), endpoint: graphEndpoint}, nil
+}
+
+// PrincipalExists returns true if Graph knows the service principal, false on 404
+func (g *GraphPrincipalChecker) PrincipalExists(ctx context.Context, principalID string) (bool, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, fmt.Sprintf("%s/v1.0/servicePrincipals/%s?$select=id", g.endpoint, principalID))
+	if err != nil {
+		return false, fmt.Errorf("failed to build Microsoft Graph request: %w", err)
+	}
+
+	resp, err := g.pipeline.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("microsoft Graph request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, runtime.NewResponseError(resp)
+	}
+}
+
+// waitForPrincipal polls the directory with exponential backoff until the principal appears.
+// It returns ErrPrincipalNotInDirectory if the principal is still missing after principalPollTimeout.
+func (r *RoleAssigner) waitForPrincipal(ctx context.Context, principalID string) error {
+	backoff := retry.Backoff{Initial: principalPollInitialDelay, Max: principalPollMaxDelay, Clock: r.Clock, MinDelay: throttle.Shared.Delay}
+	deadline := r.Clock.Now().Add(principalPollTimeout)
+
+	for attempt := 1; ; attempt++ {
+		opCtx, cancel := r.operationContext(ctx)
+		exists, err := r.PrincipalChecker.PrincipalExists(opCtx, principalID)
+		cancel()
+		if err != nil {
+			// Lookup failures are not conclusive; let the caller fall back to its own retries
+			return fmt.Errorf("failed to look up principal %s: %w", principalID, err)
+		}
+		if exists {
+			r.logger.Infof("✅ Principal %s found in directory (attempt %d)", principalID, attempt)
+			return nil
+		}
+
+		delay := backoff.Delay(attempt)
+		if r.Clock.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("%w: %s not visible after %v", ErrPrincipalNotInDirectory, principalID, principalPollTimeout)
+		}
+
+		r.logger.Infof("⏳ Principal %s not yet visible in directory, checking again in %v (attempt %d)...", principalID, delay, attempt)
+		if err := backoff.Wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
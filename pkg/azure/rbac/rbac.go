@@ -0,0 +1,140 @@
+// Package rbac manages Azure role assignments for principals created or used by the agent.
+// It is shared by components that need to grant access to Azure resources and can be used
+// directly by Go consumers of this module.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+)
+
+// RoleAssignmentsClient defines the role assignment operations used by RoleAssigner.
+// It matches the Azure SDK client so tests can substitute a mock.
+type RoleAssignmentsClient interface {
+	Create(ctx context.Context, scope string, roleAssignmentName string, parameters armauthorization.RoleAssignmentCreateParameters, options *armauthorization.RoleAssignmentsClientCreateOptions) (armauthorization.RoleAssignmentsClientCreateResponse, error)
+	Delete(ctx context.Context, scope string, roleAssignmentName string, options *armauthorization.RoleAssignmentsClientDeleteOptions) (armauthorization.RoleAssignmentsClientDeleteResponse, error)
+	NewListForScopePager(scope string, options *armauthorization.RoleAssignmentsClientListForScopeOptions) *runtime.Pager[armauthorization.RoleAssignmentsClientListForScopeResponse]
+}
+
+// azureRoleAssignmentsClient wraps the real Azure SDK client to implement RoleAssignmentsClient
+type azureRoleAssignmentsClient struct {
+	client *armauthorization.RoleAssignmentsClient
+}
+
+// NewRoleAssignmentsClient creates a RoleAssignmentsClient backed by the Azure SDK.
+// options may be nil.
+func NewRoleAssignmentsClient(subscriptionID string, cred azcore.TokenCredential, options *arm.ClientOptions) (RoleAssignmentsClient, error) {
+	client, err := armauthorization.NewRoleAssignmentsClient(subscriptionID, cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role assignments client: %w", err)
+	}
+	return &azureRoleAssignmentsClient{client: client}, nil
+}
+
+func (a *azureRoleAssignmentsClient) Create(ctx context.Context, scope string, roleAssignmentName string, parameters armauthorization.RoleAssignmentCreateParameters, options *armauthorization.RoleAssignmentsClientCreateOptions) (armauthorization.RoleAssignmentsClientCreateResponse, error) {
+	return a.client.Create(ctx, scope, roleAssignmentName, parameters, options)
+}
+
+func (a *azureRoleAssignmentsClient) Delete(ctx context.Context, scope string, roleAssignmentName string, options *armauthorization.RoleAssignmentsClientDeleteOptions) (armauthorization.RoleAssignmentsClientDeleteResponse, error) {
+	return a.client.Delete(ctx, scope, roleAssignmentName, options)
+}
+
+func (a *azureRoleAssignmentsClient) NewListForScopePager(scope string, options *armauthorization.RoleAssignmentsClientListForScopeOptions) *runtime.Pager[armauthorization.RoleAssignmentsClientListForScopeResponse] {
+	return a.client.NewListForScopePager(scope, options)
+}
+
+// ManagedByDescription is written to the description of every role assignment created by RoleAssigner.
+// Reconcile only prunes assignments carrying it, so assignments made by other tools are never touched.
+const ManagedByDescription = "Managed by aks-flex-node"
+
+// AssignmentSpec describes a role assignment to ensure or remove
+type AssignmentSpec struct {
+	PrincipalID      string // Object ID of the principal receiving the role
+	RoleDefinitionID string // Role definition GUID or full role definition resource ID
+	Scope            string // ARM scope of the assignment
+	RoleName         string // Human readable role name, used for logging only
+
+	// Optional ABAC condition narrowing the assignment, e.g. to one storage container, and the version of
+	// the condition language, which defaults to 2.0 when a condition is set
+	Condition        string
+	ConditionVersion string
+
+	// Optional resource ID of the managed identity PrincipalID belongs to, when the identity lives in a
+	// tenant delegated through Azure Lighthouse
+	DelegatedManagedIdentityResourceID string
+}
+
+// DefaultConditionVersion is the only version of the role assignment condition language ARM accepts
+const DefaultConditionVersion = "2.0"
+
+// conditionVersion returns the condition language version sent with the spec's condition
+func (s AssignmentSpec) conditionVersion() string {
+	if s.ConditionVersion == "" {
+		return DefaultConditionVersion
+	}
+	return s.ConditionVersion
+}
+
+// Assignment is an existing role assignment returned by ListAssignments
+type Assignment struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	PrincipalID      string `json:"principalId"`
+	RoleDefinitionID string `json:"roleDefinitionId"`
+	Scope            string `json:"scope"`
+	Description      string `json:"description,omitempty"`
+	Condition        string `json:"condition,omitempty"`
+}
+
+// IsManaged reports whether the assignment was created by this tool
+func (a Assignment) IsManaged() bool {
+	return a.Description == ManagedByDescription
+}
+
+// conditionMatches reports whether the assignment carries the condition of spec, ignoring differences in
+// white space. An assignment without a condition only matches a spec without one: it grants more than a
+// conditional spec asks for, and a conditional assignment grants less than an unconditional spec.
+func (a Assignment) conditionMatches(spec AssignmentSpec) bool {
+	return strings.Join(strings.Fields(a.Condition), " ") == strings.Join(strings.Fields(spec.Condition), " ")
+}
+
+// FullRoleDefinitionID expands a role definition GUID into a subscription-scoped role definition resource ID.
+// Values that are already resource IDs are returned unchanged.
+func FullRoleDefinitionID(subscriptionID, roleDefinitionID string) string {
+	if strings.HasPrefix(roleDefinitionID, "/") {
+		return roleDefinitionID
+	}
+	return fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
+		subscriptionID, roleDefinitionID)
+}
+
+// RoleDefinitionIDForScope expands a role definition GUID into the role definition resource ID ARM expects for
+// an assignment on scope: under the scope's own subscription, or at tenant level for management group and tenant
+// root scopes. subscriptionID is used for scopes whose subscription can't be read. Values that are already
+// resource IDs are returned unchanged.
+func RoleDefinitionIDForScope(subscriptionID, scope, roleDefinitionID string) string {
+	if strings.HasPrefix(roleDefinitionID, "/") {
+		return roleDefinitionID
+	}
+	segments := strings.Split(strings.Trim(scope, "/"), "/")
+	switch {
+	case len(segments) >= 2 && strings.EqualFold(segments[0], "subscriptions") && segments[1] != "":
+		return FullRoleDefinitionID(segments[1], roleDefinitionID)
+	case segments[0] == "" || (len(segments) >= 2 && strings.EqualFold(segments[1], "Microsoft.Management")):
+		return "/providers/Microsoft.Authorization/roleDefinitions/" + roleDefinitionID
+	}
+	return FullRoleDefinitionID(subscriptionID, roleDefinitionID)
+}
+
+// sameRoleDefinition reports whether two role definition IDs or GUIDs name the same role. ARM reports a role
+// under whichever subscription or tenant-level path matches the assignment's scope, so only the trailing GUID
+// identifies it.
+func sameRoleDefinition(a, b string) bool {
+	return strings.EqualFold(a[strings.LastIndex(a, "/")+1:], b[strings.LastIndex(b, "/")+1:])
+}
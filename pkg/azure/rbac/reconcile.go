@@ -0,0 +1,154 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+)
+
+// ReconcileResult summarizes the changes made by Reconcile
+type ReconcileResult struct {
+	Created  []AssignmentSpec
+	Existing []AssignmentSpec
+	Updated  []AssignmentSpec // Assignments created by this tool whose condition was changed to the desired one
+	Pruned   []Assignment
+}
+
+// Reconcile makes the role assignments for the principals and scopes in desired match desired as a set.
+// Missing assignments are created. When prune is true, assignments created by this tool
+// (see ManagedByDescription) for the same principal and scope that are not in desired are deleted.
+// Assignments inherited from parent scopes and assignments made by other tools are never pruned.
+// All entries are attempted; failures are joined into the returned error.
+func (r *RoleAssigner) Reconcile(ctx context.Context, desired []AssignmentSpec, prune bool) (*ReconcileResult, error) {
+	result := &ReconcileResult{}
+	var errs []error
+
+	for idx, spec := range desired {
+		r.logger.Infof("📋 [%d/%d] Reconciling role '%s' for principal %s on scope: %s",
+			idx+1, len(desired), spec.RoleName, spec.PrincipalID, spec.Scope)
+
+		existing, err := r.findAssignments(ctx, spec)
+		if err != nil {
+			// Listing can fail with read-only permissions on the scope; creating is still worth a try
+			r.logger.Warnf("Unable to check existing role assignment '%s': %v", spec.RoleName, err)
+		}
+		if slices.ContainsFunc(existing, func(a Assignment) bool { return a.conditionMatches(spec) }) {
+			r.logger.Infof("✅ Role '%s' already assigned", spec.RoleName)
+			result.Existing = append(result.Existing, spec)
+			continue
+		}
+
+		// ARM allows one assignment of a role to a principal on a scope, whatever its condition, so an
+		// assignment with another condition is updated in place, or kept when another tool made it
+		if stale, ok := onScope(existing, spec); ok {
+			if !stale.IsManaged() {
+				r.logger.Warnf("⚠️  Keeping role assignment %s for role '%s' on scope %s with a different condition, it was not created by aks-flex-node",
+					stale.Name, spec.RoleName, spec.Scope)
+				result.Existing = append(result.Existing, spec)
+				continue
+			}
+			r.logger.Infof("Updating the condition of role assignment %s for role '%s'", stale.Name, spec.RoleName)
+			if err := r.putAssignment(ctx, spec, stale.Name); err != nil {
+				r.logger.Errorf("❌ Failed to update role '%s': %v", spec.RoleName, err)
+				errs = append(errs, fmt.Errorf("role '%s' on %s: %w", spec.RoleName, spec.Scope, err))
+				continue
+			}
+			result.Updated = append(result.Updated, spec)
+			continue
+		}
+
+		if err := r.EnsureAssignment(ctx, spec); err != nil {
+			r.logger.Errorf("❌ Failed to assign role '%s': %v", spec.RoleName, err)
+			errs = append(errs, fmt.Errorf("role '%s' on %s: %w", spec.RoleName, spec.Scope, err))
+			continue
+		}
+		r.logger.Infof("✅ Successfully assigned role '%s'", spec.RoleName)
+		result.Created = append(result.Created, spec)
+	}
+
+	if prune {
+		pruned, err := r.prune(ctx, desired)
+		result.Pruned = pruned
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return result, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// onScope returns the assignment made directly on the spec's scope, as opposed to one inherited from a parent
+func onScope(assignments []Assignment, spec AssignmentSpec) (Assignment, bool) {
+	for _, assignment := range assignments {
+		if strings.EqualFold(assignment.Scope, spec.Scope) {
+			return assignment, true
+		}
+	}
+	return Assignment{}, false
+}
+
+// prune deletes managed assignments on the desired (principal, scope) pairs whose role is not desired
+func (r *RoleAssigner) prune(ctx context.Context, desired []AssignmentSpec) ([]Assignment, error) {
+	type principalScope struct{ principalID, scope string }
+
+	// Group desired role definition IDs by principal and scope, which ARM compares case-insensitively
+	wanted := make(map[principalScope][]string)
+	first := make(map[principalScope]AssignmentSpec)
+	var order []principalScope
+	for _, spec := range desired {
+		key := principalScope{strings.ToLower(spec.PrincipalID), strings.ToLower(spec.Scope)}
+		if _, ok := wanted[key]; !ok {
+			first[key] = spec
+			order = append(order, key)
+		}
+		wanted[key] = append(wanted[key], spec.RoleDefinitionID)
+	}
+
+	var pruned []Assignment
+	var errs []error
+	for _, key := range order {
+		assignments, err := r.ListAssignments(ctx, first[key].Scope, first[key].PrincipalID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, assignment := range assignments {
+			// Only touch assignments we created directly on this scope
+			if !assignment.IsManaged() || !strings.EqualFold(assignment.Scope, key.scope) {
+				continue
+			}
+			// ARM reports the role under the scope's own subscription or at tenant level, so compare GUIDs
+			if slices.ContainsFunc(wanted[key], func(id string) bool { return sameRoleDefinition(id, assignment.RoleDefinitionID) }) {
+				continue
+			}
+
+			r.logger.Infof("🧹 Pruning role assignment %s (%s) for principal %s on scope: %s",
+				assignment.Name, assignment.RoleDefinitionID, assignment.PrincipalID, assignment.Scope)
+			opCtx, cancel := r.operationContext(ctx)
+			_, err := r.client.Delete(opCtx, assignment.Scope, assignment.Name, nil)
+			cancel()
+			if err != nil {
+				if azerrors.IsNotFound(err) {
+					continue
+				}
+				errs = append(errs, fmt.Errorf("failed to prune role assignment %s: %w", assignment.Name, err))
+				continue
+			}
+			r.forget(ctx, assignment)
+			pruned = append(pruned, assignment)
+		}
+	}
+
+	if len(errs) > 0 {
+		return pruned, errors.Join(errs...)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,151 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func newScopedAssignment(name, roleID, scope, description string) *armauthorization.RoleAssignment {
+	assignment := newTestAssignment(name, "principal-1", roleID)
+	assignment.Properties.Scope = to.StringPtr(scope)
+	assignment.Properties.Description = to.StringPtr(description)
+	return assignment
+}
+
+func TestReconcile(t *testing.T) {
+	const testScope = "/subscriptions/" + testSubscriptionID + "/resourceGroups/rg"
+
+	newClient := func() *mockRoleAssignmentsClient {
+		return &mockRoleAssignmentsClient{
+			assignments: []*armauthorization.RoleAssignment{
+				newScopedAssignment("existing", "role-1", testScope, ManagedByDescription),
+				newScopedAssignment("stale", "role-3", testScope, ManagedByDescription),
+				newScopedAssignment("foreign", "role-4", testScope, "created by someone else"),
+				newScopedAssignment("inherited", "role-5", "/subscriptions/"+testSubscriptionID, ManagedByDescription),
+			},
+		}
+	}
+	desired := []AssignmentSpec{
+		{PrincipalID: "principal-1", RoleDefinitionID: "role-1", Scope: testScope, RoleName: "Role 1"},
+		{PrincipalID: "principal-1", RoleDefinitionID: "role-2", Scope: testScope, RoleName: "Role 2"},
+	}
+
+	t.Run("creates missing assignments without pruning", func(t *testing.T) {
+		client := newClient()
+		assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+		result, err := assigner.Reconcile(context.Background(), desired, false)
+		if err != nil {
+			t.Fatalf("Reconcile() unexpected error: %v", err)
+		}
+		if len(result.Existing) != 1 || len(result.Created) != 1 || result.Created[0].RoleDefinitionID != "role-2" {
+			t.Errorf("Reconcile() result = %+v, want role-1 existing and role-2 created", result)
+		}
+		if client.createCalls != 1 {
+			t.Errorf("Expected 1 create call, got %d", client.createCalls)
+		}
+		if len(client.deleted) != 0 {
+			t.Errorf("Expected no deletions without prune, got %v", client.deleted)
+		}
+	})
+
+	t.Run("prunes only stale managed assignments on the same scope", func(t *testing.T) {
+		client := newClient()
+		assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+		result, err := assigner.Reconcile(context.Background(), desired, true)
+		if err != nil {
+			t.Fatalf("Reconcile() unexpected error: %v", err)
+		}
+		if len(client.deleted) != 1 || client.deleted[0] != "stale" {
+			t.Errorf("Expected only 'stale' to be pruned, got %v", client.deleted)
+		}
+		if len(result.Pruned) != 1 {
+			t.Errorf("Expected 1 pruned assignment in result, got %d", len(result.Pruned))
+		}
+	})
+
+	t.Run("keeps desired assignments on scopes outside the subscription", func(t *testing.T) {
+		const otherSubscription = "87654321-4321-4321-4321-210987654321"
+		tests := []struct {
+			name           string
+			scope          string
+			roleDefinition string // Path ARM reports the role definitions under
+		}{
+			{"other subscription", "/subscriptions/" + otherSubscription + "/resourceGroups/edge", "/subscriptions/" + otherSubscription},
+			{"management group", "/providers/Microsoft.Management/managementGroups/edge", ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assignment := func(name, roleID string) *armauthorization.RoleAssignment {
+					a := newScopedAssignment(name, roleID, tt.scope, ManagedByDescription)
+					a.Properties.RoleDefinitionID = to.StringPtr(tt.roleDefinition + "/providers/Microsoft.Authorization/roleDefinitions/" + roleID)
+					return a
+				}
+				client := &mockRoleAssignmentsClient{
+					assignments: []*armauthorization.RoleAssignment{assignment("kept", "role-1"), assignment("stale", "role-2")},
+				}
+				assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+				desired := []AssignmentSpec{{PrincipalID: "PRINCIPAL-1", RoleDefinitionID: "role-1", Scope: tt.scope, RoleName: "Role 1"}}
+
+				result, err := assigner.Reconcile(context.Background(), desired, true)
+				if err != nil {
+					t.Fatalf("Reconcile() unexpected error: %v", err)
+				}
+				if len(result.Existing) != 1 {
+					t.Errorf("Reconcile() result = %+v, want role-1 existing", result)
+				}
+				if len(client.deleted) != 1 || client.deleted[0] != "stale" {
+					t.Errorf("Expected only 'stale' to be pruned, got %v", client.deleted)
+				}
+			})
+		}
+	})
+
+	t.Run("conditions", func(t *testing.T) {
+		const condition = "((!(ActionMatches{'Microsoft.Storage/storageAccounts/blobServices/containers/blobs/read'})) OR " +
+			"(@Resource[Microsoft.Storage/storageAccounts/blobServices/containers:name] StringEquals 'node-logs'))"
+		conditional := func(name, roleID, description, condition string) *armauthorization.RoleAssignment {
+			assignment := newScopedAssignment(name, roleID, testScope, description)
+			assignment.Properties.Condition = to.StringPtr(condition)
+			return assignment
+		}
+		client := &mockRoleAssignmentsClient{
+			assignments: []*armauthorization.RoleAssignment{
+				conditional("matching", "role-1", ManagedByDescription, "  "+condition),
+				conditional("outdated", "role-2", ManagedByDescription, "old condition"),
+				newScopedAssignment("foreign", "role-3", testScope, "created by someone else"),
+			},
+		}
+		assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+		desired := []AssignmentSpec{
+			{PrincipalID: "principal-1", RoleDefinitionID: "role-1", Scope: testScope, Condition: condition},
+			{PrincipalID: "principal-1", RoleDefinitionID: "role-2", Scope: testScope, Condition: condition},
+			{PrincipalID: "principal-1", RoleDefinitionID: "role-3", Scope: testScope, Condition: condition},
+			{PrincipalID: "principal-1", RoleDefinitionID: "role-4", Scope: testScope, Condition: condition, ConditionVersion: "2.0"},
+		}
+
+		result, err := assigner.Reconcile(context.Background(), desired, false)
+		if err != nil {
+			t.Fatalf("Reconcile() unexpected error: %v", err)
+		}
+		if len(result.Existing) != 2 || len(result.Updated) != 1 || len(result.Created) != 1 {
+			t.Fatalf("Reconcile() result = %+v, want role-1 and role-3 existing, role-2 updated and role-4 created", result)
+		}
+		updated, ok := client.created["outdated"]
+		if !ok || to.String(updated.Properties.Condition) != condition {
+			t.Errorf("created %v, want 'outdated' updated in place with the new condition", client.created)
+		}
+		if len(client.created) != 2 {
+			t.Fatalf("created %d assignments, want the update and role-4", len(client.created))
+		}
+		for name, params := range client.created {
+			if to.String(params.Properties.ConditionVersion) != DefaultConditionVersion {
+				t.Errorf("assignment %s has condition version %q, want %q", name, to.String(params.Properties.ConditionVersion), DefaultConditionVersion)
+			}
+		}
+	})
+}
@@ -0,0 +1,400 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/throttle"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+// RoleAssigner creates, removes and lists role assignments for a principal.
+// EnsureAssignment retries with exponential backoff to ride out Azure AD replication delays.
+type RoleAssigner struct {
+	client         RoleAssignmentsClient
+	subscriptionID string
+	logger         *logrus.Logger
+
+	// PrincipalType is set on created assignments; defaults to ServicePrincipal, which lets ARM
+	// skip the directory lookup for freshly created managed identities
+	PrincipalType armauthorization.PrincipalType
+
+	// PrincipalChecker is optional. When set, PrincipalNotFound errors trigger a directory lookup
+	// so a wrong principal ID fails fast instead of exhausting the replication retries.
+	PrincipalChecker PrincipalChecker
+
+	// OperationTimeout bounds each Azure call made by the assigner, including SDK retries.
+	// Zero leaves calls bounded only by the caller's context.
+	OperationTimeout time.Duration
+
+	// Clock paces retries and principal polling; tests replace it with a retry.FakeClock
+	Clock retry.Clock
+
+	// Ledger is optional. When set, assignments created by EnsureAssignment are recorded in it, and
+	// RemoveRecorded deletes exactly those instead of every assignment of a role on a scope.
+	Ledger Ledger
+}
+
+// NewRoleAssigner creates a new RoleAssigner. subscriptionID is used to expand role definition GUIDs.
+func NewRoleAssigner(client RoleAssignmentsClient, subscriptionID string, logger *logrus.Logger) *RoleAssigner {
+	return &RoleAssigner{
+		client:         client,
+		subscriptionID: subscriptionID,
+		logger:         logger,
+		PrincipalType:  armauthorization.PrincipalTypeServicePrincipal,
+		Clock:          retry.RealClock,
+	}
+}
+
+// EnsureAssignment creates the role assignment if it does not exist yet.
+// An existing assignment is treated as success.
+func (r *RoleAssigner) EnsureAssignment(ctx context.Context, spec AssignmentSpec) error {
+	return r.putAssignment(ctx, spec, "")
+}
+
+// putAssignment creates the role assignment named name, or a new one under a fresh name when name is empty.
+// Putting an existing assignment's name replaces its properties, such as its condition.
+func (r *RoleAssigner) putAssignment(ctx context.Context, spec AssignmentSpec, name string) error {
+	principalID := spec.PrincipalID
+	scope := spec.Scope
+	fullRoleDefinitionID := RoleDefinitionIDForScope(r.subscriptionID, scope, spec.RoleDefinitionID)
+
+	const maxRetries = 5
+	backoff := retry.Backoff{Initial: 5 * time.Second, Max: 30 * time.Second, Attempts: maxRetries, Clock: r.Clock, MinDelay: throttle.Shared.Delay}
+
+	var lastErr error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			r.logger.Infof("⏳ Retrying role assignment after %v (attempt %d/%d)...", backoff.Delay(attempt), attempt+1, maxRetries)
+			if err := backoff.Wait(ctx, attempt); err != nil {
+				return err
+			}
+		}
+
+		roleAssignmentName := name
+		if roleAssignmentName == "" {
+			roleAssignmentName = uuid.New().String()
+		}
+		r.logger.Debugf("Calling Azure API to create role assignment with ID: %s (attempt %d/%d)", roleAssignmentName, attempt+1, maxRetries)
+
+		principalType := r.PrincipalType
+		description := ManagedByDescription
+		assignment := armauthorization.RoleAssignmentCreateParameters{
+			Properties: &armauthorization.RoleAssignmentProperties{
+				PrincipalID:      &principalID,
+				RoleDefinitionID: &fullRoleDefinitionID,
+				PrincipalType:    &principalType,
+				Description:      &description,
+			},
+		}
+		if spec.DelegatedManagedIdentityResourceID != "" {
+			assignment.Properties.DelegatedManagedIdentityResourceID = &spec.DelegatedManagedIdentityResourceID
+		}
+		if spec.Condition != "" {
+			condition, version := spec.Condition, spec.conditionVersion()
+			assignment.Properties.Condition = &condition
+			assignment.Properties.ConditionVersion = &version
+		}
+
+		// this create operation is synchronous - we need to wait for the role propagation to take effect afterwards
+		opCtx, cancel := r.operationContext(ctx)
+		response, err := r.client.Create(opCtx, scope, roleAssignmentName, assignment, nil)
+		cancel()
+		if err != nil {
+			lastErr = err
+			class := azerrors.Classify(err)
+
+			switch class.Category {
+			case azerrors.CategoryForbidden:
+				return fmt.Errorf("insufficient permissions to assign roles - ensure the user/service principal has Owner or User Access Administrator role on %s: %w", scope, err)
+			case azerrors.CategoryAlreadyExists:
+				r.logger.Info("ℹ️  Role assignment already exists (detected from error)")
+				return nil
+			}
+
+			// PrincipalNotFound is retriable - likely Azure AD replication delay
+			if class.Category == azerrors.CategoryPrincipalNotFound {
+				// If enabled, ask the directory directly whether the principal exists before burning a retry
+				if r.PrincipalChecker != nil {
+					if waitErr := r.waitForPrincipal(ctx, principalID); waitErr != nil {
+						if errors.Is(waitErr, ErrPrincipalNotInDirectory) {
+							return fmt.Errorf("principal %s does not exist in the directory - check that the principal ID is correct: %w", principalID, err)
+						}
+						if ctx.Err() != nil {
+							return ctx.Err()
+						}
+						r.logger.Warnf("⚠️  Unable to verify principal in directory: %v", waitErr)
+					} else {
+						r.logger.Info("ℹ️  Principal exists in directory, waiting for ARM to catch up...")
+					}
+				}
+				r.logger.Warnf("⚠️  Principal not found (Azure AD replication delay) - will retry...")
+				// Provide detailed error information on last attempt only
+				if attempt == maxRetries-1 {
+					r.logger.Errorf("❌ Role assignment creation failed after %d attempts:", maxRetries)
+					r.logAssignmentFailure(spec, fullRoleDefinitionID, roleAssignmentName, err)
+				}
+				continue // Retry
+			}
+
+			// Throttling and temporary service errors are retried like replication delays
+			if class.Retryable {
+				r.logger.Warnf("⚠️  Role assignment failed with %s error - will retry...", class.Category)
+				continue
+			}
+
+			// Non-retriable error - log details and return
+			r.logger.Errorf("❌ Role assignment creation failed:")
+			r.logAssignmentFailure(spec, fullRoleDefinitionID, roleAssignmentName, err)
+			return fmt.Errorf("failed to create role assignment: %w", azerrors.Wrap(err))
+		}
+
+		// Success
+		r.logger.Debugf("✅ Role assignment created successfully")
+		r.record(ctx, Assignment{
+			ID:               assignmentID(response.ID, scope, roleAssignmentName),
+			Name:             roleAssignmentName,
+			PrincipalID:      principalID,
+			RoleDefinitionID: fullRoleDefinitionID,
+			Scope:            scope,
+			Description:      description,
+			Condition:        spec.Condition,
+		})
+		return nil
+	}
+
+	// Max retries exhausted
+	if azerrors.Is(lastErr, azerrors.CategoryPrincipalNotFound) {
+		return fmt.Errorf("failed to assign role after %d attempts due to Azure AD replication delay - principal not found: %w", maxRetries, lastErr)
+	}
+	return fmt.Errorf("failed to assign role after %d attempts: %w", maxRetries, azerrors.Wrap(lastErr))
+}
+
+// logAssignmentFailure logs the details of a failed role assignment creation
+func (r *RoleAssigner) logAssignmentFailure(spec AssignmentSpec, fullRoleDefinitionID, roleAssignmentName string, err error) {
+	r.logger.Errorf("   Principal ID: %s", spec.PrincipalID)
+	r.logger.Errorf("   Role Name: %s", spec.RoleName)
+	r.logger.Errorf("   Role Definition ID: %s", fullRoleDefinitionID)
+	r.logger.Errorf("   Scope: %s", spec.Scope)
+	r.logger.Errorf("   Assignment Name: %s", roleAssignmentName)
+	r.logger.Errorf("   Azure API Error: %v", err)
+}
+
+// RemoveAssignment deletes every assignment of the role to the principal on the scope, including assignments
+// made by other tools; RemoveRecorded removes only the assignments this tool created.
+// Assignments that are already gone are ignored.
+func (r *RoleAssigner) RemoveAssignment(ctx context.Context, spec AssignmentSpec) error {
+	assignments, err := r.findAssignments(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		r.logger.Debugf("Deleting role assignment: %s", assignment.Name)
+		opCtx, cancel := r.operationContext(ctx)
+		_, err := r.client.Delete(opCtx, spec.Scope, assignment.Name, nil)
+		cancel()
+		if err != nil {
+			if azerrors.IsNotFound(err) {
+				r.logger.Debugf("Role assignment %s not found (already deleted)", assignment.Name)
+				continue
+			}
+			return fmt.Errorf("failed to delete role assignment %s: %w", assignment.Name, err)
+		}
+		r.logger.Debugf("Successfully deleted role assignment: %s", assignment.Name)
+	}
+
+	if len(assignments) == 0 {
+		r.logger.Debugf("No role assignments found for role %s on scope %s", spec.RoleName, spec.Scope)
+	}
+	return nil
+}
+
+// RemoveManagedAssignment deletes the assignments of the role to the principal on the scope that were created
+// by this tool (see ManagedByDescription), leaving assignments made by others alone
+func (r *RoleAssigner) RemoveManagedAssignment(ctx context.Context, spec AssignmentSpec) error {
+	assignments, err := r.findAssignments(ctx, spec)
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		if !strings.EqualFold(assignment.Scope, spec.Scope) {
+			continue // Inherited from a parent scope
+		}
+		if !assignment.IsManaged() {
+			r.logger.Infof("Keeping role assignment %s for role %s on scope %s, it was not created by aks-flex-node",
+				assignment.Name, spec.RoleName, spec.Scope)
+			continue
+		}
+		if err := r.deleteAssignment(ctx, assignment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveRecorded deletes the assignments recorded in the ledger for the given principals and forgets them.
+// It returns ErrNoLedger when no ledger is set or nothing was ever recorded, so callers can fall back to
+// RemoveManagedAssignment. All entries are attempted; failures are joined into the returned error.
+func (r *RoleAssigner) RemoveRecorded(ctx context.Context, principalIDs ...string) ([]Assignment, error) {
+	if r.Ledger == nil {
+		return nil, ErrNoLedger
+	}
+	recorded, err := r.Ledger.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Assignment
+	var errs []error
+	for _, assignment := range recorded {
+		if !containsFold(principalIDs, assignment.PrincipalID) {
+			continue
+		}
+		r.logger.Infof("Removing role assignment %s created by aks-flex-node on scope %s", assignment.Name, assignment.Scope)
+		if err := r.deleteAssignment(ctx, assignment); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, assignment)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// deleteAssignment deletes an assignment and forgets it in the ledger; assignments already gone are ignored
+func (r *RoleAssigner) deleteAssignment(ctx context.Context, assignment Assignment) error {
+	opCtx, cancel := r.operationContext(ctx)
+	_, err := r.client.Delete(opCtx, assignment.Scope, assignment.Name, nil)
+	cancel()
+	if err != nil && !azerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete role assignment %s: %w", assignment.Name, err)
+	}
+	r.forget(ctx, assignment)
+	return nil
+}
+
+// record adds a created assignment to the ledger. A failure is only logged: the assignment exists and
+// works, and failing the operation would not bring the record back.
+func (r *RoleAssigner) record(ctx context.Context, assignment Assignment) {
+	if r.Ledger == nil {
+		return
+	}
+	if err := r.Ledger.Record(ctx, assignment); err != nil {
+		r.logger.Warnf("⚠️  Role assignment %s was created but could not be recorded, uninstall will not remove it: %v",
+			assignment.ID, err)
+	}
+}
+
+// forget removes a deleted assignment from the ledger
+func (r *RoleAssigner) forget(ctx context.Context, assignment Assignment) {
+	if r.Ledger == nil || assignment.ID == "" {
+		return
+	}
+	if err := r.Ledger.Forget(ctx, assignment.ID); err != nil {
+		r.logger.Warnf("Failed to remove role assignment %s from the ledger: %v", assignment.ID, err)
+	}
+}
+
+// assignmentID returns the resource ID of a created assignment, built from its scope and name when ARM did not
+// return it
+func assignmentID(id *string, scope, name string) string {
+	if id != nil && *id != "" {
+		return *id
+	}
+	return strings.TrimSuffix(scope, "/") + "/providers/Microsoft.Authorization/roleAssignments/" + name
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAssignment checks if the principal has the role on the scope, with the spec's condition if any
+func (r *RoleAssigner) HasAssignment(ctx context.Context, spec AssignmentSpec) (bool, error) {
+	assignments, err := r.findAssignments(ctx, spec)
+	if err != nil {
+		return false, err
+	}
+	for _, assignment := range assignments {
+		if assignment.conditionMatches(spec) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListAssignments lists role assignments that apply to scope. If principalID is not empty,
+// only assignments for that principal are returned.
+func (r *RoleAssigner) ListAssignments(ctx context.Context, scope, principalID string) ([]Assignment, error) {
+	pager := r.client.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
+		Filter: nil, // We'll filter programmatically
+	})
+
+	var assignments []Assignment
+	for pager.More() {
+		opCtx, cancel := r.operationContext(ctx)
+		page, err := pager.NextPage(opCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
+		}
+
+		for _, ra := range page.Value {
+			if ra == nil || ra.Properties == nil ||
+				ra.Properties.PrincipalID == nil ||
+				ra.Properties.RoleDefinitionID == nil {
+				continue
+			}
+			if principalID != "" && !strings.EqualFold(*ra.Properties.PrincipalID, principalID) {
+				continue
+			}
+			assignments = append(assignments, Assignment{
+				ID:               to.String(ra.ID),
+				Name:             to.String(ra.Name),
+				PrincipalID:      *ra.Properties.PrincipalID,
+				RoleDefinitionID: *ra.Properties.RoleDefinitionID,
+				Scope:            to.String(ra.Properties.Scope),
+				Description:      to.String(ra.Properties.Description),
+				Condition:        to.String(ra.Properties.Condition),
+			})
+		}
+	}
+	return assignments, nil
+}
+
+// operationContext derives the context for one Azure call, bounded by OperationTimeout when set
+func (r *RoleAssigner) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.OperationTimeout)
+}
+
+// findAssignments returns the assignments matching the principal and role of spec on its scope
+func (r *RoleAssigner) findAssignments(ctx context.Context, spec AssignmentSpec) ([]Assignment, error) {
+	assignments, err := r.ListAssignments(ctx, spec.Scope, spec.PrincipalID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Assignment
+	for _, assignment := range assignments {
+		if sameRoleDefinition(assignment.RoleDefinitionID, spec.RoleDefinitionID) {
+			matches = append(matches, assignment)
+		}
+	}
+	return matches, nil
+}
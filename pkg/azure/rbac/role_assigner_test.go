@@ -0,0 +1,341 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+const testSubscriptionID = "12345678-1234-1234-1234-123456789012"
+
+// mockRoleAssignmentsClient is a mock implementation for testing
+type mockRoleAssignmentsClient struct {
+	createHangs bool // Create blocks until its context is done
+	createErr   error
+	deleteErr   error
+	assignments []*armauthorization.RoleAssignment
+	createCalls int
+	created     map[string]armauthorization.RoleAssignmentCreateParameters // By assignment name
+	deleted     []string
+}
+
+func (m *mockRoleAssignmentsClient) Create(ctx context.Context, scope string, roleAssignmentName string, parameters armauthorization.RoleAssignmentCreateParameters, options *armauthorization.RoleAssignmentsClientCreateOptions) (armauthorization.RoleAssignmentsClientCreateResponse, error) {
+	m.createCalls++
+	if m.created == nil {
+		m.created = map[string]armauthorization.RoleAssignmentCreateParameters{}
+	}
+	m.created[roleAssignmentName] = parameters
+	if m.createHangs {
+		<-ctx.Done()
+		return armauthorization.RoleAssignmentsClientCreateResponse{}, ctx.Err()
+	}
+	return armauthorization.RoleAssignmentsClientCreateResponse{}, m.createErr
+}
+
+func (m *mockRoleAssignmentsClient) Delete(ctx context.Context, scope string, roleAssignmentName string, options *armauthorization.RoleAssignmentsClientDeleteOptions) (armauthorization.RoleAssignmentsClientDeleteResponse, error) {
+	m.deleted = append(m.deleted, roleAssignmentName)
+	return armauthorization.RoleAssignmentsClientDeleteResponse{}, m.deleteErr
+}
+
+func (m *mockRoleAssignmentsClient) NewListForScopePager(scope string, options *armauthorization.RoleAssignmentsClientListForScopeOptions) *runtime.Pager[armauthorization.RoleAssignmentsClientListForScopeResponse] {
+	done := false
+	return runtime.NewPager(runtime.PagingHandler[armauthorization.RoleAssignmentsClientListForScopeResponse]{
+		More: func(armauthorization.RoleAssignmentsClientListForScopeResponse) bool { return !done },
+		Fetcher: func(ctx context.Context, _ *armauthorization.RoleAssignmentsClientListForScopeResponse) (armauthorization.RoleAssignmentsClientListForScopeResponse, error) {
+			done = true
+			return armauthorization.RoleAssignmentsClientListForScopeResponse{
+				RoleAssignmentListResult: armauthorization.RoleAssignmentListResult{Value: m.assignments},
+			}, nil
+		},
+	})
+}
+
+// mockPrincipalChecker is a mock implementation of PrincipalChecker for testing
+type mockPrincipalChecker struct {
+	existsAfter int // number of lookups that report the principal missing before it appears; -1 means never
+	callCount   int
+}
+
+func (m *mockPrincipalChecker) PrincipalExists(ctx context.Context, principalID string) (bool, error) {
+	m.callCount++
+	return m.existsAfter >= 0 && m.callCount > m.existsAfter, nil
+}
+
+func newTestAssignment(name, principalID, roleID string) *armauthorization.RoleAssignment {
+	return &armauthorization.RoleAssignment{
+		Name: to.StringPtr(name),
+		Properties: &armauthorization.RoleAssignmentProperties{
+			PrincipalID:      to.StringPtr(principalID),
+			RoleDefinitionID: to.StringPtr(FullRoleDefinitionID(testSubscriptionID, roleID)),
+		},
+	}
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	return logger
+}
+
+func TestFullRoleDefinitionID(t *testing.T) {
+	got := FullRoleDefinitionID(testSubscriptionID, "acdd72a7-3385-48ef-bd42-f606fba81ae7")
+	want := "/subscriptions/" + testSubscriptionID + "/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7"
+	if got != want {
+		t.Errorf("FullRoleDefinitionID() = %q, want %q", got, want)
+	}
+	if got := FullRoleDefinitionID(testSubscriptionID, want); got != want {
+		t.Errorf("FullRoleDefinitionID() should keep full IDs unchanged, got %q", got)
+	}
+}
+
+func TestListAssignments_FiltersByPrincipal(t *testing.T) {
+	client := &mockRoleAssignmentsClient{
+		assignments: []*armauthorization.RoleAssignment{
+			newTestAssignment("a1", "principal-1", "role-1"),
+			newTestAssignment("a2", "principal-2", "role-1"),
+			nil,
+		},
+	}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+	assignments, err := assigner.ListAssignments(context.Background(), "/test/scope", "principal-1")
+	if err != nil {
+		t.Fatalf("ListAssignments() unexpected error: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].Name != "a1" {
+		t.Errorf("ListAssignments() = %+v, want only a1", assignments)
+	}
+
+	// Principal IDs are GUIDs, which Azure may return in a different case than the caller holds
+	assignments, err = assigner.ListAssignments(context.Background(), "/test/scope", "PRINCIPAL-2")
+	if err != nil {
+		t.Fatalf("ListAssignments() unexpected error: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].Name != "a2" {
+		t.Errorf("ListAssignments() = %+v, want a2 matched regardless of case", assignments)
+	}
+}
+
+func TestRemoveAssignment_DeletesMatchingOnly(t *testing.T) {
+	client := &mockRoleAssignmentsClient{
+		assignments: []*armauthorization.RoleAssignment{
+			newTestAssignment("a1", "principal-1", "role-1"),
+			newTestAssignment("a2", "principal-1", "role-2"),
+			newTestAssignment("a3", "principal-2", "role-1"),
+		},
+	}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+	err := assigner.RemoveAssignment(context.Background(), AssignmentSpec{
+		PrincipalID:      "principal-1",
+		RoleDefinitionID: "role-1",
+		Scope:            "/test/scope",
+	})
+	if err != nil {
+		t.Fatalf("RemoveAssignment() unexpected error: %v", err)
+	}
+	if len(client.deleted) != 1 || client.deleted[0] != "a1" {
+		t.Errorf("RemoveAssignment() deleted %v, want [a1]", client.deleted)
+	}
+}
+
+func TestRemoveAssignment_IgnoresNotFound(t *testing.T) {
+	client := &mockRoleAssignmentsClient{
+		assignments: []*armauthorization.RoleAssignment{newTestAssignment("a1", "principal-1", "role-1")},
+		deleteErr:   errors.New("RoleAssignmentNotFound"),
+	}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+	err := assigner.RemoveAssignment(context.Background(), AssignmentSpec{
+		PrincipalID:      "principal-1",
+		RoleDefinitionID: "role-1",
+		Scope:            "/test/scope",
+	})
+	if err != nil {
+		t.Errorf("RemoveAssignment() should ignore already deleted assignments, got: %v", err)
+	}
+}
+
+func TestHasAssignment(t *testing.T) {
+	client := &mockRoleAssignmentsClient{
+		assignments: []*armauthorization.RoleAssignment{newTestAssignment("a1", "principal-1", "role-1")},
+	}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+	has, err := assigner.HasAssignment(context.Background(), AssignmentSpec{PrincipalID: "principal-1", RoleDefinitionID: "role-1", Scope: "/test/scope"})
+	if err != nil || !has {
+		t.Errorf("HasAssignment() = %v, %v, want true", has, err)
+	}
+	has, err = assigner.HasAssignment(context.Background(), AssignmentSpec{PrincipalID: "principal-1", RoleDefinitionID: "role-2", Scope: "/test/scope"})
+	if err != nil || has {
+		t.Errorf("HasAssignment() = %v, %v, want false", has, err)
+	}
+}
+
+func TestHasAssignment_ScopeOutsideSubscription(t *testing.T) {
+	tests := []struct {
+		name             string
+		scope            string
+		roleDefinitionID string // As ARM reports it on the assignment
+	}{
+		{
+			name:             "other subscription",
+			scope:            "/subscriptions/87654321-4321-4321-4321-210987654321/resourceGroups/edge",
+			roleDefinitionID: "/subscriptions/87654321-4321-4321-4321-210987654321/providers/Microsoft.Authorization/roleDefinitions/ROLE-1",
+		},
+		{
+			name:             "management group",
+			scope:            "/providers/Microsoft.Management/managementGroups/edge",
+			roleDefinitionID: "/providers/Microsoft.Authorization/roleDefinitions/role-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment := newTestAssignment("a1", "principal-1", "role-1")
+			assignment.Properties.RoleDefinitionID = to.StringPtr(tt.roleDefinitionID)
+			client := &mockRoleAssignmentsClient{assignments: []*armauthorization.RoleAssignment{assignment}}
+			assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+			has, err := assigner.HasAssignment(context.Background(), AssignmentSpec{PrincipalID: "principal-1", RoleDefinitionID: "role-1", Scope: tt.scope})
+			if err != nil || !has {
+				t.Errorf("HasAssignment() = %v, %v, want true", has, err)
+			}
+		})
+	}
+}
+
+func TestRoleDefinitionIDForScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  string
+	}{
+		{"/subscriptions/" + testSubscriptionID + "/resourceGroups/rg", "/subscriptions/" + testSubscriptionID + "/providers/Microsoft.Authorization/roleDefinitions/role-1"},
+		{"/subscriptions/other/resourceGroups/rg", "/subscriptions/other/providers/Microsoft.Authorization/roleDefinitions/role-1"},
+		{"/providers/Microsoft.Management/managementGroups/edge", "/providers/Microsoft.Authorization/roleDefinitions/role-1"},
+		{"/", "/providers/Microsoft.Authorization/roleDefinitions/role-1"},
+	}
+
+	for _, tt := range tests {
+		if got := RoleDefinitionIDForScope(testSubscriptionID, tt.scope, "role-1"); got != tt.want {
+			t.Errorf("RoleDefinitionIDForScope(%q) = %q, want %q", tt.scope, got, tt.want)
+		}
+	}
+	const full = "/subscriptions/x/providers/Microsoft.Authorization/roleDefinitions/role-1"
+	if got := RoleDefinitionIDForScope(testSubscriptionID, "/", full); got != full {
+		t.Errorf("RoleDefinitionIDForScope() = %q, want resource IDs unchanged", got)
+	}
+}
+
+func TestEnsureAssignment_DelegatedManagedIdentity(t *testing.T) {
+	const identityID = "/subscriptions/87654321-4321-4321-4321-210987654321/resourceGroups/onboarding/providers/Microsoft.ManagedIdentity/userAssignedIdentities/provisioner"
+	client := &mockRoleAssignmentsClient{}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+
+	for _, delegated := range []string{identityID, ""} {
+		client.created = nil
+		err := assigner.EnsureAssignment(context.Background(), AssignmentSpec{
+			PrincipalID:                        "principal",
+			RoleDefinitionID:                   "role-1",
+			Scope:                              "/test/scope",
+			DelegatedManagedIdentityResourceID: delegated,
+		})
+		if err != nil || len(client.created) != 1 {
+			t.Fatalf("EnsureAssignment() = %v with %d creates, want one assignment created", err, len(client.created))
+		}
+		for _, params := range client.created {
+			got := params.Properties.DelegatedManagedIdentityResourceID
+			if (delegated == "" && got != nil) || (delegated != "" && (got == nil || *got != delegated)) {
+				t.Errorf("created assignment delegated identity = %v, want %q", got, delegated)
+			}
+		}
+	}
+}
+
+func TestEnsureAssignment_PrincipalNotInDirectory_FailsFast(t *testing.T) {
+	client := &mockRoleAssignmentsClient{
+		createErr: errors.New("ERROR CODE: PrincipalNotFound"),
+	}
+	checker := &mockPrincipalChecker{existsAfter: -1}
+	clock := retry.NewFakeClock(time.Now())
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+	assigner.PrincipalChecker = checker
+	assigner.Clock = clock
+
+	err := assigner.EnsureAssignment(context.Background(), AssignmentSpec{
+		PrincipalID:      "wrong-principal-id",
+		RoleDefinitionID: "role-1",
+		Scope:            "/test/scope",
+	})
+	if err == nil {
+		t.Fatal("Expected error for principal missing from directory, got nil")
+	}
+	if !strings.Contains(err.Error(), "does not exist in the directory") {
+		t.Errorf("Expected 'does not exist in the directory' error message, got: %v", err)
+	}
+	if client.createCalls != 1 {
+		t.Errorf("Expected 1 API call (no ARM retries for unknown principal), got %d", client.createCalls)
+	}
+	if checker.callCount < 2 {
+		t.Errorf("Expected directory to be polled more than once, got %d", checker.callCount)
+	}
+	var waited time.Duration
+	for _, d := range clock.Sleeps() {
+		waited += d
+	}
+	if waited > principalPollTimeout {
+		t.Errorf("Expected polling to stop within %v, waited %v", principalPollTimeout, waited)
+	}
+}
+
+func TestWaitForPrincipal_AppearsAfterPolling(t *testing.T) {
+	checker := &mockPrincipalChecker{existsAfter: 2}
+	clock := retry.NewFakeClock(time.Now())
+	assigner := NewRoleAssigner(&mockRoleAssignmentsClient{}, testSubscriptionID, newTestLogger())
+	assigner.PrincipalChecker = checker
+	assigner.Clock = clock
+
+	if err := assigner.waitForPrincipal(context.Background(), "test-principal-id"); err != nil {
+		t.Fatalf("Expected principal to be found, got: %v", err)
+	}
+	if checker.callCount != 3 {
+		t.Errorf("Expected 3 lookups, got %d", checker.callCount)
+	}
+	if want := []time.Duration{5 * time.Second, 10 * time.Second}; !slices.Equal(clock.Sleeps(), want) {
+		t.Errorf("Expected polling delays %v, got %v", want, clock.Sleeps())
+	}
+}
+
+func TestEnsureAssignmentOperationTimeout(t *testing.T) {
+	client := &mockRoleAssignmentsClient{createHangs: true}
+	assigner := NewRoleAssigner(client, testSubscriptionID, newTestLogger())
+	assigner.OperationTimeout = 20 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := assigner.EnsureAssignment(ctx, AssignmentSpec{
+		PrincipalID:      "principal",
+		RoleDefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+		RoleName:         "Reader",
+		Scope:            "/subscriptions/" + testSubscriptionID,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("EnsureAssignment() error = %v, want a deadline exceeded error", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("EnsureAssignment() should fail on the operation timeout, not the caller's deadline")
+	}
+	if client.createCalls != 1 {
+		t.Errorf("Create called %d times, want 1 for a non-retryable error", client.createCalls)
+	}
+}
@@ -0,0 +1,279 @@
+package scope
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind identifies the level of an Azure RBAC scope
+type Kind string
+
+const (
+	KindManagementGroup Kind = "ManagementGroup"
+	KindSubscription    Kind = "Subscription"
+	KindResourceGroup   Kind = "ResourceGroup"
+	KindResource        Kind = "Resource"
+)
+
+var (
+	// subscriptionIDPattern matches a subscription GUID
+	subscriptionIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	// resourceGroupPattern follows ARM naming rules: 1-90 chars of alphanumerics, underscores, parentheses, hyphens and periods
+	resourceGroupPattern = regexp.MustCompile(`^[-\w\.\(\)]{1,90}$`)
+
+	// managementGroupPattern follows ARM naming rules: 1-90 chars of alphanumerics, underscores, parentheses, hyphens and periods
+	managementGroupPattern = regexp.MustCompile(`^[-\w\.\(\)]{1,90}$`)
+
+	// providerNamespacePattern matches resource provider namespaces such as Microsoft.ContainerService
+	providerNamespacePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(\.[A-Za-z][A-Za-z0-9]*)+$`)
+
+	// placeholderPattern matches template placeholders such as {subscriptionId}
+	placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+)
+
+// Scope is a parsed and validated Azure RBAC scope
+type Scope struct {
+	Kind              Kind
+	ManagementGroup   string
+	SubscriptionID    string
+	ResourceGroup     string
+	ProviderNamespace string
+	// ResourceTypes and ResourceNames hold the type/name pairs of a (possibly nested) resource,
+	// e.g. ["virtualNetworks", "subnets"] and ["vnet1", "default"]
+	ResourceTypes []string
+	ResourceNames []string
+}
+
+// String returns the canonical ARM form of the scope
+func (s *Scope) String() string {
+	switch s.Kind {
+	case KindManagementGroup:
+		return "/providers/Microsoft.Management/managementGroups/" + s.ManagementGroup
+	case KindSubscription:
+		return "/subscriptions/" + s.SubscriptionID
+	case KindResourceGroup:
+		return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", s.SubscriptionID, s.ResourceGroup)
+	case KindResource:
+		var b strings.Builder
+		fmt.Fprintf(&b, "/subscriptions/%s/resourceGroups/%s/providers/%s", s.SubscriptionID, s.ResourceGroup, s.ProviderNamespace)
+		for idx := range s.ResourceTypes {
+			fmt.Fprintf(&b, "/%s/%s", s.ResourceTypes[idx], s.ResourceNames[idx])
+		}
+		return b.String()
+	default:
+		return ""
+	}
+}
+
+// Subscription builds a subscription-level scope
+func Subscription(subscriptionID string) (string, error) {
+	s := &Scope{Kind: KindSubscription, SubscriptionID: subscriptionID}
+	if err := s.validate(); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// ResourceGroup builds a resource group-level scope
+func ResourceGroup(subscriptionID, resourceGroup string) (string, error) {
+	s := &Scope{Kind: KindResourceGroup, SubscriptionID: subscriptionID, ResourceGroup: resourceGroup}
+	if err := s.validate(); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// Resource builds a resource-level scope. resourceType may contain nested types separated by
+// "/" (e.g. "virtualNetworks/subnets"), in which case name must contain the matching names.
+func Resource(subscriptionID, resourceGroup, providerNamespace, resourceType, name string) (string, error) {
+	types := strings.Split(resourceType, "/")
+	names := strings.Split(name, "/")
+	if len(types) != len(names) {
+		return "", fmt.Errorf("resource type %q and name %q have a different number of segments", resourceType, name)
+	}
+	s := &Scope{
+		Kind:              KindResource,
+		SubscriptionID:    subscriptionID,
+		ResourceGroup:     resourceGroup,
+		ProviderNamespace: providerNamespace,
+		ResourceTypes:     types,
+		ResourceNames:     names,
+	}
+	if err := s.validate(); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// ManagementGroup builds a management group-level scope
+func ManagementGroup(groupID string) (string, error) {
+	s := &Scope{Kind: KindManagementGroup, ManagementGroup: groupID}
+	if err := s.validate(); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// Validate checks that scope is a well-formed ARM scope
+func Validate(scope string) error {
+	_, err := Parse(scope)
+	return err
+}
+
+// Parse parses and validates an ARM scope string.
+// Keywords such as "subscriptions" and "resourceGroups" are matched case-insensitively, as ARM does.
+func Parse(scope string) (*Scope, error) {
+	if scope == "" {
+		return nil, fmt.Errorf("scope is empty")
+	}
+	if !strings.HasPrefix(scope, "/") {
+		return nil, fmt.Errorf("scope %q must start with '/'", scope)
+	}
+	if strings.HasSuffix(scope, "/") {
+		return nil, fmt.Errorf("scope %q must not end with '/'", scope)
+	}
+
+	segments := strings.Split(strings.TrimPrefix(scope, "/"), "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("scope %q contains an empty path segment", scope)
+		}
+	}
+
+	s, err := parseSegments(segments)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scope %q: %w", scope, err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scope %q: %w", scope, err)
+	}
+	return s, nil
+}
+
+func parseSegments(segments []string) (*Scope, error) {
+	// /providers/Microsoft.Management/managementGroups/{id}
+	if strings.EqualFold(segments[0], "providers") {
+		if len(segments) != 4 ||
+			!strings.EqualFold(segments[1], "Microsoft.Management") ||
+			!strings.EqualFold(segments[2], "managementGroups") {
+			return nil, fmt.Errorf("expected /providers/Microsoft.Management/managementGroups/{groupId}")
+		}
+		return &Scope{Kind: KindManagementGroup, ManagementGroup: segments[3]}, nil
+	}
+
+	if !strings.EqualFold(segments[0], "subscriptions") || len(segments) < 2 {
+		return nil, fmt.Errorf("expected /subscriptions/{subscriptionId} or /providers/Microsoft.Management/managementGroups/{groupId}")
+	}
+	s := &Scope{Kind: KindSubscription, SubscriptionID: segments[1]}
+	if len(segments) == 2 {
+		return s, nil
+	}
+
+	if !strings.EqualFold(segments[2], "resourceGroups") || len(segments) < 4 {
+		return nil, fmt.Errorf("expected /subscriptions/{subscriptionId}/resourceGroups/{resourceGroup}")
+	}
+	s.Kind = KindResourceGroup
+	s.ResourceGroup = segments[3]
+	if len(segments) == 4 {
+		return s, nil
+	}
+
+	// /providers/{namespace}/{type}/{name}[/{type}/{name}...]
+	rest := segments[4:]
+	if !strings.EqualFold(rest[0], "providers") || len(rest) < 4 || len(rest)%2 != 0 {
+		return nil, fmt.Errorf("expected /providers/{namespace}/{type}/{name} after the resource group")
+	}
+	s.Kind = KindResource
+	s.ProviderNamespace = rest[1]
+	for idx := 2; idx < len(rest); idx += 2 {
+		s.ResourceTypes = append(s.ResourceTypes, rest[idx])
+		s.ResourceNames = append(s.ResourceNames, rest[idx+1])
+	}
+	return s, nil
+}
+
+// validate checks the individual components of a scope
+func (s *Scope) validate() error {
+	switch s.Kind {
+	case KindManagementGroup:
+		if !managementGroupPattern.MatchString(s.ManagementGroup) {
+			return fmt.Errorf("invalid management group ID %q", s.ManagementGroup)
+		}
+		return nil
+	case KindSubscription, KindResourceGroup, KindResource:
+	default:
+		return fmt.Errorf("unknown scope kind %q", s.Kind)
+	}
+
+	if !subscriptionIDPattern.MatchString(s.SubscriptionID) {
+		return fmt.Errorf("invalid subscription ID %q: expected a GUID", s.SubscriptionID)
+	}
+	if s.Kind == KindSubscription {
+		return nil
+	}
+
+	if !resourceGroupPattern.MatchString(s.ResourceGroup) || strings.HasSuffix(s.ResourceGroup, ".") {
+		return fmt.Errorf("invalid resource group name %q", s.ResourceGroup)
+	}
+	if s.Kind == KindResourceGroup {
+		return nil
+	}
+
+	if !providerNamespacePattern.MatchString(s.ProviderNamespace) {
+		return fmt.Errorf("invalid resource provider namespace %q", s.ProviderNamespace)
+	}
+	if len(s.ResourceTypes) == 0 || len(s.ResourceTypes) != len(s.ResourceNames) {
+		return fmt.Errorf("resource scope requires matching resource type and name segments")
+	}
+	for idx := range s.ResourceTypes {
+		if s.ResourceTypes[idx] == "" || s.ResourceNames[idx] == "" {
+			return fmt.Errorf("resource type and name segments must not be empty")
+		}
+		if strings.ContainsAny(s.ResourceTypes[idx]+s.ResourceNames[idx], "/{}") {
+			return fmt.Errorf("invalid resource segment %s/%s", s.ResourceTypes[idx], s.ResourceNames[idx])
+		}
+	}
+	return nil
+}
+
+// Expand substitutes {placeholder} references in template with values from vars and validates the result.
+// Unknown placeholders and placeholders whose value is empty are reported as errors, so a typo in the
+// config fails at load time instead of producing a malformed scope.
+func Expand(template string, vars map[string]string) (string, error) {
+	var expandErr error
+	expanded := placeholderPattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := match[1 : len(match)-1]
+		value, ok := vars[name]
+		if expandErr == nil {
+			switch {
+			case !ok:
+				expandErr = fmt.Errorf("unknown placeholder %s in scope %q. Supported placeholders are: %s",
+					match, template, strings.Join(placeholderNames(vars), ", "))
+			case value == "":
+				expandErr = fmt.Errorf("placeholder %s in scope %q has no value", match, template)
+			}
+		}
+		return value
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	if err := Validate(expanded); err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// placeholderNames returns the supported placeholders in {name} form, sorted for stable error messages
+func placeholderNames(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return names
+}
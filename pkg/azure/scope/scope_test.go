@@ -0,0 +1,146 @@
+package scope
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSubscriptionID = "12345678-1234-1234-1234-123456789012"
+
+func TestBuilders(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func() (string, error)
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "subscription",
+			build: func() (string, error) { return Subscription(testSubscriptionID) },
+			want:  "/subscriptions/" + testSubscriptionID,
+		},
+		{
+			name:  "resource group",
+			build: func() (string, error) { return ResourceGroup(testSubscriptionID, "my-rg") },
+			want:  "/subscriptions/" + testSubscriptionID + "/resourceGroups/my-rg",
+		},
+		{
+			name: "resource",
+			build: func() (string, error) {
+				return Resource(testSubscriptionID, "my-rg", "Microsoft.ContainerService", "managedClusters", "aks")
+			},
+			want: "/subscriptions/" + testSubscriptionID + "/resourceGroups/my-rg/providers/Microsoft.ContainerService/managedClusters/aks",
+		},
+		{
+			name: "nested resource",
+			build: func() (string, error) {
+				return Resource(testSubscriptionID, "my-rg", "Microsoft.Network", "virtualNetworks/subnets", "vnet/default")
+			},
+			want: "/subscriptions/" + testSubscriptionID + "/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/default",
+		},
+		{
+			name:  "management group",
+			build: func() (string, error) { return ManagementGroup("platform-mg") },
+			want:  "/providers/Microsoft.Management/managementGroups/platform-mg",
+		},
+		{
+			name:    "invalid subscription",
+			build:   func() (string, error) { return Subscription("not-a-guid") },
+			wantErr: true,
+		},
+		{
+			name:    "invalid resource group",
+			build:   func() (string, error) { return ResourceGroup(testSubscriptionID, "bad/rg") },
+			wantErr: true,
+		},
+		{
+			name: "mismatched nested resource",
+			build: func() (string, error) {
+				return Resource(testSubscriptionID, "my-rg", "Microsoft.Network", "virtualNetworks/subnets", "vnet")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got scope %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		scope    string
+		wantKind Kind
+		wantErr  bool
+	}{
+		{"/subscriptions/" + testSubscriptionID, KindSubscription, false},
+		{"/SUBSCRIPTIONS/" + testSubscriptionID + "/resourcegroups/rg", KindResourceGroup, false},
+		{"/subscriptions/" + testSubscriptionID + "/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm", KindResource, false},
+		{"/providers/Microsoft.Management/managementGroups/mg", KindManagementGroup, false},
+		{"", "", true},
+		{"subscriptions/" + testSubscriptionID, "", true},
+		{"/subscriptions/" + testSubscriptionID + "/", "", true},
+		{"/subscriptions//resourceGroups/rg", "", true},
+		{"/subscriptions/" + testSubscriptionID + "/resourceGroup/rg", "", true},
+		{"/subscriptions/" + testSubscriptionID + "/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines", "", true},
+		{"/providers/Microsoft.Authorization/roleDefinitions/x", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			s, err := Parse(tt.scope)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%q) expected error", tt.scope)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) unexpected error: %v", tt.scope, err)
+			}
+			if s.Kind != tt.wantKind {
+				t.Errorf("Parse(%q) kind = %s, want %s", tt.scope, s.Kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{
+		"subscriptionId": testSubscriptionID,
+		"resourceGroup":  "my-rg",
+		"arcGroup":       "",
+	}
+
+	got, err := Expand("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroup}", vars)
+	if err != nil {
+		t.Fatalf("Expand() unexpected error: %v", err)
+	}
+	if want := "/subscriptions/" + testSubscriptionID + "/resourceGroups/my-rg"; got != want {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+
+	if _, err := Expand("/subscriptions/{subId}", vars); err == nil || !strings.Contains(err.Error(), "unknown placeholder {subId}") {
+		t.Errorf("Expand() with unknown placeholder error = %v", err)
+	}
+	if _, err := Expand("/subscriptions/{subscriptionId}/resourceGroups/{arcGroup}", vars); err == nil || !strings.Contains(err.Error(), "has no value") {
+		t.Errorf("Expand() with empty placeholder error = %v", err)
+	}
+	if _, err := Expand("/subscriptions/{subscriptionId}/resourceGroups", vars); err == nil {
+		t.Error("Expand() should validate the expanded scope")
+	}
+}
@@ -0,0 +1,129 @@
+// Package throttle paces Azure requests by the hints in Azure's responses. All clients share one Pacer
+// through the client pipeline, so when ARM throttles one component or reports its rate limit running
+// out, the others slow down too instead of each retrying on its own schedule.
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+const (
+	// maxRetryAfter caps the wait taken from a Retry-After header, so a bad value cannot stall the agent
+	maxRetryAfter = 5 * time.Minute
+
+	// lowRemaining is the number of requests left in a rate limit window below which requests are spaced out
+	lowRemaining = 100
+
+	// maxSpacing is the gap kept between requests when the rate limit window is exhausted
+	maxSpacing = 2 * time.Second
+)
+
+// Shared is the pacer of all Azure clients of the process
+var Shared = NewPacer(retry.RealClock)
+
+// Pacer holds requests back while Azure asked clients to wait, and spaces them out while the remaining
+// requests of a rate limit window run low
+type Pacer struct {
+	mu        sync.Mutex
+	clock     retry.Clock
+	notBefore time.Time     // No request before this time, from the last Retry-After of a throttled request
+	spacing   time.Duration // Gap kept between requests, from the last reported remaining requests
+	next      time.Time     // Earliest time of the next request while spacing is kept
+}
+
+// NewPacer creates a pacer on clock
+func NewPacer(clock retry.Clock) *Pacer {
+	return &Pacer{clock: clock}
+}
+
+// Delay returns how long Azure asked clients to hold off from now. Retry loops of components use it as
+// the shortest wait of their backoff.
+func (p *Pacer) Delay() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(p.notBefore.Sub(p.clock.Now()), 0)
+}
+
+// Wait blocks until the next request may be sent, or ctx is done
+func (p *Pacer) Wait(ctx context.Context) error {
+	wait := p.reserve()
+	if wait <= 0 {
+		return nil
+	}
+	return retry.Sleep(ctx, p.clock, wait)
+}
+
+// reserve returns the wait before the next request and takes its slot, so concurrent requests are spaced
+// from each other
+func (p *Pacer) reserve() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock.Now()
+	at := now
+	if p.notBefore.After(at) {
+		at = p.notBefore
+	}
+	if p.spacing > 0 {
+		if p.next.After(at) {
+			at = p.next
+		}
+		p.next = at.Add(p.spacing)
+	}
+	return at.Sub(now)
+}
+
+// Observe records the hints of a response
+func (p *Pacer) Observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock.Now()
+	hints := azerrors.Hints(resp, now)
+	// Long-running operations return Retry-After as their polling interval; only throttling asks to hold off
+	if hints.RetryAfter > 0 && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if until := now.Add(min(hints.RetryAfter, maxRetryAfter)); until.After(p.notBefore) {
+			p.notBefore = until
+		}
+	}
+	if hints.Remaining >= 0 {
+		p.spacing = spacing(hints.Remaining)
+	}
+}
+
+// spacing returns the gap between requests for the remaining requests of a rate limit window: none while
+// plenty are left, growing to maxSpacing as they run out
+func spacing(remaining int) time.Duration {
+	if remaining >= lowRemaining {
+		return 0
+	}
+	return maxSpacing * time.Duration(lowRemaining-remaining) / lowRemaining
+}
+
+// Policy returns a per-retry pipeline policy that paces every attempt, including the SDK's retries
+func (p *Pacer) Policy() policy.Policy {
+	return pacerPolicy{pacer: p}
+}
+
+type pacerPolicy struct {
+	pacer *Pacer
+}
+
+// Do waits for the pacer, sends the request and records the hints of its response
+func (pp pacerPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if err := pp.pacer.Wait(req.Raw().Context()); err != nil {
+		return nil, err
+	}
+	resp, err := req.Next()
+	pp.pacer.Observe(resp)
+	return resp, err
+}
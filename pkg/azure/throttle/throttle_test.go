@@ -0,0 +1,121 @@
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+func response(status int, header map[string]string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	for name, value := range header {
+		resp.Header.Set(name, value)
+	}
+	return resp
+}
+
+func TestPacerRetryAfter(t *testing.T) {
+	clock := retry.NewFakeClock(time.Unix(0, 0))
+	p := NewPacer(clock)
+
+	// Polling intervals of long-running operations do not hold other requests back
+	p.Observe(response(http.StatusAccepted, map[string]string{"Retry-After": "10"}))
+	if got := p.Delay(); got != 0 {
+		t.Errorf("Delay() after a polling response = %v, want 0", got)
+	}
+
+	p.Observe(response(http.StatusTooManyRequests, map[string]string{"Retry-After": "20"}))
+	p.Observe(response(http.StatusServiceUnavailable, map[string]string{"Retry-After": "5"})) // A shorter hint does not shorten the wait
+	if got := p.Delay(); got != 20*time.Second {
+		t.Errorf("Delay() = %v, want 20s", got)
+	}
+	if err := p.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() unexpected error: %v", err)
+	}
+	if got := clock.Sleeps(); !slices.Equal(got, []time.Duration{20 * time.Second}) {
+		t.Errorf("Wait() slept %v, want [20s]", got)
+	}
+	if got := p.Delay(); got != 0 {
+		t.Errorf("Delay() after waiting = %v, want 0", got)
+	}
+
+	p.Observe(response(http.StatusTooManyRequests, map[string]string{"Retry-After": "86400"}))
+	if got := p.Delay(); got != maxRetryAfter {
+		t.Errorf("Delay() with a day-long Retry-After = %v, want %v", got, maxRetryAfter)
+	}
+}
+
+func TestPacerSpacing(t *testing.T) {
+	clock := retry.NewFakeClock(time.Unix(0, 0))
+	p := NewPacer(clock)
+
+	p.Observe(response(http.StatusOK, map[string]string{
+		"x-ms-ratelimit-remaining-subscription-reads": "11999",
+		"x-ms-ratelimit-remaining-tenant-reads":       "50",
+	}))
+	for range 3 {
+		if err := p.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() unexpected error: %v", err)
+		}
+	}
+	// The first request goes out at once and the next ones are spaced by 1s, for half of the window left
+	if got := clock.Sleeps(); !slices.Equal(got, []time.Duration{time.Second, time.Second}) {
+		t.Errorf("Wait() slept %v, want [1s 1s]", got)
+	}
+
+	p.Observe(response(http.StatusOK, map[string]string{"x-ms-ratelimit-remaining-subscription-reads": "500"}))
+	if got := p.reserve(); got != 0 {
+		t.Errorf("reserve() = %v, want 0 once plenty of requests are left", got)
+	}
+}
+
+type fakeTransport struct {
+	responses []*http.Response
+	sent      int
+}
+
+func (f *fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	resp := f.responses[f.sent]
+	resp.Request = req
+	f.sent++
+	return resp, nil
+}
+
+func TestPolicy(t *testing.T) {
+	clock := retry.NewFakeClock(time.Unix(0, 0))
+	p := NewPacer(clock)
+	transport := &fakeTransport{responses: []*http.Response{
+		response(http.StatusTooManyRequests, map[string]string{"Retry-After": "30"}),
+		response(http.StatusOK, nil),
+	}}
+	pipeline := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        transport,
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerRetryPolicies: []policy.Policy{p.Policy()},
+	})
+
+	for _, want := range []int{http.StatusTooManyRequests, http.StatusOK} {
+		req, err := runtime.NewRequest(context.Background(), http.MethodGet, "https://management.azure.com/subscriptions")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := pipeline.Do(req)
+		if err != nil {
+			t.Fatalf("Do() unexpected error: %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("Do() status = %d, want %d", resp.StatusCode, want)
+		}
+	}
+	// The second request, as any other client's would, waited for the first one's Retry-After
+	if got := clock.Sleeps(); !slices.Equal(got, []time.Duration{30 * time.Second}) {
+		t.Errorf("pipeline slept %v, want [30s]", got)
+	}
+}
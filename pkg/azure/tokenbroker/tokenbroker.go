@@ -0,0 +1,107 @@
+// Package tokenbroker requests Kubernetes bootstrap tokens from a broker, e.g. an Azure Function, that issues
+// them to machines presenting a managed identity token it trusts, so tokens need not be copied onto each node
+package tokenbroker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// tokenPattern matches bootstrap tokens, <token-id>.<token-secret>
+var tokenPattern = regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`)
+
+// Request identifies the node and the cluster it joins
+type Request struct {
+	ClusterResourceID string `json:"clusterResourceId"`
+	NodeName          string `json:"nodeName"`
+}
+
+// Token is the broker's response. The broker may also return the cluster's API server URL and CA certificate,
+// so they need not be configured either.
+type Token struct {
+	Token      string    `json:"token"`
+	ExpiresOn  time.Time `json:"expiresOn"`
+	ServerURL  string    `json:"serverURL,omitempty"`
+	CACertData string    `json:"caCertData,omitempty"`
+}
+
+// Client requests bootstrap tokens from one broker
+type Client struct {
+	pipeline runtime.Pipeline
+	url      string
+}
+
+// NewClient creates a client for the broker at brokerURL. Requests carry a token for audience, the application
+// ID URI or client ID of the broker's app registration. options may be nil.
+func NewClient(brokerURL, audience string, cred azcore.TokenCredential, options *policy.ClientOptions) (*Client, error) {
+	if err := ValidateURL(brokerURL); err != nil {
+		return nil, err
+	}
+	client, err := azcore.NewClient("tokenbroker.Client", "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{Scope(audience)}, nil)},
+	}, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bootstrap token broker client: %w", err)
+	}
+	return &Client{pipeline: client.Pipeline(), url: brokerURL}, nil
+}
+
+// ValidateURL checks that brokerURL is an https URL
+func ValidateURL(brokerURL string) error {
+	parsed, err := url.Parse(brokerURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("invalid bootstrap token broker URL %q: expected an https URL", brokerURL)
+	}
+	return nil
+}
+
+// Scope returns the token scope of audience
+func Scope(audience string) string {
+	if strings.HasSuffix(audience, "/.default") {
+		return audience
+	}
+	return strings.TrimSuffix(audience, "/") + "/.default"
+}
+
+// RequestToken asks the broker for a bootstrap token for the node
+func (c *Client) RequestToken(ctx context.Context, request Request) (*Token, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodPost, c.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build bootstrap token request: %w", err)
+	}
+	if err := runtime.MarshalAsJSON(req, request); err != nil {
+		return nil, fmt.Errorf("failed to build bootstrap token request: %w", err)
+	}
+
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap token request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	var token Token
+	if err := runtime.UnmarshalAsJSON(resp, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode the bootstrap token response: %w", err)
+	}
+	if !tokenPattern.MatchString(token.Token) {
+		return nil, fmt.Errorf("the broker returned a malformed bootstrap token, expected <token-id>.<token-secret>")
+	}
+	if !token.ExpiresOn.IsZero() && !token.ExpiresOn.After(time.Now()) {
+		return nil, fmt.Errorf("the broker returned a bootstrap token that expired at %s", token.ExpiresOn.Format(time.RFC3339))
+	}
+	return &token, nil
+}
@@ -0,0 +1,102 @@
+package tokenbroker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+type fakeCredential struct {
+	scopes []string
+}
+
+func (f *fakeCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	f.scopes = options.Scopes
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestRequestToken(t *testing.T) {
+	expiresOn := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantErr  string
+	}{
+		{
+			name:     "token",
+			status:   http.StatusOK,
+			response: fmt.Sprintf(`{"token": "abcdef.0123456789abcdef", "expiresOn": %q, "serverURL": "https://cluster:443"}`, expiresOn.Format(time.RFC3339)),
+		},
+		{name: "forbidden", status: http.StatusForbidden, response: `{"error": "not trusted"}`, wantErr: "403"},
+		{name: "malformed token", status: http.StatusOK, response: `{"token": "secret"}`, wantErr: "malformed bootstrap token"},
+		{name: "expired token", status: http.StatusOK, response: `{"token": "abcdef.0123456789abcdef", "expiresOn": "2020-01-01T00:00:00Z"}`, wantErr: "expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Request
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			cred := &fakeCredential{}
+			client, err := NewClient(server.URL+"/api/token", "api://flex-broker", cred, &policy.ClientOptions{
+				Transport: server.Client(),
+				Retry:     policy.RetryOptions{MaxRetries: -1},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := client.RequestToken(context.Background(), Request{ClusterResourceID: "/subscriptions/sub/cluster", NodeName: "edge-01"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("RequestToken() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RequestToken() unexpected error: %v", err)
+			}
+			if token.Token != "abcdef.0123456789abcdef" || !token.ExpiresOn.Equal(expiresOn) || token.ServerURL != "https://cluster:443" {
+				t.Errorf("RequestToken() = %+v, want the broker's token", token)
+			}
+			if got.NodeName != "edge-01" || got.ClusterResourceID != "/subscriptions/sub/cluster" {
+				t.Errorf("broker received %+v, want the node and cluster", got)
+			}
+			if len(cred.scopes) != 1 || cred.scopes[0] != "api://flex-broker/.default" {
+				t.Errorf("token scopes = %v, want the broker audience", cred.scopes)
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, brokerURL := range []string{"", "http://broker.example.com/api/token", "broker.example.com"} {
+		if err := ValidateURL(brokerURL); err == nil {
+			t.Errorf("ValidateURL(%q) expected error", brokerURL)
+		}
+	}
+	if err := ValidateURL("https://flex-broker.azurewebsites.net/api/token"); err != nil {
+		t.Errorf("ValidateURL() unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package writelimit limits how many ARM writes the nodes of a fleet send to one subscription at the same
+// time, so onboarding many nodes at once does not trip the subscription's write throttling. The nodes share
+// a set of write slots per subscription, blobs in an Azure Storage container, and a node holds the lease of a
+// slot's blob while it sends a write request.
+package writelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/blob"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+const (
+	// leaseDuration is the longest finite blob lease; the slot of a node that stops without releasing it
+	// frees up after this time
+	leaseDuration = 60 * time.Second
+
+	// waitInitial and waitMax bound the wait before trying the slots again while all are taken
+	waitInitial = 2 * time.Second
+	waitMax     = 30 * time.Second
+
+	// slotPrefix is the blob name prefix of the write slots
+	slotPrefix = "arm-writes/"
+)
+
+// Leaser takes and gives up leases of blobs; *blob.Client implements it
+type Leaser interface {
+	AcquireLease(ctx context.Context, name string, duration time.Duration) (string, error)
+	ReleaseLease(ctx context.Context, name, leaseID string) error
+}
+
+// Lazy returns a Leaser that creates the Leaser with newLeaser on first use, for clients that are set up
+// where errors cannot be returned
+func Lazy(newLeaser func() (Leaser, error)) Leaser {
+	return &lazyLeaser{get: sync.OnceValues(newLeaser)}
+}
+
+type lazyLeaser struct {
+	get func() (Leaser, error)
+}
+
+func (l *lazyLeaser) AcquireLease(ctx context.Context, name string, duration time.Duration) (string, error) {
+	leaser, err := l.get()
+	if err != nil {
+		return "", err
+	}
+	return leaser.AcquireLease(ctx, name, duration)
+}
+
+func (l *lazyLeaser) ReleaseLease(ctx context.Context, name, leaseID string) error {
+	leaser, err := l.get()
+	if err != nil {
+		return err
+	}
+	return leaser.ReleaseLease(ctx, name, leaseID)
+}
+
+// Limiter hands out the write slots of subscriptions
+type Limiter struct {
+	leaser Leaser
+	slots  int
+	clock  retry.Clock
+}
+
+// NewLimiter creates a limiter allowing slots concurrent writes per subscription across all nodes using the
+// same container
+func NewLimiter(leaser Leaser, slots int, clock retry.Clock) *Limiter {
+	return &Limiter{leaser: leaser, slots: slots, clock: clock}
+}
+
+// Acquire blocks until it holds a write slot of subscriptionID, or ctx is done. The returned function gives
+// the slot up again.
+func (l *Limiter) Acquire(ctx context.Context, subscriptionID string) (func(), error) {
+	backoff := retry.Backoff{Initial: waitInitial, Max: waitMax}
+	for attempt := 0; ; attempt++ {
+		// Nodes that found all slots taken at the same time spread out their next try
+		if delay := backoff.Delay(attempt); delay > 0 {
+			if err := retry.Sleep(ctx, l.clock, delay+rand.N(delay/2)); err != nil {
+				return nil, err
+			}
+		}
+		start := rand.IntN(l.slots)
+		for i := range l.slots {
+			name := slotName(subscriptionID, (start+i)%l.slots)
+			leaseID, err := l.leaser.AcquireLease(ctx, name, leaseDuration)
+			if errors.Is(err, blob.ErrLeased) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire ARM write slot of subscription %s: %w", subscriptionID, err)
+			}
+			return func() {
+				_ = l.leaser.ReleaseLease(context.WithoutCancel(ctx), name, leaseID)
+			}, nil
+		}
+	}
+}
+
+// Policy returns a per-retry pipeline policy holding a write slot of the request's subscription while a write
+// request is sent. It belongs after the throttle's policy, so slots are not held while Azure asked to wait.
+// Reads and requests outside a subscription are not limited.
+func (l *Limiter) Policy() policy.Policy {
+	return limiterPolicy{limiter: l}
+}
+
+type limiterPolicy struct {
+	limiter *Limiter
+}
+
+// Do acquires a slot for write requests, sends the request and gives the slot up
+func (lp limiterPolicy) Do(req *policy.Request) (*http.Response, error) {
+	raw := req.Raw()
+	subscriptionID := subscriptionOf(raw.URL.Path)
+	if !isWrite(raw.Method) || subscriptionID == "" {
+		return req.Next()
+	}
+	release, err := lp.limiter.Acquire(raw.Context(), subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return req.Next()
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// subscriptionOf returns the lower-case subscription ID of an ARM request path, or "" outside a subscription
+func subscriptionOf(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 || !strings.EqualFold(segments[0], "subscriptions") {
+		return ""
+	}
+	return strings.ToLower(segments[1])
+}
+
+func slotName(subscriptionID string, slot int) string {
+	return fmt.Sprintf("%s%s/%d", slotPrefix, subscriptionID, slot)
+}
@@ -0,0 +1,154 @@
+package writelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/blob"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+// fakeLeaser holds leases in memory and records the blobs it was asked for. After maxAttempts attempts, when
+// set, it calls cancel to end a writer that keeps waiting.
+type fakeLeaser struct {
+	mu          sync.Mutex
+	leased      map[string]string
+	names       []string
+	maxAttempts int
+	cancel      func()
+}
+
+func (f *fakeLeaser) AcquireLease(ctx context.Context, name string, duration time.Duration) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.names = append(f.names, name)
+	if f.maxAttempts > 0 && len(f.names) >= f.maxAttempts {
+		f.cancel()
+	}
+	if _, ok := f.leased[name]; ok {
+		return "", fmt.Errorf("%w: %s", blob.ErrLeased, name)
+	}
+	f.leased[name] = fmt.Sprintf("lease-%d", len(f.names))
+	return f.leased[name], nil
+}
+
+func (f *fakeLeaser) ReleaseLease(ctx context.Context, name, leaseID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.leased[name] == leaseID {
+		delete(f.leased, name)
+	}
+	return nil
+}
+
+func (f *fakeLeaser) held() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.leased)
+}
+
+func TestAcquire(t *testing.T) {
+	const subscriptionID = "12345678-1234-1234-1234-123456789012"
+	leaser := &fakeLeaser{leased: map[string]string{}}
+	clock := retry.NewFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(leaser, 2, clock)
+
+	var releases []func()
+	for range 2 {
+		release, err := limiter.Acquire(context.Background(), subscriptionID)
+		if err != nil {
+			t.Fatalf("Acquire() unexpected error: %v", err)
+		}
+		releases = append(releases, release)
+	}
+	if got := len(clock.Sleeps()); got != 0 {
+		t.Errorf("Acquire() with free slots waited %d times", got)
+	}
+
+	// Both slots are taken; a third writer waits until the context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	leaser.maxAttempts, leaser.cancel = len(leaser.names)+6, cancel
+	if _, err := limiter.Acquire(ctx, subscriptionID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() with all slots taken error = %v, want context.Canceled", err)
+	}
+	if got := len(clock.Sleeps()); got != 2 {
+		t.Errorf("Acquire() with all slots taken waited %d times, want 2", got)
+	}
+	leaser.maxAttempts = 0
+
+	releases[0]()
+	if _, err := limiter.Acquire(context.Background(), subscriptionID); err != nil {
+		t.Errorf("Acquire() after a release unexpected error: %v", err)
+	}
+
+	// Other subscriptions have their own slots
+	if _, err := limiter.Acquire(context.Background(), "other"); err != nil {
+		t.Errorf("Acquire() for another subscription unexpected error: %v", err)
+	}
+}
+
+func TestAcquireError(t *testing.T) {
+	limiter := NewLimiter(Lazy(func() (Leaser, error) { return nil, errors.New("no credential") }), 2, retry.NewFakeClock(time.Unix(0, 0)))
+	if _, err := limiter.Acquire(context.Background(), "sub"); err == nil {
+		t.Error("Acquire() expected the error of the leaser")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	leaser := &fakeLeaser{leased: map[string]string{}}
+	limiter := NewLimiter(leaser, 1, retry.NewFakeClock(time.Unix(0, 0)))
+
+	var heldDuringSend int
+	transport := transportFunc(func(req *http.Request) (*http.Response, error) {
+		heldDuringSend = leaser.held()
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+	pipeline := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        transport,
+		PerRetryPolicies: []policy.Policy{limiter.Policy()},
+	})
+
+	tests := []struct {
+		method   string
+		url      string
+		wantHeld int
+	}{
+		{method: http.MethodPut, url: "https://management.azure.com/subscriptions/SUB/resourceGroups/rg?api-version=1", wantHeld: 1},
+		{method: http.MethodPost, url: "https://management.azure.com/subscriptions/sub/providers/x/register", wantHeld: 1},
+		{method: http.MethodGet, url: "https://management.azure.com/subscriptions/sub/resourceGroups/rg", wantHeld: 0},
+		{method: http.MethodPut, url: "https://management.azure.com/providers/Microsoft.Management/managementGroups/mg", wantHeld: 0},
+	}
+	for _, tt := range tests {
+		req, err := runtime.NewRequest(context.Background(), tt.method, tt.url)
+		if err != nil {
+			t.Fatalf("NewRequest() unexpected error: %v", err)
+		}
+		if _, err := pipeline.Do(req); err != nil {
+			t.Fatalf("%s %s unexpected error: %v", tt.method, tt.url, err)
+		}
+		if heldDuringSend != tt.wantHeld {
+			t.Errorf("%s %s held %d slots while sent, want %d", tt.method, tt.url, heldDuringSend, tt.wantHeld)
+		}
+		if held := leaser.held(); held != 0 {
+			t.Errorf("%s %s left %d slots held", tt.method, tt.url, held)
+		}
+	}
+	if want := []string{"arm-writes/sub/0", "arm-writes/sub/0"}; !slices.Equal(leaser.names, want) {
+		t.Errorf("leased slots = %v, want %v", leaser.names, want)
+	}
+}
+
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
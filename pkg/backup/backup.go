@@ -0,0 +1,297 @@
+// Package backup writes and restores encrypted snapshots of a node's identity and configuration: the agent
+// configuration and state, the kubelet's certificates and kubeconfigs, the rendered runtime and kubelet
+// configuration, and the Arc agent state. Restoring a snapshot on a replacement machine with the same hostname
+// lets it rejoin the cluster and Azure as the same node after a hardware failure.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// manifestName is the archive entry describing the snapshot
+const manifestName = "manifest.json"
+
+// maxArchiveSize bounds the snapshot, which is built and decrypted in memory
+const maxArchiveSize = 256 << 20
+
+// DefaultPaths are the files and directories a snapshot holds. Missing paths are skipped.
+var DefaultPaths = []string{
+	// Agent configuration, credentials referenced by it, and state such as the drift manifest
+	"/etc/aks-flex-node",
+	"/var/lib/aks-flex-node",
+
+	// Kubelet identity and configuration
+	"/var/lib/kubelet/kubeconfig",
+	"/var/lib/kubelet/pki",
+	"/var/lib/kubelet/config.yaml",
+	"/var/lib/kubelet/token.sh",
+	"/etc/kubernetes",
+	"/etc/default/kubelet",
+	"/etc/systemd/system/kubelet.service",
+	"/etc/systemd/system/kubelet.service.d",
+
+	// Container runtime configuration
+	"/etc/containerd",
+	"/etc/crio",
+
+	// Arc agent identity and configuration
+	"/var/opt/azcmagent",
+	"/etc/opt/azcmagent",
+}
+
+// excludedDirs are left out of a snapshot: logs can be large and are not needed to restore
+var excludedDirs = []string{
+	"/var/opt/azcmagent/log",
+}
+
+// Manifest describes a snapshot
+type Manifest struct {
+	Hostname     string    `json:"hostname"`
+	AgentVersion string    `json:"agentVersion"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Files        []string  `json:"files"` // Absolute paths of the files, directories and symlinks in the snapshot
+}
+
+// entry is a file, directory or symlink of a snapshot
+type entry struct {
+	header *tar.Header
+	data   []byte
+}
+
+// Archive is a decrypted snapshot
+type Archive struct {
+	Manifest Manifest
+	entries  []entry
+}
+
+// Create writes an encrypted snapshot of paths, read below root, to w. root is "/" except in tests.
+func Create(w io.Writer, root string, paths []string, manifest Manifest, passphrase []byte) (*Manifest, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	manifest.Files = nil
+	var size int64
+	for _, p := range paths {
+		err := filepath.WalkDir(filepath.Join(root, p), func(file string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			name := "/" + strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(file, root)), "/")
+			if d.IsDir() && slices.Contains(excludedDirs, name) {
+				return filepath.SkipDir
+			}
+			written, err := addFile(tw, file, name)
+			if err != nil {
+				return err
+			}
+			if size += written; size > maxArchiveSize {
+				return fmt.Errorf("snapshot exceeds %d MiB", maxArchiveSize>>20)
+			}
+			manifest.Files = append(manifest.Files, name)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", p, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	header := &tar.Header{Name: manifestName, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.CreatedAt, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(tw.Close(), gz.Close()); err != nil {
+		return nil, err
+	}
+
+	sealed, err := seal(buf.Bytes(), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(sealed); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// addFile adds file to the archive under name with its mode, owner and, for a symlink, its target
+func addFile(tw *tar.Writer, file, name string) (int64, error) {
+	info, err := os.Lstat(file)
+	if err != nil {
+		return 0, err
+	}
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(file); err != nil {
+			return 0, err
+		}
+	} else if !info.Mode().IsRegular() && !info.IsDir() {
+		// Sockets and other special files cannot be restored
+		return 0, nil
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return 0, err
+	}
+	// Keep the absolute path without the leading slash, like tar does
+	header.Name = strings.TrimPrefix(name, "/")
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	header.Uname, header.Gname = "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tw.Write(data)
+	return int64(len(data)), err
+}
+
+// Open decrypts a snapshot and checks every entry before anything is written
+func Open(r io.Reader, passphrase []byte) (*Archive, error) {
+	sealed, err := io.ReadAll(io.LimitReader(r, int64(maxArchiveSize+headerSize+1)))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) > maxArchiveSize+headerSize {
+		return nil, fmt.Errorf("snapshot exceeds %d MiB", maxArchiveSize>>20)
+	}
+	data, err := unseal(sealed, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	archive := &Archive{}
+	foundManifest := false
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot: %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot: %w", err)
+		}
+		if header.Name == manifestName {
+			if err := json.Unmarshal(content, &archive.Manifest); err != nil {
+				return nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+			}
+			foundManifest = true
+			continue
+		}
+		clean := path.Clean("/" + header.Name)
+		if clean == "/" || clean != "/"+strings.TrimSuffix(header.Name, "/") {
+			return nil, fmt.Errorf("invalid path %q in snapshot", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+		default:
+			return nil, fmt.Errorf("unsupported entry %q in snapshot", header.Name)
+		}
+		header.Name = clean
+		archive.entries = append(archive.entries, entry{header: header, data: content})
+	}
+	if !foundManifest {
+		return nil, errors.New("invalid snapshot: no manifest")
+	}
+	return archive, nil
+}
+
+// Extract writes the snapshot below root, replacing existing files. Directories come first and symlinks last,
+// so no file is written through a symlink from the snapshot. root is "/" except in tests.
+func (a *Archive) Extract(root string) error {
+	order := map[byte]int{tar.TypeDir: 0, tar.TypeReg: 1, tar.TypeSymlink: 2}
+	entries := slices.Clone(a.entries)
+	slices.SortStableFunc(entries, func(x, y entry) int {
+		return order[x.header.Typeflag] - order[y.header.Typeflag]
+	})
+
+	for _, e := range entries {
+		target := filepath.Join(root, filepath.FromSlash(e.header.Name))
+		mode := fs.FileMode(e.header.Mode).Perm()
+		var err error
+		switch e.header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, mode); err == nil {
+				err = os.Chmod(target, mode)
+			}
+		case tar.TypeReg:
+			err = writeFile(target, e.data, mode)
+		case tar.TypeSymlink:
+			if err = os.MkdirAll(filepath.Dir(target), 0o755); err == nil {
+				if err = os.Remove(target); errors.Is(err, fs.ErrNotExist) {
+					err = nil
+				}
+			}
+			if err == nil {
+				err = os.Symlink(e.header.Linkname, target)
+			}
+		}
+		if err == nil && os.Geteuid() == 0 {
+			err = os.Lchown(target, e.header.Uid, e.header.Gid)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", e.header.Name, err)
+		}
+	}
+	return nil
+}
+
+// writeFile replaces path atomically, so an interrupted restore never leaves a truncated certificate behind
+func writeFile(path string, data []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	_, err = tmp.Write(data)
+	if err = errors.Join(err, tmp.Chmod(mode), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testPassphrase = []byte("correct horse battery staple")
+
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "etc/aks-flex-node/config.json"), `{"agent":{}}`, 0o640)
+	writeTestFile(t, filepath.Join(src, "var/lib/kubelet/pki/kubelet-client-2026-10-17.pem"), "CERT", 0o600)
+	if err := os.Symlink("kubelet-client-2026-10-17.pem", filepath.Join(src, "var/lib/kubelet/pki/kubelet-client-current.pem")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(src, "var/opt/azcmagent/agentconfig.json"), `{"resourceName":"edge-01"}`, 0o644)
+	writeTestFile(t, filepath.Join(src, "var/opt/azcmagent/log/himds.log"), "log", 0o644)
+
+	var sealed bytes.Buffer
+	paths := []string{"/etc/aks-flex-node", "/var/lib/kubelet/pki", "/var/opt/azcmagent", "/etc/crio"}
+	created := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	manifest, err := Create(&sealed, src, paths, Manifest{Hostname: "edge-01", CreatedAt: created}, testPassphrase)
+	if err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if slices.Contains(manifest.Files, "/var/opt/azcmagent/log/himds.log") {
+		t.Error("Create() included the Arc agent logs")
+	}
+	if bytes.Contains(sealed.Bytes(), []byte("CERT")) {
+		t.Error("Create() wrote the certificate in the clear")
+	}
+
+	archive, err := Open(bytes.NewReader(sealed.Bytes()), testPassphrase)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	if archive.Manifest.Hostname != "edge-01" || !archive.Manifest.CreatedAt.Equal(created) {
+		t.Errorf("Open() manifest = %+v, want the hostname and creation time", archive.Manifest)
+	}
+
+	dst := t.TempDir()
+	if err := archive.Extract(dst); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "var/lib/kubelet/pki/kubelet-client-current.pem"))
+	if err != nil || string(data) != "CERT" {
+		t.Errorf("restored certificate = %q, %v, want it reachable through the symlink", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "etc/aks-flex-node/config.json"))
+	if err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("restored config mode = %v, %v, want 0640", info, err)
+	}
+}
+
+func TestOpenWrongPassphrase(t *testing.T) {
+	var sealed bytes.Buffer
+	if _, err := Create(&sealed, t.TempDir(), nil, Manifest{}, testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(&sealed, []byte("not the passphrase at all")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Open() error = %v, want ErrDecrypt", err)
+	}
+	if _, err := Create(&sealed, t.TempDir(), nil, Manifest{}, []byte("short")); err == nil {
+		t.Error("Create() accepted a short passphrase")
+	}
+}
+
+func TestOpenRejectsPathTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{manifestName, "etc/../../root/.ssh/authorized_keys"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: 2, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := errors.Join(tw.Close(), gz.Close()); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := seal(buf.Bytes(), testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(bytes.NewReader(sealed), testPassphrase); err == nil {
+		t.Error("Open() accepted a path outside the root")
+	}
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// A sealed snapshot is the magic, the scrypt salt, the GCM nonce and the AES-256-GCM ciphertext
+const (
+	magic     = "AKSFNBK1"
+	saltSize  = 16
+	nonceSize = 12
+
+	headerSize = len(magic) + saltSize + nonceSize
+)
+
+// MinPassphraseLength is the shortest passphrase accepted for a snapshot
+const MinPassphraseLength = 12
+
+// ErrDecrypt is returned when a snapshot cannot be decrypted, usually because of a wrong passphrase
+var ErrDecrypt = errors.New("failed to decrypt snapshot: wrong passphrase or corrupted file")
+
+// deriveKey stretches the passphrase into an AES-256 key; scrypt makes guessing passphrases expensive
+func deriveKey(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(plaintext, passphrase []byte) ([]byte, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, err
+	}
+	salt, nonce := header[len(magic):len(magic)+saltSize], header[len(magic)+saltSize:]
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	// The header is authenticated too, so the salt and nonce cannot be swapped
+	return append(header, aead.Seal(nil, nonce, plaintext, header)...), nil
+}
+
+func unseal(sealed, passphrase []byte) ([]byte, error) {
+	if len(sealed) < headerSize || string(sealed[:len(magic)]) != magic {
+		return nil, errors.New("not an aks-flex-node snapshot")
+	}
+	header := sealed[:headerSize]
+	salt, nonce := header[len(magic):len(magic)+saltSize], header[len(magic)+saltSize:]
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, sealed[headerSize:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
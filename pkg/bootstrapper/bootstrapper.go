@@ -2,65 +2,250 @@ package bootstrapper
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 
 	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/azure_prerequisites"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ca_trust"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
-	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/custom_scripts"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/dns"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/fluent_bit"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/image_prepull"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_binaries"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/node_topology"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/npd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/preflight"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/runc"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/services"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/sriov"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ssh_hardening"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/static_pods"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/system_configuration"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/workload_isolation"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/discovery"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
+	"go.goms.io/aks/AKSFlexNode/pkg/state"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
 // Bootstrapper executes bootstrap steps sequentially
 type Bootstrapper struct {
 	*BaseExecutor
+	reconfigure bool
 }
 
-// New creates a new bootstrapper
+// New creates a new bootstrapper. It works on a snapshot of cfg, so later changes to cfg
+// by the caller do not affect steps that are already running.
 func New(cfg *config.Config, logger *logrus.Logger) *Bootstrapper {
 	return &Bootstrapper{
-		BaseExecutor: NewBaseExecutor(cfg, logger),
+		BaseExecutor: NewBaseExecutor(cfg.Snapshot(), logger),
 	}
 }
 
-// Bootstrap executes all bootstrap steps sequentially
-func (b *Bootstrapper) Bootstrap(ctx context.Context) (*ExecutionResult, error) {
-	// Define the bootstrap steps in order - using modules directly
+// Reconfigure makes Bootstrap run the steps that install files even when they report themselves
+// completed, so their configuration files are rendered again after the configuration changed
+func (b *Bootstrapper) Reconfigure() {
+	b.reconfigure = true
+}
+
+// BootstrapStepNames returns the names of the bootstrap steps in execution order
+func (b *Bootstrapper) BootstrapStepNames() []string {
+	steps := b.bootstrapSteps()
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.GetName()
+	}
+	return names
+}
+
+// bootstrapSteps defines the bootstrap steps in order - using modules directly, with the components from
+// components.external inserted where they are registered
+func (b *Bootstrapper) bootstrapSteps() []Executor {
+	cfg := b.config
 	steps := []Executor{
-		arc.NewInstaller(b.logger),                  // Setup Arc
-		services.NewUnInstaller(b.logger),           // Stop kubelet before setup
-		system_configuration.NewInstaller(b.logger), // Configure system (early)
-		runc.NewInstaller(b.logger),                 // Install runc
-		containerd.NewInstaller(b.logger),           // Install containerd
-		kube_binaries.NewInstaller(b.logger),        // Install k8s binaries
-		cni.NewInstaller(b.logger),                  // Setup CNI (after container runtime)
-		kubelet.NewInstaller(b.logger),              // Configure kubelet service with Arc MSI auth
-		npd.NewInstaller(b.logger),                  // Install Node Problem Detector
-		services.NewInstaller(b.logger),             // Start services
-	}
-
-	return b.ExecuteSteps(ctx, steps, "bootstrap")
+		preflight.NewInstaller(cfg, b.logger),            // Verify preconditions before changing anything
+		ca_trust.NewInstaller(cfg, b.logger),             // Install enterprise CAs, which preflight already trusted in-process
+		dns.NewInstaller(cfg, b.logger),                  // Apply custom DNS upstreams, which preflight already resolved through
+		azure_prerequisites.NewInstaller(cfg, b.logger),  // Create missing Azure resources of a new site when azure.prerequisites.deploy is set
+		arc.NewInstaller(cfg, b.logger),                  // Setup Arc
+		services.NewUnInstaller(cfg, b.logger),           // Stop kubelet before setup
+		system_configuration.NewInstaller(cfg, b.logger), // Configure system (early)
+		sriov.NewInstaller(cfg, b.logger),                // Create SR-IOV VFs when sriov is enabled (after hugepages)
+		workload_isolation.NewInstaller(cfg, b.logger),   // Confine Kubernetes to its share of a shared host
+		runc.NewInstaller(cfg, b.logger),                 // Install runc
+		container_runtime.NewInstaller(cfg, b.logger),    // Install containerd or CRI-O
+		container_runtime.NewVerifier(cfg, b.logger),     // Pull and run a test container through CRI
+		image_prepull.NewInstaller(cfg, b.logger),        // Pre-pull critical system images
+		kube_binaries.NewInstaller(cfg, b.logger),        // Install k8s binaries
+		cni.NewInstaller(cfg, b.logger),                  // Setup CNI (after container runtime)
+		node_topology.NewInstaller(cfg, b.logger),        // Collect the hardware topology for the node labels
+		kubelet.NewInstaller(cfg, b.logger),              // Configure kubelet service with Arc MSI auth
+		static_pods.NewInstaller(cfg, b.logger),          // Write static pod manifests before kubelet starts
+		npd.NewInstaller(cfg, b.logger),                  // Install Node Problem Detector
+		custom_scripts.NewInstaller(cfg, b.logger),       // Run custom scripts before kubelet starts
+		services.NewInstaller(cfg, b.logger),             // Start services
+		fluent_bit.NewInstaller(cfg, b.logger),           // Ship node logs when fluentBit is enabled
+		ssh_hardening.NewInstaller(cfg, b.logger),        // Harden SSH and set up break-glass access when ssh is enabled
+		npd.NewVerifier(cfg, b.logger),                   // Verify NPD reports node conditions (warnings only)
+		node_topology.NewPublisher(cfg, b.logger),        // Keep the topology labels and annotation current (warnings only)
+		services.NewObserver(cfg, b.logger),              // Watch the services and the node for agent.observe.window
+	}
+	return withComponentInstallers(withoutDisabledComponents(cfg, steps, b.logger), externalComponents(cfg, b.logger))
+}
+
+// Bootstrap executes all bootstrap steps sequentially. With a state backend configured, the state saved there
+// is restored first when the node has none, and the state is saved back afterwards.
+func (b *Bootstrapper) Bootstrap(ctx context.Context) (*ExecutionResult, error) {
+	// Restoring state and discovering the cluster call Azure before preflight, possibly through a TLS-inspecting proxy
+	if err := ca_trust.TrustInProcess(ctx, b.config, b.logger); err != nil {
+		return nil, err
+	}
+	// A re-imaged node must pick up its saved state, such as a pending reboot, before anything writes new state
+	if _, err := state.Restore(ctx, b.config, b.logger); err != nil {
+		return nil, fmt.Errorf("failed to restore the agent's state from the %s state backend: %w", b.config.Agent.State.Backend, err)
+	}
+	result, err := b.bootstrap(ctx)
+	b.report(result)
+	b.saveState(ctx)
+	return result, err
+}
+
+func (b *Bootstrapper) bootstrap(ctx context.Context) (*ExecutionResult, error) {
+	// Steps read the cluster's location and node resource group, which may only be known from ARM
+	if err := discovery.DiscoverCluster(ctx, b.config, b.logger); err != nil {
+		return nil, err
+	}
+	if overrides := templates.Overrides(b.config.GetTemplatesDirectory()); len(overrides) > 0 {
+		b.logger.Infof("Rendering host files with template overrides: %s", strings.Join(overrides, ", "))
+	}
+	steps := b.bootstrapSteps()
+
+	pending, err := loadRebootState()
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		if !pending.rebooted() {
+			// Nothing to do until the machine restarts; re-running would request the same reboot again
+			b.logger.Infof("Waiting for the reboot requested by step %s before continuing bootstrap", pending.Step)
+			return &ExecutionResult{RebootRequired: true, RebootStep: pending.Step, RebootScheduledAt: pending.ScheduledAt}, nil
+		}
+		b.logger.Infof("Resuming bootstrap after the reboot requested by step %s", pending.Step)
+	}
+
+	run := steps
+	if b.reconfigure {
+		run = forceFileOwners(steps)
+	}
+	// Only a successful bootstrap records the manifest
+	bootstrappedBefore := utils.FileExists(drift.ManifestPath)
+	result, err := b.ExecuteSteps(ctx, run, "bootstrap")
+	if err != nil {
+		if !bootstrappedBefore {
+			err = b.rollBackAfterObservation(ctx, result, err)
+		}
+		return result, err
+	}
+
+	if result.RebootRequired {
+		// Guard against a reboot loop when the boot configuration did not take effect
+		if pending != nil && pending.Step == result.RebootStep {
+			result.Error = fmt.Sprintf("step %s still requires a reboot after the machine was rebooted", result.RebootStep)
+			return result, fmt.Errorf("bootstrap failed at step %s: %s; check the boot configuration", result.RebootStep, result.Error)
+		}
+		if err := b.requestReboot(ctx, result); err != nil {
+			return result, fmt.Errorf("failed to request reboot for step %s: %w", result.RebootStep, err)
+		}
+		return result, nil
+	}
+
+	if pending != nil && result.Success {
+		clearRebootState(b.logger)
+	}
+	if result.Success {
+		b.recordManifest(steps)
+	}
+	return result, nil
+}
+
+// rollBackAfterObservation unbootstraps a node that failed the observation window of its first bootstrap when
+// agent.observe.onFailure is rollback, so it does not stay in the cluster with crash-looping services. A node
+// bootstrapped before, e.g. changed by apply, is left in place for investigation.
+func (b *Bootstrapper) rollBackAfterObservation(ctx context.Context, result *ExecutionResult, err error) error {
+	failed := result.StepResults[len(result.StepResults)-1]
+	if failed.StepName != services.ObserverStepName || b.config.GetObserveOnFailure() != "rollback" {
+		return err
+	}
+	b.logger.Warnf("Rolling back bootstrap with unbootstrap: %s", failed.Error)
+	if _, rollbackErr := b.Unbootstrap(ctx); rollbackErr != nil {
+		return fmt.Errorf("%w; rolling back failed: %v", err, rollbackErr)
+	}
+	return fmt.Errorf("%w; the node was rolled back", err)
 }
 
 // Unbootstrap executes all cleanup steps sequentially (in reverse order of bootstrap)
 func (b *Bootstrapper) Unbootstrap(ctx context.Context) (*ExecutionResult, error) {
-	steps := []Executor{
-		services.NewUnInstaller(b.logger),             // Stop services first
-		npd.NewUnInstaller(b.logger),                  // Uninstall Node Problem Detector
-		kubelet.NewUnInstaller(b.logger),              // Clean kubelet configuration
-		cni.NewUnInstaller(b.logger),                  // Clean CNI configs
-		kube_binaries.NewUnInstaller(b.logger),        // Uninstall k8s binaries
-		containerd.NewUnInstaller(b.logger),           // Uninstall containerd binary
-		runc.NewUnInstaller(b.logger),                 // Uninstall runc binary
-		system_configuration.NewUnInstaller(b.logger), // Clean system settings
-		arc.NewUnInstaller(b.logger),                  // Uninstall Arc (after cleanup)
-	}
-
-	return b.ExecuteSteps(ctx, steps, "unbootstrap")
+	cfg := b.config
+	// External components are removed first, while the node still works as they found it
+	steps := append(componentUninstallers(externalComponents(cfg, b.logger)),
+		services.NewUnInstaller(cfg, b.logger),             // Stop services first
+		fluent_bit.NewUnInstaller(cfg, b.logger),           // Remove the log shipper
+		ssh_hardening.NewUnInstaller(cfg, b.logger),        // Revert SSH hardening and break-glass access (before Arc is removed)
+		npd.NewUnInstaller(cfg, b.logger),                  // Uninstall Node Problem Detector
+		static_pods.NewUnInstaller(cfg, b.logger),          // Remove the static pod manifests the agent wrote
+		custom_scripts.NewUnInstaller(cfg, b.logger),       // Remove the custom scripts and their records
+		kubelet.NewUnInstaller(b.logger),                   // Clean kubelet configuration
+		node_topology.NewUnInstaller(cfg, b.logger),        // Remove the topology inventory
+		cni.NewUnInstaller(cfg, b.logger),                  // Clean CNI configs
+		kube_binaries.NewUnInstaller(b.logger),             // Uninstall k8s binaries
+		container_runtime.NewUnInstaller(cfg, b.logger),    // Uninstall containerd or CRI-O
+		runc.NewUnInstaller(cfg, b.logger),                 // Uninstall runc binary
+		sriov.NewUnInstaller(cfg, b.logger),                // Remove SR-IOV VFs and device plugin configuration
+		workload_isolation.NewUnInstaller(cfg, b.logger),   // Remove the Kubernetes slice
+		system_configuration.NewUnInstaller(cfg, b.logger), // Clean system settings
+		arc.NewUnInstaller(cfg, b.logger),                  // Uninstall Arc (after cleanup)
+		dns.NewUnInstaller(cfg, b.logger),                  // Restore the host's DNS settings, Arc cleanup may still need them
+		ca_trust.NewUnInstaller(cfg, b.logger),             // Remove custom CAs last, Arc cleanup may still need them
+	)
+
+	result, err := b.ExecuteSteps(ctx, withoutDisabledComponents(cfg, steps, b.logger), "unbootstrap")
+
+	// Put back host files bootstrap replaced, such as a containerd config.toml removed with /etc/containerd.
+	// After a failed step the backups are kept for the next attempt.
+	if err == nil {
+		if failed := filebackup.RevertAll(b.logger); len(failed) > 0 {
+			b.logger.Warnf("Left %d changed files in place, their originals are kept in %s: %s",
+				len(failed), filebackup.Dir, strings.Join(failed, ", "))
+		}
+	}
+
+	// A reboot requested by bootstrap is no longer needed once the node is removed
+	if pending, stateErr := loadRebootState(); stateErr == nil && pending != nil {
+		if !pending.rebooted() && !pending.ScheduledAt.IsZero() {
+			if cancelErr := utils.RunSystemCommand("shutdown", "-c"); cancelErr != nil {
+				b.logger.WithError(cancelErr).Warn("Failed to cancel the scheduled reboot")
+			}
+		}
+		clearRebootState(b.logger)
+	}
+	b.clearManifest()
+	b.saveState(ctx)
+	return result, err
+}
+
+// saveState updates the copy of the agent's state in the configured state backend. The node keeps working
+// without it, so failures are only logged.
+func (b *Bootstrapper) saveState(ctx context.Context) {
+	if err := state.Save(ctx, b.config, b.logger); err != nil {
+		b.logger.Warnf("Failed to save the agent's state to the %s state backend: %v", b.config.Agent.State.Backend, err)
+	}
 }
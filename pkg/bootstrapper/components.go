@@ -0,0 +1,222 @@
+package bootstrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/component"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// externalComponent is a component from components.external, created through the component SDK or run
+// through its exec protocol
+type externalComponent struct {
+	name      string
+	after     string
+	component component.Component
+	err       error // Why the component cannot run, e.g. it is not registered; reported when its step runs
+}
+
+// builtinComponentSteps maps the bootstrap and unbootstrap steps of built-in components to the component's
+// name in components.enabled, except runtimeVerificationStep
+var builtinComponentSteps = map[string]string{
+	"SystemConfigured":              config.ComponentSystemConfiguration,
+	"SystemCleanup":                 config.ComponentSystemConfiguration,
+	"Runc_Installer":                config.ComponentRunc,
+	"Runc_Uninstaller":              config.ComponentRunc,
+	"ContainerdInstaller":           config.ComponentContainerd,
+	"ContainerdUninstaller":         config.ComponentContainerd,
+	"CRIOInstaller":                 config.ComponentCRIO,
+	"CRIOUninstaller":               config.ComponentCRIO,
+	"ImagePrePull":                  config.ComponentImagePrePull,
+	"KubeBinariesInstaller":         config.ComponentKubeBinaries,
+	"KubernetesComponentsExecuteed": config.ComponentKubeBinaries,
+	"CNISetup":                      config.ComponentCNI,
+	"CNICleanup":                    config.ComponentCNI,
+	"NodeTopology_Installer":        config.ComponentNodeTopology,
+	"NodeTopology_Publisher":        config.ComponentNodeTopology,
+	"NodeTopology_UnInstaller":      config.ComponentNodeTopology,
+	"KubeletInstaller":              config.ComponentKubelet,
+	"KubeletUnInstaller":            config.ComponentKubelet,
+	"StaticPods_Installer":          config.ComponentStaticPods,
+	"StaticPods_UnInstaller":        config.ComponentStaticPods,
+	"NPD_Installer":                 config.ComponentNPD,
+	"NPD_Verification":              config.ComponentNPD,
+	"NPD_UnInstaller":               config.ComponentNPD,
+	"CustomScripts_Installer":       config.ComponentCustomScripts,
+	"CustomScripts_UnInstaller":     config.ComponentCustomScripts,
+}
+
+// runtimeVerificationStep tests whichever container runtime containerRuntime selects
+const runtimeVerificationStep = "ContainerRuntimeVerification"
+
+// withoutDisabledComponents drops the steps of built-in components that components.enabled turns off. Those
+// are neither installed nor removed, since something other than the agent may provide them.
+func withoutDisabledComponents(cfg *config.Config, steps []Executor, logger *logrus.Logger) []Executor {
+	kept := make([]Executor, 0, len(steps))
+	for _, step := range steps {
+		name, ok := builtinComponentSteps[step.GetName()]
+		if step.GetName() == runtimeVerificationStep {
+			name, ok = cfg.GetContainerRuntime(), true
+		}
+		if ok && !cfg.IsComponentEnabled(name) {
+			logger.Debugf("Leaving out step %s: components.enabled turns %s off", step.GetName(), name)
+			continue
+		}
+		kept = append(kept, step)
+	}
+	return kept
+}
+
+// externalComponents creates the components listed in components.external, in the order they are listed
+func externalComponents(cfg *config.Config, logger *logrus.Logger) []*externalComponent {
+	if len(cfg.Components.External) == 0 {
+		return nil
+	}
+	pluginErr := component.LoadPlugins(cfg.Components.Plugins)
+	components := make([]*externalComponent, 0, len(cfg.Components.External))
+	for _, ext := range cfg.Components.External {
+		c := &externalComponent{name: ext.Name, after: ext.After}
+		env := component.NewEnv(ext.Name, cfg, logger, ext.Settings)
+		if ext.Exec != "" {
+			timeout, _ := time.ParseDuration(ext.Timeout) // Validated with the configuration
+			c.component = component.NewExec(env, ext.Exec, timeout)
+			components = append(components, c)
+			continue
+		}
+		registration, ok := component.Lookup(ext.Name)
+		switch {
+		case pluginErr != nil:
+			c.err = pluginErr
+		case !ok:
+			c.err = fmt.Errorf("component %s is not registered, registered components: [%s]", ext.Name, strings.Join(component.Names(), ", "))
+		default:
+			if c.after == "" {
+				c.after = registration.After
+			}
+			c.component, c.err = registration.New(env)
+			if c.err != nil {
+				c.err = fmt.Errorf("failed to create component %s: %w", ext.Name, c.err)
+			}
+		}
+		components = append(components, c)
+	}
+	return components
+}
+
+// withComponentInstallers inserts the installers of components after the steps they are registered after, or
+// at the end. Components registered after the same step keep the order they are listed in.
+func withComponentInstallers(steps []Executor, components []*externalComponent) []Executor {
+	for _, c := range components {
+		at := len(steps)
+		if c.after != "" {
+			at = -1
+			for i, step := range steps {
+				if step.GetName() == c.after {
+					at = i + 1
+					break
+				}
+			}
+			if at < 0 {
+				if c.err == nil {
+					c.err = fmt.Errorf("component %s is to be installed after step %s, which is not a bootstrap step", c.name, c.after)
+				}
+				at = len(steps)
+			}
+			for at < len(steps) {
+				if _, ok := steps[at].(*componentInstaller); !ok {
+					break
+				}
+				at++
+			}
+		}
+		steps = append(steps[:at], append([]Executor{&componentInstaller{c}}, steps[at:]...)...)
+	}
+	return steps
+}
+
+// componentUninstallers returns the uninstallers of components, in reverse order
+func componentUninstallers(components []*externalComponent) []Executor {
+	steps := make([]Executor, 0, len(components))
+	for i := len(components) - 1; i >= 0; i-- {
+		steps = append(steps, &componentUninstaller{components[i]})
+	}
+	return steps
+}
+
+// componentInstaller installs an external component as a bootstrap step
+type componentInstaller struct {
+	*externalComponent
+}
+
+// GetName returns the step name
+func (s *componentInstaller) GetName() string {
+	return "Component_" + s.name
+}
+
+// Validate reports why the component cannot run, or validates the component's preconditions
+func (s *componentInstaller) Validate(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	if validator, ok := s.component.(component.Validator); ok {
+		return validator.Validate(ctx)
+	}
+	return nil
+}
+
+// Execute installs the component
+func (s *componentInstaller) Execute(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.component.Install(ctx)
+}
+
+// IsCompleted reports whether the component is installed
+func (s *componentInstaller) IsCompleted(ctx context.Context) bool {
+	return s.err == nil && s.component.IsInstalled(ctx)
+}
+
+// ManagedFiles returns the files the component installs
+func (s *componentInstaller) ManagedFiles() []string {
+	if owner, ok := s.component.(component.FileOwner); ok && s.err == nil {
+		return owner.ManagedFiles()
+	}
+	return nil
+}
+
+// RequiresReboot reports whether the component's changes need a reboot
+func (s *componentInstaller) RequiresReboot(ctx context.Context) bool {
+	if rebooter, ok := s.component.(component.RebootRequirer); ok && s.err == nil {
+		return rebooter.RequiresReboot(ctx)
+	}
+	return false
+}
+
+// componentUninstaller removes an external component as an unbootstrap step
+type componentUninstaller struct {
+	*externalComponent
+}
+
+// GetName returns the step name
+func (s *componentUninstaller) GetName() string {
+	return "Component_" + s.name + "_Cleanup"
+}
+
+// Execute removes the component
+func (s *componentUninstaller) Execute(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.component.Uninstall(ctx)
+}
+
+// IsCompleted returns false; Uninstall removes whatever is left of the component
+func (s *componentUninstaller) IsCompleted(ctx context.Context) bool {
+	return false
+}
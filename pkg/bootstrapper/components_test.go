@@ -0,0 +1,164 @@
+package bootstrapper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/component"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// securityAgent is a component as a platform team would register it
+type securityAgent struct {
+	env       *component.Env
+	installed bool
+}
+
+func (a *securityAgent) Install(ctx context.Context) error    { a.installed = true; return nil }
+func (a *securityAgent) IsInstalled(ctx context.Context) bool { return a.installed }
+func (a *securityAgent) Uninstall(ctx context.Context) error  { a.installed = false; return nil }
+func (a *securityAgent) ManagedFiles() []string               { return []string{"/opt/contoso/agent"} }
+
+func (a *securityAgent) Validate(ctx context.Context) error {
+	if a.env.Setting("endpoint", "") == "" {
+		return errors.New("endpoint is required")
+	}
+	return nil
+}
+
+func init() {
+	component.Register(component.Registration{
+		Name:  "security-agent",
+		After: "Containerd",
+		New:   func(env *component.Env) (component.Component, error) { return &securityAgent{env: env}, nil },
+	})
+	component.Register(component.Registration{
+		Name:  "audit-agent",
+		After: "Containerd",
+		New:   func(env *component.Env) (component.Component, error) { return &securityAgent{env: env}, nil },
+	})
+	component.Register(component.Registration{
+		Name:  "misplaced-agent",
+		After: "Missing",
+		New:   func(env *component.Env) (component.Component, error) { return &securityAgent{env: env}, nil },
+	})
+}
+
+func stepNames(steps []Executor) []string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.GetName()
+	}
+	return names
+}
+
+func TestWithComponentInstallers(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Components: config.ComponentsConfig{External: []config.ExternalComponentConfig{
+		{Name: "security-agent", Settings: map[string]string{"endpoint": "https://agent.contoso.com"}},
+		{Name: "audit-agent"},
+		{Name: "unknown-agent"},
+		{Name: "misplaced-agent"},
+	}}}
+	components := externalComponents(cfg, logrus.New())
+
+	steps := withComponentInstallers([]Executor{&fakeStep{name: "Containerd"}, &fakeStep{name: "Kubelet"}}, components)
+	want := []string{"Containerd", "Component_security-agent", "Component_audit-agent", "Kubelet", "Component_unknown-agent", "Component_misplaced-agent"}
+	if got := stepNames(steps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("withComponentInstallers() = %v, want %v", got, want)
+	}
+
+	security := steps[1].(*componentInstaller)
+	if err := security.Validate(ctx); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	if security.IsCompleted(ctx) {
+		t.Error("IsCompleted() before Execute() = true")
+	}
+	if err := security.Execute(ctx); err != nil || !security.IsCompleted(ctx) {
+		t.Errorf("Execute() = %v, want the component installed", err)
+	}
+	if files := security.ManagedFiles(); !reflect.DeepEqual(files, []string{"/opt/contoso/agent"}) {
+		t.Errorf("ManagedFiles() = %v", files)
+	}
+
+	for i, wantErr := range map[int]string{2: "endpoint is required", 4: "not registered", 5: "not a bootstrap step"} {
+		if err := steps[i].(*componentInstaller).Validate(ctx); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s Validate() = %v, want %q", steps[i].GetName(), err, wantErr)
+		}
+	}
+
+	uninstallers := componentUninstallers(components)
+	if got, want := stepNames(uninstallers)[0], "Component_misplaced-agent_Cleanup"; got != want {
+		t.Errorf("first uninstaller = %s, want %s", got, want)
+	}
+	if err := uninstallers[3].Execute(ctx); err != nil || security.IsCompleted(ctx) {
+		t.Errorf("uninstaller Execute() = %v, want the component removed", err)
+	}
+}
+
+func TestWithoutDisabledComponents(t *testing.T) {
+	steps := []Executor{
+		&fakeStep{name: "CNISetup"},
+		&fakeStep{name: "ContainerRuntimeVerification"},
+		&fakeStep{name: "ImagePrePull"},
+		&fakeStep{name: "NPD_Installer"},
+		&fakeStep{name: "ServicesEnabled"},
+		&fakeStep{name: "NPD_Verification"},
+	}
+	tests := []struct {
+		name    string
+		runtime string
+		enabled map[string]bool
+		want    []string
+	}{
+		{name: "all on", want: stepNames(steps)},
+		{
+			name:    "leaves off",
+			enabled: map[string]bool{"npd": false, "imagePrePull": false, "kubelet": true},
+			want:    []string{"CNISetup", "ContainerRuntimeVerification", "ServicesEnabled"},
+		},
+		{
+			name:    "selected runtime off",
+			enabled: map[string]bool{"containerd": false},
+			want:    []string{"CNISetup", "ImagePrePull", "NPD_Installer", "ServicesEnabled", "NPD_Verification"},
+		},
+		{
+			name:    "other runtime off",
+			runtime: "cri-o",
+			enabled: map[string]bool{"containerd": false},
+			want:    stepNames(steps),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{ContainerRuntime: tt.runtime, Components: config.ComponentsConfig{Enabled: tt.enabled}}
+			if got := stepNames(withoutDisabledComponents(cfg, steps, logrus.New())); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withoutDisabledComponents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinComponentSteps(t *testing.T) {
+	// Every built-in component must leave out at least one real bootstrap step when turned off
+	covered := map[string]bool{}
+	for _, runtime := range []string{"containerd", "cri-o"} {
+		for _, name := range New(&config.Config{ContainerRuntime: runtime}, logrus.New()).BootstrapStepNames() {
+			if component, ok := builtinComponentSteps[name]; ok {
+				covered[component] = true
+			}
+		}
+	}
+	for _, component := range config.BuiltinComponents {
+		if !covered[component] {
+			t.Errorf("no bootstrap step belongs to built-in component %s", component)
+		}
+	}
+}
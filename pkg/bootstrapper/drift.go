@@ -0,0 +1,140 @@
+package bootstrapper
+
+import (
+	"context"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/services"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+	"go.goms.io/aks/AKSFlexNode/pkg/sbom"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Remediate reinstalls files changed outside of bootstrap by running the steps that own them again,
+// between stopping and restarting the node services as bootstrap does. Drifted files are removed first,
+// so steps that skip an existing installation download it again. The installed files are recorded
+// again once all steps succeed.
+func (b *Bootstrapper) Remediate(ctx context.Context, findings []drift.Finding) (*ExecutionResult, error) {
+	return b.rerun(ctx, remediationSteps(b.bootstrapSteps(), findings))
+}
+
+// Reinstall runs the named bootstrap steps again, even when they report themselves completed, between
+// stopping and restarting the node services as bootstrap does
+func (b *Bootstrapper) Reinstall(ctx context.Context, stepNames []string) (*ExecutionResult, error) {
+	named := map[string][]string{}
+	for _, name := range stepNames {
+		named[name] = nil
+	}
+	return b.rerun(ctx, forceSteps(b.bootstrapSteps(), named))
+}
+
+// rerun runs steps between stopping and restarting the node services and records the installed files on success
+func (b *Bootstrapper) rerun(ctx context.Context, rerun []Executor) (*ExecutionResult, error) {
+	cfg := b.config
+	steps := []Executor{services.NewUnInstaller(cfg, b.logger)}
+	steps = append(steps, rerun...)
+	steps = append(steps, services.NewInstaller(cfg, b.logger))
+
+	result, err := b.ExecuteSteps(ctx, steps, "bootstrap")
+	defer b.saveState(ctx)
+	if err != nil {
+		return result, err
+	}
+	if result.Success {
+		b.recordManifest(b.bootstrapSteps())
+	}
+	return result, nil
+}
+
+// remediationSteps returns the steps owning the drifted files in bootstrap order, each forced to run
+func remediationSteps(steps []Executor, findings []drift.Finding) []Executor {
+	drifted := map[string][]string{}
+	for _, finding := range findings {
+		drifted[finding.Step] = append(drifted[finding.Step], finding.Path)
+	}
+	return forceSteps(steps, drifted)
+}
+
+// forceSteps returns the steps named in files in bootstrap order, each forced to run after removing its listed files
+func forceSteps(steps []Executor, files map[string][]string) []Executor {
+	var forced []Executor
+	for _, step := range steps {
+		if stepFiles, ok := files[step.GetName()]; ok {
+			forced = append(forced, &forcedStep{Executor: step, files: stepFiles})
+		}
+	}
+	return forced
+}
+
+// forceFileOwners returns steps with each step that installs files forced to run
+func forceFileOwners(steps []Executor) []Executor {
+	forced := make([]Executor, len(steps))
+	for i, step := range steps {
+		forced[i] = step
+		if _, ok := step.(FileOwner); ok {
+			forced[i] = &forcedStep{Executor: step}
+		}
+	}
+	return forced
+}
+
+// forcedStep runs a step even when it reports itself completed, after removing the given files
+type forcedStep struct {
+	Executor
+	files []string
+}
+
+// IsCompleted always returns false; the step's own check does not look at file contents
+func (s *forcedStep) IsCompleted(ctx context.Context) bool {
+	return false
+}
+
+// Validate validates the preconditions of the wrapped step
+func (s *forcedStep) Validate(ctx context.Context) error {
+	if step, ok := s.Executor.(StepExecutor); ok {
+		return step.Validate(ctx)
+	}
+	return nil
+}
+
+// Execute removes the drifted files and runs the wrapped step
+func (s *forcedStep) Execute(ctx context.Context) error {
+	for _, file := range s.files {
+		if err := utils.RunCleanupCommand(file); err != nil {
+			return err
+		}
+	}
+	return s.Executor.Execute(ctx)
+}
+
+// managedFiles returns the files installed by each step, keyed by step name
+func managedFiles(steps []Executor) map[string][]string {
+	files := map[string][]string{}
+	for _, step := range steps {
+		if owner, ok := step.(FileOwner); ok {
+			files[step.GetName()] = owner.ManagedFiles()
+		}
+	}
+	return files
+}
+
+// recordManifest records the digests of the files installed by steps, for drift detection in agent mode
+func (b *Bootstrapper) recordManifest(steps []Executor) {
+	manifest, err := drift.NewManifest(managedFiles(steps))
+	if err == nil {
+		err = manifest.Save(drift.ManifestPath)
+	}
+	if err != nil {
+		b.logger.Warnf("Failed to record installed files for drift detection: %v", err)
+	}
+}
+
+// clearManifest removes the drift manifest and report, and the SBOM of the installed components, once the
+// node is unbootstrapped
+func (b *Bootstrapper) clearManifest() {
+	for _, path := range []string{drift.ManifestPath, drift.ReportPath, download.ProvenancePath, sbom.SPDXPath, sbom.CycloneDXPath} {
+		if err := utils.RunCleanupCommand(path); err != nil {
+			b.logger.Debugf("Failed to remove %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package bootstrapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+)
+
+// ownerStep is a bootstrap step that installs files
+type ownerStep struct {
+	fakeStep
+	files []string
+}
+
+func (o *ownerStep) ManagedFiles() []string { return o.files }
+
+func TestManagedFiles(t *testing.T) {
+	steps := []Executor{
+		&fakeStep{name: "Preflight"},
+		&ownerStep{fakeStep: fakeStep{name: "Containerd"}, files: []string{"/usr/bin/containerd", "/etc/containerd/config.toml"}},
+	}
+	want := map[string][]string{"Containerd": {"/usr/bin/containerd", "/etc/containerd/config.toml"}}
+	if got := managedFiles(steps); !reflect.DeepEqual(got, want) {
+		t.Errorf("managedFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestRemediationSteps(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(config, []byte("hand edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runc := &fakeStep{name: "Runc"}
+	containerd := &fakeStep{name: "Containerd"}
+	kubelet := &fakeStep{name: "Kubelet"}
+	findings := []drift.Finding{
+		{Path: "/usr/local/bin/kubelet", Step: "Kubelet", Problem: drift.Missing},
+		{Path: config, Step: "Containerd", Problem: drift.Modified},
+	}
+
+	steps := remediationSteps([]Executor{runc, containerd, kubelet}, findings)
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.GetName())
+		if step.IsCompleted(context.Background()) {
+			t.Errorf("remediation step %s reports completed, want it forced to run", step.GetName())
+		}
+	}
+	if want := []string{"Containerd", "Kubelet"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("remediationSteps() = %v, want %v in bootstrap order", names, want)
+	}
+
+	if err := steps[0].Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if !containerd.ran {
+		t.Error("Execute() did not run the owning step")
+	}
+	if _, err := os.Stat(config); !os.IsNotExist(err) {
+		t.Errorf("Execute() left the drifted file %s in place", config)
+	}
+}
+
+func TestForceFileOwners(t *testing.T) {
+	preflight := &fakeStep{name: "Preflight"}
+	containerd := &ownerStep{fakeStep: fakeStep{name: "Containerd"}}
+	steps := forceFileOwners([]Executor{preflight, containerd})
+
+	if steps[0] != Executor(preflight) {
+		t.Error("forceFileOwners() wrapped a step that installs no files")
+	}
+	if _, ok := steps[1].(*forcedStep); !ok || steps[1].GetName() != "Containerd" {
+		t.Errorf("forceFileOwners() = %T for Containerd, want it forced", steps[1])
+	}
+}
@@ -2,12 +2,14 @@ package bootstrapper
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
 )
 
 // executor is a common base interface for all executors
@@ -32,6 +34,29 @@ type StepExecutor interface {
 	Validate(ctx context.Context) error
 }
 
+// RebootRequirer is implemented by steps whose changes only take effect after a reboot,
+// such as kernel boot parameters. Bootstrap stops after such a step and resumes on the next boot.
+type RebootRequirer interface {
+	// RequiresReboot reports whether the changes made by Execute need a reboot before later steps can run
+	RequiresReboot(ctx context.Context) bool
+}
+
+// FileOwner is implemented by steps that install binaries or render configuration files, so the agent
+// can detect when those files are changed outside of bootstrap and re-run the step that owns them
+type FileOwner interface {
+	// ManagedFiles returns the paths of the files the step installs
+	ManagedFiles() []string
+}
+
+// StepObserver is notified as steps run, e.g. to render progress in a terminal UI
+type StepObserver interface {
+	// StepStarted is called before a step is checked and executed
+	StepStarted(stepName string)
+
+	// StepFinished is called with the result of each step
+	StepFinished(result StepResult)
+}
+
 // ExecutionResult represents the result of bootstrap or unbootstrap process
 type ExecutionResult struct {
 	Success     bool          `json:"success"`
@@ -39,20 +64,31 @@ type ExecutionResult struct {
 	Duration    time.Duration `json:"duration"`
 	StepResults []StepResult  `json:"step_results"`
 	Error       string        `json:"error,omitempty"`
+
+	// Set when bootstrap stopped early because RebootStep needs a reboot; the remaining steps run after it
+	RebootRequired    bool      `json:"reboot_required,omitempty"`
+	RebootStep        string    `json:"reboot_step,omitempty"`
+	RebootScheduledAt time.Time `json:"reboot_scheduled_at,omitzero"` // zero when the reboot is left to the operator
 }
 
 // StepResult represents the result of a single step
 type StepResult struct {
 	StepName string        `json:"step_name"`
 	Success  bool          `json:"success"`
+	Skipped  bool          `json:"skipped,omitempty"` // The step reported itself completed and did not run
 	Duration time.Duration `json:"duration"`
 	Error    string        `json:"error,omitempty"`
+	Err      error         `json:"-"` // *errdefs.ComponentError wrapping the failure, nil on success
+
+	Retries         int64 `json:"retries"`          // Backoff and Azure SDK retries while the step ran
+	DownloadedBytes int64 `json:"downloaded_bytes"` // Bytes downloaded while the step ran
 }
 
 // BaseExecutor provides common functionality for bootstrap and unbootstrap operations
 type BaseExecutor struct {
-	config *config.Config
-	logger *logrus.Logger
+	config   *config.Config
+	logger   *logrus.Logger
+	observer StepObserver
 }
 
 // NewBaseExecutor creates a new base executor
@@ -63,6 +99,11 @@ func NewBaseExecutor(cfg *config.Config, logger *logrus.Logger) *BaseExecutor {
 	}
 }
 
+// SetObserver registers an observer that is notified of step progress
+func (be *BaseExecutor) SetObserver(observer StepObserver) {
+	be.observer = observer
+}
+
 // ExecuteSteps executes a list of steps and returns results
 func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, stepType string) (*ExecutionResult, error) {
 	be.logger.Infof("Starting AKS node %s", stepType)
@@ -74,7 +115,16 @@ func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, step
 
 	// Execute each step
 	for _, step := range steps {
+		if be.observer != nil {
+			be.observer.StepStarted(step.GetName())
+		}
+		before := profiling.Snapshot()
 		stepResult := be.executeStep(ctx, step, stepType)
+		counted := profiling.Snapshot().Sub(before)
+		stepResult.Retries, stepResult.DownloadedBytes = counted.Retries, counted.DownloadedBytes
+		if be.observer != nil {
+			be.observer.StepFinished(stepResult)
+		}
 		result.StepResults = append(result.StepResults, stepResult)
 
 		if !stepResult.Success {
@@ -87,12 +137,27 @@ func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, step
 
 				be.logger.Errorf("Bootstrap failed at step %s: %s (completedSteps: %d, totalSteps: %d)",
 					stepResult.StepName, stepResult.Error, len(result.StepResults), len(steps))
+				// Point the user at the most likely fix; callers return the error without looking at the result
+				be.logger.Warn(messages.HintForFailure(stepResult.StepName, stepResult.Error))
 
-				return result, fmt.Errorf("bootstrap failed at step %s: %w", stepResult.StepName, errors.New(stepResult.Error))
+				return result, fmt.Errorf("bootstrap failed at step %s: %w", stepResult.StepName, stepResult.Err)
 			}
 			// Unbootstrap continues even if some steps fail for best effort cleanup
 			be.logger.Warnf("Cleanup step %s failed: %s (continuing with remaining steps)",
 				stepResult.StepName, stepResult.Error)
+			continue
+		}
+
+		// Later steps may depend on changes that only take effect after a reboot
+		if rebooter, ok := step.(RebootRequirer); ok && stepType == "bootstrap" && rebooter.RequiresReboot(ctx) {
+			result.RebootRequired = true
+			result.RebootStep = stepResult.StepName
+			result.Duration = time.Since(startTime)
+			result.StepCount = len(result.StepResults)
+
+			be.logger.Warnf("Bootstrap step %s requires a reboot, stopping before the remaining %d steps",
+				stepResult.StepName, len(steps)-len(result.StepResults))
+			return result, nil
 		}
 	}
 
@@ -125,7 +190,9 @@ func (be *BaseExecutor) executeStep(ctx context.Context, step Executor, stepType
 	// Check if step is already completed
 	if step.IsCompleted(ctx) {
 		be.logger.Infof("%s step: %s already completed", stepType, stepName)
-		return be.createStepResult(stepName, startTime, true, "")
+		result := be.createStepResult(stepName, startTime, nil)
+		result.Skipped = true
+		return result
 	}
 
 	var err error
@@ -133,7 +200,7 @@ func (be *BaseExecutor) executeStep(ctx context.Context, step Executor, stepType
 		// Validate preconditions for bootstrap steps
 		if validationErr := bootstrapStep.Validate(ctx); validationErr != nil {
 			be.logger.Errorf("%s step %s validation failed with error: %s", stepType, stepName, validationErr)
-			return be.createStepResult(stepName, startTime, false, fmt.Sprintf("validation failed: %v", validationErr))
+			return be.createStepResult(stepName, startTime, &errdefs.ComponentError{Name: stepName, Phase: errdefs.PhaseValidate, Err: validationErr})
 		}
 	}
 
@@ -141,21 +208,25 @@ func (be *BaseExecutor) executeStep(ctx context.Context, step Executor, stepType
 	err = step.Execute(ctx)
 	if err != nil {
 		be.logger.Errorf("%s step: %s failed with error: %s with duration %s", stepType, stepName, err, time.Since(startTime))
-		return be.createStepResult(stepName, startTime, false, err.Error())
+		return be.createStepResult(stepName, startTime, &errdefs.ComponentError{Name: stepName, Phase: errdefs.PhaseExecute, Err: err})
 	}
 
 	be.logger.Infof("%s step: %s completed successfully with duration %s", stepType, stepName, time.Since(startTime))
-	return be.createStepResult(stepName, startTime, true, "")
+	return be.createStepResult(stepName, startTime, nil)
 }
 
-// createStepResult creates a StepResult with consistent formatting
-func (be *BaseExecutor) createStepResult(stepName string, startTime time.Time, success bool, errorMsg string) StepResult {
-	return StepResult{
+// createStepResult creates a StepResult with consistent formatting; a nil err means the step succeeded
+func (be *BaseExecutor) createStepResult(stepName string, startTime time.Time, err error) StepResult {
+	result := StepResult{
 		StepName: stepName,
-		Success:  success,
+		Success:  err == nil,
 		Duration: time.Since(startTime),
-		Error:    errorMsg,
 	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Err = err
+	}
+	return result
 }
 
 // countSuccessfulSteps counts the number of successful steps
@@ -0,0 +1,53 @@
+package bootstrapper
+
+import (
+	"context"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ca_trust"
+	"go.goms.io/aks/AKSFlexNode/pkg/discovery"
+)
+
+// Actions of a planned step
+const (
+	// ActionInstall means the step reports itself not completed and runs
+	ActionInstall = "install"
+	// ActionReconfigure means the step is completed but renders its files again for a changed configuration
+	ActionReconfigure = "reconfigure"
+	// ActionNone means the step is completed and skipped
+	ActionNone = "none"
+)
+
+// PlannedStep is what bootstrap will do with one step
+type PlannedStep struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// Plan returns what Bootstrap would do with each step, in execution order, without changing the node.
+// Each step is planned from the node's current state, so a step that only completes once an earlier
+// step ran is planned to install.
+func (b *Bootstrapper) Plan(ctx context.Context) ([]PlannedStep, error) {
+	// Discovery calls ARM, possibly through a TLS-inspecting proxy
+	if err := ca_trust.TrustInProcess(ctx, b.config, b.logger); err != nil {
+		return nil, err
+	}
+	// Steps read the cluster's location and node resource group, which may only be known from ARM
+	if err := discovery.DiscoverCluster(ctx, b.config, b.logger); err != nil {
+		return nil, err
+	}
+
+	steps := b.bootstrapSteps()
+	planned := make([]PlannedStep, len(steps))
+	for i, step := range steps {
+		action := ActionNone
+		_, owner := step.(FileOwner)
+		switch {
+		case !step.IsCompleted(ctx):
+			action = ActionInstall
+		case b.reconfigure && owner:
+			action = ActionReconfigure
+		}
+		planned[i] = PlannedStep{Name: step.GetName(), Action: action}
+	}
+	return planned, nil
+}
@@ -0,0 +1,253 @@
+package bootstrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// rebootStatePath records a reboot requested by a bootstrap step until bootstrap completes after it
+	rebootStatePath = "/var/lib/aks-flex-node/reboot-pending.json"
+
+	// The oneshot unit resuming bootstrap on the next boot, started before the agent service
+	resumeUnitName = "aks-flex-node-resume.service"
+	resumeUnitPath = "/etc/systemd/system/" + resumeUnitName
+
+	// bootIDPath changes on every boot, which tells a pending reboot apart from one that already happened
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
+)
+
+// rebootState is persisted when a step requests a reboot
+type rebootState struct {
+	Step        string    `json:"step"`
+	BootID      string    `json:"bootId"`
+	RequestedAt time.Time `json:"requestedAt"`
+	ScheduledAt time.Time `json:"scheduledAt,omitzero"`
+}
+
+// currentBootID returns the kernel's identifier for the current boot
+func currentBootID() (string, error) {
+	data, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read boot ID: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// loadRebootState returns the pending reboot request, or nil if there is none
+func loadRebootState() (*rebootState, error) {
+	data, err := os.ReadFile(rebootStatePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rebootStatePath, err)
+	}
+	state := &rebootState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", rebootStatePath, err)
+	}
+	return state, nil
+}
+
+// saveRebootState persists the reboot request before the reboot is scheduled
+func saveRebootState(state *rebootState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode reboot state: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(rebootStatePath)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(rebootStatePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rebootStatePath, err)
+	}
+	return nil
+}
+
+// rebooted reports whether the machine restarted since the state was recorded
+func (s *rebootState) rebooted() bool {
+	bootID, err := currentBootID()
+	return err == nil && bootID != s.BootID
+}
+
+// RebootPending reports whether a bootstrap step requested a reboot that has not happened yet
+func RebootPending() bool {
+	state, err := loadRebootState()
+	return err == nil && state != nil && !state.rebooted()
+}
+
+// ResumePending reports whether bootstrap was interrupted by a reboot that has since happened
+func ResumePending() bool {
+	state, err := loadRebootState()
+	return err == nil && state != nil && state.rebooted()
+}
+
+// requestReboot persists the reboot request, installs the resume unit and schedules the reboot per the configured policy
+func (b *Bootstrapper) requestReboot(ctx context.Context, result *ExecutionResult) error {
+	bootID, err := currentBootID()
+	if err != nil {
+		return err
+	}
+	if err := b.installResumeUnit(); err != nil {
+		return fmt.Errorf("failed to install resume unit: %w", err)
+	}
+
+	now := time.Now()
+	state := &rebootState{Step: result.RebootStep, BootID: bootID, RequestedAt: now}
+	switch b.config.GetRebootPolicy() {
+	case "immediate":
+		state.ScheduledAt = now
+	case "maintenance-window":
+		when, err := nextRebootTime(now, b.config.Agent.Reboot.MaintenanceWindow)
+		if err != nil {
+			return err
+		}
+		state.ScheduledAt = when
+	}
+
+	// Persist first so the next boot resumes even if the reboot happens right away
+	if err := saveRebootState(state); err != nil {
+		return err
+	}
+	result.RebootScheduledAt = state.ScheduledAt
+	if state.ScheduledAt.IsZero() {
+		return nil
+	}
+	return scheduleReboot(now, state.ScheduledAt, result.RebootStep)
+}
+
+// scheduleReboot asks systemd to reboot at the given time, allowing at least a minute for logs to be flushed
+func scheduleReboot(now, when time.Time, step string) error {
+	at := "+1"
+	if when.Sub(now) > time.Minute {
+		at = when.Format("15:04")
+	}
+	message := fmt.Sprintf("aks-flex-node: rebooting to resume bootstrap after step %s", step)
+	if err := utils.RunSystemCommand("shutdown", "-r", at, message); err != nil {
+		return fmt.Errorf("failed to schedule reboot: %w", err)
+	}
+	return nil
+}
+
+// nextRebootTime returns now when it falls inside the daily window, otherwise the next start of the window.
+// Windows may wrap around midnight, e.g. "22:00-02:00".
+func nextRebootTime(now time.Time, window string) (time.Time, error) {
+	startText, endText, ok := strings.Cut(window, "-")
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid maintenance window: %s", window)
+	}
+	start, err := time.Parse("15:04", startText)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid maintenance window start %s: %w", startText, err)
+	}
+	end, err := time.Parse("15:04", endText)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid maintenance window end %s: %w", endText, err)
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startToday := midnight.Add(time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute)
+	duration := end.Sub(start)
+	if duration <= 0 {
+		duration += 24 * time.Hour
+	}
+
+	// The window that started yesterday may still be open when it wraps around midnight
+	for _, windowStart := range []time.Time{startToday.AddDate(0, 0, -1), startToday} {
+		if !now.Before(windowStart) && now.Before(windowStart.Add(duration)) {
+			return now, nil
+		}
+	}
+	if now.Before(startToday) {
+		return startToday, nil
+	}
+	return startToday.AddDate(0, 0, 1), nil
+}
+
+// InMaintenanceWindow reports whether now falls inside the daily maintenance window, e.g. "02:00-04:00"
+func InMaintenanceWindow(now time.Time, window string) bool {
+	next, err := nextRebootTime(now, window)
+	return err == nil && next.Equal(now)
+}
+
+// installResumeUnit writes and enables the oneshot unit that resumes bootstrap on the next boot
+func (b *Bootstrapper) installResumeUnit() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to resolve agent executable: %w", err)
+	}
+	configPath := b.config.GetConfigPath()
+	if configPath == "" {
+		return fmt.Errorf("config path is unknown, cannot resume bootstrap after reboot")
+	}
+
+	userName := ""
+	if current, err := user.Current(); err == nil && current.Uid != "0" {
+		userName = current.Username
+	}
+	unit := renderResumeUnit(executable, configPath, userName, os.Getenv("AZURE_CONFIG_DIR"))
+
+	if err := utils.WriteFileAtomicSystem(resumeUnitPath, []byte(unit), 0o644); err != nil {
+		return err
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	return utils.RunSystemCommand("systemctl", "enable", resumeUnitName)
+}
+
+// renderResumeUnit renders the resume unit running 'aks-flex-node resume' as the given user (root when empty)
+func renderResumeUnit(executable, configPath, userName, azureConfigDir string) string {
+	var service strings.Builder
+	fmt.Fprintf(&service, "ExecStart=%s resume --config %s\n", executable, configPath)
+	if userName != "" {
+		fmt.Fprintf(&service, "User=%s\n", userName)
+	}
+	if azureConfigDir != "" {
+		fmt.Fprintf(&service, "Environment=AZURE_CONFIG_DIR=%s\n", azureConfigDir)
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=Resume AKS Flex Node bootstrap after a reboot
+After=network-online.target
+Wants=network-online.target
+Before=aks-flex-node-agent.service
+ConditionPathExists=%s
+
+[Service]
+Type=oneshot
+%sTimeoutStartSec=1800
+StandardOutput=journal
+StandardError=journal
+
+[Install]
+WantedBy=multi-user.target
+`, rebootStatePath, service.String())
+}
+
+// clearRebootState removes the reboot request and the resume unit once bootstrap completed after the reboot
+func clearRebootState(logger *logrus.Logger) {
+	if err := utils.RunSystemCommand("systemctl", "disable", resumeUnitName); err != nil {
+		logger.WithError(err).Warnf("Failed to disable %s", resumeUnitName)
+	}
+	if err := utils.RunCleanupCommand(resumeUnitPath); err != nil {
+		logger.WithError(err).Warnf("Failed to remove %s", resumeUnitPath)
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		logger.WithError(err).Warn("Failed to reload systemd")
+	}
+	if err := utils.RunCleanupCommand(rebootStatePath); err != nil {
+		logger.WithError(err).Warnf("Failed to remove %s", rebootStatePath)
+	}
+}
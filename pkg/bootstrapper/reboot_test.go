@@ -0,0 +1,192 @@
+package bootstrapper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+)
+
+func TestNextRebootTime(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 10, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name   string
+		now    time.Time
+		window string
+		want   time.Time
+	}{
+		{"inside window", at(2, 30), "02:00-04:00", at(2, 30)},
+		{"before window", at(1, 0), "02:00-04:00", at(2, 0)},
+		{"after window", at(5, 0), "02:00-04:00", at(2, 0).AddDate(0, 0, 1)},
+		{"at window end", at(4, 0), "02:00-04:00", at(2, 0).AddDate(0, 0, 1)},
+		{"wrapping window after midnight", at(1, 0), "22:00-02:00", at(1, 0)},
+		{"wrapping window before midnight", at(23, 0), "22:00-02:00", at(23, 0)},
+		{"wrapping window outside", at(12, 0), "22:00-02:00", at(22, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextRebootTime(tt.now, tt.window)
+			if err != nil {
+				t.Fatalf("nextRebootTime() unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextRebootTime(%s, %s) = %s, want %s", tt.now.Format("15:04"), tt.window, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := nextRebootTime(at(0, 0), "02:00"); err == nil {
+		t.Error("nextRebootTime() expected error for a window without end")
+	}
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 10, hour, minute, 0, 0, time.UTC)
+	}
+	if !InMaintenanceWindow(at(2, 30), "02:00-04:00") || !InMaintenanceWindow(at(1, 0), "22:00-02:00") {
+		t.Error("InMaintenanceWindow() = false inside the window")
+	}
+	if InMaintenanceWindow(at(5, 0), "02:00-04:00") || InMaintenanceWindow(at(2, 30), "") {
+		t.Error("InMaintenanceWindow() = true outside the window or without one")
+	}
+}
+
+func TestRenderResumeUnit(t *testing.T) {
+	unit := renderResumeUnit("/usr/local/bin/aks-flex-node", "/etc/aks-flex-node/config.json", "aks-flex-node", "/home/azureuser/.azure")
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/aks-flex-node resume --config /etc/aks-flex-node/config.json\n",
+		"User=aks-flex-node\n",
+		"Environment=AZURE_CONFIG_DIR=/home/azureuser/.azure\n",
+		"Type=oneshot\n",
+		"Before=aks-flex-node-agent.service\n",
+		"ConditionPathExists=" + rebootStatePath + "\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("renderResumeUnit() missing %q in:\n%s", want, unit)
+		}
+	}
+
+	rootUnit := renderResumeUnit("/usr/local/bin/aks-flex-node", "/etc/aks-flex-node/config.json", "", "")
+	if strings.Contains(rootUnit, "User=") || strings.Contains(rootUnit, "Environment=") {
+		t.Errorf("renderResumeUnit() for root should not set User or Environment:\n%s", rootUnit)
+	}
+}
+
+// fakeStep is a bootstrap step that records execution and optionally fails or requests a reboot
+type fakeStep struct {
+	name   string
+	reboot bool
+	ran    bool
+	err    error
+}
+
+func (f *fakeStep) Execute(ctx context.Context) error       { f.ran = true; return f.err }
+func (f *fakeStep) IsCompleted(ctx context.Context) bool    { return false }
+func (f *fakeStep) GetName() string                         { return f.name }
+func (f *fakeStep) Validate(ctx context.Context) error      { return nil }
+func (f *fakeStep) RequiresReboot(ctx context.Context) bool { return f.reboot }
+
+func TestExecuteStepsStopsForReboot(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	executor := NewBaseExecutor(nil, logger)
+
+	first := &fakeStep{name: "First"}
+	kernel := &fakeStep{name: "Kernel", reboot: true}
+	last := &fakeStep{name: "Last"}
+
+	result, err := executor.ExecuteSteps(context.Background(), []Executor{first, kernel, last}, "bootstrap")
+	if err != nil {
+		t.Fatalf("ExecuteSteps() unexpected error: %v", err)
+	}
+	if !result.RebootRequired || result.RebootStep != "Kernel" {
+		t.Errorf("ExecuteSteps() reboot = %v at %q, want reboot at Kernel", result.RebootRequired, result.RebootStep)
+	}
+	if result.Success || last.ran {
+		t.Error("ExecuteSteps() should stop before the steps after the reboot")
+	}
+	if result.StepCount != 2 {
+		t.Errorf("ExecuteSteps() StepCount = %d, want 2", result.StepCount)
+	}
+
+	// Unbootstrap never stops for reboots
+	last.ran = false
+	result, _ = executor.ExecuteSteps(context.Background(), []Executor{kernel, last}, "unbootstrap")
+	if result.RebootRequired || !last.ran {
+		t.Error("ExecuteSteps() should ignore reboot requests during unbootstrap")
+	}
+}
+
+func TestExecuteStepsWrapsStepErrors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	executor := NewBaseExecutor(nil, logger)
+
+	cause := errors.New("download failed")
+	failing := &fakeStep{name: "Containerd", err: cause}
+	result, err := executor.ExecuteSteps(context.Background(), []Executor{failing}, "bootstrap")
+	if !errors.Is(err, cause) {
+		t.Fatalf("ExecuteSteps() error = %v, want wrapping %v", err, cause)
+	}
+	componentErr, ok := errdefs.As[*errdefs.ComponentError](err)
+	if !ok || componentErr.Name != "Containerd" || componentErr.Phase != errdefs.PhaseExecute {
+		t.Errorf("ExecuteSteps() error = %#v, want *errdefs.ComponentError for Containerd execute", err)
+	}
+	if result.StepResults[0].Error != "download failed" {
+		t.Errorf("StepResult.Error = %q, want the step's error message", result.StepResults[0].Error)
+	}
+}
+
+func TestExecuteStepsPrintsHintForFailedStep(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"known issue", errors.New("pull mcr.microsoft.com/oss/kubernetes/pause:3.6: x509: certificate signed by unknown authority"), "#tls-errors-from-mcr"},
+		{"step hint", errors.New("download failed"), messages.Get(messages.HintDownload)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := logrus.New()
+			logger.SetOutput(&out)
+			executor := NewBaseExecutor(nil, logger)
+
+			failing := &fakeStep{name: "ContainerdInstaller", err: tt.err}
+			if _, err := executor.ExecuteSteps(context.Background(), []Executor{failing}, "bootstrap"); err == nil {
+				t.Fatal("ExecuteSteps() expected error for a failed step")
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("ExecuteSteps() logged\n%s\nwant the hint %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkExecuteSteps measures the executor's own overhead per bootstrap run
+func BenchmarkExecuteSteps(b *testing.B) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	executor := NewBaseExecutor(nil, logger)
+
+	steps := make([]Executor, 20)
+	for i := range steps {
+		steps[i] = &fakeStep{name: "Step"}
+	}
+	for b.Loop() {
+		if _, err := executor.ExecuteSteps(context.Background(), steps, "bootstrap"); err != nil {
+			b.Fatalf("ExecuteSteps() unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package bootstrapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/services"
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// systemdSystemDir holds the units bootstrap installs
+const systemdSystemDir = "/etc/systemd/system"
+
+// Replaced in tests
+var (
+	fileExists    = utils.FileExists
+	serviceActive = utils.IsServiceActive
+	serviceOn     = utils.IsServiceEnabled
+	backupEntries = filebackup.Entries
+	hostname      = os.Hostname
+)
+
+// PlannedFile is an installed file Unbootstrap would remove
+type PlannedFile struct {
+	Step string `json:"step"`
+	Path string `json:"path"`
+}
+
+// RemovalPlan lists what Unbootstrap would remove from the node, Azure and the cluster. The workloads it
+// would disrupt are reported by the decommission package.
+type RemovalPlan struct {
+	Files          []PlannedFile `json:"files"`
+	RestoredFiles  []string      `json:"restoredFiles"`  // Host files put back the way they were before bootstrap
+	Services       []string      `json:"services"`       // Units stopped and removed, with their current state
+	AzureResources []string      `json:"azureResources"` // Resources deleted from Azure
+	ClusterObjects []string      `json:"clusterObjects"` // Objects in the cluster affected by the node going away
+}
+
+// PlanUnbootstrap returns what Unbootstrap would remove, without changing the node, Azure or the cluster
+func (b *Bootstrapper) PlanUnbootstrap(ctx context.Context) *RemovalPlan {
+	cfg := b.config
+	plan := &RemovalPlan{}
+
+	restored := map[string]bool{}
+	entries, err := backupEntries()
+	if err != nil {
+		b.logger.Warnf("Failed to read the backed up host files: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Existed {
+			plan.RestoredFiles = append(plan.RestoredFiles, entry.Path)
+			restored[entry.Path] = true
+		}
+	}
+
+	units := []string{services.KubeletService, container_runtime.ForConfig(cfg).ServiceName()}
+	for _, step := range b.bootstrapSteps() {
+		owner, ok := step.(FileOwner)
+		if !ok {
+			continue
+		}
+		for _, path := range owner.ManagedFiles() {
+			if restored[path] || !fileExists(path) {
+				continue
+			}
+			plan.Files = append(plan.Files, PlannedFile{Step: step.GetName(), Path: path})
+			if filepath.Dir(path) == systemdSystemDir && strings.HasSuffix(path, ".service") {
+				units = append(units, strings.TrimSuffix(filepath.Base(path), ".service"))
+			}
+		}
+	}
+	if cfg.IsARCEnabled() {
+		units = append(units, arc.AgentServices()...)
+	}
+	plan.Services = unitStates(units)
+
+	plan.AzureResources = arc.NewUnInstaller(cfg, b.logger).PlannedRemovals(ctx)
+	plan.ClusterObjects = b.affectedClusterObjects()
+	return plan
+}
+
+// unitStates describes each unit that is running or enabled, once, in the given order
+func unitStates(units []string) []string {
+	var states []string
+	var seen []string
+	for _, unit := range units {
+		if slices.Contains(seen, unit) {
+			continue
+		}
+		seen = append(seen, unit)
+		switch {
+		case serviceActive(unit):
+			states = append(states, unit+" (running)")
+		case serviceOn(unit):
+			states = append(states, unit+" (enabled, not running)")
+		}
+	}
+	return states
+}
+
+// affectedClusterObjects describes what happens to the node's objects in the cluster. Unbootstrap does not
+// delete the Node object, it only stops the kubelet behind it.
+func (b *Bootstrapper) affectedClusterObjects() []string {
+	node := "this machine"
+	if name, err := hostname(); err == nil {
+		node = strings.ToLower(name)
+	}
+	objects := []string{
+		fmt.Sprintf("Node %s turns NotReady and stays registered until it is deleted with: kubectl delete node %s", node, node),
+		fmt.Sprintf("Lease kube-node-lease/%s is no longer renewed", node),
+		"DaemonSet pods on the node stop and are not rescheduled elsewhere",
+	}
+	for _, pod := range b.config.StaticPods {
+		objects = append(objects, fmt.Sprintf("Static pod %s and its mirror pod are removed", pod.Name))
+	}
+	return objects
+}
+
+// Lines describes the plan section by section, for printing
+func (p *RemovalPlan) Lines() []string {
+	var lines []string
+	section := func(title string, items []string) {
+		lines = append(lines, fmt.Sprintf("%s (%d):", title, len(items)))
+		if len(items) == 0 {
+			lines = append(lines, "  none")
+		}
+		for _, item := range items {
+			lines = append(lines, "  "+item)
+		}
+	}
+	files := make([]string, len(p.Files))
+	for i, file := range p.Files {
+		files[i] = fmt.Sprintf("%s (%s)", file.Path, file.Step)
+	}
+	section("Files removed", files)
+	section("Files restored from backup", p.RestoredFiles)
+	section("Services stopped and removed", p.Services)
+	section("Azure resources deleted", p.AzureResources)
+	section("Cluster objects affected", p.ClusterObjects)
+	return lines
+}
@@ -0,0 +1,61 @@
+package bootstrapper
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
+)
+
+func TestPlanUnbootstrap(t *testing.T) {
+	origFileExists, origServiceActive, origServiceOn, origBackupEntries, origHostname := fileExists, serviceActive, serviceOn, backupEntries, hostname
+	defer func() {
+		fileExists, serviceActive, serviceOn, backupEntries, hostname = origFileExists, origServiceActive, origServiceOn, origBackupEntries, origHostname
+	}()
+
+	installed := map[string]bool{
+		"/etc/systemd/system/containerd.service": true,
+		"/etc/containerd/config.toml":            true,
+	}
+	fileExists = func(path string) bool { return installed[path] }
+	serviceActive = func(unit string) bool { return unit == "containerd" }
+	serviceOn = func(unit string) bool { return unit == "kubelet" }
+	backupEntries = func() ([]filebackup.Entry, error) {
+		return []filebackup.Entry{
+			{Path: "/etc/containerd/config.toml", Existed: true},
+			{Path: "/etc/sysctl.d/99-aks-flex-node.conf"},
+		}, nil
+	}
+	hostname = func() (string, error) { return "Edge-01", nil }
+
+	cfg := &config.Config{StaticPods: []config.StaticPodConfig{{Name: "edge-proxy"}}}
+	plan := New(cfg, logrus.New()).PlanUnbootstrap(context.Background())
+
+	if want := []PlannedFile{{Step: "ContainerdInstaller", Path: "/etc/systemd/system/containerd.service"}}; !reflect.DeepEqual(plan.Files, want) {
+		t.Errorf("Files = %v, want %v", plan.Files, want)
+	}
+	if want := []string{"/etc/containerd/config.toml"}; !reflect.DeepEqual(plan.RestoredFiles, want) {
+		t.Errorf("RestoredFiles = %v, want %v", plan.RestoredFiles, want)
+	}
+	if want := []string{"kubelet (enabled, not running)", "containerd (running)"}; !reflect.DeepEqual(plan.Services, want) {
+		t.Errorf("Services = %v, want %v", plan.Services, want)
+	}
+	if len(plan.AzureResources) != 0 {
+		t.Errorf("AzureResources = %v, want none without Arc", plan.AzureResources)
+	}
+	if !strings.Contains(plan.ClusterObjects[0], "kubectl delete node edge-01") {
+		t.Errorf("ClusterObjects[0] = %q, want the node deletion hint", plan.ClusterObjects[0])
+	}
+	if !slices.Contains(plan.ClusterObjects, "Static pod edge-proxy and its mirror pod are removed") {
+		t.Errorf("ClusterObjects = %v, want the static pod", plan.ClusterObjects)
+	}
+	if lines := plan.Lines(); !slices.Contains(lines, "Azure resources deleted (0):") || !slices.Contains(lines, "  none") {
+		t.Errorf("Lines() = %v, want an empty Azure section", lines)
+	}
+}
@@ -0,0 +1,157 @@
+package bootstrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ReportPath holds the report of the last bootstrap, so operators can compare sites and spot slow steps
+const ReportPath = "/var/lib/aks-flex-node/bootstrap-report.json"
+
+// Replaced in tests
+var reportPath = ReportPath
+
+// Step results in a report
+const (
+	StepSucceeded = "succeeded"
+	StepSkipped   = "skipped" // Already completed, so the step did not run
+	StepFailed    = "failed"
+)
+
+// Report summarizes where the time of a bootstrap went, step by step
+type Report struct {
+	FinishedAt      time.Time    `json:"finishedAt"`
+	Success         bool         `json:"success"`
+	DurationSeconds float64      `json:"durationSeconds"`
+	Steps           []StepReport `json:"steps"`
+}
+
+// StepReport is one row of a Report
+type StepReport struct {
+	Step            string  `json:"step"`
+	Result          string  `json:"result"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Retries         int64   `json:"retries"`
+	DownloadedBytes int64   `json:"downloadedBytes"`
+	KnownIssue      string  `json:"knownIssue,omitempty"` // ID of the known issue a failed step ran into
+}
+
+// NewReport builds the report of a bootstrap from its result
+func NewReport(result *ExecutionResult, finishedAt time.Time) *Report {
+	report := &Report{
+		FinishedAt:      finishedAt.UTC(),
+		Success:         result.Success,
+		DurationSeconds: result.Duration.Seconds(),
+		Steps:           make([]StepReport, 0, len(result.StepResults)),
+	}
+	for _, step := range result.StepResults {
+		outcome, knownIssue := StepSucceeded, ""
+		switch {
+		case !step.Success:
+			outcome = StepFailed
+			knownIssue = messages.KnownIssue(step.Error)
+		case step.Skipped:
+			outcome = StepSkipped
+		}
+		report.Steps = append(report.Steps, StepReport{
+			Step:            step.StepName,
+			Result:          outcome,
+			DurationSeconds: step.Duration.Seconds(),
+			Retries:         step.Retries,
+			DownloadedBytes: step.DownloadedBytes,
+			KnownIssue:      knownIssue,
+		})
+	}
+	return report
+}
+
+// WriteTable writes the report as a table with a row per step, followed by the totals
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tDURATION\tRETRIES\tDOWNLOADED\tRESULT")
+	var retries, downloaded int64
+	for _, step := range r.Steps {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", step.Step, formatSeconds(step.DurationSeconds), step.Retries, formatBytes(step.DownloadedBytes), step.Result)
+		retries += step.Retries
+		downloaded += step.DownloadedBytes
+	}
+	result := StepSucceeded
+	if !r.Success {
+		result = StepFailed
+	}
+	fmt.Fprintf(tw, "TOTAL\t%s\t%d\t%s\t%s\n", formatSeconds(r.DurationSeconds), retries, formatBytes(downloaded), result)
+	return tw.Flush()
+}
+
+// report logs the report of a bootstrap that ran steps and saves it for the node status
+func (b *Bootstrapper) report(result *ExecutionResult) {
+	if result == nil || len(result.StepResults) == 0 {
+		return
+	}
+	report := NewReport(result, time.Now())
+	var table strings.Builder
+	if err := report.WriteTable(&table); err == nil {
+		b.logger.Infof("Bootstrap summary:\n%s", table.String())
+	}
+	if err := SaveReport(report); err != nil {
+		b.logger.Warnf("Failed to save the bootstrap report: %v", err)
+	}
+}
+
+// SaveReport writes r to ReportPath, replacing the report of the previous bootstrap
+func SaveReport(r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bootstrap report: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(reportPath)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(reportPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", reportPath, err)
+	}
+	return nil
+}
+
+// LoadReport returns the report of the last bootstrap, or nil if none was saved
+func LoadReport() (*Report, error) {
+	data, err := os.ReadFile(reportPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", reportPath, err)
+	}
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", reportPath, err)
+	}
+	return report, nil
+}
+
+func formatSeconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
+
+// formatBytes formats n in binary units, e.g. 41.3 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
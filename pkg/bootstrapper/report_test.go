@@ -0,0 +1,82 @@
+package bootstrapper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReport(t *testing.T) {
+	result := &ExecutionResult{
+		Success:  false,
+		Duration: 95 * time.Second,
+		StepResults: []StepResult{
+			{StepName: "PreflightChecks", Success: true, Skipped: true, Duration: 10 * time.Millisecond},
+			{StepName: "KubeBinariesInstaller", Success: true, Duration: 80 * time.Second, Retries: 2, DownloadedBytes: 3 << 20},
+			{StepName: "KubeletInstaller", Success: false, Duration: 15 * time.Second, Retries: 5, Error: "unknown cgroup driver cgroupfs2"},
+		},
+	}
+	report := NewReport(result, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	var results []string
+	for _, step := range report.Steps {
+		results = append(results, step.Result)
+	}
+	if want := []string{StepSkipped, StepSucceeded, StepFailed}; !reflect.DeepEqual(results, want) {
+		t.Errorf("step results = %v, want %v", results, want)
+	}
+	if got := report.Steps[2].KnownIssue; got != "cgroup-driver" {
+		t.Errorf("KnownIssue of the failed step = %q, want cgroup-driver", got)
+	}
+
+	var table strings.Builder
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatalf("WriteTable() unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("WriteTable() wrote %d lines, want a header, 3 steps and the total:\n%s", len(lines), table.String())
+	}
+	for i, want := range [][]string{
+		{"STEP", "DURATION", "RETRIES", "DOWNLOADED", "RESULT"},
+		{"KubeBinariesInstaller", "1m20s", "2", "3.0", "MiB", "succeeded"},
+		{"TOTAL", "1m35s", "7", "3.0", "MiB", "failed"},
+	} {
+		line := lines[[]int{0, 2, 4}[i]]
+		if got := strings.Fields(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("table row = %q, want %v", line, want)
+		}
+	}
+}
+
+func TestLoadReport(t *testing.T) {
+	original := reportPath
+	defer func() { reportPath = original }()
+	reportPath = filepath.Join(t.TempDir(), "bootstrap-report.json")
+
+	if report, err := LoadReport(); err != nil || report != nil {
+		t.Errorf("LoadReport() without a report = %v, %v, want nil", report, err)
+	}
+	data := `{"finishedAt":"2026-01-02T03:04:05Z","success":true,"durationSeconds":12.5,"steps":[{"step":"ArcInstall","result":"succeeded","durationSeconds":12.5,"retries":1,"downloadedBytes":0}]}`
+	if err := os.WriteFile(reportPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := LoadReport()
+	if err != nil {
+		t.Fatalf("LoadReport() unexpected error: %v", err)
+	}
+	if !report.Success || len(report.Steps) != 1 || report.Steps[0].Retries != 1 {
+		t.Errorf("LoadReport() = %+v", report)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
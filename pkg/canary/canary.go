@@ -0,0 +1,175 @@
+// Package canary verifies the node after its components changed by running a smoke pod pinned to it. The pod
+// needs runc to start its container, the CNI to get an address, and the pod network and cluster DNS to resolve
+// the API server's service name, so an upgrade that broke any of them fails instead of being reported as done.
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+const (
+	// podPrefix is the name prefix of smoke pods, followed by the node name
+	podPrefix = "aks-flex-node-canary-"
+
+	// serviceName is resolved by the smoke pod through the cluster DNS
+	serviceName = "kubernetes.default.svc"
+
+	pollInterval = 5 * time.Second
+)
+
+// Canary runs the smoke pod with kubectl
+type Canary struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	kubectl      func(ctx context.Context, args ...string) (string, error)
+	hostname     func() (string, error)
+	clock        retry.Clock
+	pollInterval time.Duration
+}
+
+// New creates a canary running kubectl with the configured kubeconfig
+func New(cfg *config.Config, logger *logrus.Logger) *Canary {
+	return &Canary{
+		config: cfg,
+		logger: logger,
+		kubectl: func(ctx context.Context, args ...string) (string, error) {
+			// The canary kubeconfig is the agent's own credential, so kubectl runs as the agent user without sudo
+			output, err := exec.CommandContext(ctx, "kubectl", args...).CombinedOutput()
+			return string(output), err
+		},
+		hostname:     os.Hostname,
+		clock:        retry.RealClock,
+		pollInterval: pollInterval,
+	}
+}
+
+// Verify runs the smoke pod on the node and returns an error unless it completes its checks within the
+// configured timeout. The pod is deleted afterwards either way.
+func (c *Canary) Verify(ctx context.Context) error {
+	hostname, err := c.hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine node name: %w", err)
+	}
+	// The kubelet registers the node under its lower-cased hostname
+	node := strings.ToLower(hostname)
+	pod := podName(node)
+
+	// A pod left over from an interrupted run would make creating the new one fail
+	c.delete(ctx, pod)
+	defer c.delete(context.WithoutCancel(ctx), pod)
+
+	c.logger.Infof("Running smoke pod %s/%s on node %s", c.config.GetCanaryNamespace(), pod, node)
+	overrides, err := json.Marshal(podOverrides(node))
+	if err != nil {
+		return fmt.Errorf("failed to encode smoke pod: %w", err)
+	}
+	output, err := c.run(ctx, "run", pod, "--image", c.config.GetCanaryImage(), "--restart=Never",
+		"--labels", "app.kubernetes.io/managed-by=aks-flex-node", "--overrides", string(overrides),
+		"--command", "--", "sh", "-c", "nslookup "+serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to create smoke pod: %w: %s", err, strings.TrimSpace(output))
+	}
+
+	status, err := c.wait(ctx, pod)
+	if err != nil {
+		return err
+	}
+	if status.phase != "Succeeded" {
+		logs, _ := c.run(ctx, "logs", pod, "--tail=20")
+		return fmt.Errorf("smoke pod %s on node %s %s: %s", pod, node, status, strings.TrimSpace(logs))
+	}
+	c.logger.Infof("✅ Smoke pod ran on node %s and resolved %s", node, serviceName)
+	return nil
+}
+
+// podStatus is the part of a pod's status that tells why it has not finished
+type podStatus struct {
+	phase  string
+	reason string // Reason the container is waiting, e.g. ContainerCreating or ImagePullBackOff
+}
+
+func (s podStatus) String() string {
+	switch {
+	case s.phase == "Failed":
+		return "failed"
+	case s.reason != "":
+		return fmt.Sprintf("did not finish: %s (%s)", s.phase, s.reason)
+	case s.phase != "":
+		return fmt.Sprintf("did not finish: %s", s.phase)
+	}
+	return "was not found"
+}
+
+// wait polls the pod until it finished or the configured timeout ran out, and returns its last status
+func (c *Canary) wait(ctx context.Context, pod string) (podStatus, error) {
+	deadline := c.clock.Now().Add(c.config.GetCanaryTimeout())
+	var status podStatus
+	for {
+		output, err := c.run(ctx, "get", "pod", pod, "-o",
+			"jsonpath={.status.phase} {.status.containerStatuses[0].state.waiting.reason}")
+		if err != nil {
+			c.logger.Debugf("Failed to get smoke pod %s: %v: %s", pod, err, strings.TrimSpace(output))
+		} else {
+			fields := strings.Fields(output)
+			status = podStatus{}
+			if len(fields) > 0 {
+				status.phase = fields[0]
+			}
+			if len(fields) > 1 {
+				status.reason = fields[1]
+			}
+		}
+		if status.phase == "Succeeded" || status.phase == "Failed" || !c.clock.Now().Add(c.pollInterval).Before(deadline) {
+			return status, nil
+		}
+		if err := retry.Sleep(ctx, c.clock, c.pollInterval); err != nil {
+			return status, err
+		}
+	}
+}
+
+func (c *Canary) delete(ctx context.Context, pod string) {
+	if output, err := c.run(ctx, "delete", "pod", pod, "--ignore-not-found", "--wait=false"); err != nil {
+		c.logger.Warnf("Failed to delete smoke pod %s: %v: %s", pod, err, strings.TrimSpace(output))
+	}
+}
+
+func (c *Canary) run(ctx context.Context, args ...string) (string, error) {
+	args = append([]string{"--kubeconfig", c.config.Agent.Canary.Kubeconfig, "--namespace", c.config.GetCanaryNamespace()}, args...)
+	return c.kubectl(ctx, args...)
+}
+
+// podName returns the name of the node's smoke pod, within the 63 characters of a pod name label
+func podName(node string) string {
+	name := podPrefix + node
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-.")
+	}
+	return name
+}
+
+// podOverrides pins the smoke pod to node, tolerating its taints so it also runs on a cordoned or tainted node,
+// and keeps it from mounting a service account token it does not need
+func podOverrides(node string) map[string]any {
+	return map[string]any{
+		"apiVersion": "v1",
+		"spec": map[string]any{
+			"nodeSelector":                  map[string]string{"kubernetes.io/hostname": node},
+			"tolerations":                   []map[string]string{{"operator": "Exists"}},
+			"automountServiceAccountToken":  false,
+			"terminationGracePeriodSeconds": 0,
+		},
+	}
+}
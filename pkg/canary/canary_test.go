@@ -0,0 +1,102 @@
+package canary
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+// fakeKubectl answers kubectl get with the statuses in order, repeating the last one, and records the commands
+type fakeKubectl struct {
+	statuses []string
+	commands []string
+}
+
+func (f *fakeKubectl) run(ctx context.Context, args ...string) (string, error) {
+	// Drop --kubeconfig and --namespace with their values
+	command := strings.Join(args[4:], " ")
+	f.commands = append(f.commands, command)
+	switch args[4] {
+	case "get":
+		status := f.statuses[0]
+		if len(f.statuses) > 1 {
+			f.statuses = f.statuses[1:]
+		}
+		return status, nil
+	case "logs":
+		return "nslookup: can't resolve 'kubernetes.default.svc'", nil
+	}
+	return "", nil
+}
+
+func newTestCanary(kubectl *fakeKubectl) *Canary {
+	cfg := &config.Config{}
+	cfg.Agent.Canary = config.CanaryConfig{Enabled: true, Kubeconfig: "/etc/canary.kubeconfig", Timeout: "1m"}
+	return &Canary{
+		config:       cfg,
+		logger:       logrus.New(),
+		kubectl:      kubectl.run,
+		hostname:     func() (string, error) { return "Edge-Node-1", nil },
+		clock:        retry.NewFakeClock(time.Unix(0, 0)),
+		pollInterval: 10 * time.Second,
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		wantErr  string
+		wantGets int
+	}{
+		{name: "succeeds", statuses: []string{"Pending ContainerCreating", "Running ", "Succeeded "}, wantGets: 3},
+		{name: "DNS fails", statuses: []string{"Failed "}, wantErr: "failed: nslookup: can't resolve", wantGets: 1},
+		{name: "sandbox never starts", statuses: []string{"Pending ContainerCreating"}, wantErr: "did not finish: Pending (ContainerCreating)", wantGets: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubectl := &fakeKubectl{statuses: tt.statuses}
+			err := newTestCanary(kubectl).Verify(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Verify() unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() error = %v, want containing %q", err, tt.wantErr)
+			}
+
+			gets := 0
+			for _, command := range kubectl.commands {
+				if strings.HasPrefix(command, "get ") {
+					gets++
+				}
+			}
+			if gets != tt.wantGets {
+				t.Errorf("polled the pod %d times, want %d", gets, tt.wantGets)
+			}
+			if first := kubectl.commands[0]; first != "delete pod aks-flex-node-canary-edge-node-1 --ignore-not-found --wait=false" {
+				t.Errorf("first command = %q, want deleting a leftover pod", first)
+			}
+			if last := kubectl.commands[len(kubectl.commands)-1]; !strings.HasPrefix(last, "delete pod aks-flex-node-canary-edge-node-1") {
+				t.Errorf("last command = %q, want deleting the pod", last)
+			}
+			if run := kubectl.commands[1]; !strings.Contains(run, `"kubernetes.io/hostname":"edge-node-1"`) {
+				t.Errorf("smoke pod is not pinned to the node: %s", run)
+			}
+		})
+	}
+}
+
+func TestPodName(t *testing.T) {
+	name := podName(strings.Repeat("a", 41) + "-" + strings.Repeat("b", 40))
+	if len(name) > 63 || strings.HasSuffix(name, "-") {
+		t.Errorf("podName() = %q, want at most 63 characters not ending in '-'", name)
+	}
+}
@@ -0,0 +1,58 @@
+// Package cgroup detects the cgroup hierarchy the host booted with. Kubelet and the container runtime must
+// use the same cgroup driver, and Kubernetes drops support for the legacy (v1) hierarchy, so bootstrap
+// aligns the drivers and warns about hosts that still need to move to the unified (v2) hierarchy.
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Mode is the cgroup hierarchy the host is running with
+type Mode string
+
+const (
+	// ModeUnified is cgroup v2: a single hierarchy mounted at /sys/fs/cgroup
+	ModeUnified Mode = "v2"
+	// ModeHybrid mounts the v1 controllers at /sys/fs/cgroup and an empty v2 hierarchy at /sys/fs/cgroup/unified
+	ModeHybrid Mode = "hybrid"
+	// ModeLegacy is cgroup v1 only
+	ModeLegacy Mode = "v1"
+)
+
+// Cgroup drivers of kubelet and the container runtimes
+const (
+	DriverSystemd  = "systemd"
+	DriverCgroupfs = "cgroupfs"
+)
+
+// KernelParameter moves systemd to the unified hierarchy when added to the kernel command line
+const KernelParameter = "systemd.unified_cgroup_hierarchy=1"
+
+// root is where the cgroup hierarchy is mounted; replaced in tests
+var root = "/sys/fs/cgroup"
+
+// Detect returns the cgroup mode of the running host
+func Detect() (Mode, error) {
+	if _, err := os.Stat(root); err != nil {
+		return "", fmt.Errorf("cgroup hierarchy is not mounted at %s: %w", root, err)
+	}
+	if fileExists(filepath.Join(root, "cgroup.controllers")) {
+		return ModeUnified, nil
+	}
+	if fileExists(filepath.Join(root, "unified", "cgroup.controllers")) {
+		return ModeHybrid, nil
+	}
+	return ModeLegacy, nil
+}
+
+// IsUnified reports whether the host runs the unified (v2) hierarchy
+func (m Mode) IsUnified() bool {
+	return m == ModeUnified
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
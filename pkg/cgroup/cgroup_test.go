@@ -0,0 +1,48 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    Mode
+		wantErr bool
+	}{
+		{name: "unified", files: []string{"cgroup.controllers"}, want: ModeUnified},
+		{name: "hybrid", files: []string{"cpu/tasks", "unified/cgroup.controllers"}, want: ModeHybrid},
+		{name: "legacy", files: []string{"cpu/tasks"}, want: ModeLegacy},
+		{name: "not mounted", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			original := root
+			root = filepath.Join(dir, "cgroup")
+			t.Cleanup(func() { root = original })
+
+			for _, file := range tt.files {
+				path := filepath.Join(root, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Detect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Detect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
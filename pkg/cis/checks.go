@@ -0,0 +1,201 @@
+package cis
+
+import (
+	"strings"
+	"time"
+)
+
+// check is one benchmark recommendation
+type check struct {
+	id, title, remediation string
+	run                    func(n *node) (Status, string)
+}
+
+// fixFlag is the remediation of a kubelet flag installed by bootstrap
+const fixFlag = "set it in KUBELET_FLAGS in /etc/default/kubelet, through an override of the kubelet-defaults template"
+
+// strongCiphers are the cipher suites the benchmark accepts
+var strongCiphers = map[string]bool{
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       true,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         true,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":        true,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": true,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         true,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":          true,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   true,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       true,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               true,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               true,
+}
+
+// checks are the automated checks of the benchmark's worker node section; checks that are manual or about
+// kube-proxy, which does not run from a file on the node, are left out
+var checks = []check{
+	{
+		id: "4.1.1", title: "kubelet service file permissions are 600 or more restrictive",
+		remediation: "chmod 600 the kubelet unit and its drop-ins",
+		run:         func(n *node) (Status, string) { return n.checkPermissions(serviceFiles()...) },
+	},
+	{
+		id: "4.1.2", title: "kubelet service file ownership is root:root",
+		remediation: "chown root:root the kubelet unit and its drop-ins",
+		run:         func(n *node) (Status, string) { return n.checkOwnership(serviceFiles()...) },
+	},
+	{
+		id: "4.1.5", title: "kubelet kubeconfig permissions are 600 or more restrictive",
+		remediation: "chmod 600 the kubeconfig passed to --kubeconfig",
+		run:         func(n *node) (Status, string) { return n.checkPermissions(n.kubeconfig()) },
+	},
+	{
+		id: "4.1.6", title: "kubelet kubeconfig ownership is root:root",
+		remediation: "chown root:root the kubeconfig passed to --kubeconfig",
+		run:         func(n *node) (Status, string) { return n.checkOwnership(n.kubeconfig()) },
+	},
+	{
+		id: "4.1.7", title: "client certificate authorities file permissions are 600 or more restrictive",
+		remediation: "chmod 600 the file passed to --client-ca-file",
+		run:         func(n *node) (Status, string) { return n.checkPermissions(n.flags["client-ca-file"]) },
+	},
+	{
+		id: "4.1.8", title: "client certificate authorities file ownership is root:root",
+		remediation: "chown root:root the file passed to --client-ca-file",
+		run:         func(n *node) (Status, string) { return n.checkOwnership(n.flags["client-ca-file"]) },
+	},
+	{
+		id: "4.1.9", title: "kubelet configuration file permissions are 600 or more restrictive",
+		remediation: "chmod 600 the file passed to --config",
+		run:         func(n *node) (Status, string) { return n.checkPermissions(n.flags["config"]) },
+	},
+	{
+		id: "4.1.10", title: "kubelet configuration file ownership is root:root",
+		remediation: "chown root:root the file passed to --config",
+		run:         func(n *node) (Status, string) { return n.checkOwnership(n.flags["config"]) },
+	},
+	{
+		id: "4.2.1", title: "--anonymous-auth is false",
+		remediation: "Add --anonymous-auth=false; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			// Anonymous requests are allowed unless turned off
+			value, _ := n.flag("anonymous-auth")
+			return pass(isFalse(value)), n.describe("anonymous-auth")
+		},
+	},
+	{
+		id: "4.2.2", title: "--authorization-mode is not AlwaysAllow",
+		remediation: "Add --authorization-mode=Webhook; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			// The flag defaults to AlwaysAllow
+			value, ok := n.flag("authorization-mode")
+			return pass(ok && !containsItem(value, "AlwaysAllow")), n.describe("authorization-mode")
+		},
+	},
+	{
+		id: "4.2.3", title: "--client-ca-file is set",
+		remediation: "Add --client-ca-file with the cluster CA, so the API server can authenticate to the kubelet with its client certificate; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			value, _ := n.flag("client-ca-file")
+			return pass(value != ""), n.describe("client-ca-file")
+		},
+	},
+	{
+		id: "4.2.4", title: "--read-only-port is 0",
+		remediation: "Add --read-only-port=0; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			// The flag defaults to 10255
+			value, _ := n.flag("read-only-port")
+			return pass(value == "0"), n.describe("read-only-port")
+		},
+	},
+	{
+		id: "4.2.5", title: "--streaming-connection-idle-timeout is not 0",
+		remediation: "Set --streaming-connection-idle-timeout to a duration such as 4h; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			value, ok := n.flag("streaming-connection-idle-timeout")
+			d, err := time.ParseDuration(value)
+			return pass(!ok || (err == nil && d > 0)), n.describe("streaming-connection-idle-timeout")
+		},
+	},
+	{
+		id: "4.2.6", title: "--make-iptables-util-chains is true",
+		remediation: "Remove --make-iptables-util-chains=false; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			value, _ := n.flag("make-iptables-util-chains")
+			return pass(!isFalse(value)), n.describe("make-iptables-util-chains")
+		},
+	},
+	{
+		id: "4.2.7", title: "--hostname-override is not set",
+		remediation: "Remove --hostname-override; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			_, ok := n.flag("hostname-override")
+			return pass(!ok), n.describe("hostname-override")
+		},
+	},
+	{
+		id: "4.2.9", title: "the kubelet serves a trusted certificate",
+		remediation: "Add --tls-cert-file and --tls-private-key-file, or --rotate-server-certificates=true; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			cert, _ := n.flag("tls-cert-file")
+			key, _ := n.flag("tls-private-key-file")
+			rotate, _ := n.flag("rotate-server-certificates")
+			if cert != "" && key != "" {
+				return Pass, n.describe("tls-cert-file") + ", " + n.describe("tls-private-key-file")
+			}
+			return pass(rotate == "true"), n.describe("rotate-server-certificates")
+		},
+	},
+	{
+		id: "4.2.10", title: "--rotate-certificates is not false",
+		remediation: "Add --rotate-certificates=true; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			value, _ := n.flag("rotate-certificates")
+			return pass(!isFalse(value)), n.describe("rotate-certificates")
+		},
+	},
+	{
+		id: "4.2.11", title: "the RotateKubeletServerCertificate feature gate is not turned off",
+		remediation: "Remove RotateKubeletServerCertificate=false from --feature-gates; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			value, _ := n.flag("feature-gates.RotateKubeletServerCertificate")
+			return pass(!isFalse(value)), n.describe("feature-gates")
+		},
+	},
+	{
+		id: "4.2.12", title: "the kubelet only uses strong cipher suites",
+		remediation: "Set --tls-cipher-suites to strong cipher suites only; " + fixFlag,
+		run: func(n *node) (Status, string) {
+			value, ok := n.flag("tls-cipher-suites")
+			if !ok {
+				// Go's defaults include CBC and 3DES suites
+				return Fail, n.describe("tls-cipher-suites")
+			}
+			var weak []string
+			for _, suite := range strings.Split(value, ",") {
+				if suite = strings.TrimSpace(suite); !strongCiphers[suite] {
+					weak = append(weak, suite)
+				}
+			}
+			if len(weak) > 0 {
+				return Fail, "weak cipher suites: " + strings.Join(weak, ", ")
+			}
+			return Pass, "--tls-cipher-suites has only strong cipher suites"
+		},
+	},
+}
+
+func pass(ok bool) Status {
+	if ok {
+		return Pass
+	}
+	return Fail
+}
+
+// containsItem reports whether a comma-separated list holds item
+func containsItem(list, item string) bool {
+	for _, value := range strings.Split(list, ",") {
+		if strings.TrimSpace(value) == item {
+			return true
+		}
+	}
+	return false
+}
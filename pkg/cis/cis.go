@@ -0,0 +1,212 @@
+// Package cis assesses the node against the automated worker node checks of the CIS Kubernetes Benchmark:
+// the permissions and ownership of the kubelet's files and the kubelet's authentication, authorization
+// and TLS settings. It reads the installed configuration and changes nothing.
+package cis
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Benchmark is the benchmark the checks are taken from
+const Benchmark = "CIS Kubernetes Benchmark v1.9.0, worker node security configuration"
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Fail Status = "FAIL"
+	Skip Status = "SKIP" // Not applicable to this node, e.g. the file the check is about is not used
+)
+
+// Result is the outcome of one benchmark check
+type Result struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Status      Status `json:"status"`
+	Detail      string `json:"detail"`
+	Remediation string `json:"remediation,omitempty"` // Set for failed checks
+}
+
+// Report is the scored outcome of all checks
+type Report struct {
+	Benchmark  string    `json:"benchmark"`
+	AssessedAt time.Time `json:"assessedAt"`
+	FlagSource string    `json:"flagSource"` // Where the kubelet's flags were read from
+	Results    []Result  `json:"results"`
+	Passed     int       `json:"passed"`
+	Failed     int       `json:"failed"`
+	Skipped    int       `json:"skipped"`
+	Score      int       `json:"score"` // Percentage of the applicable checks that passed
+}
+
+// node is what the checks inspect
+type node struct {
+	flags map[string]string
+	stat  func(path string) (fileInfo, error)
+}
+
+// fileInfo is the mode and owner of a file
+type fileInfo struct {
+	mode     fs.FileMode
+	uid, gid uint32
+}
+
+// Assess runs the checks against the installed kubelet
+func Assess() (*Report, error) {
+	flags, source, err := KubeletFlags()
+	if err != nil {
+		return nil, err
+	}
+	report := assess(&node{flags: flags, stat: statFile})
+	report.FlagSource = source
+	return report, nil
+}
+
+func assess(n *node) *Report {
+	report := &Report{Benchmark: Benchmark, AssessedAt: time.Now().UTC()}
+	for _, c := range checks {
+		status, detail := c.run(n)
+		result := Result{ID: c.id, Title: c.title, Status: status, Detail: detail}
+		switch status {
+		case Pass:
+			report.Passed++
+		case Fail:
+			report.Failed++
+			result.Remediation = c.remediation
+		default:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, result)
+	}
+	if applicable := report.Passed + report.Failed; applicable > 0 {
+		report.Score = report.Passed * 100 / applicable
+	}
+	return report
+}
+
+// WriteText writes the report as a table followed by the remediation of each failed check
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%s\nKubelet flags read from %s\n\n", r.Benchmark, r.FlagSource)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tCHECK\tDETAIL")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.ID, result.Status, result.Title, result.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var remediations []string
+	for _, result := range r.Results {
+		if result.Status == Fail {
+			remediations = append(remediations, fmt.Sprintf("  %s: %s", result.ID, result.Remediation))
+		}
+	}
+	if len(remediations) > 0 {
+		fmt.Fprintf(w, "\nRemediation:\n%s\n", strings.Join(remediations, "\n"))
+	}
+	_, err := fmt.Fprintf(w, "\nScore: %d%% (%d passed, %d failed, %d skipped)\n", r.Score, r.Passed, r.Failed, r.Skipped)
+	return err
+}
+
+func statFile(path string) (fileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileInfo{}, err
+	}
+	result := fileInfo{mode: info.Mode().Perm()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		result.uid, result.gid = st.Uid, st.Gid
+	}
+	return result, nil
+}
+
+// serviceFiles returns the kubelet unit and its drop-ins
+func serviceFiles() []string {
+	dropIns, _ := filepath.Glob(filepath.Join(kubeletDropIns, "*.conf"))
+	return append([]string{kubeletUnit}, dropIns...)
+}
+
+// kubeconfig returns the kubelet's kubeconfig
+func (n *node) kubeconfig() string {
+	if path := n.flags["kubeconfig"]; path != "" {
+		return path
+	}
+	return "/var/lib/kubelet/kubeconfig"
+}
+
+// checkPermissions fails when a file can be written by its group or read or written by others, i.e. is not
+// 600 or more restrictive. Missing files are skipped.
+func (n *node) checkPermissions(paths ...string) (Status, string) {
+	return n.checkFiles(paths, func(path string, info fileInfo) string {
+		if info.mode&0o177 != 0 {
+			return fmt.Sprintf("%s has mode %04o", path, info.mode)
+		}
+		return ""
+	})
+}
+
+// checkOwnership fails when a file is not owned by root:root. Missing files are skipped.
+func (n *node) checkOwnership(paths ...string) (Status, string) {
+	return n.checkFiles(paths, func(path string, info fileInfo) string {
+		if info.uid != 0 || info.gid != 0 {
+			return fmt.Sprintf("%s is owned by %d:%d", path, info.uid, info.gid)
+		}
+		return ""
+	})
+}
+
+func (n *node) checkFiles(paths []string, problem func(string, fileInfo) string) (Status, string) {
+	var checked, problems []string
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		info, err := n.stat(path)
+		if err != nil {
+			continue
+		}
+		checked = append(checked, path)
+		if p := problem(path, info); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	switch {
+	case len(checked) == 0:
+		return Skip, "file not present"
+	case len(problems) > 0:
+		return Fail, strings.Join(problems, "; ")
+	default:
+		return Pass, strings.Join(checked, ", ")
+	}
+}
+
+// flag returns the value of a kubelet flag and whether it is set
+func (n *node) flag(name string) (string, bool) {
+	value, ok := n.flags[name]
+	return value, ok
+}
+
+// describe returns "--name=value", or "--name not set"
+func (n *node) describe(name string) string {
+	if value, ok := n.flag(name); ok {
+		return "--" + name + "=" + value
+	}
+	return "--" + name + " not set"
+}
+
+// isFalse reports whether a boolean flag value is false
+func isFalse(value string) bool {
+	b, err := strconv.ParseBool(value)
+	return err == nil && !b
+}
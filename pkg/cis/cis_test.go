@@ -0,0 +1,153 @@
+package cis
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	got := parseFlags([]string{
+		"--enable-server", "--kubeconfig", "/var/lib/kubelet/kubeconfig", "--read-only-port=0",
+		"--feature-gates=RotateKubeletServerCertificate=false,Foo=true", "-v=2",
+	})
+	want := map[string]string{
+		"enable-server":  "true",
+		"kubeconfig":     "/var/lib/kubelet/kubeconfig",
+		"read-only-port": "0",
+		"feature-gates":  "RotateKubeletServerCertificate=false,Foo=true",
+		"feature-gates.RotateKubeletServerCertificate": "false",
+		"feature-gates.Foo":                            "true",
+		"v":                                            "2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFlags() = %v, want %v", got, want)
+	}
+}
+
+func TestUnitKubeletArgs(t *testing.T) {
+	dir := t.TempDir()
+	oldUnit, oldDropIns, oldDefaults := kubeletUnit, kubeletDropIns, kubeletDefaults
+	kubeletUnit = filepath.Join(dir, "kubelet.service")
+	kubeletDropIns = filepath.Join(dir, "kubelet.service.d")
+	kubeletDefaults = filepath.Join(dir, "kubelet")
+	t.Cleanup(func() { kubeletUnit, kubeletDropIns, kubeletDefaults = oldUnit, oldDropIns, oldDefaults })
+
+	files := map[string]string{
+		kubeletUnit: "[Service]\nEnvironmentFile=/etc/default/kubelet\nExecStart=/usr/local/bin/kubelet \\\n" +
+			"        --enable-server \\\n        --node-labels=\"${KUBELET_NODE_LABELS}\" \\\n" +
+			"        $KUBELET_TLS_BOOTSTRAP_FLAGS \\\n        $KUBELET_FLAGS\n",
+		kubeletDefaults: "KUBELET_NODE_LABELS=\"pool=edge\"\nKUBELET_FLAGS=\"\\\n  --anonymous-auth=false \\\n  --read-only-port=0  \"\n",
+		filepath.Join(kubeletDropIns, "10-tlsbootstrap.conf"): "[Service]\nEnvironment=KUBELET_TLS_BOOTSTRAP_FLAGS=\"--kubeconfig /var/lib/kubelet/kubeconfig\"\n",
+	}
+	for path, data := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args, err := unitKubeletArgs()
+	if err != nil {
+		t.Fatalf("unitKubeletArgs() unexpected error: %v", err)
+	}
+	want := []string{"--enable-server", "--node-labels=pool=edge", "--kubeconfig", "/var/lib/kubelet/kubeconfig", "--anonymous-auth=false", "--read-only-port=0"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("unitKubeletArgs() = %q, want %q", args, want)
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	flags := map[string]string{"read-only-port": "0"}
+	config := `
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+readOnlyPort: 10255
+authentication:
+  anonymous:
+    enabled: false
+  x509:
+    clientCAFile: /etc/kubernetes/certs/ca.crt
+tlsCipherSuites: [TLS_RSA_WITH_AES_128_GCM_SHA256, TLS_RSA_WITH_AES_256_CBC_SHA]
+`
+	if err := applyConfigFile(flags, []byte(config)); err != nil {
+		t.Fatalf("applyConfigFile() unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"read-only-port":    "0", // Flags take precedence
+		"anonymous-auth":    "false",
+		"client-ca-file":    "/etc/kubernetes/certs/ca.crt",
+		"tls-cipher-suites": "TLS_RSA_WITH_AES_128_GCM_SHA256,TLS_RSA_WITH_AES_256_CBC_SHA",
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("applyConfigFile() = %v, want %v", flags, want)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	oldUnit, oldDropIns := kubeletUnit, kubeletDropIns
+	kubeletUnit, kubeletDropIns = "/etc/systemd/system/kubelet.service", t.TempDir()
+	t.Cleanup(func() { kubeletUnit, kubeletDropIns = oldUnit, oldDropIns })
+
+	files := map[string]fileInfo{
+		"/etc/systemd/system/kubelet.service": {mode: 0o644},
+		"/var/lib/kubelet/kubeconfig":         {mode: 0o600},
+		"/etc/kubernetes/certs/ca.crt":        {mode: 0o600, uid: 1000},
+	}
+	n := &node{
+		flags: parseFlags([]string{
+			"--kubeconfig=/var/lib/kubelet/kubeconfig", "--anonymous-auth=false", "--authorization-mode=Webhook",
+			"--client-ca-file=/etc/kubernetes/certs/ca.crt", "--read-only-port=0", "--streaming-connection-idle-timeout=4h",
+			"--rotate-certificates=true", "--tls-cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_RSA_WITH_AES_256_CBC_SHA",
+		}),
+		stat: func(path string) (fileInfo, error) {
+			if info, ok := files[path]; ok {
+				return info, nil
+			}
+			return fileInfo{}, fs.ErrNotExist
+		},
+	}
+
+	report := assess(n)
+	got := map[string]Status{}
+	for _, result := range report.Results {
+		got[result.ID] = result.Status
+		if result.Status == Fail && result.Remediation == "" {
+			t.Errorf("failed check %s has no remediation", result.ID)
+		}
+	}
+	want := map[string]Status{
+		"4.1.1": Fail, "4.1.2": Pass, "4.1.5": Pass, "4.1.6": Pass, "4.1.7": Pass, "4.1.8": Fail, "4.1.9": Skip, "4.1.10": Skip,
+		"4.2.1": Pass, "4.2.2": Pass, "4.2.3": Pass, "4.2.4": Pass, "4.2.5": Pass, "4.2.6": Pass, "4.2.7": Pass,
+		"4.2.9": Fail, "4.2.10": Pass, "4.2.11": Pass, "4.2.12": Fail,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("assess() = %v, want %v", got, want)
+	}
+	if report.Passed != 13 || report.Failed != 4 || report.Skipped != 2 || report.Score != 76 {
+		t.Errorf("assess() score = %d%% (%d passed, %d failed, %d skipped), want 76%% (13, 4, 2)",
+			report.Score, report.Passed, report.Failed, report.Skipped)
+	}
+
+	// Unset flags fall back to the kubelet's insecure defaults
+	report = assess(&node{flags: map[string]string{}, stat: n.stat})
+	for _, result := range report.Results {
+		if (result.ID == "4.2.1" || result.ID == "4.2.2" || result.ID == "4.2.4") && result.Status != Fail {
+			t.Errorf("check %s = %s with the flag unset, want FAIL", result.ID, result.Status)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatalf("WriteText() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Remediation:\n  4.1.1: chmod 600") || !strings.Contains(out.String(), "Score: ") {
+		t.Errorf("WriteText() = %s, want the remediations and the score", out.String())
+	}
+}
@@ -0,0 +1,229 @@
+package cis
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Installed kubelet files; replaced in tests
+var (
+	procDir         = "/proc"
+	kubeletUnit     = "/etc/systemd/system/kubelet.service"
+	kubeletDropIns  = "/etc/systemd/system/kubelet.service.d"
+	kubeletDefaults = "/etc/default/kubelet"
+)
+
+// configFields maps kubelet configuration file fields to the flags they set. Flags take precedence over the file.
+var configFields = map[string]string{
+	"authentication.anonymous.enabled":            "anonymous-auth",
+	"authentication.webhook.enabled":              "authentication-token-webhook",
+	"authentication.x509.clientCAFile":            "client-ca-file",
+	"authorization.mode":                          "authorization-mode",
+	"readOnlyPort":                                "read-only-port",
+	"streamingConnectionIdleTimeout":              "streaming-connection-idle-timeout",
+	"makeIPTablesUtilChains":                      "make-iptables-util-chains",
+	"protectKernelDefaults":                       "protect-kernel-defaults",
+	"tlsCertFile":                                 "tls-cert-file",
+	"tlsPrivateKeyFile":                           "tls-private-key-file",
+	"rotateCertificates":                          "rotate-certificates",
+	"serverTLSBootstrap":                          "rotate-server-certificates",
+	"tlsCipherSuites":                             "tls-cipher-suites",
+	"eventRecordQPS":                              "event-qps",
+	"podPidsLimit":                                "pod-max-pids",
+	"featureGates.RotateKubeletServerCertificate": "feature-gates.RotateKubeletServerCertificate",
+}
+
+// KubeletFlags returns the kubelet's effective flags, with the fields of its --config file added for flags that
+// are not set, and where they were read from: the command line of the running kubelet, or else the flags the
+// installed systemd unit starts it with
+func KubeletFlags() (map[string]string, string, error) {
+	args, source, err := runningKubeletArgs()
+	if err != nil || args == nil {
+		args, err = unitKubeletArgs()
+		if err != nil {
+			return nil, "", err
+		}
+		source = kubeletUnit
+	}
+	flags := parseFlags(args)
+	if path := flags["config"]; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read kubelet configuration %s: %w", path, err)
+		}
+		if err := applyConfigFile(flags, data); err != nil {
+			return nil, "", fmt.Errorf("failed to parse kubelet configuration %s: %w", path, err)
+		}
+	}
+	return flags, source, nil
+}
+
+// runningKubeletArgs returns the arguments of the running kubelet, or nil when it is not running
+func runningKubeletArgs() ([]string, string, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "kubelet" {
+			continue
+		}
+		path := filepath.Join(procDir, entry.Name(), "cmdline")
+		cmdline, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		return args[1:], "running kubelet (pid " + entry.Name() + ")", nil
+	}
+	return nil, "", nil
+}
+
+// unitKubeletArgs returns the arguments ExecStart of the installed unit passes to the kubelet, with the
+// variables of its environment file and drop-ins expanded
+func unitKubeletArgs() ([]string, error) {
+	unit, err := os.ReadFile(kubeletUnit)
+	if err != nil {
+		return nil, fmt.Errorf("kubelet is not running and its unit cannot be read: %w", err)
+	}
+	env := map[string]string{}
+	if data, err := os.ReadFile(kubeletDefaults); err == nil {
+		for _, line := range unitLines(data) {
+			setVariable(env, line)
+		}
+	}
+	dropIns, _ := filepath.Glob(filepath.Join(kubeletDropIns, "*.conf"))
+	for _, path := range dropIns {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range unitLines(data) {
+			if value, ok := strings.CutPrefix(line, "Environment="); ok {
+				setVariable(env, strings.Trim(value, `"`))
+			}
+		}
+	}
+	return execStartArgs(unitLines(unit), env), nil
+}
+
+// execStartArgs returns the arguments of the last ExecStart line, without the binary
+func execStartArgs(lines []string, env map[string]string) []string {
+	var command string
+	for _, line := range lines {
+		if value, ok := strings.CutPrefix(line, "ExecStart="); ok && value != "" {
+			command = value
+		}
+	}
+	fields := strings.Fields(os.Expand(command, func(name string) string { return env[name] }))
+	if len(fields) == 0 {
+		return nil
+	}
+	args := fields[1:]
+	for i, arg := range args {
+		args[i] = strings.ReplaceAll(arg, `"`, "")
+	}
+	return args
+}
+
+// unitLines returns the lines of a unit or environment file with continuation lines joined
+func unitLines(data []byte) []string {
+	joined := strings.ReplaceAll(string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))), "\\\n", " ")
+	var lines []string
+	for _, line := range strings.Split(joined, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// setVariable records a NAME=value assignment, without quotes around the value
+func setVariable(env map[string]string, assignment string) {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return
+	}
+	env[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+}
+
+// parseFlags returns the flags in args by name. Flags are written as --name=value or --name value;
+// a flag without a value is true.
+func parseFlags(args []string) map[string]string {
+	flags := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name, value, ok := strings.Cut(name, "="); ok {
+			flags[name] = value
+			continue
+		}
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			flags[name] = args[i+1]
+			i++
+			continue
+		}
+		flags[name] = "true"
+	}
+	for _, gate := range strings.Split(flags["feature-gates"], ",") {
+		if name, value, ok := strings.Cut(gate, "="); ok {
+			flags["feature-gates."+strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return flags
+}
+
+// applyConfigFile adds the fields of a KubeletConfiguration file for flags that are not set
+func applyConfigFile(flags map[string]string, data []byte) error {
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	for field, flag := range configFields {
+		if _, ok := flags[flag]; ok {
+			continue
+		}
+		if value, ok := lookup(config, strings.Split(field, ".")); ok {
+			flags[flag] = value
+		}
+	}
+	return nil
+}
+
+// lookup returns the field at path in a decoded YAML document as a flag value
+func lookup(doc map[string]any, path []string) (string, bool) {
+	value, ok := doc[path[0]]
+	if !ok {
+		return "", false
+	}
+	if len(path) > 1 {
+		nested, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		return lookup(nested, path[1:])
+	}
+	switch v := value.(type) {
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ","), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
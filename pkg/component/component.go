@@ -0,0 +1,121 @@
+// Package component is the SDK for components built outside the agent, such as a platform team's security
+// agent. A component registers a factory under a name, from an init function of a package compiled into a
+// custom build of the agent or of a Go plugin listed in components.plugins. Listing the name in
+// components.external then installs it during bootstrap, with the agent's logging, state tracking, drift
+// detection and reboot handling, and removes it during unbootstrap.
+//
+// The interfaces and the Env helpers in this package are kept stable; the agent's internal packages are not.
+package component
+
+import (
+	"context"
+	"fmt"
+	"plugin"
+	"sort"
+	"sync"
+)
+
+// Component is a piece of software installed on the node
+type Component interface {
+	// Install installs or updates the component as configured. It runs again when the component reports
+	// itself not installed, so it must be idempotent.
+	Install(ctx context.Context) error
+
+	// IsInstalled reports whether the component is installed as configured; Install is skipped when it is
+	IsInstalled(ctx context.Context) bool
+
+	// Uninstall removes the component. Removing a component that is not installed is not an error.
+	Uninstall(ctx context.Context) error
+}
+
+// Validator is implemented by components that check their settings and preconditions before Install
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// FileOwner is implemented by components that install files, so changes to them made outside the agent
+// are detected as drift and repaired by installing the component again
+type FileOwner interface {
+	ManagedFiles() []string
+}
+
+// RebootRequirer is implemented by components whose changes only take effect after a reboot. Bootstrap
+// stops after the component and resumes on the next boot.
+type RebootRequirer interface {
+	RequiresReboot(ctx context.Context) bool
+}
+
+// Factory creates the component with the environment it is installed in
+type Factory func(env *Env) (Component, error)
+
+// Registration describes a component
+type Registration struct {
+	Name string // Name the component is listed under in components.external: lowercase letters, digits and dashes
+
+	// After is the name of the bootstrap step the component is installed after, e.g. ContainerdInstaller;
+	// empty installs it after all of the agent's installers. Unbootstrap removes components in reverse order.
+	After string
+
+	New Factory
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Registration{}
+	plugins  = map[string]error{} // Plugins opened so far, with the error of opening them
+)
+
+// Register makes a component available under its name. It is meant to be called from an init function and
+// panics when the registration is incomplete or the name is taken.
+func Register(r Registration) {
+	mu.Lock()
+	defer mu.Unlock()
+	if r.Name == "" || r.New == nil {
+		panic("component: Register requires a name and a factory")
+	}
+	if _, ok := registry[r.Name]; ok {
+		panic("component: Register called twice for " + r.Name)
+	}
+	registry[r.Name] = r
+}
+
+// Lookup returns the registration of the named component
+func Lookup(name string) (Registration, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Names returns the names of the registered components
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPlugins opens the Go plugins at paths, whose init functions register their components. A plugin is
+// opened once per process. Plugins must be built with the same Go version and module versions as the agent.
+func LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		mu.Lock()
+		err, opened := plugins[path]
+		mu.Unlock()
+		if !opened {
+			// plugin.Open runs the plugin's init functions, which call Register; the lock is not held
+			_, err = plugin.Open(path)
+			mu.Lock()
+			plugins[path] = err
+			mu.Unlock()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load component plugin %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package component
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type nopComponent struct{}
+
+func (nopComponent) Install(ctx context.Context) error    { return nil }
+func (nopComponent) IsInstalled(ctx context.Context) bool { return true }
+func (nopComponent) Uninstall(ctx context.Context) error  { return nil }
+
+func newNop(env *Env) (Component, error) { return nopComponent{}, nil }
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", name)
+		}
+	}()
+	f()
+}
+
+func useStateDir(t *testing.T) string {
+	t.Helper()
+	old := stateDir
+	stateDir = t.TempDir()
+	t.Cleanup(func() { stateDir = old })
+	return stateDir
+}
+
+func TestRegister(t *testing.T) {
+	Register(Registration{Name: "registry-test", After: "KubeletInstaller", New: newNop})
+
+	r, ok := Lookup("registry-test")
+	if !ok || r.After != "KubeletInstaller" {
+		t.Fatalf("Lookup() = %+v, %v, want the registration", r, ok)
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Error("Lookup() found an unregistered component")
+	}
+	if !slices.Contains(Names(), "registry-test") {
+		t.Errorf("Names() = %v, want registry-test", Names())
+	}
+
+	expectPanic(t, "Register() of a taken name", func() { Register(Registration{Name: "registry-test", New: newNop}) })
+	expectPanic(t, "Register() without a factory", func() { Register(Registration{Name: "no-factory"}) })
+}
+
+func TestLoadPlugins(t *testing.T) {
+	if err := LoadPlugins(nil); err != nil {
+		t.Errorf("LoadPlugins(nil) unexpected error: %v", err)
+	}
+	if err := LoadPlugins([]string{filepath.Join(t.TempDir(), "missing.so")}); err == nil {
+		t.Error("LoadPlugins() of a missing plugin succeeded")
+	}
+}
+
+func TestState(t *testing.T) {
+	dir := useStateDir(t)
+	env := NewEnv("contoso-agent", nil, nil, map[string]string{"endpoint": "https://agent.contoso.com", "empty": ""})
+
+	if got := env.Setting("endpoint", "default"); got != "https://agent.contoso.com" {
+		t.Errorf("Setting(endpoint) = %q", got)
+	}
+	if got := env.Setting("empty", "default"); got != "default" {
+		t.Errorf("Setting(empty) = %q, want the default", got)
+	}
+
+	type installed struct{ Version string }
+	var got installed
+	if ok, err := env.LoadState(&got); err != nil || ok {
+		t.Fatalf("LoadState() before SaveState() = %v, %v, want no state", ok, err)
+	}
+	if err := env.SaveState(installed{Version: "1.2.3"}); err != nil {
+		t.Fatalf("SaveState() unexpected error: %v", err)
+	}
+	if ok, err := env.LoadState(&got); err != nil || !ok || got.Version != "1.2.3" {
+		t.Errorf("LoadState() = %+v, %v, %v, want the saved state", got, ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "contoso-agent.json")); err != nil {
+		t.Errorf("state file: %v", err)
+	}
+	if err := env.ClearState(); err != nil {
+		t.Fatalf("ClearState() unexpected error: %v", err)
+	}
+	if ok, err := env.LoadState(&got); err != nil || ok {
+		t.Errorf("LoadState() after ClearState() = %v, %v, want no state", ok, err)
+	}
+}
@@ -0,0 +1,162 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// StateDir holds the state components save with Env.SaveState, one file per component. It is part of the
+// agent's state directory, so a configured state backend keeps it too.
+const StateDir = "/var/lib/aks-flex-node/components"
+
+// unitDir is where Env.InstallUnit writes systemd units
+const unitDir = "/etc/systemd/system"
+
+// Replaced in tests
+var (
+	stateDir = StateDir
+	unitPath = func(name string) string { return filepath.Join(unitDir, name) }
+)
+
+// Env is the environment a component is installed in, with helpers for the work components share
+type Env struct {
+	Name     string            // Name the component registered under
+	Config   *config.Config    // Agent configuration; read-only
+	Logger   *logrus.Logger    // Agent logger
+	Settings map[string]string // components.external settings of the component
+}
+
+// NewEnv creates the environment of the named component; a nil logger uses the standard logger
+func NewEnv(name string, cfg *config.Config, logger *logrus.Logger, settings map[string]string) *Env {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	if settings == nil {
+		settings = map[string]string{}
+	}
+	return &Env{Name: name, Config: cfg, Logger: logger, Settings: settings}
+}
+
+// Setting returns the named setting, or def when it is not set
+func (e *Env) Setting(name, def string) string {
+	if value, ok := e.Settings[name]; ok && value != "" {
+		return value
+	}
+	return def
+}
+
+// Download writes the versioned release artifact at url to destination, through the agent's artifact cache,
+// LAN peers, checksum pinning and rate limits, and records its provenance for the SBOM
+func (e *Env) Download(ctx context.Context, url, version, destination string) error {
+	return download.NewManager(e.Config, e.Logger).For(e.Name, version).Fetch(ctx, url, destination)
+}
+
+// RenderTemplate renders defaultText with data, or the override <name>.tmpl in the templates directory when
+// there is one, so operators can change the component's files without a new build
+func (e *Env) RenderTemplate(name, defaultText string, data any) (string, error) {
+	return templates.RenderWithDefault(e.Config, name, defaultText, data)
+}
+
+// WriteFile atomically writes data to path, creating its directory
+func (e *Env) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := utils.WriteFileAtomicSystem(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// InstallUnit writes the systemd unit name, e.g. contoso-agent.service, and reloads systemd when it changed.
+// It returns whether the unit changed.
+func (e *Env) InstallUnit(name, content string) (bool, error) {
+	path := unitPath(name)
+	if current, err := os.ReadFile(path); err == nil && string(current) == content {
+		return false, nil
+	}
+	if err := e.WriteFile(path, []byte(content), 0o644); err != nil {
+		return false, err
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		return true, fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	return true, nil
+}
+
+// RemoveUnit stops and disables the systemd unit name and removes its file
+func (e *Env) RemoveUnit(name string) error {
+	if utils.IsServiceActive(name) {
+		if err := utils.StopService(name); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", name, err)
+		}
+	}
+	if utils.IsServiceEnabled(name) {
+		if err := utils.DisableService(name); err != nil {
+			return fmt.Errorf("failed to disable %s: %w", name, err)
+		}
+	}
+	if err := utils.RunCleanupCommand(unitPath(name)); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	return utils.ReloadSystemd()
+}
+
+// EnableAndStart enables the systemd unit name and starts it, restarting it when restart is set, e.g.
+// because InstallUnit or a configuration file changed
+func (e *Env) EnableAndStart(name string, restart bool) error {
+	if err := utils.EnableAndStartService(name); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	if restart {
+		if err := utils.RestartService(name); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// LoadState decodes the component's saved state into v. It returns false when no state was saved.
+func (e *Env) LoadState(v any) (bool, error) {
+	data, err := os.ReadFile(e.statePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read state of %s: %w", e.Name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode state of %s: %w", e.Name, err)
+	}
+	return true, nil
+}
+
+// SaveState saves v as the component's state
+func (e *Env) SaveState(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state of %s: %w", e.Name, err)
+	}
+	return e.WriteFile(e.statePath(), data, 0o600)
+}
+
+// ClearState removes the component's saved state
+func (e *Env) ClearState() error {
+	return utils.RunCleanupCommand(e.statePath())
+}
+
+func (e *Env) statePath() string {
+	return filepath.Join(stateDir, e.Name+".json")
+}
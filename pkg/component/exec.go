@@ -0,0 +1,170 @@
+package component
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExecProtocolVersion is the version of the exec protocol sent in every request
+const ExecProtocolVersion = 1
+
+// Phases of the exec protocol
+const (
+	PhaseExecute   = "execute"   // Install or update the component
+	PhaseStatus    = "status"    // Report whether the component is installed as configured
+	PhaseUninstall = "uninstall" // Remove the component
+)
+
+// DefaultExecTimeout is the time allowed for each run of an executable without a configured timeout
+const DefaultExecTimeout = 10 * time.Minute
+
+// ExecRequest is written to the executable's stdin
+type ExecRequest struct {
+	ProtocolVersion int               `json:"protocolVersion"`
+	Phase           string            `json:"phase"`
+	Name            string            `json:"name"`
+	Settings        map[string]string `json:"settings"`
+	State           json.RawMessage   `json:"state,omitempty"` // State returned by the last successful execute
+}
+
+// ExecResponse is read from the executable's stdout. Empty output is read as an empty response.
+type ExecResponse struct {
+	Installed      bool            `json:"installed,omitempty"`      // status: the component is installed as configured
+	State          json.RawMessage `json:"state,omitempty"`          // execute: kept by the agent and sent back in later requests
+	ManagedFiles   []string        `json:"managedFiles,omitempty"`   // execute: files to watch for drift
+	RebootRequired bool            `json:"rebootRequired,omitempty"` // execute: bootstrap stops until the node has rebooted
+	Error          string          `json:"error,omitempty"`          // Fails the phase, like a non-zero exit status
+}
+
+// execRecord is what the agent keeps of an exec component between runs
+type execRecord struct {
+	State        json.RawMessage `json:"state,omitempty"`
+	ManagedFiles []string        `json:"managedFiles,omitempty"`
+}
+
+// execComponent is a component implemented by an executable speaking the exec protocol
+type execComponent struct {
+	env     *Env
+	path    string
+	timeout time.Duration
+	reboot  bool
+}
+
+// NewExec creates a component that runs the executable at path for each phase, with the phase as its only
+// argument, the ExecRequest on stdin and the ExecResponse on stdout. Lines the executable writes to stderr
+// are logged. A zero timeout allows DefaultExecTimeout for each run.
+func NewExec(env *Env, path string, timeout time.Duration) Component {
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	return &execComponent{env: env, path: path, timeout: timeout}
+}
+
+// Validate checks the executable exists and can be run
+func (c *execComponent) Validate(ctx context.Context) error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return fmt.Errorf("executable of component %s: %w", c.env.Name, err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("executable of component %s: %s is not an executable file", c.env.Name, c.path)
+	}
+	return nil
+}
+
+// Install runs the execute phase and keeps the state it returns
+func (c *execComponent) Install(ctx context.Context) error {
+	response, err := c.run(ctx, PhaseExecute)
+	if err != nil {
+		return err
+	}
+	c.reboot = response.RebootRequired
+	return c.env.SaveState(execRecord{State: response.State, ManagedFiles: response.ManagedFiles})
+}
+
+// IsInstalled runs the status phase; a failed run reports the component as not installed
+func (c *execComponent) IsInstalled(ctx context.Context) bool {
+	response, err := c.run(ctx, PhaseStatus)
+	if err != nil {
+		c.env.Logger.Warnf("Failed to get the status of component %s: %v", c.env.Name, err)
+		return false
+	}
+	return response.Installed
+}
+
+// Uninstall runs the uninstall phase and clears the kept state
+func (c *execComponent) Uninstall(ctx context.Context) error {
+	if _, err := c.run(ctx, PhaseUninstall); err != nil {
+		return err
+	}
+	return c.env.ClearState()
+}
+
+// ManagedFiles returns the files the last successful execute reported
+func (c *execComponent) ManagedFiles() []string {
+	var record execRecord
+	if _, err := c.env.LoadState(&record); err != nil {
+		c.env.Logger.Warnf("Failed to load the files of component %s: %v", c.env.Name, err)
+	}
+	return record.ManagedFiles
+}
+
+// RequiresReboot reports whether the execute phase asked for a reboot
+func (c *execComponent) RequiresReboot(ctx context.Context) bool {
+	return c.reboot
+}
+
+// run runs the executable for phase and decodes its response
+func (c *execComponent) run(ctx context.Context, phase string) (*ExecResponse, error) {
+	var record execRecord
+	if _, err := c.env.LoadState(&record); err != nil {
+		return nil, err
+	}
+	request, err := json.Marshal(ExecRequest{
+		ProtocolVersion: ExecProtocolVersion,
+		Phase:           phase,
+		Name:            c.env.Name,
+		Settings:        c.env.Settings,
+		State:           record.State,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s request of component %s: %w", phase, c.env.Name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	stderr := c.env.Logger.WithFields(logrus.Fields{"component": c.env.Name, "phase": phase}).WriterLevel(logrus.InfoLevel)
+	defer func() { _ = stderr.Close() }()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.path, phase)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s of component %s did not finish within %s", phase, c.env.Name, c.timeout)
+	}
+
+	response := &ExecResponse{}
+	if output := bytes.TrimSpace(stdout.Bytes()); len(output) > 0 {
+		if err := json.Unmarshal(output, response); err != nil && runErr == nil {
+			return nil, fmt.Errorf("invalid %s response of component %s: %w", phase, c.env.Name, err)
+		}
+	}
+	switch {
+	case response.Error != "":
+		return nil, fmt.Errorf("%s of component %s failed: %s", phase, c.env.Name, response.Error)
+	case runErr != nil:
+		return nil, fmt.Errorf("%s of component %s failed: %w", phase, c.env.Name, runErr)
+	}
+	return response, nil
+}
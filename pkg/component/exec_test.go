@@ -0,0 +1,134 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// installScript is an exec component that installs a marker file and remembers the version it installed
+const installScript = `#!/bin/sh
+dir=$(dirname "$0")
+cat > "$dir/request-$1.json"
+echo "running $1" >&2
+case "$1" in
+execute)
+	touch "$dir/installed"
+	echo '{"state": {"version": "1.2.3"}, "managedFiles": ["/opt/contoso/agent"], "rebootRequired": true}'
+	;;
+status)
+	if [ -f "$dir/installed" ]; then echo '{"installed": true}'; fi
+	;;
+uninstall)
+	rm -f "$dir/installed"
+	;;
+esac
+`
+
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "component.sh")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readRequest(t *testing.T, path, phase string) ExecRequest {
+	t.Helper()
+	var request ExecRequest
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "request-"+phase+".json"))
+	if err == nil {
+		err = json.Unmarshal(data, &request)
+	}
+	if err != nil {
+		t.Fatalf("request of %s: %v", phase, err)
+	}
+	return request
+}
+
+func TestExec(t *testing.T) {
+	ctx := context.Background()
+	useStateDir(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	path := writeScript(t, installScript)
+	c := NewExec(NewEnv("contoso-agent", nil, logger, map[string]string{"endpoint": "https://agent.contoso.com"}), path, 0)
+
+	if err := c.(Validator).Validate(ctx); err != nil {
+		t.Fatalf("Validate() unexpected error: %v", err)
+	}
+	if c.IsInstalled(ctx) {
+		t.Fatal("IsInstalled() before Install() = true")
+	}
+	if err := c.Install(ctx); err != nil {
+		t.Fatalf("Install() unexpected error: %v", err)
+	}
+	request := readRequest(t, path, PhaseExecute)
+	if request.ProtocolVersion != ExecProtocolVersion || request.Name != "contoso-agent" || request.Settings["endpoint"] != "https://agent.contoso.com" {
+		t.Errorf("execute request = %+v", request)
+	}
+	if !c.IsInstalled(ctx) {
+		t.Error("IsInstalled() after Install() = false")
+	}
+	if !c.(RebootRequirer).RequiresReboot(ctx) {
+		t.Error("RequiresReboot() = false, want the reboot the executable asked for")
+	}
+	if files := c.(FileOwner).ManagedFiles(); !reflect.DeepEqual(files, []string{"/opt/contoso/agent"}) {
+		t.Errorf("ManagedFiles() = %v", files)
+	}
+
+	// The state returned by execute is sent back in later requests
+	if state := string(readRequest(t, path, PhaseStatus).State); state != `{"version":"1.2.3"}` {
+		t.Errorf("status request state = %s, want the state returned by execute", state)
+	}
+
+	if err := c.Uninstall(ctx); err != nil {
+		t.Fatalf("Uninstall() unexpected error: %v", err)
+	}
+	if c.IsInstalled(ctx) {
+		t.Error("IsInstalled() after Uninstall() = true")
+	}
+	if state := readRequest(t, path, PhaseStatus).State; state != nil {
+		t.Errorf("status request state after Uninstall() = %s, want none", state)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	ctx := context.Background()
+	useStateDir(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	env := NewEnv("contoso-agent", nil, logger, nil)
+
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		wantErr string
+	}{
+		{name: "reported error", script: "#!/bin/sh\necho '{\"error\": \"license expired\"}'\n", wantErr: "license expired"},
+		{name: "exit status", script: "#!/bin/sh\nexit 3\n", wantErr: "exit status 3"},
+		{name: "invalid response", script: "#!/bin/sh\necho done\n", wantErr: "invalid execute response"},
+		{name: "timeout", script: "#!/bin/sh\nexec sleep 5\n", timeout: 100 * time.Millisecond, wantErr: "did not finish within"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewExec(env, writeScript(t, tt.script), tt.timeout).Install(ctx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Install() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := NewExec(env, filepath.Join(t.TempDir(), "missing"), 0).(Validator).Validate(ctx); err == nil {
+		t.Error("Validate() of a missing executable succeeded")
+	}
+}
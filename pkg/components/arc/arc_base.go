@@ -2,23 +2,35 @@ package arc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
 	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/rbac"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
 )
 
 // RoleAssignment represents a role assignment configuration
 type roleAssignment struct {
-	roleName string
-	scope    string
-	roleID   string
+	roleName    string
+	scope       string
+	roleID      string
+	principalID string // empty means the Arc machine's managed identity
+
+	condition        string // Optional ABAC condition narrowing the assignment
+	conditionVersion string
+
+	delegatedIdentityID string // Optional resource ID of the delegated managed identity principalID belongs to
 }
 
 // base provides common functionality that's common for both Installer and Uninstaller
@@ -27,15 +39,21 @@ type base struct {
 	logger                     *logrus.Logger
 	authProvider               *auth.AuthProvider
 	hybridComputeMachineClient *armhybridcompute.MachinesClient
+	machineExtensionsClient    *armhybridcompute.MachineExtensionsClient
 	mcClient                   *armcontainerservice.ManagedClustersClient
 	roleAssignmentsClient      roleAssignmentsClient
+	principalChecker           principalChecker // optional, set when azure.arc.verifyPrincipal is enabled
+	clock                      retry.Clock      // paces waits and retries; nil means the real clock
+	ledger                     rbac.Ledger      // records created role assignments; nil records nothing
 }
 
 // newbase creates a new Arc base instance which will be shared by Installer and Uninstaller
-func newBase(logger *logrus.Logger) *base {
+func newBase(cfg *config.Config, logger *logrus.Logger) *base {
 	return &base{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
+		clock:  retry.RealClock,
+		ledger: rbac.NewFileLedger(roleAssignmentLedgerPath),
 	}
 }
 
@@ -45,32 +63,56 @@ func (ab *base) setUpClients(ctx context.Context) error {
 		return fmt.Errorf("fail to ensureAuthentication: %w", err)
 	}
 
-	cred, err := auth.NewAuthProvider().UserCredential(config.GetConfig())
+	cfg := ab.config
+	cred, err := auth.NewAuthProvider().UserCredential(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to get authentication credential: %w", err)
 	}
 
+	// The cluster and its role assignments may live in another tenant than the Arc machine
+	clusterCred, err := auth.NewAuthProvider().ClusterCredential(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster tenant credential: %w", err)
+	}
+	clientOptions := auth.ARMClientOptions(cfg)
+
 	// Create hybrid compute machines client
-	hybridComputeMachineClient, err := armhybridcompute.NewMachinesClient(config.GetConfig().GetSubscriptionID(), cred, nil)
+	hybridComputeMachineClient, err := armhybridcompute.NewMachinesClient(cfg.GetSubscriptionID(), cred, clientOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create hybrid compute client: %w", err)
 	}
 
+	machineExtensionsClient, err := armhybridcompute.NewMachineExtensionsClient(cfg.GetSubscriptionID(), cred, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create hybrid compute extensions client: %w", err)
+	}
+
 	// Create managed clusters client
-	mcClient, err := armcontainerservice.NewManagedClustersClient(config.GetConfig().GetSubscriptionID(), cred, nil)
+	mcClient, err := armcontainerservice.NewManagedClustersClient(cfg.GetTargetClusterSubscriptionID(), clusterCred, clientOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create managed clusters client: %w", err)
 	}
 
 	// Create role assignments client
-	azureClient, err := armauthorization.NewRoleAssignmentsClient(config.GetConfig().GetSubscriptionID(), cred, nil)
+	roleAssignmentsClient, err := rbac.NewRoleAssignmentsClient(cfg.GetSubscriptionID(), clusterCred, clientOptions)
 	if err != nil {
-		return fmt.Errorf("failed to create role assignments client: %w", err)
+		return err
 	}
 
 	ab.hybridComputeMachineClient = hybridComputeMachineClient
+	ab.machineExtensionsClient = machineExtensionsClient
 	ab.mcClient = mcClient
-	ab.roleAssignmentsClient = &azureRoleAssignmentsClient{client: azureClient}
+	ab.roleAssignmentsClient = roleAssignmentsClient
+
+	// Optionally verify principals in Microsoft Graph to tell replication delay apart from a wrong principal ID
+	if ab.config.IsArcPrincipalVerificationEnabled() {
+		graphOptions := auth.ClientOptions(cfg)
+		checker, err := rbac.NewGraphPrincipalChecker(cred, &graphOptions)
+		if err != nil {
+			return fmt.Errorf("failed to create principal checker: %w", err)
+		}
+		ab.principalChecker = checker
+	}
 	return nil
 }
 
@@ -80,9 +122,11 @@ func (ab *base) getArcMachine(ctx context.Context) (*armhybridcompute.Machine, e
 	arcResourceGroup := ab.config.GetArcResourceGroup()
 
 	ab.logger.Infof("Getting Arc machine info for: %s in resource group: %s", arcMachineName, arcResourceGroup)
-	result, err := ab.hybridComputeMachineClient.Get(ctx, arcResourceGroup, arcMachineName, nil)
+	opCtx, cancel := auth.OperationContext(ctx, ab.config)
+	defer cancel()
+	result, err := ab.hybridComputeMachineClient.Get(opCtx, arcResourceGroup, arcMachineName, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Arc machine info via SDK: %w", err)
+		return nil, azerrors.Wrap(fmt.Errorf("failed to get Arc machine info via SDK: %w", err))
 	}
 	machine := result.Machine
 	ab.logger.Infof("Successfully retrieved Arc machine info: %s (ID: %s)", to.String(machine.Name), to.String(machine.ID))
@@ -94,9 +138,11 @@ func (ab *base) getAKSCluster(ctx context.Context) (*armcontainerservice.Managed
 	clusterResourceGroup := ab.config.GetTargetClusterResourceGroup()
 
 	ab.logger.Infof("Getting AKS cluster info for: %s in resource group: %s", clusterName, clusterResourceGroup)
-	result, err := ab.mcClient.Get(ctx, clusterResourceGroup, clusterName, nil)
+	opCtx, cancel := auth.OperationContext(ctx, ab.config)
+	defer cancel()
+	result, err := ab.mcClient.Get(opCtx, clusterResourceGroup, clusterName, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get AKS cluster info via SDK: %w", err)
+		return nil, azerrors.Wrap(fmt.Errorf("failed to get AKS cluster info via SDK: %w", err))
 	}
 	cluster := result.ManagedCluster
 	ab.logger.Infof("Successfully retrieved AKS cluster info: %s (ID: %s)", to.String(cluster.Name), to.String(cluster.ID))
@@ -108,7 +154,7 @@ func (ab *base) checkRequiredPermissions(ctx context.Context, principalID string
 	// Check each required role assignment
 	requiredRoles := ab.getRoleAssignments()
 	for _, required := range requiredRoles {
-		hasRole, err := ab.checkRoleAssignment(ctx, principalID, required.roleID, required.scope)
+		hasRole, err := ab.roleAssigner().HasAssignment(ctx, required.spec(principalID))
 		if err != nil {
 			return false, fmt.Errorf("error checking role %s on scope %s: %w", required.roleName, required.scope, err)
 		}
@@ -123,43 +169,193 @@ func (ab *base) checkRequiredPermissions(ctx context.Context, principalID string
 }
 
 func (ab *base) getRoleAssignments() []roleAssignment {
-	return []roleAssignment{
-		{"Reader (Target Cluster)", ab.config.GetTargetClusterID(), roleDefinitionIDs["Reader"]},
-		{"Azure Kubernetes Service RBAC Cluster Admin", ab.config.GetTargetClusterID(), roleDefinitionIDs["Azure Kubernetes Service RBAC Cluster Admin"]},
-		{"Azure Kubernetes Service Cluster Admin Role", ab.config.GetTargetClusterID(), roleDefinitionIDs["Azure Kubernetes Service Cluster Admin Role"]},
+	assignments := []roleAssignment{
+		{roleName: "Reader (Target Cluster)", scope: ab.config.GetTargetClusterID(), roleID: roleDefinitionIDs["Reader"]},
+		{roleName: "Azure Kubernetes Service RBAC Cluster Admin", scope: ab.config.GetTargetClusterID(), roleID: roleDefinitionIDs["Azure Kubernetes Service RBAC Cluster Admin"]},
+		{roleName: "Azure Kubernetes Service Cluster Admin Role", scope: ab.config.GetTargetClusterID(), roleID: roleDefinitionIDs["Azure Kubernetes Service Cluster Admin Role"]},
+	}
+
+	// Append user-configured role assignments; scopes were already expanded and validated at config load
+	for _, configured := range ab.config.GetArcRoleAssignments() {
+		roleID, err := resolveRoleDefinitionID(configured.Role)
+		if err != nil {
+			ab.logger.Warnf("Skipping configured role assignment: %v", err)
+			continue
+		}
+		assignments = append(assignments, roleAssignment{
+			roleName:         configured.Role,
+			scope:            configured.Scope,
+			roleID:           roleID,
+			principalID:      configured.PrincipalID,
+			condition:        configured.Condition,
+			conditionVersion: configured.ConditionVersion,
+
+			delegatedIdentityID: configured.DelegatedManagedIdentityResourceID,
+		})
 	}
+	return assignments
 }
 
-// checkRoleAssignment checks if a principal has a specific role assignment on a scope
-func (ab *base) checkRoleAssignment(ctx context.Context, principalID, roleDefinitionID, scope string) (bool, error) {
-	// Build the full role definition ID
-	fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
-		ab.config.Azure.SubscriptionID, roleDefinitionID)
+// principalFor returns the principal the assignment targets, falling back to the Arc managed identity
+func (ra roleAssignment) principalFor(arcPrincipalID string) string {
+	if ra.principalID != "" {
+		return ra.principalID
+	}
+	return arcPrincipalID
+}
+
+// toSpecs converts role assignments into rbac specs, resolving the Arc managed identity as needed
+func toSpecs(assignments []roleAssignment, arcPrincipalID string) []rbac.AssignmentSpec {
+	specs := make([]rbac.AssignmentSpec, 0, len(assignments))
+	for _, ra := range assignments {
+		specs = append(specs, ra.spec(arcPrincipalID))
+	}
+	return specs
+}
+
+// spec converts the role assignment into an rbac spec, resolving the Arc managed identity as needed
+func (ra roleAssignment) spec(arcPrincipalID string) rbac.AssignmentSpec {
+	return rbac.AssignmentSpec{
+		PrincipalID:      ra.principalFor(arcPrincipalID),
+		RoleDefinitionID: ra.roleID,
+		Scope:            ra.scope,
+		RoleName:         ra.roleName,
+		Condition:        ra.condition,
+		ConditionVersion: ra.conditionVersion,
+
+		DelegatedManagedIdentityResourceID: ra.delegatedIdentityID,
+	}
+}
 
-	// List role assignments for the scope
-	pager := ab.roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
-		Filter: nil, // We'll filter programmatically
-	})
+// validateConfiguredRoleAssignments ensures every configured role refers to a known role definition
+func (ab *base) validateConfiguredRoleAssignments() error {
+	for idx, configured := range ab.config.GetArcRoleAssignments() {
+		if _, err := resolveRoleDefinitionID(configured.Role); err != nil {
+			return fmt.Errorf("azure.arc.roleAssignments[%d]: %w", idx, err)
+		}
+	}
+	return nil
+}
 
-	for pager.More() {
-		page, err := pager.NextPage(ctx)
+// resolveRoleDefinitionID maps a built-in role name or a role definition GUID to a role definition ID
+func resolveRoleDefinitionID(role string) (string, error) {
+	if roleID, ok := roleDefinitionIDs[role]; ok {
+		return roleID, nil
+	}
+	if _, err := uuid.Parse(role); err == nil {
+		return strings.ToLower(role), nil
+	}
+	return "", fmt.Errorf("unknown role '%s': use a role definition GUID or one of the built-in role names", role)
+}
+
+// updateArcMachineTags replaces the tags of the Arc machine resource
+func (ab *base) updateArcMachineTags(ctx context.Context, tags map[string]*string) error {
+	arcMachineName := ab.config.GetArcMachineName()
+	arcResourceGroup := ab.config.GetArcResourceGroup()
+
+	opCtx, cancel := auth.OperationContext(ctx, ab.config)
+	defer cancel()
+	update := armhybridcompute.MachineUpdate{Tags: tags}
+	if _, err := ab.hybridComputeMachineClient.Update(opCtx, arcResourceGroup, arcMachineName, update, nil); err != nil {
+		return azerrors.Wrap(fmt.Errorf("failed to update Arc machine tags: %w", err))
+	}
+	return nil
+}
+
+// TagMachine sets tags on the node's Arc machine, keeping the tags it already has
+func TagMachine(ctx context.Context, cfg *config.Config, logger *logrus.Logger, tags map[string]string) error {
+	ab := newBase(cfg, logger)
+	if err := ab.setUpClients(ctx); err != nil {
+		return err
+	}
+	machine, err := ab.getArcMachine(ctx)
+	if err != nil {
+		return err
+	}
+	merged, changed := mergeTags(machine.Tags, tags)
+	if !changed {
+		return nil
+	}
+	return ab.updateArcMachineTags(ctx, merged)
+}
+
+// deleteArcMachine deletes the Arc machine resource; a resource that is already gone is not an error
+func (ab *base) deleteArcMachine(ctx context.Context) error {
+	arcMachineName := ab.config.GetArcMachineName()
+	arcResourceGroup := ab.config.GetArcResourceGroup()
+	ab.logger.Infof("Deleting Arc machine resource: %s in resource group: %s", arcMachineName, arcResourceGroup)
+
+	opCtx, cancel := auth.OperationContext(ctx, ab.config)
+	defer cancel()
+	if _, err := ab.hybridComputeMachineClient.Delete(opCtx, arcResourceGroup, arcMachineName, nil); err != nil {
+		if azerrors.IsNotFound(err) {
+			ab.logger.Info("Arc machine resource not found (already deleted)")
+			return nil
+		}
+		return azerrors.Wrap(fmt.Errorf("failed to delete Arc machine resource: %w", err))
+	}
+	return nil
+}
+
+// removeRoleAssignmentsFor removes the role assignments the agent created for the principals of the given
+// assignments, resolving the Arc managed identity to managedIdentityID. Assignments that existed before the
+// agent created its own, on the same scope and for the same principal, are never removed.
+func (ab *base) removeRoleAssignmentsFor(ctx context.Context, managedIdentityID string, assignments []roleAssignment) error {
+	ab.logger.Infof("Removing role assignments for managed identity: %s", managedIdentityID)
+
+	var principals []string
+	for _, role := range assignments {
+		if principal := role.principalFor(managedIdentityID); !slices.Contains(principals, principal) {
+			principals = append(principals, principal)
+		}
+	}
+	removed, err := ab.roleAssigner().RemoveRecorded(ctx, principals...)
+	if !errors.Is(err, rbac.ErrNoLedger) {
 		if err != nil {
-			return false, fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
+			return fmt.Errorf("failed to remove some role assignments: %w", err)
 		}
+		ab.logger.Infof("Removed %d role assignments created by the agent", len(removed))
+		return nil
+	}
 
-		// Check each role assignment
-		for _, assignment := range page.Value {
-			if assignment.Properties != nil &&
-				assignment.Properties.PrincipalID != nil &&
-				assignment.Properties.RoleDefinitionID != nil &&
-				*assignment.Properties.PrincipalID == principalID &&
-				*assignment.Properties.RoleDefinitionID == fullRoleDefinitionID {
-				return true, nil
-			}
+	// Nodes bootstrapped before the ledger existed: remove the configured roles, but only assignments that
+	// carry the agent's description
+	ab.logger.Info("No record of the role assignments the agent created, removing the configured roles it manages")
+	var removalErrors []string
+	for _, role := range assignments {
+		ab.logger.Infof("Removing role assignment: %s on scope %s", role.roleName, role.scope)
+		err := ab.roleAssigner().RemoveManagedAssignment(ctx, rbac.AssignmentSpec{
+			PrincipalID:      role.principalFor(managedIdentityID),
+			RoleDefinitionID: role.roleID,
+			Scope:            role.scope,
+			RoleName:         role.roleName,
+		})
+		if err != nil {
+			ab.logger.Warnf("Failed to remove role assignment %s on scope %s: %v", role.roleName, role.scope, err)
+			removalErrors = append(removalErrors, fmt.Sprintf("%s: %v", role.roleName, err))
+		} else {
+			ab.logger.Infof("Successfully removed role assignment: %s on scope %s", role.roleName, role.scope)
 		}
 	}
 
-	return false, nil
+	if len(removalErrors) > 0 {
+		return fmt.Errorf("failed to remove some role assignments: %s", strings.Join(removalErrors, "; "))
+	}
+
+	ab.logger.Info("All RBAC role assignments removed successfully")
+	return nil
+}
+
+// roleAssigner returns a RoleAssigner backed by the base's role assignments client
+func (ab *base) roleAssigner() *rbac.RoleAssigner {
+	assigner := rbac.NewRoleAssigner(ab.roleAssignmentsClient, ab.config.Azure.SubscriptionID, ab.logger)
+	assigner.PrincipalChecker = ab.principalChecker
+	assigner.OperationTimeout = ab.config.GetAzureOperationTimeout()
+	assigner.Ledger = ab.ledger
+	if ab.clock != nil {
+		assigner.Clock = ab.clock
+	}
+	return assigner
 }
 
 // ensureAuthentication ensures the appropriate authentication (SP or CLI) method is set up
@@ -2,6 +2,7 @@ package arc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,24 +10,33 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
 	"github.com/Azure/go-autorest/autorest/to"
-	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/rbac"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/throttle"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
 // Installer handles Azure Arc installation operations
 type Installer struct {
 	*base
+
+	// Local agent state and host identity used to detect stale Arc machine resources; replaced in tests
+	showAgentStatus func(ctx context.Context) (*agentStatus, error)
+	readHost        func() host
 }
 
 // NewInstaller creates a new Arc installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		base: newBase(logger),
+		base:            newBase(cfg, logger),
+		showAgentStatus: showAgentStatus,
+		readHost:        readHost,
 	}
 }
 
@@ -36,6 +46,14 @@ func (i *Installer) Validate(ctx context.Context) error {
 		i.logger.Info("Azure Arc installation is disabled in configuration")
 		return nil
 	}
+	// The name must be derivable, e.g. the firmware must report a serial number, before anything is installed
+	if _, err := i.config.ResolveArcMachineName(); err != nil {
+		return fmt.Errorf("failed to determine the Arc machine name: %w", err)
+	}
+	// Reject unknown roles before touching Azure
+	if err := i.validateConfiguredRoleAssignments(); err != nil {
+		return fmt.Errorf("invalid role assignment configuration: %w", err)
+	}
 	// Ensure SP or CLI auth is ready for Arc agent setup
 	if err := i.ensureAuthentication(ctx); err != nil {
 		i.logger.Errorf("Authentication setup failed: %v", err)
@@ -116,6 +134,8 @@ func (i *Installer) setupArcPermissions() error {
 
 // downloadArcInstallScript downloads the Arc installation script using curl or wget
 func (i *Installer) downloadArcInstallScript(ctx context.Context, destPath string) error {
+	defer profiling.Track(profiling.Download)()
+
 	// Try curl first
 	if _, err := exec.LookPath("curl"); err == nil {
 		cmd := exec.CommandContext(ctx, "curl", "-L", "-o", destPath, arcInstallScriptURL)
@@ -155,12 +175,13 @@ func (i *Installer) Execute(ctx context.Context) error {
 
 	// Step 2: Register Arc machine with Azure
 	i.logger.Info("Step 2: Registering Arc machine with Azure")
-	arcMachine, err := i.registerArcMachine(ctx)
+	arcMachine, stalePrincipalID, err := i.registerArcMachine(ctx)
 	if err != nil {
 		i.logger.Errorf("Failed to register Arc machine: %v", err)
 		return fmt.Errorf("arc bootstrap setup failed at machine registration: %w", err)
 	}
 	i.logger.Info("Successfully registered Arc machine with Azure")
+	i.syncMachineTags(ctx, arcMachine)
 
 	// Step 3: Validate managed cluster requirements
 	i.logger.Info("Step 3: Validating Managed Cluster requirements")
@@ -170,13 +191,24 @@ func (i *Installer) Execute(ctx context.Context) error {
 	}
 
 	// Step 4: Assign RBAC roles to managed identity
-	time.Sleep(10 * time.Second) // brief pause to ensure identity is ready
+	// Brief pause to ensure identity is ready
+	if err := retry.Sleep(ctx, i.clock, 10*time.Second); err != nil {
+		return err
+	}
 	i.logger.Info("Step 4: Assigning RBAC roles to managed identity")
 	if err := i.assignRBACRoles(ctx, arcMachine); err != nil {
 		i.logger.Errorf("Failed to assign RBAC roles: %v", err)
 		return fmt.Errorf("arc bootstrap setup failed at RBAC role assignment: %w", err)
 	}
 	i.logger.Info("Successfully assigned RBAC roles")
+	i.cleanUpStalePrincipal(ctx, stalePrincipalID, getArcMachineIdentityID(arcMachine))
+
+	// Step 5: Install, update and remove the declared extensions
+	i.logger.Info("Step 5: Reconciling Arc extensions")
+	if err := i.reconcileExtensions(ctx, arcMachine); err != nil {
+		i.logger.Errorf("Failed to reconcile Arc extensions: %v", err)
+		return fmt.Errorf("arc bootstrap setup failed at extension reconciliation: %w", err)
+	}
 
 	i.logger.Info("Arc setup for bootstrap completed successfully")
 	return nil
@@ -192,6 +224,12 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 	}
 	i.logger.Debug("Checking Arc setup completion status")
 
+	// Declared extensions that changed since they were last reconciled need another run
+	if !extensionsUpToDate(i.config.GetArcExtensions()) {
+		i.logger.Debug("Declared Arc extensions changed since they were last reconciled")
+		return false
+	}
+
 	// Check if Arc services are running
 	if !isArcServicesRunning() {
 		i.logger.Debug("Arc services are not running")
@@ -232,25 +270,78 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 	return false
 }
 
-// registerArcMachine registers the machine with Azure Arc using the Arc agent
-func (i *Installer) registerArcMachine(ctx context.Context) (*armhybridcompute.Machine, error) {
+// registerArcMachine registers the machine with Azure Arc using the Arc agent. An Arc machine resource left behind
+// by an earlier installation is handled according to azure.arc.reinstallStrategy; in that case the principal ID
+// of its identity is returned so its role assignments can be replaced.
+func (i *Installer) registerArcMachine(ctx context.Context) (*armhybridcompute.Machine, string, error) {
 	i.logger.Info("Registering machine with Azure Arc using Arc agent")
 
 	// Check if already registered
 	machine, err := i.getArcMachine(ctx)
-	if err == nil && machine != nil {
+	if err != nil {
+		machine = nil
+	}
+	local, err := i.showAgentStatus(ctx)
+	if err != nil {
+		i.logger.Warnf("Could not read the local Arc agent state: %v", err)
+	}
+	h := i.readHost()
+
+	var stalePrincipalID string
+	state, reason := classifyRegistration(i.config, machine, local, h)
+	if state == registrationForeign && i.config.IsArcAutoSuffixEnabled() {
+		if machine, state, reason, err = i.autoSuffixName(ctx, local, h, reason); err != nil {
+			return nil, "", err
+		}
+	}
+	switch state {
+	case registrationConnected:
 		i.logger.Infof("Machine already registered as Arc machine: %s", to.String(machine.Name))
-		return machine, nil
+		i.recordMachineName()
+		return machine, "", nil
+	case registrationForeign:
+		return nil, "", fmt.Errorf("%s", reason)
+	case registrationStale:
+		stalePrincipalID = getArcMachineIdentityID(machine)
+		if err := i.resolveStaleRegistration(ctx, reason); err != nil {
+			return nil, "", err
+		}
 	}
 
 	// Register using Arc agent command
 	if err := i.runArcAgentConnect(ctx); err != nil {
-		return nil, fmt.Errorf("failed to register Arc machine using agent: %w", err)
+		return nil, "", fmt.Errorf("failed to register Arc machine using agent: %w", err)
 	}
 
 	// make sure registration is complete before proceeding
 	// otherwise role assignment may fail due to identity not found
-	return i.waitForArcRegistration(ctx)
+	var vmID string
+	if local, err := i.showAgentStatus(ctx); err == nil && local != nil {
+		vmID = local.VMID
+	}
+	machine, err = i.waitForArcRegistration(ctx, vmID)
+	if err != nil {
+		return nil, "", err
+	}
+	i.recordMachineName()
+	return machine, stalePrincipalID, nil
+}
+
+// syncMachineTags applies the configured tags to a machine that was connected earlier, e.g. the current
+// cluster after the node switched clusters. A failure only leaves stale metadata, so it is logged.
+func (i *Installer) syncMachineTags(ctx context.Context, arcMachine *armhybridcompute.Machine) {
+	if arcMachine == nil {
+		return
+	}
+	tags, changed := mergeTags(arcMachine.Tags, i.config.GetArcMachineTags())
+	if !changed {
+		return
+	}
+	if err := i.updateArcMachineTags(ctx, tags); err != nil {
+		i.logger.Warnf("Failed to update the Arc machine tags: %v", err)
+		return
+	}
+	i.logger.Info("Updated the Arc machine tags")
 }
 
 func (i *Installer) validateManagedCluster(ctx context.Context) error {
@@ -273,35 +364,35 @@ func (i *Installer) validateManagedCluster(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) waitForArcRegistration(ctx context.Context) (*armhybridcompute.Machine, error) {
-	const (
-		maxRetries   = 10
-		initialDelay = 5 * time.Second
-		maxDelay     = 30 * time.Second
-	)
+// waitForArcRegistration waits until the Arc machine has an identity and, when vmID is known, until it
+// reflects the agent that just connected rather than an earlier installation
+func (i *Installer) waitForArcRegistration(ctx context.Context, vmID string) (*armhybridcompute.Machine, error) {
+	backoff := retry.Backoff{Initial: 5 * time.Second, Max: 30 * time.Second, Attempts: 10, Clock: i.clock, MinDelay: throttle.Shared.Delay}
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	var registered *armhybridcompute.Machine
+	err := retry.Do(ctx, backoff, func(ctx context.Context, attempt int) error {
 		machine, err := i.getArcMachine(ctx)
 		if err == nil &&
 			machine != nil &&
 			machine.Identity != nil &&
-			machine.Identity.PrincipalID != nil {
-			return machine, nil // Success!
+			machine.Identity.PrincipalID != nil &&
+			(vmID == "" || machine.Properties == nil || strings.EqualFold(to.String(machine.Properties.VMID), vmID)) {
+			registered = machine
+			return nil
 		}
-		i.logger.Infof("Arc machine not yet registered (attempt %d/%d): %s", attempt+1, maxRetries, err)
-
-		delay := min(initialDelay*time.Duration(1<<attempt), maxDelay)
-		i.logger.Infof("Registration attempt %d/%d, waiting %v...", attempt+1, maxRetries, delay)
-
-		select {
-		case <-time.After(delay):
-			continue
-		case <-ctx.Done():
-			return nil, ctx.Err()
+		if err == nil {
+			err = errors.New("machine identity is not ready")
 		}
+		i.logger.Infof("Arc machine not yet registered (attempt %d/%d): %s", attempt, backoff.Attempts, err)
+		return err
+	})
+	if errors.Is(err, retry.ErrExhausted) {
+		return nil, fmt.Errorf("arc registration timed out after %d attempts", backoff.Attempts)
 	}
-
-	return nil, fmt.Errorf("arc registration timed out after %d attempts", maxRetries)
+	if err != nil {
+		return nil, err
+	}
+	return registered, nil
 }
 
 // runArcAgentConnect connects the machine to Azure Arc using the Arc agent
@@ -326,13 +417,18 @@ func (i *Installer) runArcAgentConnect(ctx context.Context) error {
 	}
 
 	// Add Arc tags if any
-	tags := i.config.GetArcTags()
+	tags := i.config.GetArcMachineTags()
 	tagArgs := []string{}
 	for key, value := range tags {
 		tagArgs = append(tagArgs, "--tags", fmt.Sprintf("%s=%s", key, value))
 	}
 	args = append(args, tagArgs...)
 
+	// Connect through the Arc private link scope so the agent uses private endpoints
+	if scopeID := i.config.GetArcPrivateLinkScopeID(); scopeID != "" {
+		args = append(args, "--private-link-scope", scopeID)
+	}
+
 	// Add authentication parameters
 	// For CLI authentication, we need to preserve the user's environment
 	if err := i.addAuthenticationArgs(ctx, &args); err != nil {
@@ -355,26 +451,15 @@ func (i *Installer) assignRBACRoles(ctx context.Context, arcMachine *armhybridco
 		return fmt.Errorf("managed identity ID not found on Arc machine")
 	}
 
-	// Track assignment results
-	requiredRoles := i.getRoleAssignments()
-	var assignmentErrors []error
-	for idx, role := range requiredRoles {
-		i.logger.Infof("📋 [%d/%d] Assigning role '%s' on scope: %s", idx+1, len(requiredRoles), role.roleName, role.scope)
-
-		if err := i.assignRole(ctx, managedIdentityID, role.roleID, role.scope, role.roleName); err != nil {
-			i.logger.Errorf("❌ Failed to assign role '%s': %v", role.roleName, err)
-			assignmentErrors = append(assignmentErrors, fmt.Errorf("role '%s': %w", role.roleName, err))
-		} else {
-			i.logger.Infof("✅ Successfully assigned role '%s'", role.roleName)
-		}
+	// Reconcile built-in and configured role assignments as one set
+	desired := toSpecs(i.getRoleAssignments(), managedIdentityID)
+	result, err := i.roleAssigner().Reconcile(ctx, desired, i.config.IsArcRoleAssignmentPruneEnabled())
+	if result != nil && len(result.Pruned) > 0 {
+		i.logger.Infof("🧹 Pruned %d stale role assignments", len(result.Pruned))
 	}
-
-	if len(assignmentErrors) > 0 {
-		i.logger.Errorf("⚠️  RBAC role assignment completed with %d failures", len(assignmentErrors))
-		for _, err := range assignmentErrors {
-			i.logger.Errorf("   - %v", err)
-		}
-		return fmt.Errorf("failed to assign %d out of %d RBAC roles", len(assignmentErrors), len(requiredRoles))
+	if err != nil {
+		i.logger.Errorf("⚠️  RBAC role assignment completed with failures: %v", err)
+		return fmt.Errorf("failed to reconcile %d RBAC role assignments: %w", len(desired), err)
 	}
 
 	// wait for permissions to propagate
@@ -393,115 +478,36 @@ func (i *Installer) assignRBACRoles(ctx context.Context, arcMachine *armhybridco
 func (i *Installer) assignRole(
 	ctx context.Context, principalID, roleDefinitionID, scope, roleName string,
 ) error {
-	// Build the full role definition ID
-	fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
-		i.config.Azure.SubscriptionID, roleDefinitionID)
-
-	const (
-		maxRetries   = 5
-		initialDelay = 5 * time.Second
-		maxDelay     = 30 * time.Second
-	)
-
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			delay := min(initialDelay*time.Duration(1<<(attempt-1)), maxDelay)
-			i.logger.Infof("⏳ Retrying role assignment after %v (attempt %d/%d)...", delay, attempt+1, maxRetries)
-			select {
-			case <-time.After(delay):
-			case <-ctx.Done():
-				return ctx.Err()
-			}
-		}
-
-		roleAssignmentName := uuid.New().String()
-		i.logger.Debugf("Calling Azure API to create role assignment with ID: %s (attempt %d/%d)", roleAssignmentName, attempt+1, maxRetries)
-
-		// Set PrincipalType to ServicePrincipal for Arc managed identities
-		// This helps Azure work around replication delays when the identity was just created
-		principalType := armauthorization.PrincipalTypeServicePrincipal
-		assignment := armauthorization.RoleAssignmentCreateParameters{
-			Properties: &armauthorization.RoleAssignmentProperties{
-				PrincipalID:      &principalID,
-				RoleDefinitionID: &fullRoleDefinitionID,
-				PrincipalType:    &principalType,
-			},
-		}
-
-		// this create operation is synchronous - we need to wait for the role propagation to take effect afterwards
-		if _, err := i.roleAssignmentsClient.Create(ctx, scope, roleAssignmentName, assignment, nil); err != nil {
-			lastErr = err
-			errStr := err.Error()
-
-			// Check for common error patterns
-			if strings.Contains(errStr, "403") || strings.Contains(errStr, "Forbidden") {
-				return fmt.Errorf("insufficient permissions to assign roles - ensure the user/service principal has Owner or User Access Administrator role on the target cluster: %w", err)
-			}
-			if strings.Contains(errStr, "RoleAssignmentExists") {
-				i.logger.Info("ℹ️  Role assignment already exists (detected from error)")
-				return nil
-			}
-
-			// PrincipalNotFound is retriable - likely Azure AD replication delay
-			if strings.Contains(errStr, "PrincipalNotFound") {
-				i.logger.Warnf("⚠️  Principal not found (Azure AD replication delay) - will retry...")
-				// Provide detailed error information on last attempt only
-				if attempt == maxRetries-1 {
-					i.logger.Errorf("❌ Role assignment creation failed after %d attempts:", maxRetries)
-					i.logger.Errorf("   Principal ID: %s", principalID)
-					i.logger.Errorf("   Role Name: %s", roleName)
-					i.logger.Errorf("   Role Definition ID: %s", fullRoleDefinitionID)
-					i.logger.Errorf("   Scope: %s", scope)
-					i.logger.Errorf("   Assignment Name: %s", roleAssignmentName)
-					i.logger.Errorf("   Azure API Error: %v", err)
-				}
-				continue // Retry
-			}
-
-			// Non-retriable error - log details and return
-			i.logger.Errorf("❌ Role assignment creation failed:")
-			i.logger.Errorf("   Principal ID: %s", principalID)
-			i.logger.Errorf("   Role Name: %s", roleName)
-			i.logger.Errorf("   Role Definition ID: %s", fullRoleDefinitionID)
-			i.logger.Errorf("   Scope: %s", scope)
-			i.logger.Errorf("   Assignment Name: %s", roleAssignmentName)
-			i.logger.Errorf("   Azure API Error: %v", err)
-			return fmt.Errorf("failed to create role assignment: %s", err)
-		}
-
-		// Success
-		i.logger.Debugf("✅ Role assignment created successfully")
-		return nil
-	}
-
-	// Max retries exhausted
-	return fmt.Errorf("failed to assign role after %d attempts due to Azure AD replication delay - arc managed identity not found: %w", maxRetries, lastErr)
+	return i.roleAssigner().EnsureAssignment(ctx, rbac.AssignmentSpec{
+		PrincipalID:      principalID,
+		RoleDefinitionID: roleDefinitionID,
+		Scope:            scope,
+		RoleName:         roleName,
+	})
 }
 
 // waitForPermissions waits for RBAC permissions propagation with timeout
 func (i *Installer) waitForPermissions(ctx context.Context, managedIdentityID string) error {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	maxWaitTime := 10 * time.Minute // Maximum wait time
-	timeout := time.After(maxWaitTime)
+	const (
+		pollInterval = 10 * time.Second
+		maxWaitTime  = 10 * time.Minute
+	)
+	deadline := i.clock.Now().Add(maxWaitTime)
 
 	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("context cancelled while waiting for permissions: %w", ctx.Err())
-		case <-timeout:
+		if err := retry.Sleep(ctx, i.clock, pollInterval); err != nil {
+			return fmt.Errorf("context cancelled while waiting for permissions: %w", err)
+		}
+		if hasPermissions, err := i.checkRequiredPermissions(ctx, managedIdentityID); err == nil && hasPermissions {
+			i.logger.Info("✅ All required RBAC permissions are now available!")
+			return nil
+		} else if err != nil {
+			i.logger.Warnf("Error while checking permissions: %s", err)
+		}
+		if !i.clock.Now().Before(deadline) {
 			return fmt.Errorf("timeout after %v waiting for RBAC permissions to be assigned", maxWaitTime)
-		case <-ticker.C:
-			if hasPermissions, err := i.checkRequiredPermissions(ctx, managedIdentityID); err == nil && hasPermissions {
-				i.logger.Info("✅ All required RBAC permissions are now available!")
-				return nil
-			} else if err != nil {
-				i.logger.Warnf("Error while checking permissions: %s", err)
-			}
-			i.logger.Info("⏳ Some permissions are still missing, will check again in 10 seconds...")
 		}
+		i.logger.Info("⏳ Some permissions are still missing, will check again in 10 seconds...")
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
 )
 
 // mockRoleAssignmentsClient is a mock implementation for testing
@@ -72,11 +74,13 @@ func TestAssignRole_Success(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -116,19 +120,19 @@ func TestAssignRole_PrincipalNotFound_RetriesAndSucceeds(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
 	// Execute
 	ctx := context.Background()
-	startTime := time.Now()
 	err := installer.assignRole(ctx, "test-principal-id", "test-role-id", "/test/scope", "TestRole")
-	duration := time.Since(startTime)
 
 	// Verify
 	if err != nil {
@@ -137,9 +141,9 @@ func TestAssignRole_PrincipalNotFound_RetriesAndSucceeds(t *testing.T) {
 	if mockClient.callCount != 3 {
 		t.Errorf("Expected 3 API calls (2 failures + 1 success), got %d", mockClient.callCount)
 	}
-	// Should have delays: 5s + 10s = 15s (with some tolerance)
-	if duration < 14*time.Second {
-		t.Errorf("Expected at least 15s of retries, got %v", duration)
+	// Should have delays: 5s + 10s
+	if want := []time.Duration{5 * time.Second, 10 * time.Second}; !slices.Equal(clock.Sleeps(), want) {
+		t.Errorf("Expected retry delays %v, got %v", want, clock.Sleeps())
 	}
 }
 
@@ -161,11 +165,13 @@ func TestAssignRole_PrincipalNotFound_ExhaustsRetries(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -205,11 +211,13 @@ func TestAssignRole_ForbiddenError_NoRetry(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -246,11 +254,13 @@ func TestAssignRole_RoleAssignmentExists_ReturnsSuccess(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -278,29 +288,25 @@ func TestAssignRole_ContextCancellation(t *testing.T) {
 		},
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	mockClient := &mockRoleAssignmentsClient{
 		createFunc: func(ctx context.Context, scope string, roleAssignmentName string, parameters armauthorization.RoleAssignmentCreateParameters, options *armauthorization.RoleAssignmentsClientCreateOptions) (armauthorization.RoleAssignmentsClientCreateResponse, error) {
-			// Always fail to trigger retry
+			// Cancel after the first attempt, while the retry is pending
+			cancel()
 			return armauthorization.RoleAssignmentsClientCreateResponse{}, newMockResponseError("PrincipalNotFound", "Principal does not exist")
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
-	// Execute with cancelled context
-	ctx, cancel := context.WithCancel(context.Background())
-	// Cancel after first attempt triggers retry
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		cancel()
-	}()
-
 	err := installer.assignRole(ctx, "test-principal-id", "test-role-id", "/test/scope", "TestRole")
 
 	// Verify - should fail with context error
@@ -310,6 +316,9 @@ func TestAssignRole_ContextCancellation(t *testing.T) {
 	if !errors.Is(err, context.Canceled) {
 		t.Errorf("Expected context.Canceled error, got: %v", err)
 	}
+	if mockClient.callCount != 1 {
+		t.Errorf("Expected no attempts after cancellation, got %d calls", mockClient.callCount)
+	}
 }
 
 func TestAssignRole_GenericError_NoRetry(t *testing.T) {
@@ -329,11 +338,13 @@ func TestAssignRole_GenericError_NoRetry(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -371,11 +382,13 @@ func TestAssignRole_PrincipalTypeIsSetCorrectly(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	installer := &Installer{
 		base: &base{
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -403,10 +416,11 @@ func TestAssignRole_ExponentialBackoff(t *testing.T) {
 		},
 	}
 
+	clock := retry.NewFakeClock(time.Now())
 	var attemptTimes []time.Time
 	mockClient := &mockRoleAssignmentsClient{
 		createFunc: func(ctx context.Context, scope string, roleAssignmentName string, parameters armauthorization.RoleAssignmentCreateParameters, options *armauthorization.RoleAssignmentsClientCreateOptions) (armauthorization.RoleAssignmentsClientCreateResponse, error) {
-			attemptTimes = append(attemptTimes, time.Now())
+			attemptTimes = append(attemptTimes, clock.Now())
 			// Fail first 3 attempts, succeed on 4th
 			if len(attemptTimes) < 4 {
 				return armauthorization.RoleAssignmentsClientCreateResponse{}, newMockResponseError("PrincipalNotFound", "Principal does not exist")
@@ -420,6 +434,7 @@ func TestAssignRole_ExponentialBackoff(t *testing.T) {
 			config:                cfg,
 			logger:                logger,
 			roleAssignmentsClient: mockClient,
+			clock:                 clock,
 		},
 	}
 
@@ -435,19 +450,11 @@ func TestAssignRole_ExponentialBackoff(t *testing.T) {
 		t.Fatalf("Expected 4 attempts, got %d", len(attemptTimes))
 	}
 
-	// Check backoff delays: attempt1->attempt2 (~5s), attempt2->attempt3 (~10s), attempt3->attempt4 (~20s)
-	delays := []time.Duration{
-		attemptTimes[1].Sub(attemptTimes[0]),
-		attemptTimes[2].Sub(attemptTimes[1]),
-		attemptTimes[3].Sub(attemptTimes[2]),
-	}
-
+	// Check backoff delays: attempt1->attempt2 (5s), attempt2->attempt3 (10s), attempt3->attempt4 (20s)
 	expectedDelays := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second}
-	tolerance := 500 * time.Millisecond
-
-	for i, delay := range delays {
-		if delay < expectedDelays[i]-tolerance || delay > expectedDelays[i]+tolerance {
-			t.Errorf("Attempt %d->%d: expected delay ~%v, got %v", i+1, i+2, expectedDelays[i], delay)
+	for i, expected := range expectedDelays {
+		if delay := attemptTimes[i+1].Sub(attemptTimes[i]); delay != expected {
+			t.Errorf("Attempt %d->%d: expected delay %v, got %v", i+1, i+2, expected, delay)
 		}
 	}
 }
@@ -2,29 +2,45 @@ package arc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"slices"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
 	"github.com/sirupsen/logrus"
 
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/rbac"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
+// Replaced in tests
+var (
+	// machineStatePaths hold the agent's local state about the Arc machine and its identity
+	machineStatePaths = []string{ExtensionsStatePath, RoleAssignmentReportPath}
+	forgetMachineName = func() error { return config.RecordArcMachineName(nil) }
+)
+
 // UnInstaller handles Azure Arc cleanup operations
 type UnInstaller struct {
 	*base
 }
 
 // NewUnInstaller creates a new Arc UnInstaller
-func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
 	return &UnInstaller{
-		base: newBase(logger),
+		base: newBase(cfg, logger),
 	}
 }
 
+// AgentServices returns the Arc agent's systemd services, which the UnInstaller stops and removes
+func AgentServices() []string {
+	return arcServices
+}
+
 // GetName returns the cleanup step name
 func (u *UnInstaller) GetName() string {
 	return "ArcUnbootstrap"
@@ -42,38 +58,24 @@ func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 
 // Execute performs Arc cleanup as part of the unbootstrap process
 // This method is designed to be called from unbootstrap steps and handles all Arc-related cleanup
-// It's resilient to failures and continues cleanup even if some operations fail
+// It's resilient to failures and continues cleanup even if some operations fail. The Arc machine may
+// already have been deleted from Azure, e.g. from the portal; the role assignments the agent recorded
+// and the local agent state are cleaned up anyway, and what could not be done is reported.
 func (u *UnInstaller) Execute(ctx context.Context) error {
 	u.logger.Info("Starting Arc cleanup for unbootstrap process")
 
+	var failedOperations, skippedOperations []string
+
 	// Step 1: Set up Azure SDK clients
 	u.logger.Info("Step 1: Setting up Azure SDK clients")
-	if err := u.setUpClients(ctx); err != nil {
-		return fmt.Errorf("arc bootstrap setup failed at client setup: %w", err)
-	}
-
-	arcMachine, err := u.getArcMachine(ctx)
-	if err != nil {
-		u.logger.Warnf("Failed to get Arc machine (continuing cleanup): %v", err)
-	}
-
-	var failedOperations []string
-	// Step 2: Remove RBAC role assignments first (while authentication still works)
-	u.logger.Info("Step 2: Removing RBAC role assignments")
-	if err := u.removeRBACRoles(ctx, arcMachine); err != nil {
-		u.logger.Warnf("Failed to remove RBAC roles (continuing cleanup): %v", err)
-		failedOperations = append(failedOperations, "RBAC role removal")
-	} else {
-		u.logger.Info("Successfully removed RBAC role assignments")
-	}
-
-	// Step 3: Unregister Arc machine resource from Azure
-	u.logger.Info("Step 3: Unregistering Arc machine from Azure")
-	if err := u.unregisterArcMachine(ctx); err != nil {
-		u.logger.Warnf("Failed to unregister Arc machine (continuing cleanup): %v", err)
-		failedOperations = append(failedOperations, "Arc machine unregistration")
+	clientErr := u.setUpClients(ctx)
+	if clientErr != nil {
+		u.logger.Warnf("Failed to set up Azure clients, cleaning up the local Arc agent only: %v", clientErr)
+		skippedOperations = append(skippedOperations, "RBAC role removal (no Azure access)", "Arc machine unregistration (no Azure access)")
 	} else {
-		u.logger.Info("Successfully unregistered Arc machine from Azure")
+		failed, skipped := u.cleanUpAzure(ctx)
+		failedOperations = append(failedOperations, failed...)
+		skippedOperations = append(skippedOperations, skipped...)
 	}
 
 	// Step 4: Disconnect Arc machine
@@ -96,6 +98,14 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 	}
 
 	// Report results
+	if len(skippedOperations) > 0 {
+		u.logger.Warnf("Arc cleanup skipped %d operations: %s", len(skippedOperations), strings.Join(skippedOperations, ", "))
+	}
+	if clientErr != nil {
+		// The node is clean, but the Arc machine and its role assignments are left in Azure
+		return fmt.Errorf("arc cleanup could not reach Azure, remove the Arc machine %s and its role assignments manually or re-run unbootstrap: %w",
+			u.config.GetArcMachineName(), clientErr)
+	}
 	if len(failedOperations) > 0 {
 		u.logger.Warnf("Arc cleanup completed with %d failed operations: %s",
 			len(failedOperations), strings.Join(failedOperations, ", "))
@@ -107,26 +117,120 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 	return nil
 }
 
+// cleanUpAzure removes the role assignments and the Arc machine resource, and returns the operations that
+// failed and the ones skipped because there was nothing left to do
+func (u *UnInstaller) cleanUpAzure(ctx context.Context) (failed, skipped []string) {
+	arcMachine, err := u.getArcMachine(ctx)
+	machineDeleted := azerrors.IsNotFound(err)
+	switch {
+	case machineDeleted:
+		u.logger.Warnf("Arc machine %s was already deleted from Azure (continuing cleanup)", u.config.GetArcMachineName())
+	case err != nil:
+		u.logger.Warnf("Failed to get Arc machine (continuing cleanup): %v", err)
+	}
+
+	// Step 2: Remove RBAC role assignments first (while authentication still works)
+	u.logger.Info("Step 2: Removing RBAC role assignments")
+	var roleErr error
+	if machineDeleted {
+		var skip string
+		skip, roleErr = u.removeOrphanedRoleAssignments(ctx)
+		if skip != "" {
+			skipped = append(skipped, skip)
+		}
+	} else {
+		roleErr = u.removeRBACRoles(ctx, arcMachine)
+	}
+	if roleErr != nil {
+		u.logger.Warnf("Failed to remove RBAC roles (continuing cleanup): %v", roleErr)
+		failed = append(failed, "RBAC role removal")
+	} else {
+		u.logger.Info("Successfully removed RBAC role assignments")
+	}
+
+	// Step 3: Unregister Arc machine resource from Azure
+	u.logger.Info("Step 3: Unregistering Arc machine from Azure")
+	if machineDeleted {
+		skipped = append(skipped, "Arc machine unregistration (already deleted)")
+		u.removeMachineState()
+		return failed, skipped
+	}
+	if err := u.unregisterArcMachine(ctx); err != nil {
+		u.logger.Warnf("Failed to unregister Arc machine (continuing cleanup): %v", err)
+		failed = append(failed, "Arc machine unregistration")
+	} else {
+		u.logger.Info("Successfully unregistered Arc machine from Azure")
+	}
+	return failed, skipped
+}
+
 // unregisterArcMachine removes the Arc machine registration from Azure
 func (u *UnInstaller) unregisterArcMachine(ctx context.Context) error {
 	u.logger.Info("Unregistering Arc machine from Azure")
-	arcMachineName := u.config.GetArcMachineName()
-	arcResourceGroup := u.config.GetArcResourceGroup()
-	u.logger.Infof("Deleting Arc machine resource: %s in resource group: %s", arcMachineName, arcResourceGroup)
-
-	// Delete the Arc machine resource from Azure
-	if _, err := u.hybridComputeMachineClient.Delete(ctx, arcResourceGroup, arcMachineName, nil); err != nil {
-		if strings.Contains(err.Error(), "ResourceNotFound") || strings.Contains(err.Error(), "NotFound") {
-			u.logger.Info("Arc machine resource not found (already deleted)")
-			return nil
-		}
-		return fmt.Errorf("failed to delete Arc machine resource: %w", err)
+	if err := u.deleteArcMachine(ctx); err != nil {
+		return err
 	}
-
+	u.removeMachineState()
 	u.logger.Info("Arc machine successfully unregistered from Azure")
 	return nil
 }
 
+// removeMachineState removes the local state about an Arc machine that no longer exists in Azure
+func (u *UnInstaller) removeMachineState() {
+	// Deleting the machine removes its extensions, and its identity's role assignments no longer need checking
+	for _, path := range machineStatePaths {
+		if err := utils.RunCleanupCommand(path); err != nil {
+			u.logger.Debugf("Failed to remove %s: %v (may not exist)", path, err)
+		}
+	}
+	// A later installation derives its name afresh
+	if err := forgetMachineName(); err != nil {
+		u.logger.Debugf("Failed to remove the recorded Arc machine name: %v", err)
+	}
+}
+
+// PlannedRemovals describes the Azure resources the UnInstaller would delete, without contacting Azure: the Arc
+// machine with its extensions, and the role assignments the agent recorded creating. Nodes bootstrapped before
+// the record existed list the configured role assignments the agent manages instead.
+func (u *UnInstaller) PlannedRemovals(ctx context.Context) []string {
+	if !u.config.IsARCEnabled() {
+		return nil
+	}
+	removals := []string{fmt.Sprintf("Arc machine /subscriptions/%s/resourceGroups/%s/providers/Microsoft.HybridCompute/machines/%s and its extensions",
+		u.config.Azure.SubscriptionID, u.config.GetArcResourceGroup(), u.config.GetArcMachineName())}
+
+	var recorded []rbac.Assignment
+	err := rbac.ErrNoLedger
+	if u.ledger != nil {
+		recorded, err = u.ledger.List(ctx)
+	}
+	if err == nil {
+		roleNames := map[string]string{}
+		for name, id := range roleDefinitionIDs {
+			roleNames[id] = name
+		}
+		for _, assignment := range recorded {
+			role := assignment.RoleDefinitionID[strings.LastIndex(assignment.RoleDefinitionID, "/")+1:]
+			if name, ok := roleNames[role]; ok {
+				role = name
+			}
+			removals = append(removals, fmt.Sprintf("role assignment %s (%s for principal %s) on %s", assignment.Name, role, assignment.PrincipalID, assignment.Scope))
+		}
+		return removals
+	}
+	if !errors.Is(err, rbac.ErrNoLedger) {
+		u.logger.Warnf("Failed to read the recorded role assignments: %v", err)
+	}
+	for _, role := range u.getRoleAssignments() {
+		principal := role.principalID
+		if principal == "" {
+			principal = "the Arc machine's identity"
+		}
+		removals = append(removals, fmt.Sprintf("role assignment %s for %s on %s, if created by the agent", role.roleName, principal, role.scope))
+	}
+	return removals
+}
+
 // removeRBACRoles removes all RBAC role assignments for the Arc machine's managed identity
 func (u *UnInstaller) removeRBACRoles(ctx context.Context, arcMachine *armhybridcompute.Machine) error {
 	managedIdentityID := getArcMachineIdentityID(arcMachine)
@@ -134,29 +238,41 @@ func (u *UnInstaller) removeRBACRoles(ctx context.Context, arcMachine *armhybrid
 		u.logger.Info("No managed identity found for Arc machine")
 		return nil
 	}
+	return u.removeRoleAssignmentsFor(ctx, managedIdentityID, u.getRoleAssignments())
+}
 
-	u.logger.Infof("Removing role assignments for managed identity: %s", managedIdentityID)
+// removeOrphanedRoleAssignments removes the role assignments the agent recorded creating after the Arc machine
+// was deleted from Azure. The machine's identity went with it, so its principal ID cannot be looked up; every
+// principal the agent recorded an assignment for on this node is cleaned up instead. Without a record, the
+// identity's assignments cannot be told apart from others, so they are skipped and the description of what
+// was skipped is returned.
+func (u *UnInstaller) removeOrphanedRoleAssignments(ctx context.Context) (string, error) {
+	var recorded []rbac.Assignment
+	err := rbac.ErrNoLedger
+	if u.ledger != nil {
+		recorded, err = u.ledger.List(ctx)
+	}
+	if errors.Is(err, rbac.ErrNoLedger) {
+		u.logger.Warn("No record of the role assignments the agent created; assignments of the deleted Arc machine's identity " +
+			"are left and show as \"Identity not found\" on their scopes")
+		return "RBAC role removal for the deleted Arc machine's identity (not recorded)", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the recorded role assignments: %w", err)
+	}
 
-	// Define the scopes where we assigned roles
-	// Remove each role assignment
-	var removalErrors []string
-	rolesToRemove := u.getRoleAssignments()
-	for _, role := range rolesToRemove {
-		u.logger.Infof("Removing role assignment: %s on scope %s", role.roleName, role.scope)
-		if err := u.removeRoleAssignment(ctx, managedIdentityID, role.roleID, role.scope, role.roleName); err != nil {
-			u.logger.Warnf("Failed to remove role assignment %s on scope %s: %v", role.roleName, role.scope, err)
-			removalErrors = append(removalErrors, fmt.Sprintf("%s: %v", role.roleName, err))
-		} else {
-			u.logger.Infof("Successfully removed role assignment: %s on scope %s", role.roleName, role.scope)
+	var principals []string
+	for _, assignment := range recorded {
+		if !slices.Contains(principals, assignment.PrincipalID) {
+			principals = append(principals, assignment.PrincipalID)
 		}
 	}
-
-	if len(removalErrors) > 0 {
-		return fmt.Errorf("failed to remove some role assignments: %s", strings.Join(removalErrors, "; "))
+	removed, err := u.roleAssigner().RemoveRecorded(ctx, principals...)
+	if err != nil {
+		return "", fmt.Errorf("failed to remove some role assignments: %w", err)
 	}
-
-	u.logger.Info("All RBAC role assignments removed successfully")
-	return nil
+	u.logger.Infof("Removed %d role assignments created by the agent", len(removed))
+	return "", nil
 }
 
 // disconnectArcMachine disconnects the machine using azcmagent
@@ -173,59 +289,6 @@ func (u *UnInstaller) disconnectArcMachine(ctx context.Context) error {
 	return nil
 }
 
-// removeRoleAssignment removes role assignment for a specific principal, role, and scope
-func (u *UnInstaller) removeRoleAssignment(ctx context.Context, principalID, roleDefinitionID, scope, roleName string) error {
-	// Build the full role definition ID
-	fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
-		u.config.Azure.SubscriptionID, roleDefinitionID)
-
-	// List role assignment for the scope
-	pager := u.roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
-		Filter: nil, // We'll filter programmatically
-	})
-
-	var assignmentsToDelete []string
-	for pager.More() {
-		page, err := pager.NextPage(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
-		}
-
-		// Find matching role assignments
-		for _, assignment := range page.Value {
-			if assignment.Properties != nil &&
-				assignment.Properties.PrincipalID != nil &&
-				assignment.Properties.RoleDefinitionID != nil &&
-				*assignment.Properties.PrincipalID == principalID &&
-				*assignment.Properties.RoleDefinitionID == fullRoleDefinitionID {
-				if assignment.Name != nil {
-					assignmentsToDelete = append(assignmentsToDelete, *assignment.Name)
-				}
-			}
-		}
-	}
-
-	// Delete found assignments
-	for _, assignmentName := range assignmentsToDelete {
-		u.logger.Debugf("Deleting role assignment: %s", assignmentName)
-		_, err := u.roleAssignmentsClient.Delete(ctx, scope, assignmentName, nil)
-		if err != nil {
-			if strings.Contains(err.Error(), "RoleAssignmentNotFound") || strings.Contains(err.Error(), "NotFound") {
-				u.logger.Debugf("Role assignment %s not found (already deleted)", assignmentName)
-				continue
-			}
-			return fmt.Errorf("failed to delete role assignment %s: %w", assignmentName, err)
-		}
-		u.logger.Debugf("Successfully deleted role assignment: %s", assignmentName)
-	}
-
-	if len(assignmentsToDelete) == 0 {
-		u.logger.Debugf("No role assignments found for role %s on scope %s", roleName, scope)
-	}
-
-	return nil
-}
-
 // removeArcAgentBinary removes Arc agent binaries, services, and configuration files
 func (u *UnInstaller) removeArcAgentBinary(ctx context.Context) error {
 	u.logger.Info("Removing Azure Arc agent binaries and configuration...")
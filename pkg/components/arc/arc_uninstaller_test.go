@@ -0,0 +1,150 @@
+package arc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/rbac"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// deleteRecorder records the role assignments deleted through it
+type deleteRecorder struct {
+	mockRoleAssignmentsClient
+	deleted []string
+}
+
+func (d *deleteRecorder) Delete(ctx context.Context, scope string, roleAssignmentName string, options *armauthorization.RoleAssignmentsClientDeleteOptions) (armauthorization.RoleAssignmentsClientDeleteResponse, error) {
+	d.deleted = append(d.deleted, roleAssignmentName)
+	return armauthorization.RoleAssignmentsClientDeleteResponse{}, nil
+}
+
+func TestRemoveOrphanedRoleAssignments(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	cfg := &config.Config{Azure: config.AzureConfig{SubscriptionID: "test-sub-id"}}
+
+	t.Run("recorded assignments are removed", func(t *testing.T) {
+		ledger := rbac.NewFileLedger(filepath.Join(t.TempDir(), "role-assignments.json"))
+		for _, assignment := range []rbac.Assignment{
+			{ID: "/assignments/a1", Name: "a1", PrincipalID: "deleted-machine-identity", Scope: "/subscriptions/test-sub-id/resourceGroups/rg"},
+			{ID: "/assignments/a2", Name: "a2", PrincipalID: "deleted-machine-identity", Scope: "/subscriptions/test-sub-id/resourceGroups/nodes"},
+			{ID: "/assignments/a3", Name: "a3", PrincipalID: "kubelet-identity", Scope: "/subscriptions/test-sub-id/resourceGroups/rg"},
+		} {
+			if err := ledger.Record(context.Background(), assignment); err != nil {
+				t.Fatal(err)
+			}
+		}
+		client := &deleteRecorder{}
+		u := &UnInstaller{base: &base{config: cfg, logger: logger, roleAssignmentsClient: client, ledger: ledger}}
+
+		skipped, err := u.removeOrphanedRoleAssignments(context.Background())
+		if err != nil || skipped != "" {
+			t.Fatalf("removeOrphanedRoleAssignments() = %q, %v, want all removed", skipped, err)
+		}
+		slices.Sort(client.deleted)
+		if want := []string{"a1", "a2", "a3"}; !slices.Equal(client.deleted, want) {
+			t.Errorf("deleted %v, want %v", client.deleted, want)
+		}
+		if left, _ := ledger.List(context.Background()); len(left) != 0 {
+			t.Errorf("ledger still lists %v, want the removed assignments forgotten", left)
+		}
+	})
+
+	t.Run("nothing recorded", func(t *testing.T) {
+		client := &deleteRecorder{}
+		u := &UnInstaller{base: &base{config: cfg, logger: logger, roleAssignmentsClient: client}}
+
+		skipped, err := u.removeOrphanedRoleAssignments(context.Background())
+		if err != nil || !strings.Contains(skipped, "not recorded") {
+			t.Fatalf("removeOrphanedRoleAssignments() = %q, %v, want the removal reported as skipped", skipped, err)
+		}
+		if len(client.deleted) != 0 {
+			t.Errorf("deleted %v, want nothing without a record", client.deleted)
+		}
+	})
+}
+
+// deletedMachineTransport answers ARM as if the Arc machine was deleted and records the methods it was sent
+type deletedMachineTransport struct {
+	methods []string
+}
+
+func (d *deletedMachineTransport) Do(req *http.Request) (*http.Response, error) {
+	d.methods = append(d.methods, req.Method)
+	return &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"ResourceNotFound","message":"The Resource 'Microsoft.HybridCompute/machines/edge-01' was not found."}}`)),
+		Request:    req,
+	}, nil
+}
+
+type staticCredential struct{}
+
+func (staticCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token"}, nil
+}
+
+func TestCleanUpAzureDeletedMachine(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	cfg := &config.Config{Azure: config.AzureConfig{
+		SubscriptionID: "test-sub-id",
+		Arc:            &config.ArcConfig{MachineName: "edge-01", ResourceGroup: "rg"},
+	}}
+
+	dir := t.TempDir()
+	statePaths := []string{filepath.Join(dir, "arc-extensions.json"), filepath.Join(dir, "role-assignment-report")}
+	for _, path := range statePaths {
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forgotName := false
+	originalPaths, originalForget := machineStatePaths, forgetMachineName
+	machineStatePaths = statePaths
+	forgetMachineName = func() error { forgotName = true; return nil }
+	defer func() { machineStatePaths, forgetMachineName = originalPaths, originalForget }()
+
+	transport := &deletedMachineTransport{}
+	machines, err := armhybridcompute.NewMachinesClient("test-sub-id", staticCredential{}, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{Transport: transport, Retry: policy.RetryOptions{MaxRetries: -1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &UnInstaller{base: &base{config: cfg, logger: logger, hybridComputeMachineClient: machines, roleAssignmentsClient: &deleteRecorder{}}}
+
+	failed, skipped := u.cleanUpAzure(context.Background())
+	if len(failed) != 0 {
+		t.Errorf("failed = %v, want nothing to fail", failed)
+	}
+	if !slices.Contains(skipped, "Arc machine unregistration (already deleted)") {
+		t.Errorf("skipped = %v, want the unregistration skipped", skipped)
+	}
+	if slices.Contains(transport.methods, http.MethodDelete) {
+		t.Errorf("sent %v, want no DELETE for a machine that is already gone", transport.methods)
+	}
+	for _, path := range statePaths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists, want the local state removed", path)
+		}
+	}
+	if !forgotName {
+		t.Error("the recorded Arc machine name was kept, want it removed")
+	}
+}
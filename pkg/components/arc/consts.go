@@ -3,6 +3,9 @@ package arc
 const (
 	// Arc agent installation script URL
 	arcInstallScriptURL = "https://gbl.his.arc.azure.com/azcmagent-linux"
+
+	// Records the role assignments the agent created, so unbootstrap removes exactly those
+	roleAssignmentLedgerPath = "/var/lib/aks-flex-node/role-assignments.json"
 )
 
 var (
@@ -13,6 +16,8 @@ var (
 		"Contributor":         "b24988ac-6180-42a0-ab88-20f7382dd24c",
 		"Azure Kubernetes Service RBAC Cluster Admin": "b1ff04bb-8a4e-4dc4-8eb5-8693973ce19b",
 		"Azure Kubernetes Service Cluster Admin Role": "0ab0b1a8-8aac-4efd-b8c2-3ee1fb270be8",
+		"Storage Blob Data Reader":                    "2a2b9908-6ea1-4ae2-8e65-a410df84e7d1",
+		"Storage Blob Data Contributor":               "ba92f5b4-2d11-453d-a403-e96b0029c9fe",
 	}
 
 	// Arc services that may be present (not all are guaranteed to exist on every installation)
@@ -0,0 +1,387 @@
+package arc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ExtensionsStatePath records the provisioning state of the declared Arc extensions for the node status
+const ExtensionsStatePath = "/var/lib/aks-flex-node/arc-extensions.json"
+
+// managedExtensionTag marks the extensions the agent installed; only those are removed when no longer declared
+const managedExtensionTag = "aks-flex-node-managed"
+
+// extensionTimeout bounds how long an extension may take to install, update or uninstall on the machine
+const extensionTimeout = 20 * time.Minute
+
+// ExtensionStatus is the provisioning state of an Arc extension as last seen by the Arc installer
+type ExtensionStatus struct {
+	Name              string `json:"name"`
+	Type              string `json:"type"`
+	Version           string `json:"version,omitempty"`
+	ProvisioningState string `json:"provisioningState"`
+	Message           string `json:"message,omitempty"`
+}
+
+// ExtensionsState is written after the declared extensions were reconciled
+type ExtensionsState struct {
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	Extensions []ExtensionStatus `json:"extensions"`
+
+	// Fingerprint of the declared extensions and their settings, so a change makes the Arc step run again
+	Fingerprint string `json:"fingerprint"`
+
+	// SHA-256 of the protected settings applied to each extension; Azure never returns them to compare
+	ProtectedSettings map[string]string `json:"protectedSettings,omitempty"`
+}
+
+// ReadExtensionsState returns the recorded extension state, or nil when the extensions were never reconciled
+func ReadExtensionsState() (*ExtensionsState, error) {
+	data, err := os.ReadFile(ExtensionsStatePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ExtensionsStatePath, err)
+	}
+	state := &ExtensionsState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ExtensionsStatePath, err)
+	}
+	return state, nil
+}
+
+func writeExtensionsState(state *ExtensionsState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(ExtensionsStatePath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(ExtensionsStatePath), err)
+	}
+	if err := utils.WriteFileAtomicSystem(ExtensionsStatePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ExtensionsStatePath, err)
+	}
+	return nil
+}
+
+// desiredExtension is a declared extension with its settings read from their files
+type desiredExtension struct {
+	config.ArcExtensionConfig
+	settings      map[string]any
+	protected     map[string]any
+	protectedHash string
+}
+
+// loadDesiredExtensions reads the settings files of the declared extensions
+func loadDesiredExtensions(declared []config.ArcExtensionConfig) ([]desiredExtension, error) {
+	desired := make([]desiredExtension, 0, len(declared))
+	for _, ext := range declared {
+		d := desiredExtension{ArcExtensionConfig: ext}
+		var err error
+		if d.settings, _, err = readSettingsFile(ext.SettingsFile); err != nil {
+			return nil, fmt.Errorf("extension %s: %w", ext.Name, err)
+		}
+		if d.protected, d.protectedHash, err = readSettingsFile(ext.ProtectedSettingsFile); err != nil {
+			return nil, fmt.Errorf("extension %s: %w", ext.Name, err)
+		}
+		desired = append(desired, d)
+	}
+	return desired, nil
+}
+
+// readSettingsFile reads a JSON object of extension settings and returns it with the SHA-256 of the file
+func readSettingsFile(path string) (map[string]any, string, error) {
+	if path == "" {
+		return nil, "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read settings: %w", err)
+	}
+	var settings map[string]any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, "", fmt.Errorf("invalid settings in %s: expected a JSON object: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return settings, hex.EncodeToString(sum[:]), nil
+}
+
+// extensionsFingerprint identifies the declared extensions and their settings
+func extensionsFingerprint(desired []desiredExtension) string {
+	h := sha256.New()
+	for _, d := range desired {
+		data, _ := json.Marshal(struct {
+			Config    config.ArcExtensionConfig
+			Settings  map[string]any
+			Protected string
+		}{d.ArcExtensionConfig, d.settings, d.protectedHash})
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// extensionPlan lists the extensions to install or update and the agent's extensions to remove
+type extensionPlan struct {
+	apply  []desiredExtension
+	remove []string
+}
+
+// planExtensions compares the declared extensions with the ones on the machine. applied holds the hashes of the
+// protected settings last applied to each extension.
+func planExtensions(desired []desiredExtension, existing []*armhybridcompute.MachineExtension, applied map[string]string) extensionPlan {
+	byName := make(map[string]*armhybridcompute.MachineExtension, len(existing))
+	for _, ext := range existing {
+		if ext != nil && ext.Name != nil {
+			byName[strings.ToLower(*ext.Name)] = ext
+		}
+	}
+
+	var plan extensionPlan
+	declared := make(map[string]bool, len(desired))
+	for _, d := range desired {
+		declared[strings.ToLower(d.Name)] = true
+		if current := byName[strings.ToLower(d.Name)]; current == nil || !extensionMatches(d, current, applied[strings.ToLower(d.Name)]) {
+			plan.apply = append(plan.apply, d)
+		}
+	}
+	for name, ext := range byName {
+		if !declared[name] && to.String(ext.Tags[managedExtensionTag]) == "true" {
+			plan.remove = append(plan.remove, to.String(ext.Name))
+		}
+	}
+	slices.Sort(plan.remove)
+	return plan
+}
+
+// extensionMatches reports whether an extension on the machine is the declared one, installed by the agent and
+// provisioned successfully
+func extensionMatches(d desiredExtension, current *armhybridcompute.MachineExtension, appliedProtectedHash string) bool {
+	props := current.Properties
+	if props == nil || to.String(current.Tags[managedExtensionTag]) != "true" {
+		return false
+	}
+	if !strings.EqualFold(to.String(props.Publisher), d.Publisher) || !strings.EqualFold(to.String(props.Type), d.Type) {
+		return false
+	}
+	// The installed version may be more specific than the declared one, e.g. 1.33.2 for 1.33
+	if version := to.String(props.TypeHandlerVersion); d.TypeHandlerVersion != "" &&
+		version != d.TypeHandlerVersion && !strings.HasPrefix(version, d.TypeHandlerVersion+".") {
+		return false
+	}
+	if props.EnableAutomaticUpgrade != nil && *props.EnableAutomaticUpgrade != d.EnableAutomaticUpgrade {
+		return false
+	}
+	if !sameSettings(props.Settings, d.settings) || appliedProtectedHash != d.protectedHash {
+		return false
+	}
+	return strings.EqualFold(to.String(props.ProvisioningState), "Succeeded")
+}
+
+// sameSettings compares extension settings as JSON, treating missing and empty settings alike
+func sameSettings(current any, desired map[string]any) bool {
+	normalize := func(v any) any {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var out any
+		_ = json.Unmarshal(data, &out)
+		if m, ok := out.(map[string]any); ok && len(m) == 0 {
+			return nil
+		}
+		return out
+	}
+	return reflect.DeepEqual(normalize(current), normalize(desired))
+}
+
+// reconcileExtensions installs, updates and removes the Arc machine's extensions as declared and records their
+// provisioning state for the node status. A failed extension does not fail bootstrap; it is reported in status.
+func (i *Installer) reconcileExtensions(ctx context.Context, arcMachine *armhybridcompute.Machine) error {
+	desired, err := loadDesiredExtensions(i.config.GetArcExtensions())
+	if err != nil {
+		return err
+	}
+	recorded, err := ReadExtensionsState()
+	if err != nil {
+		i.logger.Warnf("Ignoring the recorded extension state: %v", err)
+	}
+	if len(desired) == 0 && recorded == nil {
+		return nil
+	}
+	applied := map[string]string{}
+	if recorded != nil && recorded.ProtectedSettings != nil {
+		applied = recorded.ProtectedSettings
+	}
+
+	existing, err := i.listArcExtensions(ctx)
+	if err != nil {
+		return err
+	}
+	plan := planExtensions(desired, existing, applied)
+
+	state := &ExtensionsState{Fingerprint: extensionsFingerprint(desired), ProtectedSettings: map[string]string{}}
+	for _, name := range plan.remove {
+		i.logger.Infof("Removing Arc extension %s, which is no longer declared", name)
+		if err := i.deleteArcExtension(ctx, name); err != nil {
+			i.logger.Warnf("Failed to remove Arc extension %s: %v", name, err)
+		}
+	}
+	for _, d := range plan.apply {
+		i.logger.Infof("Installing Arc extension %s (%s.%s %s)", d.Name, d.Publisher, d.Type, d.TypeHandlerVersion)
+		if err := i.applyArcExtension(ctx, arcMachine, d); err != nil {
+			i.logger.Warnf("Failed to install Arc extension %s: %v", d.Name, err)
+			continue
+		}
+		i.logger.Infof("Arc extension %s is installed", d.Name)
+	}
+
+	// Record the state Azure reports after the changes
+	if existing, err = i.listArcExtensions(ctx); err != nil {
+		return err
+	}
+	state.Extensions = extensionStatuses(desired, existing)
+	for _, d := range desired {
+		if d.protectedHash == "" {
+			continue
+		}
+		name := strings.ToLower(d.Name)
+		if !slices.ContainsFunc(plan.apply, func(a desiredExtension) bool { return strings.EqualFold(a.Name, d.Name) }) {
+			state.ProtectedSettings[name] = applied[name]
+		} else if status := findStatus(state.Extensions, d.Name); status != nil && status.ProvisioningState == "Succeeded" {
+			state.ProtectedSettings[name] = d.protectedHash
+		}
+	}
+	state.UpdatedAt = time.Now()
+	return writeExtensionsState(state)
+}
+
+// extensionStatuses returns the provisioning state of each declared extension
+func extensionStatuses(desired []desiredExtension, existing []*armhybridcompute.MachineExtension) []ExtensionStatus {
+	statuses := make([]ExtensionStatus, 0, len(desired))
+	for _, d := range desired {
+		status := ExtensionStatus{Name: d.Name, Type: d.Publisher + "." + d.Type, ProvisioningState: "NotInstalled"}
+		for _, ext := range existing {
+			if ext == nil || !strings.EqualFold(to.String(ext.Name), d.Name) || ext.Properties == nil {
+				continue
+			}
+			status.Version = to.String(ext.Properties.TypeHandlerVersion)
+			status.ProvisioningState = to.String(ext.Properties.ProvisioningState)
+			if view := ext.Properties.InstanceView; view != nil && view.Status != nil {
+				status.Message = to.String(view.Status.Message)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func findStatus(statuses []ExtensionStatus, name string) *ExtensionStatus {
+	for idx := range statuses {
+		if strings.EqualFold(statuses[idx].Name, name) {
+			return &statuses[idx]
+		}
+	}
+	return nil
+}
+
+// extensionsUpToDate reports whether the declared extensions were reconciled as currently declared
+func extensionsUpToDate(declared []config.ArcExtensionConfig) bool {
+	recorded, err := ReadExtensionsState()
+	if err != nil {
+		return false
+	}
+	if recorded == nil {
+		return len(declared) == 0
+	}
+	desired, err := loadDesiredExtensions(declared)
+	if err != nil {
+		return false
+	}
+	return recorded.Fingerprint == extensionsFingerprint(desired)
+}
+
+// listArcExtensions lists the extensions of the Arc machine
+func (ab *base) listArcExtensions(ctx context.Context) ([]*armhybridcompute.MachineExtension, error) {
+	opCtx, cancel := auth.OperationContext(ctx, ab.config)
+	defer cancel()
+
+	var extensions []*armhybridcompute.MachineExtension
+	pager := ab.machineExtensionsClient.NewListPager(ab.config.GetArcResourceGroup(), ab.config.GetArcMachineName(), nil)
+	for pager.More() {
+		page, err := pager.NextPage(opCtx)
+		if err != nil {
+			return nil, azerrors.Wrap(fmt.Errorf("failed to list Arc extensions: %w", err))
+		}
+		extensions = append(extensions, page.Value...)
+	}
+	return extensions, nil
+}
+
+// applyArcExtension installs or updates an extension and waits until the machine reports the result
+func (ab *base) applyArcExtension(ctx context.Context, arcMachine *armhybridcompute.Machine, d desiredExtension) error {
+	props := &armhybridcompute.MachineExtensionProperties{
+		Publisher:               to.StringPtr(d.Publisher),
+		Type:                    to.StringPtr(d.Type),
+		AutoUpgradeMinorVersion: to.BoolPtr(true),
+		EnableAutomaticUpgrade:  to.BoolPtr(d.EnableAutomaticUpgrade),
+	}
+	if d.TypeHandlerVersion != "" {
+		props.TypeHandlerVersion = to.StringPtr(d.TypeHandlerVersion)
+	}
+	if d.settings != nil {
+		props.Settings = d.settings
+	}
+	if d.protected != nil {
+		props.ProtectedSettings = d.protected
+	}
+	extension := armhybridcompute.MachineExtension{
+		Location:   arcMachine.Location,
+		Tags:       map[string]*string{managedExtensionTag: to.StringPtr("true")},
+		Properties: props,
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, extensionTimeout)
+	defer cancel()
+	poller, err := ab.machineExtensionsClient.BeginCreateOrUpdate(opCtx, ab.config.GetArcResourceGroup(), ab.config.GetArcMachineName(), d.Name, extension, nil)
+	if err != nil {
+		return azerrors.Wrap(fmt.Errorf("failed to install Arc extension: %w", err))
+	}
+	if _, err := poller.PollUntilDone(opCtx, nil); err != nil {
+		return azerrors.Wrap(fmt.Errorf("extension did not provision: %w", err))
+	}
+	return nil
+}
+
+// deleteArcExtension uninstalls an extension; one that is already gone is not an error
+func (ab *base) deleteArcExtension(ctx context.Context, name string) error {
+	opCtx, cancel := context.WithTimeout(ctx, extensionTimeout)
+	defer cancel()
+	poller, err := ab.machineExtensionsClient.BeginDelete(opCtx, ab.config.GetArcResourceGroup(), ab.config.GetArcMachineName(), name, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(opCtx, nil)
+	}
+	if err != nil && !azerrors.IsNotFound(err) {
+		return azerrors.Wrap(fmt.Errorf("failed to remove Arc extension: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package arc
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func testExtension(name, version, state string, managed bool, settings any) *armhybridcompute.MachineExtension {
+	ext := &armhybridcompute.MachineExtension{
+		Name: to.StringPtr(name),
+		Tags: map[string]*string{},
+		Properties: &armhybridcompute.MachineExtensionProperties{
+			Publisher:          to.StringPtr("Microsoft.Azure.Monitor"),
+			Type:               to.StringPtr(name),
+			TypeHandlerVersion: to.StringPtr(version),
+			ProvisioningState:  to.StringPtr(state),
+			Settings:           settings,
+		},
+	}
+	if managed {
+		ext.Tags[managedExtensionTag] = to.StringPtr("true")
+	}
+	return ext
+}
+
+func testDesired(name, version string, settings map[string]any) desiredExtension {
+	return desiredExtension{
+		ArcExtensionConfig: config.ArcExtensionConfig{Name: name, Publisher: "Microsoft.Azure.Monitor", Type: name, TypeHandlerVersion: version},
+		settings:           settings,
+	}
+}
+
+func TestPlanExtensions(t *testing.T) {
+	settings := map[string]any{"workspaceId": "abc", "stopOnMultipleConnections": true}
+	existing := []*armhybridcompute.MachineExtension{
+		// Installed as declared, reported with a more specific version and settings in another key order
+		testExtension("AzureMonitorLinuxAgent", "1.33.2", "Succeeded", true, map[string]any{"stopOnMultipleConnections": true, "workspaceId": "abc"}),
+		// Declared with other settings
+		testExtension("DependencyAgentLinux", "9.10", "Succeeded", true, nil),
+		// Failed to provision
+		testExtension("CustomScript", "2.1", "Failed", true, nil),
+		// No longer declared: removed when the agent installed it, kept otherwise
+		testExtension("OldAgent", "1.0", "Succeeded", true, nil),
+		testExtension("MDE.Linux", "1.0", "Succeeded", false, nil),
+	}
+	plan := planExtensions([]desiredExtension{
+		testDesired("AzureMonitorLinuxAgent", "1.33", settings),
+		testDesired("DependencyAgentLinux", "9.10", map[string]any{"enableAMA": "true"}),
+		testDesired("CustomScript", "2.1", nil),
+		testDesired("NewAgent", "", nil),
+	}, existing, nil)
+
+	var applied []string
+	for _, d := range plan.apply {
+		applied = append(applied, d.Name)
+	}
+	if want := []string{"DependencyAgentLinux", "CustomScript", "NewAgent"}; !slices.Equal(applied, want) {
+		t.Errorf("planExtensions() apply = %v, want %v", applied, want)
+	}
+	if want := []string{"OldAgent"}; !slices.Equal(plan.remove, want) {
+		t.Errorf("planExtensions() remove = %v, want %v", plan.remove, want)
+	}
+}
+
+func TestPlanExtensionsProtectedSettings(t *testing.T) {
+	existing := []*armhybridcompute.MachineExtension{testExtension("CustomScript", "2.1", "Succeeded", true, nil)}
+	d := testDesired("CustomScript", "2.1", nil)
+	d.protectedHash = "new"
+
+	if plan := planExtensions([]desiredExtension{d}, existing, map[string]string{"customscript": "old"}); len(plan.apply) != 1 {
+		t.Error("planExtensions() did not update an extension whose protected settings changed")
+	}
+	if plan := planExtensions([]desiredExtension{d}, existing, map[string]string{"customscript": "new"}); len(plan.apply) != 0 {
+		t.Error("planExtensions() updated an extension whose protected settings are unchanged")
+	}
+}
+
+func TestExtensionStatuses(t *testing.T) {
+	failed := testExtension("CustomScript", "2.1.3", "Failed", true, nil)
+	failed.Properties.InstanceView = &armhybridcompute.MachineExtensionInstanceView{
+		Status: &armhybridcompute.MachineExtensionInstanceViewStatus{Message: to.StringPtr("script exited with 1")},
+	}
+	statuses := extensionStatuses([]desiredExtension{testDesired("CustomScript", "2.1", nil), testDesired("NewAgent", "", nil)},
+		[]*armhybridcompute.MachineExtension{failed})
+
+	want := []ExtensionStatus{
+		{Name: "CustomScript", Type: "Microsoft.Azure.Monitor.CustomScript", Version: "2.1.3", ProvisioningState: "Failed", Message: "script exited with 1"},
+		{Name: "NewAgent", Type: "Microsoft.Azure.Monitor.NewAgent", ProvisioningState: "NotInstalled"},
+	}
+	if !slices.Equal(statuses, want) {
+		t.Errorf("extensionStatuses() = %+v, want %+v", statuses, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os/exec"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/Azure/go-autorest/autorest/to"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -38,3 +39,20 @@ func getArcMachineIdentityID(arcMachine *armhybridcompute.Machine) string {
 	}
 	return ""
 }
+
+// mergeTags returns the machine's tags with the desired ones set, and whether any of them changed.
+// Tags set by others are kept; azcmagent connect only applies tags when the machine is first connected.
+func mergeTags(current map[string]*string, desired map[string]string) (map[string]*string, bool) {
+	merged := make(map[string]*string, len(current)+len(desired))
+	for key, value := range current {
+		merged[key] = value
+	}
+	changed := false
+	for key, value := range desired {
+		if current[key] == nil || *current[key] != value {
+			merged[key] = to.StringPtr(value)
+			changed = true
+		}
+	}
+	return merged, changed
+}
@@ -0,0 +1,252 @@
+package arc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// agentShowTimeout bounds the azcmagent show call used to read the local connection state
+const agentShowTimeout = 10 * time.Second
+
+// productUUIDPath holds the SMBIOS UUID of the machine, which Arc reports as the machine's vmUuid
+const productUUIDPath = "/sys/class/dmi/id/product_uuid"
+
+// maxAutoSuffixAttempts bounds the suffixed names tried with azure.arc.autoSuffix
+const maxAutoSuffixAttempts = 5
+
+// agentStatus is the subset of 'azcmagent show -j' describing the local agent's connection
+type agentStatus struct {
+	ResourceName   string `json:"resourceName"`
+	ResourceGroup  string `json:"resourceGroup"`
+	SubscriptionID string `json:"subscriptionId"`
+	Status         string `json:"status"`
+	VMID           string `json:"vmId"`
+}
+
+// connectedTo reports whether the local agent is connected as the configured Arc machine
+func (s *agentStatus) connectedTo(cfg *config.Config) bool {
+	return s != nil &&
+		strings.EqualFold(s.Status, "Connected") &&
+		strings.EqualFold(s.ResourceName, cfg.GetArcMachineName()) &&
+		strings.EqualFold(s.ResourceGroup, cfg.GetArcResourceGroup()) &&
+		strings.EqualFold(s.SubscriptionID, cfg.GetSubscriptionID())
+}
+
+// showAgentStatus reads the local agent state; it returns nil when the agent is not installed
+func showAgentStatus(ctx context.Context) (*agentStatus, error) {
+	if !isArcAgentInstalled() {
+		return nil, nil
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, agentShowTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(timeoutCtx, "azcmagent", "show", "-j").Output()
+	if err != nil {
+		return nil, fmt.Errorf("azcmagent show failed: %w", err)
+	}
+	var status agentStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse azcmagent show output: %w", err)
+	}
+	return &status, nil
+}
+
+// registration describes how an existing Arc machine resource relates to this machine
+type registration int
+
+const (
+	// registrationNone means there is no Arc machine resource yet
+	registrationNone registration = iota
+	// registrationConnected means the local agent is connected to the resource
+	registrationConnected
+	// registrationStale means the resource was left behind by an earlier installation on this machine
+	registrationStale
+	// registrationForeign means the resource belongs to another machine that is still connected
+	registrationForeign
+)
+
+// host identifies this machine to tell its Arc machine resources from those of other computers
+type host struct {
+	name        string
+	productUUID string // SMBIOS UUID, empty when it cannot be read
+}
+
+// readHost reads the host name and SMBIOS UUID; the UUID is only readable by root
+func readHost() host {
+	var h host
+	h.name, _ = os.Hostname()
+	if data, err := os.ReadFile(productUUIDPath); err == nil {
+		h.productUUID = strings.TrimSpace(string(data))
+	}
+	return h
+}
+
+// classifyRegistration compares the Arc machine resource with the local agent and host
+func classifyRegistration(cfg *config.Config, machine *armhybridcompute.Machine, local *agentStatus, h host) (registration, string) {
+	if machine == nil {
+		return registrationNone, ""
+	}
+
+	var vmID, vmUUID, computerName, status string
+	if props := machine.Properties; props != nil {
+		vmID = to.String(props.VMID)
+		vmUUID = to.String(props.VMUUID)
+		if props.OSProfile != nil {
+			computerName = to.String(props.OSProfile.ComputerName)
+		}
+		if props.Status != nil {
+			status = string(*props.Status)
+		}
+	}
+
+	if local.connectedTo(cfg) && (vmID == "" || local.VMID == "" || strings.EqualFold(vmID, local.VMID)) {
+		return registrationConnected, ""
+	}
+
+	if strings.EqualFold(status, string(armhybridcompute.StatusTypesConnected)) {
+		// Edge sites often reuse host names, so a resource connected from a computer of the same name is
+		// only this machine's if the hardware matches too
+		if computerName != "" && h.name != "" && !strings.EqualFold(computerName, h.name) {
+			return registrationForeign, fmt.Sprintf("Arc machine %s is connected from another computer (%s); %s",
+				cfg.GetArcMachineName(), computerName, foreignRemedy)
+		}
+		if vmUUID != "" && h.productUUID != "" && !sameSMBIOSUUID(vmUUID, h.productUUID) {
+			return registrationForeign, fmt.Sprintf("Arc machine %s is connected from another computer with the same host name "+
+				"(SMBIOS UUID %s); %s", cfg.GetArcMachineName(), vmUUID, foreignRemedy)
+		}
+	}
+
+	reason := fmt.Sprintf("Arc machine %s already exists in Azure (status %s) but this machine's agent is not connected to it, "+
+		"usually because it was left behind by an earlier installation", cfg.GetArcMachineName(), statusOrUnknown(status))
+	return registrationStale, reason
+}
+
+// foreignRemedy tells how to resolve a name taken by another computer
+const foreignRemedy = "choose a different azure.arc.machineName or azure.arc.namingStrategy, or set azure.arc.autoSuffix"
+
+// sameSMBIOSUUID compares SMBIOS UUIDs. Firmware and tools disagree on the byte order of the first three
+// fields, so a UUID also matches its byte-swapped form.
+func sameSMBIOSUUID(a, b string) bool {
+	return strings.EqualFold(a, b) || strings.EqualFold(swapUUIDFields(a), b)
+}
+
+// swapUUIDFields reverses the byte order of the first three fields of a UUID, or returns "" if it is malformed
+func swapUUIDFields(uuid string) string {
+	fields := strings.Split(uuid, "-")
+	if len(fields) != 5 || len(fields[0]) != 8 || len(fields[1]) != 4 || len(fields[2]) != 4 {
+		return ""
+	}
+	for idx := 0; idx < 3; idx++ {
+		field := fields[idx]
+		var swapped strings.Builder
+		for pos := len(field) - 2; pos >= 0; pos -= 2 {
+			swapped.WriteString(field[pos : pos+2])
+		}
+		fields[idx] = swapped.String()
+	}
+	return strings.Join(fields, "-")
+}
+
+func statusOrUnknown(status string) string {
+	if status == "" {
+		return "unknown"
+	}
+	return status
+}
+
+// arcIdentityAssignments returns the role assignments that target the Arc machine's own identity
+func arcIdentityAssignments(assignments []roleAssignment) []roleAssignment {
+	var own []roleAssignment
+	for _, ra := range assignments {
+		if ra.principalID == "" {
+			own = append(own, ra)
+		}
+	}
+	return own
+}
+
+// autoSuffixCandidates returns the names tried with azure.arc.autoSuffix: base-suffix, then base-suffix-2 and so on.
+// Numbered candidates cover machines cloned from one image, which share their machine ID.
+func autoSuffixCandidates(base, suffix string) []string {
+	candidates := make([]string, 0, maxAutoSuffixAttempts)
+	for n := 1; n <= maxAutoSuffixAttempts; n++ {
+		if n == 1 {
+			candidates = append(candidates, config.WithArcNameSuffix(base, suffix))
+			continue
+		}
+		candidates = append(candidates, config.WithArcNameSuffix(base, fmt.Sprintf("%s-%d", suffix, n)))
+	}
+	return candidates
+}
+
+// autoSuffixName switches to the first suffixed name that is not connected from another computer, and returns
+// the existing resource of that name, if any, with how it relates to this machine
+func (i *Installer) autoSuffixName(ctx context.Context, local *agentStatus, h host, reason string) (*armhybridcompute.Machine, registration, string, error) {
+	base := i.config.GetArcMachineName()
+	suffix, err := config.MachineIDSuffix()
+	if err != nil {
+		return nil, registrationNone, "", fmt.Errorf("%s, and no suffix could be derived: %w", reason, err)
+	}
+	for _, name := range autoSuffixCandidates(base, suffix) {
+		i.config.UseArcMachineName(name)
+		machine, err := i.getArcMachine(ctx)
+		if err != nil {
+			machine = nil
+		}
+		state, nameReason := classifyRegistration(i.config, machine, local, h)
+		if state != registrationForeign {
+			i.logger.Warnf("%s; connecting as %s instead", reason, name)
+			return machine, state, nameReason, nil
+		}
+		reason = nameReason
+	}
+	return nil, registrationNone, "", fmt.Errorf("%s, and the %d suffixed names are taken too", reason, maxAutoSuffixAttempts)
+}
+
+// recordMachineName records the name the node is connected under, so later runs keep it. A failure only
+// matters once the naming inputs change, e.g. the hostname, so it is logged.
+func (i *Installer) recordMachineName() {
+	record := i.config.NewArcMachineNameRecord(i.config.GetArcMachineName())
+	if err := config.RecordArcMachineName(&record); err != nil {
+		i.logger.Warnf("Failed to record the Arc machine name: %v", err)
+	}
+}
+
+// resolveStaleRegistration applies azure.arc.reinstallStrategy to a stale Arc machine resource
+func (i *Installer) resolveStaleRegistration(ctx context.Context, reason string) error {
+	strategy := i.config.GetArcReinstallStrategy()
+	switch strategy {
+	case "adopt":
+		i.logger.Warnf("%s; adopting the existing resource", reason)
+		return nil
+	case "recreate":
+		i.logger.Warnf("%s; deleting and recreating it", reason)
+		return i.deleteArcMachine(ctx)
+	default:
+		return fmt.Errorf("%s: set azure.arc.reinstallStrategy to 'adopt' to connect to the existing resource "+
+			"or 'recreate' to delete and recreate it", reason)
+	}
+}
+
+// cleanUpStalePrincipal removes the role assignments of an Arc identity replaced during reinstall.
+// Failures are logged only: the new identity already has its roles, and the old one no longer signs in.
+func (i *Installer) cleanUpStalePrincipal(ctx context.Context, stalePrincipalID, currentPrincipalID string) {
+	if stalePrincipalID == "" || strings.EqualFold(stalePrincipalID, currentPrincipalID) {
+		return
+	}
+	i.logger.Infof("Arc machine identity changed from %s to %s, removing role assignments of the old identity",
+		stalePrincipalID, currentPrincipalID)
+	if err := i.removeRoleAssignmentsFor(ctx, stalePrincipalID, arcIdentityAssignments(i.getRoleAssignments())); err != nil {
+		i.logger.Warnf("Failed to remove role assignments of the replaced identity %s: %v", stalePrincipalID, err)
+	}
+}
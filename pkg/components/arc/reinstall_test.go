@@ -0,0 +1,170 @@
+package arc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func newReinstallTestConfig() *config.Config {
+	return &config.Config{
+		Azure: config.AzureConfig{
+			SubscriptionID: "sub",
+			Arc:            &config.ArcConfig{MachineName: "edge-01", ResourceGroup: "arc-rg"},
+		},
+	}
+}
+
+func newTestMachine(vmID, computerName string, status armhybridcompute.StatusTypes) *armhybridcompute.Machine {
+	return &armhybridcompute.Machine{
+		Name: to.StringPtr("edge-01"),
+		Properties: &armhybridcompute.MachineProperties{
+			VMID:      to.StringPtr(vmID),
+			Status:    &status,
+			OSProfile: &armhybridcompute.OSProfile{ComputerName: to.StringPtr(computerName)},
+		},
+	}
+}
+
+func TestClassifyRegistration(t *testing.T) {
+	connected := &agentStatus{ResourceName: "edge-01", ResourceGroup: "arc-rg", SubscriptionID: "sub", Status: "Connected", VMID: "vm-1"}
+
+	tests := []struct {
+		name       string
+		machine    *armhybridcompute.Machine
+		local      *agentStatus
+		want       registration
+		wantReason string
+	}{
+		{name: "no resource", local: connected, want: registrationNone},
+		{
+			name:    "connected to the resource",
+			machine: newTestMachine("vm-1", "edge-01", armhybridcompute.StatusTypesConnected),
+			local:   connected,
+			want:    registrationConnected,
+		},
+		{
+			name:       "agent not installed",
+			machine:    newTestMachine("vm-1", "edge-01", armhybridcompute.StatusTypesDisconnected),
+			want:       registrationStale,
+			wantReason: "status Disconnected",
+		},
+		{
+			name:    "agent disconnected locally",
+			machine: newTestMachine("vm-1", "edge-01", armhybridcompute.StatusTypesDisconnected),
+			local:   &agentStatus{Status: "Disconnected", VMID: "vm-2"},
+			want:    registrationStale,
+		},
+		{
+			name:    "resource recreated for another agent",
+			machine: newTestMachine("vm-9", "edge-01", armhybridcompute.StatusTypesConnected),
+			local:   connected,
+			want:    registrationStale,
+		},
+		{
+			name:       "resource used by another computer",
+			machine:    newTestMachine("vm-9", "other-host", armhybridcompute.StatusTypesConnected),
+			want:       registrationForeign,
+			wantReason: "connected from another computer (other-host)",
+		},
+		{
+			name: "resource of another computer with the same host name",
+			machine: func() *armhybridcompute.Machine {
+				m := newTestMachine("vm-9", "edge-01", armhybridcompute.StatusTypesConnected)
+				m.Properties.VMUUID = to.StringPtr("11111111-2222-3333-4444-555555555555")
+				return m
+			}(),
+			want:       registrationForeign,
+			wantReason: "same host name (SMBIOS UUID 11111111-2222-3333-4444-555555555555)",
+		},
+		{
+			name: "resource of this hardware with byte-swapped UUID",
+			machine: func() *armhybridcompute.Machine {
+				m := newTestMachine("vm-9", "edge-01", armhybridcompute.StatusTypesConnected)
+				m.Properties.VMUUID = to.StringPtr("D4C3B2A1-F6E5-0807-090A-0B0C0D0E0F10")
+				return m
+			}(),
+			want: registrationStale,
+		},
+		{
+			name:    "failed resource of another computer is stale",
+			machine: newTestMachine("vm-9", "other-host", armhybridcompute.StatusTypesError),
+			want:    registrationStale,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := host{name: "edge-01", productUUID: "a1b2c3d4-e5f6-0708-090a-0b0c0d0e0f10"}
+			got, reason := classifyRegistration(newReinstallTestConfig(), tt.machine, tt.local, h)
+			if got != tt.want {
+				t.Errorf("classifyRegistration() = %v, want %v (reason %q)", got, tt.want, reason)
+			}
+			if !strings.Contains(reason, tt.wantReason) {
+				t.Errorf("classifyRegistration() reason = %q, want containing %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSameSMBIOSUUID(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"A1B2C3D4-E5F6-0708-090A-0B0C0D0E0F10", "a1b2c3d4-e5f6-0708-090a-0b0c0d0e0f10", true},
+		{"d4c3b2a1-f6e5-0807-090a-0b0c0d0e0f10", "a1b2c3d4-e5f6-0708-090a-0b0c0d0e0f10", true},
+		{"a1b2c3d4-e5f6-0708-090a-0b0c0d0e0f11", "a1b2c3d4-e5f6-0708-090a-0b0c0d0e0f10", false},
+		{"not-a-uuid", "a1b2c3d4-e5f6-0708-090a-0b0c0d0e0f10", false},
+	}
+	for _, tt := range tests {
+		if got := sameSMBIOSUUID(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameSMBIOSUUID(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAutoSuffixCandidates(t *testing.T) {
+	got := autoSuffixCandidates("edge-01", "3f9a1c")
+	want := []string{"edge-01-3f9a1c", "edge-01-3f9a1c-2", "edge-01-3f9a1c-3", "edge-01-3f9a1c-4", "edge-01-3f9a1c-5"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("autoSuffixCandidates() = %v, want %v", got, want)
+	}
+
+	long := strings.Repeat("a", 60)
+	for _, name := range autoSuffixCandidates(long, "3f9a1c") {
+		if len(name) > 54 {
+			t.Errorf("autoSuffixCandidates() returned %q, longer than 54 characters", name)
+		}
+	}
+}
+
+func TestArcIdentityAssignments(t *testing.T) {
+	assignments := []roleAssignment{
+		{roleName: "Reader", scope: "/cluster", roleID: "r"},
+		{roleName: "Contributor", scope: "/rg", roleID: "c", principalID: "11111111-1111-1111-1111-111111111111"},
+	}
+	got := arcIdentityAssignments(assignments)
+	if len(got) != 1 || got[0].roleName != "Reader" {
+		t.Errorf("arcIdentityAssignments() = %+v, want only the Arc identity assignment", got)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	current := map[string]*string{"owner": to.StringPtr("ops"), "aks-flex-node-cluster": to.StringPtr("east")}
+
+	merged, changed := mergeTags(current, map[string]string{"aks-flex-node-cluster": "west"})
+	if !changed || to.String(merged["aks-flex-node-cluster"]) != "west" || to.String(merged["owner"]) != "ops" {
+		t.Errorf("mergeTags() = %v, %v, want the cluster replaced and other tags kept", merged, changed)
+	}
+	if to.String(current["aks-flex-node-cluster"]) != "east" {
+		t.Error("mergeTags() modified the current tags")
+	}
+	if _, changed := mergeTags(current, map[string]string{"owner": "ops"}); changed {
+		t.Error("mergeTags() reported a change for tags already set")
+	}
+}
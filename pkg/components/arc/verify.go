@@ -0,0 +1,93 @@
+package arc
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// RoleAssignmentReportPath holds one line per required role assignment found missing, empty when none is.
+// NPD reads it to raise the RoleAssignmentMissing problem.
+const RoleAssignmentReportPath = "/var/lib/aks-flex-node/role-assignment-report"
+
+// MissingRoleAssignment is a role assignment the node needs that no longer exists in Azure
+type MissingRoleAssignment struct {
+	RoleName    string
+	Scope       string
+	PrincipalID string
+	Recreated   bool // The assignment was created again
+}
+
+// String describes the missing assignment, e.g. "Reader (Target Cluster) on /subscriptions/... for 1234..."
+func (m MissingRoleAssignment) String() string {
+	return fmt.Sprintf("%s on %s for %s", m.RoleName, m.Scope, m.PrincipalID)
+}
+
+// VerifyRoleAssignments lists the role assignments of the Arc machine's managed identity and of the configured
+// principals, and returns the required ones that are missing, e.g. because an admin removed them. With
+// recreate, missing assignments are created again and recorded so unbootstrap removes them; one that cannot
+// be created is returned without Recreated set.
+func VerifyRoleAssignments(ctx context.Context, cfg *config.Config, logger *logrus.Logger, recreate bool) ([]MissingRoleAssignment, error) {
+	ab := newBase(cfg, logger)
+	if err := ab.setUpClients(ctx); err != nil {
+		return nil, err
+	}
+	machine, err := ab.getArcMachine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	principalID := getArcMachineIdentityID(machine)
+	if principalID == "" {
+		return nil, fmt.Errorf("managed identity ID not found on Arc machine")
+	}
+	return ab.verifyRoleAssignments(ctx, principalID, recreate)
+}
+
+func (ab *base) verifyRoleAssignments(ctx context.Context, principalID string, recreate bool) ([]MissingRoleAssignment, error) {
+	assigner := ab.roleAssigner()
+	var missing []MissingRoleAssignment
+	for _, required := range ab.getRoleAssignments() {
+		spec := required.spec(principalID)
+		has, err := assigner.HasAssignment(ctx, spec)
+		if err != nil {
+			return nil, fmt.Errorf("error checking role %s on scope %s: %w", required.roleName, required.scope, err)
+		}
+		if has {
+			continue
+		}
+		assignment := MissingRoleAssignment{RoleName: required.roleName, Scope: required.scope, PrincipalID: spec.PrincipalID}
+		if recreate {
+			if err := assigner.EnsureAssignment(ctx, spec); err != nil {
+				ab.logger.Warnf("Failed to recreate role assignment %s: %v", assignment, err)
+			} else {
+				assignment.Recreated = true
+			}
+		}
+		missing = append(missing, assignment)
+	}
+	return missing, nil
+}
+
+// WriteRoleAssignmentReport records the missing role assignments that were not recreated for NPD
+func WriteRoleAssignmentReport(path string, missing []MissingRoleAssignment) error {
+	var report strings.Builder
+	for _, assignment := range missing {
+		if !assignment.Recreated {
+			report.WriteString(assignment.String())
+			report.WriteByte('\n')
+		}
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := utils.WriteFileAtomicSystem(path, []byte(report.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
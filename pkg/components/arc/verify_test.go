@@ -0,0 +1,108 @@
+package arc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/rbac"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// listingClient lists a fixed set of role assignments and records the ones created through it
+type listingClient struct {
+	mockRoleAssignmentsClient
+	assignments []*armauthorization.RoleAssignment
+	created     []string // Role definition IDs
+}
+
+func (l *listingClient) Create(ctx context.Context, scope string, roleAssignmentName string, parameters armauthorization.RoleAssignmentCreateParameters, options *armauthorization.RoleAssignmentsClientCreateOptions) (armauthorization.RoleAssignmentsClientCreateResponse, error) {
+	l.created = append(l.created, to.String(parameters.Properties.RoleDefinitionID))
+	return armauthorization.RoleAssignmentsClientCreateResponse{}, nil
+}
+
+func (l *listingClient) NewListForScopePager(scope string, options *armauthorization.RoleAssignmentsClientListForScopeOptions) *runtime.Pager[armauthorization.RoleAssignmentsClientListForScopeResponse] {
+	done := false
+	return runtime.NewPager(runtime.PagingHandler[armauthorization.RoleAssignmentsClientListForScopeResponse]{
+		More: func(armauthorization.RoleAssignmentsClientListForScopeResponse) bool { return !done },
+		Fetcher: func(ctx context.Context, _ *armauthorization.RoleAssignmentsClientListForScopeResponse) (armauthorization.RoleAssignmentsClientListForScopeResponse, error) {
+			done = true
+			return armauthorization.RoleAssignmentsClientListForScopeResponse{
+				RoleAssignmentListResult: armauthorization.RoleAssignmentListResult{Value: l.assignments},
+			}, nil
+		},
+	})
+}
+
+func TestVerifyRoleAssignments(t *testing.T) {
+	const (
+		subscriptionID = "12345678-1234-1234-1234-123456789012"
+		clusterID      = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"
+		principalID    = "arc-identity"
+	)
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	cfg := &config.Config{Azure: config.AzureConfig{
+		SubscriptionID: subscriptionID,
+		TargetCluster:  &config.TargetClusterConfig{ResourceID: clusterID},
+	}}
+	// An admin removed the cluster admin role
+	existing := func() []*armauthorization.RoleAssignment {
+		var assignments []*armauthorization.RoleAssignment
+		for _, role := range []string{"Reader", "Azure Kubernetes Service RBAC Cluster Admin"} {
+			assignments = append(assignments, &armauthorization.RoleAssignment{
+				Name: to.StringPtr(role),
+				Properties: &armauthorization.RoleAssignmentProperties{
+					PrincipalID:      to.StringPtr(principalID),
+					RoleDefinitionID: to.StringPtr(rbac.FullRoleDefinitionID(subscriptionID, roleDefinitionIDs[role])),
+					Scope:            to.StringPtr(clusterID),
+				},
+			})
+		}
+		return assignments
+	}
+
+	for _, recreate := range []bool{false, true} {
+		client := &listingClient{assignments: existing()}
+		ab := &base{config: cfg, logger: logger, roleAssignmentsClient: client}
+
+		missing, err := ab.verifyRoleAssignments(context.Background(), principalID, recreate)
+		if err != nil {
+			t.Fatalf("verifyRoleAssignments(recreate=%v) unexpected error: %v", recreate, err)
+		}
+		if len(missing) != 1 || missing[0].RoleName != "Azure Kubernetes Service Cluster Admin Role" || missing[0].PrincipalID != principalID {
+			t.Fatalf("verifyRoleAssignments(recreate=%v) = %+v, want the cluster admin role missing", recreate, missing)
+		}
+		wantCreates := 0
+		if recreate {
+			wantCreates = 1
+		}
+		if missing[0].Recreated != recreate || len(client.created) != wantCreates {
+			t.Errorf("verifyRoleAssignments(recreate=%v) recreated %v with %d creates", recreate, missing[0].Recreated, len(client.created))
+		}
+	}
+}
+
+func TestWriteRoleAssignmentReport(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "role-assignment-report")
+	missing := []MissingRoleAssignment{
+		{RoleName: "Reader", Scope: "/subscriptions/sub", PrincipalID: "p1"},
+		{RoleName: "Network Contributor", Scope: "/subscriptions/sub/resourceGroups/nodes", PrincipalID: "p1", Recreated: true},
+	}
+	if err := WriteRoleAssignmentReport(report, missing); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Reader on /subscriptions/sub for p1\n"; string(data) != want {
+		t.Errorf("report = %q, want only the assignment still missing %q", data, want)
+	}
+}
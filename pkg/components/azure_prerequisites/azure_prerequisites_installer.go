@@ -0,0 +1,74 @@
+package azure_prerequisites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/prerequisites"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// newClient creates the ARM client used to check and deploy the prerequisites; tests replace it
+var newClient = prerequisites.NewClientForConfig
+
+// Installer creates the Azure resources a new site needs before the node can join: the resource
+// group, the user-assigned identity and the links of the private DNS zones to the site's vnet
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new Azure prerequisites Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "AzurePrerequisites_Installer"
+}
+
+// Validate is a no-op; the prerequisites configuration is validated with the rest of the config
+func (i *Installer) Validate(ctx context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when deploying prerequisites is disabled. Otherwise Execute runs every
+// time; it only checks Azure and deploys nothing when all resources exist.
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	return !i.config.IsPrerequisitesDeployEnabled()
+}
+
+// Execute deploys the prerequisites that do not exist yet
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.IsPrerequisitesDeployEnabled() {
+		return nil
+	}
+
+	client, err := newClient(ctx, i.config, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to create Azure resources client: %w", err)
+	}
+
+	spec := prerequisites.NewSpec(i.config)
+	plan, err := prerequisites.Check(ctx, client, spec)
+	if err != nil {
+		return fmt.Errorf("failed to check Azure prerequisites: %w", err)
+	}
+	if plan.Empty() {
+		i.logger.Info("All Azure prerequisites exist")
+		return nil
+	}
+
+	i.logger.Infof("Deploying Azure prerequisites: %s", plan)
+	if err := prerequisites.Deploy(ctx, client, spec, plan); err != nil {
+		return fmt.Errorf("failed to deploy Azure prerequisites: %w", err)
+	}
+	i.logger.Infof("✅ Deployed Azure prerequisites as deployment %s", prerequisites.DeploymentName(spec))
+	return nil
+}
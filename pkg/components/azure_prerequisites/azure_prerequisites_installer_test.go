@@ -0,0 +1,70 @@
+package azure_prerequisites
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/prerequisites"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// fakeClient reports whether the resource group exists and counts deployments
+type fakeClient struct {
+	groupExists bool
+	deployments int
+}
+
+func (f *fakeClient) ResourceGroupExists(ctx context.Context, name string) (bool, error) {
+	return f.groupExists, nil
+}
+
+func (f *fakeClient) ResourceExists(ctx context.Context, id, apiVersion string) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeClient) DeployAtSubscription(ctx context.Context, name, location string, template, parameters map[string]any) error {
+	f.deployments++
+	return nil
+}
+
+func TestExecute(t *testing.T) {
+	original := newClient
+	defer func() { newClient = original }()
+
+	tests := []struct {
+		name            string
+		deploy          bool
+		groupExists     bool
+		wantCompleted   bool
+		wantDeployments int
+	}{
+		{name: "disabled", deploy: false, wantCompleted: true},
+		{name: "missing resource group", deploy: true, groupExists: false, wantDeployments: 1},
+		{name: "everything exists", deploy: true, groupExists: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{groupExists: tt.groupExists}
+			newClient = func(context.Context, *config.Config, *logrus.Logger) (prerequisites.Client, error) {
+				return client, nil
+			}
+			cfg := &config.Config{}
+			cfg.Azure.SubscriptionID = "12345678-1234-1234-1234-123456789012"
+			cfg.Azure.Prerequisites = &config.PrerequisitesConfig{Deploy: tt.deploy, ResourceGroup: "site-rg", Location: "westeurope"}
+
+			installer := NewInstaller(cfg, logrus.New())
+			if got := installer.IsCompleted(context.Background()); got != tt.wantCompleted {
+				t.Errorf("IsCompleted() = %v, want %v", got, tt.wantCompleted)
+			}
+			if err := installer.Execute(context.Background()); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if client.deployments != tt.wantDeployments {
+				t.Errorf("deployments = %d, want %d", client.deployments, tt.wantDeployments)
+			}
+		})
+	}
+}
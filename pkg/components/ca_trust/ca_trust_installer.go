@@ -0,0 +1,267 @@
+package ca_trust
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/keyvault"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Installer installs enterprise root CAs into the OS trust store and containerd's registry TLS config
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new CA trust Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "CATrustInstaller"
+}
+
+// Validate checks that configured certificate files exist and that the OS has a supported trust store
+func (i *Installer) Validate(ctx context.Context) error {
+	for _, path := range i.config.CATrust.CertificateFiles {
+		if !utils.FileExists(path) {
+			return fmt.Errorf("CA certificate file %s does not exist", path)
+		}
+	}
+	if i.config.IsCATrustConfigured() {
+		if _, err := detectTrustStore(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsCompleted returns true only when no CA trust is configured and nothing was installed before.
+// Otherwise Execute runs every time; it is idempotent and only touches files whose content changed.
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if i.config.IsCATrustConfigured() {
+		return false
+	}
+	store, err := detectTrustStore()
+	return err != nil || len(store.managedFiles()) == 0
+}
+
+// Execute installs the configured CA certificates
+func (i *Installer) Execute(ctx context.Context) error {
+	certs, err := CollectCertificates(ctx, i.config, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to collect CA certificates: %w", err)
+	}
+	i.logger.Infof("Installing %d custom CA certificate(s)", len(certs))
+
+	store, err := detectTrustStore()
+	if err != nil {
+		return err
+	}
+	changed, err := i.installTrustStore(store, certs)
+	if err != nil {
+		return fmt.Errorf("failed to install CA certificates into the OS trust store: %w", err)
+	}
+
+	if err := i.configureRegistries(certs); err != nil {
+		return fmt.Errorf("failed to configure containerd registry TLS: %w", err)
+	}
+
+	if changed {
+		i.restartArcServices()
+	}
+
+	i.logger.Info("Custom CA certificates installed successfully")
+	return nil
+}
+
+// TrustInProcess has the agent's Azure clients trust the caTrust certificates, so the calls it makes before
+// preflight, such as restoring state and discovering the cluster, reach Azure through a TLS-inspecting proxy
+// whose CA the host does not trust yet. It is a no-op unless caTrust is set.
+func TrustInProcess(ctx context.Context, cfg *config.Config, logger *logrus.Logger) error {
+	if !cfg.IsCATrustConfigured() {
+		return nil
+	}
+	certs, err := CollectCertificates(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("cannot read the caTrust certificates: %w", err)
+	}
+	auth.TrustCertificates(certs)
+	return nil
+}
+
+// CollectCertificates gathers the caTrust certificates from inline config, local files and Key Vault
+func CollectCertificates(ctx context.Context, cfg *config.Config, logger *logrus.Logger) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for idx, data := range cfg.CATrust.Certificates {
+		parsed, err := parseCertificates([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("caTrust.certificates[%d]: %w", idx, err)
+		}
+		certs = append(certs, parsed...)
+	}
+
+	for _, path := range cfg.CATrust.CertificateFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		parsed, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, parsed...)
+	}
+
+	if kv := cfg.CATrust.KeyVault; kv != nil && len(kv.CertificateNames) > 0 {
+		parsed, err := fetchKeyVaultCertificates(ctx, cfg, logger, kv)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, parsed...)
+	}
+
+	for _, cert := range certs {
+		if !cert.IsCA {
+			logger.Warnf("Certificate %q is not a CA certificate; it will only be trusted as a leaf", cert.Subject.String())
+		}
+	}
+	return dedupeCertificates(certs), nil
+}
+
+// fetchKeyVaultCertificates reads the configured certificates from Key Vault
+func fetchKeyVaultCertificates(ctx context.Context, cfg *config.Config, logger *logrus.Logger, kv *config.KeyVaultCertificatesConfig) ([]*x509.Certificate, error) {
+	cred, err := auth.NewAuthProvider().UserCredential(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authentication credential: %w", err)
+	}
+	clientOptions := auth.ClientOptions(cfg)
+	client, err := keyvault.NewCertificateClient(kv.VaultURL, cred, &clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for _, name := range kv.CertificateNames {
+		logger.Infof("Fetching CA certificate %s from %s", name, kv.VaultURL)
+		opCtx, cancel := auth.OperationContext(ctx, cfg)
+		data, err := client.GetCertificatePEM(opCtx, name)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch certificate %s from Key Vault: %w", name, err)
+		}
+		parsed, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("key Vault certificate %s: %w", name, err)
+		}
+		certs = append(certs, parsed...)
+	}
+	return certs, nil
+}
+
+// installTrustStore writes one file per certificate, removes stale ones and refreshes the trust store.
+// It reports whether the trust store changed.
+func (i *Installer) installTrustStore(store trustStore, certs []*x509.Certificate) (bool, error) {
+	if err := utils.RunSystemCommand("mkdir", "-p", store.dir); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", store.dir, err)
+	}
+
+	changed := false
+	desired := map[string]bool{}
+	for _, cert := range certs {
+		path := store.path(certificateFileName(cert))
+		desired[path] = true
+		data := encodeCertificate(cert)
+
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			continue
+		}
+		if err := utils.WriteFileAtomicSystem(path, data, 0o644); err != nil {
+			return false, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		i.logger.Infof("Installed CA certificate %q", cert.Subject.String())
+		changed = true
+	}
+
+	for _, path := range store.managedFiles() {
+		if desired[path] {
+			continue
+		}
+		if err := utils.RunCleanupCommand(path); err != nil {
+			return false, fmt.Errorf("failed to remove stale certificate %s: %w", path, err)
+		}
+		i.logger.Infof("Removed CA certificate %s that is no longer configured", path)
+		changed = true
+	}
+
+	if !changed {
+		i.logger.Info("OS trust store already contains the configured CA certificates")
+		return false, nil
+	}
+	if err := utils.RunSystemCommand(store.update[0], store.update[1:]...); err != nil {
+		return false, fmt.Errorf("%s failed: %w", strings.Join(store.update, " "), err)
+	}
+	return true, nil
+}
+
+// configureRegistries writes a CA bundle and hosts.toml for each configured registry.
+// containerd reads certs.d on every pull, so no restart is needed.
+func (i *Installer) configureRegistries(certs []*x509.Certificate) error {
+	var bundle bytes.Buffer
+	for _, cert := range certs {
+		bundle.Write(encodeCertificate(cert))
+	}
+
+	for _, registry := range i.config.CATrust.Registries {
+		dir := filepath.Join(containerdCertsDir, registry)
+		hostsPath := filepath.Join(dir, registryHostsFileName)
+
+		// Never overwrite registry configuration the user manages themselves
+		if existing, err := os.ReadFile(hostsPath); err == nil && !isManagedFile(existing) {
+			i.logger.Warnf("Skipping %s: %s is not managed by aks-flex-node", registry, hostsPath)
+			continue
+		}
+
+		if err := utils.RunSystemCommand("mkdir", "-p", dir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := utils.WriteFileAtomicSystem(filepath.Join(dir, registryCAFileName), bundle.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write CA bundle for %s: %w", registry, err)
+		}
+		if err := utils.WriteFileAtomicSystem(hostsPath, []byte(registryHostsTOML(registry)), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", hostsPath, err)
+		}
+		i.logger.Infof("Configured containerd to trust custom CAs for registry %s", registry)
+	}
+	return nil
+}
+
+// restartArcServices restarts running Arc agent services so they pick up the new trust store
+func (i *Installer) restartArcServices() {
+	for _, service := range arcServices {
+		if !utils.IsServiceActive(service) {
+			continue
+		}
+		if err := utils.RestartService(service); err != nil {
+			i.logger.Warnf("Failed to restart %s after trust store update: %v", service, err)
+			continue
+		}
+		i.logger.Infof("Restarted %s to load the updated trust store", service)
+	}
+}
@@ -0,0 +1,97 @@
+package ca_trust
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes CA certificates and registry TLS configuration installed by the agent
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new CA trust unInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "CATrustCleanup"
+}
+
+// IsCompleted checks if the agent's CA certificates have been removed
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	store, err := detectTrustStore()
+	return (err != nil || len(store.managedFiles()) == 0) && len(u.managedRegistryDirs()) == 0
+}
+
+// Execute removes the agent's CA certificates and refreshes the trust store
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	u.logger.Info("Removing custom CA certificates")
+
+	for _, dir := range u.managedRegistryDirs() {
+		if err := utils.RunSystemCommand("rm", "-rf", dir); err != nil {
+			u.logger.Warnf("Failed to remove registry TLS configuration %s: %v", dir, err)
+		}
+	}
+
+	if store, err := detectTrustStore(); err == nil {
+		u.removeTrustStoreFiles(store)
+	}
+
+	u.logger.Info("Custom CA certificate cleanup completed")
+	return nil
+}
+
+// removeTrustStoreFiles removes the agent's certificates from the trust store and refreshes it
+func (u *UnInstaller) removeTrustStoreFiles(store trustStore) {
+	files := store.managedFiles()
+	if len(files) == 0 && !(store.ownsDir() && utils.DirectoryExists(store.dir)) {
+		return
+	}
+	for _, path := range files {
+		if err := utils.RunCleanupCommand(path); err != nil {
+			u.logger.Warnf("Failed to remove %s: %v", path, err)
+		}
+	}
+	if store.ownsDir() {
+		if err := utils.RunSystemCommand("rm", "-rf", store.dir); err != nil {
+			u.logger.Warnf("Failed to remove %s: %v", store.dir, err)
+		}
+	}
+	if err := utils.RunSystemCommand(store.refresh[0], store.refresh[1:]...); err != nil {
+		u.logger.Warnf("Failed to refresh trust store: %v", err)
+	}
+}
+
+// managedRegistryDirs returns containerd registry directories whose hosts.toml was written by the agent
+func (u *UnInstaller) managedRegistryDirs() []string {
+	entries, err := os.ReadDir(containerdCertsDir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(containerdCertsDir, entry.Name())
+		content, err := os.ReadFile(filepath.Join(dir, registryHostsFileName))
+		if err == nil && isManagedFile(content) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
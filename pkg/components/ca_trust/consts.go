@@ -0,0 +1,16 @@
+package ca_trust
+
+const (
+	// containerd reads per-registry TLS configuration from this directory (see containerd config_path)
+	containerdCertsDir = "/etc/containerd/certs.d"
+
+	// File names written into each registry directory
+	registryHostsFileName = "hosts.toml"
+	registryCAFileName    = "aks-flex-node-ca.crt"
+
+	// First line of files written by the agent so user-managed files are never overwritten or removed
+	managedMarker = "# Managed by aks-flex-node"
+)
+
+// Arc services restarted after the trust store changes so they load the new roots
+var arcServices = []string{"himdsd", "gcarcservice", "extd"}
@@ -0,0 +1,81 @@
+package ca_trust
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"path/filepath"
+)
+
+// parseCertificates decodes all PEM certificates in data, rejecting malformed input and non-certificate blocks
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := bytes.TrimSpace(data)
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("data is not PEM encoded")
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q, only certificates are supported", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+		rest = bytes.TrimSpace(rest)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return certs, nil
+}
+
+// encodeCertificate returns the PEM encoding of a certificate
+func encodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// certificateFileName derives a stable file name from the certificate fingerprint, so the same CA
+// from several sources is installed once and changes are detected by name
+func certificateFileName(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return fmt.Sprintf("aks-flex-node-%s.crt", hex.EncodeToString(sum[:8]))
+}
+
+// dedupeCertificates drops certificates with the same fingerprint, keeping the first occurrence
+func dedupeCertificates(certs []*x509.Certificate) []*x509.Certificate {
+	seen := map[string]bool{}
+	var unique []*x509.Certificate
+	for _, cert := range certs {
+		name := certificateFileName(cert)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, cert)
+	}
+	return unique
+}
+
+// registryHostsTOML renders the containerd hosts.toml trusting the CA bundle for a registry
+func registryHostsTOML(registry string) string {
+	caPath := filepath.Join(containerdCertsDir, registry, registryCAFileName)
+	return fmt.Sprintf(`%s
+server = "https://%s"
+
+[host."https://%s"]
+  capabilities = ["pull", "resolve"]
+  ca = "%s"
+`, managedMarker, registry, registry, caPath)
+}
+
+// isManagedFile checks if file content was written by the agent
+func isManagedFile(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(managedMarker))
+}
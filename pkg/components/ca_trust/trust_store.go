@@ -0,0 +1,92 @@
+package ca_trust
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// trustStore is an OS trust store the agent installs certificates into
+type trustStore struct {
+	// sourceDir is the directory the OS reads local CA certificates from
+	sourceDir string
+	// dir holds the agent's certificates; a directory of its own where the OS reads sources recursively
+	dir string
+	// prefix names the agent's certificates where they share sourceDir with others
+	prefix string
+	// update regenerates the trust store from its sources
+	update []string
+	// refresh regenerates the trust store after certificates were removed
+	refresh []string
+}
+
+// trustStores are the supported trust stores, in detection order
+var trustStores = []trustStore{
+	{ // Debian and Ubuntu
+		sourceDir: "/usr/local/share/ca-certificates",
+		dir:       "/usr/local/share/ca-certificates/aks-flex-node",
+		update:    []string{"update-ca-certificates"},
+		refresh:   []string{"update-ca-certificates", "--fresh"},
+	},
+	{ // RHEL, Fedora and Azure Linux; p11-kit does not read subdirectories of the anchors
+		sourceDir: "/etc/pki/ca-trust/source/anchors",
+		dir:       "/etc/pki/ca-trust/source/anchors",
+		prefix:    "aks-flex-node-",
+		update:    []string{"update-ca-trust", "extract"},
+		refresh:   []string{"update-ca-trust", "extract"},
+	},
+}
+
+// detectTrustStore returns the trust store of this OS
+func detectTrustStore() (trustStore, error) {
+	return detectTrustStoreWith(utils.DirectoryExists, func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	})
+}
+
+// TrustStoreDir returns the directory this OS reads local CA certificates from, or an error when caTrust is not
+// supported on this OS
+func TrustStoreDir() (string, error) {
+	store, err := detectTrustStore()
+	return store.sourceDir, err
+}
+
+func detectTrustStoreWith(dirExists, commandExists func(string) bool) (trustStore, error) {
+	for _, store := range trustStores {
+		if dirExists(store.sourceDir) && commandExists(store.update[0]) {
+			return store, nil
+		}
+	}
+	return trustStore{}, fmt.Errorf("caTrust is not supported on this OS: neither update-ca-certificates nor update-ca-trust is installed")
+}
+
+// path returns where a certificate file of the agent goes
+func (s trustStore) path(name string) string {
+	return filepath.Join(s.dir, s.prefix+name)
+}
+
+// managedFiles returns the certificate files the agent installed
+func (s trustStore) managedFiles() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), s.prefix) || !strings.HasSuffix(entry.Name(), ".crt") {
+			continue
+		}
+		files = append(files, filepath.Join(s.dir, entry.Name()))
+	}
+	return files
+}
+
+// ownsDir reports whether dir holds only the agent's certificates
+func (s trustStore) ownsDir() bool {
+	return s.dir != s.sourceDir
+}
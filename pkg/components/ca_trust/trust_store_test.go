@@ -0,0 +1,86 @@
+package ca_trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectTrustStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirs     []string
+		commands []string
+		wantDir  string
+		wantErr  bool
+	}{
+		{
+			name:     "debian",
+			dirs:     []string{"/usr/local/share/ca-certificates"},
+			commands: []string{"update-ca-certificates"},
+			wantDir:  "/usr/local/share/ca-certificates/aks-flex-node",
+		},
+		{
+			name:     "rhel",
+			dirs:     []string{"/etc/pki/ca-trust/source/anchors"},
+			commands: []string{"update-ca-trust"},
+			wantDir:  "/etc/pki/ca-trust/source/anchors",
+		},
+		{
+			name:     "directory without its update command",
+			dirs:     []string{"/usr/local/share/ca-certificates"},
+			commands: []string{"update-ca-trust"},
+			wantErr:  true,
+		},
+		{name: "unsupported", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contains := func(list []string) func(string) bool {
+				return func(s string) bool {
+					for _, item := range list {
+						if item == s {
+							return true
+						}
+					}
+					return false
+				}
+			}
+			store, err := detectTrustStoreWith(contains(tt.dirs), contains(tt.commands))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("detectTrustStoreWith() = %+v, want an error", store)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("detectTrustStoreWith() unexpected error: %v", err)
+			}
+			if store.dir != tt.wantDir {
+				t.Errorf("dir = %s, want %s", store.dir, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestTrustStoreManagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"aks-flex-node-1a2b.crt", "ca-bundle.crt", "aks-flex-node-notes.txt", "corp-root.pem"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := trustStore{sourceDir: dir, dir: dir, prefix: "aks-flex-node-"}
+
+	files := store.managedFiles()
+	if len(files) != 1 || filepath.Base(files[0]) != "aks-flex-node-1a2b.crt" {
+		t.Errorf("managedFiles() = %v, want only the agent's certificate", files)
+	}
+	if path := store.path("1a2b.crt"); path != filepath.Join(dir, "aks-flex-node-1a2b.crt") {
+		t.Errorf("path() = %s", path)
+	}
+	if store.ownsDir() {
+		t.Error("ownsDir() = true for a directory shared with other certificates")
+	}
+}
@@ -2,11 +2,13 @@ package cni
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -17,9 +19,9 @@ type Installer struct {
 }
 
 // NewInstaller creates a new CNI setup Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -52,7 +54,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 
 	// Install CNI plugins
 	i.logger.Info("Step 2: Installing CNI plugins")
-	if err := i.installCNIPlugins(); err != nil {
+	if err := i.installCNIPlugins(ctx); err != nil {
 		i.logger.Errorf("CNI plugins installation failed: %v", err)
 		return fmt.Errorf("failed to install CNI plugins version %s: %w", defaultCNIVersion, err)
 	}
@@ -60,7 +62,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 
 	// Create bridge configuration for edge node
 	i.logger.Info("Step 3: Creating bridge configuration")
-	if err := i.createBridgeConfig(); err != nil {
+	if err := i.createBridgeConfig(ctx); err != nil {
 		i.logger.Errorf("Bridge configuration creation failed: %v", err)
 		return fmt.Errorf("failed to create bridge config: %w", err)
 	}
@@ -130,7 +132,7 @@ func (i *Installer) prepareCNIDirectories() error {
 }
 
 // installCNIPlugins downloads and installs CNI plugins (matching reference script)
-func (i *Installer) installCNIPlugins() error {
+func (i *Installer) installCNIPlugins(ctx context.Context) error {
 	if canSkipCNIPluginInstallation() {
 		logrus.Info("CNI plugins are already installed and valid, skipping installation")
 		return nil
@@ -142,14 +144,6 @@ func (i *Installer) installCNIPlugins() error {
 		logrus.Warnf("Failed to clean CNI bin directory: %v", err)
 	}
 
-	// Ensure curl is installed before using it for downloading CNI plugins
-	if err := utils.RunSystemCommand("which", "curl"); err != nil {
-		logrus.Info("Installing curl...")
-		if err := utils.RunSystemCommand("apt", "install", "-y", "curl"); err != nil {
-			logrus.Warnf("Failed to install curl: %v", err)
-		}
-	}
-
 	// Construct CNI download URL
 	cniFileName, cniDownloadURL, err := i.constructCNIDownloadURL()
 	if err != nil {
@@ -162,17 +156,17 @@ func (i *Installer) installCNIPlugins() error {
 	if err := utils.RunSystemCommand("bash", "-c", fmt.Sprintf("rm -f %s", tempFile)); err != nil {
 		logrus.Warnf("Failed to clean up existing CNI temp files from /tmp: %s", err)
 	}
-	if err := utils.RunSystemCommand("curl", "-o", tempFile, "-L", cniDownloadURL); err != nil {
-		return fmt.Errorf("failed to download CNI plugins: %w", err)
-	}
 	defer func() {
 		if err := utils.RunCleanupCommand(tempFile); err != nil {
 			logrus.Warnf("Failed to clean up temp file %s: %v", tempFile, err)
 		}
 	}()
+	if err := download.NewManager(i.config, i.logger).For("cni-plugins", getCNIVersion(i.config)).Fetch(ctx, cniDownloadURL, tempFile); err != nil {
+		return fmt.Errorf("failed to download CNI plugins: %w", err)
+	}
 
 	// Extract CNI plugins to /opt/cni/bin
-	if err := utils.RunSystemCommand("tar", "-C", DefaultCNIBinDir, "-xzf", tempFile); err != nil {
+	if err := utils.RunLimitedCommand(i.config.GetInstallLimitProperties(), "tar", "-C", DefaultCNIBinDir, "-xzf", tempFile); err != nil {
 		return fmt.Errorf("failed to extract CNI plugins: %w", err)
 	}
 
@@ -207,7 +201,7 @@ func (i *Installer) constructCNIDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	url := fmt.Sprintf(cniDownLoadURL, cniVersion, arch, cniVersion)
+	url := i.config.GetArtifactURL("cni-plugins", cniVersion, arch, fmt.Sprintf(cniDownLoadURL, cniVersion, arch, cniVersion))
 	fileName := fmt.Sprintf(cniFileName, arch, cniVersion)
 	i.logger.Infof("Constructed CNI download URL: %s", url)
 	return fileName, url, nil
@@ -222,7 +216,7 @@ func getCNIVersion(cfg *config.Config) string {
 
 // CreateBridgeConfig creates bridge CNI configuration for edge nodes (compatible with BYO Cilium)
 // Uses 99-bridge.conf filename to ensure CNI solutions like Cilium can override with higher priority configs
-func (i *Installer) createBridgeConfig() error {
+func (i *Installer) createBridgeConfig(ctx context.Context) error {
 	configPath := filepath.Join(DefaultCNIConfDir, bridgeConfigFile)
 
 	// Load br_netfilter kernel module which is required for bridge networking
@@ -238,33 +232,13 @@ func (i *Installer) createBridgeConfig() error {
 		logrus.Warnf("Failed to remove existing config file: %v", err)
 	}
 
-	bridgeConfig := fmt.Sprintf(`{
-    "cniVersion": "%s",
-    "name": "bridge",
-    "type": "bridge",
-    "bridge": "cni0",
-    "isGateway": true,
-    "ipMasq": true,
-    "ipam": {
-        "type": "host-local",
-        "ranges": [
-            [
-                {
-                    "subnet": "10.244.0.0/16",
-                    "gateway": "10.244.0.1"
-                }
-            ]
-        ],
-        "routes": [
-            {
-                "dst": "0.0.0.0/0"
-            }
-        ]
-    }
-}`, defaultCNISpecVersion)
+	bridgeConfig, err := renderBridgeConfig(i.config.GetIPFamilies(), i.podMTU(ctx))
+	if err != nil {
+		return err
+	}
 
 	// Write the config file into a temp file for Atomic file write
-	tempBridgeFile, err := utils.CreateTempFile("bridge-cni-*.conf", []byte(bridgeConfig))
+	tempBridgeFile, err := utils.CreateTempFile("bridge-cni-*.conf", bridgeConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create temporary bridge config file: %w", err)
 	}
@@ -288,3 +262,46 @@ func (i *Installer) createBridgeConfig() error {
 	logrus.Info("Bridge CNI configuration created")
 	return nil
 }
+
+// bridgeRange is a host-local IPAM range of the bridge configuration
+type bridgeRange struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway"`
+}
+
+// renderBridgeConfig renders the bridge configuration with a pod subnet and default route per IP family, so
+// pods on dual-stack nodes get an address of each family. An mtu of 0 keeps the bridge plugin's default.
+func renderBridgeConfig(families []string, mtu int) ([]byte, error) {
+	var ranges [][]bridgeRange
+	var routes []map[string]string
+	for _, family := range families {
+		if family == config.IPFamilyIPv6 {
+			ranges = append(ranges, []bridgeRange{{Subnet: BridgePodSubnetIPv6, Gateway: bridgeGatewayIPv6}})
+			routes = append(routes, map[string]string{"dst": "::/0"})
+			continue
+		}
+		ranges = append(ranges, []bridgeRange{{Subnet: BridgePodSubnet, Gateway: bridgeGateway}})
+		routes = append(routes, map[string]string{"dst": "0.0.0.0/0"})
+	}
+	bridgeConfig := map[string]any{
+		"cniVersion": defaultCNISpecVersion,
+		"name":       "bridge",
+		"type":       "bridge",
+		"bridge":     "cni0",
+		"isGateway":  true,
+		"ipMasq":     true,
+		"ipam": map[string]any{
+			"type":   "host-local",
+			"ranges": ranges,
+			"routes": routes,
+		},
+	}
+	if mtu > 0 {
+		bridgeConfig["mtu"] = mtu
+	}
+	data, err := json.MarshalIndent(bridgeConfig, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bridge config: %w", err)
+	}
+	return data, nil
+}
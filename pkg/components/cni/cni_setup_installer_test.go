@@ -0,0 +1,101 @@
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderBridgeConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		families   []string
+		mtu        int
+		wantRanges []string
+		wantRoutes []string
+	}{
+		{name: "IPv4", families: []string{"IPv4"}, wantRanges: []string{BridgePodSubnet}, wantRoutes: []string{"0.0.0.0/0"}},
+		{name: "IPv6", families: []string{"IPv6"}, wantRanges: []string{BridgePodSubnetIPv6}, wantRoutes: []string{"::/0"}},
+		{name: "dual-stack", families: []string{"IPv6", "IPv4"}, wantRanges: []string{BridgePodSubnetIPv6, BridgePodSubnet}, wantRoutes: []string{"::/0", "0.0.0.0/0"}},
+		{name: "tunnel mtu", families: []string{"IPv4"}, mtu: 1380, wantRanges: []string{BridgePodSubnet}, wantRoutes: []string{"0.0.0.0/0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := renderBridgeConfig(tt.families, tt.mtu)
+			if err != nil {
+				t.Fatalf("renderBridgeConfig() unexpected error: %v", err)
+			}
+			var rendered struct {
+				Type string `json:"type"`
+				MTU  int    `json:"mtu"`
+				IPAM struct {
+					Ranges [][]bridgeRange     `json:"ranges"`
+					Routes []map[string]string `json:"routes"`
+				} `json:"ipam"`
+			}
+			if err := json.Unmarshal(data, &rendered); err != nil {
+				t.Fatalf("renderBridgeConfig() rendered invalid JSON: %v", err)
+			}
+			if rendered.Type != "bridge" || len(rendered.IPAM.Ranges) != len(tt.wantRanges) || len(rendered.IPAM.Routes) != len(tt.wantRoutes) {
+				t.Fatalf("renderBridgeConfig() = %s", data)
+			}
+			if rendered.MTU != tt.mtu {
+				t.Errorf("renderBridgeConfig() mtu = %d, want %d", rendered.MTU, tt.mtu)
+			}
+			for i := range tt.wantRanges {
+				if rendered.IPAM.Ranges[i][0].Subnet != tt.wantRanges[i] || rendered.IPAM.Routes[i]["dst"] != tt.wantRoutes[i] {
+					t.Errorf("renderBridgeConfig() range %d = %+v, route %v", i, rendered.IPAM.Ranges[i], rendered.IPAM.Routes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPodMTU(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured int
+		serverURL  string
+		families   []string
+		mtuErr     error
+		want       int
+		wantTarget string
+	}{
+		{name: "configured", configured: 1380, want: 1380},
+		{name: "toward the api server", serverURL: "https://flex-abc.privatelink.eastus.azmk8s.io:443", want: 1400, wantTarget: "10.1.0.4:443"},
+		{name: "api server by address", serverURL: "https://10.2.0.4:6443", want: 1400, wantTarget: "10.2.0.4:443"},
+		{name: "default route", want: 1400, wantTarget: "192.0.2.1:9"},
+		{name: "IPv6 default route", families: []string{"IPv6"}, want: 1400, wantTarget: "[2001:db8::1]:9"},
+		{name: "detection fails", mtuErr: errors.New("network is unreachable"), want: 0, wantTarget: "192.0.2.1:9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target string
+			origRouteMTU, origLookup := routeMTU, lookupHost
+			defer func() { routeMTU, lookupHost = origRouteMTU, origLookup }()
+			routeMTU = func(address string) (int, error) {
+				target = address
+				return 1400, tt.mtuErr
+			}
+			lookupHost = func(ctx context.Context, host string) ([]net.IP, error) {
+				return []net.IP{net.ParseIP("10.1.0.4")}, nil
+			}
+
+			cfg := &config.Config{CNI: config.CNIConfig{MTU: tt.configured}}
+			cfg.Node.Kubelet.ServerURL = tt.serverURL
+			cfg.Node.Network.IPFamilies = tt.families
+			if got := NewInstaller(cfg, logrus.New()).podMTU(context.Background()); got != tt.want {
+				t.Errorf("podMTU() = %d, want %d", got, tt.want)
+			}
+			if target != tt.wantTarget {
+				t.Errorf("measured toward %q, want %q", target, tt.wantTarget)
+			}
+		})
+	}
+}
@@ -13,6 +13,15 @@ const (
 	// can override this temporary bridge with lower-numbered configs (e.g., 05-cilium.conf)
 	bridgeConfigFile = "99-bridge.conf"
 
+	// BridgePodSubnet is the pod address range handed out by the temporary bridge configuration
+	BridgePodSubnet = "10.244.0.0/16"
+	// bridgeGateway is the gateway address of the bridge within BridgePodSubnet
+	bridgeGateway = "10.244.0.1"
+	// BridgePodSubnetIPv6 is the IPv6 pod address range of the bridge configuration on IPv6 and dual-stack nodes
+	BridgePodSubnetIPv6 = "fd00:10:244::/64"
+	// bridgeGatewayIPv6 is the gateway address of the bridge within BridgePodSubnetIPv6
+	bridgeGatewayIPv6 = "fd00:10:244::1"
+
 	// Required CNI plugins
 	bridgePlugin    = "bridge"
 	hostLocalPlugin = "host-local"
@@ -0,0 +1,98 @@
+package cni
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/ipfamily"
+)
+
+// overlayOverhead is the encapsulation overhead of VXLAN and Geneve overlays such as Cilium's tunnel mode
+const overlayOverhead = 50
+
+// routeMTU returns the kernel's MTU for the route to address, including a smaller path MTU learned from
+// ICMP "fragmentation needed" messages, such as during the APIServerPath preflight check; replaced in tests
+var routeMTU = func(address string) (int, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return 0, fmt.Errorf("not a UDP connection")
+	}
+	raw, err := udpConn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	level, opt := syscall.IPPROTO_IP, syscall.IP_MTU
+	if remote, ok := conn.RemoteAddr().(*net.UDPAddr); ok && remote.IP.To4() == nil {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_MTU
+	}
+	var mtu int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		mtu, sockErr = syscall.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		return 0, err
+	}
+	return mtu, sockErr
+}
+
+// lookupHost resolves the API server's host name; replaced in tests
+var lookupHost = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// podMTU returns the MTU of pod interfaces: cni.mtu when set, otherwise the path MTU toward the API server,
+// or toward the default route when the API server is not known before bootstrap. Pod traffic is not
+// encapsulated by the bridge, so pods get the full path MTU. It returns 0 when detection fails, which keeps
+// the bridge plugin's default.
+func (i *Installer) podMTU(ctx context.Context) int {
+	if i.config.CNI.MTU != 0 {
+		i.logger.Infof("Using configured pod MTU %d", i.config.CNI.MTU)
+		return i.config.CNI.MTU
+	}
+
+	target, description, err := i.mtuTarget(ctx)
+	if err != nil {
+		i.logger.Warnf("Cannot detect the path MTU, keeping the default pod MTU; set cni.mtu if the node is behind a tunnel: %v", err)
+		return 0
+	}
+	mtu, err := routeMTU(target)
+	if err != nil {
+		i.logger.Warnf("Cannot detect the path MTU toward %s, keeping the default pod MTU; set cni.mtu if the node is behind a tunnel: %v", description, err)
+		return 0
+	}
+	i.logger.Infof("Path MTU toward %s is %d; pods use MTU %d", description, mtu, mtu)
+	i.logger.Infof("Overlay CNIs such as Cilium in tunnel mode need an MTU of at most %d on this node", mtu-overlayOverhead)
+	return mtu
+}
+
+// mtuTarget returns the address the path MTU is measured toward and a description for the logs
+func (i *Installer) mtuTarget(ctx context.Context) (string, string, error) {
+	serverURL := i.config.Node.Kubelet.ServerURL
+	if serverURL == "" {
+		family := i.config.GetIPFamilies()[0]
+		return ipfamily.ProbeAddress(family), fmt.Sprintf("the %s default route", family), nil
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Hostname() == "" {
+		return "", "", fmt.Errorf("invalid node.kubelet.serverURL %q", serverURL)
+	}
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil {
+		addrs, err := lookupHost(ctx, u.Hostname())
+		if err != nil || len(addrs) == 0 {
+			return "", "", fmt.Errorf("%s does not resolve: %v", u.Hostname(), err)
+		}
+		ip = addrs[0]
+	}
+	return net.JoinHostPort(ip.String(), "443"), "the API server " + u.Hostname(), nil
+}
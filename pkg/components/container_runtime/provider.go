@@ -0,0 +1,98 @@
+// Package container_runtime selects the CRI container runtime of the node. Each runtime is a
+// Provider that supplies its install and uninstall steps and the socket kubelet connects to.
+package container_runtime
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/crio"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// Step is a bootstrap or unbootstrap step supplied by a runtime provider
+type Step interface {
+	Execute(ctx context.Context) error
+	IsCompleted(ctx context.Context) bool
+	GetName() string
+}
+
+// InstallStep is a bootstrap step that also validates its preconditions
+type InstallStep interface {
+	Step
+	Validate(ctx context.Context) error
+}
+
+// Provider is a container runtime kubelet can use
+type Provider interface {
+	// Name returns the runtime name used in the containerRuntime setting
+	Name() string
+	// ServiceName returns the runtime's systemd unit
+	ServiceName() string
+	// Socket returns the path of the runtime's CRI socket
+	Socket() string
+	// PauseImage returns the infra container image the runtime uses for pod sandboxes
+	PauseImage() string
+	// NewInstaller returns the step installing and configuring the runtime
+	NewInstaller(logger *logrus.Logger) InstallStep
+	// NewUnInstaller returns the step removing the runtime
+	NewUnInstaller(logger *logrus.Logger) Step
+}
+
+// ForConfig returns the provider of the runtime selected by containerRuntime
+func ForConfig(cfg *config.Config) Provider {
+	if cfg.GetContainerRuntime() == "cri-o" {
+		return &crioProvider{config: cfg}
+	}
+	return &containerdProvider{config: cfg}
+}
+
+// Endpoint returns the CRI endpoint of a provider in the form kubelet and CRI clients expect
+func Endpoint(p Provider) string {
+	return "unix://" + p.Socket()
+}
+
+// NewInstaller returns the install step of the configured runtime
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) InstallStep {
+	return ForConfig(cfg).NewInstaller(logger)
+}
+
+// NewUnInstaller returns the uninstall step of the configured runtime
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) Step {
+	return ForConfig(cfg).NewUnInstaller(logger)
+}
+
+type containerdProvider struct {
+	config *config.Config
+}
+
+func (p *containerdProvider) Name() string        { return "containerd" }
+func (p *containerdProvider) ServiceName() string { return containerd.ServiceName }
+func (p *containerdProvider) Socket() string      { return containerd.Socket }
+func (p *containerdProvider) PauseImage() string  { return containerd.PauseImage(p.config) }
+
+func (p *containerdProvider) NewInstaller(logger *logrus.Logger) InstallStep {
+	return containerd.NewInstaller(p.config, logger)
+}
+
+func (p *containerdProvider) NewUnInstaller(logger *logrus.Logger) Step {
+	return containerd.NewUnInstaller(logger)
+}
+
+type crioProvider struct {
+	config *config.Config
+}
+
+func (p *crioProvider) Name() string        { return "cri-o" }
+func (p *crioProvider) ServiceName() string { return crio.ServiceName }
+func (p *crioProvider) Socket() string      { return crio.Socket }
+func (p *crioProvider) PauseImage() string  { return crio.PauseImage(p.config) }
+
+func (p *crioProvider) NewInstaller(logger *logrus.Logger) InstallStep {
+	return crio.NewInstaller(p.config, logger)
+}
+
+func (p *crioProvider) NewUnInstaller(logger *logrus.Logger) Step {
+	return crio.NewUnInstaller(logger)
+}
@@ -0,0 +1,53 @@
+package container_runtime
+
+import (
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestForConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		wantName    string
+		wantService string
+		wantSocket  string
+		wantPause   string
+	}{
+		{
+			name:        "containerd by default",
+			cfg:         &config.Config{},
+			wantName:    "containerd",
+			wantService: "containerd",
+			wantSocket:  "unix:///run/containerd/containerd.sock",
+			wantPause:   "mcr.microsoft.com/oss/kubernetes/pause:3.6",
+		},
+		{
+			name:        "cri-o",
+			cfg:         &config.Config{ContainerRuntime: "cri-o", CRIO: config.CRIOConfig{PauseImage: "registry.example.com/pause:3.10"}},
+			wantName:    "cri-o",
+			wantService: "crio",
+			wantSocket:  "unix:///run/crio/crio.sock",
+			wantPause:   "registry.example.com/pause:3.10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ForConfig(tt.cfg)
+			if p.Name() != tt.wantName {
+				t.Errorf("Name() = %q, want %q", p.Name(), tt.wantName)
+			}
+			if p.ServiceName() != tt.wantService {
+				t.Errorf("ServiceName() = %q, want %q", p.ServiceName(), tt.wantService)
+			}
+			if got := Endpoint(p); got != tt.wantSocket {
+				t.Errorf("Endpoint() = %q, want %q", got, tt.wantSocket)
+			}
+			if p.PauseImage() != tt.wantPause {
+				t.Errorf("PauseImage() = %q, want %q", p.PauseImage(), tt.wantPause)
+			}
+		})
+	}
+}
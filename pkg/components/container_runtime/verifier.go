@@ -0,0 +1,80 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/cri"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// How long the runtime may take to come up and to pull and run the pause image
+	serviceStartTimeout = 30 * time.Second
+	smokeTestTimeout    = 3 * time.Minute
+)
+
+// Verifier runs a container through the runtime's CRI API right after the runtime is installed.
+// A broken snapshotter, runc or registry access otherwise only shows up later as a NotReady node
+// or pods stuck in ContainerCreating.
+type Verifier struct {
+	provider Provider
+	logger   *logrus.Logger
+}
+
+// NewVerifier creates a new container runtime smoke test step
+func NewVerifier(cfg *config.Config, logger *logrus.Logger) *Verifier {
+	return &Verifier{
+		provider: ForConfig(cfg),
+		logger:   logger,
+	}
+}
+
+// GetName returns the step name
+func (v *Verifier) GetName() string {
+	return "ContainerRuntimeVerification"
+}
+
+// Execute restarts the runtime with the new configuration, pulls the pause image and runs it as a test container
+func (v *Verifier) Execute(ctx context.Context) error {
+	service := v.provider.ServiceName()
+	v.logger.Infof("Restarting %s to verify the container runtime", service)
+	if err := utils.EnableAndStartService(service); err != nil {
+		return fmt.Errorf("failed to start %s: %w", service, err)
+	}
+	if err := utils.RestartService(service); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", service, err)
+	}
+	if err := utils.WaitForService(service, serviceStartTimeout, v.logger); err != nil {
+		return fmt.Errorf("%s did not start: %w", service, err)
+	}
+
+	client, err := cri.NewClient(Endpoint(v.provider))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, smokeTestTimeout)
+	defer cancel()
+	if err := client.SmokeTest(ctx, v.provider.PauseImage(), v.logger); err != nil {
+		return fmt.Errorf("container runtime smoke test failed: %w", err)
+	}
+	v.logger.Infof("✅ %s pulled and ran a test container successfully", v.provider.Name())
+	return nil
+}
+
+// IsCompleted always returns false so the runtime is verified on every bootstrap
+func (v *Verifier) IsCompleted(ctx context.Context) bool {
+	return false
+}
+
+// Validate validates preconditions before execution
+func (v *Verifier) Validate(ctx context.Context) error {
+	return nil
+}
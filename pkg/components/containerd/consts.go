@@ -1,14 +1,34 @@
 package containerd
 
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
+
 const (
-	systemBinDir               = "/usr/bin"
-	defaultContainerdBinaryDir = "/usr/bin/containerd"
 	defaultContainerdConfigDir = "/etc/containerd"
 	containerdConfigFile       = "/etc/containerd/config.toml"
 	containerdServiceFile      = "/etc/systemd/system/containerd.service"
 	containerdDataDir          = "/var/lib/containerd"
+
+	// ServiceName is the systemd unit of containerd
+	ServiceName = "containerd"
+	// Socket is the CRI socket containerd listens on
+	Socket = "/run/containerd/containerd.sock"
+
+	// agentGroup is the group of the service user created by the install script
+	agentGroup = "aks-flex-node"
+)
+
+// Binary installation directory, /opt/bin on immutable hosts
+var (
+	systemBinDir               = osimage.BinDir("/usr/bin")
+	defaultContainerdBinaryDir = systemBinDir + "/containerd"
+
+	// runc is installed by the runc component
+	runcBinary = osimage.BinPath("/usr/bin/runc")
 )
 
+// systemdDefaultPath is the PATH systemd gives services; containerd finds its shims there
+const systemdDefaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
 var containerdDirs = []string{
 	defaultContainerdConfigDir,
 }
@@ -3,13 +3,20 @@ package containerd
 import (
 	"context"
 	"fmt"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroup"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
 )
 
 // Installer handles containerd installation operations
@@ -19,9 +26,9 @@ type Installer struct {
 }
 
 // NewInstaller creates a new containerd Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -35,7 +42,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Info("Prepared containerd directories successfully")
 
 	i.logger.Infof("Step 2: Downloading and installing containerd version %s", i.getContainerdVersion())
-	if err := i.installContainerd(); err != nil {
+	if err := i.installContainerd(ctx); err != nil {
 		return fmt.Errorf("failed to install containerd: %w", err)
 	}
 	i.logger.Info("containerd binaries installed successfully")
@@ -76,7 +83,7 @@ func (i *Installer) prepareContainerdDirectories() error {
 	return nil
 }
 
-func (i *Installer) installContainerd() error {
+func (i *Installer) installContainerd(ctx context.Context) error {
 	// Check if we can skip installation
 	if i.canSkipContainerdInstallation() {
 		i.logger.Info("containerd is already installed and valid, skipping installation")
@@ -109,13 +116,13 @@ func (i *Installer) installContainerd() error {
 	}()
 
 	i.logger.Infof("Downloading containerd from %s into %s", containerdURL, tempFile)
-	if err := utils.DownloadFile(containerdURL, tempFile); err != nil {
+	if err := download.NewManager(i.config, i.logger).For("containerd", i.getContainerdVersion()).Fetch(ctx, containerdURL, tempFile); err != nil {
 		return fmt.Errorf("failed to download containerd from %s: %w", containerdURL, err)
 	}
 
 	// Extract containerd binaries directly to /usr/bin, stripping the 'bin/' prefix
 	i.logger.Info("Extracting containerd binaries to /usr/bin")
-	if err := utils.RunSystemCommand("tar", "-C", systemBinDir, "--strip-components=1", "-xzf", tempFile, "bin/"); err != nil {
+	if err := utils.RunLimitedCommand(i.config.GetInstallLimitProperties(), "tar", "-C", systemBinDir, "--strip-components=1", "-xzf", tempFile, "bin/"); err != nil {
 		return fmt.Errorf("failed to extract containerd binaries: %w", err)
 	}
 
@@ -164,7 +171,7 @@ func (i *Installer) constructContainerdDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	url := fmt.Sprintf(containerdDownloadURL, containerdVersion, containerdVersion, arch)
+	url := i.config.GetArtifactURL("containerd", containerdVersion, arch, fmt.Sprintf(containerdDownloadURL, containerdVersion, containerdVersion, arch))
 	fileName := fmt.Sprintf(containerdFileName, containerdVersion, arch)
 	i.logger.Infof("Constructed containerd download URL: %s", url)
 	return fileName, url, nil
@@ -217,98 +224,67 @@ func (i *Installer) configure() error {
 
 // createContainerdServiceFile creates the containerd systemd service file
 func (i *Installer) createContainerdServiceFile() error {
-	containerdService := `[Unit]
-Description=containerd container runtime
-Documentation=https://containerd.io
-After=network.target local-fs.target
-[Service]
-ExecStartPre=-/sbin/modprobe overlay
-ExecStart=/usr/bin/containerd
-Type=notify
-Delegate=yes
-KillMode=process
-Restart=always
-RestartSec=5
-# Having non-zero Limit*s causes performance problems due to accounting overhead
-# in the kernel. We recommend using cgroups to do container-local accounting.
-LimitNPROC=infinity
-LimitCORE=infinity
-LimitNOFILE=infinity
-# Comment TasksMax if your systemd version does not supports it.
-# Only systemd 226 and above support this version.
-TasksMax=infinity
-OOMScoreAdjust=-999
-[Install]
-WantedBy=multi-user.target`
-
-	// Create containerd service file using sudo-aware approach
-	tempFile, err := utils.CreateTempFile("containerd-service-*.service", []byte(containerdService))
+	containerdService, err := templates.Render(i.config, templates.ContainerdService, containerdServiceData{
+		Binary: defaultContainerdBinaryDir,
+		Path:   servicePath(),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create temporary containerd service file: %w", err)
+		return err
 	}
-	defer utils.CleanupTempFile(tempFile.Name())
 
-	// Copy the temp file to the final location using sudo
-	if err := utils.RunSystemCommand("cp", tempFile.Name(), containerdServiceFile); err != nil {
+	// Root can modify the service, but everyone else can only read it, and nobody can execute it
+	if err := validation.WriteFile(containerdServiceFile, containerdService, 0o644, validation.SystemdUnit, i.logger); err != nil {
 		return fmt.Errorf("failed to install containerd service file: %w", err)
 	}
 
-	// Set proper permissions: root can modify the service, but everyone else can only read it, and nobody can execute it
-	if err := utils.RunSystemCommand("chmod", "644", containerdServiceFile); err != nil {
-		return fmt.Errorf("failed to set containerd service file permissions: %w", err)
+	return nil
+}
+
+// containerdServiceData holds the variables of the containerd unit template
+type containerdServiceData struct {
+	Binary string
+	Path   string
+}
+
+// servicePath returns the PATH containerd needs to find its shims, or an empty string when they are in one of
+// systemd's default directories
+func servicePath() string {
+	if systemBinDir != osimage.OptBinDir {
+		return ""
 	}
+	return osimage.OptBinDir + ":" + systemdDefaultPath
+}
 
-	return nil
+// containerdConfigData holds the variables of the containerd configuration template
+type containerdConfigData struct {
+	SocketGroupID  int
+	PauseImage     string
+	CNIBinDir      string
+	CNIConfDir     string
+	MetricsAddress string
+	SystemdCgroup  bool
+	RuncBinary     string
 }
 
 // createContainerdConfigFile creates the containerd configuration file
 func (i *Installer) createContainerdConfigFile() error {
-	containerdConfig := fmt.Sprintf(`version = 2
-oom_score = 0
-[plugins."io.containerd.grpc.v1.cri"]
-	sandbox_image = "%s"
-	[plugins."io.containerd.grpc.v1.cri".containerd]
-		default_runtime_name = "runc"
-		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
-			runtime_type = "io.containerd.runc.v2"
-		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
-			BinaryName = "/usr/bin/runc"
-			SystemdCgroup = true
-		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted]
-			runtime_type = "io.containerd.runc.v2"
-		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted.options]
-			BinaryName = "/usr/bin/runc"
-	[plugins."io.containerd.grpc.v1.cri".cni]
-		bin_dir = "%s"
-		conf_dir = "%s"
-	[plugins."io.containerd.grpc.v1.cri".registry]
-		config_path = "/etc/containerd/certs.d"
-	[plugins."io.containerd.grpc.v1.cri".registry.headers]
-		X-Meta-Source-Client = ["azure/aks"]
-[metrics]
-	address = "%s"`,
-		i.getPauseImage(),
-		cni.DefaultCNIBinDir,
-		cni.DefaultCNIConfDir,
-		i.getMetricsAddress())
-
-	// Create a tmp containerd config file
-	tempConfigFile, err := utils.CreateTempFile("containerd-config-*.toml", []byte(containerdConfig))
+	containerdConfig, err := templates.Render(i.config, templates.ContainerdConfig, containerdConfigData{
+		SocketGroupID:  socketGroupID(),
+		PauseImage:     i.getPauseImage(),
+		CNIBinDir:      cni.DefaultCNIBinDir,
+		CNIConfDir:     cni.DefaultCNIConfDir,
+		MetricsAddress: i.getMetricsAddress(),
+		SystemdCgroup:  i.config.GetCgroupDriver() == cgroup.DriverSystemd,
+		RuncBinary:     runcBinary,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create temporary containerd config file: %w", err)
+		return err
 	}
-	defer utils.CleanupTempFile(tempConfigFile.Name())
 
-	// Copy the temp file to the final location using sudo
-	if err := utils.RunSystemCommand("cp", tempConfigFile.Name(), containerdConfigFile); err != nil {
+	if err := validation.WriteFile(containerdConfigFile, containerdConfig, 0o644, validation.ContainerdConfig, i.logger); err != nil {
 		return fmt.Errorf("failed to install containerd config file: %w", err)
 	}
 
-	// Set proper permissions
-	if err := utils.RunSystemCommand("chmod", "644", containerdConfigFile); err != nil {
-		return fmt.Errorf("failed to set containerd config file permissions: %w", err)
-	}
-
 	return nil
 }
 
@@ -322,6 +298,15 @@ func (i *Installer) GetName() string {
 	return "ContainerdInstaller"
 }
 
+// ManagedFiles returns the binaries and configuration files installed by this step
+func (i *Installer) ManagedFiles() []string {
+	files := make([]string, 0, len(containerdBinaries)+2)
+	for _, binary := range containerdBinaries {
+		files = append(files, filepath.Join(systemBinDir, binary))
+	}
+	return append(files, containerdConfigFile, containerdServiceFile)
+}
+
 // IsCompleted checks if containerd and required plugins are installed
 func (i *Installer) IsCompleted(ctx context.Context) bool {
 	// Check if containerd binaries are installed and functional
@@ -357,13 +342,32 @@ func (i *Installer) getContainerdVersion() string {
 }
 
 func (i *Installer) getPauseImage() string {
-	if i.config.Containerd.PauseImage != "" {
-		return i.config.Containerd.PauseImage
+	return PauseImage(i.config)
+}
+
+// PauseImage returns the configured pause image of containerd
+func PauseImage(cfg *config.Config) string {
+	if cfg.Containerd.PauseImage != "" {
+		return cfg.Containerd.PauseImage
 	}
 	// Default pause image
 	return "mcr.microsoft.com/oss/kubernetes/pause:3.6"
 }
 
+// socketGroupID returns the group owning the containerd socket. The agent's service group is used
+// so the agent can talk CRI to containerd without root; without that group only root has access.
+func socketGroupID() int {
+	group, err := user.LookupGroup(agentGroup)
+	if err != nil {
+		return 0
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return 0
+	}
+	return gid
+}
+
 func (i *Installer) getMetricsAddress() string {
 	if i.config.Containerd.MetricsAddress != "" {
 		return i.config.Containerd.MetricsAddress
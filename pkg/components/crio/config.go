@@ -0,0 +1,50 @@
+package crio
+
+import (
+	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+)
+
+// PauseImage returns the configured pause image of CRI-O
+func PauseImage(cfg *config.Config) string {
+	if cfg.CRIO.PauseImage != "" {
+		return cfg.CRIO.PauseImage
+	}
+	return defaultPauseImage
+}
+
+// renderConfig renders the CRI-O drop-in configuration. Only settings that differ from the CRI-O
+// defaults are set; runc and conmon are referenced explicitly because they are not on CRI-O's default paths.
+func renderConfig(cfg *config.Config) (string, error) {
+	return templates.Render(cfg, templates.CRIOConfig, struct {
+		Socket, BinDir, RuncBinary, PauseImage, PolicyFile, CNIConfDir, CNIBinDir, CgroupDriver string
+		MetricsPort                                                                             int
+	}{
+		Socket:       Socket,
+		BinDir:       crioBinDir,
+		RuncBinary:   runcBinary,
+		PauseImage:   PauseImage(cfg),
+		PolicyFile:   crioPolicyFile,
+		CNIConfDir:   cni.DefaultCNIConfDir,
+		CNIBinDir:    cni.DefaultCNIBinDir,
+		MetricsPort:  metricsPort,
+		CgroupDriver: cfg.GetCgroupDriver(),
+	})
+}
+
+// renderService renders the CRI-O systemd unit. CRI-O has no setting for the socket group, so the
+// socket is handed to the agent's group after start, like containerd's [grpc] gid.
+func renderService(cfg *config.Config) (string, error) {
+	return templates.Render(cfg, templates.CRIOService, struct{ Binary, Group, Socket string }{
+		Binary: crioBinary,
+		Group:  agentGroup,
+		Socket: Socket,
+	})
+}
+
+// renderPolicy renders the signature policy, which accepts every image like the default containerd setup.
+// It lives in /etc/crio so an existing /etc/containers/policy.json of podman or skopeo is left alone.
+func renderPolicy(cfg *config.Config) (string, error) {
+	return templates.Render(cfg, templates.CRIOPolicy, nil)
+}
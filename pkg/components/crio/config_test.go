@@ -0,0 +1,57 @@
+package crio
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestPauseImage(t *testing.T) {
+	if got := PauseImage(&config.Config{}); got != defaultPauseImage {
+		t.Errorf("PauseImage() = %q, want the default %q", got, defaultPauseImage)
+	}
+	cfg := &config.Config{CRIO: config.CRIOConfig{PauseImage: "registry.example.com/pause:3.10"}}
+	if got := PauseImage(cfg); got != "registry.example.com/pause:3.10" {
+		t.Errorf("PauseImage() = %q, want the configured image", got)
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	got, err := renderConfig(&config.Config{CRIO: config.CRIOConfig{PauseImage: "registry.example.com/pause:3.10"}})
+	if err != nil {
+		t.Fatalf("renderConfig() unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`listen = "/run/crio/crio.sock"`,
+		`cgroup_manager = "systemd"`,
+		`pinns_path = "/usr/local/bin/pinns"`,
+		"[crio.runtime.runtimes.runc]\nruntime_path = \"/usr/bin/runc\"",
+		`monitor_path = "/usr/local/bin/conmon"`,
+		`pause_image = "registry.example.com/pause:3.10"`,
+		`signature_policy = "/etc/crio/policy.json"`,
+		`network_dir = "/etc/cni/net.d"`,
+		`plugin_dirs = ["/opt/cni/bin"]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderConfig() is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderService(t *testing.T) {
+	got, err := renderService(&config.Config{})
+	if err != nil {
+		t.Fatalf("renderService() unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/crio\n",
+		"ExecStartPost=-/usr/bin/chgrp aks-flex-node /run/crio/crio.sock\n",
+		"ExecStartPost=-/usr/bin/chmod 0660 /run/crio/crio.sock\n",
+		"Type=notify\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderService() is missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package crio
+
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
+
+const (
+	// ServiceName is the systemd unit of CRI-O
+	ServiceName = "crio"
+	// Socket is the CRI socket CRI-O listens on
+	Socket = "/run/crio/crio.sock"
+
+	crioConfigDir   = "/etc/crio"
+	crioDropInDir   = "/etc/crio/crio.conf.d"
+	crioConfigFile  = "/etc/crio/crio.conf.d/10-aks-flex-node.conf"
+	crioPolicyFile  = "/etc/crio/policy.json"
+	crioServiceFile = "/etc/systemd/system/crio.service"
+	crioDataDir     = "/var/lib/crio"
+
+	// agentGroup is the group of the service user created by the install script
+	agentGroup = "aks-flex-node"
+
+	defaultPauseImage = "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+	metricsPort       = 9537
+)
+
+// Binaries go to /usr/local/bin so they never overwrite the conmon of a distribution podman package, or to
+// /opt/bin on immutable hosts
+var (
+	crioBinDir = osimage.BinDir("/usr/local/bin")
+	crioBinary = crioBinDir + "/crio"
+
+	// runc is installed by the runc component and shared with containerd
+	runcBinary = osimage.BinPath("/usr/bin/runc")
+)
+
+// crioBinaries are the binaries taken from the CRI-O static bundle
+var crioBinaries = []string{
+	"crio",
+	"pinns",
+	"conmon",
+}
+
+var (
+	crioFileName    = "cri-o.%s.v%s.tar.gz"
+	crioDownloadURL = "https://storage.googleapis.com/cri-o/artifacts/" + crioFileName
+)
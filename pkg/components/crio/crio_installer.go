@@ -0,0 +1,170 @@
+package crio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
+)
+
+// Installer handles CRI-O installation operations
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new CRI-O Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Execute downloads the CRI-O static bundle, installs its binaries and configures the service
+func (i *Installer) Execute(ctx context.Context) error {
+	version := i.config.GetCRIOVersion()
+	i.logger.Infof("Step 1: Downloading and installing CRI-O version %s", version)
+	if err := i.installCRIO(ctx, version); err != nil {
+		return fmt.Errorf("failed to install CRI-O: %w", err)
+	}
+	i.logger.Info("CRI-O binaries installed successfully")
+
+	i.logger.Info("Step 2: Configuring CRI-O")
+	if err := i.configure(); err != nil {
+		return fmt.Errorf("CRI-O configuration failed: %w", err)
+	}
+
+	i.logger.Info("Installer: CRI-O installed and configured successfully")
+	return nil
+}
+
+func (i *Installer) installCRIO(ctx context.Context, version string) error {
+	if i.isVersionInstalled(version) {
+		i.logger.Infof("CRI-O version %s is already installed, skipping installation", version)
+		return nil
+	}
+
+	arch, err := utils.GetArc()
+	if err != nil {
+		return fmt.Errorf("failed to get architecture: %w", err)
+	}
+	url := i.config.GetArtifactURL("cri-o", version, arch, fmt.Sprintf(crioDownloadURL, arch, version))
+	tempFile := filepath.Join("/tmp", fmt.Sprintf(crioFileName, arch, version))
+	defer func() {
+		if err := utils.RunCleanupCommand(tempFile); err != nil {
+			i.logger.Warnf("Failed to clean up temp file %s: %v", tempFile, err)
+		}
+	}()
+
+	i.logger.Infof("Downloading CRI-O from %s into %s", url, tempFile)
+	if err := download.NewManager(i.config, i.logger).For("cri-o", version).Fetch(ctx, url, tempFile); err != nil {
+		return fmt.Errorf("failed to download CRI-O from %s: %w", url, err)
+	}
+
+	// The bundle holds cri-o/bin/<binary>; extract only the binaries the node needs
+	members := make([]string, 0, len(crioBinaries))
+	for _, binary := range crioBinaries {
+		members = append(members, "cri-o/bin/"+binary)
+	}
+	i.logger.Infof("Extracting CRI-O binaries to %s", crioBinDir)
+	args := append([]string{"-C", crioBinDir, "--strip-components=2", "-xzf", tempFile}, members...)
+	if err := utils.RunLimitedCommand(i.config.GetInstallLimitProperties(), "tar", args...); err != nil {
+		return fmt.Errorf("failed to extract CRI-O binaries: %w", err)
+	}
+
+	for _, binary := range crioBinaries {
+		if err := utils.RunSystemCommand("chmod", "0755", filepath.Join(crioBinDir, binary)); err != nil {
+			return fmt.Errorf("failed to set executable permissions on %s: %w", binary, err)
+		}
+	}
+	return nil
+}
+
+// isVersionInstalled checks whether all CRI-O binaries exist and crio reports the wanted version
+func (i *Installer) isVersionInstalled(version string) bool {
+	for _, binary := range crioBinaries {
+		if !utils.FileExists(filepath.Join(crioBinDir, binary)) {
+			return false
+		}
+	}
+	output, err := utils.RunCommandWithOutput(crioBinary, "--version")
+	if err != nil {
+		i.logger.Debugf("Failed to get CRI-O version: %v", err)
+		return false
+	}
+	return strings.Contains(output, version)
+}
+
+// configure writes the CRI-O drop-in configuration, signature policy and systemd unit
+func (i *Installer) configure() error {
+	if err := utils.RunSystemCommand("mkdir", "-p", crioDropInDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", crioDropInDir, err)
+	}
+
+	files := []struct {
+		path   string
+		render func(*config.Config) (string, error)
+		check  validation.Check
+	}{
+		{crioConfigFile, renderConfig, validation.CRIOConfig},
+		{crioPolicyFile, renderPolicy, validation.JSON},
+		{crioServiceFile, renderService, validation.SystemdUnit},
+	}
+	for _, file := range files {
+		content, err := file.render(i.config)
+		if err != nil {
+			return err
+		}
+		if err := validation.WriteFile(file.path, content, 0o644, file.check, i.logger); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.path, err)
+		}
+	}
+
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd after CRI-O configuration: %w", err)
+	}
+	return nil
+}
+
+// Validate validates preconditions before execution
+func (i *Installer) Validate(ctx context.Context) error {
+	return nil
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "CRIOInstaller"
+}
+
+// ManagedFiles returns the binaries and configuration files installed by this step
+func (i *Installer) ManagedFiles() []string {
+	files := make([]string, 0, len(crioBinaries)+3)
+	for _, binary := range crioBinaries {
+		files = append(files, filepath.Join(crioBinDir, binary))
+	}
+	return append(files, crioConfigFile, crioPolicyFile, crioServiceFile)
+}
+
+// IsCompleted checks if the configured CRI-O version is installed with the current configuration
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.isVersionInstalled(i.config.GetCRIOVersion()) {
+		return false
+	}
+	current, err := os.ReadFile(crioConfigFile)
+	if err != nil {
+		return false
+	}
+	if desired, err := renderConfig(i.config); err != nil || !bytes.Equal(current, []byte(desired)) {
+		return false
+	}
+	return utils.FileExists(crioPolicyFile) && utils.FileExists(crioServiceFile)
+}
@@ -0,0 +1,64 @@
+package crio
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller handles CRI-O uninstallation operations
+type UnInstaller struct {
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new CRI-O unInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "CRIOUninstaller"
+}
+
+// Execute stops CRI-O and removes its binaries, configuration and state.
+// Images in /var/lib/containers are kept because podman may share that storage.
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	u.logger.Info("Uninstalling CRI-O")
+
+	if utils.ServiceExists(ServiceName) {
+		if err := utils.StopService(ServiceName); err != nil {
+			u.logger.Warnf("Failed to stop CRI-O service: %v", err)
+		}
+		if err := utils.DisableService(ServiceName); err != nil {
+			u.logger.Warnf("Failed to disable CRI-O service: %v", err)
+		}
+	}
+
+	files := []string{crioConfigFile, crioPolicyFile, crioServiceFile}
+	for _, binary := range crioBinaries {
+		files = append(files, filepath.Join(crioBinDir, binary))
+	}
+	for _, err := range utils.RemoveFiles(files, u.logger) {
+		u.logger.Warnf("CRI-O file removal error: %v", err)
+	}
+	for _, err := range utils.RemoveDirectories([]string{crioDataDir}, u.logger) {
+		u.logger.Warnf("CRI-O directory removal error: %v", err)
+	}
+
+	if err := utils.ReloadSystemd(); err != nil {
+		u.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+
+	u.logger.Info("CRI-O uninstalled successfully")
+	return nil
+}
+
+// IsCompleted checks if CRI-O has been completely removed
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !utils.FileExists(crioBinary) && !utils.FileExists(crioConfigFile) && !utils.FileExists(crioServiceFile)
+}
@@ -0,0 +1,22 @@
+package custom_scripts
+
+import "time"
+
+const (
+	// StateDir holds the installed scripts, the output of their last run and the record of it
+	StateDir = "/var/lib/aks-flex-node/custom-scripts"
+
+	defaultInterpreter = "/bin/sh"
+
+	// unitPrefix names the transient service each script runs as
+	unitPrefix = "aks-flex-node-script-"
+
+	// killGrace is how long after its timeout the agent waits for systemd to stop a script
+	killGrace = 30 * time.Second
+
+	// outputTailLines is how many lines of output a failure reports
+	outputTailLines = 20
+)
+
+// stateDir is where scripts and records are kept; replaced in tests
+var stateDir = StateDir
@@ -0,0 +1,172 @@
+package custom_scripts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// runCommand runs systemd-run and returns its combined output; replaced in tests
+var runCommand = utils.RunCommandWithOutputContext
+
+// Installer runs the configured custom scripts in order before kubelet starts. Run-once scripts are skipped
+// when they already succeeded with their current content; the others run on every bootstrap.
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new custom scripts Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "CustomScripts_Installer"
+}
+
+// Validate checks the scripts load, match their checksums and have an executable interpreter
+func (i *Installer) Validate(ctx context.Context) error {
+	if len(i.config.CustomScripts) == 0 {
+		return nil
+	}
+	if !utils.BinaryExists("systemd-run") {
+		return fmt.Errorf("systemd-run is required to run custom scripts")
+	}
+	for _, script := range i.config.CustomScripts {
+		if _, err := loadScript(script); err != nil {
+			return err
+		}
+		info, err := os.Stat(interpreter(script))
+		if err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+			return fmt.Errorf("interpreter %s of custom script %s is not an executable file", interpreter(script), script.Name)
+		}
+	}
+	return nil
+}
+
+// IsCompleted returns true when every script runs once and already succeeded with its current content
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	for _, script := range i.config.CustomScripts {
+		content, err := loadScript(script)
+		if err != nil || !alreadyRan(script, checksum(content)) {
+			return false
+		}
+	}
+	return len(staleFiles(i.config.CustomScripts)) == 0
+}
+
+// Execute runs the scripts in order and stops at the first one that fails or times out
+func (i *Installer) Execute(ctx context.Context) error {
+	if len(i.config.CustomScripts) > 0 {
+		if err := utils.RunSystemCommand("mkdir", "-p", stateDir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", stateDir, err)
+		}
+	}
+	for _, script := range i.config.CustomScripts {
+		if err := i.run(ctx, script); err != nil {
+			return err
+		}
+	}
+	for _, file := range staleFiles(i.config.CustomScripts) {
+		i.logger.Infof("Removing %s, the custom script is no longer configured", file)
+		if err := utils.RunCleanupCommand(file); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// run runs a single script unless it is run-once and already succeeded, and records the outcome
+func (i *Installer) run(ctx context.Context, script config.CustomScriptConfig) error {
+	content, err := loadScript(script)
+	if err != nil {
+		return err
+	}
+	sum := checksum(content)
+	if alreadyRan(script, sum) {
+		i.logger.Infof("Custom script %s already succeeded, skipping", script.Name)
+		return nil
+	}
+	if err := utils.WriteFileAtomicSystem(scriptPath(script.Name), content, 0o700); err != nil {
+		return fmt.Errorf("failed to write custom script %s: %w", script.Name, err)
+	}
+
+	timeout := i.config.GetCustomScriptTimeout(script)
+	runCtx, cancel := context.WithTimeout(ctx, timeout+killGrace)
+	defer cancel()
+
+	i.logger.Infof("Running custom script %s (timeout %s)", script.Name, timeout)
+	started := time.Now()
+	output, runErr := runCommand(runCtx, "systemd-run", runArgs(script, timeout)...)
+	duration := time.Since(started)
+
+	exitCode, err := exitCodeOf(runErr)
+	if err != nil {
+		return fmt.Errorf("failed to run custom script %s: %w", script.Name, err)
+	}
+	r := record{
+		Checksum:  sum,
+		ExitCode:  exitCode,
+		Succeeded: succeeded(script, exitCode),
+		StartedAt: started.UTC(),
+		Duration:  duration.Round(time.Millisecond).String(),
+		TimedOut:  duration >= timeout,
+	}
+	if r.TimedOut {
+		r.Succeeded = false
+	}
+	if err := i.save(script.Name, output, r); err != nil {
+		return err
+	}
+
+	switch {
+	case r.TimedOut:
+		return fmt.Errorf("custom script %s timed out after %s, output:\n%s", script.Name, timeout, tail(output, outputTailLines))
+	case !r.Succeeded:
+		return fmt.Errorf("custom script %s exited with code %d, output:\n%s", script.Name, exitCode, tail(output, outputTailLines))
+	}
+	i.logger.Infof("Custom script %s succeeded in %s", script.Name, r.Duration)
+	return nil
+}
+
+// save writes the output and the record of a run
+func (i *Installer) save(name, output string, r record) error {
+	if err := utils.WriteFileAtomicSystem(outputPath(name), []byte(output), 0o600); err != nil {
+		return fmt.Errorf("failed to write output of custom script %s: %w", name, err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal record of custom script %s: %w", name, err)
+	}
+	if err := utils.WriteFileAtomicSystem(recordPath(name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write record of custom script %s: %w", name, err)
+	}
+	return nil
+}
+
+// exitCodeOf returns the exit code systemd-run passed on from the script, or an error when the script
+// could not be started at all
+func exitCodeOf(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode(), nil
+	}
+	return -1, err
+}
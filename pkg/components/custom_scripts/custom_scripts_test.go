@@ -0,0 +1,151 @@
+package custom_scripts
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRunArgs(t *testing.T) {
+	stateDir = "/state"
+	defer func() { stateDir = StateDir }()
+
+	script := config.CustomScriptConfig{Name: "tune-nic", Interpreter: "/bin/bash", ReadWritePaths: []string{"/etc/tuned"}}
+	args := runArgs(script, 90*time.Second+time.Millisecond)
+
+	for _, want := range []string{
+		"--unit=aks-flex-node-script-tune-nic",
+		"--wait",
+		"--property=RuntimeMaxSec=91s",
+		"--property=ProtectSystem=strict",
+		"--property=ReadWritePaths=/etc/tuned",
+	} {
+		if !slices.Contains(args, want) {
+			t.Errorf("runArgs() = %v, missing %s", args, want)
+		}
+	}
+	if got := args[len(args)-2:]; got[0] != "/bin/bash" || got[1] != "/state/tune-nic.script" {
+		t.Errorf("runArgs() ends with %v, want the interpreter and the script", got)
+	}
+
+	args = runArgs(config.CustomScriptConfig{Name: "plain"}, time.Minute)
+	if slices.Contains(args, "--property=ProtectSystem=strict") {
+		t.Errorf("runArgs() = %v, the file system should stay writable without readWritePaths", args)
+	}
+	if args[len(args)-2] != defaultInterpreter {
+		t.Errorf("runArgs() interpreter = %s, want %s", args[len(args)-2], defaultInterpreter)
+	}
+}
+
+func TestSucceeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		exitCodes []int
+		exitCode  int
+		want      bool
+	}{
+		{name: "default zero", exitCode: 0, want: true},
+		{name: "default non-zero", exitCode: 1, want: false},
+		{name: "listed code", exitCodes: []int{0, 3}, exitCode: 3, want: true},
+		{name: "unlisted code", exitCodes: []int{3}, exitCode: 0, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := succeeded(config.CustomScriptConfig{ExitCodes: tt.exitCodes}, tt.exitCode); got != tt.want {
+				t.Errorf("succeeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadScriptChecksum(t *testing.T) {
+	script := config.CustomScriptConfig{Name: "s", Script: "echo hi\n"}
+	script.SHA256 = checksum([]byte(script.Script))
+	if _, err := loadScript(script); err != nil {
+		t.Fatalf("loadScript() error = %v", err)
+	}
+	script.SHA256 = strings.Repeat("0", 64)
+	if _, err := loadScript(script); err == nil {
+		t.Error("loadScript() expected a checksum mismatch error")
+	}
+}
+
+// fakeExit returns an *exec.ExitError with the given code
+func fakeExit(t *testing.T, code int) error {
+	t.Helper()
+	err := exec.Command("/bin/sh", "-c", "exit "+strconv.Itoa(code)).Run()
+	if err == nil {
+		t.Fatal("expected the command to fail")
+	}
+	return err
+}
+
+func TestExecute(t *testing.T) {
+	stateDir = t.TempDir()
+	defer func() { stateDir = StateDir }()
+
+	original := runCommand
+	defer func() { runCommand = original }()
+
+	var runs []string
+	var result error
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		runs = append(runs, strings.TrimPrefix(args[0], "--unit="+unitPrefix))
+		return "line1\nline2\n", result
+	}
+
+	cfg := &config.Config{CustomScripts: []config.CustomScriptConfig{
+		{Name: "once", Script: "true"},
+		{Name: "every", Script: "true", Run: "always"},
+	}}
+	installer := NewInstaller(cfg, logrus.New())
+
+	if err := installer.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if err := installer.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if want := []string{"once", "every", "every"}; !slices.Equal(runs, want) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+	if installer.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = true, want false with a script running always")
+	}
+	if output, err := os.ReadFile(outputPath("every")); err != nil || string(output) != "line1\nline2\n" {
+		t.Errorf("output = %q, %v", output, err)
+	}
+
+	// Changing a run-once script runs it again; a failure is recorded and reported
+	cfg.CustomScripts = []config.CustomScriptConfig{{Name: "once", Script: "false"}}
+	result = fakeExit(t, 2)
+	err := installer.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exited with code 2") || !strings.Contains(err.Error(), "line2") {
+		t.Fatalf("Execute() error = %v, want exit code 2 with the output", err)
+	}
+	r, err := loadRecord("once")
+	if err != nil || r == nil || r.Succeeded || r.ExitCode != 2 {
+		t.Errorf("record = %+v, %v", r, err)
+	}
+
+	// A listed exit code counts as success, and files of scripts no longer configured are removed
+	cfg.CustomScripts[0].ExitCodes = []int{2}
+	if err := installer.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, err := os.Stat(recordPath("every")); !os.IsNotExist(err) {
+		t.Errorf("record of removed script still exists: %v", err)
+	}
+	if !installer.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = false, want true once every run-once script succeeded")
+	}
+}
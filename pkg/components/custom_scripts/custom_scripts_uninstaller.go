@@ -0,0 +1,43 @@
+package custom_scripts
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes the installed scripts and the records of their runs. What the scripts changed on the
+// machine is not undone.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new custom scripts UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "CustomScripts_UnInstaller"
+}
+
+// Execute removes the scripts directory
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	for _, err := range utils.RemoveDirectories([]string{stateDir}, u.logger) {
+		u.logger.Warnf("Failed to remove custom scripts: %v", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when the scripts directory is gone
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !utils.FileExists(stateDir)
+}
@@ -0,0 +1,151 @@
+package custom_scripts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// record is what the agent keeps of the last run of a script
+type record struct {
+	Checksum  string    `json:"checksum"` // SHA-256 of the script content that ran
+	ExitCode  int       `json:"exitCode"`
+	Succeeded bool      `json:"succeeded"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  string    `json:"duration"`
+	TimedOut  bool      `json:"timedOut,omitempty"`
+}
+
+func scriptPath(name string) string { return filepath.Join(stateDir, name+".script") }
+func recordPath(name string) string { return filepath.Join(stateDir, name+".json") }
+func outputPath(name string) string { return filepath.Join(stateDir, name+".log") }
+
+// loadScript returns the content of a script and verifies its checksum when one is configured
+func loadScript(script config.CustomScriptConfig) ([]byte, error) {
+	content := []byte(script.Script)
+	if script.ScriptFile != "" {
+		data, err := os.ReadFile(script.ScriptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom script %s: %w", script.Name, err)
+		}
+		content = data
+	}
+	if script.SHA256 != "" && !strings.EqualFold(checksum(content), script.SHA256) {
+		return nil, fmt.Errorf("custom script %s has SHA-256 %s, expected %s", script.Name, checksum(content), script.SHA256)
+	}
+	return content, nil
+}
+
+// checksum returns the hex encoded SHA-256 digest of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadRecord returns the record of the last run of a script, or nil when it never ran
+func loadRecord(name string) (*record, error) {
+	data, err := os.ReadFile(recordPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the last run of custom script %s: %w", name, err)
+	}
+	r := &record{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("invalid record of custom script %s: %w", name, err)
+	}
+	return r, nil
+}
+
+// runsOnce reports whether a script is skipped once it succeeded with its current content
+func runsOnce(script config.CustomScriptConfig) bool {
+	return script.Run != "always"
+}
+
+// alreadyRan reports whether a run-once script succeeded with content of the given checksum
+func alreadyRan(script config.CustomScriptConfig, sum string) bool {
+	if !runsOnce(script) {
+		return false
+	}
+	last, err := loadRecord(script.Name)
+	return err == nil && last != nil && last.Succeeded && last.Checksum == sum
+}
+
+// succeeded reports whether an exit code counts as success for a script
+func succeeded(script config.CustomScriptConfig, exitCode int) bool {
+	if len(script.ExitCodes) == 0 {
+		return exitCode == 0
+	}
+	return slices.Contains(script.ExitCodes, exitCode)
+}
+
+// interpreter returns the interpreter the script runs with
+func interpreter(script config.CustomScriptConfig) string {
+	if script.Interpreter != "" {
+		return script.Interpreter
+	}
+	return defaultInterpreter
+}
+
+// runArgs returns the systemd-run arguments running a script as a transient service. The service gets a
+// private /tmp, cannot gain privileges, is stopped after its timeout and, with readWritePaths, sees a
+// read-only file system except those paths. Its output is piped back to the agent.
+func runArgs(script config.CustomScriptConfig, timeout time.Duration) []string {
+	args := []string{
+		"--unit=" + unitPrefix + script.Name,
+		"--quiet",
+		"--wait",
+		"--pipe",
+		"--collect",
+		"--service-type=exec",
+		"--working-directory=" + stateDir,
+		"--setenv=AKS_FLEX_NODE_SCRIPT=" + script.Name,
+		fmt.Sprintf("--property=RuntimeMaxSec=%ds", int(math.Ceil(timeout.Seconds()))),
+		"--property=PrivateTmp=yes",
+		"--property=NoNewPrivileges=yes",
+	}
+	if len(script.ReadWritePaths) > 0 {
+		args = append(args, "--property=ProtectSystem=strict", "--property=ProtectHome=read-only")
+		for _, path := range script.ReadWritePaths {
+			args = append(args, "--property=ReadWritePaths="+path)
+		}
+	}
+	return append(args, interpreter(script), scriptPath(script.Name))
+}
+
+// tail returns the last lines of output
+func tail(output string, lines int) string {
+	all := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
+
+// staleFiles returns the files of scripts no longer configured
+func staleFiles(scripts []config.CustomScriptConfig) []string {
+	configured := make(map[string]bool, len(scripts))
+	for _, script := range scripts {
+		configured[script.Name] = true
+	}
+	var stale []string
+	for _, pattern := range []string{"*.script", "*.json", "*.log"} {
+		files, _ := filepath.Glob(filepath.Join(stateDir, pattern))
+		for _, file := range files {
+			if !configured[strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))] {
+				stale = append(stale, file)
+			}
+		}
+	}
+	return stale
+}
@@ -0,0 +1,114 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Resolver returns a resolver that answers like the host resolver will once node.dns is applied. With upstreams
+// configured, queries go to them directly, so the names the node needs can be checked during preflight
+// before the host's resolver configuration is changed.
+func Resolver(cfg *config.Config) *net.Resolver {
+	upstreams := cfg.Node.DNS.Upstreams
+	if len(upstreams) == 0 {
+		return net.DefaultResolver
+	}
+	var next atomic.Uint32
+	return &net.Resolver{
+		PreferGo: true,
+		// The resolver dials once per attempt; the attempts rotate through the upstreams
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			upstream := upstreams[int(next.Add(1)-1)%len(upstreams)]
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, net.JoinHostPort(upstream, "53"))
+		},
+	}
+}
+
+// ResolvConf returns the resolver configuration kubelet passes on to pods: the one systemd-resolved keeps
+// with the real upstream servers when it runs, as pods cannot reach its stub at 127.0.0.53, otherwise
+// /etc/resolv.conf
+func ResolvConf() string {
+	if utils.FileExists(resolvedResolvConfPath) {
+		return resolvedResolvConfPath
+	}
+	return resolvConfPath
+}
+
+// renderResolvedDropIn renders the systemd-resolved settings. The routing domain "~." sends every query to the
+// configured upstreams first, rather than to whichever link's servers systemd-resolved picks.
+func renderResolvedDropIn(dns config.NodeDNSConfig) string {
+	var b strings.Builder
+	b.WriteString(generatedHeader)
+	b.WriteString("[Resolve]\n")
+	if len(dns.Upstreams) > 0 {
+		b.WriteString("DNS=" + strings.Join(dns.Upstreams, " ") + "\n")
+	}
+	domains := append([]string{}, dns.SearchDomains...)
+	if len(dns.Upstreams) > 0 {
+		domains = append(domains, "~.")
+	}
+	b.WriteString("Domains=" + strings.Join(domains, " ") + "\n")
+	return b.String()
+}
+
+// renderResolvConf renders /etc/resolv.conf for hosts without systemd-resolved from the current one: the
+// configured upstreams replace its name servers, and the configured search domains replace its search list.
+// Settings that are not configured, and options such as ndots, are kept.
+func renderResolvConf(current string, dns config.NodeDNSConfig) string {
+	var nameservers, search, rest []string
+	for _, line := range strings.Split(current, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";"):
+			// Comments, including a previous header, are dropped
+		case fields[0] == "nameserver":
+			nameservers = append(nameservers, line)
+		case fields[0] == "search" || fields[0] == "domain":
+			// The last search or domain line wins
+			search = []string{line}
+		default:
+			rest = append(rest, line)
+		}
+	}
+	if len(dns.Upstreams) > 0 {
+		nameservers = nil
+		for _, upstream := range dns.Upstreams {
+			nameservers = append(nameservers, "nameserver "+upstream)
+		}
+	}
+	if len(dns.SearchDomains) > 0 {
+		search = []string{"search " + strings.Join(dns.SearchDomains, " ")}
+	}
+
+	var b strings.Builder
+	b.WriteString(generatedHeader)
+	for _, line := range append(append(nameservers, search...), rest...) {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// NamesToVerify returns the names that must resolve through the new configuration: the API server kubelet
+// registers with when it is configured, and node.dns.verifyNames
+func NamesToVerify(cfg *config.Config) []string {
+	var names []string
+	if serverURL := cfg.Node.Kubelet.ServerURL; serverURL != "" {
+		if u, err := url.Parse(serverURL); err == nil && u.Hostname() != "" && net.ParseIP(u.Hostname()) == nil {
+			names = append(names, u.Hostname())
+		}
+	}
+	for _, name := range cfg.Node.DNS.VerifyNames {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
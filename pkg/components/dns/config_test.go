@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderResolvedDropIn(t *testing.T) {
+	tests := []struct {
+		name string
+		dns  config.NodeDNSConfig
+		want string
+	}{
+		{
+			name: "upstreams take all queries",
+			dns:  config.NodeDNSConfig{Upstreams: []string{"10.1.0.4", "10.1.0.5"}, SearchDomains: []string{"corp.example.com"}},
+			want: "[Resolve]\nDNS=10.1.0.4 10.1.0.5\nDomains=corp.example.com ~.\n",
+		},
+		{
+			name: "search domains only",
+			dns:  config.NodeDNSConfig{SearchDomains: []string{"corp.example.com", "example.com"}},
+			want: "[Resolve]\nDomains=corp.example.com example.com\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderResolvedDropIn(tt.dns); got != generatedHeader+tt.want {
+				t.Errorf("renderResolvedDropIn() =\n%s\nwant\n%s", got, generatedHeader+tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderResolvConf(t *testing.T) {
+	const current = `# Written by dhclient
+nameserver 192.168.1.1
+nameserver 192.168.1.2
+domain lan
+options ndots:2 timeout:1
+`
+	tests := []struct {
+		name string
+		dns  config.NodeDNSConfig
+		want string
+	}{
+		{
+			name: "upstreams and search domains replace the host's",
+			dns:  config.NodeDNSConfig{Upstreams: []string{"10.1.0.4", "fd00::53"}, SearchDomains: []string{"corp.example.com"}},
+			want: "nameserver 10.1.0.4\nnameserver fd00::53\nsearch corp.example.com\noptions ndots:2 timeout:1\n",
+		},
+		{
+			name: "search domains keep the host's servers",
+			dns:  config.NodeDNSConfig{SearchDomains: []string{"corp.example.com"}},
+			want: "nameserver 192.168.1.1\nnameserver 192.168.1.2\nsearch corp.example.com\noptions ndots:2 timeout:1\n",
+		},
+		{
+			name: "upstreams keep the host's search list",
+			dns:  config.NodeDNSConfig{Upstreams: []string{"10.1.0.4"}},
+			want: "nameserver 10.1.0.4\ndomain lan\noptions ndots:2 timeout:1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderResolvConf(current, tt.dns)
+			if got != generatedHeader+tt.want {
+				t.Errorf("renderResolvConf() =\n%s\nwant\n%s", got, generatedHeader+tt.want)
+			}
+			// Rendering the agent's own file again must not change it, or every bootstrap would rewrite it
+			if again := renderResolvConf(got, tt.dns); again != got {
+				t.Errorf("renderResolvConf() is not stable:\n%s\nthen\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	cfg := &config.Config{Node: config.NodeConfig{
+		Kubelet: config.KubeletConfig{ServerURL: "https://aks-private.privatelink.westeurope.azmk8s.io:443"},
+		DNS:     config.NodeDNSConfig{Upstreams: []string{"10.1.0.4"}, VerifyNames: []string{"registry.corp.example.com", "aks-private.privatelink.westeurope.azmk8s.io"}},
+	}}
+	want := []string{"aks-private.privatelink.westeurope.azmk8s.io", "registry.corp.example.com"}
+	if got := NamesToVerify(cfg); !reflect.DeepEqual(got, want) {
+		t.Fatalf("NamesToVerify() = %v, want %v", got, want)
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	installer := NewInstaller(cfg, logger)
+	installer.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if strings.HasSuffix(host, "azmk8s.io") {
+			return nil, errors.New("no such host")
+		}
+		return []string{"10.2.0.7"}, nil
+	}
+	err := installer.verify(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "aks-private.privatelink.westeurope.azmk8s.io: no such host") || strings.Contains(err.Error(), "registry") {
+		t.Errorf("verify() error = %v, want only the API server failing", err)
+	}
+
+	// An API server addressed by IP has no name to resolve
+	cfg.Node.Kubelet.ServerURL = "https://10.0.0.1:443"
+	cfg.Node.DNS.VerifyNames = nil
+	if got := NamesToVerify(cfg); len(got) != 0 {
+		t.Errorf("NamesToVerify() = %v, want no names", got)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	if Resolver(&config.Config{}) != net.DefaultResolver {
+		t.Error("Resolver() without upstreams must be the host resolver")
+	}
+	cfg := &config.Config{Node: config.NodeConfig{DNS: config.NodeDNSConfig{Upstreams: []string{"10.1.0.4"}}}}
+	if resolver := Resolver(cfg); resolver == net.DefaultResolver || !resolver.PreferGo || resolver.Dial == nil {
+		t.Error("Resolver() with upstreams must query them directly")
+	}
+}
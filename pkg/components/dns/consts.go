@@ -0,0 +1,21 @@
+package dns
+
+import "time"
+
+const (
+	resolvedServiceName = "systemd-resolved"
+
+	// The agent's settings for systemd-resolved, layered on /etc/systemd/resolved.conf
+	resolvedDropInDir  = "/etc/systemd/resolved.conf.d"
+	resolvedDropInPath = "/etc/systemd/resolved.conf.d/90-aks-flex-node.conf"
+
+	// resolvedResolvConfPath lists the upstream servers systemd-resolved uses, for programs that bypass its stub
+	resolvedResolvConfPath = "/run/systemd/resolve/resolv.conf"
+	resolvConfPath         = "/etc/resolv.conf"
+
+	// generatedHeader starts the files the agent writes, so it recognizes its own resolv.conf
+	generatedHeader = "# Generated by aks-flex-node. Do not edit; changes are overwritten on the next bootstrap.\n"
+
+	// lookupTimeout bounds each lookup of a name to verify
+	lookupTimeout = 10 * time.Second
+)
@@ -0,0 +1,181 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Installer applies node.dns to the host resolver: through a drop-in when systemd-resolved manages DNS,
+// otherwise by rewriting /etc/resolv.conf. It then verifies that the names the node needs resolve.
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	lookup func(ctx context.Context, host string) ([]string, error) // Replaced in tests
+}
+
+// NewInstaller creates a new DNS Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+		lookup: net.DefaultResolver.LookupHost,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "DNS_Installer"
+}
+
+// Validate has nothing to check beyond config validation
+func (i *Installer) Validate(ctx context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when the host resolver is configured as desired, or when node.dns is not set and
+// nothing the agent configured before is left
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.config.IsDNSConfigured() {
+		return !utils.FileExists(resolvedDropInPath) && !ownsResolvConf()
+	}
+	if utils.IsServiceActive(resolvedServiceName) {
+		current, err := os.ReadFile(resolvedDropInPath)
+		return err == nil && string(current) == renderResolvedDropIn(i.config.Node.DNS)
+	}
+	current, err := os.ReadFile(resolvConfPath)
+	return err == nil && string(current) == renderResolvConf(string(current), i.config.Node.DNS)
+}
+
+// Execute applies the configured upstreams and search domains and verifies resolution
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.IsDNSConfigured() {
+		// node.dns was removed since the last bootstrap
+		return removeConfiguration(i.logger)
+	}
+
+	dns := i.config.Node.DNS
+	i.logger.Infof("Configuring DNS with upstreams [%s] and search domains [%s]",
+		strings.Join(dns.Upstreams, ", "), strings.Join(dns.SearchDomains, ", "))
+	if utils.IsServiceActive(resolvedServiceName) {
+		if err := i.configureResolved(); err != nil {
+			return fmt.Errorf("failed to configure systemd-resolved: %w", err)
+		}
+	} else {
+		if err := i.configureResolvConf(); err != nil {
+			return fmt.Errorf("failed to configure %s: %w", resolvConfPath, err)
+		}
+	}
+
+	if err := i.verify(ctx); err != nil {
+		return err
+	}
+	i.logger.Info("DNS configured successfully")
+	return nil
+}
+
+// configureResolved writes the drop-in and restarts systemd-resolved to apply it
+func (i *Installer) configureResolved() error {
+	desired := renderResolvedDropIn(i.config.Node.DNS)
+	if current, err := os.ReadFile(resolvedDropInPath); err == nil && string(current) == desired {
+		return nil
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", resolvedDropInDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", resolvedDropInDir, err)
+	}
+	if err := utils.WriteFileAtomicSystem(resolvedDropInPath, []byte(desired), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", resolvedDropInPath, err)
+	}
+	if err := utils.RestartService(resolvedServiceName); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", resolvedServiceName, err)
+	}
+	i.logger.Infof("Configured systemd-resolved through %s", resolvedDropInPath)
+	return nil
+}
+
+// configureResolvConf rewrites /etc/resolv.conf, keeping the original to restore on unbootstrap. A symlink to
+// a file managed by another tool is replaced, so that tool no longer overwrites the settings.
+func (i *Installer) configureResolvConf() error {
+	current, err := os.ReadFile(resolvConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	desired := renderResolvConf(string(current), i.config.Node.DNS)
+	if string(current) == desired {
+		return nil
+	}
+	if err := filebackup.Save(resolvConfPath, i.logger); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomicSystem(resolvConfPath, []byte(desired), 0o644); err != nil {
+		return err
+	}
+	if err := filebackup.Record(resolvConfPath); err != nil {
+		return err
+	}
+	i.logger.Infof("Configured %s", resolvConfPath)
+	return nil
+}
+
+// verify resolves the names the node needs through the new configuration, so a wrong upstream fails here
+// rather than later as registration or image pull errors
+func (i *Installer) verify(ctx context.Context) error {
+	names := NamesToVerify(i.config)
+	if len(names) == 0 {
+		i.logger.Info("No names to verify DNS resolution with; set node.dns.verifyNames to check the upstreams")
+		return nil
+	}
+	var failed []string
+	for _, name := range names {
+		lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		addrs, err := i.lookup(lookupCtx, name)
+		cancel()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		i.logger.Infof("%s resolves to %s", name, strings.Join(addrs, ", "))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("names do not resolve with the configured DNS; check node.dns.upstreams and that they serve these zones: %s",
+			strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// ownsResolvConf reports whether /etc/resolv.conf is the one the agent wrote
+func ownsResolvConf() bool {
+	current, err := os.ReadFile(resolvConfPath)
+	return err == nil && strings.HasPrefix(string(current), generatedHeader)
+}
+
+// removeConfiguration removes the systemd-resolved drop-in and restores the original /etc/resolv.conf
+func removeConfiguration(logger *logrus.Logger) error {
+	if utils.FileExists(resolvedDropInPath) {
+		if err := utils.RunCleanupCommand(resolvedDropInPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", resolvedDropInPath, err)
+		}
+		if utils.IsServiceActive(resolvedServiceName) {
+			if err := utils.RestartService(resolvedServiceName); err != nil {
+				return fmt.Errorf("failed to restart %s: %w", resolvedServiceName, err)
+			}
+		}
+		logger.Infof("Removed the DNS settings in %s", resolvedDropInPath)
+	}
+	if ownsResolvConf() {
+		if err := filebackup.Revert(resolvConfPath, logger); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", resolvConfPath, err)
+		}
+		logger.Infof("Restored the original %s", resolvConfPath)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package dns
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes the DNS settings the agent applied
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new DNS UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "DNS_UnInstaller"
+}
+
+// Execute removes the systemd-resolved drop-in and restores the original /etc/resolv.conf
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	return removeConfiguration(u.logger)
+}
+
+// IsCompleted returns true when no DNS settings of the agent are left
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !utils.FileExists(resolvedDropInPath) && !ownsResolvConf()
+}
@@ -0,0 +1,111 @@
+package fluent_bit
+
+import (
+	"fmt"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+)
+
+// renderConfig renders the fluent-bit configuration shipping the configured sources to the destination.
+// sharedKey is the Log Analytics workspace key and is only used for the log-analytics destination.
+func renderConfig(cfg config.FluentBitConfig, sources []string, hostname, sharedKey string) string {
+	var b strings.Builder
+	section := func(name string, settings ...string) {
+		fmt.Fprintf(&b, "[%s]\n", name)
+		for i := 0; i+1 < len(settings); i += 2 {
+			fmt.Fprintf(&b, "    %-18s %s\n", settings[i], settings[i+1])
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("# Generated by aks-flex-node. Do not edit; changes are overwritten on the next bootstrap.\n\n")
+	section("SERVICE",
+		"Flush", "5",
+		"Log_Level", "info",
+	)
+
+	for _, source := range sources {
+		switch source {
+		case "kubelet", "containerd":
+			section("INPUT",
+				"Name", "systemd",
+				"Tag", source,
+				"Systemd_Filter", fmt.Sprintf("_SYSTEMD_UNIT=%s.service", source),
+				"DB", fmt.Sprintf("%s/%s.db", fluentBitStateDir, source),
+				"Read_From_Tail", "On",
+				"Strip_Underscores", "On",
+			)
+		case "syslog":
+			section("INPUT",
+				"Name", "tail",
+				"Tag", "syslog",
+				"Path", syslogFilePath,
+				"DB", fluentBitStateDir+"/syslog.db",
+				"Key", "MESSAGE",
+				"Skip_Long_Lines", "On",
+			)
+		}
+	}
+
+	// Tell the logs of the flex nodes apart at the destination
+	section("FILTER",
+		"Name", "record_modifier",
+		"Match", "*",
+		"Record", "Computer "+hostname,
+	)
+
+	switch cfg.Destination {
+	case "log-analytics":
+		logType := cfg.LogType
+		if logType == "" {
+			logType = defaultLogType
+		}
+		section("OUTPUT",
+			"Name", "azure",
+			"Match", "*",
+			"Customer_ID", cfg.WorkspaceID,
+			"Shared_Key", sharedKey,
+			"Log_Type", logType,
+			"Retry_Limit", "False",
+		)
+	case "syslog":
+		mode := cfg.SyslogMode
+		if mode == "" {
+			mode = "tcp"
+		}
+		port := cfg.SyslogPort
+		if port == 0 {
+			port = defaultSyslogPort
+			if mode == "tls" {
+				port = defaultTLSPort
+			}
+		}
+		settings := []string{
+			"Name", "syslog",
+			"Match", "*",
+			"Host", cfg.SyslogHost,
+			"Port", fmt.Sprint(port),
+			"Mode", mode,
+			"Syslog_Format", "rfc5424",
+			"Syslog_Hostname_Key", "Computer",
+			"Syslog_Appname_Key", "SYSTEMD_UNIT",
+			"Syslog_Message_Key", "MESSAGE",
+			"Retry_Limit", "False",
+		}
+		if mode == "tls" {
+			settings = append(settings, "tls", "On", "tls.verify", "On")
+		}
+		section("OUTPUT", settings...)
+	}
+	return b.String()
+}
+
+// renderDropIn renders the systemd drop-in pointing the fluent-bit service at the agent's configuration
+func renderDropIn(cfg *config.Config) (string, error) {
+	return templates.Render(cfg, templates.FluentBitDropIn, struct{ Binary, Config string }{
+		Binary: fluentBitBinaryPath,
+		Config: fluentBitConfigPath,
+	})
+}
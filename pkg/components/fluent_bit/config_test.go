@@ -0,0 +1,75 @@
+package fluent_bit
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderConfig(t *testing.T) {
+	t.Run("log analytics", func(t *testing.T) {
+		cfg := config.FluentBitConfig{
+			Destination: "log-analytics",
+			WorkspaceID: "00000000-0000-0000-0000-000000000001",
+		}
+		rendered := renderConfig(cfg, []string{"kubelet", "syslog"}, "node1", "c2VjcmV0")
+
+		for _, want := range []string{
+			"Systemd_Filter     _SYSTEMD_UNIT=kubelet.service",
+			"Path               " + syslogFilePath,
+			"Record             Computer node1",
+			"Name               azure",
+			"Customer_ID        00000000-0000-0000-0000-000000000001",
+			"Shared_Key         c2VjcmV0",
+			"Log_Type           " + defaultLogType,
+		} {
+			if !strings.Contains(rendered, want) {
+				t.Errorf("rendered config missing %q:\n%s", want, rendered)
+			}
+		}
+		if strings.Contains(rendered, "containerd.service") {
+			t.Errorf("rendered config ships containerd logs that were not requested:\n%s", rendered)
+		}
+	})
+
+	t.Run("syslog over tls", func(t *testing.T) {
+		cfg := config.FluentBitConfig{Destination: "syslog", SyslogHost: "logs.example.com", SyslogMode: "tls"}
+		rendered := renderConfig(cfg, []string{"containerd"}, "node1", "")
+
+		for _, want := range []string{
+			"Systemd_Filter     _SYSTEMD_UNIT=containerd.service",
+			"Name               syslog",
+			"Host               logs.example.com",
+			"Port               6514",
+			"Mode               tls",
+			"tls                On",
+		} {
+			if !strings.Contains(rendered, want) {
+				t.Errorf("rendered config missing %q:\n%s", want, rendered)
+			}
+		}
+	})
+
+	t.Run("syslog defaults", func(t *testing.T) {
+		cfg := config.FluentBitConfig{Destination: "syslog", SyslogHost: "10.0.0.5"}
+		rendered := renderConfig(cfg, []string{"kubelet"}, "node1", "")
+		if !strings.Contains(rendered, "Port               514\n") || !strings.Contains(rendered, "Mode               tcp\n") {
+			t.Errorf("rendered config does not default to tcp/514:\n%s", rendered)
+		}
+		if strings.Contains(rendered, "tls ") {
+			t.Errorf("rendered config enables tls for tcp:\n%s", rendered)
+		}
+	})
+}
+
+func TestRenderDropIn(t *testing.T) {
+	dropIn, err := renderDropIn(&config.Config{})
+	if err != nil {
+		t.Fatalf("renderDropIn() unexpected error: %v", err)
+	}
+	// An empty ExecStart= clears the package's command before setting ours
+	if !strings.Contains(dropIn, "ExecStart=\nExecStart="+fluentBitBinaryPath+" -c "+fluentBitConfigPath+"\n") {
+		t.Errorf("renderDropIn() = %q, want ExecStart reset and pointed at %s", dropIn, fluentBitConfigPath)
+	}
+}
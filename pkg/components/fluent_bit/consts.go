@@ -0,0 +1,23 @@
+package fluent_bit
+
+const (
+	fluentBitServiceName = "fluent-bit"
+	fluentBitBinaryPath  = "/opt/fluent-bit/bin/fluent-bit"
+
+	// The agent keeps its own configuration next to the package default and points the service at it
+	// with a drop-in, so package upgrades never conflict with it
+	fluentBitConfigPath = "/etc/fluent-bit/aks-flex-node.conf"
+	fluentBitDropInDir  = "/etc/systemd/system/fluent-bit.service.d"
+	fluentBitDropInPath = "/etc/systemd/system/fluent-bit.service.d/10-aks-flex-node.conf"
+
+	// Read positions of the journal and tailed files, so restarts neither lose nor resend logs
+	fluentBitStateDir = "/var/lib/fluent-bit/aks-flex-node"
+
+	syslogFilePath = "/var/log/syslog"
+
+	defaultLogType    = "AKSFlexNode"
+	defaultSyslogPort = 514
+	defaultTLSPort    = 6514
+)
+
+var fluentBitInstallScriptURL = "https://raw.githubusercontent.com/fluent/fluent-bit/master/install.sh"
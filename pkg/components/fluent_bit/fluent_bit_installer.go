@@ -0,0 +1,213 @@
+package fluent_bit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/secrets"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
+)
+
+// Installer installs and configures fluent-bit to ship node logs when fluentBit.enabled is set
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new fluent-bit Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "FluentBit_Installer"
+}
+
+// Validate checks that the Log Analytics workspace key can be read
+func (i *Installer) Validate(ctx context.Context) error {
+	if !i.config.FluentBit.Enabled || i.config.FluentBit.Destination != "log-analytics" {
+		return nil
+	}
+	if _, err := i.sharedKey(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsCompleted returns true when fluent-bit is disabled, or installed, configured as desired and running
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.config.FluentBit.Enabled {
+		return true
+	}
+	if !utils.FileExists(fluentBitBinaryPath) || !i.isVersionCorrect() {
+		return false
+	}
+	desired, err := i.desiredConfig(ctx)
+	if err != nil {
+		return false
+	}
+	// The configuration is only readable by root
+	current, err := utils.RunCommandWithOutput("cat", fluentBitConfigPath)
+	if err != nil || current != desired {
+		return false
+	}
+	return utils.FileExists(fluentBitDropInPath) && utils.IsServiceActive(fluentBitServiceName)
+}
+
+// Execute installs fluent-bit if needed, writes its configuration and (re)starts it
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.FluentBit.Enabled {
+		return nil
+	}
+
+	if !utils.FileExists(fluentBitBinaryPath) || !i.isVersionCorrect() {
+		if err := i.install(ctx); err != nil {
+			return fmt.Errorf("fluent-bit installation failed: %w", err)
+		}
+	}
+
+	i.logger.Infof("Configuring fluent-bit to ship %s logs to %s",
+		strings.Join(i.config.GetFluentBitSources(), ", "), i.config.FluentBit.Destination)
+	if err := i.configure(ctx); err != nil {
+		return fmt.Errorf("fluent-bit configuration failed: %w", err)
+	}
+
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	if err := utils.EnableAndStartService(fluentBitServiceName); err != nil {
+		return fmt.Errorf("failed to enable and start fluent-bit: %w", err)
+	}
+	// Pick up configuration changes when fluent-bit was already running
+	if err := utils.RestartService(fluentBitServiceName); err != nil {
+		return fmt.Errorf("failed to restart fluent-bit: %w", err)
+	}
+
+	i.logger.Info("fluent-bit installed and running")
+	return nil
+}
+
+// install runs the fluent-bit install script, which adds the fluent-bit package repository and installs the package
+func (i *Installer) install(ctx context.Context) error {
+	tempDir, err := os.MkdirTemp("", "fluent-bit-install-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer func() {
+		if rmErr := os.RemoveAll(tempDir); rmErr != nil {
+			i.logger.Debugf("Failed to clean up temp directory %s: %v", tempDir, rmErr)
+		}
+	}()
+
+	scriptPath := filepath.Join(tempDir, "install.sh")
+	i.logger.Info("Downloading fluent-bit installation script...")
+	if err := utils.DownloadFile(fluentBitInstallScriptURL, scriptPath); err != nil {
+		return fmt.Errorf("failed to download fluent-bit installation script: %w", err)
+	}
+
+	command := "bash " + scriptPath
+	if version := i.config.FluentBit.Version; version != "" {
+		// The version was validated at config load, so it is safe to interpolate
+		command = fmt.Sprintf("FLUENT_BIT_RELEASE_VERSION=%s %s", version, command)
+	}
+	i.logger.Infof("Installing fluent-bit %s", i.versionDescription())
+	if err := utils.RunSystemCommand("bash", "-c", command); err != nil {
+		return fmt.Errorf("failed to run fluent-bit installation script: %w", err)
+	}
+	return nil
+}
+
+// configure writes the fluent-bit configuration and the systemd drop-in using it
+func (i *Installer) configure(ctx context.Context) error {
+	desired, err := i.desiredConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(fluentBitConfigPath), fluentBitDropInDir, fluentBitStateDir); err != nil {
+		return fmt.Errorf("failed to create fluent-bit directories: %w", err)
+	}
+	// The configuration may hold the workspace key
+	if err := utils.WriteFileAtomicSystem(fluentBitConfigPath, []byte(desired), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fluentBitConfigPath, err)
+	}
+	dropIn, err := renderDropIn(i.config)
+	if err != nil {
+		return err
+	}
+	if err := validation.WriteFile(fluentBitDropInPath, dropIn, 0o644, validation.SystemdDropIn, i.logger); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fluentBitDropInPath, err)
+	}
+	return nil
+}
+
+// desiredConfig renders the fluent-bit configuration for the current config
+func (i *Installer) desiredConfig(ctx context.Context) (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname: %w", err)
+	}
+	sharedKey := ""
+	if i.config.FluentBit.Destination == "log-analytics" {
+		if sharedKey, err = i.sharedKey(ctx); err != nil {
+			return "", err
+		}
+	}
+	return renderConfig(i.config.FluentBit, i.config.GetFluentBitSources(), strings.ToLower(hostname), sharedKey), nil
+}
+
+// sharedKey reads the Log Analytics workspace key from fluentBit.sharedKeyFile, or resolves the secret
+// reference in fluentBit.sharedKey
+func (i *Installer) sharedKey(ctx context.Context) (string, error) {
+	if reference := i.config.FluentBit.SharedKey; reference != "" {
+		key, err := secrets.ResolveString(ctx, i.config, reference)
+		if err != nil {
+			return "", fmt.Errorf("failed to read Log Analytics shared key: %w", err)
+		}
+		if strings.ContainsAny(key, " \t\r\n") {
+			return "", fmt.Errorf("log Analytics shared key %s must hold a single key", secrets.Redact(reference))
+		}
+		return key, nil
+	}
+	path := i.config.FluentBit.SharedKeyFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Log Analytics shared key file %s: %w", path, err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" || strings.ContainsAny(key, " \t\r\n") {
+		return "", fmt.Errorf("log Analytics shared key file %s must hold a single key", path)
+	}
+	return key, nil
+}
+
+// isVersionCorrect checks the installed fluent-bit version when a version is pinned
+func (i *Installer) isVersionCorrect() bool {
+	version := i.config.FluentBit.Version
+	if version == "" {
+		return true
+	}
+	output, err := utils.RunCommandWithOutput(fluentBitBinaryPath, "--version")
+	if err != nil {
+		i.logger.Debugf("Failed to get fluent-bit version: %v", err)
+		return false
+	}
+	return strings.Contains(output, "v"+version)
+}
+
+func (i *Installer) versionDescription() string {
+	if i.config.FluentBit.Version == "" {
+		return "(latest release)"
+	}
+	return "v" + i.config.FluentBit.Version
+}
@@ -0,0 +1,68 @@
+package fluent_bit
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller stops fluent-bit and removes the package and the agent's configuration
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new fluent-bit UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "FluentBit_UnInstaller"
+}
+
+// Execute removes fluent-bit installed by the agent
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	// Leave a fluent-bit installation the agent did not configure alone
+	if !utils.FileExists(fluentBitConfigPath) {
+		return nil
+	}
+	u.logger.Info("Uninstalling fluent-bit")
+
+	if utils.ServiceExists(fluentBitServiceName) {
+		if err := utils.StopService(fluentBitServiceName); err != nil {
+			u.logger.Warnf("Failed to stop fluent-bit: %v", err)
+		}
+		if err := utils.DisableService(fluentBitServiceName); err != nil {
+			u.logger.Warnf("Failed to disable fluent-bit: %v", err)
+		}
+	}
+
+	if err := utils.RunSystemCommand("apt", "-y", "remove", "fluent-bit"); err != nil {
+		u.logger.Debugf("Failed to remove fluent-bit package: %v (may not be installed)", err)
+	}
+	for _, err := range utils.RemoveFiles([]string{fluentBitConfigPath, fluentBitDropInPath}, u.logger) {
+		u.logger.Debugf("Failed to remove fluent-bit file: %v", err)
+	}
+	for _, err := range utils.RemoveDirectories([]string{fluentBitStateDir}, u.logger) {
+		u.logger.Debugf("Failed to remove fluent-bit state: %v", err)
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		u.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+
+	u.logger.Info("fluent-bit uninstalled successfully")
+	return nil
+}
+
+// IsCompleted returns true when the agent's fluent-bit configuration is gone
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !utils.FileExists(fluentBitConfigPath)
+}
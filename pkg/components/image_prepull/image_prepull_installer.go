@@ -0,0 +1,203 @@
+// Package image_prepull pulls critical system images right after the container runtime is installed.
+package image_prepull
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/cri"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// imageClient is the part of the CRI client used to pull images
+type imageClient interface {
+	ImageExists(ctx context.Context, image string) (bool, error)
+	PullImage(ctx context.Context, image string) (string, error)
+	Close() error
+}
+
+// Installer pulls the pause image and the images listed in imagePrePull.images
+type Installer struct {
+	config   *config.Config
+	logger   *logrus.Logger
+	provider container_runtime.Provider
+
+	newClient func(endpoint string) (imageClient, error)
+	// setProperties changes resource control properties of a running unit until the next reboot
+	setProperties func(unit string, properties []string) error
+}
+
+// NewInstaller creates a new image pre-pull Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config:   cfg,
+		logger:   logger,
+		provider: container_runtime.ForConfig(cfg),
+		newClient: func(endpoint string) (imageClient, error) {
+			return cri.NewClient(endpoint)
+		},
+		setProperties: func(unit string, properties []string) error {
+			return utils.RunSystemCommand("systemctl", setPropertyArgs(unit, properties)...)
+		},
+	}
+}
+
+// setPropertyArgs returns the systemctl arguments changing properties of a unit until the next reboot. The
+// sudoers rule for image pulls matches exactly this form, so keep both in sync.
+func setPropertyArgs(unit string, properties []string) []string {
+	return append([]string{"set-property", "--runtime", unit}, properties...)
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "ImagePrePull"
+}
+
+// Execute pulls every missing image and fails with the runtime's error for each image that could not be pulled
+func (i *Installer) Execute(ctx context.Context) error {
+	client, err := i.newClient(container_runtime.Endpoint(i.provider))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	images := imagesToPull(i.provider.PauseImage(), i.config.ImagePrePull.Images)
+	missing, err := missingImages(ctx, client, images)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		i.logger.Infof("All %d pre-pull images are already present", len(images))
+		return nil
+	}
+
+	i.logger.Infof("Pulling %d of %d images", len(missing), len(images))
+	restore := i.limitRuntime()
+	defer restore()
+	if err := i.pullAll(ctx, client, missing); err != nil {
+		return err
+	}
+	i.logger.Infof("✅ Pulled %d images", len(missing))
+	return nil
+}
+
+// limitRuntime applies the CPU and IO install limits to the container runtime, which decompresses and writes
+// the pulled layers, and returns a function lifting them again. Memory limits are left out, since the runtime's
+// unit also holds the container shims.
+func (i *Installer) limitRuntime() func() {
+	var limits, resets []string
+	for _, property := range i.config.GetInstallLimitProperties() {
+		name, _, _ := strings.Cut(property, "=")
+		if name == "CPUQuota" || name == "IOWeight" {
+			limits = append(limits, property)
+			resets = append(resets, name+"=")
+		}
+	}
+	if len(limits) == 0 {
+		return func() {}
+	}
+
+	unit := i.provider.ServiceName()
+	if err := i.setProperties(unit, limits); err != nil {
+		i.logger.Warnf("Failed to limit %s during image pulls, pulling unconfined: %v", unit, err)
+		return func() {}
+	}
+	i.logger.Infof("Limiting %s to %s while pulling images", unit, strings.Join(limits, ", "))
+	return func() {
+		if err := i.setProperties(unit, resets); err != nil {
+			i.logger.Warnf("Failed to lift the image pull limits of %s, they stay until the next reboot: %v", unit, err)
+		}
+	}
+}
+
+// pullAll pulls images concurrently and returns the errors of all failed pulls, in image order
+func (i *Installer) pullAll(ctx context.Context, client imageClient, images []string) error {
+	pullErrors := make([]error, len(images))
+	slots := make(chan struct{}, i.config.GetImagePrePullParallelism())
+	var wg sync.WaitGroup
+	for index, image := range images {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			pullCtx, cancel := context.WithTimeout(ctx, i.config.GetImagePrePullTimeout())
+			defer cancel()
+			if _, err := client.PullImage(pullCtx, image); err != nil {
+				pullErrors[index] = err
+				return
+			}
+			i.logger.Infof("Pulled %s", image)
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range pullErrors {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to pull %d of %d images: %w", len(failed), len(images), errors.Join(failed...))
+	}
+	return nil
+}
+
+// IsCompleted checks if all images are present in the runtime's image store
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	client, err := i.newClient(container_runtime.Endpoint(i.provider))
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	missing, err := missingImages(ctx, client, imagesToPull(i.provider.PauseImage(), i.config.ImagePrePull.Images))
+	if err != nil {
+		i.logger.Debugf("Failed to check pre-pulled images: %v", err)
+		return false
+	}
+	return len(missing) == 0
+}
+
+// Validate validates preconditions before execution
+func (i *Installer) Validate(ctx context.Context) error {
+	return nil
+}
+
+// imagesToPull returns the pause image followed by the configured images, without duplicates
+func imagesToPull(pauseImage string, images []string) []string {
+	result := []string{pauseImage}
+	for _, image := range images {
+		if image != pauseImage {
+			result = append(result, image)
+		}
+	}
+	return result
+}
+
+// missingImages returns the images not yet present in the runtime's image store
+func missingImages(ctx context.Context, client imageClient, images []string) ([]string, error) {
+	var missing []string
+	for _, image := range images {
+		exists, err := client.ImageExists(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, image)
+		}
+	}
+	return missing, nil
+}
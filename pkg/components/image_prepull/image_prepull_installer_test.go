@@ -0,0 +1,137 @@
+package image_prepull
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// fakeClient is an image store holding the present images; pulls of images in failing return an error
+type fakeClient struct {
+	mu      sync.Mutex
+	present map[string]bool
+	failing map[string]bool
+	pulled  []string
+}
+
+func (f *fakeClient) ImageExists(_ context.Context, image string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.present[image], nil
+}
+
+func (f *fakeClient) PullImage(_ context.Context, image string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[image] {
+		return "", errors.New("CRI PullImage " + image + " failed: rpc error: code = NotFound desc = not found")
+	}
+	f.present[image] = true
+	f.pulled = append(f.pulled, image)
+	return "sha256:" + image, nil
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
+
+func newTestInstaller(client *fakeClient, images ...string) *Installer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{ImagePrePull: config.ImagePrePullConfig{Images: images}}
+	return &Installer{
+		config:   cfg,
+		logger:   logger,
+		provider: container_runtime.ForConfig(cfg),
+		newClient: func(string) (imageClient, error) {
+			return client, nil
+		},
+	}
+}
+
+func TestImagesToPull(t *testing.T) {
+	got := imagesToPull("pause:3.6", []string{"csi:1.0", "pause:3.6", "cni:1.2"})
+	want := []string{"pause:3.6", "csi:1.0", "cni:1.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imagesToPull() = %v, want %v", got, want)
+	}
+}
+
+func TestExecutePullsMissingImages(t *testing.T) {
+	pause := "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+	client := &fakeClient{present: map[string]bool{pause: true}}
+	installer := newTestInstaller(client, "csi:1.0", "cni:1.2")
+
+	if installer.IsCompleted(context.Background()) {
+		t.Fatal("IsCompleted() = true before pulling")
+	}
+	if err := installer.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(client.pulled) != 2 {
+		t.Errorf("pulled %v, want only the two missing images", client.pulled)
+	}
+	if !installer.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = false after pulling")
+	}
+}
+
+func TestExecuteReportsEveryFailedPull(t *testing.T) {
+	client := &fakeClient{
+		present: map[string]bool{},
+		failing: map[string]bool{"wrong.registry/csi:1.0": true, "wrong.registry/cni:1.2": true},
+	}
+	installer := newTestInstaller(client, "wrong.registry/csi:1.0", "metrics:0.7", "wrong.registry/cni:1.2")
+
+	err := installer.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Execute() error = nil, want pull failures")
+	}
+	for _, want := range []string{
+		"failed to pull 2 of 4 images",
+		"CRI PullImage wrong.registry/csi:1.0 failed: rpc error: code = NotFound",
+		"CRI PullImage wrong.registry/cni:1.2 failed",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Execute() error = %v, want containing %q", err, want)
+		}
+	}
+	if !client.present["metrics:0.7"] {
+		t.Error("a failed pull stopped the other images from being pulled")
+	}
+}
+
+func TestExecuteLimitsRuntimeWhilePulling(t *testing.T) {
+	client := &fakeClient{present: map[string]bool{}}
+	installer := newTestInstaller(client, "csi:1.0")
+	installer.config.Agent.InstallLimits = config.InstallLimitsConfig{CPUQuota: "50%", IOWeight: 20, MemoryMax: "512M"}
+	var calls []string
+	installer.setProperties = func(unit string, properties []string) error {
+		calls = append(calls, unit+" "+strings.Join(properties, " "))
+		return nil
+	}
+
+	if err := installer.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := []string{"containerd CPUQuota=50% IOWeight=20", "containerd CPUQuota= IOWeight="}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("setProperties calls = %q, want the limits without memory and then their reset", calls)
+	}
+}
+
+func TestSetPropertyArgs(t *testing.T) {
+	got := setPropertyArgs("containerd", []string{"CPUQuota=50%", "IOWeight=20"})
+	want := []string{"set-property", "--runtime", "containerd", "CPUQuota=50%", "IOWeight=20"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("setPropertyArgs() = %q, want %q", got, want)
+	}
+}
@@ -1,24 +1,28 @@
 package kube_binaries
 
-const (
-	// Binary installation directory
-	binDir = "/usr/local/bin"
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
 
+const (
 	// Kubernetes binaries
 	kubeletBinary = "kubelet"
 	kubectlBinary = "kubectl"
 	kubeadmBinary = "kubeadm"
 
-	// Kubernetes binary paths
-	kubeletPath = binDir + "/" + kubeletBinary
-	kubectlPath = binDir + "/" + kubectlBinary
-	kubeadmPath = binDir + "/" + kubeadmBinary
-
 	// Repository files (these might be used externally, keeping uppercase for now)
 	KubernetesRepoList = "/etc/apt/sources.list.d/kubernetes.list"
 	KubernetesKeyring  = "/etc/apt/keyrings/kubernetes-apt-keyring.gpg"
 )
 
+// Binary installation directory, /opt/bin on immutable hosts
+var binDir = osimage.BinDir("/usr/local/bin")
+
+// Kubernetes binary paths
+var (
+	kubeletPath = binDir + "/" + kubeletBinary
+	kubectlPath = binDir + "/" + kubectlBinary
+	kubeadmPath = binDir + "/" + kubeadmBinary
+)
+
 var (
 	kubernetesFileName           = "kubernetes-node-linux-%s.tar.gz"
 	defaultKubernetesURLTemplate = "https://acs-mirror.azureedge.net/kubernetes/v%s/binaries/kubernetes-node-linux-%s.tar.gz"
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -17,9 +18,9 @@ type Installer struct {
 }
 
 // NewInstaller creates a new Kube binaries Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -29,7 +30,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Infof("Installing Kube Binaries of version %s", i.config.GetKubernetesVersion())
 
 	// Download and install Kubernetes binaries
-	if err := i.installKubeBinaries(); err != nil {
+	if err := i.installKubeBinaries(ctx); err != nil {
 		return fmt.Errorf("failed to install Kubernetes: %w", err)
 	}
 
@@ -37,7 +38,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) installKubeBinaries() error {
+func (i *Installer) installKubeBinaries(ctx context.Context) error {
 	// Clean up any corrupted installations before proceeding
 	i.logger.Info("Cleaning up corrupted Kubernetes installation files to start fresh")
 	if err := i.cleanupExistingInstallation(); err != nil {
@@ -65,13 +66,13 @@ func (i *Installer) installKubeBinaries() error {
 
 	// Download Kube binaries with validation
 	i.logger.Infof("Downloading Kube binaries from %s into %s", url, tempFile)
-	if err := utils.DownloadFile(url, tempFile); err != nil {
+	if err := download.NewManager(i.config, i.logger).For("kubernetes", i.config.GetKubernetesVersion()).Fetch(ctx, url, tempFile); err != nil {
 		return fmt.Errorf("failed to download Kube binaries from %s: %w", url, err)
 	}
 
 	// Extract Kubernetes binaries directly to binDir, stripping the 'kubernetes/node/bin/' prefix
 	i.logger.Infof("Extracting Kubernetes binaries to %s", binDir)
-	if err := utils.RunSystemCommand("tar", "-C", binDir, "--strip-components=3", "-xzf", tempFile, kubernetesTarPath); err != nil {
+	if err := utils.RunLimitedCommand(i.config.GetInstallLimitProperties(), "tar", "-C", binDir, "--strip-components=3", "-xzf", tempFile, kubernetesTarPath); err != nil {
 		return fmt.Errorf("failed to extract Kubernetes binaries: %w", err)
 	}
 
@@ -169,7 +170,7 @@ func (i *Installer) constructKubeBinariesDownloadURL() (string, string, error) {
 
 	kubernetesVersion := i.config.GetKubernetesVersion()
 	urlTemplate := i.getKubernetesURLTemplate()
-	url := fmt.Sprintf(urlTemplate, kubernetesVersion, arch)
+	url := i.config.GetArtifactURL("kubernetes", kubernetesVersion, arch, fmt.Sprintf(urlTemplate, kubernetesVersion, arch))
 	fileName := fmt.Sprintf(kubernetesFileName, arch)
 	i.logger.Infof("Constructed Kubernetes download URL: %s", url)
 	return fileName, url, nil
@@ -187,3 +188,8 @@ func (i *Installer) getKubernetesURLTemplate() string {
 func (i *Installer) GetName() string {
 	return "KubeBinariesInstaller"
 }
+
+// ManagedFiles returns the Kubernetes binaries installed by this step
+func (i *Installer) ManagedFiles() []string {
+	return kubeBinariesPaths
+}
@@ -0,0 +1,44 @@
+package kubelet
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/cri"
+)
+
+// runtimeQueryTimeout bounds the CRI call asking the container runtime for its cgroup driver
+const runtimeQueryTimeout = 10 * time.Second
+
+// queryRuntimeCgroupDriver asks the container runtime at endpoint for its cgroup driver; replaced in tests
+var queryRuntimeCgroupDriver = func(ctx context.Context, endpoint string) (string, error) {
+	client, err := cri.NewClient(endpoint)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, runtimeQueryTimeout)
+	defer cancel()
+	return client.CgroupDriver(ctx)
+}
+
+// alignCgroupDriver returns the cgroup driver kubelet must use. Kubelet exits with an opaque error when its
+// driver differs from the runtime's, so the driver the runtime reports wins over the configured one. Runtimes
+// that cannot report their driver were configured by the agent with the configured driver.
+func alignCgroupDriver(ctx context.Context, configured, endpoint string, logger *logrus.Logger) string {
+	driver, err := queryRuntimeCgroupDriver(ctx, endpoint)
+	if err != nil {
+		logger.Debugf("Container runtime did not report its cgroup driver, using %s: %v", configured, err)
+		return configured
+	}
+	if driver != configured {
+		logger.Warnf("⚠️  Container runtime uses the %s cgroup driver but node.cgroup.driver is %s; configuring kubelet "+
+			"with %s to match the runtime", driver, configured, driver)
+	}
+	return driver
+}
@@ -0,0 +1,37 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAlignCgroupDriver(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		runtime    string
+		runtimeErr error
+		want       string
+	}{
+		{name: "runtime matches", configured: "systemd", runtime: "systemd", want: "systemd"},
+		{name: "runtime differs", configured: "systemd", runtime: "cgroupfs", want: "cgroupfs"},
+		{name: "runtime cannot report", configured: "cgroupfs", runtimeErr: errors.New("unimplemented"), want: "cgroupfs"},
+	}
+
+	original := queryRuntimeCgroupDriver
+	t.Cleanup(func() { queryRuntimeCgroupDriver = original })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryRuntimeCgroupDriver = func(ctx context.Context, endpoint string) (string, error) {
+				return tt.runtime, tt.runtimeErr
+			}
+			if got := alignCgroupDriver(context.Background(), tt.configured, "unix:///run/cri.sock", logrus.New()); got != tt.want {
+				t.Errorf("alignCgroupDriver() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package kubelet
 
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
+
+// kubeletBinaryPath is installed by the kube binaries component, in /opt/bin on immutable hosts
+var kubeletBinaryPath = osimage.BinPath("/usr/local/bin/kubelet")
+
 const (
 	// System directories
 	etcDefaultDir     = "/etc/default"
@@ -0,0 +1,110 @@
+package kubelet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// diskPolicy holds the image garbage collection and disk eviction settings of kubelet
+type diskPolicy struct {
+	ImageGCHighThreshold    int
+	ImageGCLowThreshold     int
+	EvictionHard            map[string]string
+	EvictionSoft            map[string]string
+	EvictionSoftGracePeriod map[string]string
+}
+
+// fallbackDiskPolicy is used when the disk size is unknown or auto reservation is disabled; it matches
+// the thresholds used before disk based defaults existed
+var fallbackDiskPolicy = diskPolicy{ImageGCHighThreshold: 85, ImageGCLowThreshold: 80}
+
+// computeDiskPolicy returns image GC and eviction thresholds for a disk. Kubelet's defaults (image GC at
+// 85%, eviction at 10% free) leave small disks too little room: images are only collected once the disk
+// is almost full, and pods are evicted before GC frees enough space. Small disks therefore start image GC
+// earlier and get a soft eviction threshold that gives pods time to terminate gracefully. Large disks use
+// smaller percentages so tens of gigabytes are not held back. Because kubelet drops every default signal
+// once --eviction-hard is set, the disk and inode signals are always listed explicitly.
+func computeDiskPolicy(diskBytes uint64) diskPolicy {
+	hard := map[string]string{"nodefs.inodesFree": "5%"}
+	policy := diskPolicy{EvictionHard: hard}
+	switch {
+	case diskBytes < 64*gib:
+		policy.ImageGCHighThreshold, policy.ImageGCLowThreshold = 70, 50
+		hard["nodefs.available"], hard["imagefs.available"] = "10%", "10%"
+		policy.EvictionSoft = map[string]string{"nodefs.available": "15%", "imagefs.available": "15%"}
+		policy.EvictionSoftGracePeriod = map[string]string{"nodefs.available": "1m", "imagefs.available": "1m"}
+	case diskBytes < 256*gib:
+		policy.ImageGCHighThreshold, policy.ImageGCLowThreshold = 80, 65
+		hard["nodefs.available"], hard["imagefs.available"] = "10%", "10%"
+		policy.EvictionSoft = map[string]string{"nodefs.available": "15%", "imagefs.available": "15%"}
+		policy.EvictionSoftGracePeriod = map[string]string{"nodefs.available": "2m", "imagefs.available": "2m"}
+	default:
+		policy.ImageGCHighThreshold, policy.ImageGCLowThreshold = 85, 75
+		hard["nodefs.available"], hard["imagefs.available"] = "5%", "5%"
+		policy.EvictionSoft = map[string]string{"nodefs.available": "10%", "imagefs.available": "10%"}
+		policy.EvictionSoftGracePeriod = map[string]string{"nodefs.available": "2m", "imagefs.available": "2m"}
+	}
+	return policy
+}
+
+// applyConfiguredDiskPolicy overlays the configured GC thresholds and soft eviction settings on computed ones.
+// Configured hard eviction thresholds are merged with the memory reservations by the caller.
+func applyConfiguredDiskPolicy(computed diskPolicy, cfg config.KubeletConfig) diskPolicy {
+	result := computed
+	if cfg.ImageGCHighThreshold > 0 {
+		result.ImageGCHighThreshold, result.ImageGCLowThreshold = cfg.ImageGCHighThreshold, cfg.ImageGCLowThreshold
+	}
+	result.EvictionSoft = mergeReservation(computed.EvictionSoft, cfg.EvictionSoft)
+	result.EvictionSoftGracePeriod = mergeReservation(computed.EvictionSoftGracePeriod, cfg.EvictionSoftGracePeriod)
+	return result
+}
+
+// detectDiskSize returns the size of the file system holding path, using the nearest existing parent
+// because the kubelet directory may not exist yet on the first bootstrap
+func detectDiskSize(path string) (uint64, error) {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, fmt.Errorf("failed to stat file system of %s: %w", path, err)
+	}
+	return fs.Blocks * uint64(fs.Bsize), nil
+}
+
+// diskPressureFlags returns the kubelet flags for soft eviction and the minimum image GC age
+func diskPressureFlags(policy diskPolicy, imageMinimumGCAge string) []string {
+	var flags []string
+	if len(policy.EvictionSoft) > 0 {
+		flags = append(flags,
+			"--eviction-soft="+sortedPairs(policy.EvictionSoft, "<"),
+			"--eviction-soft-grace-period="+sortedPairs(policy.EvictionSoftGracePeriod, "="))
+	}
+	if imageMinimumGCAge != "" {
+		flags = append(flags, "--image-minimum-gc-age="+imageMinimumGCAge)
+	}
+	return flags
+}
+
+// sortedPairs renders a map as comma separated key<op>value pairs in key order
+func sortedPairs(m map[string]string, op string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+op+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
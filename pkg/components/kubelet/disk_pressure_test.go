@@ -0,0 +1,87 @@
+package kubelet
+
+import (
+	"reflect"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestComputeDiskPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		diskBytes    uint64
+		wantGCHigh   int
+		wantGCLow    int
+		wantHardDisk string
+		wantSoftDisk string
+	}{
+		{name: "30Gi disk", diskBytes: 30 * gib, wantGCHigh: 70, wantGCLow: 50, wantHardDisk: "10%", wantSoftDisk: "15%"},
+		{name: "128Gi disk", diskBytes: 128 * gib, wantGCHigh: 80, wantGCLow: 65, wantHardDisk: "10%", wantSoftDisk: "15%"},
+		{name: "1Ti disk", diskBytes: 1024 * gib, wantGCHigh: 85, wantGCLow: 75, wantHardDisk: "5%", wantSoftDisk: "10%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeDiskPolicy(tt.diskBytes)
+			if got.ImageGCHighThreshold != tt.wantGCHigh || got.ImageGCLowThreshold != tt.wantGCLow {
+				t.Errorf("image GC = %d/%d, want %d/%d", got.ImageGCHighThreshold, got.ImageGCLowThreshold, tt.wantGCHigh, tt.wantGCLow)
+			}
+			if got.EvictionHard["nodefs.available"] != tt.wantHardDisk || got.EvictionHard["imagefs.available"] != tt.wantHardDisk {
+				t.Errorf("EvictionHard = %v, want disk signals at %s", got.EvictionHard, tt.wantHardDisk)
+			}
+			if got.EvictionHard["nodefs.inodesFree"] != "5%" {
+				t.Errorf("EvictionHard = %v, want nodefs.inodesFree kept", got.EvictionHard)
+			}
+			if got.EvictionSoft["nodefs.available"] != tt.wantSoftDisk {
+				t.Errorf("EvictionSoft = %v, want nodefs.available at %s", got.EvictionSoft, tt.wantSoftDisk)
+			}
+
+			// The computed defaults must pass the same consistency checks as configured values
+			hard := mergeReservation(got.EvictionHard, map[string]string{"memory.available": "100Mi"})
+			if err := config.ValidateDiskPressurePolicy(got.ImageGCHighThreshold, got.ImageGCLowThreshold,
+				hard, got.EvictionSoft, got.EvictionSoftGracePeriod); err != nil {
+				t.Errorf("computed policy is inconsistent: %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyConfiguredDiskPolicy(t *testing.T) {
+	computed := computeDiskPolicy(30 * gib)
+	got := applyConfiguredDiskPolicy(computed, config.KubeletConfig{
+		ImageGCHighThreshold:    75,
+		ImageGCLowThreshold:     60,
+		EvictionSoft:            map[string]string{"memory.available": "500Mi"},
+		EvictionSoftGracePeriod: map[string]string{"memory.available": "30s"},
+	})
+
+	if got.ImageGCHighThreshold != 75 || got.ImageGCLowThreshold != 60 {
+		t.Errorf("image GC = %d/%d, want the configured 75/60", got.ImageGCHighThreshold, got.ImageGCLowThreshold)
+	}
+	wantSoft := map[string]string{"memory.available": "500Mi", "nodefs.available": "15%", "imagefs.available": "15%"}
+	if !reflect.DeepEqual(got.EvictionSoft, wantSoft) {
+		t.Errorf("EvictionSoft = %v, want %v", got.EvictionSoft, wantSoft)
+	}
+	if got.EvictionSoftGracePeriod["memory.available"] != "30s" || got.EvictionSoftGracePeriod["nodefs.available"] != "1m" {
+		t.Errorf("EvictionSoftGracePeriod = %v, want configured and computed grace periods", got.EvictionSoftGracePeriod)
+	}
+}
+
+func TestDiskPressureFlags(t *testing.T) {
+	policy := diskPolicy{
+		EvictionSoft:            map[string]string{"nodefs.available": "15%", "imagefs.available": "15%"},
+		EvictionSoftGracePeriod: map[string]string{"nodefs.available": "1m", "imagefs.available": "1m"},
+	}
+	want := []string{
+		"--eviction-soft=imagefs.available<15%,nodefs.available<15%",
+		"--eviction-soft-grace-period=imagefs.available=1m,nodefs.available=1m",
+		"--image-minimum-gc-age=5m",
+	}
+	if got := diskPressureFlags(policy, "5m"); !reflect.DeepEqual(got, want) {
+		t.Errorf("diskPressureFlags() = %v, want %v", got, want)
+	}
+	if got := diskPressureFlags(fallbackDiskPolicy, ""); len(got) != 0 {
+		t.Errorf("diskPressureFlags() = %v, want no flags for the fallback policy", got)
+	}
+}
@@ -5,15 +5,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/sirupsen/logrus"
 
 	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/tokenbroker"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/dns"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/workload_isolation"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/ipfamily"
+	"go.goms.io/aks/AKSFlexNode/pkg/nodecert"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+	"go.goms.io/aks/AKSFlexNode/pkg/topology"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
 )
 
 // Installer handles kubelet installation and configuration
@@ -24,9 +36,9 @@ type Installer struct {
 }
 
 // NewInstaller creates a new kubelet Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -36,6 +48,18 @@ func (i *Installer) GetName() string {
 	return "KubeletInstaller"
 }
 
+// ManagedFiles returns the kubelet service and configuration files rendered by this step. The kubeconfig
+// is left out because it holds credentials that are rotated.
+func (i *Installer) ManagedFiles() []string {
+	return []string{
+		kubeletDefaultsPath,
+		kubeletServicePath,
+		kubeletContainerdConfig,
+		kubeletTLSBootstrapConfig,
+		kubeletTokenScriptPath,
+	}
+}
+
 // Execute installs and configures kubelet service
 func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Info("Installing and configuring kubelet")
@@ -89,7 +113,7 @@ func (i *Installer) configure(ctx context.Context) error {
 	}
 
 	// Create kubelet defaults file
-	if err := i.createKubeletDefaultsFile(); err != nil {
+	if err := i.createKubeletDefaultsFile(ctx); err != nil {
 		return err
 	}
 
@@ -99,6 +123,11 @@ func (i *Installer) configure(ctx context.Context) error {
 		if err := i.createKubeconfigWithBootstrapToken(ctx); err != nil {
 			return err
 		}
+	} else if i.config.IsNodeCertificateConfigured() {
+		// Node certificate authentication uses a client certificate issued for the node
+		if err := i.createKubeconfigWithNodeCertificate(ctx); err != nil {
+			return err
+		}
 	} else {
 		// Arc or Service Principal authentication uses exec credential provider
 		// Create token script for exec credential authentication (Arc or Service Principal)
@@ -187,7 +216,7 @@ func (i *Installer) ensureRequiredPackages() error {
 	for _, pkg := range packages {
 		if err := utils.RunSystemCommand("which", pkg); err != nil {
 			i.logger.Infof("Installing %s...", pkg)
-			if err := utils.RunSystemCommand("apt", "install", "-y", pkg); err != nil {
+			if err := installPackage(pkg); err != nil {
 				return fmt.Errorf("failed to install %s: %w", pkg, err)
 			}
 			i.logger.Infof("Successfully installed %s", pkg)
@@ -200,50 +229,101 @@ func (i *Installer) ensureRequiredPackages() error {
 	return nil
 }
 
+// installPackage installs a distribution package, layering it with rpm-ostree on OSTree hosts
+func installPackage(pkg string) error {
+	switch osimage.Current().Variant {
+	case osimage.VariantMutable:
+		return utils.RunSystemCommand("apt", "install", "-y", pkg)
+	case osimage.VariantOSTree:
+		// --apply-live makes the layered package usable without booting the new deployment
+		return utils.RunSystemCommand("rpm-ostree", "install", "--idempotent", "--apply-live", "--assumeyes", pkg)
+	default:
+		return fmt.Errorf("this immutable OS has no package manager; add %s to the image", pkg)
+	}
+}
+
+// kubeletDefaultsData holds the variables of the kubelet defaults template
+type kubeletDefaultsData struct {
+	NodeLabels           string
+	Verbosity            int
+	ClusterDNS           string
+	EvictionHard         string
+	KubeReserved         string
+	SystemReserved       string
+	ImageGCHighThreshold int
+	ImageGCLowThreshold  int
+	MaxPods              int
+	CgroupDriver         string
+	ResolvConf           string
+	ExtraFlags           string
+}
+
 // createKubeletDefaultsFile creates the kubelet defaults configuration file
-func (i *Installer) createKubeletDefaultsFile() error {
-	// Create kubelet default config
-	labels := make([]string, 0, len(i.config.Node.Labels))
-	for key, value := range i.config.Node.Labels {
+func (i *Installer) createKubeletDefaultsFile(ctx context.Context) error {
+	// Create kubelet default config. The hardware topology is registered as labels, configured labels win.
+	inventory, err := topology.Load()
+	if err != nil {
+		i.logger.Warnf("Registering the node without topology labels: %v", err)
+	}
+	nodeLabels := topology.Labels(inventory)
+	for key, value := range i.config.GetNodeLabels() {
+		nodeLabels[key] = value
+	}
+	labels := make([]string, 0, len(nodeLabels))
+	for key, value := range nodeLabels {
 		labels = append(labels, fmt.Sprintf("%s=%s", key, value))
 	}
+	sort.Strings(labels)
+
+	reserved := i.resourceReservations()
+	disk := i.diskPolicy()
+	evictionHard := mergeReservation(disk.EvictionHard, reserved.EvictionHard)
+	if err := config.ValidateDiskPressurePolicy(disk.ImageGCHighThreshold, disk.ImageGCLowThreshold,
+		evictionHard, disk.EvictionSoft, disk.EvictionSoftGracePeriod); err != nil {
+		return fmt.Errorf("inconsistent disk pressure settings after applying defaults for this disk: %w", err)
+	}
+
+	// Kubelet refuses to start when the CPU manager policy differs from its checkpoint
+	if err := resetCPUManagerStateIfPolicyChanged(i.config.Node.Kubelet.CPUManagerPolicy, i.logger); err != nil {
+		return err
+	}
 
-	kubeletDefaults := fmt.Sprintf(`KUBELET_NODE_LABELS="%s"
-KUBELET_CONFIG_FILE_FLAGS=""
-KUBELET_FLAGS="\
-  --v=%d \
-  --address=0.0.0.0 \
-  --anonymous-auth=false \
-  --authentication-token-webhook=true \
-  --authorization-mode=Webhook \
-  --cgroup-driver=systemd \
-  --cgroups-per-qos=true \
-  --enforce-node-allocatable=pods \
-  --cluster-dns=%s \
-  --cluster-domain=cluster.local \
-  --event-qps=0  \
-  --eviction-hard=%s  \
-  --kube-reserved=%s  \
-  --image-gc-high-threshold=%d  \
-  --image-gc-low-threshold=%d  \
-  --max-pods=%d  \
-  --node-status-update-frequency=10s  \
-  --pod-max-pids=-1  \
-  --protect-kernel-defaults=true  \
-  --read-only-port=0  \
-  --resolv-conf=/run/systemd/resolve/resolv.conf  \
-  --streaming-connection-idle-timeout=4h  \
-  --rotate-certificates=true \
-  --tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_RSA_WITH_AES_256_GCM_SHA384,TLS_RSA_WITH_AES_128_GCM_SHA256 \
-  "`,
-		strings.Join(labels, ","),
-		i.config.Node.Kubelet.Verbosity,
-		i.config.Node.Kubelet.DNSServiceIP,
-		mapToEvictionThresholds(i.config.Node.Kubelet.EvictionHard, ","),
-		mapToKeyValuePairs(i.config.Node.Kubelet.KubeReserved, ","),
-		i.config.Node.Kubelet.ImageGCHighThreshold,
-		i.config.Node.Kubelet.ImageGCLowThreshold,
-		i.config.Node.MaxPods)
+	extraFlags := resourceManagerFlags(i.config.Node.Kubelet)
+	extraFlags = append(extraFlags, diskPressureFlags(disk, i.config.Node.Kubelet.ImageMinimumGCAge)...)
+	extraFlags = append(extraFlags, taintFlags(i.config.GetNodeTaints())...)
+	nodeIPFlag, err := ipfamily.NodeIPFlag(i.config)
+	if err != nil {
+		return err
+	}
+	if nodeIPFlag != "" {
+		// IPv6 and dual-stack nodes register an address of each family, the primary family first
+		extraFlags = append(extraFlags, nodeIPFlag)
+	}
+	if i.config.IsWorkloadIsolationEnabled() {
+		// Pods run in the Kubernetes slice with kubelet, within the share of the shared host
+		extraFlags = append(extraFlags, "--cgroup-root="+workload_isolation.CgroupRoot)
+	}
+
+	runtimeEndpoint := container_runtime.Endpoint(container_runtime.ForConfig(i.config))
+	cgroupDriver := alignCgroupDriver(ctx, i.config.GetCgroupDriver(), runtimeEndpoint, i.logger)
+
+	kubeletDefaults, err := templates.Render(i.config, templates.KubeletDefaults, kubeletDefaultsData{
+		NodeLabels:           strings.Join(labels, ","),
+		Verbosity:            i.config.Node.Kubelet.Verbosity,
+		ClusterDNS:           i.config.Node.Kubelet.DNSServiceIP,
+		EvictionHard:         mapToEvictionThresholds(evictionHard, ","),
+		KubeReserved:         mapToKeyValuePairs(reserved.KubeReserved, ","),
+		SystemReserved:       mapToKeyValuePairs(reserved.SystemReserved, ","),
+		ImageGCHighThreshold: disk.ImageGCHighThreshold,
+		ImageGCLowThreshold:  disk.ImageGCLowThreshold,
+		MaxPods:              i.config.Node.MaxPods,
+		CgroupDriver:         cgroupDriver,
+		ResolvConf:           dns.ResolvConf(),
+		ExtraFlags:           formatExtraFlags(extraFlags),
+	})
+	if err != nil {
+		return err
+	}
 
 	// Ensure /etc/default directory exists
 	if err := utils.RunSystemCommand("mkdir", "-p", etcDefaultDir); err != nil {
@@ -251,13 +331,67 @@ KUBELET_FLAGS="\
 	}
 
 	// Write kubelet defaults file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(kubeletDefaultsPath, []byte(kubeletDefaults), 0o644); err != nil {
+	if err := validation.WriteFile(kubeletDefaultsPath, kubeletDefaults, 0o644, validation.KubeletDefaults, i.logger); err != nil {
 		return fmt.Errorf("failed to create kubelet defaults file: %w", err)
 	}
 
 	return nil
 }
 
+// resourceReservations returns the kubelet reservations: values computed from the host's capacity,
+// overridden key by key with the configured ones
+func (i *Installer) resourceReservations() reservations {
+	kubeletCfg := i.config.Node.Kubelet
+	configured := reservations{
+		KubeReserved:   kubeletCfg.KubeReserved,
+		SystemReserved: kubeletCfg.SystemReserved,
+		EvictionHard:   kubeletCfg.EvictionHard,
+	}
+	if kubeletCfg.DisableAutoReservation {
+		return configured
+	}
+
+	host, err := detectHostResources()
+	if err != nil {
+		i.logger.Warnf("Failed to detect host resources, using configured reservations only: %v", err)
+		return configured
+	}
+
+	computed := computeReservations(host, i.config.Node.MaxPods, i.config.GetKubernetesVersion())
+	if shared := kubeletCfg.SharedHost; shared != nil {
+		withholdForSharedHost(computed, host, shared.CPUPercent, shared.MemoryPercent)
+	}
+	i.logger.Infof("Computed kubelet reservations for %d CPUs and %dMi memory: kube-reserved=%v system-reserved=%v eviction-hard=%v",
+		host.CPUCores, host.MemoryBytes/mib, computed.KubeReserved, computed.SystemReserved, computed.EvictionHard)
+
+	merged := reservations{
+		KubeReserved:   mergeReservation(computed.KubeReserved, configured.KubeReserved),
+		SystemReserved: mergeReservation(computed.SystemReserved, configured.SystemReserved),
+		EvictionHard:   mergeReservation(computed.EvictionHard, configured.EvictionHard),
+	}
+	cpu, memory := estimateAllocatable(host, merged)
+	i.logger.Infof("The node will report about %dm CPU and %dMi memory allocatable to the scheduler", cpu, memory/mib)
+	return merged
+}
+
+// diskPolicy returns the image GC and disk eviction settings: values picked for the size of the kubelet
+// disk, overridden with the configured ones
+func (i *Installer) diskPolicy() diskPolicy {
+	kubeletCfg := i.config.Node.Kubelet
+	computed := fallbackDiskPolicy
+	if !kubeletCfg.DisableAutoReservation {
+		size, err := detectDiskSize(i.config.Paths.Kubernetes.KubeletDir)
+		if err != nil {
+			i.logger.Warnf("Failed to detect disk size, using default image GC thresholds: %v", err)
+		} else {
+			computed = computeDiskPolicy(size)
+			i.logger.Infof("Computed disk pressure policy for a %dGi disk: image GC %d%%/%d%% eviction-hard=%v eviction-soft=%v",
+				size/gib, computed.ImageGCHighThreshold, computed.ImageGCLowThreshold, computed.EvictionHard, computed.EvictionSoft)
+		}
+	}
+	return applyConfiguredDiskPolicy(computed, kubeletCfg)
+}
+
 // createSystemdDropInFile creates a systemd drop-in file with the given content
 func (i *Installer) createSystemdDropInFile(filePath, content, description string) error {
 	// Ensure kubelet service.d directory exists
@@ -266,57 +400,45 @@ func (i *Installer) createSystemdDropInFile(filePath, content, description strin
 	}
 
 	// Write config file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(filePath, []byte(content), 0o644); err != nil {
+	if err := validation.WriteFile(filePath, content, 0o644, validation.KubeletDropIn, i.logger); err != nil {
 		return fmt.Errorf("failed to create %s: %w", description, err)
 	}
 
 	return nil
 }
 
-// createKubeletContainerdConfig creates the kubelet containerd configuration
+// createKubeletContainerdConfig points kubelet at the CRI socket of the configured container runtime.
+// The drop-in and variable keep their containerd names so upgraded nodes don't end up with two drop-ins.
 func (i *Installer) createKubeletContainerdConfig() error {
-	containerdConf := `[Service]
-Environment=KUBELET_CONTAINERD_FLAGS="--runtime-request-timeout=15m --container-runtime-endpoint=unix:///run/containerd/containerd.sock"`
+	containerdConf, err := templates.Render(i.config, templates.KubeletContainerdDropIn, struct{ RuntimeEndpoint string }{
+		RuntimeEndpoint: container_runtime.Endpoint(container_runtime.ForConfig(i.config)),
+	})
+	if err != nil {
+		return err
+	}
 
 	return i.createSystemdDropInFile(kubeletContainerdConfig, containerdConf, "kubelet containerd config file")
 }
 
 // createKubeletTLSBootstrapConfig creates the kubelet TLS bootstrap configuration
 func (i *Installer) createKubeletTLSBootstrapConfig() error {
-	tlsBootstrapConf := `[Service]
-Environment=KUBELET_TLS_BOOTSTRAP_FLAGS="--kubeconfig /var/lib/kubelet/kubeconfig"`
+	tlsBootstrapConf, err := templates.Render(i.config, templates.KubeletTLSBootstrapDropIn, nil)
+	if err != nil {
+		return err
+	}
 
 	return i.createSystemdDropInFile(kubeletTLSBootstrapConfig, tlsBootstrapConf, "kubelet TLS bootstrap config file")
 }
 
 // createKubeletServiceFile creates the main kubelet systemd service file
 func (i *Installer) createKubeletServiceFile() error {
-	kubeletService := `[Unit]
-Description=Kubelet
-ConditionPathExists=/usr/local/bin/kubelet
-[Service]
-Restart=always
-EnvironmentFile=/etc/default/kubelet
-SuccessExitStatus=143
-# Ace does not recall why this is done
-ExecStartPre=/bin/bash -c "if [ $(mount | grep \"/var/lib/kubelet\" | wc -l) -le 0 ] ; then /bin/mount --bind /var/lib/kubelet /var/lib/kubelet ; fi"
-ExecStartPre=/bin/mount --make-shared /var/lib/kubelet
-ExecStartPre=-/sbin/ebtables -t nat --list
-ExecStartPre=-/sbin/iptables -t nat --numeric --list
-ExecStart=/usr/local/bin/kubelet \
-        --enable-server \
-        --node-labels="${KUBELET_NODE_LABELS}" \
-        --volume-plugin-dir=/etc/kubernetes/volumeplugins \
-        --pod-manifest-path=/etc/kubernetes/manifests/ \
-        $KUBELET_TLS_BOOTSTRAP_FLAGS \
-        $KUBELET_CONFIG_FILE_FLAGS \
-        $KUBELET_CONTAINERD_FLAGS \
-        $KUBELET_FLAGS
-[Install]
-WantedBy=multi-user.target`
+	kubeletService, err := templates.Render(i.config, templates.KubeletService, struct{ Binary string }{Binary: kubeletBinaryPath})
+	if err != nil {
+		return err
+	}
 
 	// Write kubelet service file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(kubeletServicePath, []byte(kubeletService), 0o644); err != nil {
+	if err := validation.WriteFile(kubeletServicePath, kubeletService, 0o644, validation.SystemdUnit, i.logger); err != nil {
 		return fmt.Errorf("failed to create kubelet service file: %w", err)
 	}
 
@@ -436,20 +558,49 @@ EOF
 
 // createServicePrincipalTokenScript creates the Service Principal token script
 func (i *Installer) createServicePrincipalTokenScript() error {
-	sp := i.config.Azure.ServicePrincipal
-	tokenScript := fmt.Sprintf(`#!/bin/bash
+	return i.writeTokenScript(servicePrincipalTokenScript(i.config.Azure.ServicePrincipal))
+}
+
+// servicePrincipalTokenScript renders the token script, authenticating with the client secret or certificate
+func servicePrincipalTokenScript(sp *config.ServicePrincipalConfig) string {
+	credential := fmt.Sprintf(`CLIENT_SECRET="%s"
+CREDENTIAL_ARGS=(-d "client_secret=${CLIENT_SECRET}")`, sp.ClientSecret)
+	if sp.ClientCertificateFile != "" {
+		// Sign a client assertion with the certificate's private key
+		// https://learn.microsoft.com/entra/identity-platform/certificate-credentials
+		credential = fmt.Sprintf(`CERT_FILE="%s"
+TOKEN_URL="https://login.microsoftonline.com/${TENANT_ID}/oauth2/v2.0/token"
+
+b64url() { openssl base64 -A | tr '+/' '-_' | tr -d '='; }
+
+NOW=$(date +%%s)
+THUMBPRINT=$(openssl x509 -in "$CERT_FILE" -outform DER | openssl dgst -sha1 -binary | b64url)
+HEADER=$(printf '{"alg":"RS256","typ":"JWT","x5t":"%%s"}' "$THUMBPRINT" | b64url)
+PAYLOAD=$(printf '{"aud":"%%s","iss":"%%s","sub":"%%s","jti":"%%s","nbf":%%d,"exp":%%d}' \
+  "$TOKEN_URL" "$CLIENT_ID" "$CLIENT_ID" "$(cat /proc/sys/kernel/random/uuid)" "$NOW" "$((NOW + 600))" | b64url)
+SIGNATURE=$(printf '%%s.%%s' "$HEADER" "$PAYLOAD" | openssl dgst -sha256 -sign "$CERT_FILE" -binary | b64url)
+if [ -z "$THUMBPRINT" ] || [ -z "$SIGNATURE" ]; then
+    echo "Failed to sign a client assertion with $CERT_FILE"
+    exit 255
+fi
+
+CREDENTIAL_ARGS=(-d "client_assertion_type=urn:ietf:params:oauth:client-assertion-type:jwt-bearer" \
+  -d "client_assertion=${HEADER}.${PAYLOAD}.${SIGNATURE}")`, sp.ClientCertificateFile)
+	}
+
+	return fmt.Sprintf(`#!/bin/bash
 
 # Get Azure AD token using Service Principal credentials for direct AKS authentication
 
 CLIENT_ID="%s"
-CLIENT_SECRET="%s"
 TENANT_ID="%s"
+%s
 
 TOKEN_RESPONSE=$(curl -s -X POST \
   "https://login.microsoftonline.com/${TENANT_ID}/oauth2/v2.0/token" \
   -H "Content-Type: application/x-www-form-urlencoded" \
   -d "client_id=${CLIENT_ID}" \
-  -d "client_secret=${CLIENT_SECRET}" \
+  "${CREDENTIAL_ARGS[@]}" \
   -d "scope=%s/.default" \
   -d "grant_type=client_credentials")
 
@@ -480,9 +631,17 @@ cat <<EOF
     "token": "${ACCESS_TOKEN}"
   }
 }
-EOF`, sp.ClientID, sp.ClientSecret, sp.TenantID, aksServiceResourceID)
+EOF`, sp.ClientID, sp.TenantID, credential, aksServiceResourceID)
+}
 
-	return i.writeTokenScript(tokenScript)
+// RefreshTokenScript rewrites the exec credential script from the current configuration, e.g. after the
+// service principal credential was rotated. The kubelet runs the script whenever its token expires, so
+// it picks up the new credential without a restart.
+func (i *Installer) RefreshTokenScript() error {
+	if !utils.FileExists(kubeletTokenScriptPath) {
+		return nil
+	}
+	return i.createTokenScript()
 }
 
 // writeTokenScript helper method to write the token script with proper permissions
@@ -594,6 +753,25 @@ func (i *Installer) createKubeconfigWithBootstrapToken(ctx context.Context) erro
 		return fmt.Errorf("failed to get hostname for bootstrap kubeconfig: %w", err)
 	}
 
+	if i.config.IsBootstrapTokenBrokerConfigured() {
+		token, err := i.requestBrokerToken(ctx, hostname)
+		if err != nil {
+			return err
+		}
+		bootstrapToken = token.Token
+		// The configured cluster info wins, so a broker cannot redirect the node to another API server
+		if serverURL == "" {
+			serverURL = token.ServerURL
+		}
+		if caCertData == "" {
+			caCertData = token.CACertData
+		}
+		if serverURL == "" || caCertData == "" {
+			return fmt.Errorf("the bootstrap token broker returned no API server URL or CA certificate; " +
+				"set node.kubelet.serverURL and node.kubelet.caCertData")
+		}
+	}
+
 	// Include node name in username for better auditing in Kubernetes API server logs
 	username := fmt.Sprintf("kubelet-bootstrap-%s", hostname)
 
@@ -644,14 +822,102 @@ users:
 	return nil
 }
 
+// createKubeconfigWithNodeCertificate issues the node's client certificate, unless a valid one exists, and creates
+// a kubeconfig authenticating with it
+func (i *Installer) createKubeconfigWithNodeCertificate(ctx context.Context) error {
+	issuer, err := nodecert.NewIssuer(i.config)
+	if err != nil {
+		return fmt.Errorf("failed to set up the node certificate issuer: %w", err)
+	}
+	i.logger.Infof("Ensuring the node client certificate from %s", issuer)
+	cert, issued, err := nodecert.Ensure(ctx, i.config, issuer, time.Now())
+	if err != nil {
+		return err
+	}
+	if issued {
+		i.logger.Infof("Issued node certificate for %s, valid until %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	}
+
+	clusterName := i.config.Azure.TargetCluster.Name
+	user := cert.Subject.CommonName
+	// The certificate file holds the key as well, as the kubelet's own rotated certificates do
+	kubeconfigContent := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: %s
+  name: %s
+contexts:
+- context:
+    cluster: %s
+    user: %s
+  name: %s
+current-context: %s
+users:
+- name: %s
+  user:
+    client-certificate: %s
+    client-key: %s
+`,
+		i.config.Node.Kubelet.CACertData,
+		i.config.Node.Kubelet.ServerURL,
+		clusterName,
+		clusterName,
+		user,
+		clusterName,
+		clusterName,
+		user,
+		nodecert.CertificatePath,
+		nodecert.CertificatePath)
+
+	if err := utils.WriteFileAtomicSystem(KubeletKubeconfigPath, []byte(kubeconfigContent), 0o600); err != nil {
+		return fmt.Errorf("failed to create node certificate kubeconfig file: %w", err)
+	}
+
+	i.logger.Info("Node certificate kubeconfig created successfully")
+	return nil
+}
+
+// requestBrokerToken requests a bootstrap token for the node from the configured broker
+func (i *Installer) requestBrokerToken(ctx context.Context, nodeName string) (*tokenbroker.Token, error) {
+	broker := i.config.Azure.BootstrapToken.Broker
+	i.logger.Infof("Requesting a bootstrap token from %s", broker.URL)
+
+	cred, err := auth.NewAuthProvider().BrokerCredential(i.config)
+	if err != nil {
+		return nil, err
+	}
+	options := auth.ClientOptions(i.config)
+	client, err := tokenbroker.NewClient(broker.URL, broker.Audience, cred, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	opCtx, cancel := auth.OperationContext(ctx, i.config)
+	defer cancel()
+	var clusterResourceID string
+	if i.config.Azure.TargetCluster != nil {
+		clusterResourceID = i.config.Azure.TargetCluster.ResourceID
+	}
+	token, err := client.RequestToken(opCtx, tokenbroker.Request{ClusterResourceID: clusterResourceID, NodeName: nodeName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a bootstrap token from the broker: %w", err)
+	}
+	if !token.ExpiresOn.IsZero() {
+		i.logger.Infof("Received a bootstrap token valid until %s", token.ExpiresOn.Format(time.RFC3339))
+	}
+	return token, nil
+}
+
 // setUpClients sets up Azure SDK clients for fetching cluster credentials
 func (i *Installer) setUpClients() error {
-	cred, err := auth.NewAuthProvider().UserCredential(config.GetConfig())
+	cred, err := auth.NewAuthProvider().ClusterCredential(i.config)
 	if err != nil {
 		return fmt.Errorf("failed to get authentication credential: %w", err)
 	}
 	clusterSubID := i.config.GetTargetClusterSubscriptionID()
-	clientFactory, err := armcontainerservice.NewClientFactory(clusterSubID, cred, nil)
+	clientFactory, err := armcontainerservice.NewClientFactory(clusterSubID, cred, auth.ARMClientOptions(i.config))
 	if err != nil {
 		return fmt.Errorf("failed to create Azure Container Service client factory: %w", err)
 	}
@@ -661,14 +927,16 @@ func (i *Installer) setUpClients() error {
 
 // getClusterCredentials retrieves cluster kube admin credentials using Azure SDK
 func (i *Installer) getClusterCredentials(ctx context.Context) ([]byte, error) {
-	cfg := config.GetConfig()
+	cfg := i.config
 	clusterResourceGroup := cfg.GetTargetClusterResourceGroup()
 	clusterName := cfg.GetTargetClusterName()
 	i.logger.Infof("Fetching cluster credentials for cluster %s in resource group %s using Azure SDK",
 		clusterName, clusterResourceGroup)
 
 	// Get cluster admin credentials using the Azure SDK
-	resp, err := i.mcClient.ListClusterAdminCredentials(ctx, clusterResourceGroup, clusterName, nil)
+	opCtx, cancel := auth.OperationContext(ctx, cfg)
+	defer cancel()
+	resp, err := i.mcClient.ListClusterAdminCredentials(opCtx, clusterResourceGroup, clusterName, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster admin credentials for %s in resource group %s: %w", clusterName, clusterResourceGroup, err)
 	}
@@ -0,0 +1,25 @@
+package kubelet
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestServicePrincipalTokenScript(t *testing.T) {
+	secret := servicePrincipalTokenScript(&config.ServicePrincipalConfig{TenantID: "tenant", ClientID: "client", ClientSecret: "s3cret"})
+	if !strings.Contains(secret, `CLIENT_SECRET="s3cret"`) || strings.Contains(secret, "client_assertion") {
+		t.Errorf("secret token script does not authenticate with the client secret:\n%s", secret)
+	}
+
+	cert := servicePrincipalTokenScript(&config.ServicePrincipalConfig{TenantID: "tenant", ClientID: "client", ClientCertificateFile: "/etc/aks-flex-node/sp.pem"})
+	for _, want := range []string{`CERT_FILE="/etc/aks-flex-node/sp.pem"`, "client_assertion_type=urn:ietf:params:oauth:client-assertion-type:jwt-bearer", `date +%s`} {
+		if !strings.Contains(cert, want) {
+			t.Errorf("certificate token script does not contain %q:\n%s", want, cert)
+		}
+	}
+	if strings.Contains(cert, "CLIENT_SECRET") || strings.Contains(cert, "%!") {
+		t.Errorf("certificate token script is malformed:\n%s", cert)
+	}
+}
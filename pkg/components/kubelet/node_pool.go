@@ -0,0 +1,12 @@
+package kubelet
+
+import "strings"
+
+// taintFlags renders the node pool taints for the kubelet. The kubelet only applies them when it
+// registers the node, so taint changes on an existing node must be made with kubectl.
+func taintFlags(taints []string) []string {
+	if len(taints) == 0 {
+		return nil
+	}
+	return []string{"--register-with-taints=" + strings.Join(taints, ",")}
+}
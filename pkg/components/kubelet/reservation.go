@@ -0,0 +1,214 @@
+package kubelet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	mib = 1024 * 1024
+	gib = 1024 * mib
+
+	// Source of the host's total memory
+	procMeminfoPath = "/proc/meminfo"
+)
+
+// hostResources describes the capacity the reservations are computed from
+type hostResources struct {
+	CPUCores    int
+	MemoryBytes uint64
+}
+
+// reservations holds the kubelet resource reservation flags
+type reservations struct {
+	KubeReserved   map[string]string
+	SystemReserved map[string]string
+	EvictionHard   map[string]string
+}
+
+// detectHostResources reads the number of CPUs and total memory of the host
+func detectHostResources() (hostResources, error) {
+	f, err := os.Open(procMeminfoPath)
+	if err != nil {
+		return hostResources{}, fmt.Errorf("failed to open %s: %w", procMeminfoPath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	memory, err := parseMemTotal(f)
+	if err != nil {
+		return hostResources{}, err
+	}
+	return hostResources{CPUCores: runtime.NumCPU(), MemoryBytes: memory}, nil
+}
+
+// parseMemTotal extracts MemTotal in bytes from /proc/meminfo content
+func parseMemTotal(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kib, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal value %q: %w", fields[1], err)
+		}
+		return kib * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read meminfo: %w", err)
+	}
+	return 0, fmt.Errorf("MemTotal not found in meminfo")
+}
+
+// computeReservations returns the recommended reservations for the host, mirroring the AKS formulas.
+// Kubernetes 1.29+ uses the per-pod memory formula and a smaller eviction threshold, older versions
+// use the regressive memory tiers.
+func computeReservations(host hostResources, maxPods int, kubernetesVersion string) reservations {
+	r := reservations{
+		KubeReserved: map[string]string{
+			"cpu": fmt.Sprintf("%dm", kubeReservedCPUMillicores(host.CPUCores)),
+		},
+		SystemReserved: map[string]string{
+			"cpu":    "100m",
+			"memory": fmt.Sprintf("%dMi", systemReservedMemoryMiB(host.MemoryBytes)),
+		},
+	}
+
+	if atLeastMinor(kubernetesVersion, 29) {
+		r.KubeReserved["memory"] = fmt.Sprintf("%dMi", kubeReservedMemoryMiBPerPod(host.MemoryBytes, maxPods))
+		r.EvictionHard = map[string]string{"memory.available": "100Mi"}
+	} else {
+		r.KubeReserved["memory"] = fmt.Sprintf("%dMi", kubeReservedMemoryMiBRegressive(host.MemoryBytes))
+		r.EvictionHard = map[string]string{"memory.available": "750Mi"}
+	}
+	return r
+}
+
+// withholdForSharedHost adds the CPU and memory not given to Kubernetes on a shared host to system-reserved,
+// so allocatable only covers Kubernetes' share. A percent of 0 or 100 gives Kubernetes the whole resource.
+func withholdForSharedHost(r reservations, host hostResources, cpuPercent, memoryPercent int) {
+	if cpuPercent > 0 && cpuPercent < 100 {
+		withheld := host.CPUCores * 1000 * (100 - cpuPercent) / 100
+		r.SystemReserved["cpu"] = fmt.Sprintf("%dm", withheld+quantityValue(r.SystemReserved["cpu"], true))
+	}
+	if memoryPercent > 0 && memoryPercent < 100 {
+		withheld := host.MemoryBytes / mib * uint64(100-memoryPercent) / 100
+		r.SystemReserved["memory"] = fmt.Sprintf("%dMi", withheld+uint64(quantityValue(r.SystemReserved["memory"], false))/mib)
+	}
+}
+
+// estimateAllocatable returns the CPU in millicores and memory in bytes the node reports as allocatable:
+// the capacity less kube-reserved, system-reserved and the hard memory eviction threshold. Kubelet also
+// leaves out pre-allocated hugepages, which are not counted here.
+func estimateAllocatable(host hostResources, r reservations) (int64, int64) {
+	cpu := int64(host.CPUCores) * 1000
+	memory := int64(host.MemoryBytes)
+	for _, reserved := range []map[string]string{r.KubeReserved, r.SystemReserved} {
+		cpu -= int64(quantityValue(reserved["cpu"], true))
+		memory -= int64(quantityValue(reserved["memory"], false))
+	}
+	memory -= int64(quantityValue(r.EvictionHard["memory.available"], false))
+	return max(cpu, 0), max(memory, 0)
+}
+
+// quantityValue parses a resource quantity, in millis when milli is set. Empty, invalid and percentage
+// values count as 0.
+func quantityValue(value string, milli bool) int {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+	if milli {
+		return int(q.MilliValue())
+	}
+	return int(q.Value())
+}
+
+// kubeReservedCPUMillicores reserves 6% of the first core, 1% of the second, 0.5% of cores 3-4
+// and 0.25% of every core above 4
+func kubeReservedCPUMillicores(cores int) int {
+	millicores := 0.0
+	for core := 1; core <= cores; core++ {
+		switch {
+		case core == 1:
+			millicores += 60
+		case core == 2:
+			millicores += 10
+		case core <= 4:
+			millicores += 5
+		default:
+			millicores += 2.5
+		}
+	}
+	return int(millicores)
+}
+
+// kubeReservedMemoryMiBPerPod reserves 20MiB per pod plus 50MiB, capped at 25% of memory
+func kubeReservedMemoryMiBPerPod(memoryBytes uint64, maxPods int) uint64 {
+	reserved := uint64(20*maxPods + 50)
+	return min(reserved, memoryBytes/mib/4)
+}
+
+// kubeReservedMemoryMiBRegressive reserves 25% of the first 4GiB, 20% of the next 4GiB,
+// 10% of the next 8GiB, 6% of the next 112GiB and 2% of anything above 128GiB
+func kubeReservedMemoryMiBRegressive(memoryBytes uint64) uint64 {
+	tiers := []struct {
+		size    uint64
+		percent uint64
+	}{
+		{4 * gib, 25},
+		{4 * gib, 20},
+		{8 * gib, 10},
+		{112 * gib, 6},
+	}
+
+	remaining := memoryBytes
+	var reserved uint64
+	for _, tier := range tiers {
+		portion := min(remaining, tier.size)
+		reserved += portion * tier.percent / 100
+		remaining -= portion
+	}
+	reserved += remaining * 2 / 100
+	return reserved / mib
+}
+
+// systemReservedMemoryMiB reserves 5% of memory for OS daemons such as the Arc agent,
+// at least 256MiB and at most 1GiB
+func systemReservedMemoryMiB(memoryBytes uint64) uint64 {
+	return min(max(memoryBytes/mib*5/100, 256), 1024)
+}
+
+// atLeastMinor reports whether a 1.x Kubernetes version is at least 1.<minor>.
+// Unparseable versions are treated as current.
+func atLeastMinor(version string, minor int) bool {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return true
+	}
+	got, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return true
+	}
+	return got >= minor
+}
+
+// mergeReservation overlays configured values on computed ones; configured keys always win
+func mergeReservation(computed, configured map[string]string) map[string]string {
+	merged := make(map[string]string, len(computed)+len(configured))
+	for k, v := range computed {
+		merged[k] = v
+	}
+	for k, v := range configured {
+		merged[k] = v
+	}
+	return merged
+}
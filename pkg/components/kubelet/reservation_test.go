@@ -0,0 +1,104 @@
+package kubelet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKubeReservedCPUMillicores(t *testing.T) {
+	tests := []struct {
+		cores int
+		want  int
+	}{
+		{1, 60},
+		{2, 70},
+		{4, 80},
+		{8, 90},
+		{64, 230},
+	}
+	for _, tt := range tests {
+		if got := kubeReservedCPUMillicores(tt.cores); got != tt.want {
+			t.Errorf("kubeReservedCPUMillicores(%d) = %d, want %d", tt.cores, got, tt.want)
+		}
+	}
+}
+
+func TestKubeReservedMemory(t *testing.T) {
+	// Regressive tiers: 25% of 4GiB + 20% of 4GiB + 10% of 8GiB = 1024 + 819 + 819 MiB
+	if got := kubeReservedMemoryMiBRegressive(16 * gib); got != 2662 {
+		t.Errorf("kubeReservedMemoryMiBRegressive(16GiB) = %d, want 2662", got)
+	}
+	if got := kubeReservedMemoryMiBRegressive(2 * gib); got != 512 {
+		t.Errorf("kubeReservedMemoryMiBRegressive(2GiB) = %d, want 512", got)
+	}
+
+	// Per pod formula: 20MiB * 110 + 50MiB, capped at 25% of memory
+	if got := kubeReservedMemoryMiBPerPod(16*gib, 110); got != 2250 {
+		t.Errorf("kubeReservedMemoryMiBPerPod(16GiB, 110) = %d, want 2250", got)
+	}
+	if got := kubeReservedMemoryMiBPerPod(4*gib, 110); got != 1024 {
+		t.Errorf("kubeReservedMemoryMiBPerPod(4GiB, 110) = %d, want 1024", got)
+	}
+}
+
+func TestComputeReservations(t *testing.T) {
+	host := hostResources{CPUCores: 4, MemoryBytes: 16 * gib}
+
+	current := computeReservations(host, 30, "1.30.3")
+	if current.KubeReserved["cpu"] != "80m" || current.KubeReserved["memory"] != "650Mi" {
+		t.Errorf("computeReservations(1.30) kube-reserved = %v", current.KubeReserved)
+	}
+	if current.EvictionHard["memory.available"] != "100Mi" {
+		t.Errorf("computeReservations(1.30) eviction-hard = %v", current.EvictionHard)
+	}
+	if current.SystemReserved["memory"] != "819Mi" {
+		t.Errorf("computeReservations() system-reserved = %v", current.SystemReserved)
+	}
+
+	legacy := computeReservations(host, 30, "1.28.5")
+	if legacy.KubeReserved["memory"] != "2662Mi" || legacy.EvictionHard["memory.available"] != "750Mi" {
+		t.Errorf("computeReservations(1.28) = %+v", legacy)
+	}
+}
+
+func TestWithholdForSharedHost(t *testing.T) {
+	host := hostResources{CPUCores: 8, MemoryBytes: 32 * gib}
+	r := computeReservations(host, 30, "1.31.1")
+	withholdForSharedHost(r, host, 50, 75)
+	if r.SystemReserved["cpu"] != "4100m" || r.SystemReserved["memory"] != "9216Mi" {
+		t.Errorf("withholdForSharedHost() system-reserved = %v, want 4100m and 9216Mi", r.SystemReserved)
+	}
+
+	cpu, memory := estimateAllocatable(host, r)
+	if cpu != 8000-4100-90 || memory != (32*1024-9216-650-100)*mib {
+		t.Errorf("estimateAllocatable() = %dm, %dMi", cpu, memory/mib)
+	}
+
+	whole := computeReservations(host, 30, "1.31.1")
+	withholdForSharedHost(whole, host, 100, 0)
+	if whole.SystemReserved["cpu"] != "100m" || whole.SystemReserved["memory"] != "1024Mi" {
+		t.Errorf("withholdForSharedHost(100, 0) system-reserved = %v, want it unchanged", whole.SystemReserved)
+	}
+}
+
+func TestMergeReservation(t *testing.T) {
+	merged := mergeReservation(map[string]string{"cpu": "80m", "memory": "650Mi"}, map[string]string{"memory": "1Gi", "pid": "1000"})
+	if merged["cpu"] != "80m" || merged["memory"] != "1Gi" || merged["pid"] != "1000" {
+		t.Errorf("mergeReservation() = %v", merged)
+	}
+}
+
+func TestParseMemTotal(t *testing.T) {
+	meminfo := "MemTotal:       16384000 kB\nMemFree:         1000 kB\n"
+	got, err := parseMemTotal(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatalf("parseMemTotal() unexpected error: %v", err)
+	}
+	if got != 16384000*1024 {
+		t.Errorf("parseMemTotal() = %d, want %d", got, 16384000*1024)
+	}
+
+	if _, err := parseMemTotal(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Error("parseMemTotal() expected error when MemTotal is missing")
+	}
+}
@@ -0,0 +1,79 @@
+package kubelet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// CPU manager checkpoint written by kubelet
+const cpuManagerStatePath = "/var/lib/kubelet/cpu_manager_state"
+
+// resourceManagerFlags returns the kubelet flags for the CPU and topology managers
+func resourceManagerFlags(cfg config.KubeletConfig) []string {
+	var flags []string
+	if cfg.CPUManagerPolicy != "" {
+		flags = append(flags, "--cpu-manager-policy="+cfg.CPUManagerPolicy)
+	}
+	if cfg.ReservedCPUs != "" {
+		flags = append(flags, "--reserved-cpus="+cfg.ReservedCPUs)
+	}
+	if cfg.TopologyManagerPolicy != "" {
+		flags = append(flags, "--topology-manager-policy="+cfg.TopologyManagerPolicy)
+	}
+	if cfg.TopologyManagerScope != "" {
+		flags = append(flags, "--topology-manager-scope="+cfg.TopologyManagerScope)
+	}
+	return flags
+}
+
+// formatExtraFlags renders flags as continuation lines for KUBELET_FLAGS in the defaults file
+func formatExtraFlags(flags []string) string {
+	var b strings.Builder
+	for _, flag := range flags {
+		fmt.Fprintf(&b, "  %s \\\n", flag)
+	}
+	return b.String()
+}
+
+// cpuManagerPolicyFromState returns the policy name recorded in a CPU manager checkpoint
+func cpuManagerPolicyFromState(data []byte) (string, error) {
+	var state struct {
+		PolicyName string `json:"policyName"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", fmt.Errorf("failed to parse CPU manager state: %w", err)
+	}
+	return state.PolicyName, nil
+}
+
+// resetCPUManagerStateIfPolicyChanged removes the CPU manager checkpoint when the configured policy differs
+// from the recorded one, which kubelet otherwise rejects at startup
+func resetCPUManagerStateIfPolicyChanged(policy string, logger *logrus.Logger) error {
+	if policy == "" {
+		policy = "none"
+	}
+
+	data, err := os.ReadFile(cpuManagerStatePath)
+	if err != nil {
+		// No checkpoint yet, nothing to reset
+		return nil
+	}
+
+	recorded, err := cpuManagerPolicyFromState(data)
+	if err == nil && recorded == policy {
+		return nil
+	}
+
+	logger.Infof("CPU manager policy changed from %q to %q, removing %s", recorded, policy, cpuManagerStatePath)
+	if err := utils.RunCleanupCommand(cpuManagerStatePath); err != nil {
+		return fmt.Errorf("failed to remove stale CPU manager state: %w", err)
+	}
+	return nil
+}
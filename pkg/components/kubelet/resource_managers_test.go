@@ -0,0 +1,51 @@
+package kubelet
+
+import (
+	"reflect"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestResourceManagerFlags(t *testing.T) {
+	if flags := resourceManagerFlags(config.KubeletConfig{}); len(flags) != 0 {
+		t.Errorf("resourceManagerFlags() with defaults = %v, want none", flags)
+	}
+
+	got := resourceManagerFlags(config.KubeletConfig{
+		CPUManagerPolicy:      "static",
+		ReservedCPUs:          "0-1",
+		TopologyManagerPolicy: "single-numa-node",
+		TopologyManagerScope:  "pod",
+	})
+	want := []string{
+		"--cpu-manager-policy=static",
+		"--reserved-cpus=0-1",
+		"--topology-manager-policy=single-numa-node",
+		"--topology-manager-scope=pod",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resourceManagerFlags() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatExtraFlags(t *testing.T) {
+	if got := formatExtraFlags(nil); got != "" {
+		t.Errorf("formatExtraFlags(nil) = %q, want empty", got)
+	}
+	got := formatExtraFlags([]string{"--a=1", "--b=2"})
+	want := "  --a=1 \\\n  --b=2 \\\n"
+	if got != want {
+		t.Errorf("formatExtraFlags() = %q, want %q", got, want)
+	}
+}
+
+func TestCPUManagerPolicyFromState(t *testing.T) {
+	policy, err := cpuManagerPolicyFromState([]byte(`{"policyName":"static","defaultCpuSet":"2-7","checksum":123}`))
+	if err != nil || policy != "static" {
+		t.Errorf("cpuManagerPolicyFromState() = %q, %v, want static", policy, err)
+	}
+	if _, err := cpuManagerPolicyFromState([]byte("not json")); err == nil {
+		t.Error("cpuManagerPolicyFromState() expected error for invalid JSON")
+	}
+}
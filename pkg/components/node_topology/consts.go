@@ -0,0 +1,13 @@
+package node_topology
+
+import "time"
+
+const (
+	// How long the publisher waits for kubelet to register the node, and how often it checks
+	registrationTimeout  = 2 * time.Minute
+	registrationInterval = 5 * time.Second
+
+	// requestTimeout bounds each kubectl call so a slow API server cannot hold up bootstrap. The sudoers rules
+	// for the publisher include it, so keep both in sync.
+	requestTimeout = 10 * time.Second
+)
@@ -0,0 +1,55 @@
+package node_topology
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/topology"
+)
+
+// Installer collects the hardware topology before kubelet is configured, so kubelet registers the node with
+// the topology labels, and keeps it in the inventory file
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new node topology Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "NodeTopology_Installer"
+}
+
+// Execute collects the topology and writes the inventory file
+func (i *Installer) Execute(ctx context.Context) error {
+	inventory, err := topology.Collect()
+	if err != nil {
+		return fmt.Errorf("failed to collect the hardware topology: %w", err)
+	}
+	if err := topology.Save(inventory); err != nil {
+		return err
+	}
+	i.logger.Infof("Hardware topology: %d NUMA nodes, %d SR-IOV NICs, %d GPUs, recorded in %s",
+		len(inventory.NUMANodes), len(inventory.SRIOVNICs), len(inventory.GPUs), topology.InventoryPath)
+	return nil
+}
+
+// IsCompleted always returns false so hardware added since the last bootstrap is picked up
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	return false
+}
+
+// Validate validates preconditions before execution
+func (i *Installer) Validate(ctx context.Context) error {
+	return nil
+}
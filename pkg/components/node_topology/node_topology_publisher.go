@@ -0,0 +1,144 @@
+package node_topology
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/topology"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Publisher sets the topology labels and annotation on the node once kubelet has registered it. Kubelet only
+// applies --node-labels when it registers the node, so this keeps the labels of an existing node current.
+// Problems are logged as warnings; the node works without its topology labels.
+type Publisher struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	kubectl  func(args ...string) (string, error)
+	hostname func() (string, error)
+	sleep    func(ctx context.Context, d time.Duration)
+	timeout  time.Duration
+}
+
+// NewPublisher creates a new node topology Publisher
+func NewPublisher(cfg *config.Config, logger *logrus.Logger) *Publisher {
+	return &Publisher{
+		config: cfg,
+		logger: logger,
+		kubectl: func(args ...string) (string, error) {
+			return utils.RunCommandWithOutput("kubectl", args...)
+		},
+		hostname: os.Hostname,
+		sleep: func(ctx context.Context, d time.Duration) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(d):
+			}
+		},
+		timeout: registrationTimeout,
+	}
+}
+
+// GetName returns the step name
+func (p *Publisher) GetName() string {
+	return "NodeTopology_Publisher"
+}
+
+// Execute waits for the node and patches its topology labels and annotation
+func (p *Publisher) Execute(ctx context.Context) error {
+	inventory, err := topology.Load()
+	if err != nil || inventory == nil {
+		p.logger.Warnf("⚠️  Skipping topology labels: no hardware topology was collected: %v", err)
+		return nil
+	}
+	if err := p.publish(ctx, inventory); err != nil {
+		p.logger.Warnf("⚠️  Failed to publish the hardware topology on the node: %v", err)
+		return nil
+	}
+	p.logger.Info("✅ Hardware topology published as node labels and annotation")
+	return nil
+}
+
+// IsCompleted always returns false so the labels follow the collected topology
+func (p *Publisher) IsCompleted(ctx context.Context) bool {
+	return false
+}
+
+// Validate validates preconditions before execution
+func (p *Publisher) Validate(ctx context.Context) error {
+	return nil
+}
+
+func (p *Publisher) publish(ctx context.Context, inventory *topology.Inventory) error {
+	// Kubelet registers the node under its lower-cased hostname
+	hostname, err := p.hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine node name: %w", err)
+	}
+	node := strings.ToLower(hostname)
+
+	patch, err := topologyPatch(inventory, p.config.Node.Labels)
+	if err != nil {
+		return err
+	}
+	if err := p.waitForNode(ctx, node); err != nil {
+		return err
+	}
+	output, err := p.kubectl("--kubeconfig", kubelet.KubeletKubeconfigPath, "--request-timeout="+requestTimeout.String(),
+		"patch", "node", node, "--type=merge", "-p", patch)
+	if err != nil {
+		return fmt.Errorf("kubectl patch node %s failed: %w: %s", node, err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// waitForNode polls until kubelet has registered the node
+func (p *Publisher) waitForNode(ctx context.Context, node string) error {
+	deadline := time.Now().Add(p.timeout)
+	for {
+		output, err := p.kubectl("--kubeconfig", kubelet.KubeletKubeconfigPath, "--request-timeout="+requestTimeout.String(),
+			"get", "node", node, "-o", "name")
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			return fmt.Errorf("node %s was not registered within %s: %w: %s", node, p.timeout, err, strings.TrimSpace(output))
+		}
+		p.sleep(ctx, registrationInterval)
+	}
+}
+
+// topologyPatch returns the merge patch setting the topology labels and annotation. Topology labels the host
+// no longer has hardware for are removed, and labels set in node.labels are left to the configuration.
+func topologyPatch(inventory *topology.Inventory, configured map[string]string) (string, error) {
+	current := topology.Labels(inventory)
+	labels := make(map[string]any, len(topology.LabelKeys))
+	for _, key := range topology.LabelKeys {
+		if _, ok := configured[key]; ok {
+			continue
+		}
+		if value, ok := current[key]; ok {
+			labels[key] = value
+		} else {
+			labels[key] = nil
+		}
+	}
+	annotations, err := topology.Annotations(inventory)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the hardware topology: %w", err)
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"labels": labels, "annotations": annotations}})
+	if err != nil {
+		return "", err
+	}
+	return string(patch), nil
+}
@@ -0,0 +1,89 @@
+package node_topology
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/topology"
+)
+
+func TestTopologyPatch(t *testing.T) {
+	inventory := &topology.Inventory{
+		NUMANodes: []topology.NUMANode{{ID: 0}, {ID: 1}},
+		GPUs:      []topology.GPU{{Vendor: "nvidia"}},
+	}
+	patch, err := topologyPatch(inventory, map[string]string{topology.LabelGPUVendor: "nvidia-a100"})
+	if err != nil {
+		t.Fatalf("topologyPatch() unexpected error: %v", err)
+	}
+
+	var got struct {
+		Metadata struct {
+			Labels      map[string]*string `json:"labels"`
+			Annotations map[string]string  `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(patch), &got); err != nil {
+		t.Fatalf("invalid patch %s: %v", patch, err)
+	}
+	two, one := "2", "1"
+	want := map[string]*string{
+		topology.LabelNUMANodes: &two,
+		topology.LabelGPUCount:  &one,
+		topology.LabelSRIOV:     nil,
+		topology.LabelSRIOVVFs:  nil,
+	}
+	if !reflect.DeepEqual(got.Metadata.Labels, want) {
+		t.Errorf("patch labels = %v, want %v", got.Metadata.Labels, want)
+	}
+	if !strings.Contains(got.Metadata.Annotations[topology.AnnotationTopology], `"numaNodes"`) {
+		t.Errorf("patch annotations = %v, want the inventory", got.Metadata.Annotations)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var calls []string
+	registered := 2
+	p := &Publisher{
+		config: &config.Config{},
+		logger: logrus.New(),
+		kubectl: func(args ...string) (string, error) {
+			// Leave out the patch body; the rest has to match the sudoers rules exactly
+			if args[3] == "patch" {
+				args = args[:len(args)-1]
+			}
+			calls = append(calls, strings.Join(args, " "))
+			if args[3] == "get" && registered > 0 {
+				registered--
+				return `Error from server (NotFound): nodes "node-1" not found`, errors.New("exit status 1")
+			}
+			return "", nil
+		},
+		hostname: func() (string, error) { return "Node-1", nil },
+		sleep:    func(ctx context.Context, d time.Duration) {},
+		timeout:  time.Minute,
+	}
+
+	if err := p.publish(context.Background(), &topology.Inventory{NUMANodes: []topology.NUMANode{{ID: 0}}}); err != nil {
+		t.Fatalf("publish() unexpected error: %v", err)
+	}
+	get := "--kubeconfig /var/lib/kubelet/kubeconfig --request-timeout=10s get node node-1 -o name"
+	want := []string{get, get, get, "--kubeconfig /var/lib/kubelet/kubeconfig --request-timeout=10s patch node node-1 --type=merge -p"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("kubectl calls = %v, want %v", calls, want)
+	}
+
+	p.timeout = 0
+	registered = 1
+	if err := p.publish(context.Background(), &topology.Inventory{}); err == nil || !strings.Contains(err.Error(), "was not registered") {
+		t.Errorf("publish() error = %v, want the node not registered", err)
+	}
+}
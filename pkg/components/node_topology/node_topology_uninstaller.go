@@ -0,0 +1,43 @@
+package node_topology
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/topology"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes the topology inventory file. The labels and annotation go away with the Node object.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new node topology UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "NodeTopology_UnInstaller"
+}
+
+// Execute removes the inventory file
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	if err := topology.Remove(); err != nil {
+		u.logger.Warnf("Failed to remove %s: %v", topology.InventoryPath, err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when the inventory file is gone
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !utils.FileExists(topology.InventoryPath)
+}
@@ -1,11 +1,19 @@
 package npd
 
-// NPD binary paths to check and manage
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
+
+// NPD binary path to check and manage, in /opt/bin on immutable hosts
+var npdBinaryPath = osimage.BinPath("/usr/bin/node-problem-detector")
+
 const (
-	npdBinaryPath  = "/usr/bin/node-problem-detector"
 	npdConfigPath  = "/etc/node-problem-detector/kernel-monitor.json"
 	npdServicePath = "/etc/systemd/system/node-problem-detector.service"
 	tempDir        = "/tmp/npd"
+
+	// Custom plugin scripts, their monitor configurations and the checksums recorded at install time
+	npdPluginDir           = "/etc/node-problem-detector/plugins"
+	npdPluginMonitorDir    = "/etc/node-problem-detector/custom-plugin-monitors"
+	npdPluginChecksumsPath = "/var/lib/aks-flex-node/npd-plugin-checksums.json"
 )
 
 var (
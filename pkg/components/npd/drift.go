@@ -0,0 +1,38 @@
+package npd
+
+import (
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+)
+
+// driftPluginName is the built-in plugin raising the drift event from the agent's drift report
+const driftPluginName = "config-drift"
+
+// driftScript reports the files the agent found changed outside of bootstrap.
+// {{REPORT}} is replaced with the path of the agent's drift report.
+const driftScript = `#!/bin/sh
+# Generated by aks-flex-node: installed files changed outside of bootstrap
+if [ -s {{REPORT}} ]; then
+    echo "Files changed outside of bootstrap: $(tr '\n' ';' < {{REPORT}})"
+    exit 1
+fi
+echo "No installed files changed"
+exit 0
+`
+
+// driftPlugins returns the NPD plugin raising the drift event, or nil when drift detection is disabled.
+// Drift is reported as an event unless the node condition is enabled.
+func driftPlugins(d config.DriftConfig) []config.NPDPluginConfig {
+	if d.Disabled {
+		return nil
+	}
+	return []config.NPDPluginConfig{{
+		Name:      driftPluginName,
+		Script:    strings.ReplaceAll(driftScript, "{{REPORT}}", drift.ReportPath),
+		Condition: "ConfigDrift",
+		Reason:    "FilesChangedOutsideBootstrap",
+		Temporary: !d.NodeCondition,
+	}}
+}
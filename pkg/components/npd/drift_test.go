@@ -0,0 +1,60 @@
+package npd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+)
+
+func TestDriftPlugins(t *testing.T) {
+	if plugins := driftPlugins(config.DriftConfig{Disabled: true}); plugins != nil {
+		t.Errorf("driftPlugins() = %v with drift detection disabled, want none", plugins)
+	}
+
+	plugins := driftPlugins(config.DriftConfig{})
+	if len(plugins) != 1 || !plugins[0].Temporary {
+		t.Fatalf("driftPlugins() = %+v, want a single temporary plugin", plugins)
+	}
+	if !strings.Contains(plugins[0].Script, drift.ReportPath) {
+		t.Errorf("drift script does not read %s:\n%s", drift.ReportPath, plugins[0].Script)
+	}
+	if plugins := driftPlugins(config.DriftConfig{NodeCondition: true}); plugins[0].Temporary || plugins[0].Condition != "ConfigDrift" {
+		t.Errorf("driftPlugins() = %+v with nodeCondition, want the ConfigDrift condition", plugins)
+	}
+}
+
+func TestDriftScript(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "drift-report")
+	script := strings.ReplaceAll(driftScript, "{{REPORT}}", report)
+	run := func() (int, string) {
+		output, err := exec.Command("sh", "-c", script).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), string(output)
+		}
+		if err != nil {
+			t.Fatalf("failed to run drift script: %v", err)
+		}
+		return 0, string(output)
+	}
+
+	if code, _ := run(); code != 0 {
+		t.Errorf("drift script exit code = %d without a report, want 0", code)
+	}
+	if err := os.WriteFile(report, []byte(""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := run(); code != 0 {
+		t.Errorf("drift script exit code = %d with an empty report, want 0", code)
+	}
+	if err := os.WriteFile(report, []byte("modified /etc/containerd/config.toml (ContainerdInstaller)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, output := run(); code != 1 || !strings.Contains(output, "/etc/containerd/config.toml") {
+		t.Errorf("drift script = %d, %q with drifted files, want 1 naming the file", code, output)
+	}
+}
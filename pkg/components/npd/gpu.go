@@ -0,0 +1,98 @@
+package npd
+
+import (
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// GPU health plugins. Each one sets its own node condition, so the scheduler and operators can
+// tell a dying GPU (XID), bad memory (ECC) and an overheating GPU apart.
+const (
+	gpuXidPluginName     = "gpu-xid"
+	gpuECCPluginName     = "gpu-ecc"
+	gpuThermalPluginName = "gpu-thermal"
+)
+
+// gpuXidScript reports critical NVIDIA XID errors logged by the driver since boot. These XIDs
+// (double bit ECC errors, row remapping failures, NVLink errors, GPU fallen off the bus, contained
+// and uncontained memory errors) leave the GPU unusable until it is reset or the node reboots.
+const gpuXidScript = `#!/bin/sh
+# Generated by aks-flex-node: critical NVIDIA XID errors since boot
+if ! command -v journalctl >/dev/null 2>&1; then
+    echo "journalctl not found"
+    exit 2
+fi
+xids=$(journalctl -k -b --no-pager 2>/dev/null | grep -oE 'NVRM: Xid \([^)]*\): (48|63|64|74|79|92|94|95),' | sort -u | tr '\n' ' ')
+if [ -n "$xids" ]; then
+    echo "Critical XID errors: $xids"
+    exit 1
+fi
+echo "No critical XID errors"
+exit 0
+`
+
+// gpuECCScript reports GPUs with uncorrectable ECC errors since the driver was loaded
+const gpuECCScript = `#!/bin/sh
+# Generated by aks-flex-node: uncorrectable NVIDIA GPU ECC errors
+if ! command -v nvidia-smi >/dev/null 2>&1; then
+    echo "nvidia-smi not found"
+    exit 2
+fi
+if ! out=$(nvidia-smi --query-gpu=index,ecc.errors.uncorrected.volatile.total --format=csv,noheader,nounits 2>&1); then
+    echo "nvidia-smi failed: $out"
+    exit 2
+fi
+bad=$(echo "$out" | awk -F', *' '$2 ~ /^[0-9]+$/ && $2 > 0 { printf "GPU%s=%s ", $1, $2 }')
+if [ -n "$bad" ]; then
+    echo "Uncorrectable ECC errors: $bad"
+    exit 1
+fi
+echo "No uncorrectable ECC errors"
+exit 0
+`
+
+// gpuThermalScript reports GPUs slowed down by thermal protection, or above the configured temperature.
+// {{MAX_TEMPERATURE}} is replaced with the limit in degrees Celsius, 0 to rely on the driver only.
+const gpuThermalScript = `#!/bin/sh
+# Generated by aks-flex-node: NVIDIA GPU thermal slowdown
+if ! command -v nvidia-smi >/dev/null 2>&1; then
+    echo "nvidia-smi not found"
+    exit 2
+fi
+if ! out=$(nvidia-smi --query-gpu=index,temperature.gpu,clocks_throttle_reasons.hw_thermal_slowdown,clocks_throttle_reasons.sw_thermal_slowdown --format=csv,noheader,nounits 2>&1); then
+    echo "nvidia-smi failed: $out"
+    exit 2
+fi
+bad=$(echo "$out" | awk -F', *' -v max={{MAX_TEMPERATURE}} '$3 == "Active" || $4 == "Active" || (max > 0 && $2 + 0 >= max) { printf "GPU%s=%sC ", $1, $2 }')
+if [ -n "$bad" ]; then
+    echo "Thermal slowdown: $bad"
+    exit 1
+fi
+echo "No thermal slowdown"
+exit 0
+`
+
+// gpuPlugins returns the NPD plugins checking NVIDIA GPU health, or nil when GPU health checks are disabled
+func gpuPlugins(gpu config.NPDGPUHealthConfig) []config.NPDPluginConfig {
+	if !gpu.Enabled {
+		return nil
+	}
+	thermal := strings.ReplaceAll(gpuThermalScript, "{{MAX_TEMPERATURE}}", strconv.Itoa(gpu.MaxTemperature))
+	return []config.NPDPluginConfig{
+		{Name: gpuXidPluginName, Script: gpuXidScript, Condition: "GPUXidError", Reason: "CriticalXidError", Interval: gpu.Interval},
+		{Name: gpuECCPluginName, Script: gpuECCScript, Condition: "GPUMemoryError", Reason: "UncorrectableECCError", Interval: gpu.Interval},
+		{Name: gpuThermalPluginName, Script: thermal, Condition: "GPUThermalSlowdown", Reason: "ThermalSlowdown", Interval: gpu.Interval},
+	}
+}
+
+// allPlugins returns the custom plugins followed by the built-in GPU health, drift, reboot-required and role
+// assignment plugins
+func allPlugins(cfg *config.Config) []config.NPDPluginConfig {
+	plugins := append([]config.NPDPluginConfig{}, cfg.Npd.CustomPlugins...)
+	plugins = append(plugins, gpuPlugins(cfg.Npd.GPUHealth)...)
+	plugins = append(plugins, driftPlugins(cfg.Agent.Drift)...)
+	plugins = append(plugins, patchingPlugins(cfg.Agent.Patching)...)
+	return append(plugins, roleAssignmentPlugins(cfg)...)
+}
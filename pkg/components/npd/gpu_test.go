@@ -0,0 +1,98 @@
+package npd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestGPUPlugins(t *testing.T) {
+	if plugins := gpuPlugins(config.NPDGPUHealthConfig{}); plugins != nil {
+		t.Errorf("gpuPlugins() with GPU health disabled = %v, want none", plugins)
+	}
+
+	plugins := gpuPlugins(config.NPDGPUHealthConfig{Enabled: true, Interval: "30s", MaxTemperature: 85})
+	want := map[string]string{
+		gpuXidPluginName:     "GPUXidError",
+		gpuECCPluginName:     "GPUMemoryError",
+		gpuThermalPluginName: "GPUThermalSlowdown",
+	}
+	if len(plugins) != len(want) {
+		t.Fatalf("gpuPlugins() = %d plugins, want %d", len(plugins), len(want))
+	}
+	for _, plugin := range plugins {
+		if want[plugin.Name] != plugin.Condition || plugin.Interval != "30s" {
+			t.Errorf("plugin %s reports %s every %s, want %s every 30s", plugin.Name, plugin.Condition, plugin.Interval, want[plugin.Name])
+		}
+		if plugin.Name == gpuThermalPluginName && !strings.Contains(plugin.Script, "-v max=85 ") {
+			t.Errorf("thermal script does not use the configured temperature:\n%s", plugin.Script)
+		}
+	}
+
+	cfg := &config.Config{Npd: config.NPDConfig{
+		CustomPlugins: []config.NPDPluginConfig{raidPlugin},
+		GPUHealth:     config.NPDGPUHealthConfig{Enabled: true},
+	}}
+	if all := allPlugins(cfg); len(all) != 6 || all[0].Name != raidPlugin.Name || all[4].Name != driftPluginName || all[5].Name != rebootRequiredPluginName {
+		t.Errorf("allPlugins() = %d plugins, want the custom plugin followed by 3 GPU plugins, the drift and the reboot-required plugin", len(all))
+	}
+}
+
+// runGPUScript runs a GPU plugin script with a fake nvidia-smi printing output and returns its exit code and output
+func runGPUScript(t *testing.T, script, nvidiaSMIOutput string) (int, string) {
+	t.Helper()
+	if _, err := exec.LookPath("awk"); err != nil {
+		t.Skip("awk not available")
+	}
+	dir := t.TempDir()
+	fake := "#!/bin/sh\ncat <<'OUT'\n" + nvidiaSMIOutput + "\nOUT\n"
+	if err := os.WriteFile(filepath.Join(dir, "nvidia-smi"), []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Env = append(os.Environ(), "PATH="+dir+":"+os.Getenv("PATH"))
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), string(output)
+	}
+	if err != nil {
+		t.Fatalf("failed to run script: %v", err)
+	}
+	return 0, string(output)
+}
+
+func TestGPUScripts(t *testing.T) {
+	plugins := map[string]config.NPDPluginConfig{}
+	for _, plugin := range gpuPlugins(config.NPDGPUHealthConfig{Enabled: true, MaxTemperature: 85}) {
+		plugins[plugin.Name] = plugin
+	}
+
+	tests := []struct {
+		name     string
+		plugin   string
+		output   string
+		wantCode int
+		wantText string
+	}{
+		{name: "ecc healthy", plugin: gpuECCPluginName, output: "0, 0\n1, [N/A]", wantCode: 0},
+		{name: "ecc errors", plugin: gpuECCPluginName, output: "0, 0\n1, 3", wantCode: 1, wantText: "GPU1=3"},
+		{name: "thermal healthy", plugin: gpuThermalPluginName, output: "0, 60, Not Active, Not Active", wantCode: 0},
+		{name: "thermal slowdown", plugin: gpuThermalPluginName, output: "0, 70, Active, Not Active", wantCode: 1, wantText: "GPU0=70C"},
+		{name: "over temperature", plugin: gpuThermalPluginName, output: "0, 60, Not Active, Not Active\n1, 90, Not Active, Not Active", wantCode: 1, wantText: "GPU1=90C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, output := runGPUScript(t, plugins[tt.plugin].Script, tt.output)
+			if code != tt.wantCode || !strings.Contains(output, tt.wantText) {
+				t.Errorf("script exited %d with %q, want %d containing %q", code, output, tt.wantCode, tt.wantText)
+			}
+		})
+	}
+}
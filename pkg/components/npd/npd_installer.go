@@ -3,13 +3,17 @@ package npd
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
 )
 
 type Installer struct {
@@ -17,9 +21,9 @@ type Installer struct {
 	logger *logrus.Logger
 }
 
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -28,17 +32,26 @@ func (i *Installer) GetName() string {
 	return "NPD_Installer"
 }
 
+// ManagedFiles returns the binary and configuration files installed by this step.
+// Plugins are checked against their own recorded checksums.
+func (i *Installer) ManagedFiles() []string {
+	return []string{npdBinaryPath, npdConfigPath, npdServicePath}
+}
+
 func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Infof("Installing Node Problem Detector version %s", i.config.Npd.Version)
 
-	// clean up any existing installation
-	if err := i.cleanupExistingInstallation(); err != nil {
-		return fmt.Errorf("failed to clean up existing NPD installation: %w", err)
-	}
+	// Only reinstall the binary when it is missing or outdated; plugin drift just needs reconfiguration
+	if !utils.FileExists(npdBinaryPath) || !i.isNpdVersionCorrect() {
+		// clean up any existing installation
+		if err := i.cleanupExistingInstallation(); err != nil {
+			return fmt.Errorf("failed to clean up existing NPD installation: %w", err)
+		}
 
-	// Install NPD
-	if err := i.installNpd(); err != nil {
-		return fmt.Errorf("NPD installation failed: %w", err)
+		// Install NPD
+		if err := i.installNpd(ctx); err != nil {
+			return fmt.Errorf("NPD installation failed: %w", err)
+		}
 	}
 
 	i.logger.Info("Configuring NPD")
@@ -50,7 +63,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) installNpd() error {
+func (i *Installer) installNpd(ctx context.Context) error {
 	// construct download URL
 	npdFileName, npdDownloadURL, err := i.getNpdDownloadURL()
 	if err != nil {
@@ -75,13 +88,13 @@ func (i *Installer) installNpd() error {
 
 	i.logger.Debugf("Downloading NPD from %s to %s", npdDownloadURL, tempFile)
 
-	if err := utils.DownloadFile(npdDownloadURL, tempFile); err != nil {
+	if err := download.NewManager(i.config, i.logger).For("node-problem-detector", i.config.Npd.Version).Fetch(ctx, npdDownloadURL, tempFile); err != nil {
 		return fmt.Errorf("failed to download NPD archive from %s: %w", npdDownloadURL, err)
 	}
 
 	// Extract NPD binary from tar.gz archive
 	i.logger.Info("Extracting NPD binary from archive")
-	if err := utils.RunSystemCommand("tar", "-xzf", tempFile, "-C", tempDir); err != nil {
+	if err := utils.RunLimitedCommand(i.config.GetInstallLimitProperties(), "tar", "-xzf", tempFile, "-C", tempDir); err != nil {
 		return fmt.Errorf("failed to extract NPD archive: %w", err)
 	}
 
@@ -115,11 +128,26 @@ func (i *Installer) installNpd() error {
 }
 
 func (i *Installer) configure() error {
+	// Install site-specific plugin scripts before the service references their monitor configurations
+	if err := installPlugins(i.config, allPlugins(i.config), i.logger); err != nil {
+		return fmt.Errorf("failed to install NPD custom plugins: %w", err)
+	}
+
 	// Create NPD systemd service
 	if err := i.createNpdServiceFile(); err != nil {
 		return err
 	}
 
+	// A running NPD only reads its monitor configurations at startup
+	if utils.IsServiceActive("node-problem-detector") {
+		if err := utils.ReloadSystemd(); err != nil {
+			return fmt.Errorf("failed to reload systemd: %w", err)
+		}
+		if err := utils.RestartService("node-problem-detector"); err != nil {
+			return fmt.Errorf("failed to restart NPD: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -134,23 +162,22 @@ func (i *Installer) createNpdServiceFile() error {
 		return fmt.Errorf("failed to extract cluster info: %w", err)
 	}
 
-	cmd := fmt.Sprintf("%s --apiserver-override=\"%s?inClusterConfig=false&auth=%s\" --config.system-log-monitor=%s",
-		npdBinaryPath, serverURL, kubelet.KubeletKubeconfigPath, npdConfigPath)
-
-	npdService := `[Unit]
-Description=Node Problem Detector
-After=network.target
-
-[Service]
-ExecStart=` + cmd + `
-Restart=on-failure
-RestartSec=5s
+	npdService, err := templates.Render(i.config, templates.NPDService, struct {
+		Binary, APIServer, Kubeconfig, SystemLogMonitor string
+		PluginMonitors                                  []string
+	}{
+		Binary:           npdBinaryPath,
+		APIServer:        serverURL,
+		Kubeconfig:       kubelet.KubeletKubeconfigPath,
+		SystemLogMonitor: npdConfigPath,
+		PluginMonitors:   pluginMonitorPaths(allPlugins(i.config)),
+	})
+	if err != nil {
+		return err
+	}
 
-[Install]
-WantedBy=multi-user.target
-`
 	// Write NPD service file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(npdServicePath, []byte(npdService), 0644); err != nil {
+	if err := validation.WriteFile(npdServicePath, npdService, 0o644, validation.SystemdUnit, i.logger); err != nil {
 		return fmt.Errorf("failed to create NPD service file: %w", err)
 	}
 
@@ -166,12 +193,35 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 	}
 
 	// Verify it's the correct version and functional
-	return i.isNpdVersionCorrect()
+	if !i.isNpdVersionCorrect() {
+		return false
+	}
+
+	// Custom plugins must match the configuration and their recorded checksums
+	recorded, err := loadPluginChecksums()
+	if err != nil {
+		i.logger.Debugf("Failed to load NPD plugin checksums: %v", err)
+		return false
+	}
+	drift := pluginDrift(i.config, allPlugins(i.config), recorded, os.ReadFile)
+	for _, difference := range drift {
+		i.logger.Warnf("NPD plugin drift: %s", difference)
+	}
+	return len(drift) == 0
 }
 
 // Validate validates prerequisites before installing NPD
 func (i *Installer) Validate(ctx context.Context) error {
-
+	// Fail before touching the node when a plugin script is unreadable or does not match its checksum
+	for _, plugin := range i.config.Npd.CustomPlugins {
+		if _, err := loadPluginScript(plugin, os.ReadFile); err != nil {
+			return err
+		}
+	}
+	// GPU checks need the NVIDIA driver; without it they only report an unknown state
+	if i.config.Npd.GPUHealth.Enabled && !utils.BinaryExists("nvidia-smi") {
+		i.logger.Warn("npd.gpuHealth is enabled but nvidia-smi was not found; install the NVIDIA driver for GPU health checks")
+	}
 	return nil
 }
 
@@ -221,8 +271,8 @@ func (i *Installer) getNpdDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	// Construct the download URL based on the version
-	downloadURL := fmt.Sprintf(npdDownloadURL, npdVersion, npdVersion, arch)
+	// Construct the download URL based on the version, unless the version manifest lists it
+	downloadURL := i.config.GetArtifactURL("node-problem-detector", npdVersion, arch, fmt.Sprintf(npdDownloadURL, npdVersion, npdVersion, arch))
 	fileName := fmt.Sprintf(npdFileName, npdVersion)
 
 	return fileName, downloadURL, nil
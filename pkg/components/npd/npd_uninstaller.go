@@ -13,9 +13,9 @@ type UnInstaller struct {
 	logger *logrus.Logger
 }
 
-func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
 	return &UnInstaller{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -36,13 +36,21 @@ func (nu *UnInstaller) Execute(ctx context.Context) error {
 		nu.logger.Debugf("Failed to remove config %s: %v (may not exist)", npdConfigPath, err)
 	}
 
+	// Remove custom plugins and their recorded checksums
+	for _, err := range utils.RemoveDirectories([]string{npdPluginDir, npdPluginMonitorDir}, nu.logger) {
+		nu.logger.Debugf("Failed to remove NPD plugin directory: %v", err)
+	}
+	if err := utils.RunCleanupCommand(npdPluginChecksumsPath); err != nil {
+		nu.logger.Debugf("Failed to remove %s: %v (may not exist)", npdPluginChecksumsPath, err)
+	}
+
 	nu.logger.Info("Node Problem Detector uninstalled successfully")
 	return nil
 }
 
 func (nu *UnInstaller) IsCompleted(ctx context.Context) bool {
 	// Check if NPD is uninstalled
-	if !utils.FileExists(npdBinaryPath) && !utils.FileExists(npdConfigPath) {
+	if !utils.FileExists(npdBinaryPath) && !utils.FileExists(npdConfigPath) && !utils.DirectoryExists(npdPluginDir) {
 		return true
 	}
 	return false
@@ -0,0 +1,197 @@
+package npd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// How long to wait for NPD to publish its node conditions after the service starts
+	conditionWaitTimeout  = 90 * time.Second
+	conditionPollInterval = 10 * time.Second
+)
+
+// Verifier checks that Node Problem Detector actually reports its conditions on the Node object.
+// A missing kubeconfig or RBAC permission otherwise makes NPD fail silently.
+// Problems are logged as warnings and never fail bootstrap.
+type Verifier struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	kubectl       func(args ...string) (string, error)
+	fileExists    func(path string) bool
+	serviceActive func(name string) bool
+	readFile      func(path string) ([]byte, error)
+	timeout       time.Duration
+	pollInterval  time.Duration
+	clock         retry.Clock
+}
+
+// NewVerifier creates a new NPD verification step
+func NewVerifier(cfg *config.Config, logger *logrus.Logger) *Verifier {
+	return &Verifier{
+		config: cfg,
+		logger: logger,
+		kubectl: func(args ...string) (string, error) {
+			return utils.RunCommandWithOutput("kubectl", args...)
+		},
+		fileExists:    utils.FileExists,
+		serviceActive: utils.IsServiceActive,
+		readFile:      os.ReadFile,
+		timeout:       conditionWaitTimeout,
+		pollInterval:  conditionPollInterval,
+		clock:         retry.RealClock,
+	}
+}
+
+// GetName returns the step name
+func (v *Verifier) GetName() string {
+	return "NPD_Verification"
+}
+
+// Execute verifies NPD end to end and logs a warning for each problem found
+func (v *Verifier) Execute(ctx context.Context) error {
+	nodeName, err := os.Hostname()
+	if err != nil {
+		v.logger.Warnf("⚠️  Skipping Node Problem Detector verification: failed to get hostname: %v", err)
+		return nil
+	}
+	// Kubelet registers the node under the lower-cased hostname
+	nodeName = strings.ToLower(nodeName)
+
+	problems := v.verify(ctx, nodeName)
+	for _, problem := range problems {
+		v.logger.Warnf("⚠️  Node Problem Detector: %s", problem)
+	}
+	if len(problems) == 0 {
+		v.logger.Infof("✅ Node Problem Detector conditions are reported on node %s", nodeName)
+	}
+	return nil
+}
+
+// verify returns human readable problems preventing NPD from reporting node conditions
+func (v *Verifier) verify(ctx context.Context, nodeName string) []string {
+	kubeconfig := kubelet.KubeletKubeconfigPath
+	if !v.fileExists(kubeconfig) {
+		return []string{fmt.Sprintf("kubeconfig %s is missing, so NPD cannot reach the API server", kubeconfig)}
+	}
+
+	var problems []string
+	if !v.serviceActive("node-problem-detector") {
+		problems = append(problems, "service is not running; inspect it with 'journalctl -u node-problem-detector'")
+	}
+
+	// NPD reports through the kubelet identity, which needs to update its node status and create events
+	for _, permission := range [][]string{
+		{"patch", "nodes/" + nodeName, "--subresource=status"},
+		{"create", "events"},
+	} {
+		args := append([]string{"--kubeconfig", kubeconfig, "auth", "can-i"}, permission...)
+		output, err := v.kubectl(args...)
+		if strings.TrimSpace(output) != "yes" {
+			problems = append(problems, fmt.Sprintf("the node identity cannot %s (kubectl auth can-i: %s %v)",
+				strings.Join(permission, " "), strings.TrimSpace(output), err))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	data, err := v.readFile(npdConfigPath)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to read monitor configuration %s: %v", npdConfigPath, err)}
+	}
+	expected, err := expectedConditions(data)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if v.config != nil {
+		for _, plugin := range allPlugins(v.config) {
+			if !plugin.Temporary {
+				expected = append(expected, plugin.Condition)
+			}
+		}
+	}
+
+	missing := v.waitForConditions(ctx, kubeconfig, nodeName, expected)
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("conditions %s did not appear on node %s within %s; check the NPD "+
+			"--apiserver-override flag and 'journalctl -u node-problem-detector'", strings.Join(missing, ", "), nodeName, v.timeout))
+	}
+	return problems
+}
+
+// waitForConditions polls the Node object until every expected condition is present and returns those still missing
+func (v *Verifier) waitForConditions(ctx context.Context, kubeconfig, nodeName string, expected []string) []string {
+	deadline := v.clock.Now().Add(v.timeout)
+	for {
+		output, err := v.kubectl("--kubeconfig", kubeconfig, "get", "node", nodeName, "-o", "jsonpath={.status.conditions[*].type}")
+		var missing []string
+		if err != nil {
+			v.logger.Debugf("Failed to get node conditions: %v", err)
+			missing = expected
+		} else {
+			missing = missingConditions(expected, strings.Fields(output))
+		}
+		if len(missing) == 0 || !v.clock.Now().Add(v.pollInterval).Before(deadline) {
+			return missing
+		}
+		if err := retry.Sleep(ctx, v.clock, v.pollInterval); err != nil {
+			return missing
+		}
+	}
+}
+
+// expectedConditions returns the node condition types declared in an NPD system log monitor configuration
+func expectedConditions(monitorConfig []byte) ([]string, error) {
+	var monitor struct {
+		Conditions []struct {
+			Type string `json:"type"`
+		} `json:"conditions"`
+	}
+	if err := json.Unmarshal(monitorConfig, &monitor); err != nil {
+		return nil, fmt.Errorf("failed to parse NPD monitor configuration: %w", err)
+	}
+	types := make([]string, 0, len(monitor.Conditions))
+	for _, condition := range monitor.Conditions {
+		if condition.Type != "" {
+			types = append(types, condition.Type)
+		}
+	}
+	return types, nil
+}
+
+// missingConditions returns the expected condition types not present on the node
+func missingConditions(expected, present []string) []string {
+	found := make(map[string]bool, len(present))
+	for _, conditionType := range present {
+		found[conditionType] = true
+	}
+	var missing []string
+	for _, conditionType := range expected {
+		if !found[conditionType] {
+			missing = append(missing, conditionType)
+		}
+	}
+	return missing
+}
+
+// IsCompleted always returns false so NPD is verified on every bootstrap
+func (v *Verifier) IsCompleted(ctx context.Context) bool {
+	return false
+}
+
+// Validate validates prerequisites before verifying NPD
+func (v *Verifier) Validate(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package npd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+const kernelMonitorConfig = `{
+  "plugin": "kmsg",
+  "source": "kernel-monitor",
+  "conditions": [
+    {"type": "KernelDeadlock", "reason": "KernelHasNoDeadlock", "message": "kernel has no deadlock"},
+    {"type": "ReadonlyFilesystem", "reason": "FilesystemIsNotReadOnly", "message": "Filesystem is not read-only"}
+  ]
+}`
+
+func newTestVerifier(kubectl func(args ...string) (string, error)) *Verifier {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return &Verifier{
+		logger:        logger,
+		kubectl:       kubectl,
+		fileExists:    func(string) bool { return true },
+		serviceActive: func(string) bool { return true },
+		readFile:      func(string) ([]byte, error) { return []byte(kernelMonitorConfig), nil },
+		timeout:       conditionWaitTimeout,
+		pollInterval:  conditionPollInterval,
+		clock:         retry.NewFakeClock(time.Now()),
+	}
+}
+
+func TestExpectedConditions(t *testing.T) {
+	got, err := expectedConditions([]byte(kernelMonitorConfig))
+	if err != nil {
+		t.Fatalf("expectedConditions() unexpected error: %v", err)
+	}
+	if want := []string{"KernelDeadlock", "ReadonlyFilesystem"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expectedConditions() = %v, want %v", got, want)
+	}
+	if _, err := expectedConditions([]byte("{")); err == nil {
+		t.Error("expectedConditions() expected error for invalid JSON")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	allowed := func(args ...string) (string, error) {
+		if args[2] == "auth" {
+			return "yes", nil
+		}
+		return "MemoryPressure DiskPressure PIDPressure Ready KernelDeadlock ReadonlyFilesystem", nil
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		if problems := newTestVerifier(allowed).verify(context.Background(), "node1"); len(problems) != 0 {
+			t.Errorf("verify() = %v, want no problems", problems)
+		}
+	})
+
+	t.Run("missing kubeconfig", func(t *testing.T) {
+		verifier := newTestVerifier(allowed)
+		verifier.fileExists = func(string) bool { return false }
+		problems := verifier.verify(context.Background(), "node1")
+		if len(problems) != 1 || !strings.Contains(problems[0], "kubeconfig") {
+			t.Errorf("verify() = %v, want missing kubeconfig", problems)
+		}
+	})
+
+	t.Run("missing RBAC", func(t *testing.T) {
+		verifier := newTestVerifier(func(args ...string) (string, error) {
+			if args[2] == "auth" && args[4] == "create" {
+				return "no", errors.New("exit status 1")
+			}
+			return allowed(args...)
+		})
+		problems := verifier.verify(context.Background(), "node1")
+		if len(problems) != 1 || !strings.Contains(problems[0], "cannot create events") {
+			t.Errorf("verify() = %v, want RBAC problem", problems)
+		}
+	})
+
+	t.Run("conditions not reported", func(t *testing.T) {
+		verifier := newTestVerifier(func(args ...string) (string, error) {
+			if args[2] == "auth" {
+				return "yes", nil
+			}
+			return "Ready KernelDeadlock", nil
+		})
+		problems := verifier.verify(context.Background(), "node1")
+		if len(problems) != 1 || !strings.Contains(problems[0], "ReadonlyFilesystem") || strings.Contains(problems[0], "KernelDeadlock,") {
+			t.Errorf("verify() = %v, want ReadonlyFilesystem missing", problems)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package npd
+
+import (
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/patching"
+)
+
+// rebootRequiredPluginName is the built-in plugin raising the RebootRequired problem from the agent's patch report
+const rebootRequiredPluginName = "reboot-required"
+
+// rebootRequiredScript reports the OS updates waiting for a reboot.
+// {{REPORT}} is replaced with the path of the agent's patch report.
+const rebootRequiredScript = `#!/bin/sh
+# Generated by aks-flex-node: OS updates waiting for a reboot
+if [ -s {{REPORT}} ]; then
+    echo "Reboot required for: $(tr '\n' ';' < {{REPORT}})"
+    exit 1
+fi
+echo "No reboot required"
+exit 0
+`
+
+// patchingPlugins returns the NPD plugin raising the RebootRequired problem, or nil when the detection is disabled.
+// A pending reboot is reported as an event unless the node condition is enabled.
+func patchingPlugins(p config.PatchingConfig) []config.NPDPluginConfig {
+	if p.Disabled {
+		return nil
+	}
+	return []config.NPDPluginConfig{{
+		Name:      rebootRequiredPluginName,
+		Script:    strings.ReplaceAll(rebootRequiredScript, "{{REPORT}}", patching.ReportPath),
+		Condition: "RebootRequired",
+		Reason:    "OSUpdatesPendingReboot",
+		Temporary: !p.NodeCondition,
+	}}
+}
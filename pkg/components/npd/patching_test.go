@@ -0,0 +1,54 @@
+package npd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/patching"
+)
+
+func TestPatchingPlugins(t *testing.T) {
+	if plugins := patchingPlugins(config.PatchingConfig{Disabled: true}); plugins != nil {
+		t.Errorf("patchingPlugins() = %v with detection disabled, want none", plugins)
+	}
+
+	plugins := patchingPlugins(config.PatchingConfig{})
+	if len(plugins) != 1 || !plugins[0].Temporary {
+		t.Fatalf("patchingPlugins() = %+v, want a single temporary plugin", plugins)
+	}
+	if !strings.Contains(plugins[0].Script, patching.ReportPath) {
+		t.Errorf("reboot-required script does not read %s:\n%s", patching.ReportPath, plugins[0].Script)
+	}
+	if plugins := patchingPlugins(config.PatchingConfig{NodeCondition: true}); plugins[0].Temporary || plugins[0].Condition != "RebootRequired" {
+		t.Errorf("patchingPlugins() = %+v with nodeCondition, want the RebootRequired condition", plugins)
+	}
+}
+
+func TestRebootRequiredScript(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "reboot-required")
+	script := strings.ReplaceAll(rebootRequiredScript, "{{REPORT}}", report)
+	run := func() (int, string) {
+		output, err := exec.Command("sh", "-c", script).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), string(output)
+		}
+		if err != nil {
+			t.Fatalf("failed to run reboot-required script: %v", err)
+		}
+		return 0, string(output)
+	}
+
+	if code, _ := run(); code != 0 {
+		t.Errorf("reboot-required script exit code = %d without a report, want 0", code)
+	}
+	if err := os.WriteFile(report, []byte("linux-image-5.15.0-94-generic\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, output := run(); code != 1 || !strings.Contains(output, "linux-image-5.15.0-94-generic") {
+		t.Errorf("reboot-required script = %d, %q with a pending reboot, want 1 naming the package", code, output)
+	}
+}
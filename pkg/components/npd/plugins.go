@@ -0,0 +1,265 @@
+package npd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	defaultPluginInterval = "60s"
+	defaultPluginTimeout  = "10s"
+	// Longest plugin output NPD keeps as the condition message
+	pluginMaxOutputLength = 160
+)
+
+// pluginMonitor mirrors the NPD custom plugin monitor configuration format
+type pluginMonitor struct {
+	Plugin       string              `json:"plugin"`
+	PluginConfig pluginMonitorConfig `json:"pluginConfig"`
+	Source       string              `json:"source"`
+	Conditions   []pluginCondition   `json:"conditions"`
+	Rules        []pluginRule        `json:"rules"`
+}
+
+type pluginMonitorConfig struct {
+	InvokeInterval  string `json:"invoke_interval"`
+	Timeout         string `json:"timeout"`
+	MaxOutputLength int    `json:"max_output_length"`
+	Concurrency     int    `json:"concurrency"`
+}
+
+type pluginCondition struct {
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+type pluginRule struct {
+	Type      string `json:"type"`
+	Condition string `json:"condition,omitempty"` // Set for permanent rules only
+	Reason    string `json:"reason"`
+	Path      string `json:"path"`
+	Timeout   string `json:"timeout"`
+}
+
+// pluginMonitorData holds the variables of the plugin monitor template
+type pluginMonitorData struct {
+	Name            string
+	Interval        string
+	Timeout         string
+	MaxOutputLength int
+	Condition       string
+	Reason          string
+	ScriptPath      string
+	Temporary       bool
+}
+
+// pluginScriptPath returns where the script of a custom plugin is installed
+func pluginScriptPath(name string) string {
+	return filepath.Join(npdPluginDir, name+".sh")
+}
+
+// pluginMonitorPath returns where the monitor configuration of a custom plugin is installed
+func pluginMonitorPath(name string) string {
+	return filepath.Join(npdPluginMonitorDir, name+".json")
+}
+
+// pluginMonitorPaths returns the monitor configuration paths of all configured plugins
+func pluginMonitorPaths(plugins []config.NPDPluginConfig) []string {
+	paths := make([]string, 0, len(plugins))
+	for _, plugin := range plugins {
+		paths = append(paths, pluginMonitorPath(plugin.Name))
+	}
+	return paths
+}
+
+// renderPluginMonitor renders the NPD custom plugin monitor configuration running a plugin script
+func renderPluginMonitor(cfg *config.Config, plugin config.NPDPluginConfig) ([]byte, error) {
+	interval := plugin.Interval
+	if interval == "" {
+		interval = defaultPluginInterval
+	}
+	timeout := plugin.Timeout
+	if timeout == "" {
+		timeout = defaultPluginTimeout
+	}
+
+	// Temporary problems are reported as events and counted in problem_counter, without a condition
+	monitor, err := templates.Render(cfg, templates.NPDPluginMonitor, pluginMonitorData{
+		Name:            plugin.Name,
+		Interval:        interval,
+		Timeout:         timeout,
+		MaxOutputLength: pluginMaxOutputLength,
+		Condition:       plugin.Condition,
+		Reason:          plugin.Reason,
+		ScriptPath:      pluginScriptPath(plugin.Name),
+		Temporary:       plugin.Temporary,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render monitor configuration for plugin %s: %w", plugin.Name, err)
+	}
+	return []byte(monitor), nil
+}
+
+// loadPluginScript returns the script content of a plugin and verifies its checksum when one is configured
+func loadPluginScript(plugin config.NPDPluginConfig, readFile func(string) ([]byte, error)) ([]byte, error) {
+	script := []byte(plugin.Script)
+	if plugin.ScriptFile != "" {
+		data, err := readFile(plugin.ScriptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read script of plugin %s: %w", plugin.Name, err)
+		}
+		script = data
+	}
+	if plugin.SHA256 != "" && !strings.EqualFold(checksum(script), plugin.SHA256) {
+		return nil, fmt.Errorf("script of plugin %s has SHA-256 %s, expected %s", plugin.Name, checksum(script), plugin.SHA256)
+	}
+	return script, nil
+}
+
+// checksum returns the hex encoded SHA-256 digest of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// installPlugins installs the plugin scripts and monitor configurations, removes plugins no longer
+// configured and records the installed script checksums for drift detection
+func installPlugins(cfg *config.Config, plugins []config.NPDPluginConfig, logger *logrus.Logger) error {
+	installed := make(map[string]bool, len(plugins))
+	checksums := make(map[string]string, len(plugins))
+	if len(plugins) > 0 {
+		if err := utils.RunSystemCommand("mkdir", "-p", npdPluginDir, npdPluginMonitorDir); err != nil {
+			return fmt.Errorf("failed to create NPD plugin directories: %w", err)
+		}
+	}
+
+	for _, plugin := range plugins {
+		script, err := loadPluginScript(plugin, os.ReadFile)
+		if err != nil {
+			return err
+		}
+		monitor, err := renderPluginMonitor(cfg, plugin)
+		if err != nil {
+			return err
+		}
+
+		// Scripts are executed by NPD as root, so only root may modify them
+		if err := utils.WriteFileAtomicSystem(pluginScriptPath(plugin.Name), script, 0o755); err != nil {
+			return fmt.Errorf("failed to install script of plugin %s: %w", plugin.Name, err)
+		}
+		if err := utils.WriteFileAtomicSystem(pluginMonitorPath(plugin.Name), monitor, 0o644); err != nil {
+			return fmt.Errorf("failed to install monitor configuration of plugin %s: %w", plugin.Name, err)
+		}
+		installed[plugin.Name] = true
+		checksums[plugin.Name] = checksum(script)
+		logger.Infof("Installed NPD plugin %s reporting condition %s", plugin.Name, plugin.Condition)
+	}
+
+	// Remove plugins installed by a previous configuration
+	previous, err := loadPluginChecksums()
+	if err != nil {
+		logger.Warnf("Failed to read recorded NPD plugin checksums: %v", err)
+	}
+	for name := range previous {
+		if installed[name] {
+			continue
+		}
+		logger.Infof("Removing NPD plugin %s which is no longer configured", name)
+		removePlugin(name, logger)
+	}
+
+	return savePluginChecksums(checksums)
+}
+
+// removePlugin removes the script and monitor configuration of a plugin
+func removePlugin(name string, logger *logrus.Logger) {
+	for _, path := range []string{pluginScriptPath(name), pluginMonitorPath(name)} {
+		if err := utils.RunCleanupCommand(path); err != nil {
+			logger.Debugf("Failed to remove %s: %v (may not exist)", path, err)
+		}
+	}
+}
+
+// loadPluginChecksums returns the recorded script checksums by plugin name
+func loadPluginChecksums() (map[string]string, error) {
+	data, err := os.ReadFile(npdPluginChecksumsPath)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", npdPluginChecksumsPath, err)
+	}
+	checksums := map[string]string{}
+	if err := json.Unmarshal(data, &checksums); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", npdPluginChecksumsPath, err)
+	}
+	return checksums, nil
+}
+
+// savePluginChecksums records the installed script checksums, removing the record when no plugin is installed
+func savePluginChecksums(checksums map[string]string) error {
+	if len(checksums) == 0 {
+		return utils.RunCleanupCommand(npdPluginChecksumsPath)
+	}
+	data, err := json.MarshalIndent(checksums, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode NPD plugin checksums: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(npdPluginChecksumsPath)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(npdPluginChecksumsPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", npdPluginChecksumsPath, err)
+	}
+	return nil
+}
+
+// pluginDrift compares installed plugins with the recorded checksums and the desired configuration.
+// It returns a description of each difference, sorted for stable output.
+func pluginDrift(cfg *config.Config, plugins []config.NPDPluginConfig, recorded map[string]string, readFile func(string) ([]byte, error)) []string {
+	var drift []string
+	configured := make(map[string]bool, len(plugins))
+	for _, plugin := range plugins {
+		configured[plugin.Name] = true
+
+		installedScript, err := readFile(pluginScriptPath(plugin.Name))
+		if err != nil {
+			drift = append(drift, fmt.Sprintf("plugin %s is not installed", plugin.Name))
+			continue
+		}
+		actual := checksum(installedScript)
+		if want, ok := recorded[plugin.Name]; ok && actual != want {
+			drift = append(drift, fmt.Sprintf("script of plugin %s was modified on disk (SHA-256 %s, installed %s)", plugin.Name, actual, want))
+			continue
+		}
+		if desired, err := loadPluginScript(plugin, readFile); err == nil && checksum(desired) != actual {
+			drift = append(drift, fmt.Sprintf("script of plugin %s differs from the configuration", plugin.Name))
+			continue
+		}
+
+		installedMonitor, err := readFile(pluginMonitorPath(plugin.Name))
+		desiredMonitor, renderErr := renderPluginMonitor(cfg, plugin)
+		if err != nil || renderErr != nil || string(installedMonitor) != string(desiredMonitor) {
+			drift = append(drift, fmt.Sprintf("monitor configuration of plugin %s differs from the configuration", plugin.Name))
+		}
+	}
+	for name := range recorded {
+		if !configured[name] {
+			drift = append(drift, fmt.Sprintf("plugin %s is installed but no longer configured", name))
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
@@ -0,0 +1,184 @@
+package npd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+var raidPlugin = config.NPDPluginConfig{
+	Name:      "raid-health",
+	Script:    "#!/bin/sh\nexit 0\n",
+	Condition: "RAIDProblem",
+	Reason:    "RAIDDegraded",
+}
+
+func TestRenderPluginMonitor(t *testing.T) {
+	data, err := renderPluginMonitor(&config.Config{}, raidPlugin)
+	if err != nil {
+		t.Fatalf("renderPluginMonitor() unexpected error: %v", err)
+	}
+
+	var monitor pluginMonitor
+	if err := json.Unmarshal(data, &monitor); err != nil {
+		t.Fatalf("rendered monitor is not valid JSON: %v", err)
+	}
+	if monitor.Plugin != "custom" {
+		t.Errorf("plugin = %q, want custom", monitor.Plugin)
+	}
+	if monitor.PluginConfig.InvokeInterval != defaultPluginInterval || monitor.PluginConfig.Timeout != defaultPluginTimeout {
+		t.Errorf("pluginConfig = %+v, want default interval and timeout", monitor.PluginConfig)
+	}
+	if len(monitor.Conditions) != 1 || monitor.Conditions[0].Type != "RAIDProblem" || monitor.Conditions[0].Reason != "NoRAIDProblem" {
+		t.Errorf("conditions = %+v, want RAIDProblem with reason NoRAIDProblem", monitor.Conditions)
+	}
+	if len(monitor.Rules) != 1 {
+		t.Fatalf("rules = %+v, want a single rule", monitor.Rules)
+	}
+	rule := monitor.Rules[0]
+	if rule.Type != "permanent" || rule.Condition != "RAIDProblem" || rule.Reason != "RAIDDegraded" || rule.Path != pluginScriptPath("raid-health") {
+		t.Errorf("rule = %+v, want permanent RAIDProblem rule running the installed script", rule)
+	}
+
+	// Expected conditions of the verifier must include the plugin condition
+	conditions, err := expectedConditions(data)
+	if err != nil || len(conditions) != 1 || conditions[0] != "RAIDProblem" {
+		t.Errorf("expectedConditions() = %v, %v, want [RAIDProblem]", conditions, err)
+	}
+}
+
+func TestRenderTemporaryPluginMonitor(t *testing.T) {
+	plugin := raidPlugin
+	plugin.Temporary = true
+	data, err := renderPluginMonitor(&config.Config{}, plugin)
+	if err != nil {
+		t.Fatalf("renderPluginMonitor() unexpected error: %v", err)
+	}
+
+	var monitor pluginMonitor
+	if err := json.Unmarshal(data, &monitor); err != nil {
+		t.Fatalf("rendered monitor is not valid JSON: %v", err)
+	}
+	if len(monitor.Conditions) != 0 {
+		t.Errorf("conditions = %+v, want none for a temporary plugin", monitor.Conditions)
+	}
+	if len(monitor.Rules) != 1 || monitor.Rules[0].Type != "temporary" || monitor.Rules[0].Condition != "" || monitor.Rules[0].Reason != "RAIDDegraded" {
+		t.Errorf("rules = %+v, want a single temporary RAIDDegraded rule without condition", monitor.Rules)
+	}
+}
+
+func TestLoadPluginScript(t *testing.T) {
+	script := []byte("#!/bin/sh\nexit 1\n")
+	readFile := func(path string) ([]byte, error) {
+		if path == "/opt/health/raid.sh" {
+			return script, nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	fromFile := raidPlugin
+	fromFile.Script, fromFile.ScriptFile = "", "/opt/health/raid.sh"
+	if got, err := loadPluginScript(fromFile, readFile); err != nil || string(got) != string(script) {
+		t.Errorf("loadPluginScript() = %q, %v, want script file content", got, err)
+	}
+
+	fromFile.SHA256 = strings.ToUpper(checksum(script))
+	if _, err := loadPluginScript(fromFile, readFile); err != nil {
+		t.Errorf("loadPluginScript() with matching checksum unexpected error: %v", err)
+	}
+
+	fromFile.SHA256 = checksum([]byte("other"))
+	if _, err := loadPluginScript(fromFile, readFile); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("loadPluginScript() with wrong checksum error = %v, want checksum mismatch", err)
+	}
+
+	missing := raidPlugin
+	missing.Script, missing.ScriptFile = "", "/opt/health/missing.sh"
+	if _, err := loadPluginScript(missing, readFile); err == nil {
+		t.Error("loadPluginScript() with missing script file expected error")
+	}
+}
+
+func TestPluginDrift(t *testing.T) {
+	monitor, err := renderPluginMonitor(&config.Config{}, raidPlugin)
+	if err != nil {
+		t.Fatalf("renderPluginMonitor() unexpected error: %v", err)
+	}
+	installed := map[string][]byte{
+		pluginScriptPath("raid-health"):  []byte(raidPlugin.Script),
+		pluginMonitorPath("raid-health"): monitor,
+	}
+	readFile := func(files map[string][]byte) func(string) ([]byte, error) {
+		return func(path string) ([]byte, error) {
+			if data, ok := files[path]; ok {
+				return data, nil
+			}
+			return nil, fmt.Errorf("open %s: %w", path, os.ErrNotExist)
+		}
+	}
+	recorded := map[string]string{"raid-health": checksum([]byte(raidPlugin.Script))}
+
+	tests := []struct {
+		name     string
+		plugins  []config.NPDPluginConfig
+		recorded map[string]string
+		files    map[string][]byte
+		want     string
+	}{
+		{name: "in sync", plugins: []config.NPDPluginConfig{raidPlugin}, recorded: recorded, files: installed},
+		{name: "nothing configured", files: map[string][]byte{}},
+		{name: "not installed", plugins: []config.NPDPluginConfig{raidPlugin}, files: map[string][]byte{}, want: "is not installed"},
+		{
+			name:     "modified on disk",
+			plugins:  []config.NPDPluginConfig{raidPlugin},
+			recorded: recorded,
+			files: map[string][]byte{
+				pluginScriptPath("raid-health"):  []byte("#!/bin/sh\nexit 0 # edited\n"),
+				pluginMonitorPath("raid-health"): monitor,
+			},
+			want: "was modified on disk",
+		},
+		{
+			name: "configuration changed",
+			plugins: []config.NPDPluginConfig{func() config.NPDPluginConfig {
+				p := raidPlugin
+				p.Script = "#!/bin/sh\nexit 2\n"
+				return p
+			}()},
+			recorded: recorded,
+			files:    installed,
+			want:     "differs from the configuration",
+		},
+		{
+			name: "monitor changed",
+			plugins: []config.NPDPluginConfig{func() config.NPDPluginConfig {
+				p := raidPlugin
+				p.Interval = "5m"
+				return p
+			}()},
+			recorded: recorded,
+			files:    installed,
+			want:     "monitor configuration of plugin raid-health",
+		},
+		{name: "no longer configured", recorded: recorded, files: installed, want: "no longer configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift := pluginDrift(&config.Config{}, tt.plugins, tt.recorded, readFile(tt.files))
+			if tt.want == "" {
+				if len(drift) != 0 {
+					t.Errorf("pluginDrift() = %v, want no drift", drift)
+				}
+				return
+			}
+			if len(drift) != 1 || !strings.Contains(drift[0], tt.want) {
+				t.Errorf("pluginDrift() = %v, want a single difference containing %q", drift, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package npd
+
+import (
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// roleAssignmentPluginName is the built-in plugin raising the RoleAssignmentMissing problem from the agent's
+// role assignment report
+const roleAssignmentPluginName = "role-assignment-missing"
+
+// roleAssignmentScript reports the required role assignments the agent found removed.
+// {{REPORT}} is replaced with the path of the agent's role assignment report.
+const roleAssignmentScript = `#!/bin/sh
+# Generated by aks-flex-node: required role assignments removed from Azure
+if [ -s {{REPORT}} ]; then
+    echo "Role assignments missing: $(tr '\n' ';' < {{REPORT}})"
+    exit 1
+fi
+echo "All required role assignments exist"
+exit 0
+`
+
+// roleAssignmentPlugins returns the NPD plugin raising the RoleAssignmentMissing problem, or nil outside Arc mode
+// or when the check is disabled. A missing assignment is reported as an event unless the node condition is enabled.
+func roleAssignmentPlugins(cfg *config.Config) []config.NPDPluginConfig {
+	check := cfg.Agent.RoleAssignments
+	if !cfg.IsARCEnabled() || check.Disabled {
+		return nil
+	}
+	return []config.NPDPluginConfig{{
+		Name:      roleAssignmentPluginName,
+		Script:    strings.ReplaceAll(roleAssignmentScript, "{{REPORT}}", arc.RoleAssignmentReportPath),
+		Condition: "RoleAssignmentMissing",
+		Reason:    "RequiredRoleAssignmentRemoved",
+		Temporary: !check.NodeCondition,
+	}}
+}
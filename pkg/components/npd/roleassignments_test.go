@@ -0,0 +1,37 @@
+package npd
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRoleAssignmentPlugins(t *testing.T) {
+	arcConfig := func(check config.RoleAssignmentCheckConfig) *config.Config {
+		return &config.Config{
+			Azure: config.AzureConfig{Arc: &config.ArcConfig{Enabled: true}},
+			Agent: config.AgentConfig{RoleAssignments: check},
+		}
+	}
+
+	if plugins := roleAssignmentPlugins(&config.Config{}); plugins != nil {
+		t.Errorf("roleAssignmentPlugins() = %v without Arc, want none", plugins)
+	}
+	if plugins := roleAssignmentPlugins(arcConfig(config.RoleAssignmentCheckConfig{Disabled: true})); plugins != nil {
+		t.Errorf("roleAssignmentPlugins() = %v with the check disabled, want none", plugins)
+	}
+
+	plugins := roleAssignmentPlugins(arcConfig(config.RoleAssignmentCheckConfig{}))
+	if len(plugins) != 1 || !plugins[0].Temporary {
+		t.Fatalf("roleAssignmentPlugins() = %+v, want a single temporary plugin", plugins)
+	}
+	if !strings.Contains(plugins[0].Script, arc.RoleAssignmentReportPath) {
+		t.Errorf("role assignment script does not read %s:\n%s", arc.RoleAssignmentReportPath, plugins[0].Script)
+	}
+	plugins = roleAssignmentPlugins(arcConfig(config.RoleAssignmentCheckConfig{NodeCondition: true}))
+	if plugins[0].Temporary || plugins[0].Condition != "RoleAssignmentMissing" {
+		t.Errorf("roleAssignmentPlugins() = %+v with nodeCondition, want the RoleAssignmentMissing condition", plugins)
+	}
+}
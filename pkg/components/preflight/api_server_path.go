@@ -0,0 +1,337 @@
+package preflight
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/endpoints"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/dns"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	// latencySamples is the number of TCP connections timed to the API server
+	latencySamples = 5
+	// handshakeTimeout bounds the TLS handshake. The server's certificate chain is the first data sent in
+	// full-size packets, so a handshake that stalls after TCP connected points at dropped large packets.
+	handshakeTimeout = 10 * time.Second
+)
+
+// apiServerPathCheck verifies the path to the API server the node registers with, whether over the internet,
+// a private endpoint, VPN or ExpressRoute. Tunnel MTU problems otherwise show up as flaky node registration.
+type apiServerPathCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// Created lazily from the configured credentials; set directly in tests
+	mcClient managedClusterGetter
+
+	lookup    func(ctx context.Context, host string) ([]net.IP, error)
+	dial      func(ctx context.Context, network, address string) (net.Conn, error)
+	handshake func(ctx context.Context, conn net.Conn, host string) error
+	pathMTU   func(conn net.Conn) (int, error)
+	// routeInterface returns the interface and MTU of the local address the connection leaves from
+	routeInterface func(local net.IP) (string, int, error)
+	now            func() time.Time
+}
+
+// pathReport is what the check learned about the path to the API server
+type pathReport struct {
+	host      string
+	address   net.IP
+	private   bool
+	iface     string
+	ifaceMTU  int
+	pathMTU   int
+	latencies []time.Duration
+}
+
+func newAPIServerPathCheck(cfg *config.Config, logger *logrus.Logger) *apiServerPathCheck {
+	dialer := &net.Dialer{Timeout: endpointDialTimeout}
+	return &apiServerPathCheck{
+		config: cfg,
+		logger: logger,
+		lookup: func(ctx context.Context, host string) ([]net.IP, error) {
+			return dns.Resolver(cfg).LookupIP(ctx, "ip", host)
+		},
+		dial:           dialer.DialContext,
+		handshake:      tlsHandshake,
+		pathMTU:        socketPathMTU,
+		routeInterface: interfaceOf,
+		now:            time.Now,
+	}
+}
+
+// Name returns the check name
+func (c *apiServerPathCheck) Name() string {
+	return "APIServerPath"
+}
+
+// Run probes the API server's address, latency and path MTU and reports the problems found
+func (c *apiServerPathCheck) Run(ctx context.Context) error {
+	if c.config.GetAPIServerPathMode() == "skip" {
+		c.logger.Debug("preflight.apiServerPath.mode is 'skip', skipping API server path check")
+		return nil
+	}
+
+	server, err := c.serverAddress(ctx)
+	if err != nil {
+		return err
+	}
+	if server == "" {
+		c.logger.Debug("API server URL comes from the bootstrap token broker, skipping API server path check")
+		return nil
+	}
+
+	report, problems := c.probe(ctx, server)
+	if report != nil {
+		c.logReport(report)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if c.config.GetAPIServerPathMode() == "warn" {
+		for _, problem := range problems {
+			c.logger.Warnf("API server path: %s", problem)
+		}
+		c.logger.Warn("preflight.apiServerPath.mode is 'warn', continuing despite the problems above")
+		return nil
+	}
+	return fmt.Errorf("path to API server %s is not healthy: %s; set preflight.apiServerPath.mode to 'warn' to continue anyway",
+		server, strings.Join(problems, "; "))
+}
+
+// serverAddress returns the API server's host:port: node.kubelet.serverURL when set, otherwise the cluster's
+// private FQDN for private clusters or its public FQDN. It returns "" when neither is known before bootstrap.
+func (c *apiServerPathCheck) serverAddress(ctx context.Context) (string, error) {
+	if serverURL := c.config.Node.Kubelet.ServerURL; serverURL != "" {
+		u, err := url.Parse(serverURL)
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("invalid node.kubelet.serverURL %q", serverURL)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		return net.JoinHostPort(u.Hostname(), port), nil
+	}
+	if c.config.IsKubernetesCredentialConfigured() {
+		return "", nil
+	}
+
+	if c.mcClient == nil {
+		mcClient, err := newManagedClusterClient(c.config)
+		if err != nil {
+			return "", err
+		}
+		c.mcClient = mcClient
+	}
+	opCtx, cancel := auth.OperationContext(ctx, c.config)
+	defer cancel()
+	resp, err := c.mcClient.Get(opCtx, c.config.GetTargetClusterResourceGroup(), c.config.GetTargetClusterName(), nil)
+	if err != nil {
+		return "", azerrors.Wrap(fmt.Errorf("cannot read cluster %s to find its API server: %w", c.config.GetTargetClusterID(), err))
+	}
+	props := resp.Properties
+	if props == nil {
+		return "", fmt.Errorf("cluster %s returned no properties", c.config.GetTargetClusterID())
+	}
+	if access := props.APIServerAccessProfile; access != nil && access.EnablePrivateCluster != nil && *access.EnablePrivateCluster &&
+		props.PrivateFQDN != nil && *props.PrivateFQDN != "" {
+		return net.JoinHostPort(*props.PrivateFQDN, "443"), nil
+	}
+	if props.Fqdn == nil || *props.Fqdn == "" {
+		return "", fmt.Errorf("cluster %s has no API server FQDN", c.config.GetTargetClusterID())
+	}
+	return net.JoinHostPort(*props.Fqdn, "443"), nil
+}
+
+// probe resolves the server, times TCP connections to it and completes a TLS handshake to exercise
+// full-size packets. Problems are returned with their likely cause.
+func (c *apiServerPathCheck) probe(ctx context.Context, server string) (*pathReport, []string) {
+	host, port, _ := net.SplitHostPort(server)
+	report := &pathReport{host: host}
+
+	if ip := net.ParseIP(host); ip != nil {
+		report.address = ip
+	} else {
+		addrs, err := c.lookup(ctx, host)
+		if err != nil || len(addrs) == 0 {
+			return nil, []string{fmt.Sprintf("%s does not resolve - for private clusters, forward the privatelink zone to Azure DNS from the node's DNS server: %v", host, err)}
+		}
+		report.address = addrs[0]
+	}
+	report.private = len(endpoints.PublicAddresses([]net.IP{report.address})) == 0
+	address := net.JoinHostPort(report.address.String(), port)
+
+	// The first connection is kept for the TLS handshake
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+	for range latencySamples {
+		start := c.now()
+		sample, err := c.dial(ctx, "tcp", address)
+		if err != nil {
+			route := "the internet"
+			if report.private {
+				route = "the VPN or ExpressRoute circuit"
+			}
+			return report, []string{fmt.Sprintf("%s (%s) is unreachable on port %s - check routing, NSG and firewalls across %s: %v",
+				host, report.address, port, route, err)}
+		}
+		report.latencies = append(report.latencies, c.now().Sub(start))
+		if conn == nil {
+			conn = sample
+		} else {
+			_ = sample.Close()
+		}
+	}
+
+	var problems []string
+	if median := report.medianLatency(); median > c.config.GetAPIServerMaxLatency() {
+		problems = append(problems, fmt.Sprintf("median connect time %s exceeds %s - kubelet leases and watches time out on slow links; "+
+			"check for tunnel congestion or hairpinning through a distant hub", median.Round(time.Millisecond), c.config.GetAPIServerMaxLatency()))
+	}
+
+	if tcpAddr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		if iface, mtu, err := c.routeInterface(tcpAddr.IP); err == nil {
+			report.iface, report.ifaceMTU = iface, mtu
+		}
+	}
+
+	hsCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	if err := c.handshake(hsCtx, conn, host); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || isTimeout(err) {
+			return report, append(problems, fmt.Sprintf("TCP connects but the TLS handshake stalls - large packets from the API server are dropped. "+
+				"This is typical of a VPN or ExpressRoute tunnel with a smaller MTU where ICMP 'fragmentation needed' is blocked; "+
+				"lower the MTU of %s, enable TCP MSS clamping on the tunnel, or allow ICMP type 3 code 4 through the firewalls", report.interfaceName()))
+		}
+		return report, append(problems, fmt.Sprintf("TLS handshake with %s failed - a TLS-inspecting proxy or firewall may be in the path: %v", host, err))
+	}
+
+	if mtu, err := c.pathMTU(conn); err == nil {
+		report.pathMTU = mtu
+		if mtu < c.config.GetAPIServerMinPathMTU() {
+			problems = append(problems, fmt.Sprintf("path MTU %d is below %d - lower the MTU of %s or the pod network to fit the tunnel",
+				mtu, c.config.GetAPIServerMinPathMTU(), report.interfaceName()))
+		}
+	} else {
+		c.logger.Debugf("Could not read the path MTU to %s: %v", host, err)
+	}
+	return report, problems
+}
+
+// logReport logs the path to the API server
+func (c *apiServerPathCheck) logReport(r *pathReport) {
+	kind := "public"
+	if r.private {
+		kind = "private (private endpoint, VPN or ExpressRoute)"
+	}
+	c.logger.Infof("API server %s resolves to %s address %s", r.host, kind, r.address)
+	if r.iface != "" {
+		c.logger.Infof("Traffic to the API server leaves through %s (MTU %d)", r.iface, r.ifaceMTU)
+	}
+	if len(r.latencies) > 0 {
+		c.logger.Infof("TCP connect time over %d samples: median %s, max %s",
+			len(r.latencies), r.medianLatency().Round(time.Millisecond), slices.Max(r.latencies).Round(time.Millisecond))
+	}
+	if r.pathMTU > 0 {
+		if r.ifaceMTU > 0 && r.pathMTU < r.ifaceMTU {
+			c.logger.Infof("Path MTU to the API server is %d, below the interface MTU; path MTU discovery is working", r.pathMTU)
+		} else {
+			c.logger.Infof("Path MTU to the API server is %d", r.pathMTU)
+		}
+	}
+}
+
+// medianLatency returns the median of the connect time samples
+func (r *pathReport) medianLatency() time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(r.latencies)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// interfaceName returns the outgoing interface for messages, or a generic description when unknown
+func (r *pathReport) interfaceName() string {
+	if r.iface == "" {
+		return "the tunnel interface"
+	}
+	return r.iface
+}
+
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// tlsHandshake completes a TLS handshake over conn. No request is sent and the certificate is not trusted
+// for anything, so it is not verified; the handshake only makes the server send full-size packets.
+func tlsHandshake(ctx context.Context, conn net.Conn, host string) error {
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	return tlsConn.HandshakeContext(ctx)
+}
+
+// socketPathMTU reads the kernel's path MTU for a connected TCP socket, which includes what path MTU
+// discovery learned during the TLS handshake
+func socketPathMTU(conn net.Conn) (int, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return 0, fmt.Errorf("not a TCP connection")
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	level, opt := syscall.IPPROTO_IP, syscall.IP_MTU
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_MTU
+	}
+	var mtu int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		mtu, sockErr = syscall.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		return 0, err
+	}
+	return mtu, sockErr
+}
+
+// interfaceOf returns the name and MTU of the interface that holds the local address
+func interfaceOf(local net.IP) (string, int, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", 0, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return iface.Name, iface.MTU, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("no interface has address %s", local)
+}
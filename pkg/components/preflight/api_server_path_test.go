@@ -0,0 +1,120 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestAPIServerPathCheck(t *testing.T) {
+	privateCluster := armcontainerservice.ManagedCluster{Properties: &armcontainerservice.ManagedClusterProperties{
+		Fqdn:                   to.Ptr("test-cluster-abc.hcp.eastus.azmk8s.io"),
+		PrivateFQDN:            to.Ptr("test-cluster-abc.privatelink.eastus.azmk8s.io"),
+		APIServerAccessProfile: &armcontainerservice.ManagedClusterAPIServerAccessProfile{EnablePrivateCluster: to.Ptr(true)},
+	}}
+
+	tests := []struct {
+		name         string
+		serverURL    string
+		mode         string
+		latency      time.Duration
+		dialErr      error
+		handshakeErr error
+		mtu          int
+		noResolve    bool
+		wantErr      string
+		wantHost     string
+	}{
+		{name: "healthy private cluster", wantHost: "test-cluster-abc.privatelink.eastus.azmk8s.io"},
+		{name: "server URL wins over the cluster", serverURL: "https://10.1.0.4:6443", wantHost: "10.1.0.4"},
+		{name: "slow link", latency: 400 * time.Millisecond, wantErr: "median connect time 420ms exceeds 250ms"},
+		{name: "unreachable", dialErr: errors.New("i/o timeout"), wantErr: "across the VPN or ExpressRoute circuit"},
+		{name: "mtu blackhole", handshakeErr: context.DeadlineExceeded, wantErr: "TLS handshake stalls"},
+		{name: "tls interception", handshakeErr: errors.New("remote error: tls: handshake failure"), wantErr: "TLS-inspecting proxy"},
+		{name: "small path mtu", mtu: 1200, wantErr: "path MTU 1200 is below 1280"},
+		{name: "private zone not forwarded", noResolve: true, wantErr: "does not resolve"},
+		{name: "warn mode continues", mode: "warn", mtu: 1200},
+		{name: "skip mode", mode: "skip", dialErr: errors.New("i/o timeout")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(homeTenant)
+			cfg.Node.Kubelet.ServerURL = tt.serverURL
+			cfg.Preflight.APIServerPath = &config.APIServerPathConfig{Mode: tt.mode}
+			check := newAPIServerPathCheck(cfg, newTestLogger())
+			check.mcClient = &fakeClusterGetter{cluster: privateCluster}
+
+			var dialed string
+			clock := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+			check.now = func() time.Time { return clock }
+			check.lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+				if tt.noResolve {
+					return nil, errors.New("no such host")
+				}
+				return []net.IP{net.ParseIP("10.1.0.4")}, nil
+			}
+			check.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				if tt.dialErr != nil {
+					return nil, tt.dialErr
+				}
+				dialed = address
+				clock = clock.Add(tt.latency + 20*time.Millisecond)
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+			var handshakeHost string
+			check.handshake = func(ctx context.Context, conn net.Conn, host string) error {
+				handshakeHost = host
+				return tt.handshakeErr
+			}
+			check.pathMTU = func(conn net.Conn) (int, error) {
+				if tt.mtu != 0 {
+					return tt.mtu, nil
+				}
+				return 1500, nil
+			}
+
+			err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if tt.wantHost != "" && handshakeHost != tt.wantHost {
+				t.Errorf("handshake host = %q, want %q", handshakeHost, tt.wantHost)
+			}
+			if tt.serverURL != "" && dialed != "10.1.0.4:6443" {
+				t.Errorf("dialed %q, want the server URL's port", dialed)
+			}
+		})
+	}
+}
+
+func TestAPIServerPathSkipsBrokeredServer(t *testing.T) {
+	cfg := newTestConfig(homeTenant)
+	cfg.Azure.BootstrapToken = &config.BootstrapTokenConfig{Token: "abcdef.0123456789abcdef"}
+	check := newAPIServerPathCheck(cfg, newTestLogger())
+	getter := &fakeClusterGetter{}
+	check.mcClient = getter
+
+	if err := check.Run(context.Background()); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if getter.called {
+		t.Error("expected the cluster not to be read with bootstrap token authentication")
+	}
+}
@@ -0,0 +1,66 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/prerequisites"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// prerequisitesCheck verifies, before anything is created, that the credential can read the resources declared
+// in azure.prerequisites, and reports which of them the AzurePrerequisites step will deploy
+type prerequisitesCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// Created lazily from the configured credentials; set directly in tests
+	client prerequisites.Client
+	// plan is what the deployment creates, known once the check ran
+	plan *prerequisites.Plan
+}
+
+func newPrerequisitesCheck(cfg *config.Config, logger *logrus.Logger) *prerequisitesCheck {
+	return &prerequisitesCheck{config: cfg, logger: logger}
+}
+
+// Name returns the check name
+func (c *prerequisitesCheck) Name() string {
+	return "AzurePrerequisites"
+}
+
+// Run checks which prerequisites exist; it is a no-op unless azure.prerequisites.deploy is set
+func (c *prerequisitesCheck) Run(ctx context.Context) error {
+	if !c.config.IsPrerequisitesDeployEnabled() {
+		c.logger.Debug("Deploying Azure prerequisites is not enabled, skipping prerequisites check")
+		return nil
+	}
+	if c.client == nil {
+		client, err := prerequisites.NewClientForConfig(ctx, c.config, c.logger)
+		if err != nil {
+			return err
+		}
+		c.client = client
+	}
+
+	plan, err := prerequisites.Check(ctx, c.client, prerequisites.NewSpec(c.config))
+	if err != nil {
+		return azerrors.Wrap(fmt.Errorf("cannot read the Azure prerequisites - grant the credential Contributor on the subscription and on the resource groups of the DNS zones: %w", err))
+	}
+	c.plan = &plan
+	if plan.Empty() {
+		c.logger.Info("All Azure prerequisites exist")
+	} else {
+		c.logger.Infof("Azure prerequisites to deploy after preflight: %s", plan)
+	}
+	return nil
+}
+
+// zoneLinksPending reports whether private DNS zone links are still to be deployed, so private endpoints
+// cannot resolve to private addresses yet
+func (c *prerequisitesCheck) zoneLinksPending() bool {
+	return c.plan != nil && len(c.plan.PrivateDNSZoneIDs) > 0
+}
@@ -0,0 +1,88 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// fakePrerequisitesClient reports every resource as missing and fails reads with err
+type fakePrerequisitesClient struct {
+	err         error
+	deployments int
+}
+
+func (f *fakePrerequisitesClient) ResourceGroupExists(ctx context.Context, name string) (bool, error) {
+	return false, f.err
+}
+
+func (f *fakePrerequisitesClient) ResourceExists(ctx context.Context, id, apiVersion string) (bool, error) {
+	return false, f.err
+}
+
+func (f *fakePrerequisitesClient) DeployAtSubscription(ctx context.Context, name, location string, template, parameters map[string]any) error {
+	f.deployments++
+	return nil
+}
+
+func TestPrerequisitesCheck(t *testing.T) {
+	const zoneID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/dns-rg/providers/Microsoft.Network/privateDnsZones/privatelink.his.arc.azure.com"
+	newConfig := func() *config.Config {
+		cfg := newPrivateLinkTestConfig(false)
+		cfg.Azure.Prerequisites = &config.PrerequisitesConfig{
+			Deploy: true, ResourceGroup: "site-rg", Location: "westeurope",
+			VirtualNetworkID:  "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/net-rg/providers/Microsoft.Network/virtualNetworks/edge-vnet",
+			PrivateDNSZoneIDs: []string{zoneID},
+		}
+		return cfg
+	}
+
+	t.Run("unreadable prerequisites fail", func(t *testing.T) {
+		check := newPrerequisitesCheck(newConfig(), newTestLogger())
+		check.client = &fakePrerequisitesClient{err: errors.New("AuthorizationFailed")}
+		if err := check.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot read the Azure prerequisites") {
+			t.Fatalf("Run() error = %v, want the prerequisites to be unreadable", err)
+		}
+	})
+
+	t.Run("pending zone links only warn about private endpoints", func(t *testing.T) {
+		cfg := newConfig()
+		client := &fakePrerequisitesClient{}
+		prerequisites := newPrerequisitesCheck(cfg, newTestLogger())
+		prerequisites.client = client
+		if err := prerequisites.Run(context.Background()); err != nil {
+			t.Fatalf("Run() unexpected error: %v", err)
+		}
+		if client.deployments != 0 {
+			t.Fatalf("preflight deployed %d times, want nothing created", client.deployments)
+		}
+
+		endpoints := newPrivateEndpointCheck(cfg, newTestLogger())
+		endpoints.zoneLinksPending = prerequisites.zoneLinksPending
+		endpoints.lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("20.0.0.1")}, nil
+		}
+		endpoints.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			_ = server.Close()
+			return client, nil
+		}
+		if err := endpoints.Run(context.Background()); err != nil {
+			t.Errorf("Run() error = %v, want public resolution to be tolerated until the zone links exist", err)
+		}
+	})
+
+	t.Run("disabled deployment is skipped", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.Azure.Prerequisites.Deploy = false
+		check := newPrerequisitesCheck(cfg, newTestLogger())
+		check.client = &fakePrerequisitesClient{err: errors.New("must not be called")}
+		if err := check.Run(context.Background()); err != nil || check.zoneLinksPending() {
+			t.Errorf("Run() = %v, zoneLinksPending() = %v, want the check skipped", err, check.zoneLinksPending())
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package preflight
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ca_trust"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// caTrustCheck reads the certificates in caTrust before the CATrust step changes the host's trust store, so
+// unreadable or malformed certificates fail here, and has the agent's Azure clients trust them for the checks
+// that follow
+type caTrustCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// trustStoreDir detects the OS trust store; replaced in tests
+	trustStoreDir func() (string, error)
+	// collect gathers the configured certificates; replaced in tests
+	collect func(ctx context.Context, cfg *config.Config, logger *logrus.Logger) ([]*x509.Certificate, error)
+	// trust makes the Azure clients trust the certificates; replaced in tests
+	trust func(certs []*x509.Certificate)
+}
+
+func newCATrustCheck(cfg *config.Config, logger *logrus.Logger) *caTrustCheck {
+	return &caTrustCheck{
+		config:        cfg,
+		logger:        logger,
+		trustStoreDir: ca_trust.TrustStoreDir,
+		collect:       ca_trust.CollectCertificates,
+		trust:         auth.TrustCertificates,
+	}
+}
+
+// Name returns the check name
+func (c *caTrustCheck) Name() string {
+	return "CATrust"
+}
+
+// Run checks the OS has a supported trust store and collects the configured CA certificates; it is a no-op
+// unless caTrust is set
+func (c *caTrustCheck) Run(ctx context.Context) error {
+	if !c.config.IsCATrustConfigured() {
+		c.logger.Debug("CA trust is not configured, skipping CA trust check")
+		return nil
+	}
+	if _, err := c.trustStoreDir(); err != nil {
+		return err
+	}
+	certs, err := c.collect(ctx, c.config, c.logger)
+	if err != nil {
+		return fmt.Errorf("cannot read the caTrust certificates: %w", err)
+	}
+	c.trust(certs)
+	c.logger.Infof("%d custom CA certificate(s) are trusted by Azure clients and installed after preflight", len(certs))
+	return nil
+}
@@ -0,0 +1,71 @@
+package preflight
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestCATrustCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured bool
+		collectErr error
+		storeErr   error
+		wantErr    string
+		wantTrust  bool
+	}{
+		{name: "not configured is skipped"},
+		{name: "collected certificates are trusted", configured: true, wantTrust: true},
+		{
+			name:       "unreadable certificates fail",
+			configured: true,
+			collectErr: errors.New("failed to read /etc/ssl/contoso-root.pem: no such file or directory"),
+			wantErr:    "cannot read the caTrust certificates: failed to read /etc/ssl/contoso-root.pem",
+		},
+		{
+			name:       "unsupported trust store fails",
+			configured: true,
+			storeErr:   errors.New("caTrust is not supported on this OS"),
+			wantErr:    "caTrust is not supported on this OS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("")
+			if tt.configured {
+				cfg.CATrust.CertificateFiles = []string{"/etc/ssl/contoso-root.pem"}
+			}
+			trusted := false
+			check := newCATrustCheck(cfg, newTestLogger())
+			check.trustStoreDir = func() (string, error) {
+				return "/usr/local/share/ca-certificates", tt.storeErr
+			}
+			check.collect = func(ctx context.Context, cfg *config.Config, logger *logrus.Logger) ([]*x509.Certificate, error) {
+				return []*x509.Certificate{{}}, tt.collectErr
+			}
+			check.trust = func(certs []*x509.Certificate) { trusted = true }
+
+			err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if trusted != tt.wantTrust {
+				t.Errorf("trusted = %v, want %v", trusted, tt.wantTrust)
+			}
+		})
+	}
+}
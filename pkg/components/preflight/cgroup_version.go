@@ -0,0 +1,62 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroup"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// cgroupV1UnsupportedVersion is the first kubelet release that refuses to start on a cgroup v1 host by default
+var cgroupV1UnsupportedVersion = version.MustParseGeneric("1.35.0")
+
+// cgroupVersionCheck detects hosts still running the legacy (v1) or hybrid cgroup hierarchy. Kubernetes has
+// deprecated cgroup v1, so those hosts are warned to migrate, and fail when the kubelet would not start on them.
+type cgroupVersionCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// detect returns the host's cgroup mode; replaced in tests
+	detect func() (cgroup.Mode, error)
+}
+
+func newCgroupVersionCheck(cfg *config.Config, logger *logrus.Logger) *cgroupVersionCheck {
+	return &cgroupVersionCheck{config: cfg, logger: logger, detect: cgroup.Detect}
+}
+
+// Name returns the check name
+func (c *cgroupVersionCheck) Name() string {
+	return "CgroupVersion"
+}
+
+// Run warns about cgroup v1 hosts, or fails when the configured kubelet does not support them and the host is
+// not set to migrate
+func (c *cgroupVersionCheck) Run(ctx context.Context) error {
+	mode, err := c.detect()
+	if err != nil {
+		c.logger.Warnf("⚠️  Could not detect the cgroup version of this host: %v", err)
+		return nil
+	}
+	if mode.IsUnified() {
+		c.logger.Debug("Host runs the unified (v2) cgroup hierarchy")
+		return nil
+	}
+	if c.config.Node.Cgroup.MigrateToV2 {
+		c.logger.Infof("Host runs the %s cgroup hierarchy; it is switched to cgroup v2 and rebooted during bootstrap", mode)
+		return nil
+	}
+
+	kubeletVersion, err := version.ParseGeneric(c.config.GetKubernetesVersion())
+	if err == nil && kubeletVersion.AtLeast(cgroupV1UnsupportedVersion) {
+		return fmt.Errorf("host runs the %s cgroup hierarchy, which kubelet %s does not support; set node.cgroup.migrateToV2 "+
+			"to switch it to cgroup v2 with a reboot, or add %s to the kernel command line yourself",
+			mode, c.config.GetKubernetesVersion(), cgroup.KernelParameter)
+	}
+	c.logger.Warnf("⚠️  Host runs the %s cgroup hierarchy, which Kubernetes has deprecated and kubelet 1.35 no longer "+
+		"supports; set node.cgroup.migrateToV2 to switch it to cgroup v2 with a reboot", mode)
+	return nil
+}
@@ -0,0 +1,52 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroup"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestCgroupVersionCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        cgroup.Mode
+		detectErr   error
+		version     string
+		migrateToV2 bool
+		wantErr     string
+	}{
+		{name: "unified host passes", mode: cgroup.ModeUnified, version: "1.35.0"},
+		{name: "v1 host with older kubelet is warned", mode: cgroup.ModeLegacy, version: "1.34.2"},
+		{name: "hybrid host with kubelet 1.35 fails", mode: cgroup.ModeHybrid, version: "1.35.0", wantErr: "migrateToV2"},
+		{name: "v1 host set to migrate passes", mode: cgroup.ModeLegacy, version: "1.35.0", migrateToV2: true},
+		{name: "detection failure is only warned", detectErr: errors.New("not mounted"), version: "1.35.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Kubernetes: config.KubernetesConfig{Version: tt.version},
+				Node:       config.NodeConfig{Cgroup: config.CgroupConfig{MigrateToV2: tt.migrateToV2}},
+			}
+			check := newCgroupVersionCheck(cfg, logrus.New())
+			check.detect = func() (cgroup.Mode, error) { return tt.mode, tt.detectErr }
+
+			err := check.Run(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Run() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	// maxKubeletMinorSkew is how many minor versions the kubelet may lag behind the API server
+	maxKubeletMinorSkew = 3
+
+	// minCNIPluginsVersion is the oldest CNI plugins release supported by current container runtimes
+	minCNIPluginsVersion = "1.0.0"
+)
+
+// clusterCompatibilityCheck reads the target cluster's version and network profile and verifies the
+// node's kubelet, CNI and host network can work with it
+type clusterCompatibilityCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// Created lazily from the configured credentials; set directly in tests
+	mcClient managedClusterGetter
+	// interfaceAddrs lists the host's addresses; replaced in tests
+	interfaceAddrs func() ([]net.Addr, error)
+}
+
+func newClusterCompatibilityCheck(cfg *config.Config, logger *logrus.Logger) *clusterCompatibilityCheck {
+	return &clusterCompatibilityCheck{config: cfg, logger: logger, interfaceAddrs: net.InterfaceAddrs}
+}
+
+// Name returns the check name
+func (c *clusterCompatibilityCheck) Name() string {
+	return "ClusterCompatibility"
+}
+
+// Run compares the node configuration with the cluster; bootstrap token and node certificate setups have no
+// Azure credential to read the cluster with, so the check is skipped for them
+func (c *clusterCompatibilityCheck) Run(ctx context.Context) error {
+	if c.config.IsKubernetesCredentialConfigured() {
+		c.logger.Debug("Kubernetes credential authentication configured, skipping cluster compatibility check")
+		return nil
+	}
+
+	if c.mcClient == nil {
+		mcClient, err := newManagedClusterClient(c.config)
+		if err != nil {
+			return err
+		}
+		c.mcClient = mcClient
+	}
+
+	clusterName := c.config.GetTargetClusterName()
+	opCtx, cancel := auth.OperationContext(ctx, c.config)
+	defer cancel()
+	resp, err := c.mcClient.Get(opCtx, c.config.GetTargetClusterResourceGroup(), clusterName, nil)
+	if err != nil {
+		return azerrors.Wrap(fmt.Errorf("cannot read cluster %s to check compatibility: %w", c.config.GetTargetClusterID(), err))
+	}
+	if resp.Properties == nil {
+		return fmt.Errorf("cluster %s returned no properties", c.config.GetTargetClusterID())
+	}
+
+	if profile := resp.Properties.NetworkProfile; profile != nil && profile.OutboundType != nil {
+		c.logger.Infof("Cluster %s uses outbound type %s; this node egresses through its own network and must reach the API server directly",
+			clusterName, *profile.OutboundType)
+	}
+
+	nodeNets, err := c.hostNetworks()
+	if err != nil {
+		c.logger.Warnf("Could not list host addresses, skipping node network overlap checks: %v", err)
+	}
+
+	problems := clusterIncompatibilities(c.config, resp.Properties, nodeNets)
+	if len(problems) == 0 {
+		c.logger.Infof("Node configuration is compatible with cluster %s", clusterName)
+		return nil
+	}
+
+	if c.config.GetClusterCompatibilityMode() == "warn" {
+		for _, problem := range problems {
+			c.logger.Warnf("Cluster compatibility: %s", problem)
+		}
+		c.logger.Warn("preflight.clusterCompatibility is 'warn', continuing despite the problems above")
+		return nil
+	}
+	return fmt.Errorf("node is not compatible with cluster %s: %s; set preflight.clusterCompatibility to 'warn' to continue anyway",
+		clusterName, strings.Join(problems, "; "))
+}
+
+// newManagedClusterClient creates a client for the target cluster with the cluster tenant's credential
+func newManagedClusterClient(cfg *config.Config) (managedClusterGetter, error) {
+	clusterCred, err := auth.NewAuthProvider().ClusterCredential(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster tenant credential: %w", err)
+	}
+	mcClient, err := armcontainerservice.NewManagedClustersClient(cfg.GetTargetClusterSubscriptionID(), clusterCred, auth.ARMClientOptions(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed clusters client: %w", err)
+	}
+	return mcClient, nil
+}
+
+// hostNetworks returns the networks of the host's global unicast addresses
+func (c *clusterCompatibilityCheck) hostNetworks() ([]*net.IPNet, error) {
+	addrs, err := c.interfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var nets []*net.IPNet
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// clusterIncompatibilities lists every reason the node configuration cannot work with the cluster
+func clusterIncompatibilities(cfg *config.Config, cluster *armcontainerservice.ManagedClusterProperties, nodeNets []*net.IPNet) []string {
+	var problems []string
+
+	controlPlane := cluster.CurrentKubernetesVersion
+	if controlPlane == nil {
+		controlPlane = cluster.KubernetesVersion
+	}
+	if controlPlane != nil {
+		if problem := versionSkew(cfg.GetKubernetesVersion(), *controlPlane); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if cfg.CNI.Version != "" {
+		if v, err := version.ParseGeneric(cfg.CNI.Version); err == nil && v.LessThan(version.MustParseGeneric(minCNIPluginsVersion)) {
+			problems = append(problems, fmt.Sprintf("CNI plugins %s are older than %s, the oldest release supported with current container runtimes; raise cni.version",
+				cfg.CNI.Version, minCNIPluginsVersion))
+		}
+	}
+
+	profile := cluster.NetworkProfile
+	if profile == nil {
+		return problems
+	}
+
+	if problem := networkPluginMismatch(profile); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	if profile.DNSServiceIP != nil && cfg.Node.Kubelet.DNSServiceIP != "" && *profile.DNSServiceIP != cfg.Node.Kubelet.DNSServiceIP {
+		problems = append(problems, fmt.Sprintf("node.kubelet.dnsServiceIP is %s but the cluster DNS service is %s; pods on this node could not resolve names",
+			cfg.Node.Kubelet.DNSServiceIP, *profile.DNSServiceIP))
+	}
+
+	problems = append(problems, ipFamilyMismatches(cfg, profile)...)
+
+	var bridgeNets []*net.IPNet
+	for _, subnet := range []string{cni.BridgePodSubnet, cni.BridgePodSubnetIPv6} {
+		_, bridgeNet, _ := net.ParseCIDR(subnet)
+		bridgeNets = append(bridgeNets, bridgeNet)
+	}
+	// Dual-stack clusters list a range per family; the single value is the first of them
+	ranges := []struct {
+		name  string
+		cidrs []string
+	}{
+		{"service CIDR", cidrList(profile.ServiceCidr, profile.ServiceCidrs)},
+		{"pod CIDR", cidrList(profile.PodCidr, profile.PodCidrs)},
+	}
+	for _, r := range ranges {
+		for _, cidr := range r.cidrs {
+			_, clusterNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			for _, nodeNet := range nodeNets {
+				if overlaps(clusterNet, nodeNet) {
+					problems = append(problems, fmt.Sprintf("host network %s overlaps the cluster %s %s; traffic to the host would be routed into the cluster",
+						nodeNet, r.name, clusterNet))
+				}
+			}
+			for _, bridgeNet := range bridgeNets {
+				if r.name == "service CIDR" && overlaps(clusterNet, bridgeNet) {
+					problems = append(problems, fmt.Sprintf("the bridge CNI pod subnet %s overlaps the cluster service CIDR %s", bridgeNet, clusterNet))
+				}
+			}
+		}
+	}
+	for _, nodeNet := range nodeNets {
+		for _, bridgeNet := range bridgeNets {
+			if overlaps(bridgeNet, nodeNet) {
+				problems = append(problems, fmt.Sprintf("host network %s overlaps the bridge CNI pod subnet %s", nodeNet, bridgeNet))
+			}
+		}
+	}
+
+	return problems
+}
+
+// cidrList returns the cluster's ranges of one kind without duplicates
+func cidrList(single *string, list []*string) []string {
+	var cidrs []string
+	seen := map[string]bool{}
+	for _, cidr := range append([]*string{single}, list...) {
+		if cidr != nil && *cidr != "" && !seen[*cidr] {
+			seen[*cidr] = true
+			cidrs = append(cidrs, *cidr)
+		}
+	}
+	return cidrs
+}
+
+// ipFamilyMismatches compares node.network.ipFamilies with the cluster's IP families. A node must take part
+// in every family of the cluster, with the same primary family, for its pods to reach services of each family.
+func ipFamilyMismatches(cfg *config.Config, profile *armcontainerservice.NetworkProfile) []string {
+	var clusterFamilies []string
+	for _, family := range profile.IPFamilies {
+		if family != nil {
+			clusterFamilies = append(clusterFamilies, string(*family))
+		}
+	}
+	if len(clusterFamilies) == 0 {
+		// Clusters created before dual-stack support report no families and are IPv4 only
+		clusterFamilies = []string{config.IPFamilyIPv4}
+	}
+	nodeFamilies := cfg.GetIPFamilies()
+	if strings.Join(nodeFamilies, ",") == strings.Join(clusterFamilies, ",") {
+		return nil
+	}
+	if len(nodeFamilies) == len(clusterFamilies) && len(nodeFamilies) == 2 {
+		return []string{fmt.Sprintf("node.network.ipFamilies lists %s first but the cluster's primary IP family is %s; list the families in the cluster's order",
+			nodeFamilies[0], clusterFamilies[0])}
+	}
+	return []string{fmt.Sprintf("node.network.ipFamilies is %s but the cluster uses %s; set node.network.ipFamilies to %s",
+		strings.Join(nodeFamilies, ", "), strings.Join(clusterFamilies, ", "), strings.Join(clusterFamilies, ", "))}
+}
+
+// versionSkew checks the kubelet against the control plane using the Kubernetes version skew policy:
+// the kubelet must not be newer than the API server and may be at most three minor versions older
+func versionSkew(kubeletVersion, controlPlaneVersion string) string {
+	kubelet, err := version.ParseGeneric(kubeletVersion)
+	if err != nil {
+		return fmt.Sprintf("kubernetes.version %q is not a valid version", kubeletVersion)
+	}
+	apiServer, err := version.ParseGeneric(controlPlaneVersion)
+	if err != nil {
+		// An unexpected format from the service is not the node's problem
+		return ""
+	}
+
+	if kubelet.Major() != apiServer.Major() {
+		return fmt.Sprintf("kubelet %s and control plane %s have different major versions", kubeletVersion, controlPlaneVersion)
+	}
+	if kubelet.Minor() > apiServer.Minor() {
+		return fmt.Sprintf("version skew: kubelet %s is newer than the control plane %s; set kubernetes.version to %d.%d or older",
+			kubeletVersion, controlPlaneVersion, apiServer.Major(), apiServer.Minor())
+	}
+	if apiServer.Minor()-kubelet.Minor() > maxKubeletMinorSkew {
+		return fmt.Sprintf("version skew: kubelet %s is more than %d minor versions older than the control plane %s; set kubernetes.version to %d.%d or newer",
+			kubeletVersion, maxKubeletMinorSkew, controlPlaneVersion, apiServer.Major(), apiServer.Minor()-maxKubeletMinorSkew)
+	}
+	return ""
+}
+
+// networkPluginMismatch explains why the cluster's network plugin cannot give pods on this node
+// connectivity. Only clusters without a managed plugin ("none") let a bring-your-own CNI such as
+// Cilium span Azure and non-Azure nodes.
+func networkPluginMismatch(profile *armcontainerservice.NetworkProfile) string {
+	if profile.NetworkPlugin == nil {
+		return ""
+	}
+	const remedy = "use a cluster created with --network-plugin none and install a CNI such as Cilium that covers all nodes"
+
+	switch *profile.NetworkPlugin {
+	case armcontainerservice.NetworkPluginNone:
+		return ""
+	case armcontainerservice.NetworkPluginKubenet:
+		return "network plugin mismatch: kubenet routes pod traffic through Azure route tables that only cover the cluster's Azure VMs, " +
+			"so pods on this node would be unreachable; " + remedy
+	case armcontainerservice.NetworkPluginAzure:
+		if profile.NetworkPluginMode != nil && *profile.NetworkPluginMode == armcontainerservice.NetworkPluginModeOverlay {
+			return "network plugin mismatch: Azure CNI Overlay programs pod routes in the Azure virtual network for Azure VMs only, " +
+				"so pods on this node would be unreachable; " + remedy
+		}
+		return "network plugin mismatch: Azure CNI assigns pod IPs from the virtual network through the node's Azure network interface, " +
+			"which this node does not have; " + remedy
+	default:
+		return fmt.Sprintf("network plugin mismatch: network plugin %s is not supported for flex nodes; %s", *profile.NetworkPlugin, remedy)
+	}
+}
+
+// overlaps reports whether two networks share any address
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
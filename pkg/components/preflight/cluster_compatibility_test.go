@@ -0,0 +1,194 @@
+package preflight
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+)
+
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%q): %v", cidr, err)
+	}
+	ipNet.IP = ip
+	return ipNet
+}
+
+func TestVersionSkew(t *testing.T) {
+	tests := []struct {
+		kubelet, controlPlane string
+		want                  string
+	}{
+		{"1.30.3", "1.30.3", ""},
+		{"1.30.3", "1.30.7", ""},
+		{"1.27.0", "1.30.7", ""},
+		{"1.26.9", "1.30.7", "more than 3 minor versions older"},
+		{"1.31.0", "1.30.7", "newer than the control plane"},
+		{"2.0.0", "1.30.7", "different major versions"},
+		{"latest", "1.30.7", "not a valid version"},
+		{"1.30.3", "unknown", ""},
+	}
+	for _, tt := range tests {
+		got := versionSkew(tt.kubelet, tt.controlPlane)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("versionSkew(%q, %q) = %q, want containing %q", tt.kubelet, tt.controlPlane, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkPluginMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile armcontainerservice.NetworkProfile
+		want    string
+	}{
+		{"byo cni", armcontainerservice.NetworkProfile{NetworkPlugin: to.Ptr(armcontainerservice.NetworkPluginNone)}, ""},
+		{"no plugin reported", armcontainerservice.NetworkProfile{}, ""},
+		{"kubenet", armcontainerservice.NetworkProfile{NetworkPlugin: to.Ptr(armcontainerservice.NetworkPluginKubenet)}, "kubenet"},
+		{"azure cni", armcontainerservice.NetworkProfile{NetworkPlugin: to.Ptr(armcontainerservice.NetworkPluginAzure)}, "network interface"},
+		{"azure cni overlay", armcontainerservice.NetworkProfile{
+			NetworkPlugin:     to.Ptr(armcontainerservice.NetworkPluginAzure),
+			NetworkPluginMode: to.Ptr(armcontainerservice.NetworkPluginModeOverlay),
+		}, "Overlay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := networkPluginMismatch(&tt.profile)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("networkPluginMismatch() = %q, want containing %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterIncompatibilities(t *testing.T) {
+	compatible := func() *armcontainerservice.ManagedClusterProperties {
+		return &armcontainerservice.ManagedClusterProperties{
+			CurrentKubernetesVersion: to.Ptr("1.30.7"),
+			NetworkProfile: &armcontainerservice.NetworkProfile{
+				NetworkPlugin: to.Ptr(armcontainerservice.NetworkPluginNone),
+				ServiceCidr:   to.Ptr("10.0.0.0/16"),
+				DNSServiceIP:  to.Ptr("10.0.0.10"),
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(*armcontainerservice.ManagedClusterProperties)
+		cni      string
+		families []string
+		nodeNets []string
+		want     []string
+	}{
+		{name: "compatible", nodeNets: []string{"192.168.1.20/24"}},
+		{
+			name: "dns service mismatch",
+			mutate: func(p *armcontainerservice.ManagedClusterProperties) {
+				p.NetworkProfile.DNSServiceIP = to.Ptr("10.2.0.10")
+			},
+			want: []string{"dnsServiceIP"},
+		},
+		{name: "host overlaps service cidr", nodeNets: []string{"10.0.4.5/24"}, want: []string{"overlaps the cluster service CIDR"}},
+		{name: "host overlaps bridge subnet", nodeNets: []string{"10.244.3.4/24"}, want: []string{"bridge CNI pod subnet"}},
+		{
+			name: "service cidr overlaps bridge subnet",
+			mutate: func(p *armcontainerservice.ManagedClusterProperties) {
+				p.NetworkProfile.ServiceCidr = to.Ptr("10.0.0.0/8")
+			},
+			want: []string{"overlaps the cluster service CIDR 10.0.0.0/8"},
+		},
+		{name: "old cni plugins", cni: "0.9.1", want: []string{"CNI plugins 0.9.1"}},
+		{
+			name: "dual-stack cluster with an IPv4 node",
+			mutate: func(p *armcontainerservice.ManagedClusterProperties) {
+				p.NetworkProfile.IPFamilies = []*armcontainerservice.IPFamily{to.Ptr(armcontainerservice.IPFamilyIPv4), to.Ptr(armcontainerservice.IPFamilyIPv6)}
+			},
+			want: []string{"set node.network.ipFamilies to IPv4, IPv6"},
+		},
+		{
+			name: "dual-stack cluster with the node's families swapped",
+			mutate: func(p *armcontainerservice.ManagedClusterProperties) {
+				p.NetworkProfile.IPFamilies = []*armcontainerservice.IPFamily{to.Ptr(armcontainerservice.IPFamilyIPv4), to.Ptr(armcontainerservice.IPFamilyIPv6)}
+			},
+			families: []string{"IPv6", "IPv4"},
+			want:     []string{"primary IP family is IPv4"},
+		},
+		{
+			name: "host overlaps the IPv6 service cidr of a dual-stack cluster",
+			mutate: func(p *armcontainerservice.ManagedClusterProperties) {
+				p.NetworkProfile.IPFamilies = []*armcontainerservice.IPFamily{to.Ptr(armcontainerservice.IPFamilyIPv4), to.Ptr(armcontainerservice.IPFamilyIPv6)}
+				p.NetworkProfile.ServiceCidrs = []*string{to.Ptr("10.0.0.0/16"), to.Ptr("fd10:0:0:1::/108")}
+			},
+			families: []string{"IPv4", "IPv6"},
+			nodeNets: []string{"192.168.1.20/24", "fd10:0:0:1::5/64"},
+			want:     []string{"host network fd10:0:0:1::5/64 overlaps the cluster service CIDR fd10:0:0:1::/108"},
+		},
+		{
+			name: "skew and plugin reported together",
+			mutate: func(p *armcontainerservice.ManagedClusterProperties) {
+				p.CurrentKubernetesVersion = to.Ptr("1.29.0")
+				p.NetworkProfile.NetworkPlugin = to.Ptr(armcontainerservice.NetworkPluginKubenet)
+			},
+			want: []string{"version skew", "kubenet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(homeTenant)
+			cfg.Kubernetes.Version = "1.30.3"
+			cfg.Node.Kubelet.DNSServiceIP = "10.0.0.10"
+			cfg.CNI.Version = tt.cni
+			cfg.Node.Network.IPFamilies = tt.families
+			cluster := compatible()
+			if tt.mutate != nil {
+				tt.mutate(cluster)
+			}
+			var nodeNets []*net.IPNet
+			for _, cidr := range tt.nodeNets {
+				nodeNets = append(nodeNets, mustCIDR(t, cidr))
+			}
+
+			got := clusterIncompatibilities(cfg, cluster, nodeNets)
+			if len(got) != len(tt.want) {
+				t.Fatalf("clusterIncompatibilities() = %q, want %d problems", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("problem %d = %q, want containing %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterCompatibilityCheckModes(t *testing.T) {
+	cluster := armcontainerservice.ManagedCluster{Properties: &armcontainerservice.ManagedClusterProperties{
+		CurrentKubernetesVersion: to.Ptr("1.30.7"),
+		NetworkProfile:           &armcontainerservice.NetworkProfile{NetworkPlugin: to.Ptr(armcontainerservice.NetworkPluginKubenet)},
+	}}
+
+	for _, mode := range []string{"", "enforce", "warn"} {
+		cfg := newTestConfig(homeTenant)
+		cfg.Kubernetes.Version = "1.30.3"
+		cfg.Preflight.ClusterCompatibility = mode
+		check := newClusterCompatibilityCheck(cfg, newTestLogger())
+		check.mcClient = &fakeClusterGetter{cluster: cluster}
+		check.interfaceAddrs = func() ([]net.Addr, error) { return nil, nil }
+
+		err := check.Run(context.Background())
+		if mode == "warn" {
+			if err != nil {
+				t.Errorf("mode %q: Run() unexpected error: %v", mode, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), "kubenet") {
+			t.Errorf("mode %q: Run() error = %v, want kubenet mismatch", mode, err)
+		}
+	}
+}
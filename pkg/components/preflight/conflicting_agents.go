@@ -0,0 +1,240 @@
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// arcShowTimeout bounds the azcmagent show call used to find the resource an existing Arc agent is connected as
+const arcShowTimeout = 10 * time.Second
+
+// knownAgent describes another Kubernetes distribution or container runtime that conflicts with the node agent
+type knownAgent struct {
+	name     string
+	services []string // systemd services that are stopped and disabled during remediation
+}
+
+// knownAgents lists installations that fight over the kubelet ports, the containerd socket or the cgroup hierarchy
+var knownAgents = []knownAgent{
+	{name: "k3s", services: []string{"k3s", "k3s-agent"}},
+	{name: "rke2", services: []string{"rke2-server", "rke2-agent"}},
+	{name: "microk8s", services: []string{"snap.microk8s.daemon-kubelite", "snap.microk8s.daemon-containerd"}},
+	{name: "docker", services: []string{"docker", "docker.socket"}},
+}
+
+// Unit files written by the agent; a kubelet or containerd service without them was installed by something else
+const (
+	agentKubeletUnit    = "/etc/systemd/system/kubelet.service"
+	agentContainerdUnit = "/etc/systemd/system/containerd.service"
+)
+
+// conflict is an installation found on the machine that would break bootstrap
+type conflict struct {
+	name     string
+	reason   string
+	services []string // services to stop and disable
+	arc      bool     // an Arc agent connected as another resource, remediated by disconnecting locally
+}
+
+// hostProbe abstracts the host inspection so detection can be tested
+type hostProbe interface {
+	ServiceActive(name string) bool
+	ServiceEnabled(name string) bool
+	FileExists(path string) bool
+	ArcShow(ctx context.Context) ([]byte, error)
+}
+
+// systemProbe inspects the real machine
+type systemProbe struct{}
+
+func (systemProbe) ServiceActive(name string) bool  { return utils.IsServiceActive(name) }
+func (systemProbe) ServiceEnabled(name string) bool { return utils.IsServiceEnabled(name) }
+func (systemProbe) FileExists(path string) bool     { return utils.FileExists(path) }
+func (systemProbe) ArcShow(ctx context.Context) ([]byte, error) {
+	if _, err := exec.LookPath("azcmagent"); err != nil {
+		return nil, nil
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, arcShowTimeout)
+	defer cancel()
+	return exec.CommandContext(timeoutCtx, "azcmagent", "show", "-j").Output()
+}
+
+// conflictingAgentsCheck detects other Kubernetes distributions, container runtimes and Arc connections
+// and handles them according to preflight.conflictingAgents, instead of failing halfway through bootstrap
+// with port conflicts or cgroup errors.
+type conflictingAgentsCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+	probe  hostProbe
+
+	stopService    func(name string) error
+	disableService func(name string) error
+	arcDisconnect  func(ctx context.Context) error
+}
+
+func newConflictingAgentsCheck(cfg *config.Config, logger *logrus.Logger) *conflictingAgentsCheck {
+	return &conflictingAgentsCheck{
+		config:         cfg,
+		logger:         logger,
+		probe:          systemProbe{},
+		stopService:    utils.StopService,
+		disableService: utils.DisableService,
+		arcDisconnect: func(ctx context.Context) error {
+			return utils.RunSystemCommand("azcmagent", "disconnect", "--force-local-only")
+		},
+	}
+}
+
+// Name returns the check name
+func (c *conflictingAgentsCheck) Name() string {
+	return "ConflictingAgents"
+}
+
+// Run detects conflicts and aborts, remediates or warns depending on the configured mode
+func (c *conflictingAgentsCheck) Run(ctx context.Context) error {
+	conflicts := c.detect(ctx)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	switch mode := c.config.GetConflictingAgentsMode(); mode {
+	case "coexist":
+		for _, found := range conflicts {
+			c.logger.Warnf("⚠️  Continuing alongside %s (%s); port, socket or cgroup conflicts are expected", found.name, found.reason)
+		}
+		return nil
+	case "stop-and-disable":
+		if err := c.remediate(ctx, conflicts); err != nil {
+			return err
+		}
+		if remaining := c.detect(ctx); len(remaining) > 0 {
+			return fmt.Errorf("conflicts remain after remediation: %s", describeConflicts(remaining))
+		}
+		return nil
+	default:
+		return fmt.Errorf("found %s; remove them, or set preflight.conflictingAgents to "+
+			"\"stop-and-disable\" to stop them or \"coexist\" to continue anyway", describeConflicts(conflicts))
+	}
+}
+
+// detect returns every conflicting installation found on the machine
+func (c *conflictingAgentsCheck) detect(ctx context.Context) []conflict {
+	var conflicts []conflict
+	for _, agent := range knownAgents {
+		if found, ok := detectKnownAgent(c.probe, agent); ok {
+			conflicts = append(conflicts, found)
+		}
+	}
+
+	// A kubelet or containerd running from a unit the agent did not write comes from a package or another installer
+	if c.probe.ServiceActive("kubelet") && !c.probe.FileExists(agentKubeletUnit) {
+		conflicts = append(conflicts, conflict{name: "kubelet", reason: "kubelet service not installed by aks-flex-node is running", services: []string{"kubelet"}})
+	}
+	if c.probe.ServiceActive("containerd") && !c.probe.FileExists(agentContainerdUnit) {
+		conflicts = append(conflicts, conflict{name: "containerd", reason: "containerd service not installed by aks-flex-node is running", services: []string{"containerd"}})
+	}
+
+	if c.config.IsARCEnabled() {
+		if found, ok := c.detectArcConflict(ctx); ok {
+			conflicts = append(conflicts, found)
+		}
+	}
+	return conflicts
+}
+
+// detectKnownAgent reports an agent when any of its services is running or would start at boot.
+// Installations that are stopped and disabled do not conflict and are left alone.
+func detectKnownAgent(probe hostProbe, agent knownAgent) (conflict, bool) {
+	for _, service := range agent.services {
+		if probe.ServiceActive(service) {
+			return conflict{name: agent.name, reason: fmt.Sprintf("service %s is running", service), services: agent.services}, true
+		}
+		if probe.ServiceEnabled(service) {
+			return conflict{name: agent.name, reason: fmt.Sprintf("service %s is enabled", service), services: agent.services}, true
+		}
+	}
+	return conflict{}, false
+}
+
+// arcAgentStatus is the subset of 'azcmagent show -j' needed to identify the connected resource
+type arcAgentStatus struct {
+	ResourceName   string `json:"resourceName"`
+	ResourceGroup  string `json:"resourceGroup"`
+	SubscriptionID string `json:"subscriptionId"`
+	Status         string `json:"status"`
+}
+
+// detectArcConflict reports an Arc agent connected as a different resource than the configured one
+func (c *conflictingAgentsCheck) detectArcConflict(ctx context.Context) (conflict, bool) {
+	output, err := c.probe.ArcShow(ctx)
+	if err != nil || len(output) == 0 {
+		return conflict{}, false
+	}
+	var status arcAgentStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		c.logger.Debugf("Could not parse azcmagent show output: %v", err)
+		return conflict{}, false
+	}
+	if !strings.EqualFold(status.Status, "Connected") {
+		return conflict{}, false
+	}
+
+	if strings.EqualFold(status.ResourceName, c.config.GetArcMachineName()) &&
+		strings.EqualFold(status.ResourceGroup, c.config.GetArcResourceGroup()) &&
+		strings.EqualFold(status.SubscriptionID, c.config.GetSubscriptionID()) {
+		return conflict{}, false
+	}
+	return conflict{
+		name: "Azure Arc agent",
+		reason: fmt.Sprintf("connected as %s in resource group %s of subscription %s",
+			status.ResourceName, status.ResourceGroup, status.SubscriptionID),
+		arc: true,
+	}, true
+}
+
+// remediate stops and disables conflicting services and disconnects a foreign Arc connection
+func (c *conflictingAgentsCheck) remediate(ctx context.Context, conflicts []conflict) error {
+	var errs []error
+	for _, found := range conflicts {
+		c.logger.Warnf("Stopping and disabling %s (%s)", found.name, found.reason)
+		if found.arc {
+			if err := c.arcDisconnect(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to disconnect Arc agent: %w", err))
+			}
+			continue
+		}
+		for _, service := range found.services {
+			if c.probe.ServiceActive(service) {
+				if err := c.stopService(service); err != nil {
+					errs = append(errs, fmt.Errorf("failed to stop %s: %w", service, err))
+					continue
+				}
+			}
+			if c.probe.ServiceEnabled(service) {
+				if err := c.disableService(service); err != nil {
+					errs = append(errs, fmt.Errorf("failed to disable %s: %w", service, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// describeConflicts renders conflicts for error messages
+func describeConflicts(conflicts []conflict) string {
+	parts := make([]string, 0, len(conflicts))
+	for _, found := range conflicts {
+		parts = append(parts, fmt.Sprintf("%s (%s)", found.name, found.reason))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,161 @@
+package preflight
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// fakeProbe simulates systemd services, files and azcmagent output; stopping or disabling updates its state
+type fakeProbe struct {
+	active  map[string]bool
+	enabled map[string]bool
+	files   map[string]bool
+	arcShow string
+}
+
+func (f *fakeProbe) ServiceActive(name string) bool  { return f.active[name] }
+func (f *fakeProbe) ServiceEnabled(name string) bool { return f.enabled[name] }
+func (f *fakeProbe) FileExists(path string) bool     { return f.files[path] }
+func (f *fakeProbe) ArcShow(ctx context.Context) ([]byte, error) {
+	return []byte(f.arcShow), nil
+}
+
+func newConflictTestCheck(mode string, probe *fakeProbe) (*conflictingAgentsCheck, *[]string) {
+	var actions []string
+	cfg := newTestConfig("")
+	cfg.Preflight.ConflictingAgents = mode
+	cfg.Azure.Arc = &config.ArcConfig{Enabled: true, MachineName: "edge-01", ResourceGroup: "arc-rg"}
+	return &conflictingAgentsCheck{
+		config: cfg,
+		logger: newTestLogger(),
+		probe:  probe,
+		stopService: func(name string) error {
+			actions = append(actions, "stop "+name)
+			probe.active[name] = false
+			return nil
+		},
+		disableService: func(name string) error {
+			actions = append(actions, "disable "+name)
+			probe.enabled[name] = false
+			return nil
+		},
+		arcDisconnect: func(ctx context.Context) error {
+			actions = append(actions, "arc disconnect")
+			probe.arcShow = `{"status":"Disconnected"}`
+			return nil
+		},
+	}, &actions
+}
+
+func newFakeProbe() *fakeProbe {
+	return &fakeProbe{active: map[string]bool{}, enabled: map[string]bool{}, files: map[string]bool{}}
+}
+
+func TestConflictingAgentsDetection(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(p *fakeProbe)
+		want  []string
+	}{
+		{
+			name: "clean machine",
+		},
+		{
+			name: "agent's own kubelet and containerd are not conflicts",
+			setup: func(p *fakeProbe) {
+				p.active["kubelet"], p.active["containerd"] = true, true
+				p.files[agentKubeletUnit], p.files[agentContainerdUnit] = true, true
+			},
+		},
+		{
+			name:  "running k3s",
+			setup: func(p *fakeProbe) { p.active["k3s"] = true },
+			want:  []string{"k3s"},
+		},
+		{
+			name:  "enabled docker",
+			setup: func(p *fakeProbe) { p.enabled["docker.socket"] = true },
+			want:  []string{"docker"},
+		},
+		{
+			name:  "package kubelet",
+			setup: func(p *fakeProbe) { p.active["kubelet"] = true },
+			want:  []string{"kubelet"},
+		},
+		{
+			name: "Arc connected as another machine",
+			setup: func(p *fakeProbe) {
+				p.arcShow = `{"resourceName":"other","resourceGroup":"arc-rg","subscriptionId":"12345678-1234-1234-1234-123456789012","status":"Connected"}`
+			},
+			want: []string{"Azure Arc agent"},
+		},
+		{
+			name: "Arc connected as the configured machine",
+			setup: func(p *fakeProbe) {
+				p.arcShow = `{"resourceName":"EDGE-01","resourceGroup":"arc-rg","subscriptionId":"12345678-1234-1234-1234-123456789012","status":"Connected"}`
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := newFakeProbe()
+			if tt.setup != nil {
+				tt.setup(probe)
+			}
+			check, _ := newConflictTestCheck("", probe)
+			var got []string
+			for _, found := range check.detect(context.Background()) {
+				got = append(got, found.name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflictingAgentsModes(t *testing.T) {
+	setup := func() *fakeProbe {
+		probe := newFakeProbe()
+		probe.active["rke2-agent"], probe.enabled["rke2-agent"] = true, true
+		return probe
+	}
+
+	t.Run("abort fails with remediation hint", func(t *testing.T) {
+		check, actions := newConflictTestCheck("", setup())
+		err := check.Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "rke2") || !strings.Contains(err.Error(), "stop-and-disable") {
+			t.Errorf("Run() error = %v, want rke2 conflict with remediation hint", err)
+		}
+		if len(*actions) != 0 {
+			t.Errorf("abort should not change the machine, got %v", *actions)
+		}
+	})
+
+	t.Run("coexist only warns", func(t *testing.T) {
+		check, actions := newConflictTestCheck("coexist", setup())
+		if err := check.Run(context.Background()); err != nil {
+			t.Errorf("Run() unexpected error: %v", err)
+		}
+		if len(*actions) != 0 {
+			t.Errorf("coexist should not change the machine, got %v", *actions)
+		}
+	})
+
+	t.Run("stop-and-disable remediates", func(t *testing.T) {
+		probe := setup()
+		probe.arcShow = `{"resourceName":"other","status":"Connected"}`
+		check, actions := newConflictTestCheck("stop-and-disable", probe)
+		if err := check.Run(context.Background()); err != nil {
+			t.Fatalf("Run() unexpected error: %v", err)
+		}
+		want := "stop rke2-agent,disable rke2-agent,arc disconnect"
+		if got := strings.Join(*actions, ","); got != want {
+			t.Errorf("remediation actions = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// managedClusterGetter is the subset of the managed clusters client used to verify cluster access
+type managedClusterGetter interface {
+	Get(ctx context.Context, resourceGroupName, resourceName string, options *armcontainerservice.ManagedClustersClientGetOptions) (armcontainerservice.ManagedClustersClientGetResponse, error)
+}
+
+// crossTenantCheck verifies that the node's identity can obtain tokens for every auxiliary tenant
+// and read the target cluster, i.e. that the cross-tenant trust relationship exists
+type crossTenantCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// Created lazily from the configured credentials; set directly in tests
+	cred     azcore.TokenCredential
+	mcClient managedClusterGetter
+}
+
+func newCrossTenantCheck(cfg *config.Config, logger *logrus.Logger) *crossTenantCheck {
+	return &crossTenantCheck{config: cfg, logger: logger}
+}
+
+// Name returns the check name
+func (c *crossTenantCheck) Name() string {
+	return "CrossTenantTrust"
+}
+
+// Run verifies the cross-tenant setup; it is a no-op for single-tenant configurations
+func (c *crossTenantCheck) Run(ctx context.Context) error {
+	tenants := c.config.GetAuxiliaryTenantIDs()
+	if len(tenants) == 0 || c.config.IsKubernetesCredentialConfigured() {
+		c.logger.Debug("No auxiliary tenants configured, skipping cross-tenant trust check")
+		return nil
+	}
+
+	if err := c.setUpClients(); err != nil {
+		return err
+	}
+
+	for _, tenantID := range tenants {
+		c.logger.Infof("Verifying a token can be issued for tenant %s", tenantID)
+		_, err := c.cred.GetToken(ctx, policy.TokenRequestOptions{
+			Scopes:   []string{"https://management.azure.com/.default"},
+			TenantID: tenantID,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot obtain a token for tenant %s - %s: %w", tenantID, c.remediation(tenantID), err)
+		}
+	}
+
+	clusterTenantID := c.config.GetTargetClusterTenantID()
+	clusterName := c.config.GetTargetClusterName()
+	c.logger.Infof("Verifying access to cluster %s in tenant %s", clusterName, clusterTenantID)
+	opCtx, cancel := auth.OperationContext(ctx, c.config)
+	defer cancel()
+	if _, err := c.mcClient.Get(opCtx, c.config.GetTargetClusterResourceGroup(), clusterName, nil); err != nil {
+		return fmt.Errorf("cannot read cluster %s in tenant %s - grant the node's identity access to the cluster in that tenant: %w",
+			c.config.GetTargetClusterID(), clusterTenantID, err)
+	}
+	return nil
+}
+
+// setUpClients creates the credential and cluster client unless they were injected
+func (c *crossTenantCheck) setUpClients() error {
+	authProvider := auth.NewAuthProvider()
+	if c.cred == nil {
+		cred, err := authProvider.UserCredential(c.config)
+		if err != nil {
+			return fmt.Errorf("failed to get authentication credential: %w", err)
+		}
+		c.cred = cred
+	}
+	if c.mcClient == nil {
+		clusterCred, err := authProvider.ClusterCredential(c.config)
+		if err != nil {
+			return fmt.Errorf("failed to get cluster tenant credential: %w", err)
+		}
+		mcClient, err := armcontainerservice.NewManagedClustersClient(c.config.GetTargetClusterSubscriptionID(), clusterCred, auth.ARMClientOptions(c.config))
+		if err != nil {
+			return fmt.Errorf("failed to create managed clusters client: %w", err)
+		}
+		c.mcClient = mcClient
+	}
+	return nil
+}
+
+// remediation explains how to establish trust with a tenant for the configured authentication method
+func (c *crossTenantCheck) remediation(tenantID string) string {
+	if c.config.IsSPConfigured() {
+		return fmt.Sprintf("make the service principal's app registration multi-tenant and grant admin consent in tenant %s", tenantID)
+	}
+	return fmt.Sprintf("run 'az login --tenant %s' with an account that is a member or guest of that tenant", tenantID)
+}
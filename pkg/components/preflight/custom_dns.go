@@ -0,0 +1,68 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/dns"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// dnsLookupTimeout bounds each lookup of a name the node needs
+const dnsLookupTimeout = 10 * time.Second
+
+// customDNSCheck verifies that the names the node needs resolve through node.dns before the DNS step changes
+// the host's resolver configuration, so wrong upstreams fail here and leave the host as it was
+type customDNSCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	lookup func(ctx context.Context, host string) ([]string, error)
+}
+
+func newCustomDNSCheck(cfg *config.Config, logger *logrus.Logger) *customDNSCheck {
+	return &customDNSCheck{
+		config: cfg,
+		logger: logger,
+		lookup: dns.Resolver(cfg).LookupHost,
+	}
+}
+
+// Name returns the check name
+func (c *customDNSCheck) Name() string {
+	return "CustomDNS"
+}
+
+// Run resolves the names to verify through the configured upstreams; it is a no-op unless node.dns is set
+func (c *customDNSCheck) Run(ctx context.Context) error {
+	if !c.config.IsDNSConfigured() {
+		c.logger.Debug("Custom DNS is not configured, skipping DNS check")
+		return nil
+	}
+	names := dns.NamesToVerify(c.config)
+	if len(names) == 0 {
+		c.logger.Info("No names to verify DNS resolution with; set node.dns.verifyNames to check the upstreams")
+		return nil
+	}
+
+	var failed []string
+	for _, name := range names {
+		lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+		addrs, err := c.lookup(lookupCtx, name)
+		cancel()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		c.logger.Infof("%s resolves to %s", name, strings.Join(addrs, ", "))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("names do not resolve with the configured DNS; check node.dns.upstreams and that they serve these zones: %s",
+			strings.Join(failed, "; "))
+	}
+	return nil
+}
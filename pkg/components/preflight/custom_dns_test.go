@@ -0,0 +1,64 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestCustomDNSCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		dns        config.NodeDNSConfig
+		unresolved string
+		wantErr    string
+		wantLookup []string
+	}{
+		{name: "not configured is skipped"},
+		{
+			name:       "names resolve through the upstreams",
+			dns:        config.NodeDNSConfig{Upstreams: []string{"10.1.0.4"}, VerifyNames: []string{"registry.corp.example.com"}},
+			wantLookup: []string{"aks-private.privatelink.westeurope.azmk8s.io", "registry.corp.example.com"},
+		},
+		{
+			name:       "unresolved name fails",
+			dns:        config.NodeDNSConfig{Upstreams: []string{"10.1.0.4"}, VerifyNames: []string{"registry.corp.example.com"}},
+			unresolved: "registry.corp.example.com",
+			wantErr:    "registry.corp.example.com: no such host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("")
+			cfg.Node.Kubelet.ServerURL = "https://aks-private.privatelink.westeurope.azmk8s.io:443"
+			cfg.Node.DNS = tt.dns
+			var looked []string
+			check := newCustomDNSCheck(cfg, newTestLogger())
+			check.lookup = func(ctx context.Context, host string) ([]string, error) {
+				looked = append(looked, host)
+				if host == tt.unresolved {
+					return nil, errors.New("no such host")
+				}
+				return []string{"10.2.0.7"}, nil
+			}
+
+			err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if strings.Join(looked, ",") != strings.Join(tt.wantLookup, ",") {
+				t.Errorf("looked up %v, want %v", looked, tt.wantLookup)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/guestconfig"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// guestConfigurationLister is the subset of the guest configuration client we need
+type guestConfigurationLister interface {
+	ListMachineAssignments(ctx context.Context, subscriptionID, resourceGroup, machineName string) ([]guestconfig.Assignment, error)
+}
+
+// guestConfigurationCheck finds the Azure Policy guest configurations assigned to the Arc machine, records
+// their compliance for the node status and warns about the ones that would undo what bootstrap applies.
+// It never fails bootstrap: policy is owned by another team and the conflicts are often exempted later.
+type guestConfigurationCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// Created lazily from the configured credentials; set directly in tests
+	client guestConfigurationLister
+	// writeState records the result; replaced in tests
+	writeState func(*guestconfig.State) error
+}
+
+func newGuestConfigurationCheck(cfg *config.Config, logger *logrus.Logger) *guestConfigurationCheck {
+	return &guestConfigurationCheck{config: cfg, logger: logger, writeState: guestconfig.WriteState}
+}
+
+// Name returns the check name
+func (c *guestConfigurationCheck) Name() string {
+	return "GuestConfiguration"
+}
+
+// Run lists the assignments and warns about conflicts; guest configuration needs an Arc machine
+func (c *guestConfigurationCheck) Run(ctx context.Context) error {
+	if !c.config.IsARCEnabled() {
+		c.logger.Debug("Arc is disabled, skipping guest configuration check")
+		return nil
+	}
+
+	state, err := refreshGuestConfiguration(ctx, c.config, c.client)
+	if err != nil {
+		c.logger.Warnf("⚠️  Could not read the guest configuration assignments of the Arc machine: %v", err)
+		return nil
+	}
+	if err := c.writeState(state); err != nil {
+		c.logger.Warnf("Failed to record guest configuration assignments: %v", err)
+	}
+	for _, conflict := range state.Conflicts {
+		c.logger.Warnf("⚠️  Azure Policy %s; exempt this machine from the assignment or expect the node to break when it is applied", conflict)
+	}
+	return nil
+}
+
+// RefreshGuestConfiguration lists the guest configuration assignments of the configured Arc machine, finds
+// their conflicts and records them for the node status. The agent daemon calls it to keep compliance current.
+func RefreshGuestConfiguration(ctx context.Context, cfg *config.Config) (*guestconfig.State, error) {
+	state, err := refreshGuestConfiguration(ctx, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return state, guestconfig.WriteState(state)
+}
+
+// refreshGuestConfiguration lists the assignments and finds their conflicts. client may be nil, in which
+// case one is created from the configured credentials.
+func refreshGuestConfiguration(ctx context.Context, cfg *config.Config, client guestConfigurationLister) (*guestconfig.State, error) {
+	if client == nil {
+		cred, err := auth.NewAuthProvider().UserCredential(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credential: %w", err)
+		}
+		gcClient, err := guestconfig.NewClient(cred, auth.ARMClientOptions(cfg))
+		if err != nil {
+			return nil, err
+		}
+		client = gcClient
+	}
+
+	opCtx, cancel := auth.OperationContext(ctx, cfg)
+	defer cancel()
+	assignments, err := client.ListMachineAssignments(opCtx, cfg.GetSubscriptionID(), cfg.GetArcResourceGroup(), cfg.GetArcMachineName())
+	if err != nil {
+		return nil, azerrors.Wrap(err)
+	}
+	return &guestconfig.State{
+		UpdatedAt:   time.Now().UTC(),
+		Assignments: assignments,
+		Conflicts:   guestconfig.Conflicts(assignments),
+	}, nil
+}
@@ -0,0 +1,68 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/guestconfig"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+type fakeGuestConfigurationLister struct {
+	assignments []guestconfig.Assignment
+	err         error
+	machine     string
+}
+
+func (f *fakeGuestConfigurationLister) ListMachineAssignments(ctx context.Context, subscriptionID, resourceGroup, machineName string) ([]guestconfig.Assignment, error) {
+	f.machine = resourceGroup + "/" + machineName
+	return f.assignments, f.err
+}
+
+func TestGuestConfigurationCheck(t *testing.T) {
+	baseline := guestconfig.Assignment{Name: "baseline", Configuration: "AzureLinuxBaseline", AssignmentType: "ApplyAndAutoCorrect", ComplianceStatus: "NonCompliant"}
+
+	tests := []struct {
+		name          string
+		arcEnabled    bool
+		lister        *fakeGuestConfigurationLister
+		wantState     bool
+		wantConflicts int
+	}{
+		{name: "conflicts are recorded", arcEnabled: true, lister: &fakeGuestConfigurationLister{assignments: []guestconfig.Assignment{baseline}}, wantState: true, wantConflicts: 1},
+		{name: "no assignments", arcEnabled: true, lister: &fakeGuestConfigurationLister{}, wantState: true},
+		{name: "listing failure only warns", arcEnabled: true, lister: &fakeGuestConfigurationLister{err: errors.New("403 Forbidden")}},
+		{name: "skipped without arc", lister: &fakeGuestConfigurationLister{assignments: []guestconfig.Assignment{baseline}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("")
+			cfg.Azure.Arc = &config.ArcConfig{Enabled: tt.arcEnabled, MachineName: "edge-01", ResourceGroup: "arc-rg"}
+			var written *guestconfig.State
+			check := newGuestConfigurationCheck(cfg, newTestLogger())
+			check.client = tt.lister
+			check.writeState = func(state *guestconfig.State) error {
+				written = state
+				return nil
+			}
+
+			if err := check.Run(context.Background()); err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if (written != nil) != tt.wantState {
+				t.Fatalf("Run() recorded state = %+v, want recorded %v", written, tt.wantState)
+			}
+			if written == nil {
+				return
+			}
+			if tt.lister.machine != "arc-rg/edge-01" {
+				t.Errorf("Run() listed assignments of %q, want arc-rg/edge-01", tt.lister.machine)
+			}
+			if len(written.Conflicts) != tt.wantConflicts || len(written.Assignments) != len(tt.lister.assignments) {
+				t.Errorf("Run() recorded %+v, want %d conflicts", written, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ca_trust"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
+)
+
+// immutableOSRequiredBinaries are tools the node needs that the agent only installs with a package manager
+var immutableOSRequiredBinaries = []string{"jq", "iptables"}
+
+// immutableOSCheck detects immutable operating systems and verifies every path bootstrap writes to is writable
+// there, so a Flatcar or Fedora CoreOS host fails before anything is installed rather than halfway through
+type immutableOSCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// Replaced in tests
+	detect   func() osimage.Info
+	readOnly func(path string) bool
+	lookPath func(file string) (string, error)
+}
+
+func newImmutableOSCheck(cfg *config.Config, logger *logrus.Logger) *immutableOSCheck {
+	return &immutableOSCheck{
+		config:   cfg,
+		logger:   logger,
+		detect:   osimage.Current,
+		readOnly: osimage.ReadOnly,
+		lookPath: exec.LookPath,
+	}
+}
+
+// Name returns the check name
+func (c *immutableOSCheck) Name() string {
+	return "ImmutableOS"
+}
+
+// Run fails when the host is immutable and bootstrap would write to a read-only path, or needs a package the
+// host has no way to install
+func (c *immutableOSCheck) Run(ctx context.Context) error {
+	info := c.detect()
+	if !info.Immutable() {
+		c.logger.Debug("Host has a writable /usr")
+		return nil
+	}
+	c.logger.Infof("Host runs an immutable OS (%s, %s); binaries are installed to %s", info.ID, info.Variant, osimage.OptBinDir)
+
+	var problems []string
+	for _, path := range c.writablePaths() {
+		if c.readOnly(path) {
+			problems = append(problems, path+" is read-only")
+		}
+	}
+	if info.Variant != osimage.VariantOSTree {
+		// OSTree hosts layer missing packages with rpm-ostree; the others must ship them in the image
+		for _, binary := range immutableOSRequiredBinaries {
+			if _, err := c.lookPath(binary); err != nil {
+				problems = append(problems, binary+" is missing and cannot be installed on this OS; add it to the image")
+			}
+		}
+		if c.config.Node.Cgroup.MigrateToV2 {
+			problems = append(problems, "node.cgroup.migrateToV2 cannot change the kernel command line of this OS; boot the image with cgroup v2")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("bootstrap cannot run on this immutable OS (%s): %s", info.Variant, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// writablePaths returns the directories bootstrap writes to with the configuration
+func (c *immutableOSCheck) writablePaths() []string {
+	paths := []string{
+		"/etc/systemd/system",
+		c.config.Paths.Kubernetes.ConfigDir,
+		c.config.Paths.Kubernetes.KubeletDir,
+		cni.DefaultCNIBinDir,
+		cni.DefaultCNIConfDir,
+		osimage.OptBinDir,
+		"/var/lib/aks-flex-node",
+	}
+	if c.config.IsCATrustConfigured() {
+		if dir, err := ca_trust.TrustStoreDir(); err == nil {
+			paths = append(paths, dir)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,62 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
+)
+
+func TestImmutableOSCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		variant     osimage.Variant
+		readOnly    []string
+		missing     []string
+		migrateToV2 bool
+		wantErr     string
+	}{
+		{name: "mutable host passes", variant: osimage.VariantMutable, readOnly: []string{"/opt/bin"}},
+		{name: "flatcar with writable paths passes", variant: osimage.VariantFlatcar},
+		{name: "read-only bin directory fails", variant: osimage.VariantFlatcar, readOnly: []string{"/opt/bin"}, wantErr: "/opt/bin is read-only"},
+		{name: "missing package fails without rpm-ostree", variant: osimage.VariantReadOnlyUsr, missing: []string{"jq"}, wantErr: "jq is missing"},
+		{name: "missing package is layered on ostree", variant: osimage.VariantOSTree, missing: []string{"jq"}},
+		{name: "cgroup migration fails on flatcar", variant: osimage.VariantFlatcar, migrateToV2: true, wantErr: "migrateToV2"},
+		{name: "cgroup migration uses rpm-ostree on ostree", variant: osimage.VariantOSTree, migrateToV2: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Node: config.NodeConfig{Cgroup: config.CgroupConfig{MigrateToV2: tt.migrateToV2}}}
+			cfg.Paths.Kubernetes.ConfigDir = "/etc/kubernetes"
+			cfg.Paths.Kubernetes.KubeletDir = "/var/lib/kubelet"
+
+			check := newImmutableOSCheck(cfg, logrus.New())
+			check.detect = func() osimage.Info { return osimage.Info{ID: "test", Variant: tt.variant} }
+			check.readOnly = func(path string) bool { return slices.Contains(tt.readOnly, path) }
+			check.lookPath = func(file string) (string, error) {
+				if slices.Contains(tt.missing, file) {
+					return "", errors.New("not found")
+				}
+				return "/usr/bin/" + file, nil
+			}
+
+			err := check.Run(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Run() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
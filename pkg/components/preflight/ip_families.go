@@ -0,0 +1,138 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/endpoints"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/dns"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/ipfamily"
+)
+
+// ipFamiliesCheck verifies that the host has an address for every configured IP family, and that IPv6-only
+// nodes can reach Azure over IPv6. Kubelet otherwise registers without the node IPs and pods lose a family.
+type ipFamiliesCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	interfaceAddrs func() ([]net.Addr, error)
+	sourceAddress  func(family string) (net.IP, error)
+	lookup         func(ctx context.Context, host string) ([]net.IP, error)
+	dial           func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newIPFamiliesCheck(cfg *config.Config, logger *logrus.Logger) *ipFamiliesCheck {
+	dialer := &net.Dialer{Timeout: endpointDialTimeout}
+	return &ipFamiliesCheck{
+		config:         cfg,
+		logger:         logger,
+		interfaceAddrs: net.InterfaceAddrs,
+		sourceAddress:  ipfamily.SourceAddress,
+		lookup: func(ctx context.Context, host string) ([]net.IP, error) {
+			return dns.Resolver(cfg).LookupIP(ctx, "ip6", host)
+		},
+		dial: dialer.DialContext,
+	}
+}
+
+// Name returns the check name
+func (c *ipFamiliesCheck) Name() string {
+	return "IPFamilies"
+}
+
+// Run verifies the node's addresses; it is a no-op for IPv4-only nodes without configured node IPs
+func (c *ipFamiliesCheck) Run(ctx context.Context) error {
+	families := c.config.GetIPFamilies()
+	if len(c.config.Node.Network.NodeIPs) == 0 && len(families) == 1 && families[0] == config.IPFamilyIPv4 {
+		c.logger.Debug("Node is IPv4 only, skipping IP family check")
+		return nil
+	}
+
+	var err error
+	if len(c.config.Node.Network.NodeIPs) > 0 {
+		err = c.checkNodeIPs()
+	} else {
+		err = c.checkSourceAddresses(families)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(families) == 1 && families[0] == config.IPFamilyIPv6 {
+		return c.checkAzureOverIPv6(ctx)
+	}
+	return nil
+}
+
+// checkNodeIPs requires every configured node IP to be assigned to one of the host's interfaces
+func (c *ipFamiliesCheck) checkNodeIPs() error {
+	addrs, err := c.interfaceAddrs()
+	if err != nil {
+		return fmt.Errorf("failed to list the host's addresses: %w", err)
+	}
+	assigned := map[string]bool{}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			assigned[ipNet.IP.String()] = true
+		}
+	}
+
+	var errs []error
+	for _, nodeIP := range c.config.Node.Network.NodeIPs {
+		ip := net.ParseIP(nodeIP)
+		if ip == nil || !assigned[ip.String()] {
+			errs = append(errs, fmt.Errorf("node IP %s is not assigned to any interface of the host", nodeIP))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkSourceAddresses requires a routable address for every family, the addresses kubelet registers
+func (c *ipFamiliesCheck) checkSourceAddresses(families []string) error {
+	var errs []error
+	for _, family := range families {
+		ip, err := c.sourceAddress(family)
+		if err != nil {
+			hint := "add a default route or set node.network.nodeIPs"
+			if family == config.IPFamilyIPv6 {
+				hint = "check that net.ipv6.conf.all.disable_ipv6 is 0 and that the uplink has a global IPv6 address and default route"
+			}
+			errs = append(errs, fmt.Errorf("%w - %s", err, hint))
+			continue
+		}
+		c.logger.Infof("Node %s address is %s", family, ip)
+	}
+	return errors.Join(errs...)
+}
+
+// checkAzureOverIPv6 requires the Azure endpoints to resolve to IPv6 addresses and accept connections over
+// IPv6. Several Azure services have no IPv6 endpoint, so IPv6-only networks need DNS64 and NAT64.
+func (c *ipFamiliesCheck) checkAzureOverIPv6(ctx context.Context) error {
+	var errs []error
+	for _, endpoint := range endpoints.ForRegion(c.config.GetArcLocation()) {
+		isArc := strings.HasSuffix(endpoint.Host, ".arc.azure.com") || strings.HasSuffix(endpoint.Host, ".guestconfiguration.azure.com")
+		if isArc && !c.config.IsARCEnabled() {
+			continue
+		}
+		addrs, err := c.lookup(ctx, endpoint.Host)
+		if err != nil || len(addrs) == 0 {
+			errs = append(errs, fmt.Errorf("%s has no IPv6 address - IPv6-only nodes need a DNS64 resolver and a NAT64 gateway to reach Azure", endpoint.Host))
+			continue
+		}
+		conn, err := c.dial(ctx, "tcp6", endpoint.Address())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s resolves to %s but port 443 is unreachable over IPv6 - check the NAT64 gateway, firewall and routing: %w",
+				endpoint.Host, addrs[0], err))
+			continue
+		}
+		_ = conn.Close()
+		c.logger.Debugf("%s (%s) is reachable over IPv6 at %s", endpoint.Name, endpoint.Host, addrs[0])
+	}
+	return errors.Join(errs...)
+}
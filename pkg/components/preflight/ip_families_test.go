@@ -0,0 +1,80 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestIPFamiliesCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		families  []string
+		nodeIPs   []string
+		noIPv6    bool
+		noAAAA    bool
+		wantErr   string
+		wantLooks bool
+	}{
+		{name: "IPv4 only is skipped"},
+		{name: "dual-stack with addresses", families: []string{"IPv4", "IPv6"}},
+		{name: "dual-stack without an IPv6 route", families: []string{"IPv4", "IPv6"}, noIPv6: true, wantErr: "disable_ipv6 is 0"},
+		{name: "assigned node IPs", families: []string{"IPv4", "IPv6"}, nodeIPs: []string{"192.168.1.20", "fd00::20"}},
+		{name: "unassigned node IP", families: []string{"IPv4", "IPv6"}, nodeIPs: []string{"192.168.1.20", "fd00::21"}, wantErr: "node IP fd00::21 is not assigned"},
+		{name: "IPv6 only reaches Azure", families: []string{"IPv6"}, wantLooks: true},
+		{name: "IPv6 only without DNS64", families: []string{"IPv6"}, noAAAA: true, wantErr: "DNS64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("")
+			cfg.Node.Network.IPFamilies = tt.families
+			cfg.Node.Network.NodeIPs = tt.nodeIPs
+			looked := false
+			check := newIPFamiliesCheck(cfg, newTestLogger())
+			check.interfaceAddrs = func() ([]net.Addr, error) {
+				return []net.Addr{
+					&net.IPNet{IP: net.ParseIP("192.168.1.20"), Mask: net.CIDRMask(24, 32)},
+					&net.IPNet{IP: net.ParseIP("fd00::20"), Mask: net.CIDRMask(64, 128)},
+				}, nil
+			}
+			check.sourceAddress = func(family string) (net.IP, error) {
+				if family == "IPv6" {
+					if tt.noIPv6 {
+						return nil, errors.New("the host has no IPv6 default route")
+					}
+					return net.ParseIP("2001:db8:1::20"), nil
+				}
+				return net.ParseIP("192.168.1.20"), nil
+			}
+			check.lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+				looked = true
+				if tt.noAAAA {
+					return nil, errors.New("no such host")
+				}
+				return []net.IP{net.ParseIP("64:ff9b::1400:1")}, nil
+			}
+			check.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+
+			err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if looked != tt.wantLooks {
+				t.Errorf("resolved Azure endpoints = %v, want %v", looked, tt.wantLooks)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/crio"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// requiredListener is a TCP port or unix socket a component listens on
+type requiredListener struct {
+	purpose string   // e.g. "kubelet API"
+	owners  []string // Process names allowed to hold it, i.e. the component itself on a re-run
+	port    int
+	address net.IP // Address the component binds to; unspecified means all addresses
+	socket  string // Unix socket path, instead of a port
+}
+
+func (r requiredListener) String() string {
+	if r.socket != "" {
+		return fmt.Sprintf("socket %s (%s)", r.socket, r.purpose)
+	}
+	return fmt.Sprintf("port %d/tcp (%s)", r.port, r.purpose)
+}
+
+// listener is a socket some process listens on
+type listener struct {
+	address net.IP
+	port    int
+	socket  string
+	inode   string
+}
+
+// process is the owner of a listening socket
+type process struct {
+	pid  int
+	name string
+}
+
+// portConflictsCheck finds other processes listening on the ports and socket paths the components need. The
+// components would otherwise fail to start later with bind errors that only show up in their journal.
+type portConflictsCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	procDir string // Mount point of the proc file system; replaced in tests
+}
+
+func newPortConflictsCheck(cfg *config.Config, logger *logrus.Logger) *portConflictsCheck {
+	return &portConflictsCheck{config: cfg, logger: logger, procDir: "/proc"}
+}
+
+// Name returns the check name
+func (c *portConflictsCheck) Name() string {
+	return "PortConflicts"
+}
+
+// Run fails when a port or socket a component needs is held by another process, naming the process. With
+// preflight.conflictingAgents set to coexist, the conflicts are only warned about.
+func (c *portConflictsCheck) Run(ctx context.Context) error {
+	listeners, err := readListeners(c.procDir)
+	if err != nil {
+		c.logger.Warnf("⚠️  Could not list the listening sockets of this host: %v", err)
+		return nil
+	}
+	owners := socketOwners(c.procDir)
+
+	var conflicts []string
+	for _, required := range c.requiredListeners() {
+		for _, found := range listeners {
+			if !required.heldBy(found) {
+				continue
+			}
+			owner, known := owners[found.inode]
+			if known && required.allows(owner.name) {
+				continue
+			}
+			holder := "an unknown process"
+			if known {
+				holder = fmt.Sprintf("%s (pid %d)", owner.name, owner.pid)
+			}
+			if found.socket == "" {
+				holder += " on " + found.address.String()
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%s is in use by %s", required, holder))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	if c.config.GetConflictingAgentsMode() == "coexist" {
+		for _, conflict := range conflicts {
+			c.logger.Warnf("⚠️  Continuing although %s; the component will fail to bind it", conflict)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s; stop the processes holding them, or set preflight.conflictingAgents to \"coexist\" to continue anyway",
+		strings.Join(conflicts, "; "))
+}
+
+// requiredListeners returns the ports and sockets of the enabled components
+func (c *portConflictsCheck) requiredListeners() []requiredListener {
+	loopback := net.IPv4(127, 0, 0, 1)
+	var required []requiredListener
+	if c.config.IsComponentEnabled(config.ComponentKubelet) {
+		// The read-only port 10255 is turned off, so it is not needed
+		required = append(required,
+			requiredListener{purpose: "kubelet API", owners: []string{"kubelet"}, port: 10250},
+			requiredListener{purpose: "kubelet health", owners: []string{"kubelet"}, port: 10248, address: loopback},
+		)
+	}
+	switch runtime := c.config.GetContainerRuntime(); {
+	case runtime == "cri-o" && c.config.IsComponentEnabled(config.ComponentCRIO):
+		required = append(required, requiredListener{purpose: "CRI-O", owners: []string{"crio"}, socket: crio.Socket})
+	case runtime != "cri-o" && c.config.IsComponentEnabled(config.ComponentContainerd):
+		required = append(required, requiredListener{purpose: "containerd", owners: []string{"containerd"}, socket: containerd.Socket})
+	}
+	if c.config.IsComponentEnabled(config.ComponentNPD) {
+		required = append(required,
+			requiredListener{purpose: "Node Problem Detector health", owners: []string{"node-problem-de"}, port: 20256, address: loopback},
+			requiredListener{purpose: "Node Problem Detector metrics", owners: []string{"node-problem-de"}, port: 20257, address: loopback},
+		)
+	}
+	// The agent's own listeners are held by the agent when bootstrap runs from its daemon
+	for purpose, address := range map[string]string{"webhook listener": c.config.Agent.Webhook.ListenAddress, "artifact cache peers": c.config.Downloads.Peers.ServeAddress} {
+		if address == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(address)
+		if number, convErr := strconv.Atoi(port); err == nil && convErr == nil {
+			required = append(required, requiredListener{purpose: purpose, owners: []string{"aks-flex-node"}, port: number, address: net.ParseIP(host)})
+		}
+	}
+	return required
+}
+
+// heldBy reports whether the listener occupies the port or socket the component needs
+func (r requiredListener) heldBy(l listener) bool {
+	if r.socket != "" {
+		return l.socket == r.socket
+	}
+	if l.socket != "" || l.port != r.port {
+		return false
+	}
+	// Sockets on the same port only coexist when both are bound to specific, different addresses
+	return r.address == nil || r.address.IsUnspecified() || l.address.IsUnspecified() || r.address.Equal(l.address)
+}
+
+// allows reports whether name, as shown in /proc/<pid>/comm, is one of the component's own processes
+func (r requiredListener) allows(name string) bool {
+	for _, owner := range r.owners {
+		if name == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// readListeners lists the listening TCP sockets and unix sockets bound to a path
+func readListeners(procDir string) ([]listener, error) {
+	var listeners []listener
+	for _, name := range []string{"tcp", "tcp6"} {
+		found, err := readTCPListeners(filepath.Join(procDir, "net", name))
+		if err != nil && !(name == "tcp6" && os.IsNotExist(err)) {
+			return nil, err
+		}
+		listeners = append(listeners, found...)
+	}
+	found, err := readUnixListeners(filepath.Join(procDir, "net", "unix"))
+	if err != nil {
+		return nil, err
+	}
+	return append(listeners, found...), nil
+}
+
+// tcpListen is the state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// readTCPListeners parses /proc/net/tcp or tcp6: "sl local_address rem_address st ... uid timeout inode"
+func readTCPListeners(path string) ([]listener, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var listeners []listener
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		address, port, err := parseProcAddress(fields[1])
+		if err != nil {
+			continue
+		}
+		listeners = append(listeners, listener{address: address, port: port, inode: fields[9]})
+	}
+	return listeners, scanner.Err()
+}
+
+// parseProcAddress parses an address such as "0100007F:2742": the IP in host byte order, 32 bits at a time
+func parseProcAddress(field string) (net.IP, int, error) {
+	hexIP, hexPort, ok := strings.Cut(field, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("malformed address %q", field)
+	}
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("malformed address %q", field)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed port %q", field)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
+	return ip, int(port), nil
+}
+
+// unixAcceptConnections is the __SO_ACCEPTCON flag of a listening socket in /proc/net/unix
+const unixAcceptConnections = 0x10000
+
+// readUnixListeners parses /proc/net/unix: "Num RefCount Protocol Flags Type St Inode Path"
+func readUnixListeners(path string) ([]listener, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var listeners []listener
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || strings.HasPrefix(fields[7], "@") {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&unixAcceptConnections == 0 {
+			continue
+		}
+		listeners = append(listeners, listener{socket: fields[7], inode: fields[6]})
+	}
+	return listeners, scanner.Err()
+}
+
+// socketOwners maps socket inodes to the processes holding them, from the descriptors in /proc/<pid>/fd.
+// Processes whose descriptors cannot be read are left out.
+func socketOwners(procDir string) map[string]process {
+	owners := map[string]process{}
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procDir, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		var name string
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			if name == "" {
+				comm, _ := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+				name = strings.TrimSpace(string(comm))
+			}
+			owners[strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")] = process{pid: pid, name: name}
+		}
+	}
+	return owners
+}
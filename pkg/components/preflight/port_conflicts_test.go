@@ -0,0 +1,194 @@
+package preflight
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	tcpHeader  = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	unixHeader = "Num       RefCount Protocol Flags    Type St Inode Path\n"
+)
+
+// fakeProc lays out the parts of /proc the check reads: the socket tables and each process's name and
+// socket descriptors
+type fakeProc struct {
+	dir  string
+	tcp  []string
+	tcp6 []string
+	unix []string
+}
+
+func (p *fakeProc) process(t *testing.T, pid int, name string, inodes ...string) {
+	fdDir := filepath.Join(p.dir, strconv.Itoa(pid), "fd")
+	if err := os.MkdirAll(fdDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(p.dir, strconv.Itoa(pid), "comm"), []byte(name+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for idx, inode := range inodes {
+		if err := os.Symlink("socket:["+inode+"]", filepath.Join(fdDir, strconv.Itoa(idx+3))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (p *fakeProc) write(t *testing.T) {
+	if err := os.MkdirAll(filepath.Join(p.dir, "net"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, lines := range map[string][]string{"tcp": p.tcp, "tcp6": p.tcp6} {
+		content := tcpHeader + strings.Join(lines, "\n")
+		if err := os.WriteFile(filepath.Join(p.dir, "net", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(p.dir, "net", "unix"), []byte(unixHeader+strings.Join(p.unix, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tcpLine is a /proc/net/tcp entry for a socket in state st
+func tcpLine(address string, st string, inode string) string {
+	return "   0: " + address + " 00000000:0000 " + st + " 00000000:00000000 00:00000000 00000000     0        0 " + inode + " 1 0000000000000000 100 0 0 10 0"
+}
+
+func unixLine(flags, inode, path string) string {
+	return "0000000000000000: 00000002 00000000 " + flags + " 0001 01 " + inode + " " + path
+}
+
+func TestParseProcAddress(t *testing.T) {
+	tests := []struct {
+		field    string
+		wantIP   net.IP
+		wantPort int
+	}{
+		{field: "0100007F:2742", wantIP: net.IPv4(127, 0, 0, 1), wantPort: 10050},
+		{field: "00000000:280A", wantIP: net.IPv4zero, wantPort: 10250},
+		{field: "00000000000000000000000001000000:4F21", wantIP: net.IPv6loopback, wantPort: 20257},
+		{field: "0000000000000000FFFF00000100007F:0016", wantIP: net.IPv4(127, 0, 0, 1), wantPort: 22},
+	}
+	for _, tt := range tests {
+		ip, port, err := parseProcAddress(tt.field)
+		if err != nil || !ip.Equal(tt.wantIP) || port != tt.wantPort {
+			t.Errorf("parseProcAddress(%q) = %v, %d, %v, want %v, %d", tt.field, ip, port, err, tt.wantIP, tt.wantPort)
+		}
+	}
+	if _, _, err := parseProcAddress("0100007F"); err == nil {
+		t.Error("parseProcAddress() expected an error without a port")
+	}
+}
+
+func TestPortConflictsCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, p *fakeProc)
+		cfg     config.Config
+		wantErr []string
+	}{
+		{
+			name: "free host passes",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.tcp = []string{tcpLine("00000000:0016", "0A", "100")}
+				p.process(t, 1, "sshd", "100")
+			},
+		},
+		{
+			name: "another kubelet API listener fails naming the process",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.tcp6 = []string{tcpLine("00000000000000000000000000000000:280A", "0A", "200")}
+				p.process(t, 4242, "k3s-server", "200")
+			},
+			wantErr: []string{"port 10250/tcp (kubelet API)", "k3s-server (pid 4242) on ::"},
+		},
+		{
+			name: "components holding their own listeners pass",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.tcp = []string{tcpLine("00000000:280A", "0A", "300"), tcpLine("0100007F:4F20", "0A", "301")}
+				p.unix = []string{unixLine("00010000", "302", "/run/containerd/containerd.sock")}
+				p.process(t, 10, "kubelet", "300")
+				p.process(t, 11, "node-problem-de", "301")
+				p.process(t, 12, "containerd", "302")
+			},
+		},
+		{
+			name: "containerd socket held by another daemon fails",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.unix = []string{unixLine("00010000", "400", "/run/containerd/containerd.sock")}
+				p.process(t, 77, "dockerd", "400")
+			},
+			wantErr: []string{"socket /run/containerd/containerd.sock (containerd)", "dockerd (pid 77)"},
+		},
+		{
+			name: "connected sockets and other addresses are ignored",
+			setup: func(t *testing.T, p *fakeProc) {
+				// An outgoing connection from port 10250, and NPD's port bound on another address
+				p.tcp = []string{tcpLine("0100007F:280A", "01", "500"), tcpLine("0A00000A:4F21", "0A", "501")}
+				p.unix = []string{unixLine("00000000", "502", "/run/containerd/containerd.sock")}
+				p.process(t, 5, "curl", "500", "502")
+				p.process(t, 6, "exporter", "501")
+			},
+		},
+		{
+			name: "unknown owner is still reported",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.tcp = []string{tcpLine("0100007F:4F21", "0A", "600")}
+			},
+			wantErr: []string{"port 20257/tcp (Node Problem Detector metrics) is in use by an unknown process"},
+		},
+		{
+			name: "disabled component is not checked",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.tcp = []string{tcpLine("0100007F:4F21", "0A", "700")}
+				p.process(t, 7, "exporter", "700")
+			},
+			cfg: config.Config{Components: config.ComponentsConfig{Enabled: map[string]bool{config.ComponentNPD: false}}},
+		},
+		{
+			name: "coexist only warns",
+			setup: func(t *testing.T, p *fakeProc) {
+				p.tcp = []string{tcpLine("00000000:280A", "0A", "800")}
+				p.process(t, 8, "k3s-server", "800")
+			},
+			cfg: config.Config{Preflight: config.PreflightConfig{ConflictingAgents: "coexist"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := &fakeProc{dir: t.TempDir()}
+			tt.setup(t, proc)
+			proc.write(t)
+
+			cfg := tt.cfg
+			check := newPortConflictsCheck(&cfg, logrus.New())
+			check.procDir = proc.dir
+
+			err := check.Run(context.Background())
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Run() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Run() expected an error containing %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Run() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+)
+
+// Check is a single precondition verified before bootstrap changes anything on the machine or in Azure
+type Check interface {
+	// Name returns a short human readable name for the check
+	Name() string
+
+	// Run returns an error describing the problem and how to fix it, or nil if the check passed
+	Run(ctx context.Context) error
+}
+
+// Installer runs all preflight checks as the first bootstrap step
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+	checks []Check
+}
+
+// NewInstaller creates a new preflight step with the default checks
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+		checks: defaultChecks(cfg, logger),
+	}
+}
+
+// defaultChecks returns the checks run before every bootstrap
+func defaultChecks(cfg *config.Config, logger *logrus.Logger) []Check {
+	// Private endpoints resolve publicly until the DNS zone links among the prerequisites are deployed
+	prerequisites := newPrerequisitesCheck(cfg, logger)
+	privateEndpoints := newPrivateEndpointCheck(cfg, logger)
+	privateEndpoints.zoneLinksPending = prerequisites.zoneLinksPending
+	return []Check{
+		newCATrustCheck(cfg, logger),
+		newCustomDNSCheck(cfg, logger),
+		newCrossTenantCheck(cfg, logger),
+		newClusterCompatibilityCheck(cfg, logger),
+		newAPIServerPathCheck(cfg, logger),
+		prerequisites,
+		privateEndpoints,
+		newIPFamiliesCheck(cfg, logger),
+		newConflictingAgentsCheck(cfg, logger),
+		newGuestConfigurationCheck(cfg, logger),
+		newCgroupVersionCheck(cfg, logger),
+		newImmutableOSCheck(cfg, logger),
+		newPortConflictsCheck(cfg, logger),
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "PreflightChecks"
+}
+
+// Execute runs every check and reports all failures together so they can be fixed in one pass
+func (i *Installer) Execute(ctx context.Context) error {
+	var errs []error
+	var failed []string
+	for _, check := range i.checks {
+		if err := check.Run(ctx); err != nil {
+			i.logger.Errorf("❌ Preflight check %s failed: %v", check.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", check.Name(), err))
+			failed = append(failed, check.Name())
+			continue
+		}
+		i.logger.Infof("✅ Preflight check %s passed", check.Name())
+	}
+
+	if len(errs) > 0 {
+		return &errdefs.PreflightError{
+			Checks: failed,
+			Err:    fmt.Errorf("%d of %d preflight checks failed: %w", len(errs), len(i.checks), errors.Join(errs...)),
+		}
+	}
+	return nil
+}
+
+// IsCompleted always returns false so preflight checks run on every bootstrap
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	return false
+}
+
+// Validate validates preconditions before execution
+func (i *Installer) Validate(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,164 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+)
+
+const (
+	homeTenant    = "11111111-1111-1111-1111-111111111111"
+	clusterTenant = "22222222-2222-2222-2222-222222222222"
+)
+
+// fakeCheck is a Check returning a fixed result
+type fakeCheck struct {
+	name string
+	err  error
+	ran  bool
+}
+
+func (f *fakeCheck) Name() string { return f.name }
+
+func (f *fakeCheck) Run(ctx context.Context) error {
+	f.ran = true
+	return f.err
+}
+
+// fakeCredential records requested tenants and fails for the ones listed in denied
+type fakeCredential struct {
+	denied    map[string]bool
+	requested []string
+}
+
+func (f *fakeCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	f.requested = append(f.requested, options.TenantID)
+	if f.denied[options.TenantID] {
+		return azcore.AccessToken{}, errors.New("AADSTS700016: application not found in the directory")
+	}
+	return azcore.AccessToken{Token: "token"}, nil
+}
+
+// fakeClusterGetter returns a fixed cluster and error from Get
+type fakeClusterGetter struct {
+	cluster armcontainerservice.ManagedCluster
+	err     error
+	called  bool
+}
+
+func (f *fakeClusterGetter) Get(ctx context.Context, resourceGroupName, resourceName string, options *armcontainerservice.ManagedClustersClientGetOptions) (armcontainerservice.ManagedClustersClientGetResponse, error) {
+	f.called = true
+	return armcontainerservice.ManagedClustersClientGetResponse{ManagedCluster: f.cluster}, f.err
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return logger
+}
+
+func newTestConfig(clusterTenantID string) *config.Config {
+	return &config.Config{
+		Azure: config.AzureConfig{
+			SubscriptionID: "12345678-1234-1234-1234-123456789012",
+			TenantID:       homeTenant,
+			TargetCluster: &config.TargetClusterConfig{
+				ResourceID:    "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				Name:          "test-cluster",
+				ResourceGroup: "test-rg",
+				TenantID:      clusterTenantID,
+			},
+		},
+	}
+}
+
+func TestExecuteReportsAllFailures(t *testing.T) {
+	first := &fakeCheck{name: "First", err: errors.New("first problem")}
+	second := &fakeCheck{name: "Second"}
+	third := &fakeCheck{name: "Third", err: errors.New("third problem")}
+
+	installer := &Installer{logger: newTestLogger(), checks: []Check{first, second, third}}
+	err := installer.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Execute() expected error")
+	}
+	if !second.ran || !third.ran {
+		t.Error("Execute() should run every check even after a failure")
+	}
+	for _, want := range []string{"2 of 3 preflight checks failed", "First: first problem", "Third: third problem"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Execute() error = %q, want containing %q", err, want)
+		}
+	}
+	preflightErr, ok := errdefs.As[*errdefs.PreflightError](err)
+	if !ok || strings.Join(preflightErr.Checks, ",") != "First,Third" {
+		t.Errorf("Execute() error = %#v, want *errdefs.PreflightError for First and Third", err)
+	}
+}
+
+func TestCrossTenantCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		clusterTenant string
+		denied        map[string]bool
+		clusterErr    error
+		wantErr       string
+		wantGet       bool
+	}{
+		{
+			name:          "single tenant is skipped",
+			clusterTenant: "",
+		},
+		{
+			name:          "trusted tenant passes",
+			clusterTenant: clusterTenant,
+			wantGet:       true,
+		},
+		{
+			name:          "token failure explains remediation",
+			clusterTenant: clusterTenant,
+			denied:        map[string]bool{clusterTenant: true},
+			wantErr:       "az login --tenant " + clusterTenant,
+		},
+		{
+			name:          "cluster access failure",
+			clusterTenant: clusterTenant,
+			clusterErr:    errors.New("AuthorizationFailed"),
+			wantErr:       "cannot read cluster",
+			wantGet:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred := &fakeCredential{denied: tt.denied}
+			getter := &fakeClusterGetter{err: tt.clusterErr}
+			check := newCrossTenantCheck(newTestConfig(tt.clusterTenant), newTestLogger())
+			check.cred = cred
+			check.mcClient = getter
+
+			err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if getter.called != tt.wantGet {
+				t.Errorf("cluster Get called = %v, want %v", getter.called, tt.wantGet)
+			}
+			if tt.clusterTenant == "" && len(cred.requested) != 0 {
+				t.Errorf("single tenant config should not request tokens, got %v", cred.requested)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/endpoints"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/dns"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// endpointDialTimeout bounds each TCP connection attempt to a private endpoint
+const endpointDialTimeout = 5 * time.Second
+
+// privateEndpointCheck verifies that private-link endpoints resolve to private IPs and accept connections.
+// Split-horizon DNS mistakes otherwise surface much later as opaque TLS or timeout errors.
+type privateEndpointCheck struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	lookup func(ctx context.Context, host string) ([]net.IP, error)
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+	// zoneLinksPending reports whether links of private DNS zones are deployed after preflight
+	zoneLinksPending func() bool
+}
+
+func newPrivateEndpointCheck(cfg *config.Config, logger *logrus.Logger) *privateEndpointCheck {
+	dialer := &net.Dialer{Timeout: endpointDialTimeout}
+	return &privateEndpointCheck{
+		config: cfg,
+		logger: logger,
+		lookup: func(ctx context.Context, host string) ([]net.IP, error) {
+			return dns.Resolver(cfg).LookupIP(ctx, "ip", host)
+		},
+		dial: dialer.DialContext,
+	}
+}
+
+// Name returns the check name
+func (c *privateEndpointCheck) Name() string {
+	return "PrivateEndpoints"
+}
+
+// Run verifies private endpoint resolution and reachability; it is a no-op unless private link is enabled
+func (c *privateEndpointCheck) Run(ctx context.Context) error {
+	if !c.config.IsPrivateLinkEnabled() {
+		c.logger.Debug("Private link is not enabled, skipping private endpoint check")
+		return nil
+	}
+
+	var errs []error
+	for _, endpoint := range c.endpoints() {
+		if !endpoint.PrivateLinkCapable {
+			c.logger.Infof("%s (%s) has no private endpoint and must be reachable through the firewall or proxy", endpoint.Name, endpoint.Host)
+			continue
+		}
+		if err := c.checkEndpoint(ctx, endpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// endpoints returns the endpoints relevant to the configured authentication mode
+func (c *privateEndpointCheck) endpoints() []endpoints.Endpoint {
+	var list []endpoints.Endpoint
+	for _, endpoint := range endpoints.ForRegion(c.config.GetArcLocation()) {
+		isArc := strings.HasSuffix(endpoint.Host, ".arc.azure.com") || strings.HasSuffix(endpoint.Host, ".guestconfiguration.azure.com")
+		if isArc && !c.config.IsARCEnabled() {
+			continue
+		}
+		list = append(list, endpoint)
+	}
+	for _, host := range c.config.Azure.PrivateLink.AdditionalHosts {
+		list = append(list, endpoints.Endpoint{Name: host, Host: host, PrivateLinkCapable: true})
+	}
+	return list
+}
+
+// checkEndpoint resolves the endpoint, requires private addresses only and opens a TCP connection
+func (c *privateEndpointCheck) checkEndpoint(ctx context.Context, endpoint endpoints.Endpoint) error {
+	addrs, err := c.lookup(ctx, endpoint.Host)
+	if err != nil {
+		return c.resolutionProblem(fmt.Errorf("%s does not resolve - add a conditional forwarder for its privatelink zone to your DNS server: %w", endpoint.Host, err))
+	}
+
+	if public := endpoints.PublicAddresses(addrs); len(public) > 0 {
+		return c.resolutionProblem(fmt.Errorf("%s resolves to public address %s instead of a private endpoint - "+
+			"link the privatelink DNS zone to the node's network or forward the zone from on-premises DNS", endpoint.Host, public[0]))
+	}
+
+	conn, err := c.dial(ctx, "tcp", endpoint.Address())
+	if err != nil {
+		return fmt.Errorf("%s resolves to %s but port 443 is unreachable - check NSG, firewall and routing to the private endpoint: %w",
+			endpoint.Host, addrs[0], err)
+	}
+	_ = conn.Close()
+
+	c.logger.Infof("%s (%s) resolves to private address %s", endpoint.Name, endpoint.Host, addrs[0])
+	return nil
+}
+
+// resolutionProblem returns err, or only logs it while the DNS zone links the endpoint may depend on are still
+// to be deployed by the AzurePrerequisites step; the next bootstrap checks the endpoint again
+func (c *privateEndpointCheck) resolutionProblem(err error) error {
+	if c.zoneLinksPending == nil || !c.zoneLinksPending() {
+		return err
+	}
+	c.logger.Warnf("⚠️  %v (the private DNS zone links are deployed after preflight)", err)
+	return nil
+}
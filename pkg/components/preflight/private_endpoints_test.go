@@ -0,0 +1,102 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func newPrivateLinkTestConfig(arcEnabled bool) *config.Config {
+	cfg := newTestConfig("")
+	cfg.Azure.Arc = &config.ArcConfig{Enabled: arcEnabled, Location: "eastus"}
+	cfg.Azure.PrivateLink = &config.PrivateLinkConfig{Enabled: true, AdditionalHosts: []string{"myvault.vault.azure.net"}}
+	return cfg
+}
+
+func TestPrivateEndpointCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *config.Config
+		publicHost string
+		dialErr    error
+		wantErr    string
+		wantLookup []string
+		skipLookup []string
+	}{
+		{
+			name:       "all private endpoints pass",
+			cfg:        newPrivateLinkTestConfig(true),
+			wantLookup: []string{"management.azure.com", "eastus.his.arc.azure.com", "myvault.vault.azure.net"},
+			skipLookup: []string{"login.microsoftonline.com"},
+		},
+		{
+			name:       "arc endpoints are skipped without arc",
+			cfg:        newPrivateLinkTestConfig(false),
+			wantLookup: []string{"management.azure.com"},
+			skipLookup: []string{"gbl.his.arc.azure.com"},
+		},
+		{
+			name:       "public resolution fails",
+			cfg:        newPrivateLinkTestConfig(true),
+			publicHost: "management.azure.com",
+			wantErr:    "management.azure.com resolves to public address 20.0.0.1",
+		},
+		{
+			name:    "unreachable endpoint fails",
+			cfg:     newPrivateLinkTestConfig(false),
+			dialErr: errors.New("i/o timeout"),
+			wantErr: "port 443 is unreachable",
+		},
+		{
+			name: "disabled private link is skipped",
+			cfg:  newTestConfig(""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			looked := map[string]bool{}
+			check := newPrivateEndpointCheck(tt.cfg, newTestLogger())
+			check.lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+				looked[host] = true
+				if host == tt.publicHost {
+					return []net.IP{net.ParseIP("20.0.0.1")}, nil
+				}
+				return []net.IP{net.ParseIP("10.0.0.5")}, nil
+			}
+			check.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				if tt.dialErr != nil {
+					return nil, tt.dialErr
+				}
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+
+			err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			for _, host := range tt.wantLookup {
+				if !looked[host] {
+					t.Errorf("expected %s to be resolved", host)
+				}
+			}
+			for _, host := range tt.skipLookup {
+				if looked[host] {
+					t.Errorf("expected %s not to be resolved", host)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package runc
 
-// Runc binary paths to check and manage
-const (
-	runcBinaryPath = "/usr/bin/runc"
-)
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
+
+// Runc binary path to check and manage, in /opt/bin on immutable hosts
+var runcBinaryPath = osimage.BinPath("/usr/bin/runc")
 
 var (
 	runcFileName    = "runc.%s"
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -17,9 +18,9 @@ type Installer struct {
 }
 
 // NewInstaller creates a new runc Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -29,6 +30,11 @@ func (i *Installer) GetName() string {
 	return "Runc_Installer"
 }
 
+// ManagedFiles returns the binary installed by this step
+func (i *Installer) ManagedFiles() []string {
+	return []string{runcBinaryPath}
+}
+
 // Execute downloads and installs the runc container runtime
 func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Infof("Installing runc version %s", i.getRuncVersion())
@@ -39,7 +45,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	}
 
 	// Install runc
-	if err := i.installRunc(); err != nil {
+	if err := i.installRunc(ctx); err != nil {
 		return fmt.Errorf("runc installation failed: %w", err)
 	}
 
@@ -47,7 +53,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) installRunc() error {
+func (i *Installer) installRunc(ctx context.Context) error {
 	// Construct download URL
 	runcFileName, runcDownloadURL, err := i.constructRuncDownloadURL()
 	if err != nil {
@@ -68,7 +74,7 @@ func (i *Installer) installRunc() error {
 
 	i.logger.Infof("Downloading runc from %s into %s", runcDownloadURL, tempFile)
 
-	if err := utils.DownloadFile(runcDownloadURL, tempFile); err != nil {
+	if err := download.NewManager(i.config, i.logger).For("runc", i.getRuncVersion()).Fetch(ctx, runcDownloadURL, tempFile); err != nil {
 		return fmt.Errorf("failed to download runc from %s: %w", runcDownloadURL, err)
 	}
 
@@ -88,7 +94,7 @@ func (i *Installer) constructRuncDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	url := fmt.Sprintf(runcDownloadURL, runcVersion, arch)
+	url := i.config.GetArtifactURL("runc", runcVersion, arch, fmt.Sprintf(runcDownloadURL, runcVersion, arch))
 	fileName := fmt.Sprintf(runcFileName, arch)
 	i.logger.Infof("Constructed runc download URL: %s", url)
 	return fileName, url, nil
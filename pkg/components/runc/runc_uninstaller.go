@@ -15,9 +15,9 @@ type UnInstaller struct {
 }
 
 // NewUnInstaller creates a new runc unInstaller
-func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
 	return &UnInstaller{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -10,3 +10,13 @@ const (
 	// Service startup timeout
 	ServiceStartupTimeout = 30 * time.Second
 )
+
+// problemConditions are the node conditions that report a problem when True: kubelet's pressure conditions and the
+// problems Node Problem Detector reports with its default and GPU monitors. Other conditions, such as the agent's
+// own FlexNodeAgentReady, are positive or not caused by a change to the node.
+var problemConditions = []string{
+	"MemoryPressure", "DiskPressure", "PIDPressure", "NetworkUnavailable",
+	"KernelDeadlock", "ReadonlyFilesystem", "FrequentKubeletRestart", "FrequentContainerdRestart",
+	"FrequentDockerRestart", "CorruptDockerOverlay2",
+	"GPUXidError", "GPUMemoryError", "GPUThermalSlowdown",
+}
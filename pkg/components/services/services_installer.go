@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
@@ -17,14 +18,15 @@ type Installer struct {
 }
 
 // NewInstaller creates a new services Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
 
-// Execute enables and starts required services (containerd and kubelet)
+// Execute enables and starts required services (the container runtime, kubelet and node-problem-detector),
+// leaving out those components.enabled turns off
 func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Info("Enabling and starting services")
 
@@ -33,44 +35,51 @@ func (i *Installer) Execute(ctx context.Context) error {
 		return fmt.Errorf("failed to reload systemd: %w", err)
 	}
 
-	// Enable and start containerd
-	i.logger.Info("Enabling and starting containerd service")
-	if err := utils.EnableAndStartService("containerd"); err != nil {
-		i.logger.Errorf("Failed to enable and start containerd: %v", err)
-		return fmt.Errorf("failed to enable and start containerd: %w", err)
-	}
+	if i.config.IsComponentEnabled(i.config.GetContainerRuntime()) {
+		// Enable and start the container runtime
+		runtime := container_runtime.ForConfig(i.config).ServiceName()
+		i.logger.Infof("Enabling and starting %s service", runtime)
+		if err := utils.EnableAndStartService(runtime); err != nil {
+			i.logger.Errorf("Failed to enable and start %s: %v", runtime, err)
+			return fmt.Errorf("failed to enable and start %s: %w", runtime, err)
+		}
 
-	// Restart containerd to pick up CNI configuration changes
-	i.logger.Info("Restarting containerd service to apply CNI configuration")
-	if err := utils.RestartService("containerd"); err != nil {
-		i.logger.Errorf("Failed to restart containerd: %v", err)
-		return fmt.Errorf("failed to restart containerd for CNI reload: %w", err)
+		// Restart the container runtime to pick up CNI configuration changes
+		i.logger.Infof("Restarting %s service to apply CNI configuration", runtime)
+		if err := utils.RestartService(runtime); err != nil {
+			i.logger.Errorf("Failed to restart %s: %v", runtime, err)
+			return fmt.Errorf("failed to restart %s for CNI reload: %w", runtime, err)
+		}
 	}
 
-	// Enable and start kubelet
-	i.logger.Info("Enabling and starting kubelet service")
-	if err := utils.EnableAndStartService("kubelet"); err != nil {
-		i.logger.Errorf("Failed to enable and start kubelet: %v", err)
-		return fmt.Errorf("failed to enable and start kubelet: %w", err)
-	}
+	if i.config.IsComponentEnabled(config.ComponentKubelet) {
+		// Enable and start kubelet
+		i.logger.Info("Enabling and starting kubelet service")
+		if err := utils.EnableAndStartService("kubelet"); err != nil {
+			i.logger.Errorf("Failed to enable and start kubelet: %v", err)
+			return fmt.Errorf("failed to enable and start kubelet: %w", err)
+		}
 
-	// Wait for kubelet to start and validate it's running properly
-	i.logger.Info("Waiting for kubelet to start...")
-	if err := utils.WaitForService("kubelet", 30*time.Second, i.logger); err != nil {
-		return fmt.Errorf("kubelet failed to start properly: %w", err)
+		// Wait for kubelet to start and validate it's running properly
+		i.logger.Info("Waiting for kubelet to start...")
+		if err := utils.WaitForService("kubelet", 30*time.Second, i.logger); err != nil {
+			return fmt.Errorf("kubelet failed to start properly: %w", err)
+		}
 	}
 
-	i.logger.Info("Enabling and starting node-problem-detector service")
-	if err := utils.EnableAndStartService("node-problem-detector"); err != nil {
-		i.logger.Errorf("Failed to enable and start node-problem-detector: %v", err)
-		return fmt.Errorf("failed to enable and start node-problem-detector: %w", err)
+	if i.config.IsComponentEnabled(config.ComponentNPD) {
+		i.logger.Info("Enabling and starting node-problem-detector service")
+		if err := utils.EnableAndStartService("node-problem-detector"); err != nil {
+			i.logger.Errorf("Failed to enable and start node-problem-detector: %v", err)
+			return fmt.Errorf("failed to enable and start node-problem-detector: %w", err)
+		}
 	}
 
 	i.logger.Info("All services enabled and started successfully")
 	return nil
 }
 
-// IsCompleted checks if containerd and kubelet services are enabled and running
+// IsCompleted checks if the container runtime and kubelet services are enabled and running
 func (i *Installer) IsCompleted(ctx context.Context) bool {
 	// always return false to ensure services are reenabled each time
 	return false
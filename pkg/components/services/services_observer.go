@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ObserverStepName is the name of the observation step, which bootstrap rolls back after when it fails
+const ObserverStepName = "ObservationWindow"
+
+// serviceState is what systemd reports about a service
+type serviceState struct {
+	active   string // ActiveState, e.g. "active" or "activating" while waiting to restart
+	restarts int    // NRestarts, the automatic restarts since the service was started
+}
+
+// Observer watches the services and the node for agent.observe.window after they started. Start checks only
+// see an instant, while a service that crashes a minute later and is restarted by systemd looks healthy to
+// them.
+type Observer struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	state      func(service string) (serviceState, error)
+	conditions func(ctx context.Context) (map[string]string, error) // Node condition types and their status
+	clock      retry.Clock
+}
+
+// NewObserver creates the observation step
+func NewObserver(cfg *config.Config, logger *logrus.Logger) *Observer {
+	o := &Observer{
+		config: cfg,
+		logger: logger,
+		state:  systemdState,
+		clock:  retry.RealClock,
+	}
+	o.conditions = o.nodeConditions
+	return o
+}
+
+// GetName returns the step name
+func (o *Observer) GetName() string {
+	return ObserverStepName
+}
+
+// Validate has nothing to check beyond config validation
+func (o *Observer) Validate(ctx context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when no observation window is configured; otherwise the node is watched on every
+// bootstrap, as every bootstrap restarts the services
+func (o *Observer) IsCompleted(ctx context.Context) bool {
+	return o.config.GetObserveWindow() == 0
+}
+
+// Execute watches the services until the window ends and fails with everything that went wrong in it
+func (o *Observer) Execute(ctx context.Context) error {
+	window, interval := o.config.GetObserveWindow(), o.config.GetObserveInterval()
+	services := o.services()
+	o.logger.Infof("Watching %s and the node for %s before bootstrap is declared successful", strings.Join(services, ", "), window)
+
+	baseline := map[string]int{}
+	inactive := map[string]int{}
+	restarts := map[string]int{}
+	deadline := o.clock.Now().Add(window)
+	checks := 0
+	for {
+		checks++
+		for _, service := range services {
+			state, err := o.state(service)
+			if err != nil {
+				o.logger.Warnf("Failed to read the state of %s, its restarts are not observed: %v", service, err)
+				continue
+			}
+			if _, ok := baseline[service]; !ok {
+				baseline[service] = state.restarts
+			}
+			if state.active != "active" {
+				inactive[service]++
+				o.logger.Warnf("⚠️  %s is %s during the observation window", service, state.active)
+			}
+			restarts[service] = state.restarts - baseline[service]
+		}
+		// The last check is at the end of the window
+		remaining := deadline.Sub(o.clock.Now())
+		if remaining <= 0 {
+			break
+		}
+		if err := retry.Sleep(ctx, o.clock, min(interval, remaining)); err != nil {
+			return err
+		}
+	}
+
+	var problems []string
+	for _, service := range services {
+		if count := restarts[service]; count > o.config.Agent.Observe.MaxRestarts {
+			problems = append(problems, fmt.Sprintf("%s restarted %d times", service, count))
+		}
+		if count := inactive[service]; count > 0 {
+			problems = append(problems, fmt.Sprintf("%s was not active in %d of %d checks", service, count, checks))
+		}
+	}
+	problems = append(problems, o.conditionProblems(ctx)...)
+	if len(problems) > 0 {
+		return fmt.Errorf("the node was unhealthy during the %s observation window: %s; inspect the services with 'journalctl -u <service>'",
+			window, strings.Join(problems, "; "))
+	}
+	o.logger.Infof("✅ Services and node stayed healthy for %s", window)
+	return nil
+}
+
+// services returns the services of the enabled components
+func (o *Observer) services() []string {
+	var services []string
+	if o.config.IsComponentEnabled(o.config.GetContainerRuntime()) {
+		services = append(services, container_runtime.ForConfig(o.config).ServiceName())
+	}
+	if o.config.IsComponentEnabled(config.ComponentKubelet) {
+		services = append(services, "kubelet")
+	}
+	if o.config.IsComponentEnabled(config.ComponentNPD) {
+		services = append(services, "node-problem-detector")
+	}
+	return services
+}
+
+// conditionProblems reports a node that is not Ready at the end of the window, and the conditions reporting a
+// problem, such as DiskPressure or the problems NPD detects
+func (o *Observer) conditionProblems(ctx context.Context) []string {
+	if !o.config.IsComponentEnabled(config.ComponentKubelet) {
+		return nil
+	}
+	conditions, err := o.conditions(ctx)
+	if err != nil {
+		return []string{fmt.Sprintf("the node's conditions could not be read: %v", err)}
+	}
+	var problems []string
+	switch status := conditions["Ready"]; status {
+	case "True":
+	case "":
+		problems = append(problems, "the node has no Ready condition")
+	default:
+		problems = append(problems, "the node is not Ready (Ready="+status+")")
+	}
+	var reported []string
+	for _, conditionType := range problemConditions {
+		if conditions[conditionType] == "True" {
+			reported = append(reported, conditionType)
+		}
+	}
+	sort.Strings(reported)
+	if len(reported) > 0 {
+		problems = append(problems, "the node reports "+strings.Join(reported, ", "))
+	}
+	return problems
+}
+
+// nodeConditions reads the conditions of the node with kubelet's credentials
+func (o *Observer) nodeConditions(ctx context.Context) (map[string]string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	// Kubelet registers the node under the lower-cased hostname
+	output, err := utils.RunCommandWithOutputContext(ctx, "kubectl", "--kubeconfig", kubelet.KubeletKubeconfigPath,
+		"get", "node", strings.ToLower(hostname), "-o", "jsonpath={.status.conditions}")
+	if err != nil {
+		return nil, err
+	}
+	return parseConditions(output)
+}
+
+// parseConditions maps the types of the node's conditions to their status
+func parseConditions(output string) (map[string]string, error) {
+	var conditions []struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+	}
+	if strings.TrimSpace(output) == "" {
+		return map[string]string{}, nil
+	}
+	if err := json.Unmarshal([]byte(output), &conditions); err != nil {
+		return nil, fmt.Errorf("failed to parse node conditions: %w", err)
+	}
+	statuses := make(map[string]string, len(conditions))
+	for _, condition := range conditions {
+		statuses[condition.Type] = condition.Status
+	}
+	return statuses, nil
+}
+
+// systemdState reads the state and the restart count of a service
+func systemdState(service string) (serviceState, error) {
+	// Reading unit properties needs no privilege, and the agent user has no sudo rule for it
+	output, err := exec.Command("systemctl", "show", service, "--property=ActiveState,NRestarts").CombinedOutput()
+	if err != nil {
+		return serviceState{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	var state serviceState
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "ActiveState":
+			state.active = value
+		case "NRestarts":
+			state.restarts, _ = strconv.Atoi(value)
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/heartbeat"
+	"go.goms.io/aks/AKSFlexNode/pkg/retry"
+)
+
+func TestObserver(t *testing.T) {
+	healthy := map[string]string{"Ready": "True", "MemoryPressure": "False", "KernelDeadlock": "False"}
+	tests := []struct {
+		name        string
+		states      map[string][]serviceState // Per service, one state per check, repeating the last
+		conditions  map[string]string
+		condErr     error
+		maxRestarts int
+		wantErr     []string
+	}{
+		{
+			name:       "healthy node passes",
+			conditions: healthy,
+		},
+		{
+			name: "crash-looping kubelet fails",
+			states: map[string][]serviceState{"kubelet": {
+				{active: "active", restarts: 2}, {active: "activating", restarts: 3}, {active: "active", restarts: 5},
+			}},
+			conditions: healthy,
+			wantErr:    []string{"kubelet restarted 3 times", "kubelet was not active in 1 of 5 checks"},
+		},
+		{
+			name:        "restarts within the limit pass",
+			states:      map[string][]serviceState{"containerd": {{active: "active"}, {active: "active", restarts: 1}}},
+			conditions:  healthy,
+			maxRestarts: 1,
+		},
+		{
+			name:       "node not ready and reporting problems fails",
+			conditions: map[string]string{"Ready": "False", "KernelDeadlock": "True", "DiskPressure": "True"},
+			wantErr:    []string{"not Ready (Ready=False)", "the node reports DiskPressure, KernelDeadlock"},
+		},
+		{
+			name:       "positive conditions pass",
+			conditions: map[string]string{"Ready": "True", heartbeat.ConditionType: "True", "MemoryPressure": "False"},
+		},
+		{
+			name:    "unreadable conditions fail",
+			condErr: errors.New("Unauthorized"),
+			wantErr: []string{"conditions could not be read: Unauthorized"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Agent.Observe = config.ObserveConfig{Window: "1m", Interval: "15s", MaxRestarts: tt.maxRestarts}
+			logger := logrus.New()
+			logger.SetLevel(logrus.ErrorLevel)
+
+			observer := NewObserver(cfg, logger)
+			observer.clock = retry.NewFakeClock(time.Unix(0, 0))
+			observer.state = func(service string) (serviceState, error) {
+				states := tt.states[service]
+				if len(states) == 0 {
+					return serviceState{active: "active"}, nil
+				}
+				state := states[0]
+				if len(states) > 1 {
+					tt.states[service] = states[1:]
+				}
+				return state, nil
+			}
+			observer.conditions = func(ctx context.Context) (map[string]string, error) {
+				return tt.conditions, tt.condErr
+			}
+
+			if got := observer.services(); strings.Join(got, ",") != "containerd,kubelet,node-problem-detector" {
+				t.Fatalf("services() = %v", got)
+			}
+			err := observer.Execute(context.Background())
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Execute() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Execute() expected an error containing %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Execute() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	output := `[{"type":"MemoryPressure","status":"False"},{"type":"Ready","status":"True","reason":"KubeletReady"}]`
+	conditions, err := parseConditions(output)
+	if err != nil || conditions["Ready"] != "True" || conditions["MemoryPressure"] != "False" {
+		t.Errorf("parseConditions() = %v, %v", conditions, err)
+	}
+	if conditions, err := parseConditions(""); err != nil || len(conditions) != 0 {
+		t.Errorf("parseConditions(\"\") = %v, %v, want no conditions", conditions, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
@@ -15,9 +16,9 @@ type UnInstaller struct {
 }
 
 // NewUnInstaller creates a new services unInstaller
-func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
 	return &UnInstaller{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -27,12 +28,12 @@ func (su *UnInstaller) GetName() string {
 	return "ServicesDisabled"
 }
 
-// Execute stops and disables services
+// Execute stops and disables services, leaving those of components components.enabled turns off alone
 func (su *UnInstaller) Execute(ctx context.Context) error {
 	su.logger.Info("Stopping and disabling services")
 
 	// Stop and disable kubelet
-	if utils.ServiceExists("kubelet") {
+	if su.config.IsComponentEnabled(config.ComponentKubelet) && utils.ServiceExists("kubelet") {
 		su.logger.Info("Stopping and disabling kubelet service")
 		if err := utils.StopService("kubelet"); err != nil {
 			su.logger.Warnf("Failed to stop kubelet: %v", err)
@@ -42,14 +43,15 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 		}
 	}
 
-	// Stop and disable containerd
-	if utils.ServiceExists("containerd") {
-		su.logger.Info("Stopping and disabling containerd service")
-		if err := utils.StopService("containerd"); err != nil {
-			su.logger.Warnf("Failed to stop containerd: %v", err)
+	// Stop and disable the container runtime
+	runtime := container_runtime.ForConfig(su.config).ServiceName()
+	if su.config.IsComponentEnabled(su.config.GetContainerRuntime()) && utils.ServiceExists(runtime) {
+		su.logger.Infof("Stopping and disabling %s service", runtime)
+		if err := utils.StopService(runtime); err != nil {
+			su.logger.Warnf("Failed to stop %s: %v", runtime, err)
 		}
-		if err := utils.DisableService("containerd"); err != nil {
-			su.logger.Warnf("Failed to disable containerd: %v", err)
+		if err := utils.DisableService(runtime); err != nil {
+			su.logger.Warnf("Failed to disable %s: %v", runtime, err)
 		}
 	}
 
@@ -60,5 +62,5 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 // IsCompleted checks if services have been stopped and disabled
 func (su *UnInstaller) IsCompleted(ctx context.Context) bool {
 	// Services are considered Executeed if they are not active
-	return !utils.IsServiceActive("containerd") && !utils.IsServiceActive("kubelet")
+	return !utils.IsServiceActive(container_runtime.ForConfig(su.config).ServiceName()) && !utils.IsServiceActive("kubelet")
 }
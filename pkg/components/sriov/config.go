@@ -0,0 +1,116 @@
+package sriov
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+)
+
+// devicePluginConfig is the SR-IOV network device plugin's resource list
+type devicePluginConfig struct {
+	ResourceList []devicePluginResource `json:"resourceList"`
+}
+
+// devicePluginResource advertises the VFs of the selected physical functions as a resource
+type devicePluginResource struct {
+	ResourceName string                `json:"resourceName"`
+	Selectors    devicePluginSelectors `json:"selectors"`
+}
+
+type devicePluginSelectors struct {
+	PFNames []string `json:"pfNames"`
+	Drivers []string `json:"drivers,omitempty"`
+}
+
+// renderScript renders the script creating the VFs and binding their drivers
+func renderScript(cfg *config.Config) (string, error) {
+	return templates.Render(cfg, templates.SRIOVSetup, struct{ NICs []config.SRIOVNICConfig }{NICs: cfg.SRIOV.NICs})
+}
+
+// renderService renders the unit running the script at boot
+func renderService(cfg *config.Config) (string, error) {
+	return templates.Render(cfg, templates.SRIOVService, struct{ Script string }{Script: scriptPath})
+}
+
+// renderDevicePluginConfig renders one device plugin resource per NIC
+func renderDevicePluginConfig(cfg *config.Config) (string, error) {
+	resources := make([]devicePluginResource, 0, len(cfg.SRIOV.NICs))
+	for _, nic := range cfg.SRIOV.NICs {
+		resource := devicePluginResource{
+			ResourceName: cfg.GetSRIOVResourceName(nic),
+			Selectors:    devicePluginSelectors{PFNames: []string{nic.Interface}},
+		}
+		if nic.Driver != "" {
+			resource.Selectors.Drivers = []string{nic.Driver}
+		}
+		resources = append(resources, resource)
+	}
+	data, err := json.MarshalIndent(devicePluginConfig{ResourceList: resources}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the SR-IOV device plugin configuration: %w", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// deviceDir returns the sysfs directory of the PCI device behind a network interface
+func deviceDir(iface string) string {
+	return filepath.Join(sysfsRoot, "class/net", iface, "device")
+}
+
+// checkNIC verifies the interface is an SR-IOV physical function supporting the requested VFs
+func checkNIC(nic config.SRIOVNICConfig) error {
+	if _, err := os.Stat(filepath.Join(sysfsRoot, "class/net", nic.Interface)); err != nil {
+		return fmt.Errorf("network interface %s does not exist", nic.Interface)
+	}
+	total, err := readInt(filepath.Join(deviceDir(nic.Interface), "sriov_totalvfs"))
+	if err != nil {
+		return fmt.Errorf("network interface %s does not support SR-IOV; enable SR-IOV in the NIC firmware and BIOS", nic.Interface)
+	}
+	if nic.NumVFs > total {
+		return fmt.Errorf("network interface %s supports %d virtual functions, %d requested", nic.Interface, total, nic.NumVFs)
+	}
+	return nil
+}
+
+// checkIOMMU verifies the IOMMU is on, which vfio-pci needs to hand VFs to user space
+func checkIOMMU() error {
+	groups, err := os.ReadDir(filepath.Join(sysfsRoot, "kernel/iommu_groups"))
+	if err != nil || len(groups) == 0 {
+		return fmt.Errorf("vfio-pci needs the IOMMU; enable VT-d or AMD-Vi in the BIOS and add 'intel_iommu=on iommu=pt' " +
+			"or 'amd_iommu=on iommu=pt' to the kernel command line")
+	}
+	return nil
+}
+
+// nicConfigured reports whether the interface has the requested VFs, bound to the requested driver
+func nicConfigured(nic config.SRIOVNICConfig) bool {
+	current, err := readInt(filepath.Join(deviceDir(nic.Interface), "sriov_numvfs"))
+	if err != nil || current != nic.NumVFs {
+		return false
+	}
+	if nic.Driver == "" {
+		return true
+	}
+	vfs, _ := filepath.Glob(filepath.Join(deviceDir(nic.Interface), "virtfn*"))
+	for _, vf := range vfs {
+		driver, err := filepath.EvalSymlinks(filepath.Join(vf, "driver"))
+		if err != nil || filepath.Base(driver) != nic.Driver {
+			return false
+		}
+	}
+	return len(vfs) == nic.NumVFs
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,18 @@
+package sriov
+
+import "go.goms.io/aks/AKSFlexNode/pkg/osimage"
+
+const (
+	serviceName = "aks-flex-node-sriov"
+	servicePath = "/etc/systemd/system/aks-flex-node-sriov.service"
+
+	// Configuration of the SR-IOV network device plugin, read from the host by its DaemonSet
+	devicePluginConfigDir  = "/etc/pcidp"
+	devicePluginConfigPath = "/etc/pcidp/config.json"
+)
+
+// scriptPath is the boot-time setup script, in /opt/bin on immutable hosts
+var scriptPath = osimage.BinPath("/usr/local/sbin/aks-flex-node-sriov")
+
+// sysfsRoot is where sysfs is mounted; replaced in tests
+var sysfsRoot = "/sys"
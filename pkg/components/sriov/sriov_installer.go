@@ -0,0 +1,140 @@
+package sriov
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
+)
+
+// Installer creates the SR-IOV virtual functions declared in sriov.nics, binds their drivers and writes the
+// SR-IOV network device plugin configuration, when sriov.enabled is set. The VFs are created by a oneshot
+// service so they come back on every boot, before kubelet starts.
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new SR-IOV Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "SRIOV_Installer"
+}
+
+// ManagedFiles returns the files the step writes
+func (i *Installer) ManagedFiles() []string {
+	if !i.config.SRIOV.Enabled {
+		return nil
+	}
+	return []string{scriptPath, servicePath, devicePluginConfigPath}
+}
+
+// Validate checks every NIC supports the requested VFs, and the IOMMU is on for VFs bound to vfio-pci
+func (i *Installer) Validate(ctx context.Context) error {
+	if !i.config.SRIOV.Enabled {
+		return nil
+	}
+	for _, nic := range i.config.SRIOV.NICs {
+		if err := checkNIC(nic); err != nil {
+			return err
+		}
+		if nic.Driver == "vfio-pci" {
+			if err := checkIOMMU(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsCompleted returns true when SR-IOV is disabled, or the files are current and every NIC is configured
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.config.SRIOV.Enabled {
+		return true
+	}
+	desired, err := i.desiredFiles()
+	if err != nil {
+		return false
+	}
+	for path, content := range desired {
+		current, err := os.ReadFile(path)
+		if err != nil || string(current) != content {
+			return false
+		}
+	}
+	for _, nic := range i.config.SRIOV.NICs {
+		if !nicConfigured(nic) {
+			return false
+		}
+	}
+	return utils.IsServiceEnabled(serviceName)
+}
+
+// Execute writes the script, unit and device plugin configuration and runs the script through the unit
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.SRIOV.Enabled {
+		return nil
+	}
+	desired, err := i.desiredFiles()
+	if err != nil {
+		return err
+	}
+
+	if err := utils.RunSystemCommand("mkdir", "-p", devicePluginConfigDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", devicePluginConfigDir, err)
+	}
+	if err := utils.WriteFileAtomicSystem(scriptPath, []byte(desired[scriptPath]), 0o755); err != nil {
+		return fmt.Errorf("failed to write %s: %w", scriptPath, err)
+	}
+	if err := validation.WriteFile(servicePath, desired[servicePath], 0o644, validation.SystemdUnit, i.logger); err != nil {
+		return fmt.Errorf("failed to write %s: %w", servicePath, err)
+	}
+	if err := validation.WriteFile(devicePluginConfigPath, desired[devicePluginConfigPath], 0o644, validation.JSON, i.logger); err != nil {
+		return fmt.Errorf("failed to write %s: %w", devicePluginConfigPath, err)
+	}
+
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	if err := utils.EnableAndStartService(serviceName); err != nil {
+		return fmt.Errorf("failed to enable %s: %w", serviceName, err)
+	}
+	// The oneshot unit stays active after its first run, so restart it to apply a changed layout
+	if err := utils.RestartService(serviceName); err != nil {
+		return fmt.Errorf("failed to create the SR-IOV virtual functions, see 'journalctl -u %s': %w", serviceName, err)
+	}
+
+	for _, nic := range i.config.SRIOV.NICs {
+		i.logger.Infof("Created %d virtual functions on %s, advertised as %s", nic.NumVFs, nic.Interface, i.config.GetSRIOVResourceName(nic))
+	}
+	return nil
+}
+
+// desiredFiles renders the contents of the files the step writes, by path
+func (i *Installer) desiredFiles() (map[string]string, error) {
+	script, err := renderScript(i.config)
+	if err != nil {
+		return nil, err
+	}
+	service, err := renderService(i.config)
+	if err != nil {
+		return nil, err
+	}
+	devicePlugin, err := renderDevicePluginConfig(i.config)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{scriptPath: script, servicePath: service, devicePluginConfigPath: devicePlugin}, nil
+}
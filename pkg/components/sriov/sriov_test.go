@@ -0,0 +1,139 @@
+package sriov
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{SRIOV: config.SRIOVConfig{Enabled: true, NICs: []config.SRIOVNICConfig{
+		{Interface: "ens1f0", NumVFs: 8},
+		{Interface: "ens1f1", NumVFs: 2, Driver: "vfio-pci", ResourceName: "dpdk_net"},
+	}}}
+}
+
+func TestRenderScript(t *testing.T) {
+	script, err := renderScript(testConfig())
+	if err != nil {
+		t.Fatalf("renderScript() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(script, "#!/bin/sh\n") {
+		t.Errorf("renderScript() does not start with the shebang:\n%s", script)
+	}
+	for _, want := range []string{"configure_vfs ens1f0 8\n", "configure_vfs ens1f1 2\nbind_vfs ens1f1 vfio-pci\n"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("renderScript() missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "bind_vfs ens1f0") {
+		t.Errorf("renderScript() binds the VFs of a NIC without a driver:\n%s", script)
+	}
+}
+
+func TestRenderDevicePluginConfig(t *testing.T) {
+	got, err := renderDevicePluginConfig(testConfig())
+	if err != nil {
+		t.Fatalf("renderDevicePluginConfig() unexpected error: %v", err)
+	}
+	for _, want := range []string{`"resourceName": "sriov_ens1f0"`, `"resourceName": "dpdk_net"`, `"drivers": [`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderDevicePluginConfig() missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, `"drivers"`) != 1 {
+		t.Errorf("renderDevicePluginConfig() selects a driver for a NIC without one:\n%s", got)
+	}
+}
+
+// writeNIC creates a fake physical function with total supported and num created VFs bound to driver
+func writeNIC(t *testing.T, iface string, total, num int, driver string) {
+	t.Helper()
+	device := filepath.Join(sysfsRoot, "class/net", iface, "device")
+	if err := os.MkdirAll(device, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"sriov_totalvfs": strconv.Itoa(total), "sriov_numvfs": strconv.Itoa(num)}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(device, name), []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for vf := 0; vf < num; vf++ {
+		driverDir := filepath.Join(sysfsRoot, "bus/pci/drivers", driver)
+		vfDir := filepath.Join(device, "virtfn"+strconv.Itoa(vf))
+		if err := os.MkdirAll(driverDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(vfDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(driverDir, filepath.Join(vfDir, "driver")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func useSysfs(t *testing.T) {
+	original := sysfsRoot
+	sysfsRoot = t.TempDir()
+	t.Cleanup(func() { sysfsRoot = original })
+}
+
+func TestCheckNIC(t *testing.T) {
+	useSysfs(t)
+	writeNIC(t, "ens1f0", 8, 0, "")
+	if err := os.MkdirAll(filepath.Join(sysfsRoot, "class/net/eth0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		nic     config.SRIOVNICConfig
+		wantErr string
+	}{
+		{name: "supported", nic: config.SRIOVNICConfig{Interface: "ens1f0", NumVFs: 8}},
+		{name: "too many VFs", nic: config.SRIOVNICConfig{Interface: "ens1f0", NumVFs: 9}, wantErr: "supports 8 virtual functions"},
+		{name: "no SR-IOV", nic: config.SRIOVNICConfig{Interface: "eth0", NumVFs: 1}, wantErr: "does not support SR-IOV"},
+		{name: "missing interface", nic: config.SRIOVNICConfig{Interface: "ens9", NumVFs: 1}, wantErr: "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkNIC(tt.nic)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkNIC() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkNIC() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNICConfigured(t *testing.T) {
+	useSysfs(t)
+	writeNIC(t, "ens1f0", 8, 2, "iavf")
+	writeNIC(t, "ens1f1", 8, 2, "vfio-pci")
+
+	tests := []struct {
+		nic  config.SRIOVNICConfig
+		want bool
+	}{
+		{nic: config.SRIOVNICConfig{Interface: "ens1f0", NumVFs: 2}, want: true},
+		{nic: config.SRIOVNICConfig{Interface: "ens1f0", NumVFs: 4}, want: false},
+		{nic: config.SRIOVNICConfig{Interface: "ens1f0", NumVFs: 2, Driver: "vfio-pci"}, want: false},
+		{nic: config.SRIOVNICConfig{Interface: "ens1f1", NumVFs: 2, Driver: "vfio-pci"}, want: true},
+	}
+	for _, tt := range tests {
+		if got := nicConfigured(tt.nic); got != tt.want {
+			t.Errorf("nicConfigured(%+v) = %v, want %v", tt.nic, got, tt.want)
+		}
+	}
+}
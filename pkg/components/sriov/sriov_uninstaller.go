@@ -0,0 +1,66 @@
+package sriov
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes the SR-IOV service, script and device plugin configuration and the VFs of the
+// configured NICs
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new SR-IOV UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "SRIOV_UnInstaller"
+}
+
+// Execute disables the service, removes the VFs and deletes the files the installer wrote
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	// Leave SR-IOV set up by something else alone
+	if !utils.FileExists(servicePath) {
+		return nil
+	}
+	u.logger.Info("Removing SR-IOV configuration")
+
+	if err := utils.DisableService(serviceName); err != nil {
+		u.logger.Warnf("Failed to disable %s: %v", serviceName, err)
+	}
+	for _, nic := range u.config.SRIOV.NICs {
+		// Interface names were validated with the configuration, so they are safe in the command
+		numVFs := filepath.Join(deviceDir(nic.Interface), "sriov_numvfs")
+		if utils.FileExists(numVFs) {
+			if err := utils.RunSystemCommand("sh", "-c", fmt.Sprintf("echo 0 > %s", numVFs)); err != nil {
+				u.logger.Warnf("Failed to remove the virtual functions of %s: %v", nic.Interface, err)
+			}
+		}
+	}
+	for _, err := range utils.RemoveFiles([]string{servicePath, scriptPath, devicePluginConfigPath}, u.logger) {
+		u.logger.Warnf("Failed to remove SR-IOV file: %v", err)
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		u.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when the SR-IOV service is gone
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !utils.FileExists(servicePath)
+}
@@ -0,0 +1,231 @@
+package ssh_hardening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/hybridconnectivity"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// breakGlassKeys reads and checks the public keys allowed to log in as the break-glass user
+func breakGlassKeys(cfg *config.Config) (string, error) {
+	path := cfg.SSH.BreakGlass.AuthorizedKeysFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read break-glass authorized keys file %s: %w", path, err)
+	}
+	keys, err := renderAuthorizedKeys(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid break-glass authorized keys file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// checkBreakGlassUser fails when the break-glass user exists but was not created by the agent, as
+// unbootstrap would otherwise delete or change an account it does not own
+func checkBreakGlassUser(user string, st *state) error {
+	if userExists(user) && st.CreatedUser != user {
+		return fmt.Errorf("user %s already exists and was not created by aks-flex-node; choose another ssh.breakGlass.user", user)
+	}
+	return nil
+}
+
+func userExists(user string) bool {
+	_, err := utils.RunCommandWithOutput("id", "-u", user)
+	return err == nil
+}
+
+// setUpBreakGlassUser creates the break-glass user and installs its keys and sudo rule
+func setUpBreakGlassUser(cfg *config.Config, logger *logrus.Logger, st *state) error {
+	user := cfg.GetSSHBreakGlassUser()
+	keys, err := breakGlassKeys(cfg)
+	if err != nil {
+		return err
+	}
+	if st.CreatedUser != "" && st.CreatedUser != user {
+		// ssh.breakGlass.user changed; the old user is no longer needed
+		if err := deleteUser(st); err != nil {
+			return err
+		}
+	}
+	if err := checkBreakGlassUser(user, st); err != nil {
+		return err
+	}
+
+	if !userExists(user) {
+		logger.Infof("Creating break-glass user %s", user)
+		// A password of * cannot be used to log in but, unlike a locked account, still allows key authentication
+		if err := utils.RunSystemCommand("useradd", "--create-home", "--shell", "/bin/bash", "--password", "*",
+			"--comment", "AKS flex node break-glass access", user); err != nil {
+			return fmt.Errorf("failed to create break-glass user %s: %w", user, err)
+		}
+		st.CreatedUser = user
+		if err := st.save(); err != nil {
+			return err
+		}
+	}
+
+	if err := utils.WriteFileAtomicSystem(breakGlassKeysPath, []byte(keys), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", breakGlassKeysPath, err)
+	}
+
+	if !cfg.SSH.BreakGlass.Sudo {
+		return utils.RunCleanupCommand(breakGlassSudoersPath)
+	}
+	sudoers := fmt.Sprintf("# Generated by aks-flex-node\n%s ALL=(ALL) NOPASSWD:ALL\n", user)
+	if err := utils.WriteFileAtomicSystem(breakGlassSudoersStagingPath, []byte(sudoers), 0o440); err != nil {
+		return fmt.Errorf("failed to write %s: %w", breakGlassSudoersStagingPath, err)
+	}
+	// A broken sudoers file disables sudo for everyone, including the agent, so it is checked before it is used
+	if err := utils.RunSystemCommand("visudo", "-cf", breakGlassSudoersStagingPath); err != nil {
+		_ = utils.RunCleanupCommand(breakGlassSudoersStagingPath)
+		return fmt.Errorf("sudo rejected the break-glass rule: %w", err)
+	}
+	if err := utils.RunSystemCommand("mv", breakGlassSudoersStagingPath, breakGlassSudoersPath); err != nil {
+		return fmt.Errorf("failed to install %s: %w", breakGlassSudoersPath, err)
+	}
+	return nil
+}
+
+// setUpArcAccess lets the Arc agent forward connections to the SSH port and configures SSH on the
+// machine's connectivity endpoint, which `az ssh arc` connects through
+func setUpArcAccess(ctx context.Context, cfg *config.Config, logger *logrus.Logger, st *state) error {
+	output, err := utils.RunCommandWithOutput("azcmagent", "config", "get", incomingPortsSetting)
+	if err != nil {
+		return fmt.Errorf("failed to read the Arc agent incoming ports: %w", err)
+	}
+	ports := parseIncomingPorts(output)
+	port := strconv.Itoa(sshListeningPort)
+	if !slices.Contains(ports, port) {
+		if !st.IncomingPortsChanged {
+			st.PreviousIncomingPorts = ports
+		}
+		logger.Infof("Allowing Arc connections to port %s", port)
+		if err := utils.RunSystemCommand("azcmagent", "config", "set", incomingPortsSetting, strings.Join(append(ports, port), ",")); err != nil {
+			return fmt.Errorf("failed to allow Arc connections to port %s: %w", port, err)
+		}
+		st.IncomingPortsChanged = true
+		if err := st.save(); err != nil {
+			return err
+		}
+	}
+
+	if st.EndpointConfigured {
+		return nil
+	}
+	client, err := newEndpointClient(cfg)
+	if err != nil {
+		return err
+	}
+	opCtx, cancel := auth.OperationContext(ctx, cfg)
+	defer cancel()
+	created, err := client.EnsureSSHEndpoint(opCtx, cfg.GetSubscriptionID(), cfg.GetArcResourceGroup(), cfg.GetArcMachineName(), sshListeningPort)
+	if err != nil {
+		return azerrors.Wrap(err)
+	}
+	st.EndpointConfigured, st.EndpointCreated = true, created
+	return st.save()
+}
+
+// revertBreakGlass undoes what break-glass setup changed, as recorded in st. It carries on past failures
+// so as much as possible is reverted, and keeps what could not be reverted recorded for the next attempt.
+func revertBreakGlass(ctx context.Context, cfg *config.Config, logger *logrus.Logger, st *state) error {
+	var errs []error
+
+	if st.EndpointConfigured {
+		if st.EndpointCreated {
+			logger.Info("Removing SSH from the Arc connectivity endpoint")
+			if err := deleteEndpoint(ctx, cfg); err != nil {
+				errs = append(errs, err)
+			} else {
+				st.EndpointConfigured, st.EndpointCreated = false, false
+			}
+		} else {
+			st.EndpointConfigured = false
+		}
+	}
+
+	if st.IncomingPortsChanged {
+		if err := restoreIncomingPorts(st.PreviousIncomingPorts); err != nil {
+			errs = append(errs, err)
+		} else {
+			st.IncomingPortsChanged, st.PreviousIncomingPorts = false, nil
+		}
+	}
+
+	for _, path := range []string{breakGlassSudoersPath, breakGlassSudoersStagingPath, breakGlassKeysPath} {
+		if err := utils.RunCleanupCommand(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := deleteUser(st); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := st.save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// restoreIncomingPorts puts back the Arc agent incoming ports found before break-glass setup
+func restoreIncomingPorts(previous []string) error {
+	// Nothing to restore once the Arc agent is gone
+	if !utils.BinaryExists("azcmagent") {
+		return nil
+	}
+	args := []string{"config", "clear", incomingPortsSetting}
+	if len(previous) > 0 {
+		args = []string{"config", "set", incomingPortsSetting, strings.Join(previous, ",")}
+	}
+	if err := utils.RunSystemCommand("azcmagent", args...); err != nil {
+		return fmt.Errorf("failed to restore the Arc agent incoming ports: %w", err)
+	}
+	return nil
+}
+
+// deleteUser removes the user the agent created, with its home directory
+func deleteUser(st *state) error {
+	if st.CreatedUser == "" {
+		return nil
+	}
+	if userExists(st.CreatedUser) {
+		if err := utils.RunSystemCommand("userdel", "--remove", st.CreatedUser); err != nil {
+			return fmt.Errorf("failed to delete break-glass user %s: %w", st.CreatedUser, err)
+		}
+	}
+	st.CreatedUser = ""
+	return st.save()
+}
+
+func deleteEndpoint(ctx context.Context, cfg *config.Config) error {
+	client, err := newEndpointClient(cfg)
+	if err != nil {
+		return err
+	}
+	opCtx, cancel := auth.OperationContext(ctx, cfg)
+	defer cancel()
+	if err := client.DeleteSSHEndpoint(opCtx, cfg.GetSubscriptionID(), cfg.GetArcResourceGroup(), cfg.GetArcMachineName()); err != nil {
+		return azerrors.Wrap(err)
+	}
+	return nil
+}
+
+func newEndpointClient(cfg *config.Config) (*hybridconnectivity.Client, error) {
+	cred, err := auth.NewAuthProvider().UserCredential(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return hybridconnectivity.NewClient(cred, auth.ARMClientOptions(cfg))
+}
@@ -0,0 +1,150 @@
+package ssh_hardening
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// setting is an sshd keyword and its value
+type setting struct {
+	keyword string
+	value   string
+}
+
+// profileSettings returns the sshd settings of a hardening profile. Both profiles only accept public keys.
+func profileSettings(profile string) []setting {
+	settings := []setting{
+		{"PasswordAuthentication", "no"},
+		{"KbdInteractiveAuthentication", "no"},
+		{"PermitEmptyPasswords", "no"},
+		{"PubkeyAuthentication", "yes"},
+	}
+	if profile != "strict" {
+		return append(settings, setting{"PermitRootLogin", "prohibit-password"})
+	}
+	return append(settings,
+		setting{"PermitRootLogin", "no"},
+		setting{"MaxAuthTries", "3"},
+		setting{"LoginGraceTime", "30"},
+		setting{"X11Forwarding", "no"},
+		setting{"AllowAgentForwarding", "no"},
+		setting{"AllowTcpForwarding", "no"},
+		setting{"ClientAliveInterval", "300"},
+		setting{"ClientAliveCountMax", "2"},
+	)
+}
+
+// renderDropIn renders the sshd drop-in for the SSH configuration. breakGlassUser is empty when break-glass
+// access is not configured; otherwise the user may always log in, with the keys in breakGlassKeysPath only.
+func renderDropIn(cfg config.SSHConfig, profile, breakGlassUser string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by aks-flex-node. Do not edit; changes are overwritten on the next bootstrap.\n")
+	fmt.Fprintf(&b, "# Hardening profile: %s\n", profile)
+	for _, s := range profileSettings(profile) {
+		fmt.Fprintf(&b, "%s %s\n", s.keyword, s.value)
+	}
+
+	if len(cfg.AllowUsers) > 0 {
+		users := slices.Clone(cfg.AllowUsers)
+		if breakGlassUser != "" && !slices.Contains(users, breakGlassUser) {
+			users = append(users, breakGlassUser)
+		}
+		fmt.Fprintf(&b, "AllowUsers %s\n", strings.Join(users, " "))
+	}
+	if cfg.Banner != "" {
+		fmt.Fprintf(&b, "Banner %s\n", sshdBannerPath)
+	}
+
+	if breakGlassUser != "" {
+		// Match applies to everything after it, including the main sshd_config once this file is included;
+		// Match all ends the block
+		fmt.Fprintf(&b, "\nMatch User %s\n    AuthorizedKeysFile %s\nMatch all\n", breakGlassUser, breakGlassKeysPath)
+	}
+	return b.String()
+}
+
+// renderBanner renders the banner text, which sshd sends as is
+func renderBanner(banner string) string {
+	return strings.TrimRight(banner, "\n") + "\n"
+}
+
+// effectiveMismatches compares the profile settings with the effective configuration printed by `sshd -T`,
+// which differ when sshd_config sets them before including the drop-ins
+func effectiveMismatches(effective string, settings []setting) []string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(effective))
+	for scanner.Scan() {
+		keyword, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if found {
+			values[strings.ToLower(keyword)] = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+
+	var mismatches []string
+	for _, s := range settings {
+		value, ok := values[strings.ToLower(s.keyword)]
+		if !ok {
+			// Older sshd versions do not know every keyword
+			continue
+		}
+		want := strings.ToLower(s.value)
+		// sshd -T prints the old name of prohibit-password on some versions
+		if value == want || (want == "prohibit-password" && value == "without-password") {
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s is %s instead of %s", s.keyword, value, s.value))
+	}
+	return mismatches
+}
+
+// renderAuthorizedKeys checks each key of an authorized_keys file and returns the keys without comments
+// and blank lines
+func renderAuthorizedKeys(data []byte) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Generated by aks-flex-node from ssh.breakGlass.authorizedKeysFile\n")
+	keys := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(text)); err != nil {
+			return "", fmt.Errorf("line %d is not a valid public key: %w", line, err)
+		}
+		b.WriteString(text + "\n")
+		keys++
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if keys == 0 {
+		return "", fmt.Errorf("no public keys found")
+	}
+	return b.String(), nil
+}
+
+var portPattern = regexp.MustCompile(`\d+`)
+
+// parseIncomingPorts reads the ports from the output of `azcmagent config get incomingconnections.ports`,
+// which prints the setting name and a list such as [22,3389]
+func parseIncomingPorts(output string) []string {
+	if i := strings.LastIndex(output, ":"); i >= 0 {
+		output = output[i+1:]
+	}
+	var ports []string
+	for _, port := range portPattern.FindAllString(output, -1) {
+		if n, err := strconv.Atoi(port); err == nil && n > 0 && n <= 65535 {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
@@ -0,0 +1,118 @@
+package ssh_hardening
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderDropIn(t *testing.T) {
+	t.Run("baseline", func(t *testing.T) {
+		dropIn := renderDropIn(config.SSHConfig{}, "baseline", "")
+		for _, want := range []string{"PasswordAuthentication no\n", "KbdInteractiveAuthentication no\n", "PermitRootLogin prohibit-password\n"} {
+			if !strings.Contains(dropIn, want) {
+				t.Errorf("renderDropIn() missing %q:\n%s", want, dropIn)
+			}
+		}
+		for _, unwanted := range []string{"AllowUsers", "Banner", "Match", "AllowTcpForwarding"} {
+			if strings.Contains(dropIn, unwanted) {
+				t.Errorf("renderDropIn() sets %s, which was not configured:\n%s", unwanted, dropIn)
+			}
+		}
+	})
+
+	t.Run("strict with break glass", func(t *testing.T) {
+		cfg := config.SSHConfig{AllowUsers: []string{"azureuser"}, Banner: "Authorized use only"}
+		dropIn := renderDropIn(cfg, "strict", "aks-breakglass")
+		for _, want := range []string{
+			"PermitRootLogin no\n",
+			"AllowTcpForwarding no\n",
+			"AllowUsers azureuser aks-breakglass\n",
+			"Banner " + sshdBannerPath + "\n",
+			"Match User aks-breakglass\n    AuthorizedKeysFile " + breakGlassKeysPath + "\n",
+		} {
+			if !strings.Contains(dropIn, want) {
+				t.Errorf("renderDropIn() missing %q:\n%s", want, dropIn)
+			}
+		}
+		if strings.Contains(dropIn, "prohibit-password") {
+			t.Errorf("renderDropIn() sets PermitRootLogin twice; sshd keeps the first:\n%s", dropIn)
+		}
+		// The main sshd_config continues after the included drop-in, so the Match block must be closed
+		if !strings.HasSuffix(dropIn, "Match all\n") {
+			t.Errorf("renderDropIn() does not end the Match block:\n%s", dropIn)
+		}
+	})
+
+	t.Run("break glass user already allowed", func(t *testing.T) {
+		dropIn := renderDropIn(config.SSHConfig{AllowUsers: []string{"aks-breakglass"}}, "baseline", "aks-breakglass")
+		if !strings.Contains(dropIn, "AllowUsers aks-breakglass\n") {
+			t.Errorf("renderDropIn() repeats the break-glass user:\n%s", dropIn)
+		}
+	})
+}
+
+func TestEffectiveMismatches(t *testing.T) {
+	effective := "port 22\npasswordauthentication yes\nkbdinteractiveauthentication no\npermitemptypasswords no\n" +
+		"pubkeyauthentication yes\npermitrootlogin without-password\n"
+	mismatches := effectiveMismatches(effective, profileSettings("baseline"))
+	if len(mismatches) != 1 || mismatches[0] != "PasswordAuthentication is yes instead of no" {
+		t.Errorf("effectiveMismatches() = %q, want only the password authentication override", mismatches)
+	}
+
+	// Keywords the installed sshd does not print are not reported
+	if mismatches := effectiveMismatches("passwordauthentication no\n", profileSettings("strict")); len(mismatches) != 0 {
+		t.Errorf("effectiveMismatches() = %q, want none", mismatches)
+	}
+}
+
+func TestRenderAuthorizedKeys(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + " ops@example"
+
+	keys, err := renderAuthorizedKeys([]byte("# on-call team\n\n" + line + "\n"))
+	if err != nil {
+		t.Fatalf("renderAuthorizedKeys() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(keys, "\n"+line+"\n") || strings.Contains(keys, "on-call") {
+		t.Errorf("renderAuthorizedKeys() = %q, want the key without comments", keys)
+	}
+
+	for name, data := range map[string]string{
+		"empty":     "# no keys yet\n",
+		"not a key": line + "\nssh-rsa not-base64\n",
+	} {
+		if _, err := renderAuthorizedKeys([]byte(data)); err == nil {
+			t.Errorf("renderAuthorizedKeys(%s) expected error", name)
+		}
+	}
+}
+
+func TestParseIncomingPorts(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "incomingconnections.ports : [22, 3389]\n", want: "22,3389"},
+		{output: "incomingconnections.ports : []\n", want: ""},
+		{output: "6516\n", want: "6516"},
+		{output: "incomingconnections.ports : [0, 70000]\n", want: ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(parseIncomingPorts(tt.output), ","); got != tt.want {
+			t.Errorf("parseIncomingPorts(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package ssh_hardening
+
+const (
+	// The settings are a drop-in so the distribution's sshd_config stays untouched. sshd keeps the first value it
+	// reads for most keywords and includes drop-ins in lexical order, so the 00- prefix wins over the drop-ins
+	// cloud-init and images ship, such as 50-cloud-init.conf enabling password authentication.
+	sshdConfigPath   = "/etc/ssh/sshd_config"
+	sshdDropInDir    = "/etc/ssh/sshd_config.d"
+	sshdDropInPath   = "/etc/ssh/sshd_config.d/00-aks-flex-node.conf"
+	sshdBannerPath   = "/etc/ssh/aks-flex-node-banner"
+	sshdBinaryPath   = "/usr/sbin/sshd"
+	sshListeningPort = 22
+
+	// The break-glass keys are owned by root outside the user's home, so the user cannot add keys of its own
+	breakGlassKeysPath    = "/etc/ssh/aks-flex-node-breakglass-keys"
+	breakGlassSudoersPath = "/etc/sudoers.d/aks-flex-node-breakglass"
+	// The rule is checked at a staging path before it is moved into place. sudo skips files in sudoers.d whose
+	// names contain a dot, so a rule that fails the check is never read, and the move stays on one file system.
+	breakGlassSudoersStagingPath = "/etc/sudoers.d/.aks-flex-node-breakglass.new"
+
+	// statePath records what break-glass setup changed, so unbootstrap reverts exactly that
+	statePath = "/var/lib/aks-flex-node/ssh-hardening.json"
+
+	// Arc agent setting listing the local ports reachable through the Arc connectivity endpoint
+	incomingPortsSetting = "incomingconnections.ports"
+)
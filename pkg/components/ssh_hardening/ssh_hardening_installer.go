@@ -0,0 +1,201 @@
+package ssh_hardening
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Installer hardens the SSH server and sets up break-glass access through Arc when ssh.enabled is set
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new SSH hardening Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "SSHHardening_Installer"
+}
+
+// ManagedFiles returns the sshd drop-in, banner and break-glass files installed by this step
+func (i *Installer) ManagedFiles() []string {
+	return []string{sshdDropInPath, sshdBannerPath, breakGlassKeysPath, breakGlassSudoersPath}
+}
+
+// Validate checks that an SSH server is installed and the break-glass keys and user can be used
+func (i *Installer) Validate(ctx context.Context) error {
+	if !i.config.SSH.Enabled {
+		return nil
+	}
+	if !utils.FileExists(sshdBinaryPath) {
+		return fmt.Errorf("ssh.enabled is set but the OpenSSH server (%s) is not installed", sshdBinaryPath)
+	}
+	if !i.config.IsSSHBreakGlassEnabled() {
+		return nil
+	}
+	if _, err := breakGlassKeys(i.config); err != nil {
+		return err
+	}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	return checkBreakGlassUser(i.config.GetSSHBreakGlassUser(), st)
+}
+
+// IsCompleted returns true when SSH hardening is disabled, or sshd and break-glass access are set up as configured
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.config.SSH.Enabled {
+		return true
+	}
+	if !fileHasContent(sshdDropInPath, i.desiredDropIn()) {
+		return false
+	}
+	if i.config.SSH.Banner != "" && !fileHasContent(sshdBannerPath, renderBanner(i.config.SSH.Banner)) {
+		return false
+	}
+	st, err := loadState()
+	if err != nil {
+		return false
+	}
+	if !i.config.IsSSHBreakGlassEnabled() {
+		return st.isEmpty() && !utils.FileExists(breakGlassKeysPath)
+	}
+
+	keys, err := breakGlassKeys(i.config)
+	if err != nil || !fileHasContent(breakGlassKeysPath, keys) {
+		return false
+	}
+	return userExists(i.config.GetSSHBreakGlassUser()) &&
+		utils.FileExists(breakGlassSudoersPath) == i.config.SSH.BreakGlass.Sudo &&
+		st.EndpointConfigured
+}
+
+// Execute applies the hardening profile and sets up or removes break-glass access
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.SSH.Enabled {
+		return nil
+	}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	breakGlass := i.config.IsSSHBreakGlassEnabled()
+	if breakGlass {
+		// The user and its keys must exist before sshd lets the user in
+		if err := setUpBreakGlassUser(i.config, i.logger, st); err != nil {
+			return err
+		}
+	}
+
+	i.logger.Infof("Applying the %s SSH hardening profile", i.config.GetSSHProfile())
+	if err := i.configureSSHD(); err != nil {
+		return fmt.Errorf("SSH hardening failed: %w", err)
+	}
+
+	if !breakGlass {
+		if st.isEmpty() && !utils.FileExists(breakGlassKeysPath) {
+			return nil
+		}
+		i.logger.Info("Removing break-glass access, which is no longer configured")
+		return revertBreakGlass(ctx, i.config, i.logger, st)
+	}
+	if err := setUpArcAccess(ctx, i.config, i.logger, st); err != nil {
+		return fmt.Errorf("failed to set up break-glass access through Arc: %w", err)
+	}
+	i.logger.Infof("Break-glass access ready: az ssh arc --resource-group %s --name %s --local-user %s",
+		i.config.GetArcResourceGroup(), i.config.GetArcMachineName(), i.config.GetSSHBreakGlassUser())
+	return nil
+}
+
+// configureSSHD writes the banner and drop-in and reloads sshd. The previous drop-in is restored when sshd
+// rejects the new configuration or does not apply it, so a mistake never locks operators out.
+func (i *Installer) configureSSHD() error {
+	previous, readErr := os.ReadFile(sshdDropInPath)
+	restore := func() {
+		var err error
+		if readErr == nil {
+			err = utils.WriteFileAtomicSystem(sshdDropInPath, previous, 0o644)
+		} else {
+			err = utils.RunCleanupCommand(sshdDropInPath)
+		}
+		if err != nil {
+			i.logger.Warnf("Failed to restore %s: %v", sshdDropInPath, err)
+		}
+	}
+
+	if err := utils.RunSystemCommand("mkdir", "-p", sshdDropInDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", sshdDropInDir, err)
+	}
+	if banner := i.config.SSH.Banner; banner != "" {
+		if err := utils.WriteFileAtomicSystem(sshdBannerPath, []byte(renderBanner(banner)), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", sshdBannerPath, err)
+		}
+	}
+	if err := utils.WriteFileAtomicSystem(sshdDropInPath, []byte(i.desiredDropIn()), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sshdDropInPath, err)
+	}
+
+	if output, err := utils.RunCommandWithOutput("sshd", "-t"); err != nil {
+		restore()
+		return fmt.Errorf("sshd rejected the configuration: %s: %w", strings.TrimSpace(output), err)
+	}
+	effective, err := utils.RunCommandWithOutput("sshd", "-T")
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to read the effective sshd configuration: %w", err)
+	}
+	if mismatches := effectiveMismatches(effective, profileSettings(i.config.GetSSHProfile())); len(mismatches) > 0 {
+		restore()
+		return fmt.Errorf("%s overrides the hardening profile before including %s: %s",
+			sshdConfigPath, sshdDropInDir, strings.Join(mismatches, "; "))
+	}
+	if i.config.SSH.Banner == "" {
+		if err := utils.RunCleanupCommand(sshdBannerPath); err != nil {
+			return err
+		}
+	}
+	return reloadSSHD()
+}
+
+func (i *Installer) desiredDropIn() string {
+	user := ""
+	if i.config.IsSSHBreakGlassEnabled() {
+		user = i.config.GetSSHBreakGlassUser()
+	}
+	return renderDropIn(i.config.SSH, i.config.GetSSHProfile(), user)
+}
+
+// reloadSSHD makes a running sshd read its configuration again. Socket-activated sshd reads it on
+// the next connection, so an inactive service is left alone.
+func reloadSSHD() error {
+	service := "sshd"
+	if utils.ServiceExists("ssh") {
+		// Debian and Ubuntu name the service ssh
+		service = "ssh"
+	}
+	if err := utils.RunSystemCommand("systemctl", "try-reload-or-restart", service); err != nil {
+		return fmt.Errorf("failed to reload %s: %w", service, err)
+	}
+	return nil
+}
+
+func fileHasContent(path, content string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && string(data) == content
+}
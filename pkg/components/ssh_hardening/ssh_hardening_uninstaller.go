@@ -0,0 +1,64 @@
+package ssh_hardening
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller reverts SSH hardening and break-glass access, leaving sshd configured as before bootstrap
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new SSH hardening UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "SSHHardening_UnInstaller"
+}
+
+// Execute removes the sshd drop-in and banner and reverts break-glass access
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	if !utils.FileExists(sshdDropInPath) && st.isEmpty() && !utils.FileExists(breakGlassKeysPath) {
+		return nil
+	}
+	u.logger.Info("Reverting SSH hardening")
+
+	// sshd must stop referring to the break-glass keys before they are removed
+	for _, err := range utils.RemoveFiles([]string{sshdDropInPath, sshdBannerPath}, u.logger) {
+		u.logger.Debugf("Failed to remove SSH hardening file: %v", err)
+	}
+	if utils.FileExists(sshdBinaryPath) {
+		if err := reloadSSHD(); err != nil {
+			u.logger.Warnf("Failed to reload sshd: %v", err)
+		}
+	}
+
+	if err := revertBreakGlass(ctx, u.config, u.logger, st); err != nil {
+		u.logger.Warnf("Failed to fully revert break-glass access: %v", err)
+	}
+
+	u.logger.Info("SSH hardening reverted")
+	return nil
+}
+
+// IsCompleted returns true when neither the sshd drop-in nor break-glass access is left
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	st, err := loadState()
+	return err == nil && st.isEmpty() && !utils.FileExists(sshdDropInPath) && !utils.FileExists(breakGlassKeysPath)
+}
@@ -0,0 +1,67 @@
+package ssh_hardening
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// state records what break-glass setup changed outside of its own files, so it is reverted exactly and
+// nothing an operator set up before is removed
+type state struct {
+	// Local user created for break-glass access, deleted on revert
+	CreatedUser string `json:"createdUser,omitempty"`
+
+	// Whether the SSH port was added to the Arc agent's incoming ports, and the ports before that
+	IncomingPortsChanged  bool     `json:"incomingPortsChanged,omitempty"`
+	PreviousIncomingPorts []string `json:"previousIncomingPorts,omitempty"`
+
+	// Whether the SSH service configuration on the Arc connectivity endpoint is set up, and whether
+	// the agent created it rather than finding it
+	EndpointConfigured bool `json:"endpointConfigured,omitempty"`
+	EndpointCreated    bool `json:"endpointCreated,omitempty"`
+}
+
+// isEmpty reports whether nothing is left to revert
+func (s *state) isEmpty() bool {
+	return s.CreatedUser == "" && !s.IncomingPortsChanged && !s.EndpointConfigured
+}
+
+// loadState returns the recorded state, or an empty one when break-glass access was never set up
+func loadState() (*state, error) {
+	st := &state{}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", statePath, err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", statePath, err)
+	}
+	return st, nil
+}
+
+// save records the state, removing the file once nothing is left to revert
+func (s *state) save() error {
+	if s.isEmpty() {
+		return utils.RunCleanupCommand(statePath)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(statePath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(statePath), err)
+	}
+	if err := utils.WriteFileAtomicSystem(statePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", statePath, err)
+	}
+	return nil
+}
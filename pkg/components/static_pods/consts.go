@@ -0,0 +1,5 @@
+package static_pods
+
+// Manifests the agent writes are named with this prefix, so manifests written by others are never touched and
+// manifests of pods removed from the configuration are found
+const manifestPrefix = "aks-flex-node-"
@@ -0,0 +1,124 @@
+package static_pods
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+)
+
+// manifestData is available to static pod manifest templates
+type manifestData struct {
+	NodeName          string
+	DNSServiceIP      string
+	KubernetesVersion string
+}
+
+// podManifest holds the fields of a pod manifest the agent checks before kubelet sees it
+type podManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Name  string `yaml:"name"`
+			Image string `yaml:"image"`
+		} `yaml:"containers"`
+	} `yaml:"spec"`
+}
+
+// manifestPath returns where the manifest of the named static pod is written
+func manifestPath(cfg *config.Config, name string) string {
+	return filepath.Join(cfg.Paths.Kubernetes.ManifestsDir, manifestPrefix+name+".yaml")
+}
+
+// desiredManifests renders the configured static pods, by the path they are written to
+func desiredManifests(cfg *config.Config) (map[string]string, error) {
+	if len(cfg.StaticPods) == 0 {
+		return nil, nil
+	}
+	nodeName, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	data := manifestData{
+		NodeName:          strings.ToLower(nodeName),
+		DNSServiceIP:      cfg.Node.Kubelet.DNSServiceIP,
+		KubernetesVersion: cfg.GetKubernetesVersion(),
+	}
+
+	manifests := make(map[string]string, len(cfg.StaticPods))
+	for _, pod := range cfg.StaticPods {
+		text := pod.Manifest
+		if pod.ManifestFile != "" {
+			content, err := os.ReadFile(pod.ManifestFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifest of static pod %s: %w", pod.Name, err)
+			}
+			text = string(content)
+		}
+		rendered, err := templates.RenderWithDefault(cfg, "static-pod-"+pod.Name, text, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render manifest of static pod %s: %w", pod.Name, err)
+		}
+		if err := checkManifest(rendered); err != nil {
+			return nil, fmt.Errorf("invalid manifest of static pod %s: %w", pod.Name, err)
+		}
+		manifests[manifestPath(cfg, pod.Name)] = rendered
+	}
+	return manifests, nil
+}
+
+// checkManifest checks a manifest is a single v1 Pod with containers, which kubelet would otherwise skip with
+// nothing but a log line
+func checkManifest(manifest string) error {
+	var pod podManifest
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	if err := decoder.Decode(&pod); err != nil {
+		return err
+	}
+	var next any
+	if err := decoder.Decode(&next); err == nil {
+		return fmt.Errorf("expected a single document")
+	}
+	switch {
+	case pod.APIVersion != "v1" || pod.Kind != "Pod":
+		return fmt.Errorf("expected apiVersion v1 and kind Pod, got %s %s", pod.APIVersion, pod.Kind)
+	case pod.Metadata.Name == "":
+		return fmt.Errorf("metadata.name is required")
+	case len(pod.Spec.Containers) == 0:
+		return fmt.Errorf("spec.containers is required")
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "" || container.Image == "" {
+			return fmt.Errorf("every container needs a name and an image")
+		}
+	}
+	return nil
+}
+
+// ownedManifests returns the manifests the agent wrote to the manifests directory
+func ownedManifests(cfg *config.Config) []string {
+	paths, _ := filepath.Glob(filepath.Join(cfg.Paths.Kubernetes.ManifestsDir, manifestPrefix+"*.yaml"))
+	sort.Strings(paths)
+	return paths
+}
+
+// staleManifests returns the manifests the agent wrote for static pods that are no longer configured
+func staleManifests(cfg *config.Config, desired map[string]string) []string {
+	var stale []string
+	for _, path := range ownedManifests(cfg) {
+		if _, ok := desired[path]; !ok {
+			stale = append(stale, path)
+		}
+	}
+	return stale
+}
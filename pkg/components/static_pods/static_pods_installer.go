@@ -0,0 +1,99 @@
+package static_pods
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Installer writes the manifests of the configured static pods to kubelet's manifests directory, before
+// kubelet starts, and removes the manifests of static pods no longer configured
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new static pods Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "StaticPods_Installer"
+}
+
+// ManagedFiles returns the manifests of the configured static pods
+func (i *Installer) ManagedFiles() []string {
+	paths := make([]string, 0, len(i.config.StaticPods))
+	for _, pod := range i.config.StaticPods {
+		paths = append(paths, manifestPath(i.config, pod.Name))
+	}
+	return paths
+}
+
+// Validate renders the manifests and checks they are pods kubelet can run
+func (i *Installer) Validate(ctx context.Context) error {
+	_, err := desiredManifests(i.config)
+	return err
+}
+
+// IsCompleted returns true when the written manifests match the configured static pods
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	desired, err := desiredManifests(i.config)
+	if err != nil {
+		return false
+	}
+	for path, manifest := range desired {
+		current, err := os.ReadFile(path)
+		if err != nil || string(current) != manifest {
+			return false
+		}
+	}
+	return len(staleManifests(i.config, desired)) == 0
+}
+
+// Execute writes the manifests; kubelet picks up changes to its manifests directory by itself
+func (i *Installer) Execute(ctx context.Context) error {
+	desired, err := desiredManifests(i.config)
+	if err != nil {
+		return err
+	}
+	if len(desired) > 0 {
+		if err := utils.RunSystemCommand("mkdir", "-p", i.config.Paths.Kubernetes.ManifestsDir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", i.config.Paths.Kubernetes.ManifestsDir, err)
+		}
+	}
+
+	paths := make([]string, 0, len(desired))
+	for path := range desired {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if current, err := os.ReadFile(path); err == nil && string(current) == desired[path] {
+			continue
+		}
+		i.logger.Infof("Writing static pod manifest %s", path)
+		if err := utils.WriteFileAtomicSystem(path, []byte(desired[path]), 0o644); err != nil {
+			return fmt.Errorf("failed to write static pod manifest %s: %w", path, err)
+		}
+	}
+
+	for _, path := range staleManifests(i.config, desired) {
+		i.logger.Infof("Removing static pod manifest %s, the pod is no longer configured", path)
+		if err := utils.RunCleanupCommand(path); err != nil {
+			return fmt.Errorf("failed to remove static pod manifest %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package static_pods
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const nodeLocalDNS = `apiVersion: v1
+kind: Pod
+metadata:
+  name: node-local-dns
+  namespace: kube-system
+spec:
+  hostNetwork: true
+  containers:
+  - name: node-cache
+    image: registry.k8s.io/dns/k8s-dns-node-cache:1.23.1
+    args: ["-localip", "169.254.20.10,{{.DNSServiceIP}}"]
+`
+
+func newConfig(t *testing.T, pods ...config.StaticPodConfig) *config.Config {
+	t.Helper()
+	cfg := &config.Config{StaticPods: pods}
+	cfg.Paths.Kubernetes.ManifestsDir = filepath.Join(t.TempDir(), "manifests")
+	cfg.Templates.Directory = t.TempDir()
+	cfg.Node.Kubelet.DNSServiceIP = "10.0.0.10"
+	return cfg
+}
+
+func TestCheckManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{name: "pod", manifest: nodeLocalDNS},
+		{name: "json", manifest: `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "dns"}, "spec": {"containers": [{"name": "dns", "image": "dns:1"}]}}`},
+		{name: "deployment", manifest: "apiVersion: apps/v1\nkind: Deployment\n", wantErr: "expected apiVersion v1 and kind Pod"},
+		{name: "no name", manifest: "apiVersion: v1\nkind: Pod\n", wantErr: "metadata.name is required"},
+		{name: "no containers", manifest: "apiVersion: v1\nkind: Pod\nmetadata:\n  name: dns\n", wantErr: "spec.containers is required"},
+		{name: "no image", manifest: "apiVersion: v1\nkind: Pod\nmetadata:\n  name: dns\nspec:\n  containers:\n  - name: dns\n", wantErr: "name and an image"},
+		{name: "two documents", manifest: nodeLocalDNS + "---\n" + nodeLocalDNS, wantErr: "single document"},
+		{name: "not yaml", manifest: "{", wantErr: "yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkManifest(tt.manifest)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkManifest() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkManifest() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstallAndUninstall(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	cfg := newConfig(t, config.StaticPodConfig{Name: "node-local-dns", Manifest: nodeLocalDNS})
+	dir := cfg.Paths.Kubernetes.ManifestsDir
+
+	// Manifests written by others are left alone
+	foreign := filepath.Join(dir, "etcd.yaml")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(foreign, []byte("foreign"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	installer := NewInstaller(cfg, logger)
+	if err := installer.Validate(ctx); err != nil {
+		t.Fatalf("Validate() unexpected error: %v", err)
+	}
+	if installer.IsCompleted(ctx) {
+		t.Fatal("IsCompleted() before Execute() = true")
+	}
+	if err := installer.Execute(ctx); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	path := filepath.Join(dir, "aks-flex-node-node-local-dns.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"169.254.20.10,10.0.0.10"`) {
+		t.Fatalf("manifest = %q, %v, want the rendered DNS service IP", data, err)
+	}
+	if !installer.IsCompleted(ctx) {
+		t.Error("IsCompleted() after Execute() = false")
+	}
+	if files := installer.ManagedFiles(); !reflect.DeepEqual(files, []string{path}) {
+		t.Errorf("ManagedFiles() = %v, want %v", files, []string{path})
+	}
+
+	// A pod removed from the configuration loses its manifest
+	removed := NewInstaller(newConfigIn(cfg), logger)
+	if removed.IsCompleted(ctx) {
+		t.Error("IsCompleted() with a stale manifest = true")
+	}
+	if err := removed.Execute(ctx); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stale manifest still exists: %v", err)
+	}
+
+	if err := installer.Execute(ctx); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	uninstaller := NewUnInstaller(cfg, logger)
+	if err := uninstaller.Execute(ctx); err != nil || !uninstaller.IsCompleted(ctx) {
+		t.Errorf("UnInstaller Execute() = %v, want the manifests removed", err)
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Errorf("foreign manifest removed: %v", err)
+	}
+}
+
+// newConfigIn returns a configuration without static pods using the manifests directory of cfg
+func newConfigIn(cfg *config.Config) *config.Config {
+	empty := &config.Config{}
+	empty.Paths.Kubernetes.ManifestsDir = cfg.Paths.Kubernetes.ManifestsDir
+	return empty
+}
+
+func TestInvalidManifest(t *testing.T) {
+	cfg := newConfig(t, config.StaticPodConfig{Name: "dns", ManifestFile: filepath.Join(t.TempDir(), "missing.yaml")})
+	if err := NewInstaller(cfg, logrus.New()).Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "static pod dns") {
+		t.Errorf("Validate() error = %v, want the missing manifest of dns", err)
+	}
+}
@@ -0,0 +1,42 @@
+package static_pods
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes the static pod manifests the agent wrote, leaving other manifests alone
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new static pods UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "StaticPods_UnInstaller"
+}
+
+// Execute removes the manifests the agent wrote
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	for _, err := range utils.RemoveFiles(ownedManifests(u.config), u.logger) {
+		u.logger.Warnf("Failed to remove static pod manifest: %v", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when no manifest written by the agent is left
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return len(ownedManifests(u.config)) == 0
+}
@@ -0,0 +1,124 @@
+package system_configuration
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroup"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// GRUB drop-in moving systemd to the unified (v2) cgroup hierarchy
+	grubCgroupPath = "/etc/default/grub.d/90-aks-flex-node-cgroup.cfg"
+
+	// Marks that the agent added the cgroup v2 kernel argument to the OSTree deployment with rpm-ostree, which
+	// leaves nothing else on disk to tell it apart from an argument the image or the user set
+	ostreeCgroupMarker = "/var/lib/aks-flex-node/cgroup-v2-kargs"
+)
+
+// detectCgroupMode returns the host's cgroup mode; replaced in tests
+var detectCgroupMode = cgroup.Detect
+
+// grubCgroupConfig renders the GRUB drop-in booting the host with the unified cgroup hierarchy
+func grubCgroupConfig() string {
+	return "# Managed by aks-flex-node: boot with the unified (v2) cgroup hierarchy\n" +
+		"GRUB_CMDLINE_LINUX=\"$GRUB_CMDLINE_LINUX " + cgroup.KernelParameter + "\"\n"
+}
+
+// cgroupMigrationDone reports whether the host is in the cgroup state node.cgroup.migrateToV2 asks for
+func cgroupMigrationDone(migrate bool) bool {
+	if !migrate {
+		return !utils.FileExists(grubCgroupPath) && !utils.FileExists(ostreeCgroupMarker)
+	}
+	mode, err := detectCgroupMode()
+	return err == nil && mode.IsUnified()
+}
+
+// configureCgroupV2 writes the GRUB drop-in switching a cgroup v1 host to cgroup v2, or removes it when the
+// migration is not requested. It reports whether a reboot is needed for the kernel to boot with cgroup v2.
+func configureCgroupV2(migrate bool, logger *logrus.Logger) (bool, error) {
+	if osimage.Current().Variant == osimage.VariantOSTree {
+		return configureCgroupV2OSTree(migrate, logger)
+	}
+	if !migrate {
+		if utils.FileExists(grubCgroupPath) {
+			if err := utils.RunCleanupCommand(grubCgroupPath); err != nil {
+				return false, fmt.Errorf("failed to remove %s: %w", grubCgroupPath, err)
+			}
+			if err := utils.RunSystemCommand("update-grub"); err != nil {
+				return false, fmt.Errorf("failed to update GRUB configuration: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	mode, err := detectCgroupMode()
+	if err != nil {
+		return false, err
+	}
+	if mode.IsUnified() && !utils.FileExists(grubCgroupPath) {
+		// Already on cgroup v2 through the distribution's defaults; nothing to pin
+		logger.Debug("Host already runs the unified cgroup hierarchy")
+		return false, nil
+	}
+
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(grubCgroupPath)); err != nil {
+		return false, fmt.Errorf("failed to create GRUB drop-in directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(grubCgroupPath, []byte(grubCgroupConfig()), 0o644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", grubCgroupPath, err)
+	}
+	if err := utils.RunSystemCommand("update-grub"); err != nil {
+		return false, fmt.Errorf("failed to update GRUB configuration; add '%s' to the kernel command line manually "+
+			"and reboot: %w", cgroup.KernelParameter, err)
+	}
+
+	if !mode.IsUnified() {
+		logger.Infof("Host runs the %s cgroup hierarchy, a reboot is required to switch to cgroup v2", mode)
+		return true, nil
+	}
+	return false, nil
+}
+
+// configureCgroupV2OSTree adds the cgroup v2 kernel argument to the next OSTree deployment, where GRUB's
+// configuration is generated from the deployment and drop-ins are ignored, or removes it when the migration is
+// no longer requested. It reports whether a reboot is needed to boot the new deployment.
+func configureCgroupV2OSTree(migrate bool, logger *logrus.Logger) (bool, error) {
+	if !migrate {
+		if !utils.FileExists(ostreeCgroupMarker) {
+			return false, nil
+		}
+		if err := utils.RunSystemCommand("rpm-ostree", "kargs", "--delete-if-present="+cgroup.KernelParameter); err != nil {
+			return false, fmt.Errorf("failed to remove the cgroup v2 kernel argument: %w", err)
+		}
+		if err := utils.RunCleanupCommand(ostreeCgroupMarker); err != nil {
+			return false, fmt.Errorf("failed to remove %s: %w", ostreeCgroupMarker, err)
+		}
+		return false, nil
+	}
+
+	mode, err := detectCgroupMode()
+	if err != nil {
+		return false, err
+	}
+	if mode.IsUnified() {
+		logger.Debug("Host already runs the unified cgroup hierarchy")
+		return false, nil
+	}
+
+	if err := utils.RunSystemCommand("rpm-ostree", "kargs", "--append-if-missing="+cgroup.KernelParameter); err != nil {
+		return false, fmt.Errorf("failed to add the cgroup v2 kernel argument with rpm-ostree: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(ostreeCgroupMarker)); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(ostreeCgroupMarker), err)
+	}
+	if err := utils.WriteFileAtomicSystem(ostreeCgroupMarker, []byte(cgroup.KernelParameter+"\n"), 0o644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", ostreeCgroupMarker, err)
+	}
+	logger.Infof("Host runs the %s cgroup hierarchy, a reboot is required to boot the deployment with cgroup v2", mode)
+	return true, nil
+}
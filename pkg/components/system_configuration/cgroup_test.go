@@ -0,0 +1,39 @@
+package system_configuration
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroup"
+)
+
+func TestGrubCgroupConfig(t *testing.T) {
+	if got := grubCgroupConfig(); !strings.Contains(got, `GRUB_CMDLINE_LINUX="$GRUB_CMDLINE_LINUX systemd.unified_cgroup_hierarchy=1"`) {
+		t.Errorf("grubCgroupConfig() = %q", got)
+	}
+}
+
+func TestCgroupMigrationDone(t *testing.T) {
+	original := detectCgroupMode
+	t.Cleanup(func() { detectCgroupMode = original })
+
+	tests := []struct {
+		name      string
+		mode      cgroup.Mode
+		detectErr error
+		want      bool
+	}{
+		{name: "unified host", mode: cgroup.ModeUnified, want: true},
+		{name: "hybrid host", mode: cgroup.ModeHybrid, want: false},
+		{name: "detection fails", detectErr: errors.New("not mounted"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detectCgroupMode = func() (cgroup.Mode, error) { return tt.mode, tt.detectErr }
+			if got := cgroupMigrationDone(true); got != tt.want {
+				t.Errorf("cgroupMigrationDone(true) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
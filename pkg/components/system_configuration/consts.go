@@ -8,4 +8,10 @@ const (
 	sysctlConfigPath = "/etc/sysctl.d/999-sysctl-aks.conf"
 	resolvConfPath   = "/etc/resolv.conf"
 	resolvConfSource = "/run/systemd/resolve/resolv.conf"
+
+	// Kernel values recorded before the agent first changed them, restored on uninstall
+	sysctlOriginalsPath = "/var/lib/aks-flex-node/sysctl-original.json"
+
+	// Original values while uninstall applies them; the name sorts after every other sysctl.d file
+	sysctlRestorePath = "/etc/sysctl.d/999-sysctl-aks-restore.conf"
 )
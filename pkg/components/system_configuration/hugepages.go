@@ -0,0 +1,98 @@
+package system_configuration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// Kernel interface reporting the number of reserved 1Gi hugepages
+	hugepages1GiPath = "/sys/kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages"
+
+	// GRUB drop-in adding the 1Gi hugepages kernel parameters
+	grubHugepagesPath = "/etc/default/grub.d/90-aks-flex-node-hugepages.cfg"
+)
+
+// hugepagesSysctls returns the sysctl settings allocating 2Mi hugepages, or none if no pages are requested
+func hugepagesSysctls(pages2Mi int) []sysctlSetting {
+	if pages2Mi <= 0 {
+		return nil
+	}
+	return []sysctlSetting{{"vm.nr_hugepages", strconv.Itoa(pages2Mi)}}
+}
+
+// read1GiHugepages returns the number of 1Gi hugepages reserved by the running kernel.
+// ok is false when the kernel or CPU does not support 1Gi pages.
+func read1GiHugepages() (count int, ok bool, err error) {
+	data, err := os.ReadFile(hugepages1GiPath)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read %s: %w", hugepages1GiPath, err)
+	}
+	count, err = strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value in %s: %w", hugepages1GiPath, err)
+	}
+	return count, true, nil
+}
+
+// check1GiHugepagesSupport fails when 1Gi hugepages are requested on a kernel or CPU without support for them
+func check1GiHugepagesSupport(desired int, supported bool) error {
+	if desired > 0 && !supported {
+		return fmt.Errorf("node.hugepages.pages1Gi is set but this kernel or CPU does not support 1Gi hugepages")
+	}
+	return nil
+}
+
+// grubHugepagesConfig renders the GRUB drop-in reserving 1Gi hugepages at boot.
+// The default hugepage size stays 2Mi so vm.nr_hugepages keeps counting 2Mi pages.
+func grubHugepagesConfig(pages1Gi int) string {
+	return fmt.Sprintf("# Managed by aks-flex-node: reserve 1Gi hugepages at boot\n"+
+		"GRUB_CMDLINE_LINUX=\"$GRUB_CMDLINE_LINUX hugepagesz=1G hugepages=%d\"\n", pages1Gi)
+}
+
+// configure1GiHugepages writes the GRUB drop-in for 1Gi hugepages, or removes it when none are requested.
+// It reports whether a reboot is needed for the kernel to reserve the requested pages.
+func configure1GiHugepages(desired int, logger *logrus.Logger) (bool, error) {
+	if desired <= 0 {
+		if utils.FileExists(grubHugepagesPath) {
+			if err := utils.RunCleanupCommand(grubHugepagesPath); err != nil {
+				return false, fmt.Errorf("failed to remove %s: %w", grubHugepagesPath, err)
+			}
+			if err := utils.RunSystemCommand("update-grub"); err != nil {
+				return false, fmt.Errorf("failed to update GRUB configuration: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(grubHugepagesPath)); err != nil {
+		return false, fmt.Errorf("failed to create GRUB drop-in directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(grubHugepagesPath, []byte(grubHugepagesConfig(desired)), 0o644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", grubHugepagesPath, err)
+	}
+	if err := utils.RunSystemCommand("update-grub"); err != nil {
+		return false, fmt.Errorf("failed to update GRUB configuration; add 'hugepagesz=1G hugepages=%d' to the kernel "+
+			"command line manually and reboot: %w", desired, err)
+	}
+
+	current, _, err := read1GiHugepages()
+	if err != nil {
+		return false, err
+	}
+	if current < desired {
+		logger.Infof("%d 1Gi hugepages requested but %d reserved, a reboot is required", desired, current)
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,38 @@
+package system_configuration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHugepagesSysctls(t *testing.T) {
+	if got := hugepagesSysctls(0); len(got) != 0 {
+		t.Errorf("hugepagesSysctls(0) = %v, want none", got)
+	}
+	got := hugepagesSysctls(512)
+	if len(got) != 1 || got[0] != (sysctlSetting{"vm.nr_hugepages", "512"}) {
+		t.Errorf("hugepagesSysctls(512) = %v", got)
+	}
+}
+
+func TestCheck1GiHugepagesSupport(t *testing.T) {
+	if err := check1GiHugepagesSupport(0, false); err != nil {
+		t.Errorf("check1GiHugepagesSupport(0, false) unexpected error: %v", err)
+	}
+	if err := check1GiHugepagesSupport(4, true); err != nil {
+		t.Errorf("check1GiHugepagesSupport(4, true) unexpected error: %v", err)
+	}
+	if err := check1GiHugepagesSupport(4, false); err == nil || !strings.Contains(err.Error(), "does not support 1Gi hugepages") {
+		t.Errorf("check1GiHugepagesSupport(4, false) error = %v, want unsupported", err)
+	}
+}
+
+func TestGrubHugepagesConfig(t *testing.T) {
+	got := grubHugepagesConfig(8)
+	if !strings.Contains(got, `GRUB_CMDLINE_LINUX="$GRUB_CMDLINE_LINUX hugepagesz=1G hugepages=8"`) {
+		t.Errorf("grubHugepagesConfig(8) = %q", got)
+	}
+	if strings.Contains(got, "default_hugepagesz") {
+		t.Error("grubHugepagesConfig() must keep the default hugepage size so vm.nr_hugepages counts 2Mi pages")
+	}
+}
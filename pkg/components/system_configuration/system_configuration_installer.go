@@ -3,9 +3,11 @@ package system_configuration
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -13,12 +15,14 @@ import (
 type Installer struct {
 	config *config.Config
 	logger *logrus.Logger
+
+	rebootRequired bool // set by Execute when boot parameters changed and the kernel has not applied them yet
 }
 
 // NewInstaller creates a new system configuration Installer
-func NewInstaller(logger *logrus.Logger) *Installer {
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
 	return &Installer{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -32,6 +36,19 @@ func (i *Installer) Execute(ctx context.Context) error {
 		return fmt.Errorf("failed to configure sysctl settings: %w", err)
 	}
 
+	// 1Gi hugepages can only be reserved from the kernel command line
+	hugepagesReboot, err := configure1GiHugepages(i.config.Node.Hugepages.Pages1Gi, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure 1Gi hugepages: %w", err)
+	}
+
+	// Switching from cgroup v1 to v2 also takes a kernel command line change and a reboot
+	cgroupReboot, err := configureCgroupV2(i.config.Node.Cgroup.MigrateToV2, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure cgroup v2: %w", err)
+	}
+	i.rebootRequired = hugepagesReboot || cgroupReboot
+
 	// Configure resolv.conf
 	if err := i.configureResolvConf(); err != nil {
 		return fmt.Errorf("failed to configure resolv.conf: %w", err)
@@ -43,12 +60,50 @@ func (i *Installer) Execute(ctx context.Context) error {
 
 // IsCompleted checks if system configuration has been applied
 func (i *Installer) IsCompleted(ctx context.Context) bool {
-	return utils.FileExists(sysctlConfigPath) &&
-		utils.FileExists(resolvConfPath)
+	// Re-apply when the tuning profile or hugepages settings changed since the last run
+	desired, err := i.sysctlConfig()
+	if err != nil {
+		return false
+	}
+	current, err := os.ReadFile(sysctlConfigPath)
+	if err != nil || string(current) != desired {
+		return false
+	}
+	if pages1Gi := i.config.Node.Hugepages.Pages1Gi; pages1Gi > 0 {
+		if reserved, _, err := read1GiHugepages(); err != nil || reserved < pages1Gi {
+			return false
+		}
+	} else if utils.FileExists(grubHugepagesPath) {
+		return false
+	}
+	if !cgroupMigrationDone(i.config.Node.Cgroup.MigrateToV2) {
+		return false
+	}
+	return utils.FileExists(resolvConfPath)
+}
+
+// RequiresReboot reports whether the kernel must restart to reserve the requested 1Gi hugepages or to boot
+// with cgroup v2
+func (i *Installer) RequiresReboot(ctx context.Context) bool {
+	return i.rebootRequired
 }
 
 // Validate validates the system configuration installation
 func (i *Installer) Validate(ctx context.Context) error {
+	if _, err := resolveProfile(i.config.Node.Tuning); err != nil {
+		return err
+	}
+
+	// 1Gi hugepages need CPU and kernel support, so check before changing the boot configuration
+	if desired := i.config.Node.Hugepages.Pages1Gi; desired > 0 {
+		_, supported, err := read1GiHugepages()
+		if err != nil {
+			return err
+		}
+		if err := check1GiHugepagesSupport(desired, supported); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -60,13 +115,22 @@ func (i *Installer) configureSysctl() error {
 		return fmt.Errorf("failed to disable swap: %w", err)
 	}
 
-	sysctlConfig := `# Kubernetes sysctl settings
-net.bridge.bridge-nf-call-iptables = 1
-net.bridge.bridge-nf-call-ip6tables = 1
-net.ipv4.ip_forward = 1
-vm.overcommit_memory = 1
-kernel.panic = 10
-kernel.panic_on_oops = 1`
+	settings, err := i.sysctlSettings()
+	if err != nil {
+		return err
+	}
+
+	// Record the values the kernel had before the agent changed them so uninstall can restore them
+	originals, err := loadOriginalSysctls()
+	if err != nil {
+		return err
+	}
+	if recordOriginals(originals, settings, readSysctl) {
+		if err := saveOriginalSysctls(originals); err != nil {
+			return err
+		}
+	}
+	sysctlConfig := renderSysctlConfig(profileName(i.config.Node.Tuning), settings)
 
 	// Create sysctl directory if it doesn't exist
 	if err := utils.RunSystemCommand("mkdir", "-p", sysctlDir); err != nil {
@@ -80,6 +144,10 @@ kernel.panic_on_oops = 1`
 	}
 	defer utils.CleanupTempFile(tempFile.Name())
 
+	if err := filebackup.Save(sysctlConfigPath, i.logger); err != nil {
+		return err
+	}
+
 	// Copy to final location
 	if err := utils.RunSystemCommand("cp", tempFile.Name(), sysctlConfigPath); err != nil {
 		return fmt.Errorf("failed to install sysctl config file: %w", err)
@@ -89,24 +157,62 @@ kernel.panic_on_oops = 1`
 	if err := utils.RunSystemCommand("chmod", "644", sysctlConfigPath); err != nil {
 		return fmt.Errorf("failed to set sysctl config file permissions: %w", err)
 	}
+	if err := filebackup.Record(sysctlConfigPath); err != nil {
+		return err
+	}
 
 	// Apply sysctl settings
 	if err := utils.RunSystemCommand("sysctl", "--system"); err != nil {
 		return fmt.Errorf("failed to apply sysctl settings: %w", err)
 	}
 
-	i.logger.Info("Sysctl configuration applied successfully")
+	i.logger.Infof("Sysctl configuration applied successfully with tuning profile %s", profileName(i.config.Node.Tuning))
 	return nil
 }
 
+// sysctlSettings returns the Kubernetes base settings, the IPv6 settings of IPv6 and dual-stack nodes, followed by the tuning profile and hugepages settings
+func (i *Installer) sysctlSettings() ([]sysctlSetting, error) {
+	profile, err := resolveProfile(i.config.Node.Tuning)
+	if err != nil {
+		return nil, err
+	}
+	settings := append([]sysctlSetting{}, baseSysctls...)
+	if i.config.HasIPFamily(config.IPFamilyIPv6) {
+		settings = append(settings, ipv6Sysctls...)
+		output, err := utils.RunCommandWithOutput("ip", "-6", "route", "show", "default")
+		if err != nil {
+			i.logger.Warnf("Failed to list the IPv6 default routes, router advertisements may stop being accepted: %v", err)
+		}
+		settings = append(settings, acceptRASysctls(parseDefaultRouteDevices(output))...)
+	}
+	settings = append(settings, profile...)
+	settings = append(settings, hugepagesSysctls(i.config.GetHugepages2Mi())...)
+	return settings, nil
+}
+
+// sysctlConfig renders the desired contents of the sysctl configuration file
+func (i *Installer) sysctlConfig() (string, error) {
+	settings, err := i.sysctlSettings()
+	if err != nil {
+		return "", err
+	}
+	return renderSysctlConfig(profileName(i.config.Node.Tuning), settings), nil
+}
+
 // configureResolvConf configures DNS resolution
 func (i *Installer) configureResolvConf() error {
 	// Check if systemd-resolved is managing DNS
 	if utils.FileExists(resolvConfSource) {
+		if err := filebackup.Save(resolvConfPath, i.logger); err != nil {
+			return err
+		}
 		// Create symlink to systemd-resolved configuration
 		if err := utils.RunSystemCommand("ln", "-sf", resolvConfSource, resolvConfPath); err != nil {
 			return fmt.Errorf("failed to configure resolv.conf symlink: %w", err)
 		}
+		if err := filebackup.Record(resolvConfPath); err != nil {
+			return err
+		}
 		i.logger.Info("Configured resolv.conf to use systemd-resolved")
 	} else {
 		i.logger.Info("systemd-resolved not available, using existing resolv.conf")
@@ -5,6 +5,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -15,9 +16,9 @@ type UnInstaller struct {
 }
 
 // NewUnInstaller creates a new system configuration unInstaller
-func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
 	return &UnInstaller{
-		config: config.GetConfig(),
+		config: cfg,
 		logger: logger,
 	}
 }
@@ -36,6 +37,21 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 		su.logger.WithError(err).Warn("Failed to cleanup sysctl configuration")
 	}
 
+	// Restore the kernel values the agent changed; files in /etc/sysctl.d are re-applied below
+	if err := restoreOriginalSysctls(su.logger); err != nil {
+		su.logger.WithError(err).Warn("Failed to restore original sysctl values")
+	}
+
+	// Remove the 1Gi hugepages boot parameters; pages stay reserved until the next reboot
+	if _, err := configure1GiHugepages(0, su.logger); err != nil {
+		su.logger.WithError(err).Warn("Failed to remove 1Gi hugepages boot configuration")
+	}
+
+	// Remove the cgroup v2 boot parameter; the host keeps cgroup v2 until the next reboot
+	if _, err := configureCgroupV2(false, su.logger); err != nil {
+		su.logger.WithError(err).Warn("Failed to remove cgroup v2 boot configuration")
+	}
+
 	// Cleanup resolv.conf configuration
 	if err := su.cleanupResolvConf(); err != nil {
 		su.logger.WithError(err).Warn("Failed to cleanup resolv.conf configuration")
@@ -53,7 +69,8 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 // IsCompleted checks if system configuration has been removed
 func (su *UnInstaller) IsCompleted(ctx context.Context) bool {
 	// Check if sysctl config exists
-	if utils.FileExists(sysctlConfigPath) {
+	if utils.FileExists(sysctlConfigPath) || utils.FileExists(sysctlOriginalsPath) || utils.FileExists(sysctlRestorePath) ||
+		utils.FileExists(grubHugepagesPath) || utils.FileExists(grubCgroupPath) {
 		return false
 	}
 	// Note: We don't check resolv.conf as it may have been restored to original state
@@ -61,10 +78,10 @@ func (su *UnInstaller) IsCompleted(ctx context.Context) bool {
 	return true
 }
 
-// cleanupSysctlConfig removes the sysctl configuration
+// cleanupSysctlConfig removes the sysctl configuration, or restores the file it replaced
 func (su *UnInstaller) cleanupSysctlConfig() error {
 	if utils.FileExists(sysctlConfigPath) {
-		if err := utils.RunCleanupCommand(sysctlConfigPath); err != nil {
+		if err := filebackup.Revert(sysctlConfigPath, su.logger); err != nil {
 			return err
 		}
 		su.logger.Info("Removed sysctl configuration file")
@@ -74,6 +91,15 @@ func (su *UnInstaller) cleanupSysctlConfig() error {
 
 // cleanupResolvConf restores original resolv.conf configuration
 func (su *UnInstaller) cleanupResolvConf() error {
+	// Put back the file or symlink bootstrap replaced
+	entry, err := filebackup.Find(resolvConfPath)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		return filebackup.Revert(resolvConfPath, su.logger)
+	}
+
 	// Check if resolv.conf is a symlink to systemd-resolved that we created
 	if utils.FileExists(resolvConfPath) {
 		// Get link target
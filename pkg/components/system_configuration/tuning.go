@@ -0,0 +1,276 @@
+package system_configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// sysctlSetting is a single sysctl key/value pair, kept in order so the rendered file is stable
+type sysctlSetting struct {
+	key   string
+	value string
+}
+
+// baseSysctls are required by Kubernetes regardless of the selected profile
+var baseSysctls = []sysctlSetting{
+	{"net.bridge.bridge-nf-call-iptables", "1"},
+	{"net.bridge.bridge-nf-call-ip6tables", "1"},
+	{"net.ipv4.ip_forward", "1"},
+	{"vm.overcommit_memory", "1"},
+	{"kernel.panic", "10"},
+	{"kernel.panic_on_oops", "1"},
+}
+
+// ipv6Sysctls route IPv6 pod traffic on IPv6 and dual-stack nodes
+var ipv6Sysctls = []sysctlSetting{
+	{"net.ipv6.conf.all.disable_ipv6", "0"},
+	{"net.ipv6.conf.all.forwarding", "1"},
+}
+
+// acceptRASysctls keep router advertisements on the IPv6 uplinks, which the kernel ignores once forwarding is
+// on. Without them, the default route learned from the router expires and the node drops off the network.
+// Interfaces with a dot in their name, such as VLANs, cannot be named in a sysctl key and are left out.
+func acceptRASysctls(uplinks []string) []sysctlSetting {
+	var settings []sysctlSetting
+	for _, uplink := range uplinks {
+		if strings.Contains(uplink, ".") {
+			continue
+		}
+		settings = append(settings, sysctlSetting{"net.ipv6.conf." + uplink + ".accept_ra", "2"})
+	}
+	return settings
+}
+
+// parseDefaultRouteDevices returns the interfaces of the default routes listed by 'ip route show default'
+func parseDefaultRouteDevices(output string) []string {
+	var devices []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "dev" && !seen[fields[i+1]] {
+				seen[fields[i+1]] = true
+				devices = append(devices, fields[i+1])
+			}
+		}
+	}
+	return devices
+}
+
+// builtinProfiles holds the tuning profiles shipped with the agent. Every profile includes the default settings.
+var builtinProfiles = map[string][]sysctlSetting{
+	"default": defaultProfile,
+	"high-network": append(append([]sysctlSetting{}, defaultProfile...),
+		sysctlSetting{"net.core.rmem_max", "16777216"},
+		sysctlSetting{"net.core.wmem_max", "16777216"},
+		sysctlSetting{"net.ipv4.tcp_rmem", "4096 87380 16777216"},
+		sysctlSetting{"net.ipv4.tcp_wmem", "4096 65536 16777216"},
+		sysctlSetting{"net.core.netdev_max_backlog", "30000"},
+		sysctlSetting{"net.ipv4.tcp_max_syn_backlog", "16384"},
+		sysctlSetting{"net.ipv4.ip_local_port_range", "1024 65535"},
+		sysctlSetting{"net.ipv4.tcp_tw_reuse", "1"},
+		sysctlSetting{"net.netfilter.nf_conntrack_max", "1048576"},
+	),
+	"database": append(append([]sysctlSetting{}, defaultProfile...),
+		sysctlSetting{"vm.max_map_count", "262144"},
+		sysctlSetting{"vm.dirty_ratio", "10"},
+		sysctlSetting{"vm.dirty_background_ratio", "5"},
+		sysctlSetting{"vm.zone_reclaim_mode", "0"},
+		sysctlSetting{"fs.aio-max-nr", "1048576"},
+		sysctlSetting{"fs.file-max", "2097152"},
+	),
+}
+
+// defaultProfile matches the settings AKS applies to its own nodes
+var defaultProfile = []sysctlSetting{
+	{"fs.inotify.max_user_watches", "1048576"},
+	{"fs.inotify.max_user_instances", "8192"},
+	{"net.core.somaxconn", "16384"},
+	{"net.ipv4.neigh.default.gc_thresh1", "4096"},
+	{"net.ipv4.neigh.default.gc_thresh2", "8192"},
+	{"net.ipv4.neigh.default.gc_thresh3", "16384"},
+}
+
+// resolveProfile returns the sysctl settings of the named profile.
+// Custom profiles are layered on the built-in profile they extend; a custom profile named after a built-in one extends it.
+func resolveProfile(tuning config.TuningConfig) ([]sysctlSetting, error) {
+	name := profileName(tuning)
+
+	custom, isCustom := tuning.Profiles[name]
+	if !isCustom {
+		settings, ok := builtinProfiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tuning profile '%s': use one of %s or define it in node.tuning.profiles",
+				name, strings.Join(builtinProfileNames(), ", "))
+		}
+		return settings, nil
+	}
+
+	base := custom.Extends
+	if base == "" {
+		if _, ok := builtinProfiles[name]; ok {
+			base = name
+		}
+	}
+	var settings []sysctlSetting
+	if base != "" {
+		builtin, ok := builtinProfiles[base]
+		if !ok {
+			return nil, fmt.Errorf("tuning profile '%s' extends unknown built-in profile '%s'", name, base)
+		}
+		settings = append(settings, builtin...)
+	}
+
+	keys := make([]string, 0, len(custom.Sysctls))
+	for key := range custom.Sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		settings = append(settings, sysctlSetting{key, custom.Sysctls[key]})
+	}
+	return settings, nil
+}
+
+// profileName returns the selected profile, falling back to the default one
+func profileName(tuning config.TuningConfig) string {
+	if tuning.Profile == "" {
+		return "default"
+	}
+	return tuning.Profile
+}
+
+// builtinProfileNames returns the sorted built-in profile names
+func builtinProfileNames() []string {
+	names := make([]string, 0, len(builtinProfiles))
+	for name := range builtinProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderSysctlConfig renders settings as a sysctl.d file. Later entries win, so profile values override base ones.
+func renderSysctlConfig(profile string, settings []sysctlSetting) string {
+	var b strings.Builder
+	b.WriteString("# Kubernetes sysctl settings\n")
+	fmt.Fprintf(&b, "# Tuning profile: %s\n", profile)
+	for _, s := range settings {
+		fmt.Fprintf(&b, "%s = %s\n", s.key, s.value)
+	}
+	return b.String()
+}
+
+// sysctlProcPath maps a sysctl key to its /proc/sys file
+func sysctlProcPath(key string) string {
+	return filepath.Join("/proc/sys", strings.ReplaceAll(key, ".", "/"))
+}
+
+// readSysctl returns the current kernel value of key, normalizing whitespace the way sysctl.d files expect.
+// ok is false when the key does not exist on this kernel, e.g. because its module is not loaded.
+func readSysctl(key string) (value string, ok bool) {
+	data, err := os.ReadFile(sysctlProcPath(key))
+	if err != nil {
+		return "", false
+	}
+	return strings.Join(strings.Fields(string(data)), " "), true
+}
+
+// loadOriginalSysctls reads the values recorded before the agent first changed them
+func loadOriginalSysctls() (map[string]string, error) {
+	data, err := os.ReadFile(sysctlOriginalsPath)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sysctlOriginalsPath, err)
+	}
+	originals := map[string]string{}
+	if err := json.Unmarshal(data, &originals); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", sysctlOriginalsPath, err)
+	}
+	return originals, nil
+}
+
+// recordOriginals adds the current value of every key not yet recorded, so repeated runs and profile
+// changes never overwrite the pre-agent value with one the agent set. It reports whether anything was added.
+func recordOriginals(originals map[string]string, settings []sysctlSetting, read func(string) (string, bool)) bool {
+	added := false
+	for _, s := range settings {
+		if _, recorded := originals[s.key]; recorded {
+			continue
+		}
+		if value, ok := read(s.key); ok {
+			originals[s.key] = value
+			added = true
+		}
+	}
+	return added
+}
+
+// saveOriginalSysctls persists the recorded original values
+func saveOriginalSysctls(originals map[string]string) error {
+	data, err := json.MarshalIndent(originals, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode original sysctl values: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(sysctlOriginalsPath)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(sysctlOriginalsPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sysctlOriginalsPath, err)
+	}
+	return nil
+}
+
+// restoreOriginalSysctls writes the recorded original values back to the kernel and forgets them. The values
+// are applied through a temporary sysctl.d file, so the agent needs no sudo rule for setting arbitrary keys.
+// Values that cannot be restored are reported by sysctl but do not stop the rest from being restored.
+func restoreOriginalSysctls(logger *logrus.Logger) error {
+	originals, err := loadOriginalSysctls()
+	if err != nil {
+		return err
+	}
+	if len(originals) == 0 {
+		return nil
+	}
+
+	if err := utils.WriteFileAtomicSystem(sysctlRestorePath, []byte(renderSysctlRestore(originals)), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sysctlRestorePath, err)
+	}
+	if err := utils.RunSystemCommand("sysctl", "--system"); err != nil {
+		logger.WithError(err).Warn("Failed to restore some original sysctl values")
+	}
+	// The original values only need to be applied once; the file must not pin them across reboots
+	if err := utils.RunCleanupCommand(sysctlRestorePath); err != nil {
+		return err
+	}
+	logger.Infof("Restored %d original sysctl values", len(originals))
+
+	return utils.RunCleanupCommand(sysctlOriginalsPath)
+}
+
+// renderSysctlRestore renders the original values as a sysctl.d file, in key order
+func renderSysctlRestore(originals map[string]string) string {
+	keys := make([]string, 0, len(originals))
+	for key := range originals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Original sysctl values restored by aks-flex-node unbootstrap\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", key, originals[key])
+	}
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package system_configuration
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func settingValue(settings []sysctlSetting, key string) (string, bool) {
+	value, found := "", false
+	for _, s := range settings {
+		if s.key == key {
+			value, found = s.value, true // later entries win, as in sysctl.d
+		}
+	}
+	return value, found
+}
+
+func TestResolveProfile(t *testing.T) {
+	t.Run("empty selects default", func(t *testing.T) {
+		settings, err := resolveProfile(config.TuningConfig{})
+		if err != nil {
+			t.Fatalf("resolveProfile() unexpected error: %v", err)
+		}
+		if _, ok := settingValue(settings, "fs.inotify.max_user_watches"); !ok {
+			t.Error("default profile should set fs.inotify.max_user_watches")
+		}
+	})
+
+	t.Run("built-in profiles include default settings", func(t *testing.T) {
+		for _, name := range []string{"high-network", "database"} {
+			settings, err := resolveProfile(config.TuningConfig{Profile: name})
+			if err != nil {
+				t.Fatalf("resolveProfile(%s) unexpected error: %v", name, err)
+			}
+			if _, ok := settingValue(settings, "net.core.somaxconn"); !ok {
+				t.Errorf("profile %s should include the default settings", name)
+			}
+		}
+	})
+
+	t.Run("custom profile extends built-in", func(t *testing.T) {
+		settings, err := resolveProfile(config.TuningConfig{
+			Profile: "ingress",
+			Profiles: map[string]config.TuningProfileConfig{
+				"ingress": {Extends: "high-network", Sysctls: map[string]string{"net.core.somaxconn": "65535"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("resolveProfile() unexpected error: %v", err)
+		}
+		if v, _ := settingValue(settings, "net.core.somaxconn"); v != "65535" {
+			t.Errorf("net.core.somaxconn = %q, want custom value 65535", v)
+		}
+		if _, ok := settingValue(settings, "net.core.rmem_max"); !ok {
+			t.Error("custom profile should keep settings of the extended profile")
+		}
+	})
+
+	t.Run("custom profile named after built-in overrides it", func(t *testing.T) {
+		settings, err := resolveProfile(config.TuningConfig{
+			Profile: "database",
+			Profiles: map[string]config.TuningProfileConfig{
+				"database": {Sysctls: map[string]string{"vm.max_map_count": "524288"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("resolveProfile() unexpected error: %v", err)
+		}
+		if v, _ := settingValue(settings, "vm.max_map_count"); v != "524288" {
+			t.Errorf("vm.max_map_count = %q, want 524288", v)
+		}
+		if _, ok := settingValue(settings, "vm.dirty_ratio"); !ok {
+			t.Error("override should keep the other built-in database settings")
+		}
+	})
+
+	t.Run("unknown profiles fail", func(t *testing.T) {
+		if _, err := resolveProfile(config.TuningConfig{Profile: "gaming"}); err == nil || !strings.Contains(err.Error(), "unknown tuning profile") {
+			t.Errorf("resolveProfile() error = %v, want unknown tuning profile", err)
+		}
+		_, err := resolveProfile(config.TuningConfig{
+			Profile:  "custom",
+			Profiles: map[string]config.TuningProfileConfig{"custom": {Extends: "gaming"}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "unknown built-in profile") {
+			t.Errorf("resolveProfile() error = %v, want unknown built-in profile", err)
+		}
+	})
+}
+
+func TestRenderSysctlConfig(t *testing.T) {
+	got := renderSysctlConfig("database", []sysctlSetting{{"vm.max_map_count", "262144"}, {"net.ipv4.tcp_rmem", "4096 87380 16777216"}})
+	want := "# Kubernetes sysctl settings\n# Tuning profile: database\nvm.max_map_count = 262144\nnet.ipv4.tcp_rmem = 4096 87380 16777216\n"
+	if got != want {
+		t.Errorf("renderSysctlConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderSysctlRestore(t *testing.T) {
+	got := renderSysctlRestore(map[string]string{"vm.max_map_count": "65530", "net.ipv4.tcp_rmem": "4096 131072 6291456"})
+	want := "# Original sysctl values restored by aks-flex-node unbootstrap\nnet.ipv4.tcp_rmem = 4096 131072 6291456\nvm.max_map_count = 65530\n"
+	if got != want {
+		t.Errorf("renderSysctlRestore() = %q, want %q", got, want)
+	}
+}
+
+func TestRecordOriginals(t *testing.T) {
+	kernel := map[string]string{"vm.max_map_count": "65530", "net.core.somaxconn": "4096"}
+	read := func(key string) (string, bool) {
+		v, ok := kernel[key]
+		return v, ok
+	}
+
+	originals := map[string]string{"net.core.somaxconn": "128"}
+	settings := []sysctlSetting{{"vm.max_map_count", "262144"}, {"net.core.somaxconn", "16384"}, {"net.netfilter.nf_conntrack_max", "1048576"}}
+	if !recordOriginals(originals, settings, read) {
+		t.Fatal("recordOriginals() should report new values")
+	}
+	if originals["net.core.somaxconn"] != "128" {
+		t.Errorf("already recorded value was overwritten: %q", originals["net.core.somaxconn"])
+	}
+	if originals["vm.max_map_count"] != "65530" {
+		t.Errorf("vm.max_map_count original = %q, want 65530", originals["vm.max_map_count"])
+	}
+	if _, ok := originals["net.netfilter.nf_conntrack_max"]; ok {
+		t.Error("keys missing from the kernel should not be recorded")
+	}
+	if recordOriginals(originals, settings, read) {
+		t.Error("recordOriginals() should report no change on a second run")
+	}
+}
+
+func TestAcceptRASysctls(t *testing.T) {
+	output := "default via fe80::1 dev eth0 proto ra metric 100 expires 1798sec pref medium\n" +
+		"default via fe80::1 dev eth0.100 proto ra metric 200 pref medium\n" +
+		"default proto ra metric 300 pref medium\n\tnexthop via fe80::2 dev eth1 weight 1\n"
+	devices := parseDefaultRouteDevices(output)
+	if strings.Join(devices, ",") != "eth0,eth0.100,eth1" {
+		t.Fatalf("parseDefaultRouteDevices() = %v, want eth0, eth0.100 and eth1", devices)
+	}
+	settings := acceptRASysctls(devices)
+	if len(settings) != 2 || settings[0].key != "net.ipv6.conf.eth0.accept_ra" || settings[1].key != "net.ipv6.conf.eth1.accept_ra" {
+		t.Errorf("acceptRASysctls() = %v, want eth0 and eth1 without the VLAN", settings)
+	}
+}
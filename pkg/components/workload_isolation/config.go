@@ -0,0 +1,83 @@
+package workload_isolation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/container_runtime"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/templates"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// generatedHeader marks the files the agent rendered; a slice with the same name set up by something else is
+// left alone
+const generatedHeader = "# Generated by aks-flex-node"
+
+// sliceLimits returns the slice's CPUQuota and MemoryMax for the shared host share on a host with cpus CPUs.
+// A share of 0 or 100 percent leaves the resource unlimited.
+func sliceLimits(shared *config.SharedHostConfig, cpus int) (cpuQuota, memoryMax string) {
+	if shared.CPUPercent > 0 && shared.CPUPercent < 100 {
+		// CPUQuota is relative to one CPU, so 4 of 8 CPUs is 400%
+		cpuQuota = fmt.Sprintf("%d%%", cpus*shared.CPUPercent)
+	}
+	if shared.MemoryPercent > 0 && shared.MemoryPercent < 100 {
+		// systemd takes percentages relative to the physical memory
+		memoryMax = fmt.Sprintf("%d%%", shared.MemoryPercent)
+	}
+	return cpuQuota, memoryMax
+}
+
+// dropInPaths returns the drop-ins moving kubelet and the container runtime into the slice
+func dropInPaths(cfg *config.Config) []string {
+	services := []string{kubeletService, container_runtime.ForConfig(cfg).ServiceName()}
+	paths := make([]string, len(services))
+	for i, service := range services {
+		paths[i] = filepath.Join(systemdUnitDir, service+".service.d", sliceDropInName)
+	}
+	return paths
+}
+
+// desiredFiles renders the slice and the drop-ins, by path
+func desiredFiles(cfg *config.Config) (map[string]string, error) {
+	cpuQuota, memoryMax := sliceLimits(cfg.Node.Kubelet.SharedHost, runtime.NumCPU())
+	slice, err := templates.Render(cfg, templates.KubernetesSlice, struct{ CPUQuota, MemoryMax string }{
+		CPUQuota:  cpuQuota,
+		MemoryMax: memoryMax,
+	})
+	if err != nil {
+		return nil, err
+	}
+	dropIn, err := templates.Render(cfg, templates.SliceDropIn, struct{ Slice string }{Slice: sliceName})
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{slicePath: slice}
+	for _, path := range dropInPaths(cfg) {
+		files[path] = dropIn
+	}
+	return files, nil
+}
+
+// installed reports whether the agent installed the slice
+func installed() bool {
+	data, err := os.ReadFile(slicePath)
+	return err == nil && strings.Contains(string(data), generatedHeader)
+}
+
+// removeFiles deletes the slice and the drop-ins. Services already running in the slice stay there until
+// they restart.
+func removeFiles(cfg *config.Config, logger *logrus.Logger) error {
+	if errs := utils.RemoveFiles(append([]string{slicePath}, dropInPaths(cfg)...), logger); len(errs) > 0 {
+		return fmt.Errorf("failed to remove workload isolation files: %v", errs)
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	return nil
+}
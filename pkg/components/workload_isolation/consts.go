@@ -0,0 +1,15 @@
+package workload_isolation
+
+const (
+	// sliceName is the slice kubelet, the container runtime and pods run in. Kubelet's systemd cgroup driver
+	// derives the pod slices from it, e.g. kubernetes-kubepods-burstable.slice.
+	sliceName = "kubernetes.slice"
+	slicePath = "/etc/systemd/system/kubernetes.slice"
+
+	// CgroupRoot is kubelet's --cgroup-root, placing the pod cgroups inside the slice
+	CgroupRoot = "/" + sliceName
+
+	systemdUnitDir  = "/etc/systemd/system"
+	sliceDropInName = "20-slice.conf"
+	kubeletService  = "kubelet"
+)
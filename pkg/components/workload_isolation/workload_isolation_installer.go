@@ -0,0 +1,125 @@
+package workload_isolation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/validation"
+)
+
+// Installer confines kubelet, the container runtime and all pods to the Kubernetes share of a shared host
+// when node.kubelet.sharedHost.isolate is set. They run in kubernetes.slice, whose CPU and memory limits
+// keep them from starving the workloads running beside Kubernetes. The services pick up the slice when the
+// services step restarts them.
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new workload isolation Installer
+func NewInstaller(cfg *config.Config, logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "WorkloadIsolation_Installer"
+}
+
+// ManagedFiles returns the files the step writes
+func (i *Installer) ManagedFiles() []string {
+	if !i.config.IsWorkloadIsolationEnabled() {
+		return nil
+	}
+	return append([]string{slicePath}, dropInPaths(i.config)...)
+}
+
+// Validate checks the host runs the unified cgroup hierarchy, which CPU and memory limits of a slice need to
+// apply to everything below it
+func (i *Installer) Validate(ctx context.Context) error {
+	if !i.config.IsWorkloadIsolationEnabled() {
+		return nil
+	}
+	if !utils.FileExists("/sys/fs/cgroup/cgroup.controllers") {
+		return fmt.Errorf("node.kubelet.sharedHost.isolate requires cgroup v2; boot the host with systemd.unified_cgroup_hierarchy=1")
+	}
+	return nil
+}
+
+// IsCompleted returns true when the slice and drop-ins are current, or isolation is off and they are gone
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.config.IsWorkloadIsolationEnabled() {
+		return !installed()
+	}
+	desired, err := desiredFiles(i.config)
+	if err != nil {
+		return false
+	}
+	for path, content := range desired {
+		current, err := os.ReadFile(path)
+		if err != nil || string(current) != content {
+			return false
+		}
+	}
+	return true
+}
+
+// Execute writes the slice and the drop-ins moving kubelet and the container runtime into it, or removes
+// them when isolation was turned off
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.IsWorkloadIsolationEnabled() {
+		if installed() {
+			i.logger.Info("node.kubelet.sharedHost.isolate is off, moving kubelet and the container runtime out of " + sliceName)
+			return removeFiles(i.config, i.logger)
+		}
+		return nil
+	}
+	desired, err := desiredFiles(i.config)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(desired))
+	for path := range desired {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		check := validation.SystemdDropIn
+		if path == slicePath {
+			check = validation.SystemdUnit
+		}
+		if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := validation.WriteFile(path, desired[path], 0o644, check, i.logger); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+
+	shared := i.config.Node.Kubelet.SharedHost
+	i.logger.Infof("Confining kubelet, the container runtime and pods to %s (CPU %d%%, memory %d%%)",
+		sliceName, percentOrAll(shared.CPUPercent), percentOrAll(shared.MemoryPercent))
+	return nil
+}
+
+// percentOrAll returns the share in percent, where 0 means all of the resource
+func percentOrAll(percent int) int {
+	if percent == 0 {
+		return 100
+	}
+	return percent
+}
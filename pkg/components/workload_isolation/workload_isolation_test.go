@@ -0,0 +1,51 @@
+package workload_isolation
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestSliceLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		shared        config.SharedHostConfig
+		wantCPUQuota  string
+		wantMemoryMax string
+	}{
+		{name: "half the CPUs", shared: config.SharedHostConfig{CPUPercent: 50}, wantCPUQuota: "400%"},
+		{name: "memory only", shared: config.SharedHostConfig{MemoryPercent: 75, CPUPercent: 100}, wantMemoryMax: "75%"},
+		{name: "both", shared: config.SharedHostConfig{CPUPercent: 25, MemoryPercent: 60}, wantCPUQuota: "200%", wantMemoryMax: "60%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpuQuota, memoryMax := sliceLimits(&tt.shared, 8)
+			if cpuQuota != tt.wantCPUQuota || memoryMax != tt.wantMemoryMax {
+				t.Errorf("sliceLimits() = %q, %q, want %q, %q", cpuQuota, memoryMax, tt.wantCPUQuota, tt.wantMemoryMax)
+			}
+		})
+	}
+}
+
+func TestDesiredFiles(t *testing.T) {
+	cfg := &config.Config{ContainerRuntime: "cri-o"}
+	cfg.Node.Kubelet.SharedHost = &config.SharedHostConfig{MemoryPercent: 60, Isolate: true}
+
+	files, err := desiredFiles(cfg)
+	if err != nil {
+		t.Fatalf("desiredFiles() unexpected error: %v", err)
+	}
+	slice := files[slicePath]
+	if !strings.HasPrefix(slice, generatedHeader) || !strings.Contains(slice, "MemoryMax=60%\n") || strings.Contains(slice, "CPUQuota") {
+		t.Errorf("slice = %q, want only a memory limit", slice)
+	}
+	for _, path := range []string{"/etc/systemd/system/kubelet.service.d/20-slice.conf", "/etc/systemd/system/crio.service.d/20-slice.conf"} {
+		if !strings.Contains(files[path], "Slice=kubernetes.slice") {
+			t.Errorf("%s = %q, want the service moved into the slice", path, files[path])
+		}
+	}
+	if len(files) != 3 {
+		t.Errorf("desiredFiles() wrote %d files, want the slice and two drop-ins", len(files))
+	}
+}
@@ -0,0 +1,42 @@
+package workload_isolation
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// UnInstaller removes the Kubernetes slice and the drop-ins moving services into it
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new workload isolation UnInstaller
+func NewUnInstaller(cfg *config.Config, logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "WorkloadIsolation_UnInstaller"
+}
+
+// Execute deletes the slice and the drop-ins. The services were stopped before, so nothing runs in the slice.
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	if !installed() {
+		return nil
+	}
+	u.logger.Info("Removing the Kubernetes slice")
+	return removeFiles(u.config, u.logger)
+}
+
+// IsCompleted returns true when there is no slice the agent installed
+func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	return !installed()
+}
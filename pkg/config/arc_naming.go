@@ -0,0 +1,274 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Naming strategies of the Arc machine resource, set in azure.arc.namingStrategy
+const (
+	ArcNamingHostname       = "hostname"
+	ArcNamingHostnameSuffix = "hostnameSuffix"
+	ArcNamingSerialNumber   = "serialNumber"
+	ArcNamingExplicit       = "explicit"
+)
+
+var validArcNamingStrategies = map[string]bool{
+	"":                      true,
+	ArcNamingHostname:       true,
+	ArcNamingHostnameSuffix: true,
+	ArcNamingSerialNumber:   true,
+	ArcNamingExplicit:       true,
+}
+
+// ArcMachineNamePath records the Arc machine name the node was connected under, so later runs, including
+// unprivileged ones that cannot read the serial number, keep using it after a hostname change or auto-suffixing
+const ArcMachineNamePath = "/var/lib/aks-flex-node/arc-machine-name.json"
+
+// maxArcMachineNameLength is the longest Arc machine resource name Azure accepts
+const maxArcMachineNameLength = 54
+
+// arcMachineNamePattern matches the names Azure accepts for Arc machine resources
+var arcMachineNamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]{0,52}[a-zA-Z0-9_-])?$`)
+
+// invalidArcNameChars matches the characters that are replaced when a name is derived from the host
+var invalidArcNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// placeholderSerialNumbers are values firmware reports when no serial number was set
+var placeholderSerialNumbers = map[string]bool{
+	"":                       true,
+	"0":                      true,
+	"none":                   true,
+	"not specified":          true,
+	"not applicable":         true,
+	"default string":         true,
+	"system serial number":   true,
+	"to be filled by o.e.m.": true,
+}
+
+// Replaced in tests
+var (
+	arcMachineNamePath = ArcMachineNamePath
+	productSerialPath  = "/sys/class/dmi/id/product_serial"
+	machineIDPath      = "/etc/machine-id"
+	osHostname         = os.Hostname
+)
+
+// ArcMachineNameRecord is the Arc machine name the node was connected under, with the settings it was derived from.
+// The record only applies while those settings are unchanged.
+type ArcMachineNameRecord struct {
+	Name           string `json:"name"`
+	NamingStrategy string `json:"namingStrategy"`
+	MachineName    string `json:"machineName,omitempty"`
+	NameSuffix     string `json:"nameSuffix,omitempty"`
+}
+
+// NewArcMachineNameRecord returns the record of name under the current naming settings
+func (cfg *Config) NewArcMachineNameRecord(name string) ArcMachineNameRecord {
+	record := ArcMachineNameRecord{Name: name, NamingStrategy: cfg.GetArcNamingStrategy()}
+	if cfg.Azure.Arc != nil {
+		record.MachineName = cfg.Azure.Arc.MachineName
+		record.NameSuffix = cfg.Azure.Arc.NameSuffix
+	}
+	return record
+}
+
+// RecordArcMachineName records the name the node was connected under; nil removes the record
+func RecordArcMachineName(record *ArcMachineNameRecord) error {
+	if record == nil {
+		if err := os.Remove(arcMachineNamePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(arcMachineNamePath), 0o755); err != nil {
+		return err
+	}
+	tmp := arcMachineNamePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, arcMachineNamePath)
+}
+
+// recordedArcMachineName returns the record written by RecordArcMachineName, or nil when there is none
+func recordedArcMachineName() (*ArcMachineNameRecord, error) {
+	data, err := os.ReadFile(arcMachineNamePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &ArcMachineNameRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", arcMachineNamePath, err)
+	}
+	return record, nil
+}
+
+// applyArcMachineNameRecord uses the recorded name if it was derived from the current naming settings
+func (cfg *Config) applyArcMachineNameRecord(record *ArcMachineNameRecord) {
+	if record == nil || !arcMachineNamePattern.MatchString(record.Name) {
+		return
+	}
+	current := cfg.NewArcMachineNameRecord(record.Name)
+	if *record == current {
+		cfg.arcMachineName = record.Name
+	}
+}
+
+// UseArcMachineName makes name the Arc machine name of this run, e.g. after it was auto-suffixed
+func (cfg *Config) UseArcMachineName(name string) {
+	cfg.arcMachineName = name
+}
+
+// GetArcNamingStrategy returns how the Arc machine resource is named. An explicit azure.arc.machineName
+// implies the explicit strategy, so configurations written before strategies existed keep their names.
+func (cfg *Config) GetArcNamingStrategy() string {
+	if cfg.Azure.Arc == nil {
+		return ArcNamingHostname
+	}
+	if cfg.Azure.Arc.NamingStrategy != "" {
+		return cfg.Azure.Arc.NamingStrategy
+	}
+	if cfg.Azure.Arc.MachineName != "" {
+		return ArcNamingExplicit
+	}
+	return ArcNamingHostname
+}
+
+// IsArcAutoSuffixEnabled returns whether a name taken by another machine is suffixed instead of failing
+func (cfg *Config) IsArcAutoSuffixEnabled() bool {
+	return cfg.Azure.Arc != nil && cfg.Azure.Arc.AutoSuffix
+}
+
+// ResolveArcMachineName returns the Arc machine name: the one recorded or chosen during this run, else the one
+// derived with the configured naming strategy
+func (cfg *Config) ResolveArcMachineName() (string, error) {
+	if cfg.arcMachineName != "" {
+		return cfg.arcMachineName, nil
+	}
+
+	var name string
+	switch strategy := cfg.GetArcNamingStrategy(); strategy {
+	case ArcNamingExplicit:
+		if cfg.Azure.Arc == nil || cfg.Azure.Arc.MachineName == "" {
+			return "", fmt.Errorf("azure.arc.namingStrategy explicit requires azure.arc.machineName")
+		}
+		return cfg.Azure.Arc.MachineName, nil
+	case ArcNamingHostname, ArcNamingHostnameSuffix:
+		hostname, err := osHostname()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the hostname: %w", err)
+		}
+		name = hostname
+		if strategy == ArcNamingHostnameSuffix {
+			suffix := cfg.Azure.Arc.NameSuffix
+			if suffix == "" {
+				if suffix, err = MachineIDSuffix(); err != nil {
+					return "", err
+				}
+			}
+			name = WithArcNameSuffix(sanitizeArcName(name), suffix)
+		}
+	case ArcNamingSerialNumber:
+		data, err := os.ReadFile(productSerialPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the serial number: %w", err)
+		}
+		serial := strings.TrimSpace(string(data))
+		if placeholderSerialNumbers[strings.ToLower(serial)] {
+			return "", fmt.Errorf("the firmware reports no serial number (%q); use another azure.arc.namingStrategy", serial)
+		}
+		name = serial
+	default:
+		return "", fmt.Errorf("invalid azure.arc.namingStrategy: %s", strategy)
+	}
+
+	name = sanitizeArcName(name)
+	if !arcMachineNamePattern.MatchString(name) {
+		return "", fmt.Errorf("cannot derive a valid Arc machine name from %q", name)
+	}
+	return name, nil
+}
+
+// MachineIDSuffix returns a short suffix that is stable for this OS installation, derived from /etc/machine-id.
+// The ID is hashed because it is meant to stay private to the machine.
+func MachineIDSuffix() (string, error) {
+	data, err := os.ReadFile(machineIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", machineIDPath, err)
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return "", fmt.Errorf("%s is empty", machineIDPath)
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])[:6], nil
+}
+
+// WithArcNameSuffix appends "-suffix" to name, shortening name so the result fits the length limit
+func WithArcNameSuffix(name, suffix string) string {
+	suffix = sanitizeArcName(suffix)
+	if limit := maxArcMachineNameLength - len(suffix) - 1; len(name) > limit {
+		name = strings.TrimRight(name[:limit], ".-_")
+	}
+	return name + "-" + suffix
+}
+
+// sanitizeArcName replaces the characters Azure does not accept in a name derived from the host
+func sanitizeArcName(name string) string {
+	name = invalidArcNameChars.ReplaceAllString(strings.TrimSpace(name), "-")
+	name = strings.Trim(name, ".-_")
+	if len(name) > maxArcMachineNameLength {
+		name = strings.TrimRight(name[:maxArcMachineNameLength], ".-_")
+	}
+	return name
+}
+
+// validateArcNaming checks the naming settings that do not depend on the host
+func (c *Config) validateArcNaming() error {
+	arc := c.Azure.Arc
+	if arc == nil {
+		return nil
+	}
+	if !validArcNamingStrategies[arc.NamingStrategy] {
+		return fmt.Errorf("invalid azure.arc.namingStrategy: %s. Valid values are: %s, %s, %s, %s", arc.NamingStrategy,
+			ArcNamingHostname, ArcNamingHostnameSuffix, ArcNamingSerialNumber, ArcNamingExplicit)
+	}
+	strategy := c.GetArcNamingStrategy()
+	if arc.MachineName != "" {
+		if strategy != ArcNamingExplicit {
+			return fmt.Errorf("azure.arc.machineName requires azure.arc.namingStrategy explicit, not %s", strategy)
+		}
+		if !arcMachineNamePattern.MatchString(arc.MachineName) {
+			return fmt.Errorf("invalid azure.arc.machineName: %q. Expected up to %d letters, digits, '.', '_' or '-'",
+				arc.MachineName, maxArcMachineNameLength)
+		}
+	}
+	if strategy == ArcNamingExplicit && arc.MachineName == "" {
+		return fmt.Errorf("azure.arc.namingStrategy explicit requires azure.arc.machineName")
+	}
+	if arc.NameSuffix != "" {
+		if strategy != ArcNamingHostnameSuffix {
+			return fmt.Errorf("azure.arc.nameSuffix requires azure.arc.namingStrategy %s", ArcNamingHostnameSuffix)
+		}
+		if sanitizeArcName(arc.NameSuffix) != arc.NameSuffix || len(arc.NameSuffix) > 16 {
+			return fmt.Errorf("invalid azure.arc.nameSuffix: %q. Expected up to 16 letters, digits, '.', '_' or '-'", arc.NameSuffix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CurrentClusterPath records the cluster of azure.clusters that `aks-flex-node switch-cluster` moved the node to.
+// It takes precedence over azure.currentCluster, so the node stays in the cluster it was moved to.
+const CurrentClusterPath = "/var/lib/aks-flex-node/current-cluster"
+
+// currentClusterPath is CurrentClusterPath, replaced in tests
+var currentClusterPath = CurrentClusterPath
+
+// RecordCurrentCluster records the cluster the node is a member of; an empty name removes the record
+func RecordCurrentCluster(name string) error {
+	if name == "" {
+		if err := os.Remove(currentClusterPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(currentClusterPath), 0o755); err != nil {
+		return err
+	}
+	tmp := currentClusterPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.ToLower(name)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, currentClusterPath)
+}
+
+// RecordedCurrentCluster returns the cluster recorded by RecordCurrentCluster, or "" when none is
+func RecordedCurrentCluster() (string, error) {
+	data, err := os.ReadFile(currentClusterPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(string(data))), nil
+}
+
+// selectTargetCluster sets azure.targetCluster to the cluster of azure.clusters the node is a member of:
+// the recorded one if any, else azure.currentCluster, else the only cluster listed
+func (c *Config) selectTargetCluster(recorded string) error {
+	if len(c.Azure.Clusters) == 0 {
+		if c.Azure.CurrentCluster != "" {
+			return fmt.Errorf("azure.currentCluster requires azure.clusters")
+		}
+		return nil
+	}
+	if c.Azure.TargetCluster != nil {
+		return fmt.Errorf("azure.targetCluster and azure.clusters cannot both be set")
+	}
+	if c.IsBootstrapTokenConfigured() {
+		return fmt.Errorf("azure.clusters cannot be used with azure.bootstrapToken: a bootstrap token is only valid for one cluster")
+	}
+	if c.IsNodeCertificateConfigured() {
+		return fmt.Errorf("azure.clusters cannot be used with azure.nodeCertificate: node.kubelet.serverURL and caCertData are for one cluster")
+	}
+
+	// Viper lowercases map keys when loading, so names are compared in lower case
+	clusters := make(map[string]*TargetClusterConfig, len(c.Azure.Clusters))
+	for name, cluster := range c.Azure.Clusters {
+		// Check every cluster, not only the current one, so a switch cannot fail on a typo
+		if err := validateCluster(name, cluster); err != nil {
+			return err
+		}
+		if _, dup := clusters[strings.ToLower(name)]; dup {
+			return fmt.Errorf("azure.clusters lists %q more than once", strings.ToLower(name))
+		}
+		clusters[strings.ToLower(name)] = cluster
+	}
+	c.Azure.Clusters = clusters
+
+	names := c.GetClusterNames()
+	name := strings.ToLower(c.Azure.CurrentCluster)
+	switch {
+	case recorded != "":
+		if c.Azure.Clusters[recorded] == nil {
+			return fmt.Errorf("cluster %q recorded in %s is not in azure.clusters. Expected one of %s",
+				recorded, currentClusterPath, strings.Join(names, ", "))
+		}
+		name = recorded
+	case name == "" && len(names) == 1:
+		name = names[0]
+	case name == "":
+		return fmt.Errorf("azure.currentCluster is required when azure.clusters lists more than one cluster")
+	case c.Azure.Clusters[name] == nil:
+		return fmt.Errorf("invalid azure.currentCluster: %q. Expected one of %s", c.Azure.CurrentCluster, strings.Join(names, ", "))
+	}
+
+	cluster := *c.Azure.Clusters[name]
+	c.Azure.TargetCluster = &cluster
+	c.Azure.CurrentCluster = name
+	return nil
+}
+
+// validateCluster checks the required fields of an entry of azure.clusters
+func validateCluster(name string, cluster *TargetClusterConfig) error {
+	if cluster == nil || cluster.ResourceID == "" {
+		return fmt.Errorf("azure.clusters.%s.resourceId is required", name)
+	}
+	if err := validateAzureResourceID(cluster.ResourceID); err != nil {
+		return fmt.Errorf("invalid azure.clusters.%s.resourceId: %w", name, err)
+	}
+	return nil
+}
@@ -1,11 +1,26 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"path/filepath"
 	"regexp"
-	"sync"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/blob"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/keyvault"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/scope"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/tokenbroker"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
 )
 
 const (
@@ -17,77 +32,180 @@ const (
 
 	// Environment variable prefix
 	envPrefix = "AKS_NODE_CONTROLLER"
-)
 
-// Singleton instance for configuration
-var (
-	configInstance *Config
-	configMutex    sync.RWMutex
+	// SchemaVersion is the configuration file format this agent reads and `aks-flex-node init` writes
+	SchemaVersion = "1"
 )
 
-// GetConfig returns the singleton configuration instance.
-// Returns nil if configuration has not been loaded yet. Use LoadConfig() first.
-// This function is thread-safe and handles concurrent access correctly.
-func GetConfig() *Config {
-	configMutex.RLock()
-	defer configMutex.RUnlock()
-	return configInstance
-}
-
 // LoadConfig loads configuration from a JSON file and environment variables.
-// The configPath parameter is required and cannot be empty.
+// The configPath parameter is required and cannot be empty. There is no global configuration;
+// callers pass the returned Config, or a Snapshot of it, to the components that need it.
 // Environment variables can override config file values using the AKS_NODE_CONTROLLER_ prefix.
 // For example: AKS_NODE_CONTROLLER_AZURE_LOCATION=westus2
 func LoadConfig(configPath string) (*Config, error) {
+	return loadConfig(configPath, "")
+}
+
+// LoadLayeredConfig loads the configuration like LoadConfig and applies the spec of the NodeSpec at specPath,
+// as pulled from the agent's source, on top of it. The local file keeps the credentials and the source itself;
+// the pulled NodeSpec cannot change where the agent pulls from.
+func LoadLayeredConfig(configPath, specPath string) (*Config, error) {
+	if specPath == "" {
+		return nil, &errdefs.ConfigError{Path: configPath, Err: fmt.Errorf("NodeSpec path is required")}
+	}
+	return loadConfig(configPath, specPath)
+}
+
+// loadConfig loads the configuration file, with the NodeSpec at specPath applied on top when it is set
+func loadConfig(configPath, specPath string) (*Config, error) {
 	// Require config path to be specified
 	if configPath == "" {
-		return nil, fmt.Errorf("config file path is required")
+		return nil, &errdefs.ConfigError{Path: configPath, Err: fmt.Errorf("config file path is required")}
 	}
 
 	// Set up viper
 	v := viper.New()
-	v.SetConfigType("json")
+	v.SetConfigType(configType(configPath))
 	v.AutomaticEnv()
 	v.SetEnvPrefix(envPrefix)
 
 	// Load the specified config file
 	v.SetConfigFile(configPath)
 	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file at %s: %w", configPath, err)
+		return nil, &errdefs.ConfigError{Path: configPath, Err: fmt.Errorf("failed to read config file at %s: %w", configPath, err)}
+	}
+
+	// A NodeSpec document carries the configuration in its spec
+	root, err := nodeSpecRoot(v)
+	if err != nil {
+		return nil, &errdefs.ConfigError{Path: configPath, Err: err}
+	}
+	nodeSpecName := v.GetString("metadata.name")
+
+	// A NodeSpec pulled from a source is applied on top of the local configuration
+	if specPath != "" {
+		overlay, name, err := readSourceSpec(specPath)
+		if err != nil {
+			return nil, &errdefs.ConfigError{Path: specPath, Err: err}
+		}
+		if root != "" {
+			overlay = map[string]any{root: overlay}
+		}
+		if err := v.MergeConfigMap(overlay); err != nil {
+			return nil, &errdefs.ConfigError{Path: specPath, Err: fmt.Errorf("failed to merge NodeSpec: %w", err)}
+		}
+		nodeSpecName = name
 	}
 
 	// Unmarshal config
 	config := &Config{}
-	if err := v.Unmarshal(config); err != nil {
-		return nil, fmt.Errorf("error unmarshaling config: %w", err)
+	if root == "" {
+		err = v.Unmarshal(config)
+	} else {
+		err = v.UnmarshalKey(root, config)
+	}
+	if root != "" || specPath != "" {
+		config.nodeSpecName = nodeSpecName
+	}
+	if err != nil {
+		return nil, &errdefs.ConfigError{Path: configPath, Err: fmt.Errorf("error unmarshaling config: %w", err)}
 	}
 
 	// Track if managedIdentity was explicitly set in config
 	// This is necessary because viper unmarshals empty JSON objects {} as nil pointers
 	// Using viper.IsSet() correctly detects if the key was present in the config file
-	config.isMIExplicitlySet = v.IsSet("azure.managedIdentity")
+	config.isMIExplicitlySet = v.IsSet(strings.TrimPrefix(root+".azure.managedIdentity", "."))
+
+	// Remember where the config came from so a bootstrap interrupted by a reboot can resume with it
+	if absPath, err := filepath.Abs(configPath); err == nil {
+		config.path = absPath
+	} else {
+		config.path = configPath
+	}
+
+	if err := config.Prepare(); err != nil {
+		return nil, &errdefs.ConfigError{Path: configPath, Err: err}
+	}
+	return config, nil
+}
+
+// Prepare readies a configuration the way LoadConfig readies one read from a file: it selects the target
+// cluster, sets defaults, validates it, and fills in what earlier runs recorded on the node. Library callers
+// that build the configuration in code call it before handing the configuration to the agent.
+func (c *Config) Prepare() error {
+	if c.Azure.ManagedIdentity != nil {
+		c.isMIExplicitlySet = true
+	}
+
+	// A node listing several clusters joins the one it is a member of
+	recorded, err := RecordedCurrentCluster()
+	if err != nil {
+		return fmt.Errorf("failed to read current cluster: %w", err)
+	}
+	if err := c.selectTargetCluster(recorded); err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
+	}
 
 	// Set defaults for any missing values
-	config.SetDefaults()
+	c.SetDefaults()
 
 	// Validate the configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
 	}
 
-	populateTargetClusterInfoFromConfig(config)
+	populateTargetClusterInfoFromConfig(c)
 
-	// Set the singleton instance
-	configMutex.Lock()
-	defer configMutex.Unlock()
-	configInstance = config
+	// Role assignment scopes may reference target cluster info, so expand them after it is populated
+	if err := c.resolveRoleAssignmentScopes(); err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
+	}
 
-	return config, nil
+	// What an earlier run read from ARM about the cluster fills in what the configuration leaves out
+	discovered, err := recordedDiscoveredCluster()
+	if err != nil {
+		return fmt.Errorf("failed to read discovered cluster: %w", err)
+	}
+	if discovered != nil {
+		if err := c.ApplyDiscoveredCluster(*discovered); err != nil {
+			return fmt.Errorf("config validation failed: %w", err)
+		}
+	}
+
+	// The node keeps the Arc machine name it was connected under while the naming settings are unchanged
+	arcName, err := recordedArcMachineName()
+	if err != nil {
+		return fmt.Errorf("failed to read Arc machine name: %w", err)
+	}
+	c.applyArcMachineNameRecord(arcName)
+
+	return nil
+}
+
+// Snapshot returns a deep copy of the configuration. The bootstrapper hands snapshots to components,
+// which treat them as read-only, so steps can run concurrently without sharing mutable state and
+// tests can build independent configurations side by side.
+func (c *Config) Snapshot() *Config {
+	if c == nil {
+		return nil
+	}
+	// Config holds only JSON-encodable values, so the round trip cannot fail
+	data, _ := json.Marshal(c)
+	snapshot := &Config{}
+	_ = json.Unmarshal(data, snapshot)
+	snapshot.isMIExplicitlySet = c.isMIExplicitlySet
+	snapshot.path = c.path
+	snapshot.nodeSpecName = c.nodeSpecName
+	snapshot.clusterDiscovered = c.clusterDiscovered
+	snapshot.arcMachineName = c.arcMachineName
+	snapshot.roleAssignmentScopeTemplates = slices.Clone(c.roleAssignmentScopeTemplates)
+	return snapshot
 }
 
 // SetDefaults sets default values for any missing configuration fields
 func (c *Config) SetDefaults() {
 	c.setAzureCloudDefaults()
+	c.setSubscriptionDefaults()
 	c.setAgentDefaults()
 	c.setPathDefaults()
 	c.setNodeDefaults()
@@ -103,6 +221,16 @@ func (c *Config) setAzureCloudDefaults() {
 	}
 }
 
+func (c *Config) setSubscriptionDefaults() {
+	// The node's resources go in the cluster's subscription unless another one is set
+	if c.Azure.SubscriptionID != "" || c.Azure.TargetCluster == nil {
+		return
+	}
+	if matches := AKSClusterResourceIDPattern.FindStringSubmatch(c.Azure.TargetCluster.ResourceID); len(matches) > 1 {
+		c.Azure.SubscriptionID = matches[1]
+	}
+}
+
 func (c *Config) setAgentDefaults() {
 	// Set default agent configuration if not provided
 	if c.Agent.LogLevel == "" {
@@ -150,12 +278,7 @@ func (c *Config) setNodeDefaults() {
 	if c.Node.Kubelet.Verbosity == 0 {
 		c.Node.Kubelet.Verbosity = 2
 	}
-	if c.Node.Kubelet.ImageGCHighThreshold == 0 {
-		c.Node.Kubelet.ImageGCHighThreshold = 85 // start GC when disk usage > 85%
-	}
-	if c.Node.Kubelet.ImageGCLowThreshold == 0 {
-		c.Node.Kubelet.ImageGCLowThreshold = 80 // stop GC when disk usage < 80%
-	}
+	// Image GC thresholds are left unset here; the kubelet installer picks them from the disk size
 	// Set default DNS service IP if not provided
 	// Note: This default assumes the standard AKS service CIDR (10.0.0.0/16)
 	// Clusters with custom service CIDRs should specify this value explicitly
@@ -166,9 +289,15 @@ func (c *Config) setNodeDefaults() {
 	if c.Node.Kubelet.KubeReserved == nil {
 		c.Node.Kubelet.KubeReserved = make(map[string]string)
 	}
+	if c.Node.Kubelet.SystemReserved == nil {
+		c.Node.Kubelet.SystemReserved = make(map[string]string)
+	}
 	if c.Node.Kubelet.EvictionHard == nil {
 		c.Node.Kubelet.EvictionHard = make(map[string]string)
 	}
+	if c.Node.Tuning.Profile == "" {
+		c.Node.Tuning.Profile = "default"
+	}
 }
 
 func (c *Config) setContainerdDefaults() {
@@ -196,6 +325,9 @@ func (c *Config) setNpdDefaults() {
 // Pattern is case insensitive to handle variations in Azure resource path casing
 var AKSClusterResourceIDPattern = regexp.MustCompile(`(?i)^/subscriptions/([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})/resourcegroups/([a-zA-Z0-9_\-\.]+)/providers/microsoft\.containerservice/managedclusters/([a-zA-Z0-9_\-\.]+)$`)
 
+// guidPattern matches Azure object IDs such as principal IDs
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 // BootstrapTokenPattern is the regex pattern for Kubernetes bootstrap tokens
 // Format: <token-id>.<token-secret> where token-id is 6 chars [a-z0-9] and token-secret is 16 chars [a-z0-9]
 var BootstrapTokenPattern = regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`)
@@ -218,6 +350,23 @@ func validateBootstrapToken(cfg *Config) error {
 		return fmt.Errorf("bootstrap token configuration is nil")
 	}
 
+	if broker := tokenCfg.Broker; broker != nil {
+		if tokenCfg.Token != "" {
+			return fmt.Errorf("token and broker cannot both be set")
+		}
+		if err := tokenbroker.ValidateURL(broker.URL); err != nil {
+			return fmt.Errorf("invalid broker.url: %w", err)
+		}
+		if broker.Audience == "" {
+			return fmt.Errorf("broker.audience is required")
+		}
+		if broker.ClientID != "" && !guidPattern.MatchString(broker.ClientID) {
+			return fmt.Errorf("invalid broker.clientId: %s. Expected a client ID (GUID)", broker.ClientID)
+		}
+		// The broker may return the cluster's API server URL and CA certificate along with the token
+		return nil
+	}
+
 	// Validate token format
 	if !BootstrapTokenPattern.MatchString(tokenCfg.Token) {
 		return fmt.Errorf("invalid bootstrap token format. Expected format: <token-id>.<token-secret> " +
@@ -236,6 +385,65 @@ func validateBootstrapToken(cfg *Config) error {
 	return nil
 }
 
+// Issuers of azure.nodeCertificate
+const (
+	NodeCertificateIssuerKeyVault = "keyVault"
+	NodeCertificateIssuerCA       = "ca"
+)
+
+// validateNodeCertificate validates the node certificate configuration
+func (c *Config) validateNodeCertificate() error {
+	nc := c.Azure.NodeCertificate
+	if nc == nil {
+		return nil
+	}
+	switch nc.Issuer {
+	case NodeCertificateIssuerKeyVault:
+		kv := nc.KeyVault
+		if kv == nil || kv.VaultURL == "" || kv.IssuerName == "" {
+			return fmt.Errorf("keyVault.vaultUrl and keyVault.issuerName are required with issuer keyVault")
+		}
+		if !strings.HasPrefix(kv.VaultURL, "https://") {
+			return fmt.Errorf("invalid keyVault.vaultUrl: %s. Expected format: https://{vault-name}.vault.azure.net", kv.VaultURL)
+		}
+		if kv.CertificateName != "" && !keyVaultObjectNamePattern.MatchString(kv.CertificateName) {
+			return fmt.Errorf("invalid keyVault.certificateName: %q. Expected letters, digits and '-'", kv.CertificateName)
+		}
+		if kv.ValidityMonths < 0 {
+			return fmt.Errorf("keyVault.validityMonths must not be negative")
+		}
+		if kv.ClientID != "" && !guidPattern.MatchString(kv.ClientID) {
+			return fmt.Errorf("invalid keyVault.clientId: %s. Expected a client ID (GUID)", kv.ClientID)
+		}
+	case NodeCertificateIssuerCA:
+		ca := nc.CA
+		if ca == nil || ca.CertFile == "" || ca.KeyFile == "" {
+			return fmt.Errorf("ca.certFile and ca.keyFile are required with issuer ca")
+		}
+		if !filepath.IsAbs(ca.CertFile) || !filepath.IsAbs(ca.KeyFile) {
+			return fmt.Errorf("ca.certFile and ca.keyFile must be absolute paths")
+		}
+		if ca.ValidityDays < 0 {
+			return fmt.Errorf("ca.validityDays must not be negative")
+		}
+	default:
+		return fmt.Errorf("invalid issuer: %q. Valid values are: %s, %s", nc.Issuer, NodeCertificateIssuerKeyVault, NodeCertificateIssuerCA)
+	}
+	if nc.RenewBefore != "" {
+		if d, err := time.ParseDuration(nc.RenewBefore); err != nil || d <= 0 {
+			return fmt.Errorf("invalid renewBefore: %q. Expected a positive duration such as 720h", nc.RenewBefore)
+		}
+	}
+	// Without an Azure identity, the cluster's API server URL and CA certificate cannot be read from ARM
+	if c.Node.Kubelet.ServerURL == "" || c.Node.Kubelet.CACertData == "" {
+		return fmt.Errorf("node.kubelet.serverURL and node.kubelet.caCertData are required")
+	}
+	return nil
+}
+
+// keyVaultObjectNamePattern matches the names Key Vault accepts for certificates and secrets
+var keyVaultObjectNamePattern = regexp.MustCompile(`^[0-9a-zA-Z-]{1,127}$`)
+
 // validLogLevels defines the allowed logging levels for the agent
 var validLogLevels = map[string]bool{
 	"debug":   true,
@@ -252,6 +460,10 @@ var validAzureClouds = map[string]bool{
 
 // Validate validates the configuration and ensures all required fields are set
 func (c *Config) Validate() error {
+	if c.SchemaVersion != "" && c.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("unsupported schemaVersion %q: this agent reads schemaVersion %q", c.SchemaVersion, SchemaVersion)
+	}
+
 	// Validate required Azure configuration (core requirements for Arc discovery)
 	if c.Azure.SubscriptionID == "" {
 		return fmt.Errorf("azure.subscriptionId is required")
@@ -259,8 +471,9 @@ func (c *Config) Validate() error {
 	if c.Azure.TenantID == "" {
 		return fmt.Errorf("azure.tenantId is required")
 	}
-	if c.Azure.TargetCluster.Location == "" {
-		return fmt.Errorf("azure.targetCluster.location is required")
+	// Without an Azure credential the cluster's location cannot be read from ARM
+	if c.Azure.TargetCluster.Location == "" && c.IsBootstrapTokenConfigured() {
+		return fmt.Errorf("azure.targetCluster.location is required with azure.bootstrapToken")
 	}
 	if c.Azure.TargetCluster.ResourceID == "" {
 		return fmt.Errorf("azure.targetCluster.resourceId is required")
@@ -295,12 +508,15 @@ func (c *Config) Validate() error {
 	if c.IsBootstrapTokenConfigured() {
 		authMethodCount++
 	}
+	if c.IsNodeCertificateConfigured() {
+		authMethodCount++
+	}
 
 	if authMethodCount == 0 {
-		return fmt.Errorf("at least one authentication method must be configured: Arc, Service Principal, Managed Identity, or Bootstrap Token")
+		return fmt.Errorf("at least one authentication method must be configured: Arc, Service Principal, Managed Identity, Bootstrap Token, or Node Certificate")
 	}
 	if authMethodCount > 1 {
-		return fmt.Errorf("only one authentication method can be enabled at a time: Arc, Service Principal, Managed Identity, or Bootstrap Token")
+		return fmt.Errorf("only one authentication method can be enabled at a time: Arc, Service Principal, Managed Identity, Bootstrap Token, or Node Certificate")
 	}
 
 	// Validate bootstrap token if configured
@@ -310,30 +526,1666 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := c.validateNodeCertificate(); err != nil {
+		return fmt.Errorf("invalid node certificate configuration: %w", err)
+	}
+
+	if err := c.validateServicePrincipal(); err != nil {
+		return err
+	}
+
+	if err := c.validateTenants(); err != nil {
+		return err
+	}
+
+	if err := c.validatePrivateLink(); err != nil {
+		return err
+	}
+
+	if err := c.validateAzureTimeouts(); err != nil {
+		return err
+	}
+
+	if err := c.validateARMWriteLimit(); err != nil {
+		return err
+	}
+
+	if err := c.validateCanary(); err != nil {
+		return err
+	}
+
+	if err := c.validateObserve(); err != nil {
+		return err
+	}
+
+	if err := c.validatePrerequisites(); err != nil {
+		return err
+	}
+
+	if err := c.validateCATrust(); err != nil {
+		return err
+	}
+
+	if err := c.validateResourceManagers(); err != nil {
+		return err
+	}
+
+	if err := c.validateNodeNetwork(); err != nil {
+		return err
+	}
+
+	if err := c.validateNodeDNS(); err != nil {
+		return err
+	}
+
+	if err := c.validateCNIMTU(); err != nil {
+		return err
+	}
+
+	if err := c.validateNodePool(); err != nil {
+		return err
+	}
+
+	if err := c.validateTuning(); err != nil {
+		return err
+	}
+
+	if err := c.validateDiskPressure(); err != nil {
+		return err
+	}
+
+	if err := c.validateNPDPlugins(); err != nil {
+		return err
+	}
+
+	if err := c.validateNPDMetrics(); err != nil {
+		return err
+	}
+
+	if err := c.validateFluentBit(); err != nil {
+		return err
+	}
+
+	if err := c.validateSSH(); err != nil {
+		return err
+	}
+
+	if err := c.validateSRIOV(); err != nil {
+		return err
+	}
+
+	if err := c.validateContainerRuntime(); err != nil {
+		return err
+	}
+
+	if err := c.validateImagePrePull(); err != nil {
+		return err
+	}
+
+	if err := c.validateDownloads(); err != nil {
+		return err
+	}
+
+	if c.Templates.Directory != "" && !filepath.IsAbs(c.Templates.Directory) {
+		return fmt.Errorf("invalid templates.directory: %s. Expected an absolute path", c.Templates.Directory)
+	}
+
+	if err := c.validateReboot(); err != nil {
+		return err
+	}
+
+	if err := c.validateDrift(); err != nil {
+		return err
+	}
+
+	if err := c.validatePatching(); err != nil {
+		return err
+	}
+
+	if err := c.validateRoleAssignmentCheck(); err != nil {
+		return err
+	}
+
+	if err := c.validateSpecSource(); err != nil {
+		return err
+	}
+
+	if err := c.validateWebhook(); err != nil {
+		return err
+	}
+
+	if err := c.validateHeartbeat(); err != nil {
+		return err
+	}
+
+	if err := c.validateTelemetry(); err != nil {
+		return err
+	}
+
+	if err := c.validateState(); err != nil {
+		return err
+	}
+
+	if err := c.validateComponents(); err != nil {
+		return err
+	}
+
+	if err := c.validateComponentToggles(); err != nil {
+		return err
+	}
+
+	if err := c.validateStaticPods(); err != nil {
+		return err
+	}
+
+	if err := c.validateCustomScripts(); err != nil {
+		return err
+	}
+
+	if !validConflictingAgentModes[c.Preflight.ConflictingAgents] {
+		return fmt.Errorf("invalid preflight.conflictingAgents: %s. Valid values are: abort, stop-and-disable, coexist", c.Preflight.ConflictingAgents)
+	}
+
+	if c.Azure.Arc != nil && !validArcReinstallStrategies[c.Azure.Arc.ReinstallStrategy] {
+		return fmt.Errorf("invalid azure.arc.reinstallStrategy: %s. Valid values are: fail, adopt, recreate", c.Azure.Arc.ReinstallStrategy)
+	}
+
+	if err := c.validateArcNaming(); err != nil {
+		return err
+	}
+
+	if err := c.validateArcExtensions(); err != nil {
+		return err
+	}
+
+	if !validClusterCompatibilityModes[c.Preflight.ClusterCompatibility] {
+		return fmt.Errorf("invalid preflight.clusterCompatibility: %s. Valid values are: enforce, warn", c.Preflight.ClusterCompatibility)
+	}
+
+	if err := c.validateAPIServerPath(); err != nil {
+		return err
+	}
+
+	if err := c.validateInstallLimits(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateAPIServerPath checks the API server path check's mode and limits
+func (c *Config) validateAPIServerPath() error {
+	p := c.Preflight.APIServerPath
+	if p == nil {
+		return nil
+	}
+	switch p.Mode {
+	case "", "enforce", "warn", "skip":
+	default:
+		return fmt.Errorf("invalid preflight.apiServerPath.mode: %s. Valid values are: enforce, warn, skip", p.Mode)
+	}
+	if p.MaxLatencyMs < 0 {
+		return fmt.Errorf("preflight.apiServerPath.maxLatencyMs must not be negative")
+	}
+	if p.MinPathMTU != 0 && (p.MinPathMTU < 576 || p.MinPathMTU > 9216) {
+		return fmt.Errorf("preflight.apiServerPath.minPathMTU must be between 576 and 9216, got %d", p.MinPathMTU)
+	}
+	return nil
+}
+
+var (
+	// cpuQuotaPattern matches systemd's CPUQuota= values, a percentage of one CPU
+	cpuQuotaPattern = regexp.MustCompile(`^[1-9][0-9]*%$`)
+	// memoryMaxPattern matches systemd's MemoryMax= values, bytes with an optional K, M, G or T suffix
+	memoryMaxPattern = regexp.MustCompile(`^[1-9][0-9]*[KMGT]?$`)
+)
+
+// validateInstallLimits checks the install limits against the values systemd accepts
+func (c *Config) validateInstallLimits() error {
+	limits := c.Agent.InstallLimits
+	if limits.CPUQuota != "" && !cpuQuotaPattern.MatchString(limits.CPUQuota) {
+		return fmt.Errorf("invalid agent.installLimits.cpuQuota: %s. Expected a percentage of one CPU such as 50%%", limits.CPUQuota)
+	}
+	if limits.IOWeight != 0 && (limits.IOWeight < 1 || limits.IOWeight > 10000) {
+		return fmt.Errorf("agent.installLimits.ioWeight must be between 1 and 10000, got %d", limits.IOWeight)
+	}
+	if limits.MemoryMax != "" && !memoryMaxPattern.MatchString(limits.MemoryMax) {
+		return fmt.Errorf("invalid agent.installLimits.memoryMax: %s. Expected bytes with an optional K, M, G or T suffix such as 512M", limits.MemoryMax)
+	}
+	return nil
+}
+
+// arcExtensionNamePattern matches the names Azure accepts for machine extensions
+var arcExtensionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,63}$`)
+
+// validateArcExtensions validates the extensions declared for the Arc machine
+func (c *Config) validateArcExtensions() error {
+	extensions := c.GetArcExtensions()
+	if len(extensions) > 0 && !c.IsARCEnabled() {
+		return fmt.Errorf("azure.arc.extensions requires azure.arc.enabled")
+	}
+	seen := make(map[string]bool, len(extensions))
+	for idx, ext := range extensions {
+		if !arcExtensionNamePattern.MatchString(ext.Name) {
+			return fmt.Errorf("invalid azure.arc.extensions[%d].name: %q. Expected letters, digits, '.', '_' or '-'", idx, ext.Name)
+		}
+		if seen[strings.ToLower(ext.Name)] {
+			return fmt.Errorf("azure.arc.extensions declares %q more than once", ext.Name)
+		}
+		seen[strings.ToLower(ext.Name)] = true
+		if ext.Publisher == "" || ext.Type == "" {
+			return fmt.Errorf("azure.arc.extensions[%d] (%s) requires publisher and type", idx, ext.Name)
+		}
+		files := []struct{ field, path string }{{"settingsFile", ext.SettingsFile}, {"protectedSettingsFile", ext.ProtectedSettingsFile}}
+		for _, file := range files {
+			if file.path != "" && !filepath.IsAbs(file.path) {
+				return fmt.Errorf("invalid azure.arc.extensions[%d].%s: %q. Expected an absolute path", idx, file.field, file.path)
+			}
+		}
+	}
+	return nil
+}
+
+// secretReferencePattern matches a secret reference: a URI scheme, a colon and what the scheme's provider
+// understands. Which schemes are available depends on the providers registered in the build, so only the
+// syntax is checked here.
+var secretReferencePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*:\S+$`)
+
+// validateSecretReference validates the secret reference set in field
+func validateSecretReference(field, reference string) error {
+	if !secretReferencePattern.MatchString(reference) {
+		return fmt.Errorf("invalid %s: expected a secret reference such as env:NAME, file:/path, keyvault:<secret ID> or pkcs11:<URI>", field)
+	}
 	return nil
 }
 
-// populateTargetClusterInfoFromConfig extracts cluster information from the resource ID
-// This function should only be called after validateAzureResourceID confirms the format is correct
-func populateTargetClusterInfoFromConfig(cfg *Config) {
-	matches := AKSClusterResourceIDPattern.FindStringSubmatch(cfg.Azure.TargetCluster.ResourceID)
-	if len(matches) < 4 {
-		// This should not happen if validation occurred first, but handle gracefully
-		return
+// validateServicePrincipal validates the service principal credential and its rotation source
+func (c *Config) validateServicePrincipal() error {
+	sp := c.Azure.ServicePrincipal
+	if sp == nil {
+		return nil
+	}
+	if sp.ClientSecret != "" && sp.ClientCertificateFile != "" {
+		return fmt.Errorf("azure.servicePrincipal.clientSecret and azure.servicePrincipal.clientCertificateFile cannot both be set")
+	}
+	if sp.ClientCertificateFile != "" && !filepath.IsAbs(sp.ClientCertificateFile) {
+		return fmt.Errorf("invalid azure.servicePrincipal.clientCertificateFile: %q. Expected an absolute path", sp.ClientCertificateFile)
 	}
 
-	subscriptionID := matches[1]
-	resourceGroupName := matches[2]
-	clusterName := matches[3]
+	rotation := sp.Rotation
+	if rotation == nil {
+		return nil
+	}
+	sources := 0
+	for _, source := range []string{rotation.KeyVaultSecretID, rotation.File, rotation.Secret} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("azure.servicePrincipal.rotation requires exactly one of keyVaultSecretId, file and secret")
+	}
+	if rotation.Secret != "" {
+		if err := validateSecretReference("azure.servicePrincipal.rotation.secret", rotation.Secret); err != nil {
+			return err
+		}
+	}
+	if rotation.KeyVaultSecretID != "" {
+		id, err := keyvault.ParseSecretID(rotation.KeyVaultSecretID)
+		if err != nil {
+			return fmt.Errorf("invalid azure.servicePrincipal.rotation.keyVaultSecretId: %w", err)
+		}
+		if id.Version != "" {
+			return fmt.Errorf("invalid azure.servicePrincipal.rotation.keyVaultSecretId: %q. Expected a secret ID without a version, so new versions are picked up", rotation.KeyVaultSecretID)
+		}
+	}
+	if rotation.File != "" && !filepath.IsAbs(rotation.File) {
+		return fmt.Errorf("invalid azure.servicePrincipal.rotation.file: %q. Expected an absolute path", rotation.File)
+	}
+	if rotation.Interval != "" {
+		if d, err := time.ParseDuration(rotation.Interval); err != nil || d < time.Minute {
+			return fmt.Errorf("invalid azure.servicePrincipal.rotation.interval: %q. Expected a duration of at least 1m such as 1h", rotation.Interval)
+		}
+	}
+	return nil
+}
 
-	// AKS node resource group follows the pattern: MC_{cluster-resource-group}_{cluster-name}_{location}
-	mcResourceGroup := fmt.Sprintf("MC_%s_%s_%s",
-		resourceGroupName,
-		clusterName,
-		cfg.Azure.TargetCluster.Location)
+// validateTenants validates the cross-tenant configuration
+func (c *Config) validateTenants() error {
+	if c.Azure.TargetCluster.TenantID != "" && !guidPattern.MatchString(c.Azure.TargetCluster.TenantID) {
+		return fmt.Errorf("invalid azure.targetCluster.tenantId: %s. Expected a tenant ID (GUID)", c.Azure.TargetCluster.TenantID)
+	}
+	for idx, tenantID := range c.Azure.AuxiliaryTenantIDs {
+		if !guidPattern.MatchString(tenantID) {
+			return fmt.Errorf("invalid azure.auxiliaryTenantIds[%d]: %s. Expected a tenant ID (GUID)", idx, tenantID)
+		}
+	}
 
-	cfg.Azure.TargetCluster.Name = clusterName
-	cfg.Azure.TargetCluster.ResourceGroup = resourceGroupName
-	cfg.Azure.TargetCluster.SubscriptionID = subscriptionID
-	cfg.Azure.TargetCluster.NodeResourceGroup = mcResourceGroup
+	// Managed identities only exist in their home tenant and cannot obtain tokens for another one
+	if c.IsMIConfigured() && len(c.GetAuxiliaryTenantIDs()) > 0 {
+		return fmt.Errorf("managed identity authentication cannot be used across tenants; " +
+			"use a multi-tenant service principal or Azure CLI authentication instead")
+	}
+	return nil
+}
+
+// validatePrivateLink validates the private endpoint configuration
+func (c *Config) validatePrivateLink() error {
+	if !c.IsPrivateLinkEnabled() {
+		return nil
+	}
+
+	if scopeID := c.Azure.PrivateLink.ArcPrivateLinkScopeID; scopeID != "" {
+		parsed, err := scope.Parse(scopeID)
+		if err != nil || parsed.Kind != scope.KindResource ||
+			!strings.EqualFold(parsed.ProviderNamespace, "Microsoft.HybridCompute") ||
+			len(parsed.ResourceTypes) != 1 || !strings.EqualFold(parsed.ResourceTypes[0], "privateLinkScopes") {
+			return fmt.Errorf("invalid azure.privateLink.arcPrivateLinkScopeId: %s. Expected format: "+
+				"/subscriptions/{subscription-id}/resourceGroups/{resource-group}/providers/Microsoft.HybridCompute/privateLinkScopes/{name}", scopeID)
+		}
+	}
+
+	for idx, host := range c.Azure.PrivateLink.AdditionalHosts {
+		if host == "" || strings.ContainsAny(host, "/: ") {
+			return fmt.Errorf("invalid azure.privateLink.additionalHosts[%d]: %q. Expected a host name", idx, host)
+		}
+	}
+	return nil
+}
+
+// managedIdentityNamePattern matches the names Azure accepts for user-assigned managed identities
+var managedIdentityNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{2,127}$`)
+
+// validatePrerequisites validates the Azure resources bootstrap creates when azure.prerequisites.deploy is set
+func (c *Config) validatePrerequisites() error {
+	if !c.IsPrerequisitesDeployEnabled() {
+		return nil
+	}
+	prerequisites := c.Azure.Prerequisites
+
+	if c.GetPrerequisitesResourceGroup() == "" || c.GetPrerequisitesLocation() == "" {
+		return fmt.Errorf("azure.prerequisites.resourceGroup and azure.prerequisites.location are required when " +
+			"azure.prerequisites.deploy is set and no Arc resource group and location are configured")
+	}
+	if name := prerequisites.ManagedIdentityName; name != "" && !managedIdentityNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid azure.prerequisites.managedIdentityName: %q. Expected 3-128 letters, digits, "+
+			"hyphens and underscores, starting with a letter or digit", name)
+	}
+
+	if len(prerequisites.PrivateDNSZoneIDs) == 0 {
+		return nil
+	}
+	if !isResourceID(prerequisites.VirtualNetworkID, "Microsoft.Network", "virtualNetworks") {
+		return fmt.Errorf("invalid azure.prerequisites.virtualNetworkId: %q. Expected format: "+
+			"/subscriptions/{subscription-id}/resourceGroups/{resource-group}/providers/Microsoft.Network/virtualNetworks/{name}",
+			prerequisites.VirtualNetworkID)
+	}
+	for idx, zoneID := range prerequisites.PrivateDNSZoneIDs {
+		if !isResourceID(zoneID, "Microsoft.Network", "privateDnsZones") {
+			return fmt.Errorf("invalid azure.prerequisites.privateDnsZoneIds[%d]: %q. Expected format: "+
+				"/subscriptions/{subscription-id}/resourceGroups/{resource-group}/providers/Microsoft.Network/privateDnsZones/{zone}",
+				idx, zoneID)
+		}
+	}
+	return nil
+}
+
+// isResourceID reports whether id is the ID of a top-level resource of the given provider and type
+func isResourceID(id, providerNamespace, resourceType string) bool {
+	parsed, err := scope.Parse(id)
+	return err == nil && parsed.Kind == scope.KindResource &&
+		strings.EqualFold(parsed.ProviderNamespace, providerNamespace) &&
+		len(parsed.ResourceTypes) == 1 && strings.EqualFold(parsed.ResourceTypes[0], resourceType)
+}
+
+// validateAzureTimeouts validates the optional Azure API call limits
+func (c *Config) validateAzureTimeouts() error {
+	timeouts := c.Azure.Timeouts
+	if timeouts == nil {
+		return nil
+	}
+	if timeouts.PerTry != "" {
+		if timeout, err := time.ParseDuration(timeouts.PerTry); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid azure.timeouts.perTry: %s. Expected a positive duration such as 1m", timeouts.PerTry)
+		}
+	}
+	if timeouts.Operation != "" {
+		if timeout, err := time.ParseDuration(timeouts.Operation); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid azure.timeouts.operation: %s. Expected a positive duration such as 5m", timeouts.Operation)
+		}
+	}
+	if c.GetAzureTryTimeout() > c.GetAzureOperationTimeout() {
+		return fmt.Errorf("invalid azure.timeouts: perTry %s must not exceed operation %s", c.GetAzureTryTimeout(), c.GetAzureOperationTimeout())
+	}
+	return nil
+}
+
+// validateARMWriteLimit validates the container coordinating the fleet's ARM writes
+func (c *Config) validateARMWriteLimit() error {
+	limit := c.Azure.ARMWriteLimit
+	if limit == nil {
+		return nil
+	}
+	if err := blob.ValidateContainerURL(limit.ContainerURL); err != nil {
+		return fmt.Errorf("invalid azure.armWriteLimit.containerUrl: %w", err)
+	}
+	if limit.MaxConcurrent < 0 {
+		return fmt.Errorf("invalid azure.armWriteLimit.maxConcurrent: %d. Expected a positive number", limit.MaxConcurrent)
+	}
+	return nil
+}
+
+// validateCanary validates the smoke pod run after changes
+func (c *Config) validateCanary() error {
+	canary := c.Agent.Canary
+	if !canary.Enabled {
+		return nil
+	}
+	if !filepath.IsAbs(canary.Kubeconfig) {
+		return fmt.Errorf("invalid agent.canary.kubeconfig: %q. Expected an absolute path", canary.Kubeconfig)
+	}
+	if len(c.GetCanaryNamespace()) > 63 || !componentNamePattern.MatchString(c.GetCanaryNamespace()) {
+		return fmt.Errorf("invalid agent.canary.namespace: %q. Expected a Kubernetes namespace name", canary.Namespace)
+	}
+	if canary.Timeout != "" {
+		if timeout, err := time.ParseDuration(canary.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid agent.canary.timeout: %s. Expected a positive duration such as 3m", canary.Timeout)
+		}
+	}
+	return nil
+}
+
+// validObserveOnFailure lists what happens when the node fails its observation window
+var validObserveOnFailure = []string{"fail", "rollback"}
+
+// validateObserve validates the observation window after bootstrap
+func (c *Config) validateObserve() error {
+	observe := c.Agent.Observe
+	if observe.Window == "" {
+		return nil
+	}
+	window, err := time.ParseDuration(observe.Window)
+	if err != nil || window <= 0 || window > 2*time.Hour {
+		return fmt.Errorf("invalid agent.observe.window: %s. Expected a duration up to 2h such as 10m", observe.Window)
+	}
+	if observe.Interval != "" {
+		interval, err := time.ParseDuration(observe.Interval)
+		if err != nil || interval < 5*time.Second || interval > window {
+			return fmt.Errorf("invalid agent.observe.interval: %s. Expected a duration of at least 5s and at most the window", observe.Interval)
+		}
+	}
+	if observe.MaxRestarts < 0 {
+		return fmt.Errorf("invalid agent.observe.maxRestarts: %d", observe.MaxRestarts)
+	}
+	if observe.OnFailure != "" && !slices.Contains(validObserveOnFailure, observe.OnFailure) {
+		return fmt.Errorf("invalid agent.observe.onFailure: %s. Valid values are: %s", observe.OnFailure, strings.Join(validObserveOnFailure, ", "))
+	}
+	return nil
+}
+
+// validateCATrust validates the custom CA trust configuration
+func (c *Config) validateCATrust() error {
+	if kv := c.CATrust.KeyVault; kv != nil {
+		if kv.VaultURL == "" {
+			return fmt.Errorf("caTrust.keyVault.vaultUrl is required")
+		}
+		if !strings.HasPrefix(kv.VaultURL, "https://") {
+			return fmt.Errorf("invalid caTrust.keyVault.vaultUrl: %s. Expected format: https://{vault-name}.vault.azure.net", kv.VaultURL)
+		}
+		if len(kv.CertificateNames) == 0 {
+			return fmt.Errorf("caTrust.keyVault.certificateNames must list at least one certificate")
+		}
+	}
+
+	for idx, registry := range c.CATrust.Registries {
+		if registry == "" || strings.ContainsAny(registry, "/ ") {
+			return fmt.Errorf("invalid caTrust.registries[%d]: %q. Expected a registry host such as myregistry.example.com:5000", idx, registry)
+		}
+	}
+	if len(c.CATrust.Registries) > 0 && !c.IsCATrustConfigured() {
+		return fmt.Errorf("caTrust.registries requires at least one CA certificate source")
+	}
+	return nil
+}
+
+// validCPUManagerPolicies, validTopologyManagerPolicies and validTopologyManagerScopes define the supported kubelet values
+var (
+	validCPUManagerPolicies      = map[string]bool{"": true, "none": true, "static": true}
+	validTopologyManagerPolicies = map[string]bool{"": true, "none": true, "best-effort": true, "restricted": true, "single-numa-node": true}
+	validTopologyManagerScopes   = map[string]bool{"": true, "container": true, "pod": true}
+)
+
+// cpuListPattern matches kubelet CPU lists such as "0", "0-3" or "0,2,4-7"
+var cpuListPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+// validCgroupDrivers are the cgroup drivers kubelet and the container runtimes support; empty means systemd
+var validCgroupDrivers = map[string]bool{"": true, "systemd": true, "cgroupfs": true}
+
+// validateResourceManagers validates hugepages, the cgroup driver, kubelet CPU/topology manager settings and
+// the shared host share
+func (c *Config) validateResourceManagers() error {
+	kubelet := c.Node.Kubelet
+	if !validCPUManagerPolicies[kubelet.CPUManagerPolicy] {
+		return fmt.Errorf("invalid node.kubelet.cpuManagerPolicy: %s. Valid values are: none, static", kubelet.CPUManagerPolicy)
+	}
+	if !validTopologyManagerPolicies[kubelet.TopologyManagerPolicy] {
+		return fmt.Errorf("invalid node.kubelet.topologyManagerPolicy: %s. Valid values are: none, best-effort, restricted, single-numa-node", kubelet.TopologyManagerPolicy)
+	}
+	if !validTopologyManagerScopes[kubelet.TopologyManagerScope] {
+		return fmt.Errorf("invalid node.kubelet.topologyManagerScope: %s. Valid values are: container, pod", kubelet.TopologyManagerScope)
+	}
+	if kubelet.ReservedCPUs != "" {
+		if kubelet.CPUManagerPolicy != "static" {
+			return fmt.Errorf("node.kubelet.reservedCpus requires node.kubelet.cpuManagerPolicy to be static")
+		}
+		if !cpuListPattern.MatchString(kubelet.ReservedCPUs) {
+			return fmt.Errorf("invalid node.kubelet.reservedCpus: %s. Expected a CPU list such as 0-1 or 0,2", kubelet.ReservedCPUs)
+		}
+	}
+
+	// The static policy needs a non-zero CPU reservation to carve exclusive CPUs from
+	if kubelet.CPUManagerPolicy == "static" && kubelet.DisableAutoReservation && kubelet.ReservedCPUs == "" &&
+		kubelet.KubeReserved["cpu"] == "" && kubelet.SystemReserved["cpu"] == "" {
+		return fmt.Errorf("node.kubelet.cpuManagerPolicy static requires a CPU reservation: set reservedCpus, " +
+			"kubeReserved.cpu or systemReserved.cpu, or enable automatic reservation")
+	}
+
+	if shared := kubelet.SharedHost; shared != nil {
+		if shared.CPUPercent < 0 || shared.CPUPercent > 100 || shared.MemoryPercent < 0 || shared.MemoryPercent > 100 {
+			return fmt.Errorf("node.kubelet.sharedHost cpuPercent and memoryPercent must be between 1 and 100")
+		}
+		// The withheld share is computed from the host's capacity along with the other reservations
+		if kubelet.DisableAutoReservation {
+			return fmt.Errorf("node.kubelet.sharedHost requires automatic reservation; remove node.kubelet.disableAutoReservation")
+		}
+		if shared.Isolate {
+			if shared.CPUPercent%100 == 0 && shared.MemoryPercent%100 == 0 {
+				return fmt.Errorf("node.kubelet.sharedHost.isolate requires cpuPercent or memoryPercent below 100")
+			}
+			// Pods are only placed in the slice when kubelet manages their cgroups through systemd
+			if c.GetCgroupDriver() != "systemd" {
+				return fmt.Errorf("node.kubelet.sharedHost.isolate requires node.cgroup.driver systemd")
+			}
+		}
+	}
+
+	if c.Node.Hugepages.Pages2Mi < 0 || c.Node.Hugepages.Pages1Gi < 0 {
+		return fmt.Errorf("node.hugepages page counts must not be negative")
+	}
+	if !validCgroupDrivers[c.Node.Cgroup.Driver] {
+		return fmt.Errorf("invalid node.cgroup.driver: %s. Valid values are: systemd, cgroupfs", c.Node.Cgroup.Driver)
+	}
+	return nil
+}
+
+// populateTargetClusterInfoFromConfig extracts cluster information from the resource ID
+// This function should only be called after validateAzureResourceID confirms the format is correct
+func populateTargetClusterInfoFromConfig(cfg *Config) {
+	matches := AKSClusterResourceIDPattern.FindStringSubmatch(cfg.Azure.TargetCluster.ResourceID)
+	if len(matches) < 4 {
+		// This should not happen if validation occurred first, but handle gracefully
+		return
+	}
+
+	subscriptionID := matches[1]
+	resourceGroupName := matches[2]
+	clusterName := matches[3]
+
+	cfg.Azure.TargetCluster.Name = clusterName
+	cfg.Azure.TargetCluster.ResourceGroup = resourceGroupName
+	cfg.Azure.TargetCluster.SubscriptionID = subscriptionID
+
+	// Until the cluster is read from ARM, assume the default node resource group:
+	// MC_{cluster-resource-group}_{cluster-name}_{location}. Clusters may set another name.
+	if cfg.Azure.TargetCluster.Location != "" {
+		cfg.Azure.TargetCluster.NodeResourceGroup = fmt.Sprintf("MC_%s_%s_%s",
+			resourceGroupName,
+			clusterName,
+			cfg.Azure.TargetCluster.Location)
+	}
+}
+
+// resolveRoleAssignmentScopes expands placeholders in azure.arc.roleAssignments scopes and validates the result,
+// so malformed scopes are rejected at config load rather than by ARM at assignment time. Scopes using
+// {nodeResourceGroup} before the cluster's location is known are only checked, and expanded once it is discovered.
+func (c *Config) resolveRoleAssignmentScopes() error {
+	if c.Azure.Arc == nil {
+		return nil
+	}
+
+	// Scopes are expanded from the templates as written, so a discovered cluster can change them
+	if c.roleAssignmentScopeTemplates == nil {
+		for _, ra := range c.Azure.Arc.RoleAssignments {
+			c.roleAssignmentScopeTemplates = append(c.roleAssignmentScopeTemplates, ra.Scope)
+		}
+	}
+
+	vars := c.ScopeTemplateVariables()
+	pending := vars["nodeResourceGroup"] == ""
+	if pending {
+		// Any valid name lets the rest of the scope be checked
+		vars["nodeResourceGroup"] = "pending-discovery"
+	}
+	for idx := range c.Azure.Arc.RoleAssignments {
+		ra := &c.Azure.Arc.RoleAssignments[idx]
+		if idx < len(c.roleAssignmentScopeTemplates) {
+			ra.Scope = c.roleAssignmentScopeTemplates[idx]
+		}
+		if ra.Role == "" {
+			return fmt.Errorf("azure.arc.roleAssignments[%d].role is required", idx)
+		}
+		if ra.Scope == "" {
+			return fmt.Errorf("azure.arc.roleAssignments[%d].scope is required", idx)
+		}
+		if ra.PrincipalID != "" && !guidPattern.MatchString(ra.PrincipalID) {
+			return fmt.Errorf("invalid azure.arc.roleAssignments[%d].principalId: %s. Expected an object ID (GUID)", idx, ra.PrincipalID)
+		}
+		if err := validateRoleAssignmentCondition(ra); err != nil {
+			return fmt.Errorf("invalid azure.arc.roleAssignments[%d]: %w", idx, err)
+		}
+		if err := validateDelegatedManagedIdentity(ra); err != nil {
+			return fmt.Errorf("invalid azure.arc.roleAssignments[%d]: %w", idx, err)
+		}
+		expanded, err := scope.Expand(ra.Scope, vars)
+		if err != nil {
+			return fmt.Errorf("invalid azure.arc.roleAssignments[%d].scope: %w", idx, err)
+		}
+		if !pending || !strings.Contains(ra.Scope, "{nodeResourceGroup}") {
+			ra.Scope = expanded
+		}
+	}
+	return nil
+}
+
+// maxConditionLength is the longest role assignment condition ARM accepts
+const maxConditionLength = 8192
+
+// validateRoleAssignmentCondition checks the ABAC condition of a role assignment. ARM only accepts
+// version 2.0 of the condition language; the expression itself is checked by ARM when it is assigned.
+func validateRoleAssignmentCondition(ra *RoleAssignmentConfig) error {
+	if ra.Condition == "" {
+		if ra.ConditionVersion != "" {
+			return fmt.Errorf("conditionVersion is set without a condition")
+		}
+		return nil
+	}
+	if len(ra.Condition) > maxConditionLength {
+		return fmt.Errorf("condition is %d characters long, ARM accepts at most %d", len(ra.Condition), maxConditionLength)
+	}
+	if ra.ConditionVersion != "" && ra.ConditionVersion != "2.0" {
+		return fmt.Errorf("unsupported conditionVersion %q. Expected 2.0", ra.ConditionVersion)
+	}
+	return nil
+}
+
+// validateDelegatedManagedIdentity checks the delegated managed identity of a role assignment. The assignment
+// goes to that identity, so principalId must be set to its principal ID rather than default to the Arc identity.
+func validateDelegatedManagedIdentity(ra *RoleAssignmentConfig) error {
+	if ra.DelegatedManagedIdentityResourceID == "" {
+		return nil
+	}
+	if !isResourceID(ra.DelegatedManagedIdentityResourceID, "Microsoft.ManagedIdentity", "userAssignedIdentities") {
+		return fmt.Errorf("delegatedManagedIdentityResourceId %s is not a user-assigned managed identity resource ID", ra.DelegatedManagedIdentityResourceID)
+	}
+	if ra.PrincipalID == "" {
+		return fmt.Errorf("delegatedManagedIdentityResourceId requires principalId, the principal ID of the delegated identity")
+	}
+	return nil
+}
+
+// validConflictingAgentModes lists the supported remediation modes for conflicting agents; empty means abort
+var validConflictingAgentModes = map[string]bool{"": true, "abort": true, "stop-and-disable": true, "coexist": true}
+
+// validArcReinstallStrategies lists the supported handling of stale Arc machine resources; empty means fail
+var validArcReinstallStrategies = map[string]bool{"": true, "fail": true, "adopt": true, "recreate": true}
+
+// validClusterCompatibilityModes lists the supported handling of cluster incompatibilities; empty means enforce
+var validClusterCompatibilityModes = map[string]bool{"": true, "enforce": true, "warn": true}
+
+// IP families of node.network.ipFamilies, named like the cluster's network profile names them
+const (
+	IPFamilyIPv4 = "IPv4"
+	IPFamilyIPv6 = "IPv6"
+)
+
+// validateNodeNetwork validates the IP families and that the node IPs match them
+func (c *Config) validateNodeNetwork() error {
+	network := c.Node.Network
+	if len(network.IPFamilies) > 2 {
+		return fmt.Errorf("node.network.ipFamilies lists %d families; use IPv4, IPv6 or both", len(network.IPFamilies))
+	}
+	seen := map[string]bool{}
+	for _, family := range network.IPFamilies {
+		if family != IPFamilyIPv4 && family != IPFamilyIPv6 {
+			return fmt.Errorf("invalid node.network.ipFamilies entry: %s. Valid values are: %s, %s", family, IPFamilyIPv4, IPFamilyIPv6)
+		}
+		if seen[family] {
+			return fmt.Errorf("node.network.ipFamilies lists %s twice", family)
+		}
+		seen[family] = true
+	}
+	if len(network.NodeIPs) == 0 {
+		return nil
+	}
+	families := c.GetIPFamilies()
+	if len(network.NodeIPs) != len(families) {
+		return fmt.Errorf("node.network.nodeIPs must list one address per IP family (%s)", strings.Join(families, ", "))
+	}
+	for i, value := range network.NodeIPs {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("invalid node.network.nodeIPs entry: %s. Expected an IP address", value)
+		}
+		if family := ipFamilyOf(ip); family != families[i] {
+			return fmt.Errorf("node.network.nodeIPs[%d] %s is an %s address, but the %s family is listed there in node.network.ipFamilies",
+				i, value, family, families[i])
+		}
+	}
+	return nil
+}
+
+// dnsNamePattern matches a fully qualified DNS name without the trailing dot, e.g. corp.example.com
+var dnsNamePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// validateNodeDNS checks the upstream DNS servers, search domains and names to verify. The C library only reads
+// the first three name servers of resolv.conf, so more upstreams would be ignored on hosts without systemd-resolved.
+func (c *Config) validateNodeDNS() error {
+	dns := c.Node.DNS
+	if len(dns.Upstreams) > 3 {
+		return fmt.Errorf("node.dns.upstreams lists %d servers; at most 3 are used", len(dns.Upstreams))
+	}
+	seen := map[string]bool{}
+	for _, upstream := range dns.Upstreams {
+		ip := net.ParseIP(upstream)
+		if ip == nil {
+			return fmt.Errorf("invalid node.dns.upstreams entry: %s. Expected an IP address", upstream)
+		}
+		if seen[ip.String()] {
+			return fmt.Errorf("node.dns.upstreams lists %s twice", upstream)
+		}
+		seen[ip.String()] = true
+	}
+	for _, domain := range dns.SearchDomains {
+		if len(domain) > 253 || !dnsNamePattern.MatchString(domain) {
+			return fmt.Errorf("invalid node.dns.searchDomains entry: %q. Expected a domain name such as corp.example.com", domain)
+		}
+	}
+	for _, name := range dns.VerifyNames {
+		if len(name) > 253 || !dnsNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid node.dns.verifyNames entry: %q. Expected a host name such as registry.corp.example.com", name)
+		}
+	}
+	return nil
+}
+
+// validateCNIMTU checks that a configured pod MTU is one Linux interfaces accept, and that IPv6 pods get
+// at least the IPv6 minimum MTU
+func (c *Config) validateCNIMTU() error {
+	mtu := c.CNI.MTU
+	if mtu == 0 {
+		return nil
+	}
+	if mtu < 576 || mtu > 9216 {
+		return fmt.Errorf("cni.mtu must be between 576 and 9216, got %d", mtu)
+	}
+	if c.HasIPFamily(IPFamilyIPv6) && mtu < 1280 {
+		return fmt.Errorf("cni.mtu must be at least 1280 on IPv6 nodes, got %d", mtu)
+	}
+	return nil
+}
+
+// ipFamilyOf returns the IP family of ip
+func ipFamilyOf(ip net.IP) string {
+	if ip.To4() != nil {
+		return IPFamilyIPv4
+	}
+	return IPFamilyIPv6
+}
+
+var (
+	// nodePoolNamePattern follows AKS agent pool naming: lowercase alphanumeric, starting with a letter
+	nodePoolNamePattern = regexp.MustCompile(`^[a-z][a-z0-9]{0,11}$`)
+	// taintPattern matches key[=value]:Effect as accepted by the kubelet's --register-with-taints
+	taintPattern = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_./]*)(=[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?:(NoSchedule|PreferNoSchedule|NoExecute)$`)
+)
+
+// validateNodePool validates the external node pool settings
+func (c *Config) validateNodePool() error {
+	pool := c.Node.Pool
+	if pool.Name == "" {
+		if pool.Mode != "" || len(pool.Taints) > 0 {
+			return fmt.Errorf("node.pool.name is required when node.pool.mode or node.pool.taints is set")
+		}
+		return nil
+	}
+	if !nodePoolNamePattern.MatchString(pool.Name) {
+		return fmt.Errorf("invalid node.pool.name: %s. Expected 1-12 lowercase letters and digits, starting with a letter", pool.Name)
+	}
+	if pool.Mode != "" && pool.Mode != "user" && pool.Mode != "system" {
+		return fmt.Errorf("invalid node.pool.mode: %s. Valid values are: user, system", pool.Mode)
+	}
+	for _, taint := range pool.Taints {
+		if !taintPattern.MatchString(taint) {
+			return fmt.Errorf("invalid node.pool.taints entry: %q. Expected key=value:Effect with effect NoSchedule, PreferNoSchedule or NoExecute", taint)
+		}
+	}
+	for _, label := range []string{AgentPoolLabel, AKSAgentPoolLabel, AKSNodePoolModeLabel} {
+		if value, ok := c.Node.Labels[label]; ok {
+			return fmt.Errorf("node.labels sets %s=%s, which node.pool manages; remove it from node.labels", label, value)
+		}
+	}
+	return nil
+}
+
+// sysctlKeyPattern matches sysctl keys such as "net.core.somaxconn" or "net.ipv4.conf.all.rp_filter"
+var sysctlKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[A-Za-z0-9_-]+)+$`)
+
+// validateTuning validates custom kernel tuning profiles; built-in profile names are resolved by the system configuration step
+func (c *Config) validateTuning() error {
+	for name, profile := range c.Node.Tuning.Profiles {
+		if name == "" {
+			return fmt.Errorf("node.tuning.profiles contains a profile with an empty name")
+		}
+		if profile.Extends == name {
+			return fmt.Errorf("node.tuning.profiles.%s cannot extend itself", name)
+		}
+		for key, value := range profile.Sysctls {
+			if !sysctlKeyPattern.MatchString(key) {
+				return fmt.Errorf("invalid sysctl key in node.tuning.profiles.%s: %q", name, key)
+			}
+			if strings.TrimSpace(value) == "" || strings.ContainsAny(value, "\n\r") {
+				return fmt.Errorf("invalid value for sysctl %s in node.tuning.profiles.%s: %q", key, name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// maintenanceWindowPattern matches daily local time windows such as "02:00-04:00" or "22:00-01:00"
+var maintenanceWindowPattern = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d-([01]\d|2[0-3]):[0-5]\d$`)
+
+// validateReboot validates the reboot policy and its maintenance window
+func (c *Config) validateReboot() error {
+	reboot := c.Agent.Reboot
+	switch reboot.Policy {
+	case "", "manual", "immediate":
+	case "maintenance-window":
+		if reboot.MaintenanceWindow == "" {
+			return fmt.Errorf("agent.reboot.maintenanceWindow is required when agent.reboot.policy is maintenance-window")
+		}
+	default:
+		return fmt.Errorf("invalid agent.reboot.policy: %s. Valid values are: manual, immediate, maintenance-window", reboot.Policy)
+	}
+
+	if reboot.MaintenanceWindow != "" {
+		if !maintenanceWindowPattern.MatchString(reboot.MaintenanceWindow) {
+			return fmt.Errorf("invalid agent.reboot.maintenanceWindow: %s. Expected a daily window such as 02:00-04:00", reboot.MaintenanceWindow)
+		}
+		if start, end, _ := strings.Cut(reboot.MaintenanceWindow, "-"); start == end {
+			return fmt.Errorf("invalid agent.reboot.maintenanceWindow: %s. Start and end must differ", reboot.MaintenanceWindow)
+		}
+	}
+	return nil
+}
+
+// validateDrift validates the drift detection interval
+func (c *Config) validateDrift() error {
+	interval := c.Agent.Drift.Interval
+	if interval == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(interval); err != nil || d < time.Minute {
+		return fmt.Errorf("invalid agent.drift.interval: %q. Expected a duration of at least 1m such as 10m", interval)
+	}
+	return nil
+}
+
+// validatePatching validates the OS patch detection interval and reboot policy
+func (c *Config) validatePatching() error {
+	patching := c.Agent.Patching
+	if patching.Interval != "" {
+		if d, err := time.ParseDuration(patching.Interval); err != nil || d < time.Minute {
+			return fmt.Errorf("invalid agent.patching.interval: %q. Expected a duration of at least 1m such as 15m", patching.Interval)
+		}
+	}
+	switch patching.RebootPolicy {
+	case "", "report":
+	case "maintenance-window":
+		if c.Agent.Reboot.MaintenanceWindow == "" {
+			return fmt.Errorf("agent.reboot.maintenanceWindow is required when agent.patching.rebootPolicy is maintenance-window")
+		}
+		if !filepath.IsAbs(patching.DrainKubeconfig) {
+			return fmt.Errorf("invalid agent.patching.drainKubeconfig: %q. An absolute path is required to drain the node before a patch reboot", patching.DrainKubeconfig)
+		}
+	default:
+		return fmt.Errorf("invalid agent.patching.rebootPolicy: %s. Valid values are: report, maintenance-window", patching.RebootPolicy)
+	}
+	return nil
+}
+
+// validateRoleAssignmentCheck validates the role assignment check interval. Listing role assignments counts
+// against the ARM read quota of the subscription, so the interval is at least 5m.
+func (c *Config) validateRoleAssignmentCheck() error {
+	interval := c.Agent.RoleAssignments.Interval
+	if interval == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(interval); err != nil || d < 5*time.Minute {
+		return fmt.Errorf("invalid agent.roleAssignments.interval: %q. Expected a duration of at least 5m such as 30m", interval)
+	}
+	return nil
+}
+
+// validateHeartbeat validates the heartbeat interval
+func (c *Config) validateHeartbeat() error {
+	interval := c.Agent.Heartbeat.Interval
+	if interval == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(interval); err != nil || d < 10*time.Second {
+		return fmt.Errorf("invalid agent.heartbeat.interval: %q. Expected a duration of at least 10s such as 1m", interval)
+	}
+	return nil
+}
+
+// validateTelemetry validates the telemetry endpoint when telemetry is enabled
+func (c *Config) validateTelemetry() error {
+	telemetry := c.Agent.Telemetry
+	if !telemetry.Enabled {
+		return nil
+	}
+	u, err := url.Parse(telemetry.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid agent.telemetry.endpoint: %q. Expected an https URL", telemetry.Endpoint)
+	}
+	if telemetry.BearerTokenFile != "" && !filepath.IsAbs(telemetry.BearerTokenFile) {
+		return fmt.Errorf("invalid agent.telemetry.bearerTokenFile: %s. Expected an absolute path", telemetry.BearerTokenFile)
+	}
+	return nil
+}
+
+// componentNamePattern matches the names external components register under
+var componentNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// validateComponents validates the plugins and external components to install
+func (c *Config) validateComponents() error {
+	for _, path := range c.Components.Plugins {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("invalid components.plugins entry: %s. Expected an absolute path", path)
+		}
+	}
+	seen := map[string]bool{}
+	for _, component := range c.Components.External {
+		if !componentNamePattern.MatchString(component.Name) {
+			return fmt.Errorf("invalid components.external name: %q. Expected lowercase letters, digits and dashes", component.Name)
+		}
+		if seen[component.Name] {
+			return fmt.Errorf("invalid components.external: %s is listed more than once", component.Name)
+		}
+		seen[component.Name] = true
+		if component.Exec != "" && !filepath.IsAbs(component.Exec) {
+			return fmt.Errorf("invalid components.external exec of %s: %s. Expected an absolute path", component.Name, component.Exec)
+		}
+		if component.Timeout != "" {
+			if component.Exec == "" {
+				return fmt.Errorf("invalid components.external timeout of %s: only executables have a timeout", component.Name)
+			}
+			if timeout, err := time.ParseDuration(component.Timeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("invalid components.external timeout of %s: %s. Expected a positive duration such as 10m", component.Name, component.Timeout)
+			}
+		}
+	}
+	return nil
+}
+
+// componentDependencies returns the built-in components the built-in component name needs on the node
+func (c *Config) componentDependencies(name string) []string {
+	switch name {
+	case ComponentContainerd, ComponentCRIO:
+		return []string{ComponentRunc}
+	case ComponentImagePrePull:
+		return []string{c.GetContainerRuntime()}
+	case ComponentKubelet:
+		return []string{c.GetContainerRuntime(), ComponentKubeBinaries, ComponentCNI}
+	case ComponentNodeTopology, ComponentStaticPods, ComponentNPD:
+		return []string{ComponentKubelet}
+	}
+	return nil
+}
+
+// validateComponentToggles validates that no built-in component left on needs one that components.enabled
+// turns off, so a broken combination fails before bootstrap changes anything
+func (c *Config) validateComponentToggles() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Components.Enabled)) {
+		if !slices.Contains(BuiltinComponents, name) {
+			return fmt.Errorf("invalid components.enabled entry: %q. Expected one of: %s", name, strings.Join(BuiltinComponents, ", "))
+		}
+	}
+
+	runtime := c.GetContainerRuntime()
+	for _, name := range BuiltinComponents {
+		if !c.IsComponentEnabled(name) {
+			continue
+		}
+		if (name == ComponentContainerd || name == ComponentCRIO) && name != runtime {
+			continue // Not installed, whatever its toggle says
+		}
+		for _, dependency := range c.componentDependencies(name) {
+			if !c.IsComponentEnabled(dependency) {
+				return fmt.Errorf("invalid components.enabled: %s needs %s, which is turned off. Turn %s on or also turn off %s", name, dependency, dependency, name)
+			}
+		}
+	}
+	if c.Agent.Canary.Enabled && !c.IsComponentEnabled(ComponentKubelet) {
+		return fmt.Errorf("invalid components.enabled: agent.canary needs kubelet, which is turned off")
+	}
+	return nil
+}
+
+// validateStaticPods validates the names and sources of the static pod manifests; their contents are checked
+// when they are rendered
+func (c *Config) validateStaticPods() error {
+	seen := map[string]bool{}
+	for _, pod := range c.StaticPods {
+		if !componentNamePattern.MatchString(pod.Name) {
+			return fmt.Errorf("invalid staticPods name: %q. Expected lowercase letters, digits and dashes", pod.Name)
+		}
+		if seen[pod.Name] {
+			return fmt.Errorf("invalid staticPods: %s is listed more than once", pod.Name)
+		}
+		seen[pod.Name] = true
+		if (pod.Manifest == "") == (pod.ManifestFile == "") {
+			return fmt.Errorf("invalid staticPods entry %s: exactly one of manifest and manifestFile is required", pod.Name)
+		}
+		if pod.ManifestFile != "" && !filepath.IsAbs(pod.ManifestFile) {
+			return fmt.Errorf("invalid staticPods manifestFile of %s: %s. Expected an absolute path", pod.Name, pod.ManifestFile)
+		}
+	}
+	return nil
+}
+
+// validCustomScriptRuns are when custom scripts run; empty means once
+var validCustomScriptRuns = map[string]bool{"": true, "once": true, "always": true}
+
+// validateCustomScripts validates the custom scripts; script files and checksums are checked before they run
+func (c *Config) validateCustomScripts() error {
+	seen := map[string]bool{}
+	for _, script := range c.CustomScripts {
+		if !componentNamePattern.MatchString(script.Name) {
+			return fmt.Errorf("invalid customScripts name: %q. Expected lowercase letters, digits and dashes", script.Name)
+		}
+		if seen[script.Name] {
+			return fmt.Errorf("invalid customScripts: %s is listed more than once", script.Name)
+		}
+		seen[script.Name] = true
+		if (script.Script == "") == (script.ScriptFile == "") {
+			return fmt.Errorf("invalid customScripts entry %s: exactly one of script and scriptFile is required", script.Name)
+		}
+		if script.ScriptFile != "" && !filepath.IsAbs(script.ScriptFile) {
+			return fmt.Errorf("invalid customScripts scriptFile of %s: %s. Expected an absolute path", script.Name, script.ScriptFile)
+		}
+		if script.SHA256 != "" && !sha256Pattern.MatchString(script.SHA256) {
+			return fmt.Errorf("invalid customScripts sha256 of %s: expected 64 hex characters", script.Name)
+		}
+		if script.Interpreter != "" && !filepath.IsAbs(script.Interpreter) {
+			return fmt.Errorf("invalid customScripts interpreter of %s: %s. Expected an absolute path", script.Name, script.Interpreter)
+		}
+		if script.Timeout != "" {
+			if d, err := time.ParseDuration(script.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("invalid customScripts timeout of %s: %q. Expected a duration such as 10m", script.Name, script.Timeout)
+			}
+		}
+		for _, code := range script.ExitCodes {
+			if code < 0 || code > 255 {
+				return fmt.Errorf("invalid customScripts exitCodes of %s: %d. Expected 0 to 255", script.Name, code)
+			}
+		}
+		if !validCustomScriptRuns[script.Run] {
+			return fmt.Errorf("invalid customScripts run of %s: %s. Valid values are: once, always", script.Name, script.Run)
+		}
+		for _, path := range script.ReadWritePaths {
+			if !filepath.IsAbs(path) {
+				return fmt.Errorf("invalid customScripts readWritePaths of %s: %s. Expected an absolute path", script.Name, path)
+			}
+		}
+	}
+	return nil
+}
+
+// validateState validates the backend the agent's state is copied to
+func (c *Config) validateState() error {
+	state := c.Agent.State
+	switch state.Backend {
+	case "":
+		return nil
+	case StateBackendFile:
+		if !filepath.IsAbs(state.Path) {
+			return fmt.Errorf("invalid agent.state.path: %q. Expected an absolute path", state.Path)
+		}
+	case StateBackendAzureBlob:
+		if err := blob.ValidateContainerURL(state.AzureBlob.ContainerURL); err != nil {
+			return fmt.Errorf("invalid agent.state.azureBlob.containerUrl: %w", err)
+		}
+		if strings.Contains(state.AzureBlob.Prefix, "..") {
+			return fmt.Errorf("invalid agent.state.azureBlob.prefix: %q. Must not contain '..'", state.AzureBlob.Prefix)
+		}
+	default:
+		return fmt.Errorf("invalid agent.state.backend: %q. Expected %s or %s", state.Backend, StateBackendFile, StateBackendAzureBlob)
+	}
+	return nil
+}
+
+// validateSpecSource validates the Git or OCI source of the agent's NodeSpec and its signature verification
+func (c *Config) validateSpecSource() error {
+	source := c.Agent.Source
+	if source.Git == nil && source.OCI == nil {
+		return nil
+	}
+	if source.Git != nil && source.OCI != nil {
+		return fmt.Errorf("agent.source requires exactly one of git or oci")
+	}
+	if source.Interval != "" {
+		if d, err := time.ParseDuration(source.Interval); err != nil || d < time.Minute {
+			return fmt.Errorf("invalid agent.source.interval: %q. Expected a duration of at least 1m such as 5m", source.Interval)
+		}
+	}
+
+	if git := source.Git; git != nil {
+		// Either a URL with a scheme or the scp-like syntax of ssh remotes, such as git@github.com:contoso/edge-nodes.git
+		if strings.HasPrefix(git.URL, "-") || (!strings.Contains(git.URL, "://") && !strings.Contains(git.URL, "@")) {
+			return fmt.Errorf("invalid agent.source.git.url: %q. Expected a repository URL such as https://github.com/contoso/edge-nodes.git", git.URL)
+		}
+		if strings.HasPrefix(git.Ref, "-") {
+			return fmt.Errorf("invalid agent.source.git.ref: %q. Expected a branch or tag", git.Ref)
+		}
+		if err := validateSourcePath("agent.source.git.path", git.Path); err != nil {
+			return err
+		}
+		if git.AllowedSignersFile != "" && git.GPGPublicKeyFile != "" {
+			return fmt.Errorf("agent.source.git accepts only one of allowedSignersFile or gpgPublicKeyFile")
+		}
+		if git.AllowedSignersFile == "" && git.GPGPublicKeyFile == "" && !source.AllowUnsigned {
+			return fmt.Errorf("agent.source.git requires allowedSignersFile or gpgPublicKeyFile to verify commit signatures")
+		}
+	}
+
+	if oci := source.OCI; oci != nil {
+		if oci.Reference == "" || strings.HasPrefix(oci.Reference, "-") || strings.Contains(oci.Reference, "://") {
+			return fmt.Errorf("invalid agent.source.oci.reference: %q. Expected a reference such as contoso.azurecr.io/nodespecs/edge:prod", oci.Reference)
+		}
+		if err := validateSourcePath("agent.source.oci.path", oci.Path); err != nil {
+			return err
+		}
+		if oci.CosignPublicKeyFile == "" && !source.AllowUnsigned {
+			return fmt.Errorf("agent.source.oci requires cosignPublicKeyFile to verify the artifact signature")
+		}
+	}
+	return nil
+}
+
+// webhookActions are the provisioning actions the webhook listener can be allowed to run
+var webhookActions = map[string]bool{"bootstrap": true, "install": true, "upgrade": true, "drain": true, "uncordon": true}
+
+// validateWebhook validates the webhook listener, its credentials and the allowlisted actions
+func (c *Config) validateWebhook() error {
+	webhook := c.Agent.Webhook
+	if webhook.ListenAddress == "" {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(webhook.ListenAddress); err != nil || port == "" {
+		return fmt.Errorf("invalid agent.webhook.listenAddress: %s. Expected host:port or :port", webhook.ListenAddress)
+	}
+	if webhook.Secret != "" {
+		if webhook.SecretFile != "" {
+			return fmt.Errorf("agent.webhook.secretFile and agent.webhook.secret cannot both be set")
+		}
+		if err := validateSecretReference("agent.webhook.secret", webhook.Secret); err != nil {
+			return err
+		}
+	} else if !filepath.IsAbs(webhook.SecretFile) {
+		return fmt.Errorf("invalid agent.webhook.secretFile: %q. Expected an absolute path to the shared secret", webhook.SecretFile)
+	}
+	if (webhook.TLSCertFile == "") != (webhook.TLSKeyFile == "") {
+		return fmt.Errorf("agent.webhook requires both tlsCertFile and tlsKeyFile to serve HTTPS")
+	}
+	if len(webhook.AllowedActions) == 0 {
+		return fmt.Errorf("agent.webhook.allowedActions is required. Expected one or more of bootstrap, install, upgrade, drain, uncordon")
+	}
+	for i, action := range webhook.AllowedActions {
+		if !webhookActions[action] {
+			return fmt.Errorf("invalid agent.webhook.allowedActions[%d]: %q. Expected one of bootstrap, install, upgrade, drain, uncordon", i, action)
+		}
+		if (action == "drain" || action == "uncordon") && webhook.DrainKubeconfig == "" {
+			return fmt.Errorf("agent.webhook.allowedActions[%d] %q requires agent.webhook.drainKubeconfig", i, action)
+		}
+	}
+	if webhook.DrainKubeconfig != "" && !filepath.IsAbs(webhook.DrainKubeconfig) {
+		return fmt.Errorf("invalid agent.webhook.drainKubeconfig: %q. Expected an absolute path", webhook.DrainKubeconfig)
+	}
+	return nil
+}
+
+// validateSourcePath validates the relative path of the NodeSpec file in a Git repository or OCI artifact
+func validateSourcePath(field, path string) error {
+	if path == "" {
+		return nil
+	}
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(path) || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(path, "-") {
+		return fmt.Errorf("invalid %s: %q. Expected a relative path such as nodes/edge.yaml", field, path)
+	}
+	return nil
+}
+
+var (
+	// npdPluginNamePattern keeps plugin names safe to use as file names
+	npdPluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+	// conditionTypePattern matches Kubernetes condition types and reasons such as "RAIDProblem"
+	conditionTypePattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	// sha256Pattern matches a hex encoded SHA-256 digest
+	sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// validateNPDPlugins validates custom Node Problem Detector plugins and the GPU health checks
+func (c *Config) validateNPDPlugins() error {
+	// Custom plugins must not reuse the names of the built-in GPU health plugins
+	seen := map[string]bool{}
+	if c.Npd.GPUHealth.Enabled {
+		seen["gpu-xid"], seen["gpu-ecc"], seen["gpu-thermal"] = true, true, true
+	}
+	if !c.Agent.Drift.Disabled {
+		seen["config-drift"] = true
+	}
+	for idx, plugin := range c.Npd.CustomPlugins {
+		field := fmt.Sprintf("npd.customPlugins[%d]", idx)
+		if !npdPluginNamePattern.MatchString(plugin.Name) {
+			return fmt.Errorf("invalid %s.name: %q. Use lower case letters, digits and dashes", field, plugin.Name)
+		}
+		if seen[plugin.Name] {
+			return fmt.Errorf("duplicate %s.name: %s is already in use", field, plugin.Name)
+		}
+		seen[plugin.Name] = true
+
+		if (plugin.Script == "") == (plugin.ScriptFile == "") {
+			return fmt.Errorf("%s requires exactly one of script or scriptFile", field)
+		}
+		if plugin.SHA256 != "" && !sha256Pattern.MatchString(plugin.SHA256) {
+			return fmt.Errorf("invalid %s.sha256: expected 64 hex characters", field)
+		}
+		if !plugin.Temporary && !conditionTypePattern.MatchString(plugin.Condition) {
+			return fmt.Errorf("invalid %s.condition: %q. Expected a condition type such as RAIDProblem", field, plugin.Condition)
+		}
+		if !conditionTypePattern.MatchString(plugin.Reason) {
+			return fmt.Errorf("invalid %s.reason: %q. Expected a reason such as RAIDDegraded", field, plugin.Reason)
+		}
+		for name, value := range map[string]string{"interval": plugin.Interval, "timeout": plugin.Timeout} {
+			if value == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				return fmt.Errorf("invalid %s.%s: %q. Expected a duration such as 30s", field, name, value)
+			}
+		}
+	}
+
+	gpu := c.Npd.GPUHealth
+	if gpu.Interval != "" {
+		if d, err := time.ParseDuration(gpu.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid npd.gpuHealth.interval: %q. Expected a duration such as 30s", gpu.Interval)
+		}
+	}
+	if gpu.MaxTemperature < 0 || gpu.MaxTemperature > 150 {
+		return fmt.Errorf("invalid npd.gpuHealth.maxTemperature: %d. Expected degrees Celsius between 0 and 150", gpu.MaxTemperature)
+	}
+	return nil
+}
+
+// validNPDMetricsSinks are the supported destinations for NPD problem metrics
+var validNPDMetricsSinks = []string{"azure-monitor", "prometheus-remote-write"}
+
+// validateNPDMetrics validates the NPD problem metrics export settings
+func (c *Config) validateNPDMetrics() error {
+	metrics := c.Npd.Metrics
+	if metrics.Sink == "" {
+		return nil
+	}
+	if !slices.Contains(validNPDMetricsSinks, metrics.Sink) {
+		return fmt.Errorf("invalid npd.metrics.sink: %s. Valid values are: %s", metrics.Sink, strings.Join(validNPDMetricsSinks, ", "))
+	}
+	if metrics.Interval != "" {
+		if d, err := time.ParseDuration(metrics.Interval); err != nil || d < 10*time.Second {
+			return fmt.Errorf("invalid npd.metrics.interval: %q. Expected a duration of at least 10s", metrics.Interval)
+		}
+	}
+
+	switch metrics.Sink {
+	case "azure-monitor":
+		// Without Arc there is no machine resource to attach the metrics to
+		if !c.IsARCEnabled() && (metrics.ResourceID == "" || metrics.Region == "") {
+			return fmt.Errorf("npd.metrics.resourceId and npd.metrics.region are required for the azure-monitor sink when Arc is disabled")
+		}
+		if metrics.ResourceID != "" && !strings.HasPrefix(strings.ToLower(metrics.ResourceID), "/subscriptions/") {
+			return fmt.Errorf("invalid npd.metrics.resourceId: %s. Expected an Azure resource ID", metrics.ResourceID)
+		}
+	case "prometheus-remote-write":
+		u, err := url.Parse(metrics.RemoteWriteURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid npd.metrics.remoteWriteUrl: %q. Expected an http(s) URL", metrics.RemoteWriteURL)
+		}
+	}
+	return nil
+}
+
+var (
+	validSSHProfiles = []string{"baseline", "strict"}
+
+	// linuxUserPattern matches the user names useradd accepts by default
+	linuxUserPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+)
+
+// validateSSH validates the optional SSH hardening and break-glass access settings
+func (c *Config) validateSSH() error {
+	ssh := c.SSH
+	if !ssh.Enabled {
+		return nil
+	}
+	if ssh.Profile != "" && !slices.Contains(validSSHProfiles, ssh.Profile) {
+		return fmt.Errorf("invalid ssh.profile: %s. Valid values are: %s", ssh.Profile, strings.Join(validSSHProfiles, ", "))
+	}
+	for _, user := range ssh.AllowUsers {
+		if !linuxUserPattern.MatchString(user) {
+			return fmt.Errorf("invalid ssh.allowUsers entry: %q. Expected a local user name", user)
+		}
+	}
+	if strings.ContainsRune(ssh.Banner, 0) {
+		return fmt.Errorf("invalid ssh.banner: must be text")
+	}
+
+	breakGlass := ssh.BreakGlass
+	if breakGlass == nil {
+		return nil
+	}
+	if !c.IsARCEnabled() {
+		return fmt.Errorf("ssh.breakGlass requires azure.arc.enabled; break-glass access goes through the Arc agent")
+	}
+	if user := c.GetSSHBreakGlassUser(); !linuxUserPattern.MatchString(user) || user == "root" {
+		return fmt.Errorf("invalid ssh.breakGlass.user: %q. Expected a local user name other than root", user)
+	}
+	if !filepath.IsAbs(breakGlass.AuthorizedKeysFile) {
+		return fmt.Errorf("invalid ssh.breakGlass.authorizedKeysFile: %q. Expected an absolute path", breakGlass.AuthorizedKeysFile)
+	}
+	return nil
+}
+
+var (
+	validFluentBitSources      = []string{"kubelet", "containerd", "syslog"}
+	validFluentBitDestinations = []string{"log-analytics", "syslog"}
+	validFluentBitSyslogModes  = []string{"tcp", "udp", "tls"}
+
+	// fluentBitVersionPattern matches fluent-bit release versions such as 3.2.2
+	fluentBitVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+	// logTypePattern matches Log Analytics custom log names
+	logTypePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,99}$`)
+)
+
+var (
+	validContainerRuntimes = []string{"containerd", "cri-o"}
+
+	// crioVersionPattern matches CRI-O release versions such as 1.32.0
+	crioVersionPattern = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.[0-9]+$`)
+)
+
+// validateContainerRuntime validates the container runtime selection and the CRI-O settings
+func (c *Config) validateContainerRuntime() error {
+	if !slices.Contains(validContainerRuntimes, c.GetContainerRuntime()) {
+		return fmt.Errorf("invalid containerRuntime: %s. Valid values are: %s", c.ContainerRuntime, strings.Join(validContainerRuntimes, ", "))
+	}
+	if c.GetContainerRuntime() != "cri-o" || c.CRIO.Version == "" {
+		return nil
+	}
+	match := crioVersionPattern.FindStringSubmatch(c.CRIO.Version)
+	if match == nil {
+		return fmt.Errorf("invalid crio.version: %s. Expected a release version such as 1.32.0", c.CRIO.Version)
+	}
+	// CRI-O only supports the Kubernetes minor version it was released for
+	kube := strings.Split(strings.TrimPrefix(c.Kubernetes.Version, "v"), ".")
+	if len(kube) >= 2 && (kube[0] != match[1] || kube[1] != match[2]) {
+		return fmt.Errorf("crio.version %s does not match kubernetes.version %s. CRI-O %s.%s only supports Kubernetes %s.%s",
+			c.CRIO.Version, c.Kubernetes.Version, match[1], match[2], match[1], match[2])
+	}
+	return nil
+}
+
+// validateImagePrePull validates the images pulled after the container runtime is installed
+func (c *Config) validateImagePrePull() error {
+	seen := make(map[string]bool)
+	for _, image := range c.ImagePrePull.Images {
+		if image == "" || strings.ContainsAny(image, " \t\r\n") {
+			return fmt.Errorf("invalid imagePrePull.images entry: %q", image)
+		}
+		if seen[image] {
+			return fmt.Errorf("duplicate imagePrePull.images entry: %s", image)
+		}
+		seen[image] = true
+	}
+	if c.ImagePrePull.Timeout != "" {
+		if timeout, err := time.ParseDuration(c.ImagePrePull.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid imagePrePull.timeout: %s. Expected a positive duration such as 5m", c.ImagePrePull.Timeout)
+		}
+	}
+	if c.ImagePrePull.Parallelism < 0 || c.ImagePrePull.Parallelism > 10 {
+		return fmt.Errorf("invalid imagePrePull.parallelism: %d. Expected 1 to 10", c.ImagePrePull.Parallelism)
+	}
+	return nil
+}
+
+// validateDownloads validates the artifact cache directories, LAN peers and pinned checksums
+func (c *Config) validateDownloads() error {
+	cache := c.Downloads.Cache
+	if cache.Directory != "" && !filepath.IsAbs(cache.Directory) {
+		return fmt.Errorf("invalid downloads.cache.directory: %s. Expected an absolute path", cache.Directory)
+	}
+	for _, dir := range cache.SeedDirectories {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("invalid downloads.cache.seedDirectories entry: %s. Expected an absolute path", dir)
+		}
+	}
+	peers := c.Downloads.Peers
+	if peers.ServeAddress != "" {
+		if cache.Disabled {
+			return fmt.Errorf("downloads.peers.serveAddress requires the download cache; remove downloads.cache.disabled")
+		}
+		if _, port, err := net.SplitHostPort(peers.ServeAddress); err != nil || port == "" {
+			return fmt.Errorf("invalid downloads.peers.serveAddress: %s. Expected host:port or :port", peers.ServeAddress)
+		}
+	}
+	for _, peer := range peers.URLs {
+		if u, err := url.Parse(peer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid downloads.peers.urls entry: %s. Expected a URL such as http://10.0.0.5:8470", peer)
+		}
+	}
+
+	for _, limit := range []struct{ field, value string }{
+		{"global", c.Downloads.RateLimit.Global},
+		{"perArtifact", c.Downloads.RateLimit.PerArtifact},
+	} {
+		if limit.value == "" {
+			continue
+		}
+		if q, err := resource.ParseQuantity(limit.value); err != nil || q.Value() < 1024 {
+			return fmt.Errorf("invalid downloads.rateLimit.%s: %s. Expected bytes per second of at least 1Ki, such as 5Mi", limit.field, limit.value)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, checksum := range c.Downloads.Checksums {
+		if !strings.HasPrefix(checksum.URL, "https://") && !strings.HasPrefix(checksum.URL, "http://") {
+			return fmt.Errorf("invalid downloads.checksums url: %q. Expected a download URL", checksum.URL)
+		}
+		if seen[checksum.URL] {
+			return fmt.Errorf("duplicate downloads.checksums url: %s", checksum.URL)
+		}
+		seen[checksum.URL] = true
+		if !sha256Pattern.MatchString(checksum.SHA256) {
+			return fmt.Errorf("invalid downloads.checksums sha256 for %s: %s. Expected a hex encoded SHA-256 digest", checksum.URL, checksum.SHA256)
+		}
+	}
+	return c.validateArtifacts()
+}
+
+// Components, operating systems and architectures of the release artifacts in downloads.artifacts
+var (
+	validArtifactComponents = []string{"kubernetes", "containerd", "cri-o", "runc", "cni-plugins", "node-problem-detector"}
+	validArtifactOSes       = []string{"linux", "windows"}
+	validArtifactArchs      = []string{"amd64", "arm64", "arm"}
+)
+
+// validateArtifacts validates the version manifest: one artifact per component version, OS and architecture,
+// each with a download URL and a digest that agrees with downloads.checksums
+func (c *Config) validateArtifacts() error {
+	versions := make(map[string]bool)
+	for _, artifacts := range c.Downloads.Artifacts {
+		if !slices.Contains(validArtifactComponents, artifacts.Component) {
+			return fmt.Errorf("invalid downloads.artifacts component: %q. Valid values are: %s", artifacts.Component, strings.Join(validArtifactComponents, ", "))
+		}
+		version := strings.TrimPrefix(artifacts.Version, "v")
+		if version == "" {
+			return fmt.Errorf("invalid downloads.artifacts entry for %s: version is required", artifacts.Component)
+		}
+		id := artifacts.Component + " " + version
+		if versions[id] {
+			return fmt.Errorf("duplicate downloads.artifacts entry for %s %s", artifacts.Component, artifacts.Version)
+		}
+		versions[id] = true
+		if len(artifacts.Platforms) == 0 {
+			return fmt.Errorf("invalid downloads.artifacts entry for %s %s: no platforms", artifacts.Component, artifacts.Version)
+		}
+
+		platforms := make(map[string]bool)
+		for _, artifact := range artifacts.Platforms {
+			if !slices.Contains(validArtifactOSes, artifact.OS) || !slices.Contains(validArtifactArchs, artifact.Arch) {
+				return fmt.Errorf("invalid downloads.artifacts platform of %s %s: %s/%s. Expected an OS of %s and an arch of %s",
+					artifacts.Component, artifacts.Version, artifact.OS, artifact.Arch, strings.Join(validArtifactOSes, ", "), strings.Join(validArtifactArchs, ", "))
+			}
+			platform := artifact.OS + "/" + artifact.Arch
+			if platforms[platform] {
+				return fmt.Errorf("duplicate downloads.artifacts platform of %s %s: %s", artifacts.Component, artifacts.Version, platform)
+			}
+			platforms[platform] = true
+			if u, err := url.Parse(artifact.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid downloads.artifacts url of %s %s %s: %q. Expected a download URL", artifacts.Component, artifacts.Version, platform, artifact.URL)
+			}
+			if artifact.SHA256 == "" {
+				continue
+			}
+			if !sha256Pattern.MatchString(artifact.SHA256) {
+				return fmt.Errorf("invalid downloads.artifacts sha256 of %s %s %s: %s. Expected a hex encoded SHA-256 digest", artifacts.Component, artifacts.Version, platform, artifact.SHA256)
+			}
+			for _, checksum := range c.Downloads.Checksums {
+				if checksum.URL == artifact.URL && !strings.EqualFold(checksum.SHA256, artifact.SHA256) {
+					return fmt.Errorf("conflicting sha256 for %s in downloads.artifacts and downloads.checksums", artifact.URL)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateFluentBit validates the optional fluent-bit log shipper settings
+// sriovInterfacePattern matches Linux network interface names, which are at most 15 characters
+var sriovInterfacePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,15}$`)
+
+// sriovDriverPattern matches kernel module names
+var sriovDriverPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// sriovResourceNamePattern matches the resource names the SR-IOV network device plugin accepts
+var sriovResourceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// validateSRIOV validates the SR-IOV NIC layout
+func (c *Config) validateSRIOV() error {
+	sriov := c.SRIOV
+	if !sriov.Enabled {
+		return nil
+	}
+	if len(sriov.NICs) == 0 {
+		return fmt.Errorf("sriov.nics must list at least one NIC when sriov is enabled")
+	}
+	if sriov.Hugepages2Mi < 0 {
+		return fmt.Errorf("sriov.hugepages2Mi must not be negative")
+	}
+	interfaces := map[string]bool{}
+	resources := map[string]bool{}
+	for _, nic := range sriov.NICs {
+		if !sriovInterfacePattern.MatchString(nic.Interface) {
+			return fmt.Errorf("invalid sriov.nics interface: %q. Expected a network interface name", nic.Interface)
+		}
+		if interfaces[nic.Interface] {
+			return fmt.Errorf("duplicate sriov.nics interface: %s", nic.Interface)
+		}
+		interfaces[nic.Interface] = true
+		if nic.NumVFs < 1 {
+			return fmt.Errorf("invalid sriov.nics numVfs of %s: %d. Expected at least 1", nic.Interface, nic.NumVFs)
+		}
+		if nic.Driver != "" && !sriovDriverPattern.MatchString(nic.Driver) {
+			return fmt.Errorf("invalid sriov.nics driver of %s: %q. Expected a kernel module name such as vfio-pci", nic.Interface, nic.Driver)
+		}
+		resource := c.GetSRIOVResourceName(nic)
+		if !sriovResourceNamePattern.MatchString(resource) {
+			return fmt.Errorf("invalid sriov.nics resourceName of %s: %q. Use letters, digits and underscores", nic.Interface, resource)
+		}
+		if resources[resource] {
+			return fmt.Errorf("duplicate sriov.nics resourceName: %s", resource)
+		}
+		resources[resource] = true
+	}
+	return nil
+}
+
+func (c *Config) validateFluentBit() error {
+	fb := c.FluentBit
+	if !fb.Enabled {
+		return nil
+	}
+	if fb.Version != "" && !fluentBitVersionPattern.MatchString(fb.Version) {
+		return fmt.Errorf("invalid fluentBit.version: %s. Expected a release version such as 3.2.2", fb.Version)
+	}
+	for _, source := range fb.Sources {
+		if !slices.Contains(validFluentBitSources, source) {
+			return fmt.Errorf("invalid fluentBit.sources entry: %s. Valid values are: %s", source, strings.Join(validFluentBitSources, ", "))
+		}
+	}
+
+	switch fb.Destination {
+	case "log-analytics":
+		if _, err := uuid.Parse(fb.WorkspaceID); err != nil {
+			return fmt.Errorf("invalid fluentBit.workspaceId: %q. Expected the workspace GUID", fb.WorkspaceID)
+		}
+		if fb.SharedKeyFile == "" && fb.SharedKey == "" {
+			return fmt.Errorf("fluentBit.sharedKeyFile is required for the log-analytics destination, or a secret reference in fluentBit.sharedKey")
+		}
+		if fb.SharedKeyFile != "" && fb.SharedKey != "" {
+			return fmt.Errorf("fluentBit.sharedKeyFile and fluentBit.sharedKey cannot both be set")
+		}
+		if fb.SharedKey != "" {
+			if err := validateSecretReference("fluentBit.sharedKey", fb.SharedKey); err != nil {
+				return err
+			}
+		}
+		if fb.LogType != "" && !logTypePattern.MatchString(fb.LogType) {
+			return fmt.Errorf("invalid fluentBit.logType: %s. Use letters, digits and underscores", fb.LogType)
+		}
+	case "syslog":
+		if fb.SyslogHost == "" || strings.ContainsAny(fb.SyslogHost, " \t\r\n") {
+			return fmt.Errorf("invalid fluentBit.syslogHost: %q. A host name or address is required for the syslog destination", fb.SyslogHost)
+		}
+		if fb.SyslogPort < 0 || fb.SyslogPort > 65535 {
+			return fmt.Errorf("invalid fluentBit.syslogPort: %d", fb.SyslogPort)
+		}
+		if fb.SyslogMode != "" && !slices.Contains(validFluentBitSyslogModes, fb.SyslogMode) {
+			return fmt.Errorf("invalid fluentBit.syslogMode: %s. Valid values are: %s", fb.SyslogMode, strings.Join(validFluentBitSyslogModes, ", "))
+		}
+	default:
+		return fmt.Errorf("invalid fluentBit.destination: %q. Valid values are: %s", fb.Destination, strings.Join(validFluentBitDestinations, ", "))
+	}
+	return nil
 }
@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetDefaults(t *testing.T) {
@@ -51,8 +54,8 @@ func TestSetDefaults(t *testing.T) {
 			want: func(c *Config) bool {
 				return c.Node.MaxPods == 50 && // preserved
 					c.Node.Kubelet.Verbosity == 2 &&
-					c.Node.Kubelet.ImageGCHighThreshold == 85 &&
-					c.Node.Kubelet.ImageGCLowThreshold == 80 &&
+					c.Node.Kubelet.ImageGCHighThreshold == 0 && // picked from the disk size at install time
+					c.Node.Kubelet.ImageGCLowThreshold == 0 &&
 					c.Node.Kubelet.KubeReserved != nil &&
 					c.Node.Kubelet.EvictionHard != nil
 			},
@@ -103,6 +106,12 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:    "unsupported schema version fails",
+			config:  &Config{SchemaVersion: "2"},
+			wantErr: true,
+			errMsg:  "unsupported schemaVersion \"2\"",
+		},
 		{
 			name: "missing subscription ID fails",
 			config: &Config{
@@ -134,19 +143,20 @@ func TestValidate(t *testing.T) {
 			errMsg:  "azure.tenantId is required",
 		},
 		{
-			name: "missing target cluster location fails",
+			name: "missing target cluster location fails with bootstrap token",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
 					TenantID:       "12345678-1234-1234-1234-123456789012",
 					Cloud:          "AzurePublicCloud",
+					BootstrapToken: &BootstrapTokenConfig{Token: "abcdef.0123456789abcdef"},
 					TargetCluster: &TargetClusterConfig{
 						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
 					},
 				},
 			},
 			wantErr: true,
-			errMsg:  "azure.targetCluster.location is required",
+			errMsg:  "azure.targetCluster.location is required with azure.bootstrapToken",
 		},
 		{
 			name: "missing target cluster resource ID fails",
@@ -358,6 +368,368 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+func TestLoadNodeSpec(t *testing.T) {
+	const spec = `apiVersion: aksflexnode.azure.com/v1alpha1
+kind: NodeSpec
+metadata:
+  name: edge-01
+spec:
+  azure:
+    subscriptionId: 12345678-1234-1234-1234-123456789012
+    tenantId: 12345678-1234-1234-1234-123456789012
+    bootstrapToken:
+      token: abcdef.0123456789abcdef
+    targetCluster:
+      resourceId: /subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster
+      location: eastus
+  kubernetes:
+    version: 1.31.1
+  node:
+    kubelet:
+      serverURL: https://test-cluster-abc123.hcp.eastus.azmk8s.io:443
+      caCertData: LS0tLS1CRUdJTi1DRVJUSUZJQ0FURS0tLS0t
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nodespec.yaml")
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if cfg.GetNodeSpecName() != "edge-01" {
+		t.Errorf("GetNodeSpecName() = %q, want edge-01", cfg.GetNodeSpecName())
+	}
+	if cfg.Kubernetes.Version != "1.31.1" || cfg.Azure.TargetCluster.Name != "test-cluster" {
+		t.Errorf("LoadConfig() = kubernetes %q, cluster %q, want the values of the spec", cfg.Kubernetes.Version, cfg.Azure.TargetCluster.Name)
+	}
+	if cfg.Snapshot().GetNodeSpecName() != "edge-01" {
+		t.Error("Snapshot() lost the NodeSpec name")
+	}
+
+	for name, document := range map[string]string{
+		"wrong kind":        strings.Replace(spec, "kind: NodeSpec", "kind: Node", 1),
+		"wrong apiVersion":  strings.Replace(spec, "v1alpha1", "v9", 1),
+		"missing spec":      "apiVersion: aksflexnode.azure.com/v1alpha1\nkind: NodeSpec\n",
+		"invalid spec body": strings.Replace(spec, "version: 1.31.1", "version: [1, 31]", 1),
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".yml")
+		if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("%s: LoadConfig() expected error", name)
+		}
+	}
+}
+
+func TestLoadLayeredConfig(t *testing.T) {
+	const local = `{
+  "azure": {
+    "subscriptionId": "12345678-1234-1234-1234-123456789012",
+    "tenantId": "12345678-1234-1234-1234-123456789012",
+    "bootstrapToken": {"token": "abcdef.0123456789abcdef"},
+    "targetCluster": {
+      "resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+      "location": "eastus"
+    }
+  },
+  "kubernetes": {"version": "1.30.0"},
+  "node": {
+    "kubelet": {"serverURL": "https://test-cluster-abc123.hcp.eastus.azmk8s.io:443", "caCertData": "LS0tLS1CRUdJTi1DRVJUSUZJQ0FURS0tLS0t"}
+  },
+  "agent": {
+    "source": {"git": {"url": "https://github.com/contoso/edge-nodes.git", "allowedSignersFile": "/etc/aks-flex-node/allowed_signers"}}
+  }
+}`
+	const pulled = `apiVersion: aksflexnode.azure.com/v1alpha1
+kind: NodeSpec
+metadata:
+  name: edge-fleet
+spec:
+  kubernetes:
+    version: 1.31.1
+  node:
+    labels:
+      site: store-42
+  agent:
+    source:
+      git:
+        url: https://attacker.example/nodes.git
+`
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	specPath := filepath.Join(dir, "nodespec.yaml")
+	if err := os.WriteFile(configPath, []byte(local), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(specPath, []byte(pulled), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadLayeredConfig(configPath, specPath)
+	if err != nil {
+		t.Fatalf("LoadLayeredConfig() unexpected error: %v", err)
+	}
+	if cfg.Kubernetes.Version != "1.31.1" || cfg.Node.Labels["site"] != "store-42" {
+		t.Errorf("LoadLayeredConfig() = kubernetes %q, labels %v, want the values of the pulled spec", cfg.Kubernetes.Version, cfg.Node.Labels)
+	}
+	if cfg.Azure.BootstrapToken == nil || cfg.Azure.TargetCluster.Name != "test-cluster" {
+		t.Error("LoadLayeredConfig() lost settings of the local configuration")
+	}
+	if got := cfg.Agent.Source.Git.URL; got != "https://github.com/contoso/edge-nodes.git" {
+		t.Errorf("LoadLayeredConfig() source url = %q, want the pulled spec unable to change it", got)
+	}
+	if cfg.GetNodeSpecName() != "edge-fleet" {
+		t.Errorf("GetNodeSpecName() = %q, want edge-fleet", cfg.GetNodeSpecName())
+	}
+
+	if err := os.WriteFile(specPath, []byte(local), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLayeredConfig(configPath, specPath); err == nil {
+		t.Error("LoadLayeredConfig() expected error for a pulled document that is not a NodeSpec")
+	}
+}
+
+func TestLoadConfigClusters(t *testing.T) {
+	const configJSON = `{
+  "azure": {
+    "subscriptionId": "12345678-1234-1234-1234-123456789012",
+    "tenantId": "12345678-1234-1234-1234-123456789012",
+    "managedIdentity": {},
+    "clusters": {
+      "east": {
+        "resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/rg-east/providers/Microsoft.ContainerService/managedClusters/aks-east",
+        "location": "eastus"
+      },
+      "West": {
+        "resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/rg-west/providers/Microsoft.ContainerService/managedClusters/aks-west",
+        "location": "westus2"
+      }
+    },
+    "currentCluster": "east"
+  },
+  "kubernetes": {"version": "1.31.1"}
+}`
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(configJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	currentClusterPath = filepath.Join(dir, "current-cluster")
+	defer func() { currentClusterPath = CurrentClusterPath }()
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if cfg.GetCurrentCluster() != "east" || cfg.GetTargetClusterName() != "aks-east" || cfg.GetTargetClusterResourceGroup() != "rg-east" {
+		t.Errorf("LoadConfig() = cluster %q (%s/%s), want east", cfg.GetCurrentCluster(), cfg.GetTargetClusterResourceGroup(), cfg.GetTargetClusterName())
+	}
+	if got := cfg.GetClusterNames(); strings.Join(got, ",") != "east,west" {
+		t.Errorf("GetClusterNames() = %v, want east and west", got)
+	}
+
+	// A recorded switch takes precedence over the configured current cluster
+	if err := RecordCurrentCluster("West"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadConfig(configPath); err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if cfg.GetCurrentCluster() != "west" || cfg.GetTargetClusterName() != "aks-west" || cfg.Azure.TargetCluster.Location != "westus2" {
+		t.Errorf("LoadConfig() = cluster %q (%s), want the recorded west", cfg.GetCurrentCluster(), cfg.GetTargetClusterName())
+	}
+	if tags := cfg.GetArcMachineTags(); !strings.HasSuffix(tags[ClusterTag], "/aks-west") {
+		t.Errorf("GetArcMachineTags() = %v, want the current cluster", tags)
+	}
+
+	if err := RecordCurrentCluster("north"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("LoadConfig() expected error for a recorded cluster that is not configured")
+	}
+	if err := RecordCurrentCluster(""); err != nil {
+		t.Fatal(err)
+	}
+	if recorded, err := RecordedCurrentCluster(); recorded != "" || err != nil {
+		t.Errorf("RecordedCurrentCluster() = %q, %v after removing the record", recorded, err)
+	}
+}
+
+func TestLoadConfigDiscoveredCluster(t *testing.T) {
+	const clusterID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"
+	const configJSON = `{
+  "azure": {
+    "tenantId": "12345678-1234-1234-1234-123456789012",
+    "managedIdentity": {},
+    "targetCluster": {"resourceId": "` + clusterID + `"},
+    "arc": {
+      "roleAssignments": [
+        {"role": "Reader", "scope": "/subscriptions/{clusterSubscriptionId}/resourceGroups/{nodeResourceGroup}"},
+        {"role": "Reader", "scope": "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroup}"}
+      ]
+    }
+  },
+  "kubernetes": {"version": "1.31.1"}
+}`
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(configJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	discoveredClusterPath = filepath.Join(dir, "cluster-discovery.json")
+	defer func() { discoveredClusterPath = DiscoveredClusterPath }()
+
+	// Only the cluster resource ID is configured
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if cfg.GetSubscriptionID() != "12345678-1234-1234-1234-123456789012" {
+		t.Errorf("GetSubscriptionID() = %q, want the cluster subscription", cfg.GetSubscriptionID())
+	}
+	if !cfg.NeedsClusterDiscovery() || cfg.GetTargetClusterNodeResourceGroup() != "" {
+		t.Errorf("LoadConfig() = node resource group %q, want it left to discovery", cfg.GetTargetClusterNodeResourceGroup())
+	}
+	assignments := cfg.GetArcRoleAssignments()
+	if assignments[0].Scope != "/subscriptions/{clusterSubscriptionId}/resourceGroups/{nodeResourceGroup}" {
+		t.Errorf("scope = %q, want it expanded after discovery", assignments[0].Scope)
+	}
+	if assignments[1].Scope != "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg" {
+		t.Errorf("scope = %q, want it expanded at load", assignments[1].Scope)
+	}
+
+	discovered := DiscoveredCluster{ResourceID: strings.ToLower(clusterID), Location: "westeurope", NodeResourceGroup: "custom-nodes"}
+	if err := RecordDiscoveredCluster(discovered); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadConfig(configPath); err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if cfg.NeedsClusterDiscovery() || cfg.GetArcLocation() != "westeurope" || cfg.GetTargetClusterNodeResourceGroup() != "custom-nodes" {
+		t.Errorf("LoadConfig() = location %q, node resource group %q, want the discovered ones", cfg.GetArcLocation(), cfg.GetTargetClusterNodeResourceGroup())
+	}
+	if got := cfg.GetArcRoleAssignments()[0].Scope; got != "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/custom-nodes" {
+		t.Errorf("scope = %q, want the discovered node resource group", got)
+	}
+
+	// A record of another cluster does not apply
+	discovered.ResourceID = strings.Replace(clusterID, "test-cluster", "other-cluster", 1)
+	if err := RecordDiscoveredCluster(discovered); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadConfig(configPath); err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if !cfg.NeedsClusterDiscovery() || cfg.Azure.TargetCluster.Location != "" {
+		t.Errorf("LoadConfig() applied the record of another cluster")
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	dir := t.TempDir()
+	discoveredClusterPath = filepath.Join(dir, "cluster-discovery.json")
+	defer func() { discoveredClusterPath = DiscoveredClusterPath }()
+
+	cfg := &Config{
+		Azure: AzureConfig{
+			SubscriptionID:  "12345678-1234-1234-1234-123456789012",
+			TenantID:        "12345678-1234-1234-1234-123456789012",
+			ManagedIdentity: &ManagedIdentityConfig{},
+			TargetCluster: &TargetClusterConfig{
+				ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				Location:   "eastus",
+			},
+		},
+		Kubernetes: KubernetesConfig{Version: "1.31.1"},
+	}
+	if err := cfg.Prepare(); err != nil {
+		t.Fatalf("Prepare() unexpected error: %v", err)
+	}
+	if !cfg.IsMIConfigured() {
+		t.Error("IsMIConfigured() = false, want the managed identity set in code to count")
+	}
+	if cfg.Azure.TargetCluster.Name != "test-cluster" || cfg.Azure.TargetCluster.ResourceGroup != "test-rg" {
+		t.Errorf("TargetCluster = %+v, want it populated from the resource ID", cfg.Azure.TargetCluster)
+	}
+	if cfg.Agent.LogLevel == "" {
+		t.Error("Agent.LogLevel is empty, want defaults set")
+	}
+
+	invalid := &Config{}
+	if err := invalid.Prepare(); err == nil || !strings.Contains(err.Error(), "config validation failed") {
+		t.Errorf("Prepare() error = %v, want a validation error", err)
+	}
+}
+
+func TestSelectTargetCluster(t *testing.T) {
+	east := &TargetClusterConfig{
+		ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/east",
+		Location:   "eastus",
+	}
+	tests := []struct {
+		name    string
+		azure   AzureConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "single target cluster", azure: AzureConfig{TargetCluster: east}},
+		{name: "only cluster listed", azure: AzureConfig{Clusters: map[string]*TargetClusterConfig{"east": east}}, want: "east"},
+		{
+			name:  "current cluster",
+			azure: AzureConfig{Clusters: map[string]*TargetClusterConfig{"east": east, "west": east}, CurrentCluster: "West"},
+			want:  "west",
+		},
+		{
+			name:    "current cluster missing",
+			azure:   AzureConfig{Clusters: map[string]*TargetClusterConfig{"east": east, "west": east}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown current cluster",
+			azure:   AzureConfig{Clusters: map[string]*TargetClusterConfig{"east": east}, CurrentCluster: "north"},
+			wantErr: true,
+		},
+		{name: "current cluster without clusters", azure: AzureConfig{TargetCluster: east, CurrentCluster: "east"}, wantErr: true},
+		{
+			name:    "target cluster and clusters",
+			azure:   AzureConfig{TargetCluster: east, Clusters: map[string]*TargetClusterConfig{"east": east}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid cluster",
+			azure:   AzureConfig{Clusters: map[string]*TargetClusterConfig{"east": east, "west": {ResourceID: "aks-west", Location: "westus2"}}, CurrentCluster: "east"},
+			wantErr: true,
+		},
+		{
+			name: "bootstrap token",
+			azure: AzureConfig{
+				Clusters:       map[string]*TargetClusterConfig{"east": east},
+				BootstrapToken: &BootstrapTokenConfig{Token: "abcdef.0123456789abcdef"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: tt.azure}
+			err := cfg.selectTargetCluster("")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectTargetCluster() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.GetCurrentCluster() != tt.want {
+				t.Errorf("GetCurrentCluster() = %q, want %q", cfg.GetCurrentCluster(), tt.want)
+			}
+		})
+	}
+}
+
 func TestValidateAzureResourceID(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -745,6 +1117,66 @@ func TestValidateBootstrapToken(t *testing.T) {
 			wantErr:   true,
 			errString: "invalid bootstrap token format",
 		},
+		{
+			name: "broker without server URL",
+			config: &Config{
+				Azure: AzureConfig{
+					BootstrapToken: &BootstrapTokenConfig{
+						Broker: &BootstrapTokenBrokerConfig{URL: "https://flex-broker.azurewebsites.net/api/token", Audience: "api://flex-broker"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "broker and token",
+			config: &Config{
+				Azure: AzureConfig{
+					BootstrapToken: &BootstrapTokenConfig{
+						Token:  "abcdef.0123456789abcdef",
+						Broker: &BootstrapTokenBrokerConfig{URL: "https://flex-broker.azurewebsites.net/api/token", Audience: "api://flex-broker"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: "cannot both be set",
+		},
+		{
+			name: "broker over http",
+			config: &Config{
+				Azure: AzureConfig{
+					BootstrapToken: &BootstrapTokenConfig{
+						Broker: &BootstrapTokenBrokerConfig{URL: "http://flex-broker.azurewebsites.net/api/token", Audience: "api://flex-broker"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: "invalid broker.url",
+		},
+		{
+			name: "broker without audience",
+			config: &Config{
+				Azure: AzureConfig{
+					BootstrapToken: &BootstrapTokenConfig{
+						Broker: &BootstrapTokenBrokerConfig{URL: "https://flex-broker.azurewebsites.net/api/token"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: "broker.audience is required",
+		},
+		{
+			name: "broker with invalid client ID",
+			config: &Config{
+				Azure: AzureConfig{
+					BootstrapToken: &BootstrapTokenConfig{
+						Broker: &BootstrapTokenBrokerConfig{URL: "https://flex-broker.azurewebsites.net/api/token", Audience: "api://flex-broker", ClientID: "flex"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: "invalid broker.clientId",
+		},
 	}
 
 	for _, tt := range tests {
@@ -765,6 +1197,81 @@ func TestValidateBootstrapToken(t *testing.T) {
 	}
 }
 
+func TestValidateNodeCertificate(t *testing.T) {
+	kubelet := KubeletConfig{
+		ServerURL:  "https://test-cluster-abc123.hcp.eastus.azmk8s.io:443",
+		CACertData: "LS0tLS1CRUdJTi1DRVJUSUZJQ0FURS0tLS0t",
+	}
+	tests := []struct {
+		name      string
+		cert      *NodeCertificateConfig
+		kubelet   KubeletConfig
+		errString string
+	}{
+		{
+			name:    "key vault issuer",
+			cert:    &NodeCertificateConfig{Issuer: "keyVault", KeyVault: &NodeCertificateKeyVaultConfig{VaultURL: "https://myvault.vault.azure.net", IssuerName: "corp-ca"}},
+			kubelet: kubelet,
+		},
+		{
+			name:    "ca issuer",
+			cert:    &NodeCertificateConfig{Issuer: "ca", CA: &NodeCertificateCAConfig{CertFile: "/etc/pki/ca.crt", KeyFile: "/etc/pki/ca.key"}, RenewBefore: "720h"},
+			kubelet: kubelet,
+		},
+		{
+			name:      "unknown issuer",
+			cert:      &NodeCertificateConfig{Issuer: "acme"},
+			kubelet:   kubelet,
+			errString: "invalid issuer",
+		},
+		{
+			name:      "key vault issuer without issuer name",
+			cert:      &NodeCertificateConfig{Issuer: "keyVault", KeyVault: &NodeCertificateKeyVaultConfig{VaultURL: "https://myvault.vault.azure.net"}},
+			kubelet:   kubelet,
+			errString: "keyVault.issuerName are required",
+		},
+		{
+			name:      "key vault over http",
+			cert:      &NodeCertificateConfig{Issuer: "keyVault", KeyVault: &NodeCertificateKeyVaultConfig{VaultURL: "http://myvault.vault.azure.net", IssuerName: "corp-ca"}},
+			kubelet:   kubelet,
+			errString: "invalid keyVault.vaultUrl",
+		},
+		{
+			name:      "relative CA key path",
+			cert:      &NodeCertificateConfig{Issuer: "ca", CA: &NodeCertificateCAConfig{CertFile: "/etc/pki/ca.crt", KeyFile: "ca.key"}},
+			kubelet:   kubelet,
+			errString: "must be absolute paths",
+		},
+		{
+			name:      "invalid renewBefore",
+			cert:      &NodeCertificateConfig{Issuer: "ca", CA: &NodeCertificateCAConfig{CertFile: "/etc/pki/ca.crt", KeyFile: "/etc/pki/ca.key"}, RenewBefore: "a month"},
+			kubelet:   kubelet,
+			errString: "invalid renewBefore",
+		},
+		{
+			name:      "missing cluster info",
+			cert:      &NodeCertificateConfig{Issuer: "ca", CA: &NodeCertificateCAConfig{CertFile: "/etc/pki/ca.crt", KeyFile: "/etc/pki/ca.key"}},
+			errString: "node.kubelet.serverURL and node.kubelet.caCertData are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{NodeCertificate: tt.cert}, Node: NodeConfig{Kubelet: tt.kubelet}}
+			err := cfg.validateNodeCertificate()
+			if tt.errString == "" {
+				if err != nil {
+					t.Errorf("validateNodeCertificate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errString) {
+				t.Errorf("validateNodeCertificate() error = %v, want error containing %q", err, tt.errString)
+			}
+		})
+	}
+}
+
 func TestAuthenticationMethodValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1052,3 +1559,2266 @@ func TestAuthenticationMethodValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveRoleAssignmentScopes(t *testing.T) {
+	newConfig := func(assignments ...RoleAssignmentConfig) *Config {
+		cfg := &Config{
+			Azure: AzureConfig{
+				SubscriptionID: "12345678-1234-1234-1234-123456789012",
+				Arc: &ArcConfig{
+					Enabled:         true,
+					RoleAssignments: assignments,
+				},
+				TargetCluster: &TargetClusterConfig{
+					ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+					Location:   "eastus",
+				},
+			},
+		}
+		populateTargetClusterInfoFromConfig(cfg)
+		return cfg
+	}
+
+	tests := []struct {
+		name      string
+		config    *Config
+		wantScope string
+		wantErr   string
+	}{
+		{
+			name:      "placeholders are expanded",
+			config:    newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}/resourceGroups/{nodeResourceGroup}"}),
+			wantScope: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/MC_test-rg_test-cluster_eastus",
+		},
+		{
+			name:      "literal scope is kept",
+			config:    newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/12345678-1234-1234-1234-123456789012"}),
+			wantScope: "/subscriptions/12345678-1234-1234-1234-123456789012",
+		},
+		{
+			name:    "unknown placeholder fails",
+			config:  newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscription}"}),
+			wantErr: "unknown placeholder {subscription}",
+		},
+		{
+			name:    "malformed scope fails",
+			config:  newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}/resourcegroup/rg"}),
+			wantErr: "invalid azure.arc.roleAssignments[0].scope",
+		},
+		{
+			name:    "missing role fails",
+			config:  newConfig(RoleAssignmentConfig{Scope: "/subscriptions/{subscriptionId}"}),
+			wantErr: "azure.arc.roleAssignments[0].role is required",
+		},
+		{
+			name: "condition is kept",
+			config: newConfig(RoleAssignmentConfig{Role: "Storage Blob Data Reader", Scope: "/subscriptions/{subscriptionId}",
+				Condition: "@Resource[Microsoft.Storage/storageAccounts/blobServices/containers:name] StringEquals 'node-logs'", ConditionVersion: "2.0"}),
+			wantScope: "/subscriptions/12345678-1234-1234-1234-123456789012",
+		},
+		{
+			name:    "condition version without condition fails",
+			config:  newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}", ConditionVersion: "2.0"}),
+			wantErr: "conditionVersion is set without a condition",
+		},
+		{
+			name:    "unsupported condition version fails",
+			config:  newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}", Condition: "true", ConditionVersion: "1.0"}),
+			wantErr: `unsupported conditionVersion "1.0"`,
+		},
+		{
+			name: "delegated managed identity is kept",
+			config: newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}", PrincipalID: "11111111-2222-3333-4444-555555555555",
+				DelegatedManagedIdentityResourceID: "/subscriptions/87654321-4321-4321-4321-210987654321/resourceGroups/onboarding/providers/Microsoft.ManagedIdentity/userAssignedIdentities/provisioner"}),
+			wantScope: "/subscriptions/12345678-1234-1234-1234-123456789012",
+		},
+		{
+			name: "delegated managed identity without principal fails",
+			config: newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}",
+				DelegatedManagedIdentityResourceID: "/subscriptions/87654321-4321-4321-4321-210987654321/resourceGroups/onboarding/providers/Microsoft.ManagedIdentity/userAssignedIdentities/provisioner"}),
+			wantErr: "delegatedManagedIdentityResourceId requires principalId",
+		},
+		{
+			name: "delegated managed identity of another type fails",
+			config: newConfig(RoleAssignmentConfig{Role: "Reader", Scope: "/subscriptions/{subscriptionId}", PrincipalID: "11111111-2222-3333-4444-555555555555",
+				DelegatedManagedIdentityResourceID: "/subscriptions/87654321-4321-4321-4321-210987654321/resourceGroups/onboarding/providers/Microsoft.Compute/virtualMachines/provisioner"}),
+			wantErr: "is not a user-assigned managed identity resource ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.resolveRoleAssignmentScopes()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveRoleAssignmentScopes() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveRoleAssignmentScopes() unexpected error = %v", err)
+			}
+			if got := tt.config.Azure.Arc.RoleAssignments[0].Scope; got != tt.wantScope {
+				t.Errorf("scope = %q, want %q", got, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestCrossTenantConfiguration(t *testing.T) {
+	const (
+		homeTenant    = "11111111-1111-1111-1111-111111111111"
+		clusterTenant = "22222222-2222-2222-2222-222222222222"
+		extraTenant   = "33333333-3333-3333-3333-333333333333"
+	)
+
+	newConfig := func(clusterTenantID string, auxiliary ...string) *Config {
+		return &Config{
+			Azure: AzureConfig{
+				SubscriptionID:     "12345678-1234-1234-1234-123456789012",
+				TenantID:           homeTenant,
+				Cloud:              "AzurePublicCloud",
+				ServicePrincipal:   &ServicePrincipalConfig{TenantID: homeTenant, ClientID: "client", ClientSecret: "secret"},
+				AuxiliaryTenantIDs: auxiliary,
+				TargetCluster: &TargetClusterConfig{
+					ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+					Location:   "eastus",
+					TenantID:   clusterTenantID,
+				},
+			},
+			Agent: AgentConfig{LogLevel: "info"},
+		}
+	}
+
+	t.Run("cluster tenant defaults to azure tenant", func(t *testing.T) {
+		cfg := newConfig("")
+		if got := cfg.GetTargetClusterTenantID(); got != homeTenant {
+			t.Errorf("GetTargetClusterTenantID() = %q, want %q", got, homeTenant)
+		}
+		if cfg.IsCrossTenant() {
+			t.Error("IsCrossTenant() = true, want false")
+		}
+		if got := cfg.GetAuxiliaryTenantIDs(); len(got) != 0 {
+			t.Errorf("GetAuxiliaryTenantIDs() = %v, want none", got)
+		}
+	})
+
+	t.Run("cluster tenant is added to auxiliary tenants once", func(t *testing.T) {
+		cfg := newConfig(clusterTenant, strings.ToUpper(clusterTenant), extraTenant, homeTenant)
+		if !cfg.IsCrossTenant() {
+			t.Error("IsCrossTenant() = false, want true")
+		}
+		got := cfg.GetAuxiliaryTenantIDs()
+		if len(got) != 2 || got[0] != clusterTenant || got[1] != extraTenant {
+			t.Errorf("GetAuxiliaryTenantIDs() = %v, want [%s %s]", got, clusterTenant, extraTenant)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("invalid tenant IDs fail", func(t *testing.T) {
+		if err := newConfig("contoso.onmicrosoft.com").Validate(); err == nil || !strings.Contains(err.Error(), "azure.targetCluster.tenantId") {
+			t.Errorf("Validate() error = %v, want invalid azure.targetCluster.tenantId", err)
+		}
+		if err := newConfig("", "not-a-guid").Validate(); err == nil || !strings.Contains(err.Error(), "azure.auxiliaryTenantIds[0]") {
+			t.Errorf("Validate() error = %v, want invalid azure.auxiliaryTenantIds[0]", err)
+		}
+	})
+
+	t.Run("managed identity cannot be used across tenants", func(t *testing.T) {
+		cfg := newConfig(clusterTenant)
+		cfg.Azure.ServicePrincipal = nil
+		cfg.isMIExplicitlySet = true
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "cannot be used across tenants") {
+			t.Errorf("Validate() error = %v, want cross-tenant managed identity error", err)
+		}
+	})
+}
+
+func TestValidatePrivateLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		pl      *PrivateLinkConfig
+		wantErr string
+	}{
+		{
+			name: "disabled is not validated",
+			pl:   &PrivateLinkConfig{ArcPrivateLinkScopeID: "bogus"},
+		},
+		{
+			name: "valid private link scope",
+			pl: &PrivateLinkConfig{
+				Enabled:               true,
+				ArcPrivateLinkScopeID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/net-rg/providers/Microsoft.HybridCompute/privateLinkScopes/pls",
+				AdditionalHosts:       []string{"myregistry.azurecr.io"},
+			},
+		},
+		{
+			name: "wrong resource type fails",
+			pl: &PrivateLinkConfig{
+				Enabled:               true,
+				ArcPrivateLinkScopeID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/net-rg/providers/Microsoft.Network/privateEndpoints/pe",
+			},
+			wantErr: "invalid azure.privateLink.arcPrivateLinkScopeId",
+		},
+		{
+			name:    "URL instead of host fails",
+			pl:      &PrivateLinkConfig{Enabled: true, AdditionalHosts: []string{"https://myvault.vault.azure.net"}},
+			wantErr: "invalid azure.privateLink.additionalHosts[0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{PrivateLink: tt.pl}}
+			err := cfg.validatePrivateLink()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePrivateLink() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePrivateLink() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePrerequisites(t *testing.T) {
+	const (
+		vnetID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/net-rg/providers/Microsoft.Network/virtualNetworks/site-vnet"
+		zoneID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/dns-rg/providers/Microsoft.Network/privateDnsZones/privatelink.his.arc.azure.com"
+	)
+	arc := &ArcConfig{ResourceGroup: "site-rg", Location: "westeurope"}
+	tests := []struct {
+		name          string
+		arc           *ArcConfig
+		prerequisites *PrerequisitesConfig
+		wantErr       string
+	}{
+		{
+			name:          "not deployed is not validated",
+			prerequisites: &PrerequisitesConfig{ManagedIdentityName: "!"},
+		},
+		{
+			name:          "identity and DNS links",
+			arc:           arc,
+			prerequisites: &PrerequisitesConfig{Deploy: true, ManagedIdentityName: "site-identity", VirtualNetworkID: vnetID, PrivateDNSZoneIDs: []string{zoneID}},
+		},
+		{
+			name:          "resource group and location are required",
+			prerequisites: &PrerequisitesConfig{Deploy: true, ResourceGroup: "site-rg"},
+			wantErr:       "azure.prerequisites.location",
+		},
+		{
+			name:          "invalid identity name fails",
+			arc:           arc,
+			prerequisites: &PrerequisitesConfig{Deploy: true, ManagedIdentityName: "a b"},
+			wantErr:       "invalid azure.prerequisites.managedIdentityName",
+		},
+		{
+			name:          "DNS links need a virtual network",
+			arc:           arc,
+			prerequisites: &PrerequisitesConfig{Deploy: true, PrivateDNSZoneIDs: []string{zoneID}},
+			wantErr:       "invalid azure.prerequisites.virtualNetworkId",
+		},
+		{
+			name:          "zone of the wrong type fails",
+			arc:           arc,
+			prerequisites: &PrerequisitesConfig{Deploy: true, VirtualNetworkID: vnetID, PrivateDNSZoneIDs: []string{vnetID}},
+			wantErr:       "invalid azure.prerequisites.privateDnsZoneIds[0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{Arc: tt.arc, Prerequisites: tt.prerequisites}}
+			err := cfg.validatePrerequisites()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePrerequisites() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePrerequisites() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateARMWriteLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   *ARMWriteLimitConfig
+		want    int
+		wantErr string
+	}{
+		{name: "not configured", want: 10},
+		{name: "default concurrency", limit: &ARMWriteLimitConfig{ContainerURL: "https://contoso.blob.core.windows.net/fleet"}, want: 10},
+		{name: "custom concurrency", limit: &ARMWriteLimitConfig{ContainerURL: "https://contoso.blob.core.windows.net/fleet", MaxConcurrent: 3}, want: 3},
+		{name: "container is required", limit: &ARMWriteLimitConfig{MaxConcurrent: 3}, wantErr: "invalid azure.armWriteLimit.containerUrl"},
+		{name: "negative concurrency fails", limit: &ARMWriteLimitConfig{ContainerURL: "https://contoso.blob.core.windows.net/fleet", MaxConcurrent: -1}, wantErr: "invalid azure.armWriteLimit.maxConcurrent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{ARMWriteLimit: tt.limit}}
+			err := cfg.validateARMWriteLimit()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateARMWriteLimit() unexpected error: %v", err)
+				}
+				if got := cfg.GetARMWriteConcurrency(); got != tt.want {
+					t.Errorf("GetARMWriteConcurrency() = %d, want %d", got, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateARMWriteLimit() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateObserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe ObserveConfig
+		wantErr string
+	}{
+		{name: "off without a window", observe: ObserveConfig{Interval: "often"}},
+		{name: "window with rollback", observe: ObserveConfig{Window: "10m", Interval: "30s", MaxRestarts: 1, OnFailure: "rollback"}},
+		{name: "invalid window fails", observe: ObserveConfig{Window: "0s"}, wantErr: "invalid agent.observe.window"},
+		{name: "window above 2h fails", observe: ObserveConfig{Window: "3h"}, wantErr: "invalid agent.observe.window"},
+		{name: "interval below 5s fails", observe: ObserveConfig{Window: "10m", Interval: "1s"}, wantErr: "invalid agent.observe.interval"},
+		{name: "interval above the window fails", observe: ObserveConfig{Window: "1m", Interval: "2m"}, wantErr: "invalid agent.observe.interval"},
+		{name: "negative restarts fail", observe: ObserveConfig{Window: "10m", MaxRestarts: -1}, wantErr: "invalid agent.observe.maxRestarts"},
+		{name: "unknown failure mode fails", observe: ObserveConfig{Window: "10m", OnFailure: "unbootstrap"}, wantErr: "invalid agent.observe.onFailure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Observe: tt.observe}}
+			err := cfg.validateObserve()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateObserve() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateObserve() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCanary(t *testing.T) {
+	tests := []struct {
+		name    string
+		canary  CanaryConfig
+		wantErr string
+	}{
+		{name: "disabled is not validated", canary: CanaryConfig{Timeout: "soon"}},
+		{name: "enabled", canary: CanaryConfig{Enabled: true, Kubeconfig: "/etc/aks-flex-node/canary.kubeconfig", Namespace: "flex-canary", Timeout: "5m"}},
+		{name: "kubeconfig is required", canary: CanaryConfig{Enabled: true}, wantErr: "invalid agent.canary.kubeconfig"},
+		{name: "invalid namespace fails", canary: CanaryConfig{Enabled: true, Kubeconfig: "/k", Namespace: "Kube_System"}, wantErr: "invalid agent.canary.namespace"},
+		{name: "invalid timeout fails", canary: CanaryConfig{Enabled: true, Kubeconfig: "/k", Timeout: "-1m"}, wantErr: "invalid agent.canary.timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Canary: tt.canary}}
+			err := cfg.validateCanary()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateCanary() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateCanary() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCATrust(t *testing.T) {
+	tests := []struct {
+		name    string
+		caTrust CATrustConfig
+		wantErr string
+	}{
+		{
+			name: "empty is valid",
+		},
+		{
+			name: "certificates with registries",
+			caTrust: CATrustConfig{
+				CertificateFiles: []string{"/etc/ssl/contoso-root.pem"},
+				Registries:       []string{"myregistry.example.com:5000"},
+			},
+		},
+		{
+			name:    "key vault without URL fails",
+			caTrust: CATrustConfig{KeyVault: &KeyVaultCertificatesConfig{CertificateNames: []string{"root"}}},
+			wantErr: "caTrust.keyVault.vaultUrl is required",
+		},
+		{
+			name:    "key vault without certificates fails",
+			caTrust: CATrustConfig{KeyVault: &KeyVaultCertificatesConfig{VaultURL: "https://myvault.vault.azure.net"}},
+			wantErr: "caTrust.keyVault.certificateNames",
+		},
+		{
+			name:    "registry URL fails",
+			caTrust: CATrustConfig{Certificates: []string{"pem"}, Registries: []string{"https://myregistry.example.com"}},
+			wantErr: "invalid caTrust.registries[0]",
+		},
+		{
+			name:    "registries without certificates fail",
+			caTrust: CATrustConfig{Registries: []string{"myregistry.example.com"}},
+			wantErr: "requires at least one CA certificate source",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CATrust: tt.caTrust}
+			err := cfg.validateCATrust()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateCATrust() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateCATrust() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateResourceManagers(t *testing.T) {
+	tests := []struct {
+		name      string
+		kubelet   KubeletConfig
+		hugepages HugepagesConfig
+		cgroup    CgroupConfig
+		wantErr   string
+	}{
+		{
+			name: "defaults are valid",
+		},
+		{
+			name: "static policy with topology manager",
+			kubelet: KubeletConfig{
+				CPUManagerPolicy:      "static",
+				ReservedCPUs:          "0-1,4",
+				TopologyManagerPolicy: "single-numa-node",
+				TopologyManagerScope:  "pod",
+			},
+			hugepages: HugepagesConfig{Pages2Mi: 1024, Pages1Gi: 4},
+		},
+		{
+			name:    "unknown CPU manager policy fails",
+			kubelet: KubeletConfig{CPUManagerPolicy: "dynamic"},
+			wantErr: "invalid node.kubelet.cpuManagerPolicy",
+		},
+		{
+			name:    "unknown topology manager policy fails",
+			kubelet: KubeletConfig{TopologyManagerPolicy: "strict"},
+			wantErr: "invalid node.kubelet.topologyManagerPolicy",
+		},
+		{
+			name:    "reserved CPUs without static policy fails",
+			kubelet: KubeletConfig{ReservedCPUs: "0"},
+			wantErr: "requires node.kubelet.cpuManagerPolicy to be static",
+		},
+		{
+			name:    "shared host",
+			kubelet: KubeletConfig{SharedHost: &SharedHostConfig{CPUPercent: 50, MemoryPercent: 75}},
+		},
+		{
+			name:    "shared host above 100 percent fails",
+			kubelet: KubeletConfig{SharedHost: &SharedHostConfig{MemoryPercent: 120}},
+			wantErr: "must be between 1 and 100",
+		},
+		{
+			name:    "shared host without automatic reservation fails",
+			kubelet: KubeletConfig{SharedHost: &SharedHostConfig{CPUPercent: 50}, DisableAutoReservation: true},
+			wantErr: "requires automatic reservation",
+		},
+		{
+			name:    "shared host isolation without a share fails",
+			kubelet: KubeletConfig{SharedHost: &SharedHostConfig{Isolate: true}},
+			wantErr: "requires cpuPercent or memoryPercent below 100",
+		},
+		{
+			name:    "shared host isolation with cgroupfs fails",
+			kubelet: KubeletConfig{SharedHost: &SharedHostConfig{CPUPercent: 50, Isolate: true}},
+			cgroup:  CgroupConfig{Driver: "cgroupfs"},
+			wantErr: "requires node.cgroup.driver systemd",
+		},
+		{
+			name:    "malformed CPU list fails",
+			kubelet: KubeletConfig{CPUManagerPolicy: "static", ReservedCPUs: "0-"},
+			wantErr: "invalid node.kubelet.reservedCpus",
+		},
+		{
+			name:    "static policy without any CPU reservation fails",
+			kubelet: KubeletConfig{CPUManagerPolicy: "static", DisableAutoReservation: true},
+			wantErr: "requires a CPU reservation",
+		},
+		{
+			name:      "negative hugepages fail",
+			hugepages: HugepagesConfig{Pages2Mi: -1},
+			wantErr:   "must not be negative",
+		},
+		{
+			name:   "cgroupfs driver",
+			cgroup: CgroupConfig{Driver: "cgroupfs", MigrateToV2: true},
+		},
+		{
+			name:    "unknown cgroup driver fails",
+			cgroup:  CgroupConfig{Driver: "systemd-cgroup"},
+			wantErr: "invalid node.cgroup.driver",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Node: NodeConfig{Kubelet: tt.kubelet, Hugepages: tt.hugepages, Cgroup: tt.cgroup}}
+			err := cfg.validateResourceManagers()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateResourceManagers() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateResourceManagers() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTuning(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]TuningProfileConfig
+		wantErr  string
+	}{
+		{
+			name: "no custom profiles",
+		},
+		{
+			name: "valid custom profile",
+			profiles: map[string]TuningProfileConfig{
+				"ingress": {Extends: "high-network", Sysctls: map[string]string{"net.ipv4.tcp_rmem": "4096 87380 33554432", "net.ipv4.conf.all.rp_filter": "2"}},
+			},
+		},
+		{
+			name:     "self reference fails",
+			profiles: map[string]TuningProfileConfig{"loop": {Extends: "loop"}},
+			wantErr:  "cannot extend itself",
+		},
+		{
+			name:     "invalid key fails",
+			profiles: map[string]TuningProfileConfig{"bad": {Sysctls: map[string]string{"somaxconn": "1"}}},
+			wantErr:  "invalid sysctl key",
+		},
+		{
+			name:     "multi-line value fails",
+			profiles: map[string]TuningProfileConfig{"bad": {Sysctls: map[string]string{"net.core.somaxconn": "1\nkernel.panic = 0"}}},
+			wantErr:  "invalid value for sysctl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Node: NodeConfig{Tuning: TuningConfig{Profiles: tt.profiles}}}
+			err := cfg.validateTuning()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateTuning() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateTuning() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNodeNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		network NodeNetworkConfig
+		wantErr string
+	}{
+		{name: "IPv4 by default"},
+		{name: "dual-stack, IPv6 primary", network: NodeNetworkConfig{IPFamilies: []string{"IPv6", "IPv4"}, NodeIPs: []string{"2001:db8::10", "10.0.0.10"}}},
+		{name: "IPv6 only", network: NodeNetworkConfig{IPFamilies: []string{"IPv6"}}},
+		{name: "unknown family", network: NodeNetworkConfig{IPFamilies: []string{"ipv6"}}, wantErr: "invalid node.network.ipFamilies"},
+		{name: "family twice", network: NodeNetworkConfig{IPFamilies: []string{"IPv4", "IPv4"}}, wantErr: "lists IPv4 twice"},
+		{name: "node IP per family", network: NodeNetworkConfig{IPFamilies: []string{"IPv4", "IPv6"}, NodeIPs: []string{"10.0.0.10"}}, wantErr: "one address per IP family"},
+		{name: "node IP of the wrong family", network: NodeNetworkConfig{IPFamilies: []string{"IPv4", "IPv6"}, NodeIPs: []string{"2001:db8::10", "10.0.0.10"}}, wantErr: "is an IPv6 address"},
+		{name: "invalid node IP", network: NodeNetworkConfig{NodeIPs: []string{"10.0.0"}}, wantErr: "invalid node.network.nodeIPs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Node: NodeConfig{Network: tt.network}}
+			err := cfg.validateNodeNetwork()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNodeNetwork() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNodeNetwork() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNodeDNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		dns     NodeDNSConfig
+		wantErr string
+	}{
+		{name: "not configured"},
+		{name: "upstreams and search domains", dns: NodeDNSConfig{Upstreams: []string{"10.1.0.4", "fd00::53"}, SearchDomains: []string{"corp.example.com", "example"}, VerifyNames: []string{"registry.corp.example.com"}}},
+		{name: "too many upstreams", dns: NodeDNSConfig{Upstreams: []string{"10.1.0.4", "10.1.0.5", "10.1.0.6", "10.1.0.7"}}, wantErr: "at most 3"},
+		{name: "upstream with a port", dns: NodeDNSConfig{Upstreams: []string{"10.1.0.4:53"}}, wantErr: "invalid node.dns.upstreams"},
+		{name: "upstream twice", dns: NodeDNSConfig{Upstreams: []string{"fd00::53", "fd00:0::53"}}, wantErr: "lists fd00:0::53 twice"},
+		{name: "search domain with a trailing dot", dns: NodeDNSConfig{SearchDomains: []string{"corp.example.com."}}, wantErr: "invalid node.dns.searchDomains"},
+		{name: "search domain with spaces", dns: NodeDNSConfig{SearchDomains: []string{"corp example.com"}}, wantErr: "invalid node.dns.searchDomains"},
+		{name: "invalid name to verify", dns: NodeDNSConfig{VerifyNames: []string{"https://registry"}}, wantErr: "invalid node.dns.verifyNames"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Node: NodeConfig{DNS: tt.dns}}
+			err := cfg.validateNodeDNS()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNodeDNS() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNodeDNS() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAPIServerPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    *APIServerPathConfig
+		wantErr string
+	}{
+		{name: "not configured"},
+		{name: "vpn limits", path: &APIServerPathConfig{Mode: "warn", MaxLatencyMs: 80, MinPathMTU: 1400}},
+		{name: "unknown mode", path: &APIServerPathConfig{Mode: "fail"}, wantErr: "invalid preflight.apiServerPath.mode"},
+		{name: "negative latency", path: &APIServerPathConfig{MaxLatencyMs: -1}, wantErr: "must not be negative"},
+		{name: "mtu too small", path: &APIServerPathConfig{MinPathMTU: 500}, wantErr: "between 576 and 9216"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Preflight: PreflightConfig{APIServerPath: tt.path}}
+			err := cfg.validateAPIServerPath()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateAPIServerPath() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateAPIServerPath() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCNIMTU(t *testing.T) {
+	tests := []struct {
+		name     string
+		mtu      int
+		families []string
+		wantErr  string
+	}{
+		{name: "detected"},
+		{name: "vpn tunnel", mtu: 1380},
+		{name: "jumbo frames", mtu: 9000},
+		{name: "too small", mtu: 500, wantErr: "between 576 and 9216"},
+		{name: "too large", mtu: 9500, wantErr: "between 576 and 9216"},
+		{name: "below the IPv6 minimum", mtu: 1200, families: []string{"IPv4", "IPv6"}, wantErr: "at least 1280 on IPv6 nodes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CNI: CNIConfig{MTU: tt.mtu}, Node: NodeConfig{Network: NodeNetworkConfig{IPFamilies: tt.families}}}
+			err := cfg.validateCNIMTU()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateCNIMTU() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateCNIMTU() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInstallLimits(t *testing.T) {
+	tests := []struct {
+		name           string
+		limits         InstallLimitsConfig
+		wantProperties []string
+		wantErr        string
+	}{
+		{name: "no limits"},
+		{
+			name:           "all limits",
+			limits:         InstallLimitsConfig{CPUQuota: "50%", IOWeight: 20, MemoryMax: "512M"},
+			wantProperties: []string{"CPUQuota=50%", "IOWeight=20", "MemoryMax=512M"},
+		},
+		{name: "cpu quota without percent", limits: InstallLimitsConfig{CPUQuota: "0.5"}, wantErr: "invalid agent.installLimits.cpuQuota"},
+		{name: "io weight too large", limits: InstallLimitsConfig{IOWeight: 20000}, wantErr: "between 1 and 10000"},
+		{name: "memory with unit", limits: InstallLimitsConfig{MemoryMax: "512MB"}, wantErr: "invalid agent.installLimits.memoryMax"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{InstallLimits: tt.limits}}
+			err := cfg.validateInstallLimits()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("validateInstallLimits() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateInstallLimits() unexpected error: %v", err)
+			}
+			if got := cfg.GetInstallLimitProperties(); !reflect.DeepEqual(got, tt.wantProperties) {
+				t.Errorf("GetInstallLimitProperties() = %v, want %v", got, tt.wantProperties)
+			}
+		})
+	}
+}
+
+func TestValidateNodePool(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    NodePoolConfig
+		labels  map[string]string
+		wantErr string
+	}{
+		{name: "no pool"},
+		{name: "valid pool", pool: NodePoolConfig{Name: "edgegpu", Mode: "user", Taints: []string{"sku=gpu:NoSchedule", "dedicated:NoExecute"}}},
+		{name: "mode without name", pool: NodePoolConfig{Mode: "system"}, wantErr: "node.pool.name is required"},
+		{name: "uppercase name", pool: NodePoolConfig{Name: "EdgeGPU"}, wantErr: "invalid node.pool.name"},
+		{name: "name too long", pool: NodePoolConfig{Name: "edgegpupool123"}, wantErr: "invalid node.pool.name"},
+		{name: "invalid mode", pool: NodePoolConfig{Name: "edge", Mode: "spot"}, wantErr: "invalid node.pool.mode"},
+		{name: "taint without effect", pool: NodePoolConfig{Name: "edge", Taints: []string{"sku=gpu"}}, wantErr: "invalid node.pool.taints"},
+		{name: "taint with unknown effect", pool: NodePoolConfig{Name: "edge", Taints: []string{"sku=gpu:Never"}}, wantErr: "invalid node.pool.taints"},
+		{
+			name:    "conflicting label",
+			pool:    NodePoolConfig{Name: "edge"},
+			labels:  map[string]string{"kubernetes.azure.com/agentpool": "other"},
+			wantErr: "which node.pool manages",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Node: NodeConfig{Pool: tt.pool, Labels: tt.labels}}
+			err := cfg.validateNodePool()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNodePool() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNodePool() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNodePoolLabelsAndTags(t *testing.T) {
+	cfg := &Config{
+		Azure: AzureConfig{Arc: &ArcConfig{Tags: map[string]string{"env": "prod", NodePoolModeTag: "custom"}}},
+		Node: NodeConfig{
+			Labels: map[string]string{"kubernetes.azure.com/managed": "false"},
+			Pool:   NodePoolConfig{Name: "edge"},
+		},
+	}
+
+	labels := cfg.GetNodeLabels()
+	want := map[string]string{
+		"kubernetes.azure.com/managed": "false",
+		AgentPoolLabel:                 "edge",
+		AKSAgentPoolLabel:              "edge",
+		AKSNodePoolModeLabel:           "user",
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("GetNodeLabels() = %v, want %v", labels, want)
+	}
+	for key, value := range want {
+		if labels[key] != value {
+			t.Errorf("GetNodeLabels()[%q] = %q, want %q", key, labels[key], value)
+		}
+	}
+	if len(cfg.Node.Labels) != 1 {
+		t.Errorf("GetNodeLabels() modified node.labels: %v", cfg.Node.Labels)
+	}
+
+	tags := cfg.GetArcMachineTags()
+	if tags[NodePoolTag] != "edge" || tags["env"] != "prod" {
+		t.Errorf("GetArcMachineTags() = %v, want pool and configured tags", tags)
+	}
+	if tags[NodePoolModeTag] != "custom" {
+		t.Errorf("GetArcMachineTags()[%q] = %q, configured tags should win", NodePoolModeTag, tags[NodePoolModeTag])
+	}
+
+	if got := (&Config{}).GetArcMachineTags(); len(got) != 0 {
+		t.Errorf("GetArcMachineTags() without a pool = %v, want empty", got)
+	}
+}
+
+func TestConflictingAgentsMode(t *testing.T) {
+	cfg := &Config{}
+	if got := cfg.GetConflictingAgentsMode(); got != "abort" {
+		t.Errorf("GetConflictingAgentsMode() default = %q, want abort", got)
+	}
+	for mode, valid := range map[string]bool{"abort": true, "stop-and-disable": true, "coexist": true, "ignore": false} {
+		if validConflictingAgentModes[mode] != valid {
+			t.Errorf("validConflictingAgentModes[%q] = %v, want %v", mode, !valid, valid)
+		}
+	}
+}
+
+func TestValidateReboot(t *testing.T) {
+	tests := []struct {
+		name    string
+		reboot  RebootConfig
+		wantErr string
+	}{
+		{name: "default manual"},
+		{name: "immediate", reboot: RebootConfig{Policy: "immediate"}},
+		{name: "maintenance window", reboot: RebootConfig{Policy: "maintenance-window", MaintenanceWindow: "22:00-02:00"}},
+		{name: "unknown policy", reboot: RebootConfig{Policy: "later"}, wantErr: "invalid agent.reboot.policy"},
+		{name: "window required", reboot: RebootConfig{Policy: "maintenance-window"}, wantErr: "maintenanceWindow is required"},
+		{name: "malformed window", reboot: RebootConfig{Policy: "maintenance-window", MaintenanceWindow: "2am-4am"}, wantErr: "invalid agent.reboot.maintenanceWindow"},
+		{name: "empty window", reboot: RebootConfig{Policy: "maintenance-window", MaintenanceWindow: "02:00-02:00"}, wantErr: "must differ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Reboot: tt.reboot}}
+			err := cfg.validateReboot()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateReboot() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateReboot() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		drift   DriftConfig
+		wantErr string
+	}{
+		{name: "default interval"},
+		{name: "custom interval", drift: DriftConfig{Interval: "30m", NodeCondition: true, Remediate: true}},
+		{name: "malformed interval", drift: DriftConfig{Interval: "hourly"}, wantErr: "invalid agent.drift.interval"},
+		{name: "interval too short", drift: DriftConfig{Interval: "10s"}, wantErr: "invalid agent.drift.interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Drift: tt.drift}}
+			err := cfg.validateDrift()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateDrift() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateDrift() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRoleAssignmentCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   RoleAssignmentCheckConfig
+		wantErr string
+	}{
+		{name: "default interval"},
+		{name: "custom interval", check: RoleAssignmentCheckConfig{Interval: "1h", NodeCondition: true, Recreate: true}},
+		{name: "malformed interval", check: RoleAssignmentCheckConfig{Interval: "hourly"}, wantErr: "invalid agent.roleAssignments.interval"},
+		{name: "interval too short", check: RoleAssignmentCheckConfig{Interval: "1m"}, wantErr: "invalid agent.roleAssignments.interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{RoleAssignments: tt.check}}
+			err := cfg.validateRoleAssignmentCheck()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateRoleAssignmentCheck() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateRoleAssignmentCheck() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTelemetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		telemetry TelemetryConfig
+		wantErr   string
+	}{
+		{name: "disabled"},
+		{name: "disabled ignores endpoint", telemetry: TelemetryConfig{Endpoint: "not a url"}},
+		{name: "enabled", telemetry: TelemetryConfig{Enabled: true, Endpoint: "https://telemetry.contoso.com/v1/flex-node", BearerTokenFile: "/etc/aks-flex-node/telemetry-token"}},
+		{name: "enabled without endpoint", telemetry: TelemetryConfig{Enabled: true}, wantErr: "invalid agent.telemetry.endpoint"},
+		{name: "plain http", telemetry: TelemetryConfig{Enabled: true, Endpoint: "http://telemetry.contoso.com"}, wantErr: "invalid agent.telemetry.endpoint"},
+		{name: "relative token file", telemetry: TelemetryConfig{Enabled: true, Endpoint: "https://telemetry.contoso.com", BearerTokenFile: "token"}, wantErr: "invalid agent.telemetry.bearerTokenFile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Telemetry: tt.telemetry}}
+			err := cfg.validateTelemetry()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateTelemetry() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateTelemetry() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateState(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   StateConfig
+		wantErr string
+	}{
+		{name: "on the node only"},
+		{name: "file", state: StateConfig{Backend: StateBackendFile, Path: "/mnt/state/node-1"}},
+		{name: "file without path", state: StateConfig{Backend: StateBackendFile}, wantErr: "invalid agent.state.path"},
+		{name: "azure blob", state: StateConfig{Backend: StateBackendAzureBlob, AzureBlob: StateAzureBlobConfig{ContainerURL: "https://contoso.blob.core.windows.net/flex-node", Prefix: "pool-a/node-1"}}},
+		{name: "blob URL without container", state: StateConfig{Backend: StateBackendAzureBlob, AzureBlob: StateAzureBlobConfig{ContainerURL: "https://contoso.blob.core.windows.net"}}, wantErr: "invalid agent.state.azureBlob.containerUrl"},
+		{name: "prefix escapes", state: StateConfig{Backend: StateBackendAzureBlob, AzureBlob: StateAzureBlobConfig{ContainerURL: "https://contoso.blob.core.windows.net/flex-node", Prefix: "../other"}}, wantErr: "invalid agent.state.azureBlob.prefix"},
+		{name: "unknown backend", state: StateConfig{Backend: "sqlite"}, wantErr: "invalid agent.state.backend"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{State: tt.state}}
+			err := cfg.validateState()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateState() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateState() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateComponents(t *testing.T) {
+	tests := []struct {
+		name       string
+		components ComponentsConfig
+		wantErr    string
+	}{
+		{name: "none"},
+		{name: "plugin and component", components: ComponentsConfig{
+			Plugins:  []string{"/opt/contoso/security-agent.so"},
+			External: []ExternalComponentConfig{{Name: "security-agent", Settings: map[string]string{"tenant": "contoso"}}},
+		}},
+		{name: "relative plugin", components: ComponentsConfig{Plugins: []string{"security-agent.so"}}, wantErr: "invalid components.plugins"},
+		{name: "missing name", components: ComponentsConfig{External: []ExternalComponentConfig{{}}}, wantErr: "invalid components.external name"},
+		{name: "uppercase name", components: ComponentsConfig{External: []ExternalComponentConfig{{Name: "SecurityAgent"}}}, wantErr: "invalid components.external name"},
+		{name: "duplicate", components: ComponentsConfig{External: []ExternalComponentConfig{{Name: "agent"}, {Name: "agent"}}}, wantErr: "listed more than once"},
+		{name: "exec", components: ComponentsConfig{External: []ExternalComponentConfig{{Name: "agent", Exec: "/opt/contoso/install.sh", After: "KubeletInstaller", Timeout: "5m"}}}},
+		{name: "relative exec", components: ComponentsConfig{External: []ExternalComponentConfig{{Name: "agent", Exec: "install.sh"}}}, wantErr: "Expected an absolute path"},
+		{name: "timeout without exec", components: ComponentsConfig{External: []ExternalComponentConfig{{Name: "agent", Timeout: "5m"}}}, wantErr: "only executables"},
+		{name: "invalid timeout", components: ComponentsConfig{External: []ExternalComponentConfig{{Name: "agent", Exec: "/opt/contoso/install.sh", Timeout: "-1m"}}}, wantErr: "positive duration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Components: tt.components}
+			err := cfg.validateComponents()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateComponents() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateComponents() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateComponentToggles(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime string
+		enabled map[string]bool
+		canary  bool
+		wantErr string
+	}{
+		{name: "all on"},
+		{name: "leaf off", enabled: map[string]bool{"npd": false, "imagePrePull": false, "customScripts": false}},
+		{name: "explicitly on", enabled: map[string]bool{"kubelet": true}},
+		{name: "unknown component", enabled: map[string]bool{"docker": false}, wantErr: `invalid components.enabled entry: "docker"`},
+		{name: "containerd off with kubelet on", enabled: map[string]bool{"containerd": false, "imagePrePull": false}, wantErr: "kubelet needs containerd"},
+		{name: "runtime only", enabled: map[string]bool{"kubelet": false, "npd": false, "staticPods": false, "nodeTopology": false}},
+		{name: "kubelet off with npd on", enabled: map[string]bool{"kubelet": false, "staticPods": false, "nodeTopology": false}, wantErr: "npd needs kubelet"},
+		{name: "runc off with containerd on", enabled: map[string]bool{"runc": false}, wantErr: "containerd needs runc"},
+		{name: "unused runtime off", enabled: map[string]bool{"cri-o": false}},
+		{name: "selected cri-o off", runtime: "cri-o", enabled: map[string]bool{"cri-o": false}, wantErr: "imagePrePull needs cri-o"},
+		{name: "canary without kubelet", enabled: map[string]bool{"kubelet": false, "npd": false, "staticPods": false, "nodeTopology": false}, canary: true, wantErr: "agent.canary needs kubelet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{ContainerRuntime: tt.runtime, Components: ComponentsConfig{Enabled: tt.enabled}}
+			cfg.Agent.Canary.Enabled = tt.canary
+			err := cfg.validateComponentToggles()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateComponentToggles() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateComponentToggles() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStaticPods(t *testing.T) {
+	const manifest = "apiVersion: v1\nkind: Pod\n"
+	tests := []struct {
+		name    string
+		pods    []StaticPodConfig
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "inline and file", pods: []StaticPodConfig{
+			{Name: "node-local-dns", Manifest: manifest},
+			{Name: "cloud-node-manager", ManifestFile: "/etc/contoso/cloud-node-manager.yaml"},
+		}},
+		{name: "bad name", pods: []StaticPodConfig{{Name: "Node_DNS", Manifest: manifest}}, wantErr: "invalid staticPods name"},
+		{name: "duplicate", pods: []StaticPodConfig{{Name: "dns", Manifest: manifest}, {Name: "dns", Manifest: manifest}}, wantErr: "listed more than once"},
+		{name: "no manifest", pods: []StaticPodConfig{{Name: "dns"}}, wantErr: "exactly one of manifest and manifestFile"},
+		{name: "both manifests", pods: []StaticPodConfig{{Name: "dns", Manifest: manifest, ManifestFile: "/etc/dns.yaml"}}, wantErr: "exactly one of manifest and manifestFile"},
+		{name: "relative file", pods: []StaticPodConfig{{Name: "dns", ManifestFile: "dns.yaml"}}, wantErr: "Expected an absolute path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{StaticPods: tt.pods}
+			err := cfg.validateStaticPods()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateStaticPods() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateStaticPods() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCustomScripts(t *testing.T) {
+	tests := []struct {
+		name    string
+		scripts []CustomScriptConfig
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "inline and file", scripts: []CustomScriptConfig{
+			{Name: "tune-nic", Script: "ethtool -G eth0 rx 4096", Timeout: "1m", ExitCodes: []int{0, 3}, Run: "always"},
+			{Name: "inventory", ScriptFile: "/opt/contoso/inventory.py", Interpreter: "/usr/bin/python3", ReadWritePaths: []string{"/var/lib/contoso"}},
+		}},
+		{name: "bad name", scripts: []CustomScriptConfig{{Name: "Tune NIC", Script: "true"}}, wantErr: "invalid customScripts name"},
+		{name: "duplicate", scripts: []CustomScriptConfig{{Name: "a", Script: "true"}, {Name: "a", Script: "true"}}, wantErr: "listed more than once"},
+		{name: "no script", scripts: []CustomScriptConfig{{Name: "a"}}, wantErr: "exactly one of script and scriptFile"},
+		{name: "relative file", scripts: []CustomScriptConfig{{Name: "a", ScriptFile: "a.sh"}}, wantErr: "invalid customScripts scriptFile"},
+		{name: "bad checksum", scripts: []CustomScriptConfig{{Name: "a", Script: "true", SHA256: "abc"}}, wantErr: "invalid customScripts sha256"},
+		{name: "relative interpreter", scripts: []CustomScriptConfig{{Name: "a", Script: "true", Interpreter: "bash"}}, wantErr: "invalid customScripts interpreter"},
+		{name: "bad timeout", scripts: []CustomScriptConfig{{Name: "a", Script: "true", Timeout: "-1m"}}, wantErr: "invalid customScripts timeout"},
+		{name: "bad exit code", scripts: []CustomScriptConfig{{Name: "a", Script: "true", ExitCodes: []int{256}}}, wantErr: "invalid customScripts exitCodes"},
+		{name: "bad run", scripts: []CustomScriptConfig{{Name: "a", Script: "true", Run: "boot"}}, wantErr: "invalid customScripts run"},
+		{name: "relative writable path", scripts: []CustomScriptConfig{{Name: "a", Script: "true", ReadWritePaths: []string{"var"}}}, wantErr: "invalid customScripts readWritePaths"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CustomScripts: tt.scripts}
+			err := cfg.validateCustomScripts()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateCustomScripts() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateCustomScripts() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		patching PatchingConfig
+		window   string
+		wantErr  string
+	}{
+		{name: "default"},
+		{name: "report", patching: PatchingConfig{Interval: "1h", NodeCondition: true, RebootPolicy: "report"}},
+		{name: "maintenance window", patching: PatchingConfig{RebootPolicy: "maintenance-window", DrainKubeconfig: "/etc/aks-flex-node/drain.kubeconfig"}, window: "02:00-04:00"},
+		{name: "interval too short", patching: PatchingConfig{Interval: "30s"}, wantErr: "invalid agent.patching.interval"},
+		{name: "unknown policy", patching: PatchingConfig{RebootPolicy: "immediate"}, wantErr: "invalid agent.patching.rebootPolicy"},
+		{name: "missing window", patching: PatchingConfig{RebootPolicy: "maintenance-window", DrainKubeconfig: "/k"}, wantErr: "agent.reboot.maintenanceWindow is required"},
+		{name: "missing drain kubeconfig", patching: PatchingConfig{RebootPolicy: "maintenance-window"}, window: "02:00-04:00", wantErr: "invalid agent.patching.drainKubeconfig"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Patching: tt.patching, Reboot: RebootConfig{MaintenanceWindow: tt.window}}}
+			err := cfg.validatePatching()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePatching() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePatching() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHeartbeat(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat HeartbeatConfig
+		wantErr   string
+	}{
+		{name: "default interval"},
+		{name: "custom interval", heartbeat: HeartbeatConfig{Interval: "30s"}},
+		{name: "disabled", heartbeat: HeartbeatConfig{Disabled: true}},
+		{name: "malformed interval", heartbeat: HeartbeatConfig{Interval: "often"}, wantErr: "invalid agent.heartbeat.interval"},
+		{name: "interval too short", heartbeat: HeartbeatConfig{Interval: "1s"}, wantErr: "invalid agent.heartbeat.interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Heartbeat: tt.heartbeat}}
+			err := cfg.validateHeartbeat()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateHeartbeat() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateHeartbeat() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateArcExtensions(t *testing.T) {
+	monitor := ArcExtensionConfig{Name: "AzureMonitorLinuxAgent", Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorLinuxAgent"}
+	script := ArcExtensionConfig{
+		Name:                  "CustomScript",
+		Publisher:             "Microsoft.Azure.Extensions",
+		Type:                  "CustomScript",
+		TypeHandlerVersion:    "2.1",
+		ProtectedSettingsFile: "/etc/aks-flex-node/custom-script.json",
+	}
+	with := func(modify func(e *ArcExtensionConfig)) ArcExtensionConfig {
+		e := script
+		modify(&e)
+		return e
+	}
+
+	tests := []struct {
+		name       string
+		arcEnabled bool
+		extensions []ArcExtensionConfig
+		wantErr    string
+	}{
+		{name: "no extensions"},
+		{name: "extensions", arcEnabled: true, extensions: []ArcExtensionConfig{monitor, script}},
+		{name: "arc disabled", extensions: []ArcExtensionConfig{monitor}, wantErr: "requires azure.arc.enabled"},
+		{name: "duplicate name", arcEnabled: true, extensions: []ArcExtensionConfig{monitor, with(func(e *ArcExtensionConfig) { e.Name = "azuremonitorlinuxagent" })}, wantErr: "more than once"},
+		{name: "invalid name", arcEnabled: true, extensions: []ArcExtensionConfig{with(func(e *ArcExtensionConfig) { e.Name = "custom script" })}, wantErr: "invalid azure.arc.extensions[0].name"},
+		{name: "missing type", arcEnabled: true, extensions: []ArcExtensionConfig{with(func(e *ArcExtensionConfig) { e.Type = "" })}, wantErr: "requires publisher and type"},
+		{name: "relative protected settings", arcEnabled: true, extensions: []ArcExtensionConfig{with(func(e *ArcExtensionConfig) { e.ProtectedSettingsFile = "secrets.json" })}, wantErr: "protectedSettingsFile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{Arc: &ArcConfig{Enabled: tt.arcEnabled, Extensions: tt.extensions}}}
+			err := cfg.validateArcExtensions()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateArcExtensions() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateArcExtensions() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateArcNaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		arc     ArcConfig
+		wantErr string
+	}{
+		{name: "default"},
+		{name: "legacy machine name", arc: ArcConfig{MachineName: "edge-01"}},
+		{name: "explicit", arc: ArcConfig{NamingStrategy: "explicit", MachineName: "edge-01"}},
+		{name: "hostname suffix", arc: ArcConfig{NamingStrategy: "hostnameSuffix", NameSuffix: "site-12"}},
+		{name: "serial number", arc: ArcConfig{NamingStrategy: "serialNumber", AutoSuffix: true}},
+		{name: "unknown strategy", arc: ArcConfig{NamingStrategy: "fqdn"}, wantErr: "invalid azure.arc.namingStrategy"},
+		{name: "explicit without name", arc: ArcConfig{NamingStrategy: "explicit"}, wantErr: "requires azure.arc.machineName"},
+		{name: "name with another strategy", arc: ArcConfig{NamingStrategy: "hostname", MachineName: "edge-01"}, wantErr: "requires azure.arc.namingStrategy explicit"},
+		{name: "invalid name", arc: ArcConfig{MachineName: "edge 01"}, wantErr: "invalid azure.arc.machineName"},
+		{name: "suffix with another strategy", arc: ArcConfig{NameSuffix: "site-12"}, wantErr: "requires azure.arc.namingStrategy hostnameSuffix"},
+		{name: "invalid suffix", arc: ArcConfig{NamingStrategy: "hostnameSuffix", NameSuffix: "site 12"}, wantErr: "invalid azure.arc.nameSuffix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{Arc: &tt.arc}}
+			err := cfg.validateArcNaming()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateArcNaming() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateArcNaming() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveArcMachineName(t *testing.T) {
+	dir := t.TempDir()
+	productSerialPath = filepath.Join(dir, "product_serial")
+	machineIDPath = filepath.Join(dir, "machine-id")
+	osHostname = func() (string, error) { return "edge-01.contoso.local", nil }
+	defer func() {
+		productSerialPath = "/sys/class/dmi/id/product_serial"
+		machineIDPath = "/etc/machine-id"
+		osHostname = os.Hostname
+	}()
+	if err := os.WriteFile(machineIDPath, []byte("0123456789abcdef0123456789abcdef\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	suffix, err := MachineIDSuffix()
+	if err != nil || len(suffix) != 6 {
+		t.Fatalf("MachineIDSuffix() = %q, %v, want 6 characters", suffix, err)
+	}
+
+	tests := []struct {
+		name    string
+		arc     *ArcConfig
+		serial  string
+		want    string
+		wantErr string
+	}{
+		{name: "no arc config", want: "edge-01.contoso.local"},
+		{name: "hostname", arc: &ArcConfig{}, want: "edge-01.contoso.local"},
+		{name: "explicit", arc: &ArcConfig{MachineName: "store-4711"}, want: "store-4711"},
+		{name: "hostname with suffix", arc: &ArcConfig{NamingStrategy: "hostnameSuffix", NameSuffix: "site-12"}, want: "edge-01.contoso.local-site-12"},
+		{name: "hostname with machine ID suffix", arc: &ArcConfig{NamingStrategy: "hostnameSuffix"}, want: "edge-01.contoso.local-" + suffix},
+		{name: "serial number", arc: &ArcConfig{NamingStrategy: "serialNumber"}, serial: " CZ2 41Q0JX\n", want: "CZ2-41Q0JX"},
+		{name: "placeholder serial number", arc: &ArcConfig{NamingStrategy: "serialNumber"}, serial: "To Be Filled By O.E.M.", wantErr: "no serial number"},
+		{name: "unreadable serial number", arc: &ArcConfig{NamingStrategy: "serialNumber"}, wantErr: "failed to read the serial number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(productSerialPath)
+			if tt.serial != "" {
+				if err := os.WriteFile(productSerialPath, []byte(tt.serial), 0o400); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg := &Config{Azure: AzureConfig{Arc: tt.arc}}
+			got, err := cfg.ResolveArcMachineName()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ResolveArcMachineName() error = %v, want containing %q", err, tt.wantErr)
+				}
+				if cfg.GetArcMachineName() != "" {
+					t.Errorf("GetArcMachineName() = %q, want empty", cfg.GetArcMachineName())
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveArcMachineName() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestArcMachineNameRecord(t *testing.T) {
+	arcMachineNamePath = filepath.Join(t.TempDir(), "arc-machine-name.json")
+	defer func() { arcMachineNamePath = ArcMachineNamePath }()
+
+	cfg := &Config{Azure: AzureConfig{Arc: &ArcConfig{NamingStrategy: "hostnameSuffix", NameSuffix: "site-12"}}}
+	record := cfg.NewArcMachineNameRecord("edge-01-site-12-3f9a1c")
+	if err := RecordArcMachineName(&record); err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := recordedArcMachineName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.applyArcMachineNameRecord(recorded)
+	if got := cfg.GetArcMachineName(); got != "edge-01-site-12-3f9a1c" {
+		t.Errorf("GetArcMachineName() = %q, want the recorded name", got)
+	}
+	if got := cfg.Snapshot().GetArcMachineName(); got != "edge-01-site-12-3f9a1c" {
+		t.Errorf("Snapshot().GetArcMachineName() = %q, want the recorded name", got)
+	}
+
+	// A record made under other naming settings does not apply
+	changed := &Config{Azure: AzureConfig{Arc: &ArcConfig{NamingStrategy: "explicit", MachineName: "store-4711"}}}
+	changed.applyArcMachineNameRecord(recorded)
+	if got := changed.GetArcMachineName(); got != "store-4711" {
+		t.Errorf("GetArcMachineName() = %q, want the configured name", got)
+	}
+
+	if err := RecordArcMachineName(nil); err != nil {
+		t.Fatal(err)
+	}
+	if recorded, err := recordedArcMachineName(); err != nil || recorded != nil {
+		t.Errorf("recordedArcMachineName() = %v, %v, want no record", recorded, err)
+	}
+}
+
+func TestValidateServicePrincipal(t *testing.T) {
+	vault := &CredentialRotationConfig{KeyVaultSecretID: "https://myvault.vault.azure.net/secrets/flex-node-sp"}
+
+	tests := []struct {
+		name    string
+		sp      *ServicePrincipalConfig
+		wantErr string
+	}{
+		{name: "no service principal"},
+		{name: "secret", sp: &ServicePrincipalConfig{ClientSecret: "secret"}},
+		{name: "certificate with key vault rotation", sp: &ServicePrincipalConfig{ClientCertificateFile: "/etc/aks-flex-node/sp.pem", Rotation: vault}},
+		{name: "file rotation", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{File: "/run/secrets/sp", Interval: "15m"}}},
+		{name: "secret and certificate", sp: &ServicePrincipalConfig{ClientSecret: "secret", ClientCertificateFile: "/etc/aks-flex-node/sp.pem"}, wantErr: "cannot both be set"},
+		{name: "relative certificate", sp: &ServicePrincipalConfig{ClientCertificateFile: "sp.pem"}, wantErr: "clientCertificateFile"},
+		{name: "no rotation source", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{}}, wantErr: "exactly one of keyVaultSecretId, file and secret"},
+		{name: "two rotation sources", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{KeyVaultSecretID: vault.KeyVaultSecretID, File: "/run/secrets/sp"}}, wantErr: "exactly one of keyVaultSecretId, file and secret"},
+		{name: "secret reference rotation", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{Secret: "env:FLEX_NODE_SP_SECRET"}}},
+		{name: "file and secret reference", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{File: "/run/secrets/sp", Secret: "env:FLEX_NODE_SP_SECRET"}}, wantErr: "exactly one of"},
+		{name: "invalid secret reference", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{Secret: "FLEX_NODE_SP_SECRET"}}, wantErr: "invalid azure.servicePrincipal.rotation.secret"},
+		{name: "pinned secret version", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{KeyVaultSecretID: vault.KeyVaultSecretID + "/0123abcd"}}, wantErr: "without a version"},
+		{name: "invalid secret ID", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{KeyVaultSecretID: "flex-node-sp"}}, wantErr: "keyVaultSecretId"},
+		{name: "short interval", sp: &ServicePrincipalConfig{ClientSecret: "secret", Rotation: &CredentialRotationConfig{File: "/run/secrets/sp", Interval: "10s"}}, wantErr: "rotation.interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{ServicePrincipal: tt.sp}}
+			err := cfg.validateServicePrincipal()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateServicePrincipal() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateServicePrincipal() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetServicePrincipalCredential(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		doc             string
+		secret          string
+		certificateFile string
+		want            string
+		wantErr         string
+	}{
+		{
+			name:   "json secret",
+			path:   "config.json",
+			doc:    `{"azure": {"servicePrincipal": {"tenantId": "t", "clientId": "c", "clientSecret": "old"}, "subscriptionId": "s"}, "agent": {"logLevel": "info"}}`,
+			secret: "new",
+			want: `{
+  "agent": {
+    "logLevel": "info"
+  },
+  "azure": {
+    "servicePrincipal": {
+      "clientId": "c",
+      "clientSecret": "new",
+      "tenantId": "t"
+    },
+    "subscriptionId": "s"
+  }
+}
+`,
+		},
+		{
+			name:            "json secret replaced by certificate, keys matched case-insensitively",
+			path:            "config.json",
+			doc:             `{"Azure": {"ServicePrincipal": {"clientid": "c", "clientsecret": "old"}}}`,
+			certificateFile: "/etc/aks-flex-node/service-principal-0123.pem",
+			want: `{
+  "Azure": {
+    "ServicePrincipal": {
+      "clientCertificateFile": "/etc/aks-flex-node/service-principal-0123.pem",
+      "clientid": "c"
+    }
+  }
+}
+`,
+		},
+		{
+			name: "yaml keeps comments and order",
+			path: "config.yaml",
+			doc: `# Edge node
+azure:
+  servicePrincipal:
+    tenantId: t # home tenant
+    clientCertificateFile: /etc/aks-flex-node/service-principal-0123.pem
+    clientId: c
+  subscriptionId: s
+`,
+			secret: "new",
+			want: `# Edge node
+azure:
+  servicePrincipal:
+    tenantId: t # home tenant
+    clientId: c
+    clientSecret: new
+  subscriptionId: s
+`,
+		},
+		{
+			name: "nodespec",
+			path: "nodespec.yaml",
+			doc: `apiVersion: aksflexnode.azure.com/v1alpha1
+kind: NodeSpec
+spec:
+  azure:
+    servicePrincipal:
+      clientSecret: old
+`,
+			secret: "new",
+			want: `apiVersion: aksflexnode.azure.com/v1alpha1
+kind: NodeSpec
+spec:
+  azure:
+    servicePrincipal:
+      clientSecret: new
+`,
+		},
+		{name: "no service principal", path: "config.json", doc: `{"azure": {}}`, secret: "new", wantErr: "no azure.servicePrincipal"},
+		{name: "secret and certificate", path: "config.json", doc: `{}`, secret: "new", certificateFile: "/sp.pem", wantErr: "exactly one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetServicePrincipalCredential([]byte(tt.doc), tt.path, tt.secret, tt.certificateFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("SetServicePrincipalCredential() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetServicePrincipalCredential() unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("SetServicePrincipalCredential() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSpecSource(t *testing.T) {
+	git := func(modify func(g *GitSourceConfig)) *GitSourceConfig {
+		g := &GitSourceConfig{URL: "https://github.com/contoso/edge-nodes.git", AllowedSignersFile: "/etc/aks-flex-node/allowed_signers"}
+		modify(g)
+		return g
+	}
+	oci := &OCISourceConfig{Reference: "contoso.azurecr.io/nodespecs/edge:prod", CosignPublicKeyFile: "/etc/aks-flex-node/cosign.pub"}
+
+	tests := []struct {
+		name    string
+		source  SpecSourceConfig
+		wantErr string
+	}{
+		{name: "no source"},
+		{name: "git with ssh signers", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) {}), Interval: "2m"}},
+		{name: "git over ssh with gpg key", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) {
+			g.URL, g.AllowedSignersFile, g.GPGPublicKeyFile = "git@github.com:contoso/edge-nodes.git", "", "/etc/aks-flex-node/signers.asc"
+			g.Ref, g.Path = "release", "nodes/edge.yaml"
+		})}},
+		{name: "oci", source: SpecSourceConfig{OCI: oci}},
+		{name: "unsigned when allowed", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) { g.AllowedSignersFile = "" }), AllowUnsigned: true}},
+		{name: "both sources", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) {}), OCI: oci}, wantErr: "exactly one of git or oci"},
+		{name: "interval too short", source: SpecSourceConfig{OCI: oci, Interval: "30s"}, wantErr: "invalid agent.source.interval"},
+		{name: "missing url", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) { g.URL = "" })}, wantErr: "invalid agent.source.git.url"},
+		{name: "option as ref", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) { g.Ref = "--upload-pack=sh" })}, wantErr: "invalid agent.source.git.ref"},
+		{name: "path outside repository", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) { g.Path = "../etc/passwd" })}, wantErr: "invalid agent.source.git.path"},
+		{name: "both key types", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) { g.GPGPublicKeyFile = "/etc/keys.asc" })}, wantErr: "only one of allowedSignersFile or gpgPublicKeyFile"},
+		{name: "unsigned git", source: SpecSourceConfig{Git: git(func(g *GitSourceConfig) { g.AllowedSignersFile = "" })}, wantErr: "to verify commit signatures"},
+		{name: "oci url", source: SpecSourceConfig{OCI: &OCISourceConfig{Reference: "https://contoso.azurecr.io/edge", CosignPublicKeyFile: "/k"}}, wantErr: "invalid agent.source.oci.reference"},
+		{name: "unsigned oci", source: SpecSourceConfig{OCI: &OCISourceConfig{Reference: "contoso.azurecr.io/edge:prod"}}, wantErr: "requires cosignPublicKeyFile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Source: tt.source}}
+			err := cfg.validateSpecSource()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSpecSource() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSpecSource() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	valid := WebhookConfig{ListenAddress: ":8443", SecretFile: "/etc/aks-flex-node/webhook-secret", AllowedActions: []string{"bootstrap", "install"}}
+	with := func(modify func(w *WebhookConfig)) WebhookConfig {
+		w := valid
+		modify(&w)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		webhook WebhookConfig
+		wantErr string
+	}{
+		{name: "disabled"},
+		{name: "valid", webhook: valid},
+		{name: "tls and drain", webhook: with(func(w *WebhookConfig) {
+			w.TLSCertFile, w.TLSKeyFile = "/etc/aks-flex-node/tls.crt", "/etc/aks-flex-node/tls.key"
+			w.AllowedActions, w.DrainKubeconfig = []string{"drain", "uncordon"}, "/etc/aks-flex-node/drain.kubeconfig"
+		})},
+		{name: "missing port", webhook: with(func(w *WebhookConfig) { w.ListenAddress = "0.0.0.0" }), wantErr: "invalid agent.webhook.listenAddress"},
+		{name: "missing secret", webhook: with(func(w *WebhookConfig) { w.SecretFile = "" }), wantErr: "invalid agent.webhook.secretFile"},
+		{name: "secret reference", webhook: with(func(w *WebhookConfig) {
+			w.SecretFile, w.Secret = "", "pkcs11:token=node;object=webhook?module-path=/usr/lib/softhsm/libsofthsm2.so"
+		})},
+		{name: "secret file and reference", webhook: with(func(w *WebhookConfig) { w.Secret = "env:WEBHOOK_SECRET" }), wantErr: "cannot both be set"},
+		{name: "certificate without key", webhook: with(func(w *WebhookConfig) { w.TLSCertFile = "/etc/tls.crt" }), wantErr: "both tlsCertFile and tlsKeyFile"},
+		{name: "no actions", webhook: with(func(w *WebhookConfig) { w.AllowedActions = nil }), wantErr: "allowedActions is required"},
+		{name: "unknown action", webhook: with(func(w *WebhookConfig) { w.AllowedActions = []string{"exec"} }), wantErr: "invalid agent.webhook.allowedActions[0]"},
+		{name: "drain without kubeconfig", webhook: with(func(w *WebhookConfig) { w.AllowedActions = []string{"drain"} }), wantErr: "requires agent.webhook.drainKubeconfig"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{Webhook: tt.webhook}}
+			err := cfg.validateWebhook()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateWebhook() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateWebhook() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNPDPlugins(t *testing.T) {
+	valid := NPDPluginConfig{Name: "raid-health", Script: "#!/bin/sh\nexit 0\n", Condition: "RAIDProblem", Reason: "RAIDDegraded"}
+	with := func(modify func(p *NPDPluginConfig)) NPDPluginConfig {
+		p := valid
+		modify(&p)
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		plugins []NPDPluginConfig
+		wantErr string
+	}{
+		{name: "no plugins"},
+		{name: "inline script", plugins: []NPDPluginConfig{valid}},
+		{name: "script file with checksum", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) {
+			p.Script, p.ScriptFile = "", "/opt/health/raid.sh"
+			p.SHA256 = strings.Repeat("ab", 32)
+			p.Interval, p.Timeout = "30s", "5s"
+		})}},
+		{name: "invalid name", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Name = "../raid" })}, wantErr: "invalid npd.customPlugins[0].name"},
+		{name: "duplicate name", plugins: []NPDPluginConfig{valid, valid}, wantErr: "duplicate npd.customPlugins[1].name"},
+		{name: "no script", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Script = "" })}, wantErr: "exactly one of script or scriptFile"},
+		{name: "both scripts", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.ScriptFile = "/opt/raid.sh" })}, wantErr: "exactly one of script or scriptFile"},
+		{name: "malformed checksum", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.SHA256 = "abc" })}, wantErr: "invalid npd.customPlugins[0].sha256"},
+		{name: "missing condition", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Condition = "" })}, wantErr: "invalid npd.customPlugins[0].condition"},
+		{name: "temporary without condition", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Condition, p.Temporary = "", true })}},
+		{name: "built-in drift name", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Name = "config-drift" })}, wantErr: "duplicate npd.customPlugins[0].name"},
+		{name: "lower case reason", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Reason = "degraded" })}, wantErr: "invalid npd.customPlugins[0].reason"},
+		{name: "bad interval", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Interval = "often" })}, wantErr: "invalid npd.customPlugins[0].interval"},
+		{name: "negative timeout", plugins: []NPDPluginConfig{with(func(p *NPDPluginConfig) { p.Timeout = "-1s" })}, wantErr: "invalid npd.customPlugins[0].timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Npd: NPDConfig{CustomPlugins: tt.plugins}}
+			err := cfg.validateNPDPlugins()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNPDPlugins() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNPDPlugins() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNPDMetrics(t *testing.T) {
+	arc := &ArcConfig{Enabled: true}
+	tests := []struct {
+		name    string
+		arc     *ArcConfig
+		metrics NPDMetricsConfig
+		wantErr string
+	}{
+		{name: "disabled"},
+		{name: "azure monitor with arc", arc: arc, metrics: NPDMetricsConfig{Sink: "azure-monitor", Interval: "5m"}},
+		{name: "azure monitor with resource", metrics: NPDMetricsConfig{
+			Sink: "azure-monitor", Region: "westus2",
+			ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm1",
+		}},
+		{name: "azure monitor without resource", metrics: NPDMetricsConfig{Sink: "azure-monitor"}, wantErr: "required for the azure-monitor sink"},
+		{name: "malformed resource id", arc: arc, metrics: NPDMetricsConfig{Sink: "azure-monitor", ResourceID: "vm1"}, wantErr: "invalid npd.metrics.resourceId"},
+		{name: "remote write", metrics: NPDMetricsConfig{Sink: "prometheus-remote-write", RemoteWriteURL: "https://prometheus.example.com/api/v1/write"}},
+		{name: "remote write without url", metrics: NPDMetricsConfig{Sink: "prometheus-remote-write"}, wantErr: "invalid npd.metrics.remoteWriteUrl"},
+		{name: "unknown sink", metrics: NPDMetricsConfig{Sink: "statsd"}, wantErr: "invalid npd.metrics.sink"},
+		{name: "interval too short", arc: arc, metrics: NPDMetricsConfig{Sink: "azure-monitor", Interval: "1s"}, wantErr: "invalid npd.metrics.interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{Arc: tt.arc}, Npd: NPDConfig{Metrics: tt.metrics}}
+			err := cfg.validateNPDMetrics()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNPDMetrics() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNPDMetrics() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFluentBit(t *testing.T) {
+	workspace := "00000000-0000-0000-0000-000000000001"
+	tests := []struct {
+		name    string
+		fb      FluentBitConfig
+		wantErr string
+	}{
+		{name: "disabled", fb: FluentBitConfig{Destination: "elsewhere"}},
+		{name: "log analytics", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: workspace, SharedKeyFile: "/etc/aks-flex-node/la-key", Version: "3.2.2"}},
+		{name: "syslog", fb: FluentBitConfig{Enabled: true, Destination: "syslog", SyslogHost: "logs.example.com", SyslogMode: "tls", Sources: []string{"kubelet"}}},
+		{name: "missing destination", fb: FluentBitConfig{Enabled: true}, wantErr: "invalid fluentBit.destination"},
+		{name: "bad version", fb: FluentBitConfig{Enabled: true, Destination: "syslog", SyslogHost: "h", Version: "latest; rm -rf /"}, wantErr: "invalid fluentBit.version"},
+		{name: "unknown source", fb: FluentBitConfig{Enabled: true, Destination: "syslog", SyslogHost: "h", Sources: []string{"audit"}}, wantErr: "invalid fluentBit.sources"},
+		{name: "bad workspace", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: "ws", SharedKeyFile: "/k"}, wantErr: "invalid fluentBit.workspaceId"},
+		{name: "missing key file", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: workspace}, wantErr: "sharedKeyFile is required"},
+		{name: "key reference", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: workspace, SharedKey: "keyvault:https://myvault.vault.azure.net/secrets/la-key"}},
+		{name: "key file and reference", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: workspace, SharedKeyFile: "/k", SharedKey: "env:LA_KEY"}, wantErr: "cannot both be set"},
+		{name: "invalid key reference", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: workspace, SharedKey: "env: LA_KEY"}, wantErr: "invalid fluentBit.sharedKey"},
+		{name: "bad log type", fb: FluentBitConfig{Enabled: true, Destination: "log-analytics", WorkspaceID: workspace, SharedKeyFile: "/k", LogType: "my-logs"}, wantErr: "invalid fluentBit.logType"},
+		{name: "missing syslog host", fb: FluentBitConfig{Enabled: true, Destination: "syslog"}, wantErr: "invalid fluentBit.syslogHost"},
+		{name: "bad syslog mode", fb: FluentBitConfig{Enabled: true, Destination: "syslog", SyslogHost: "h", SyslogMode: "quic"}, wantErr: "invalid fluentBit.syslogMode"},
+		{name: "bad syslog port", fb: FluentBitConfig{Enabled: true, Destination: "syslog", SyslogHost: "h", SyslogPort: 70000}, wantErr: "invalid fluentBit.syslogPort"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{FluentBit: tt.fb}
+			err := cfg.validateFluentBit()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateFluentBit() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateFluentBit() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSSH(t *testing.T) {
+	keys := &SSHBreakGlassConfig{AuthorizedKeysFile: "/etc/aks-flex-node/breakglass.pub"}
+	tests := []struct {
+		name    string
+		ssh     SSHConfig
+		noArc   bool
+		wantErr string
+	}{
+		{name: "disabled", ssh: SSHConfig{Profile: "paranoid"}},
+		{name: "baseline", ssh: SSHConfig{Enabled: true, AllowUsers: []string{"azureuser", "ops_1"}, Banner: "Authorized use only"}},
+		{name: "break glass", ssh: SSHConfig{Enabled: true, Profile: "strict", BreakGlass: keys}},
+		{name: "unknown profile", ssh: SSHConfig{Enabled: true, Profile: "paranoid"}, wantErr: "invalid ssh.profile"},
+		{name: "bad allowed user", ssh: SSHConfig{Enabled: true, AllowUsers: []string{"ops *"}}, wantErr: "invalid ssh.allowUsers"},
+		{name: "break glass without arc", ssh: SSHConfig{Enabled: true, BreakGlass: keys}, noArc: true, wantErr: "requires azure.arc.enabled"},
+		{name: "root break glass user", ssh: SSHConfig{Enabled: true, BreakGlass: &SSHBreakGlassConfig{User: "root", AuthorizedKeysFile: "/k"}}, wantErr: "invalid ssh.breakGlass.user"},
+		{name: "relative keys file", ssh: SSHConfig{Enabled: true, BreakGlass: &SSHBreakGlassConfig{AuthorizedKeysFile: "keys.pub"}}, wantErr: "invalid ssh.breakGlass.authorizedKeysFile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{SSH: tt.ssh, Azure: AzureConfig{Arc: &ArcConfig{Enabled: !tt.noArc}}}
+			err := cfg.validateSSH()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSSH() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSSH() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSRIOV(t *testing.T) {
+	nic := SRIOVNICConfig{Interface: "ens1f0", NumVFs: 8}
+	tests := []struct {
+		name    string
+		sriov   SRIOVConfig
+		wantErr string
+	}{
+		{name: "disabled", sriov: SRIOVConfig{NICs: []SRIOVNICConfig{{Interface: "bad name"}}}},
+		{
+			name: "dpdk layout",
+			sriov: SRIOVConfig{Enabled: true, Hugepages2Mi: 1024, NICs: []SRIOVNICConfig{
+				nic,
+				{Interface: "ens1f1", NumVFs: 4, Driver: "vfio-pci", ResourceName: "dpdk_ens1f1"},
+			}},
+		},
+		{name: "no nics", sriov: SRIOVConfig{Enabled: true}, wantErr: "at least one NIC"},
+		{name: "bad interface", sriov: SRIOVConfig{Enabled: true, NICs: []SRIOVNICConfig{{Interface: "ens1f0; reboot", NumVFs: 1}}}, wantErr: "invalid sriov.nics interface"},
+		{name: "duplicate interface", sriov: SRIOVConfig{Enabled: true, NICs: []SRIOVNICConfig{nic, nic}}, wantErr: "duplicate sriov.nics interface"},
+		{name: "no vfs", sriov: SRIOVConfig{Enabled: true, NICs: []SRIOVNICConfig{{Interface: "ens1f0"}}}, wantErr: "invalid sriov.nics numVfs"},
+		{name: "bad driver", sriov: SRIOVConfig{Enabled: true, NICs: []SRIOVNICConfig{{Interface: "ens1f0", NumVFs: 1, Driver: "../vfio"}}}, wantErr: "invalid sriov.nics driver"},
+		{
+			name: "duplicate resource",
+			sriov: SRIOVConfig{Enabled: true, NICs: []SRIOVNICConfig{
+				{Interface: "ens1f0", NumVFs: 1, ResourceName: "sriov_net"},
+				{Interface: "ens1f1", NumVFs: 1, ResourceName: "sriov_net"},
+			}},
+			wantErr: "duplicate sriov.nics resourceName",
+		},
+		{name: "negative hugepages", sriov: SRIOVConfig{Enabled: true, NICs: []SRIOVNICConfig{nic}, Hugepages2Mi: -1}, wantErr: "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{SRIOV: tt.sriov}
+			err := cfg.validateSRIOV()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSRIOV() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSRIOV() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetHugepages2Mi(t *testing.T) {
+	cfg := &Config{Node: NodeConfig{Hugepages: HugepagesConfig{Pages2Mi: 512}}, SRIOV: SRIOVConfig{Hugepages2Mi: 2048}}
+	if got := cfg.GetHugepages2Mi(); got != 512 {
+		t.Errorf("GetHugepages2Mi() with sriov disabled = %d, want 512", got)
+	}
+	cfg.SRIOV.Enabled = true
+	if got := cfg.GetHugepages2Mi(); got != 2048 {
+		t.Errorf("GetHugepages2Mi() with sriov enabled = %d, want 2048", got)
+	}
+}
+
+func TestValidateNPDGPUHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		npd     NPDConfig
+		wantErr string
+	}{
+		{name: "enabled", npd: NPDConfig{GPUHealth: NPDGPUHealthConfig{Enabled: true, Interval: "30s", MaxTemperature: 85}}},
+		{name: "bad interval", npd: NPDConfig{GPUHealth: NPDGPUHealthConfig{Enabled: true, Interval: "soon"}}, wantErr: "invalid npd.gpuHealth.interval"},
+		{name: "bad temperature", npd: NPDConfig{GPUHealth: NPDGPUHealthConfig{Enabled: true, MaxTemperature: 400}}, wantErr: "invalid npd.gpuHealth.maxTemperature"},
+		{
+			name: "custom plugin reuses a GPU plugin name",
+			npd: NPDConfig{
+				GPUHealth:     NPDGPUHealthConfig{Enabled: true},
+				CustomPlugins: []NPDPluginConfig{{Name: "gpu-ecc", Script: "exit 0", Condition: "ECC", Reason: "Bad"}},
+			},
+			wantErr: "gpu-ecc is already in use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Npd: tt.npd}
+			err := cfg.validateNPDPlugins()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNPDPlugins() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNPDPlugins() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateContainerRuntime(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "default", cfg: Config{}},
+		{name: "cri-o with default version", cfg: Config{ContainerRuntime: "cri-o", Kubernetes: KubernetesConfig{Version: "1.32.7"}}},
+		{name: "cri-o matching version", cfg: Config{ContainerRuntime: "cri-o", CRIO: CRIOConfig{Version: "1.32.3"}, Kubernetes: KubernetesConfig{Version: "1.32.7"}}},
+		{name: "unknown runtime", cfg: Config{ContainerRuntime: "docker"}, wantErr: "invalid containerRuntime: docker"},
+		{name: "bad version", cfg: Config{ContainerRuntime: "cri-o", CRIO: CRIOConfig{Version: "main"}}, wantErr: "invalid crio.version"},
+		{
+			name:    "version skew",
+			cfg:     Config{ContainerRuntime: "cri-o", CRIO: CRIOConfig{Version: "1.30.0"}, Kubernetes: KubernetesConfig{Version: "1.32.7"}},
+			wantErr: "CRI-O 1.30 only supports Kubernetes 1.30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validateContainerRuntime()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateContainerRuntime() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateContainerRuntime() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetCRIOVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "configured", cfg: Config{CRIO: CRIOConfig{Version: "1.31.4"}, Kubernetes: KubernetesConfig{Version: "1.32.7"}}, want: "1.31.4"},
+		{name: "follows kubernetes minor", cfg: Config{Kubernetes: KubernetesConfig{Version: "v1.30.2"}}, want: "1.30.0"},
+		{name: "no kubernetes version", cfg: Config{}, want: "1.32.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.GetCRIOVersion(); got != tt.want {
+				t.Errorf("GetCRIOVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateImagePrePull(t *testing.T) {
+	tests := []struct {
+		name    string
+		prePull ImagePrePullConfig
+		wantErr string
+	}{
+		{name: "empty", prePull: ImagePrePullConfig{}},
+		{name: "valid", prePull: ImagePrePullConfig{Images: []string{"mcr.microsoft.com/oss/kubernetes/kube-proxy:v1.32.7", "myacr.azurecr.io/csi-node:1.0"}, Timeout: "10m", Parallelism: 5}},
+		{name: "blank image", prePull: ImagePrePullConfig{Images: []string{""}}, wantErr: "invalid imagePrePull.images entry"},
+		{name: "image with space", prePull: ImagePrePullConfig{Images: []string{"busybox latest"}}, wantErr: "invalid imagePrePull.images entry"},
+		{name: "duplicate image", prePull: ImagePrePullConfig{Images: []string{"busybox", "busybox"}}, wantErr: "duplicate imagePrePull.images entry: busybox"},
+		{name: "bad timeout", prePull: ImagePrePullConfig{Timeout: "forever"}, wantErr: "invalid imagePrePull.timeout"},
+		{name: "zero timeout", prePull: ImagePrePullConfig{Timeout: "0s"}, wantErr: "invalid imagePrePull.timeout"},
+		{name: "bad parallelism", prePull: ImagePrePullConfig{Parallelism: 50}, wantErr: "invalid imagePrePull.parallelism"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{ImagePrePull: tt.prePull}
+			err := cfg.validateImagePrePull()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateImagePrePull() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateImagePrePull() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDownloads(t *testing.T) {
+	const url = "https://github.com/opencontainers/runc/releases/download/v1.3.0/runc.amd64"
+	const digest = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name      string
+		downloads DownloadsConfig
+		wantErr   string
+	}{
+		{name: "empty", downloads: DownloadsConfig{}},
+		{name: "valid", downloads: DownloadsConfig{
+			Cache:     DownloadCacheConfig{Directory: "/data/artifacts", SeedDirectories: []string{"/mnt/usb/artifacts"}},
+			Checksums: []ArtifactChecksum{{URL: url, SHA256: strings.ToUpper(digest)}},
+		}},
+		{name: "peers", downloads: DownloadsConfig{Peers: DownloadPeersConfig{ServeAddress: ":8470", URLs: []string{"http://10.0.0.5:8470"}}}},
+		{name: "serve without cache", downloads: DownloadsConfig{Cache: DownloadCacheConfig{Disabled: true}, Peers: DownloadPeersConfig{ServeAddress: ":8470"}}, wantErr: "requires the download cache"},
+		{name: "bad serve address", downloads: DownloadsConfig{Peers: DownloadPeersConfig{ServeAddress: "8470"}}, wantErr: "invalid downloads.peers.serveAddress"},
+		{name: "bad peer URL", downloads: DownloadsConfig{Peers: DownloadPeersConfig{URLs: []string{"10.0.0.5:8470"}}}, wantErr: "invalid downloads.peers.urls entry"},
+		{name: "rate limits", downloads: DownloadsConfig{RateLimit: DownloadRateLimitConfig{Global: "10Mi", PerArtifact: "2M"}}},
+		{name: "bad rate limit", downloads: DownloadsConfig{RateLimit: DownloadRateLimitConfig{Global: "fast"}}, wantErr: "invalid downloads.rateLimit.global"},
+		{name: "tiny rate limit", downloads: DownloadsConfig{RateLimit: DownloadRateLimitConfig{PerArtifact: "100"}}, wantErr: "invalid downloads.rateLimit.perArtifact"},
+		{name: "relative directory", downloads: DownloadsConfig{Cache: DownloadCacheConfig{Directory: "artifacts"}}, wantErr: "invalid downloads.cache.directory"},
+		{name: "relative seed", downloads: DownloadsConfig{Cache: DownloadCacheConfig{SeedDirectories: []string{"usb"}}}, wantErr: "invalid downloads.cache.seedDirectories entry"},
+		{name: "checksum without URL", downloads: DownloadsConfig{Checksums: []ArtifactChecksum{{SHA256: digest}}}, wantErr: "invalid downloads.checksums url"},
+		{name: "short checksum", downloads: DownloadsConfig{Checksums: []ArtifactChecksum{{URL: url, SHA256: "abc"}}}, wantErr: "invalid downloads.checksums sha256"},
+		{name: "duplicate URL", downloads: DownloadsConfig{Checksums: []ArtifactChecksum{{URL: url, SHA256: digest}, {URL: url, SHA256: digest}}}, wantErr: "duplicate downloads.checksums url"},
+		{name: "manifest", downloads: DownloadsConfig{
+			Checksums: []ArtifactChecksum{{URL: url, SHA256: digest}},
+			Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{
+				{OS: "linux", Arch: "amd64", URL: url, SHA256: digest},
+				{OS: "linux", Arch: "arm64", URL: "https://mirror.contoso.com/runc/1.3.0/runc.arm64"},
+				{OS: "windows", Arch: "amd64", URL: "https://mirror.contoso.com/runc/1.3.0/runc.exe"},
+			}}},
+		}},
+		{name: "unknown component", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "docker", Version: "1.0"}}}, wantErr: "invalid downloads.artifacts component"},
+		{name: "manifest without version", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "runc"}}}, wantErr: "version is required"},
+		{name: "duplicate version", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{
+			{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{{OS: "linux", Arch: "amd64", URL: url}}},
+			{Component: "runc", Version: "v1.3.0", Platforms: []PlatformArtifact{{OS: "linux", Arch: "arm64", URL: url}}},
+		}}, wantErr: "duplicate downloads.artifacts entry"},
+		{name: "no platforms", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0"}}}, wantErr: "no platforms"},
+		{name: "unknown platform", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{{OS: "darwin", Arch: "amd64", URL: url}}}}}, wantErr: "invalid downloads.artifacts platform"},
+		{name: "duplicate platform", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{
+			{OS: "linux", Arch: "amd64", URL: url}, {OS: "linux", Arch: "amd64", URL: url},
+		}}}}, wantErr: "duplicate downloads.artifacts platform"},
+		{name: "artifact without URL", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{{OS: "linux", Arch: "amd64"}}}}}, wantErr: "invalid downloads.artifacts url"},
+		{name: "bad artifact checksum", downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{{OS: "linux", Arch: "amd64", URL: url, SHA256: "abc"}}}}}, wantErr: "invalid downloads.artifacts sha256"},
+		{name: "conflicting checksum", downloads: DownloadsConfig{
+			Checksums: []ArtifactChecksum{{URL: url, SHA256: digest}},
+			Artifacts: []ComponentArtifacts{{Component: "runc", Version: "1.3.0", Platforms: []PlatformArtifact{{OS: "linux", Arch: "amd64", URL: url, SHA256: strings.Repeat("f", 64)}}}},
+		}, wantErr: "conflicting sha256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Downloads: tt.downloads}
+			err := cfg.validateDownloads()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateDownloads() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateDownloads() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetArtifactURL(t *testing.T) {
+	const upstream = "https://github.com/containerd/containerd/releases/download/v1.7.20/containerd-1.7.20-linux-amd64.tar.gz"
+	const mirror = "https://mirror.contoso.com/containerd/1.7.20/containerd-linux-amd64.tar.gz"
+	const digest = "0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"
+	cfg := &Config{Downloads: DownloadsConfig{Artifacts: []ComponentArtifacts{{
+		Component: "containerd",
+		Version:   "v1.7.20",
+		Platforms: []PlatformArtifact{{OS: runtime.GOOS, Arch: "amd64", URL: mirror, SHA256: digest}},
+	}}}}
+
+	if got := cfg.GetArtifactURL("containerd", "1.7.20", "amd64", upstream); got != mirror {
+		t.Errorf("GetArtifactURL() = %s, want the manifest URL", got)
+	}
+	for _, tt := range []struct{ component, version, arch string }{
+		{"containerd", "1.7.20", "arm64"},
+		{"containerd", "2.0.0", "amd64"},
+		{"runc", "1.7.20", "amd64"},
+	} {
+		if got := cfg.GetArtifactURL(tt.component, tt.version, tt.arch, upstream); got != upstream {
+			t.Errorf("GetArtifactURL(%s, %s, %s) = %s, want upstream", tt.component, tt.version, tt.arch, got)
+		}
+	}
+	if got := cfg.GetArtifactChecksum(mirror); got != strings.ToLower(digest) {
+		t.Errorf("GetArtifactChecksum() = %s, want the manifest digest", got)
+	}
+}
+
+func TestValidateAzureTimeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeouts      *AzureTimeoutsConfig
+		wantErr       string
+		wantTry       time.Duration
+		wantOperation time.Duration
+	}{
+		{name: "defaults", wantTry: time.Minute, wantOperation: 5 * time.Minute},
+		{name: "custom", timeouts: &AzureTimeoutsConfig{PerTry: "20s", Operation: "2m"}, wantTry: 20 * time.Second, wantOperation: 2 * time.Minute},
+		{name: "bad per try", timeouts: &AzureTimeoutsConfig{PerTry: "soon"}, wantErr: "invalid azure.timeouts.perTry"},
+		{name: "zero operation", timeouts: &AzureTimeoutsConfig{Operation: "0s"}, wantErr: "invalid azure.timeouts.operation"},
+		{name: "per try exceeds operation", timeouts: &AzureTimeoutsConfig{PerTry: "2m", Operation: "1m"}, wantErr: "must not exceed operation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{Timeouts: tt.timeouts}}
+			err := cfg.validateAzureTimeouts()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("validateAzureTimeouts() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateAzureTimeouts() unexpected error: %v", err)
+			}
+			if got := cfg.GetAzureTryTimeout(); got != tt.wantTry {
+				t.Errorf("GetAzureTryTimeout() = %v, want %v", got, tt.wantTry)
+			}
+			if got := cfg.GetAzureOperationTimeout(); got != tt.wantOperation {
+				t.Errorf("GetAzureOperationTimeout() = %v, want %v", got, tt.wantOperation)
+			}
+		})
+	}
+}
+
+func TestValidateDiskPressure(t *testing.T) {
+	tests := []struct {
+		name    string
+		kubelet KubeletConfig
+		wantErr string
+	}{
+		{name: "defaults", kubelet: KubeletConfig{}},
+		{
+			name: "consistent policy",
+			kubelet: KubeletConfig{
+				ImageGCHighThreshold:    70,
+				ImageGCLowThreshold:     50,
+				EvictionHard:            map[string]string{"memory.available": "100Mi", "nodefs.available": "10%"},
+				EvictionSoft:            map[string]string{"memory.available": "500Mi", "nodefs.available": "15%"},
+				EvictionSoftGracePeriod: map[string]string{"memory.available": "30s", "nodefs.available": "2m"},
+				ImageMinimumGCAge:       "5m",
+			},
+		},
+		{name: "only high threshold", kubelet: KubeletConfig{ImageGCHighThreshold: 70}, wantErr: "must be set together"},
+		{name: "low above high", kubelet: KubeletConfig{ImageGCHighThreshold: 70, ImageGCLowThreshold: 80}, wantErr: "must be lower than imageGCHighThreshold"},
+		{name: "threshold above 100", kubelet: KubeletConfig{ImageGCHighThreshold: 120, ImageGCLowThreshold: 80}, wantErr: "between 0 and 100"},
+		{name: "unknown signal", kubelet: KubeletConfig{EvictionHard: map[string]string{"disk.available": "10%"}}, wantErr: "invalid evictionHard signal: disk.available"},
+		{name: "bad threshold", kubelet: KubeletConfig{EvictionHard: map[string]string{"nodefs.available": "lots"}}, wantErr: "invalid evictionHard[nodefs.available]"},
+		{name: "soft without grace period", kubelet: KubeletConfig{EvictionSoft: map[string]string{"nodefs.available": "15%"}}, wantErr: "requires a grace period"},
+		{name: "grace period without soft", kubelet: KubeletConfig{EvictionSoftGracePeriod: map[string]string{"nodefs.available": "1m"}}, wantErr: "has no matching evictionSoft threshold"},
+		{
+			name: "soft below hard",
+			kubelet: KubeletConfig{
+				EvictionHard:            map[string]string{"memory.available": "1Gi"},
+				EvictionSoft:            map[string]string{"memory.available": "500Mi"},
+				EvictionSoftGracePeriod: map[string]string{"memory.available": "30s"},
+			},
+			wantErr: "otherwise it never fires",
+		},
+		{
+			name:    "image GC after eviction",
+			kubelet: KubeletConfig{ImageGCHighThreshold: 92, ImageGCLowThreshold: 80, EvictionHard: map[string]string{"imagefs.available": "10%"}},
+			wantErr: "must be below the disk usage at which imagefs.available<10% triggers eviction",
+		},
+		{name: "bad minimum GC age", kubelet: KubeletConfig{ImageMinimumGCAge: "a while"}, wantErr: "invalid node.kubelet.imageMinimumGCAge"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Node: NodeConfig{Kubelet: tt.kubelet}}
+			err := cfg.validateDiskPressure()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateDiskPressure() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateDiskPressure() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cfg := &Config{
+		Azure: AzureConfig{
+			SubscriptionID:   "sub",
+			ServicePrincipal: &ServicePrincipalConfig{ClientID: "client"},
+			TargetCluster:    &TargetClusterConfig{Name: "cluster"},
+		},
+		Node:              NodeConfig{Labels: map[string]string{"zone": "edge"}},
+		isMIExplicitlySet: true,
+		path:              "/etc/aks-flex-node/config.json",
+	}
+
+	snapshot := cfg.Snapshot()
+	if snapshot.Azure.ServicePrincipal.ClientID != "client" || snapshot.Node.Labels["zone"] != "edge" {
+		t.Fatalf("Snapshot() = %+v, want the same values", snapshot)
+	}
+	if !snapshot.isMIExplicitlySet || snapshot.GetConfigPath() != cfg.GetConfigPath() {
+		t.Error("Snapshot() should keep the internal fields")
+	}
+
+	// Changes to the original must not reach the snapshot
+	cfg.Azure.ServicePrincipal.ClientID = "changed"
+	cfg.Node.Labels["zone"] = "changed"
+	cfg.Azure.TargetCluster.Name = "changed"
+	if snapshot.Azure.ServicePrincipal.ClientID != "client" || snapshot.Node.Labels["zone"] != "edge" ||
+		snapshot.Azure.TargetCluster.Name != "cluster" {
+		t.Errorf("Snapshot() shares state with the original: %+v", snapshot)
+	}
+
+	var nilConfig *Config
+	if nilConfig.Snapshot() != nil {
+		t.Error("Snapshot() of nil should be nil")
+	}
+}
+
+func BenchmarkLoadConfig(b *testing.B) {
+	configFile := filepath.Join(b.TempDir(), "config.json")
+	configJSON := `{
+		"azure": {
+			"subscriptionId": "12345678-1234-1234-1234-123456789012",
+			"tenantId": "12345678-1234-1234-1234-123456789012",
+			"bootstrapToken": {"token": "abcdef.0123456789abcdef"},
+			"targetCluster": {
+				"resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				"location": "eastus"
+			}
+		},
+		"node": {
+			"kubelet": {
+				"serverURL": "https://test-cluster-abc123.hcp.eastus.azmk8s.io:443",
+				"caCertData": "LS0tLS1CRUdJTi1DRVJUSUZJQ0FURS0tLS0tCk1JSUREekNDQWZlZ0F3SUJBZ0lSQU1kbzBZa0R"
+			}
+		}
+	}`
+	if err := os.WriteFile(configFile, []byte(configJSON), 0o644); err != nil {
+		b.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	for b.Loop() {
+		if _, err := LoadConfig(configFile); err != nil {
+			b.Fatalf("LoadConfig() unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	cfg := &Config{
+		Azure: AzureConfig{
+			SubscriptionID:   "sub",
+			ServicePrincipal: &ServicePrincipalConfig{ClientID: "client"},
+			TargetCluster:    &TargetClusterConfig{Name: "cluster"},
+		},
+		Node: NodeConfig{Labels: map[string]string{"zone": "edge"}},
+	}
+	for b.Loop() {
+		cfg.Snapshot()
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Keys of the service principal credential in a configuration document
+const (
+	clientSecretKey          = "clientSecret"
+	clientCertificateFileKey = "clientCertificateFile"
+)
+
+// SetServicePrincipalCredential returns the configuration document read from path with the service principal's
+// client secret or certificate file replaced; the other one is removed. JSON documents are encoded again with
+// sorted keys, YAML documents keep their layout and comments. A NodeSpec document is edited under its spec.
+func SetServicePrincipalCredential(data []byte, path, secret, certificateFile string) ([]byte, error) {
+	if (secret == "") == (certificateFile == "") {
+		return nil, fmt.Errorf("exactly one of a client secret and a certificate file is required")
+	}
+	if configType(path) == "yaml" {
+		return setYAMLCredential(data, secret, certificateFile)
+	}
+	return setJSONCredential(data, secret, certificateFile)
+}
+
+func setJSONCredential(data []byte, secret, certificateFile string) ([]byte, error) {
+	var doc map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	root := doc
+	if _, ok := lookupKey(doc, "kind"); ok {
+		root, _ = lookupMap(doc, "spec")
+	}
+	azure, _ := lookupMap(root, "azure")
+	sp, ok := lookupMap(azure, "servicePrincipal")
+	if !ok {
+		return nil, fmt.Errorf("configuration has no azure.servicePrincipal")
+	}
+	for _, field := range []struct{ key, value string }{{clientSecretKey, secret}, {clientCertificateFileKey, certificateFile}} {
+		key, found := lookupKey(sp, field.key)
+		if !found {
+			key = field.key
+		}
+		if field.value == "" {
+			delete(sp, key)
+		} else {
+			sp[key] = field.value
+		}
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// lookupKey finds a key case-insensitively, as the configuration loader does
+func lookupKey(m map[string]any, key string) (string, bool) {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func lookupMap(m map[string]any, key string) (map[string]any, bool) {
+	k, ok := lookupKey(m, key)
+	if !ok {
+		return nil, false
+	}
+	child, ok := m[k].(map[string]any)
+	return child, ok
+}
+
+func setYAMLCredential(data []byte, secret, certificateFile string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("configuration is empty")
+	}
+
+	root := doc.Content[0]
+	if _, kind := yamlValue(root, "kind"); kind != nil {
+		_, root = yamlValue(root, "spec")
+	}
+	_, azure := yamlValue(root, "azure")
+	_, sp := yamlValue(azure, "servicePrincipal")
+	if sp == nil || sp.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("configuration has no azure.servicePrincipal")
+	}
+	for _, field := range []struct{ key, value string }{{clientSecretKey, secret}, {clientCertificateFileKey, certificateFile}} {
+		index, value := yamlValue(sp, field.key)
+		switch {
+		case field.value == "" && value != nil:
+			sp.Content = append(sp.Content[:index], sp.Content[index+2:]...)
+		case field.value != "" && value != nil:
+			value.Kind, value.Tag, value.Value, value.Style = yaml.ScalarNode, "!!str", field.value, 0
+		case field.value != "":
+			sp.Content = append(sp.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.key},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.value})
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// yamlValue returns the index of the key node and the value node of a mapping key, found case-insensitively
+func yamlValue(mapping *yaml.Node, key string) (int, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return 0, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			return i, mapping.Content[i+1]
+		}
+	}
+	return 0, nil
+}
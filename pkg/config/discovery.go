@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiscoveredClusterPath records what was read from ARM about the target cluster, so the configuration only
+// needs azure.targetCluster.resourceId and later runs do not query ARM again
+const DiscoveredClusterPath = "/var/lib/aks-flex-node/cluster-discovery.json"
+
+// discoveredClusterPath is DiscoveredClusterPath, replaced in tests
+var discoveredClusterPath = DiscoveredClusterPath
+
+// DiscoveredCluster is what ARM reports about the target cluster
+type DiscoveredCluster struct {
+	ResourceID        string `json:"resourceId"`
+	Location          string `json:"location"`
+	NodeResourceGroup string `json:"nodeResourceGroup"`
+}
+
+// RecordDiscoveredCluster records d for later runs
+func RecordDiscoveredCluster(d DiscoveredCluster) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(discoveredClusterPath), 0o755); err != nil {
+		return err
+	}
+	tmp := discoveredClusterPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, discoveredClusterPath)
+}
+
+// recordedDiscoveredCluster returns the cluster recorded by RecordDiscoveredCluster, or nil when none is
+func recordedDiscoveredCluster() (*DiscoveredCluster, error) {
+	data, err := os.ReadFile(discoveredClusterPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &DiscoveredCluster{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", discoveredClusterPath, err)
+	}
+	return d, nil
+}
+
+// NeedsClusterDiscovery returns true when the target cluster's location and node resource group have not been
+// read from ARM yet. Bootstrap token setups have no Azure credential, so they are never discovered.
+func (cfg *Config) NeedsClusterDiscovery() bool {
+	return !cfg.clusterDiscovered && cfg.Azure.TargetCluster != nil && !cfg.IsKubernetesCredentialConfigured()
+}
+
+// ApplyDiscoveredCluster fills in the target cluster's location, unless configured, and node resource group from d,
+// and expands the role assignment scopes again with them. A record of another cluster, e.g. from before
+// `aks-flex-node switch-cluster`, is ignored.
+func (cfg *Config) ApplyDiscoveredCluster(d DiscoveredCluster) error {
+	cluster := cfg.Azure.TargetCluster
+	if cluster == nil || !strings.EqualFold(d.ResourceID, cluster.ResourceID) {
+		return nil
+	}
+	if cluster.Location == "" {
+		cluster.Location = d.Location
+	}
+	if d.NodeResourceGroup != "" {
+		cluster.NodeResourceGroup = d.NodeResourceGroup
+	}
+	cfg.clusterDiscovered = true
+	return cfg.resolveRoleAssignmentScopes()
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// evictionSignals are the kubelet eviction signals that may be used in eviction thresholds
+var evictionSignals = []string{
+	"memory.available",
+	"nodefs.available",
+	"nodefs.inodesFree",
+	"imagefs.available",
+	"imagefs.inodesFree",
+	"containerfs.available",
+	"containerfs.inodesFree",
+	"pid.available",
+}
+
+// validateDiskPressure validates the configured image garbage collection and eviction settings
+func (c *Config) validateDiskPressure() error {
+	kubelet := c.Node.Kubelet
+	if (kubelet.ImageGCHighThreshold == 0) != (kubelet.ImageGCLowThreshold == 0) {
+		return fmt.Errorf("node.kubelet.imageGCHighThreshold and node.kubelet.imageGCLowThreshold must be set together")
+	}
+	if kubelet.ImageMinimumGCAge != "" {
+		if age, err := time.ParseDuration(kubelet.ImageMinimumGCAge); err != nil || age < 0 {
+			return fmt.Errorf("invalid node.kubelet.imageMinimumGCAge: %s. Expected a duration such as 2m", kubelet.ImageMinimumGCAge)
+		}
+	}
+	return ValidateDiskPressurePolicy(kubelet.ImageGCHighThreshold, kubelet.ImageGCLowThreshold,
+		kubelet.EvictionHard, kubelet.EvictionSoft, kubelet.EvictionSoftGracePeriod)
+}
+
+// ValidateDiskPressurePolicy checks that image garbage collection and eviction thresholds work together:
+// every soft threshold has a grace period, soft thresholds fire before hard ones, and image GC starts
+// before the disk fills up enough to trigger eviction. Zero GC thresholds are not checked.
+func ValidateDiskPressurePolicy(gcHigh, gcLow int, hard, soft, softGracePeriod map[string]string) error {
+	if gcHigh < 0 || gcHigh > 100 || gcLow < 0 || gcLow > 100 {
+		return fmt.Errorf("image GC thresholds must be between 0 and 100 percent of disk usage, got high %d and low %d", gcHigh, gcLow)
+	}
+	if gcHigh > 0 && gcLow >= gcHigh {
+		return fmt.Errorf("imageGCLowThreshold (%d) must be lower than imageGCHighThreshold (%d)", gcLow, gcHigh)
+	}
+
+	for name, thresholds := range map[string]map[string]string{"evictionHard": hard, "evictionSoft": soft} {
+		for _, signal := range sortedKeys(thresholds) {
+			if !slices.Contains(evictionSignals, signal) {
+				return fmt.Errorf("invalid %s signal: %s. Valid signals are: %s", name, signal, strings.Join(evictionSignals, ", "))
+			}
+			if _, _, err := parseThreshold(thresholds[signal]); err != nil {
+				return fmt.Errorf("invalid %s[%s]: %w", name, signal, err)
+			}
+		}
+	}
+
+	for _, signal := range sortedKeys(soft) {
+		grace, ok := softGracePeriod[signal]
+		if !ok {
+			return fmt.Errorf("evictionSoft[%s] requires a grace period in evictionSoftGracePeriod", signal)
+		}
+		if d, err := time.ParseDuration(grace); err != nil || d <= 0 {
+			return fmt.Errorf("invalid evictionSoftGracePeriod[%s]: %s. Expected a positive duration such as 2m", signal, grace)
+		}
+		if hardValue, ok := hard[signal]; ok && !firesBefore(soft[signal], hardValue) {
+			return fmt.Errorf("evictionSoft[%s] (%s) must keep more %s available than evictionHard (%s), otherwise it never fires",
+				signal, soft[signal], signal, hardValue)
+		}
+	}
+	for _, signal := range sortedKeys(softGracePeriod) {
+		if _, ok := soft[signal]; !ok {
+			return fmt.Errorf("evictionSoftGracePeriod[%s] has no matching evictionSoft threshold", signal)
+		}
+	}
+
+	// Image GC must free space before kubelet starts evicting pods for the same disk
+	if gcHigh > 0 {
+		for _, thresholds := range []map[string]string{hard, soft} {
+			for _, signal := range []string{"imagefs.available", "nodefs.available"} {
+				percent, isPercent, _ := parseThreshold(thresholds[signal])
+				if thresholds[signal] == "" || !isPercent {
+					continue
+				}
+				if float64(gcHigh) >= 100-percent {
+					return fmt.Errorf("imageGCHighThreshold (%d%%) must be below the disk usage at which %s<%s triggers eviction (%g%%)",
+						gcHigh, signal, thresholds[signal], 100-percent)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseThreshold parses an eviction threshold, either a percentage such as "10%" or a quantity such as "2Gi".
+// For percentages it returns the percentage and true.
+func parseThreshold(value string) (float64, bool, error) {
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return 0, false, fmt.Errorf("%q is not a percentage between 0 and 100", value)
+		}
+		return p, true, nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil || q.Sign() <= 0 {
+		return 0, false, fmt.Errorf("%q is neither a percentage nor a positive quantity", value)
+	}
+	return 0, false, nil
+}
+
+// firesBefore reports whether threshold a keeps more of a resource available than b. Thresholds
+// of different kinds (percentage and quantity) cannot be compared and are accepted.
+func firesBefore(a, b string) bool {
+	aPercent, aIsPercent, aErr := parseThreshold(a)
+	bPercent, bIsPercent, bErr := parseThreshold(b)
+	if aErr != nil || bErr != nil || aIsPercent != bIsPercent {
+		return true
+	}
+	if aIsPercent {
+		return aPercent > bPercent
+	}
+	aQuantity, bQuantity := resource.MustParse(a), resource.MustParse(b)
+	return aQuantity.Cmp(bQuantity) > 0
+}
+
+// sortedKeys returns the keys of a map in a stable order for deterministic error messages
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// NodeSpecAPIVersion is the apiVersion of the NodeSpec documents this agent reads
+	NodeSpecAPIVersion = "aksflexnode.azure.com/v1alpha1"
+	// NodeSpecKind is the kind of a NodeSpec document
+	NodeSpecKind = "NodeSpec"
+)
+
+// configType returns the viper config type of a configuration or NodeSpec file by its extension
+func configType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
+
+// nodeSpecRoot returns the key holding the configuration in the loaded document: "spec" for a
+// NodeSpec, or "" for a plain config file, which has no kind
+func nodeSpecRoot(v *viper.Viper) (string, error) {
+	kind, apiVersion := v.GetString("kind"), v.GetString("apiVersion")
+	if kind == "" && apiVersion == "" {
+		return "", nil
+	}
+	if kind != NodeSpecKind {
+		return "", fmt.Errorf("invalid kind: %q. Expected %s", kind, NodeSpecKind)
+	}
+	if apiVersion != NodeSpecAPIVersion {
+		return "", fmt.Errorf("invalid apiVersion: %q. Expected %s", apiVersion, NodeSpecAPIVersion)
+	}
+	if !v.IsSet("spec") {
+		return "", fmt.Errorf("NodeSpec has no spec")
+	}
+	return "spec", nil
+}
+
+// readSourceSpec reads a NodeSpec pulled from the agent's source and returns its spec and name.
+// The agent's source settings are dropped from the spec so a pulled revision cannot redirect the agent.
+func readSourceSpec(path string) (map[string]any, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read NodeSpec at %s: %w", path, err)
+	}
+	v := viper.New()
+	v.SetConfigType(configType(path))
+	if err := v.ReadConfig(strings.NewReader(string(data))); err != nil {
+		return nil, "", fmt.Errorf("failed to parse NodeSpec at %s: %w", path, err)
+	}
+	root, err := nodeSpecRoot(v)
+	if err != nil {
+		return nil, "", err
+	}
+	if root == "" {
+		return nil, "", fmt.Errorf("invalid kind: %q. Expected %s", "", NodeSpecKind)
+	}
+
+	spec := v.GetStringMap(root)
+	if agent, ok := spec["agent"].(map[string]any); ok {
+		delete(agent, "source")
+	}
+	return spec, v.GetString("metadata.name"), nil
+}
@@ -1,36 +1,144 @@
 package config
 
-import "os"
+import (
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
 
 // Config represents the complete agent configuration structure.
 // It contains Azure-specific settings and agent operational settings.
 type Config struct {
+	// Version of the configuration file format, written by `aks-flex-node init`; empty means the current version
+	SchemaVersion string `json:"schemaVersion,omitempty"`
+
 	Azure      AzureConfig      `json:"azure"`
 	Agent      AgentConfig      `json:"agent"`
 	Containerd ContainerdConfig `json:"containerd"`
+	CRIO       CRIOConfig       `json:"crio"`
 	Kubernetes KubernetesConfig `json:"kubernetes"`
 	CNI        CNIConfig        `json:"cni"`
 	Runc       RuntimeConfig    `json:"runc"`
 	Node       NodeConfig       `json:"node"`
 	Paths      PathsConfig      `json:"paths"`
 	Npd        NPDConfig        `json:"npd"`
+	CATrust    CATrustConfig    `json:"caTrust"`
+	Preflight  PreflightConfig  `json:"preflight"`
+	FluentBit  FluentBitConfig  `json:"fluentBit"`
+	SSH        SSHConfig        `json:"ssh"`
+	SRIOV      SRIOVConfig      `json:"sriov"`
+
+	ImagePrePull ImagePrePullConfig `json:"imagePrePull"`
+	Downloads    DownloadsConfig    `json:"downloads"`
+	Templates    TemplatesConfig    `json:"templates"`
+	Components   ComponentsConfig   `json:"components"`
+	StaticPods   []StaticPodConfig  `json:"staticPods,omitempty"` // Bootstrap-critical addons kubelet runs before joining
+
+	CustomScripts []CustomScriptConfig `json:"customScripts,omitempty"` // Scripts run in order before kubelet starts
+
+	// Container runtime kubelet talks to over CRI: "containerd" (default) or "cri-o"
+	ContainerRuntime string `json:"containerRuntime,omitempty"`
 
 	// Internal field to track if ManagedIdentity was explicitly set in config
 	// This is necessary because viper unmarshals empty JSON objects {} as nil
 	isMIExplicitlySet bool `json:"-"`
+
+	// Path the configuration was loaded from, used to resume bootstrap after a reboot
+	path string `json:"-"`
+
+	// metadata.name of the NodeSpec the configuration was loaded from, empty for a plain config file
+	nodeSpecName string `json:"-"`
+
+	// Whether the target cluster's location and node resource group were read from ARM
+	clusterDiscovered bool `json:"-"`
+
+	// Arc machine name the node was connected under, recorded or chosen during this run
+	arcMachineName string `json:"-"`
+
+	// azure.arc.roleAssignments scopes as written, expanded again once the cluster is discovered
+	roleAssignmentScopeTemplates []string `json:"-"`
 }
 
 // AzureConfig holds Azure-specific configuration required for connecting to Azure services.
 // All fields except Cloud are required for proper operation.
 type AzureConfig struct {
-	SubscriptionID   string                  `json:"subscriptionId"`             // Azure subscription ID
+	SubscriptionID   string                  `json:"subscriptionId"`             // Azure subscription ID (defaults to the target cluster's)
 	TenantID         string                  `json:"tenantId"`                   // Azure tenant ID
 	Cloud            string                  `json:"cloud"`                      // Azure cloud environment (defaults to AzurePublicCloud)
 	ServicePrincipal *ServicePrincipalConfig `json:"servicePrincipal,omitempty"` // Optional service principal authentication
 	ManagedIdentity  *ManagedIdentityConfig  `json:"managedIdentity,omitempty"`  // Optional managed identity authentication
 	BootstrapToken   *BootstrapTokenConfig   `json:"bootstrapToken,omitempty"`   // Optional bootstrap token authentication
+	NodeCertificate  *NodeCertificateConfig  `json:"nodeCertificate,omitempty"`  // Optional client certificate authentication
 	Arc              *ArcConfig              `json:"arc"`                        // Azure Arc machine configuration
 	TargetCluster    *TargetClusterConfig    `json:"targetCluster"`              // Target AKS cluster configuration
+
+	// Clusters the node may be a member of, by name, instead of a single targetCluster; the node joins
+	// currentCluster and `aks-flex-node switch-cluster` moves it to another one. Names are case-insensitive.
+	Clusters       map[string]*TargetClusterConfig `json:"clusters,omitempty"`
+	CurrentCluster string                          `json:"currentCluster,omitempty"`
+
+	// Additional tenants whose tokens are attached to ARM requests (x-ms-authorization-auxiliary).
+	// The target cluster tenant is added automatically when it differs from tenantId.
+	AuxiliaryTenantIDs []string `json:"auxiliaryTenantIds,omitempty"`
+
+	PrivateLink *PrivateLinkConfig `json:"privateLink,omitempty"` // Optional private endpoint connectivity
+
+	// Azure resources a new site needs, created from a template built into the agent when they don't exist
+	Prerequisites *PrerequisitesConfig `json:"prerequisites,omitempty"`
+
+	Timeouts *AzureTimeoutsConfig `json:"timeouts,omitempty"` // Optional limits on Azure API calls
+
+	// Limits the ARM writes all nodes sharing the container send to one subscription at the same time
+	ARMWriteLimit *ARMWriteLimitConfig `json:"armWriteLimit,omitempty"`
+}
+
+// PrerequisitesConfig declares Azure resources bootstrap creates before connecting the machine to Arc when
+// deploy is set, so a single command stands up a new edge site. Existing resources are left unchanged.
+type PrerequisitesConfig struct {
+	Deploy        bool              `json:"deploy"`                  // Create the missing resources during bootstrap
+	ResourceGroup string            `json:"resourceGroup,omitempty"` // Resource group to create (defaults to azure.arc.resourceGroup)
+	Location      string            `json:"location,omitempty"`      // Region of the created resources (defaults to azure.arc.location)
+	Tags          map[string]string `json:"tags,omitempty"`          // Tags of the created resources
+
+	// Name of a user-assigned managed identity to create in the resource group, e.g. for workload identity
+	ManagedIdentityName string `json:"managedIdentityName,omitempty"`
+
+	// Private DNS zones, by resource ID, linked to virtualNetworkId so the site resolves private endpoints
+	PrivateDNSZoneIDs []string `json:"privateDnsZoneIds,omitempty"`
+	VirtualNetworkID  string   `json:"virtualNetworkId,omitempty"`
+}
+
+// ARMWriteLimitConfig coordinates the nodes of a fleet that are onboarded at the same time, so together they stay
+// below the write throttling of their subscription. Nodes hold the lease of one of maxConcurrent blobs per
+// subscription in the container while they send an ARM write. The configured Azure credential needs the Storage
+// Blob Data Contributor role on the container.
+type ARMWriteLimitConfig struct {
+	ContainerURL  string `json:"containerUrl"`            // Container shared by the fleet, e.g. https://myaccount.blob.core.windows.net/aks-flex-node
+	MaxConcurrent int    `json:"maxConcurrent,omitempty"` // ARM writes allowed at once per subscription (defaults to 10)
+}
+
+// AzureTimeoutsConfig bounds how long Azure API calls may take, so a stuck connection fails the call
+// instead of holding up bootstrap
+type AzureTimeoutsConfig struct {
+	PerTry    string `json:"perTry,omitempty"`    // Time allowed for each HTTP attempt before the SDK retries (defaults to 1m)
+	Operation string `json:"operation,omitempty"` // Time allowed for one Azure operation including retries (defaults to 5m)
+}
+
+// PrivateLinkConfig declares that ARM and Arc traffic goes over private endpoints.
+// Microsoft Entra ID (AAD) has no private endpoint and must stay reachable through the firewall or proxy.
+type PrivateLinkConfig struct {
+	Enabled bool `json:"enabled"` // Whether ARM and Arc endpoints are expected to resolve to private IPs
+
+	// Resource ID of the Azure Arc private link scope the machine connects through
+	ArcPrivateLinkScopeID string `json:"arcPrivateLinkScopeId,omitempty"`
+
+	// Additional host names that must resolve to private IPs, e.g. a private Key Vault or ACR
+	AdditionalHosts []string `json:"additionalHosts,omitempty"`
 }
 
 // ServicePrincipalConfig holds Azure service principal authentication configuration.
@@ -39,6 +147,23 @@ type ServicePrincipalConfig struct {
 	TenantID     string `json:"tenantId"`     // Azure AD tenant ID
 	ClientID     string `json:"clientId"`     // Azure AD application (client) ID
 	ClientSecret string `json:"clientSecret"` // Azure AD application client secret
+
+	// PEM file holding the application's certificate and unencrypted private key, instead of clientSecret
+	ClientCertificateFile string `json:"clientCertificateFile,omitempty"`
+
+	// Where `aks-flex-node rotate-credentials` and the agent pick up a new secret or certificate
+	Rotation *CredentialRotationConfig `json:"rotation,omitempty"`
+}
+
+// CredentialRotationConfig names the source of the next service principal credential. The agent checks it
+// periodically and switches to a new secret or certificate as soon as it is published, so the old one can
+// be removed from the application shortly after.
+type CredentialRotationConfig struct {
+	KeyVaultSecretID string `json:"keyVaultSecretId,omitempty"` // Key Vault secret holding the credential, e.g. https://myvault.vault.azure.net/secrets/flex-node-sp
+	File             string `json:"file,omitempty"`             // Local file holding the credential, e.g. written by a configuration management tool
+	Secret           string `json:"secret,omitempty"`           // Secret reference holding the credential, e.g. env:FLEX_NODE_SP_SECRET
+	Interval         string `json:"interval,omitempty"`         // How often the agent checks the source (defaults to 1h)
+	Disabled         bool   `json:"disabled,omitempty"`         // Only rotate when `aks-flex-node rotate-credentials` runs
 }
 
 // ManagedIdentityConfig holds managed identity authentication configuration.
@@ -51,31 +176,430 @@ type ManagedIdentityConfig struct {
 // Bootstrap tokens provide a lightweight authentication method for node joining.
 type BootstrapTokenConfig struct {
 	Token string `json:"token"` // Bootstrap token in format: <token-id>.<token-secret>
+
+	// Request a short-lived token from a broker with the machine's managed identity instead of configuring one
+	Broker *BootstrapTokenBrokerConfig `json:"broker,omitempty"`
+}
+
+// BootstrapTokenBrokerConfig describes an HTTPS endpoint, e.g. an Azure Function, that issues bootstrap tokens
+// to machines presenting a managed identity token for its app registration
+type BootstrapTokenBrokerConfig struct {
+	URL      string `json:"url"`                // Endpoint the token is requested from with a POST
+	Audience string `json:"audience"`           // Application ID URI or client ID of the broker's app registration
+	ClientID string `json:"clientId,omitempty"` // User-assigned managed identity to use; the system-assigned one when empty
+}
+
+// NodeCertificateConfig makes the kubelet authenticate with a client certificate for system:node:<hostname>,
+// issued by Key Vault or an internal CA, for clusters that do not allow token-based TLS bootstrap.
+// The agent renews the certificate before it expires.
+type NodeCertificateConfig struct {
+	Issuer   string                         `json:"issuer"`             // "keyVault" or "ca"
+	KeyVault *NodeCertificateKeyVaultConfig `json:"keyVault,omitempty"` // Key Vault issuer settings
+	CA       *NodeCertificateCAConfig       `json:"ca,omitempty"`       // Internal CA issuer settings
+
+	// How long before expiry the certificate is renewed, e.g. "720h"; a third of its lifetime when empty
+	RenewBefore string `json:"renewBefore,omitempty"`
+}
+
+// NodeCertificateKeyVaultConfig issues the certificate through a certificate issuer registered in a Key Vault,
+// which must be a CA the cluster trusts for client certificates
+type NodeCertificateKeyVaultConfig struct {
+	VaultURL        string `json:"vaultUrl"`                  // e.g. https://myvault.vault.azure.net
+	IssuerName      string `json:"issuerName"`                // Certificate issuer registered in the vault
+	CertificateName string `json:"certificateName,omitempty"` // Defaults to aks-flex-node-<hostname>
+	ValidityMonths  int    `json:"validityMonths,omitempty"`  // Defaults to 12
+	ClientID        string `json:"clientId,omitempty"`        // User-assigned managed identity to use; the system-assigned one when empty
+}
+
+// NodeCertificateCAConfig signs the certificate on the node with an internal CA's certificate and key
+type NodeCertificateCAConfig struct {
+	CertFile     string `json:"certFile"`               // PEM CA certificate
+	KeyFile      string `json:"keyFile"`                // PEM CA private key, readable by root only
+	ValidityDays int    `json:"validityDays,omitempty"` // Defaults to 365
 }
 
 // TargetClusterConfig holds configuration for the target AKS cluster the ARC machine will connect to.
 type TargetClusterConfig struct {
 	ResourceID        string `json:"resourceId"` // Full resource ID of the target AKS cluster
-	Location          string `json:"location"`   // Azure region of the cluster (e.g., "eastus", "westus2")
+	Location          string `json:"location"`   // Azure region of the cluster (e.g., "eastus", "westus2"); read from ARM when empty
+	TenantID          string `json:"tenantId"`   // AAD tenant owning the cluster subscription (defaults to azure.tenantId)
 	Name              string // will be populated from ResourceID
 	ResourceGroup     string // will be populated from ResourceID
 	SubscriptionID    string // will be populated from ResourceID
-	NodeResourceGroup string // will be populated from ResourceID
+	NodeResourceGroup string // will be read from ARM, or derived from ResourceID and Location
 }
 
 // ArcConfig holds Azure Arc machine configuration for registering the machine with Azure Arc.
 type ArcConfig struct {
 	Enabled       bool              `json:"enabled"`       // Whether to enable Azure Arc registration
-	MachineName   string            `json:"machineName"`   // Name for the Arc machine resource
+	MachineName   string            `json:"machineName"`   // Name for the Arc machine resource, with namingStrategy explicit
 	Tags          map[string]string `json:"tags"`          // Tags to apply to the Arc machine
 	ResourceGroup string            `json:"resourceGroup"` // Azure resource group for Arc machine
 	Location      string            `json:"location"`      // Azure region for Arc machine
+
+	// How the Arc machine resource is named: "hostname" (default), "hostnameSuffix", "serialNumber" or "explicit".
+	// A machineName without a strategy is explicit.
+	NamingStrategy string `json:"namingStrategy,omitempty"`
+
+	// Suffix appended to the hostname with namingStrategy hostnameSuffix; derived from /etc/machine-id when empty
+	NameSuffix string `json:"nameSuffix,omitempty"`
+
+	// When the name is taken by an Arc machine connected from another computer, connect under the name with a
+	// suffix derived from /etc/machine-id instead of failing. The chosen name is recorded for later runs.
+	AutoSuffix bool `json:"autoSuffix,omitempty"`
+
+	// Poll Microsoft Graph for the managed identity before retrying PrincipalNotFound errors,
+	// so a wrong principal ID fails fast instead of exhausting the replication retries
+	VerifyPrincipal bool `json:"verifyPrincipal,omitempty"`
+
+	// Additional role assignments reconciled together with the built-in ones
+	RoleAssignments []RoleAssignmentConfig `json:"roleAssignments,omitempty"`
+
+	// Delete role assignments previously created by the agent on the declared principals and scopes
+	// that are no longer declared. Assignments made by other tools are never removed.
+	PruneRoleAssignments bool `json:"pruneRoleAssignments,omitempty"`
+
+	// What to do when the Arc machine resource exists from an earlier installation but this machine's agent
+	// is not connected to it: "fail" (default), "adopt" connects to the existing resource, "recreate" deletes
+	// and recreates it. Role assignments of the old identity are replaced by ones for the new identity.
+	ReinstallStrategy string `json:"reinstallStrategy,omitempty"`
+
+	// Extensions installed on the Arc machine and kept at the declared version and settings. Extensions the
+	// agent installed that are no longer declared are removed; extensions installed by others are left alone.
+	Extensions []ArcExtensionConfig `json:"extensions,omitempty"`
+}
+
+// ArcExtensionConfig declares an Arc machine extension, e.g. the Azure Monitor agent or a custom script
+type ArcExtensionConfig struct {
+	Name                   string `json:"name"`                             // Name of the extension resource on the machine
+	Publisher              string `json:"publisher"`                        // e.g. "Microsoft.Azure.Monitor"
+	Type                   string `json:"type"`                             // e.g. "AzureMonitorLinuxAgent"
+	TypeHandlerVersion     string `json:"typeHandlerVersion,omitempty"`     // Version to install; the latest when empty
+	EnableAutomaticUpgrade bool   `json:"enableAutomaticUpgrade,omitempty"` // Let Azure upgrade the extension when a new version is released
+
+	// JSON files on this machine with the extension's public and protected settings. Settings are read from
+	// files because their keys are case-sensitive, which config keys are not. Protected settings, e.g. a
+	// script's credentials, are encrypted in transit and never returned by Azure.
+	SettingsFile          string `json:"settingsFile,omitempty"`
+	ProtectedSettingsFile string `json:"protectedSettingsFile,omitempty"`
+}
+
+// RoleAssignmentConfig describes an additional role assignment reconciled by the Arc installer.
+// Scope may contain placeholders such as {subscriptionId} or {nodeResourceGroup} which are
+// expanded and validated when the config is loaded.
+type RoleAssignmentConfig struct {
+	PrincipalID string `json:"principalId,omitempty"` // Object ID of the principal; defaults to the Arc machine's managed identity
+	Role        string `json:"role"`                  // Built-in role name (e.g. "Reader") or role definition GUID
+	Scope       string `json:"scope"`                 // ARM scope or scope template
+
+	// Optional ABAC condition narrowing the assignment, e.g. to one storage container or resource name pattern
+	Condition        string `json:"condition,omitempty"`
+	ConditionVersion string `json:"conditionVersion,omitempty"` // Condition language version; defaults to 2.0
+
+	// Resource ID of the user-assigned managed identity principalId belongs to, when it lives in a customer
+	// tenant delegated to the service provider through Azure Lighthouse
+	DelegatedManagedIdentityResourceID string `json:"delegatedManagedIdentityResourceId,omitempty"`
+}
+
+// CATrustConfig holds enterprise root CAs installed into the OS trust store, containerd's registry TLS
+// configuration and therefore the Arc agent, so TLS-intercepting proxies do not break downloads and ARM calls.
+type CATrustConfig struct {
+	Certificates     []string                    `json:"certificates,omitempty"`     // PEM encoded CA certificates
+	CertificateFiles []string                    `json:"certificateFiles,omitempty"` // Paths to PEM files on the node
+	KeyVault         *KeyVaultCertificatesConfig `json:"keyVault,omitempty"`         // Optional certificates stored in Azure Key Vault
+	Registries       []string                    `json:"registries,omitempty"`       // Registry hosts (host[:port]) whose containerd TLS config trusts the CAs
+}
+
+// KeyVaultCertificatesConfig identifies CA certificates stored in Azure Key Vault.
+// Only the public certificate is read, so the credential needs the certificates/get permission only.
+type KeyVaultCertificatesConfig struct {
+	VaultURL         string   `json:"vaultUrl"`         // Vault URL, e.g. https://myvault.vault.azure.net
+	CertificateNames []string `json:"certificateNames"` // Names of the certificates to install
 }
 
 // AgentConfig holds agent-specific operational configuration.
 type AgentConfig struct {
 	LogLevel string `json:"logLevel"` // Logging level: debug, info, warning, error
 	LogDir   string `json:"logDir"`   // Directory for log files
+	Locale   string `json:"locale"`   // Locale for user-facing messages (e.g. "en", "de", "es", "zh-CN"); defaults to the environment locale
+
+	Reboot RebootConfig `json:"reboot"` // What to do when a bootstrap step needs a reboot to take effect
+	Drift  DriftConfig  `json:"drift"`  // Detection of installed files changed outside of bootstrap
+
+	Patching PatchingConfig `json:"patching"` // Detection and coordination of reboots needed by OS updates
+
+	// Verification that the Arc role assignments bootstrap created were not removed since
+	RoleAssignments RoleAssignmentCheckConfig `json:"roleAssignments"`
+
+	// Where the agent pulls its NodeSpec from; only read from the local configuration file
+	Source SpecSourceConfig `json:"source"`
+
+	Webhook WebhookConfig `json:"webhook"` // Listener for provisioning actions sent by a central controller
+
+	Heartbeat HeartbeatConfig `json:"heartbeat"` // Liveness of the agent published on its node
+
+	SBOM SBOMConfig `json:"sbom"` // Software bill of materials of the installed components
+
+	Telemetry TelemetryConfig `json:"telemetry"` // Opt-in reporting of anonymized operation outcomes
+	State     StateConfig     `json:"state"`     // Copy of the agent's state kept off the node
+
+	Canary CanaryConfig `json:"canary"` // Smoke pod run on the node after its components changed
+
+	Observe ObserveConfig `json:"observe"` // Window after bootstrap watching the services and the node before success
+
+	// cgroup limits for heavy install work, so bootstrap cannot starve workloads already on the host
+	InstallLimits InstallLimitsConfig `json:"installLimits"`
+}
+
+// InstallLimitsConfig holds the cgroup limits archive extraction runs under, and the CPU and IO limits the
+// container runtime runs under while bootstrap pre-pulls images. Unset limits are not applied.
+type InstallLimitsConfig struct {
+	CPUQuota  string `json:"cpuQuota,omitempty"`  // CPU time as a percentage of one CPU, e.g. "50%"; "200%" allows two CPUs
+	IOWeight  int    `json:"ioWeight,omitempty"`  // Relative IO weight from 1 to 10000; systemd's default is 100
+	MemoryMax string `json:"memoryMax,omitempty"` // Memory of archive extraction, e.g. "512M"; not applied to the runtime
+}
+
+// ComponentsConfig adds components built outside the agent to bootstrap. They register themselves through the
+// pkg/component SDK, either compiled into a custom build of the agent or loaded from Go plugins, or are
+// executables speaking the SDK's exec protocol.
+type ComponentsConfig struct {
+	Plugins  []string                  `json:"plugins,omitempty"`  // Absolute paths of Go plugins (.so) registering components
+	External []ExternalComponentConfig `json:"external,omitempty"` // Registered components to install, in order
+
+	// Turns built-in components off by name, e.g. {"npd": false}; components not listed are on
+	Enabled map[string]bool `json:"enabled,omitempty"`
+}
+
+// Built-in components components.enabled can turn off. The container runtime goes by its containerRuntime name.
+const (
+	ComponentSystemConfiguration = "systemConfiguration"
+	ComponentRunc                = "runc"
+	ComponentContainerd          = "containerd"
+	ComponentCRIO                = "cri-o"
+	ComponentImagePrePull        = "imagePrePull"
+	ComponentKubeBinaries        = "kubeBinaries"
+	ComponentCNI                 = "cni"
+	ComponentNodeTopology        = "nodeTopology"
+	ComponentKubelet             = "kubelet"
+	ComponentStaticPods          = "staticPods"
+	ComponentNPD                 = "npd"
+	ComponentCustomScripts       = "customScripts"
+)
+
+// BuiltinComponents lists the built-in components in bootstrap order
+var BuiltinComponents = []string{
+	ComponentSystemConfiguration, ComponentRunc, ComponentContainerd, ComponentCRIO, ComponentImagePrePull,
+	ComponentKubeBinaries, ComponentCNI, ComponentNodeTopology, ComponentKubelet, ComponentStaticPods,
+	ComponentNPD, ComponentCustomScripts,
+}
+
+// StaticPodConfig is an addon kubelet runs as a static pod, such as node-local DNS, so it works before the node
+// has joined and cluster addons are scheduled to it. The manifest is a Go template of a v1 Pod rendered with
+// the node name, the cluster DNS service IP and the Kubernetes version.
+type StaticPodConfig struct {
+	Name         string `json:"name"`                   // Lowercase name of the manifest file, e.g. "node-local-dns"
+	Manifest     string `json:"manifest,omitempty"`     // Inline manifest, YAML or JSON
+	ManifestFile string `json:"manifestFile,omitempty"` // Path of the manifest on this machine, used instead of manifest
+}
+
+// CustomScriptConfig is a script the agent runs before kubelet starts, in the order scripts are listed, instead
+// of rc.local or cloud-init hacks. Each run is a transient systemd service with a timeout and a sandbox, and its
+// exit code and output are recorded.
+type CustomScriptConfig struct {
+	Name           string   `json:"name"`                     // Lowercase name of the script, e.g. "tune-nic"
+	Script         string   `json:"script,omitempty"`         // Inline script content
+	ScriptFile     string   `json:"scriptFile,omitempty"`     // Path of the script on this machine, used instead of script
+	SHA256         string   `json:"sha256,omitempty"`         // Expected SHA-256 of the script; bootstrap fails on mismatch
+	Interpreter    string   `json:"interpreter,omitempty"`    // Absolute path of the interpreter (defaults to /bin/sh)
+	Timeout        string   `json:"timeout,omitempty"`        // The script is killed after this long (defaults to 5m)
+	ExitCodes      []int    `json:"exitCodes,omitempty"`      // Exit codes counted as success (defaults to 0)
+	Run            string   `json:"run,omitempty"`            // "once" (default) until it succeeds with its current content, or "always"
+	ReadWritePaths []string `json:"readWritePaths,omitempty"` // When set, the file system is read-only for the script except these paths
+}
+
+// ExternalComponentConfig enables a registered component, or an executable speaking the exec protocol of the
+// pkg/component SDK, and holds its settings
+type ExternalComponentConfig struct {
+	Name     string            `json:"name"`               // Name the component registered under, or a name for the executable
+	Exec     string            `json:"exec,omitempty"`     // Absolute path of the executable, instead of a registered component
+	After    string            `json:"after,omitempty"`    // Bootstrap step to install after, overriding the registered one
+	Timeout  string            `json:"timeout,omitempty"`  // Time allowed for each run of the executable (defaults to 10m)
+	Settings map[string]string `json:"settings,omitempty"` // Passed to the component as is
+}
+
+// State backends
+const (
+	StateBackendFile      = "file"
+	StateBackendAzureBlob = "azureBlob"
+)
+
+// StateConfig keeps a copy of the agent's state directory, /var/lib/aks-flex-node, in a backend that outlives the
+// node's disk. Bootstrap restores the copy when it finds the state directory empty, so diskless or re-imaged nodes
+// resume as the same node, and updates it after bootstrap and unbootstrap. By default the state only lives on the node.
+type StateConfig struct {
+	Backend   string               `json:"backend,omitempty"`   // file or azureBlob; empty keeps the state on the node only
+	Path      string               `json:"path,omitempty"`      // file: directory of the copy, e.g. on a network mount
+	AzureBlob StateAzureBlobConfig `json:"azureBlob,omitempty"` // azureBlob: container of the copy
+}
+
+// StateAzureBlobConfig is the Azure Storage container the agent's state is copied to. The configured Azure
+// credential needs the Storage Blob Data Contributor role on it.
+type StateAzureBlobConfig struct {
+	ContainerURL string `json:"containerUrl,omitempty"` // e.g. https://myaccount.blob.core.windows.net/aks-flex-node
+	Prefix       string `json:"prefix,omitempty"`       // Blob name prefix of this node's copy; defaults to the hostname
+}
+
+// CanaryConfig runs a smoke pod pinned to the node after apply or an upgrade changed its components. The apply only
+// succeeds once the pod ran and resolved a cluster name through the pod network, so a broken CNI or runc upgrade is
+// caught before it is reported as done. Off by default.
+type CanaryConfig struct {
+	Enabled    bool   `json:"enabled,omitempty"`    // Run the smoke pod after changes
+	Kubeconfig string `json:"kubeconfig,omitempty"` // Kubeconfig of an identity allowed to create and delete pods in namespace
+	Namespace  string `json:"namespace,omitempty"`  // Namespace of the smoke pod (defaults to default)
+	Image      string `json:"image,omitempty"`      // Image with sh and nslookup (defaults to mcr.microsoft.com/azurelinux/busybox:1.36)
+	Timeout    string `json:"timeout,omitempty"`    // Time allowed for the pod to run and finish its checks (defaults to 3m)
+}
+
+// ObserveConfig holds bootstrap open for a window after the services started, watching the container runtime,
+// kubelet and NPD and the node's conditions. A service that crash-loops after passing its start checks then fails
+// bootstrap instead of being reported as done. Off unless a window is set.
+type ObserveConfig struct {
+	Window      string `json:"window,omitempty"`      // How long to watch, e.g. "10m"; off when empty
+	Interval    string `json:"interval,omitempty"`    // Time between checks (defaults to 15s)
+	MaxRestarts int    `json:"maxRestarts,omitempty"` // Restarts of each service tolerated during the window (defaults to 0)
+	OnFailure   string `json:"onFailure,omitempty"`   // "fail" (default) or "rollback" to unbootstrap a node failing its first bootstrap
+}
+
+// TelemetryConfig opts in to reporting the outcome of bootstrap, unbootstrap and the other operations that change
+// the node to an endpoint of the operator's choice. Reports hold the operation, its result, the duration and failed
+// step, and the category of the error, never names, addresses, Azure resource IDs or error messages. Off by default.
+type TelemetryConfig struct {
+	Enabled         bool   `json:"enabled,omitempty"`         // Send reports; nothing is sent unless set
+	Endpoint        string `json:"endpoint,omitempty"`        // HTTPS URL reports are POSTed to as JSON
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"` // File holding a token sent in the Authorization header
+}
+
+// SBOMConfig controls the software bill of materials written after each successful bootstrap. It lists the
+// agent, every installed component with the artifact it was downloaded from, and the files bootstrap installed,
+// in SPDX and CycloneDX JSON under /var/lib/aks-flex-node.
+type SBOMConfig struct {
+	Disabled bool `json:"disabled,omitempty"` // Don't write the SBOM
+
+	// Also tag the Arc machine with the SBOM's digest and the installed component versions, so audits can
+	// query them in Azure Resource Graph
+	AttachToArc bool `json:"attachToArc,omitempty"`
+}
+
+// HeartbeatConfig controls the FlexNodeAgentReady node condition the agent daemon keeps up to date, so the
+// control plane can tell a dead agent from a dead kubelet and clean up nodes whose agent is gone.
+type HeartbeatConfig struct {
+	Disabled bool   `json:"disabled,omitempty"` // Turn off the heartbeat
+	Interval string `json:"interval,omitempty"` // How often the heartbeat is published (defaults to 1m)
+}
+
+// WebhookConfig enables a listener in the agent daemon that accepts provisioning actions from a central
+// controller, as an alternative to driving nodes over SSH. Requests are signed with a shared secret and
+// every request is recorded in an audit log, whether it was rejected, succeeded or failed.
+type WebhookConfig struct {
+	ListenAddress  string   `json:"listenAddress,omitempty"`  // host:port to listen on; the listener is off when empty
+	SecretFile     string   `json:"secretFile,omitempty"`     // File holding the shared secret requests are signed with
+	Secret         string   `json:"secret,omitempty"`         // Secret reference to the shared secret, instead of secretFile
+	TLSCertFile    string   `json:"tlsCertFile,omitempty"`    // Serve HTTPS with this certificate
+	TLSKeyFile     string   `json:"tlsKeyFile,omitempty"`     // Private key of tlsCertFile
+	AllowedActions []string `json:"allowedActions,omitempty"` // Actions the listener accepts: bootstrap, install, upgrade, drain, uncordon
+
+	// Kubeconfig of an identity allowed to cordon the node and evict its pods, for drain and uncordon.
+	// The kubelet's node identity cannot evict pods.
+	DrainKubeconfig string `json:"drainKubeconfig,omitempty"`
+}
+
+// SpecSourceConfig points the agent at a NodeSpec kept in a Git repository or an OCI registry. The agent polls the
+// source and applies each new revision on top of the local configuration file, which keeps credentials off the source.
+type SpecSourceConfig struct {
+	Git      *GitSourceConfig `json:"git,omitempty"`
+	OCI      *OCISourceConfig `json:"oci,omitempty"`
+	Interval string           `json:"interval,omitempty"` // How often the source is polled (defaults to 5m)
+
+	// Apply revisions without a verified signature. Only for testing; anyone who can push to the source controls the node.
+	AllowUnsigned bool `json:"allowUnsigned,omitempty"`
+}
+
+// GitSourceConfig identifies a NodeSpec file in a Git repository. The commit must be signed by one of the trusted keys.
+type GitSourceConfig struct {
+	URL                string `json:"url"`                          // Repository URL, e.g. https://github.com/contoso/edge-nodes.git
+	Ref                string `json:"ref,omitempty"`                // Branch or tag (defaults to main)
+	Path               string `json:"path,omitempty"`               // NodeSpec file in the repository (defaults to nodespec.yaml)
+	AllowedSignersFile string `json:"allowedSignersFile,omitempty"` // SSH allowed signers file trusted for SSH-signed commits
+	GPGPublicKeyFile   string `json:"gpgPublicKeyFile,omitempty"`   // Armored GPG public keys trusted for GPG-signed commits
+}
+
+// OCISourceConfig identifies a NodeSpec pushed as an OCI artifact, e.g. with `oras push`. The artifact must be signed with cosign.
+type OCISourceConfig struct {
+	Reference           string `json:"reference"`                     // Artifact reference, e.g. contoso.azurecr.io/nodespecs/edge:prod
+	Path                string `json:"path,omitempty"`                // NodeSpec file in the artifact (defaults to nodespec.yaml)
+	CosignPublicKeyFile string `json:"cosignPublicKeyFile,omitempty"` // Cosign public key the artifact signature is verified with
+}
+
+// DriftConfig controls how the agent detects binaries and configuration files installed by bootstrap that were
+// changed outside of it, such as a hand-edited containerd config.toml or a replaced kubelet binary. Drift is
+// always logged and raised as an NPD event, which the NPD metrics export forwards as a problem counter.
+type DriftConfig struct {
+	Disabled      bool   `json:"disabled,omitempty"`      // Turn off drift detection
+	Interval      string `json:"interval,omitempty"`      // How often installed files are checked (defaults to 10m)
+	NodeCondition bool   `json:"nodeCondition,omitempty"` // Also set the ConfigDrift node condition while files differ
+	Remediate     bool   `json:"remediate,omitempty"`     // Reinstall drifted files by re-running the bootstrap steps owning them
+}
+
+// RoleAssignmentCheckConfig controls the periodic check that the role assignments the node needs, the built-in
+// cluster roles and azure.arc.roleAssignments, still exist. A removed assignment otherwise only shows up later
+// as kubelet or Arc failures. Missing assignments are logged and raised as an NPD event. Only applies in Arc mode.
+type RoleAssignmentCheckConfig struct {
+	Disabled      bool   `json:"disabled,omitempty"`      // Turn off the check
+	Interval      string `json:"interval,omitempty"`      // How often the role assignments are listed (defaults to 30m)
+	NodeCondition bool   `json:"nodeCondition,omitempty"` // Also set the RoleAssignmentMissing node condition while one is missing
+	Recreate      bool   `json:"recreate,omitempty"`      // Create missing role assignments again; needs permission to assign roles
+}
+
+// PatchingConfig controls the detection of OS updates that wait for a reboot or for services to restart, e.g.
+// after unattended-upgrades or dnf-automatic installed them. Pending reboots are shown in the node status and
+// raised as an NPD event, which the NPD metrics export forwards as a problem counter. While a patch reboot is
+// under way, e.g. by kured, the agent neither re-bootstraps the node nor remediates drift.
+type PatchingConfig struct {
+	Disabled      bool   `json:"disabled,omitempty"`      // Turn off the detection
+	Interval      string `json:"interval,omitempty"`      // How often pending reboots are checked (defaults to 15m)
+	NodeCondition bool   `json:"nodeCondition,omitempty"` // Also set the RebootRequired node condition while a reboot is pending
+
+	// "report" (default) only reports pending reboots, leaving them to the operator or kured.
+	// "maintenance-window" drains the node and reboots it within agent.reboot.maintenanceWindow.
+	RebootPolicy string `json:"rebootPolicy,omitempty"`
+	// Kubeconfig allowed to drain and uncordon the node, for the maintenance-window policy
+	DrainKubeconfig string `json:"drainKubeconfig,omitempty"`
+}
+
+// RebootConfig controls how reboots requested by bootstrap steps, e.g. for kernel boot parameters, are carried out.
+// Bootstrap always stops at the step that needs the reboot and resumes on the next boot.
+type RebootConfig struct {
+	Policy            string `json:"policy,omitempty"`            // "manual" (default), "immediate" or "maintenance-window"
+	MaintenanceWindow string `json:"maintenanceWindow,omitempty"` // Daily local time window such as "02:00-04:00" for the maintenance-window policy
+}
+
+// PreflightConfig holds settings for the checks run before bootstrap changes anything.
+type PreflightConfig struct {
+	// How to handle other Kubernetes distributions, container runtimes or Arc agents found on the machine:
+	// "abort" (default) fails bootstrap, "stop-and-disable" stops and disables them, "coexist" only warns
+	ConflictingAgents string `json:"conflictingAgents,omitempty"`
+	// What to do when the target cluster's version or network setup is incompatible with this node:
+	// "enforce" (default) fails bootstrap, "warn" only logs the problems
+	ClusterCompatibility string `json:"clusterCompatibility,omitempty"`
+	// Expected latency and MTU on the path to the API server, such as a VPN or ExpressRoute circuit
+	APIServerPath *APIServerPathConfig `json:"apiServerPath,omitempty"`
+}
+
+// APIServerPathConfig holds the limits the API server path check enforces.
+type APIServerPathConfig struct {
+	Mode         string `json:"mode,omitempty"`         // "enforce" (default) fails bootstrap, "warn" only logs the problems, "skip" disables the check
+	MaxLatencyMs int    `json:"maxLatencyMs,omitempty"` // Highest acceptable median TCP connect time (default 250)
+	MinPathMTU   int    `json:"minPathMTU,omitempty"`   // Smallest acceptable path MTU (default 1280, the IPv6 minimum)
 }
 
 // KubernetesConfig holds configuration settings for Kubernetes components.
@@ -97,23 +621,195 @@ type ContainerdConfig struct {
 	MetricsAddress string `json:"metricsAddress"`
 }
 
+// CRIOConfig holds configuration settings for the CRI-O runtime, used when containerRuntime is "cri-o".
+type CRIOConfig struct {
+	Version    string `json:"version,omitempty"`    // CRI-O release, e.g. "1.32.0" (defaults to the Kubernetes minor version)
+	PauseImage string `json:"pauseImage,omitempty"` // Infra container image of every pod
+}
+
+// ImagePrePullConfig lists images pulled right after the container runtime is installed, so system pods
+// become Ready quickly after the node joins and a wrong registry mapping fails bootstrap instead of the first pod.
+type ImagePrePullConfig struct {
+	Images      []string `json:"images,omitempty"`      // Images such as CNI, CSI node and metrics agents; the pause image is always pulled
+	Timeout     string   `json:"timeout,omitempty"`     // Time allowed for each pull (defaults to 5m)
+	Parallelism int      `json:"parallelism,omitempty"` // Number of concurrent pulls (defaults to 3)
+}
+
+// TemplatesConfig points at a directory of templates replacing the embedded ones bootstrap renders the host's
+// systemd units, container runtime configuration and NPD monitors from. A file named <template>.tmpl in the
+// directory replaces the embedded template of that name; templates without an override keep the embedded one.
+type TemplatesConfig struct {
+	Directory string `json:"directory,omitempty"` // Defaults to /etc/aks-flex-node/templates
+}
+
+// DownloadsConfig holds settings for the release artifacts downloaded during bootstrap, such as the
+// Kubernetes binaries, container runtime and CNI plugins.
+type DownloadsConfig struct {
+	Cache     DownloadCacheConfig     `json:"cache"`
+	Peers     DownloadPeersConfig     `json:"peers"`
+	RateLimit DownloadRateLimitConfig `json:"rateLimit"`
+
+	// Expected SHA-256 digests of artifacts. A download that does not match fails and is not cached.
+	Checksums []ArtifactChecksum `json:"checksums,omitempty"`
+
+	// Version manifest listing where each component version is downloaded from per OS and architecture,
+	// instead of the upstream release URLs. Versions without an entry keep the upstream URLs.
+	Artifacts []ComponentArtifacts `json:"artifacts,omitempty"`
+}
+
+// ComponentArtifacts lists the release artifacts of one version of a component
+type ComponentArtifacts struct {
+	Component string             `json:"component"` // kubernetes, containerd, cri-o, runc, cni-plugins or node-problem-detector
+	Version   string             `json:"version"`   // As configured for the component, e.g. "1.7.20" for containerd
+	Platforms []PlatformArtifact `json:"platforms"`
+}
+
+// PlatformArtifact is the release artifact of a component version for one OS and architecture
+type PlatformArtifact struct {
+	OS     string `json:"os"`               // "linux" or "windows"
+	Arch   string `json:"arch"`             // "amd64", "arm64" or "arm"
+	URL    string `json:"url"`              // Must serve the same archive layout as the upstream release
+	SHA256 string `json:"sha256,omitempty"` // Pinned like downloads.checksums
+}
+
+// ArtifactChecksum pins the SHA-256 digest of the artifact at a download URL
+type ArtifactChecksum struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// DownloadCacheConfig controls the content-addressed artifact cache. Artifacts are stored by SHA-256 digest,
+// so repeated installs and repairs reuse them, and a cache directory copied from another node can seed this one.
+type DownloadCacheConfig struct {
+	Disabled  bool   `json:"disabled,omitempty"`
+	Directory string `json:"directory,omitempty"` // Defaults to /var/cache/aks-flex-node/artifacts
+	// Read-only caches with the same layout, e.g. on a USB drive or a mounted share, consulted before downloading
+	SeedDirectories []string `json:"seedDirectories,omitempty"`
+}
+
+// DownloadPeersConfig shares artifact caches between nodes on the same LAN, so a batch of nodes at an
+// edge site downloads each artifact over the WAN only once.
+type DownloadPeersConfig struct {
+	// Address the agent daemon serves its artifact cache on, such as ":8470"; empty does not serve
+	ServeAddress string `json:"serveAddress,omitempty"`
+	// Base URLs of peers serving their cache, such as "http://10.0.0.5:8470", tried in order before the internet
+	URLs []string `json:"urls,omitempty"`
+	// TrustUnpinned also takes artifacts without a pinned checksum from peers, verified only against the digest
+	// the peer itself reports; by default only artifacts pinned in downloads.checksums are taken from peers
+	TrustUnpinned bool `json:"trustUnpinned,omitempty"`
+}
+
+// DownloadRateLimitConfig caps the bandwidth artifact downloads use, so bootstrap at a site with a
+// constrained link does not starve production traffic. Rates are bytes per second written as
+// quantities such as "5Mi"; empty means unlimited.
+type DownloadRateLimitConfig struct {
+	Global      string `json:"global,omitempty"`      // Combined rate of all downloads running at the same time
+	PerArtifact string `json:"perArtifact,omitempty"` // Rate of each single download
+}
+
 // NodeConfig holds configuration settings for the Kubernetes node.
 type NodeConfig struct {
-	MaxPods int               `json:"maxPods"`
-	Labels  map[string]string `json:"labels"`
-	Kubelet KubeletConfig     `json:"kubelet"`
+	MaxPods   int               `json:"maxPods"`
+	Labels    map[string]string `json:"labels"`
+	Pool      NodePoolConfig    `json:"pool"`
+	Kubelet   KubeletConfig     `json:"kubelet"`
+	Hugepages HugepagesConfig   `json:"hugepages"`
+	Tuning    TuningConfig      `json:"tuning"`
+	Cgroup    CgroupConfig      `json:"cgroup"`
+	Network   NodeNetworkConfig `json:"network"`
+	DNS       NodeDNSConfig     `json:"dns"`
+}
+
+// NodeDNSConfig sets the host's upstream DNS servers and search domains, for networks whose DHCP does not hand
+// out the resolvers that know the private zones. Kubelet passes the host's resolver configuration on to pods
+// with the Default DNS policy and to the cluster DNS as its upstream.
+type NodeDNSConfig struct {
+	Upstreams     []string `json:"upstreams,omitempty"`     // IP addresses of DNS servers, used before those learned from DHCP
+	SearchDomains []string `json:"searchDomains,omitempty"` // Domains tried for names without a dot, e.g. "corp.example.com"
+	VerifyNames   []string `json:"verifyNames,omitempty"`   // Names that must resolve once applied, besides the host of node.kubelet.serverURL
+}
+
+// NodeNetworkConfig holds the IP families the node takes part in, for IPv6-only and dual-stack clusters
+type NodeNetworkConfig struct {
+	IPFamilies []string `json:"ipFamilies,omitempty"` // "IPv4" and/or "IPv6", the primary family first (default: IPv4)
+	NodeIPs    []string `json:"nodeIPs,omitempty"`    // Addresses kubelet registers, one per family in the same order; detected when empty
+}
+
+// CgroupConfig controls the cgroup driver kubelet and the container runtime share, and the move of hosts still
+// on cgroup v1 to the unified (v2) hierarchy, which Kubernetes requires from 1.35
+type CgroupConfig struct {
+	Driver      string `json:"driver,omitempty"`      // "systemd" (default) or "cgroupfs"
+	MigrateToV2 bool   `json:"migrateToV2,omitempty"` // Switch a cgroup v1 host to v2 on the kernel command line and reboot
+}
+
+// NodePoolConfig groups flex nodes into a logical external pool. The node gets the labels AKS puts on
+// agent pool nodes, so scheduling and autoscaling policies written for agent pools can target flex capacity.
+type NodePoolConfig struct {
+	Name   string   `json:"name,omitempty"`   // Pool name, following AKS agent pool naming, e.g. "edgegpu"
+	Mode   string   `json:"mode,omitempty"`   // "user" (default) or "system"
+	Taints []string `json:"taints,omitempty"` // Taints registered with the node, e.g. "sku=gpu:NoSchedule"
+}
+
+// TuningConfig selects the kernel tuning (sysctl) profile applied to the node.
+type TuningConfig struct {
+	Profile  string                         `json:"profile,omitempty"`  // Built-in (default, high-network, database) or custom profile name
+	Profiles map[string]TuningProfileConfig `json:"profiles,omitempty"` // Custom profiles keyed by name
+}
+
+// TuningProfileConfig defines a custom tuning profile as sysctl settings layered on an optional built-in profile.
+type TuningProfileConfig struct {
+	Extends string            `json:"extends,omitempty"` // Built-in profile to start from
+	Sysctls map[string]string `json:"sysctls"`           // sysctl key/value pairs, e.g. "net.core.somaxconn": "32768"
+}
+
+// HugepagesConfig holds the number of hugepages pre-allocated on the host.
+// 2Mi pages are allocated at runtime; 1Gi pages must be reserved on the kernel command line and need a reboot.
+type HugepagesConfig struct {
+	Pages2Mi int `json:"pages2Mi,omitempty"` // Number of 2Mi hugepages
+	Pages1Gi int `json:"pages1Gi,omitempty"` // Number of 1Gi hugepages, validated against the running kernel
 }
 
 // KubeletConfig holds kubelet-specific configuration settings.
 type KubeletConfig struct {
-	KubeReserved         map[string]string `json:"kubeReserved"`
+	KubeReserved         map[string]string `json:"kubeReserved"`   // Overrides individual computed kube-reserved values
+	SystemReserved       map[string]string `json:"systemReserved"` // Overrides individual computed system-reserved values
 	EvictionHard         map[string]string `json:"evictionHard"`
 	Verbosity            int               `json:"verbosity"`
-	ImageGCHighThreshold int               `json:"imageGCHighThreshold"`
-	ImageGCLowThreshold  int               `json:"imageGCLowThreshold"`
-	DNSServiceIP         string            `json:"dnsServiceIP"` // Cluster DNS service IP (default: 10.0.0.10 for AKS)
-	ServerURL            string            `json:"serverURL"`    // Kubernetes API server URL
-	CACertData           string            `json:"caCertData"`   // Base64-encoded CA certificate data
+	ImageGCHighThreshold int               `json:"imageGCHighThreshold"` // Disk usage percent that starts image GC (defaults by disk size)
+	ImageGCLowThreshold  int               `json:"imageGCLowThreshold"`  // Disk usage percent image GC frees down to (defaults by disk size)
+	DNSServiceIP         string            `json:"dnsServiceIP"`         // Cluster DNS service IP (default: 10.0.0.10 for AKS)
+	ServerURL            string            `json:"serverURL"`            // Kubernetes API server URL
+	CACertData           string            `json:"caCertData"`           // Base64-encoded CA certificate data
+
+	// CPU and topology manager settings for latency sensitive (telco/NFV) workloads
+	CPUManagerPolicy      string `json:"cpuManagerPolicy,omitempty"`      // "none" (default) or "static"
+	ReservedCPUs          string `json:"reservedCpus,omitempty"`          // Explicit CPU list for system daemons, e.g. "0-1"
+	TopologyManagerPolicy string `json:"topologyManagerPolicy,omitempty"` // "none" (default), "best-effort", "restricted" or "single-numa-node"
+	TopologyManagerScope  string `json:"topologyManagerScope,omitempty"`  // "container" (default) or "pod"
+
+	// Soft eviction thresholds such as {"nodefs.available": "15%"}, each with a grace period such as {"nodefs.available": "2m"}
+	EvictionSoft            map[string]string `json:"evictionSoft,omitempty"`
+	EvictionSoftGracePeriod map[string]string `json:"evictionSoftGracePeriod,omitempty"`
+	ImageMinimumGCAge       string            `json:"imageMinimumGCAge,omitempty"` // Minimum age of an unused image before GC may remove it, e.g. "2m"
+
+	// Skip computing kube-reserved/system-reserved from the host's CPU and memory, and disk thresholds
+	// from the disk size; only configured values are used
+	DisableAutoReservation bool `json:"disableAutoReservation,omitempty"`
+
+	// Only part of the machine is for Kubernetes; the rest is reserved for workloads running beside it
+	SharedHost *SharedHostConfig `json:"sharedHost,omitempty"`
+}
+
+// SharedHostConfig limits the allocatable resources of a node whose machine also runs workloads outside
+// Kubernetes. The share not given to Kubernetes is added to system-reserved, so the scheduler never places
+// pods on capacity the other workloads use.
+type SharedHostConfig struct {
+	CPUPercent    int `json:"cpuPercent,omitempty"`    // Share of the CPUs for Kubernetes, 1-100 (default 100)
+	MemoryPercent int `json:"memoryPercent,omitempty"` // Share of the memory for Kubernetes, 1-100 (default 100)
+
+	// Also confine kubelet, the container runtime and all pods to the share with a systemd slice, so they
+	// cannot starve the other workloads at runtime
+	Isolate bool `json:"isolate,omitempty"`
 }
 
 // PathsConfig holds file system paths used by the agent for Kubernetes and CNI configurations.
@@ -133,43 +829,189 @@ type KubernetesPathsConfig struct {
 // CNIPathsConfig holds file system paths related to CNI plugins and configurations.
 type CNIConfig struct {
 	Version string `json:"version"`
+	// Pod interface MTU of the bridge configuration; 0 detects the path MTU toward the cluster
+	MTU int `json:"mtu,omitempty"`
 }
 
 // NPDConfig holds configuration settings for the Node Problem Detector (NPD).
 type NPDConfig struct {
 	Version string `json:"version"`
+
+	// Site-specific health checks run by NPD's custom plugin monitor, e.g. RAID controller or fan sensors
+	CustomPlugins []NPDPluginConfig `json:"customPlugins,omitempty"`
+
+	// NVIDIA GPU health checks (XID, ECC and thermal) reported as node conditions
+	GPUHealth NPDGPUHealthConfig `json:"gpuHealth"`
+
+	// Forwards NPD problem counters so fleet-wide problem rates are visible without scraping every node
+	Metrics NPDMetricsConfig `json:"metrics"`
+}
+
+// NPDGPUHealthConfig enables NPD plugins that check NVIDIA GPUs with the driver's nvidia-smi and kernel log.
+type NPDGPUHealthConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Interval       string `json:"interval,omitempty"`       // How often the checks run (defaults to 60s)
+	MaxTemperature int    `json:"maxTemperature,omitempty"` // GPU temperature in °C reported as a problem; 0 only reports driver thermal slowdown
+}
+
+// NPDMetricsConfig configures where the agent forwards the problem metrics NPD exposes on the node.
+type NPDMetricsConfig struct {
+	Sink            string `json:"sink,omitempty"`            // "" (disabled), "azure-monitor" or "prometheus-remote-write"
+	Interval        string `json:"interval,omitempty"`        // How often metrics are forwarded (defaults to 60s)
+	ResourceID      string `json:"resourceId,omitempty"`      // azure-monitor: resource the custom metrics are emitted for (defaults to the Arc machine)
+	Region          string `json:"region,omitempty"`          // azure-monitor: region of that resource (defaults to the Arc location)
+	RemoteWriteURL  string `json:"remoteWriteUrl,omitempty"`  // prometheus-remote-write: receiver URL
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"` // prometheus-remote-write: optional file holding a bearer token
+}
+
+// FluentBitConfig configures the optional fluent-bit log shipper, for clusters that don't run a logging DaemonSet on flex nodes.
+type FluentBitConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Version     string   `json:"version,omitempty"`     // fluent-bit release to install, e.g. "3.2.2" (defaults to the latest release)
+	Sources     []string `json:"sources,omitempty"`     // Logs to ship: "kubelet", "containerd" and "syslog" (defaults to all)
+	Destination string   `json:"destination,omitempty"` // "log-analytics" or "syslog"
+
+	WorkspaceID   string `json:"workspaceId,omitempty"`   // log-analytics: Log Analytics workspace ID
+	SharedKeyFile string `json:"sharedKeyFile,omitempty"` // log-analytics: file holding the workspace primary or secondary key
+	SharedKey     string `json:"sharedKey,omitempty"`     // log-analytics: secret reference to the key, instead of sharedKeyFile
+	LogType       string `json:"logType,omitempty"`       // log-analytics: custom log table name without the _CL suffix (defaults to AKSFlexNode)
+
+	SyslogHost string `json:"syslogHost,omitempty"` // syslog: receiver host name or address
+	SyslogPort int    `json:"syslogPort,omitempty"` // syslog: receiver port (defaults to 514, or 6514 for tls)
+	SyslogMode string `json:"syslogMode,omitempty"` // syslog: "tcp" (default), "udp" or "tls"
+}
+
+// SSHConfig hardens the SSH server of the node and optionally sets up break-glass access through Azure Arc,
+// for operators who must reach a node whose cluster connectivity is broken. Everything is reverted on unbootstrap.
+type SSHConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Profile    string   `json:"profile,omitempty"`    // "baseline" (default) allows key authentication only, "strict" also disables root login and forwarding
+	AllowUsers []string `json:"allowUsers,omitempty"` // Only these users may log in; the break-glass user is added (defaults to all users)
+	Banner     string   `json:"banner,omitempty"`     // Text shown before authentication, e.g. a legal notice
+
+	BreakGlass *SSHBreakGlassConfig `json:"breakGlass,omitempty"`
+}
+
+// SSHBreakGlassConfig creates a local user reachable with `az ssh arc` through the Arc agent, without
+// exposing the SSH port on the network. It requires Arc.
+type SSHBreakGlassConfig struct {
+	User               string `json:"user,omitempty"`     // Local user to create (defaults to aks-breakglass)
+	AuthorizedKeysFile string `json:"authorizedKeysFile"` // File holding the public keys allowed to log in as the user
+	Sudo               bool   `json:"sudo,omitempty"`     // Let the user run any command as root without a password
+}
+
+// SRIOVConfig creates SR-IOV virtual functions on the node's NICs and prepares them for the SR-IOV network
+// device plugin, which the cluster runs as a DaemonSet. VFs bound to vfio-pci are used by DPDK applications.
+type SRIOVConfig struct {
+	Enabled      bool             `json:"enabled"`
+	NICs         []SRIOVNICConfig `json:"nics,omitempty"`
+	Hugepages2Mi int              `json:"hugepages2Mi,omitempty"` // 2Mi hugepages DPDK applications need; node.hugepages.pages2Mi is raised to it
+}
+
+// SRIOVNICConfig declares the virtual functions of a physical NIC
+type SRIOVNICConfig struct {
+	Interface    string `json:"interface"`              // Network interface of the physical function, e.g. ens1f0
+	NumVFs       int    `json:"numVfs"`                 // Virtual functions to create
+	Driver       string `json:"driver,omitempty"`       // Driver the VFs are bound to, e.g. vfio-pci for DPDK (defaults to the NIC's VF driver)
+	ResourceName string `json:"resourceName,omitempty"` // Device plugin resource the VFs are advertised as (defaults to sriov_<interface>)
+}
+
+// NPDPluginConfig describes a custom NPD plugin script and the node condition it reports.
+// The script exits 0 when healthy, 1 when the problem is present and any other code when the state is unknown.
+type NPDPluginConfig struct {
+	Name       string `json:"name"`                 // Plugin name used for installed file names, e.g. "raid-health"
+	Script     string `json:"script,omitempty"`     // Inline script content
+	ScriptFile string `json:"scriptFile,omitempty"` // Path of the script on this machine, used instead of script
+	SHA256     string `json:"sha256,omitempty"`     // Expected SHA-256 of the script; installation fails on mismatch
+	Condition  string `json:"condition"`            // Node condition type set while the problem is present, e.g. "RAIDProblem"
+	Reason     string `json:"reason"`               // Condition reason while the problem is present, e.g. "RAIDDegraded"
+	Interval   string `json:"interval,omitempty"`   // How often the script runs (defaults to 60s)
+	Timeout    string `json:"timeout,omitempty"`    // Script timeout (defaults to 10s)
+	Temporary  bool   `json:"temporary,omitempty"`  // Report problems as events and problem_counter metrics instead of setting the condition
 }
 
 // IsSPConfigured checks if service principal credentials are provided in the configuration
 func (cfg *Config) IsSPConfigured() bool {
 	return cfg.Azure.ServicePrincipal != nil &&
 		cfg.Azure.ServicePrincipal.ClientID != "" &&
-		cfg.Azure.ServicePrincipal.ClientSecret != "" &&
+		(cfg.Azure.ServicePrincipal.ClientSecret != "" || cfg.Azure.ServicePrincipal.ClientCertificateFile != "") &&
 		cfg.Azure.ServicePrincipal.TenantID != ""
 }
 
+// IsCredentialRotationConfigured checks if a source for new service principal credentials is configured
+func (cfg *Config) IsCredentialRotationConfigured() bool {
+	return cfg.IsSPConfigured() && cfg.Azure.ServicePrincipal.Rotation != nil &&
+		(cfg.Azure.ServicePrincipal.Rotation.KeyVaultSecretID != "" || cfg.Azure.ServicePrincipal.Rotation.File != "" ||
+			cfg.Azure.ServicePrincipal.Rotation.Secret != "")
+}
+
+// GetCredentialRotationInterval returns how often the agent checks for a new service principal credential
+func (cfg *Config) GetCredentialRotationInterval() time.Duration {
+	// Validated at config load
+	if cfg.Azure.ServicePrincipal != nil && cfg.Azure.ServicePrincipal.Rotation != nil {
+		if interval, err := time.ParseDuration(cfg.Azure.ServicePrincipal.Rotation.Interval); err == nil {
+			return interval
+		}
+	}
+	return time.Hour
+}
+
 // IsMIConfigured checks if managed identity configuration is provided in the configuration
 // Uses internal flag set during config loading to handle viper's empty object behavior
 func (cfg *Config) IsMIConfigured() bool {
 	return cfg.isMIExplicitlySet
 }
 
-// IsBootstrapTokenConfigured checks if bootstrap token credentials are provided in the configuration
+// UseManagedIdentity selects managed identity authentication, optionally for the identity with the given client ID
+func (cfg *Config) UseManagedIdentity(clientID string) {
+	cfg.Azure.ManagedIdentity = &ManagedIdentityConfig{ClientID: clientID}
+	cfg.isMIExplicitlySet = true
+}
+
+// IsBootstrapTokenConfigured checks if bootstrap token credentials are provided in the configuration,
+// either the token itself or a broker it is requested from
 func (cfg *Config) IsBootstrapTokenConfigured() bool {
 	return cfg.Azure.BootstrapToken != nil &&
-		cfg.Azure.BootstrapToken.Token != ""
+		(cfg.Azure.BootstrapToken.Token != "" || cfg.Azure.BootstrapToken.Broker != nil)
 }
 
-// GetArcMachineName returns the Arc machine name from configuration or defaults to the system hostname
-func (cfg *Config) GetArcMachineName() string {
-	if cfg.Azure.Arc != nil && cfg.Azure.Arc.MachineName != "" {
-		return cfg.Azure.Arc.MachineName
+// IsNodeCertificateConfigured checks if the kubelet authenticates with an issued client certificate
+func (cfg *Config) IsNodeCertificateConfigured() bool {
+	return cfg.Azure.NodeCertificate != nil
+}
+
+// IsKubernetesCredentialConfigured checks if the kubelet authenticates with a Kubernetes credential, a bootstrap
+// token or a node certificate, rather than an Azure identity. There is then no credential to read ARM with.
+func (cfg *Config) IsKubernetesCredentialConfigured() bool {
+	return cfg.IsBootstrapTokenConfigured() || cfg.IsNodeCertificateConfigured()
+}
+
+// GetNodeCertificateRenewBefore returns how long before expiry the node certificate is renewed, or 0 for a
+// third of its lifetime
+func (cfg *Config) GetNodeCertificateRenewBefore() time.Duration {
+	if cfg.Azure.NodeCertificate == nil {
+		return 0
 	}
-	hostname, err := os.Hostname()
-	if err == nil {
-		return hostname
+	d, err := time.ParseDuration(cfg.Azure.NodeCertificate.RenewBefore)
+	if err != nil {
+		return 0
 	}
-	return ""
+	return d
+}
+
+// IsBootstrapTokenBrokerConfigured checks if the bootstrap token is requested from a broker
+func (cfg *Config) IsBootstrapTokenBrokerConfigured() bool {
+	return cfg.Azure.BootstrapToken != nil && cfg.Azure.BootstrapToken.Broker != nil
+}
+
+// GetArcMachineName returns the Arc machine name derived with azure.arc.namingStrategy, which defaults to the
+// system hostname, or "" when it cannot be derived
+func (cfg *Config) GetArcMachineName() string {
+	name, err := cfg.ResolveArcMachineName()
+	if err != nil {
+		return ""
+	}
+	return name
 }
 
 // GetTargetClusterName returns the target AKS cluster name from configuration
@@ -180,6 +1022,22 @@ func (cfg *Config) GetTargetClusterName() string {
 	return ""
 }
 
+// GetClusterNames returns the names of the clusters in azure.clusters, sorted
+func (cfg *Config) GetClusterNames() []string {
+	names := make([]string, 0, len(cfg.Azure.Clusters))
+	for name := range cfg.Azure.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetCurrentCluster returns the name of the cluster of azure.clusters the node is a member of,
+// or "" when a single targetCluster is configured
+func (cfg *Config) GetCurrentCluster() string {
+	return cfg.Azure.CurrentCluster
+}
+
 // GetTargetClusterSubscriptionID returns the target AKS cluster subscription ID from configuration
 func (cfg *Config) GetTargetClusterSubscriptionID() string {
 	if cfg.Azure.TargetCluster != nil && cfg.Azure.TargetCluster.SubscriptionID != "" {
@@ -196,6 +1054,44 @@ func (cfg *Config) GetTargetClusterResourceGroup() string {
 	return ""
 }
 
+// GetTargetClusterNodeResourceGroup returns the target AKS cluster node (MC_) resource group from configuration
+func (cfg *Config) GetTargetClusterNodeResourceGroup() string {
+	if cfg.Azure.TargetCluster != nil && cfg.Azure.TargetCluster.NodeResourceGroup != "" {
+		return cfg.Azure.TargetCluster.NodeResourceGroup
+	}
+	return ""
+}
+
+// GetTargetClusterTenantID returns the AAD tenant owning the target cluster subscription, defaulting to the node's tenant
+func (cfg *Config) GetTargetClusterTenantID() string {
+	if cfg.Azure.TargetCluster != nil && cfg.Azure.TargetCluster.TenantID != "" {
+		return cfg.Azure.TargetCluster.TenantID
+	}
+	return cfg.GetTenantID()
+}
+
+// IsCrossTenant checks if the target cluster lives in a different AAD tenant than the node's identity
+func (cfg *Config) IsCrossTenant() bool {
+	return !strings.EqualFold(cfg.GetTargetClusterTenantID(), cfg.GetTenantID())
+}
+
+// GetAuxiliaryTenantIDs returns the tenants, other than the node's own, that ARM requests need tokens for.
+// The target cluster tenant is included when onboarding across tenants.
+func (cfg *Config) GetAuxiliaryTenantIDs() []string {
+	var tenants []string
+	seen := map[string]bool{strings.ToLower(cfg.GetTenantID()): true}
+	candidates := append([]string{cfg.GetTargetClusterTenantID()}, cfg.Azure.AuxiliaryTenantIDs...)
+	for _, tenantID := range candidates {
+		key := strings.ToLower(tenantID)
+		if tenantID == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tenants = append(tenants, tenantID)
+	}
+	return tenants
+}
+
 // GetTargetClusterLocation returns the target AKS cluster location from configuration
 func (cfg *Config) GetTargetClusterLocation() string {
 	if cfg.Azure.TargetCluster != nil && cfg.Azure.TargetCluster.Location != "" {
@@ -237,6 +1133,330 @@ func (cfg *Config) GetArcTags() map[string]string {
 	return map[string]string{}
 }
 
+// Labels and Arc tags describing the node pool, matching what AKS sets on agent pool nodes
+const (
+	AgentPoolLabel       = "agentpool"
+	AKSAgentPoolLabel    = "kubernetes.azure.com/agentpool"
+	AKSNodePoolModeLabel = "kubernetes.azure.com/mode"
+
+	NodePoolTag     = "aks-flex-node-pool"
+	NodePoolModeTag = "aks-flex-node-pool-mode"
+	ClusterTag      = "aks-flex-node-cluster"
+)
+
+// IsNodePoolConfigured returns true when the node belongs to a named external pool
+func (cfg *Config) IsNodePoolConfigured() bool {
+	return cfg.Node.Pool.Name != ""
+}
+
+// GetNodePoolMode returns the node pool mode, defaulting to user
+func (cfg *Config) GetNodePoolMode() string {
+	if cfg.Node.Pool.Mode == "" {
+		return "user"
+	}
+	return cfg.Node.Pool.Mode
+}
+
+// GetNodeLabels returns the labels registered with the node: the configured labels plus the
+// agent pool labels when a pool is configured
+func (cfg *Config) GetNodeLabels() map[string]string {
+	labels := make(map[string]string, len(cfg.Node.Labels)+3)
+	for key, value := range cfg.Node.Labels {
+		labels[key] = value
+	}
+	if cfg.IsNodePoolConfigured() {
+		labels[AgentPoolLabel] = cfg.Node.Pool.Name
+		labels[AKSAgentPoolLabel] = cfg.Node.Pool.Name
+		labels[AKSNodePoolModeLabel] = cfg.GetNodePoolMode()
+	}
+	return labels
+}
+
+// GetNodeTaints returns the taints registered with the node
+func (cfg *Config) GetNodeTaints() []string {
+	return cfg.Node.Pool.Taints
+}
+
+// GetArcMachineTags returns the tags applied to the Arc machine: the configured tags plus the
+// node pool metadata and current cluster, so the pool's machines can be found in Azure
+func (cfg *Config) GetArcMachineTags() map[string]string {
+	tags := make(map[string]string)
+	if cfg.IsNodePoolConfigured() {
+		tags[NodePoolTag] = cfg.Node.Pool.Name
+		tags[NodePoolModeTag] = cfg.GetNodePoolMode()
+	}
+	// A node that can move between clusters records which one it is a member of
+	if cfg.GetCurrentCluster() != "" && cfg.Azure.TargetCluster != nil {
+		tags[ClusterTag] = cfg.Azure.TargetCluster.ResourceID
+	}
+	for key, value := range cfg.GetArcTags() {
+		tags[key] = value
+	}
+	return tags
+}
+
+// GetArcRoleAssignments returns the additional role assignments configured for the Arc managed identity
+func (cfg *Config) GetArcRoleAssignments() []RoleAssignmentConfig {
+	if cfg.Azure.Arc != nil {
+		return cfg.Azure.Arc.RoleAssignments
+	}
+	return nil
+}
+
+// IsArcPrincipalVerificationEnabled checks if principals should be verified in Microsoft Graph before role assignment
+func (cfg *Config) IsArcPrincipalVerificationEnabled() bool {
+	return cfg.Azure.Arc != nil && cfg.Azure.Arc.VerifyPrincipal
+}
+
+// GetArcExtensions returns the extensions declared for the Arc machine
+func (cfg *Config) GetArcExtensions() []ArcExtensionConfig {
+	if cfg.Azure.Arc != nil {
+		return cfg.Azure.Arc.Extensions
+	}
+	return nil
+}
+
+// IsArcRoleAssignmentPruneEnabled checks if stale agent-created role assignments should be pruned
+func (cfg *Config) IsArcRoleAssignmentPruneEnabled() bool {
+	return cfg.Azure.Arc != nil && cfg.Azure.Arc.PruneRoleAssignments
+}
+
+// GetArcReinstallStrategy returns how a stale Arc machine resource is handled, defaulting to fail
+func (cfg *Config) GetArcReinstallStrategy() string {
+	if cfg.Azure.Arc == nil || cfg.Azure.Arc.ReinstallStrategy == "" {
+		return "fail"
+	}
+	return cfg.Azure.Arc.ReinstallStrategy
+}
+
+// ScopeTemplateVariables returns the values available as placeholders in role assignment scope templates
+func (cfg *Config) ScopeTemplateVariables() map[string]string {
+	return map[string]string{
+		"subscriptionId":        cfg.GetSubscriptionID(),
+		"resourceGroup":         cfg.GetTargetClusterResourceGroup(),
+		"nodeResourceGroup":     cfg.GetTargetClusterNodeResourceGroup(),
+		"clusterSubscriptionId": cfg.GetTargetClusterSubscriptionID(),
+		"clusterName":           cfg.GetTargetClusterName(),
+		"clusterId":             cfg.GetTargetClusterID(),
+		"arcResourceGroup":      cfg.GetArcResourceGroup(),
+	}
+}
+
+// IsCATrustConfigured checks if any custom CA certificate source is configured
+func (cfg *Config) IsCATrustConfigured() bool {
+	return len(cfg.CATrust.Certificates) > 0 ||
+		len(cfg.CATrust.CertificateFiles) > 0 ||
+		(cfg.CATrust.KeyVault != nil && len(cfg.CATrust.KeyVault.CertificateNames) > 0)
+}
+
+// IsDNSConfigured checks if custom upstream DNS servers or search domains are configured
+func (cfg *Config) IsDNSConfigured() bool {
+	return len(cfg.Node.DNS.Upstreams) > 0 || len(cfg.Node.DNS.SearchDomains) > 0
+}
+
+// IsPrivateLinkEnabled checks if Azure traffic is declared to go over private endpoints
+func (cfg *Config) IsPrivateLinkEnabled() bool {
+	return cfg.Azure.PrivateLink != nil && cfg.Azure.PrivateLink.Enabled
+}
+
+// GetArcPrivateLinkScopeID returns the Arc private link scope resource ID when private link is enabled
+func (cfg *Config) GetArcPrivateLinkScopeID() string {
+	if cfg.IsPrivateLinkEnabled() {
+		return cfg.Azure.PrivateLink.ArcPrivateLinkScopeID
+	}
+	return ""
+}
+
+// GetConflictingAgentsMode returns how preflight handles conflicting agents, defaulting to abort
+func (cfg *Config) GetConflictingAgentsMode() string {
+	if cfg.Preflight.ConflictingAgents == "" {
+		return "abort"
+	}
+	return cfg.Preflight.ConflictingAgents
+}
+
+// GetClusterCompatibilityMode returns how preflight handles cluster incompatibilities, defaulting to enforce
+func (cfg *Config) GetClusterCompatibilityMode() string {
+	if cfg.Preflight.ClusterCompatibility == "" {
+		return "enforce"
+	}
+	return cfg.Preflight.ClusterCompatibility
+}
+
+// GetInstallLimitProperties returns the configured install limits as systemd resource control properties,
+// such as "CPUQuota=50%", in a fixed order
+func (cfg *Config) GetInstallLimitProperties() []string {
+	limits := cfg.Agent.InstallLimits
+	var properties []string
+	if limits.CPUQuota != "" {
+		properties = append(properties, "CPUQuota="+limits.CPUQuota)
+	}
+	if limits.IOWeight != 0 {
+		properties = append(properties, "IOWeight="+strconv.Itoa(limits.IOWeight))
+	}
+	if limits.MemoryMax != "" {
+		properties = append(properties, "MemoryMax="+limits.MemoryMax)
+	}
+	return properties
+}
+
+// GetAPIServerPathMode returns how API server path problems are handled, defaulting to "enforce"
+func (cfg *Config) GetAPIServerPathMode() string {
+	if cfg.Preflight.APIServerPath == nil || cfg.Preflight.APIServerPath.Mode == "" {
+		return "enforce"
+	}
+	return cfg.Preflight.APIServerPath.Mode
+}
+
+// GetAPIServerMaxLatency returns the highest acceptable connect time to the API server, defaulting to 250ms
+func (cfg *Config) GetAPIServerMaxLatency() time.Duration {
+	if cfg.Preflight.APIServerPath == nil || cfg.Preflight.APIServerPath.MaxLatencyMs == 0 {
+		return 250 * time.Millisecond
+	}
+	return time.Duration(cfg.Preflight.APIServerPath.MaxLatencyMs) * time.Millisecond
+}
+
+// GetAPIServerMinPathMTU returns the smallest acceptable path MTU to the API server, defaulting to 1280
+func (cfg *Config) GetAPIServerMinPathMTU() int {
+	if cfg.Preflight.APIServerPath == nil || cfg.Preflight.APIServerPath.MinPathMTU == 0 {
+		return 1280
+	}
+	return cfg.Preflight.APIServerPath.MinPathMTU
+}
+
+// IsNPDMetricsExportEnabled returns true when NPD problem metrics are forwarded to a metrics sink
+func (cfg *Config) IsNPDMetricsExportEnabled() bool {
+	return cfg.Npd.Metrics.Sink != ""
+}
+
+// GetFluentBitSources returns the logs fluent-bit ships, defaulting to all supported sources
+func (cfg *Config) GetFluentBitSources() []string {
+	if len(cfg.FluentBit.Sources) == 0 {
+		return []string{"kubelet", "containerd", "syslog"}
+	}
+	return cfg.FluentBit.Sources
+}
+
+// GetSSHProfile returns the SSH hardening profile, defaulting to baseline
+func (cfg *Config) GetSSHProfile() string {
+	if cfg.SSH.Profile == "" {
+		return "baseline"
+	}
+	return cfg.SSH.Profile
+}
+
+// IsSSHBreakGlassEnabled returns true when SSH hardening sets up a break-glass user
+func (cfg *Config) IsSSHBreakGlassEnabled() bool {
+	return cfg.SSH.Enabled && cfg.SSH.BreakGlass != nil
+}
+
+// GetSSHBreakGlassUser returns the break-glass user name, defaulting to aks-breakglass
+func (cfg *Config) GetSSHBreakGlassUser() string {
+	if cfg.SSH.BreakGlass == nil || cfg.SSH.BreakGlass.User == "" {
+		return "aks-breakglass"
+	}
+	return cfg.SSH.BreakGlass.User
+}
+
+// GetRebootPolicy returns how reboots requested by bootstrap steps are carried out, defaulting to manual
+func (cfg *Config) GetRebootPolicy() string {
+	if cfg.Agent.Reboot.Policy == "" {
+		return "manual"
+	}
+	return cfg.Agent.Reboot.Policy
+}
+
+// GetDriftCheckInterval returns how often the agent checks installed files for drift
+func (cfg *Config) GetDriftCheckInterval() time.Duration {
+	// Validated at config load
+	if interval, err := time.ParseDuration(cfg.Agent.Drift.Interval); err == nil {
+		return interval
+	}
+	return 10 * time.Minute
+}
+
+// GetPatchingCheckInterval returns how often the agent checks for OS updates waiting for a reboot
+func (cfg *Config) GetPatchingCheckInterval() time.Duration {
+	// Validated at config load
+	if interval, err := time.ParseDuration(cfg.Agent.Patching.Interval); err == nil {
+		return interval
+	}
+	return 15 * time.Minute
+}
+
+// GetRoleAssignmentCheckInterval returns how often the agent checks that the node's role assignments still exist
+func (cfg *Config) GetRoleAssignmentCheckInterval() time.Duration {
+	// Validated at config load
+	if interval, err := time.ParseDuration(cfg.Agent.RoleAssignments.Interval); err == nil {
+		return interval
+	}
+	return 30 * time.Minute
+}
+
+// GetHeartbeatInterval returns how often the agent publishes its heartbeat on the node
+func (cfg *Config) GetHeartbeatInterval() time.Duration {
+	// Validated at config load
+	if interval, err := time.ParseDuration(cfg.Agent.Heartbeat.Interval); err == nil {
+		return interval
+	}
+	return time.Minute
+}
+
+// GetConfigPath returns the path the configuration was loaded from
+func (cfg *Config) GetConfigPath() string {
+	return cfg.path
+}
+
+// IsWebhookEnabled reports whether the agent listens for provisioning actions
+func (cfg *Config) IsWebhookEnabled() bool {
+	return cfg.Agent.Webhook.ListenAddress != ""
+}
+
+// GetWebhookAuditLogPath returns the file every webhook request is recorded in
+func (cfg *Config) GetWebhookAuditLogPath() string {
+	return filepath.Join(cfg.Agent.LogDir, "webhook-audit.log")
+}
+
+// IsSpecSourceConfigured reports whether the agent pulls its NodeSpec from a Git repository or OCI registry
+func (cfg *Config) IsSpecSourceConfigured() bool {
+	return cfg.Agent.Source.Git != nil || cfg.Agent.Source.OCI != nil
+}
+
+// GetSpecSourceInterval returns how often the agent polls its NodeSpec source
+func (cfg *Config) GetSpecSourceInterval() time.Duration {
+	// Validated at config load
+	if interval, err := time.ParseDuration(cfg.Agent.Source.Interval); err == nil {
+		return interval
+	}
+	return 5 * time.Minute
+}
+
+// GetNodeSpecName returns the name of the NodeSpec the configuration was loaded from, or "" for a plain config file
+func (cfg *Config) GetNodeSpecName() string {
+	return cfg.nodeSpecName
+}
+
+// IsPrerequisitesDeployEnabled checks if bootstrap creates the declared prerequisite Azure resources
+func (cfg *Config) IsPrerequisitesDeployEnabled() bool {
+	return cfg.Azure.Prerequisites != nil && cfg.Azure.Prerequisites.Deploy
+}
+
+// GetPrerequisitesResourceGroup returns the resource group of the prerequisites, defaulting to the Arc machine's
+func (cfg *Config) GetPrerequisitesResourceGroup() string {
+	if cfg.Azure.Prerequisites != nil && cfg.Azure.Prerequisites.ResourceGroup != "" {
+		return cfg.Azure.Prerequisites.ResourceGroup
+	}
+	return cfg.GetArcResourceGroup()
+}
+
+// GetPrerequisitesLocation returns the region of the prerequisites, defaulting to the Arc machine's
+func (cfg *Config) GetPrerequisitesLocation() string {
+	if cfg.Azure.Prerequisites != nil && cfg.Azure.Prerequisites.Location != "" {
+		return cfg.Azure.Prerequisites.Location
+	}
+	return cfg.GetArcLocation()
+}
+
 // GetSubscriptionID returns the Azure subscription ID from configuration
 func (cfg *Config) GetSubscriptionID() string {
 	return cfg.Azure.SubscriptionID
@@ -252,6 +1472,252 @@ func (cfg *Config) GetKubernetesVersion() string {
 	return cfg.Kubernetes.Version
 }
 
+// GetHugepages2Mi returns the 2Mi hugepages to reserve: node.hugepages.pages2Mi, raised to what DPDK needs
+// when SR-IOV is enabled
+func (cfg *Config) GetHugepages2Mi() int {
+	if cfg.SRIOV.Enabled && cfg.SRIOV.Hugepages2Mi > cfg.Node.Hugepages.Pages2Mi {
+		return cfg.SRIOV.Hugepages2Mi
+	}
+	return cfg.Node.Hugepages.Pages2Mi
+}
+
+// GetSRIOVResourceName returns the device plugin resource the VFs of nic are advertised as
+func (cfg *Config) GetSRIOVResourceName(nic SRIOVNICConfig) string {
+	if nic.ResourceName != "" {
+		return nic.ResourceName
+	}
+	return "sriov_" + strings.NewReplacer("-", "_", ".", "_", ":", "_").Replace(nic.Interface)
+}
+
+// GetCustomScriptTimeout returns how long a custom script may run, defaulting to 5 minutes
+func (cfg *Config) GetCustomScriptTimeout(script CustomScriptConfig) time.Duration {
+	if timeout, err := time.ParseDuration(script.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return 5 * time.Minute
+}
+
+// GetIPFamilies returns the node's IP families, the primary one first
+func (cfg *Config) GetIPFamilies() []string {
+	if len(cfg.Node.Network.IPFamilies) == 0 {
+		return []string{IPFamilyIPv4}
+	}
+	return cfg.Node.Network.IPFamilies
+}
+
+// HasIPFamily returns true when the node takes part in the IP family
+func (cfg *Config) HasIPFamily(family string) bool {
+	for _, f := range cfg.GetIPFamilies() {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWorkloadIsolationEnabled returns true when kubelet, the container runtime and pods are confined to the
+// Kubernetes share of a shared host
+func (cfg *Config) IsWorkloadIsolationEnabled() bool {
+	return cfg.Node.Kubelet.SharedHost != nil && cfg.Node.Kubelet.SharedHost.Isolate
+}
+
+// GetCgroupDriver returns the cgroup driver of kubelet and the container runtime, defaulting to systemd
+func (cfg *Config) GetCgroupDriver() string {
+	if cfg.Node.Cgroup.Driver == "" {
+		return "systemd"
+	}
+	return cfg.Node.Cgroup.Driver
+}
+
+// GetContainerRuntime returns the container runtime kubelet uses, defaulting to containerd
+func (cfg *Config) GetContainerRuntime() string {
+	if cfg.ContainerRuntime == "" {
+		return "containerd"
+	}
+	return cfg.ContainerRuntime
+}
+
+// GetImagePrePullTimeout returns the time allowed for each pre-pulled image, defaulting to 5 minutes
+func (cfg *Config) GetImagePrePullTimeout() time.Duration {
+	// Validated at config load
+	if timeout, err := time.ParseDuration(cfg.ImagePrePull.Timeout); err == nil {
+		return timeout
+	}
+	return 5 * time.Minute
+}
+
+// GetDownloadCacheDirectory returns the directory of the artifact cache
+func (cfg *Config) GetDownloadCacheDirectory() string {
+	if cfg.Downloads.Cache.Directory != "" {
+		return cfg.Downloads.Cache.Directory
+	}
+	return "/var/cache/aks-flex-node/artifacts"
+}
+
+// GetTemplatesDirectory returns the directory holding template overrides
+func (cfg *Config) GetTemplatesDirectory() string {
+	if cfg.Templates.Directory != "" {
+		return cfg.Templates.Directory
+	}
+	return "/etc/aks-flex-node/templates"
+}
+
+// GetDownloadRateLimits returns the global and per-artifact download rates in bytes per second; 0 means unlimited
+func (cfg *Config) GetDownloadRateLimits() (global, perArtifact int64) {
+	// Validated at config load
+	parse := func(value string) int64 {
+		if q, err := resource.ParseQuantity(value); err == nil {
+			return q.Value()
+		}
+		return 0
+	}
+	return parse(cfg.Downloads.RateLimit.Global), parse(cfg.Downloads.RateLimit.PerArtifact)
+}
+
+// GetArtifactURL returns the URL of the artifact of a component version for this OS and arch from the version
+// manifest, or upstream when the manifest has none
+func (cfg *Config) GetArtifactURL(component, version, arch, upstream string) string {
+	for _, artifacts := range cfg.Downloads.Artifacts {
+		if artifacts.Component != component || strings.TrimPrefix(artifacts.Version, "v") != strings.TrimPrefix(version, "v") {
+			continue
+		}
+		for _, artifact := range artifacts.Platforms {
+			if artifact.OS == runtime.GOOS && artifact.Arch == arch {
+				return artifact.URL
+			}
+		}
+	}
+	return upstream
+}
+
+// GetArtifactChecksum returns the pinned SHA-256 digest of the artifact at url, from downloads.checksums or the
+// version manifest, or "" when none is configured
+func (cfg *Config) GetArtifactChecksum(url string) string {
+	for _, checksum := range cfg.Downloads.Checksums {
+		if checksum.URL == url {
+			return strings.ToLower(checksum.SHA256)
+		}
+	}
+	for _, artifacts := range cfg.Downloads.Artifacts {
+		for _, artifact := range artifacts.Platforms {
+			if artifact.URL == url && artifact.SHA256 != "" {
+				return strings.ToLower(artifact.SHA256)
+			}
+		}
+	}
+	return ""
+}
+
+// GetAzureTryTimeout returns the time allowed for each HTTP attempt of an Azure API call, defaulting to 1 minute
+func (cfg *Config) GetAzureTryTimeout() time.Duration {
+	// Validated at config load
+	if cfg.Azure.Timeouts != nil {
+		if timeout, err := time.ParseDuration(cfg.Azure.Timeouts.PerTry); err == nil {
+			return timeout
+		}
+	}
+	return time.Minute
+}
+
+// GetCanaryNamespace returns the namespace of the smoke pod, defaulting to default
+func (cfg *Config) GetCanaryNamespace() string {
+	if cfg.Agent.Canary.Namespace != "" {
+		return cfg.Agent.Canary.Namespace
+	}
+	return "default"
+}
+
+// GetCanaryImage returns the image of the smoke pod
+func (cfg *Config) GetCanaryImage() string {
+	if cfg.Agent.Canary.Image != "" {
+		return cfg.Agent.Canary.Image
+	}
+	return "mcr.microsoft.com/azurelinux/busybox:1.36"
+}
+
+// GetCanaryTimeout returns the time allowed for the smoke pod, defaulting to 3 minutes
+func (cfg *Config) GetCanaryTimeout() time.Duration {
+	// Validated at config load
+	if timeout, err := time.ParseDuration(cfg.Agent.Canary.Timeout); err == nil {
+		return timeout
+	}
+	return 3 * time.Minute
+}
+
+// GetObserveWindow returns how long bootstrap watches the node after the services started, 0 when it does not
+func (cfg *Config) GetObserveWindow() time.Duration {
+	// Validated at config load
+	if window, err := time.ParseDuration(cfg.Agent.Observe.Window); err == nil {
+		return window
+	}
+	return 0
+}
+
+// GetObserveInterval returns the time between checks of the observation window, defaulting to 15 seconds
+func (cfg *Config) GetObserveInterval() time.Duration {
+	// Validated at config load
+	if interval, err := time.ParseDuration(cfg.Agent.Observe.Interval); err == nil {
+		return interval
+	}
+	return 15 * time.Second
+}
+
+// GetObserveOnFailure returns what happens when the node fails its observation window, defaulting to fail
+func (cfg *Config) GetObserveOnFailure() string {
+	if cfg.Agent.Observe.OnFailure == "" {
+		return "fail"
+	}
+	return cfg.Agent.Observe.OnFailure
+}
+
+// GetARMWriteConcurrency returns how many ARM writes the fleet may send to one subscription at once, defaulting to 10
+func (cfg *Config) GetARMWriteConcurrency() int {
+	if cfg.Azure.ARMWriteLimit != nil && cfg.Azure.ARMWriteLimit.MaxConcurrent > 0 {
+		return cfg.Azure.ARMWriteLimit.MaxConcurrent
+	}
+	return 10
+}
+
+// GetAzureOperationTimeout returns the deadline for one Azure API operation including SDK retries,
+// defaulting to 5 minutes
+func (cfg *Config) GetAzureOperationTimeout() time.Duration {
+	// Validated at config load
+	if cfg.Azure.Timeouts != nil {
+		if timeout, err := time.ParseDuration(cfg.Azure.Timeouts.Operation); err == nil {
+			return timeout
+		}
+	}
+	return 5 * time.Minute
+}
+
+// GetImagePrePullParallelism returns how many images are pulled concurrently, defaulting to 3
+func (cfg *Config) GetImagePrePullParallelism() int {
+	if cfg.ImagePrePull.Parallelism <= 0 {
+		return 3
+	}
+	return cfg.ImagePrePull.Parallelism
+}
+
+// GetCRIOVersion returns the CRI-O release to install. CRI-O minor versions follow Kubernetes,
+// so it defaults to the first patch release of the configured Kubernetes minor version.
+func (cfg *Config) GetCRIOVersion() string {
+	if cfg.CRIO.Version != "" {
+		return cfg.CRIO.Version
+	}
+	parts := strings.Split(strings.TrimPrefix(cfg.Kubernetes.Version, "v"), ".")
+	if len(parts) < 2 {
+		return "1.32.0"
+	}
+	return parts[0] + "." + parts[1] + ".0"
+}
+
+// IsComponentEnabled checks if bootstrap installs the built-in component name; only components.enabled turns
+// one off
+func (cfg *Config) IsComponentEnabled(name string) bool {
+	enabled, ok := cfg.Components.Enabled[name]
+	return !ok || enabled
+}
+
 // IsARCEnabled checks if Azure Arc registration is enabled in the configuration
 func (cfg *Config) IsARCEnabled() bool {
 	return cfg.Azure.Arc != nil && cfg.Azure.Arc.Enabled
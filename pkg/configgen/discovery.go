@@ -0,0 +1,142 @@
+package configgen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// Subscription is an Azure subscription the credential can see
+type Subscription struct {
+	ID       string
+	Name     string
+	TenantID string
+}
+
+// ResourceGroup is a resource group in the selected subscription
+type ResourceGroup struct {
+	Name     string
+	Location string
+}
+
+// Cluster is an AKS cluster in the selected subscription
+type Cluster struct {
+	ID                string
+	Name              string
+	ResourceGroup     string
+	Location          string
+	KubernetesVersion string
+}
+
+// Discovery lists the Azure resources offered as choices
+type Discovery interface {
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	ResourceGroups(ctx context.Context, subscriptionID string) ([]ResourceGroup, error)
+	Clusters(ctx context.Context, subscriptionID string) ([]Cluster, error)
+}
+
+// armDiscovery lists resources through Azure Resource Manager
+type armDiscovery struct {
+	cred azcore.TokenCredential
+}
+
+// NewARMDiscovery creates a Discovery backed by ARM using the given credential
+func NewARMDiscovery(cred azcore.TokenCredential) Discovery {
+	return &armDiscovery{cred: cred}
+}
+
+func (d *armDiscovery) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	client, err := armsubscriptions.NewClient(d.cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscriptions client: %w", err)
+	}
+	var subscriptions []Subscription
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+		for _, sub := range page.Value {
+			subscriptions = append(subscriptions, Subscription{
+				ID:       to.String(sub.SubscriptionID),
+				Name:     to.String(sub.DisplayName),
+				TenantID: to.String(sub.TenantID),
+			})
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return strings.ToLower(subscriptions[i].Name) < strings.ToLower(subscriptions[j].Name)
+	})
+	return subscriptions, nil
+}
+
+func (d *armDiscovery) ResourceGroups(ctx context.Context, subscriptionID string) ([]ResourceGroup, error) {
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, d.cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource groups client: %w", err)
+	}
+	var groups []ResourceGroup
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resource groups in subscription %s: %w", subscriptionID, err)
+		}
+		for _, group := range page.Value {
+			groups = append(groups, ResourceGroup{Name: to.String(group.Name), Location: to.String(group.Location)})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name) })
+	return groups, nil
+}
+
+func (d *armDiscovery) Clusters(ctx context.Context, subscriptionID string) ([]Cluster, error) {
+	client, err := armcontainerservice.NewManagedClustersClient(subscriptionID, d.cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed clusters client: %w", err)
+	}
+	var clusters []Cluster
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list AKS clusters in subscription %s: %w", subscriptionID, err)
+		}
+		for _, mc := range page.Value {
+			cluster := Cluster{
+				ID:            to.String(mc.ID),
+				Name:          to.String(mc.Name),
+				ResourceGroup: resourceGroupFromID(to.String(mc.ID)),
+				Location:      to.String(mc.Location),
+			}
+			if mc.Properties != nil {
+				cluster.KubernetesVersion = to.String(mc.Properties.CurrentKubernetesVersion)
+				if cluster.KubernetesVersion == "" {
+					cluster.KubernetesVersion = to.String(mc.Properties.KubernetesVersion)
+				}
+			}
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return strings.ToLower(clusters[i].ID) < strings.ToLower(clusters[j].ID) })
+	return clusters, nil
+}
+
+// resourceGroupFromID returns the resource group segment of an ARM resource ID
+func resourceGroupFromID(id string) string {
+	parts := strings.Split(id, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
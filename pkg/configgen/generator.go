@@ -0,0 +1,441 @@
+// Package configgen generates the agent configuration file for `aks-flex-node init`, offering the
+// subscriptions, clusters and resource groups the credential can see and validating the result.
+package configgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Authentication methods offered by the generator, matching the agent's mutually exclusive auth settings
+const (
+	AuthArc              = "arc"
+	AuthServicePrincipal = "service-principal"
+	AuthManagedIdentity  = "managed-identity"
+	AuthBootstrapToken   = "bootstrap-token"
+)
+
+var authMethods = []string{AuthArc, AuthServicePrincipal, AuthManagedIdentity, AuthBootstrapToken}
+
+// Options holds the values given as flags; empty values are discovered or asked for
+type Options struct {
+	Auth              string
+	SubscriptionID    string
+	TenantID          string
+	ClusterResourceID string
+
+	ClientID                string // service principal
+	ClientSecret            string // service principal
+	ManagedIdentityClientID string // managed identity, optional
+
+	ArcResourceGroup string
+	ArcLocation      string // defaults to the cluster location
+	ArcMachineName   string // defaults to the hostname
+
+	BootstrapToken string
+	ServerURL      string
+	CACertData     string
+}
+
+// Generator builds a configuration file from flags, ARM discovery and prompts
+type Generator struct {
+	opts   Options
+	prompt Prompter
+
+	newDiscovery func(cfg *config.Config) (Discovery, error)
+	hostname     func() (string, error)
+}
+
+// NewGenerator creates a Generator that discovers Azure resources with the credential the options select:
+// the service principal, the managed identity, or the Azure CLI login
+func NewGenerator(opts Options, prompt Prompter) *Generator {
+	return &Generator{
+		opts:   opts,
+		prompt: prompt,
+		newDiscovery: func(cfg *config.Config) (Discovery, error) {
+			cred, err := auth.NewAuthProvider().UserCredential(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return NewARMDiscovery(cred), nil
+		},
+		hostname: os.Hostname,
+	}
+}
+
+// document is the generated configuration file; only what the selections determine is written,
+// everything else keeps the agent defaults
+type document struct {
+	SchemaVersion string             `json:"schemaVersion"`
+	Azure         azureDocument      `json:"azure"`
+	Kubernetes    kubernetesDocument `json:"kubernetes"`
+	Node          *nodeDocument      `json:"node,omitempty"`
+	Agent         agentDocument      `json:"agent"`
+}
+
+type azureDocument struct {
+	SubscriptionID   string                         `json:"subscriptionId"`
+	TenantID         string                         `json:"tenantId"`
+	Cloud            string                         `json:"cloud"`
+	ServicePrincipal *config.ServicePrincipalConfig `json:"servicePrincipal,omitempty"`
+	ManagedIdentity  *config.ManagedIdentityConfig  `json:"managedIdentity,omitempty"`
+	BootstrapToken   *config.BootstrapTokenConfig   `json:"bootstrapToken,omitempty"`
+	Arc              arcDocument                    `json:"arc"`
+	TargetCluster    targetClusterDocument          `json:"targetCluster"`
+}
+
+type arcDocument struct {
+	Enabled       bool   `json:"enabled"`
+	MachineName   string `json:"machineName,omitempty"`
+	ResourceGroup string `json:"resourceGroup,omitempty"`
+	Location      string `json:"location,omitempty"`
+}
+
+type targetClusterDocument struct {
+	ResourceID string `json:"resourceId"`
+	Location   string `json:"location"`
+}
+
+type kubernetesDocument struct {
+	Version string `json:"version"`
+}
+
+type nodeDocument struct {
+	Kubelet kubeletDocument `json:"kubelet"`
+}
+
+type kubeletDocument struct {
+	ServerURL  string `json:"serverURL"`
+	CACertData string `json:"caCertData"`
+}
+
+type agentDocument struct {
+	LogLevel string `json:"logLevel"`
+	LogDir   string `json:"logDir"`
+}
+
+// Generate asks for or discovers every setting and returns the configuration file content
+func (g *Generator) Generate(ctx context.Context) ([]byte, error) {
+	doc := document{
+		SchemaVersion: config.SchemaVersion,
+		Azure:         azureDocument{Cloud: "AzurePublicCloud"},
+		Agent:         agentDocument{LogLevel: "info", LogDir: "/var/log/aks-flex-node"},
+	}
+
+	method, err := g.authMethod()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := g.credentialConfig(method, &doc)
+	if err != nil {
+		return nil, err
+	}
+	discovery, err := g.newDiscovery(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+	}
+
+	sub, err := g.subscription(ctx, discovery)
+	if err != nil {
+		return nil, err
+	}
+	doc.Azure.SubscriptionID = sub.ID
+	doc.Azure.TenantID = firstNonEmpty(g.opts.TenantID, sub.TenantID)
+
+	cluster, err := g.cluster(ctx, discovery, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	doc.Azure.TargetCluster = targetClusterDocument{ResourceID: cluster.ID, Location: cluster.Location}
+	doc.Kubernetes.Version = strings.TrimPrefix(cluster.KubernetesVersion, "v")
+
+	switch method {
+	case AuthArc:
+		if doc.Azure.Arc, err = g.arc(ctx, discovery, sub.ID, cluster); err != nil {
+			return nil, err
+		}
+	case AuthBootstrapToken:
+		if err := g.bootstrapToken(&doc); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render configuration: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func (g *Generator) authMethod() (string, error) {
+	if g.opts.Auth != "" {
+		for _, method := range authMethods {
+			if g.opts.Auth == method {
+				return method, nil
+			}
+		}
+		return "", fmt.Errorf("invalid --auth %q. Valid values are: %s", g.opts.Auth, strings.Join(authMethods, ", "))
+	}
+	i, err := g.prompt.Select("Authentication method", authMethods, 0)
+	if err != nil {
+		return "", missing("authentication method", "--auth", err)
+	}
+	return authMethods[i], nil
+}
+
+// credentialConfig fills in the credential settings and returns a config from which the discovery
+// credential is created, so discovery uses the same identity the agent will use
+func (g *Generator) credentialConfig(method string, doc *document) (*config.Config, error) {
+	cfg := &config.Config{}
+	cfg.Azure.TenantID = g.opts.TenantID
+
+	switch method {
+	case AuthServicePrincipal:
+		sp := &config.ServicePrincipalConfig{TenantID: g.opts.TenantID, ClientID: g.opts.ClientID, ClientSecret: g.opts.ClientSecret}
+		var err error
+		if sp.TenantID == "" {
+			if sp.TenantID, err = g.prompt.Input("Tenant ID of the service principal", ""); err != nil {
+				return nil, missing("tenant ID", "--tenant-id", err)
+			}
+		}
+		if sp.ClientID == "" {
+			if sp.ClientID, err = g.prompt.Input("Service principal client ID", ""); err != nil {
+				return nil, missing("service principal client ID", "--client-id", err)
+			}
+		}
+		if sp.ClientSecret == "" {
+			if sp.ClientSecret, err = g.prompt.Secret("Service principal client secret"); err != nil {
+				return nil, missing("service principal client secret", "--client-secret", err)
+			}
+		}
+		cfg.Azure.TenantID = sp.TenantID
+		cfg.Azure.ServicePrincipal = sp
+		doc.Azure.ServicePrincipal = sp
+	case AuthManagedIdentity:
+		cfg.UseManagedIdentity(g.opts.ManagedIdentityClientID)
+		doc.Azure.ManagedIdentity = cfg.Azure.ManagedIdentity
+	}
+	// Arc and bootstrap token nodes are onboarded with the operator's Azure CLI login
+	return cfg, nil
+}
+
+func (g *Generator) subscription(ctx context.Context, discovery Discovery) (Subscription, error) {
+	subscriptions, err := discovery.Subscriptions(ctx)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if g.opts.SubscriptionID != "" {
+		for _, sub := range subscriptions {
+			if strings.EqualFold(sub.ID, g.opts.SubscriptionID) {
+				return sub, nil
+			}
+		}
+		return Subscription{}, fmt.Errorf("subscription %s is not visible to the credential", g.opts.SubscriptionID)
+	}
+	if len(subscriptions) == 0 {
+		return Subscription{}, errors.New("the credential has no access to any subscription")
+	}
+
+	options := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		options[i] = fmt.Sprintf("%s (%s)", sub.Name, sub.ID)
+	}
+	i, err := g.prompt.Select("Subscription", options, onlyChoice(options))
+	if err != nil {
+		return Subscription{}, missing("subscription", "--subscription-id", err)
+	}
+	return subscriptions[i], nil
+}
+
+func (g *Generator) cluster(ctx context.Context, discovery Discovery, subscriptionID string) (Cluster, error) {
+	if g.opts.ClusterResourceID != "" {
+		match := config.AKSClusterResourceIDPattern.FindStringSubmatch(g.opts.ClusterResourceID)
+		if match == nil {
+			return Cluster{}, fmt.Errorf("invalid --cluster-resource-id %s. Expected format: "+
+				"/subscriptions/{subscription-id}/resourceGroups/{resource-group}/providers/Microsoft.ContainerService/managedClusters/{cluster-name}",
+				g.opts.ClusterResourceID)
+		}
+		// The cluster may live in another subscription than the node
+		clusters, err := discovery.Clusters(ctx, match[1])
+		if err != nil {
+			return Cluster{}, err
+		}
+		for _, cluster := range clusters {
+			if strings.EqualFold(cluster.ID, g.opts.ClusterResourceID) {
+				return cluster, nil
+			}
+		}
+		return Cluster{}, fmt.Errorf("AKS cluster %s not found or not visible to the credential", g.opts.ClusterResourceID)
+	}
+
+	clusters, err := discovery.Clusters(ctx, subscriptionID)
+	if err != nil {
+		return Cluster{}, err
+	}
+	if len(clusters) == 0 {
+		return Cluster{}, fmt.Errorf("no AKS clusters found in subscription %s; pass --cluster-resource-id for a cluster in another subscription", subscriptionID)
+	}
+	options := make([]string, len(clusters))
+	for i, cluster := range clusters {
+		options[i] = fmt.Sprintf("%s (resource group %s, %s, Kubernetes %s)", cluster.Name, cluster.ResourceGroup, cluster.Location, cluster.KubernetesVersion)
+	}
+	i, err := g.prompt.Select("AKS cluster", options, onlyChoice(options))
+	if err != nil {
+		return Cluster{}, missing("AKS cluster", "--cluster-resource-id", err)
+	}
+	return clusters[i], nil
+}
+
+func (g *Generator) arc(ctx context.Context, discovery Discovery, subscriptionID string, cluster Cluster) (arcDocument, error) {
+	arc := arcDocument{Enabled: true}
+
+	groups, err := discovery.ResourceGroups(ctx, subscriptionID)
+	if err != nil {
+		return arc, err
+	}
+	if g.opts.ArcResourceGroup != "" {
+		for _, group := range groups {
+			if strings.EqualFold(group.Name, g.opts.ArcResourceGroup) {
+				arc.ResourceGroup = group.Name
+			}
+		}
+		if arc.ResourceGroup == "" {
+			return arc, fmt.Errorf("resource group %s not found in subscription %s", g.opts.ArcResourceGroup, subscriptionID)
+		}
+	} else {
+		if len(groups) == 0 {
+			return arc, fmt.Errorf("no resource groups found in subscription %s for the Arc machine", subscriptionID)
+		}
+		options := make([]string, len(groups))
+		def := -1
+		for i, group := range groups {
+			options[i] = fmt.Sprintf("%s (%s)", group.Name, group.Location)
+			if strings.EqualFold(group.Name, cluster.ResourceGroup) {
+				def = i
+			}
+		}
+		i, err := g.prompt.Select("Resource group for the Arc machine", options, def)
+		if err != nil {
+			return arc, missing("Arc resource group", "--arc-resource-group", err)
+		}
+		arc.ResourceGroup = groups[i].Name
+	}
+
+	if arc.Location = g.opts.ArcLocation; arc.Location == "" {
+		if arc.Location, err = g.prompt.Input("Azure region for the Arc machine", cluster.Location); err != nil {
+			return arc, missing("Arc location", "--arc-location", err)
+		}
+	}
+
+	if arc.MachineName = g.opts.ArcMachineName; arc.MachineName == "" {
+		hostname, _ := g.hostname()
+		if arc.MachineName, err = g.prompt.Input("Arc machine name", hostname); err != nil {
+			return arc, missing("Arc machine name", "--arc-machine-name", err)
+		}
+	}
+	return arc, nil
+}
+
+func (g *Generator) bootstrapToken(doc *document) error {
+	token, serverURL, caCertData := g.opts.BootstrapToken, g.opts.ServerURL, g.opts.CACertData
+	var err error
+	if token == "" {
+		if token, err = g.prompt.Secret("Bootstrap token (<token-id>.<token-secret>)"); err != nil {
+			return missing("bootstrap token", "--bootstrap-token", err)
+		}
+	}
+	if serverURL == "" {
+		if serverURL, err = g.prompt.Input("API server URL", ""); err != nil {
+			return missing("API server URL", "--server-url", err)
+		}
+	}
+	if caCertData == "" {
+		if caCertData, err = g.prompt.Input("Base64-encoded cluster CA certificate", ""); err != nil {
+			return missing("cluster CA certificate", "--ca-cert-data", err)
+		}
+	}
+	doc.Azure.BootstrapToken = &config.BootstrapTokenConfig{Token: token}
+	doc.Node = &nodeDocument{Kubelet: kubeletDocument{ServerURL: serverURL, CACertData: caCertData}}
+	return nil
+}
+
+// Validate checks that data loads as an agent configuration, exactly as the agent will read it
+func Validate(data []byte) error {
+	tmp, err := os.CreateTemp("", "aks-flex-node-config-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, writeErr := tmp.Write(data)
+	if err := errors.Join(writeErr, tmp.Close()); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if _, err := config.LoadConfig(tmp.Name()); err != nil {
+		return fmt.Errorf("generated configuration is invalid: %w", err)
+	}
+	return nil
+}
+
+// Write saves the configuration to path, refusing to replace an existing file unless force is set.
+// Files holding a client secret or bootstrap token are only readable by their owner.
+func Write(path string, data []byte, secret, force bool) error {
+	if utils.FileExists(path) && !force {
+		return fmt.Errorf("%s already exists; pass --force to overwrite it", path)
+	}
+	if dir := filepath.Dir(path); !utils.DirectoryExists(dir) {
+		if err := utils.RunSystemCommand("mkdir", "-p", dir); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	perm := os.FileMode(0o644)
+	if secret {
+		perm = 0o600
+	}
+	if err := utils.WriteFileAtomicSystem(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// HasSecret reports whether the generated configuration contains a client secret or bootstrap token
+func HasSecret(data []byte) bool {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return true
+	}
+	return doc.Azure.ServicePrincipal != nil || doc.Azure.BootstrapToken != nil
+}
+
+// missing explains which flag provides a value that could not be asked for
+func missing(what, flag string, err error) error {
+	if errors.Is(err, errNonInteractive) {
+		return fmt.Errorf("%s is required: pass %s", what, flag)
+	}
+	return fmt.Errorf("failed to read %s: %w", what, err)
+}
+
+// onlyChoice is the default selection: the single option when there is exactly one, otherwise none
+func onlyChoice(options []string) int {
+	if len(options) == 1 {
+		return 0
+	}
+	return -1
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+package configgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	testSub      = "12345678-1234-1234-1234-123456789012"
+	testOtherSub = "87654321-4321-4321-4321-210987654321"
+	testTenant   = "11111111-2222-3333-4444-555555555555"
+	testCluster  = "/subscriptions/" + testSub + "/resourceGroups/aks-rg/providers/Microsoft.ContainerService/managedClusters/edge"
+	testCluster2 = "/subscriptions/" + testSub + "/resourceGroups/other-rg/providers/Microsoft.ContainerService/managedClusters/prod"
+)
+
+type fakeDiscovery struct {
+	subscriptions []Subscription
+	groups        []ResourceGroup
+	clusters      map[string][]Cluster
+}
+
+func (f *fakeDiscovery) Subscriptions(context.Context) ([]Subscription, error) {
+	return f.subscriptions, nil
+}
+
+func (f *fakeDiscovery) ResourceGroups(context.Context, string) ([]ResourceGroup, error) {
+	return f.groups, nil
+}
+
+func (f *fakeDiscovery) Clusters(_ context.Context, subscriptionID string) ([]Cluster, error) {
+	return f.clusters[subscriptionID], nil
+}
+
+func newFakeDiscovery() *fakeDiscovery {
+	return &fakeDiscovery{
+		subscriptions: []Subscription{{ID: testSub, Name: "Edge", TenantID: testTenant}},
+		groups:        []ResourceGroup{{Name: "aks-rg", Location: "eastus"}, {Name: "arc-rg", Location: "westus2"}},
+		clusters: map[string][]Cluster{
+			testSub: {{ID: testCluster, Name: "edge", ResourceGroup: "aks-rg", Location: "eastus", KubernetesVersion: "1.30.6"}},
+		},
+	}
+}
+
+// scriptedPrompter answers questions in order and records them
+type scriptedPrompter struct {
+	answers []string
+	asked   []string
+}
+
+func (p *scriptedPrompter) next(label string) string {
+	p.asked = append(p.asked, label)
+	if len(p.answers) == 0 {
+		return ""
+	}
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return answer
+}
+
+func (p *scriptedPrompter) Select(label string, options []string, def int) (int, error) {
+	answer := p.next(label)
+	if answer == "" {
+		return def, nil
+	}
+	for i, option := range options {
+		if strings.HasPrefix(option, answer) {
+			return i, nil
+		}
+	}
+	return 0, errors.New("no such option " + answer)
+}
+
+func (p *scriptedPrompter) Input(label, def string) (string, error) {
+	if answer := p.next(label); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+func (p *scriptedPrompter) Secret(label string) (string, error) {
+	return p.next(label), nil
+}
+
+func newTestGenerator(opts Options, prompt Prompter, discovery Discovery, gotCfg **config.Config) *Generator {
+	g := NewGenerator(opts, prompt)
+	g.newDiscovery = func(cfg *config.Config) (Discovery, error) {
+		if gotCfg != nil {
+			*gotCfg = cfg
+		}
+		return discovery, nil
+	}
+	g.hostname = func() (string, error) { return "node-1", nil }
+	return g
+}
+
+func decode(t *testing.T, data []byte) document {
+	t.Helper()
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("generated config is not JSON: %v\n%s", err, data)
+	}
+	return doc
+}
+
+func TestGenerateArcNonInteractive(t *testing.T) {
+	g := newTestGenerator(Options{}, NoPrompter(), newFakeDiscovery(), nil)
+
+	data, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatalf("Validate() error = %v\n%s", err, data)
+	}
+
+	doc := decode(t, data)
+	if doc.SchemaVersion != config.SchemaVersion || doc.Azure.SubscriptionID != testSub || doc.Azure.TenantID != testTenant {
+		t.Errorf("azure = %+v, schemaVersion = %q", doc.Azure, doc.SchemaVersion)
+	}
+	want := arcDocument{Enabled: true, MachineName: "node-1", ResourceGroup: "aks-rg", Location: "eastus"}
+	if doc.Azure.Arc != want {
+		t.Errorf("arc = %+v, want %+v", doc.Azure.Arc, want)
+	}
+	if doc.Azure.TargetCluster.ResourceID != testCluster || doc.Kubernetes.Version != "1.30.6" {
+		t.Errorf("targetCluster = %+v, kubernetes = %+v", doc.Azure.TargetCluster, doc.Kubernetes)
+	}
+	if HasSecret(data) {
+		t.Error("an Arc configuration holds no secret")
+	}
+}
+
+func TestGenerateInteractiveSelections(t *testing.T) {
+	discovery := newFakeDiscovery()
+	discovery.subscriptions = append(discovery.subscriptions, Subscription{ID: testOtherSub, Name: "Other", TenantID: testTenant})
+	discovery.clusters[testSub] = append(discovery.clusters[testSub],
+		Cluster{ID: testCluster2, Name: "prod", ResourceGroup: "other-rg", Location: "westeurope", KubernetesVersion: "1.31.1"})
+	prompt := &scriptedPrompter{answers: []string{"arc", "Edge", "prod", "arc-rg", "", "edge-node-7"}}
+	g := newTestGenerator(Options{}, prompt, discovery, nil)
+
+	data, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	doc := decode(t, data)
+	if doc.Azure.TargetCluster.ResourceID != testCluster2 || doc.Azure.TargetCluster.Location != "westeurope" {
+		t.Errorf("targetCluster = %+v", doc.Azure.TargetCluster)
+	}
+	want := arcDocument{Enabled: true, MachineName: "edge-node-7", ResourceGroup: "arc-rg", Location: "westeurope"}
+	if doc.Azure.Arc != want {
+		t.Errorf("arc = %+v, want %+v", doc.Azure.Arc, want)
+	}
+	if len(prompt.asked) != 6 {
+		t.Errorf("asked %d questions: %v", len(prompt.asked), prompt.asked)
+	}
+}
+
+func TestGenerateServicePrincipal(t *testing.T) {
+	var cfg *config.Config
+	opts := Options{Auth: AuthServicePrincipal, TenantID: testTenant, ClientID: "client", SubscriptionID: testSub, ClusterResourceID: testCluster}
+	prompt := &scriptedPrompter{answers: []string{"s3cret"}}
+	g := newTestGenerator(opts, prompt, newFakeDiscovery(), &cfg)
+
+	data, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatalf("Validate() error = %v\n%s", err, data)
+	}
+	if !cfg.IsSPConfigured() {
+		t.Error("discovery should use the service principal credential")
+	}
+	doc := decode(t, data)
+	if doc.Azure.ServicePrincipal == nil || doc.Azure.ServicePrincipal.ClientSecret != "s3cret" || doc.Azure.Arc.Enabled {
+		t.Errorf("azure = %+v", doc.Azure)
+	}
+	if !HasSecret(data) {
+		t.Error("a service principal configuration holds a secret")
+	}
+}
+
+func TestGenerateManagedIdentity(t *testing.T) {
+	var cfg *config.Config
+	g := newTestGenerator(Options{Auth: AuthManagedIdentity}, NoPrompter(), newFakeDiscovery(), &cfg)
+
+	data, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !cfg.IsMIConfigured() {
+		t.Error("discovery should use the managed identity credential")
+	}
+	if !strings.Contains(string(data), `"managedIdentity": {}`) {
+		t.Errorf("managedIdentity must be written even without a client ID:\n%s", data)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatalf("Validate() error = %v\n%s", err, data)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	twoClusters := newFakeDiscovery()
+	twoClusters.clusters[testSub] = append(twoClusters.clusters[testSub], Cluster{ID: testCluster2, Name: "prod"})
+
+	tests := []struct {
+		name      string
+		opts      Options
+		discovery *fakeDiscovery
+		wantErr   string
+	}{
+		{name: "invalid auth", opts: Options{Auth: "password"}, wantErr: "invalid --auth"},
+		{name: "subscription not visible", opts: Options{SubscriptionID: testOtherSub}, wantErr: "not visible to the credential"},
+		{name: "cluster not found", opts: Options{ClusterResourceID: testCluster2}, wantErr: "not found or not visible"},
+		{name: "malformed cluster ID", opts: Options{ClusterResourceID: "edge"}, wantErr: "invalid --cluster-resource-id"},
+		{name: "ambiguous cluster", discovery: twoClusters, wantErr: "pass --cluster-resource-id"},
+		{name: "unknown resource group", opts: Options{ArcResourceGroup: "nope"}, wantErr: "resource group nope not found"},
+		{name: "missing bootstrap token", opts: Options{Auth: AuthBootstrapToken}, wantErr: "pass --bootstrap-token"},
+		{name: "missing client secret", opts: Options{Auth: AuthServicePrincipal, TenantID: testTenant, ClientID: "c"}, wantErr: "pass --client-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery := tt.discovery
+			if discovery == nil {
+				discovery = newFakeDiscovery()
+			}
+			_, err := newTestGenerator(tt.opts, NoPrompter(), discovery, nil).Generate(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Generate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsInvalidConfig(t *testing.T) {
+	data := []byte(`{"schemaVersion": "1", "azure": {"subscriptionId": "` + testSub + `"}}`)
+	if err := Validate(data); err == nil || !strings.Contains(err.Error(), "generated configuration is invalid") {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package configgen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Prompter asks the operator for the values that were not given as flags
+type Prompter interface {
+	// Select returns the index of the chosen option; def is the index picked on an empty answer, -1 for none
+	Select(label string, options []string, def int) (int, error)
+	// Input returns a free text answer, or def on an empty answer
+	Input(label, def string) (string, error)
+	// Secret returns an answer that is not echoed
+	Secret(label string) (string, error)
+}
+
+// errNonInteractive is returned when a value without default is missing and prompting is not allowed
+var errNonInteractive = errors.New("value not given and prompting is disabled")
+
+// noPrompter answers every question with its default, for --non-interactive runs
+type noPrompter struct{}
+
+func (noPrompter) Select(_ string, options []string, def int) (int, error) {
+	if def < 0 || def >= len(options) {
+		return 0, errNonInteractive
+	}
+	return def, nil
+}
+
+func (noPrompter) Input(_, def string) (string, error) {
+	if def == "" {
+		return "", errNonInteractive
+	}
+	return def, nil
+}
+
+func (noPrompter) Secret(string) (string, error) {
+	return "", errNonInteractive
+}
+
+// NoPrompter returns a Prompter that takes the default of every question and fails questions without one
+func NoPrompter() Prompter {
+	return noPrompter{}
+}
+
+// terminalPrompter asks on a terminal, numbering the options of a selection
+type terminalPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	tty *os.File
+}
+
+// NewTerminalPrompter creates a Prompter reading answers from in and writing questions to out.
+// Secrets are read without echo when in is a terminal.
+func NewTerminalPrompter(in *os.File, out io.Writer) Prompter {
+	return &terminalPrompter{in: bufio.NewReader(in), out: out, tty: in}
+}
+
+func (p *terminalPrompter) Select(label string, options []string, def int) (int, error) {
+	fmt.Fprintf(p.out, "%s:\n", label)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %2d) %s\n", i+1, option)
+	}
+	for {
+		defAnswer := ""
+		if def >= 0 && def < len(options) {
+			defAnswer = strconv.Itoa(def + 1)
+		}
+		answer, err := p.Input("Choose a number", defAnswer)
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		fmt.Fprintf(p.out, "Enter a number between 1 and %d.\n", len(options))
+	}
+}
+
+func (p *terminalPrompter) Input(label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+func (p *terminalPrompter) Secret(label string) (string, error) {
+	if !term.IsTerminal(int(p.tty.Fd())) {
+		return p.Input(label, "")
+	}
+	fmt.Fprintf(p.out, "%s: ", label)
+	secret, err := term.ReadPassword(int(p.tty.Fd()))
+	fmt.Fprintln(p.out)
+	if err != nil {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
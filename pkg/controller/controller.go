@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/webhook"
+)
+
+const (
+	// retryDelay is how long the controller waits before it retries after an action failed
+	retryDelay = 5 * time.Minute
+	// watchRetryDelay is how long the controller waits before watching again after the watch failed
+	watchRetryDelay = 10 * time.Second
+	// defaultSecretKey is the Secret key read when secretRef.key is empty
+	defaultSecretKey = "secret"
+)
+
+// agentClient sends actions to an agent's webhook listener
+type agentClient interface {
+	Submit(ctx context.Context, action webhook.Action) (*webhook.Record, error)
+	Get(ctx context.Context, id string) (*webhook.Record, error)
+}
+
+// Controller drives the agents registered with FlexNode objects towards their spec
+type Controller struct {
+	client          dynamic.Interface
+	logger          *logrus.Logger
+	secretNamespace string
+	syncPeriod      time.Duration
+	now             func() time.Time
+
+	newAgentClient func(endpoint string, secret, caBundle []byte) (agentClient, error)
+}
+
+// New creates a controller that checks every FlexNode at least once per syncPeriod. The shared secrets of
+// the agents are only read from Secrets in secretNamespace, the namespace the controller may read Secrets in.
+func New(client dynamic.Interface, secretNamespace string, syncPeriod time.Duration, logger *logrus.Logger) *Controller {
+	return &Controller{
+		client:          client,
+		logger:          logger,
+		secretNamespace: secretNamespace,
+		syncPeriod:      syncPeriod,
+		now:             time.Now,
+		newAgentClient: func(endpoint string, secret, caBundle []byte) (agentClient, error) {
+			return webhook.NewClient(endpoint, secret, caBundle)
+		},
+	}
+}
+
+// Run reconciles all FlexNodes every sync period, and right away when one changes, until ctx is done
+func (c *Controller) Run(ctx context.Context) error {
+	changed := make(chan struct{}, 1)
+	go c.watch(ctx, changed)
+
+	ticker := time.NewTicker(c.syncPeriod)
+	defer ticker.Stop()
+	for {
+		if err := c.ReconcileAll(ctx); err != nil {
+			c.logger.Errorf("Failed to reconcile FlexNodes: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
+
+// watch signals changed whenever a FlexNode changes, watching again whenever the watch ends
+func (c *Controller) watch(ctx context.Context, changed chan<- struct{}) {
+	for ctx.Err() == nil {
+		watcher, err := c.client.Resource(FlexNodeResource).Watch(ctx, metav1.ListOptions{})
+		if err != nil {
+			c.logger.Warnf("Failed to watch FlexNodes: %v", err)
+		} else {
+			for range watcher.ResultChan() {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+			watcher.Stop()
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// ReconcileAll reconciles every FlexNode; a node that fails does not keep the others from being reconciled
+func (c *Controller) ReconcileAll(ctx context.Context) error {
+	list, err := c.client.Resource(FlexNodeResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list FlexNodes: %w", err)
+	}
+	for i := range list.Items {
+		if err := c.reconcile(ctx, &list.Items[i]); err != nil {
+			c.logger.Errorf("Failed to reconcile FlexNode %s: %v", list.Items[i].GetName(), err)
+		}
+	}
+	return nil
+}
+
+// reconcile follows up on the action in flight on the node's agent, submits the next one and records the progress
+func (c *Controller) reconcile(ctx context.Context, obj *unstructured.Unstructured) error {
+	var node FlexNode
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &node); err != nil {
+		return fmt.Errorf("invalid FlexNode: %w", err)
+	}
+	before := node.Status
+
+	agent, err := c.agentFor(ctx, &node)
+	if err != nil {
+		node.Status.Phase, node.Status.Message = PhaseFailed, err.Error()
+	} else {
+		c.step(ctx, &node, agent)
+	}
+	node.Status.ObservedGeneration = node.Generation
+
+	if equality.Semantic.DeepEqual(before, node.Status) {
+		return nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&node)
+	if err != nil {
+		return err
+	}
+	obj.Object["status"] = content["status"]
+	if _, err := c.client.Resource(FlexNodeResource).UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// step advances the node by at most one finished and one newly submitted action
+func (c *Controller) step(ctx context.Context, node *FlexNode, agent agentClient) {
+	status := &node.Status
+	if current := status.CurrentAction; current != nil {
+		record, err := agent.Get(ctx, current.ID)
+		switch {
+		case errors.Is(err, webhook.ErrUnknownAction):
+			c.fail(node, fmt.Errorf("the agent lost %s action %s, probably because it restarted", current.Action, current.ID))
+		case err != nil:
+			status.Message = fmt.Sprintf("agent unreachable: %v", err)
+			return
+		case record.State == webhook.Succeeded:
+			c.complete(node)
+		case record.State == webhook.Failed:
+			c.fail(node, fmt.Errorf("%s action %s failed on the agent: %s", current.Action, current.ID, record.Error))
+		default:
+			current.State = record.State
+			status.Phase, status.Message = PhaseProgressing, ""
+			return
+		}
+	}
+
+	if status.Phase == PhaseFailed && status.LastFailure != nil && c.now().Sub(status.LastFailure.Time) < retryDelay {
+		return
+	}
+	next := nextAction(node)
+	if next == nil {
+		status.Phase, status.Message = PhaseConverged, ""
+		return
+	}
+
+	next.ID = fmt.Sprintf("%s-%d-%s", next.Action, node.Generation, strconv.FormatInt(c.now().UnixNano(), 36))
+	record, err := agent.Submit(ctx, webhook.Action{ID: next.ID, Action: next.Action, KubernetesVersion: next.KubernetesVersion})
+	if err != nil {
+		c.fail(node, fmt.Errorf("failed to submit %s action: %w", next.Action, err))
+		return
+	}
+	next.State = record.State
+	status.CurrentAction = next
+	status.Phase, status.Message = PhaseProgressing, ""
+	c.logger.Infof("Submitted %s action %s to FlexNode %s", next.Action, next.ID, node.Name)
+}
+
+// complete records the outcome of the action that just succeeded
+func (c *Controller) complete(node *FlexNode) {
+	status := &node.Status
+	current := status.CurrentAction
+	switch current.Action {
+	case webhook.Drain:
+		status.Drained = true
+	case webhook.Uncordon:
+		status.Drained = false
+	case webhook.Upgrade:
+		status.KubernetesVersion = current.KubernetesVersion
+	case webhook.Bootstrap:
+		status.LastReconcileRequest = current.ReconcileRequest
+	}
+	status.CurrentAction, status.LastFailure = nil, nil
+	c.logger.Infof("%s action %s succeeded on FlexNode %s", current.Action, current.ID, node.Name)
+}
+
+// fail records a failed action; the controller tries again after retryDelay
+func (c *Controller) fail(node *FlexNode, err error) {
+	now := metav1.NewTime(c.now())
+	node.Status.Phase, node.Status.Message = PhaseFailed, err.Error()
+	node.Status.CurrentAction, node.Status.LastFailure = nil, &now
+	c.logger.Warnf("FlexNode %s: %v", node.Name, err)
+}
+
+// nextAction returns the next action that brings the node closer to its spec, or nil when it matches.
+// The node is drained before it is upgraded or bootstrapped and uncordoned after.
+func nextAction(node *FlexNode) *ActionStatus {
+	spec, status := node.Spec, node.Status
+	switch {
+	case spec.Drain && !status.Drained:
+		return &ActionStatus{Action: webhook.Drain}
+	case spec.KubernetesVersion != "" && spec.KubernetesVersion != status.KubernetesVersion:
+		return &ActionStatus{Action: webhook.Upgrade, KubernetesVersion: spec.KubernetesVersion}
+	case spec.ReconcileRequest != "" && spec.ReconcileRequest != status.LastReconcileRequest:
+		return &ActionStatus{Action: webhook.Bootstrap, ReconcileRequest: spec.ReconcileRequest}
+	case !spec.Drain && status.Drained:
+		return &ActionStatus{Action: webhook.Uncordon}
+	default:
+		return nil
+	}
+}
+
+// agentFor returns a client for the node's agent with the shared secret from the referenced Secret
+func (c *Controller) agentFor(ctx context.Context, node *FlexNode) (agentClient, error) {
+	ref := node.Spec.SecretRef
+	// FlexNodes are cluster-scoped, so whoever can create one must not make the controller use any Secret
+	if ref.Namespace != c.secretNamespace {
+		return nil, fmt.Errorf("secretRef %s/%s is outside namespace %s, the only one the controller reads Secrets from",
+			ref.Namespace, ref.Name, c.secretNamespace)
+	}
+	key := ref.Key
+	if key == "" {
+		key = defaultSecretKey
+	}
+	secret, err := c.client.Resource(secretResource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	encoded, _, _ := unstructured.NestedString(secret.Object, "data", key)
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	value = bytes.TrimSpace(value)
+	if err != nil || len(value) == 0 {
+		return nil, fmt.Errorf("no key %q in Secret %s/%s", key, ref.Namespace, ref.Name)
+	}
+	return c.newAgentClient(node.Spec.Endpoint, value, []byte(node.Spec.CABundle))
+}
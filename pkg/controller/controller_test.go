@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/webhook"
+)
+
+// fakeAgent is an agent that keeps actions in memory; tests finish them by hand
+type fakeAgent struct {
+	secret    string
+	submitted []webhook.Action
+	records   map[string]*webhook.Record
+}
+
+func (a *fakeAgent) Submit(ctx context.Context, action webhook.Action) (*webhook.Record, error) {
+	a.submitted = append(a.submitted, action)
+	a.records[action.ID] = &webhook.Record{Action: action, State: webhook.Queued}
+	return a.records[action.ID], nil
+}
+
+func (a *fakeAgent) Get(ctx context.Context, id string) (*webhook.Record, error) {
+	record, ok := a.records[id]
+	if !ok {
+		return nil, webhook.ErrUnknownAction
+	}
+	return record, nil
+}
+
+// finish completes the last submitted action
+func (a *fakeAgent) finish(err error) {
+	record := a.records[a.submitted[len(a.submitted)-1].ID]
+	record.State = webhook.Succeeded
+	if err != nil {
+		record.State, record.Error = webhook.Failed, err.Error()
+	}
+}
+
+func newTestController(t *testing.T, spec FlexNodeSpec) (*Controller, *fakeAgent, func() FlexNode) {
+	t.Helper()
+	node := &FlexNode{
+		TypeMeta:   metav1.TypeMeta{APIVersion: Group + "/" + Version, Kind: "FlexNode"},
+		ObjectMeta: metav1.ObjectMeta{Name: "edge-01", Generation: 3},
+		Spec:       spec,
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"name": "edge-01-webhook", "namespace": "flexnode-system"},
+		"data":       map[string]any{"secret": base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef\n"))},
+	}}
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{FlexNodeResource: "FlexNodeList", secretResource: "SecretList"},
+		&unstructured.Unstructured{Object: content}, secret)
+	agent := &fakeAgent{records: map[string]*webhook.Record{}}
+	c := New(client, "flexnode-system", time.Minute, logrus.New())
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	c.newAgentClient = func(endpoint string, secret, caBundle []byte) (agentClient, error) {
+		agent.secret = string(secret)
+		return agent, nil
+	}
+
+	get := func() FlexNode {
+		obj, err := client.Resource(FlexNodeResource).Get(context.Background(), "edge-01", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got FlexNode
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	return c, agent, get
+}
+
+func TestReconcileDrainUpgradeUncordon(t *testing.T) {
+	spec := FlexNodeSpec{
+		Endpoint:          "https://edge-01:8443",
+		SecretRef:         SecretReference{Namespace: "flexnode-system", Name: "edge-01-webhook"},
+		KubernetesVersion: "1.31.2",
+		Drain:             true,
+	}
+	c, agent, get := newTestController(t, spec)
+	ctx := context.Background()
+
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatalf("ReconcileAll() unexpected error: %v", err)
+	}
+	if agent.secret != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("agent client secret = %q, want it from the Secret without the newline", agent.secret)
+	}
+	node := get()
+	if node.Status.Phase != PhaseProgressing || node.Status.CurrentAction == nil || node.Status.CurrentAction.Action != webhook.Drain {
+		t.Fatalf("status = %+v, want the drain submitted first", node.Status)
+	}
+	if node.Status.ObservedGeneration != 3 {
+		t.Errorf("observedGeneration = %d, want 3", node.Status.ObservedGeneration)
+	}
+
+	// Nothing changes while the drain runs
+	if err := c.ReconcileAll(ctx); err != nil || len(agent.submitted) != 1 {
+		t.Fatalf("ReconcileAll() submitted %v, %v while an action runs, want nothing new", agent.submitted, err)
+	}
+
+	agent.finish(nil)
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	node = get()
+	if !node.Status.Drained || node.Status.CurrentAction == nil || node.Status.CurrentAction.Action != webhook.Upgrade {
+		t.Fatalf("status = %+v, want the node drained and the upgrade submitted", node.Status)
+	}
+	if got := agent.submitted[1].KubernetesVersion; got != "1.31.2" {
+		t.Errorf("upgrade version = %q, want 1.31.2", got)
+	}
+
+	agent.finish(nil)
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	node = get()
+	if node.Status.KubernetesVersion != "1.31.2" || node.Status.Phase != PhaseConverged || node.Status.CurrentAction != nil {
+		t.Fatalf("status = %+v, want the node upgraded and converged while drain is requested", node.Status)
+	}
+
+	// Clearing spec.drain uncordons the node
+	obj, _ := c.client.Resource(FlexNodeResource).Get(ctx, "edge-01", metav1.GetOptions{})
+	_ = unstructured.SetNestedField(obj.Object, false, "spec", "drain")
+	if _, err := c.client.Resource(FlexNodeResource).Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := agent.submitted[len(agent.submitted)-1].Action; got != webhook.Uncordon {
+		t.Errorf("submitted %s after spec.drain was cleared, want uncordon", got)
+	}
+}
+
+func TestReconcileFailure(t *testing.T) {
+	spec := FlexNodeSpec{
+		Endpoint:         "https://edge-01:8443",
+		SecretRef:        SecretReference{Namespace: "flexnode-system", Name: "edge-01-webhook"},
+		ReconcileRequest: "2026-10-17",
+	}
+	c, agent, get := newTestController(t, spec)
+	ctx := context.Background()
+
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	agent.finish(errors.New("containerd download failed"))
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	node := get()
+	if node.Status.Phase != PhaseFailed || node.Status.LastFailure == nil || node.Status.Message == "" {
+		t.Fatalf("status = %+v, want the failure recorded", node.Status)
+	}
+
+	// The controller waits before it tries again
+	if err := c.ReconcileAll(ctx); err != nil || len(agent.submitted) != 1 {
+		t.Fatalf("ReconcileAll() submitted %v, %v right after a failure, want nothing new", agent.submitted, err)
+	}
+	later := c.now().Add(retryDelay + time.Second)
+	c.now = func() time.Time { return later }
+	if err := c.ReconcileAll(ctx); err != nil || len(agent.submitted) != 2 {
+		t.Fatalf("ReconcileAll() submitted %v, %v after the retry delay, want the bootstrap again", agent.submitted, err)
+	}
+
+	agent.finish(nil)
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if node = get(); node.Status.LastReconcileRequest != "2026-10-17" || node.Status.Phase != PhaseConverged {
+		t.Errorf("status = %+v, want the reconcile request recorded", node.Status)
+	}
+}
+
+func TestReconcileSecretOutsideNamespace(t *testing.T) {
+	spec := FlexNodeSpec{
+		Endpoint:         "https://edge-01:8443",
+		SecretRef:        SecretReference{Namespace: "kube-system", Name: "bootstrap-token", Key: "token-secret"},
+		ReconcileRequest: "2026-10-17",
+	}
+	c, agent, get := newTestController(t, spec)
+	ctx := context.Background()
+	secret := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"name": "bootstrap-token", "namespace": "kube-system"},
+		"data":       map[string]any{"token-secret": base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))},
+	}}
+	if _, err := c.client.Resource(secretResource).Namespace("kube-system").Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ReconcileAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if node := get(); node.Status.Phase != PhaseFailed || !strings.Contains(node.Status.Message, "outside namespace flexnode-system") {
+		t.Errorf("status = %+v, want the secretRef rejected", node.Status)
+	}
+	if agent.secret != "" || len(agent.submitted) != 0 {
+		t.Errorf("agent client got secret %q and actions %v, want neither", agent.secret, agent.submitted)
+	}
+}
+
+func TestNextAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   FlexNodeSpec
+		status FlexNodeStatus
+		want   string
+	}{
+		{name: "nothing requested"},
+		{name: "drain first", spec: FlexNodeSpec{Drain: true, KubernetesVersion: "1.31.2"}, want: webhook.Drain},
+		{name: "upgrade", spec: FlexNodeSpec{KubernetesVersion: "1.31.2"}, status: FlexNodeStatus{KubernetesVersion: "1.30.4"}, want: webhook.Upgrade},
+		{name: "bootstrap before uncordon", spec: FlexNodeSpec{ReconcileRequest: "r2"}, status: FlexNodeStatus{Drained: true, LastReconcileRequest: "r1"}, want: webhook.Bootstrap},
+		{name: "uncordon", status: FlexNodeStatus{Drained: true}, want: webhook.Uncordon},
+		{name: "converged", spec: FlexNodeSpec{KubernetesVersion: "1.31.2", ReconcileRequest: "r1"}, status: FlexNodeStatus{KubernetesVersion: "1.31.2", LastReconcileRequest: "r1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if next := nextAction(&FlexNode{Spec: tt.spec, Status: tt.status}); next != nil {
+				got = next.Action
+			}
+			if got != tt.want {
+				t.Errorf("nextAction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package controller implements the optional FlexNode controller. It runs in the cluster, watches FlexNode
+// objects and drives each registered agent towards the object's spec through the agent's webhook listener,
+// so cluster admins can manage flex nodes with kubectl.
+package controller
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Group and version of the FlexNode custom resource
+const (
+	Group   = "aksflexnode.azure.com"
+	Version = "v1alpha1"
+)
+
+// FlexNodeResource is the FlexNode custom resource
+var FlexNodeResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "flexnodes"}
+
+// secretResource is the core Secret resource the agents' shared secrets are read from
+var secretResource = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// Phases of a FlexNode
+const (
+	PhaseConverged   = "Converged"   // The agent applied everything in the spec
+	PhaseProgressing = "Progressing" // An action is queued or running on the agent
+	PhaseFailed      = "Failed"      // The last action failed; it is retried after retryDelay
+)
+
+// FlexNode registers an agent with the controller and holds the state the node should be in
+type FlexNode struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FlexNodeSpec   `json:"spec"`
+	Status FlexNodeStatus `json:"status,omitempty"`
+}
+
+// FlexNodeSpec is where the agent can be reached and the desired state of its node
+type FlexNodeSpec struct {
+	Endpoint  string          `json:"endpoint"`           // URL of the agent's webhook listener, e.g. https://edge-01:8443
+	SecretRef SecretReference `json:"secretRef"`          // Secret holding the shared secret of the listener
+	CABundle  string          `json:"caBundle,omitempty"` // PEM certificates to trust for the listener, in addition to the system roots
+
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"` // Version to upgrade the node to
+	Drain             bool   `json:"drain,omitempty"`             // Keep the node cordoned and drained
+	ReconcileRequest  string `json:"reconcileRequest,omitempty"`  // Any new value makes the agent run bootstrap once
+}
+
+// SecretReference identifies a key in a Secret
+type SecretReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key,omitempty"` // Defaults to "secret"
+}
+
+// FlexNodeStatus is the state the controller last brought the node to
+type FlexNodeStatus struct {
+	Phase              string `json:"phase,omitempty"`
+	Message            string `json:"message,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+
+	KubernetesVersion    string `json:"kubernetesVersion,omitempty"`    // Version of the last successful upgrade
+	Drained              bool   `json:"drained,omitempty"`              // Whether the node was last drained rather than uncordoned
+	LastReconcileRequest string `json:"lastReconcileRequest,omitempty"` // reconcileRequest of the last successful bootstrap
+
+	CurrentAction *ActionStatus `json:"currentAction,omitempty"` // Action submitted to the agent and not finished yet
+	LastFailure   *metav1.Time  `json:"lastFailure,omitempty"`   // When the last action failed
+}
+
+// ActionStatus is an action submitted to the agent
+type ActionStatus struct {
+	ID                string `json:"id"`
+	Action            string `json:"action"`
+	State             string `json:"state"`
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"` // For upgrade
+	ReconcileRequest  string `json:"reconcileRequest,omitempty"`  // The reconcileRequest a bootstrap was submitted for
+}
@@ -0,0 +1,89 @@
+// Package cri is a small client for the Container Runtime Interface (CRI), the gRPC API kubelet
+// uses to talk to the container runtime. The agent uses it to check the runtime before kubelet starts.
+package cri
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// Client talks to a container runtime over its CRI socket
+type Client struct {
+	conn    *grpc.ClientConn
+	runtime runtimeapi.RuntimeServiceClient
+	image   runtimeapi.ImageServiceClient
+}
+
+// NewClient creates a client for a CRI endpoint such as unix:///run/containerd/containerd.sock.
+// The connection is established lazily on the first call.
+func NewClient(endpoint string) (*Client, error) {
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CRI client for %s: %w", endpoint, err)
+	}
+	return &Client{
+		conn:    conn,
+		runtime: runtimeapi.NewRuntimeServiceClient(conn),
+		image:   runtimeapi.NewImageServiceClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the runtime
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Version returns the name and version of the container runtime
+func (c *Client) Version(ctx context.Context) (string, string, error) {
+	resp, err := c.runtime.Version(ctx, &runtimeapi.VersionRequest{})
+	if err != nil {
+		return "", "", criError("Version", err)
+	}
+	return resp.RuntimeName, resp.RuntimeVersion, nil
+}
+
+// PullImage pulls an image and returns its image reference
+func (c *Client) PullImage(ctx context.Context, image string) (string, error) {
+	resp, err := c.image.PullImage(ctx, &runtimeapi.PullImageRequest{Image: &runtimeapi.ImageSpec{Image: image}})
+	if err != nil {
+		return "", criError("PullImage "+image, err)
+	}
+	return resp.ImageRef, nil
+}
+
+// ImageExists returns true when the image is already present in the runtime's image store
+func (c *Client) ImageExists(ctx context.Context, image string) (bool, error) {
+	resp, err := c.image.ImageStatus(ctx, &runtimeapi.ImageStatusRequest{Image: &runtimeapi.ImageSpec{Image: image}})
+	if err != nil {
+		return false, criError("ImageStatus "+image, err)
+	}
+	return resp.Image != nil, nil
+}
+
+// CgroupDriver returns the cgroup driver the runtime is configured with, "systemd" or "cgroupfs". Older
+// runtimes such as containerd 1.x do not implement the call and return an Unimplemented error.
+func (c *Client) CgroupDriver(ctx context.Context) (string, error) {
+	resp, err := c.runtime.RuntimeConfig(ctx, &runtimeapi.RuntimeConfigRequest{})
+	if err != nil {
+		return "", criError("RuntimeConfig", err)
+	}
+	if resp.Linux == nil {
+		return "", fmt.Errorf("CRI RuntimeConfig returned no Linux configuration")
+	}
+	switch resp.Linux.CgroupDriver {
+	case runtimeapi.CgroupDriver_SYSTEMD:
+		return "systemd", nil
+	case runtimeapi.CgroupDriver_CGROUPFS:
+		return "cgroupfs", nil
+	}
+	return "", fmt.Errorf("CRI RuntimeConfig returned unknown cgroup driver %s", resp.Linux.CgroupDriver)
+}
+
+// criError wraps a CRI call error, keeping the runtime's gRPC status code and message intact
+func criError(call string, err error) error {
+	return fmt.Errorf("CRI %s failed: %w", call, err)
+}
@@ -0,0 +1,115 @@
+package cri
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	smokeTestNamespace = "aks-flex-node"
+	smokeTestName      = "runtime-smoke-test"
+	// Stopping the test container should never need a grace period
+	smokeTestStopTimeout = 5
+)
+
+// SmokeTest proves that the runtime, its snapshotter and registry access work. It pulls the image,
+// runs it as a container in a host network pod sandbox, so CNI is not involved, and removes both again.
+// The returned error carries the runtime's exact CRI error.
+func (c *Client) SmokeTest(ctx context.Context, image string, logger *logrus.Logger) error {
+	name, version, err := c.Version(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Container runtime %s %s is serving CRI", name, version)
+
+	imageRef, err := c.PullImage(ctx, image)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Pulled %s", image)
+
+	sandboxConfig := smokeTestSandboxConfig(uuid.NewString())
+	sandbox, err := c.runtime.RunPodSandbox(ctx, &runtimeapi.RunPodSandboxRequest{Config: sandboxConfig})
+	if err != nil {
+		return criError("RunPodSandbox", err)
+	}
+	defer c.removeSandbox(sandbox.PodSandboxId, logger)
+
+	container, err := c.runtime.CreateContainer(ctx, &runtimeapi.CreateContainerRequest{
+		PodSandboxId:  sandbox.PodSandboxId,
+		SandboxConfig: sandboxConfig,
+		Config: &runtimeapi.ContainerConfig{
+			Metadata: &runtimeapi.ContainerMetadata{Name: smokeTestName},
+			Image:    &runtimeapi.ImageSpec{Image: imageRef},
+			Linux:    &runtimeapi.LinuxContainerConfig{},
+		},
+	})
+	if err != nil {
+		return criError("CreateContainer", err)
+	}
+	defer c.removeContainer(container.ContainerId, logger)
+
+	if _, err := c.runtime.StartContainer(ctx, &runtimeapi.StartContainerRequest{ContainerId: container.ContainerId}); err != nil {
+		return criError("StartContainer", err)
+	}
+
+	status, err := c.runtime.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: container.ContainerId})
+	if err != nil {
+		return criError("ContainerStatus", err)
+	}
+	if state := status.GetStatus().GetState(); state != runtimeapi.ContainerState_CONTAINER_RUNNING {
+		return fmt.Errorf("test container is %s instead of running: %s %s",
+			state, status.GetStatus().GetReason(), status.GetStatus().GetMessage())
+	}
+	logger.Info("Test container started successfully")
+	return nil
+}
+
+// smokeTestSandboxConfig returns the configuration of the host network pod sandbox running the test container
+func smokeTestSandboxConfig(uid string) *runtimeapi.PodSandboxConfig {
+	return &runtimeapi.PodSandboxConfig{
+		Metadata: &runtimeapi.PodSandboxMetadata{
+			Name:      smokeTestName,
+			Namespace: smokeTestNamespace,
+			Uid:       uid,
+		},
+		Hostname: smokeTestName,
+		Labels:   map[string]string{"app.kubernetes.io/managed-by": "aks-flex-node"},
+		Linux: &runtimeapi.LinuxPodSandboxConfig{
+			// A slice works with both the systemd and the cgroupfs cgroup driver
+			CgroupParent: "system.slice",
+			SecurityContext: &runtimeapi.LinuxSandboxSecurityContext{
+				NamespaceOptions: &runtimeapi.NamespaceOption{Network: runtimeapi.NamespaceMode_NODE},
+			},
+		},
+	}
+}
+
+// removeContainer stops and removes the test container, logging failures
+func (c *Client) removeContainer(id string, logger *logrus.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := c.runtime.StopContainer(ctx, &runtimeapi.StopContainerRequest{ContainerId: id, Timeout: smokeTestStopTimeout}); err != nil {
+		logger.Warnf("Failed to stop test container %s: %v", id, err)
+	}
+	if _, err := c.runtime.RemoveContainer(ctx, &runtimeapi.RemoveContainerRequest{ContainerId: id}); err != nil {
+		logger.Warnf("Failed to remove test container %s: %v", id, err)
+	}
+}
+
+// removeSandbox stops and removes the test pod sandbox, logging failures
+func (c *Client) removeSandbox(id string, logger *logrus.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := c.runtime.StopPodSandbox(ctx, &runtimeapi.StopPodSandboxRequest{PodSandboxId: id}); err != nil {
+		logger.Warnf("Failed to stop test pod sandbox %s: %v", id, err)
+	}
+	if _, err := c.runtime.RemovePodSandbox(ctx, &runtimeapi.RemovePodSandboxRequest{PodSandboxId: id}); err != nil {
+		logger.Warnf("Failed to remove test pod sandbox %s: %v", id, err)
+	}
+}
@@ -0,0 +1,211 @@
+package cri
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// fakeRuntime records the CRI calls it receives and fails the configured one
+type fakeRuntime struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	runtimeapi.UnimplementedImageServiceServer
+	calls    []string
+	failCall string
+	state    runtimeapi.ContainerState
+	linux    *runtimeapi.LinuxRuntimeConfiguration
+}
+
+func (f *fakeRuntime) call(name string) error {
+	f.calls = append(f.calls, name)
+	if name == f.failCall {
+		return status.Error(codes.Unknown, name+" broke")
+	}
+	return nil
+}
+
+func (f *fakeRuntime) Version(context.Context, *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+	return &runtimeapi.VersionResponse{RuntimeName: "fake", RuntimeVersion: "1.0"}, f.call("Version")
+}
+
+func (f *fakeRuntime) RuntimeConfig(context.Context, *runtimeapi.RuntimeConfigRequest) (*runtimeapi.RuntimeConfigResponse, error) {
+	return &runtimeapi.RuntimeConfigResponse{Linux: f.linux}, f.call("RuntimeConfig")
+}
+
+func (f *fakeRuntime) PullImage(context.Context, *runtimeapi.PullImageRequest) (*runtimeapi.PullImageResponse, error) {
+	return &runtimeapi.PullImageResponse{ImageRef: "sha256:pause"}, f.call("PullImage")
+}
+
+func (f *fakeRuntime) RunPodSandbox(_ context.Context, req *runtimeapi.RunPodSandboxRequest) (*runtimeapi.RunPodSandboxResponse, error) {
+	if req.Config.Linux.SecurityContext.NamespaceOptions.Network != runtimeapi.NamespaceMode_NODE {
+		return nil, status.Error(codes.InvalidArgument, "sandbox must use the node network")
+	}
+	return &runtimeapi.RunPodSandboxResponse{PodSandboxId: "sandbox"}, f.call("RunPodSandbox")
+}
+
+func (f *fakeRuntime) CreateContainer(context.Context, *runtimeapi.CreateContainerRequest) (*runtimeapi.CreateContainerResponse, error) {
+	return &runtimeapi.CreateContainerResponse{ContainerId: "container"}, f.call("CreateContainer")
+}
+
+func (f *fakeRuntime) StartContainer(context.Context, *runtimeapi.StartContainerRequest) (*runtimeapi.StartContainerResponse, error) {
+	return &runtimeapi.StartContainerResponse{}, f.call("StartContainer")
+}
+
+func (f *fakeRuntime) ContainerStatus(context.Context, *runtimeapi.ContainerStatusRequest) (*runtimeapi.ContainerStatusResponse, error) {
+	return &runtimeapi.ContainerStatusResponse{Status: &runtimeapi.ContainerStatus{State: f.state, Reason: "Error"}}, f.call("ContainerStatus")
+}
+
+func (f *fakeRuntime) StopContainer(context.Context, *runtimeapi.StopContainerRequest) (*runtimeapi.StopContainerResponse, error) {
+	return &runtimeapi.StopContainerResponse{}, f.call("StopContainer")
+}
+
+func (f *fakeRuntime) RemoveContainer(context.Context, *runtimeapi.RemoveContainerRequest) (*runtimeapi.RemoveContainerResponse, error) {
+	return &runtimeapi.RemoveContainerResponse{}, f.call("RemoveContainer")
+}
+
+func (f *fakeRuntime) StopPodSandbox(context.Context, *runtimeapi.StopPodSandboxRequest) (*runtimeapi.StopPodSandboxResponse, error) {
+	return &runtimeapi.StopPodSandboxResponse{}, f.call("StopPodSandbox")
+}
+
+func (f *fakeRuntime) RemovePodSandbox(context.Context, *runtimeapi.RemovePodSandboxRequest) (*runtimeapi.RemovePodSandboxResponse, error) {
+	return &runtimeapi.RemovePodSandboxResponse{}, f.call("RemovePodSandbox")
+}
+
+// startFakeRuntime serves the fake runtime on a unix socket and returns a client connected to it
+func startFakeRuntime(t *testing.T, runtime *fakeRuntime) *Client {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, runtime)
+	runtimeapi.RegisterImageServiceServer(server, runtime)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	client, err := NewClient("unix://" + socket)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestSmokeTest(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name      string
+		failCall  string
+		state     runtimeapi.ContainerState
+		wantErr   string
+		wantCalls string
+	}{
+		{
+			name:      "container runs and everything is removed",
+			state:     runtimeapi.ContainerState_CONTAINER_RUNNING,
+			wantCalls: "Version PullImage RunPodSandbox CreateContainer StartContainer ContainerStatus StopContainer RemoveContainer StopPodSandbox RemovePodSandbox",
+		},
+		{
+			name:      "pull error is returned as is",
+			failCall:  "PullImage",
+			wantErr:   "CRI PullImage pause:3.6 failed: rpc error: code = Unknown desc = PullImage broke",
+			wantCalls: "Version PullImage",
+		},
+		{
+			name:      "sandbox is removed when the container fails to start",
+			failCall:  "StartContainer",
+			wantErr:   "CRI StartContainer failed: rpc error: code = Unknown desc = StartContainer broke",
+			wantCalls: "Version PullImage RunPodSandbox CreateContainer StartContainer StopContainer RemoveContainer StopPodSandbox RemovePodSandbox",
+		},
+		{
+			name:      "exited container fails",
+			state:     runtimeapi.ContainerState_CONTAINER_EXITED,
+			wantErr:   "test container is CONTAINER_EXITED instead of running: Error",
+			wantCalls: "Version PullImage RunPodSandbox CreateContainer StartContainer ContainerStatus StopContainer RemoveContainer StopPodSandbox RemovePodSandbox",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := &fakeRuntime{failCall: tt.failCall, state: tt.state}
+			client := startFakeRuntime(t, runtime)
+
+			err := client.SmokeTest(context.Background(), "pause:3.6", logger)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("SmokeTest() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("SmokeTest() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := strings.Join(runtime.calls, " "); got != tt.wantCalls {
+				t.Errorf("calls = %q, want %q", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCgroupDriver(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime *fakeRuntime
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "systemd",
+			runtime: &fakeRuntime{linux: &runtimeapi.LinuxRuntimeConfiguration{CgroupDriver: runtimeapi.CgroupDriver_SYSTEMD}},
+			want:    "systemd",
+		},
+		{
+			name:    "cgroupfs",
+			runtime: &fakeRuntime{linux: &runtimeapi.LinuxRuntimeConfiguration{CgroupDriver: runtimeapi.CgroupDriver_CGROUPFS}},
+			want:    "cgroupfs",
+		},
+		{
+			name:    "no linux configuration",
+			runtime: &fakeRuntime{},
+			wantErr: "no Linux configuration",
+		},
+		{
+			name:    "call fails",
+			runtime: &fakeRuntime{failCall: "RuntimeConfig"},
+			wantErr: "CRI RuntimeConfig failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := startFakeRuntime(t, tt.runtime)
+			got, err := client.CgroupDriver(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CgroupDriver() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CgroupDriver() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CgroupDriver() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package decommission checks what removing AKS components from the node would disrupt: pods other than
+// DaemonSet and static pods still running on it, and volumes still attached to it. unbootstrap refuses to
+// run on a busy node unless forced, so a node is not removed while it still carries workloads.
+package decommission
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// mirrorPodAnnotation marks the API server's copy of a static pod, which goes away with the kubelet
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// Workload is a pod that unbootstrap would kill
+type Workload struct {
+	Namespace string
+	Name      string
+	Owner     string   // Kind/name of the controlling owner, e.g. ReplicaSet/web-5f7c9; empty for a bare pod
+	Claims    []string // PersistentVolumeClaims the pod mounts
+}
+
+func (w Workload) String() string {
+	s := w.Namespace + "/" + w.Name
+	if w.Owner != "" {
+		s += " (" + w.Owner + ")"
+	}
+	if len(w.Claims) > 0 {
+		s += " with volume claims " + strings.Join(w.Claims, ", ")
+	}
+	return s
+}
+
+// Report lists what unbootstrap would disrupt on the node
+type Report struct {
+	Node      string
+	Workloads []Workload
+	Volumes   []string // Volumes attached to or in use on the node
+}
+
+// Busy reports whether removing the node would disrupt anything
+func (r *Report) Busy() bool {
+	return len(r.Workloads) > 0 || len(r.Volumes) > 0
+}
+
+// Lines describes each disrupted workload and volume on its own line
+func (r *Report) Lines() []string {
+	var lines []string
+	for _, w := range r.Workloads {
+		lines = append(lines, "pod "+w.String())
+	}
+	for _, v := range r.Volumes {
+		lines = append(lines, "volume "+v)
+	}
+	return lines
+}
+
+// Checker looks up the node's pods and volumes with the kubelet's node identity
+type Checker struct {
+	kubectl    func(args ...string) (string, error)
+	hostname   func() (string, error)
+	fileExists func(path string) bool
+}
+
+// NewChecker creates a checker that runs kubectl with the kubelet kubeconfig
+func NewChecker() *Checker {
+	return &Checker{
+		kubectl: func(args ...string) (string, error) {
+			return utils.RunCommandWithOutput("kubectl", args...)
+		},
+		hostname:   os.Hostname,
+		fileExists: utils.FileExists,
+	}
+}
+
+// Check reports the workloads and volumes on the node. A node that was never bootstrapped has no
+// kubelet kubeconfig and nothing to disrupt.
+func (c *Checker) Check() (*Report, error) {
+	hostname, err := c.hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine node name: %w", err)
+	}
+	// The kubelet registers the node under its lower-cased hostname
+	report := &Report{Node: strings.ToLower(hostname)}
+	if !c.fileExists(kubelet.KubeletKubeconfigPath) {
+		return report, nil
+	}
+
+	var pods podList
+	if err := c.get(&pods, "pods", "--all-namespaces", "--field-selector", "spec.nodeName="+report.Node); err != nil {
+		return nil, err
+	}
+	report.Workloads = workloads(pods)
+
+	var node nodeObject
+	if err := c.get(&node, "node", report.Node); err != nil {
+		return nil, err
+	}
+	report.Volumes = volumes(node)
+	return report, nil
+}
+
+func (c *Checker) get(into any, args ...string) error {
+	args = append(append([]string{"--kubeconfig", kubelet.KubeletKubeconfigPath, "get"}, args...), "-o", "json")
+	output, err := c.kubectl(args...)
+	if err != nil {
+		return fmt.Errorf("kubectl get %s failed: %w: %s", args[3], err, strings.TrimSpace(output))
+	}
+	if err := json.Unmarshal([]byte(output), into); err != nil {
+		return fmt.Errorf("invalid output from kubectl get %s: %w", args[3], err)
+	}
+	return nil
+}
+
+// podList holds the fields of a pod list the check needs
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Namespace       string            `json:"namespace"`
+			Name            string            `json:"name"`
+			Annotations     map[string]string `json:"annotations"`
+			OwnerReferences []struct {
+				Kind       string `json:"kind"`
+				Name       string `json:"name"`
+				Controller bool   `json:"controller"`
+			} `json:"ownerReferences"`
+		} `json:"metadata"`
+		Spec struct {
+			Volumes []struct {
+				PersistentVolumeClaim *struct {
+					ClaimName string `json:"claimName"`
+				} `json:"persistentVolumeClaim"`
+			} `json:"volumes"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// nodeObject holds the fields of a node the check needs
+type nodeObject struct {
+	Status struct {
+		VolumesAttached []struct {
+			Name string `json:"name"`
+		} `json:"volumesAttached"`
+		VolumesInUse []string `json:"volumesInUse"`
+	} `json:"status"`
+}
+
+// workloads returns the pods that would be killed. DaemonSet pods run on every node and static pods
+// belong to the node itself, so neither counts; neither do pods that already finished.
+func workloads(pods podList) []Workload {
+	var result []Workload
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == "Succeeded" || pod.Status.Phase == "Failed" {
+			continue
+		}
+		if _, mirror := pod.Metadata.Annotations[mirrorPodAnnotation]; mirror {
+			continue
+		}
+		w := Workload{Namespace: pod.Metadata.Namespace, Name: pod.Metadata.Name}
+		daemonSet := false
+		for _, owner := range pod.Metadata.OwnerReferences {
+			if owner.Controller {
+				w.Owner = owner.Kind + "/" + owner.Name
+				daemonSet = owner.Kind == "DaemonSet"
+			}
+		}
+		if daemonSet {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				w.Claims = append(w.Claims, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// volumes returns the volumes attached to or mounted on the node, without duplicates
+func volumes(node nodeObject) []string {
+	var result []string
+	for _, v := range node.Status.VolumesAttached {
+		result = append(result, v.Name)
+	}
+	result = append(result, node.Status.VolumesInUse...)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
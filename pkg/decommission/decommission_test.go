@@ -0,0 +1,89 @@
+package decommission
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPods = `{"items": [
+  {"metadata": {"namespace": "shop", "name": "web-5f7c9-x2x4q", "ownerReferences": [{"kind": "ReplicaSet", "name": "web-5f7c9", "controller": true}]},
+   "spec": {"volumes": [{"name": "data", "persistentVolumeClaim": {"claimName": "web-data"}}, {"name": "tmp", "emptyDir": {}}]},
+   "status": {"phase": "Running"}},
+  {"metadata": {"namespace": "kube-system", "name": "kube-proxy-abcde", "ownerReferences": [{"kind": "DaemonSet", "name": "kube-proxy", "controller": true}]},
+   "status": {"phase": "Running"}},
+  {"metadata": {"namespace": "kube-system", "name": "etcd-edge-01", "annotations": {"kubernetes.io/config.mirror": "abc"}},
+   "status": {"phase": "Running"}},
+  {"metadata": {"namespace": "batch", "name": "report-28321", "ownerReferences": [{"kind": "Job", "name": "report", "controller": true}]},
+   "status": {"phase": "Succeeded"}},
+  {"metadata": {"namespace": "default", "name": "debug"}, "status": {"phase": "Pending"}}
+]}`
+
+const testNode = `{"status": {
+  "volumesAttached": [{"name": "kubernetes.io/csi/disk.csi.azure.com^web-data"}],
+  "volumesInUse": ["kubernetes.io/csi/disk.csi.azure.com^web-data", "kubernetes.io/csi/file.csi.azure.com^share"]
+}}`
+
+func newTestChecker(outputs map[string]string, err error) (*Checker, *[]string) {
+	var commands []string
+	return &Checker{
+		kubectl: func(args ...string) (string, error) {
+			command := strings.Join(args, " ")
+			commands = append(commands, command)
+			if err != nil {
+				return "error: Unauthorized", err
+			}
+			return outputs[args[3]], nil
+		},
+		hostname:   func() (string, error) { return "Edge-01", nil },
+		fileExists: func(string) bool { return true },
+	}, &commands
+}
+
+func TestCheck(t *testing.T) {
+	c, commands := newTestChecker(map[string]string{"pods": testPods, "node": testNode}, nil)
+	report, err := c.Check()
+	if err != nil {
+		t.Fatalf("Check() unexpected error: %v", err)
+	}
+	if (*commands)[0] != "--kubeconfig /var/lib/kubelet/kubeconfig get pods --all-namespaces --field-selector spec.nodeName=edge-01 -o json" {
+		t.Errorf("kubectl ran %q, want the pods on edge-01", (*commands)[0])
+	}
+
+	want := []string{
+		"pod shop/web-5f7c9-x2x4q (ReplicaSet/web-5f7c9) with volume claims web-data",
+		"pod default/debug",
+		"volume kubernetes.io/csi/disk.csi.azure.com^web-data",
+		"volume kubernetes.io/csi/file.csi.azure.com^share",
+	}
+	if got := report.Lines(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	if !report.Busy() {
+		t.Error("Busy() = false, want true")
+	}
+}
+
+func TestCheckIdleNode(t *testing.T) {
+	c, _ := newTestChecker(map[string]string{"pods": `{"items": []}`, "node": `{"status": {}}`}, nil)
+	report, err := c.Check()
+	if err != nil || report.Busy() {
+		t.Errorf("Check() = %+v, %v, want an idle node", report, err)
+	}
+}
+
+func TestCheckNotBootstrapped(t *testing.T) {
+	c, commands := newTestChecker(nil, nil)
+	c.fileExists = func(string) bool { return false }
+	report, err := c.Check()
+	if err != nil || report.Busy() || len(*commands) != 0 {
+		t.Errorf("Check() = %+v, %v after running %q, want an idle node without asking the API server", report, err, *commands)
+	}
+}
+
+func TestCheckFailure(t *testing.T) {
+	c, _ := newTestChecker(nil, errors.New("exit status 1"))
+	if _, err := c.Check(); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("Check() error = %v, want the kubectl output", err)
+	}
+}
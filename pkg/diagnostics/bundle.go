@@ -0,0 +1,99 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BundleOptions selects what goes into a support bundle
+type BundleOptions struct {
+	// Files are copied into the bundle under their base name; missing files are skipped
+	Files []string
+	// MetricsDuration is how long host metrics are sampled; zero skips the capture
+	MetricsDuration time.Duration
+	// MetricsInterval is the time between host metric samples
+	MetricsInterval time.Duration
+}
+
+// WriteBundle writes a gzipped tarball with the requested files and a host metrics capture to path.
+// A failed metrics capture is recorded in the bundle rather than failing it, so support still gets the logs.
+func WriteBundle(ctx context.Context, path string, opts BundleOptions, logger *logrus.Logger) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create support bundle %s: %w", path, err)
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	writeErr := writeBundleEntries(ctx, tw, opts, logger)
+	// Close in order so the archive is complete even when an entry failed
+	err = errors.Join(writeErr, tw.Close(), gz.Close(), out.Close())
+	if err != nil {
+		return fmt.Errorf("failed to write support bundle %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeBundleEntries(ctx context.Context, tw *tar.Writer, opts BundleOptions, logger *logrus.Logger) error {
+	for _, file := range opts.Files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Debugf("Skipping %s in support bundle: file does not exist", file)
+			continue
+		}
+		if err != nil {
+			logger.Warnf("Skipping %s in support bundle: %v", file, err)
+			continue
+		}
+		if err := addEntry(tw, filepath.Base(file), data); err != nil {
+			return err
+		}
+	}
+
+	if opts.MetricsDuration <= 0 {
+		return nil
+	}
+	logger.Infof("Capturing host metrics for %s every %s", opts.MetricsDuration, opts.MetricsInterval)
+	metrics, err := CaptureHostMetrics(ctx, opts.MetricsDuration, opts.MetricsInterval)
+	if err != nil {
+		logger.Warnf("Host metrics capture failed: %v", err)
+		if entryErr := addEntry(tw, "host-metrics.error", []byte(err.Error()+"\n")); entryErr != nil || metrics == nil {
+			return entryErr
+		}
+	}
+
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal host metrics: %w", err)
+	}
+	if err := addEntry(tw, "host-metrics.json", data); err != nil {
+		return err
+	}
+	var summary bytes.Buffer
+	if err := metrics.WriteSummary(&summary); err != nil {
+		return fmt.Errorf("failed to format host metrics: %w", err)
+	}
+	return addEntry(tw, "host-metrics.txt", summary.Bytes())
+}
+
+func addEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to add %s to support bundle: %w", name, err)
+	}
+	if _, err := io.Copy(tw, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to add %s to support bundle: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultProcDir = "/proc"
+
+	// sectorSize is the unit of the sector counters in /proc/diskstats regardless of the device block size
+	sectorSize = 512
+)
+
+// HostMetrics is a short capture of host resource usage, one sample per interval
+type HostMetrics struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Interval time.Duration `json:"interval"`
+	Samples  []Sample      `json:"samples"`
+}
+
+// Sample is the resource usage over one interval
+type Sample struct {
+	Time    time.Time           `json:"time"`
+	CPU     CPUUsage            `json:"cpu"`
+	Memory  MemoryUsage         `json:"memory"`
+	Load    [3]float64          `json:"load"`
+	Disks   map[string]DiskRate `json:"disks,omitempty"`
+	Network map[string]NetRate  `json:"network,omitempty"`
+}
+
+// CPUUsage is the share of CPU time spent in each state, in percent
+type CPUUsage struct {
+	User   float64 `json:"user"`
+	System float64 `json:"system"`
+	IOWait float64 `json:"iowait"`
+	Steal  float64 `json:"steal"`
+	Idle   float64 `json:"idle"`
+}
+
+// MemoryUsage is the memory in use at the end of the interval
+type MemoryUsage struct {
+	TotalKiB     uint64  `json:"totalKiB"`
+	AvailableKiB uint64  `json:"availableKiB"`
+	UsedPercent  float64 `json:"usedPercent"`
+	SwapUsedKiB  uint64  `json:"swapUsedKiB"`
+}
+
+// DiskRate is the IO activity of one block device
+type DiskRate struct {
+	ReadKiBps   float64 `json:"readKiBps"`
+	WriteKiBps  float64 `json:"writeKiBps"`
+	ReadIOPS    float64 `json:"readIOPS"`
+	WriteIOPS   float64 `json:"writeIOPS"`
+	UtilPercent float64 `json:"utilPercent"`
+}
+
+// NetRate is the traffic of one network interface; errors and drops are counts within the interval
+type NetRate struct {
+	RxKiBps   float64 `json:"rxKiBps"`
+	TxKiBps   float64 `json:"txKiBps"`
+	RxErrors  uint64  `json:"rxErrors"`
+	TxErrors  uint64  `json:"txErrors"`
+	RxDropped uint64  `json:"rxDropped"`
+	TxDropped uint64  `json:"txDropped"`
+}
+
+// CaptureHostMetrics samples /proc every interval for the given duration, like sar does.
+// A cancelled context ends the capture early and returns the samples taken so far.
+func CaptureHostMetrics(ctx context.Context, duration, interval time.Duration) (*HostMetrics, error) {
+	return captureHostMetrics(ctx, defaultProcDir, duration, interval, time.Now)
+}
+
+func captureHostMetrics(ctx context.Context, procDir string, duration, interval time.Duration, now func() time.Time) (*HostMetrics, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("sampling interval must be positive, got %s", interval)
+	}
+	if duration < interval {
+		return nil, fmt.Errorf("capture duration %s must be at least the sampling interval %s", duration, interval)
+	}
+
+	prev, err := readSnapshot(procDir, now())
+	if err != nil {
+		return nil, err
+	}
+	metrics := &HostMetrics{Start: prev.Time, End: prev.Time, Interval: interval}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for count := int(duration / interval); len(metrics.Samples) < count; {
+		select {
+		case <-ctx.Done():
+			return metrics, nil
+		case <-ticker.C:
+		}
+		cur, err := readSnapshot(procDir, now())
+		if err != nil {
+			return metrics, err
+		}
+		metrics.Samples = append(metrics.Samples, diffSnapshots(prev, cur))
+		metrics.End = cur.Time
+		prev = cur
+	}
+	return metrics, nil
+}
+
+// diffSnapshots turns two snapshots of cumulative counters into the rates over the time between them
+func diffSnapshots(prev, cur snapshot) Sample {
+	seconds := cur.Time.Sub(prev.Time).Seconds()
+	sample := Sample{
+		Time:    cur.Time,
+		CPU:     cpuUsage(prev.CPU, cur.CPU),
+		Memory:  memoryUsage(cur.Memory),
+		Load:    cur.Load,
+		Disks:   make(map[string]DiskRate, len(cur.Disks)),
+		Network: make(map[string]NetRate, len(cur.Network)),
+	}
+
+	for name, c := range cur.Disks {
+		p, ok := prev.Disks[name]
+		if !ok || seconds <= 0 {
+			continue
+		}
+		util := float64(delta(p.IOTimeMs, c.IOTimeMs)) / (seconds * 1000) * 100
+		sample.Disks[name] = DiskRate{
+			ReadKiBps:   float64(delta(p.SectorsRead, c.SectorsRead)*sectorSize) / 1024 / seconds,
+			WriteKiBps:  float64(delta(p.SectorsWritten, c.SectorsWritten)*sectorSize) / 1024 / seconds,
+			ReadIOPS:    float64(delta(p.Reads, c.Reads)) / seconds,
+			WriteIOPS:   float64(delta(p.Writes, c.Writes)) / seconds,
+			UtilPercent: min(util, 100),
+		}
+	}
+
+	for name, c := range cur.Network {
+		p, ok := prev.Network[name]
+		if !ok || seconds <= 0 {
+			continue
+		}
+		sample.Network[name] = NetRate{
+			RxKiBps:   float64(delta(p.RxBytes, c.RxBytes)) / 1024 / seconds,
+			TxKiBps:   float64(delta(p.TxBytes, c.TxBytes)) / 1024 / seconds,
+			RxErrors:  delta(p.RxErrors, c.RxErrors),
+			TxErrors:  delta(p.TxErrors, c.TxErrors),
+			RxDropped: delta(p.RxDropped, c.RxDropped),
+			TxDropped: delta(p.TxDropped, c.TxDropped),
+		}
+	}
+	return sample
+}
+
+func cpuUsage(prev, cur cpuTimes) CPUUsage {
+	total := float64(delta(prev.total(), cur.total()))
+	if total == 0 {
+		return CPUUsage{Idle: 100}
+	}
+	percent := func(p, c uint64) float64 { return float64(delta(p, c)) / total * 100 }
+	return CPUUsage{
+		User:   percent(prev.User+prev.Nice, cur.User+cur.Nice),
+		System: percent(prev.System+prev.IRQ+prev.SoftIRQ, cur.System+cur.IRQ+cur.SoftIRQ),
+		IOWait: percent(prev.IOWait, cur.IOWait),
+		Steal:  percent(prev.Steal, cur.Steal),
+		Idle:   percent(prev.Idle, cur.Idle),
+	}
+}
+
+func memoryUsage(m memInfo) MemoryUsage {
+	usage := MemoryUsage{TotalKiB: m.TotalKiB, AvailableKiB: m.AvailableKiB}
+	if m.TotalKiB > 0 {
+		usage.UsedPercent = float64(m.TotalKiB-min(m.AvailableKiB, m.TotalKiB)) / float64(m.TotalKiB) * 100
+	}
+	if m.SwapTotalKiB > m.SwapFreeKiB {
+		usage.SwapUsedKiB = m.SwapTotalKiB - m.SwapFreeKiB
+	}
+	return usage
+}
+
+// delta returns the growth of a counter, treating a reset (e.g. a re-created interface) as no growth
+func delta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// WriteSummary writes the capture as sar-style tables: CPU and memory per sample, then averages and peaks per device
+func (m *HostMetrics) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Host metrics from %s to %s, every %s\n\n", m.Start.Format(time.RFC3339), m.End.Format(time.RFC3339), m.Interval)
+	_, _ = io.WriteString(tw, "time\t%user\t%system\t%iowait\t%steal\t%idle\t%memused\tswapKiB\tload1\t\n")
+	for _, s := range m.Samples {
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%d\t%.2f\t\n",
+			s.Time.Format("15:04:05"), s.CPU.User, s.CPU.System, s.CPU.IOWait, s.CPU.Steal, s.CPU.Idle,
+			s.Memory.UsedPercent, s.Memory.SwapUsedKiB, s.Load[0])
+	}
+
+	fmt.Fprintln(tw, "\ndevice\tavg rKiB/s\tavg wKiB/s\tavg r/s\tavg w/s\tavg %util\tmax %util\t")
+	for _, name := range sampleKeys(m.Samples, func(s Sample) map[string]DiskRate { return s.Disks }) {
+		var sum DiskRate
+		var peak float64
+		n := 0
+		for _, s := range m.Samples {
+			if d, ok := s.Disks[name]; ok {
+				sum.ReadKiBps += d.ReadKiBps
+				sum.WriteKiBps += d.WriteKiBps
+				sum.ReadIOPS += d.ReadIOPS
+				sum.WriteIOPS += d.WriteIOPS
+				sum.UtilPercent += d.UtilPercent
+				peak = max(peak, d.UtilPercent)
+				n++
+			}
+		}
+		c := float64(n)
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n", name,
+			sum.ReadKiBps/c, sum.WriteKiBps/c, sum.ReadIOPS/c, sum.WriteIOPS/c, sum.UtilPercent/c, peak)
+	}
+
+	fmt.Fprintln(tw, "\ninterface\tavg rxKiB/s\tavg txKiB/s\trxerr\ttxerr\trxdrop\ttxdrop\t")
+	for _, name := range sampleKeys(m.Samples, func(s Sample) map[string]NetRate { return s.Network }) {
+		var sum NetRate
+		n := 0
+		for _, s := range m.Samples {
+			if r, ok := s.Network[name]; ok {
+				sum.RxKiBps += r.RxKiBps
+				sum.TxKiBps += r.TxKiBps
+				sum.RxErrors += r.RxErrors
+				sum.TxErrors += r.TxErrors
+				sum.RxDropped += r.RxDropped
+				sum.TxDropped += r.TxDropped
+				n++
+			}
+		}
+		c := float64(n)
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%d\t%d\t%d\t%d\t\n", name,
+			sum.RxKiBps/c, sum.TxKiBps/c, sum.RxErrors, sum.TxErrors, sum.RxDropped, sum.TxDropped)
+	}
+	return tw.Flush()
+}
+
+// sampleKeys returns the sorted device names seen in any sample
+func sampleKeys[T any](samples []Sample, field func(Sample) map[string]T) []string {
+	seen := make(map[string]bool)
+	for _, s := range samples {
+		for name := range field(s) {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
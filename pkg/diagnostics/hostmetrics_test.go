@@ -0,0 +1,220 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testStat = `cpu  1000 0 500 8000 300 0 200 0 0 0
+cpu0 500 0 250 4000 150 0 100 0 0 0
+intr 12345
+`
+	testMeminfo = `MemTotal:       16000000 kB
+MemFree:         2000000 kB
+MemAvailable:    4000000 kB
+SwapTotal:       1000000 kB
+SwapFree:         750000 kB
+`
+	testLoadavg   = "1.50 0.75 0.25 2/300 12345\n"
+	testDiskstats = `   7       0 loop0 100 0 200 10 0 0 0 0 0 10 10 0 0 0 0
+   8       0 sda 1000 0 8000 500 2000 0 16000 900 0 1000 1400 0 0 0 0
+`
+	testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  999999     100    0    0    0     0          0         0   999999     100    0    0    0     0       0          0
+  eth0: 1048576    1000    1    2    0     0          0         0  2097152    2000    3    4    0     0       0          0
+`
+)
+
+func writeProc(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testProcFiles() map[string]string {
+	return map[string]string{
+		"stat":      testStat,
+		"meminfo":   testMeminfo,
+		"loadavg":   testLoadavg,
+		"diskstats": testDiskstats,
+		"net/dev":   testNetDev,
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	writeProc(t, dir, testProcFiles())
+
+	s, err := readSnapshot(dir, time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("readSnapshot() error = %v", err)
+	}
+	if s.CPU.User != 1000 || s.CPU.Idle != 8000 || s.CPU.IOWait != 300 || s.CPU.SoftIRQ != 200 {
+		t.Errorf("CPU = %+v", s.CPU)
+	}
+	if s.Memory != (memInfo{TotalKiB: 16000000, AvailableKiB: 4000000, SwapTotalKiB: 1000000, SwapFreeKiB: 750000}) {
+		t.Errorf("Memory = %+v", s.Memory)
+	}
+	if s.Load != [3]float64{1.5, 0.75, 0.25} {
+		t.Errorf("Load = %v", s.Load)
+	}
+	if _, ok := s.Disks["loop0"]; ok {
+		t.Error("loop devices should be skipped")
+	}
+	if got := s.Disks["sda"]; got != (diskCounters{Reads: 1000, SectorsRead: 8000, Writes: 2000, SectorsWritten: 16000, IOTimeMs: 1000}) {
+		t.Errorf("Disks[sda] = %+v", got)
+	}
+	if _, ok := s.Network["lo"]; ok {
+		t.Error("loopback should be skipped")
+	}
+	if got := s.Network["eth0"]; got != (netCounters{RxBytes: 1048576, RxErrors: 1, RxDropped: 2, TxBytes: 2097152, TxErrors: 3, TxDropped: 4}) {
+		t.Errorf("Network[eth0] = %+v", got)
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "missing file", file: "loadavg", wantErr: "failed to read /proc/loadavg"},
+		{name: "no cpu line", file: "stat", content: "intr 1\n", wantErr: "cpu line not found"},
+		{name: "no MemTotal", file: "meminfo", content: "MemFree: 1 kB\n", wantErr: "MemTotal not found"},
+		{name: "bad counter", file: "diskstats", content: "8 0 sda x 0 0 0 0 0 0 0 0 0 0\n", wantErr: "device sda"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := testProcFiles()
+			if tt.content == "" {
+				delete(files, tt.file)
+			} else {
+				files[tt.file] = tt.content
+			}
+			writeProc(t, dir, files)
+
+			_, err := readSnapshot(dir, time.Now())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readSnapshot() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	start := time.Unix(100, 0)
+	prev := snapshot{
+		Time:    start,
+		CPU:     cpuTimes{User: 100, System: 100, Idle: 700, IOWait: 100},
+		Disks:   map[string]diskCounters{"sda": {Reads: 10, SectorsRead: 0, Writes: 20, SectorsWritten: 0, IOTimeMs: 0}},
+		Network: map[string]netCounters{"eth0": {RxBytes: 0, TxBytes: 0, RxErrors: 5}},
+	}
+	cur := snapshot{
+		Time:    start.Add(2 * time.Second),
+		CPU:     cpuTimes{User: 150, System: 120, Idle: 800, IOWait: 130},
+		Memory:  memInfo{TotalKiB: 1000, AvailableKiB: 250, SwapTotalKiB: 100, SwapFreeKiB: 40},
+		Disks:   map[string]diskCounters{"sda": {Reads: 30, SectorsRead: 4096, Writes: 60, SectorsWritten: 8192, IOTimeMs: 500}, "sdb": {Reads: 1}},
+		Network: map[string]netCounters{"eth0": {RxBytes: 4096, TxBytes: 2048, RxErrors: 7}},
+	}
+
+	s := diffSnapshots(prev, cur)
+
+	// 200 ticks elapsed: 50 user, 20 system, 100 idle, 30 iowait
+	wantCPU := CPUUsage{User: 25, System: 10, IOWait: 15, Idle: 50}
+	if s.CPU != wantCPU {
+		t.Errorf("CPU = %+v, want %+v", s.CPU, wantCPU)
+	}
+	if s.Memory.UsedPercent != 75 || s.Memory.SwapUsedKiB != 60 {
+		t.Errorf("Memory = %+v", s.Memory)
+	}
+	wantDisk := DiskRate{ReadKiBps: 1024, WriteKiBps: 2048, ReadIOPS: 10, WriteIOPS: 20, UtilPercent: 25}
+	if got := s.Disks["sda"]; !approxDisk(got, wantDisk) {
+		t.Errorf("Disks[sda] = %+v, want %+v", got, wantDisk)
+	}
+	if _, ok := s.Disks["sdb"]; ok {
+		t.Error("a device missing from the previous snapshot has no rate yet")
+	}
+	if got := s.Network["eth0"]; got.RxKiBps != 2 || got.TxKiBps != 1 || got.RxErrors != 2 {
+		t.Errorf("Network[eth0] = %+v", got)
+	}
+}
+
+func TestDiffSnapshotsCounterReset(t *testing.T) {
+	start := time.Unix(100, 0)
+	prev := snapshot{Time: start, Network: map[string]netCounters{"eth0": {RxBytes: 5000}}}
+	cur := snapshot{Time: start.Add(time.Second), Network: map[string]netCounters{"eth0": {RxBytes: 10}}}
+
+	if got := diffSnapshots(prev, cur).Network["eth0"].RxKiBps; got != 0 {
+		t.Errorf("RxKiBps after counter reset = %v, want 0", got)
+	}
+}
+
+func TestCaptureHostMetrics(t *testing.T) {
+	dir := t.TempDir()
+	writeProc(t, dir, testProcFiles())
+
+	metrics, err := captureHostMetrics(context.Background(), dir, 30*time.Millisecond, 10*time.Millisecond, time.Now)
+	if err != nil {
+		t.Fatalf("captureHostMetrics() error = %v", err)
+	}
+	if len(metrics.Samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(metrics.Samples))
+	}
+
+	var summary bytes.Buffer
+	if err := metrics.WriteSummary(&summary); err != nil {
+		t.Fatalf("WriteSummary() error = %v", err)
+	}
+	for _, want := range []string{"%iowait", "sda", "eth0"} {
+		if !strings.Contains(summary.String(), want) {
+			t.Errorf("summary missing %q:\n%s", want, summary.String())
+		}
+	}
+}
+
+func TestCaptureHostMetricsCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeProc(t, dir, testProcFiles())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	metrics, err := captureHostMetrics(ctx, dir, time.Hour, time.Second, time.Now)
+	if err != nil {
+		t.Fatalf("captureHostMetrics() error = %v", err)
+	}
+	if len(metrics.Samples) != 0 {
+		t.Errorf("got %d samples after cancellation, want 0", len(metrics.Samples))
+	}
+}
+
+func TestCaptureHostMetricsInvalidTiming(t *testing.T) {
+	if _, err := captureHostMetrics(context.Background(), t.TempDir(), time.Second, 0, time.Now); err == nil {
+		t.Error("expected error for zero interval")
+	}
+	if _, err := captureHostMetrics(context.Background(), t.TempDir(), time.Second, 2*time.Second, time.Now); err == nil {
+		t.Error("expected error for duration shorter than interval")
+	}
+}
+
+func approxDisk(a, b DiskRate) bool {
+	eq := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return eq(a.ReadKiBps, b.ReadKiBps) && eq(a.WriteKiBps, b.WriteKiBps) && eq(a.ReadIOPS, b.ReadIOPS) &&
+		eq(a.WriteIOPS, b.WriteIOPS) && eq(a.UtilPercent, b.UtilPercent)
+}
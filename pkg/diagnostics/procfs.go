@@ -0,0 +1,207 @@
+package diagnostics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cpuTimes holds the cumulative CPU time counters of /proc/stat, in clock ticks
+type cpuTimes struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+func (c cpuTimes) total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// memInfo holds the memory figures of /proc/meminfo, in KiB
+type memInfo struct {
+	TotalKiB, AvailableKiB, SwapTotalKiB, SwapFreeKiB uint64
+}
+
+// diskCounters holds the cumulative counters of one /proc/diskstats device
+type diskCounters struct {
+	Reads, SectorsRead, Writes, SectorsWritten, IOTimeMs uint64
+}
+
+// netCounters holds the cumulative counters of one /proc/net/dev interface
+type netCounters struct {
+	RxBytes, RxErrors, RxDropped, TxBytes, TxErrors, TxDropped uint64
+}
+
+// snapshot is the state of all counters at one point in time
+type snapshot struct {
+	Time    time.Time
+	CPU     cpuTimes
+	Memory  memInfo
+	Load    [3]float64
+	Disks   map[string]diskCounters
+	Network map[string]netCounters
+}
+
+// readSnapshot reads all counters from a proc file system mounted at procDir
+func readSnapshot(procDir string, now time.Time) (snapshot, error) {
+	s := snapshot{Time: now}
+	var err error
+	if s.CPU, err = readFile(procDir, "stat", parseCPUTimes); err != nil {
+		return s, err
+	}
+	if s.Memory, err = readFile(procDir, "meminfo", parseMemInfo); err != nil {
+		return s, err
+	}
+	if s.Load, err = readFile(procDir, "loadavg", parseLoadAvg); err != nil {
+		return s, err
+	}
+	if s.Disks, err = readFile(procDir, "diskstats", parseDiskStats); err != nil {
+		return s, err
+	}
+	if s.Network, err = readFile(procDir, "net/dev", parseNetDev); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// readFile reads a proc file and parses it
+func readFile[T any](procDir, name string, parse func(string) (T, error)) (T, error) {
+	data, err := os.ReadFile(filepath.Join(procDir, name))
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("failed to read /proc/%s: %w", name, err)
+	}
+	value, err := parse(string(data))
+	if err != nil {
+		return value, fmt.Errorf("failed to parse /proc/%s: %w", name, err)
+	}
+	return value, nil
+}
+
+// parseCPUTimes parses the aggregate "cpu" line of /proc/stat
+func parseCPUTimes(content string) (cpuTimes, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] != "cpu" {
+			continue
+		}
+		values, err := parseUints(fields[1:9])
+		if err != nil {
+			return cpuTimes{}, err
+		}
+		return cpuTimes{
+			User: values[0], Nice: values[1], System: values[2], Idle: values[3],
+			IOWait: values[4], IRQ: values[5], SoftIRQ: values[6], Steal: values[7],
+		}, nil
+	}
+	return cpuTimes{}, fmt.Errorf("cpu line not found")
+}
+
+// parseMemInfo parses the total, available and swap figures of /proc/meminfo
+func parseMemInfo(content string) (memInfo, error) {
+	var m memInfo
+	fields := map[string]*uint64{
+		"MemTotal:":     &m.TotalKiB,
+		"MemAvailable:": &m.AvailableKiB,
+		"SwapTotal:":    &m.SwapTotalKiB,
+		"SwapFree:":     &m.SwapFreeKiB,
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			continue
+		}
+		if target, ok := fields[parts[0]]; ok {
+			value, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				return m, fmt.Errorf("invalid %s value %q", parts[0], parts[1])
+			}
+			*target = value
+		}
+	}
+	if m.TotalKiB == 0 {
+		return m, fmt.Errorf("MemTotal not found")
+	}
+	return m, nil
+}
+
+// parseLoadAvg parses the 1, 5 and 15 minute load averages
+func parseLoadAvg(content string) ([3]float64, error) {
+	var load [3]float64
+	fields := strings.Fields(content)
+	if len(fields) < 3 {
+		return load, fmt.Errorf("malformed loadavg %q", strings.TrimSpace(content))
+	}
+	for i := range load {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return load, fmt.Errorf("invalid load average %q", fields[i])
+		}
+		load[i] = value
+	}
+	return load, nil
+}
+
+// parseDiskStats parses /proc/diskstats, skipping loop and ram devices which only add noise
+func parseDiskStats(content string) (map[string]diskCounters, error) {
+	disks := make(map[string]diskCounters)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 14 {
+			continue
+		}
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		// reads completed, reads merged, sectors read, ms reading, writes completed, writes merged,
+		// sectors written, ms writing, I/Os in progress, ms doing I/O
+		values, err := parseUints(fields[3:13])
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", name, err)
+		}
+		disks[name] = diskCounters{Reads: values[0], SectorsRead: values[2], Writes: values[4], SectorsWritten: values[6], IOTimeMs: values[9]}
+	}
+	return disks, nil
+}
+
+// parseNetDev parses /proc/net/dev, skipping the loopback interface
+func parseNetDev(content string) (map[string]netCounters, error) {
+	interfaces := make(map[string]netCounters)
+	for _, line := range strings.Split(content, "\n") {
+		name, counters, found := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "lo" || strings.Contains(name, "|") {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 16 {
+			continue
+		}
+		values, err := parseUints(fields[:16])
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", name, err)
+		}
+		// Receive: bytes packets errs drop fifo frame compressed multicast; transmit: bytes packets errs drop ...
+		interfaces[name] = netCounters{
+			RxBytes: values[0], RxErrors: values[2], RxDropped: values[3],
+			TxBytes: values[8], TxErrors: values[10], TxDropped: values[11],
+		}
+	}
+	return interfaces, nil
+}
+
+func parseUints(fields []string) ([]uint64, error) {
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter %q", field)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// DiscoverCluster reads the target cluster's location and node resource group from ARM when the configuration
+// only names the cluster, records them for later runs and applies them to cfg. A failure is only fatal when the
+// location is not configured, as the node cannot be registered without it.
+func DiscoverCluster(ctx context.Context, cfg *config.Config, logger *logrus.Logger) error {
+	if !cfg.NeedsClusterDiscovery() {
+		return nil
+	}
+	discovered, err := readCluster(ctx, cfg)
+	if err != nil {
+		if cfg.GetTargetClusterLocation() == "" {
+			return fmt.Errorf("azure.targetCluster.location is not set and could not be read from the cluster: %w", err)
+		}
+		logger.Warnf("Failed to read cluster %s, assuming node resource group %s: %v",
+			cfg.GetTargetClusterID(), cfg.GetTargetClusterNodeResourceGroup(), err)
+		return nil
+	}
+
+	if err := cfg.ApplyDiscoveredCluster(*discovered); err != nil {
+		return err
+	}
+	logger.Infof("Cluster %s is in %s with node resource group %s",
+		cfg.GetTargetClusterName(), cfg.GetTargetClusterLocation(), cfg.GetTargetClusterNodeResourceGroup())
+	for _, ra := range cfg.GetArcRoleAssignments() {
+		logger.Debugf("Role assignment %s at scope %s", ra.Role, ra.Scope)
+	}
+	if err := config.RecordDiscoveredCluster(*discovered); err != nil {
+		logger.Warnf("Failed to record the discovered cluster, it will be read again on the next run: %v", err)
+	}
+	return nil
+}
+
+// readCluster gets the target cluster from ARM with the cluster tenant's credential
+func readCluster(ctx context.Context, cfg *config.Config) (*config.DiscoveredCluster, error) {
+	cred, err := auth.NewAuthProvider().ClusterCredential(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster tenant credential: %w", err)
+	}
+	client, err := armcontainerservice.NewManagedClustersClient(cfg.GetTargetClusterSubscriptionID(), cred, auth.ARMClientOptions(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed clusters client: %w", err)
+	}
+
+	opCtx, cancel := auth.OperationContext(ctx, cfg)
+	defer cancel()
+	resp, err := client.Get(opCtx, cfg.GetTargetClusterResourceGroup(), cfg.GetTargetClusterName(), nil)
+	if err != nil {
+		return nil, azerrors.Wrap(err)
+	}
+	return fromManagedCluster(cfg.GetTargetClusterID(), &resp.ManagedCluster)
+}
+
+// fromManagedCluster extracts what the configuration may leave out from a managed cluster
+func fromManagedCluster(resourceID string, cluster *armcontainerservice.ManagedCluster) (*config.DiscoveredCluster, error) {
+	if cluster.Location == nil || *cluster.Location == "" {
+		return nil, fmt.Errorf("cluster %s has no location", resourceID)
+	}
+	if cluster.Properties == nil || cluster.Properties.NodeResourceGroup == nil || *cluster.Properties.NodeResourceGroup == "" {
+		return nil, fmt.Errorf("cluster %s has no node resource group", resourceID)
+	}
+	return &config.DiscoveredCluster{
+		ResourceID:        resourceID,
+		Location:          *cluster.Location,
+		NodeResourceGroup: *cluster.Properties.NodeResourceGroup,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+)
+
+func TestFromManagedCluster(t *testing.T) {
+	const clusterID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"
+
+	discovered, err := fromManagedCluster(clusterID, &armcontainerservice.ManagedCluster{
+		Location:   to.Ptr("westeurope"),
+		Properties: &armcontainerservice.ManagedClusterProperties{NodeResourceGroup: to.Ptr("custom-nodes")},
+	})
+	if err != nil {
+		t.Fatalf("fromManagedCluster() unexpected error: %v", err)
+	}
+	if discovered.ResourceID != clusterID || discovered.Location != "westeurope" || discovered.NodeResourceGroup != "custom-nodes" {
+		t.Errorf("fromManagedCluster() = %+v", discovered)
+	}
+
+	for name, cluster := range map[string]*armcontainerservice.ManagedCluster{
+		"no location":            {Properties: &armcontainerservice.ManagedClusterProperties{NodeResourceGroup: to.Ptr("custom-nodes")}},
+		"no properties":          {Location: to.Ptr("westeurope")},
+		"no node resource group": {Location: to.Ptr("westeurope"), Properties: &armcontainerservice.ManagedClusterProperties{}},
+	} {
+		if _, err := fromManagedCluster(clusterID, cluster); err == nil {
+			t.Errorf("fromManagedCluster(%s) expected error", name)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ErrChecksumMismatch is returned when an artifact does not have its pinned SHA-256 digest
+var ErrChecksumMismatch = errors.New("artifact checksum mismatch")
+
+// Cache is a content-addressed store of downloaded artifacts. The layout is:
+//
+//	sha256/<digest>     the artifact, named by the SHA-256 digest of its content
+//	urls/<url digest>   the artifact digest a download URL resolved to, named by the SHA-256 digest of the URL
+//
+// Copying a cache directory to another node, or pointing a seed directory at it, reuses its artifacts there.
+type Cache struct {
+	dir   string
+	seeds []string
+}
+
+// NewCache creates a cache stored in dir that also reads from the read-only seed directories
+func NewCache(dir string, seeds []string) *Cache {
+	return &Cache{dir: dir, seeds: seeds}
+}
+
+// Lookup returns the path of the cached artifact for url. When digest is set only an artifact with that
+// digest is returned; otherwise the digest is taken from the URL index. Artifacts found in a seed
+// directory are imported into the cache first. Corrupted artifacts are ignored.
+func (c *Cache) Lookup(url, digest string) (string, bool) {
+	for _, dir := range append([]string{c.dir}, c.seeds...) {
+		want := digest
+		if want == "" {
+			want = readIndex(dir, url)
+		}
+		if want == "" {
+			continue
+		}
+
+		path := blobPath(dir, want)
+		if got, err := fileDigest(path); err != nil || got != want {
+			continue
+		}
+		if dir == c.dir {
+			return path, true
+		}
+		if _, err := c.Add(url, path, want); err != nil {
+			// The seed copy is valid; use it in place
+			return path, true
+		}
+		return blobPath(c.dir, want), true
+	}
+	return "", false
+}
+
+// Add stores the file at path as the artifact downloaded from url and returns its digest.
+// When digest is set, a file with different content is rejected with ErrChecksumMismatch.
+func (c *Cache) Add(url, path, digest string) (string, error) {
+	got, err := fileDigest(path)
+	if err != nil {
+		return "", err
+	}
+	if digest != "" && got != digest {
+		return "", fmt.Errorf("%w: %s has SHA-256 %s, expected %s", ErrChecksumMismatch, url, got, digest)
+	}
+
+	blob := blobPath(c.dir, got)
+	if !utils.FileExists(blob) {
+		if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(blob)); err != nil {
+			return "", fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		// Copy next to the final name first, so an interrupted copy never leaves a truncated artifact
+		tmp := blob + ".tmp"
+		if err := utils.RunSystemCommand("cp", path, tmp); err != nil {
+			return "", fmt.Errorf("failed to copy %s into the cache: %w", path, err)
+		}
+		if err := utils.RunSystemCommand("chmod", "0644", tmp); err != nil {
+			return "", fmt.Errorf("failed to set permissions of cached artifact: %w", err)
+		}
+		if err := utils.RunSystemCommand("mv", tmp, blob); err != nil {
+			return "", fmt.Errorf("failed to store cached artifact: %w", err)
+		}
+	}
+
+	index := indexPath(c.dir, url)
+	if readIndex(c.dir, url) != got {
+		if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(index)); err != nil {
+			return "", fmt.Errorf("failed to create cache index directory: %w", err)
+		}
+		if err := utils.WriteFileAtomicSystem(index, []byte(got+"\n"), 0o644); err != nil {
+			return "", fmt.Errorf("failed to write cache index for %s: %w", url, err)
+		}
+	}
+	return got, nil
+}
+
+// blobPath returns the path of the artifact with the given digest in a cache directory
+func blobPath(dir, digest string) string {
+	return filepath.Join(dir, "sha256", digest)
+}
+
+// indexPath returns the path of the index entry for url in a cache directory
+func indexPath(dir, url string) string {
+	return filepath.Join(dir, "urls", urlKey(url))
+}
+
+// urlKey returns the name of the index entry for url: the hex encoded SHA-256 digest of the URL
+func urlKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// readIndex returns the artifact digest recorded for url in a cache directory, or "" when there is none
+func readIndex(dir, url string) string {
+	data, err := os.ReadFile(indexPath(dir, url))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of the file at path
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,373 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const testURL = "https://example.com/releases/v1.0.0/artifact.tar.gz"
+
+func digestOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeTestFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "artifact")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return path
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return logger
+}
+
+func TestCacheAddAndLookup(t *testing.T) {
+	cache := NewCache(t.TempDir(), nil)
+
+	digest, err := cache.Add(testURL, writeTestFile(t, "artifact"), "")
+	if err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	if digest != digestOf("artifact") {
+		t.Errorf("Add() digest = %s, want %s", digest, digestOf("artifact"))
+	}
+
+	path, ok := cache.Lookup(testURL, "")
+	if !ok || filepath.Base(path) != digest {
+		t.Fatalf("Lookup() = %s, %v, want the artifact stored by digest", path, ok)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "artifact" {
+		t.Errorf("Lookup() returned content %q, want %q", data, "artifact")
+	}
+
+	if _, ok := cache.Lookup(testURL, digestOf("other")); ok {
+		t.Error("Lookup() should miss when the pinned digest differs")
+	}
+	if _, ok := cache.Lookup("https://example.com/other.tar.gz", ""); ok {
+		t.Error("Lookup() should miss for an unknown URL")
+	}
+}
+
+func TestCacheAddChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(dir, nil)
+
+	_, err := cache.Add(testURL, writeTestFile(t, "tampered"), digestOf("artifact"))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Add() error = %v, want ErrChecksumMismatch", err)
+	}
+	if _, ok := cache.Lookup(testURL, ""); ok {
+		t.Error("Add() should not cache an artifact with the wrong digest")
+	}
+}
+
+func TestCacheLookupImportsFromSeed(t *testing.T) {
+	seed := t.TempDir()
+	if _, err := NewCache(seed, nil).Add(testURL, writeTestFile(t, "artifact"), ""); err != nil {
+		t.Fatalf("Add() to seed unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	cache := NewCache(dir, []string{seed})
+	path, ok := cache.Lookup(testURL, "")
+	if !ok {
+		t.Fatal("Lookup() should find the artifact in the seed directory")
+	}
+	if want := blobPath(dir, digestOf("artifact")); path != want {
+		t.Errorf("Lookup() = %s, want the artifact imported to %s", path, want)
+	}
+	if got := readIndex(dir, testURL); got != digestOf("artifact") {
+		t.Errorf("Lookup() should index the imported artifact, got %q", got)
+	}
+}
+
+func TestCacheLookupIgnoresCorruptArtifact(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(dir, nil)
+	digest, err := cache.Add(testURL, writeTestFile(t, "artifact"), "")
+	if err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	if err := os.WriteFile(blobPath(dir, digest), []byte("truncated"), 0o644); err != nil {
+		t.Fatalf("Failed to corrupt artifact: %v", err)
+	}
+
+	if _, ok := cache.Lookup(testURL, ""); ok {
+		t.Error("Lookup() should ignore an artifact whose content no longer matches its digest")
+	}
+}
+
+func TestManagerFetch(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+	url := server.URL + "/v1.0.0/artifact.tar.gz"
+
+	fetch := func(t *testing.T, cfg *config.Config) error {
+		t.Helper()
+		dest := filepath.Join(t.TempDir(), "artifact.tar.gz")
+		if err := NewManager(cfg, newTestLogger()).Fetch(context.Background(), url, dest); err != nil {
+			return err
+		}
+		if data, _ := os.ReadFile(dest); string(data) != "artifact" {
+			t.Errorf("Fetch() wrote %q, want %q", data, "artifact")
+		}
+		return nil
+	}
+
+	t.Run("second fetch is served from the cache", func(t *testing.T) {
+		requests.Store(0)
+		cfg := &config.Config{Downloads: config.DownloadsConfig{Cache: config.DownloadCacheConfig{Directory: t.TempDir()}}}
+		for range 2 {
+			if err := fetch(t, cfg); err != nil {
+				t.Fatalf("Fetch() unexpected error: %v", err)
+			}
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Fetch() made %d requests, want 1", got)
+		}
+	})
+
+	t.Run("disabled cache always downloads", func(t *testing.T) {
+		requests.Store(0)
+		cfg := &config.Config{Downloads: config.DownloadsConfig{Cache: config.DownloadCacheConfig{Disabled: true}}}
+		for range 2 {
+			if err := fetch(t, cfg); err != nil {
+				t.Fatalf("Fetch() unexpected error: %v", err)
+			}
+		}
+		if got := requests.Load(); got != 2 {
+			t.Errorf("Fetch() made %d requests, want 2", got)
+		}
+	})
+
+	t.Run("pinned checksum mismatch fails", func(t *testing.T) {
+		for _, disabled := range []bool{false, true} {
+			cfg := &config.Config{Downloads: config.DownloadsConfig{
+				Cache:     config.DownloadCacheConfig{Disabled: disabled, Directory: t.TempDir()},
+				Checksums: []config.ArtifactChecksum{{URL: url, SHA256: strings.ToUpper(digestOf("expected"))}},
+			}}
+			if err := fetch(t, cfg); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("Fetch() with cache disabled=%v error = %v, want ErrChecksumMismatch", disabled, err)
+			}
+		}
+	})
+}
+
+func TestManagerFetchRecordsProvenance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+	url := server.URL + "/v1.0.0/artifact.tar.gz"
+
+	oldPath := provenancePath
+	provenancePath = filepath.Join(t.TempDir(), "state", "provenance.json")
+	t.Cleanup(func() { provenancePath = oldPath })
+
+	cfg := &config.Config{Downloads: config.DownloadsConfig{Cache: config.DownloadCacheConfig{Directory: t.TempDir()}}}
+	manager := NewManager(cfg, newTestLogger())
+	for _, source := range []string{SourceOrigin, SourceCache} {
+		dest := filepath.Join(t.TempDir(), "artifact.tar.gz")
+		if err := manager.For("runc", "1.0.0").Fetch(context.Background(), url, dest); err != nil {
+			t.Fatalf("Fetch() unexpected error: %v", err)
+		}
+		records, err := LoadProvenance()
+		if err != nil {
+			t.Fatalf("LoadProvenance() unexpected error: %v", err)
+		}
+		if len(records) != 1 {
+			t.Fatalf("LoadProvenance() = %+v, want one record per component", records)
+		}
+		got := records[0]
+		if got.Component != "runc" || got.Version != "1.0.0" || got.URL != url || got.SHA256 != digestOf("artifact") || got.Source != source {
+			t.Errorf("LoadProvenance() = %+v, want runc 1.0.0 from %s", got, source)
+		}
+	}
+
+	// Unlabeled fetches are not recorded
+	if err := manager.Fetch(context.Background(), url, filepath.Join(t.TempDir(), "other")); err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if records, _ := LoadProvenance(); len(records) != 1 {
+		t.Errorf("LoadProvenance() = %+v after an unlabeled fetch, want only runc", records)
+	}
+}
+
+func TestPeerHandler(t *testing.T) {
+	dir := t.TempDir()
+	digest, err := NewCache(dir, nil).Add(testURL, writeTestFile(t, "artifact"), "")
+	if err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	server := httptest.NewServer(NewPeerHandler(NewCache(dir, nil)))
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{path: "/sha256/" + digest, wantStatus: http.StatusOK, wantBody: "artifact"},
+		{path: "/urls/" + urlKey(testURL), wantStatus: http.StatusOK, wantBody: digest + "\n"},
+		{path: "/sha256/" + digestOf("missing"), wantStatus: http.StatusNotFound},
+		{path: "/sha256/..%2f..%2fetc%2fpasswd", wantStatus: http.StatusNotFound},
+		{path: "/", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s unexpected error: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("GET %s status = %d, want %d", tt.path, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantBody != "" && string(body) != tt.wantBody {
+			t.Errorf("GET %s body = %q, want %q", tt.path, body, tt.wantBody)
+		}
+	}
+}
+
+func TestManagerFetchFromPeer(t *testing.T) {
+	var internetRequests atomic.Int32
+	internet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internetRequests.Add(1)
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer internet.Close()
+	url := internet.URL + "/v1.0.0/artifact.tar.gz"
+
+	peerDir := t.TempDir()
+	if _, err := NewCache(peerDir, nil).Add(url, writeTestFile(t, "artifact"), ""); err != nil {
+		t.Fatalf("Add() to peer cache unexpected error: %v", err)
+	}
+	peer := httptest.NewServer(NewPeerHandler(NewCache(peerDir, nil)))
+	defer peer.Close()
+
+	// A peer that serves the wrong content for every artifact
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/urls/") {
+			_, _ = w.Write([]byte(digestOf("artifact")))
+			return
+		}
+		_, _ = w.Write([]byte("tampered"))
+	}))
+	defer tampered.Close()
+
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+
+	tests := []struct {
+		name          string
+		peers         []string
+		unpinned      bool
+		trustUnpinned bool
+		wantInternet  int32
+		cacheDisabled bool
+	}{
+		{name: "peer has the artifact", peers: []string{peer.URL}, wantInternet: 0},
+		{name: "peer without cache on this node", peers: []string{peer.URL}, wantInternet: 0, cacheDisabled: true},
+		{name: "unreachable and empty peers fall back to the internet", peers: []string{"http://127.0.0.1:1", empty.URL}, wantInternet: 1},
+		{name: "tampered artifact is rejected", peers: []string{tampered.URL}, wantInternet: 1},
+		{name: "unpinned artifact is not taken from peers", peers: []string{peer.URL}, unpinned: true, wantInternet: 1},
+		{name: "unpinned artifact from trusted peers", peers: []string{peer.URL}, unpinned: true, trustUnpinned: true, wantInternet: 0},
+		{name: "tampered unpinned artifact is rejected", peers: []string{tampered.URL}, unpinned: true, trustUnpinned: true, wantInternet: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			internetRequests.Store(0)
+			dir := t.TempDir()
+			cfg := &config.Config{Downloads: config.DownloadsConfig{
+				Cache: config.DownloadCacheConfig{Directory: dir, Disabled: tt.cacheDisabled},
+				Peers: config.DownloadPeersConfig{URLs: tt.peers, TrustUnpinned: tt.trustUnpinned},
+			}}
+			if !tt.unpinned {
+				cfg.Downloads.Checksums = []config.ArtifactChecksum{{URL: url, SHA256: digestOf("artifact")}}
+			}
+			dest := filepath.Join(t.TempDir(), "artifact.tar.gz")
+			if err := NewManager(cfg, newTestLogger()).Fetch(context.Background(), url, dest); err != nil {
+				t.Fatalf("Fetch() unexpected error: %v", err)
+			}
+			if data, _ := os.ReadFile(dest); string(data) != "artifact" {
+				t.Errorf("Fetch() wrote %q, want %q", data, "artifact")
+			}
+			if got := internetRequests.Load(); got != tt.wantInternet {
+				t.Errorf("Fetch() made %d internet requests, want %d", got, tt.wantInternet)
+			}
+			if !tt.cacheDisabled && readIndex(dir, url) != digestOf("artifact") {
+				t.Error("Fetch() should cache the artifact it got")
+			}
+		})
+	}
+}
+
+func TestLimitReader(t *testing.T) {
+	const size = 64 * 1024
+	data := strings.Repeat("x", size)
+
+	if r := limitReader(context.Background(), strings.NewReader(data), nil, nil); r == nil {
+		t.Fatal("limitReader() returned nil")
+	} else if _, ok := r.(*limitedReader); ok {
+		t.Error("limitReader() without limiters should return the reader unchanged")
+	}
+
+	// At 128Ki/s with a 128Ki burst the first 64Ki pass at once; the burst is spent after that
+	limiter := newLimiter(128 * 1024)
+	r := limitReader(context.Background(), strings.NewReader(data), limiter)
+	got, err := io.ReadAll(r)
+	if err != nil || len(got) != size {
+		t.Fatalf("ReadAll() = %d bytes, %v, want %d bytes", len(got), err, size)
+	}
+	if tokens := limiter.Tokens(); tokens > 64*1024+1024 {
+		t.Errorf("limiter has %.0f tokens left, want the read to have spent about 64Ki of 128Ki", tokens)
+	}
+
+	// A cancelled context stops a throttled read
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := newLimiter(1024)
+	slow.AllowN(time.Now(), slow.Burst())
+	if _, err := io.ReadAll(limitReader(ctx, strings.NewReader(data), slow)); err == nil {
+		t.Error("ReadAll() should fail once the context is cancelled")
+	}
+}
+
+func TestSharedLimiter(t *testing.T) {
+	if sharedLimiter(0) != nil {
+		t.Error("sharedLimiter(0) should be unlimited")
+	}
+	first := sharedLimiter(1024 * 1024)
+	second := sharedLimiter(2 * 1024 * 1024)
+	if first != second {
+		t.Error("sharedLimiter() should return the same limiter to every download")
+	}
+	if second.Limit() != 2*1024*1024 {
+		t.Errorf("sharedLimiter() limit = %v, want the latest rate", second.Limit())
+	}
+}
@@ -0,0 +1,212 @@
+// Package download fetches the release artifacts installed during bootstrap, reusing a shared
+// content-addressed cache across components and runs.
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
+)
+
+// downloadTimeout bounds a single artifact download
+const downloadTimeout = 10 * time.Minute
+
+// errNotFound is returned when the server has no file at the download URL
+var errNotFound = errors.New("not found")
+
+// Manager downloads release artifacts through the artifact cache and LAN peers
+type Manager struct {
+	config *config.Config
+	logger *logrus.Logger
+	cache  *Cache // nil when the cache is disabled
+
+	client     *http.Client
+	peerClient *http.Client
+
+	global          *rate.Limiter // Shared by all downloads in the process; nil is unlimited
+	perArtifactRate int64         // Bytes per second of each download; 0 is unlimited
+
+	component, version string // Labels the provenance of fetched artifacts; see For
+}
+
+// NewManager creates a download manager for the cache configured in cfg
+func NewManager(cfg *config.Config, logger *logrus.Logger) *Manager {
+	m := &Manager{
+		config: cfg,
+		logger: logger,
+		client: &http.Client{Timeout: downloadTimeout},
+	}
+	globalRate, perArtifactRate := cfg.GetDownloadRateLimits()
+	m.global = sharedLimiter(globalRate)
+	m.perArtifactRate = perArtifactRate
+	if len(cfg.Downloads.Peers.URLs) > 0 {
+		m.peerClient = newPeerClient()
+	}
+	if !cfg.Downloads.Cache.Disabled {
+		m.cache = NewCache(cfg.GetDownloadCacheDirectory(), cfg.Downloads.Cache.SeedDirectories)
+	}
+	return m
+}
+
+// Fetch writes the artifact at url to destination. It is taken from the cache when the cache holds it,
+// otherwise from the first LAN peer that has it, and otherwise downloaded from url; new artifacts are
+// then cached. Only use it for immutable, versioned release artifacts: a URL whose content changes
+// keeps resolving to the first download.
+func (m *Manager) Fetch(ctx context.Context, url, destination string) error {
+	digest := m.config.GetArtifactChecksum(url)
+
+	if m.cache != nil {
+		if path, ok := m.cache.Lookup(url, digest); ok {
+			m.logger.Infof("Using cached artifact %s for %s", path, url)
+			if err := copyFile(path, destination); err != nil {
+				return fmt.Errorf("failed to copy cached artifact for %s: %w", url, err)
+			}
+			m.recordProvenance(url, destination, digest, SourceCache, "")
+			return nil
+		}
+	}
+
+	source, peer := SourcePeer, m.fetchFromPeers(ctx, url, digest, destination)
+	if peer == "" {
+		source = SourceOrigin
+		if err := m.get(ctx, m.client, url, destination); err != nil {
+			return err
+		}
+	}
+	if err := m.store(url, destination, digest); err != nil {
+		return err
+	}
+	m.recordProvenance(url, destination, digest, source, peer)
+	return nil
+}
+
+// fetchFromPeers tries each configured LAN peer in order and returns the one that provided the artifact,
+// or "" when none had it. Peers serve plain HTTP, so an artifact without a pinned digest is only taken from
+// them when downloads.peers.trustUnpinned is set.
+func (m *Manager) fetchFromPeers(ctx context.Context, url, digest, destination string) string {
+	peers := m.config.Downloads.Peers
+	if len(peers.URLs) == 0 {
+		return ""
+	}
+	if digest == "" {
+		if !peers.TrustUnpinned {
+			m.logger.Debugf("Not asking LAN peers for %s, which has no pinned checksum", url)
+			return ""
+		}
+		m.logger.Warnf("Asking LAN peers for %s without a pinned checksum; the artifact is verified only against "+
+			"the digest the peer reports", url)
+	}
+	for _, peer := range peers.URLs {
+		err := m.fetchFromPeer(ctx, peer, url, digest, destination)
+		if err == nil {
+			m.logger.Infof("Downloaded %s from LAN peer %s", url, peer)
+			return peer
+		}
+		if errors.Is(err, errNotOnPeer) {
+			m.logger.Debugf("LAN peer %s does not have %s", peer, url)
+		} else {
+			m.logger.Warnf("Failed to download %s from LAN peer %s: %v", url, peer, err)
+		}
+	}
+	return ""
+}
+
+// store verifies a downloaded artifact against its pinned digest and adds it to the cache
+func (m *Manager) store(url, path, digest string) error {
+	if m.cache == nil {
+		if digest == "" {
+			return nil
+		}
+		got, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		if got != digest {
+			return fmt.Errorf("%w: %s has SHA-256 %s, expected %s", ErrChecksumMismatch, url, got, digest)
+		}
+		return nil
+	}
+
+	if _, err := m.cache.Add(url, path, digest); err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			return err
+		}
+		// The artifact was downloaded fine; only later runs lose the cache hit
+		m.logger.Warnf("Failed to cache artifact from %s: %v", url, err)
+	}
+	return nil
+}
+
+// get downloads url to destination within the configured bandwidth limits
+func (m *Manager) get(ctx context.Context, client *http.Client, url, destination string) error {
+	defer profiling.Track(profiling.Download)()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download from %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", errNotFound, url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed with status %d for %s", resp.StatusCode, url)
+	}
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", destination, err)
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	body := limitReader(ctx, resp.Body, m.global, newLimiter(m.perArtifactRate))
+	written, err := io.Copy(out, body)
+	profiling.CountDownloaded(written)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", destination, err)
+	}
+	return out.Close()
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,139 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// peerDialTimeout keeps an unreachable peer from delaying the fallback to the internet
+	peerDialTimeout = 3 * time.Second
+	// peerIndexTimeout bounds the lookup of a URL in a peer's index
+	peerIndexTimeout = 10 * time.Second
+)
+
+var digestPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// errNotOnPeer is returned when a peer does not hold an artifact
+var errNotOnPeer = errors.New("artifact not available from peer")
+
+// NewPeerHandler returns an HTTP handler that serves the artifacts and URL index of a cache directory
+// with the same layout as on disk, so peers can fetch from it like from a seed directory
+func NewPeerHandler(cache *Cache) http.Handler {
+	mux := http.NewServeMux()
+	serve := func(subdir string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			name := r.PathValue("digest")
+			if !digestPattern.MatchString(name) {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeFile(w, r, filepath.Join(cache.dir, subdir, name))
+		}
+	}
+	mux.HandleFunc("GET /sha256/{digest}", serve("sha256"))
+	mux.HandleFunc("GET /urls/{digest}", serve("urls"))
+	return mux
+}
+
+// ServePeers serves the artifact cache to LAN peers on address until ctx is done
+func ServePeers(ctx context.Context, address string, cache *Cache, logger *logrus.Logger) error {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           NewPeerHandler(cache),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("Serving the artifact cache to LAN peers on %s", address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("artifact peer server failed: %w", err)
+	}
+	return nil
+}
+
+// newPeerClient returns an HTTP client that gives up quickly on unreachable peers
+func newPeerClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: peerDialTimeout}).DialContext
+	transport.Proxy = nil // Peers are on the local network
+	return &http.Client{Timeout: downloadTimeout, Transport: transport}
+}
+
+// fetchFromPeer downloads the artifact for url from a peer to destination and verifies its digest.
+// Without a pinned digest the peer's URL index decides which artifact to fetch, so the check only
+// catches transfer errors.
+func (m *Manager) fetchFromPeer(ctx context.Context, peer, url, digest, destination string) error {
+	peer = strings.TrimSuffix(peer, "/")
+	if digest == "" {
+		var err error
+		if digest, err = m.peerIndex(ctx, peer, url); err != nil {
+			return err
+		}
+	}
+
+	if err := m.get(ctx, m.peerClient, peer+"/sha256/"+digest, destination); err != nil {
+		if errors.Is(err, errNotFound) {
+			return errNotOnPeer
+		}
+		return err
+	}
+	got, err := fileDigest(destination)
+	if err != nil {
+		return err
+	}
+	if got != digest {
+		return fmt.Errorf("%w: artifact from %s has SHA-256 %s, expected %s", ErrChecksumMismatch, peer, got, digest)
+	}
+	return nil
+}
+
+// peerIndex returns the artifact digest a peer recorded for url
+func (m *Manager) peerIndex(ctx context.Context, peer, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerIndexTimeout)
+	defer cancel()
+
+	indexURL := peer + "/urls/" + urlKey(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.peerClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errNotOnPeer
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("peer index lookup failed with status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 128))
+	if err != nil {
+		return "", err
+	}
+	digest := strings.TrimSpace(string(data))
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("peer returned an invalid digest %q", digest)
+	}
+	return digest, nil
+}
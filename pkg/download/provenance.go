@@ -0,0 +1,113 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ProvenancePath records where each installed component's artifact came from
+const ProvenancePath = "/var/lib/aks-flex-node/provenance.json"
+
+// Replaced in tests
+var provenancePath = ProvenancePath
+
+// Where an artifact was taken from
+const (
+	SourceOrigin = "origin" // Downloaded from its release URL
+	SourceCache  = "cache"  // Copied from the artifact cache
+	SourcePeer   = "peer"   // Downloaded from a LAN peer
+)
+
+// Provenance records the artifact a component was installed from
+type Provenance struct {
+	Component      string    `json:"component"`
+	Version        string    `json:"version"`
+	URL            string    `json:"url"`
+	SHA256         string    `json:"sha256"`
+	Source         string    `json:"source"`                   // SourceOrigin, SourceCache or SourcePeer
+	Peer           string    `json:"peer,omitempty"`           // LAN peer the artifact was taken from
+	ChecksumPinned bool      `json:"checksumPinned,omitempty"` // Whether the SHA-256 was checked against a pinned digest
+	FetchedAt      time.Time `json:"fetchedAt"`
+}
+
+// For returns a manager recording the provenance of the artifacts it fetches as those of component at
+// version, so the SBOM can list where each installed component came from
+func (m *Manager) For(component, version string) *Manager {
+	labeled := *m
+	labeled.component, labeled.version = component, version
+	return &labeled
+}
+
+// recordProvenance records the artifact fetched to path. Provenance is informational, so failures are
+// only logged.
+func (m *Manager) recordProvenance(url, path, digest, source, peer string) {
+	if m.component == "" {
+		return
+	}
+	sum, err := fileDigest(path)
+	if err == nil {
+		err = saveProvenance(Provenance{
+			Component:      m.component,
+			Version:        m.version,
+			URL:            url,
+			SHA256:         sum,
+			Source:         source,
+			Peer:           peer,
+			ChecksumPinned: digest != "",
+			FetchedAt:      time.Now().UTC(),
+		})
+	}
+	if err != nil {
+		m.logger.Warnf("Failed to record the provenance of %s: %v", url, err)
+	}
+}
+
+// LoadProvenance returns the recorded artifacts sorted by component, or nil when none were recorded
+func LoadProvenance() ([]Provenance, error) {
+	data, err := os.ReadFile(provenancePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", provenancePath, err)
+	}
+	var records []Provenance
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", provenancePath, err)
+	}
+	return records, nil
+}
+
+// saveProvenance replaces the record of the component; an upgrade installs the component from a new artifact
+func saveProvenance(record Provenance) error {
+	records, err := LoadProvenance()
+	if err != nil {
+		return err
+	}
+	updated := []Provenance{record}
+	for _, existing := range records {
+		if existing.Component != record.Component {
+			updated = append(updated, existing)
+		}
+	}
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].Component < updated[j].Component
+	})
+
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(provenancePath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(provenancePath), err)
+	}
+	return utils.WriteFileAtomicSystem(provenancePath, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,86 @@
+package download
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// minBurst lets a limited reader pass a read buffer's worth of bytes at once even at low rates
+const minBurst = 32 * 1024
+
+// globalLimiter is shared by all downloads in the process, whichever component starts them
+var (
+	globalMu      sync.Mutex
+	globalLimiter *rate.Limiter
+)
+
+// sharedLimiter returns the process-wide limiter for bytesPerSecond, or nil when it is 0
+func sharedLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if globalLimiter == nil {
+		globalLimiter = newLimiter(bytesPerSecond)
+	} else if globalLimiter.Limit() != rate.Limit(bytesPerSecond) {
+		globalLimiter.SetLimit(rate.Limit(bytesPerSecond))
+		globalLimiter.SetBurst(burst(bytesPerSecond))
+	}
+	return globalLimiter
+}
+
+// newLimiter returns a limiter for bytesPerSecond, or nil when it is 0
+func newLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst(bytesPerSecond))
+}
+
+// burst allows up to a second's worth of bytes at once, and at least one read buffer
+func burst(bytesPerSecond int64) int {
+	return int(max(bytesPerSecond, minBurst))
+}
+
+// limitedReader throttles reads to every limiter in limiters
+type limitedReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*rate.Limiter
+}
+
+// limitReader returns r throttled to the non-nil limiters; r itself when there are none
+func limitReader(ctx context.Context, r io.Reader, limiters ...*rate.Limiter) io.Reader {
+	var active []*rate.Limiter
+	for _, limiter := range limiters {
+		if limiter != nil {
+			active = append(active, limiter)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiters: active}
+}
+
+// Read reads at most a burst of bytes, then waits until every limiter allows them
+func (l *limitedReader) Read(p []byte) (int, error) {
+	for _, limiter := range l.limiters {
+		if burst := limiter.Burst(); len(p) > burst {
+			p = p[:burst]
+		}
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		for _, limiter := range l.limiters {
+			if waitErr := limiter.WaitN(l.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
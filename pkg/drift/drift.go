@@ -0,0 +1,173 @@
+// Package drift detects binaries and configuration files that were changed outside of bootstrap,
+// such as a hand-edited containerd config.toml or a replaced kubelet binary.
+package drift
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// ManifestPath is where the digests of the files installed by the last successful bootstrap are recorded
+	ManifestPath = "/var/lib/aks-flex-node/drift-manifest.json"
+	// ReportPath holds one line per drifted file, empty when nothing drifted. NPD reads it to raise the drift event.
+	ReportPath = "/var/lib/aks-flex-node/drift-report"
+)
+
+// Problems reported for a drifted file
+const (
+	Modified = "modified"
+	Missing  = "missing"
+)
+
+// Entry is the recorded digest of a file installed by a bootstrap step
+type Entry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Step   string `json:"step"` // Name of the bootstrap step that installs the file
+}
+
+// Manifest lists the files installed by bootstrap with their digests at install time
+type Manifest struct {
+	RecordedAt time.Time `json:"recordedAt"`
+	Files      []Entry   `json:"files"`
+}
+
+// Finding is a recorded file whose content no longer matches the manifest
+type Finding struct {
+	Path    string
+	Step    string
+	Problem string // Modified or Missing
+}
+
+// String describes the finding, e.g. "modified /etc/containerd/config.toml (ContainerdInstaller)"
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s (%s)", f.Problem, f.Path, f.Step)
+}
+
+// NewManifest records the current digest of each file, keyed by the name of the step that owns it.
+// Files that do not exist are left out; not every release ships every optional binary.
+func NewManifest(files map[string][]string) (*Manifest, error) {
+	manifest := &Manifest{RecordedAt: time.Now().UTC()}
+	for step, paths := range files {
+		for _, path := range paths {
+			digest, err := fileDigest(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			manifest.Files = append(manifest.Files, Entry{Path: path, SHA256: digest, Step: step})
+		}
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return manifest, nil
+}
+
+// Check compares the recorded files with their current content. Files that cannot be read, e.g.
+// for lack of permissions, are skipped rather than reported.
+func (m *Manifest) Check() []Finding {
+	var findings []Finding
+	for _, entry := range m.Files {
+		digest, err := fileDigest(entry.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			findings = append(findings, Finding{Path: entry.Path, Step: entry.Step, Problem: Missing})
+		case err != nil:
+			continue
+		case digest != entry.SHA256:
+			findings = append(findings, Finding{Path: entry.Path, Step: entry.Step, Problem: Modified})
+		}
+	}
+	return findings
+}
+
+// Load reads the manifest at path; it returns nil without an error when none has been recorded
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drift manifest %s: %w", path, err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse drift manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Save writes the manifest to path
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode drift manifest: %w", err)
+	}
+	return writeStateFile(path, append(data, '\n'))
+}
+
+// WriteReport writes one line per finding to path, or an empty file when there are none
+func WriteReport(path string, findings []Finding) error {
+	var report strings.Builder
+	for _, finding := range findings {
+		report.WriteString(finding.String())
+		report.WriteByte('\n')
+	}
+	return writeStateFile(path, []byte(report.String()))
+}
+
+// Steps returns the names of the steps owning the drifted files, without duplicates
+func Steps(findings []Finding) []string {
+	var steps []string
+	seen := map[string]bool{}
+	for _, finding := range findings {
+		if !seen[finding.Step] {
+			seen[finding.Step] = true
+			steps = append(steps, finding.Step)
+		}
+	}
+	return steps
+}
+
+// writeStateFile writes a world-readable state file, creating its directory when needed
+func writeStateFile(path string, data []byte) error {
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of the file at path
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,113 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestManifestCheck(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.toml")
+	binary := filepath.Join(dir, "containerd")
+	unchanged := filepath.Join(dir, "runc")
+	writeFile(t, config, "version = 2")
+	writeFile(t, binary, "binary")
+	writeFile(t, unchanged, "runc")
+
+	manifest, err := NewManifest(map[string][]string{
+		"ContainerdInstaller": {config, binary, filepath.Join(dir, "containerd-shim")},
+		"RuncInstaller":       {unchanged},
+	})
+	if err != nil {
+		t.Fatalf("NewManifest() unexpected error: %v", err)
+	}
+	if len(manifest.Files) != 3 {
+		t.Fatalf("NewManifest() recorded %d files, want 3 (missing files are left out)", len(manifest.Files))
+	}
+	if findings := manifest.Check(); len(findings) != 0 {
+		t.Fatalf("Check() = %v right after recording, want no findings", findings)
+	}
+
+	writeFile(t, config, "version = 2\n# hand edited")
+	if err := os.Remove(binary); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Finding{
+		{Path: config, Step: "ContainerdInstaller", Problem: Modified},
+		{Path: binary, Step: "ContainerdInstaller", Problem: Missing},
+	}
+	if got := manifest.Check(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
+
+func TestManifestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "drift-manifest.json")
+
+	if manifest, err := Load(path); err != nil || manifest != nil {
+		t.Fatalf("Load() before recording = %v, %v, want nil, nil", manifest, err)
+	}
+
+	file := filepath.Join(dir, "kubelet")
+	writeFile(t, file, "kubelet")
+	manifest, err := NewManifest(map[string][]string{"KubeBinariesInstaller": {file}})
+	if err != nil {
+		t.Fatalf("NewManifest() unexpected error: %v", err)
+	}
+	if err := manifest.Save(path); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Files, manifest.Files) {
+		t.Errorf("Load() files = %v, want %v", loaded.Files, manifest.Files)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drift-report")
+	findings := []Finding{
+		{Path: "/etc/containerd/config.toml", Step: "ContainerdInstaller", Problem: Modified},
+		{Path: "/usr/local/bin/kubelet", Step: "KubeBinariesInstaller", Problem: Missing},
+	}
+	if err := WriteReport(path, findings); err != nil {
+		t.Fatalf("WriteReport() unexpected error: %v", err)
+	}
+	want := "modified /etc/containerd/config.toml (ContainerdInstaller)\nmissing /usr/local/bin/kubelet (KubeBinariesInstaller)\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("report = %q, want %q", data, want)
+	}
+
+	if err := WriteReport(path, nil); err != nil {
+		t.Fatalf("WriteReport() unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("report = %q without findings, want an empty file", data)
+	}
+}
+
+func TestSteps(t *testing.T) {
+	findings := []Finding{
+		{Path: "/usr/bin/containerd", Step: "ContainerdInstaller"},
+		{Path: "/usr/local/bin/kubelet", Step: "KubeBinariesInstaller"},
+		{Path: "/etc/containerd/config.toml", Step: "ContainerdInstaller"},
+	}
+	want := []string{"ContainerdInstaller", "KubeBinariesInstaller"}
+	if got := Steps(findings); !reflect.DeepEqual(got, want) {
+		t.Errorf("Steps() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+// Package errdefs defines the error types returned across the agent, so the CLI and library consumers
+// can branch on the kind of failure with errors.As instead of matching message text.
+//
+// All types wrap the underlying error; errors.Is and errors.As see through them.
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ConfigError reports a configuration file that could not be read or failed validation
+type ConfigError struct {
+	Path string // Configuration file path, empty when not loaded from a file
+	Err  error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// PreflightError reports the preflight checks that failed before bootstrap changed anything
+type PreflightError struct {
+	Checks []string // Names of the failed checks
+	Err    error
+}
+
+func (e *PreflightError) Error() string { return e.Err.Error() }
+func (e *PreflightError) Unwrap() error { return e.Err }
+
+// AzureAPIError is a failed Azure Resource Manager or Microsoft Entra ID request
+type AzureAPIError struct {
+	Code       string        // Error code returned by Azure, e.g. AuthorizationFailed
+	Status     int           // HTTP status code, 0 when unknown
+	Category   string        // Failure category, see package azerrors
+	Retryable  bool          // Whether retrying the same request can succeed
+	Hint       string        // How to fix the problem, may be empty
+	RetryAfter time.Duration // Wait Azure asked for before retrying, 0 when it did not ask
+	Err        error
+}
+
+func (e *AzureAPIError) Error() string {
+	if e.Hint == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v (hint: %s)", e.Err, e.Hint)
+}
+
+func (e *AzureAPIError) Unwrap() error { return e.Err }
+
+// Phases of a component step reported by ComponentError
+const (
+	PhaseValidate = "validate"
+	PhaseExecute  = "execute"
+)
+
+// ComponentError reports a bootstrap or unbootstrap step that failed
+type ComponentError struct {
+	Name  string // Step name, e.g. ArcInstall
+	Phase string // PhaseValidate or PhaseExecute
+	Err   error
+}
+
+func (e *ComponentError) Error() string {
+	if e.Phase == PhaseValidate {
+		return "validation failed: " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *ComponentError) Unwrap() error { return e.Err }
+
+// As is a generic form of errors.As returning the first error of type T in err's chain
+func As[T error](err error) (T, bool) {
+	var target T
+	ok := errors.As(err, &target)
+	return target, ok
+}
@@ -0,0 +1,315 @@
+// Package filebackup keeps a copy of each host file as it was before bootstrap first changed it, such as
+// /etc/resolv.conf, a sysctl.d file or a containerd config.toml installed by another tool. Uninstall uses the
+// copies to put the host back the way it was: originals are restored and files bootstrap created are removed.
+package filebackup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// Dir holds the copies of the original files
+	Dir = "/var/lib/aks-flex-node/backups"
+	// IndexPath records every file bootstrap changed, with its copy and its state before and after
+	IndexPath = "/var/lib/aks-flex-node/backups/index.json"
+)
+
+// Replaced in tests
+var (
+	backupDir      = Dir
+	indexPath      = IndexPath
+	now            = time.Now
+	installedFiles = driftManifestFiles
+)
+
+// Entry records a host file changed by bootstrap
+type Entry struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`          // Whether the file existed before bootstrap changed it
+	Backup  string `json:"backup,omitempty"` // Copy of the original, when it existed
+
+	// State of the file before and after bootstrap last wrote it: the SHA-256 of a regular file's content,
+	// or "-> target" for a symlink
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+
+	BackedUpAt time.Time `json:"backedUpAt"`
+}
+
+// ErrModified is returned when a file was changed by someone else after bootstrap last wrote it
+var ErrModified = errors.New("changed since bootstrap wrote it")
+
+// Save backs up the file at path before it is changed. Only the first change is backed up; later ones would
+// copy bootstrap's own version. A path that does not exist is recorded so revert removes it again.
+func Save(path string, logger *logrus.Logger) error {
+	entries, err := load()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[path]; ok {
+		return nil
+	}
+
+	entry := Entry{Path: path, BackedUpAt: now().UTC()}
+	before, err := fileState(path)
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	switch {
+	case before != "" && installedFiles()[path]:
+		// Installed by an agent that did not keep backups yet, so there is no original to keep
+		logger.Debugf("Not backing up %s, it was installed by bootstrap", path)
+	case before != "":
+		entry.Existed, entry.Before = true, before
+		entry.Backup = filepath.Join(backupDir, entry.BackedUpAt.Format("20060102T150405Z")+"-"+
+			strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "_"))
+		if err := utils.RunSystemCommand("mkdir", "-p", backupDir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", backupDir, err)
+		}
+		// -a keeps the owner, mode and timestamps, and copies a symlink as a symlink
+		if err := utils.RunSystemCommand("cp", "-a", path, entry.Backup); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+		logger.Infof("Backed up %s to %s before changing it", path, entry.Backup)
+	}
+
+	entries[path] = entry
+	return save(entries)
+}
+
+// Record records the state of the file at path after bootstrap wrote it, so revert can tell whether it was
+// changed since. Paths without a backup are ignored.
+func Record(path string) error {
+	entries, err := load()
+	if err != nil {
+		return err
+	}
+	entry, ok := entries[path]
+	if !ok {
+		return nil
+	}
+	after, err := fileState(path)
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", path, err)
+	}
+	if after == entry.After {
+		return nil
+	}
+	entry.After = after
+	entries[path] = entry
+	return save(entries)
+}
+
+// Revert restores the original of the file at path, or removes the file when bootstrap created it.
+// A file without a backup is removed, like files installed before backups were kept. A file changed by
+// someone else since bootstrap wrote it is left alone with ErrModified, and its backup is kept.
+func Revert(path string, logger *logrus.Logger) error {
+	entries, err := load()
+	if err != nil {
+		return err
+	}
+	entry, ok := entries[path]
+	if !ok {
+		return utils.RunCleanupCommand(path)
+	}
+	if err := restore(entry, logger); err != nil {
+		return err
+	}
+	delete(entries, path)
+	return save(entries)
+}
+
+// RevertAll reverts every file with a backup and returns the paths that could not be reverted
+func RevertAll(logger *logrus.Logger) []string {
+	entries, err := load()
+	if err != nil {
+		logger.Warnf("Failed to read file backups: %v", err)
+		return nil
+	}
+
+	var failed []string
+	for _, path := range sortedPaths(entries) {
+		if err := restore(entries[path], logger); err != nil {
+			logger.Warnf("Failed to revert %s: %v", path, err)
+			failed = append(failed, path)
+			continue
+		}
+		delete(entries, path)
+	}
+	if err := save(entries); err != nil {
+		logger.Warnf("Failed to update file backups: %v", err)
+	}
+	return failed
+}
+
+// Find returns the recorded entry of path, or nil when bootstrap has not changed it
+func Find(path string) (*Entry, error) {
+	entries, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := entries[path]; ok {
+		return &entry, nil
+	}
+	return nil, nil
+}
+
+// Entries returns the recorded files, sorted by path
+func Entries() ([]Entry, error) {
+	entries, err := load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Entry, 0, len(entries))
+	for _, path := range sortedPaths(entries) {
+		list = append(list, entries[path])
+	}
+	return list, nil
+}
+
+// restore puts the original of entry back in place
+func restore(entry Entry, logger *logrus.Logger) error {
+	current, err := fileState(entry.Path)
+	if err != nil {
+		return err
+	}
+	// Files removed by uninstall are restored; files edited after bootstrap keep the edit
+	if current != "" && entry.After != "" && current != entry.After && current != entry.Before {
+		return fmt.Errorf("%s %w, keeping it; the original is in %s", entry.Path, ErrModified, entry.Backup)
+	}
+
+	if !entry.Existed {
+		if current != "" {
+			logger.Infof("Removing %s, which did not exist before bootstrap", entry.Path)
+		}
+		return utils.RunCleanupCommand(entry.Path)
+	}
+	if current == entry.Before {
+		return utils.RunCleanupCommand(entry.Backup)
+	}
+
+	// Uninstall may have removed the whole directory, e.g. /etc/containerd
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(entry.Path)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(entry.Path), err)
+	}
+	// Copy next to the file first so the original replaces it in a single rename
+	staged := entry.Path + ".aks-flex-node-restore"
+	if err := utils.RunSystemCommand("cp", "-a", entry.Backup, staged); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", entry.Backup, err)
+	}
+	if err := utils.RunSystemCommand("mv", "-f", "-T", staged, entry.Path); err != nil {
+		_ = utils.RunCleanupCommand(staged)
+		return fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+	}
+	logger.Infof("Restored the original %s from %s", entry.Path, entry.Backup)
+	return utils.RunCleanupCommand(entry.Backup)
+}
+
+// driftManifestFiles returns the files recorded by the last bootstrap for drift detection
+func driftManifestFiles() map[string]bool {
+	files := map[string]bool{}
+	manifest, err := drift.Load(drift.ManifestPath)
+	if err != nil || manifest == nil {
+		return files
+	}
+	for _, entry := range manifest.Files {
+		files[entry.Path] = true
+	}
+	return files
+}
+
+// fileState returns "-> target" for a symlink, the SHA-256 of a regular file, or "" when path does not exist
+func fileState(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		return "-> " + target, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func load() (map[string]Entry, error) {
+	entries := map[string]Entry{}
+	data, err := os.ReadFile(indexPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", indexPath, err)
+	}
+	var list []Entry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", indexPath, err)
+	}
+	for _, entry := range list {
+		entries[entry.Path] = entry
+	}
+	return entries, nil
+}
+
+func save(entries map[string]Entry) error {
+	if len(entries) == 0 {
+		return utils.RunCleanupCommand(indexPath)
+	}
+	list := make([]Entry, 0, len(entries))
+	for _, path := range sortedPaths(entries) {
+		list = append(list, entries[path])
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode file backups: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(indexPath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(indexPath), err)
+	}
+	if err := utils.WriteFileAtomicSystem(indexPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", indexPath, err)
+	}
+	return nil
+}
+
+func sortedPaths(entries map[string]Entry) []string {
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
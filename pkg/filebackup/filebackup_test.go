@@ -0,0 +1,183 @@
+package filebackup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func setup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	oldDir, oldIndex, oldNow, oldInstalled := backupDir, indexPath, now, installedFiles
+	backupDir = filepath.Join(dir, "backups")
+	indexPath = filepath.Join(dir, "backups", "index.json")
+	now = func() time.Time { return time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC) }
+	installedFiles = func() map[string]bool { return map[string]bool{} }
+	t.Cleanup(func() { backupDir, indexPath, now, installedFiles = oldDir, oldIndex, oldNow, oldInstalled })
+	return dir
+}
+
+// write changes path the way bootstrap does
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := Save(path, logrus.New()); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(path); err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRevertRestoresOriginal(t *testing.T) {
+	dir := setup(t)
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("original\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, path, "first\n")
+	write(t, path, "second\n")
+
+	entry, err := Find(path)
+	if err != nil || entry == nil || !entry.Existed || entry.Backup == "" {
+		t.Fatalf("Find() = %+v, %v, want a backup of the original", entry, err)
+	}
+	if got := readFile(t, entry.Backup); got != "original\n" {
+		t.Errorf("backup = %q, want the content before the first change", got)
+	}
+
+	if err := Revert(path, logrus.New()); err != nil {
+		t.Fatalf("Revert() unexpected error: %v", err)
+	}
+	if got := readFile(t, path); got != "original\n" {
+		t.Errorf("reverted file = %q, want the original", got)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("reverted file mode = %v, want the original 0600", info.Mode().Perm())
+	}
+	if entries, _ := Entries(); len(entries) != 0 {
+		t.Errorf("Entries() = %+v after revert, want none", entries)
+	}
+	if _, err := os.Stat(entry.Backup); !os.IsNotExist(err) {
+		t.Errorf("backup %s still exists after revert", entry.Backup)
+	}
+}
+
+func TestRevertRemovesCreatedFile(t *testing.T) {
+	dir := setup(t)
+	path := filepath.Join(dir, "kubelet.service")
+	write(t, path, "[Service]\n")
+
+	if err := Revert(path, logrus.New()); err != nil {
+		t.Fatalf("Revert() unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Revert() left %s, which did not exist before", path)
+	}
+}
+
+func TestRevertRestoresRemovedDirectory(t *testing.T) {
+	dir := setup(t)
+	path := filepath.Join(dir, "containerd", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("original\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write(t, path, "version = 2\n")
+
+	// Uninstall removes the runtime's configuration directory
+	if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if failed := RevertAll(logrus.New()); len(failed) != 0 {
+		t.Fatalf("RevertAll() failed for %v", failed)
+	}
+	if got := readFile(t, path); got != "original\n" {
+		t.Errorf("reverted file = %q, want the original", got)
+	}
+}
+
+func TestRevertSymlink(t *testing.T) {
+	dir := setup(t)
+	path := filepath.Join(dir, "resolv.conf")
+	if err := os.Symlink("../run/systemd/resolve/stub-resolv.conf", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(path, logrus.New()); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/run/systemd/resolve/resolv.conf", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(path); err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+
+	if err := Revert(path, logrus.New()); err != nil {
+		t.Fatalf("Revert() unexpected error: %v", err)
+	}
+	if target, err := os.Readlink(path); err != nil || target != "../run/systemd/resolve/stub-resolv.conf" {
+		t.Errorf("reverted symlink = %q, %v, want the original target", target, err)
+	}
+}
+
+func TestRevertKeepsModifiedFile(t *testing.T) {
+	dir := setup(t)
+	path := filepath.Join(dir, "99-sysctl.conf")
+	if err := os.WriteFile(path, []byte("original\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write(t, path, "bootstrap\n")
+	if err := os.WriteFile(path, []byte("edited by hand\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Revert(path, logrus.New()); !errors.Is(err, ErrModified) {
+		t.Fatalf("Revert() = %v, want ErrModified", err)
+	}
+	if got := readFile(t, path); got != "edited by hand\n" {
+		t.Errorf("Revert() replaced a file edited after bootstrap: %q", got)
+	}
+	if entry, _ := Find(path); entry == nil {
+		t.Error("Revert() dropped the backup of a file it did not revert")
+	}
+}
+
+func TestSaveSkipsFilesInstalledByBootstrap(t *testing.T) {
+	dir := setup(t)
+	path := filepath.Join(dir, "kubelet.service")
+	if err := os.WriteFile(path, []byte("[Service]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	installedFiles = func() map[string]bool { return map[string]bool{path: true} }
+
+	write(t, path, "[Service]\nRestart=always\n")
+	entry, err := Find(path)
+	if err != nil || entry == nil || entry.Existed || entry.Backup != "" {
+		t.Errorf("Find() = %+v, %v, want the file recorded as created by bootstrap", entry, err)
+	}
+}
@@ -0,0 +1,310 @@
+// Package fleet queries the status of many flex nodes at once, through the agents' webhook listeners or over
+// SSH, and aggregates it into a health matrix with the nodes that are unhealthy or run other versions than the
+// rest of the fleet.
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+	"go.goms.io/aks/AKSFlexNode/pkg/webhook"
+)
+
+const (
+	// remoteStatusPath is where the agent daemon, running as the aks-flex-node user, writes the node status
+	remoteStatusPath = "/run/aks-flex-node/status.json"
+
+	// staleAfter is how old a status may be; the daemon writes it every minute
+	staleAfter = 5 * time.Minute
+
+	// DefaultParallelism bounds the nodes queried at the same time
+	DefaultParallelism = 16
+	// DefaultTimeout bounds the query of one node
+	DefaultTimeout = 20 * time.Second
+)
+
+// Inventory lists the enrolled nodes and how to reach them
+type Inventory struct {
+	Defaults NodeAccess `yaml:"defaults"`
+	Nodes    []Node     `yaml:"nodes"`
+}
+
+// NodeAccess is how a node is reached. Settings left empty on a node are taken from the inventory defaults.
+type NodeAccess struct {
+	SecretFile   string `yaml:"secretFile,omitempty"`   // Shared secret of the webhook listener
+	CABundleFile string `yaml:"caBundleFile,omitempty"` // PEM certificates to trust for the listener
+	SSHUser      string `yaml:"sshUser,omitempty"`      // User to log in as over SSH
+}
+
+// Node is an enrolled node, reached through its webhook listener or, when it has none, over SSH
+type Node struct {
+	NodeAccess `yaml:",inline"`
+	Name       string `yaml:"name"`
+	Endpoint   string `yaml:"endpoint,omitempty"` // URL of the webhook listener, e.g. https://edge-01:8443
+	SSH        string `yaml:"ssh,omitempty"`      // SSH host, e.g. edge-01.corp or 10.0.0.4
+}
+
+// LoadInventory reads an inventory file in YAML or JSON
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	inventory := &Inventory{}
+	if err := yaml.Unmarshal(data, inventory); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+	}
+	if err := inventory.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid inventory %s: %w", path, err)
+	}
+	return inventory, nil
+}
+
+// Validate checks that every node has a unique name and exactly one way to reach it
+func (inv *Inventory) Validate() error {
+	if len(inv.Nodes) == 0 {
+		return fmt.Errorf("no nodes listed")
+	}
+	names := map[string]bool{}
+	for i, node := range inv.Nodes {
+		if node.Name == "" {
+			return fmt.Errorf("nodes[%d]: name is required", i)
+		}
+		if names[node.Name] {
+			return fmt.Errorf("node %s is listed twice", node.Name)
+		}
+		names[node.Name] = true
+		if (node.Endpoint == "") == (node.SSH == "") {
+			return fmt.Errorf("node %s: set exactly one of endpoint and ssh", node.Name)
+		}
+		if node.Endpoint != "" && inv.access(node).SecretFile == "" {
+			return fmt.Errorf("node %s: secretFile is required to query the webhook listener", node.Name)
+		}
+	}
+	return nil
+}
+
+// access returns the node's access settings with the defaults filled in
+func (inv *Inventory) access(node Node) NodeAccess {
+	access := node.NodeAccess
+	if access.SecretFile == "" {
+		access.SecretFile = inv.Defaults.SecretFile
+	}
+	if access.CABundleFile == "" {
+		access.CABundleFile = inv.Defaults.CABundleFile
+	}
+	if access.SSHUser == "" {
+		access.SSHUser = inv.Defaults.SSHUser
+	}
+	return access
+}
+
+// Result is the status of one node, or why it could not be read
+type Result struct {
+	Name   string             `json:"name"`
+	Via    string             `json:"via"` // "webhook" or "ssh"
+	Error  string             `json:"error,omitempty"`
+	Status *status.NodeStatus `json:"status,omitempty"`
+	Issues []string           `json:"issues,omitempty"` // Why the node is unhealthy
+	Drift  []string           `json:"drift,omitempty"`  // Versions that differ from most of the fleet
+}
+
+// Healthy reports whether the node answered and has no issues
+func (r *Result) Healthy() bool {
+	return r.Error == "" && len(r.Issues) == 0
+}
+
+// Querier reads node statuses; the zero value is not usable, use NewQuerier
+type Querier struct {
+	inventory   *Inventory
+	parallelism int
+	timeout     time.Duration
+	now         func() time.Time
+
+	// Replaced in tests
+	viaWebhook func(ctx context.Context, node Node, access NodeAccess) ([]byte, error)
+	viaSSH     func(ctx context.Context, node Node, access NodeAccess) ([]byte, error)
+}
+
+// NewQuerier creates a querier for the nodes of inventory. parallelism and timeout fall back to the defaults when 0.
+func NewQuerier(inventory *Inventory, parallelism int, timeout time.Duration) *Querier {
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Querier{
+		inventory:   inventory,
+		parallelism: parallelism,
+		timeout:     timeout,
+		now:         time.Now,
+		viaWebhook:  queryWebhook,
+		viaSSH:      querySSH,
+	}
+}
+
+// Query reads the status of every node concurrently and returns the results in inventory order, with the
+// issues and version drift of each node filled in
+func (q *Querier) Query(ctx context.Context) []Result {
+	results := make([]Result, len(q.inventory.Nodes))
+	slots := make(chan struct{}, q.parallelism)
+	var wg sync.WaitGroup
+	for i, node := range q.inventory.Nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = q.queryNode(ctx, node)
+		}()
+	}
+	wg.Wait()
+	markDrift(results)
+	return results
+}
+
+func (q *Querier) queryNode(ctx context.Context, node Node) Result {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	access := q.inventory.access(node)
+	result := Result{Name: node.Name, Via: "webhook"}
+	query := q.viaWebhook
+	if node.SSH != "" {
+		result.Via, query = "ssh", q.viaSSH
+	}
+	data, err := query(ctx, node, access)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	nodeStatus := &status.NodeStatus{}
+	if err := json.Unmarshal(data, nodeStatus); err != nil {
+		result.Error = fmt.Sprintf("invalid status: %v", err)
+		return result
+	}
+	result.Status = nodeStatus
+	result.Issues = issues(nodeStatus, q.now())
+	return result
+}
+
+func queryWebhook(ctx context.Context, node Node, access NodeAccess) ([]byte, error) {
+	secret, err := os.ReadFile(access.SecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook secret: %w", err)
+	}
+	var caBundle []byte
+	if access.CABundleFile != "" {
+		if caBundle, err = os.ReadFile(access.CABundleFile); err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+	}
+	client, err := webhook.NewClient(node.Endpoint, []byte(strings.TrimSpace(string(secret))), caBundle)
+	if err != nil {
+		return nil, err
+	}
+	return client.Status(ctx)
+}
+
+func querySSH(ctx context.Context, node Node, access NodeAccess) ([]byte, error) {
+	target := node.SSH
+	if access.SSHUser != "" && !strings.Contains(target, "@") {
+		target = access.SSHUser + "@" + target
+	}
+	// BatchMode fails instead of prompting, so one node cannot stall the others
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=10", target, "cat", remoteStatusPath)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("ssh %s: %s", target, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("ssh %s: %w", target, err)
+	}
+	return output, nil
+}
+
+// issues returns why a node with status s is unhealthy at now
+func issues(s *status.NodeStatus, now time.Time) []string {
+	var found []string
+	if !s.KubeletRunning {
+		found = append(found, "kubelet not running")
+	} else if s.KubeletReady != "Ready" {
+		found = append(found, "node "+strings.ToLower(orUnknown(s.KubeletReady)))
+	}
+	if !s.ContainerdRunning {
+		found = append(found, "container runtime not running")
+	}
+	if s.ArcStatus.Registered && !s.ArcStatus.Connected {
+		found = append(found, "Arc agent disconnected")
+	}
+	if s.LastBootstrap != nil && !s.LastBootstrap.Success {
+		found = append(found, "last bootstrap failed")
+	}
+	if s.OSPatching != nil && s.OSPatching.RebootRequired {
+		found = append(found, "reboot required for OS updates")
+	}
+	if !s.LastUpdated.IsZero() && now.Sub(s.LastUpdated) > staleAfter {
+		found = append(found, fmt.Sprintf("status %s old", now.Sub(s.LastUpdated).Round(time.Minute)))
+	}
+	return found
+}
+
+// markDrift records on each result the kubelet and agent versions that differ from the most common ones
+func markDrift(results []Result) {
+	kubelet := mostCommon(results, func(s *status.NodeStatus) string { return s.KubeletVersion })
+	agent := mostCommon(results, func(s *status.NodeStatus) string { return s.AgentVersion })
+	for i := range results {
+		s := results[i].Status
+		if s == nil {
+			continue
+		}
+		if s.KubeletVersion != kubelet {
+			results[i].Drift = append(results[i].Drift, fmt.Sprintf("kubelet %s (fleet %s)", orUnknown(s.KubeletVersion), kubelet))
+		}
+		if s.AgentVersion != agent {
+			results[i].Drift = append(results[i].Drift, fmt.Sprintf("agent %s (fleet %s)", orUnknown(s.AgentVersion), agent))
+		}
+	}
+}
+
+// mostCommon returns the most common value of the answered statuses, the lowest on a tie so the result is stable
+func mostCommon(results []Result, value func(*status.NodeStatus) string) string {
+	counts := map[string]int{}
+	for _, result := range results {
+		if result.Status != nil {
+			counts[value(result.Status)]++
+		}
+	}
+	values := make([]string, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+func TestLoadInventory(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "webhook and ssh nodes",
+			content: `defaults:
+  secretFile: /etc/flex/secret
+  sshUser: azureuser
+nodes:
+- name: edge-01
+  endpoint: https://edge-01:8443
+- name: edge-02
+  ssh: 10.0.0.4
+`,
+		},
+		{name: "no nodes", content: "nodes: []\n", wantErr: "no nodes listed"},
+		{name: "duplicate", content: "nodes:\n- {name: a, ssh: a}\n- {name: a, ssh: b}\n", wantErr: "listed twice"},
+		{name: "both endpoint and ssh", content: "nodes:\n- {name: a, ssh: a, endpoint: https://a:8443, secretFile: /s}\n", wantErr: "exactly one of endpoint and ssh"},
+		{name: "webhook without secret", content: "nodes:\n- {name: a, endpoint: https://a:8443}\n", wantErr: "secretFile is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "inventory.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			inventory, err := LoadInventory(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadInventory() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadInventory() unexpected error: %v", err)
+			}
+			if access := inventory.access(inventory.Nodes[1]); access.SSHUser != "azureuser" || access.SecretFile != "/etc/flex/secret" {
+				t.Errorf("access() = %+v, want the defaults", access)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	healthy := status.NodeStatus{
+		KubeletVersion: "v1.31.1", AgentVersion: "v1.2.0", KubeletRunning: true, KubeletReady: "Ready",
+		ContainerdRunning: true, LastUpdated: now.Add(-time.Minute),
+		ArcStatus: status.ArcStatus{Registered: true, Connected: true},
+	}
+	notReady := healthy
+	notReady.KubeletReady = "NotReady"
+	notReady.LastBootstrap = &bootstrapper.Report{Success: false}
+	old := healthy
+	old.KubeletVersion = "v1.30.4"
+
+	statuses := map[string]status.NodeStatus{"edge-01": healthy, "edge-02": notReady, "edge-03": healthy, "edge-04": old}
+	inventory := &Inventory{Nodes: []Node{
+		{Name: "edge-01", Endpoint: "https://edge-01:8443"},
+		{Name: "edge-02", SSH: "edge-02"},
+		{Name: "edge-03", SSH: "edge-03"},
+		{Name: "edge-04", SSH: "edge-04"},
+		{Name: "edge-05", SSH: "edge-05"},
+	}}
+	fake := func(_ context.Context, node Node, _ NodeAccess) ([]byte, error) {
+		s, ok := statuses[node.Name]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		return json.Marshal(s)
+	}
+	q := NewQuerier(inventory, 2, time.Second)
+	q.now = func() time.Time { return now }
+	q.viaWebhook, q.viaSSH = fake, fake
+
+	results := q.Query(context.Background())
+	if len(results) != 5 || results[0].Name != "edge-01" || results[0].Via != "webhook" || results[1].Via != "ssh" {
+		t.Fatalf("Query() = %+v, want the nodes in inventory order", results)
+	}
+	if !results[0].Healthy() || len(results[0].Drift) != 0 {
+		t.Errorf("edge-01 = %+v, want healthy without drift", results[0])
+	}
+	if got := strings.Join(results[1].Issues, ", "); got != "node notready, last bootstrap failed" {
+		t.Errorf("edge-02 issues = %q, want not ready and failed bootstrap", got)
+	}
+	if len(results[3].Drift) != 1 || !strings.Contains(results[3].Drift[0], "kubelet v1.30.4 (fleet v1.31.1)") {
+		t.Errorf("edge-04 drift = %v, want the kubelet version", results[3].Drift)
+	}
+	if results[4].Error != "connection refused" || results[4].Healthy() {
+		t.Errorf("edge-05 = %+v, want unreachable", results[4])
+	}
+
+	unhealthy := Filter{UnhealthyOnly: true}.Apply(results)
+	if len(unhealthy) != 2 || unhealthy[0].Name != "edge-02" || unhealthy[1].Name != "edge-05" {
+		t.Errorf("unhealthy filter = %+v, want edge-02 and edge-05", unhealthy)
+	}
+	if drifted := (Filter{DriftOnly: true}).Apply(results); len(drifted) != 1 || drifted[0].Name != "edge-04" {
+		t.Errorf("drift filter = %+v, want edge-04", drifted)
+	}
+
+	var table, csv bytes.Buffer
+	if err := Write(&table, results, FormatTable); err != nil {
+		t.Fatalf("Write(table) unexpected error: %v", err)
+	}
+	if !strings.Contains(table.String(), "5 nodes: 3 healthy, 1 unhealthy, 1 unreachable, 1 with version drift") {
+		t.Errorf("table = %q, want the summary", table.String())
+	}
+	if err := Write(&csv, results, FormatCSV); err != nil {
+		t.Fatalf("Write(csv) unexpected error: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(csv.String()), "\n"); len(lines) != 6 || !strings.HasPrefix(lines[5], "edge-05,ssh,unreachable") {
+		t.Errorf("csv = %q, want a header and a row per node", csv.String())
+	}
+	if err := Write(&csv, results, "yaml"); err == nil {
+		t.Error("Write() expected error for an unknown format")
+	}
+}
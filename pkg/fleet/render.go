@@ -0,0 +1,127 @@
+package fleet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats of a health matrix
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// Filter selects the results to show
+type Filter struct {
+	UnhealthyOnly bool // Nodes that did not answer or have issues
+	DriftOnly     bool // Nodes running other versions than most of the fleet
+}
+
+// Apply returns the results the filter selects; with both options set, a node is shown if either applies
+func (f Filter) Apply(results []Result) []Result {
+	if !f.UnhealthyOnly && !f.DriftOnly {
+		return results
+	}
+	var selected []Result
+	for _, result := range results {
+		if f.UnhealthyOnly && !result.Healthy() || f.DriftOnly && len(result.Drift) > 0 {
+			selected = append(selected, result)
+		}
+	}
+	return selected
+}
+
+// matrixColumns are the columns of the table and CSV formats
+var matrixColumns = []string{"NODE", "VIA", "HEALTH", "KUBELET", "CONTAINERD", "ARC", "KUBELET VERSION", "AGENT VERSION", "ISSUES"}
+
+// Write renders results in format
+func Write(w io.Writer, results []Result, format string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if results == nil {
+			results = []Result{}
+		}
+		return encoder.Encode(results)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(matrixColumns); err != nil {
+			return err
+		}
+		for _, result := range results {
+			if err := writer.Write(row(result)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(matrixColumns, "\t"))
+		for _, result := range results {
+			fmt.Fprintln(tw, strings.Join(row(result), "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w, Summary(results))
+		return err
+	default:
+		return fmt.Errorf("unknown format %q; use %s, %s or %s", format, FormatTable, FormatJSON, FormatCSV)
+	}
+}
+
+// row returns the matrix columns of a result
+func row(r Result) []string {
+	if r.Status == nil {
+		return []string{r.Name, r.Via, "unreachable", "-", "-", "-", "-", "-", r.Error}
+	}
+	s := r.Status
+	health := "healthy"
+	if !r.Healthy() {
+		health = "unhealthy"
+	}
+	kubelet := "stopped"
+	if s.KubeletRunning {
+		kubelet = orUnknown(s.KubeletReady)
+	}
+	containerd := "stopped"
+	if s.ContainerdRunning {
+		containerd = "running"
+	}
+	arc := "-"
+	switch {
+	case s.ArcStatus.Connected:
+		arc = "connected"
+	case s.ArcStatus.Registered:
+		arc = "disconnected"
+	}
+	notes := append(append([]string{}, r.Issues...), r.Drift...)
+	return []string{r.Name, r.Via, health, kubelet, containerd, arc, orUnknown(s.KubeletVersion), orUnknown(s.AgentVersion), strings.Join(notes, "; ")}
+}
+
+// Summary counts the healthy, unhealthy, unreachable and drifted nodes of results
+func Summary(results []Result) string {
+	var healthy, unhealthy, unreachable, drifted int
+	for _, result := range results {
+		switch {
+		case result.Status == nil:
+			unreachable++
+		case result.Healthy():
+			healthy++
+		default:
+			unhealthy++
+		}
+		if len(result.Drift) > 0 {
+			drifted++
+		}
+	}
+	return fmt.Sprintf("%d nodes: %d healthy, %d unhealthy, %d unreachable, %d with version drift",
+		len(results), healthy, unhealthy, unreachable, drifted)
+}
@@ -0,0 +1,135 @@
+package flexnode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+)
+
+// EventType is what happened to a component
+type EventType string
+
+const (
+	EventStarted  EventType = "started"  // The component's step is about to be checked and run
+	EventProgress EventType = "progress" // The component logged what it is doing
+	EventFinished EventType = "finished" // The component's step succeeded, or was already completed
+	EventFailed   EventType = "failed"   // The component's step failed
+)
+
+// Event reports the progress of one component while Install or Uninstall runs
+type Event struct {
+	Type      EventType `json:"type"`
+	Operation string    `json:"operation"` // install or uninstall
+	Component string    `json:"component"` // Name of the step, e.g. ContainerdInstaller
+	Time      time.Time `json:"time"`
+
+	// Set on progress events
+	Message string `json:"message,omitempty"`
+	Level   string `json:"level,omitempty"` // Log level of the message, e.g. info or warning
+
+	// Set on finished and failed events
+	Skipped         bool          `json:"skipped,omitempty"` // The step was already completed and did not run
+	Duration        time.Duration `json:"duration,omitempty"`
+	Retries         int64         `json:"retries,omitempty"`
+	DownloadedBytes int64         `json:"downloadedBytes,omitempty"`
+	Error           string        `json:"error,omitempty"`
+	Err             error         `json:"-"` // *errdefs.ComponentError wrapping the failure
+}
+
+// WithEvents calls handler with an event as each component starts, logs progress, and finishes or fails. The
+// handler runs on the goroutine that runs the step or logs, so it should return quickly; it may be called from
+// several goroutines at once when a component logs from its own goroutines.
+func WithEvents(handler func(Event)) Option {
+	return func(n *Node) {
+		n.handlers = append(n.handlers, handler)
+	}
+}
+
+// WithEventChannel sends the events of WithEvents to events. Install and Uninstall block while the channel is
+// full, so the caller must keep receiving until they return. The channel is not closed.
+func WithEventChannel(events chan<- Event) Option {
+	return WithEvents(func(event Event) {
+		events <- event
+	})
+}
+
+// eventStream turns step notifications and the log entries in between into events for the handlers
+type eventStream struct {
+	operation string
+	handlers  []func(Event)
+	now       func() time.Time
+
+	mu        sync.Mutex
+	component string // Step that is running, empty between steps
+}
+
+func newEventStream(operation string, handlers []func(Event)) *eventStream {
+	return &eventStream{operation: operation, handlers: handlers, now: time.Now}
+}
+
+func (s *eventStream) emit(event Event) {
+	event.Operation = s.operation
+	event.Time = s.now()
+	for _, handler := range s.handlers {
+		handler(event)
+	}
+}
+
+func (s *eventStream) StepStarted(stepName string) {
+	s.mu.Lock()
+	s.component = stepName
+	s.mu.Unlock()
+	s.emit(Event{Type: EventStarted, Component: stepName})
+}
+
+func (s *eventStream) StepFinished(result bootstrapper.StepResult) {
+	s.mu.Lock()
+	s.component = ""
+	s.mu.Unlock()
+	event := Event{
+		Type:            EventFinished,
+		Component:       result.StepName,
+		Skipped:         result.Skipped,
+		Duration:        result.Duration,
+		Retries:         result.Retries,
+		DownloadedBytes: result.DownloadedBytes,
+	}
+	if !result.Success {
+		event.Type, event.Error, event.Err = EventFailed, result.Error, result.Err
+	}
+	s.emit(event)
+}
+
+// Levels reports progress from informational messages up; debug output is too noisy for a progress UI
+func (s *eventStream) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel, logrus.InfoLevel}
+}
+
+// Fire reports a log entry written while a step runs as progress of that step's component
+func (s *eventStream) Fire(entry *logrus.Entry) error {
+	s.mu.Lock()
+	component := s.component
+	s.mu.Unlock()
+	if component != "" {
+		s.emit(Event{Type: EventProgress, Component: component, Message: entry.Message, Level: entry.Level.String()})
+	}
+	return nil
+}
+
+// observers notifies several step observers in turn
+type observers []bootstrapper.StepObserver
+
+func (o observers) StepStarted(stepName string) {
+	for _, observer := range o {
+		observer.StepStarted(stepName)
+	}
+}
+
+func (o observers) StepFinished(result bootstrapper.StepResult) {
+	for _, observer := range o {
+		observer.StepFinished(result)
+	}
+}
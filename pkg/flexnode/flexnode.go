@@ -0,0 +1,178 @@
+// Package flexnode is the library entrypoint to the agent: it installs AKS node components on the machine,
+// reports their status and removes them again, the way the aks-flex-node binary does. Provisioning services
+// embed it to onboard machines without shelling out to the binary:
+//
+//	cfg, err := config.LoadConfig("/etc/aks-flex-node/config.json")
+//	...
+//	node := flexnode.New(cfg, flexnode.WithLogger(logger), flexnode.WithVersion("my-provisioner/1.2"))
+//	result, err := node.Install(ctx)
+//
+// A configuration built in code must be readied with cfg.Prepare first.
+package flexnode
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/decommission"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+	"go.goms.io/aks/AKSFlexNode/pkg/telemetry"
+)
+
+// defaultVersion identifies the agent in status and telemetry when the caller does not set a version
+const defaultVersion = "library"
+
+// Node installs, reports on and removes the AKS node components of this machine
+type Node struct {
+	config   *config.Config
+	logger   *logrus.Logger
+	version  string
+	observer bootstrapper.StepObserver
+	handlers []func(Event)
+	force    bool
+
+	// Replaced in tests
+	checkDecommission func() (*decommission.Report, error)
+}
+
+// Option customizes a Node
+type Option func(*Node)
+
+// WithLogger sets the logger the steps log to; the default is logrus's standard logger
+func WithLogger(logger *logrus.Logger) Option {
+	return func(n *Node) {
+		n.logger = logger
+	}
+}
+
+// WithVersion sets the version reported in status and telemetry, e.g. the embedding service's
+func WithVersion(version string) Option {
+	return func(n *Node) {
+		n.version = version
+	}
+}
+
+// WithStepObserver registers an observer notified as each install or uninstall step starts and finishes
+func WithStepObserver(observer bootstrapper.StepObserver) Option {
+	return func(n *Node) {
+		n.observer = observer
+	}
+}
+
+// WithForce makes Uninstall go on even if pods other than DaemonSet pods still run on the node, volumes are
+// still attached, or the API server cannot be reached to check
+func WithForce() Option {
+	return func(n *Node) {
+		n.force = true
+	}
+}
+
+// New creates a Node for cfg, which must come from config.LoadConfig or have been readied with cfg.Prepare
+func New(cfg *config.Config, opts ...Option) *Node {
+	n := &Node{
+		config:            cfg,
+		logger:            logrus.StandardLogger(),
+		version:           defaultVersion,
+		checkDecommission: decommission.NewChecker().Check,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Install bootstraps the node: it installs the container runtime and Kubernetes components, connects the
+// machine to Arc when enabled and joins it to the cluster. Completed steps are skipped, so Install can be
+// run again to converge the node. The result is returned even when a step fails, with the error; a result
+// with RebootRequired set means the node must reboot before bootstrap can finish.
+func (n *Node) Install(ctx context.Context) (*bootstrapper.ExecutionResult, error) {
+	b, detach := n.bootstrapper("install")
+	result, err := b.Bootstrap(ctx)
+	detach()
+	telemetry.NewReporter(n.config, n.logger, n.version).Report(ctx, "bootstrap", result, err)
+	if err == nil && !result.Success && !result.RebootRequired {
+		err = messages.Errorf(messages.OperationFailed, "bootstrap", result.Error)
+	}
+	return result, err
+}
+
+// Status collects the state of the node's components, the Arc connection and the kubelet
+func (n *Node) Status(ctx context.Context) (*status.NodeStatus, error) {
+	return status.NewCollector(n.config, n.logger, n.version).CollectStatus(ctx)
+}
+
+// Uninstall removes the AKS node components and the Arc connection from the machine and restores the host
+// files bootstrap changed. It refuses to run while workloads or volumes are still on the node unless the Node
+// was created WithForce. Steps that fail are reported in the result, and the remaining steps still run.
+func (n *Node) Uninstall(ctx context.Context) (*bootstrapper.ExecutionResult, error) {
+	if err := n.checkWorkloads(); err != nil {
+		return nil, err
+	}
+	b, detach := n.bootstrapper("uninstall")
+	result, err := b.Unbootstrap(ctx)
+	detach()
+	telemetry.NewReporter(n.config, n.logger, n.version).Report(ctx, "unbootstrap", result, err)
+	return result, err
+}
+
+// PlanUninstall returns what Uninstall would remove, without changing the node, Azure or the cluster
+func (n *Node) PlanUninstall(ctx context.Context) *bootstrapper.RemovalPlan {
+	return bootstrapper.New(n.config, n.logger).PlanUnbootstrap(ctx)
+}
+
+// bootstrapper creates the bootstrapper for operation with the step observer and event handlers attached.
+// The returned function detaches the event handlers from the logger once the operation is done.
+func (n *Node) bootstrapper(operation string) (*bootstrapper.Bootstrapper, func()) {
+	b := bootstrapper.New(n.config, n.logger)
+	var notify observers
+	if n.observer != nil {
+		notify = append(notify, n.observer)
+	}
+	detach := func() {}
+	if len(n.handlers) > 0 {
+		stream := newEventStream(operation, n.handlers)
+		notify = append(notify, stream)
+
+		hooks := n.logger.Hooks
+		attached := make(logrus.LevelHooks)
+		for level, levelHooks := range hooks {
+			attached[level] = append([]logrus.Hook(nil), levelHooks...)
+		}
+		attached.Add(stream)
+		n.logger.ReplaceHooks(attached)
+		detach = func() { n.logger.ReplaceHooks(hooks) }
+	}
+	if len(notify) > 0 {
+		b.SetObserver(notify)
+	}
+	return b, detach
+}
+
+// checkWorkloads reports the workloads and volumes Uninstall would disrupt, and refuses to go on while there
+// are any unless forced
+func (n *Node) checkWorkloads() error {
+	report, err := n.checkDecommission()
+	if err != nil {
+		if !n.force {
+			return messages.Errorf(messages.DecommissionCheck, err)
+		}
+		n.logger.Warnf("Skipping the workload check because of --force: %v", err)
+		return nil
+	}
+	if !report.Busy() {
+		return nil
+	}
+	n.logger.Warn(messages.Get(messages.DisruptedWorkloads, len(report.Workloads), len(report.Volumes), report.Node))
+	for _, line := range report.Lines() {
+		n.logger.Warnf("  %s", line)
+	}
+	if !n.force {
+		return messages.Errorf(messages.DecommissionBlocked, report.Node)
+	}
+	n.logger.Warn(messages.Get(messages.DecommissionForced))
+	return nil
+}
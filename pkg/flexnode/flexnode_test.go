@@ -0,0 +1,126 @@
+package flexnode
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/decommission"
+)
+
+func TestNewOptions(t *testing.T) {
+	logger := logrus.New()
+	n := New(&config.Config{}, WithLogger(logger), WithVersion("provisioner/1.2"), WithForce())
+	if n.logger != logger || n.version != "provisioner/1.2" || !n.force {
+		t.Errorf("New() = logger %p, version %q, force %v, want the options applied", n.logger, n.version, n.force)
+	}
+
+	n = New(&config.Config{})
+	if n.logger != logrus.StandardLogger() || n.version != defaultVersion || n.force {
+		t.Errorf("New() = logger %p, version %q, force %v, want the defaults", n.logger, n.version, n.force)
+	}
+}
+
+func TestCheckWorkloads(t *testing.T) {
+	busy := &decommission.Report{Node: "edge-01", Workloads: []decommission.Workload{{Namespace: "default", Name: "web-0"}}}
+	idle := &decommission.Report{Node: "edge-01"}
+
+	tests := []struct {
+		name    string
+		report  *decommission.Report
+		err     error
+		force   bool
+		wantErr string
+	}{
+		{name: "idle node", report: idle},
+		{name: "busy node", report: busy, wantErr: "refusing to unbootstrap node edge-01"},
+		{name: "busy node forced", report: busy, force: true},
+		{name: "check fails", err: errors.New("connection refused"), wantErr: "failed to check node for workloads"},
+		{name: "check fails forced", err: errors.New("connection refused"), force: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.force {
+				opts = append(opts, WithForce())
+			}
+			n := New(&config.Config{}, opts...)
+			n.checkDecommission = func() (*decommission.Report, error) { return tt.report, tt.err }
+
+			err := n.checkWorkloads()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkWorkloads() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkWorkloads() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.DebugLevel)
+	events := make(chan Event, 16)
+	n := New(&config.Config{}, WithLogger(logger), WithEventChannel(events))
+
+	_, detach := n.bootstrapper("install")
+	if len(logger.Hooks[logrus.InfoLevel]) != 1 {
+		t.Fatalf("logger has %d info hooks, want the event stream attached", len(logger.Hooks[logrus.InfoLevel]))
+	}
+	stream, ok := logger.Hooks[logrus.InfoLevel][0].(*eventStream)
+	if !ok {
+		t.Fatalf("info hook is %T, want the event stream", logger.Hooks[logrus.InfoLevel][0])
+	}
+	stream.now = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC) }
+
+	logger.Info("Resolving the cluster")
+	stream.StepStarted("ContainerdInstaller")
+	logger.Info("Downloading containerd 1.7.27")
+	logger.Debug("Extracting archive")
+	stream.StepFinished(bootstrapper.StepResult{StepName: "ContainerdInstaller", Success: true, Duration: time.Second, DownloadedBytes: 1024})
+	stream.StepStarted("KubeletInstaller")
+	cause := errors.New("kubelet did not start")
+	stream.StepFinished(bootstrapper.StepResult{StepName: "KubeletInstaller", Error: cause.Error(), Err: cause})
+	detach()
+	close(events)
+
+	if len(logger.Hooks[logrus.InfoLevel]) != 0 {
+		t.Errorf("logger has %d info hooks after the run, want the event stream detached", len(logger.Hooks[logrus.InfoLevel]))
+	}
+	var got []string
+	for event := range events {
+		if event.Operation != "install" || event.Time.IsZero() {
+			t.Errorf("event %+v, want the operation and time set", event)
+		}
+		got = append(got, string(event.Type)+" "+event.Component+" "+event.Message+event.Error)
+		if event.Type == EventFinished && event.DownloadedBytes != 1024 {
+			t.Errorf("finished event downloaded %d bytes, want 1024", event.DownloadedBytes)
+		}
+		if event.Type == EventFailed && !errors.Is(event.Err, cause) {
+			t.Errorf("failed event error = %v, want %v", event.Err, cause)
+		}
+	}
+	want := []string{
+		"started ContainerdInstaller ",
+		"progress ContainerdInstaller Downloading containerd 1.7.27",
+		"finished ContainerdInstaller ",
+		"started KubeletInstaller ",
+		"failed KubeletInstaller kubelet did not start",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// gitSource pulls the NodeSpec from a branch or tag of a Git repository with the git CLI. Only the
+// tip commit is fetched, and its signature is verified before the NodeSpec is read from it.
+type gitSource struct {
+	config        *config.GitSourceConfig
+	allowUnsigned bool
+	dir           string
+	logger        *logrus.Logger
+	run           runFunc
+}
+
+func (s *gitSource) String() string {
+	return fmt.Sprintf("%s@%s", s.config.URL, s.ref())
+}
+
+func (s *gitSource) ref() string {
+	if s.config.Ref == "" {
+		return defaultGitRef
+	}
+	return s.config.Ref
+}
+
+// Fetch fetches the tip of the configured ref, verifies the commit signature and reads the NodeSpec from it
+func (s *gitSource) Fetch(ctx context.Context) (string, []byte, error) {
+	repo := filepath.Join(s.dir, "repo")
+	if _, err := os.Stat(filepath.Join(repo, ".git")); err != nil {
+		if _, err := s.run(ctx, nil, "git", "init", "--quiet", repo); err != nil {
+			return "", nil, fmt.Errorf("failed to create checkout of %s: %w", s, err)
+		}
+	}
+	if _, err := s.run(ctx, nil, "git", "-C", repo, "fetch", "--quiet", "--depth", "1", "--no-tags", "--", s.config.URL, s.ref()); err != nil {
+		return "", nil, fmt.Errorf("failed to fetch %s: %w", s, err)
+	}
+	output, err := s.run(ctx, nil, "git", "-C", repo, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve %s: %w", s, err)
+	}
+	revision := strings.TrimSpace(string(output))
+
+	if err := s.verify(ctx, repo, revision); err != nil {
+		return "", nil, err
+	}
+
+	path := specFile(s.config.Path)
+	spec, err := s.run(ctx, nil, "git", "-C", repo, "show", revision+":"+path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s at commit %s: %w", path, revision, err)
+	}
+	return revision, spec, nil
+}
+
+// verify checks that the commit is signed by one of the trusted SSH or GPG keys
+func (s *gitSource) verify(ctx context.Context, repo, revision string) error {
+	var err error
+	switch {
+	case s.config.AllowedSignersFile != "":
+		_, err = s.run(ctx, nil, "git", "-C", repo, "-c", "gpg.ssh.allowedSignersFile="+s.config.AllowedSignersFile, "verify-commit", revision)
+	case s.config.GPGPublicKeyFile != "":
+		// A fresh keyring for each verification, so only the keys currently configured are trusted and a key
+		// removed from the file stops being trusted
+		home, err := os.MkdirTemp(s.dir, "gnupg-")
+		if err != nil {
+			return fmt.Errorf("failed to create GPG keyring directory: %w", err)
+		}
+		defer os.RemoveAll(home)
+		env := []string{"GNUPGHOME=" + home}
+		if _, err := s.run(ctx, env, "gpg", "--batch", "--quiet", "--import", s.config.GPGPublicKeyFile); err != nil {
+			return fmt.Errorf("failed to import %s: %w", s.config.GPGPublicKeyFile, err)
+		}
+		_, err = s.run(ctx, env, "git", "-C", repo, "verify-commit", revision)
+	case !s.allowUnsigned:
+		return fmt.Errorf("no trusted key configured to verify commit %s of %s", revision, s)
+	default:
+		s.logger.Warnf("Applying commit %s from %s without verifying its signature (agent.source.allowUnsigned)", revision, s)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("commit %s of %s is not signed by a trusted key: %w", revision, s, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package gitops pulls the agent's NodeSpec from a Git repository or an OCI artifact and verifies its
+// signature, so node configuration changes go through review like any other change before the agent applies them.
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	// StateDir holds the source checkout and the last pulled NodeSpec. It is in the agent user's home
+	// directory, so git and oras find the user's credentials.
+	StateDir = "/var/lib/aks-flex-node/gitops"
+	// SpecPath is the NodeSpec of the last revision applied from the source, loaded on top of the local configuration
+	SpecPath = StateDir + "/nodespec.yaml"
+	// PendingSpecPath holds a pulled NodeSpec while it is applied; it replaces SpecPath once the node converged
+	PendingSpecPath = StateDir + "/pending-nodespec.yaml"
+	// RevisionPath records the commit or artifact digest of the last NodeSpec applied successfully
+	RevisionPath = StateDir + "/applied-revision"
+
+	defaultSpecPath = "nodespec.yaml"
+	defaultGitRef   = "main"
+)
+
+// Source is where the agent pulls its NodeSpec from
+type Source interface {
+	// Fetch returns the revision of the NodeSpec currently published by the source and its verified contents
+	Fetch(ctx context.Context) (revision string, spec []byte, err error)
+	// String describes the source for logs
+	String() string
+}
+
+// runFunc runs a command with extra environment variables and returns its standard output
+type runFunc func(ctx context.Context, env []string, name string, args ...string) ([]byte, error)
+
+// NewSource returns the source configured in agent.source, or nil when the agent does not pull its NodeSpec
+func NewSource(cfg *config.Config, logger *logrus.Logger) Source {
+	source := cfg.Agent.Source
+	switch {
+	case source.Git != nil:
+		return &gitSource{config: source.Git, allowUnsigned: source.AllowUnsigned, dir: StateDir, logger: logger, run: runCommand}
+	case source.OCI != nil:
+		return &ociSource{config: source.OCI, allowUnsigned: source.AllowUnsigned, dir: StateDir, logger: logger, run: runCommand}
+	default:
+		return nil
+	}
+}
+
+// runCommand runs a command as the agent user
+func runCommand(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s %s: %w: %s", name, args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("%s %s: %w", name, args[0], err)
+	}
+	return output, nil
+}
+
+// specFile returns the configured path of the NodeSpec in the source, or the default
+func specFile(path string) string {
+	if path == "" {
+		return defaultSpecPath
+	}
+	return path
+}
+
+// LoadAppliedRevision returns the revision of the last NodeSpec applied from the source, or "" when none was
+func LoadAppliedRevision(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveAppliedRevision records the revision of a NodeSpec applied successfully
+func SaveAppliedRevision(path, revision string) error {
+	return writeStateFile(path, []byte(revision+"\n"))
+}
+
+// SaveSpec stores a pulled NodeSpec for the agent to load on top of its local configuration
+func SaveSpec(path string, spec []byte) error {
+	return writeStateFile(path, spec)
+}
+
+// writeStateFile atomically replaces a file in the agent's state directory
+func writeStateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
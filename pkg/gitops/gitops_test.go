@@ -0,0 +1,230 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// fakeRunner records the commands run and answers them from canned output
+type fakeRunner struct {
+	commands []string
+	output   map[string]string // Keyed by command prefix
+	fail     string            // Command prefix that fails
+}
+
+func (f *fakeRunner) run(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append(env, append([]string{name}, args...)...), " ")
+	f.commands = append(f.commands, command)
+	if f.fail != "" && strings.Contains(command, f.fail) {
+		return nil, errors.New("exit status 1")
+	}
+	for prefix, output := range f.output {
+		if strings.Contains(command, prefix) {
+			return []byte(output), nil
+		}
+	}
+	return nil, nil
+}
+
+func TestGitSourceFetch(t *testing.T) {
+	dir := t.TempDir()
+	runner := &fakeRunner{output: map[string]string{
+		"rev-parse FETCH_HEAD":          "0123abcd\n",
+		"show 0123abcd:nodes/edge.yaml": "kind: NodeSpec\n",
+	}}
+	source := &gitSource{
+		config: &config.GitSourceConfig{URL: "https://github.com/contoso/edge-nodes.git", Path: "nodes/edge.yaml", AllowedSignersFile: "/etc/aks-flex-node/allowed_signers"},
+		dir:    dir,
+		logger: logrus.New(),
+		run:    runner.run,
+	}
+
+	revision, spec, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if revision != "0123abcd" || string(spec) != "kind: NodeSpec\n" {
+		t.Errorf("Fetch() = %q, %q, want the commit and the NodeSpec at it", revision, spec)
+	}
+
+	repo := filepath.Join(dir, "repo")
+	want := []string{
+		"git init --quiet " + repo,
+		"git -C " + repo + " fetch --quiet --depth 1 --no-tags -- https://github.com/contoso/edge-nodes.git main",
+		"git -C " + repo + " rev-parse FETCH_HEAD",
+		"git -C " + repo + " -c gpg.ssh.allowedSignersFile=/etc/aks-flex-node/allowed_signers verify-commit 0123abcd",
+		"git -C " + repo + " show 0123abcd:nodes/edge.yaml",
+	}
+	if strings.Join(runner.commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Fetch() ran\n%s\nwant\n%s", strings.Join(runner.commands, "\n"), strings.Join(want, "\n"))
+	}
+
+	runner.fail = "verify-commit"
+	if _, spec, err := source.Fetch(context.Background()); err == nil || spec != nil {
+		t.Errorf("Fetch() = %q, %v for an unsigned commit, want an error", spec, err)
+	}
+}
+
+func TestGitSourceGPGKeyring(t *testing.T) {
+	dir := t.TempDir()
+	runner := &fakeRunner{output: map[string]string{"rev-parse": "0123abcd"}}
+	source := &gitSource{
+		config: &config.GitSourceConfig{URL: "git@github.com:contoso/edge-nodes.git", GPGPublicKeyFile: "/etc/aks-flex-node/signers.asc"},
+		dir:    dir,
+		logger: logrus.New(),
+		run:    runner.run,
+	}
+	if _, _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+
+	home := "GNUPGHOME=" + filepath.Join(dir, "gnupg-")
+	var imported, verified bool
+	for _, command := range runner.commands {
+		imported = imported || strings.HasPrefix(command, home) && strings.HasSuffix(command, " gpg --batch --quiet --import /etc/aks-flex-node/signers.asc")
+		verified = verified || strings.HasPrefix(command, home) && strings.HasSuffix(command, "verify-commit 0123abcd")
+	}
+	if !imported || !verified {
+		t.Errorf("Fetch() ran %v, want the commit verified against a keyring of the configured keys", runner.commands)
+	}
+}
+
+func TestGitSourceGPGKeyRemoved(t *testing.T) {
+	dir := t.TempDir()
+	keys := filepath.Join(dir, "signers.asc")
+	var trusted []string // Keys in the keyring when the commit was verified
+	runner := &fakeRunner{output: map[string]string{"rev-parse": "0123abcd"}}
+	run := func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+		if len(env) > 0 {
+			// Stand in for gpg and git keeping the keyring in GNUPGHOME
+			keyring := filepath.Join(strings.TrimPrefix(env[0], "GNUPGHOME="), "pubring")
+			switch {
+			case name == "gpg":
+				data, err := os.ReadFile(args[len(args)-1])
+				if err != nil {
+					return nil, err
+				}
+				f, err := os.OpenFile(keyring, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+				if err != nil {
+					return nil, err
+				}
+				defer f.Close()
+				_, err = f.Write(data)
+				return nil, err
+			case args[len(args)-2] == "verify-commit":
+				data, _ := os.ReadFile(keyring)
+				trusted = strings.Fields(string(data))
+			}
+		}
+		return runner.run(ctx, env, name, args...)
+	}
+	source := &gitSource{
+		config: &config.GitSourceConfig{URL: "git@github.com:contoso/edge-nodes.git", GPGPublicKeyFile: keys},
+		dir:    dir,
+		logger: logrus.New(),
+		run:    run,
+	}
+
+	if err := os.WriteFile(keys, []byte("release-key compromised-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if err := os.WriteFile(keys, []byte("release-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if strings.Join(trusted, " ") != "release-key" {
+		t.Errorf("Fetch() verified against keys %v, want only the key still configured", trusted)
+	}
+	if homes, _ := filepath.Glob(filepath.Join(dir, "gnupg-*")); len(homes) != 0 {
+		t.Errorf("Fetch() left keyrings %v behind", homes)
+	}
+}
+
+func TestOCISourceFetch(t *testing.T) {
+	dir := t.TempDir()
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	runner := &fakeRunner{output: map[string]string{"oras resolve": digest + "\n"}}
+	source := &ociSource{
+		config: &config.OCISourceConfig{Reference: "contoso.azurecr.io:443/nodespecs/edge:prod", CosignPublicKeyFile: "/etc/aks-flex-node/cosign.pub"},
+		dir:    dir,
+		logger: logrus.New(),
+		run:    runner.run,
+	}
+
+	// oras writes the artifact files into the output directory, which Fetch clears first
+	pinned := "contoso.azurecr.io:443/nodespecs/edge@" + digest
+	artifact := filepath.Join(dir, "artifact")
+	pull := "oras pull --output " + artifact + " " + pinned
+	runFake := runner.run
+	source.run = func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+		output, err := runFake(ctx, env, name, args...)
+		if strings.Join(append([]string{name}, args...), " ") == pull {
+			err = os.WriteFile(filepath.Join(artifact, "nodespec.yaml"), []byte("kind: NodeSpec\n"), 0o600)
+		}
+		return output, err
+	}
+
+	revision, spec, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if revision != digest || string(spec) != "kind: NodeSpec\n" {
+		t.Errorf("Fetch() = %q, %q, want the digest and the NodeSpec in the artifact", revision, spec)
+	}
+	want := []string{
+		"oras resolve contoso.azurecr.io:443/nodespecs/edge:prod",
+		"cosign verify --key /etc/aks-flex-node/cosign.pub " + pinned,
+		pull,
+	}
+	if strings.Join(runner.commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Fetch() ran\n%s\nwant\n%s", strings.Join(runner.commands, "\n"), strings.Join(want, "\n"))
+	}
+
+	runner.commands, runner.fail = nil, "cosign verify"
+	if _, _, err := source.Fetch(context.Background()); err == nil {
+		t.Error("Fetch() expected error for an unsigned artifact")
+	}
+	if len(runner.commands) != 2 {
+		t.Errorf("Fetch() ran %v, want nothing pulled after verification failed", runner.commands)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	for reference, want := range map[string]string{
+		"contoso.azurecr.io/nodespecs/edge:prod":            "contoso.azurecr.io/nodespecs/edge",
+		"contoso.azurecr.io/nodespecs/edge":                 "contoso.azurecr.io/nodespecs/edge",
+		"localhost:5000/edge":                               "localhost:5000/edge",
+		"localhost:5000/edge:v1@sha256:0123":                "localhost:5000/edge",
+		"contoso.azurecr.io/nodespecs/edge@sha256:abcd0123": "contoso.azurecr.io/nodespecs/edge",
+	} {
+		if got := repository(reference); got != want {
+			t.Errorf("repository(%q) = %q, want %q", reference, got, want)
+		}
+	}
+}
+
+func TestAppliedRevision(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gitops", "applied-revision")
+	if revision, err := LoadAppliedRevision(path); err != nil || revision != "" {
+		t.Fatalf("LoadAppliedRevision() before the first apply = %q, %v, want empty", revision, err)
+	}
+	if err := SaveAppliedRevision(path, "0123abcd"); err != nil {
+		t.Fatalf("SaveAppliedRevision() unexpected error: %v", err)
+	}
+	if revision, err := LoadAppliedRevision(path); err != nil || revision != "0123abcd" {
+		t.Errorf("LoadAppliedRevision() = %q, %v, want 0123abcd", revision, err)
+	}
+}
@@ -0,0 +1,80 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// ociSource pulls the NodeSpec from an OCI artifact with the oras CLI and verifies its cosign signature.
+// The reference is resolved to a digest first, so the verified and the pulled artifact are the same.
+type ociSource struct {
+	config        *config.OCISourceConfig
+	allowUnsigned bool
+	dir           string
+	logger        *logrus.Logger
+	run           runFunc
+}
+
+func (s *ociSource) String() string {
+	return s.config.Reference
+}
+
+// Fetch resolves the reference, verifies the signature of the artifact and reads the NodeSpec from it
+func (s *ociSource) Fetch(ctx context.Context) (string, []byte, error) {
+	output, err := s.run(ctx, nil, "oras", "resolve", s.config.Reference)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve %s: %w", s, err)
+	}
+	digest := strings.TrimSpace(string(output))
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", nil, fmt.Errorf("failed to resolve %s: unexpected digest %q", s, digest)
+	}
+	pinned := repository(s.config.Reference) + "@" + digest
+
+	switch {
+	case s.config.CosignPublicKeyFile != "":
+		if _, err := s.run(ctx, nil, "cosign", "verify", "--key", s.config.CosignPublicKeyFile, pinned); err != nil {
+			return "", nil, fmt.Errorf("artifact %s is not signed by a trusted key: %w", pinned, err)
+		}
+	case !s.allowUnsigned:
+		return "", nil, fmt.Errorf("no trusted key configured to verify artifact %s", pinned)
+	default:
+		s.logger.Warnf("Applying artifact %s without verifying its signature (agent.source.allowUnsigned)", pinned)
+	}
+
+	artifact := filepath.Join(s.dir, "artifact")
+	if err := os.RemoveAll(artifact); err != nil {
+		return "", nil, fmt.Errorf("failed to clear %s: %w", artifact, err)
+	}
+	if err := os.MkdirAll(artifact, 0o700); err != nil {
+		return "", nil, fmt.Errorf("failed to create %s: %w", artifact, err)
+	}
+	if _, err := s.run(ctx, nil, "oras", "pull", "--output", artifact, pinned); err != nil {
+		return "", nil, fmt.Errorf("failed to pull %s: %w", pinned, err)
+	}
+	path := specFile(s.config.Path)
+	spec, err := os.ReadFile(filepath.Join(artifact, path))
+	if err != nil {
+		return "", nil, fmt.Errorf("artifact %s has no %s: %w", pinned, path, err)
+	}
+	return digest, spec, nil
+}
+
+// repository returns the reference without its tag or digest, e.g. contoso.azurecr.io/nodespecs/edge
+func repository(reference string) string {
+	if i := strings.Index(reference, "@"); i >= 0 {
+		reference = reference[:i]
+	}
+	// A colon after the last slash separates the tag; one before it belongs to the registry port
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		reference = reference[:i]
+	}
+	return reference
+}
@@ -0,0 +1,133 @@
+// Package heartbeat publishes the liveness of the agent daemon as a condition on its node. The kubelet keeps
+// the Ready condition; FlexNodeAgentReady tells the control plane whether the agent managing the node is still
+// alive, so automation can clean up nodes whose agent is gone even while their kubelet keeps running.
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ConditionType is the node condition the heartbeat is published as
+const ConditionType = "FlexNodeAgentReady"
+
+// Reasons of the condition
+const (
+	ReasonRunning = "AgentRunning" // Status True: the agent daemon is running
+	ReasonStopped = "AgentStopped" // Status False: the agent daemon shut down cleanly
+)
+
+// requestTimeout bounds each update so a slow API server cannot hold up the daemon's shutdown
+const requestTimeout = 10 * time.Second
+
+// condition is the node condition as sent to the API server
+type condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	LastHeartbeatTime  string `json:"lastHeartbeatTime"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+}
+
+// Heartbeat keeps the FlexNodeAgentReady condition of the node up to date with the kubelet's node identity
+type Heartbeat struct {
+	interval time.Duration
+	version  string
+	logger   *logrus.Logger
+
+	kubectl    func(args ...string) (string, error)
+	hostname   func() (string, error)
+	fileExists func(path string) bool
+	now        func() time.Time
+}
+
+// New creates a heartbeat publishing at the configured interval on behalf of the given agent version
+func New(cfg *config.Config, version string, logger *logrus.Logger) *Heartbeat {
+	return &Heartbeat{
+		interval: cfg.GetHeartbeatInterval(),
+		version:  version,
+		logger:   logger,
+		kubectl: func(args ...string) (string, error) {
+			return utils.RunCommandWithOutput("kubectl", args...)
+		},
+		hostname:   os.Hostname,
+		fileExists: utils.FileExists,
+		now:        time.Now,
+	}
+}
+
+// Interval returns how often the heartbeat is published
+func (h *Heartbeat) Interval() time.Duration {
+	return h.interval
+}
+
+// Run publishes the heartbeat every interval until ctx is done, then marks the agent stopped.
+// Run returns only after that last update, so the caller can wait for it before exiting.
+func (h *Heartbeat) Run(ctx context.Context) {
+	started := h.now()
+	message := fmt.Sprintf("aks-flex-node agent %s is running and reports every %s", h.version, h.interval)
+	h.publish("True", ReasonRunning, message, started)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.publish("False", ReasonStopped, fmt.Sprintf("aks-flex-node agent %s stopped", h.version), h.now())
+			return
+		case <-ticker.C:
+			h.publish("True", ReasonRunning, message, started)
+		}
+	}
+}
+
+// publish sets the condition on the node; failures are logged because the next beat tries again
+func (h *Heartbeat) publish(status, reason, message string, since time.Time) {
+	// Before bootstrap there is no node identity and no node to report on
+	if !h.fileExists(kubelet.KubeletKubeconfigPath) {
+		h.logger.Debugf("Skipping heartbeat: %s does not exist yet", kubelet.KubeletKubeconfigPath)
+		return
+	}
+	if err := h.patch(status, reason, message, since); err != nil {
+		h.logger.Warnf("Failed to publish heartbeat: %v", err)
+	}
+}
+
+func (h *Heartbeat) patch(status, reason, message string, since time.Time) error {
+	// The kubelet registers the node under its lower-cased hostname
+	hostname, err := h.hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine node name: %w", err)
+	}
+	node := strings.ToLower(hostname)
+
+	// Conditions are merged by type, so the kubelet's and NPD's conditions are left alone
+	patch, err := json.Marshal(map[string]any{"status": map[string]any{"conditions": []condition{{
+		Type:               ConditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastHeartbeatTime:  h.now().UTC().Format(time.RFC3339),
+		LastTransitionTime: since.UTC().Format(time.RFC3339),
+	}}}})
+	if err != nil {
+		return err
+	}
+	output, err := h.kubectl("--kubeconfig", kubelet.KubeletKubeconfigPath, "--request-timeout="+requestTimeout.String(),
+		"patch", "node", node, "--subresource=status", "--type=strategic", "-p", string(patch))
+	if err != nil {
+		return fmt.Errorf("kubectl patch node %s failed: %w: %s", node, err, strings.TrimSpace(output))
+	}
+	return nil
+}
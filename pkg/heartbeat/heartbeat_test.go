@@ -0,0 +1,89 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type patchRecorder struct {
+	args       [][]string
+	conditions []condition
+	err        error
+}
+
+func (r *patchRecorder) kubectl(args ...string) (string, error) {
+	r.args = append(r.args, args)
+	var patch struct {
+		Status struct {
+			Conditions []condition `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(args[len(args)-1]), &patch); err != nil {
+		return "", err
+	}
+	r.conditions = append(r.conditions, patch.Status.Conditions...)
+	return "error: forbidden", r.err
+}
+
+func newTestHeartbeat(recorder *patchRecorder, kubeconfigExists bool) *Heartbeat {
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	return &Heartbeat{
+		interval:   time.Hour,
+		version:    "v1.4.0",
+		logger:     logrus.New(),
+		kubectl:    recorder.kubectl,
+		hostname:   func() (string, error) { return "Edge-01", nil },
+		fileExists: func(string) bool { return kubeconfigExists },
+		now:        func() time.Time { return now },
+	}
+}
+
+func TestRun(t *testing.T) {
+	recorder := &patchRecorder{}
+	h := newTestHeartbeat(recorder, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.Run(ctx)
+
+	if len(recorder.conditions) != 2 {
+		t.Fatalf("Run() published %d conditions, want the start and the stop", len(recorder.conditions))
+	}
+	want := []string{"--kubeconfig", "/var/lib/kubelet/kubeconfig", "--request-timeout=10s", "patch", "node", "edge-01", "--subresource=status", "--type=strategic", "-p"}
+	for i, arg := range want {
+		if recorder.args[0][i] != arg {
+			t.Fatalf("kubectl args = %q, want prefix %q", recorder.args[0], want)
+		}
+	}
+
+	running, stopped := recorder.conditions[0], recorder.conditions[1]
+	if running.Type != ConditionType || running.Status != "True" || running.Reason != ReasonRunning {
+		t.Errorf("first condition = %+v, want the agent running", running)
+	}
+	if running.LastHeartbeatTime != "2026-10-17T08:00:00Z" || running.LastTransitionTime != "2026-10-17T08:00:00Z" {
+		t.Errorf("first condition = %+v, want the heartbeat and transition at start", running)
+	}
+	if stopped.Status != "False" || stopped.Reason != ReasonStopped {
+		t.Errorf("last condition = %+v, want the agent stopped", stopped)
+	}
+}
+
+func TestPublishSkipsUnbootstrappedNode(t *testing.T) {
+	recorder := &patchRecorder{}
+	newTestHeartbeat(recorder, false).publish("True", ReasonRunning, "running", time.Now())
+	if len(recorder.args) != 0 {
+		t.Errorf("publish() ran kubectl %q without a kubelet kubeconfig", recorder.args)
+	}
+}
+
+func TestPatchError(t *testing.T) {
+	recorder := &patchRecorder{err: errors.New("exit status 1")}
+	err := newTestHeartbeat(recorder, true).patch("True", ReasonRunning, "running", time.Now())
+	if err == nil || err.Error() != "kubectl patch node edge-01 failed: exit status 1: error: forbidden" {
+		t.Errorf("patch() error = %v, want the kubectl output", err)
+	}
+}
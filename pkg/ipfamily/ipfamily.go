@@ -0,0 +1,82 @@
+// Package ipfamily finds the addresses a node uses for each of its IP families, so kubelet registers the
+// right node IPs on IPv6-only and dual-stack networks.
+package ipfamily
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// probeAddresses are documentation addresses, routed through the default route of their family. Connecting
+// a UDP socket to them sends nothing; the kernel only looks up the route and picks the source address.
+var probeAddresses = map[string]string{
+	config.IPFamilyIPv4: "192.0.2.1:9",
+	config.IPFamilyIPv6: "[2001:db8::1]:9",
+}
+
+// ProbeAddress returns an address whose route is the default route of family
+func ProbeAddress(family string) string {
+	return probeAddresses[family]
+}
+
+// SourceAddress returns the address the host sends traffic of family from, following its default route;
+// replaced in tests
+var SourceAddress = func(family string) (net.IP, error) {
+	network := "udp4"
+	if family == config.IPFamilyIPv6 {
+		network = "udp6"
+	}
+	conn, err := net.Dial(network, probeAddresses[family])
+	if err != nil {
+		return nil, fmt.Errorf("the host has no %s default route: %w", family, err)
+	}
+	defer func() { _ = conn.Close() }()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	if !ip.IsGlobalUnicast() {
+		return nil, fmt.Errorf("the host has no routable %s address, only %s", family, ip)
+	}
+	return ip, nil
+}
+
+// Of returns the IP family of ip
+func Of(ip net.IP) string {
+	if ip.To4() != nil {
+		return config.IPFamilyIPv4
+	}
+	return config.IPFamilyIPv6
+}
+
+// NodeIPs returns the addresses kubelet registers, one per IP family with the primary family first: the
+// configured node.network.nodeIPs, or the source address of each family
+func NodeIPs(cfg *config.Config) ([]string, error) {
+	if len(cfg.Node.Network.NodeIPs) > 0 {
+		return cfg.Node.Network.NodeIPs, nil
+	}
+	families := cfg.GetIPFamilies()
+	ips := make([]string, 0, len(families))
+	for _, family := range families {
+		ip, err := SourceAddress(family)
+		if err != nil {
+			return nil, fmt.Errorf("cannot detect the node's %s address, set node.network.nodeIPs: %w", family, err)
+		}
+		ips = append(ips, ip.String())
+	}
+	return ips, nil
+}
+
+// NodeIPFlag returns kubelet's --node-ip flag, or "" for IPv4-only nodes without configured node IPs, where
+// kubelet's own choice of address is kept
+func NodeIPFlag(cfg *config.Config) (string, error) {
+	families := cfg.GetIPFamilies()
+	if len(cfg.Node.Network.NodeIPs) == 0 && len(families) == 1 && families[0] == config.IPFamilyIPv4 {
+		return "", nil
+	}
+	ips, err := NodeIPs(cfg)
+	if err != nil {
+		return "", err
+	}
+	return "--node-ip=" + strings.Join(ips, ","), nil
+}
@@ -0,0 +1,47 @@
+package ipfamily
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestNodeIPFlag(t *testing.T) {
+	original := SourceAddress
+	defer func() { SourceAddress = original }()
+	SourceAddress = func(family string) (net.IP, error) {
+		if family == config.IPFamilyIPv6 {
+			return net.ParseIP("2001:db8:10::5"), nil
+		}
+		return net.ParseIP("10.0.0.5"), nil
+	}
+
+	tests := []struct {
+		name    string
+		network config.NodeNetworkConfig
+		want    string
+	}{
+		{name: "IPv4 only keeps kubelet's choice", want: ""},
+		{name: "dual-stack detected", network: config.NodeNetworkConfig{IPFamilies: []string{"IPv6", "IPv4"}}, want: "--node-ip=2001:db8:10::5,10.0.0.5"},
+		{name: "IPv6 only", network: config.NodeNetworkConfig{IPFamilies: []string{"IPv6"}}, want: "--node-ip=2001:db8:10::5"},
+		{name: "configured", network: config.NodeNetworkConfig{NodeIPs: []string{"10.1.2.3"}}, want: "--node-ip=10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Node: config.NodeConfig{Network: tt.network}}
+			got, err := NodeIPFlag(cfg)
+			if err != nil || got != tt.want {
+				t.Errorf("NodeIPFlag() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	SourceAddress = func(family string) (net.IP, error) { return nil, errors.New("network is unreachable") }
+	cfg := &config.Config{Node: config.NodeConfig{Network: config.NodeNetworkConfig{IPFamilies: []string{"IPv4", "IPv6"}}}}
+	if _, err := NodeIPFlag(cfg); err == nil || !strings.Contains(err.Error(), "set node.network.nodeIPs") {
+		t.Errorf("NodeIPFlag() error = %v, want a hint to configure the node IPs", err)
+	}
+}
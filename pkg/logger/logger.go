@@ -202,6 +202,11 @@ func ensureLogDirectoryExists(logDir string) error {
 	return nil
 }
 
+// LogToFileOnly sends the logger output to the log file in logDir only, e.g. while a terminal UI owns the console
+func LogToFileOnly(logger *logrus.Logger, logDir string) error {
+	return setupLogFile(logger, logDir)
+}
+
 // setupLogFile creates log file in the specified directory (legacy method for non-systemd)
 func setupLogFile(logger *logrus.Logger, logDir string) error {
 	// Ensure the log directory exists first
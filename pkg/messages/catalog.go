@@ -0,0 +1,333 @@
+package messages
+
+// Message keys for CLI output and errors
+const (
+	ShutdownSignal       Key = "cli.shutdownSignal"
+	CommandFailed        Key = "cli.commandFailed"
+	ConfigPathRequired   Key = "cli.configPathRequired"
+	ConfigLoadFailed     Key = "cli.configLoadFailed"
+	UnsupportedLocale    Key = "cli.unsupportedLocale"
+	OperationSucceeded   Key = "cli.operationSucceeded"
+	OperationPartialFail Key = "cli.operationPartialFail"
+	OperationFailed      Key = "cli.operationFailed"
+	OperationResultNil   Key = "cli.operationResultNil"
+	BootstrapToDaemon    Key = "cli.bootstrapToDaemon"
+	VersionTitle         Key = "cli.versionTitle"
+	VersionLine          Key = "cli.versionLine"
+	GitCommitLine        Key = "cli.gitCommitLine"
+	BuildTimeLine        Key = "cli.buildTimeLine"
+	RebootRequired       Key = "cli.rebootRequired"
+	RebootScheduled      Key = "cli.rebootScheduled"
+	RebootManual         Key = "cli.rebootManual"
+	NothingToResume      Key = "cli.nothingToResume"
+	DisruptedWorkloads   Key = "cli.disruptedWorkloads"
+	DecommissionBlocked  Key = "cli.decommissionBlocked"
+	DecommissionForced   Key = "cli.decommissionForced"
+	DecommissionCheck    Key = "cli.decommissionCheck"
+)
+
+// Message keys for the interactive install UI
+const (
+	TUITitle     Key = "tui.title"
+	TUIStarting  Key = "tui.starting"
+	TUIRunning   Key = "tui.running"
+	TUISucceeded Key = "tui.succeeded"
+	TUIFailed    Key = "tui.failed"
+	TUIReboot    Key = "tui.reboot"
+	TUILogs      Key = "tui.logs"
+	TUINoLogs    Key = "tui.noLogs"
+	TUIRetry     Key = "tui.retry"
+	TUIKeys      Key = "tui.keys"
+	TUINoTTY     Key = "tui.noTTY"
+)
+
+// Message keys for remediation hints shown after a failed step
+const (
+	HintPrefix      Key = "hint.prefix"
+	HintGeneric     Key = "hint.generic"
+	HintArc         Key = "hint.arc"
+	HintSystem      Key = "hint.system"
+	HintDownload    Key = "hint.download"
+	HintKubelet     Key = "hint.kubelet"
+	HintServices    Key = "hint.services"
+	HintNPD         Key = "hint.npd"
+	HintFluentBit   Key = "hint.fluentbit"
+	HintSSH         Key = "hint.ssh"
+	HintRuntime     Key = "hint.runtime"
+	HintImagePull   Key = "hint.imagepull"
+	HintUnbootstrap Key = "hint.unbootstrap"
+	HintPreflight   Key = "hint.preflight"
+)
+
+// Message keys for remedies of known issues, recognized by a signature in the error of a failed step
+const (
+	RemedyDocs              Key = "remedy.docs"
+	RemedyPrincipalNotFound Key = "remedy.principalNotFound"
+	RemedyMCRTLS            Key = "remedy.mcrTLS"
+	RemedyCgroupDriver      Key = "remedy.cgroupDriver"
+	RemedyDNSLoop           Key = "remedy.dnsLoop"
+)
+
+// localeOrder keeps SupportedLocales output stable
+var localeOrder = []string{"en", "de", "es", "zh-cn"}
+
+// catalogs holds the translated message formats keyed by normalized locale.
+// The English catalog is the source of truth; other catalogs may be partial.
+var catalogs = map[string]map[Key]string{
+	"en": {
+		ShutdownSignal:       "Received shutdown signal, cancelling operations...",
+		CommandFailed:        "Command execution failed: %v",
+		ConfigPathRequired:   "config path is required for %s command",
+		ConfigLoadFailed:     "failed to load config from %s: %w",
+		UnsupportedLocale:    "Warning: %v. Using '%s' locale.",
+		OperationSucceeded:   "%s completed successfully (duration: %v, steps: %d)",
+		OperationPartialFail: "%s completed with some failures: %s (duration: %v)",
+		OperationFailed:      "%s failed: %s",
+		OperationResultNil:   "%s result is nil",
+		BootstrapToDaemon:    "Bootstrap completed successfully, transitioning to daemon mode...",
+		VersionTitle:         "AKS Flex Node Agent",
+		VersionLine:          "Version: %s",
+		GitCommitLine:        "Git Commit: %s",
+		BuildTimeLine:        "Build Time: %s",
+		RebootRequired:       "%s paused after step %s, which requires a reboot; the remaining steps resume automatically on the next boot",
+		RebootScheduled:      "Reboot scheduled for %s",
+		RebootManual:         "Reboot the machine to continue, e.g. with 'sudo systemctl reboot'",
+		NothingToResume:      "No interrupted bootstrap to resume",
+		DisruptedWorkloads:   "Unbootstrap disrupts %d pods and %d volumes on node %s:",
+		DecommissionBlocked:  "refusing to unbootstrap node %s while it runs workloads; drain it first or re-run with --force",
+		DecommissionForced:   "Continuing because of --force",
+		DecommissionCheck:    "failed to check node for workloads: %w; re-run with --force to unbootstrap without the check",
+
+		TUITitle:     "AKS Flex Node bootstrap",
+		TUIStarting:  "Starting bootstrap...",
+		TUIRunning:   "Running %s...",
+		TUISucceeded: "Bootstrap completed successfully in %v. Press q to exit.",
+		TUIFailed:    "Bootstrap failed at %s. Press r to retry or q to exit.",
+		TUIReboot:    "Step %s requires a reboot; bootstrap continues automatically after the reboot. Press q to exit.",
+		TUILogs:      "Logs: %s",
+		TUINoLogs:    "No logs for this component yet.",
+		TUIRetry:     "--- retry %d ---",
+		TUIKeys:      "up/down: select component  r: retry  q: quit",
+		TUINoTTY:     "the interactive install needs a terminal: %w",
+
+		HintPrefix:      "Hint: %s",
+		HintGeneric:     "Re-run with agent.logLevel set to \"debug\" and check the log file in agent.logDir for details.",
+		HintArc:         "Verify 'az login' works for the configured tenant and that your identity has Owner or User Access Administrator on the target cluster.",
+		HintSystem:      "Check that the agent runs as root or has the sudo rules from aks-flex-node-sudoers installed.",
+		HintDownload:    "Check outbound HTTPS access to github.com and acs-mirror.azureedge.net, including any proxy configuration.",
+		HintKubelet:     "Verify the target cluster exists, has Azure RBAC enabled, and that the node identity can list cluster admin credentials.",
+		HintServices:    "Inspect the failing service with 'journalctl -u kubelet -u containerd --no-pager -n 100'.",
+		HintNPD:         "Verify the kubelet kubeconfig at /var/lib/kubelet/kubeconfig exists and is readable.",
+		HintFluentBit:   "Check the fluentBit settings and inspect the service with 'journalctl -u fluent-bit --no-pager -n 100'.",
+		HintSSH:         "Check the ssh settings; 'sudo sshd -T' shows the effective SSH server configuration. The previous sshd configuration was kept.",
+		HintRuntime:     "The error above is returned by the container runtime; inspect it with 'journalctl -u containerd --no-pager -n 100' (or '-u crio' for CRI-O) and check access to the pause image registry.",
+		HintImagePull:   "Check that every image in imagePrePull.images exists and that the node can reach its registry or registry mirror.",
+		HintUnbootstrap: "Some cleanup steps failed; re-run unbootstrap or remove the remaining files manually.",
+		HintPreflight:   "Each failed preflight check above explains what to fix; nothing was changed on this machine yet.",
+
+		RemedyDocs:              "See %s",
+		RemedyPrincipalNotFound: "The Arc machine's managed identity did not appear in Microsoft Entra ID while the role assignment was retried. Set azure.arc.verifyPrincipal to true to tell a replication delay from a wrong principal ID, then run bootstrap again.",
+		RemedyMCRTLS:            "TLS to mcr.microsoft.com failed, usually because a proxy or firewall re-signs HTTPS traffic. Add its root CA to caTrust.certificates, or exempt mcr.microsoft.com and *.data.mcr.microsoft.com from TLS inspection.",
+		RemedyCgroupDriver:      "Kubelet and the container runtime disagree on the cgroup driver. Set node.cgroup.driver to the runtime's driver, or remove manual changes to the runtime's configuration, then run bootstrap again.",
+		RemedyDNSLoop:           "Cluster DNS forwards to the node's resolver, which forwards back to cluster DNS. Make sure /etc/resolv.conf lists the real upstream servers instead of the stub resolver 127.0.0.53.",
+	},
+	"de": {
+		ShutdownSignal:       "Beendigungssignal empfangen, Vorgänge werden abgebrochen...",
+		CommandFailed:        "Befehlsausführung fehlgeschlagen: %v",
+		ConfigPathRequired:   "Für den Befehl %s ist ein Konfigurationspfad erforderlich",
+		ConfigLoadFailed:     "Konfiguration konnte nicht aus %s geladen werden: %w",
+		UnsupportedLocale:    "Warnung: %v. Es wird die Sprache '%s' verwendet.",
+		OperationSucceeded:   "%s erfolgreich abgeschlossen (Dauer: %v, Schritte: %d)",
+		OperationPartialFail: "%s mit Fehlern abgeschlossen: %s (Dauer: %v)",
+		OperationFailed:      "%s fehlgeschlagen: %s",
+		OperationResultNil:   "Ergebnis von %s ist leer",
+		BootstrapToDaemon:    "Bootstrap erfolgreich abgeschlossen, Wechsel in den Daemon-Modus...",
+		VersionTitle:         "AKS Flex Node Agent",
+		VersionLine:          "Version: %s",
+		GitCommitLine:        "Git-Commit: %s",
+		BuildTimeLine:        "Build-Zeit: %s",
+		RebootRequired:       "%s nach Schritt %s angehalten, der einen Neustart erfordert; die restlichen Schritte werden beim nächsten Start automatisch fortgesetzt",
+		RebootScheduled:      "Neustart geplant für %s",
+		RebootManual:         "Starten Sie den Rechner neu, um fortzufahren, z. B. mit 'sudo systemctl reboot'",
+		NothingToResume:      "Kein unterbrochenes Bootstrap zum Fortsetzen",
+		DisruptedWorkloads:   "Unbootstrap unterbricht %d Pods und %d Volumes auf Knoten %s:",
+		DecommissionBlocked:  "Unbootstrap von Knoten %s abgelehnt, da noch Workloads laufen; entleeren Sie ihn zuerst oder wiederholen Sie mit --force",
+		DecommissionForced:   "Wird wegen --force fortgesetzt",
+		DecommissionCheck:    "Workloads auf dem Knoten konnten nicht geprüft werden: %w; wiederholen Sie mit --force, um ohne Prüfung fortzufahren",
+
+		TUITitle:     "AKS Flex Node Bootstrap",
+		TUIStarting:  "Bootstrap wird gestartet...",
+		TUIRunning:   "%s wird ausgeführt...",
+		TUISucceeded: "Bootstrap in %v erfolgreich abgeschlossen. Drücken Sie q zum Beenden.",
+		TUIFailed:    "Bootstrap bei %s fehlgeschlagen. Drücken Sie r für einen neuen Versuch oder q zum Beenden.",
+		TUIReboot:    "Schritt %s erfordert einen Neustart; das Bootstrap wird nach dem Neustart automatisch fortgesetzt. Drücken Sie q zum Beenden.",
+		TUILogs:      "Protokoll: %s",
+		TUINoLogs:    "Noch keine Protokolleinträge für diese Komponente.",
+		TUIRetry:     "--- Versuch %d ---",
+		TUIKeys:      "auf/ab: Komponente wählen  r: erneut versuchen  q: beenden",
+		TUINoTTY:     "die interaktive Installation benötigt ein Terminal: %w",
+
+		HintPrefix:      "Hinweis: %s",
+		HintGeneric:     "Mit agent.logLevel \"debug\" erneut ausführen und die Protokolldatei in agent.logDir prüfen.",
+		HintArc:         "Prüfen Sie, ob 'az login' für den konfigurierten Mandanten funktioniert und Ihre Identität Owner oder User Access Administrator auf dem Zielcluster ist.",
+		HintSystem:      "Prüfen Sie, ob der Agent als root läuft oder die sudo-Regeln aus aks-flex-node-sudoers installiert sind.",
+		HintDownload:    "Prüfen Sie den ausgehenden HTTPS-Zugriff auf github.com und acs-mirror.azureedge.net, einschließlich Proxy-Konfiguration.",
+		HintKubelet:     "Prüfen Sie, ob der Zielcluster existiert, Azure RBAC aktiviert ist und die Knotenidentität Cluster-Admin-Anmeldedaten abrufen darf.",
+		HintServices:    "Untersuchen Sie den fehlerhaften Dienst mit 'journalctl -u kubelet -u containerd --no-pager -n 100'.",
+		HintNPD:         "Prüfen Sie, ob die kubeconfig des Kubelets unter /var/lib/kubelet/kubeconfig existiert und lesbar ist.",
+		HintFluentBit:   "Prüfen Sie die fluentBit-Einstellungen und untersuchen Sie den Dienst mit 'journalctl -u fluent-bit --no-pager -n 100'.",
+		HintSSH:         "Prüfen Sie die ssh-Einstellungen; 'sudo sshd -T' zeigt die wirksame Konfiguration des SSH-Servers. Die bisherige sshd-Konfiguration wurde beibehalten.",
+		HintRuntime:     "Der obige Fehler stammt von der Container-Runtime; untersuchen Sie ihn mit 'journalctl -u containerd --no-pager -n 100' (oder '-u crio' für CRI-O) und prüfen Sie den Zugriff auf die Registry des Pause-Images.",
+		HintImagePull:   "Prüfen Sie, ob jedes Image in imagePrePull.images existiert und ob der Knoten seine Registry oder seinen Registry-Mirror erreicht.",
+		HintUnbootstrap: "Einige Bereinigungsschritte sind fehlgeschlagen; führen Sie unbootstrap erneut aus oder entfernen Sie die verbleibenden Dateien manuell.",
+		HintPreflight:   "Jede oben fehlgeschlagene Vorabprüfung beschreibt die Abhilfe; auf diesem Rechner wurde noch nichts geändert.",
+
+		RemedyDocs:              "Siehe %s",
+		RemedyPrincipalNotFound: "Die verwaltete Identität des Arc-Computers ist während der Wiederholungen der Rollenzuweisung nicht in Microsoft Entra ID erschienen. Setzen Sie azure.arc.verifyPrincipal auf true, um eine Replikationsverzögerung von einer falschen Prinzipal-ID zu unterscheiden, und führen Sie bootstrap erneut aus.",
+		RemedyMCRTLS:            "TLS zu mcr.microsoft.com ist fehlgeschlagen, meist weil ein Proxy oder eine Firewall HTTPS-Verkehr neu signiert. Fügen Sie deren Stammzertifikat zu caTrust.certificates hinzu oder nehmen Sie mcr.microsoft.com und *.data.mcr.microsoft.com von der TLS-Prüfung aus.",
+		RemedyCgroupDriver:      "Kubelet und die Container-Runtime verwenden unterschiedliche cgroup-Treiber. Setzen Sie node.cgroup.driver auf den Treiber der Runtime oder entfernen Sie manuelle Änderungen an der Runtime-Konfiguration und führen Sie bootstrap erneut aus.",
+		RemedyDNSLoop:           "Das Cluster-DNS leitet an den Resolver des Knotens weiter, der zurück an das Cluster-DNS weiterleitet. Stellen Sie sicher, dass /etc/resolv.conf die echten Upstream-Server statt des Stub-Resolvers 127.0.0.53 enthält.",
+	},
+	"es": {
+		ShutdownSignal:       "Señal de apagado recibida, cancelando operaciones...",
+		CommandFailed:        "La ejecución del comando falló: %v",
+		ConfigPathRequired:   "se requiere la ruta de configuración para el comando %s",
+		ConfigLoadFailed:     "no se pudo cargar la configuración desde %s: %w",
+		UnsupportedLocale:    "Advertencia: %v. Se usará el idioma '%s'.",
+		OperationSucceeded:   "%s completado correctamente (duración: %v, pasos: %d)",
+		OperationPartialFail: "%s completado con algunos errores: %s (duración: %v)",
+		OperationFailed:      "%s falló: %s",
+		OperationResultNil:   "el resultado de %s está vacío",
+		BootstrapToDaemon:    "Bootstrap completado correctamente, pasando a modo daemon...",
+		VersionTitle:         "Agente AKS Flex Node",
+		VersionLine:          "Versión: %s",
+		GitCommitLine:        "Commit de Git: %s",
+		BuildTimeLine:        "Fecha de compilación: %s",
+		RebootRequired:       "%s en pausa tras el paso %s, que requiere un reinicio; los pasos restantes continúan automáticamente en el próximo arranque",
+		RebootScheduled:      "Reinicio programado para %s",
+		RebootManual:         "Reinicie la máquina para continuar, por ejemplo con 'sudo systemctl reboot'",
+		NothingToResume:      "No hay ningún bootstrap interrumpido que reanudar",
+		DisruptedWorkloads:   "Unbootstrap interrumpe %d pods y %d volúmenes en el nodo %s:",
+		DecommissionBlocked:  "se rechaza el unbootstrap del nodo %s mientras ejecuta cargas de trabajo; vacíelo primero o vuelva a ejecutar con --force",
+		DecommissionForced:   "Se continúa debido a --force",
+		DecommissionCheck:    "no se pudieron comprobar las cargas de trabajo del nodo: %w; vuelva a ejecutar con --force para omitir la comprobación",
+
+		TUITitle:     "Bootstrap de AKS Flex Node",
+		TUIStarting:  "Iniciando el bootstrap...",
+		TUIRunning:   "Ejecutando %s...",
+		TUISucceeded: "Bootstrap completado correctamente en %v. Pulse q para salir.",
+		TUIFailed:    "El bootstrap falló en %s. Pulse r para reintentar o q para salir.",
+		TUIReboot:    "El paso %s requiere un reinicio; el bootstrap continúa automáticamente después del reinicio. Pulse q para salir.",
+		TUILogs:      "Registros: %s",
+		TUINoLogs:    "Todavía no hay registros para este componente.",
+		TUIRetry:     "--- reintento %d ---",
+		TUIKeys:      "arriba/abajo: elegir componente  r: reintentar  q: salir",
+		TUINoTTY:     "la instalación interactiva necesita un terminal: %w",
+
+		HintPrefix:      "Sugerencia: %s",
+		HintGeneric:     "Vuelva a ejecutar con agent.logLevel en \"debug\" y revise el archivo de registro en agent.logDir.",
+		HintArc:         "Verifique que 'az login' funcione para el tenant configurado y que su identidad tenga Owner o User Access Administrator en el clúster de destino.",
+		HintSystem:      "Compruebe que el agente se ejecuta como root o que las reglas sudo de aks-flex-node-sudoers están instaladas.",
+		HintDownload:    "Compruebe el acceso HTTPS saliente a github.com y acs-mirror.azureedge.net, incluida la configuración del proxy.",
+		HintKubelet:     "Verifique que el clúster de destino existe, tiene Azure RBAC habilitado y que la identidad del nodo puede obtener las credenciales de administrador.",
+		HintServices:    "Inspeccione el servicio con errores con 'journalctl -u kubelet -u containerd --no-pager -n 100'.",
+		HintNPD:         "Verifique que el kubeconfig del kubelet en /var/lib/kubelet/kubeconfig existe y es legible.",
+		HintFluentBit:   "Revise la configuración de fluentBit e inspeccione el servicio con 'journalctl -u fluent-bit --no-pager -n 100'.",
+		HintSSH:         "Revise la configuración de ssh; 'sudo sshd -T' muestra la configuración efectiva del servidor SSH. Se mantuvo la configuración anterior de sshd.",
+		HintRuntime:     "El error anterior lo devuelve el runtime de contenedores; inspecciónelo con 'journalctl -u containerd --no-pager -n 100' (o '-u crio' para CRI-O) y compruebe el acceso al registro de la imagen pause.",
+		HintImagePull:   "Compruebe que cada imagen de imagePrePull.images existe y que el nodo puede acceder a su registro o espejo de registro.",
+		HintUnbootstrap: "Algunos pasos de limpieza fallaron; vuelva a ejecutar unbootstrap o elimine manualmente los archivos restantes.",
+		HintPreflight:   "Cada comprobación previa fallida indica cómo corregirla; todavía no se ha modificado nada en esta máquina.",
+
+		RemedyDocs:              "Consulte %s",
+		RemedyPrincipalNotFound: "La identidad administrada de la máquina Arc no apareció en Microsoft Entra ID mientras se reintentaba la asignación de roles. Establezca azure.arc.verifyPrincipal en true para distinguir un retraso de replicación de un ID de principal incorrecto y vuelva a ejecutar bootstrap.",
+		RemedyMCRTLS:            "Falló TLS con mcr.microsoft.com, normalmente porque un proxy o firewall vuelve a firmar el tráfico HTTPS. Agregue su CA raíz a caTrust.certificates o excluya mcr.microsoft.com y *.data.mcr.microsoft.com de la inspección TLS.",
+		RemedyCgroupDriver:      "Kubelet y el runtime de contenedores usan controladores de cgroup distintos. Establezca node.cgroup.driver al controlador del runtime o elimine los cambios manuales en su configuración y vuelva a ejecutar bootstrap.",
+		RemedyDNSLoop:           "El DNS del clúster reenvía al resolvedor del nodo, que reenvía de vuelta al DNS del clúster. Asegúrese de que /etc/resolv.conf contiene los servidores ascendentes reales en lugar del resolvedor stub 127.0.0.53.",
+	},
+	"zh-cn": {
+		ShutdownSignal:       "收到关闭信号，正在取消操作...",
+		CommandFailed:        "命令执行失败：%v",
+		ConfigPathRequired:   "%s 命令需要指定配置文件路径",
+		ConfigLoadFailed:     "无法从 %s 加载配置：%w",
+		UnsupportedLocale:    "警告：%v。将使用 '%s' 语言。",
+		OperationSucceeded:   "%s 成功完成（耗时：%v，步骤数：%d）",
+		OperationPartialFail: "%s 完成但存在失败：%s（耗时：%v）",
+		OperationFailed:      "%s 失败：%s",
+		OperationResultNil:   "%s 结果为空",
+		BootstrapToDaemon:    "Bootstrap 成功完成，正在切换到守护进程模式...",
+		VersionTitle:         "AKS Flex Node 代理",
+		VersionLine:          "版本：%s",
+		GitCommitLine:        "Git 提交：%s",
+		BuildTimeLine:        "构建时间：%s",
+		RebootRequired:       "%s 在步骤 %s 后暂停，该步骤需要重启；其余步骤将在下次启动时自动继续",
+		RebootScheduled:      "已计划于 %s 重启",
+		RebootManual:         "请重启计算机以继续，例如使用 'sudo systemctl reboot'",
+		NothingToResume:      "没有需要继续的中断的引导过程",
+		DisruptedWorkloads:   "Unbootstrap 将中断节点 %[3]s 上的 %[1]d 个 Pod 和 %[2]d 个卷：",
+		DecommissionBlocked:  "节点 %s 仍在运行工作负载，拒绝执行 unbootstrap；请先排空节点或使用 --force 重新运行",
+		DecommissionForced:   "由于指定了 --force，继续执行",
+		DecommissionCheck:    "无法检查节点上的工作负载：%w；使用 --force 重新运行以跳过检查",
+
+		TUITitle:     "AKS Flex Node 引导",
+		TUIStarting:  "正在启动引导...",
+		TUIRunning:   "正在运行 %s...",
+		TUISucceeded: "引导在 %v 内成功完成。按 q 退出。",
+		TUIFailed:    "引导在 %s 失败。按 r 重试，按 q 退出。",
+		TUIReboot:    "步骤 %s 需要重启；重启后引导将自动继续。按 q 退出。",
+		TUILogs:      "日志：%s",
+		TUINoLogs:    "此组件暂无日志。",
+		TUIRetry:     "--- 第 %d 次重试 ---",
+		TUIKeys:      "上/下：选择组件  r：重试  q：退出",
+		TUINoTTY:     "交互式安装需要终端：%w",
+
+		HintPrefix:      "提示：%s",
+		HintGeneric:     "将 agent.logLevel 设置为 \"debug\" 后重新运行，并查看 agent.logDir 中的日志文件。",
+		HintArc:         "请确认 'az login' 对所配置的租户可用，并且您的身份在目标集群上具有 Owner 或 User Access Administrator 角色。",
+		HintSystem:      "请确认代理以 root 身份运行，或已安装 aks-flex-node-sudoers 中的 sudo 规则。",
+		HintDownload:    "请检查到 github.com 和 acs-mirror.azureedge.net 的出站 HTTPS 访问，包括代理配置。",
+		HintKubelet:     "请确认目标集群存在、已启用 Azure RBAC，并且节点身份可以获取集群管理员凭据。",
+		HintServices:    "使用 'journalctl -u kubelet -u containerd --no-pager -n 100' 检查失败的服务。",
+		HintNPD:         "请确认 kubelet 的 kubeconfig（/var/lib/kubelet/kubeconfig）存在且可读。",
+		HintFluentBit:   "请检查 fluentBit 配置，并使用 'journalctl -u fluent-bit --no-pager -n 100' 检查该服务。",
+		HintSSH:         "请检查 ssh 配置；'sudo sshd -T' 会显示 SSH 服务器的实际配置。之前的 sshd 配置已保留。",
+		HintRuntime:     "上面的错误由容器运行时返回；请使用 'journalctl -u containerd --no-pager -n 100'（CRI-O 使用 '-u crio'）检查，并确认可以访问 pause 镜像所在的镜像仓库。",
+		HintImagePull:   "请确认 imagePrePull.images 中的每个镜像都存在，并且节点可以访问其镜像仓库或镜像仓库镜像。",
+		HintUnbootstrap: "部分清理步骤失败；请重新运行 unbootstrap 或手动删除剩余文件。",
+		HintPreflight:   "上面每个失败的预检都说明了修复方法；此计算机上尚未进行任何更改。",
+
+		RemedyDocs:              "参见 %s",
+		RemedyPrincipalNotFound: "在重试角色分配期间，Arc 计算机的托管标识未出现在 Microsoft Entra ID 中。请将 azure.arc.verifyPrincipal 设置为 true，以区分复制延迟和错误的主体 ID，然后重新运行 bootstrap。",
+		RemedyMCRTLS:            "与 mcr.microsoft.com 的 TLS 连接失败，通常是因为代理或防火墙重新签名了 HTTPS 流量。请将其根 CA 添加到 caTrust.certificates，或将 mcr.microsoft.com 和 *.data.mcr.microsoft.com 排除在 TLS 检查之外。",
+		RemedyCgroupDriver:      "kubelet 与容器运行时使用的 cgroup 驱动不一致。请将 node.cgroup.driver 设置为运行时的驱动，或撤销对运行时配置的手动修改，然后重新运行 bootstrap。",
+		RemedyDNSLoop:           "集群 DNS 转发到节点的解析器，而该解析器又转发回集群 DNS。请确保 /etc/resolv.conf 列出真实的上游服务器，而不是存根解析器 127.0.0.53。",
+	},
+}
+
+// stepHints maps bootstrap step names to the remediation hint shown when that step fails
+var stepHints = map[string]Key{
+	"ArcInstall":                   HintArc,
+	"SystemConfigured":             HintSystem,
+	"Runc_Installer":               HintDownload,
+	"ContainerdInstaller":          HintDownload,
+	"CRIOInstaller":                HintDownload,
+	"ContainerRuntimeVerification": HintRuntime,
+	"ImagePrePull":                 HintImagePull,
+	"KubeBinariesInstaller":        HintDownload,
+	"CNISetup":                     HintDownload,
+	"KubeletInstaller":             HintKubelet,
+	"NPD_Installer":                HintNPD,
+	"FluentBit_Installer":          HintFluentBit,
+	"SSHHardening_Installer":       HintSSH,
+	"ServicesEnabled":              HintServices,
+	"PreflightChecks":              HintPreflight,
+}
+
+// HintForStep returns the localized remediation hint for a failed step
+func HintForStep(stepName string) string {
+	key, ok := stepHints[stepName]
+	if !ok {
+		key = HintGeneric
+	}
+	return Get(HintPrefix, Get(key))
+}
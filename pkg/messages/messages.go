@@ -0,0 +1,134 @@
+package messages
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Key identifies a user-facing message in the catalog
+type Key string
+
+const (
+	// DefaultLocale is used when no locale is configured or the configured locale is not supported
+	DefaultLocale = "en"
+
+	// localeEnvVar allows overriding the locale without touching the config file
+	localeEnvVar = "AKS_FLEX_NODE_LOCALE"
+)
+
+// Singleton locale selection shared by the CLI
+var (
+	currentLocale = DefaultLocale
+	localeMutex   sync.RWMutex
+)
+
+// SupportedLocales returns the locales that have a message catalog
+func SupportedLocales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for _, locale := range localeOrder {
+		if _, ok := catalogs[locale]; ok {
+			locales = append(locales, locale)
+		}
+	}
+	return locales
+}
+
+// IsSupportedLocale checks if the given locale (in any common notation) maps to a known catalog
+func IsSupportedLocale(locale string) bool {
+	_, ok := catalogs[NormalizeLocale(locale)]
+	return ok
+}
+
+// NormalizeLocale converts POSIX and BCP 47 locale names (e.g. "de_DE.UTF-8", "zh-Hans-CN")
+// into the catalog key used by this package. Unknown locales are returned in normalized form
+// so callers can report them, but Get will fall back to DefaultLocale for them.
+func NormalizeLocale(locale string) string {
+	locale = strings.TrimSpace(locale)
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return DefaultLocale
+	}
+
+	// Strip encoding and modifier suffixes: de_DE.UTF-8@euro -> de_DE
+	if idx := strings.IndexAny(locale, ".@"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+
+	// Chinese is the only language where we ship a region-specific catalog
+	if strings.HasPrefix(locale, "zh") {
+		return "zh-cn"
+	}
+
+	if idx := strings.Index(locale, "-"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	return locale
+}
+
+// DetectLocale determines the preferred locale from the environment.
+// AKS_FLEX_NODE_LOCALE takes precedence over the standard LC_ALL, LC_MESSAGES and LANG variables.
+func DetectLocale() string {
+	for _, envVar := range []string{localeEnvVar, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(envVar); value != "" {
+			return NormalizeLocale(value)
+		}
+	}
+	return DefaultLocale
+}
+
+// SetLocale sets the locale used for subsequent lookups.
+// Unsupported locales fall back to DefaultLocale and an error is returned so the caller can warn.
+func SetLocale(locale string) error {
+	normalized := NormalizeLocale(locale)
+
+	localeMutex.Lock()
+	defer localeMutex.Unlock()
+
+	if _, ok := catalogs[normalized]; !ok {
+		currentLocale = DefaultLocale
+		return fmt.Errorf("unsupported locale '%s'. Supported locales are: %s", locale, strings.Join(SupportedLocales(), ", "))
+	}
+	currentLocale = normalized
+	return nil
+}
+
+// GetLocale returns the locale currently used for lookups
+func GetLocale() string {
+	localeMutex.RLock()
+	defer localeMutex.RUnlock()
+	return currentLocale
+}
+
+// Get returns the message for key in the current locale, formatted with args.
+// Missing translations fall back to the English catalog, and unknown keys are returned verbatim.
+func Get(key Key, args ...interface{}) string {
+	return GetForLocale(GetLocale(), key, args...)
+}
+
+// GetForLocale returns the message for key in the given locale, formatted with args
+func GetForLocale(locale string, key Key, args ...interface{}) string {
+	format := lookup(locale, key)
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// lookup returns the raw catalog entry for key, falling back to English and then to the key itself
+func lookup(locale string, key Key) string {
+	if format, ok := catalogs[NormalizeLocale(locale)][key]; ok {
+		return format
+	}
+	if format, ok := catalogs[DefaultLocale][key]; ok {
+		return format
+	}
+	return string(key)
+}
+
+// Errorf creates an error whose message is looked up in the current locale.
+// Catalog entries used with Errorf may contain %w to wrap an underlying error.
+func Errorf(key Key, args ...interface{}) error {
+	return fmt.Errorf(lookup(GetLocale(), key), args...)
+}
@@ -0,0 +1,169 @@
+package messages
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "en"},
+		{"C", "en"},
+		{"POSIX", "en"},
+		{"en_US.UTF-8", "en"},
+		{"de_DE.UTF-8@euro", "de"},
+		{"es-MX", "es"},
+		{"zh_CN.UTF-8", "zh-cn"},
+		{"zh-Hans-CN", "zh-cn"},
+		{"fr_FR", "fr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeLocale(tt.input); got != tt.want {
+				t.Errorf("NormalizeLocale(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	defer func() { _ = SetLocale(DefaultLocale) }()
+
+	if err := SetLocale("de_DE.UTF-8"); err != nil {
+		t.Fatalf("SetLocale() unexpected error: %v", err)
+	}
+	if got := GetLocale(); got != "de" {
+		t.Errorf("GetLocale() = %q, want %q", got, "de")
+	}
+
+	if err := SetLocale("fr"); err == nil {
+		t.Error("SetLocale() expected error for unsupported locale")
+	}
+	if got := GetLocale(); got != DefaultLocale {
+		t.Errorf("GetLocale() after unsupported locale = %q, want %q", got, DefaultLocale)
+	}
+}
+
+func TestDetectLocale(t *testing.T) {
+	t.Setenv("AKS_FLEX_NODE_LOCALE", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	if got := DetectLocale(); got != "es" {
+		t.Errorf("DetectLocale() = %q, want %q", got, "es")
+	}
+
+	t.Setenv("AKS_FLEX_NODE_LOCALE", "zh_CN")
+	if got := DetectLocale(); got != "zh-cn" {
+		t.Errorf("DetectLocale() with override = %q, want %q", got, "zh-cn")
+	}
+}
+
+func TestGetFallback(t *testing.T) {
+	if got := GetForLocale("de", OperationFailed, "bootstrap", "boom"); got != "bootstrap fehlgeschlagen: boom" {
+		t.Errorf("GetForLocale(de) = %q", got)
+	}
+	if got := GetForLocale("fr", OperationFailed, "bootstrap", "boom"); got != "bootstrap failed: boom" {
+		t.Errorf("GetForLocale(fr) should fall back to English, got %q", got)
+	}
+	if got := Get(Key("does.not.exist")); got != "does.not.exist" {
+		t.Errorf("Get() for unknown key = %q, want key name", got)
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for _, locale := range SupportedLocales() {
+		for key := range catalogs[DefaultLocale] {
+			if _, ok := catalogs[locale][key]; !ok {
+				t.Errorf("locale %q is missing translation for %q", locale, key)
+			}
+		}
+	}
+}
+
+func TestErrorfWraps(t *testing.T) {
+	cause := errors.New("file not found")
+	err := Errorf(ConfigLoadFailed, "/etc/aks-flex-node/config.json", cause)
+	if !errors.Is(err, cause) {
+		t.Errorf("Errorf() should wrap the underlying error, got %v", err)
+	}
+}
+
+func TestHintForStep(t *testing.T) {
+	if got := HintForStep("ArcInstall"); got != GetForLocale(DefaultLocale, HintPrefix, GetForLocale(DefaultLocale, HintArc)) {
+		t.Errorf("HintForStep(ArcInstall) = %q", got)
+	}
+	if got := HintForStep("UnknownStep"); got != Get(HintPrefix, Get(HintGeneric)) {
+		t.Errorf("HintForStep(UnknownStep) = %q", got)
+	}
+}
+
+func TestKnownIssue(t *testing.T) {
+	tests := []struct {
+		name    string
+		errText string
+		want    string
+	}{
+		{
+			name:    "principal not found after retries",
+			errText: "failed to assign role after 5 attempts due to Azure AD replication delay - principal not found: PrincipalNotFound",
+			want:    "principal-not-found",
+		},
+		{
+			name:    "unknown authority from mcr",
+			errText: `CRI PullImage mcr.microsoft.com/oss/kubernetes/pause:3.6 failed: rpc error: code = Unknown desc = failed to do request: Head "https://mcr.microsoft.com/v2/oss/kubernetes/pause/manifests/3.6": tls: failed to verify certificate: x509: certificate signed by unknown authority`,
+			want:    "mcr-tls",
+		},
+		{
+			name:    "unknown authority from another registry",
+			errText: "x509: certificate signed by unknown authority for registry.example.com",
+			want:    "",
+		},
+		{
+			name:    "mcr unreachable without tls error",
+			errText: "dial tcp: lookup mcr.microsoft.com: no such host",
+			want:    "",
+		},
+		{
+			name:    "containerd systemd cgroups with cgroupfs kubelet",
+			errText: `RunPodSandbox failed: expected cgroupsPath to be of format "slice:prefix:name" for systemd cgroups, got "/kubepods/besteffort/pod1"`,
+			want:    "cgroup-driver",
+		},
+		{
+			name:    "coredns loop",
+			errText: "canary pod failed: [FATAL] plugin/loop: Loop (127.0.0.1:55953 -> :53) detected for zone \".\"",
+			want:    "dns-loop",
+		},
+		{
+			name:    "unrelated failure",
+			errText: "failed to download kubelet: unexpected status 404",
+			want:    "",
+		},
+		{
+			name: "no error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KnownIssue(tt.errText); got != tt.want {
+				t.Errorf("KnownIssue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHintForFailure(t *testing.T) {
+	got := HintForFailure("KubeletInstaller", "expected cgroupsPath to be of format \"slice:prefix:name\"")
+	if !strings.Contains(got, Get(RemedyCgroupDriver)) || !strings.Contains(got, docsURL+"#cgroup-driver-mismatch") {
+		t.Errorf("HintForFailure() = %q, want the cgroup driver remedy with its link", got)
+	}
+	if got := HintForFailure("KubeletInstaller", "something else"); got != HintForStep("KubeletInstaller") {
+		t.Errorf("HintForFailure() = %q, want the step hint", got)
+	}
+}
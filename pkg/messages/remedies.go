@@ -0,0 +1,72 @@
+package messages
+
+import "regexp"
+
+// docsURL is the page the remedies of known issues link to
+const docsURL = "https://github.com/Azure/AKSFlexNode/blob/main/docs/usage.md"
+
+// knownIssue is a failure with a specific fix, recognized by a signature in the error message
+type knownIssue struct {
+	id        string
+	signature *regexp.Regexp
+	remedy    Key
+	anchor    string // Section of docsURL describing the issue
+}
+
+// knownIssues are checked in order; the first matching signature wins
+var knownIssues = []knownIssue{
+	{
+		id:        "principal-not-found",
+		signature: regexp.MustCompile(`(?i)PrincipalNotFound|principal not found`),
+		remedy:    RemedyPrincipalNotFound,
+		anchor:    "principalnotfound-after-retries",
+	},
+	{
+		id:        "mcr-tls",
+		signature: regexp.MustCompile(`(?is)mcr\.microsoft\.com.*(x509|tls|certificate)|(x509|tls).*mcr\.microsoft\.com`),
+		remedy:    RemedyMCRTLS,
+		anchor:    "tls-errors-from-mcr",
+	},
+	{
+		id:        "cgroup-driver",
+		signature: regexp.MustCompile(`(?i)cgroup driver|cgroupsPath`),
+		remedy:    RemedyCgroupDriver,
+		anchor:    "cgroup-driver-mismatch",
+	},
+	{
+		id:        "dns-loop",
+		signature: regexp.MustCompile(`(?i)loop \([^)]*\) detected for zone|plugin/loop`),
+		remedy:    RemedyDNSLoop,
+		anchor:    "dns-loop",
+	},
+}
+
+// KnownIssue returns the ID of the known issue whose signature appears in errText, or "" if there is none
+func KnownIssue(errText string) string {
+	if issue := findKnownIssue(errText); issue != nil {
+		return issue.id
+	}
+	return ""
+}
+
+// HintForFailure returns the localized hint for a step that failed with errText. The remedy of a known
+// issue, with a link to its documentation, takes precedence over the generic hint of the step.
+func HintForFailure(stepName, errText string) string {
+	issue := findKnownIssue(errText)
+	if issue == nil {
+		return HintForStep(stepName)
+	}
+	return Get(HintPrefix, Get(issue.remedy)+" "+Get(RemedyDocs, docsURL+"#"+issue.anchor))
+}
+
+func findKnownIssue(errText string) *knownIssue {
+	if errText == "" {
+		return nil
+	}
+	for i := range knownIssues {
+		if knownIssues[i].signature.MatchString(errText) {
+			return &knownIssues[i]
+		}
+	}
+	return nil
+}
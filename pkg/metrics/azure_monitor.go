@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const (
+	// monitoringScope is the token scope of the Azure Monitor custom metrics API
+	monitoringScope = "https://monitoring.azure.com/.default"
+
+	// Namespace and names of the custom metrics emitted for NPD problems
+	customMetricNamespace     = "AKSFlexNode/NodeProblemDetector"
+	problemCountMetricName    = "ProblemCount"
+	problemActiveMetricName   = "ProblemActive"
+	customMetricNodeDimension = "Node"
+)
+
+// customMetric is the Azure Monitor custom metrics API payload; it carries a single metric
+type customMetric struct {
+	Time string           `json:"time"`
+	Data customMetricData `json:"data"`
+}
+
+type customMetricData struct {
+	BaseData customMetricBaseData `json:"baseData"`
+}
+
+type customMetricBaseData struct {
+	Metric    string               `json:"metric"`
+	Namespace string               `json:"namespace"`
+	DimNames  []string             `json:"dimNames"`
+	Series    []customMetricSeries `json:"series"`
+}
+
+type customMetricSeries struct {
+	DimValues []string `json:"dimValues"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int      `json:"count"`
+}
+
+// azureMonitorSink emits NPD problems as Azure Monitor custom metrics of an Azure resource.
+// The identity needs the Monitoring Metrics Publisher role on that resource.
+type azureMonitorSink struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+// newAzureMonitorSink creates a sink emitting custom metrics for the resource in the given region
+func newAzureMonitorSink(cred azcore.TokenCredential, region, resourceID string, options *policy.ClientOptions) (*azureMonitorSink, error) {
+	client, err := azcore.NewClient("metrics.azureMonitorSink", "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{monitoringScope}, nil)},
+	}, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Monitor client: %w", err)
+	}
+	return &azureMonitorSink{pipeline: client.Pipeline(), endpoint: customMetricsEndpoint(region, resourceID)}, nil
+}
+
+// customMetricsEndpoint returns the regional custom metrics endpoint of a resource
+func customMetricsEndpoint(region, resourceID string) string {
+	region = strings.ToLower(strings.ReplaceAll(region, " ", ""))
+	return fmt.Sprintf("https://%s.monitoring.azure.com/%s/metrics", region, strings.Trim(resourceID, "/"))
+}
+
+// Send emits the counter growth since the last export as ProblemCount and active permanent problems as ProblemActive
+func (s *azureMonitorSink) Send(ctx context.Context, b batch) error {
+	for _, metric := range customMetrics(b) {
+		if err := s.post(ctx, metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *azureMonitorSink) post(ctx context.Context, metric customMetric) error {
+	req, err := runtime.NewRequest(ctx, http.MethodPost, s.endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to build Azure Monitor request: %w", err)
+	}
+	if err := runtime.MarshalAsJSON(req, metric); err != nil {
+		return fmt.Errorf("failed to encode %s metric: %w", metric.Data.BaseData.Metric, err)
+	}
+
+	resp, err := s.pipeline.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s metric to Azure Monitor: %w", metric.Data.BaseData.Metric, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return runtime.NewResponseError(resp)
+	}
+	return nil
+}
+
+// customMetrics converts a batch into custom metric payloads, leaving out metrics without series
+func customMetrics(b batch) []customMetric {
+	count := customMetricBaseData{
+		Metric:    problemCountMetricName,
+		Namespace: customMetricNamespace,
+		DimNames:  []string{customMetricNodeDimension, "Reason"},
+	}
+	for _, p := range b.Deltas {
+		count.Series = append(count.Series, series([]string{b.Node, p.Reason}, p.Value))
+	}
+
+	active := customMetricBaseData{
+		Metric:    problemActiveMetricName,
+		Namespace: customMetricNamespace,
+		DimNames:  []string{customMetricNodeDimension, "Type", "Reason"},
+	}
+	for _, p := range b.Problems {
+		if p.Metric == problemGaugeMetric && p.Value > 0 {
+			active.Series = append(active.Series, series([]string{b.Node, p.Type, p.Reason}, p.Value))
+		}
+	}
+
+	var metrics []customMetric
+	for _, data := range []customMetricBaseData{count, active} {
+		if len(data.Series) == 0 {
+			continue
+		}
+		metrics = append(metrics, customMetric{
+			Time: b.Time.UTC().Format(time.RFC3339),
+			Data: customMetricData{BaseData: data},
+		})
+	}
+	return metrics
+}
+
+// series returns a series holding a single value
+func series(dimValues []string, value float64) customMetricSeries {
+	return customMetricSeries{DimValues: dimValues, Min: value, Max: value, Sum: value, Count: 1}
+}
+
+// String returns a human readable description of the sink
+func (s *azureMonitorSink) String() string {
+	return "Azure Monitor " + s.endpoint
+}
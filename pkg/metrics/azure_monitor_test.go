@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCustomMetricsEndpoint(t *testing.T) {
+	got := customMetricsEndpoint("West US 2", "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/node1")
+	want := "https://westus2.monitoring.azure.com/subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/node1/metrics"
+	if got != want {
+		t.Errorf("customMetricsEndpoint() = %s, want %s", got, want)
+	}
+}
+
+func TestCustomMetrics(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("nothing to report", func(t *testing.T) {
+		metrics := customMetrics(batch{Node: "node1", Time: at, Problems: []problem{
+			{Metric: problemCounterMetric, Reason: "OOMKilling", Value: 3},
+			{Metric: problemGaugeMetric, Type: "KernelDeadlock", Reason: "DockerHung", Value: 0},
+		}})
+		if len(metrics) != 0 {
+			t.Errorf("customMetrics() = %+v, want none", metrics)
+		}
+	})
+
+	t.Run("counter increase and active problem", func(t *testing.T) {
+		metrics := customMetrics(batch{
+			Node: "node1",
+			Time: at,
+			Problems: []problem{
+				{Metric: problemGaugeMetric, Type: "RAIDProblem", Reason: "RAIDDegraded", Value: 1},
+			},
+			Deltas: []problem{{Metric: problemCounterMetric, Reason: "OOMKilling", Value: 2}},
+		})
+		if len(metrics) != 2 {
+			t.Fatalf("customMetrics() = %+v, want ProblemCount and ProblemActive", metrics)
+		}
+
+		count := metrics[0].Data.BaseData
+		if metrics[0].Time != "2026-01-02T03:04:05Z" || count.Metric != problemCountMetricName || count.Namespace != customMetricNamespace {
+			t.Errorf("first metric = %+v, want ProblemCount at 2026-01-02T03:04:05Z", metrics[0])
+		}
+		wantCount := []customMetricSeries{{DimValues: []string{"node1", "OOMKilling"}, Min: 2, Max: 2, Sum: 2, Count: 1}}
+		if !reflect.DeepEqual(count.Series, wantCount) {
+			t.Errorf("ProblemCount series = %+v, want %+v", count.Series, wantCount)
+		}
+
+		active := metrics[1].Data.BaseData
+		if active.Metric != problemActiveMetricName || !reflect.DeepEqual(active.DimNames, []string{"Node", "Type", "Reason"}) {
+			t.Errorf("second metric = %+v, want ProblemActive by node, type and reason", active)
+		}
+		if len(active.Series) != 1 || !reflect.DeepEqual(active.Series[0].DimValues, []string{"node1", "RAIDProblem", "RAIDDegraded"}) {
+			t.Errorf("ProblemActive series = %+v, want the RAIDProblem series", active.Series)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+// Package metrics forwards the problem metrics Node Problem Detector (NPD) exposes on the node to a
+// central metrics sink, so fleet-wide problem rates are visible without scraping every node.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	defaultExportInterval = time.Minute
+	httpTimeout           = 30 * time.Second
+)
+
+// batch holds the problems of one scrape
+type batch struct {
+	Node     string
+	Time     time.Time
+	Problems []problem // Current value of every problem series
+	Deltas   []problem // Growth of the problem counters since the previous export
+}
+
+// sink is a destination for NPD problem metrics
+type sink interface {
+	Send(ctx context.Context, b batch) error
+	String() string
+}
+
+// Exporter periodically scrapes NPD and forwards its problem metrics to the configured sink
+type Exporter struct {
+	logger   *logrus.Logger
+	sink     sink
+	nodeName string
+	interval time.Duration
+	scrape   func(ctx context.Context) (string, error)
+
+	// Counter values of the previous export; nil until the first scrape records a baseline
+	lastCounters map[string]float64
+}
+
+// NewExporter creates an exporter for the sink configured in npd.metrics
+func NewExporter(cfg *config.Config, logger *logrus.Logger) (*Exporter, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	client := &http.Client{Timeout: httpTimeout}
+
+	s, err := newSink(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := defaultExportInterval
+	if cfg.Npd.Metrics.Interval != "" {
+		// Validated at config load
+		interval, _ = time.ParseDuration(cfg.Npd.Metrics.Interval)
+	}
+
+	return &Exporter{
+		logger:   logger,
+		sink:     s,
+		nodeName: strings.ToLower(hostname),
+		interval: interval,
+		scrape: func(ctx context.Context) (string, error) {
+			return scrapeNPD(ctx, client)
+		},
+	}, nil
+}
+
+// newSink creates the sink selected by npd.metrics.sink
+func newSink(cfg *config.Config, client *http.Client) (sink, error) {
+	metrics := cfg.Npd.Metrics
+	switch metrics.Sink {
+	case "azure-monitor":
+		resourceID, region := metrics.ResourceID, metrics.Region
+		if resourceID == "" {
+			resourceID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.HybridCompute/machines/%s",
+				cfg.GetSubscriptionID(), cfg.GetArcResourceGroup(), cfg.GetArcMachineName())
+		}
+		if region == "" {
+			region = cfg.GetArcLocation()
+		}
+
+		// Emit as the Arc machine identity when Arc is enabled, otherwise as the configured user identity
+		provider := auth.NewAuthProvider()
+		cred, err := provider.UserCredential(cfg)
+		if cfg.IsARCEnabled() {
+			cred, err = provider.ArcCredential()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credential for Azure Monitor: %w", err)
+		}
+		clientOptions := auth.ClientOptions(cfg)
+		return newAzureMonitorSink(cred, region, resourceID, &clientOptions)
+	case "prometheus-remote-write":
+		return &remoteWriteSink{client: client, url: metrics.RemoteWriteURL, bearerTokenFile: metrics.BearerTokenFile}, nil
+	default:
+		return nil, fmt.Errorf("unsupported NPD metrics sink: %q", metrics.Sink)
+	}
+}
+
+// Interval returns how often Export should be called
+func (e *Exporter) Interval() time.Duration {
+	return e.interval
+}
+
+// Sink returns a human readable description of where metrics are sent
+func (e *Exporter) Sink() string {
+	return e.sink.String()
+}
+
+// Export scrapes NPD once and forwards the problems to the sink
+func (e *Exporter) Export(ctx context.Context) error {
+	exposition, err := e.scrape(ctx)
+	if err != nil {
+		return err
+	}
+	problems, err := parseProblems(exposition)
+	if err != nil {
+		return fmt.Errorf("failed to parse NPD metrics: %w", err)
+	}
+
+	deltas, counters := counterDeltas(e.lastCounters, problems)
+	b := batch{Node: e.nodeName, Time: time.Now(), Problems: problems, Deltas: deltas}
+	if err := e.sink.Send(ctx, b); err != nil {
+		// Keep the previous baseline so the growth is reported with the next successful export
+		return err
+	}
+	e.lastCounters = counters
+	e.logger.Debugf("Exported %d NPD problem series (%d counter increases) to %s", len(problems), len(deltas), e.sink)
+	return nil
+}
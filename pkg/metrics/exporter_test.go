@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type fakeSink struct {
+	batches []batch
+	err     error
+}
+
+func (f *fakeSink) Send(ctx context.Context, b batch) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, b)
+	return nil
+}
+
+func (f *fakeSink) String() string { return "fake" }
+
+func TestExporterExport(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	exposition := `problem_counter{reason="OOMKilling"} 3`
+	sink := &fakeSink{}
+	exporter := &Exporter{
+		logger:   logger,
+		sink:     sink,
+		nodeName: "node1",
+		scrape:   func(ctx context.Context) (string, error) { return exposition, nil },
+	}
+
+	if err := exporter.Export(context.Background()); err != nil {
+		t.Fatalf("first Export() unexpected error: %v", err)
+	}
+	if len(sink.batches) != 1 || len(sink.batches[0].Deltas) != 0 || len(sink.batches[0].Problems) != 1 {
+		t.Fatalf("first batch = %+v, want the problem without deltas", sink.batches)
+	}
+
+	// A failed send keeps the baseline, so the increase is reported by the next export
+	exposition = `problem_counter{reason="OOMKilling"} 4`
+	sink.err = errors.New("unavailable")
+	if err := exporter.Export(context.Background()); err == nil {
+		t.Fatal("Export() expected error from the sink")
+	}
+
+	exposition = `problem_counter{reason="OOMKilling"} 6`
+	sink.err = nil
+	if err := exporter.Export(context.Background()); err != nil {
+		t.Fatalf("Export() unexpected error: %v", err)
+	}
+	last := sink.batches[len(sink.batches)-1]
+	if len(last.Deltas) != 1 || last.Deltas[0].Value != 3 || last.Node != "node1" {
+		t.Errorf("last batch = %+v, want an increase of 3 on node1", last)
+	}
+
+	// Scrape failures are returned
+	exporter.scrape = func(ctx context.Context) (string, error) { return "", errors.New("connection refused") }
+	if err := exporter.Export(context.Background()); err == nil {
+		t.Error("Export() expected scrape error")
+	}
+}
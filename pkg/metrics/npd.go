@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// npdMetricsURL is where NPD exposes its Prometheus metrics by default
+	npdMetricsURL = "http://127.0.0.1:20257/metrics"
+
+	// Problem metrics exposed by NPD
+	problemCounterMetric = "problem_counter" // Number of times a temporary problem occurred, labeled by reason
+	problemGaugeMetric   = "problem_gauge"   // 1 while a permanent problem is present, labeled by condition type and reason
+)
+
+// problem is a single NPD problem series
+type problem struct {
+	Metric string
+	Type   string // Condition type, set for problem_gauge only
+	Reason string
+	Value  float64
+}
+
+// key identifies the series of a problem across scrapes
+func (p problem) key() string {
+	return p.Metric + "|" + p.Type + "|" + p.Reason
+}
+
+// scrapeNPD fetches the Prometheus text exposition of the local NPD
+func scrapeNPD(ctx context.Context, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, npdMetricsURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create NPD metrics request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to scrape NPD metrics: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to scrape NPD metrics: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read NPD metrics: %w", err)
+	}
+	return string(body), nil
+}
+
+// parseProblems extracts the problem series from a Prometheus text exposition, ignoring all other metrics
+func parseProblems(exposition string) ([]problem, error) {
+	var problems []problem
+	for lineNumber, line := range strings.Split(exposition, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, value, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber+1, err)
+		}
+		if name != problemCounterMetric && name != problemGaugeMetric {
+			continue
+		}
+		problems = append(problems, problem{
+			Metric: name,
+			Type:   labels["type"],
+			Reason: labels["reason"],
+			Value:  value,
+		})
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].key() < problems[j].key() })
+	return problems, nil
+}
+
+// parseSample parses one sample line such as `problem_counter{reason="OOMKilling"} 3 [timestamp]`
+func parseSample(line string) (string, map[string]string, float64, error) {
+	labels := map[string]string{}
+	name, rest := line, ""
+	if open := strings.IndexAny(line, "{ "); open >= 0 {
+		name, rest = line[:open], line[open:]
+	}
+	if strings.HasPrefix(rest, "{") {
+		parsed, remainder, err := parseLabels(rest[1:])
+		if err != nil {
+			return "", nil, 0, err
+		}
+		labels, rest = parsed, remainder
+	}
+
+	fields := strings.Fields(rest)
+	if name == "" || len(fields) == 0 || len(fields) > 2 {
+		return "", nil, 0, fmt.Errorf("malformed sample %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("malformed value in sample %q: %w", line, err)
+	}
+	return name, labels, value, nil
+}
+
+// parseLabels parses `name="value",...}` and returns the labels and the text after the closing brace
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+		eq := strings.Index(s, "=\"")
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("malformed labels")
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				s = s[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated value of label %s", name)
+		}
+		labels[name] = value.String()
+	}
+}
+
+// counterDeltas returns how much each problem counter grew since the previous scrape, and the counter
+// values to compare the next scrape with. The first scrape only records a baseline, so a restarted
+// agent does not report problems that happened before it started. A counter that went down was reset
+// by an NPD restart and counts from zero.
+func counterDeltas(previous map[string]float64, problems []problem) ([]problem, map[string]float64) {
+	next := make(map[string]float64)
+	var deltas []problem
+	for _, p := range problems {
+		if p.Metric != problemCounterMetric {
+			continue
+		}
+		next[p.key()] = p.Value
+		if previous == nil {
+			continue
+		}
+
+		delta := p.Value
+		if last, ok := previous[p.key()]; ok && p.Value >= last {
+			delta = p.Value - last
+		}
+		if delta > 0 {
+			p.Value = delta
+			deltas = append(deltas, p)
+		}
+	}
+	return deltas, next
+}
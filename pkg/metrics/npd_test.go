@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+const npdExposition = `# HELP problem_counter Number of times a specific type of problem have occurred.
+# TYPE problem_counter counter
+problem_counter{reason="OOMKilling"} 3
+problem_counter{reason="TaskHung"} 0
+# HELP problem_gauge Whether a specific type of problem is affecting the node or not.
+# TYPE problem_gauge gauge
+problem_gauge{reason="RAIDDegraded",type="RAIDProblem"} 1
+problem_gauge{reason="DockerHung",type="KernelDeadlock"} 0
+# TYPE go_goroutines gauge
+go_goroutines 12
+host_uptime{kernel_version="6.8.0",os_version="ubuntu \"24.04\""} 1.2e+04 1700000000000
+`
+
+func TestParseProblems(t *testing.T) {
+	problems, err := parseProblems(npdExposition)
+	if err != nil {
+		t.Fatalf("parseProblems() unexpected error: %v", err)
+	}
+	want := []problem{
+		{Metric: problemCounterMetric, Reason: "OOMKilling", Value: 3},
+		{Metric: problemCounterMetric, Reason: "TaskHung", Value: 0},
+		{Metric: problemGaugeMetric, Type: "KernelDeadlock", Reason: "DockerHung", Value: 0},
+		{Metric: problemGaugeMetric, Type: "RAIDProblem", Reason: "RAIDDegraded", Value: 1},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("parseProblems() = %+v, want %+v", problems, want)
+	}
+
+	for _, malformed := range []string{
+		`problem_counter{reason="OOMKilling} 3`,
+		`problem_counter{reason="OOMKilling"} three`,
+		`problem_counter{reason="OOMKilling"}`,
+	} {
+		if _, err := parseProblems(malformed); err == nil {
+			t.Errorf("parseProblems(%q) expected error", malformed)
+		}
+	}
+}
+
+func TestCounterDeltas(t *testing.T) {
+	counter := func(reason string, value float64) problem {
+		return problem{Metric: problemCounterMetric, Reason: reason, Value: value}
+	}
+	gauge := problem{Metric: problemGaugeMetric, Type: "RAIDProblem", Reason: "RAIDDegraded", Value: 1}
+
+	// The first scrape only records a baseline
+	deltas, baseline := counterDeltas(nil, []problem{counter("OOMKilling", 3), gauge})
+	if len(deltas) != 0 {
+		t.Errorf("first counterDeltas() = %+v, want no deltas", deltas)
+	}
+	if len(baseline) != 1 {
+		t.Errorf("baseline = %v, want only the counter", baseline)
+	}
+
+	// Growth, an unchanged counter, a new counter and a reset counter
+	previous := map[string]float64{
+		counter("OOMKilling", 0).key(): 3,
+		counter("TaskHung", 0).key():   1,
+		counter("Reset", 0).key():      10,
+	}
+	deltas, next := counterDeltas(previous, []problem{
+		counter("OOMKilling", 5),
+		counter("TaskHung", 1),
+		counter("NewProblem", 2),
+		counter("Reset", 4),
+	})
+	want := []problem{counter("OOMKilling", 2), counter("NewProblem", 2), counter("Reset", 4)}
+	if !reflect.DeepEqual(deltas, want) {
+		t.Errorf("counterDeltas() = %+v, want %+v", deltas, want)
+	}
+	if next[counter("Reset", 0).key()] != 4 {
+		t.Errorf("next baseline = %v, want the reset counter at 4", next)
+	}
+}
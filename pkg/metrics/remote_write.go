@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// remoteWriteSink pushes NPD problems to a Prometheus remote write receiver.
+// Counters are sent as cumulative values so the receiver computes rates with rate() as usual.
+type remoteWriteSink struct {
+	client          *http.Client
+	url             string
+	bearerTokenFile string
+}
+
+// remoteWriteLabel is a Prometheus label
+type remoteWriteLabel struct {
+	name  string
+	value string
+}
+
+// remoteWriteSeries is a Prometheus time series with a single sample
+type remoteWriteSeries struct {
+	labels      []remoteWriteLabel
+	value       float64
+	timestampMs int64
+}
+
+// Send pushes the current value of every problem series
+func (s *remoteWriteSink) Send(ctx context.Context, b batch) error {
+	if len(b.Problems) == 0 {
+		return nil
+	}
+	body := snappyEncode(encodeWriteRequest(remoteWriteSeriesFor(b)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create remote write request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.bearerTokenFile != "" {
+		token, err := os.ReadFile(s.bearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read remote write bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics to %s: %w", s.url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write to %s failed: %s: %s", s.url, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// String returns a human readable description of the sink
+func (s *remoteWriteSink) String() string {
+	return "Prometheus remote write " + s.url
+}
+
+// remoteWriteSeriesFor converts the problems of a batch into time series labeled with the node name
+func remoteWriteSeriesFor(b batch) []remoteWriteSeries {
+	series := make([]remoteWriteSeries, 0, len(b.Problems))
+	for _, p := range b.Problems {
+		labels := []remoteWriteLabel{
+			{"__name__", "npd_" + p.Metric},
+			{"node", b.Node},
+			{"reason", p.Reason},
+		}
+		if p.Type != "" {
+			labels = append(labels, remoteWriteLabel{"type", p.Type})
+		}
+		// Receivers require labels sorted by name
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+		series = append(series, remoteWriteSeries{labels: labels, value: p.Value, timestampMs: b.Time.UnixMilli()})
+	}
+	return series
+}
+
+// encodeWriteRequest encodes a prometheus.WriteRequest protobuf message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []remoteWriteSeries) []byte {
+	var request []byte
+	for _, s := range series {
+		var ts []byte
+		for _, label := range s.labels {
+			var l []byte
+			l = appendBytesField(l, 1, []byte(label.name))
+			l = appendBytesField(l, 2, []byte(label.value))
+			ts = appendBytesField(ts, 1, l)
+		}
+		var sample []byte
+		sample = binary.AppendUvarint(sample, 1<<3|1) // field 1, 64-bit
+		sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(s.value))
+		sample = binary.AppendUvarint(sample, 2<<3|0) // field 2, varint
+		sample = binary.AppendUvarint(sample, uint64(s.timestampMs))
+		ts = appendBytesField(ts, 2, sample)
+
+		request = appendBytesField(request, 1, ts)
+	}
+	return request
+}
+
+// appendBytesField appends a length-delimited protobuf field
+func appendBytesField(buf []byte, field uint64, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, field<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// snappyEncode encodes data in the snappy block format required by remote write.
+// The payloads are small, so the data is stored as literals without compression,
+// which every snappy decoder accepts.
+func snappyEncode(data []byte) []byte {
+	const maxLiteral = 1 << 16
+	out := binary.AppendUvarint(nil, uint64(len(data)))
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > maxLiteral {
+			chunk = chunk[:maxLiteral]
+		}
+		n := len(chunk) - 1
+		if n < 60 {
+			out = append(out, byte(n)<<2)
+		} else {
+			// Tag 61 is followed by the literal length minus one as 2 little-endian bytes
+			out = append(out, 61<<2, byte(n), byte(n>>8))
+		}
+		out = append(out, chunk...)
+		data = data[len(chunk):]
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRemoteWriteSeriesFor(t *testing.T) {
+	at := time.UnixMilli(1700000000123)
+	series := remoteWriteSeriesFor(batch{Node: "node1", Time: at, Problems: []problem{
+		{Metric: problemGaugeMetric, Type: "RAIDProblem", Reason: "RAIDDegraded", Value: 1},
+	}})
+	if len(series) != 1 {
+		t.Fatalf("remoteWriteSeriesFor() = %+v, want one series", series)
+	}
+	want := []remoteWriteLabel{
+		{"__name__", "npd_problem_gauge"},
+		{"node", "node1"},
+		{"reason", "RAIDDegraded"},
+		{"type", "RAIDProblem"},
+	}
+	for i, label := range want {
+		if series[0].labels[i] != label {
+			t.Errorf("label %d = %+v, want %+v", i, series[0].labels[i], label)
+		}
+	}
+	if series[0].value != 1 || series[0].timestampMs != 1700000000123 {
+		t.Errorf("sample = %v@%d, want 1@1700000000123", series[0].value, series[0].timestampMs)
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	got := encodeWriteRequest([]remoteWriteSeries{{
+		labels:      []remoteWriteLabel{{"a", "b"}},
+		value:       2,
+		timestampMs: 5,
+	}})
+
+	// Label{name:"a", value:"b"}
+	label := []byte{0x0a, 0x01, 'a', 0x12, 0x01, 'b'}
+	// Sample{value:2, timestamp:5}
+	sample := []byte{0x09}
+	sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(2))
+	sample = append(sample, 0x10, 0x05)
+	// TimeSeries{labels:[label], samples:[sample]}
+	ts := append([]byte{0x0a, byte(len(label))}, label...)
+	ts = append(ts, 0x12, byte(len(sample)))
+	ts = append(ts, sample...)
+	// WriteRequest{timeseries:[ts]}
+	want := append([]byte{0x0a, byte(len(ts))}, ts...)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeWriteRequest() = %x, want %x", got, want)
+	}
+}
+
+// snappyDecodeLiterals decodes snappy blocks made only of literals, as produced by snappyEncode
+func snappyDecodeLiterals(t *testing.T, block []byte) []byte {
+	t.Helper()
+	length, n := binary.Uvarint(block)
+	block = block[n:]
+	var out []byte
+	for len(block) > 0 {
+		tag := block[0]
+		if tag&0x03 != 0 {
+			t.Fatalf("unexpected non-literal tag %x", tag)
+		}
+		size := int(tag>>2) + 1
+		block = block[1:]
+		if tag>>2 == 61 {
+			size = int(block[0]) | int(block[1])<<8 + 1
+			block = block[2:]
+		}
+		out = append(out, block[:size]...)
+		block = block[size:]
+	}
+	if uint64(len(out)) != length {
+		t.Fatalf("decoded %d bytes, header says %d", len(out), length)
+	}
+	return out
+}
+
+func TestSnappyEncode(t *testing.T) {
+	for _, size := range []int{0, 1, 60, 61, 1 << 16, 1<<16 + 100} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		if got := snappyDecodeLiterals(t, snappyEncode(data)); !bytes.Equal(got, data) {
+			t.Errorf("snappy round trip of %d bytes returned %d bytes", size, len(got))
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package nodecert
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/keyvault"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	defaultCAValidityDays         = 365
+	defaultKeyVaultValidityMonths = 12
+
+	// clientAuthOID is the extended key usage requested from Key Vault
+	clientAuthOID = "1.3.6.1.5.5.7.3.2"
+)
+
+// caIssuer signs certificates on the node with an internal CA's certificate and key
+type caIssuer struct {
+	caCert   *x509.Certificate
+	caKey    crypto.Signer
+	certFile string
+	validity time.Duration
+	now      func() time.Time
+}
+
+func newCAIssuer(ca *config.NodeCertificateCAConfig) (*caIssuer, error) {
+	certPEM, err := os.ReadFile(ca.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA certificate: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s holds no PEM certificate", ca.CertFile)
+	}
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the CA certificate: %w", err)
+	}
+	if !caCert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", ca.CertFile)
+	}
+
+	keyPEM, err := os.ReadFile(ca.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA key: %w", err)
+	}
+	caKey, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ca.KeyFile, err)
+	}
+
+	days := ca.ValidityDays
+	if days == 0 {
+		days = defaultCAValidityDays
+	}
+	return &caIssuer{
+		caCert:   caCert,
+		caKey:    caKey,
+		certFile: ca.CertFile,
+		validity: time.Duration(days) * 24 * time.Hour,
+		now:      time.Now,
+	}, nil
+}
+
+func (c *caIssuer) String() string {
+	return "the CA in " + c.certFile
+}
+
+// Issue generates an ECDSA P-256 key and signs a client certificate for it, valid no longer than the CA
+func (c *caIssuer) Issue(_ context.Context, subject pkix.Name) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate a key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate a serial number: %w", err)
+	}
+
+	now := c.now()
+	notAfter := now.Add(c.validity)
+	if notAfter.After(c.caCert.NotAfter) {
+		notAfter = c.caCert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		// Allow for clock skew between the node and the API server
+		NotBefore:   now.Add(-5 * time.Minute),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.caCert, key.Public(), c.caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign the certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode the key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// parsePrivateKey parses a PEM PKCS#8, PKCS#1 or SEC 1 private key that can sign
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key format %s", block.Type)
+}
+
+// keyVaultIssuer issues certificates through a certificate issuer registered in a Key Vault. Key Vault creates
+// the key, and the certificate with its key is read back from the secret of the same name.
+type keyVaultIssuer struct {
+	certificates *keyvault.CertificateClient
+	secrets      *keyvault.SecretClient
+	cfg          *config.Config
+	kv           *config.NodeCertificateKeyVaultConfig
+}
+
+func newKeyVaultIssuer(cfg *config.Config) (*keyVaultIssuer, error) {
+	kv := cfg.Azure.NodeCertificate.KeyVault
+	cred, err := auth.NewAuthProvider().NodeCertificateCredential(cfg)
+	if err != nil {
+		return nil, err
+	}
+	options := auth.ClientOptions(cfg)
+	certificates, err := keyvault.NewCertificateClient(kv.VaultURL, cred, &options)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := keyvault.NewSecretClient(kv.VaultURL, cred, &options)
+	if err != nil {
+		return nil, err
+	}
+	return &keyVaultIssuer{certificates: certificates, secrets: secrets, cfg: cfg, kv: kv}, nil
+}
+
+func (k *keyVaultIssuer) String() string {
+	return fmt.Sprintf("issuer %s in %s", k.kv.IssuerName, k.kv.VaultURL)
+}
+
+// CertificateName returns the Key Vault certificate name for nodeName, aks-flex-node-<node name> by default
+func CertificateName(kv *config.NodeCertificateKeyVaultConfig, nodeName string) string {
+	if kv.CertificateName != "" {
+		return kv.CertificateName
+	}
+	name := "aks-flex-node-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, nodeName)
+	if len(name) > 127 {
+		name = name[:127]
+	}
+	return name
+}
+
+// Issue creates a new version of the node's certificate and reads it back with its key
+func (k *keyVaultIssuer) Issue(ctx context.Context, subject pkix.Name) ([]byte, []byte, error) {
+	name := CertificateName(k.kv, strings.TrimPrefix(subject.CommonName, "system:node:"))
+	months := k.kv.ValidityMonths
+	if months == 0 {
+		months = defaultKeyVaultValidityMonths
+	}
+
+	// Issuing may wait for a CA outside Azure, so it is given more time than a single request
+	createCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	err := k.certificates.CreateCertificate(createCtx, name, keyvault.CertificateRequest{
+		Subject:           fmt.Sprintf("CN=%s, O=%s", subject.CommonName, strings.Join(subject.Organization, ", O=")),
+		IssuerName:        k.kv.IssuerName,
+		ValidityMonths:    months,
+		ExtendedKeyUsages: []string{clientAuthOID},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opCtx, cancel := auth.OperationContext(ctx, k.cfg)
+	defer cancel()
+	secret, err := k.secrets.GetSecret(opCtx, name, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate %s with its key: %w", name, err)
+	}
+	if secret.ContentType != "application/x-pem-file" {
+		return nil, nil, fmt.Errorf("certificate %s is stored as %s, expected application/x-pem-file", name, secret.ContentType)
+	}
+	return splitPEM([]byte(secret.Value))
+}
+
+// splitPEM separates the certificates of a PEM bundle from its private key
+func splitPEM(data []byte) ([]byte, []byte, error) {
+	var certs, key []byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == "CERTIFICATE":
+			certs = append(certs, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY") && key == nil:
+			key = pem.EncodeToMemory(block)
+		}
+	}
+	if certs == nil || key == nil {
+		return nil, nil, fmt.Errorf("expected a PEM certificate and private key")
+	}
+	return certs, key, nil
+}
@@ -0,0 +1,184 @@
+// Package nodecert issues the kubelet's client certificate for system:node:<hostname> from Azure Key Vault or an
+// internal CA, for clusters that do not allow token-based TLS bootstrap. The certificate and its key are kept in
+// one PEM file the kubelet kubeconfig refers to, and renewed by the agent before they expire.
+package nodecert
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// CertificatePath holds the issued certificate followed by its private key
+	CertificatePath = "/var/lib/kubelet/pki/kubelet-client-issued.pem"
+
+	// nodesGroup is the organization the API server maps to the system:nodes group
+	nodesGroup = "system:nodes"
+)
+
+// Replaced in tests
+var (
+	certificatePath = CertificatePath
+	osHostname      = os.Hostname
+)
+
+// Issuer issues client certificates with a new private key
+type Issuer interface {
+	// Issue returns a PEM certificate chain and PEM private key for subject
+	Issue(ctx context.Context, subject pkix.Name) (certPEM, keyPEM []byte, err error)
+	// String describes the issuer for logs
+	String() string
+}
+
+// NewIssuer returns the issuer configured in azure.nodeCertificate
+func NewIssuer(cfg *config.Config) (Issuer, error) {
+	nc := cfg.Azure.NodeCertificate
+	if nc == nil {
+		return nil, fmt.Errorf("azure.nodeCertificate is not configured")
+	}
+	switch nc.Issuer {
+	case config.NodeCertificateIssuerCA:
+		return newCAIssuer(nc.CA)
+	case config.NodeCertificateIssuerKeyVault:
+		return newKeyVaultIssuer(cfg)
+	default:
+		return nil, fmt.Errorf("unknown node certificate issuer %q", nc.Issuer)
+	}
+}
+
+// NodeName returns the name the kubelet registers the node under, the lower-cased hostname
+func NodeName() (string, error) {
+	hostname, err := osHostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the hostname: %w", err)
+	}
+	return strings.ToLower(hostname), nil
+}
+
+// Subject returns the certificate subject the API server authenticates as the node
+func Subject(nodeName string) pkix.Name {
+	return pkix.Name{CommonName: "system:node:" + nodeName, Organization: []string{nodesGroup}}
+}
+
+// Current returns the certificate at CertificatePath, or nil when there is none
+func Current() (*x509.Certificate, error) {
+	data, err := os.ReadFile(certificatePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseLeaf(data)
+}
+
+// NeedsRenewal reports why cert must be replaced for nodeName at now, or "" while it is still good.
+// Without renewBefore, a certificate is renewed once two thirds of its lifetime have passed.
+func NeedsRenewal(cert *x509.Certificate, nodeName string, renewBefore time.Duration, now time.Time) string {
+	if cert == nil {
+		return "no certificate was issued yet"
+	}
+	if cert.Subject.CommonName != Subject(nodeName).CommonName {
+		return fmt.Sprintf("the certificate was issued for %s", cert.Subject.CommonName)
+	}
+	if renewBefore <= 0 {
+		renewBefore = cert.NotAfter.Sub(cert.NotBefore) / 3
+	}
+	if !now.Before(cert.NotAfter.Add(-renewBefore)) {
+		return fmt.Sprintf("the certificate expires at %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// Ensure issues a new certificate when there is none or the current one needs renewal, and returns the
+// certificate in use with whether it was renewed
+func Ensure(ctx context.Context, cfg *config.Config, issuer Issuer, now time.Time) (*x509.Certificate, bool, error) {
+	nodeName, err := NodeName()
+	if err != nil {
+		return nil, false, err
+	}
+	current, err := Current()
+	if err != nil {
+		// An unreadable certificate is replaced rather than blocking the node
+		current = nil
+	}
+	if NeedsRenewal(current, nodeName, cfg.GetNodeCertificateRenewBefore(), now) == "" {
+		return current, false, nil
+	}
+
+	certPEM, keyPEM, err := issuer.Issue(ctx, Subject(nodeName))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to issue the node certificate from %s: %w", issuer, err)
+	}
+	data := append(append([]byte{}, certPEM...), keyPEM...)
+	cert, err := parseLeaf(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s issued an invalid certificate: %w", issuer, err)
+	}
+	if err := checkIssued(cert, nodeName, now); err != nil {
+		return nil, false, fmt.Errorf("%s issued an unusable certificate: %w", issuer, err)
+	}
+	if err := utils.WriteFileAtomicSystem(certificatePath, data, 0o600); err != nil {
+		return nil, false, fmt.Errorf("failed to write %s: %w", certificatePath, err)
+	}
+	return cert, true, nil
+}
+
+// checkIssued checks that cert authenticates nodeName as a client at now
+func checkIssued(cert *x509.Certificate, nodeName string, now time.Time) error {
+	if cert.Subject.CommonName != Subject(nodeName).CommonName {
+		return fmt.Errorf("subject %s, expected %s", cert.Subject.CommonName, Subject(nodeName).CommonName)
+	}
+	if !now.Before(cert.NotAfter) {
+		return fmt.Errorf("expired at %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	if len(cert.ExtKeyUsage) > 0 && !hasClientAuth(cert) {
+		return fmt.Errorf("not valid for client authentication")
+	}
+	return nil
+}
+
+func hasClientAuth(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageClientAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLeaf returns the first certificate of a PEM bundle, which also has to hold a private key
+func parseLeaf(data []byte) (*x509.Certificate, error) {
+	var leaf *x509.Certificate
+	hasKey := false
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == "CERTIFICATE" && leaf == nil:
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			leaf = cert
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			hasKey = true
+		}
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	if !hasKey {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	return leaf, nil
+}
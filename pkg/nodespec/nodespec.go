@@ -0,0 +1,164 @@
+// Package nodespec tracks the desired state last applied to the node with `aks-flex-node apply`, so the
+// next apply can show what changes, and records reviewed plans of an apply. Secrets are never recorded, only a
+// digest that tells whether they changed.
+package nodespec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// AppliedPath is where the flattened desired state of the last successful apply is recorded
+const AppliedPath = "/var/lib/aks-flex-node/applied-nodespec.json"
+
+// secretKeys are the field names whose values are recorded as digests only
+var secretKeys = map[string]bool{
+	"clientSecret": true,
+	"token":        true,
+}
+
+// Change is a setting whose desired value differs from the last applied one
+type Change struct {
+	Path string `json:"path"`
+	From string `json:"from,omitempty"` // Empty when the setting is new
+	To   string `json:"to,omitempty"`   // Empty when the setting was removed
+}
+
+// String describes the change, e.g. "~ kubernetes.version: 1.30.0 -> 1.31.1"
+func (c Change) String() string {
+	switch {
+	case c.From == "":
+		return fmt.Sprintf("+ %s: %s", c.Path, c.To)
+	case c.To == "":
+		return fmt.Sprintf("- %s: %s", c.Path, c.From)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, c.From, c.To)
+	}
+}
+
+// Flatten returns the settings of cfg keyed by their dotted path, such as "kubernetes.version".
+// Unset settings are left out and secret values are replaced with a digest.
+func Flatten(cfg *config.Config) (map[string]string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	values := map[string]string{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+// flatten adds the leaf values below node to values
+func flatten(path string, node any, values map[string]string) {
+	switch node := node.(type) {
+	case map[string]any:
+		for key, child := range node {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			if secretKeys[key] {
+				if s, ok := child.(string); ok && s != "" {
+					values[childPath] = redact(s)
+					continue
+				}
+			}
+			flatten(childPath, child, values)
+		}
+	case []any:
+		for i, child := range node {
+			flatten(fmt.Sprintf("%s[%d]", path, i), child, values)
+		}
+	case nil:
+	case string:
+		if node != "" {
+			values[path] = node
+		}
+	default:
+		if data, err := json.Marshal(node); err == nil && string(data) != "false" && string(data) != "0" {
+			values[path] = string(data)
+		}
+	}
+}
+
+// redact returns a value identifying a secret without revealing it
+func redact(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "<redacted sha256:" + hex.EncodeToString(sum[:])[:12] + ">"
+}
+
+// Diff returns the settings that differ between the applied and the desired state, sorted by path
+func Diff(applied, desired map[string]string) []Change {
+	var changes []Change
+	for path, to := range desired {
+		if from := applied[path]; from != to {
+			changes = append(changes, Change{Path: path, From: from, To: to})
+		}
+	}
+	for path, from := range applied {
+		if _, ok := desired[path]; !ok {
+			changes = append(changes, Change{Path: path, From: from})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// LoadApplied returns the state recorded by the last successful apply, or nil when nothing was applied yet
+func LoadApplied(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// SaveApplied records the state of a successful apply
+func SaveApplied(path string, values map[string]string) error {
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode applied state: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	// The values may include resource IDs and endpoints, but no secrets
+	if err := utils.WriteFileAtomicSystem(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Summary describes the changes in one line each, or says that there are none
+func Summary(changes []Change) string {
+	if len(changes) == 0 {
+		return "No changes. The node already matches the desired state."
+	}
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
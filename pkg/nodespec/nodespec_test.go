@@ -0,0 +1,96 @@
+package nodespec
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestFlatten(t *testing.T) {
+	cfg := &config.Config{
+		Azure: config.AzureConfig{
+			SubscriptionID:   "sub",
+			ServicePrincipal: &config.ServicePrincipalConfig{ClientID: "app", ClientSecret: "s3cret"},
+		},
+		Kubernetes: config.KubernetesConfig{Version: "1.31.1"},
+		Node: config.NodeConfig{
+			Labels: map[string]string{"site": "store-42"},
+		},
+		Npd: config.NPDConfig{GPUHealth: config.NPDGPUHealthConfig{Enabled: true}},
+	}
+
+	values, err := Flatten(cfg)
+	if err != nil {
+		t.Fatalf("Flatten() unexpected error: %v", err)
+	}
+	for path, want := range map[string]string{
+		"azure.subscriptionId":                "sub",
+		"azure.servicePrincipal.clientId":     "app",
+		"kubernetes.version":                  "1.31.1",
+		"node.labels.site":                    "store-42",
+		"npd.gpuHealth.enabled":               "true",
+		"azure.servicePrincipal.clientSecret": redact("s3cret"),
+	} {
+		if got := values[path]; got != want {
+			t.Errorf("Flatten()[%q] = %q, want %q", path, got, want)
+		}
+	}
+	for path, value := range values {
+		if strings.Contains(value, "s3cret") {
+			t.Errorf("Flatten()[%q] = %q reveals the secret", path, value)
+		}
+	}
+	if _, ok := values["containerRuntime"]; ok {
+		t.Error("Flatten() recorded an unset setting")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	applied := map[string]string{
+		"kubernetes.version":    "1.30.0",
+		"npd.gpuHealth.enabled": "true",
+		"azure.subscriptionId":  "sub",
+	}
+	desired := map[string]string{
+		"kubernetes.version":   "1.31.1",
+		"azure.subscriptionId": "sub",
+		"node.labels.site":     "store-42",
+	}
+
+	want := []Change{
+		{Path: "kubernetes.version", From: "1.30.0", To: "1.31.1"},
+		{Path: "node.labels.site", To: "store-42"},
+		{Path: "npd.gpuHealth.enabled", From: "true"},
+	}
+	changes := Diff(applied, desired)
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("Diff() = %v, want %v", changes, want)
+	}
+
+	wantSummary := "~ kubernetes.version: 1.30.0 -> 1.31.1\n+ node.labels.site: store-42\n- npd.gpuHealth.enabled: true"
+	if got := Summary(changes); got != wantSummary {
+		t.Errorf("Summary() = %q, want %q", got, wantSummary)
+	}
+	if got := Summary(Diff(desired, desired)); !strings.HasPrefix(got, "No changes") {
+		t.Errorf("Summary() = %q without changes, want it to say so", got)
+	}
+}
+
+func TestAppliedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "applied-nodespec.json")
+	if values, err := LoadApplied(path); err != nil || values != nil {
+		t.Fatalf("LoadApplied() before the first apply = %v, %v, want nil, nil", values, err)
+	}
+
+	want := map[string]string{"kubernetes.version": "1.31.1"}
+	if err := SaveApplied(path, want); err != nil {
+		t.Fatalf("SaveApplied() unexpected error: %v", err)
+	}
+	got, err := LoadApplied(path)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadApplied() = %v, %v, want %v", got, err, want)
+	}
+}
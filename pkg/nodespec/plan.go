@@ -0,0 +1,139 @@
+package nodespec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// planFormat is the version of the plan file format
+const planFormat = 1
+
+// PlanStep is what apply will do with one bootstrap step
+type PlanStep struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// Plan is the reviewed outcome of `aks-flex-node plan`: the settings that change and what each bootstrap
+// step will do. `aks-flex-node apply --plan` only carries it out while its inputs are unchanged.
+type Plan struct {
+	Format       int        `json:"format"`
+	AgentVersion string     `json:"agentVersion"`
+	ConfigPath   string     `json:"configPath"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	InputsDigest string     `json:"inputsDigest"`
+	Changes      []Change   `json:"changes"`
+	Steps        []PlanStep `json:"steps"`
+}
+
+// NewPlan returns the plan of converging the node from the applied to the desired state with steps
+func NewPlan(agentVersion, configPath string, applied, desired map[string]string, steps []PlanStep, now time.Time) *Plan {
+	return &Plan{
+		Format:       planFormat,
+		AgentVersion: agentVersion,
+		ConfigPath:   configPath,
+		CreatedAt:    now.UTC(),
+		InputsDigest: inputsDigest(agentVersion, applied, desired),
+		Changes:      Diff(applied, desired),
+		Steps:        steps,
+	}
+}
+
+// inputsDigest identifies everything a plan was computed from. Secrets are part of the flattened states as
+// digests, so a changed secret changes it too.
+func inputsDigest(agentVersion string, applied, desired map[string]string) string {
+	// Maps are encoded with sorted keys, so the digest is stable
+	data, _ := json.Marshal(struct {
+		AgentVersion string            `json:"agentVersion"`
+		Applied      map[string]string `json:"applied"`
+		Desired      map[string]string `json:"desired"`
+	}{agentVersion, applied, desired})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Digest identifies the plan for approval, e.g. in a change ticket
+func (p *Plan) Digest() string {
+	data, _ := json.Marshal(struct {
+		InputsDigest string     `json:"inputsDigest"`
+		Steps        []PlanStep `json:"steps"`
+	}{p.InputsDigest, p.Steps})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Verify checks that current, the plan computed now, matches the reviewed plan p, so apply does exactly
+// what was reviewed
+func (p *Plan) Verify(current *Plan) error {
+	if p.Format != planFormat {
+		return fmt.Errorf("the plan has format %d, this agent reads format %d; create a new plan", p.Format, planFormat)
+	}
+	if p.AgentVersion != current.AgentVersion {
+		return fmt.Errorf("the plan was made by agent version %s, this is %s; create a new plan", p.AgentVersion, current.AgentVersion)
+	}
+	if p.InputsDigest != current.InputsDigest {
+		return fmt.Errorf("the configuration or the state last applied changed since the plan was made; create a new plan")
+	}
+	if len(p.Steps) != len(current.Steps) {
+		return fmt.Errorf("the plan has %d steps, the node now needs %d; create a new plan", len(p.Steps), len(current.Steps))
+	}
+	for i, step := range p.Steps {
+		if step != current.Steps[i] {
+			return fmt.Errorf("the plan says %s %s, the node now needs %s %s; create a new plan",
+				step.Action, step.Name, current.Steps[i].Action, current.Steps[i].Name)
+		}
+	}
+	return nil
+}
+
+// Summary describes the changes and the steps that run, one per line
+func (p *Plan) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan %s for %s, made %s by agent %s\n\n", p.Digest(), p.ConfigPath, p.CreatedAt.Format(time.RFC3339), p.AgentVersion)
+	b.WriteString(Summary(p.Changes))
+	b.WriteString("\n\nSteps:\n")
+	runs := 0
+	for _, step := range p.Steps {
+		if step.Action == "none" {
+			continue
+		}
+		runs++
+		fmt.Fprintf(&b, "  %d. %s %s\n", runs, step.Action, step.Name)
+	}
+	if runs == 0 {
+		b.WriteString("  none; every step is completed\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// SavePlan writes the plan file for review. It holds no secrets, but the same resource IDs as the configuration.
+func SavePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := utils.WriteFileAtomic(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadPlan reads a plan file written by SavePlan
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	plan := &Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return plan, nil
+}
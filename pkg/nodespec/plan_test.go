@@ -0,0 +1,85 @@
+package nodespec
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanVerify(t *testing.T) {
+	applied := map[string]string{"kubernetes.version": "1.30.0"}
+	desired := map[string]string{"kubernetes.version": "1.31.1"}
+	steps := []PlanStep{{Name: "KubeBinariesInstaller", Action: "install"}, {Name: "KubeletInstaller", Action: "reconfigure"}}
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	reviewed := NewPlan("v1.2.0", "/etc/aks-flex-node/config.json", applied, desired, steps, now)
+
+	tests := []struct {
+		name    string
+		current *Plan
+		wantErr string
+	}{
+		{
+			name:    "unchanged",
+			current: NewPlan("v1.2.0", "/etc/aks-flex-node/config.json", applied, desired, steps, now.Add(time.Hour)),
+		},
+		{
+			name:    "configuration changed",
+			current: NewPlan("v1.2.0", "/etc/aks-flex-node/config.json", applied, map[string]string{"kubernetes.version": "1.31.2"}, steps, now),
+			wantErr: "configuration or the state last applied changed",
+		},
+		{
+			name:    "node changed",
+			current: NewPlan("v1.2.0", "/etc/aks-flex-node/config.json", applied, desired, []PlanStep{{Name: "KubeBinariesInstaller", Action: "none"}, steps[1]}, now),
+			wantErr: "the node now needs none KubeBinariesInstaller",
+		},
+		{
+			name:    "agent upgraded",
+			current: NewPlan("v1.3.0", "/etc/aks-flex-node/config.json", applied, desired, steps, now),
+			wantErr: "agent version v1.2.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reviewed.Verify(tt.current)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Verify() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanRoundTrip(t *testing.T) {
+	plan := NewPlan("v1.2.0", "/etc/aks-flex-node/config.json",
+		nil, map[string]string{"kubernetes.version": "1.31.1"},
+		[]PlanStep{{Name: "PreflightChecks", Action: "none"}, {Name: "KubeletInstaller", Action: "install"}},
+		time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := SavePlan(path, plan); err != nil {
+		t.Fatalf("SavePlan() unexpected error: %v", err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPlan() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, plan) || loaded.Digest() != plan.Digest() {
+		t.Errorf("LoadPlan() = %+v, want %+v", loaded, plan)
+	}
+
+	summary := plan.Summary()
+	for _, want := range []string{"+ kubernetes.version: 1.31.1", "1. install KubeletInstaller"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Summary() = %q, want it to contain %q", summary, want)
+		}
+	}
+	if strings.Contains(summary, "PreflightChecks") {
+		t.Errorf("Summary() = %q, want steps without action left out", summary)
+	}
+}
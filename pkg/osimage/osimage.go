@@ -0,0 +1,181 @@
+// Package osimage detects immutable operating systems, whose /usr is read-only and replaced as a whole on
+// update: OSTree based distributions such as Fedora CoreOS, Flatcar and images with a dm-verity protected
+// /usr such as Azure Linux immutable. Components only write to writable paths on such hosts; binaries that
+// would go under a read-only /usr are installed to /opt/bin instead.
+package osimage
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Variant is the kind of immutable operating system
+type Variant string
+
+const (
+	// VariantMutable is a regular distribution with a writable /usr
+	VariantMutable Variant = ""
+	// VariantOSTree is an OSTree deployment, e.g. Fedora CoreOS or RHEL for Edge; packages are layered with rpm-ostree
+	VariantOSTree Variant = "ostree"
+	// VariantFlatcar is Flatcar Container Linux; there is no package manager
+	VariantFlatcar Variant = "flatcar"
+	// VariantReadOnlyUsr is any other distribution booting with /usr mounted read-only
+	VariantReadOnlyUsr Variant = "read-only-usr"
+)
+
+// OptBinDir is where binaries go when their usual directory is read-only
+const OptBinDir = "/opt/bin"
+
+// Replaced in tests
+var (
+	osReleasePath    = "/etc/os-release"
+	ostreeBootedPath = "/run/ostree-booted"
+	mountInfoPath    = "/proc/self/mountinfo"
+)
+
+// Info describes the operating system image of the host
+type Info struct {
+	ID      string  // ID of /etc/os-release, e.g. fedora or flatcar
+	Variant Variant // VariantMutable unless the host is immutable
+}
+
+// Immutable reports whether /usr of the host is read-only
+func (i Info) Immutable() bool {
+	return i.Variant != VariantMutable
+}
+
+// Detect returns what kind of operating system image the host runs
+func Detect() Info {
+	info := Info{ID: osReleaseID()}
+	switch {
+	case fileExists(ostreeBootedPath):
+		info.Variant = VariantOSTree
+	case info.ID == "flatcar":
+		info.Variant = VariantFlatcar
+	case ReadOnly("/usr"):
+		info.Variant = VariantReadOnlyUsr
+	}
+	return info
+}
+
+// current is the detected image of the host; detection reads a few small files, so it is done once per
+// process. Replaced in tests.
+var current = sync.OnceValue(Detect)
+
+// Current returns the operating system image of the host
+func Current() Info {
+	return current()
+}
+
+// BinDir returns dir, or OptBinDir when the host is immutable and dir is read-only
+func BinDir(dir string) string {
+	if Current().Immutable() && ReadOnly(dir) {
+		return OptBinDir
+	}
+	return dir
+}
+
+// BinPath returns path, or the same file name in OptBinDir when the host is immutable and the directory of
+// path is read-only
+func BinPath(path string) string {
+	return filepath.Join(BinDir(filepath.Dir(path)), filepath.Base(path))
+}
+
+// ExtendPath appends OptBinDir to the PATH of the process on immutable hosts, so binaries moved there are
+// found by name like the binaries of the distribution
+func ExtendPath() {
+	if !Current().Immutable() {
+		return
+	}
+	path := os.Getenv("PATH")
+	if slices.Contains(filepath.SplitList(path), OptBinDir) {
+		return
+	}
+	_ = os.Setenv("PATH", path+string(filepath.ListSeparator)+OptBinDir)
+}
+
+// ReadOnly reports whether path, or the closest existing directory above it, is on a read-only mount.
+// Symbolic links are followed, so /usr/local linked to /var/usrlocal on Fedora CoreOS is writable.
+func ReadOnly(path string) bool {
+	resolved := resolve(path)
+	mounts, err := readMounts()
+	if err != nil {
+		return false
+	}
+	best := ""
+	readOnly := false
+	for point, ro := range mounts {
+		if within(resolved, point) && len(point) > len(best) {
+			best, readOnly = point, ro
+		}
+	}
+	return readOnly
+}
+
+// resolve returns the real path of the closest existing ancestor of path, joined with the rest of path
+func resolve(path string) string {
+	path = filepath.Clean(path)
+	rest := ""
+	for {
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// within reports whether path is mount point or below it
+func within(path, point string) bool {
+	return point == "/" || path == point || strings.HasPrefix(path, point+"/")
+}
+
+// readMounts returns the mount points of the process and whether each is mounted read-only. A mount point
+// mounted more than once takes the options of the last, visible mount.
+func readMounts() (map[string]bool, error) {
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	unescape := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	mounts := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mounts[unescape.Replace(fields[4])] = slices.Contains(strings.Split(fields[5], ","), "ro")
+	}
+	return mounts, scanner.Err()
+}
+
+// osReleaseID returns the ID of /etc/os-release, or an empty string when it cannot be read
+func osReleaseID() string {
+	data, err := os.ReadFile(osReleasePath)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "ID="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
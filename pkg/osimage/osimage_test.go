@@ -0,0 +1,108 @@
+package osimage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeHost points detection at files in a temporary directory
+func fakeHost(t *testing.T, osRelease, mountInfo string, ostree bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	oldOSRelease, oldOSTree, oldMountInfo := osReleasePath, ostreeBootedPath, mountInfoPath
+	t.Cleanup(func() { osReleasePath, ostreeBootedPath, mountInfoPath = oldOSRelease, oldOSTree, oldMountInfo })
+
+	osReleasePath = filepath.Join(dir, "os-release")
+	ostreeBootedPath = filepath.Join(dir, "ostree-booted")
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	write(t, osReleasePath, osRelease)
+	write(t, mountInfoPath, mountInfo)
+	if ostree {
+		write(t, ostreeBootedPath, "")
+	}
+	return dir
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	writableUsr = "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n"
+	readOnlyUsr = writableUsr + "23 22 253:0 / /usr ro,relatime shared:2 - erofs /dev/mapper/usr ro\n"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		mountInfo string
+		ostree    bool
+		want      Variant
+	}{
+		{name: "ubuntu", osRelease: "NAME=\"Ubuntu\"\nID=ubuntu\n", mountInfo: writableUsr, want: VariantMutable},
+		{name: "fedora coreos", osRelease: "ID=fedora\nVARIANT_ID=coreos\n", mountInfo: readOnlyUsr, ostree: true, want: VariantOSTree},
+		{name: "flatcar", osRelease: "ID=flatcar\nID_LIKE=coreos\n", mountInfo: readOnlyUsr, want: VariantFlatcar},
+		{name: "read-only usr", osRelease: "ID=\"azurelinux\"\n", mountInfo: readOnlyUsr, want: VariantReadOnlyUsr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeHost(t, tt.osRelease, tt.mountInfo, tt.ostree)
+			info := Detect()
+			if info.Variant != tt.want {
+				t.Errorf("Detect().Variant = %q, want %q", info.Variant, tt.want)
+			}
+			if info.Immutable() != (tt.want != VariantMutable) {
+				t.Errorf("Detect().Immutable() = %v", info.Immutable())
+			}
+		})
+	}
+}
+
+func TestReadOnlyFollowsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	fakeHost(t, "ID=fedora\n", writableUsr+"30 22 8:2 / "+dir+"/ro ro - ext4 /dev/sda2 ro\n", true)
+
+	if err := os.MkdirAll(filepath.Join(dir, "ro"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "rw"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "rw"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ReadOnly(filepath.Join(dir, "ro", "missing", "bin")) {
+		t.Error("ReadOnly() = false for a missing directory on a read-only mount")
+	}
+	if ReadOnly(filepath.Join(dir, "link", "bin")) {
+		t.Error("ReadOnly() = true for a link to a writable directory")
+	}
+	if ReadOnly(filepath.Join(dir, "rorw")) {
+		t.Error("ReadOnly() = true for a sibling sharing the prefix of a read-only mount")
+	}
+}
+
+func TestBinPath(t *testing.T) {
+	fakeHost(t, "ID=flatcar\n", readOnlyUsr, false)
+	old := current
+	defer func() { current = old }()
+
+	current = func() Info { return Info{ID: "flatcar", Variant: VariantFlatcar} }
+	if got := BinPath("/usr/local/bin/kubelet"); got != "/opt/bin/kubelet" {
+		t.Errorf("BinPath() = %s, want /opt/bin/kubelet", got)
+	}
+	if got := BinDir("/etc/kubernetes"); got != "/etc/kubernetes" {
+		t.Errorf("BinDir() = %s, want a writable directory unchanged", got)
+	}
+
+	current = func() Info { return Info{ID: "ubuntu"} }
+	if got := BinPath("/usr/local/bin/kubelet"); got != "/usr/local/bin/kubelet" {
+		t.Errorf("BinPath() = %s, want the path unchanged on a mutable host", got)
+	}
+}
@@ -0,0 +1,353 @@
+// Package patching detects OS updates that wait for a reboot or for services to restart, and tells when a
+// patch reboot is under way so the agent's reconcile loop leaves the node alone until it is back.
+// Debian and Ubuntu report pending reboots with /var/run/reboot-required and needrestart, RHEL and its
+// derivatives with dnf needs-restarting.
+package patching
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// StatePath records the result of the last check, read by the status collector
+	StatePath = "/var/lib/aks-flex-node/os-patching.json"
+	// ReportPath lists why a reboot is pending, one reason per line, and is empty when none is.
+	// The NPD plugin raising the RebootRequired problem reads it.
+	ReportPath = "/var/lib/aks-flex-node/reboot-required"
+
+	// drainPath records that the agent drained the node for a patch reboot, so it is uncordoned after the reboot
+	drainPath = "/var/lib/aks-flex-node/patch-reboot.json"
+
+	// Written by the update-notifier hooks of Debian and Ubuntu packages that need a reboot
+	rebootRequiredPath     = "/var/run/reboot-required"
+	rebootRequiredPkgsPath = "/var/run/reboot-required.pkgs"
+
+	// Exists while systemd has a reboot or shutdown scheduled, e.g. by shutdown -r +5
+	shutdownScheduledPath = "/run/systemd/shutdown/scheduled"
+
+	// bootIDPath changes on every boot
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+	// kuredAnnotation is set by kured (with --annotate-nodes) on a node while it drains and reboots it
+	kuredAnnotation = "weave.works/kured-reboot-in-progress"
+)
+
+// State is the content of StatePath
+type State struct {
+	CheckedAt      time.Time `json:"checkedAt"`
+	RebootRequired bool      `json:"rebootRequired"`
+	// Packages or kernel versions the reboot is needed for
+	Reasons []string `json:"reasons,omitempty"`
+	// Services still running code replaced by an update; restarting them is enough
+	ServicesNeedingRestart []string `json:"servicesNeedingRestart,omitempty"`
+	// Tools the result comes from
+	Detectors []string `json:"detectors,omitempty"`
+}
+
+// prober runs the detection tools; replaced in tests
+type prober struct {
+	readFile func(path string) ([]byte, error)
+	exists   func(path string) bool
+	lookPath func(name string) bool
+	// run returns the combined output and exit code of a command that could be started
+	run func(ctx context.Context, name string, args ...string) (string, int, error)
+}
+
+var systemProber = prober{
+	readFile: os.ReadFile,
+	exists:   utils.FileExists,
+	lookPath: utils.BinaryExists,
+	run: func(ctx context.Context, name string, args ...string) (string, int, error) {
+		output, err := utils.RunCommandWithOutput(name, args...)
+		return toolResult(name, output, err)
+	},
+}
+
+// toolResult turns the exit status of a detection tool into its exit code. A tool that could not run with
+// the privileges it needs, e.g. because sudo refused it, is unavailable: its exit code is sudo's, and exit 1 of
+// dnf needs-restarting -r would otherwise report a reboot.
+func toolResult(name, output string, err error) (string, int, error) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return output, 0, err
+	}
+	for _, line := range lines(output) {
+		line = strings.ToLower(line)
+		if strings.HasPrefix(line, "sudo:") || strings.Contains(line, "is not allowed to execute") ||
+			strings.Contains(line, "permission denied") || strings.Contains(line, "superuser privileges") {
+			return output, exitErr.ExitCode(), fmt.Errorf("%s is unavailable: %s", name, line)
+		}
+	}
+	return output, exitErr.ExitCode(), nil
+}
+
+// Detect checks for OS updates waiting for a reboot or service restarts. Tools that fail are skipped, so the
+// result reflects what could be checked.
+func Detect(ctx context.Context) *State {
+	return systemProber.detect(ctx)
+}
+
+func (p prober) detect(ctx context.Context) *State {
+	state := &State{CheckedAt: time.Now().UTC()}
+
+	if p.exists(rebootRequiredPath) {
+		state.RebootRequired = true
+		state.Detectors = append(state.Detectors, "reboot-required")
+		if pkgs, err := p.readFile(rebootRequiredPkgsPath); err == nil {
+			state.Reasons = append(state.Reasons, lines(string(pkgs))...)
+		}
+	}
+
+	switch {
+	case p.lookPath("needrestart"):
+		// Batch mode in list-only mode never restarts anything
+		if output, _, err := p.run(ctx, "needrestart", "-b", "-r", "l"); err == nil {
+			state.Detectors = append(state.Detectors, "needrestart")
+			reboot, reasons, services := parseNeedrestart(output)
+			state.RebootRequired = state.RebootRequired || reboot
+			state.Reasons = append(state.Reasons, reasons...)
+			state.ServicesNeedingRestart = append(state.ServicesNeedingRestart, services...)
+		}
+	case p.lookPath("dnf"):
+		// Exit code 1 means a reboot is needed
+		if output, code, err := p.run(ctx, "dnf", "needs-restarting", "-r"); err == nil && code <= 1 {
+			state.Detectors = append(state.Detectors, "dnf needs-restarting")
+			if code == 1 {
+				state.RebootRequired = true
+				state.Reasons = append(state.Reasons, parseNeedsRestartingReboot(output)...)
+			}
+			if output, code, err := p.run(ctx, "dnf", "needs-restarting", "-s"); err == nil && code == 0 {
+				state.ServicesNeedingRestart = append(state.ServicesNeedingRestart, lines(output)...)
+			}
+		}
+	}
+
+	state.Reasons = unique(state.Reasons)
+	state.ServicesNeedingRestart = unique(state.ServicesNeedingRestart)
+	return state
+}
+
+// parseNeedrestart reads the batch output of needrestart: a pending kernel (KSTA 2 or 3) or microcode
+// (UCSTA 2) update needs a reboot, NEEDRESTART-SVC lines name the services to restart
+func parseNeedrestart(output string) (bool, []string, []string) {
+	values := map[string]string{}
+	var services []string
+	for _, line := range lines(output) {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "NEEDRESTART-SVC" {
+			services = append(services, value)
+			continue
+		}
+		values[key] = value
+	}
+
+	reboot := false
+	var reasons []string
+	if status := values["NEEDRESTART-KSTA"]; status == "2" || status == "3" {
+		reboot = true
+		reasons = append(reasons, fmt.Sprintf("kernel %s (running %s)", values["NEEDRESTART-KEXP"], values["NEEDRESTART-KCUR"]))
+	}
+	if values["NEEDRESTART-UCSTA"] == "2" {
+		reboot = true
+		reasons = append(reasons, "CPU microcode")
+	}
+	return reboot, reasons, services
+}
+
+// parseNeedsRestartingReboot reads the updated packages from `dnf needs-restarting -r`, listed as "  * kernel"
+func parseNeedsRestartingReboot(output string) []string {
+	var reasons []string
+	for _, line := range lines(output) {
+		if pkg, found := strings.CutPrefix(line, "* "); found {
+			reasons = append(reasons, strings.TrimSpace(pkg))
+		}
+	}
+	return reasons
+}
+
+// lines returns the trimmed, non-empty lines of text
+func lines(text string) []string {
+	var result []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func unique(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// ReadState returns the result of the last check, or nil when none was recorded
+func ReadState() (*State, error) {
+	data, err := os.ReadFile(StatePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", StatePath, err)
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", StatePath, err)
+	}
+	return state, nil
+}
+
+// WriteState records the result of a check and the report read by NPD
+func WriteState(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeStateFile(StatePath, data); err != nil {
+		return err
+	}
+	return writeStateFile(ReportPath, []byte(report(state)))
+}
+
+// report renders the reasons a reboot is pending for NPD, or nothing when none is
+func report(state *State) string {
+	if !state.RebootRequired {
+		return ""
+	}
+	if len(state.Reasons) == 0 {
+		return "OS updates\n"
+	}
+	return strings.Join(state.Reasons, "\n") + "\n"
+}
+
+// drain is the content of drainPath
+type drain struct {
+	BootID    string    `json:"bootId"`
+	DrainedAt time.Time `json:"drainedAt"`
+}
+
+// RecordDrain records that the node was drained for a patch reboot in the current boot
+func RecordDrain() error {
+	bootID, err := currentBootID()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(drain{BootID: bootID, DrainedAt: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(drainPath, data)
+}
+
+// Drained reports whether the agent drained the node for a patch reboot, and whether the machine
+// rebooted since
+func Drained() (drained, rebooted bool) {
+	data, err := os.ReadFile(drainPath)
+	if err != nil {
+		return false, false
+	}
+	record := drain{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return false, false
+	}
+	bootID, err := currentBootID()
+	return true, err == nil && bootID != record.BootID
+}
+
+// ClearDrain forgets the drain once the node was uncordoned
+func ClearDrain() error {
+	return utils.RunCleanupCommand(drainPath)
+}
+
+// ShutdownScheduled reports whether systemd has a reboot or shutdown scheduled
+func ShutdownScheduled() bool {
+	return systemProber.exists(shutdownScheduledPath)
+}
+
+// RebootInProgress returns why a patch reboot is under way, or an empty string when none is: a reboot
+// scheduled with systemd, a shutting down system, a drain by the agent for a patch reboot, or kured
+// rebooting the node. kubeconfig is used to read the node's annotations and may be missing.
+func RebootInProgress(ctx context.Context, kubeconfig string) string {
+	return systemProber.rebootInProgress(ctx, kubeconfig)
+}
+
+func (p prober) rebootInProgress(ctx context.Context, kubeconfig string) string {
+	if p.exists(shutdownScheduledPath) {
+		return "a reboot is scheduled"
+	}
+	if output, _, err := p.run(ctx, "systemctl", "is-system-running"); err == nil && strings.TrimSpace(output) == "stopping" {
+		return "the system is shutting down"
+	}
+	if drained, rebooted := Drained(); drained && !rebooted {
+		return "the agent drained the node for a patch reboot"
+	}
+
+	// The kubeconfig is only readable by root, kubectl runs with sudo
+	if !p.exists(kubeconfig) {
+		return ""
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	output, code, err := p.run(ctx, "kubectl", "--kubeconfig", kubeconfig, "get", "node", strings.ToLower(hostname),
+		"-o", "jsonpath={.metadata.annotations}")
+	if err != nil || code != 0 {
+		return ""
+	}
+	return kuredReboot(output)
+}
+
+// kuredReboot returns why kured is rebooting the node from the node's annotations as printed by kubectl
+func kuredReboot(annotations string) string {
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(annotations), &values); err != nil {
+		return ""
+	}
+	if _, ok := values[kuredAnnotation]; ok {
+		return "kured is rebooting the node"
+	}
+	return ""
+}
+
+func currentBootID() (string, error) {
+	data, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read boot ID: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeStateFile writes a world-readable state file, creating its directory when needed
+func writeStateFile(path string, data []byte) error {
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package patching
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// commandResult is the output and exit code of a fake command
+type commandResult struct {
+	output string
+	code   int
+}
+
+// fakeProber returns a prober reading the given files and running the given commands, keyed by command line
+func fakeProber(files map[string]string, commands map[string]commandResult) prober {
+	return prober{
+		readFile: func(path string) ([]byte, error) {
+			if content, ok := files[path]; ok {
+				return []byte(content), nil
+			}
+			return nil, fs.ErrNotExist
+		},
+		exists: func(path string) bool {
+			_, ok := files[path]
+			return ok
+		},
+		lookPath: func(name string) bool {
+			for command := range commands {
+				if strings.HasPrefix(command, name+" ") {
+					return true
+				}
+			}
+			return false
+		},
+		run: func(ctx context.Context, name string, args ...string) (string, int, error) {
+			result, ok := commands[strings.Join(append([]string{name}, args...), " ")]
+			if !ok {
+				return "", 0, errors.New("not found")
+			}
+			return result.output, result.code, nil
+		},
+	}
+}
+
+func TestDetectDebian(t *testing.T) {
+	p := fakeProber(map[string]string{
+		rebootRequiredPath:     "*** System restart required ***\n",
+		rebootRequiredPkgsPath: "linux-image-5.15.0-94-generic\nlinux-base\nlinux-base\n",
+	}, map[string]commandResult{
+		"needrestart -b -r l": {output: "NEEDRESTART-VER: 3.5\nNEEDRESTART-KCUR: 5.15.0-91-generic\nNEEDRESTART-KEXP: 5.15.0-94-generic\n" +
+			"NEEDRESTART-KSTA: 3\nNEEDRESTART-UCSTA: 1\nNEEDRESTART-SVC: containerd.service\nNEEDRESTART-SVC: systemd-logind.service\n"},
+	})
+
+	state := p.detect(context.Background())
+	if !state.RebootRequired {
+		t.Fatal("detect() RebootRequired = false, want true")
+	}
+	want := "linux-image-5.15.0-94-generic, linux-base, kernel 5.15.0-94-generic (running 5.15.0-91-generic)"
+	if got := strings.Join(state.Reasons, ", "); got != want {
+		t.Errorf("detect() reasons = %q, want %q", got, want)
+	}
+	if got := strings.Join(state.ServicesNeedingRestart, ","); got != "containerd.service,systemd-logind.service" {
+		t.Errorf("detect() services = %q, want containerd and systemd-logind", got)
+	}
+	if got := strings.Join(state.Detectors, ","); got != "reboot-required,needrestart" {
+		t.Errorf("detect() detectors = %q", got)
+	}
+}
+
+func TestDetectRHEL(t *testing.T) {
+	p := fakeProber(nil, map[string]commandResult{
+		"dnf needs-restarting -r": {code: 1, output: "Core libraries or services have been updated since boot-up:\n  * kernel\n  * systemd\n\n" +
+			"Reboot is required to fully utilize these updates.\nMore information: https://access.redhat.com/solutions/27943\n"},
+		"dnf needs-restarting -s": {output: "sshd.service\n"},
+	})
+	state := p.detect(context.Background())
+	if !state.RebootRequired || strings.Join(state.Reasons, ",") != "kernel,systemd" {
+		t.Errorf("detect() = %+v, want a reboot for kernel and systemd", state)
+	}
+	if strings.Join(state.ServicesNeedingRestart, ",") != "sshd.service" {
+		t.Errorf("detect() services = %q, want sshd.service", state.ServicesNeedingRestart)
+	}
+
+	p = fakeProber(nil, map[string]commandResult{
+		"dnf needs-restarting -r": {output: "No core libraries or services have been updated since boot-up.\nReboot should not be necessary.\n"},
+	})
+	if state := p.detect(context.Background()); state.RebootRequired || len(state.Reasons) != 0 {
+		t.Errorf("detect() = %+v, want no reboot", state)
+	}
+}
+
+func TestToolResult(t *testing.T) {
+	tests := []struct {
+		name            string
+		script          string
+		wantCode        int
+		wantUnavailable bool
+	}{
+		{name: "reboot needed", script: "echo 'Reboot is required'; exit 1", wantCode: 1},
+		{name: "refused by sudo", script: "echo 'Sorry, user aks-flex-node is not allowed to execute dnf as root.' >&2; exit 1", wantUnavailable: true},
+		{name: "sudo needs a password", script: "echo 'sudo: a password is required' >&2; exit 1", wantUnavailable: true},
+		{name: "run without root", script: "echo 'Error: This command has to be run with superuser privileges' >&2; exit 1", wantUnavailable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := exec.Command("sh", "-c", tt.script).CombinedOutput()
+			_, code, err := toolResult("dnf", string(output), err)
+			if tt.wantUnavailable {
+				if err == nil {
+					t.Fatalf("toolResult() = exit %d, want the detector unavailable", code)
+				}
+				return
+			}
+			if err != nil || code != tt.wantCode {
+				t.Errorf("toolResult() = %d, %v, want exit %d", code, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDetectNothingAvailable(t *testing.T) {
+	state := fakeProber(nil, nil).detect(context.Background())
+	if state.RebootRequired || len(state.Detectors) != 0 {
+		t.Errorf("detect() = %+v, want nothing detected", state)
+	}
+	if report(state) != "" {
+		t.Errorf("report() = %q, want empty", report(state))
+	}
+	if got := report(&State{RebootRequired: true}); got != "OS updates\n" {
+		t.Errorf("report() = %q without reasons, want a generic reason", got)
+	}
+}
+
+func TestParseNeedrestartMicrocode(t *testing.T) {
+	reboot, reasons, _ := parseNeedrestart("NEEDRESTART-KSTA: 1\nNEEDRESTART-UCSTA: 2\n")
+	if !reboot || strings.Join(reasons, ",") != "CPU microcode" {
+		t.Errorf("parseNeedrestart() = %v, %q, want a microcode reboot", reboot, reasons)
+	}
+	if reboot, _, _ := parseNeedrestart("NEEDRESTART-KSTA: 1\nNEEDRESTART-UCSTA: 1\n"); reboot {
+		t.Error("parseNeedrestart() wants a reboot with current kernel and microcode")
+	}
+}
+
+func TestRebootInProgress(t *testing.T) {
+	kubeconfig := "/var/lib/kubelet/kubeconfig"
+	if got := fakeProber(map[string]string{shutdownScheduledPath: ""}, nil).rebootInProgress(context.Background(), kubeconfig); got == "" {
+		t.Error("rebootInProgress() ignores a scheduled reboot")
+	}
+	stopping := fakeProber(nil, map[string]commandResult{"systemctl is-system-running": {output: "stopping\n", code: 1}})
+	if got := stopping.rebootInProgress(context.Background(), kubeconfig); got == "" {
+		t.Error("rebootInProgress() ignores a shutting down system")
+	}
+	if got := fakeProber(nil, nil).rebootInProgress(context.Background(), kubeconfig); got != "" {
+		t.Errorf("rebootInProgress() = %q without any signal, want empty", got)
+	}
+}
+
+func TestKuredReboot(t *testing.T) {
+	if got := kuredReboot(`{"node.alpha.kubernetes.io/ttl":"0","weave.works/kured-reboot-in-progress":"2026-10-17T02:00:00Z"}`); got == "" {
+		t.Error("kuredReboot() ignores the kured annotation")
+	}
+	for _, annotations := range []string{`{"node.alpha.kubernetes.io/ttl":"0"}`, "", "not json"} {
+		if got := kuredReboot(annotations); got != "" {
+			t.Errorf("kuredReboot(%q) = %q, want empty", annotations, got)
+		}
+	}
+}
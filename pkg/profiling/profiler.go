@@ -0,0 +1,176 @@
+package profiling
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SummaryFile is the name of the time summary written next to the profiles
+const SummaryFile = "summary.txt"
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Phase is the time breakdown of one bootstrap step
+type Phase struct {
+	Step     string        `json:"step"`
+	Duration time.Duration `json:"duration"`
+	Totals
+}
+
+// Other returns the step time not attributed to downloads, Azure calls or local commands.
+// Tracked work can overlap, so the result never goes below zero.
+func (p Phase) Other() time.Duration {
+	other := p.Duration - p.Download - p.Azure - p.Exec
+	if other < 0 {
+		return 0
+	}
+	return other
+}
+
+// Profiler writes a CPU and a heap profile for each step and records where the step's time went.
+// Steps must not overlap; the bootstrap executor runs them one at a time.
+type Profiler struct {
+	dir    string
+	logger *logrus.Logger
+
+	step    string
+	started time.Time
+	before  Totals
+	cpuFile *os.File
+
+	phases []Phase
+}
+
+// NewProfiler creates a profiler that writes profiles to dir, creating it if needed
+func NewProfiler(dir string, logger *logrus.Logger) (*Profiler, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory %s: %w", dir, err)
+	}
+	return &Profiler{dir: dir, logger: logger}, nil
+}
+
+// Start begins profiling a step
+func (p *Profiler) Start(step string) {
+	p.step = step
+	p.started = time.Now()
+	p.before = Snapshot()
+
+	path := p.profilePath("cpu")
+	f, err := os.Create(path)
+	if err != nil {
+		p.logger.Warnf("Failed to create CPU profile %s: %v", path, err)
+		return
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		p.logger.Warnf("Failed to start CPU profile for step %s: %v", step, err)
+		_ = f.Close()
+		_ = os.Remove(path)
+		return
+	}
+	p.cpuFile = f
+}
+
+// Finish stops profiling the current step, writes its heap profile and records its time breakdown
+func (p *Profiler) Finish(duration time.Duration) {
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpuFile.Close(); err != nil {
+			p.logger.Warnf("Failed to write CPU profile for step %s: %v", p.step, err)
+		}
+		p.cpuFile = nil
+	}
+	p.writeHeapProfile()
+
+	if duration <= 0 {
+		duration = time.Since(p.started)
+	}
+	p.phases = append(p.phases, Phase{Step: p.step, Duration: duration, Totals: Snapshot().Sub(p.before)})
+}
+
+// Phases returns the breakdown of the steps finished so far
+func (p *Profiler) Phases() []Phase {
+	return p.phases
+}
+
+// WriteSummary writes the summary table to the profile directory and returns its path
+func (p *Profiler) WriteSummary() (string, error) {
+	path := filepath.Join(p.dir, SummaryFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create profile summary %s: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err := WriteSummary(f, p.phases); err != nil {
+		return "", fmt.Errorf("failed to write profile summary %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// writeHeapProfile writes the live heap after the step, once a GC has dropped the step's garbage
+func (p *Profiler) writeHeapProfile() {
+	path := p.profilePath("heap")
+	f, err := os.Create(path)
+	if err != nil {
+		p.logger.Warnf("Failed to create heap profile %s: %v", path, err)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		p.logger.Warnf("Failed to write heap profile for step %s: %v", p.step, err)
+	}
+}
+
+// profilePath returns the file for the current step's profile, numbered in step order
+func (p *Profiler) profilePath(kind string) string {
+	name := unsafeFileChars.ReplaceAllString(p.step, "_")
+	return filepath.Join(p.dir, fmt.Sprintf("%02d-%s.%s.pprof", len(p.phases)+1, name, kind))
+}
+
+// WriteSummary writes a table of where each phase's time went, followed by the totals
+func WriteSummary(w io.Writer, phases []Phase) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "STEP\tDURATION\tDOWNLOAD\tAZURE\tEXEC\tOTHER\t")
+
+	var total Phase
+	total.Step = "TOTAL"
+	for _, phase := range phases {
+		writePhase(tw, phase)
+		total.Duration += phase.Duration
+		total.Download += phase.Download
+		total.Azure += phase.Azure
+		total.Exec += phase.Exec
+	}
+	writePhase(tw, total)
+	return tw.Flush()
+}
+
+func writePhase(w io.Writer, p Phase) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", p.Step,
+		round(p.Duration), share(p.Download, p.Duration), share(p.Azure, p.Duration), share(p.Exec, p.Duration), share(p.Other(), p.Duration))
+}
+
+// share formats d together with its percentage of total
+func share(d, total time.Duration) string {
+	if total <= 0 {
+		return round(d)
+	}
+	return fmt.Sprintf("%s (%d%%)", round(d), int(100*d/total))
+}
+
+func round(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,125 @@
+// Package profiling attributes bootstrap time to downloads, Azure calls and local commands, counts
+// retries and downloaded bytes, and collects CPU and heap profiles for each bootstrap phase.
+package profiling
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// Category is a kind of work whose time is tracked across the process
+type Category int
+
+const (
+	// Download covers fetching release artifacts and install scripts
+	Download Category = iota
+	// Azure covers Azure API requests, including SDK retries and token requests
+	Azure
+	// Exec covers local commands such as apt, systemctl and tar
+	Exec
+
+	numCategories
+)
+
+var categoryNames = [numCategories]string{"download", "azure", "exec"}
+
+// String returns the lowercase category name
+func (c Category) String() string {
+	if c < 0 || c >= numCategories {
+		return "unknown"
+	}
+	return categoryNames[c]
+}
+
+// totals holds the time spent in each category since process start, in nanoseconds
+var totals [numCategories]atomic.Int64
+
+// Counters of the process since start
+var (
+	downloadedBytes atomic.Int64
+	retries         atomic.Int64
+	azureCalls      atomic.Int64 // Azure requests as made by clients
+	azureAttempts   atomic.Int64 // HTTP attempts of Azure requests, including the SDK's retries
+)
+
+// CountDownloaded records n bytes received by a download
+func CountDownloaded(n int64) {
+	downloadedBytes.Add(n)
+}
+
+// CountRetry records a retry of failed work after a backoff
+func CountRetry() {
+	retries.Add(1)
+}
+
+// Track starts timing work of the given category; call the returned function when the work is done.
+// Tracking is always on: it costs two clock reads, which is negligible next to the work being timed.
+func Track(c Category) func() {
+	start := time.Now()
+	return func() {
+		totals[c].Add(int64(time.Since(start)))
+	}
+}
+
+// Totals is the time spent in each category, and the retries and downloaded bytes
+type Totals struct {
+	Download time.Duration `json:"download"`
+	Azure    time.Duration `json:"azure"`
+	Exec     time.Duration `json:"exec"`
+
+	Retries         int64 `json:"retries"` // Retries of the agent's backoff loops and of the Azure SDK
+	DownloadedBytes int64 `json:"downloadedBytes"`
+}
+
+// Sub returns the time spent and the work counted between an earlier snapshot and t
+func (t Totals) Sub(earlier Totals) Totals {
+	return Totals{
+		Download:        t.Download - earlier.Download,
+		Azure:           t.Azure - earlier.Azure,
+		Exec:            t.Exec - earlier.Exec,
+		Retries:         t.Retries - earlier.Retries,
+		DownloadedBytes: t.DownloadedBytes - earlier.DownloadedBytes,
+	}
+}
+
+// Snapshot returns the time tracked in each category and the work counted so far
+func Snapshot() Totals {
+	return Totals{
+		Download:        time.Duration(totals[Download].Load()),
+		Azure:           time.Duration(totals[Azure].Load()),
+		Exec:            time.Duration(totals[Exec].Load()),
+		Retries:         retries.Load() + max(azureAttempts.Load()-azureCalls.Load(), 0),
+		DownloadedBytes: downloadedBytes.Load(),
+	}
+}
+
+// AzurePolicy returns a per-call pipeline policy that tracks the time spent in Azure requests
+func AzurePolicy() policy.Policy {
+	return azurePolicy{}
+}
+
+type azurePolicy struct{}
+
+// Do times the request including the SDK's retries
+func (azurePolicy) Do(req *policy.Request) (*http.Response, error) {
+	defer Track(Azure)()
+	azureCalls.Add(1)
+	return req.Next()
+}
+
+// AzureAttemptPolicy returns a per-retry pipeline policy that counts the attempts of Azure requests, so the
+// SDK's retries are counted along with AzurePolicy's count of requests
+func AzureAttemptPolicy() policy.Policy {
+	return azureAttemptPolicy{}
+}
+
+type azureAttemptPolicy struct{}
+
+// Do counts the attempt
+func (azureAttemptPolicy) Do(req *policy.Request) (*http.Response, error) {
+	azureAttempts.Add(1)
+	return req.Next()
+}
@@ -0,0 +1,144 @@
+package profiling
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTrack(t *testing.T) {
+	before := Snapshot()
+	done := Track(Exec)
+	time.Sleep(5 * time.Millisecond)
+	done()
+
+	spent := Snapshot().Sub(before)
+	if spent.Exec < 5*time.Millisecond {
+		t.Errorf("Track() recorded %v of exec time, want at least 5ms", spent.Exec)
+	}
+	if spent.Download != 0 || spent.Azure != 0 {
+		t.Errorf("Track() should only record its own category, got %+v", spent)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	before := Snapshot()
+	CountDownloaded(1024)
+	CountDownloaded(512)
+	CountRetry()
+
+	// One Azure request the SDK sent three times
+	pipeline := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport: transportFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+		}),
+		Retry:            policy.RetryOptions{MaxRetries: 2, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond},
+		PerCallPolicies:  []policy.Policy{AzurePolicy()},
+		PerRetryPolicies: []policy.Policy{AzureAttemptPolicy()},
+	})
+	req, err := runtime.NewRequest(context.Background(), http.MethodGet, "https://management.azure.com/subscriptions")
+	if err != nil {
+		t.Fatalf("NewRequest() unexpected error: %v", err)
+	}
+	if _, err := pipeline.Do(req); err != nil {
+		t.Fatalf("Do() unexpected error: %v", err)
+	}
+
+	counted := Snapshot().Sub(before)
+	if counted.DownloadedBytes != 1536 || counted.Retries != 3 {
+		t.Errorf("counted %d bytes and %d retries, want 1536 bytes and 3 retries", counted.DownloadedBytes, counted.Retries)
+	}
+}
+
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestPhaseOther(t *testing.T) {
+	phase := Phase{Duration: 10 * time.Second, Totals: Totals{Download: 4 * time.Second, Azure: 3 * time.Second, Exec: time.Second}}
+	if got := phase.Other(); got != 2*time.Second {
+		t.Errorf("Other() = %v, want 2s", got)
+	}
+
+	// Overlapping work can add up to more than the step took
+	phase.Exec = 5 * time.Second
+	if got := phase.Other(); got != 0 {
+		t.Errorf("Other() = %v, want 0 when tracked time exceeds the step", got)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	phases := []Phase{
+		{Step: "ArcInstall", Duration: 4 * time.Second, Totals: Totals{Download: time.Second, Azure: 2 * time.Second}},
+		{Step: "Containerd", Duration: 6 * time.Second, Totals: Totals{Download: 3 * time.Second, Exec: 3 * time.Second}},
+	}
+	var out strings.Builder
+	if err := WriteSummary(&out, phases); err != nil {
+		t.Fatalf("WriteSummary() unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("WriteSummary() wrote %d lines, want header, 2 steps and total:\n%s", len(lines), out.String())
+	}
+	for _, want := range []string{"ArcInstall", "2s (50%)", "1s (25%)"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("WriteSummary() step line %q should contain %q", lines[1], want)
+		}
+	}
+	for _, want := range []string{"TOTAL", "10s", "4s (40%)", "3s (30%)"} {
+		if !strings.Contains(lines[3], want) {
+			t.Errorf("WriteSummary() total line %q should contain %q", lines[3], want)
+		}
+	}
+}
+
+func TestProfiler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	dir := filepath.Join(t.TempDir(), "profiles")
+
+	profiler, err := NewProfiler(dir, logger)
+	if err != nil {
+		t.Fatalf("NewProfiler() unexpected error: %v", err)
+	}
+	for _, step := range []string{"ca-trust", "Arc Install"} {
+		profiler.Start(step)
+		Track(Download)()
+		profiler.Finish(time.Second)
+	}
+
+	for _, name := range []string{"01-ca-trust.cpu.pprof", "01-ca-trust.heap.pprof", "02-Arc_Install.cpu.pprof", "02-Arc_Install.heap.pprof"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Errorf("Expected non-empty profile %s, got err %v", name, err)
+		}
+	}
+	if phases := profiler.Phases(); len(phases) != 2 || phases[1].Step != "Arc Install" || phases[1].Duration != time.Second {
+		t.Errorf("Phases() = %+v, want both steps in order", phases)
+	}
+
+	path, err := profiler.WriteSummary()
+	if err != nil {
+		t.Fatalf("WriteSummary() unexpected error: %v", err)
+	}
+	if path != filepath.Join(dir, SummaryFile) {
+		t.Errorf("WriteSummary() path = %s, want %s", path, filepath.Join(dir, SummaryFile))
+	}
+}
+
+// BenchmarkTrack measures the cost tracking adds to every command, download and Azure request
+func BenchmarkTrack(b *testing.B) {
+	for b.Loop() {
+		Track(Exec)()
+	}
+}
@@ -0,0 +1,123 @@
+// Package retry runs operations with exponential backoff. Waiting goes through a Clock, so tests
+// substitute a FakeClock and run retry loops without sleeping.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
+)
+
+// Clock tells the time and waits. RealClock is used outside tests.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the system clock
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Sleep waits for d on clock, returning early with ctx's error when ctx is done. A nil clock is RealClock.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if clock == nil {
+		clock = RealClock
+	}
+	// A done context wins even when the clock has already fired, as a fake clock always has
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Backoff describes an exponential backoff: the first retry waits Initial, every further retry
+// twice as long as the one before, up to Max
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int   // Total number of attempts, including the first one
+	Clock    Clock // Clock to wait on; nil is RealClock
+
+	// MinDelay, when set, returns the shortest wait before a retry, e.g. how long a server asked all
+	// clients to hold off. It may exceed Max.
+	MinDelay func() time.Duration
+}
+
+// Delay returns the wait before the given retry, counting the first retry as 1
+func (b Backoff) Delay(retry int) time.Duration {
+	if retry < 1 {
+		return 0
+	}
+	delay := b.Initial
+	for i := 1; i < retry && (b.Max <= 0 || delay < b.Max) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	if b.MinDelay != nil {
+		delay = max(delay, b.MinDelay())
+	}
+	return delay
+}
+
+// Wait sleeps for the delay before the given retry, counting the retry for the bootstrap report
+func (b Backoff) Wait(ctx context.Context, retry int) error {
+	if retry >= 1 {
+		profiling.CountRetry()
+	}
+	return Sleep(ctx, b.Clock, b.Delay(retry))
+}
+
+// permanentError stops Do from retrying
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; Do returns the wrapped error immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// ErrExhausted is wrapped by the error Do returns when all attempts failed
+var ErrExhausted = errors.New("retries exhausted")
+
+// Do calls fn until it succeeds, returns a Permanent error, ctx is done, or b.Attempts calls failed.
+// attempt counts from 1. After the last failed attempt Do returns fn's error joined with ErrExhausted.
+func Do(ctx context.Context, b Backoff, fn func(ctx context.Context, attempt int) error) error {
+	attempts := max(b.Attempts, 1)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if waitErr := b.Wait(ctx, attempt-1); waitErr != nil {
+				return waitErr
+			}
+		}
+		err = fn(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+	}
+	return errors.Join(err, ErrExhausted)
+}
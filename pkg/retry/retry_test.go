@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 5 * time.Second, Max: 30 * time.Second}
+	want := []time.Duration{0, 5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for retry, delay := range want {
+		if got := b.Delay(retry); got != delay {
+			t.Errorf("Delay(%d) = %v, want %v", retry, got, delay)
+		}
+	}
+	if got := (Backoff{Initial: time.Second}).Delay(100); got <= 0 {
+		t.Errorf("Delay(100) without Max = %v, want a positive delay", got)
+	}
+
+	b.MinDelay = func() time.Duration { return time.Minute }
+	if got := b.Delay(1); got != time.Minute {
+		t.Errorf("Delay(1) with MinDelay = %v, want %v", got, time.Minute)
+	}
+}
+
+func TestDo(t *testing.T) {
+	boom := errors.New("boom")
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		calls := 0
+		err := Do(context.Background(), Backoff{Initial: time.Second, Max: 3 * time.Second, Attempts: 5, Clock: clock},
+			func(context.Context, int) error {
+				calls++
+				if calls < 4 {
+					return boom
+				}
+				return nil
+			})
+		if err != nil || calls != 4 {
+			t.Fatalf("Do() = %v after %d calls, want success after 4", err, calls)
+		}
+		if want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}; !slices.Equal(clock.Sleeps(), want) {
+			t.Errorf("Sleeps() = %v, want %v", clock.Sleeps(), want)
+		}
+		if got := clock.Now(); !got.Equal(time.Unix(6, 0)) {
+			t.Errorf("Now() = %v, want 6s after the start", got)
+		}
+	})
+
+	t.Run("exhausts attempts", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), Backoff{Initial: time.Second, Attempts: 3, Clock: NewFakeClock(time.Now())},
+			func(context.Context, int) error { calls++; return boom })
+		if !errors.Is(err, boom) || !errors.Is(err, ErrExhausted) || calls != 3 {
+			t.Errorf("Do() = %v after %d calls, want boom and ErrExhausted after 3", err, calls)
+		}
+	})
+
+	t.Run("stops on permanent error", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), Backoff{Initial: time.Second, Attempts: 3, Clock: NewFakeClock(time.Now())},
+			func(context.Context, int) error { calls++; return Permanent(boom) })
+		if err != boom || calls != 1 {
+			t.Errorf("Do() = %v after %d calls, want boom after 1", err, calls)
+		}
+	})
+
+	t.Run("stops when context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := Do(ctx, Backoff{Initial: time.Hour, Attempts: 3}, func(context.Context, int) error { return boom })
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Do() = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+// Package rotation replaces the service principal credential of a node with a new client secret or certificate.
+// The new credential is read from Key Vault, a file or any other secret provider and must obtain a token before the configuration is
+// changed, so a node never switches to a credential that does not work. Once it has switched, the previous
+// credential can be removed from the application.
+package rotation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/secrets"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// CertificateDir holds the certificates written by a rotation, next to the configuration
+	CertificateDir = "/etc/aks-flex-node"
+	// certificatePrefix names the certificate files owned by rotation, which are removed once replaced
+	certificatePrefix = "service-principal-"
+
+	// validationScope is requested with the new credential to prove it works
+	validationScope = "https://management.azure.com/.default"
+
+	// Key Vault content type of a certificate stored as base64 PKCS#12
+	pkcs12ContentType = "application/x-pkcs12"
+)
+
+// Credential is a service principal client secret or a PEM certificate with its private key
+type Credential struct {
+	Secret      string
+	Certificate []byte
+}
+
+// IsCertificate reports whether the credential is a certificate
+func (c *Credential) IsCertificate() bool {
+	return len(c.Certificate) > 0
+}
+
+// Equal reports whether two credentials are the same secret or certificate
+func (c *Credential) Equal(other *Credential) bool {
+	return other != nil && c.Secret == other.Secret && bytes.Equal(c.Certificate, other.Certificate)
+}
+
+// Parse interprets the content of a credential source: base64 PKCS#12 as stored by Key Vault for certificates
+// (contentType application/x-pkcs12), a PEM certificate with an unencrypted private key, or else a client secret.
+// Certificates are converted to PEM, which the kubelet token script signs with.
+func Parse(data []byte, contentType string) (*Credential, error) {
+	if strings.EqualFold(contentType, pkcs12ContentType) {
+		der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("PKCS#12 certificate is not valid base64: %w", err)
+		}
+		return parseCertificate(der)
+	}
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		return parseCertificate(data)
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return nil, fmt.Errorf("credential is empty")
+	}
+	if strings.ContainsAny(secret, "\r\n") {
+		return nil, fmt.Errorf("client secret spans several lines; expected a single secret or a PEM certificate")
+	}
+	return &Credential{Secret: secret}, nil
+}
+
+// parseCertificate checks the certificate has an RSA private key, which Microsoft Entra ID requires to
+// sign client assertions, and encodes the certificates and key as PEM
+func parseCertificate(data []byte) (*Credential, error) {
+	certs, key, err := azidentity.ParseCertificates(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		return nil, fmt.Errorf("certificate key is %T; Microsoft Entra ID requires an RSA key", key)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	var out bytes.Buffer
+	for _, cert := range certs {
+		if err := pem.Encode(&out, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return nil, err
+		}
+	}
+	if err := pem.Encode(&out, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, err
+	}
+	return &Credential{Certificate: out.Bytes()}, nil
+}
+
+// Source is where a new credential is read from: a Key Vault secret, a local file or a secret reference
+// resolved by the secret providers
+type Source struct {
+	KeyVaultSecretID string
+	File             string
+	Secret           string
+}
+
+// SourceFromConfig returns the source configured in azure.servicePrincipal.rotation
+func SourceFromConfig(cfg *config.Config) Source {
+	if !cfg.IsCredentialRotationConfigured() {
+		return Source{}
+	}
+	rotation := cfg.Azure.ServicePrincipal.Rotation
+	return Source{KeyVaultSecretID: rotation.KeyVaultSecretID, File: rotation.File, Secret: rotation.Secret}
+}
+
+// IsEmpty reports whether no source is set
+func (s Source) IsEmpty() bool {
+	return s.KeyVaultSecretID == "" && s.File == "" && s.Secret == ""
+}
+
+// String describes the source for logs
+func (s Source) String() string {
+	switch {
+	case s.KeyVaultSecretID != "":
+		return s.KeyVaultSecretID
+	case s.File != "":
+		return s.File
+	}
+	return secrets.Redact(s.Secret)
+}
+
+// reference returns the secret reference the source is read from
+func (s Source) reference() string {
+	switch {
+	case s.KeyVaultSecretID != "":
+		return secrets.KeyVaultReference(s.KeyVaultSecretID)
+	case s.File != "":
+		// The file provider only takes absolute paths; --file is relative to the working directory
+		path, err := filepath.Abs(s.File)
+		if err != nil {
+			path = s.File
+		}
+		return secrets.FileReference(path)
+	}
+	return s.Secret
+}
+
+// Read fetches the credential from the source. Key Vault is read with the current credential, which
+// stays valid until the rotation finished.
+func Read(ctx context.Context, cfg *config.Config, source Source) (*Credential, error) {
+	secret, err := secrets.Resolve(ctx, cfg, source.reference())
+	if err != nil {
+		return nil, err
+	}
+	return Parse(secret.Value, secret.ContentType)
+}
+
+// Current returns the credential the configuration uses
+func Current(cfg *config.Config) (*Credential, error) {
+	sp := cfg.Azure.ServicePrincipal
+	if sp.ClientCertificateFile == "" {
+		return &Credential{Secret: sp.ClientSecret}, nil
+	}
+	data, err := os.ReadFile(sp.ClientCertificateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service principal certificate: %w", err)
+	}
+	return &Credential{Certificate: data}, nil
+}
+
+// Validate requests a token with the credential, which fails when it is unknown to the application or expired
+func Validate(ctx context.Context, cfg *config.Config, credential *Credential) error {
+	cred, err := auth.ServicePrincipalCredential(cfg, credential.Secret, credential.Certificate)
+	if err != nil {
+		return err
+	}
+	opCtx, cancel := auth.OperationContext(ctx, cfg)
+	defer cancel()
+	if _, err := cred.GetToken(opCtx, policy.TokenRequestOptions{Scopes: []string{validationScope}}); err != nil {
+		return fmt.Errorf("new credential cannot obtain a token: %w", err)
+	}
+	return nil
+}
+
+// Apply stores the credential in the configuration file cfg was loaded from. A certificate is written to
+// CertificateDir. The previous configuration is restored if the new one does not load, and a certificate
+// written by an earlier rotation is removed once it is replaced.
+func Apply(cfg *config.Config, credential *Credential) error {
+	path := cfg.GetConfigPath()
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	previousCertificate := cfg.Azure.ServicePrincipal.ClientCertificateFile
+	secret, certificateFile := credential.Secret, ""
+	if credential.IsCertificate() {
+		certificateFile = CertificatePath(credential)
+		if err := utils.WriteFileAtomicSystem(certificateFile, credential.Certificate, 0o600); err != nil {
+			return fmt.Errorf("failed to write certificate: %w", err)
+		}
+	}
+
+	updated, err := config.SetServicePrincipalCredential(original, path, secret, certificateFile)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomicSystem(path, updated, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+
+	if _, err := config.LoadConfig(path); err != nil {
+		if restoreErr := utils.WriteFileAtomicSystem(path, original, info.Mode().Perm()); restoreErr != nil {
+			return fmt.Errorf("configuration with the new credential does not load: %w; restoring it failed: %v", err, restoreErr)
+		}
+		return fmt.Errorf("configuration with the new credential does not load, kept the previous one: %w", err)
+	}
+
+	if previousCertificate != "" && previousCertificate != certificateFile &&
+		filepath.Dir(previousCertificate) == CertificateDir && strings.HasPrefix(filepath.Base(previousCertificate), certificatePrefix) {
+		_ = utils.RunCleanupCommand(previousCertificate)
+	}
+	return nil
+}
+
+// CertificatePath returns where a certificate is stored, named by its digest so each rotation gets a new file
+func CertificatePath(credential *Credential) string {
+	digest := sha256.Sum256(credential.Certificate)
+	return filepath.Join(CertificateDir, certificatePrefix+hex.EncodeToString(digest[:8])+".pem")
+}
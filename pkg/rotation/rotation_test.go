@@ -0,0 +1,108 @@
+package rotation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// testCertificatePEM returns a self-signed certificate for key with the key first, as some tools write it
+func testCertificatePEM(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "flex-node-sp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+}
+
+func TestParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := Parse([]byte("  abc~DEF.123\n"), "")
+	if err != nil || secret.Secret != "abc~DEF.123" || secret.IsCertificate() {
+		t.Errorf("Parse(secret) = %+v, %v, want the trimmed secret", secret, err)
+	}
+
+	cert, err := Parse(testCertificatePEM(t, rsaKey), "application/x-pem-file")
+	if err != nil {
+		t.Fatalf("Parse(certificate) unexpected error: %v", err)
+	}
+	// The certificate comes first in the stored PEM, where openssl x509 looks for it
+	if block, rest := pem.Decode(cert.Certificate); block == nil || block.Type != "CERTIFICATE" || !strings.Contains(string(rest), "PRIVATE KEY") {
+		t.Errorf("Parse(certificate) = %q, want the certificate followed by the key", cert.Certificate)
+	}
+	again, err := Parse(cert.Certificate, "")
+	if err != nil || !again.Equal(cert) || again.Equal(secret) {
+		t.Errorf("Parse() of the stored certificate = %+v, %v, want the same credential", again, err)
+	}
+
+	for name, data := range map[string]string{
+		"empty":     " \n",
+		"multiline": "abc\ndef",
+		"ec key":    string(testCertificatePEM(t, ecKey)),
+		"no key":    "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+	} {
+		if _, err := Parse([]byte(data), ""); err == nil {
+			t.Errorf("Parse(%s) expected error", name)
+		}
+	}
+	if _, err := Parse([]byte("not base64!"), "application/x-pkcs12"); err == nil {
+		t.Error("Parse() expected error for invalid PKCS#12")
+	}
+}
+
+func TestReadRelativeFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "new.pem"), []byte("abc~DEF.123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	credential, err := Read(context.Background(), &config.Config{}, Source{File: "new.pem"})
+	if err != nil || credential.Secret != "abc~DEF.123" {
+		t.Errorf("Read(--file new.pem) = %+v, %v, want the secret from the file in the working directory", credential, err)
+	}
+}
+
+func TestCertificatePath(t *testing.T) {
+	first := CertificatePath(&Credential{Certificate: []byte("one")})
+	if filepath.Dir(first) != CertificateDir || !strings.HasPrefix(filepath.Base(first), certificatePrefix) {
+		t.Errorf("CertificatePath() = %q, want a rotation-owned file in %s", first, CertificateDir)
+	}
+	if first == CertificatePath(&Credential{Certificate: []byte("two")}) {
+		t.Error("CertificatePath() returned the same path for different certificates")
+	}
+}
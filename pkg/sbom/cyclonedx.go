@@ -0,0 +1,102 @@
+package sbom
+
+import "time"
+
+// CycloneDX 1.5 JSON document; see https://cyclonedx.org/docs/1.5/json/
+type cdxDocument struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string         `json:"type"`
+	BOMRef             string         `json:"bom-ref,omitempty"`
+	Name               string         `json:"name"`
+	Version            string         `json:"version,omitempty"`
+	Hashes             []cdxHash      `json:"hashes,omitempty"`
+	ExternalReferences []cdxReference `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty  `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxReference struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (inv *Inventory) cycloneDX() cdxDocument {
+	agent := cdxComponent{Type: "application", BOMRef: "component:" + agentName, Name: agentName, Version: inv.AgentVersion}
+	doc := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newSerialNumber(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: inv.CreatedAt.Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: agentName, Version: inv.AgentVersion}}},
+			Component: cdxComponent{Type: "device", BOMRef: "node:" + inv.Hostname, Name: inv.Hostname},
+		},
+		Components: []cdxComponent{agent},
+	}
+
+	for _, component := range inv.Components {
+		c := cdxComponent{
+			Type:       "application",
+			BOMRef:     "component:" + component.Component,
+			Name:       component.Component,
+			Version:    component.Version,
+			Properties: []cdxProperty{{Name: agentName + ":source", Value: component.Source}},
+		}
+		if component.SHA256 != "" {
+			c.Hashes = []cdxHash{{Alg: "SHA-256", Content: component.SHA256}}
+		}
+		if component.URL != "" {
+			c.ExternalReferences = []cdxReference{{Type: "distribution", URL: component.URL, Comment: sourceComment(component)}}
+		}
+		if component.Peer != "" {
+			c.Properties = append(c.Properties, cdxProperty{Name: agentName + ":peer", Value: component.Peer})
+		}
+		if component.ChecksumPinned {
+			c.Properties = append(c.Properties, cdxProperty{Name: agentName + ":checksumPinned", Value: "true"})
+		}
+		if !component.FetchedAt.IsZero() {
+			c.Properties = append(c.Properties, cdxProperty{Name: agentName + ":fetchedAt", Value: component.FetchedAt.Format(time.RFC3339)})
+		}
+		doc.Components = append(doc.Components, c)
+	}
+
+	for _, file := range inv.Files {
+		doc.Components = append(doc.Components, cdxComponent{
+			Type:       "file",
+			BOMRef:     "file:" + file.Path,
+			Name:       file.Path,
+			Hashes:     []cdxHash{{Alg: "SHA-256", Content: file.SHA256}},
+			Properties: []cdxProperty{{Name: agentName + ":step", Value: file.Step}},
+		})
+	}
+	return doc
+}
@@ -0,0 +1,177 @@
+// Package sbom generates a software bill of materials of the node: the agent, every component bootstrap
+// downloaded with the artifact it came from, the packages installed through the package manager, and the
+// files bootstrap installed with their digests. It is written as SPDX and CycloneDX JSON for supply-chain audits.
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+const (
+	// SPDXPath holds the SBOM of the node in SPDX 2.3 JSON
+	SPDXPath = "/var/lib/aks-flex-node/sbom.spdx.json"
+	// CycloneDXPath holds the SBOM of the node in CycloneDX 1.5 JSON
+	CycloneDXPath = "/var/lib/aks-flex-node/sbom.cdx.json"
+)
+
+// Tags set on the Arc machine when the SBOM is attached to it
+const (
+	DigestTag     = "aks-flex-node-sbom-sha256"     // SHA-256 of the SPDX document on the node
+	ComponentsTag = "aks-flex-node-sbom-components" // Installed components as name=version
+
+	// MaxTagValueLength is the longest value Azure accepts for a tag
+	MaxTagValueLength = 256
+)
+
+// Formats the SBOM is written in
+const (
+	FormatSPDX      = "spdx"
+	FormatCycloneDX = "cyclonedx"
+)
+
+const (
+	agentName = "aks-flex-node"
+
+	// SourcePackageManager marks components installed through the distribution's package manager
+	SourcePackageManager = "package-manager"
+)
+
+// systemPackages are installed by bootstrap through the package manager rather than downloaded
+var systemPackages = []string{"azcmagent", "fluent-bit"}
+
+// Replaced in tests
+var (
+	packageVersion  = dpkgVersion
+	loadProvenance  = download.LoadProvenance
+	driftManifest   = drift.ManifestPath
+	newSerialNumber = uuid.NewString
+)
+
+// Inventory is what bootstrap installed on the node
+type Inventory struct {
+	AgentVersion string
+	Hostname     string
+	CreatedAt    time.Time
+	Components   []download.Provenance // Downloaded components and packages, sorted by name
+	Files        []drift.Entry         // Files installed by bootstrap steps, sorted by path
+}
+
+// Collect reads the recorded provenance of the downloaded components and the files installed by the last
+// successful bootstrap, and looks up the versions of the packages bootstrap installs
+func Collect(agentVersion, hostname string) (*Inventory, error) {
+	components, err := loadProvenance()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range systemPackages {
+		if version := packageVersion(name); version != "" {
+			components = append(components, download.Provenance{Component: name, Version: version, Source: SourcePackageManager})
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].Component < components[j].Component
+	})
+
+	inventory := &Inventory{AgentVersion: agentVersion, Hostname: hostname, CreatedAt: time.Now().UTC(), Components: components}
+	manifest, err := drift.Load(driftManifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		inventory.Files = manifest.Files
+	}
+	return inventory, nil
+}
+
+// Encode returns the SBOM in format, FormatSPDX or FormatCycloneDX
+func (inv *Inventory) Encode(format string) ([]byte, error) {
+	var doc any
+	switch format {
+	case FormatSPDX:
+		doc = inv.spdx()
+	case FormatCycloneDX:
+		doc = inv.cycloneDX()
+	default:
+		return nil, fmt.Errorf("unknown SBOM format %q, expected %s or %s", format, FormatSPDX, FormatCycloneDX)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s SBOM: %w", format, err)
+	}
+	return append(data, '\n'), nil
+}
+
+// Write writes the SBOM in both formats and returns the SHA-256 of the SPDX document
+func (inv *Inventory) Write() (string, error) {
+	var digest string
+	for format, path := range map[string]string{FormatSPDX: SPDXPath, FormatCycloneDX: CycloneDXPath} {
+		data, err := inv.Encode(format)
+		if err != nil {
+			return "", err
+		}
+		if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := utils.WriteFileAtomicSystem(path, data, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if format == FormatSPDX {
+			sum := sha256.Sum256(data)
+			digest = hex.EncodeToString(sum[:])
+		}
+	}
+	return digest, nil
+}
+
+// Summary lists the components as name=version separated by commas, cut at the last whole component that
+// fits in limit characters, e.g. for an Azure tag value
+func (inv *Inventory) Summary(limit int) string {
+	var summary string
+	for _, component := range inv.Components {
+		item := component.Component + "=" + component.Version
+		if summary != "" {
+			item = "," + item
+		}
+		if len(summary)+len(item) > limit {
+			break
+		}
+		summary += item
+	}
+	return summary
+}
+
+// dpkgVersion returns the installed version of a Debian package, or "" when it is not installed
+func dpkgVersion(name string) string {
+	output, err := utils.RunCommandWithOutput("dpkg-query", "-W", "-f=${Version}", name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// sourceComment describes where a component was taken from
+func sourceComment(component download.Provenance) string {
+	switch component.Source {
+	case download.SourceCache:
+		return "Taken from the artifact cache"
+	case download.SourcePeer:
+		return "Downloaded from LAN peer " + component.Peer
+	case SourcePackageManager:
+		return "Installed through the package manager"
+	default:
+		return "Downloaded from its release URL"
+	}
+}
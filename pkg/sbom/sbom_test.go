@@ -0,0 +1,143 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/download"
+	"go.goms.io/aks/AKSFlexNode/pkg/drift"
+)
+
+func testInventory() *Inventory {
+	return &Inventory{
+		AgentVersion: "v1.2.3",
+		Hostname:     "edge-01",
+		CreatedAt:    time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC),
+		Components: []download.Provenance{
+			{Component: "azcmagent", Version: "1.47.0", Source: SourcePackageManager},
+			{Component: "containerd", Version: "1.7.20", URL: "https://example.com/containerd.tar.gz", SHA256: "aa", Source: download.SourcePeer, Peer: "http://10.0.0.5:8080", ChecksumPinned: true},
+			{Component: "runc", Version: "1.1.12", URL: "https://example.com/runc", SHA256: "bb", Source: download.SourceCache},
+		},
+		Files: []drift.Entry{
+			{Path: "/etc/containerd/config.toml", SHA256: "cc", Step: "ContainerdInstaller"},
+			{Path: "/usr/local/bin/runc", SHA256: "bb", Step: "RuncInstaller"},
+		},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	oldVersion, oldProvenance, oldManifest := packageVersion, loadProvenance, driftManifest
+	t.Cleanup(func() { packageVersion, loadProvenance, driftManifest = oldVersion, oldProvenance, oldManifest })
+
+	packageVersion = func(name string) string {
+		if name == "azcmagent" {
+			return "1.47.0"
+		}
+		return "" // fluent-bit is not installed
+	}
+	loadProvenance = func() ([]download.Provenance, error) {
+		return []download.Provenance{{Component: "runc", Version: "1.1.12", Source: download.SourceOrigin}}, nil
+	}
+	driftManifest = filepath.Join(dir, "drift-manifest.json")
+	binary := filepath.Join(dir, "runc")
+	if err := os.WriteFile(binary, []byte("runc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := drift.NewManifest(map[string][]string{"RuncInstaller": {binary}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.Save(driftManifest); err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := Collect("v1.2.3", "edge-01")
+	if err != nil {
+		t.Fatalf("Collect() unexpected error: %v", err)
+	}
+	var names []string
+	for _, component := range inventory.Components {
+		names = append(names, component.Component)
+	}
+	if got := strings.Join(names, ","); got != "azcmagent,runc" {
+		t.Errorf("Collect() components = %s, want azcmagent,runc", got)
+	}
+	if len(inventory.Files) != 1 || inventory.Files[0].Path != binary {
+		t.Errorf("Collect() files = %+v, want the drift manifest's", inventory.Files)
+	}
+}
+
+func TestEncodeSPDX(t *testing.T) {
+	data, err := testInventory().Encode(FormatSPDX)
+	if err != nil {
+		t.Fatalf("Encode() unexpected error: %v", err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Encode() returned invalid JSON: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.CreationInfo.Created != "2026-10-17T08:00:00Z" {
+		t.Errorf("Encode() document header = %+v, %+v", doc.SPDXVersion, doc.CreationInfo)
+	}
+	if len(doc.Packages) != 4 || len(doc.Files) != 2 {
+		t.Fatalf("Encode() = %d packages and %d files, want the agent, 3 components and 2 files", len(doc.Packages), len(doc.Files))
+	}
+	containerd := doc.Packages[2]
+	if containerd.SPDXID != "SPDXRef-Package-containerd" || containerd.DownloadLocation != "https://example.com/containerd.tar.gz" ||
+		len(containerd.Checksums) != 1 || containerd.Checksums[0].ChecksumValue != "aa" || !strings.Contains(containerd.Comment, "10.0.0.5") {
+		t.Errorf("Encode() containerd package = %+v", containerd)
+	}
+	if azcmagent := doc.Packages[1]; azcmagent.DownloadLocation != spdxNoAssertion || azcmagent.Checksums != nil {
+		t.Errorf("Encode() package without provenance = %+v, want NOASSERTION and no checksum", azcmagent)
+	}
+	if file := doc.Files[0]; file.SPDXID != "SPDXRef-File-etc-containerd-config.toml" || file.FileName != "./etc/containerd/config.toml" {
+		t.Errorf("Encode() file = %+v", file)
+	}
+	if len(doc.Relationships) != 1+3+2 {
+		t.Errorf("Encode() = %d relationships, want one per package and file", len(doc.Relationships))
+	}
+}
+
+func TestEncodeCycloneDX(t *testing.T) {
+	data, err := testInventory().Encode(FormatCycloneDX)
+	if err != nil {
+		t.Fatalf("Encode() unexpected error: %v", err)
+	}
+	var doc cdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Encode() returned invalid JSON: %v", err)
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.SpecVersion != "1.5" || !strings.HasPrefix(doc.SerialNumber, "urn:uuid:") {
+		t.Errorf("Encode() document header = %s %s %s", doc.BOMFormat, doc.SpecVersion, doc.SerialNumber)
+	}
+	if len(doc.Components) != 1+3+2 {
+		t.Fatalf("Encode() = %d components, want the agent, 3 components and 2 files", len(doc.Components))
+	}
+	runc := doc.Components[3]
+	if runc.Name != "runc" || runc.Hashes[0].Content != "bb" || runc.ExternalReferences[0].URL != "https://example.com/runc" ||
+		runc.Properties[0].Value != download.SourceCache {
+		t.Errorf("Encode() runc component = %+v", runc)
+	}
+	if file := doc.Components[5]; file.Type != "file" || file.Name != "/usr/local/bin/runc" || file.Properties[0].Value != "RuncInstaller" {
+		t.Errorf("Encode() file component = %+v", file)
+	}
+
+	if _, err := testInventory().Encode("swid"); err == nil {
+		t.Error("Encode() expected error for an unknown format")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	inventory := testInventory()
+	if got := inventory.Summary(256); got != "azcmagent=1.47.0,containerd=1.7.20,runc=1.1.12" {
+		t.Errorf("Summary(256) = %q", got)
+	}
+	if got := inventory.Summary(40); got != "azcmagent=1.47.0,containerd=1.7.20" {
+		t.Errorf("Summary(40) = %q, want only the components that fit", got)
+	}
+}
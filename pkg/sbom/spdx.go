@@ -0,0 +1,134 @@
+package sbom
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SPDX 2.3 JSON document; see https://spdx.github.io/spdx-spec/v2.3/
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files,omitempty"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string         `json:"name"`
+	SPDXID           string         `json:"SPDXID"`
+	VersionInfo      string         `json:"versionInfo,omitempty"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	Comment          string         `json:"comment,omitempty"`
+}
+
+type spdxFile struct {
+	FileName         string         `json:"fileName"`
+	SPDXID           string         `json:"SPDXID"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	CopyrightText    string         `json:"copyrightText"`
+	Comment          string         `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const (
+	spdxDocumentID  = "SPDXRef-DOCUMENT"
+	spdxNoAssertion = "NOASSERTION"
+)
+
+// spdxInvalidID matches the characters an SPDX identifier cannot hold
+var spdxInvalidID = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+func (inv *Inventory) spdx() spdxDocument {
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      spdxDocumentID,
+		Name:        agentName + "-" + inv.Hostname,
+		// Unique per document as the specification requires
+		DocumentNamespace: fmt.Sprintf("https://go.goms.io/aks/AKSFlexNode/sbom/%s-%s", inv.Hostname, newSerialNumber()),
+		CreationInfo: spdxCreationInfo{
+			Created:  inv.CreatedAt.Format(time.RFC3339),
+			Creators: []string{"Tool: " + agentName + "-" + inv.AgentVersion},
+		},
+	}
+
+	agent := spdxPackage{
+		Name:             agentName,
+		SPDXID:           spdxID("Package", agentName),
+		VersionInfo:      inv.AgentVersion,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+	}
+	doc.Packages = append(doc.Packages, agent)
+	doc.Relationships = append(doc.Relationships, spdxRelationship{spdxDocumentID, "DESCRIBES", agent.SPDXID})
+
+	for _, component := range inv.Components {
+		pkg := spdxPackage{
+			Name:             component.Component,
+			SPDXID:           spdxID("Package", component.Component),
+			VersionInfo:      component.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			Comment:          sourceComment(component),
+		}
+		if component.URL != "" {
+			pkg.DownloadLocation = component.URL
+		}
+		if component.SHA256 != "" {
+			pkg.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: component.SHA256}}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{agent.SPDXID, "DEPENDS_ON", pkg.SPDXID})
+	}
+
+	for _, file := range inv.Files {
+		f := spdxFile{
+			FileName:         "." + file.Path,
+			SPDXID:           spdxID("File", file.Path),
+			Checksums:        []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: file.SHA256}},
+			LicenseConcluded: spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			Comment:          "Installed by bootstrap step " + file.Step,
+		}
+		doc.Files = append(doc.Files, f)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{spdxDocumentID, "DESCRIBES", f.SPDXID})
+	}
+	return doc
+}
+
+// spdxID returns the SPDX identifier of an element, e.g. SPDXRef-File-usr-bin-kubelet
+func spdxID(kind, name string) string {
+	return "SPDXRef-" + kind + "-" + spdxInvalidID.ReplaceAllString(strings.TrimPrefix(name, "/"), "-")
+}
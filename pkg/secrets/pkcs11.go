@@ -0,0 +1,141 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// pkcs11Tool is the OpenSC tool that reads data objects from a token
+const pkcs11Tool = "pkcs11-tool"
+
+// pkcs11PINVariable passes the user PIN to pkcs11-tool in its environment, which only the agent's user can read,
+// instead of on its command line, which every local user can
+const pkcs11PINVariable = "AKS_FLEX_NODE_PKCS11_PIN"
+
+// pkcs11URI holds the attributes of an RFC 7512 URI the provider uses
+type pkcs11URI struct {
+	token      string // Label of the token
+	object     string // Label of the data object holding the secret
+	modulePath string // PKCS#11 library of the HSM, e.g. /usr/lib/softhsm/libsofthsm2.so
+	pin        string // User PIN, from pin-value or read from pin-source
+}
+
+// pkcs11Provider reads data objects from an HSM or another PKCS#11 token with pkcs11-tool, so the agent does
+// not have to load the vendor's library itself
+type pkcs11Provider struct {
+	run func(ctx context.Context, env []string, name string, args ...string) (string, error)
+}
+
+func newPKCS11Provider() *pkcs11Provider {
+	return &pkcs11Provider{run: utils.RunCommandWithEnvContext}
+}
+
+func (p *pkcs11Provider) Resolve(ctx context.Context, reference string) (*Secret, error) {
+	uri, err := parsePKCS11URI(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "aks-flex-node-pkcs11-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	outputFile := filepath.Join(dir, "object")
+
+	args := []string{"--module", uri.modulePath, "--token-label", uri.token,
+		"--read-object", "--type", "data", "--label", uri.object, "--output-file", outputFile}
+	var env []string
+	if uri.pin != "" {
+		args = append(args, "--login", "--pin", "env:"+pkcs11PINVariable)
+		env = append(env, pkcs11PINVariable+"="+uri.pin)
+	}
+	if output, err := p.run(ctx, env, pkcs11Tool, args...); err != nil {
+		return nil, fmt.Errorf("failed to read object %s from token %s: %w: %s", uri.object, uri.token, err, strings.TrimSpace(output))
+	}
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s from token %s: %w", uri.object, uri.token, err)
+	}
+	return &Secret{Value: data}, nil
+}
+
+// parsePKCS11URI parses the part of a PKCS#11 URI after "pkcs11:", e.g.
+// token=node;object=webhook-secret?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-source=/etc/aks-flex-node/hsm-pin
+func parsePKCS11URI(reference string) (*pkcs11URI, error) {
+	path, query, _ := strings.Cut(reference, "?")
+	uri := &pkcs11URI{}
+	attributes := map[string]*string{"token": &uri.token, "object": &uri.object}
+	for _, attribute := range strings.Split(path, ";") {
+		name, value, err := pkcs11Attribute(attribute)
+		if err != nil {
+			return nil, err
+		}
+		if field, ok := attributes[name]; ok {
+			*field = value
+		}
+	}
+	var pinSource string
+	queryAttributes := map[string]*string{"module-path": &uri.modulePath, "pin-value": &uri.pin, "pin-source": &pinSource}
+	for _, attribute := range strings.Split(query, "&") {
+		if attribute == "" {
+			continue
+		}
+		name, value, err := pkcs11Attribute(attribute)
+		if err != nil {
+			return nil, err
+		}
+		if field, ok := queryAttributes[name]; ok {
+			*field = value
+		}
+	}
+
+	if uri.token == "" || uri.object == "" || uri.modulePath == "" {
+		return nil, fmt.Errorf("PKCS#11 URI requires the token and object attributes and the module-path query attribute")
+	}
+	if pinSource != "" {
+		if uri.pin != "" {
+			return nil, fmt.Errorf("PKCS#11 URI cannot set both pin-value and pin-source")
+		}
+		pin, err := os.ReadFile(strings.TrimPrefix(pinSource, "file:"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PKCS#11 PIN: %w", err)
+		}
+		uri.pin = strings.TrimSpace(string(pin))
+	}
+	return uri, nil
+}
+
+// pkcs11Attribute splits and unescapes a name=value attribute of a PKCS#11 URI
+func pkcs11Attribute(attribute string) (string, string, error) {
+	name, value, ok := strings.Cut(attribute, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid PKCS#11 URI attribute %q: expected name=value", name)
+	}
+	unescaped, err := url.PathUnescape(value)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid PKCS#11 URI attribute %s: %w", name, err)
+	}
+	return name, unescaped, nil
+}
+
+// redactPKCS11 replaces the value of the pin-value attribute of a PKCS#11 URI
+func redactPKCS11(reference string) string {
+	path, query, ok := strings.Cut(reference, "?")
+	if !ok {
+		return reference
+	}
+	attributes := strings.Split(query, "&")
+	for i, attribute := range attributes {
+		if strings.HasPrefix(attribute, "pin-value=") {
+			attributes[i] = "pin-value=REDACTED"
+		}
+	}
+	return path + "?" + strings.Join(attributes, "&")
+}
@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/azerrors"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/keyvault"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// Schemes of the providers built into the agent
+const (
+	SchemeEnv      = "env"      // env:NAME reads an environment variable of the agent
+	SchemeFile     = "file"     // file:/path reads a file
+	SchemeKeyVault = "keyvault" // keyvault:https://<vault>.vault.azure.net/secrets/<name>[/<version>]
+	SchemePKCS11   = "pkcs11"   // pkcs11:token=<label>;object=<label>?module-path=<library>, as defined by RFC 7512
+)
+
+// envProvider reads environment variables, e.g. set by an EnvironmentFile of the agent's systemd unit
+type envProvider struct{}
+
+func (envProvider) Resolve(ctx context.Context, name string) (*Secret, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return &Secret{Value: []byte(value)}, nil
+}
+
+// fileProvider reads files, e.g. written by a configuration management tool
+type fileProvider struct{}
+
+func (fileProvider) Resolve(ctx context.Context, path string) (*Secret, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("%q is not an absolute path", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{Value: data}, nil
+}
+
+// keyVaultProvider reads Key Vault secrets with the agent's Azure credential
+type keyVaultProvider struct {
+	config *config.Config
+}
+
+func newKeyVaultProvider(cfg *config.Config) Provider {
+	return &keyVaultProvider{config: cfg}
+}
+
+func (p *keyVaultProvider) Resolve(ctx context.Context, reference string) (*Secret, error) {
+	id, err := keyvault.ParseSecretID(reference)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := auth.NewAuthProvider().UserCredential(p.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	clientOptions := auth.ClientOptions(p.config)
+	client, err := keyvault.NewSecretClient(id.VaultURL, cred, &clientOptions)
+	if err != nil {
+		return nil, err
+	}
+	opCtx, cancel := auth.OperationContext(ctx, p.config)
+	defer cancel()
+	secret, err := client.GetSecret(opCtx, id.Name, id.Version)
+	if err != nil {
+		return nil, azerrors.Wrap(fmt.Errorf("failed to read %s: %w", id, err))
+	}
+	return &Secret{Value: []byte(secret.Value), ContentType: secret.ContentType}, nil
+}
+
+// KeyVaultReference returns the reference of a Key Vault secret ID
+func KeyVaultReference(secretID string) string {
+	return SchemeKeyVault + ":" + secretID
+}
+
+// FileReference returns the reference of a file
+func FileReference(path string) string {
+	return SchemeFile + ":" + path
+}
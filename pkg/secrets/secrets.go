@@ -0,0 +1,126 @@
+// Package secrets resolves secret references such as env:LOG_ANALYTICS_KEY or
+// keyvault:https://myvault.vault.azure.net/secrets/webhook to the secret they name. A reference is a scheme,
+// a colon and what the provider registered for the scheme understands. The configuration only stores
+// references, so a new backend, such as a HashiCorp Vault used on-premises, is added by registering a
+// provider from an init function of a package compiled into a custom build of the agent, without changing
+// how the configuration is parsed.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// Secret is a resolved secret
+type Secret struct {
+	Value []byte
+	// ContentType is what the backend reports about the value, e.g. application/x-pkcs12 for a certificate
+	// stored in Key Vault; empty when the backend has no such metadata
+	ContentType string
+}
+
+// Provider resolves the references of one scheme
+type Provider interface {
+	// Resolve returns the secret named by reference, which is the reference without its scheme and colon
+	Resolve(ctx context.Context, reference string) (*Secret, error)
+}
+
+// Factory creates the provider for the configuration the references are read from
+type Factory func(cfg *config.Config) Provider
+
+// schemePattern matches a URI scheme as defined by RFC 3986
+var schemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Factory{}
+)
+
+func init() {
+	Register(SchemeEnv, func(cfg *config.Config) Provider { return envProvider{} })
+	Register(SchemeFile, func(cfg *config.Config) Provider { return fileProvider{} })
+	Register(SchemeKeyVault, newKeyVaultProvider)
+	Register(SchemePKCS11, func(cfg *config.Config) Provider { return newPKCS11Provider() })
+}
+
+// Register makes a provider available for the references of scheme. It is meant to be called from an init
+// function and panics when the scheme is invalid or taken.
+func Register(scheme string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !schemePattern.MatchString(scheme) || factory == nil {
+		panic("secrets: Register requires a lowercase scheme and a factory")
+	}
+	if _, ok := registry[scheme]; ok {
+		panic("secrets: Register called twice for " + scheme)
+	}
+	registry[scheme] = factory
+}
+
+// Schemes returns the schemes providers are registered for
+func Schemes() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Resolve returns the secret reference names
+func Resolve(ctx context.Context, cfg *config.Config, reference string) (*Secret, error) {
+	factory, err := lookup(reference)
+	if err != nil {
+		return nil, err
+	}
+	scheme, rest, _ := strings.Cut(reference, ":")
+	secret, err := factory(cfg).Resolve(ctx, rest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s secret: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+// ResolveString returns the secret reference names as a single line of text, as keys and passwords are stored
+func ResolveString(ctx context.Context, cfg *config.Config, reference string) (string, error) {
+	secret, err := Resolve(ctx, cfg, reference)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(secret.Value))
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", Redact(reference))
+	}
+	return value, nil
+}
+
+// Redact returns the reference for logs, without the PIN a PKCS#11 URI may carry
+func Redact(reference string) string {
+	if scheme, _, _ := strings.Cut(reference, ":"); scheme == SchemePKCS11 {
+		return redactPKCS11(reference)
+	}
+	return reference
+}
+
+// lookup returns the factory registered for the scheme of reference
+func lookup(reference string) (Factory, error) {
+	scheme, rest, ok := strings.Cut(reference, ":")
+	if !ok || !schemePattern.MatchString(scheme) || rest == "" {
+		return nil, fmt.Errorf("invalid secret reference %q: expected <scheme>:<reference>, e.g. env:NAME", Redact(reference))
+	}
+	mu.Lock()
+	factory, ok := registry[scheme]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no secret provider is registered for %q; available schemes are: %s", scheme, strings.Join(Schemes(), ", "))
+	}
+	return factory, nil
+}
@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// staticProvider resolves every reference to the reference itself
+type staticProvider struct{}
+
+func (staticProvider) Resolve(ctx context.Context, reference string) (*Secret, error) {
+	return &Secret{Value: []byte(reference)}, nil
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AKS_FLEX_NODE_TEST_SECRET", "env-key")
+	Register("test-vault", func(cfg *config.Config) Provider { return staticProvider{} })
+
+	tests := []struct {
+		name      string
+		reference string
+		want      string
+		wantErr   string
+	}{
+		{name: "environment variable", reference: "env:AKS_FLEX_NODE_TEST_SECRET", want: "env-key"},
+		{name: "file", reference: FileReference(keyFile), want: "file-key"},
+		{name: "registered provider", reference: "test-vault:kv/data/node#key", want: "kv/data/node#key"},
+		{name: "unset environment variable", reference: "env:AKS_FLEX_NODE_TEST_UNSET", wantErr: "AKS_FLEX_NODE_TEST_UNSET is not set"},
+		{name: "relative file", reference: "file:key", wantErr: "not an absolute path"},
+		{name: "invalid key vault ID", reference: KeyVaultReference("flex-node-sp"), wantErr: "invalid Key Vault secret ID"},
+		{name: "unknown scheme", reference: "vault:kv/data/node", wantErr: "available schemes are: env, file, keyvault, pkcs11, test-vault"},
+		{name: "no scheme", reference: "AKS_FLEX_NODE_TEST_SECRET", wantErr: "invalid secret reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveString(context.Background(), &config.Config{}, tt.reference)
+			if tt.wantErr == "" {
+				if err != nil || got != tt.want {
+					t.Errorf("ResolveString() = %q, %v, want %q", got, err, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveString() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if !slices.Contains(Schemes(), "test-vault") {
+		t.Errorf("Schemes() = %v, want the registered scheme", Schemes())
+	}
+}
+
+func TestParsePKCS11URI(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	if err := os.WriteFile(pinFile, []byte("1234\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		reference string
+		want      *pkcs11URI
+		wantErr   string
+	}{
+		{
+			name:      "pin from file",
+			reference: "token=flex%20node;object=webhook-secret;type=data?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-source=file:" + pinFile,
+			want:      &pkcs11URI{token: "flex node", object: "webhook-secret", modulePath: "/usr/lib/softhsm/libsofthsm2.so", pin: "1234"},
+		},
+		{
+			name:      "pin value",
+			reference: "token=node;object=la-key?module-path=/opt/hsm/lib.so&pin-value=0000",
+			want:      &pkcs11URI{token: "node", object: "la-key", modulePath: "/opt/hsm/lib.so", pin: "0000"},
+		},
+		{name: "missing module", reference: "token=node;object=la-key", wantErr: "module-path"},
+		{name: "both pins", reference: "token=node;object=k?module-path=/m.so&pin-value=1&pin-source=" + pinFile, wantErr: "both pin-value and pin-source"},
+		{name: "malformed attribute", reference: "token;object=k?module-path=/m.so", wantErr: "expected name=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePKCS11URI(tt.reference)
+			if tt.wantErr == "" {
+				if err != nil || !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("parsePKCS11URI() = %+v, %v, want %+v", got, err, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parsePKCS11URI() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPKCS11Provider(t *testing.T) {
+	var gotArgs, gotEnv []string
+	provider := newPKCS11Provider()
+	provider.run = func(ctx context.Context, env []string, name string, args ...string) (string, error) {
+		gotArgs, gotEnv = args, env
+		// pkcs11-tool writes the object to the file given with --output-file
+		outputFile := args[slices.Index(args, "--output-file")+1]
+		return "", os.WriteFile(outputFile, []byte("hsm-secret"), 0o600)
+	}
+
+	secret, err := provider.Resolve(context.Background(), "token=node;object=webhook?module-path=/opt/hsm/lib.so&pin-value=0000")
+	if err != nil || string(secret.Value) != "hsm-secret" {
+		t.Fatalf("Resolve() = %v, %v, want the object", secret, err)
+	}
+	for _, want := range []string{"--module /opt/hsm/lib.so", "--token-label node", "--label webhook", "--login --pin env:" + pkcs11PINVariable} {
+		if !strings.Contains(strings.Join(gotArgs, " "), want) {
+			t.Errorf("pkcs11-tool args = %v, want %q", gotArgs, want)
+		}
+	}
+	// Command lines are world-readable in /proc, so the PIN may only reach pkcs11-tool through its environment
+	for _, arg := range gotArgs {
+		if strings.Contains(arg, "0000") {
+			t.Errorf("pkcs11-tool args = %v, want the PIN kept off the command line", gotArgs)
+		}
+	}
+	if !slices.Contains(gotEnv, pkcs11PINVariable+"=0000") {
+		t.Errorf("pkcs11-tool env = %v, want the PIN in %s", gotEnv, pkcs11PINVariable)
+	}
+
+	if got := Redact("pkcs11:token=node;object=webhook?module-path=/opt/hsm/lib.so&pin-value=0000"); strings.Contains(got, "0000") {
+		t.Errorf("Redact() = %q, want the PIN removed", got)
+	}
+}
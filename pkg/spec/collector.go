@@ -100,12 +100,12 @@ func (c *ManagedClusterSpecCollector) Collect(ctx context.Context) (*ManagedClus
 			return nil, fmt.Errorf("subscription ID missing")
 		}
 
-		cred, err := c.authProvider.UserCredential(c.cfg)
+		cred, err := c.authProvider.ClusterCredential(c.cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get credential: %w", err)
 		}
 
-		mcClient, err := armcontainerservice.NewManagedClustersClient(subscriptionID, cred, nil)
+		mcClient, err := armcontainerservice.NewManagedClustersClient(subscriptionID, cred, auth.ARMClientOptions(c.cfg))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create managed clusters client: %w", err)
 		}
@@ -113,7 +113,9 @@ func (c *ManagedClusterSpecCollector) Collect(ctx context.Context) (*ManagedClus
 	}
 
 	c.logger.Infof("Collecting managed cluster spec for %s/%s", clusterRG, clusterName)
-	resp, err := c.client.Get(ctx, clusterRG, clusterName, nil)
+	opCtx, cancel := auth.OperationContext(ctx, c.cfg)
+	defer cancel()
+	resp, err := c.client.Get(opCtx, clusterRG, clusterName, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AKS managed cluster via SDK: %w", err)
 	}
@@ -0,0 +1,334 @@
+// Package state keeps a copy of the agent's state directory in a backend that outlives the node's disk, a
+// directory on a network mount or an Azure Storage container, so diskless and re-imaged nodes resume as the
+// same node. The state directory stays the working copy: components read and write their files there, and
+// the copy is restored into an empty state directory before bootstrap and updated after it.
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/blob"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Dir is the agent's state directory
+const Dir = "/var/lib/aks-flex-node"
+
+// maxFileSize bounds the files that are copied; larger files are logs or caches rather than state
+const maxFileSize = 4 << 20
+
+// excluded are the parts of the state directory that are not copied. The originals of host files belong to
+// the disk image they were taken from, and restoring them on a new image would put back stale files.
+var excluded = []string{"backups"}
+
+// Replaced in tests
+var dir = Dir
+
+// ErrNotFound is returned by Store.Get for a key that does not exist
+var ErrNotFound = errors.New("state not found")
+
+// Store holds the files of the state directory by key, their slash-separated path relative to it
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context) ([]string, error)
+}
+
+// NewStore returns the configured backend, or nil when the state is only kept on the node
+func NewStore(cfg *config.Config) (Store, error) {
+	state := cfg.Agent.State
+	switch state.Backend {
+	case "":
+		return nil, nil
+	case config.StateBackendFile:
+		return NewFileStore(state.Path), nil
+	case config.StateBackendAzureBlob:
+		cred, err := auth.NewAuthProvider().UserCredential(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credential for the state container: %w", err)
+		}
+		options := auth.ClientOptions(cfg)
+		client, err := blob.NewClient(state.AzureBlob.ContainerURL, cred, &options)
+		if err != nil {
+			return nil, err
+		}
+		prefix := state.AzureBlob.Prefix
+		if prefix == "" {
+			if prefix, err = os.Hostname(); err != nil {
+				return nil, fmt.Errorf("failed to determine hostname for the state prefix: %w", err)
+			}
+		}
+		return NewBlobStore(client, prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", state.Backend)
+	}
+}
+
+// Restore copies the state from the configured backend into the state directory when the directory holds
+// no state, e.g. after the node was re-imaged. It returns whether state was restored.
+func Restore(ctx context.Context, cfg *config.Config, logger *logrus.Logger) (bool, error) {
+	store, err := NewStore(cfg)
+	if err != nil || store == nil {
+		return false, err
+	}
+	local := NewFileStore(dir)
+	existing, err := local.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(existing) > 0 {
+		return false, nil
+	}
+	keys, err := store.List(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to list the saved state: %w", err)
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+	for _, key := range keys {
+		data, err := store.Get(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("failed to read saved state %s: %w", key, err)
+		}
+		if err := local.Put(ctx, key, data); err != nil {
+			return false, err
+		}
+	}
+	logger.Infof("Restored %d state files from the %s state backend into %s", len(keys), cfg.Agent.State.Backend, dir)
+	return true, nil
+}
+
+// Save updates the copy in the configured backend to match the state directory, removing files that are no
+// longer there. It does nothing when no backend is configured.
+func Save(ctx context.Context, cfg *config.Config, logger *logrus.Logger) error {
+	store, err := NewStore(cfg)
+	if err != nil || store == nil {
+		return err
+	}
+	if err := mirror(ctx, NewFileStore(dir), store); err != nil {
+		return err
+	}
+	logger.Debugf("Saved the state in %s to the %s state backend", dir, cfg.Agent.State.Backend)
+	return nil
+}
+
+// mirror makes dst hold exactly the files of src
+func mirror(ctx context.Context, src, dst Store) error {
+	keys, err := src.List(ctx)
+	if err != nil {
+		return err
+	}
+	stale, err := dst.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the saved state: %w", err)
+	}
+	for _, key := range keys {
+		data, err := src.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue // Removed since it was listed
+		}
+		if err != nil {
+			return err
+		}
+		if err := dst.Put(ctx, key, data); err != nil {
+			return fmt.Errorf("failed to save state %s: %w", key, err)
+		}
+	}
+	for _, key := range stale {
+		if slices.Contains(keys, key) {
+			continue
+		}
+		if err := dst.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to remove saved state %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// FileStore keeps the state as files below a directory
+type FileStore struct {
+	root string
+}
+
+// NewFileStore creates a store of the files below root
+func NewFileStore(root string) *FileStore {
+	return &FileStore{root: root}
+}
+
+// Get implements Store
+func (s *FileStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return data, err
+}
+
+// Put implements Store. Files are written readable by their owner only, as they may hold credentials' metadata.
+// They and the directories created for them belong to the owner of the root, such as the agent's service user,
+// which reads and rewrites parts of the state directory without sudo.
+func (s *FileStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	owner, err := ownerOf(s.root)
+	if err != nil {
+		return err
+	}
+	var created []string
+	for d := filepath.Dir(path); !exists(d); d = filepath.Dir(d) {
+		created = append(created, d)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := utils.WriteFileAtomicSystem(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	for _, p := range append(created, path) {
+		if err := utils.RunSystemCommand("chown", owner, p); err != nil {
+			return fmt.Errorf("failed to set the owner of %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// ownerOf returns the uid:gid owning path, or its closest existing parent when path does not exist yet
+func ownerOf(path string) (string, error) {
+	for !exists(path) && filepath.Dir(path) != path {
+		path = filepath.Dir(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("cannot read the owner of %s", path)
+	}
+	return fmt.Sprintf("%d:%d", st.Uid, st.Gid), nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Delete implements Store
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// List implements Store. It leaves out excluded directories, temporary files of atomic writes and files over
+// maxFileSize. A missing directory holds no state.
+func (s *FileStore) List(_ context.Context) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if slices.Contains(excluded, key) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".tmp-") || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.root, err)
+	}
+	return keys, nil
+}
+
+// path returns the file of key, refusing keys that leave the root
+func (s *FileStore) path(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid state key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// BlobStore keeps the state as blobs named prefix/key in an Azure Storage container
+type BlobStore struct {
+	client *blob.Client
+	prefix string
+}
+
+// NewBlobStore creates a store of the blobs below prefix
+func NewBlobStore(client *blob.Client, prefix string) *BlobStore {
+	return &BlobStore{client: client, prefix: strings.Trim(prefix, "/") + "/"}
+}
+
+// Get implements Store
+func (s *BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.prefix+key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return data, err
+}
+
+// Put implements Store
+func (s *BlobStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.client.Put(ctx, s.prefix+key, data)
+}
+
+// Delete implements Store
+func (s *BlobStore) Delete(ctx context.Context, key string) error {
+	return s.client.Delete(ctx, s.prefix+key)
+}
+
+// List implements Store
+func (s *BlobStore) List(ctx context.Context) ([]string, error) {
+	names, err := s.client.List(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, strings.TrimPrefix(name, s.prefix))
+	}
+	return keys, nil
+}
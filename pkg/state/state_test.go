@@ -0,0 +1,151 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func useStateDir(t *testing.T) string {
+	t.Helper()
+	old := dir
+	dir = t.TempDir()
+	t.Cleanup(func() { dir = old })
+	return dir
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"reboot-pending.json":         "{}",
+		"webhook/pending-spec.json":   "{}",
+		"backups/index.json":          "{}",
+		".tmp-drift-manifest.json-12": "partial",
+	})
+	s := NewFileStore(root)
+
+	keys, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	slices.Sort(keys)
+	if want := []string{"reboot-pending.json", "webhook/pending-spec.json"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() = %v, want %v", keys, want)
+	}
+
+	if err := s.Put(ctx, "gitops/last.json", []byte("rev")); err != nil {
+		t.Fatalf("Put() unexpected error: %v", err)
+	}
+	if data, err := s.Get(ctx, "gitops/last.json"); err != nil || string(data) != "rev" {
+		t.Errorf("Get() = %q, %v, want rev", data, err)
+	}
+	if err := s.Delete(ctx, "gitops/last.json"); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if _, err := s.Get(ctx, "gitops/last.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, "../escape", nil); err == nil {
+		t.Error("Put() accepted a key outside the store")
+	}
+
+	if keys, err := NewFileStore(filepath.Join(root, "missing")).List(ctx); err != nil || len(keys) != 0 {
+		t.Errorf("List() of a missing directory = %v, %v, want no keys", keys, err)
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.New()
+	local := useStateDir(t)
+	remote := t.TempDir()
+	cfg := &config.Config{Agent: config.AgentConfig{State: config.StateConfig{Backend: config.StateBackendFile, Path: remote}}}
+
+	writeFiles(t, local, map[string]string{"reboot-pending.json": "reboot", "applied-nodespec.json": "spec"})
+	writeFiles(t, remote, map[string]string{"stale.json": "old"})
+	if err := Save(ctx, cfg, logger); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	keys, _ := NewFileStore(remote).List(ctx)
+	slices.Sort(keys)
+	if want := []string{"applied-nodespec.json", "reboot-pending.json"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("saved state = %v, want %v", keys, want)
+	}
+
+	// State is not restored over existing state
+	if restored, err := Restore(ctx, cfg, logger); err != nil || restored {
+		t.Errorf("Restore() with local state = %v, %v, want false", restored, err)
+	}
+
+	// A re-imaged node has an empty state directory
+	if err := os.RemoveAll(local); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := Restore(ctx, cfg, logger); err != nil || !restored {
+		t.Fatalf("Restore() = %v, %v, want true", restored, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(local, "reboot-pending.json")); err != nil || string(data) != "reboot" {
+		t.Errorf("restored reboot-pending.json = %q, %v, want reboot", data, err)
+	}
+
+	// Without a backend nothing happens
+	cfg.Agent.State = config.StateConfig{}
+	if restored, err := Restore(ctx, cfg, logger); err != nil || restored {
+		t.Errorf("Restore() without a backend = %v, %v, want false", restored, err)
+	}
+}
+
+func TestRestoreKeepsStateOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing file owners requires root")
+	}
+	ctx := context.Background()
+	local := useStateDir(t)
+	remote := t.TempDir()
+	cfg := &config.Config{Agent: config.AgentConfig{State: config.StateConfig{Backend: config.StateBackendFile, Path: remote}}}
+	writeFiles(t, remote, map[string]string{"gitops/applied-revision": "0123abcd\n"})
+
+	// The agent's service user owns the state directory and reads and rewrites the gitops files without sudo
+	const agentUID, agentGID = 65534, 65534
+	if err := os.Chmod(filepath.Dir(local), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(local, agentUID, agentGID); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := Restore(ctx, cfg, logrus.New()); err != nil || !restored {
+		t.Fatalf("Restore() = %v, %v, want true", restored, err)
+	}
+
+	cmd := exec.Command("sh", "-c", "cat gitops/applied-revision && printf 4567cdef > gitops/applied-revision.tmp && mv gitops/applied-revision.tmp gitops/applied-revision")
+	cmd.Dir = local
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: agentUID, Gid: agentGID}}
+	output, err := cmd.CombinedOutput()
+	if err != nil || string(output) != "0123abcd\n" {
+		t.Errorf("agent user reading and rewriting the restored revision = %q, %v, want it to succeed", output, err)
+	}
+}
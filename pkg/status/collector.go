@@ -11,8 +11,13 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/guestconfig"
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/osimage"
+	"go.goms.io/aks/AKSFlexNode/pkg/patching"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -59,12 +64,25 @@ func (c *Collector) CollectStatus(ctx context.Context) (*NodeStatus, error) {
 	}
 	status.ArcStatus = arcStatus
 
+	// Pending reboots are checked by the daemon, which runs the detection tools with the needed privileges
+	osPatching, err := patching.ReadState()
+	if err != nil {
+		c.logger.Debugf("Failed to read OS patching state: %v", err)
+	}
+	status.OSPatching = osPatching
+
+	report, err := bootstrapper.LoadReport()
+	if err != nil {
+		c.logger.Debugf("Failed to read the bootstrap report: %v", err)
+	}
+	status.LastBootstrap = report
+
 	return status, nil
 }
 
 // getKubeletVersion gets the kubelet version
 func (c *Collector) getKubeletVersion(ctx context.Context) string {
-	output, err := c.runCommand(ctx, "/usr/local/bin/kubelet", "--version")
+	output, err := c.runCommand(ctx, osimage.BinPath("/usr/local/bin/kubelet"), "--version")
 	if err != nil {
 		c.logger.Warnf("Failed to get kubelet version: %v", err)
 		return "unknown"
@@ -134,6 +152,25 @@ func (c *Collector) collectArcStatus(ctx context.Context) (ArcStatus, error) {
 		status.Registered = false
 	}
 
+	// Extension state is recorded by the Arc installer, which has the Azure credentials to read it
+	extensions, err := arc.ReadExtensionsState()
+	if err != nil {
+		c.logger.Debugf("Failed to read Arc extension state: %v", err)
+	} else if extensions != nil {
+		status.Extensions = extensions.Extensions
+		status.ExtensionsUpdatedAt = extensions.UpdatedAt
+	}
+
+	// Guest configuration compliance is recorded by the preflight check and refreshed by the daemon
+	guestConfiguration, err := guestconfig.ReadState()
+	if err != nil {
+		c.logger.Debugf("Failed to read guest configuration state: %v", err)
+	} else if guestConfiguration != nil {
+		status.GuestConfiguration = guestConfiguration.Assignments
+		status.GuestConfigurationConflicts = guestConfiguration.Conflicts
+		status.GuestConfigurationUpdatedAt = guestConfiguration.UpdatedAt
+	}
+
 	return status, nil
 }
 
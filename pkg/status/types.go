@@ -2,6 +2,11 @@ package status
 
 import (
 	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/azure/guestconfig"
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/patching"
 )
 
 // NodeStatus represents the current status and health information of the AKS edge node
@@ -20,6 +25,12 @@ type NodeStatus struct {
 	// Azure Arc status
 	ArcStatus ArcStatus `json:"arcStatus"`
 
+	// OS updates waiting for a reboot or service restarts, as of the agent daemon's last check
+	OSPatching *patching.State `json:"osPatching,omitempty"`
+
+	// Time, retries and downloads of each step of the last bootstrap
+	LastBootstrap *bootstrapper.Report `json:"lastBootstrap,omitempty"`
+
 	// Metadata
 	LastUpdated  time.Time `json:"lastUpdated"`
 	AgentVersion string    `json:"agentVersion"`
@@ -35,4 +46,14 @@ type ArcStatus struct {
 	ResourceGroup string    `json:"resourceGroup,omitempty"`
 	LastHeartbeat time.Time `json:"lastHeartbeat,omitempty"`
 	AgentVersion  string    `json:"agentVersion,omitempty"`
+
+	// Provisioning state of the extensions declared in azure.arc.extensions, as of the last Arc reconciliation
+	Extensions          []arc.ExtensionStatus `json:"extensions,omitempty"`
+	ExtensionsUpdatedAt time.Time             `json:"extensionsUpdatedAt,omitempty"`
+
+	// Azure Policy guest configurations assigned to the machine, their compliance, and the ones that
+	// would undo settings applied by bootstrap
+	GuestConfiguration          []guestconfig.Assignment `json:"guestConfiguration,omitempty"`
+	GuestConfigurationConflicts []string                 `json:"guestConfigurationConflicts,omitempty"`
+	GuestConfigurationUpdatedAt time.Time                `json:"guestConfigurationUpdatedAt,omitempty"`
 }
@@ -0,0 +1,259 @@
+// Package telemetry reports the outcome of operations that change the node, such as bootstrap, to an endpoint
+// the operator configured, so systemic failures across a fleet show up in one place. Telemetry is off unless
+// agent.telemetry.enabled is set. Reports are anonymized: they hold the operation, its result and duration,
+// the step that failed and the category of the error, but never names, addresses, Azure resource IDs or
+// error messages.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// IDPath holds the random installation ID that lets the receiver tell reports of different nodes apart
+const IDPath = "/var/lib/aks-flex-node/telemetry-id"
+
+// SchemaVersion is the version of the Report format
+const SchemaVersion = 1
+
+// sendTimeout bounds a report, so an unreachable endpoint does not hold up the agent
+const sendTimeout = 10 * time.Second
+
+// Error categories
+const (
+	CategoryConfig     = "config"
+	CategoryPreflight  = "preflight"
+	CategoryValidation = "validation" // A step rejected its configuration before changing anything
+	CategoryComponent  = "component"  // A step failed while installing or removing its component
+	CategoryTimeout    = "timeout"
+	CategoryCanceled   = "canceled"
+	CategoryUnknown    = "unknown"
+
+	// categoryAzure prefixes the azerrors category of a failed Azure request, e.g. azure/Forbidden
+	categoryAzure = "azure/"
+)
+
+// Replaced in tests
+var (
+	idPath        = IDPath
+	osReleasePath = "/etc/os-release"
+)
+
+// Report is the anonymized outcome of one operation
+type Report struct {
+	SchemaVersion  int       `json:"schemaVersion"`
+	InstallationID string    `json:"installationId"` // Random, generated on the node; not derived from any name or hardware ID
+	Timestamp      time.Time `json:"timestamp"`      // Truncated to the minute
+
+	Operation       string       `json:"operation"` // bootstrap, unbootstrap, apply, ...
+	Success         bool         `json:"success"`
+	RebootRequired  bool         `json:"rebootRequired,omitempty"`
+	DurationSeconds float64      `json:"durationSeconds"`
+	FailedStep      string       `json:"failedStep,omitempty"`
+	ErrorCategory   string       `json:"errorCategory,omitempty"`
+	Steps           []StepReport `json:"steps,omitempty"`
+
+	AgentVersion      string `json:"agentVersion"`
+	OS                string `json:"os"` // ID and VERSION_ID of /etc/os-release, e.g. ubuntu 22.04
+	Arch              string `json:"arch"`
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	ContainerRuntime  string `json:"containerRuntime,omitempty"`
+}
+
+// StepReport is the outcome of one step of the operation
+type StepReport struct {
+	Name            string  `json:"name"`
+	Success         bool    `json:"success"`
+	Skipped         bool    `json:"skipped,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Retries         int64   `json:"retries,omitempty"`
+	DownloadedBytes int64   `json:"downloadedBytes,omitempty"`
+}
+
+// Reporter sends reports to the configured endpoint
+type Reporter struct {
+	config       *config.Config
+	logger       *logrus.Logger
+	agentVersion string
+	client       *http.Client
+}
+
+// NewReporter creates a reporter for the telemetry settings of cfg
+func NewReporter(cfg *config.Config, logger *logrus.Logger, agentVersion string) *Reporter {
+	return &Reporter{
+		config:       cfg,
+		logger:       logger,
+		agentVersion: agentVersion,
+		client:       &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Report sends the outcome of an operation when telemetry is enabled. result may be nil when the operation
+// failed before running any step. Telemetry never affects the operation, so failures are only logged.
+func (r *Reporter) Report(ctx context.Context, operation string, result *bootstrapper.ExecutionResult, err error) {
+	if !r.config.Agent.Telemetry.Enabled {
+		return
+	}
+	report := r.newReport(operation, result, err)
+	if sendErr := r.send(ctx, report); sendErr != nil {
+		r.logger.Warnf("Failed to send telemetry for %s: %v", operation, sendErr)
+		return
+	}
+	r.logger.Infof("Sent anonymized telemetry for %s to %s (agent.telemetry.enabled)", operation, r.config.Agent.Telemetry.Endpoint)
+}
+
+func (r *Reporter) newReport(operation string, result *bootstrapper.ExecutionResult, err error) *Report {
+	report := &Report{
+		SchemaVersion:     SchemaVersion,
+		InstallationID:    installationID(r.logger),
+		Timestamp:         time.Now().UTC().Truncate(time.Minute),
+		Operation:         operation,
+		AgentVersion:      r.agentVersion,
+		OS:                osRelease(),
+		Arch:              runtime.GOARCH,
+		KubernetesVersion: r.config.Kubernetes.Version,
+		ContainerRuntime:  r.config.GetContainerRuntime(),
+	}
+	if result != nil {
+		report.Success = result.Success && err == nil
+		report.RebootRequired = result.RebootRequired
+		report.DurationSeconds = result.Duration.Seconds()
+		for _, step := range result.StepResults {
+			report.Steps = append(report.Steps, StepReport{
+				Name:            step.StepName,
+				Success:         step.Success,
+				Skipped:         step.Skipped,
+				DurationSeconds: step.Duration.Seconds(),
+				Retries:         step.Retries,
+				DownloadedBytes: step.DownloadedBytes,
+			})
+			if !step.Success {
+				report.FailedStep = step.StepName
+				if err == nil {
+					err = step.Err
+				}
+			}
+		}
+	}
+	if !report.Success {
+		report.ErrorCategory = Categorize(err)
+	}
+	return report
+}
+
+// Categorize returns the category of an operation's error, without anything from its message
+func Categorize(err error) string {
+	if err == nil {
+		return CategoryUnknown
+	}
+	if azErr, ok := errdefs.As[*errdefs.AzureAPIError](err); ok {
+		return categoryAzure + azErr.Category
+	}
+	if _, ok := errdefs.As[*errdefs.ConfigError](err); ok {
+		return CategoryConfig
+	}
+	if _, ok := errdefs.As[*errdefs.PreflightError](err); ok {
+		return CategoryPreflight
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return CategoryTimeout
+	case errors.Is(err, context.Canceled):
+		return CategoryCanceled
+	}
+	if componentErr, ok := errdefs.As[*errdefs.ComponentError](err); ok {
+		if componentErr.Phase == errdefs.PhaseValidate {
+			return CategoryValidation
+		}
+		return CategoryComponent
+	}
+	return CategoryUnknown
+}
+
+func (r *Reporter) send(ctx context.Context, report *Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	endpoint := r.config.Agent.Telemetry.Endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aks-flex-node/"+r.agentVersion)
+	if tokenFile := r.config.Agent.Telemetry.BearerTokenFile; tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read telemetry bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send report to %s: %w", endpoint, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s rejected the report: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// installationID returns the node's random installation ID, creating it on first use. Without a stored ID,
+// e.g. on a read-only state directory, each report gets a new one.
+func installationID(logger *logrus.Logger) string {
+	if data, err := os.ReadFile(idPath); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id
+		}
+	}
+	id := uuid.NewString()
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(idPath)); err == nil {
+		err = utils.WriteFileAtomicSystem(idPath, []byte(id+"\n"), 0o644)
+		if err != nil {
+			logger.Debugf("Failed to store the telemetry installation ID: %v", err)
+		}
+	}
+	return id
+}
+
+// osRelease returns the distribution ID and version, e.g. "ubuntu 22.04"
+func osRelease() string {
+	data, err := os.ReadFile(osReleasePath)
+	if err != nil {
+		return runtime.GOOS
+	}
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			fields[key] = strings.Trim(value, `"'`)
+		}
+	}
+	return strings.TrimSpace(fields["ID"] + " " + fields["VERSION_ID"])
+}
@@ -0,0 +1,145 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/errdefs"
+)
+
+func useTempFiles(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	oldID, oldOSRelease := idPath, osReleasePath
+	idPath = filepath.Join(dir, "telemetry-id")
+	osReleasePath = filepath.Join(dir, "os-release")
+	t.Cleanup(func() { idPath, osReleasePath = oldID, oldOSRelease })
+	if err := os.WriteFile(osReleasePath, []byte("NAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"22.04\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: CategoryUnknown},
+		{name: "config", err: &errdefs.ConfigError{Err: errors.New("bad")}, want: CategoryConfig},
+		{name: "preflight", err: fmt.Errorf("bootstrap: %w", &errdefs.PreflightError{Err: errors.New("bad")}), want: CategoryPreflight},
+		{
+			name: "azure inside component",
+			err:  &errdefs.ComponentError{Name: "ArcInstall", Phase: errdefs.PhaseExecute, Err: &errdefs.AzureAPIError{Category: "Forbidden", Err: errors.New("denied")}},
+			want: "azure/Forbidden",
+		},
+		{name: "validation", err: &errdefs.ComponentError{Phase: errdefs.PhaseValidate, Err: errors.New("bad")}, want: CategoryValidation},
+		{name: "component", err: &errdefs.ComponentError{Phase: errdefs.PhaseExecute, Err: errors.New("bad")}, want: CategoryComponent},
+		{name: "timeout", err: &errdefs.ComponentError{Err: context.DeadlineExceeded}, want: CategoryTimeout},
+		{name: "canceled", err: context.Canceled, want: CategoryCanceled},
+		{name: "other", err: errors.New("host-1 at 10.0.0.4 failed"), want: CategoryUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Categorize(tt.err); got != tt.want {
+				t.Errorf("Categorize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	useTempFiles(t)
+	cfg := &config.Config{}
+	cfg.Kubernetes.Version = "1.30.0"
+	r := NewReporter(cfg, logrus.New(), "v1.2.3")
+
+	result := &bootstrapper.ExecutionResult{
+		Duration: 90 * time.Second,
+		StepResults: []bootstrapper.StepResult{
+			{StepName: "ContainerdInstall", Success: true, Duration: time.Minute},
+			{StepName: "ArcInstall", Duration: 30 * time.Second, Err: &errdefs.ComponentError{
+				Name: "ArcInstall", Phase: errdefs.PhaseExecute,
+				Err: &errdefs.AzureAPIError{Category: "Forbidden", Err: errors.New("client 0000 in /subscriptions/xyz denied")},
+			}},
+		},
+	}
+	report := r.newReport("bootstrap", result, nil)
+
+	if report.Success || report.FailedStep != "ArcInstall" || report.ErrorCategory != "azure/Forbidden" {
+		t.Errorf("newReport() = success %v, failed step %q, category %q, want false, ArcInstall, azure/Forbidden",
+			report.Success, report.FailedStep, report.ErrorCategory)
+	}
+	if report.OS != "ubuntu 22.04" || report.AgentVersion != "v1.2.3" || report.KubernetesVersion != "1.30.0" || report.DurationSeconds != 90 {
+		t.Errorf("newReport() = %+v, want the node's OS, versions and duration", report)
+	}
+	if len(report.Steps) != 2 || report.Steps[0].DurationSeconds != 60 {
+		t.Errorf("newReport() steps = %+v, want both steps", report.Steps)
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "subscriptions") {
+		t.Errorf("report %s holds the error message", data)
+	}
+
+	// The installation ID is kept across reports
+	if again := r.newReport("bootstrap", nil, errors.New("failed")); again.InstallationID != report.InstallationID || again.ErrorCategory != CategoryUnknown {
+		t.Errorf("newReport() = ID %q, category %q, want ID %q, category unknown", again.InstallationID, again.ErrorCategory, report.InstallationID)
+	}
+}
+
+func TestReport(t *testing.T) {
+	useTempFiles(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []Report
+	var authorization string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = req.Header.Get("Authorization")
+		var report Report
+		if err := json.NewDecoder(req.Body).Decode(&report); err != nil {
+			t.Errorf("failed to decode report: %v", err)
+		}
+		received = append(received, report)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.Agent.Telemetry = config.TelemetryConfig{Endpoint: server.URL, BearerTokenFile: tokenFile}
+	r := NewReporter(cfg, logrus.New(), "v1.2.3")
+	r.client = server.Client()
+
+	result := &bootstrapper.ExecutionResult{Success: true, Duration: time.Minute}
+	r.Report(context.Background(), "bootstrap", result, nil)
+	if len(received) != 0 {
+		t.Fatalf("Report() sent %d reports with telemetry disabled, want none", len(received))
+	}
+
+	cfg.Agent.Telemetry.Enabled = true
+	r.Report(context.Background(), "bootstrap", result, nil)
+	if len(received) != 1 || !received[0].Success || received[0].Operation != "bootstrap" || received[0].ErrorCategory != "" {
+		t.Fatalf("Report() sent %+v, want one successful bootstrap", received)
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the bearer token", authorization)
+	}
+}
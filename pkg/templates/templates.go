@@ -0,0 +1,147 @@
+// Package templates renders the files bootstrap writes on the host, such as systemd units, the container runtime
+// configuration and NPD monitors, from templates embedded in the agent. A file named <template>.tmpl in the
+// configured templates directory replaces the embedded template, so site-specific changes don't need a custom
+// build of the agent. Every template documents the variables it is rendered with in a comment at its top.
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// Names of the templates
+const (
+	KubeletService            = "kubelet.service"
+	KubeletDefaults           = "kubelet-defaults"
+	KubeletContainerdDropIn   = "kubelet-containerd.conf"
+	KubeletTLSBootstrapDropIn = "kubelet-tlsbootstrap.conf"
+	ContainerdService         = "containerd.service"
+	ContainerdConfig          = "containerd-config.toml"
+	CRIOService               = "crio.service"
+	CRIOConfig                = "crio.conf"
+	CRIOPolicy                = "crio-policy.json"
+	NPDService                = "node-problem-detector.service"
+	NPDPluginMonitor          = "npd-plugin-monitor.json"
+	FluentBitDropIn           = "fluent-bit-dropin.conf"
+	SRIOVSetup                = "sriov-setup.sh"
+	SRIOVService              = "sriov.service"
+	KubernetesSlice           = "kubernetes.slice"
+	SliceDropIn               = "slice-dropin.conf"
+)
+
+const extension = ".tmpl"
+
+//go:embed files/*.tmpl
+var embedded embed.FS
+
+// funcs are the functions templates can call besides the text/template builtins
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Render renders the template called name with data, from the templates directory when it holds an override
+func Render(cfg *config.Config, name string, data any) (string, error) {
+	text, source, err := load(cfg.GetTemplatesDirectory(), name)
+	if err != nil {
+		return "", err
+	}
+	return execute(name, source, text, data)
+}
+
+// RenderWithDefault renders defaultText with data, or the override called name in the templates directory when
+// there is one. Components built outside the agent use it for the templates they ship.
+func RenderWithDefault(cfg *config.Config, name, defaultText string, data any) (string, error) {
+	path := filepath.Join(cfg.GetTemplatesDirectory(), name+extension)
+	text, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		return execute(name, path, string(text), data)
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("failed to read template override %s: %w", path, err)
+	}
+	return execute(name, "default template "+name, defaultText, data)
+}
+
+// Default returns the embedded template called name
+func Default(name string) (string, error) {
+	data, err := embedded.ReadFile("files/" + name + extension)
+	if err != nil {
+		return "", fmt.Errorf("unknown template %s", name)
+	}
+	return string(data), nil
+}
+
+// Names returns the names of all embedded templates
+func Names() []string {
+	entries, _ := fs.Glob(embedded, "files/*"+extension)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(filepath.Base(entry), extension))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Overrides returns the paths of the templates in dir that replace an embedded template
+func Overrides(dir string) []string {
+	var paths []string
+	for _, name := range Names() {
+		if path := filepath.Join(dir, name+extension); fileExists(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// load returns the text of the template called name and where it was read from
+func load(dir, name string) (text, source string, err error) {
+	path := filepath.Join(dir, name+extension)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		return string(data), path, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", "", fmt.Errorf("failed to read template override %s: %w", path, err)
+	}
+	text, err = Default(name)
+	return text, "embedded template " + name, err
+}
+
+func parse(name, source, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	return tmpl, nil
+}
+
+func execute(name, source, text string, data any) (string, error) {
+	tmpl, err := parse(name, source, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", source, err)
+	}
+	return b.String(), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
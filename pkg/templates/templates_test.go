@@ -0,0 +1,103 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestEmbeddedTemplatesParse(t *testing.T) {
+	names := Names()
+	if len(names) == 0 {
+		t.Fatal("Names() returned no templates")
+	}
+	for _, name := range names {
+		text, err := Default(name)
+		if err != nil {
+			t.Fatalf("Default(%s) unexpected error: %v", name, err)
+		}
+		if !strings.HasPrefix(text, "{{- /*") {
+			t.Errorf("embedded template %s does not start with the comment documenting its variables", name)
+		}
+		if _, err := parse(name, name, text); err != nil {
+			t.Errorf("embedded template %s does not parse: %v", name, err)
+		}
+	}
+}
+
+func TestRenderOverride(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Templates: config.TemplatesConfig{Directory: dir}}
+	data := struct{ Binary, Config string }{Binary: "/usr/bin/fluent-bit", Config: "/etc/aks-flex-node/fluent-bit.conf"}
+
+	got, err := Render(cfg, FluentBitDropIn, data)
+	if err != nil {
+		t.Fatalf("Render() unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "ExecStart=/usr/bin/fluent-bit -c /etc/aks-flex-node/fluent-bit.conf\n") {
+		t.Errorf("Render() = %q, want the embedded drop-in", got)
+	}
+
+	override := "[Service]\nExecStart=\nExecStart={{.Binary}} -c {{.Config}} --log-level=debug\n"
+	if err := os.WriteFile(filepath.Join(dir, FluentBitDropIn+".tmpl"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Render(cfg, FluentBitDropIn, data)
+	if err != nil {
+		t.Fatalf("Render() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(got, "--log-level=debug\n") {
+		t.Errorf("Render() = %q, want the override", got)
+	}
+	if paths := Overrides(dir); len(paths) != 1 || filepath.Base(paths[0]) != FluentBitDropIn+".tmpl" {
+		t.Errorf("Overrides() = %v, want the fluent-bit drop-in", paths)
+	}
+}
+
+func TestRenderWithDefault(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Templates: config.TemplatesConfig{Directory: dir}}
+	data := struct{ Endpoint string }{Endpoint: "https://agent.contoso.com"}
+
+	got, err := RenderWithDefault(cfg, "contoso-agent.conf", "endpoint={{.Endpoint}}\n", data)
+	if err != nil || got != "endpoint=https://agent.contoso.com\n" {
+		t.Errorf("RenderWithDefault() = %q, %v, want the default text", got, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "contoso-agent.conf.tmpl"), []byte("endpoint={{.Endpoint}}\nverbose=true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = RenderWithDefault(cfg, "contoso-agent.conf", "endpoint={{.Endpoint}}\n", data)
+	if err != nil || !strings.HasSuffix(got, "verbose=true\n") {
+		t.Errorf("RenderWithDefault() = %q, %v, want the override", got, err)
+	}
+
+	if _, err := RenderWithDefault(&config.Config{}, "broken", "{{.Endpoint", data); err == nil {
+		t.Error("RenderWithDefault() accepted a malformed default template")
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Templates: config.TemplatesConfig{Directory: dir}}
+
+	if _, err := Render(cfg, "missing.conf", nil); err == nil {
+		t.Error("Render() expected error for an unknown template")
+	}
+
+	// A variable the data does not have fails instead of rendering an empty value
+	if _, err := Render(cfg, FluentBitDropIn, struct{ Binary string }{Binary: "/usr/bin/fluent-bit"}); err == nil {
+		t.Error("Render() expected error for a missing variable")
+	}
+
+	path := filepath.Join(dir, FluentBitDropIn+".tmpl")
+	if err := os.WriteFile(path, []byte("[Service]\nExecStart={{.Binary\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Render(cfg, FluentBitDropIn, nil); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Render() = %v, want a parse error naming the override", err)
+	}
+}
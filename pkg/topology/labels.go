@@ -0,0 +1,60 @@
+package topology
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Node labels published from the topology
+const (
+	LabelNUMANodes = "flexnode.azure.com/numa-nodes" // Number of NUMA nodes
+	LabelSRIOV     = "flexnode.azure.com/sriov"      // "true" when a NIC supports SR-IOV virtual functions
+	LabelSRIOVVFs  = "flexnode.azure.com/sriov-vfs"  // Virtual functions the NICs support in total
+	LabelGPUCount  = "flexnode.azure.com/gpu-count"  // Number of GPUs
+	LabelGPUVendor = "flexnode.azure.com/gpu-vendor" // nvidia, amd, or mixed
+)
+
+// AnnotationTopology holds the whole inventory as JSON, for the details labels cannot carry
+const AnnotationTopology = "flexnode.azure.com/topology"
+
+// LabelKeys are all the labels the topology is published as; labels without a value are removed from the node
+var LabelKeys = []string{LabelNUMANodes, LabelSRIOV, LabelSRIOVVFs, LabelGPUCount, LabelGPUVendor}
+
+// Labels returns the node labels describing the inventory. Labels of hardware the host does not have are left out.
+func Labels(inventory *Inventory) map[string]string {
+	labels := map[string]string{}
+	if inventory == nil {
+		return labels
+	}
+	if len(inventory.NUMANodes) > 0 {
+		labels[LabelNUMANodes] = strconv.Itoa(len(inventory.NUMANodes))
+	}
+	if len(inventory.SRIOVNICs) > 0 {
+		vfs := 0
+		for _, nic := range inventory.SRIOVNICs {
+			vfs += nic.TotalVFs
+		}
+		labels[LabelSRIOV] = "true"
+		labels[LabelSRIOVVFs] = strconv.Itoa(vfs)
+	}
+	if len(inventory.GPUs) > 0 {
+		labels[LabelGPUCount] = strconv.Itoa(len(inventory.GPUs))
+		labels[LabelGPUVendor] = inventory.GPUs[0].Vendor
+		for _, gpu := range inventory.GPUs[1:] {
+			if gpu.Vendor != inventory.GPUs[0].Vendor {
+				labels[LabelGPUVendor] = "mixed"
+				break
+			}
+		}
+	}
+	return labels
+}
+
+// Annotations returns the node annotations describing the inventory
+func Annotations(inventory *Inventory) (map[string]string, error) {
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{AnnotationTopology: string(data)}, nil
+}
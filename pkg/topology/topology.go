@@ -0,0 +1,246 @@
+// Package topology collects the hardware topology of the host (NUMA nodes, SR-IOV capable NICs and GPUs) so
+// it can be published as node labels and annotations and kept in an inventory file. Schedulers and operators
+// can then target flex nodes by their hardware without labeling them by hand.
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// InventoryPath is where the topology collected during bootstrap is kept
+const InventoryPath = "/var/lib/aks-flex-node/topology.json"
+
+var (
+	// Replaced in tests
+	sysfsRoot     = "/sys"
+	inventoryPath = InventoryPath
+)
+
+// gpuVendors maps the PCI vendor IDs of GPU vendors to the names used in labels. Other display controllers,
+// such as the VGA chips of server BMCs, are not GPUs.
+var gpuVendors = map[string]string{
+	"0x10de": "nvidia",
+	"0x1002": "amd",
+}
+
+// Inventory is the hardware topology of the host
+type Inventory struct {
+	NUMANodes []NUMANode `json:"numaNodes"`
+	SRIOVNICs []SRIOVNIC `json:"sriovNics,omitempty"`
+	GPUs      []GPU      `json:"gpus,omitempty"`
+}
+
+// NUMANode is a NUMA node with its CPUs and memory
+type NUMANode struct {
+	ID        int    `json:"id"`
+	CPUs      string `json:"cpus"` // CPU list as the kernel prints it, e.g. 0-15,32-47
+	MemoryMiB int64  `json:"memoryMiB"`
+}
+
+// SRIOVNIC is a network interface able to create SR-IOV virtual functions
+type SRIOVNIC struct {
+	Interface  string `json:"interface"`
+	PCIAddress string `json:"pciAddress"`
+	TotalVFs   int    `json:"totalVfs"`
+	NumVFs     int    `json:"numVfs"`   // Virtual functions currently created
+	NUMANode   int    `json:"numaNode"` // -1 when the platform does not report one
+}
+
+// GPU is a GPU on the PCI bus
+type GPU struct {
+	PCIAddress string `json:"pciAddress"`
+	Vendor     string `json:"vendor"`
+	DeviceID   string `json:"deviceId"`
+	NUMANode   int    `json:"numaNode"` // -1 when the platform does not report one
+}
+
+// Collect reads the hardware topology from sysfs. Devices the kernel does not expose are left out; only a
+// host without any NUMA information fails.
+func Collect() (*Inventory, error) {
+	numaNodes, err := collectNUMANodes()
+	if err != nil {
+		return nil, err
+	}
+	return &Inventory{
+		NUMANodes: numaNodes,
+		SRIOVNICs: collectSRIOVNICs(),
+		GPUs:      collectGPUs(),
+	}, nil
+}
+
+// collectNUMANodes reads the NUMA nodes; hosts without NUMA support report a single node with every CPU
+func collectNUMANodes() ([]NUMANode, error) {
+	dirs, err := filepath.Glob(filepath.Join(sysfsRoot, "devices/system/node/node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		cpus, err := readString(filepath.Join(sysfsRoot, "devices/system/cpu/online"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CPUs of the host: %w", err)
+		}
+		return []NUMANode{{ID: 0, CPUs: cpus}}, nil
+	}
+
+	nodes := make([]NUMANode, 0, len(dirs))
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		cpus, err := readString(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CPUs of NUMA node %d: %w", id, err)
+		}
+		nodes = append(nodes, NUMANode{ID: id, CPUs: cpus, MemoryMiB: readNodeMemoryMiB(filepath.Join(dir, "meminfo"))})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// readNodeMemoryMiB returns the MemTotal of a NUMA node's meminfo, e.g. "Node 0 MemTotal: 65843064 kB"
+func readNodeMemoryMiB(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[2] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[3], 10, 64)
+			if err == nil {
+				return kb / 1024
+			}
+		}
+	}
+	return 0
+}
+
+// collectSRIOVNICs finds the network interfaces whose device supports virtual functions
+func collectSRIOVNICs() []SRIOVNIC {
+	devices, _ := filepath.Glob(filepath.Join(sysfsRoot, "class/net/*/device/sriov_totalvfs"))
+	var nics []SRIOVNIC
+	for _, totalPath := range devices {
+		device := filepath.Dir(totalPath)
+		total, err := readInt(totalPath)
+		if err != nil || total <= 0 {
+			continue
+		}
+		current, _ := readInt(filepath.Join(device, "sriov_numvfs"))
+		nics = append(nics, SRIOVNIC{
+			Interface:  filepath.Base(filepath.Dir(device)),
+			PCIAddress: pciAddress(device),
+			TotalVFs:   total,
+			NumVFs:     current,
+			NUMANode:   numaNode(device),
+		})
+	}
+	sort.Slice(nics, func(i, j int) bool { return nics[i].Interface < nics[j].Interface })
+	return nics
+}
+
+// collectGPUs finds the display and 3D controllers of known GPU vendors on the PCI bus
+func collectGPUs() []GPU {
+	devices, _ := filepath.Glob(filepath.Join(sysfsRoot, "bus/pci/devices/*"))
+	var gpus []GPU
+	for _, device := range devices {
+		class, err := readString(filepath.Join(device, "class"))
+		if err != nil || !strings.HasPrefix(class, "0x03") {
+			continue
+		}
+		vendorID, _ := readString(filepath.Join(device, "vendor"))
+		vendor, ok := gpuVendors[vendorID]
+		if !ok {
+			continue
+		}
+		deviceID, _ := readString(filepath.Join(device, "device"))
+		gpus = append(gpus, GPU{
+			PCIAddress: filepath.Base(device),
+			Vendor:     vendor,
+			DeviceID:   deviceID,
+			NUMANode:   numaNode(device),
+		})
+	}
+	return gpus
+}
+
+// pciAddress returns the PCI address a device link such as /sys/class/net/eth0/device points to
+func pciAddress(device string) string {
+	target, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// numaNode returns the NUMA node of a PCI device, or -1 when the platform does not report one
+func numaNode(device string) int {
+	node, err := readInt(filepath.Join(device, "numa_node"))
+	if err != nil {
+		return -1
+	}
+	return node
+}
+
+func readString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readInt(path string) (int, error) {
+	value, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// Save writes the inventory file
+func Save(inventory *Inventory) error {
+	data, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode topology inventory: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(inventoryPath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(inventoryPath), err)
+	}
+	if err := utils.WriteFileAtomicSystem(inventoryPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", inventoryPath, err)
+	}
+	return nil
+}
+
+// Load reads the inventory file; it returns nil without an error when no topology was collected yet
+func Load() (*Inventory, error) {
+	data, err := os.ReadFile(inventoryPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", inventoryPath, err)
+	}
+	inventory := &Inventory{}
+	if err := json.Unmarshal(data, inventory); err != nil {
+		return nil, fmt.Errorf("invalid topology inventory %s: %w", inventoryPath, err)
+	}
+	return inventory, nil
+}
+
+// Remove deletes the inventory file
+func Remove() error {
+	if !utils.FileExists(inventoryPath) {
+		return nil
+	}
+	return utils.RunCleanupCommand(inventoryPath)
+}
@@ -0,0 +1,144 @@
+package topology
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSysfs creates files under a fake sysfs root, and symlinks for values starting with "->"
+func writeSysfs(t *testing.T, files map[string]string) {
+	t.Helper()
+	original := sysfsRoot
+	sysfsRoot = t.TempDir()
+	t.Cleanup(func() { sysfsRoot = original })
+
+	for path, content := range files {
+		full := filepath.Join(sysfsRoot, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if target, ok := strings.CutPrefix(content, "->"); ok {
+			if err := os.MkdirAll(filepath.Join(sysfsRoot, target), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(filepath.Join(sysfsRoot, target), full); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(full, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	writeSysfs(t, map[string]string{
+		"devices/system/node/node0/cpulist":           "0-15",
+		"devices/system/node/node0/meminfo":           "Node 0 MemTotal:       65843064 kB\nNode 0 MemFree: 1 kB",
+		"devices/system/node/node1/cpulist":           "16-31",
+		"devices/system/node/node1/meminfo":           "Node 1 MemTotal:       65536000 kB",
+		"devices/system/node/possible":                "0-1",
+		"bus/pci/devices/0000:3b:00.0/sriov_totalvfs": "64",
+		"bus/pci/devices/0000:3b:00.0/sriov_numvfs":   "8",
+		"bus/pci/devices/0000:3b:00.0/numa_node":      "1",
+		"bus/pci/devices/0000:3b:00.0/class":          "0x020000",
+		"class/net/eth1/device":                       "->bus/pci/devices/0000:3b:00.0",
+		"bus/pci/devices/0000:00:05.0/sriov_totalvfs": "0",
+		"class/net/eth0/device":                       "->bus/pci/devices/0000:00:05.0",
+		"bus/pci/devices/0000:af:00.0/class":          "0x030200",
+		"bus/pci/devices/0000:af:00.0/vendor":         "0x10de",
+		"bus/pci/devices/0000:af:00.0/device":         "0x20b5",
+		"bus/pci/devices/0000:af:00.0/numa_node":      "1",
+		"bus/pci/devices/0000:03:00.0/class":          "0x030000",
+		"bus/pci/devices/0000:03:00.0/vendor":         "0x1a03", // BMC VGA controller
+	})
+
+	got, err := Collect()
+	if err != nil {
+		t.Fatalf("Collect() unexpected error: %v", err)
+	}
+	want := &Inventory{
+		NUMANodes: []NUMANode{{ID: 0, CPUs: "0-15", MemoryMiB: 64299}, {ID: 1, CPUs: "16-31", MemoryMiB: 64000}},
+		SRIOVNICs: []SRIOVNIC{{Interface: "eth1", PCIAddress: "0000:3b:00.0", TotalVFs: 64, NumVFs: 8, NUMANode: 1}},
+		GPUs:      []GPU{{PCIAddress: "0000:af:00.0", Vendor: "nvidia", DeviceID: "0x20b5", NUMANode: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Collect() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCollectWithoutNUMA(t *testing.T) {
+	writeSysfs(t, map[string]string{"devices/system/cpu/online": "0-3"})
+
+	got, err := Collect()
+	if err != nil {
+		t.Fatalf("Collect() unexpected error: %v", err)
+	}
+	if want := []NUMANode{{ID: 0, CPUs: "0-3"}}; !reflect.DeepEqual(got.NUMANodes, want) {
+		t.Errorf("Collect() NUMA nodes = %+v, want %+v", got.NUMANodes, want)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		inventory *Inventory
+		want      map[string]string
+	}{
+		{
+			name:      "no inventory",
+			inventory: nil,
+			want:      map[string]string{},
+		},
+		{
+			name:      "single NUMA node without devices",
+			inventory: &Inventory{NUMANodes: []NUMANode{{ID: 0}}},
+			want:      map[string]string{LabelNUMANodes: "1"},
+		},
+		{
+			name: "SR-IOV NICs and mixed GPUs",
+			inventory: &Inventory{
+				NUMANodes: []NUMANode{{ID: 0}, {ID: 1}},
+				SRIOVNICs: []SRIOVNIC{{TotalVFs: 64}, {TotalVFs: 32}},
+				GPUs:      []GPU{{Vendor: "nvidia"}, {Vendor: "amd"}},
+			},
+			want: map[string]string{
+				LabelNUMANodes: "2",
+				LabelSRIOV:     "true",
+				LabelSRIOVVFs:  "96",
+				LabelGPUCount:  "2",
+				LabelGPUVendor: "mixed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Labels(tt.inventory); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Labels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	original := inventoryPath
+	inventoryPath = filepath.Join(t.TempDir(), "topology.json")
+	t.Cleanup(func() { inventoryPath = original })
+
+	if inventory, err := Load(); err != nil || inventory != nil {
+		t.Fatalf("Load() without a file = %v, %v, want nil", inventory, err)
+	}
+	want := &Inventory{NUMANodes: []NUMANode{{ID: 0, CPUs: "0-7", MemoryMiB: 32000}}}
+	if err := Save(want); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	got, err := Load()
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, %v, want %+v", got, err, want)
+	}
+}
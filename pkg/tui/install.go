@@ -0,0 +1,145 @@
+// Package tui implements the interactive install mode: a terminal UI that shows the bootstrap
+// components, their progress and logs, and lets the operator retry after a failure.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+)
+
+// observer forwards step progress to the UI
+type observer struct {
+	send func(tea.Msg)
+}
+
+func (o observer) StepStarted(stepName string) {
+	o.send(stepStartedMsg{name: stepName})
+}
+
+func (o observer) StepFinished(result bootstrapper.StepResult) {
+	o.send(stepFinishedMsg{result: result})
+}
+
+// logHook forwards log entries to the UI, where they are shown under the running component
+type logHook struct {
+	send func(tea.Msg)
+}
+
+func (h logHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h logHook) Fire(entry *logrus.Entry) error {
+	h.send(logMsg{line: fmt.Sprintf("%s %-5.5s %s", entry.Time.Format("15:04:05"), entry.Level.String(), entry.Message)})
+	return nil
+}
+
+// RunInstall bootstraps the node inside the terminal UI and returns the result of the last attempt.
+// While the UI runs, the agent log only goes to the log file, and the output of commands run by the
+// components is captured and shown with the component's logs.
+func RunInstall(ctx context.Context, cfg *config.Config, log *logrus.Logger) (*bootstrapper.ExecutionResult, error) {
+	console := os.Stdout
+	if !term.IsTerminal(int(console.Fd())) {
+		return nil, messages.Errorf(messages.TUINoTTY, errors.New("standard output is not a terminal"))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	b := bootstrapper.New(cfg, log)
+	var program *tea.Program
+	send := func(msg tea.Msg) { program.Send(msg) }
+
+	// Bootstrap runs in a tea command goroutine; wait for it before giving the console back
+	var running sync.WaitGroup
+	start := func() tea.Cmd {
+		running.Add(1)
+		return func() tea.Msg {
+			defer running.Done()
+			result, err := b.Bootstrap(ctx)
+			return runFinishedMsg{result: result, err: err}
+		}
+	}
+
+	m := newModel(b.BootstrapStepNames(), start, cancel)
+	program = tea.NewProgram(m, tea.WithContext(ctx), tea.WithOutput(console), tea.WithAltScreen())
+	b.SetObserver(observer{send: send})
+
+	restoreLog := redirectLogger(log, cfg.Agent.LogDir, send)
+	defer restoreLog()
+	restoreConsole, err := captureConsole(send)
+	if err != nil {
+		return nil, err
+	}
+
+	_, runErr := program.Run()
+	running.Wait()
+	restoreConsole()
+
+	if runErr != nil && !errors.Is(runErr, tea.ErrProgramKilled) {
+		return m.result, fmt.Errorf("interactive install failed: %w", runErr)
+	}
+	if m.result == nil && m.runErr == nil {
+		return nil, context.Canceled
+	}
+	return m.result, m.runErr
+}
+
+// redirectLogger sends log entries to the UI and the log file instead of the console
+func redirectLogger(log *logrus.Logger, logDir string, send func(tea.Msg)) func() {
+	out, hooks := log.Out, log.Hooks
+	if err := logger.LogToFileOnly(log, logDir); err != nil {
+		log.SetOutput(io.Discard)
+	}
+	replaced := make(logrus.LevelHooks)
+	for level, levelHooks := range hooks {
+		replaced[level] = append([]logrus.Hook(nil), levelHooks...)
+	}
+	log.ReplaceHooks(replaced)
+	log.AddHook(logHook{send: send})
+	return func() {
+		log.ReplaceHooks(hooks)
+		log.SetOutput(out)
+	}
+}
+
+// captureConsole redirects os.Stdout and os.Stderr, which the components hand to the commands they run,
+// into the UI so command output does not draw over it
+func captureConsole(send func(tea.Msg)) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture command output: %w", err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			send(logMsg{line: scanner.Text()})
+		}
+	}()
+
+	return func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		_ = w.Close()
+		<-done
+		_ = r.Close()
+	}, nil
+}
@@ -0,0 +1,293 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/messages"
+)
+
+// maxLogLines bounds the log lines kept per component
+const maxLogLines = 500
+
+type stepState int
+
+const (
+	statePending stepState = iota
+	stateRunning
+	stateSucceeded
+	stateFailed
+)
+
+// marker is the status column of the component list
+func (s stepState) marker() string {
+	switch s {
+	case stateRunning:
+		return ">"
+	case stateSucceeded:
+		return "+"
+	case stateFailed:
+		return "x"
+	default:
+		return "."
+	}
+}
+
+// stepView is the state of one component as shown in the list
+type stepView struct {
+	name     string
+	state    stepState
+	duration time.Duration
+	err      string
+	logs     []string
+}
+
+// Messages sent to the model while bootstrap runs
+type (
+	stepStartedMsg  struct{ name string }
+	stepFinishedMsg struct{ result bootstrapper.StepResult }
+	logMsg          struct{ line string }
+	runFinishedMsg  struct {
+		result *bootstrapper.ExecutionResult
+		err    error
+	}
+)
+
+// model is the bubbletea model of the install UI: a component list, the selected component's logs and a status line
+type model struct {
+	steps    []*stepView
+	current  int // index of the running step, -1 when none runs
+	selected int
+	follow   bool // selection follows the running step until the user moves it
+	running  bool
+	attempt  int
+	result   *bootstrapper.ExecutionResult
+	runErr   error
+	width    int
+	height   int
+
+	// start runs bootstrap once and reports runFinishedMsg
+	start func() tea.Cmd
+	// cancel stops a running bootstrap when the user quits
+	cancel func()
+}
+
+func newModel(stepNames []string, start func() tea.Cmd, cancel func()) *model {
+	m := &model{current: -1, follow: true, start: start, cancel: cancel, width: 80, height: 24}
+	for _, name := range stepNames {
+		m.steps = append(m.steps, &stepView{name: name})
+	}
+	return m
+}
+
+// Init starts the first bootstrap attempt
+func (m *model) Init() tea.Cmd {
+	return m.run()
+}
+
+func (m *model) run() tea.Cmd {
+	m.attempt++
+	m.running = true
+	m.result, m.runErr = nil, nil
+	if m.attempt > 1 {
+		// Completed steps report success again quickly; everything else starts over
+		for _, step := range m.steps {
+			if step.state != stateSucceeded {
+				step.state, step.err, step.duration = statePending, "", 0
+			}
+			if len(step.logs) > 0 {
+				step.appendLog(messages.Get(messages.TUIRetry, m.attempt-1))
+			}
+		}
+		m.follow = true
+	}
+	return m.start()
+}
+
+// Update applies key presses and bootstrap progress to the model
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		return m, m.handleKey(msg)
+	case stepStartedMsg:
+		if i := m.indexOf(msg.name); i >= 0 {
+			m.current = i
+			m.steps[i].state = stateRunning
+			if m.follow {
+				m.selected = i
+			}
+		}
+	case stepFinishedMsg:
+		if i := m.indexOf(msg.result.StepName); i >= 0 {
+			step := m.steps[i]
+			step.duration = msg.result.Duration
+			step.err = msg.result.Error
+			step.state = stateSucceeded
+			if !msg.result.Success {
+				step.state = stateFailed
+			}
+		}
+		m.current = -1
+	case logMsg:
+		m.steps[m.logTarget()].appendLog(msg.line)
+	case runFinishedMsg:
+		m.running = false
+		m.current = -1
+		m.result, m.runErr = msg.result, msg.err
+		if failed := m.failedStep(); failed >= 0 {
+			m.selected = failed
+		}
+	}
+	return m, nil
+}
+
+func (m *model) handleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		if m.running && m.cancel != nil {
+			m.cancel()
+		}
+		return tea.Quit
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+			m.follow = false
+		}
+	case "down", "j":
+		if m.selected < len(m.steps)-1 {
+			m.selected++
+			m.follow = false
+		}
+	case "r":
+		if m.retryable() {
+			return m.run()
+		}
+	}
+	return nil
+}
+
+// logTarget is the component a log line belongs to: the running one, or the selected one between steps
+func (m *model) logTarget() int {
+	if m.current >= 0 {
+		return m.current
+	}
+	return m.selected
+}
+
+// retryable reports whether the last attempt ended with an error; a requested reboot is not a failure
+func (m *model) retryable() bool {
+	return !m.running && (m.failedStep() >= 0 || m.runErr != nil)
+}
+
+func (m *model) indexOf(name string) int {
+	for i, step := range m.steps {
+		if step.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *model) failedStep() int {
+	for i, step := range m.steps {
+		if step.state == stateFailed {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *stepView) appendLog(line string) {
+	s.logs = append(s.logs, line)
+	if len(s.logs) > maxLogLines {
+		s.logs = s.logs[len(s.logs)-maxLogLines:]
+	}
+}
+
+// View renders the component list, the log pane and the status line
+func (m *model) View() string {
+	var b strings.Builder
+	b.WriteString(messages.Get(messages.TUITitle) + "\n\n")
+
+	nameWidth := 0
+	for _, step := range m.steps {
+		nameWidth = max(nameWidth, len(step.name))
+	}
+	for i, step := range m.steps {
+		cursor := " "
+		if i == m.selected {
+			cursor = "*"
+		}
+		line := fmt.Sprintf("%s [%s] %-*s", cursor, step.state.marker(), nameWidth, step.name)
+		if step.state == stateSucceeded || step.state == stateFailed {
+			line += fmt.Sprintf("  %s", step.duration.Round(time.Millisecond))
+		}
+		if step.err != "" {
+			line += "  " + step.err
+		}
+		b.WriteString(truncate(line, m.width) + "\n")
+	}
+
+	selected := m.steps[m.selected]
+	b.WriteString("\n" + truncate(fmt.Sprintf("-- %s ", messages.Get(messages.TUILogs, selected.name))+strings.Repeat("-", m.width), m.width) + "\n")
+	// Title, blank lines, list, both rulers, status and key help take the rest of the screen
+	logHeight := max(m.height-len(m.steps)-len(m.status())-7, 3)
+	logs := selected.logs
+	if len(logs) == 0 {
+		logs = []string{messages.Get(messages.TUINoLogs)}
+	}
+	if len(logs) > logHeight {
+		logs = logs[len(logs)-logHeight:]
+	}
+	for _, line := range logs {
+		b.WriteString(truncate(line, m.width) + "\n")
+	}
+	for i := len(logs); i < logHeight; i++ {
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Repeat("-", m.width) + "\n")
+
+	for _, line := range m.status() {
+		b.WriteString(truncate(line, m.width) + "\n")
+	}
+	b.WriteString(truncate(messages.Get(messages.TUIKeys), m.width))
+	return b.String()
+}
+
+// status describes what bootstrap is doing or how it ended, with a remediation hint after a failure
+func (m *model) status() []string {
+	switch {
+	case m.running && m.current >= 0:
+		return []string{messages.Get(messages.TUIRunning, m.steps[m.current].name)}
+	case m.running:
+		return []string{messages.Get(messages.TUIStarting)}
+	case m.result != nil && m.result.RebootRequired:
+		return []string{messages.Get(messages.TUIReboot, m.result.RebootStep)}
+	case m.result != nil && m.result.Success && m.runErr == nil:
+		return []string{messages.Get(messages.TUISucceeded, m.result.Duration.Round(time.Second))}
+	}
+	if failed := m.failedStep(); failed >= 0 {
+		step := m.steps[failed]
+		return []string{messages.Get(messages.TUIFailed, step.name), messages.HintForFailure(step.name, step.err)}
+	}
+	if m.runErr != nil {
+		return []string{m.runErr.Error()}
+	}
+	return nil
+}
+
+// truncate cuts a line to the terminal width so the layout does not wrap
+func truncate(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return line
+	}
+	return string(runes[:width])
+}
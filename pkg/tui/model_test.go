@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+)
+
+func newTestModel(starts *int, cancelled *bool) *model {
+	start := func() tea.Cmd {
+		*starts++
+		return nil
+	}
+	cancel := func() { *cancelled = true }
+	m := newModel([]string{"Preflight", "Arc", "Kubelet"}, start, cancel)
+	m.Init()
+	return m
+}
+
+func key(k string) tea.KeyMsg {
+	if k == "up" {
+		return tea.KeyMsg{Type: tea.KeyUp}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+func TestModelProgress(t *testing.T) {
+	var starts int
+	var cancelled bool
+	m := newTestModel(&starts, &cancelled)
+
+	m.Update(logMsg{line: "starting"})
+	m.Update(stepStartedMsg{name: "Preflight"})
+	m.Update(logMsg{line: "checking disk"})
+	m.Update(stepFinishedMsg{result: bootstrapper.StepResult{StepName: "Preflight", Success: true}})
+	m.Update(stepStartedMsg{name: "Arc"})
+	m.Update(logMsg{line: "az failed"})
+
+	if m.selected != 1 {
+		t.Errorf("selected = %d, want the running step 1", m.selected)
+	}
+	if got := m.steps[0].logs; len(got) != 2 || got[1] != "checking disk" {
+		t.Errorf("Preflight logs = %v", got)
+	}
+	if got := m.steps[1].logs; len(got) != 1 || got[0] != "az failed" {
+		t.Errorf("Arc logs = %v", got)
+	}
+	if !strings.Contains(m.View(), "Running Arc") {
+		t.Errorf("View() should show the running step:\n%s", m.View())
+	}
+
+	m.Update(stepFinishedMsg{result: bootstrapper.StepResult{StepName: "Arc", Error: "login required"}})
+	m.Update(runFinishedMsg{err: errors.New("bootstrap failed at step Arc")})
+
+	if m.steps[1].state != stateFailed || m.steps[2].state != statePending {
+		t.Errorf("states = %v, %v", m.steps[1].state, m.steps[2].state)
+	}
+	view := m.View()
+	for _, want := range []string{"failed at Arc", "Hint:", "login required"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestModelRetry(t *testing.T) {
+	var starts int
+	var cancelled bool
+	m := newTestModel(&starts, &cancelled)
+
+	m.Update(key("r"))
+	if starts != 1 {
+		t.Fatalf("retry while running started %d runs, want 1", starts)
+	}
+
+	m.Update(stepStartedMsg{name: "Preflight"})
+	m.Update(stepFinishedMsg{result: bootstrapper.StepResult{StepName: "Preflight", Success: true}})
+	m.Update(stepStartedMsg{name: "Arc"})
+	m.Update(logMsg{line: "az failed"})
+	m.Update(stepFinishedMsg{result: bootstrapper.StepResult{StepName: "Arc", Error: "boom"}})
+	m.Update(runFinishedMsg{err: errors.New("boom")})
+
+	m.Update(key("r"))
+	if starts != 2 {
+		t.Fatalf("retry after failure started %d runs, want 2", starts)
+	}
+	if !m.running || m.attempt != 2 {
+		t.Errorf("running = %v, attempt = %d", m.running, m.attempt)
+	}
+	if m.steps[0].state != stateSucceeded || m.steps[1].state != statePending || m.steps[1].err != "" {
+		t.Errorf("retry should reset only unfinished steps: %+v %+v", *m.steps[0], *m.steps[1])
+	}
+	if logs := m.steps[1].logs; logs[len(logs)-1] != "--- retry 1 ---" {
+		t.Errorf("Arc logs = %v, want a retry separator", logs)
+	}
+}
+
+func TestModelNoRetryAfterRebootRequest(t *testing.T) {
+	var starts int
+	var cancelled bool
+	m := newTestModel(&starts, &cancelled)
+
+	m.Update(runFinishedMsg{result: &bootstrapper.ExecutionResult{RebootRequired: true, RebootStep: "SystemConfiguration"}})
+	m.Update(key("r"))
+	if starts != 1 {
+		t.Errorf("retry after a reboot request started %d runs, want 1", starts)
+	}
+	if !strings.Contains(m.View(), "SystemConfiguration requires a reboot") {
+		t.Errorf("View() should explain the reboot:\n%s", m.View())
+	}
+}
+
+func TestModelSelectionAndQuit(t *testing.T) {
+	var starts int
+	var cancelled bool
+	m := newTestModel(&starts, &cancelled)
+
+	m.Update(key("j"))
+	m.Update(key("j"))
+	m.Update(key("j"))
+	if m.selected != 2 {
+		t.Errorf("selected = %d, want 2", m.selected)
+	}
+	m.Update(key("up"))
+	m.Update(stepStartedMsg{name: "Preflight"})
+	if m.selected != 1 {
+		t.Errorf("selection should stay where the user moved it, got %d", m.selected)
+	}
+
+	_, cmd := m.Update(key("q"))
+	if cmd == nil || !cancelled {
+		t.Errorf("quit while running should cancel bootstrap (cancelled = %v)", cancelled)
+	}
+}
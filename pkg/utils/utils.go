@@ -16,11 +16,13 @@ import (
 	"github.com/sirupsen/logrus"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/profiling"
 )
 
 // sudoCommandLists holds the command lists for sudo determination
 var (
-	alwaysNeedsSudo = []string{"apt", "apt-get", "dpkg", "systemctl", "mount", "umount", "modprobe", "sysctl", "azcmagent", "usermod", "kubectl", "swapoff"}
+	alwaysNeedsSudo = []string{"apt", "apt-get", "dpkg", "systemctl", "mount", "umount", "modprobe", "sysctl", "azcmagent", "usermod", "kubectl", "swapoff", "update-ca-certificates", "shutdown", "update-grub", "useradd", "userdel", "visudo", "sshd", "needrestart", "dnf", "systemd-run", "rpm-ostree"}
 	conditionalSudo = []string{"mkdir", "cp", "chmod", "chown", "mv", "tar", "rm", "bash", "install", "ln", "cat"}
 	systemPaths     = []string{"/etc/", "/usr/", "/var/", "/opt/", "/boot/", "/sys/"}
 )
@@ -62,21 +64,71 @@ func createCommand(name string, args []string) *exec.Cmd {
 	return exec.Command(name, args...)
 }
 
+// commandCategory reports downloads made with curl or wget as download time rather than command time
+func commandCategory(name string) profiling.Category {
+	if name == "curl" || name == "wget" {
+		return profiling.Download
+	}
+	return profiling.Exec
+}
+
 // RunSystemCommand executes a system command with sudo when needed for privileged operations
 func RunSystemCommand(name string, args ...string) error {
+	defer profiling.Track(commandCategory(name))()
 	cmd := createCommand(name, args)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// RunLimitedCommand executes a command like RunSystemCommand, in a transient systemd scope with the given
+// resource control properties such as "CPUQuota=50%". Without properties the command runs unconfined.
+func RunLimitedCommand(properties []string, name string, args ...string) error {
+	if len(properties) == 0 {
+		return RunSystemCommand(name, args...)
+	}
+	return RunSystemCommand("systemd-run", limitedCommandArgs(properties, name, args)...)
+}
+
+// limitedCommandArgs returns the systemd-run arguments running a command in a transient scope. The sudoers
+// rule for install limits matches exactly this form, so keep both in sync.
+func limitedCommandArgs(properties []string, name string, args []string) []string {
+	scopeArgs := []string{"--scope", "--quiet", "--collect"}
+	for _, property := range properties {
+		scopeArgs = append(scopeArgs, "--property="+property)
+	}
+	scopeArgs = append(scopeArgs, "--", name)
+	return append(scopeArgs, args...)
+}
+
 // RunCommandWithOutput executes a command and returns output with sudo when needed
 func RunCommandWithOutput(name string, args ...string) (string, error) {
+	defer profiling.Track(commandCategory(name))()
 	cmd := createCommand(name, args)
 	output, err := cmd.CombinedOutput()
 	return string(output), err
 }
 
+// RunCommandWithOutputContext executes a command like RunCommandWithOutput and kills it when ctx is done
+func RunCommandWithOutputContext(ctx context.Context, name string, args ...string) (string, error) {
+	defer profiling.Track(commandCategory(name))()
+	command := createCommand(name, args)
+	cmd := exec.CommandContext(ctx, command.Args[0], command.Args[1:]...)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// RunCommandWithEnvContext executes a command like RunCommandWithOutputContext with extra environment variables
+// set only on the command, e.g. credentials that must not appear on its command line
+func RunCommandWithEnvContext(ctx context.Context, env []string, name string, args ...string) (string, error) {
+	defer profiling.Track(commandCategory(name))()
+	command := createCommand(name, args)
+	cmd := exec.CommandContext(ctx, command.Args[0], command.Args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
 // FileExists checks if a file exists
 func FileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -98,6 +150,15 @@ func IsServiceActive(serviceName string) bool {
 	return strings.TrimSpace(output) == "active"
 }
 
+// IsServiceEnabled checks if a systemd service is enabled to start at boot
+func IsServiceEnabled(serviceName string) bool {
+	output, err := RunCommandWithOutput("systemctl", "is-enabled", serviceName)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(output) == "enabled"
+}
+
 // ServiceExists checks if a systemd service unit file exists
 func ServiceExists(serviceName string) bool {
 	err := RunSystemCommand("systemctl", "list-unit-files", serviceName+".service")
@@ -318,6 +379,8 @@ func WaitForService(serviceName string, timeout time.Duration, logger *logrus.Lo
 
 // DownloadFile downloads a file from URL to destination
 func DownloadFile(url, destination string) error {
+	defer profiling.Track(profiling.Download)()
+
 	// Create HTTP client with timeout
 	client := &http.Client{
 		Timeout: 10 * time.Minute,
@@ -346,7 +409,8 @@ func DownloadFile(url, destination string) error {
 	}()
 
 	// Copy response body to file
-	_, err = io.Copy(out, resp.Body)
+	written, err := io.Copy(out, resp.Body)
+	profiling.CountDownloaded(written)
 	if err != nil {
 		return fmt.Errorf("failed to write file %s: %w", destination, err)
 	}
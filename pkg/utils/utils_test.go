@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimitedCommandArgs(t *testing.T) {
+	got := limitedCommandArgs([]string{"CPUQuota=50%", "IOWeight=20"}, "tar", []string{"-xzf", "/tmp/npd.tar.gz"})
+	want := []string{"--scope", "--quiet", "--collect", "--property=CPUQuota=50%", "--property=IOWeight=20", "--", "tar", "-xzf", "/tmp/npd.tar.gz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("limitedCommandArgs() = %q, want %q", got, want)
+	}
+}
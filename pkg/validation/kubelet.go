@@ -0,0 +1,213 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// envPattern matches an assignment of an EnvironmentFile, with the value in double quotes
+var envPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)="(.*)"$`)
+
+// Kubelet flags by the type of their value, as declared by the kubelet's configuration API. Flags missing here
+// are only checked for syntax.
+var (
+	kubeletIntFlags = map[string]bool{
+		"v": true, "event-qps": true, "max-pods": true, "pod-max-pids": true,
+		"image-gc-high-threshold": true, "image-gc-low-threshold": true,
+	}
+	kubeletBoolFlags = map[string]bool{
+		"anonymous-auth": true, "authentication-token-webhook": true, "cgroups-per-qos": true,
+		"enable-server": true, "protect-kernel-defaults": true, "rotate-certificates": true,
+	}
+	kubeletDurationFlags = map[string]bool{
+		"node-status-update-frequency": true, "streaming-connection-idle-timeout": true,
+		"image-minimum-gc-age": true, "runtime-request-timeout": true,
+	}
+	// Flags taking resource=quantity pairs
+	kubeletReservationFlags = map[string]bool{"kube-reserved": true, "system-reserved": true}
+	// Flags taking signal<threshold pairs
+	kubeletEvictionFlags = map[string]bool{"eviction-hard": true, "eviction-soft": true}
+	kubeletEnumFlags     = map[string][]string{
+		"cgroup-driver":           {"systemd", "cgroupfs"},
+		"authorization-mode":      {"Webhook", "AlwaysAllow"},
+		"cpu-manager-policy":      {"none", "static"},
+		"topology-manager-policy": {"none", "best-effort", "restricted", "single-numa-node"},
+		"topology-manager-scope":  {"container", "pod"},
+	}
+)
+
+// KubeletDefaults checks the kubelet's environment file: its syntax, and the value of every kubelet flag whose
+// type is known
+func KubeletDefaults(path, content string) error {
+	vars, err := parseEnvironmentFile(content)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"KUBELET_FLAGS", "KUBELET_CONFIG_FILE_FLAGS", "KUBELET_CONTAINERD_FLAGS", "KUBELET_TLS_BOOTSTRAP_FLAGS"} {
+		if err := checkKubeletArgs(vars[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if labels := vars["KUBELET_NODE_LABELS"]; labels != "" {
+		for _, label := range strings.Split(labels, ",") {
+			if key, _, ok := strings.Cut(label, "="); !ok || key == "" {
+				return fmt.Errorf("KUBELET_NODE_LABELS: invalid label %q. Expected key=value", label)
+			}
+		}
+	}
+	return nil
+}
+
+// KubeletDropIn checks a kubelet unit drop-in, including the kubelet flags it sets through Environment=
+func KubeletDropIn(path, content string) error {
+	sections, err := parseUnit(content)
+	if err != nil {
+		return err
+	}
+	for _, env := range sections["Service"]["Environment"] {
+		matches := envPattern.FindStringSubmatch(env)
+		if matches == nil {
+			continue
+		}
+		if err := checkKubeletArgs(matches[2]); err != nil {
+			return fmt.Errorf("%s: %w", matches[1], err)
+		}
+	}
+	return nil
+}
+
+// parseEnvironmentFile returns the variables of an EnvironmentFile whose values are in double quotes and may
+// continue on the next line after a backslash
+func parseEnvironmentFile(content string) (map[string]string, error) {
+	vars := map[string]string{}
+	lines := strings.Split(content, "\n")
+	for idx := 0; idx < len(lines); idx++ {
+		line := strings.TrimSpace(lines[idx])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		start := idx + 1
+		for strings.HasSuffix(line, `\`) && idx+1 < len(lines) {
+			idx++
+			line = strings.TrimSuffix(line, `\`) + " " + strings.TrimSpace(lines[idx])
+		}
+		matches := envPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			return nil, fmt.Errorf("line %d: expected NAME=\"value\"", start)
+		}
+		if strings.Contains(matches[2], `"`) {
+			return nil, fmt.Errorf("line %d: unbalanced quotes in %s", start, matches[1])
+		}
+		vars[matches[1]] = matches[2]
+	}
+	return vars, nil
+}
+
+// checkKubeletArgs checks kubelet arguments given as --name=value or --name value
+func checkKubeletArgs(args string) error {
+	fields := strings.Fields(args)
+	for idx := 0; idx < len(fields); idx++ {
+		arg := fields[idx]
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			return fmt.Errorf("invalid argument %q. Expected --flag=value", arg)
+		}
+		if !strings.Contains(arg, "=") && idx+1 < len(fields) && !strings.HasPrefix(fields[idx+1], "--") {
+			idx++
+			arg += "=" + fields[idx]
+		}
+		if err := checkKubeletFlag(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkKubeletFlag checks one --name[=value] argument
+func checkKubeletFlag(arg string) error {
+	name, value, hasValue := strings.Cut(arg[2:], "=")
+	invalid := func(err error) error {
+		return fmt.Errorf("invalid --%s value %q: %w", name, value, err)
+	}
+
+	switch {
+	case kubeletIntFlags[name]:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return invalid(err)
+		}
+		if strings.HasPrefix(name, "image-gc-") && (n < 0 || n > 100) {
+			return invalid(fmt.Errorf("not a percentage"))
+		}
+		if name == "max-pods" && n <= 0 {
+			return invalid(fmt.Errorf("must be positive"))
+		}
+	case kubeletBoolFlags[name]:
+		if hasValue {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return invalid(err)
+			}
+		}
+	case kubeletDurationFlags[name]:
+		if _, err := time.ParseDuration(value); err != nil {
+			return invalid(err)
+		}
+	case kubeletReservationFlags[name]:
+		return checkPairs(value, "=", func(key, quantity string) error {
+			if _, err := resource.ParseQuantity(quantity); err != nil {
+				return invalid(fmt.Errorf("%s: %w", key, err))
+			}
+			return nil
+		})
+	case kubeletEvictionFlags[name]:
+		return checkPairs(value, "<", func(signal, threshold string) error {
+			if percentage, ok := strings.CutSuffix(threshold, "%"); ok {
+				if p, err := strconv.ParseFloat(percentage, 64); err != nil || p < 0 || p > 100 {
+					return invalid(fmt.Errorf("%s: %q is not a percentage", signal, threshold))
+				}
+				return nil
+			}
+			if _, err := resource.ParseQuantity(threshold); err != nil {
+				return invalid(fmt.Errorf("%s: %w", signal, err))
+			}
+			return nil
+		})
+	case name == "eviction-soft-grace-period":
+		return checkPairs(value, "=", func(signal, period string) error {
+			if _, err := time.ParseDuration(period); err != nil {
+				return invalid(fmt.Errorf("%s: %w", signal, err))
+			}
+			return nil
+		})
+	case kubeletEnumFlags[name] != nil:
+		for _, allowed := range kubeletEnumFlags[name] {
+			if value == allowed {
+				return nil
+			}
+		}
+		return invalid(fmt.Errorf("expected one of %s", strings.Join(kubeletEnumFlags[name], ", ")))
+	}
+	return nil
+}
+
+// checkPairs calls check with the key and value of every comma-separated key<separator>value pair; an empty
+// list is valid
+func checkPairs(list, separator string, check func(key, value string) error) error {
+	if list == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(pair, separator)
+		if !ok || key == "" || value == "" {
+			return fmt.Errorf("invalid pair %q. Expected key%svalue", pair, separator)
+		}
+		if err := check(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
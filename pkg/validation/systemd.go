@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+var (
+	// sectionPattern matches a section header such as [Service]
+	sectionPattern = regexp.MustCompile(`^\[[A-Za-z][A-Za-z0-9-]*\]$`)
+	// keyPattern matches a setting name such as ExecStartPre
+	keyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+)
+
+// SystemdUnit checks a unit file: its syntax, that a service starts something, and, when systemd-analyze is
+// installed, what systemd itself reports about the unit
+func SystemdUnit(path, content string) error {
+	sections, err := parseUnit(content)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".service") && len(sections["Service"]["ExecStart"]) == 0 {
+		return fmt.Errorf("service has no ExecStart")
+	}
+	return analyzeUnit(path, content)
+}
+
+// SystemdDropIn checks the syntax of a unit drop-in, which systemd-analyze cannot check on its own
+func SystemdDropIn(path, content string) error {
+	_, err := parseUnit(content)
+	return err
+}
+
+// parseUnit returns the settings of a unit file by section and key, joining continued lines
+func parseUnit(content string) (map[string]map[string][]string, error) {
+	sections := map[string]map[string][]string{}
+	section := ""
+	lines := strings.Split(content, "\n")
+	for idx := 0; idx < len(lines); idx++ {
+		line := strings.TrimSpace(lines[idx])
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !sectionPattern.MatchString(line) {
+				return nil, fmt.Errorf("line %d: invalid section header %q", idx+1, line)
+			}
+			section = strings.Trim(line, "[]")
+			if sections[section] == nil {
+				sections[section] = map[string][]string{}
+			}
+			continue
+		}
+
+		start := idx + 1
+		for strings.HasSuffix(line, `\`) && idx+1 < len(lines) {
+			idx++
+			line = strings.TrimSuffix(line, `\`) + " " + strings.TrimSpace(lines[idx])
+		}
+		if strings.HasSuffix(line, `\`) {
+			return nil, fmt.Errorf("line %d: continuation at the end of the file", start)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !keyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected Key=Value, got %q", start, line)
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %d: %s is outside of any section", start, key)
+		}
+		if (strings.Count(value, `"`)-strings.Count(value, `\"`))%2 != 0 {
+			return nil, fmt.Errorf("line %d: unbalanced quotes in %s", start, key)
+		}
+		sections[section][key] = append(sections[section][key], strings.TrimSpace(value))
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no sections")
+	}
+	return sections, nil
+}
+
+// analyzeUnit runs systemd-analyze verify on a copy of the unit. Only findings about the unit itself fail
+// the check; systemd-analyze also reports problems of other units installed on the machine.
+func analyzeUnit(path, content string) error {
+	if !utils.BinaryExists("systemd-analyze") {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "aks-flex-node-unit-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// systemd derives the unit type from the file name
+	name := filepath.Base(path)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write temporary unit: %w", err)
+	}
+	output, err := utils.RunCommandWithOutput("systemd-analyze", "verify", "--man=no", filepath.Join(dir, name))
+	if err == nil {
+		return nil
+	}
+	if findings := unitFindings(output, name); len(findings) > 0 {
+		return fmt.Errorf("systemd-analyze verify: %s", strings.Join(findings, "; "))
+	}
+	return nil
+}
+
+// unitFindings returns the lines of systemd-analyze output about the unit called name
+func unitFindings(output, name string) []string {
+	var findings []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.Contains(line, name) {
+			findings = append(findings, line)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ContainerdConfig checks that a containerd config.toml parses and uses a configuration version containerd reads
+func ContainerdConfig(path, content string) error {
+	doc, err := parseTOML(content)
+	if err != nil {
+		return err
+	}
+	switch version := doc["version"].(type) {
+	case nil:
+		return fmt.Errorf("version is not set; containerd would read the file as a version 1 configuration")
+	case int64:
+		if version != 2 && version != 3 {
+			return fmt.Errorf("unsupported version %d. Expected 2 or 3", version)
+		}
+	default:
+		return fmt.Errorf("version is %v, not a number", version)
+	}
+	if _, ok := doc["plugins"].(map[string]any); !ok {
+		return fmt.Errorf("plugins table is missing")
+	}
+	return nil
+}
+
+// CRIOConfig checks that a CRI-O configuration drop-in parses and only holds CRI-O's tables
+func CRIOConfig(path, content string) error {
+	doc, err := parseTOML(content)
+	if err != nil {
+		return err
+	}
+	for key, value := range doc {
+		if key != "crio" {
+			return fmt.Errorf("unexpected top-level key %q. Expected only the crio table", key)
+		}
+		if _, ok := value.(map[string]any); !ok {
+			return fmt.Errorf("crio is not a table")
+		}
+	}
+	return nil
+}
+
+func parseTOML(content string) (map[string]any, error) {
+	doc := map[string]any{}
+	if err := toml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("invalid TOML: %w", err)
+	}
+	return doc, nil
+}
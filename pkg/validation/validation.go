@@ -0,0 +1,83 @@
+// Package validation checks configuration files rendered by bootstrap before they are written: systemd units
+// and drop-ins, the kubelet flags and the container runtime TOML configuration. A file that fails its check is
+// not written, so a rendering mistake fails the step instead of leaving a service that does not start.
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/filebackup"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Check validates the rendered content of the file at path
+type Check func(path, content string) error
+
+// WriteFile checks content with check and, when it is valid, logs how it differs from the file at path and
+// writes it. An invalid rendering leaves the file as it was. The file is backed up before its first change.
+func WriteFile(path, content string, perm os.FileMode, check Check, logger *logrus.Logger) error {
+	if err := check(path, content); err != nil {
+		return fmt.Errorf("refusing to write %s, the rendered file is invalid: %w", path, err)
+	}
+
+	if existing, err := os.ReadFile(path); err == nil && string(existing) != content {
+		logger.Infof("Updating %s:\n%s", path, Diff(string(existing), content))
+	}
+	if err := filebackup.Save(path, logger); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomicSystem(path, []byte(content), perm); err != nil {
+		return err
+	}
+	return filebackup.Record(path)
+}
+
+// JSON checks that content is a JSON document
+func JSON(path, content string) error {
+	if !json.Valid([]byte(content)) {
+		return fmt.Errorf("invalid JSON")
+	}
+	return nil
+}
+
+// Diff returns the lines removed from old, prefixed with "-", and added in new, prefixed with "+", in order
+func Diff(old, new string) string {
+	a := strings.Split(strings.TrimSuffix(old, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(new, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "-%s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+%s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,143 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdDropIn(t *testing.T) {
+	valid := "# Generated by aks-flex-node\n[Service]\nEnvironment=FOO=\"bar baz\"\nExecStart=\nExecStart=/usr/bin/true \\\n  --flag\n"
+	if err := SystemdDropIn("/etc/systemd/system/x.service.d/10.conf", valid); err != nil {
+		t.Errorf("SystemdDropIn() unexpected error: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"key outside section": "Environment=FOO=bar\n[Service]\n",
+		"missing equals":      "[Service]\nExecStart /usr/bin/true\n",
+		"unbalanced quotes":   "[Service]\nEnvironment=\"FOO=bar\n",
+		"broken header":       "[Service\nExecStart=/usr/bin/true\n",
+		"trailing backslash":  "[Service]\nExecStart=/usr/bin/true \\",
+		"empty":               "# nothing\n",
+	} {
+		if err := SystemdDropIn("10.conf", content); err == nil {
+			t.Errorf("SystemdDropIn(%s) expected error", name)
+		}
+	}
+}
+
+func TestSystemdUnitRequiresExecStart(t *testing.T) {
+	if err := SystemdUnit("/etc/systemd/system/x.service", "[Unit]\nDescription=x\n[Service]\nRestart=always\n"); err == nil ||
+		!strings.Contains(err.Error(), "ExecStart") {
+		t.Errorf("SystemdUnit() = %v, want an error about the missing ExecStart", err)
+	}
+}
+
+func TestUnitFindings(t *testing.T) {
+	output := "/etc/systemd/system/other.service:3: Unknown key name 'Foo'\n" +
+		"/tmp/aks-flex-node-unit-1/kubelet.service:12: Missing '=', ignoring line.\n"
+	findings := unitFindings(output, "kubelet.service")
+	if len(findings) != 1 || !strings.Contains(findings[0], "Missing '='") {
+		t.Errorf("unitFindings() = %q, want only the kubelet finding", findings)
+	}
+}
+
+func TestKubeletDefaults(t *testing.T) {
+	valid := `KUBELET_NODE_LABELS="agentpool=flex,kubernetes.azure.com/mode=user"
+KUBELET_CONFIG_FILE_FLAGS=""
+KUBELET_FLAGS="\
+  --v=2 \
+  --anonymous-auth=false \
+  --cgroup-driver=systemd \
+  --eviction-hard=memory.available<750Mi,nodefs.available<10%  \
+  --kube-reserved=cpu=100m,memory=1638Mi  \
+  --system-reserved=  \
+  --image-gc-high-threshold=85  \
+  --max-pods=110  \
+  --node-status-update-frequency=10s  \
+  --eviction-soft-grace-period=memory.available=1m30s \
+  --register-with-taints=dedicated=gpu:NoSchedule \
+  "`
+	if err := KubeletDefaults("/etc/default/kubelet", valid); err != nil {
+		t.Fatalf("KubeletDefaults() unexpected error: %v", err)
+	}
+
+	for name, flag := range map[string]string{
+		"reservation quantity": "--kube-reserved=cpu=100 millicores",
+		"eviction percentage":  "--eviction-hard=nodefs.available<110%",
+		"eviction operator":    "--eviction-hard=memory.available=750Mi",
+		"max pods":             "--max-pods=0",
+		"image gc threshold":   "--image-gc-high-threshold=185",
+		"duration":             "--node-status-update-frequency=10",
+		"enum":                 "--cgroup-driver=systemdd",
+		"not a flag":           "-v=2",
+	} {
+		content := "KUBELET_FLAGS=\"--v=2 " + flag + "\"\n"
+		if err := KubeletDefaults("/etc/default/kubelet", content); err == nil {
+			t.Errorf("KubeletDefaults(%s) expected error", name)
+		}
+	}
+	if err := KubeletDefaults("/etc/default/kubelet", "KUBELET_FLAGS=\"--v=2 \"--max-pods=3\"\n"); err == nil {
+		t.Error("KubeletDefaults() expected error for a stray quote")
+	}
+}
+
+func TestKubeletDropIn(t *testing.T) {
+	valid := "[Service]\nEnvironment=KUBELET_TLS_BOOTSTRAP_FLAGS=\"--kubeconfig /var/lib/kubelet/kubeconfig\"\n" +
+		"Environment=KUBELET_CONTAINERD_FLAGS=\"--runtime-request-timeout=15m --container-runtime-endpoint=unix:///run/containerd/containerd.sock\"\n"
+	if err := KubeletDropIn("10-tlsbootstrap.conf", valid); err != nil {
+		t.Errorf("KubeletDropIn() unexpected error: %v", err)
+	}
+	if err := KubeletDropIn("10-containerd.conf", "[Service]\nEnvironment=KUBELET_CONTAINERD_FLAGS=\"--runtime-request-timeout=15\"\n"); err == nil {
+		t.Error("KubeletDropIn() expected error for a timeout without unit")
+	}
+}
+
+func TestContainerdConfig(t *testing.T) {
+	valid := `version = 2
+oom_score = 0
+[grpc]
+	gid = 1000
+[plugins."io.containerd.grpc.v1.cri"]
+	sandbox_image = "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+	[plugins."io.containerd.grpc.v1.cri".registry.headers]
+		X-Meta-Source-Client = ["azure/aks"]
+[metrics]
+	address = "0.0.0.0:10257"`
+	if err := ContainerdConfig("/etc/containerd/config.toml", valid); err != nil {
+		t.Errorf("ContainerdConfig() unexpected error: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"no version":        "[plugins.cri]\nx = 1\n",
+		"old version":       "version = 1\n[plugins.cri]\nx = 1\n",
+		"string version":    "version = \"2\"\n[plugins.cri]\nx = 1\n",
+		"no plugins":        "version = 2\n",
+		"unterminated":      "version = 2\n[plugins.cri]\nsandbox_image = \"pause\n",
+		"duplicate section": "version = 2\n[plugins]\nx = 1\n[plugins]\ny = 2\n",
+	} {
+		if err := ContainerdConfig("/etc/containerd/config.toml", content); err == nil {
+			t.Errorf("ContainerdConfig(%s) expected error", name)
+		}
+	}
+}
+
+func TestCRIOConfig(t *testing.T) {
+	if err := CRIOConfig("10-aks.conf", "[crio.api]\nlisten = \"/run/crio/crio.sock\"\n[crio.metrics]\nenable_metrics = true\n"); err != nil {
+		t.Errorf("CRIOConfig() unexpected error: %v", err)
+	}
+	if err := CRIOConfig("10-aks.conf", "[crio.api]\nlisten = \"x\"\n[runtime]\nx = 1\n"); err == nil {
+		t.Error("CRIOConfig() expected error for a table outside crio")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := "[Service]\nRestart=always\nExecStart=/usr/bin/a\n"
+	new := "[Service]\nRestart=always\nExecStart=/usr/bin/b\nRestartSec=5\n"
+	want := "-ExecStart=/usr/bin/a\n+ExecStart=/usr/bin/b\n+RestartSec=5\n"
+	if got := Diff(old, new); got != want {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+	if got := Diff(old, old); got != "" {
+		t.Errorf("Diff() = %q for identical files, want empty", got)
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"` // rejected, queued, running, succeeded or failed
+	Action           // Empty when the request was rejected before its body was read
+	Remote string    `json:"remote,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// AuditLog appends one JSON object per line for every webhook request and action state change
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenAuditLog opens the audit log at path for appending
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// Record appends an entry; an entry that cannot be written is lost, which is not worth failing the request for
+func (a *AuditLog) Record(entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, _ = a.file.Write(append(data, '\n'))
+}
+
+// Close closes the audit log
+func (a *AuditLog) Close() error {
+	return a.file.Close()
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientTimeout bounds each request to an agent; actions run asynchronously, so requests return quickly
+const clientTimeout = 30 * time.Second
+
+// ErrUnknownAction is returned by Client.Get when the agent does not know the action, e.g. after it restarted
+var ErrUnknownAction = errors.New("agent does not know the action")
+
+// Client sends signed requests to an agent's webhook listener
+type Client struct {
+	endpoint string
+	secret   []byte
+	http     *http.Client
+	now      func() time.Time
+}
+
+// NewClient creates a client for the listener at endpoint, e.g. https://edge-01:8443. caBundle holds PEM
+// certificates to trust for the listener's certificate in addition to the system roots, and may be empty.
+func NewClient(endpoint string, secret []byte, caBundle []byte) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(caBundle) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("CA bundle for %s holds no PEM certificates", endpoint)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		secret:   secret,
+		http:     &http.Client{Timeout: clientTimeout, Transport: transport},
+		now:      time.Now,
+	}, nil
+}
+
+// Submit queues an action on the agent
+func (c *Client) Submit(ctx context.Context, action Action) (*Record, error) {
+	body, err := json.Marshal(action)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, "/v1/actions", body, http.StatusAccepted)
+}
+
+// Get returns the state of an action submitted earlier
+func (c *Client) Get(ctx context.Context, id string) (*Record, error) {
+	return c.do(ctx, http.MethodGet, "/v1/actions/"+id, nil, http.StatusOK)
+}
+
+// Status returns the node status the agent last collected, as JSON
+func (c *Client) Status(ctx context.Context) (json.RawMessage, error) {
+	data, err := c.send(ctx, http.MethodGet, "/v1/status", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid status from %s", c.endpoint)
+	}
+	return data, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, want int) (*Record, error) {
+	data, err := c.send(ctx, method, path, body, want)
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %w", c.endpoint, err)
+	}
+	return &record, nil
+}
+
+// send signs and sends a request and returns the response body when the agent answered with want
+func (c *Client) send(ctx context.Context, method, path string, body []byte, want int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(c.now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(Sign(c.secret, timestamp, method, req.URL.Path, body)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", c.endpoint, err)
+	}
+	if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/v1/actions/") {
+		return nil, ErrUnknownAction
+	}
+	if resp.StatusCode != want {
+		return nil, fmt.Errorf("%s %s%s: %s: %s", method, c.endpoint, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// drainTimeout bounds how long kubectl waits for pods to be evicted
+const drainTimeout = 10 * time.Minute
+
+// Drainer cordons, drains and removes the node with kubectl, using a kubeconfig allowed to do so
+type Drainer struct {
+	kubeconfig string
+	run        func(ctx context.Context, name string, args ...string) ([]byte, error)
+	hostname   func() (string, error)
+}
+
+// NewDrainer creates a drainer that runs kubectl with kubeconfig
+func NewDrainer(kubeconfig string) *Drainer {
+	return &Drainer{
+		kubeconfig: kubeconfig,
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, name, args...).CombinedOutput()
+		},
+		hostname: os.Hostname,
+	}
+}
+
+// Drain cordons the node and evicts its pods, leaving DaemonSet pods in place
+func (d *Drainer) Drain(ctx context.Context) error {
+	return d.kubectl(ctx, "drain", "--ignore-daemonsets", "--delete-emptydir-data", "--timeout="+drainTimeout.String())
+}
+
+// Uncordon makes the node schedulable again
+func (d *Drainer) Uncordon(ctx context.Context) error {
+	return d.kubectl(ctx, "uncordon")
+}
+
+// Delete removes the node object, so the cluster forgets a node that moved to another cluster
+func (d *Drainer) Delete(ctx context.Context) error {
+	return d.kubectl(ctx, "delete node", "--wait=false")
+}
+
+func (d *Drainer) kubectl(ctx context.Context, command string, flags ...string) error {
+	// The kubelet registers the node under its lower-cased hostname
+	hostname, err := d.hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine node name: %w", err)
+	}
+	node := strings.ToLower(hostname)
+
+	args := append([]string{"--kubeconfig", d.kubeconfig}, strings.Fields(command)...)
+	args = append(append(args, node), flags...)
+	if output, err := d.run(ctx, "kubectl", args...); err != nil {
+		return fmt.Errorf("kubectl %s %s failed: %w: %s", command, node, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	// StateDir holds the NodeSpec written by upgrade actions
+	StateDir = "/var/lib/aks-flex-node/webhook"
+	// SpecPath is the NodeSpec of the last successful upgrade, loaded on top of the local configuration
+	// so the agent keeps the upgraded version when it restarts
+	SpecPath = StateDir + "/nodespec.yaml"
+	// PendingSpecPath holds the NodeSpec of an upgrade while it is applied
+	PendingSpecPath = StateDir + "/pending-nodespec.yaml"
+)
+
+// UpgradeSpec returns the NodeSpec an upgrade applies on top of the local configuration
+func UpgradeSpec(kubernetesVersion string) []byte {
+	spec := map[string]any{
+		"apiVersion": config.NodeSpecAPIVersion,
+		"kind":       config.NodeSpecKind,
+		"metadata":   map[string]any{"name": "webhook-upgrade"},
+		"spec":       map[string]any{"kubernetes": map[string]any{"version": kubernetesVersion}},
+	}
+	// JSON is valid YAML, and the map always encodes
+	data, _ := json.MarshalIndent(spec, "", "  ")
+	return append(data, '\n')
+}
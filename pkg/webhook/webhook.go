@@ -0,0 +1,398 @@
+// Package webhook implements the agent's listener for provisioning actions sent by a central controller.
+// Requests are signed with a shared secret, only allowlisted actions are accepted, and every request is
+// recorded in an audit log. Accepted actions are queued for the agent daemon, which runs them one at a time.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/secrets"
+)
+
+// Actions a controller can request
+const (
+	Bootstrap = "bootstrap" // Converge the node to its configuration
+	Install   = "install"   // Run one bootstrap step again, e.g. to reinstall containerd
+	Upgrade   = "upgrade"   // Move the node to another Kubernetes version
+	Drain     = "drain"     // Cordon the node and evict its pods
+	Uncordon  = "uncordon"  // Make the node schedulable again
+)
+
+// States of an accepted action
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+const (
+	// TimestampHeader carries the Unix time the request was signed at
+	TimestampHeader = "X-Flex-Node-Timestamp"
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the timestamp, method, path and body,
+	// joined by dots, so a signed request cannot be replayed against another endpoint
+	SignatureHeader = "X-Flex-Node-Signature"
+
+	// maxClockSkew bounds how old a signed request may be, which limits replays
+	maxClockSkew = 5 * time.Minute
+	// recordRetention is how long finished actions can be looked up; longer than maxClockSkew so replayed IDs are caught
+	recordRetention = time.Hour
+	// queueSize bounds the actions waiting for the one that runs
+	queueSize = 8
+	// maxBodySize bounds the request body
+	maxBodySize = 64 << 10
+)
+
+var (
+	idPattern      = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	versionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+)
+
+// Action is a provisioning action requested by a controller
+type Action struct {
+	ID                string `json:"id"`                          // Chosen by the controller, unique per node
+	Action            string `json:"action"`                      // One of the allowlisted actions
+	Component         string `json:"component,omitempty"`         // Bootstrap step to run again, for install
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"` // Target version, for upgrade
+}
+
+// Record is the state of an accepted action, returned to the controller
+type Record struct {
+	Action
+	State      string    `json:"state"`
+	Error      string    `json:"error,omitempty"`
+	QueuedAt   time.Time `json:"queuedAt"`
+	FinishedAt time.Time `json:"finishedAt,omitzero"`
+}
+
+// Server accepts signed provisioning actions and queues them for the agent daemon
+type Server struct {
+	address  string
+	certFile string
+	keyFile  string
+	secret   []byte
+	allowed  map[string]bool
+	steps    map[string]bool
+	audit    *AuditLog
+	logger   *logrus.Logger
+	actions  chan Action
+	now      func() time.Time
+	// statusFile is the status the daemon last collected, served to fleet status queries
+	statusFile string
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// NewServer creates the listener configured in agent.webhook. stepNames are the bootstrap steps install can run.
+// The shared secret is read from agent.webhook.secretFile or resolved from the reference in agent.webhook.secret.
+func NewServer(ctx context.Context, cfg *config.Config, stepNames []string, logger *logrus.Logger) (*Server, error) {
+	webhook := cfg.Agent.Webhook
+	reference, source := webhook.Secret, secrets.Redact(webhook.Secret)
+	if reference == "" {
+		reference, source = secrets.FileReference(webhook.SecretFile), webhook.SecretFile
+	}
+	value, err := secrets.ResolveString(ctx, cfg, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook secret: %w", err)
+	}
+	secret := []byte(value)
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("webhook secret in %s is shorter than 32 characters", source)
+	}
+	audit, err := OpenAuditLog(cfg.GetWebhookAuditLogPath())
+	if err != nil {
+		return nil, err
+	}
+
+	s := newServer(secret, webhook.AllowedActions, stepNames, audit, logger)
+	s.address, s.certFile, s.keyFile = webhook.ListenAddress, webhook.TLSCertFile, webhook.TLSKeyFile
+	return s, nil
+}
+
+func newServer(secret []byte, allowedActions, stepNames []string, audit *AuditLog, logger *logrus.Logger) *Server {
+	s := &Server{
+		secret:  secret,
+		allowed: map[string]bool{},
+		steps:   map[string]bool{},
+		audit:   audit,
+		logger:  logger,
+		actions: make(chan Action, queueSize),
+		now:     time.Now,
+		records: map[string]*Record{},
+	}
+	for _, action := range allowedActions {
+		s.allowed[action] = true
+	}
+	for _, name := range stepNames {
+		s.steps[name] = true
+	}
+	return s
+}
+
+// ServeStatus makes the listener answer status queries with the node status in path
+func (s *Server) ServeStatus(path string) {
+	s.statusFile = path
+}
+
+// Actions returns the queue of accepted actions for the daemon to run
+func (s *Server) Actions() <-chan Action {
+	return s.actions
+}
+
+// Started records that the daemon started running an action
+func (s *Server) Started(action Action) {
+	s.setState(action, Running, nil)
+}
+
+// Finished records the outcome of an action
+func (s *Server) Finished(action Action, err error) {
+	if err != nil {
+		s.setState(action, Failed, err)
+		return
+	}
+	s.setState(action, Succeeded, nil)
+}
+
+func (s *Server) setState(action Action, state string, err error) {
+	s.mu.Lock()
+	if record, ok := s.records[action.ID]; ok {
+		record.State = state
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if state == Succeeded || state == Failed {
+			record.FinishedAt = s.now()
+		}
+	}
+	s.mu.Unlock()
+	s.audit.Record(AuditEntry{Time: s.now(), Event: state, Action: action, Error: errorString(err)})
+}
+
+// Handler returns the HTTP API of the listener
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/actions", s.handleSubmit)
+	mux.HandleFunc("GET /v1/actions/{id}", s.handleGet)
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	return mux
+}
+
+// Serve listens on the configured address until ctx is done, over HTTPS when a certificate is configured
+func (s *Server) Serve(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if s.certFile != "" {
+		s.logger.Infof("Listening for provisioning actions on https://%s", s.address)
+		err = server.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		s.logger.Warnf("Listening for provisioning actions on http://%s without TLS: actions and the node status travel "+
+			"in the clear; set agent.webhook.tlsCertFile outside of trusted networks", s.address)
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("webhook listener failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var action Action
+	if err := json.Unmarshal(body, &action); err != nil {
+		s.reject(w, r, action, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := s.validate(action); err != nil {
+		s.reject(w, r, action, http.StatusBadRequest, err)
+		return
+	}
+	if !s.allowed[action.Action] {
+		s.reject(w, r, action, http.StatusForbidden, fmt.Errorf("action %q is not allowed on this node", action.Action))
+		return
+	}
+
+	s.mu.Lock()
+	s.prune()
+	if _, ok := s.records[action.ID]; ok {
+		s.mu.Unlock()
+		s.reject(w, r, action, http.StatusConflict, fmt.Errorf("action %s was already submitted", action.ID))
+		return
+	}
+	record := &Record{Action: action, State: Queued, QueuedAt: s.now()}
+	select {
+	case s.actions <- action:
+		s.records[action.ID] = record
+	default:
+		s.mu.Unlock()
+		s.reject(w, r, action, http.StatusTooManyRequests, fmt.Errorf("%d actions are already waiting", queueSize))
+		return
+	}
+	response := *record
+	s.mu.Unlock()
+
+	s.audit.Record(AuditEntry{Time: s.now(), Event: Queued, Action: action, Remote: r.RemoteAddr})
+	s.logger.Infof("Accepted %s action %s from %s", action.Action, action.ID, r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+	s.mu.Lock()
+	record, ok := s.records[r.PathValue("id")]
+	var response Record
+	if ok {
+		response = *record
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+	if s.statusFile == "" {
+		http.Error(w, "status is not served", http.StatusNotFound)
+		return
+	}
+	data, err := os.ReadFile(s.statusFile)
+	if err != nil || !json.Valid(data) {
+		// The daemon writes the status once a minute; it may not have written it yet
+		http.Error(w, "no status collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// authenticate reads the body and checks its signature and age
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		s.reject(w, r, Action{}, http.StatusRequestEntityTooLarge, fmt.Errorf("failed to read request body: %w", err))
+		return nil, false
+	}
+	if err := s.verify(r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), r.Method, r.URL.Path, body); err != nil {
+		s.reject(w, r, Action{}, http.StatusUnauthorized, err)
+		return nil, false
+	}
+	return body, true
+}
+
+// verify checks the signature of a request and that it was signed recently
+func (s *Server) verify(timestamp, signature, method, path string, body []byte) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid %s header", TimestampHeader)
+	}
+	if age := s.now().Sub(time.Unix(seconds, 0)); age > maxClockSkew || age < -maxClockSkew {
+		return fmt.Errorf("request was signed %s ago, more than the allowed %s", age.Round(time.Second), maxClockSkew)
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("missing or invalid %s header", SignatureHeader)
+	}
+	if !hmac.Equal(got, Sign(s.secret, timestamp, method, path, body)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// validate checks the parameters of an action
+func (s *Server) validate(action Action) error {
+	if !idPattern.MatchString(action.ID) {
+		return fmt.Errorf("invalid id: %q. Expected up to 64 letters, digits, dots, dashes or underscores", action.ID)
+	}
+	switch action.Action {
+	case Install:
+		if !s.steps[action.Component] {
+			return fmt.Errorf("invalid component: %q. Expected the name of a bootstrap step", action.Component)
+		}
+	case Upgrade:
+		if !versionPattern.MatchString(action.KubernetesVersion) {
+			return fmt.Errorf("invalid kubernetesVersion: %q. Expected a version such as 1.31.2", action.KubernetesVersion)
+		}
+	case Bootstrap, Drain, Uncordon:
+	default:
+		return fmt.Errorf("unknown action: %q", action.Action)
+	}
+	return nil
+}
+
+// reject answers a request with an error and records it in the audit log
+func (s *Server) reject(w http.ResponseWriter, r *http.Request, action Action, status int, err error) {
+	s.audit.Record(AuditEntry{Time: s.now(), Event: "rejected", Action: action, Remote: r.RemoteAddr, Error: err.Error()})
+	s.logger.Warnf("Rejected webhook request from %s: %v", r.RemoteAddr, err)
+	http.Error(w, err.Error(), status)
+}
+
+// prune forgets finished actions after recordRetention; callers hold s.mu
+func (s *Server) prune() {
+	for id, record := range s.records {
+		if !record.FinishedAt.IsZero() && s.now().Sub(record.FinishedAt) > recordRetention {
+			delete(s.records, id)
+		}
+	}
+}
+
+// Sign returns the raw HMAC-SHA256 bytes of a request. Controllers hex encode them and prefix "sha256=" to
+// build SignatureHeader.
+// path is the path of the request URL, e.g. /v1/actions.
+func Sign(secret []byte, timestamp, method, path string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "." + method + "." + path + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,264 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestServer(t *testing.T, allowed ...string) (*Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "webhook-audit.log")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = audit.Close() })
+	s := newServer(testSecret, allowed, []string{"ContainerdInstaller", "KubeletInstaller"}, audit, logrus.New())
+	s.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	return s, path
+}
+
+// signedRequest builds a request signed with secret at the given Unix time
+func signedRequest(method, target string, body []byte, secret []byte, at int64) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	timestamp := strconv.FormatInt(at, 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(Sign(secret, timestamp, method, req.URL.Path, body)))
+	return req
+}
+
+func submit(s *Server, action Action, secret []byte, at int64) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(action)
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, signedRequest(http.MethodPost, "/v1/actions", body, secret, at))
+	return recorder
+}
+
+func TestSubmitAction(t *testing.T) {
+	s, auditPath := newTestServer(t, Install, Bootstrap)
+	now := s.now().Unix()
+
+	action := Action{ID: "rollout-42", Action: Install, Component: "ContainerdInstaller"}
+	if got := submit(s, action, testSecret, now); got.Code != http.StatusAccepted {
+		t.Fatalf("submit() = %d %s, want 202", got.Code, got.Body)
+	}
+	select {
+	case queued := <-s.Actions():
+		if queued != action {
+			t.Errorf("Actions() = %+v, want %+v", queued, action)
+		}
+	default:
+		t.Fatal("submit() did not queue the action")
+	}
+
+	s.Started(action)
+	s.Finished(action, errors.New("download failed"))
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, signedRequest(http.MethodGet, "/v1/actions/rollout-42", nil, testSecret, now))
+	var record Record
+	if err := json.Unmarshal(recorder.Body.Bytes(), &record); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("GET action = %d %s, want the record", recorder.Code, recorder.Body)
+	}
+	if record.State != Failed || record.Error != "download failed" {
+		t.Errorf("GET action = %+v, want it failed with the error", record)
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("audit log line %q is not JSON: %v", line, err)
+		}
+		if entry.ID != "rollout-42" {
+			t.Errorf("audit entry %q lacks the action", line)
+		}
+		events = append(events, entry.Event)
+	}
+	if got := strings.Join(events, ","); got != "queued,running,failed" {
+		t.Errorf("audit events = %s, want queued,running,failed", got)
+	}
+}
+
+func TestRejectedRequests(t *testing.T) {
+	s, auditPath := newTestServer(t, Install, Upgrade)
+	now := s.now().Unix()
+	install := Action{ID: "a1", Action: Install, Component: "ContainerdInstaller"}
+
+	tests := []struct {
+		name   string
+		action Action
+		secret []byte
+		at     int64
+		want   int
+	}{
+		{name: "wrong secret", action: install, secret: []byte("another-secret-of-sufficient-length"), at: now, want: http.StatusUnauthorized},
+		{name: "stale signature", action: install, secret: testSecret, at: now - 600, want: http.StatusUnauthorized},
+		{name: "action not allowed", action: Action{ID: "a2", Action: Drain}, secret: testSecret, at: now, want: http.StatusForbidden},
+		{name: "unknown action", action: Action{ID: "a3", Action: "exec"}, secret: testSecret, at: now, want: http.StatusBadRequest},
+		{name: "unknown component", action: Action{ID: "a4", Action: Install, Component: "sshd"}, secret: testSecret, at: now, want: http.StatusBadRequest},
+		{name: "invalid version", action: Action{ID: "a5", Action: Upgrade, KubernetesVersion: "latest"}, secret: testSecret, at: now, want: http.StatusBadRequest},
+		{name: "invalid id", action: Action{ID: "../a6", Action: Install, Component: "KubeletInstaller"}, secret: testSecret, at: now, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := submit(s, tt.action, tt.secret, tt.at); got.Code != tt.want {
+				t.Errorf("submit() = %d %s, want %d", got.Code, got.Body, tt.want)
+			}
+		})
+	}
+	if len(s.Actions()) != 0 {
+		t.Errorf("rejected requests queued %d actions", len(s.Actions()))
+	}
+
+	if got := submit(s, install, testSecret, now); got.Code != http.StatusAccepted {
+		t.Fatalf("submit() = %d %s, want 202", got.Code, got.Body)
+	}
+	if got := submit(s, install, testSecret, now); got.Code != http.StatusConflict {
+		t.Errorf("replayed submit() = %d, want 409", got.Code)
+	}
+
+	data, _ := os.ReadFile(auditPath)
+	if got := strings.Count(string(data), `"event":"rejected"`); got != len(tests)+1 {
+		t.Errorf("audit log has %d rejected entries, want %d", got, len(tests)+1)
+	}
+}
+
+func TestSignatureCoversMethodAndPath(t *testing.T) {
+	s, _ := newTestServer(t, Bootstrap)
+	s.ServeStatus(filepath.Join(t.TempDir(), "status.json"))
+	signed := signedRequest(http.MethodGet, "/v1/actions/a1", nil, testSecret, s.now().Unix())
+
+	// The headers of a signed request are replayed against another endpoint of the listener
+	for _, target := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/status"},
+		{http.MethodGet, "/v1/actions/a2"},
+		{http.MethodPost, "/v1/actions"},
+	} {
+		req := httptest.NewRequest(target.method, target.path, nil)
+		req.Header = signed.Header.Clone()
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s with the signature of GET /v1/actions/a1 = %d, want 401", target.method, target.path, recorder.Code)
+		}
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	s, _ := newTestServer(t, Bootstrap)
+	for i := 0; i < queueSize; i++ {
+		if got := submit(s, Action{ID: "b" + strconv.Itoa(i), Action: Bootstrap}, testSecret, s.now().Unix()); got.Code != http.StatusAccepted {
+			t.Fatalf("submit() #%d = %d, want 202", i, got.Code)
+		}
+	}
+	if got := submit(s, Action{ID: "overflow", Action: Bootstrap}, testSecret, s.now().Unix()); got.Code != http.StatusTooManyRequests {
+		t.Errorf("submit() with a full queue = %d, want 429", got.Code)
+	}
+}
+
+func TestDrainer(t *testing.T) {
+	var commands []string
+	d := &Drainer{
+		kubeconfig: "/etc/aks-flex-node/drain.kubeconfig",
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			commands = append(commands, name+" "+strings.Join(args, " "))
+			return nil, nil
+		},
+		hostname: func() (string, error) { return "Edge-01", nil },
+	}
+	if err := d.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+	if err := d.Uncordon(context.Background()); err != nil {
+		t.Fatalf("Uncordon() unexpected error: %v", err)
+	}
+	if err := d.Delete(context.Background()); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	want := []string{
+		"kubectl --kubeconfig /etc/aks-flex-node/drain.kubeconfig drain edge-01 --ignore-daemonsets --delete-emptydir-data --timeout=10m0s",
+		"kubectl --kubeconfig /etc/aks-flex-node/drain.kubeconfig uncordon edge-01",
+		"kubectl --kubeconfig /etc/aks-flex-node/drain.kubeconfig delete node edge-01 --wait=false",
+	}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ran %q, want %q", commands, want)
+	}
+}
+
+func TestClient(t *testing.T) {
+	s, _ := newTestServer(t, Bootstrap)
+	s.now = time.Now
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	client, err := NewClient(server.URL, testSecret, nil)
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	record, err := client.Submit(context.Background(), Action{ID: "reconcile-1", Action: Bootstrap})
+	if err != nil || record.State != Queued {
+		t.Fatalf("Submit() = %+v, %v, want the action queued", record, err)
+	}
+	<-s.Actions()
+	s.Started(record.Action)
+	s.Finished(record.Action, nil)
+
+	if record, err = client.Get(context.Background(), "reconcile-1"); err != nil || record.State != Succeeded {
+		t.Errorf("Get() = %+v, %v, want the action succeeded", record, err)
+	}
+	if _, err := client.Get(context.Background(), "unknown"); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("Get() error = %v for an unknown action, want ErrUnknownAction", err)
+	}
+
+	forged, _ := NewClient(server.URL, []byte("not-the-secret-not-the-secret-not"), nil)
+	if _, err := forged.Submit(context.Background(), Action{ID: "reconcile-2", Action: Bootstrap}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Submit() with the wrong secret error = %v, want 401", err)
+	}
+}
+
+func TestClientStatus(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.now = time.Now
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	client, err := NewClient(server.URL, testSecret, nil)
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+
+	if _, err := client.Status(context.Background()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Status() error = %v without a status file, want 404", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "status.json")
+	s.ServeStatus(path)
+	if _, err := client.Status(context.Background()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Status() error = %v before the status was collected, want 503", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"kubeletRunning": true}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Status(context.Background())
+	if err != nil || string(data) != `{"kubeletRunning": true}` {
+		t.Errorf("Status() = %s, %v, want the status file", data, err)
+	}
+}
@@ -416,6 +416,7 @@ show_next_steps() {
     echo ""
     echo -e "${YELLOW}Next Steps:${NC}"
     echo "1. Create configuration file: $CONFIG_DIR/config.json"
+    echo "   (or generate it interactively: sudo aks-flex-node init)"
     echo ""
     echo -e "${YELLOW}Example configuration:${NC}"
     cat << 'EOF'
@@ -86,6 +86,12 @@ stop_and_disable_services() {
 run_unbootstrap() {
     log_info "Running unbootstrap to clean up cluster and Arc resources..."
 
+    # unbootstrap refuses to remove a node that still runs workloads; a forced uninstall forces it too
+    local force_flag=""
+    if [[ "${1:-}" == "--force" ]]; then
+        force_flag="--force"
+    fi
+
     # Check if aks-flex-node binary exists
     if [[ ! -f "$INSTALL_DIR/aks-flex-node" ]]; then
         log_warning "AKS Flex Node binary not found at $INSTALL_DIR/aks-flex-node"
@@ -120,22 +126,38 @@ run_unbootstrap() {
         
         # Added TERM=$TERM to ensure the tool knows it can print formatted text
         # Added 2>&1 to capture Standard Error logs alongside Standard Out
-        sudo env AZURE_CONFIG_DIR="$azure_config_dir" TERM="$TERM" "$INSTALL_DIR/aks-flex-node" unbootstrap --config "$config_file" 2>&1 || {
+        sudo env AZURE_CONFIG_DIR="$azure_config_dir" TERM="$TERM" "$INSTALL_DIR/aks-flex-node" unbootstrap --config "$config_file" ${force_flag:+"$force_flag"} 2>&1 || {
             log_warning "Unbootstrap failed - this may be expected if resources are already cleaned up"
+            confirm_after_failed_unbootstrap "${1:-}"
         }
     else
         log_warning "Azure CLI credentials not found at $azure_config_dir"
         log_info "Attempting unbootstrap without Azure CLI credentials..."
         
         # Applied the same fixes here
-        sudo env TERM="$TERM" "$INSTALL_DIR/aks-flex-node" unbootstrap --config "$config_file" 2>&1 || {
+        sudo env TERM="$TERM" "$INSTALL_DIR/aks-flex-node" unbootstrap --config "$config_file" ${force_flag:+"$force_flag"} 2>&1 || {
             log_warning "Unbootstrap failed - this may be expected if resources are already cleaned up"
+            confirm_after_failed_unbootstrap "${1:-}"
         }
     fi
 
     log_success "Unbootstrap completed"
 }
 
+# confirm_after_failed_unbootstrap lets the user stop the uninstall, e.g. when unbootstrap refused to remove
+# a node that still runs workloads
+confirm_after_failed_unbootstrap() {
+    if [[ "${1:-}" == "--force" ]]; then
+        return 0
+    fi
+    read -p "Continue removing AKS Flex Node anyway? (y/N): " -n 1 -r response </dev/tty
+    echo
+    if [[ ! $response =~ ^[Yy]$ ]]; then
+        echo "Uninstall cancelled."
+        exit 0
+    fi
+}
+
 remove_systemd_service() {
     log_info "Removing systemd service files..."
 
@@ -260,7 +282,7 @@ main() {
     log_info "Starting AKS Flex Node uninstallation..."
 
     # Run unbootstrap before proceeding with uninstall
-    run_unbootstrap
+    run_unbootstrap "${1:-}"
 
     # Uninstall components in reverse order of installation
     stop_and_disable_services